@@ -2,7 +2,8 @@ package main
 
 import (
 	"context"
-	"log"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
@@ -11,32 +12,67 @@ import (
 	"time"
 
 	"ccgateway/internal/agentteam"
+	"ccgateway/internal/anomaly"
+	"ccgateway/internal/auditlog"
 	"ccgateway/internal/auth"
+	"ccgateway/internal/batch"
+	"ccgateway/internal/billing"
 	"ccgateway/internal/ccevent"
 	"ccgateway/internal/ccrun"
 	"ccgateway/internal/channel"
+	"ccgateway/internal/configfile"
+	"ccgateway/internal/cron"
+	"ccgateway/internal/dataprotect"
+	"ccgateway/internal/egress"
+	"ccgateway/internal/eval"
+	"ccgateway/internal/featureflag"
+	"ccgateway/internal/files"
 	"ccgateway/internal/gateway"
+	"ccgateway/internal/guardrail"
+	"ccgateway/internal/idgen"
+	"ccgateway/internal/imageproc"
+	"ccgateway/internal/ipaccess"
+	"ccgateway/internal/logging"
 	"ccgateway/internal/marketplace"
 	"ccgateway/internal/mcpregistry"
 	"ccgateway/internal/memory"
 	"ccgateway/internal/modelmap"
+	"ccgateway/internal/notification"
 	"ccgateway/internal/plan"
 	"ccgateway/internal/plugin"
 	"ccgateway/internal/policy"
 	"ccgateway/internal/probe"
+	"ccgateway/internal/project"
 	"ccgateway/internal/runlog"
 	"ccgateway/internal/scheduler"
+	"ccgateway/internal/secretscan"
+	"ccgateway/internal/servertools"
 	"ccgateway/internal/session"
 	"ccgateway/internal/settings"
 	"ccgateway/internal/statepersist"
+	"ccgateway/internal/storage"
 	"ccgateway/internal/subagent"
+	"ccgateway/internal/tlsserve"
 	"ccgateway/internal/todo"
 	"ccgateway/internal/token"
+	"ccgateway/internal/tokenizer"
 	"ccgateway/internal/toolcatalog"
+	"ccgateway/internal/trafficsample"
 	"ccgateway/internal/upstream"
+	"ccgateway/internal/webhook"
+	"ccgateway/internal/workspace"
 )
 
 func main() {
+	rootLogger, err := logging.FromEnv()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	// The stdlib log package now writes through the structured handler too.
+	slog.SetDefault(rootLogger)
+	logger := logging.Component("main")
+
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
@@ -44,12 +80,32 @@ func main() {
 
 	routes, err := upstream.ParseRoutesFromEnv()
 	if err != nil {
-		log.Fatalf("invalid upstream route config: %v", err)
+		fatal("invalid upstream route config", err)
 	}
 
-	adapters, err := upstream.ParseAdaptersFromEnv()
+	offline, err := upstream.OfflineModeFromEnv()
 	if err != nil {
-		log.Fatalf("invalid upstream adapter config: %v", err)
+		fatal("invalid offline mode config", err)
+	}
+	var adapters []upstream.Adapter
+	if offline != nil {
+		specs, err := upstream.ParseAdapterSpecsFromEnv()
+		if err != nil {
+			fatal("invalid upstream adapter config", err)
+		}
+		if len(specs) == 0 {
+			specs = []upstream.AdapterSpec{{Name: "mock-primary"}, {Name: "mock-fallback"}}
+		}
+		adapters, err = offline.BuildAdapters(specs)
+		if err != nil {
+			fatal("invalid upstream adapter config", err)
+		}
+		logger.Warn("OFFLINE MODE: upstream adapters replaced by fixture stand-ins", "adapters", len(adapters), "fixtures", len(offline.Fixtures))
+	} else {
+		adapters, err = upstream.ParseAdaptersFromEnv()
+		if err != nil {
+			fatal("invalid upstream adapter config", err)
+		}
 	}
 	defaultRouteFallback := []string{}
 	if len(adapters) == 0 {
@@ -64,17 +120,17 @@ func main() {
 	}
 	selector, err := scheduler.NewFromEnv(defaultRouteFallback)
 	if err != nil {
-		log.Fatalf("invalid scheduler config: %v", err)
+		fatal("invalid scheduler config", err)
 	}
 	judge, err := upstream.NewJudgeFromEnv(adapters, defaultRouteFallback)
 	if err != nil {
-		log.Fatalf("invalid judge config: %v", err)
+		fatal("invalid judge config", err)
 	}
 
 	// Initialize settings first to get intelligent dispatch config
 	settingsStore, err := settings.NewFromEnv()
 	if err != nil {
-		log.Fatalf("invalid runtime settings: %v", err)
+		fatal("invalid runtime settings", err)
 	}
 	runtimeSettings := settingsStore.Get()
 
@@ -84,17 +140,29 @@ func main() {
 		MinScoreDifference: runtimeSettings.IntelligentDispatch.MinScoreDifference,
 		ReElectInterval:    time.Duration(runtimeSettings.IntelligentDispatch.ReElectIntervalMS) * time.Millisecond,
 	})
-	election.SetOnChange(func(result scheduler.ElectionResult) {
-		log.Printf("election: scheduler=%s (score=%.0f), workers=%d, reason=%s",
-			result.SchedulerAdapter, result.SchedulerScore,
-			len(result.Workers), result.Reason)
-	})
 
 	// Dispatcher: routes complex requests to scheduler, simple to workers
 	// Default enabled=true from settings
+	adapterPrices := make(map[string]upstream.AdapterPrice, len(runtimeSettings.IntelligentDispatch.AdapterPrices))
+	for name, p := range runtimeSettings.IntelligentDispatch.AdapterPrices {
+		adapterPrices[name] = upstream.AdapterPrice(p)
+	}
 	dispatcher := upstream.NewDispatcher(upstream.DispatchConfig{
-		Enabled: runtimeSettings.IntelligentDispatch.Enabled,
+		Enabled:                        runtimeSettings.IntelligentDispatch.Enabled,
+		AdapterPrices:                  adapterPrices,
+		PreferCheapestWithinScoreDelta: runtimeSettings.IntelligentDispatch.PreferCheapestWithinScoreDelta,
 	}, election)
+	dispatchHistory, err := upstream.DispatchHistoryFromEnv()
+	if err != nil {
+		fatal("failed to init dispatch history", err)
+	}
+	defer dispatchHistory.Close()
+	dispatcher.SetHistory(dispatchHistory)
+	judgeHistory, err := upstream.JudgeHistoryFromEnv()
+	if err != nil {
+		fatal("failed to init judge history", err)
+	}
+	defer judgeHistory.Close()
 
 	svc := upstream.NewRouterService(upstream.RouterConfig{
 		Routes:              routes,
@@ -105,37 +173,99 @@ func main() {
 		ParallelCandidates:  upstream.ParseIntEnv("PARALLEL_CANDIDATES", 1),
 		EnableResponseJudge: upstream.ParseBoolEnv("ENABLE_RESPONSE_JUDGE", false),
 		Judge:               judge,
+		JudgeHistory:        judgeHistory,
 		Selector:            selector,
 		Dispatcher:          dispatcher,
+		Offline:             offline,
+		CapabilityTTL:       upstream.ParseDurationEnv("UPSTREAM_CAPABILITY_TTL", time.Hour),
+		QueueTimeout:        upstream.ParseDurationEnv("UPSTREAM_QUEUE_TIMEOUT", 30*time.Second),
 	}, adapters)
 	mapper, err := modelmap.NewFromEnv()
 	if err != nil {
-		log.Fatalf("invalid model mapping config: %v", err)
+		fatal("invalid model mapping config", err)
 	}
 	// settingsStore already initialized above for intelligent dispatch
 	toolsBase, err := toolcatalog.NewFromEnv()
 	if err != nil {
-		log.Fatalf("invalid tool catalog: %v", err)
+		fatal("invalid tool catalog", err)
 	}
 	tools := toolcatalog.NewScopedCatalog(toolsBase.Snapshot())
 	logPath := os.Getenv("RUN_LOG_PATH")
 	if logPath == "" {
 		logPath = "logs/run-events.log"
 	}
-	runLogger, err := runlog.NewFileLogger(logPath)
+	runFile, err := runlog.NewFileLogger(logPath)
 	if err != nil {
-		log.Fatalf("failed to init run logger: %v", err)
+		fatal("failed to init run logger", err)
 	}
+	runLogger := runlog.Multi{runFile, runlog.NewSlogLogger(logging.Component("runlog"))}
+	var runLogShipper *runlog.ShippingLogger
+	if kind := strings.TrimSpace(os.Getenv("RUN_LOG_STORAGE")); kind != "" {
+		store, err := storage.NewObjectBackendFromEnv(kind, os.Getenv("RUN_LOG_S3_BUCKET"), os.Getenv("RUN_LOG_S3_PREFIX"))
+		if err != nil {
+			fatal("invalid run log storage", err)
+		}
+		runLogShipper = runlog.NewShippingLogger(store, runlog.ShipConfig{
+			Interval:    upstream.ParseDurationEnv("RUN_LOG_SHIP_INTERVAL", time.Minute),
+			MaxBuffered: upstream.ParseIntEnv("RUN_LOG_SHIP_BUFFER", 10000),
+		})
+		runLogger = append(runLogger, runLogShipper)
+	}
+	adminAudit, err := auditlog.NewFromEnv()
+	if err != nil {
+		fatal("failed to init admin audit log", err)
+	}
+	featureFlags, err := featureflag.NewFromEnv()
+	if err != nil {
+		fatal("invalid feature flag config", err)
+	}
+	dataKeys, err := dataprotect.KeyringFromEnv()
+	if err != nil {
+		fatal("invalid data protection config", err)
+	}
+	idGenerator, err := idgen.FromEnv()
+	if err != nil {
+		fatal("invalid id generator config", err)
+	}
+	usageLedger, err := billing.LedgerFromEnv()
+	if err != nil {
+		fatal("failed to init usage ledger", err)
+	}
+	defer usageLedger.Close()
 	probeCfg, err := probe.ConfigFromEnv()
 	if err != nil {
-		log.Fatalf("invalid probe config: %v", err)
+		fatal("invalid probe config", err)
 	}
 	probeRunner := probe.NewRunner(probeCfg, adapters, selector)
+	if err := probeRunner.LoadIntelligenceTasks(probe.IntelligenceTasksPathFromEnv()); err != nil {
+		fatal("failed to load intelligence probe tasks", err)
+	}
+	// Intelligence evaluation: re-run on a schedule by the probe runner;
+	// the election ranks adapters on their recent score trend.
+	if upstream.ParseBoolEnv("ENABLE_TASK_DISPATCH", false) && len(adapters) > 1 {
+		intelHistory, err := probe.IntelligenceHistoryFromEnv()
+		if err != nil {
+			fatal("failed to init intelligence history", err)
+		}
+		defer intelHistory.Close()
+		probeRunner.EnableIntelligence(probe.IntelligenceConfig{
+			Interval: upstream.ParseDurationEnv("INTEL_PROBE_INTERVAL", time.Duration(runtimeSettings.IntelligentDispatch.ReElectIntervalMS)*time.Millisecond),
+			Timeout:  upstream.ParseDurationEnv("INTEL_PROBE_TIMEOUT", 15*time.Second),
+		}, intelHistory, func(scores []scheduler.IntelligenceScore) {
+			for _, sc := range scores {
+				logging.Component("probe").Info("intelligence score", "adapter", sc.AdapterName, "model", sc.Model, "score", sc.Score)
+			}
+			election.UpdateScores(scores)
+		})
+	}
 	sessionStore := session.NewStore()
 	runStore := ccrun.NewStore()
 	todoStore := todo.NewStore()
 	planStore := plan.NewStore()
-	eventStore := ccevent.NewStore()
+	eventStore, err := ccevent.NewFromEnv()
+	if err != nil {
+		fatal("failed to init event store", err)
+	}
 	subagentManager := subagent.NewManager(nil)
 	subagentManager.SetLifecycleHook(func(event subagent.LifecycleEvent) {
 		switch event.EventType {
@@ -208,28 +338,222 @@ func main() {
 			RecordText: strings.TrimSpace(event.RecordText),
 		})
 	})
+	notifications := notification.NewCenter()
+	election.SetOnChange(func(result scheduler.ElectionResult) {
+		logging.Component("scheduler").Info("election",
+			"scheduler", result.SchedulerAdapter, "score", result.SchedulerScore,
+			"workers", len(result.Workers), "reason", result.Reason)
+		dispatcher.RecordElection(result)
+		_, _ = eventStore.Append(ccevent.AppendInput{
+			EventType: "scheduler.election_changed",
+			Data: map[string]any{
+				"scheduler_adapter": result.SchedulerAdapter,
+				"scheduler_model":   result.SchedulerModel,
+				"scheduler_score":   result.SchedulerScore,
+				"workers":           len(result.Workers),
+				"reason":            result.Reason,
+			},
+		})
+	})
+	probeRunner.SetOnOutcomeChange(func(o probe.Outcome) {
+		eventType := "probe.recovered"
+		if !o.OK {
+			eventType = "probe.failed"
+		}
+		_, _ = eventStore.Append(ccevent.AppendInput{
+			EventType: eventType,
+			Data: map[string]any{
+				"adapter": o.Adapter,
+				"model":   o.Model,
+				"check":   o.Check,
+				"error":   o.Error,
+			},
+		})
+	})
+	selector.SetOnHealthChange(func(h scheduler.HealthChange) {
+		eventType := "scheduler.adapter_up"
+		data := map[string]any{"adapter": h.Adapter}
+		if h.Down {
+			eventType = "scheduler.adapter_down"
+			data["consecutive_failures"] = h.ConsecutiveFailures
+			data["last_error"] = h.LastError
+			data["cooldown_until"] = h.CooldownUntil
+		}
+		_, _ = eventStore.Append(ccevent.AppendInput{EventType: eventType, Data: data})
+	})
+	webhooks, err := webhook.NewFromEnv()
+	if err != nil {
+		fatal("invalid webhook config", err)
+	}
+	cronScheduler, err := cron.NewFromEnv()
+	if err != nil {
+		fatal("invalid cron config", err)
+	}
+	batches, err := batch.NewFromEnv()
+	if err != nil {
+		fatal("invalid batch config", err)
+	}
+	fileService, err := files.NewFromEnv()
+	if err != nil {
+		fatal("invalid files config", err)
+	}
+	projects, err := project.NewFromEnv()
+	if err != nil {
+		fatal("invalid projects config", err)
+	}
+	guardrails, err := guardrail.NewFromEnv()
+	if err != nil {
+		fatal("invalid guardrails config", err)
+	}
+	secretScanner, err := secretscan.NewFromEnv()
+	if err != nil {
+		fatal("invalid secret scan config", err)
+	}
+	var persistence gateway.PersistenceHealth
+	var stateSnapshots *statepersist.ObjectBackend
 	persistDir := strings.TrimSpace(os.Getenv("STATE_PERSIST_DIR"))
-	if persistDir != "" {
-		backend, err := statepersist.NewFileBackend(persistDir)
+	stateStorage := strings.ToLower(strings.TrimSpace(os.Getenv("STATE_STORAGE")))
+	if persistDir != "" || stateStorage != "" {
+		var backend statepersist.Backend
+		switch stateStorage {
+		case "", "file":
+			fileBackend, err := statepersist.NewFileBackend(persistDir)
+			if err != nil {
+				fatal("invalid state persistence backend", err)
+			}
+			backend = fileBackend
+		default:
+			store, err := storage.NewObjectBackendFromEnv(stateStorage, os.Getenv("STATE_S3_BUCKET"), os.Getenv("STATE_S3_PREFIX"))
+			if err != nil {
+				fatal("invalid state persistence backend", err)
+			}
+			stateSnapshots = statepersist.NewObjectBackend(store)
+			backend = stateSnapshots
+		}
+		healthCfg, err := statepersist.HealthConfigFromEnv()
 		if err != nil {
-			log.Fatalf("invalid state persistence backend: %v", err)
+			fatal("invalid state persistence health config", err)
 		}
 		persistManager := statepersist.NewManager(backend, runStore, planStore, todoStore)
+		persistManager.SetHealthConfig(healthCfg)
+		if dataKeys != nil {
+			persistManager.SetCipher(dataKeys)
+		}
 		persistManager.SetOnError(func(err error) {
-			log.Printf("state persistence autosave failed: %v", err)
+			logging.Component("statepersist").Warn("autosave failed, queued for retry", "error", err)
+		})
+		persistManager.SetOnHealthChange(func(h statepersist.HealthStatus) {
+			eventType := "persistence.recovered"
+			if h.Degraded {
+				eventType = "persistence.degraded"
+				logging.Component("statepersist").Error("state persistence degraded", "alert", true, "consecutive_failures", h.ConsecutiveFailures, "read_only", h.ReadOnly, "last_error", h.LastError)
+				notifications.Raise(notification.RaiseInput{
+					Kind:     "persistence_degraded",
+					Severity: notification.SeverityCritical,
+					Title:    "State persistence is failing",
+					Message:  h.LastError,
+					Data: map[string]any{
+						"consecutive_failures": h.ConsecutiveFailures,
+						"read_only":            h.ReadOnly,
+					},
+				})
+			} else {
+				logging.Component("statepersist").Info("state persistence recovered")
+				notifications.Resolve("persistence_degraded")
+			}
+			_, _ = eventStore.Append(ccevent.AppendInput{
+				EventType: eventType,
+				Data: map[string]any{
+					"consecutive_failures": h.ConsecutiveFailures,
+					"total_failures":       h.TotalFailures,
+					"read_only":            h.ReadOnly,
+					"last_error":           h.LastError,
+				},
+			})
 		})
 		if err := persistManager.LoadAll(); err != nil {
-			log.Fatalf("failed to load persisted state: %v", err)
+			fatal("failed to load persisted state", err)
 		}
 		persistManager.BindAutoSave()
 		if err := persistManager.SaveAll(); err != nil {
-			log.Fatalf("failed to save initial persisted state: %v", err)
+			fatal("failed to save initial persisted state", err)
 		}
-		log.Printf("state persistence enabled at %s", persistDir)
+		persistence = persistManager
+		logger.Info("state persistence enabled", "storage", stateStorage, "dir", persistDir)
+	}
+	egressPolicy, err := egress.NewFromEnv()
+	if err != nil {
+		fatal("invalid egress policy", err)
 	}
-	mcpStore, err := mcpregistry.NewFromEnv(nil)
+	egress.SetDefault(egressPolicy)
+	ipAccess, err := ipaccess.NewFromEnv()
 	if err != nil {
-		log.Fatalf("invalid mcp registry config: %v", err)
+		fatal("invalid ip access policy", err)
+	}
+	anomalies, err := anomaly.NewFromEnv()
+	if err != nil {
+		fatal("invalid anomaly detection config", err)
+	}
+	webSearch, err := servertools.NewWebSearchFromEnv()
+	if err != nil {
+		fatal("invalid web search config", err)
+	}
+	serverTools := []servertools.Tool{webSearch}
+	codeExecution, err := servertools.NewCodeExecutionFromEnv()
+	if err != nil {
+		fatal("invalid code execution config", err)
+	}
+	if codeExecution != nil {
+		serverTools = append(serverTools, codeExecution)
+		logger.Info("code_execution server tool enabled", "backend", codeExecution.Config().Backend)
+	}
+	workspaces, err := workspace.NewFromEnv()
+	if err != nil {
+		fatal("invalid workspace config", err)
+	}
+	if workspaces != nil {
+		serverTools = append(serverTools, servertools.NewWorkspaceTools(workspaces)...)
+		logger.Info("workspace file tools enabled", "root", workspaces.Config().Root, "quota_bytes", workspaces.Config().QuotaBytes)
+	}
+	builtinTools, err := servertools.NewBuiltinToolsFromEnv(workspaces)
+	if err != nil {
+		fatal("invalid builtin tools config", err)
+	}
+	if len(builtinTools) > 0 {
+		serverTools = append(serverTools, builtinTools...)
+		for _, tool := range builtinTools {
+			bash, ok := tool.(*servertools.Bash)
+			if !ok {
+				continue
+			}
+			logger.Info("bash and text_editor server tools enabled", "backend", bash.Config().Backend)
+			if bash.Config().Backend == servertools.BackendSubprocess {
+				logger.Warn("bash server tool runs commands as gateway subprocesses; they can read the gateway's environment and files outside the workspace")
+			}
+		}
+	}
+	gitTools, err := servertools.NewGitToolsFromEnv(workspaces, eventStore)
+	if err != nil {
+		fatal("invalid git tools config", err)
+	}
+	if gitTools != nil {
+		serverTools = append(serverTools, gitTools.Tools()...)
+		logger.Info("git server tools enabled", "allowed_hosts", gitTools.Config().AllowedHosts)
+	}
+	imageProcessor, err := imageproc.NewFromEnv()
+	if err != nil {
+		fatal("invalid vision image config", err)
+	}
+	tokenizers, err := tokenizer.NewFromEnv()
+	if err != nil {
+		fatal("invalid tokenizer config", err)
+	}
+	if loaded := tokenizers.Loaded(); len(loaded) > 0 {
+		logger.Info("tokenizers loaded", "vocabularies", loaded)
+	}
+	mcpStore, err := mcpregistry.NewFromEnv(egressPolicy.HTTPClient(0))
+	if err != nil {
+		fatal("invalid mcp registry config", err)
 	}
 	pluginStore := plugin.NewManager()
 
@@ -237,23 +561,57 @@ func main() {
 	marketplaceDir := "configs/marketplace"
 	marketplaceRegistry := marketplace.NewLocalRegistry(marketplaceDir)
 	if err := marketplaceRegistry.Refresh(); err != nil {
-		log.Printf("warning: failed to load marketplace registry: %v", err)
+		logger.Warn("failed to load marketplace registry", "error", err)
 	} else {
 		manifests, _ := marketplaceRegistry.List()
-		log.Printf("marketplace: loaded %d plugin manifests from %s", len(manifests), marketplaceDir)
+		logger.Info("marketplace: plugin manifests loaded", "count", len(manifests), "dir", marketplaceDir)
 	}
 
 	// Initialize stats tracker with persistence
 	statsFile := "data/marketplace-stats.json"
 	statsTracker := marketplace.NewStatsTrackerWithPersistence(statsFile)
-	log.Printf("marketplace: stats tracker initialized with persistence at %s", statsFile)
+	logger.Info("marketplace: stats tracker initialized", "path", statsFile)
 
 	marketplaceService := marketplace.NewServiceWithStats(marketplaceRegistry, pluginStore, statsTracker)
 
 	// Initialize Auth Services
 	authService := auth.NewInMemoryService()
-	tokenService := token.NewInMemoryService()
+	var tokenService token.Service = token.NewInMemoryService()
+	hashedTokens, err := token.NewHashedFileServiceFromEnv()
+	if err != nil {
+		fatal("invalid token store", err)
+	}
+	if hashedTokens != nil {
+		tokenService = hashedTokens
+		logger.Info("token store: hashed tokens persisted", "path", strings.TrimSpace(os.Getenv("TOKEN_STORE_PATH")))
+	}
 	channelStore := channel.NewAbilityStore()
+	channelHealth, err := channel.NewHealthTrackerFromEnv()
+	if err != nil {
+		fatal("invalid channel health config", err)
+	}
+
+	// CONFIG_FILE is applied over the env-built components; env variables
+	// still win for the sections they set.
+	var configReloader gateway.ConfigReloader
+	if reloader := configfile.FromEnv(configfile.Targets{
+		Upstream: svc,
+		Settings: settingsStore,
+		Tools:    tools,
+		Channels: channelStore,
+	}); reloader != nil {
+		res, err := reloader.Reload("startup")
+		if err != nil {
+			fatal("invalid config file", fmt.Errorf("%s: %w", reloader.Path(), err))
+		}
+		logger.Info("config file loaded", "path", res.Path, "applied", res.Applied, "env_overrides", res.EnvOverrides)
+		configReloader = reloader
+	}
+
+	trafficSampler, err := trafficsample.NewFromEnv()
+	if err != nil {
+		fatal("invalid traffic sample config", err)
+	}
 
 	// Default admin user
 	_, _ = authService.Register("admin", "admin123", "admin")
@@ -261,9 +619,9 @@ func main() {
 	adminToken := strings.TrimSpace(os.Getenv("ADMIN_TOKEN"))
 	if adminToken == "" {
 		adminToken = gateway.DefaultAdminToken
-		log.Printf("warning: ADMIN_TOKEN is not set; default admin token %q is enabled (change it for production)", gateway.DefaultAdminToken)
+		logger.Warn("ADMIN_TOKEN is not set; the default admin token is enabled (change it for production)", "admin_token", gateway.DefaultAdminToken)
 	} else if adminToken == gateway.DefaultAdminToken {
-		log.Printf("warning: ADMIN_TOKEN is set to default value %q (change it for production)", gateway.DefaultAdminToken)
+		logger.Warn("ADMIN_TOKEN is set to the default value (change it for production)", "admin_token", gateway.DefaultAdminToken)
 	}
 
 	router := gateway.NewRouter(gateway.Dependencies{
@@ -288,9 +646,53 @@ func main() {
 		RunLogger:          runLogger,
 		MemoryStore:        memory.NewInMemoryStore(),
 		Summarizer:         memory.NewLLMSummarizer(svc, "claude-3-haiku-20240307"),
+		Tokenizers:         tokenizers,
+		Evaluator:          eval.NewEvaluator(eval.NewServiceCompleter(svc), os.Getenv("EVAL_JUDGE_MODEL")),
 		AuthService:        authService,
 		TokenService:       tokenService,
 		ChannelStore:       channelStore,
+		ChannelHealth:      channelHealth,
+		TrafficSampler:     trafficSampler,
+		EgressPolicy:       egressPolicy,
+		IPAccess:           ipAccess,
+		Anomalies:          anomalies,
+		ImageProcessor:     imageProcessor,
+		ServerTools:        serverTools,
+		Workspaces:         workspaces,
+		Persistence:        persistence,
+		AdminAudit:         adminAudit,
+		FeatureFlags:       featureFlags,
+		UsageLedger:        usageLedger,
+		ConfigReloader:     configReloader,
+		DataKeys:           dataKeys,
+		Notifications:      notifications,
+		Webhooks:           webhooks,
+		Cron:               cronScheduler,
+		Batches:            batches,
+		Files:              fileService,
+		Projects:           projects,
+		Guardrails:         guardrails,
+		SecretScanner:      secretScanner,
+		IDGenerator:        idGenerator,
+		TrashRetention:     upstream.ParseDurationEnv("TRASH_RETENTION", 7*24*time.Hour),
+		AsyncRuns: gateway.AsyncRunConfig{
+			Workers:   upstream.ParseIntEnv("ASYNC_RUN_WORKERS", 4),
+			QueueSize: upstream.ParseIntEnv("ASYNC_RUN_QUEUE_SIZE", 256),
+			Retention: upstream.ParseDurationEnv("ASYNC_RUN_RETENTION", time.Hour),
+		},
+		StreamResume: gateway.StreamResumeConfig{
+			Window:   upstream.ParseDurationEnv("STREAM_RESUME_WINDOW", 0),
+			MaxBytes: upstream.ParseIntEnv("STREAM_RESUME_MAX_BYTES", 4<<20),
+		},
+		WireCapture: gateway.WireCaptureConfig{
+			Enabled:      upstream.ParseBoolEnv("WIRE_CAPTURE_ENABLED", false),
+			MaxRuns:      upstream.ParseIntEnv("WIRE_CAPTURE_MAX_RUNS", 100),
+			MaxBodyBytes: upstream.ParseIntEnv("WIRE_CAPTURE_MAX_BODY_BYTES", 256<<10),
+		},
+		SSE: gateway.SSEConfig{
+			PingInterval: upstream.ParseDurationEnv("SSE_PING_INTERVAL", 15*time.Second),
+			WriteTimeout: upstream.ParseDurationEnv("SSE_WRITE_TIMEOUT", 30*time.Second),
+		},
 	})
 
 	server := &http.Server{
@@ -298,56 +700,88 @@ func main() {
 		Handler:           router,
 		ReadHeaderTimeout: 5 * time.Second,
 	}
+	tlsManager, err := tlsserve.FromEnv(port)
+	if err != nil {
+		fatal("invalid TLS config", err)
+	}
+	var httpServer *http.Server
+	if tlsManager != nil {
+		server.TLSConfig = tlsManager.TLSConfig()
+		if addr := tlsManager.HTTPAddr(); addr != "" {
+			httpServer = &http.Server{
+				Addr:              addr,
+				Handler:           tlsManager.HTTPHandler(),
+				ReadHeaderTimeout: 5 * time.Second,
+			}
+		}
+	}
 
 	runtimeCtx, runtimeCancel := context.WithCancel(context.Background())
 	defer runtimeCancel()
 	if probeRunner != nil {
 		probeRunner.Start(runtimeCtx)
 	}
+	mcpStore.StartToolSync(runtimeCtx)
+	webhookEvents, stopWebhookEvents := eventStore.Subscribe(ccevent.ListFilter{})
+	defer stopWebhookEvents()
+	go webhooks.Run(runtimeCtx, webhookEvents)
+	go cronScheduler.Run(runtimeCtx)
+	if stateSnapshots != nil {
+		go stateSnapshots.Run(runtimeCtx, upstream.ParseDurationEnv("STATE_SNAPSHOT_INTERVAL", 30*time.Second), func(err error) {
+			logging.Component("statepersist").Warn("state snapshot upload failed, will retry", "error", err)
+		})
+	}
+	if runLogShipper != nil {
+		go runLogShipper.Run(runtimeCtx, func(err error) {
+			logging.Component("runlog").Warn("run log shipping failed, will retry", "error", err)
+		})
+	}
+	if pm, ok := persistence.(*statepersist.Manager); ok {
+		pm.StartRepairLoop(runtimeCtx)
+	}
 
-	// Intelligence probe: runs after first probe cycle, evaluates adapter intelligence
-	if upstream.ParseBoolEnv("ENABLE_TASK_DISPATCH", false) && len(adapters) > 1 {
+	if tlsManager != nil {
+		go tlsManager.Run(runtimeCtx, func(err error) {
+			logging.Component("tls").Warn("certificate refresh failed, will retry", "error", err)
+		})
+	}
+	if httpServer != nil {
 		go func() {
-			// Wait for initial probe to complete
-			time.Sleep(5 * time.Second)
-			log.Println("starting intelligence evaluation...")
-			intelTimeout := upstream.ParseDurationEnv("INTEL_PROBE_TIMEOUT", 15*time.Second)
-			scores := make([]scheduler.IntelligenceScore, 0, len(adapters))
-			for _, a := range adapters {
-				if a == nil {
-					continue
-				}
-				model := ""
-				if h, ok := a.(interface{ ModelHint() string }); ok {
-					model = h.ModelHint()
-				}
-				if model == "" {
-					model = "default"
-				}
-				result := probe.ProbeIntelligence(runtimeCtx, a, model, intelTimeout)
-				log.Printf("intelligence: adapter=%s model=%s score=%.0f/100 latency=%dms",
-					result.AdapterName, result.Model, result.Score, result.LatencyMS)
-				for _, d := range result.Details {
-					log.Printf("  %s: %.0f/20", d.Category, d.Score)
-				}
-				scores = append(scores, scheduler.IntelligenceScore{
-					AdapterName: result.AdapterName,
-					Model:       result.Model,
-					Score:       result.Score,
-					TestedAt:    result.TestedAt,
-				})
+			logger.Info("cc-gateway http listener for acme challenges and redirects", "addr", httpServer.Addr)
+			if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				fatal("http listener failed", err)
 			}
-			election.UpdateScores(scores)
 		}()
 	}
-
 	go func() {
-		log.Printf("cc-gateway listening on :%s", port)
+		if tlsManager != nil {
+			logger.Info("cc-gateway listening", "addr", ":"+port, "tls", tlsManager.Status().Mode)
+			if err := server.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
+				fatal("server failed", err)
+			}
+			return
+		}
+		logger.Info("cc-gateway listening", "addr", ":"+port)
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("server failed: %v", err)
+			fatal("server failed", err)
 		}
 	}()
 
+	if configReloader != nil {
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		go func() {
+			for range hup {
+				res, err := configReloader.Reload("sighup")
+				if err != nil {
+					logger.Error("config file reload failed", "error", err)
+					continue
+				}
+				logger.Info("config file reloaded", "path", res.Path, "applied", res.Applied)
+			}
+		}()
+	}
+
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
 	<-stop
@@ -356,6 +790,30 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	_ = server.Shutdown(ctx)
+	if httpServer != nil {
+		_ = httpServer.Shutdown(ctx)
+	}
+	if hashedTokens != nil {
+		if err := hashedTokens.Flush(); err != nil {
+			logger.Error("token store flush failed", "error", err)
+		}
+	}
+	if stateSnapshots != nil {
+		if err := stateSnapshots.Flush(ctx); err != nil {
+			logger.Error("state snapshot upload failed", "error", err)
+		}
+	}
+	if runLogShipper != nil {
+		if err := runLogShipper.Flush(ctx); err != nil {
+			logger.Error("run log shipping failed", "error", err)
+		}
+	}
+}
+
+// fatal logs msg with err and exits, like log.Fatalf.
+func fatal(msg string, err error) {
+	logging.Component("main").Error(msg, "error", err)
+	os.Exit(1)
 }
 
 func adapterNames(adapters []upstream.Adapter) []string {
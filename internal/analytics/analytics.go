@@ -0,0 +1,244 @@
+// Package analytics turns finished runs into time-bucketed aggregates for
+// the admin dashboard, so charts do not have to fold raw events client-side.
+package analytics
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MaxBuckets bounds the buckets of one series.
+const MaxBuckets = 1000
+
+// Sample is one finished run.
+type Sample struct {
+	Time           time.Time
+	Model          string
+	Adapter        string
+	Mode           string
+	Failed         bool
+	LatencyMS      int64
+	InputTokens    int64
+	OutputTokens   int64
+	CostUSD        float64
+	ToolLoopRounds int
+}
+
+// Query selects the window [Since, Until) cut into Bucket-wide buckets.
+// GroupBy is "", "model", "adapter" or "mode".
+type Query struct {
+	Since   time.Time
+	Until   time.Time
+	Bucket  time.Duration
+	GroupBy string
+}
+
+type Latency struct {
+	P50 int64   `json:"p50"`
+	P90 int64   `json:"p90"`
+	P99 int64   `json:"p99"`
+	Avg float64 `json:"avg"`
+}
+
+type Metrics struct {
+	Requests       int     `json:"requests"`
+	Errors         int     `json:"errors"`
+	ErrorRate      float64 `json:"error_rate"`
+	InputTokens    int64   `json:"input_tokens"`
+	OutputTokens   int64   `json:"output_tokens"`
+	CostUSD        float64 `json:"cost_usd"`
+	LatencyMS      Latency `json:"latency_ms"`
+	ToolLoopRounds int     `json:"tool_loop_rounds"`
+	// AvgToolLoopRounds averages over the requests that ran a tool loop.
+	AvgToolLoopRounds float64 `json:"avg_tool_loop_rounds"`
+}
+
+type Bucket struct {
+	Start time.Time `json:"start"`
+	Metrics
+}
+
+type Series struct {
+	Key     string   `json:"key"`
+	Totals  Metrics  `json:"totals"`
+	Buckets []Bucket `json:"buckets"`
+}
+
+type Report struct {
+	Since   time.Time `json:"since"`
+	Until   time.Time `json:"until"`
+	Bucket  string    `json:"bucket"`
+	GroupBy string    `json:"group_by,omitempty"`
+	Totals  Metrics   `json:"totals"`
+	Series  []Series  `json:"series"`
+}
+
+// Validate checks q and reports the number of buckets it spans.
+func (q Query) Validate() (int, error) {
+	if !q.Until.After(q.Since) {
+		return 0, fmt.Errorf("until must be after since")
+	}
+	if q.Bucket <= 0 {
+		return 0, fmt.Errorf("bucket must be positive")
+	}
+	switch q.GroupBy {
+	case "", "model", "adapter", "mode":
+	default:
+		return 0, fmt.Errorf("group_by must be model, adapter or mode")
+	}
+	n := int((q.Until.Sub(q.Since) + q.Bucket - 1) / q.Bucket)
+	if n > MaxBuckets {
+		return 0, fmt.Errorf("window spans %d buckets; at most %d are allowed", n, MaxBuckets)
+	}
+	return n, nil
+}
+
+// Aggregate folds samples inside the window into one series per group key
+// (a single "all" series without GroupBy), ordered by request count. Every
+// series carries every bucket, empty ones included, so charts line up.
+func Aggregate(q Query, samples []Sample) (Report, error) {
+	n, err := q.Validate()
+	if err != nil {
+		return Report{}, err
+	}
+	type group struct {
+		totals  accumulator
+		buckets []accumulator
+	}
+	groups := map[string]*group{}
+	var totals accumulator
+	for _, sample := range samples {
+		if sample.Time.Before(q.Since) || !sample.Time.Before(q.Until) {
+			continue
+		}
+		key := groupKey(q.GroupBy, sample)
+		g := groups[key]
+		if g == nil {
+			g = &group{buckets: make([]accumulator, n)}
+			groups[key] = g
+		}
+		idx := int(sample.Time.Sub(q.Since) / q.Bucket)
+		g.buckets[idx].add(sample)
+		g.totals.add(sample)
+		totals.add(sample)
+	}
+
+	report := Report{
+		Since:   q.Since,
+		Until:   q.Until,
+		Bucket:  q.Bucket.String(),
+		GroupBy: q.GroupBy,
+		Totals:  totals.metrics(),
+		Series:  make([]Series, 0, len(groups)),
+	}
+	for key, g := range groups {
+		series := Series{Key: key, Totals: g.totals.metrics(), Buckets: make([]Bucket, n)}
+		for i := range g.buckets {
+			series.Buckets[i] = Bucket{Start: q.Since.Add(time.Duration(i) * q.Bucket), Metrics: g.buckets[i].metrics()}
+		}
+		report.Series = append(report.Series, series)
+	}
+	sort.Slice(report.Series, func(i, j int) bool {
+		a, b := report.Series[i], report.Series[j]
+		if a.Totals.Requests != b.Totals.Requests {
+			return a.Totals.Requests > b.Totals.Requests
+		}
+		return a.Key < b.Key
+	})
+	return report, nil
+}
+
+func groupKey(groupBy string, s Sample) string {
+	var key string
+	switch groupBy {
+	case "":
+		return "all"
+	case "model":
+		key = s.Model
+	case "adapter":
+		key = s.Adapter
+	case "mode":
+		key = s.Mode
+	}
+	if key = strings.TrimSpace(key); key == "" {
+		return "unknown"
+	}
+	return key
+}
+
+type accumulator struct {
+	requests     int
+	errors       int
+	inputTokens  int64
+	outputTokens int64
+	costUSD      float64
+	latencies    []int64
+	rounds       int
+	loopRequests int
+}
+
+func (a *accumulator) add(s Sample) {
+	a.requests++
+	if s.Failed {
+		a.errors++
+	}
+	a.inputTokens += s.InputTokens
+	a.outputTokens += s.OutputTokens
+	a.costUSD += s.CostUSD
+	if s.LatencyMS > 0 {
+		a.latencies = append(a.latencies, s.LatencyMS)
+	}
+	if s.ToolLoopRounds > 0 {
+		a.rounds += s.ToolLoopRounds
+		a.loopRequests++
+	}
+}
+
+func (a *accumulator) metrics() Metrics {
+	m := Metrics{
+		Requests:       a.requests,
+		Errors:         a.errors,
+		InputTokens:    a.inputTokens,
+		OutputTokens:   a.outputTokens,
+		CostUSD:        round(a.costUSD, 6),
+		ToolLoopRounds: a.rounds,
+	}
+	if a.requests > 0 {
+		m.ErrorRate = round(float64(a.errors)/float64(a.requests), 4)
+	}
+	if a.loopRequests > 0 {
+		m.AvgToolLoopRounds = round(float64(a.rounds)/float64(a.loopRequests), 2)
+	}
+	if len(a.latencies) > 0 {
+		sorted := append([]int64(nil), a.latencies...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		var sum int64
+		for _, v := range sorted {
+			sum += v
+		}
+		m.LatencyMS = Latency{
+			P50: percentile(sorted, 0.50),
+			P90: percentile(sorted, 0.90),
+			P99: percentile(sorted, 0.99),
+			Avg: round(float64(sum)/float64(len(sorted)), 1),
+		}
+	}
+	return m
+}
+
+// percentile uses the nearest-rank method on sorted values.
+func percentile(sorted []int64, p float64) int64 {
+	rank := int(math.Ceil(p * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+func round(v float64, places int) float64 {
+	scale := math.Pow(10, float64(places))
+	return math.Round(v*scale) / scale
+}
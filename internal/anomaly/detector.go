@@ -0,0 +1,379 @@
+// Package anomaly learns per-adapter baselines of request volume and error
+// rate and flags buckets that deviate from them.
+package anomaly
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Kind string
+
+const (
+	KindVolumeSpike Kind = "volume_spike"
+	KindVolumeDrop  Kind = "volume_drop"
+	KindErrorRate   Kind = "error_rate"
+)
+
+// maxRecent bounds the findings kept for the status view.
+const maxRecent = 50
+
+// maxGapBuckets bounds how many idle buckets are folded into a baseline
+// when an adapter sees traffic again; older gaps no longer move the EWMA.
+const maxGapBuckets = 120
+
+// Config tunes the detector. Baselines are exponentially weighted means and
+// variances over Bucket-wide counts, updated when a bucket closes with
+// weight Alpha. Nothing is judged before WarmupBuckets buckets are learned.
+type Config struct {
+	Enabled       bool
+	Bucket        time.Duration
+	Alpha         float64
+	WarmupBuckets int
+	// ZScore is how many standard deviations a bucket must be away from
+	// the baseline to count as anomalous.
+	ZScore float64
+	// MinRequests keeps quiet adapters out of the findings: spikes and
+	// error rates are only judged for buckets with at least this many
+	// requests, drops only for baselines of at least this many.
+	MinRequests int
+	// MinErrorRateDelta is the smallest error rate increase reported, so a
+	// flat baseline does not turn every failure into a finding.
+	MinErrorRateDelta float64
+	// Cooldown suppresses repeated findings of one kind for one adapter.
+	Cooldown time.Duration
+}
+
+// Finding is one anomalous bucket.
+type Finding struct {
+	Adapter     string    `json:"adapter"`
+	Kind        Kind      `json:"kind"`
+	BucketStart time.Time `json:"bucket_start"`
+	Observed    float64   `json:"observed"`
+	Baseline    float64   `json:"baseline"`
+	StdDev      float64   `json:"stddev"`
+	ZScore      float64   `json:"z_score"`
+	Requests    int       `json:"requests"`
+	Errors      int       `json:"errors"`
+	DetectedAt  time.Time `json:"detected_at"`
+}
+
+// Baseline is the learned state of one adapter.
+type Baseline struct {
+	Adapter         string  `json:"adapter"`
+	Buckets         int     `json:"buckets"`
+	WarmingUp       bool    `json:"warming_up"`
+	MeanRequests    float64 `json:"mean_requests"`
+	StdDevRequests  float64 `json:"stddev_requests"`
+	MeanErrorRate   float64 `json:"mean_error_rate"`
+	StdDevErrorRate float64 `json:"stddev_error_rate"`
+	CurrentRequests int     `json:"current_requests"`
+	CurrentErrors   int     `json:"current_errors"`
+}
+
+type Status struct {
+	Enabled  bool       `json:"enabled"`
+	Bucket   string     `json:"bucket"`
+	Detected uint64     `json:"detected"`
+	Active   []Finding  `json:"active"`
+	Recent   []Finding  `json:"recent"`
+	Adapters []Baseline `json:"adapters"`
+}
+
+// Detector is safe for concurrent use.
+type Detector struct {
+	mu       sync.Mutex
+	cfg      Config
+	adapters map[string]*adapterState
+	recent   []Finding
+	detected uint64
+}
+
+type adapterState struct {
+	start     time.Time
+	requests  int
+	errors    int
+	volume    ewma
+	rate      ewma
+	lastFired map[Kind]time.Time
+}
+
+// ewma keeps an exponentially weighted mean and variance.
+type ewma struct {
+	n        int
+	mean     float64
+	variance float64
+}
+
+func (e *ewma) add(x, alpha float64) {
+	if e.n == 0 {
+		e.mean = x
+	} else {
+		diff := x - e.mean
+		incr := alpha * diff
+		e.mean += incr
+		e.variance = (1 - alpha) * (e.variance + diff*incr)
+	}
+	e.n++
+}
+
+func (e ewma) stddev() float64 {
+	return math.Sqrt(e.variance)
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Enabled:           true,
+		Bucket:            time.Minute,
+		Alpha:             0.1,
+		WarmupBuckets:     10,
+		ZScore:            3,
+		MinRequests:       20,
+		MinErrorRateDelta: 0.2,
+		Cooldown:          10 * time.Minute,
+	}
+}
+
+func NewDetector(cfg Config) *Detector {
+	return &Detector{cfg: sanitizeConfig(cfg), adapters: map[string]*adapterState{}}
+}
+
+// NewFromEnv reads ANOMALY_DETECTION_ENABLED, ANOMALY_BUCKET,
+// ANOMALY_ZSCORE, ANOMALY_MIN_REQUESTS, ANOMALY_WARMUP_BUCKETS and
+// ANOMALY_COOLDOWN on top of DefaultConfig.
+func NewFromEnv() (*Detector, error) {
+	cfg := DefaultConfig()
+	if raw := strings.TrimSpace(os.Getenv("ANOMALY_DETECTION_ENABLED")); raw != "" {
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ANOMALY_DETECTION_ENABLED: %w", err)
+		}
+		cfg.Enabled = v
+	}
+	if raw := strings.TrimSpace(os.Getenv("ANOMALY_BUCKET")); raw != "" {
+		v, err := time.ParseDuration(raw)
+		if err != nil || v < time.Second {
+			return nil, fmt.Errorf("ANOMALY_BUCKET must be a duration of at least 1s")
+		}
+		cfg.Bucket = v
+	}
+	if raw := strings.TrimSpace(os.Getenv("ANOMALY_ZSCORE")); raw != "" {
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil || v <= 0 {
+			return nil, fmt.Errorf("ANOMALY_ZSCORE must be a positive number")
+		}
+		cfg.ZScore = v
+	}
+	if raw := strings.TrimSpace(os.Getenv("ANOMALY_MIN_REQUESTS")); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v < 1 {
+			return nil, fmt.Errorf("ANOMALY_MIN_REQUESTS must be a positive integer")
+		}
+		cfg.MinRequests = v
+	}
+	if raw := strings.TrimSpace(os.Getenv("ANOMALY_WARMUP_BUCKETS")); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v < 1 {
+			return nil, fmt.Errorf("ANOMALY_WARMUP_BUCKETS must be a positive integer")
+		}
+		cfg.WarmupBuckets = v
+	}
+	if raw := strings.TrimSpace(os.Getenv("ANOMALY_COOLDOWN")); raw != "" {
+		v, err := time.ParseDuration(raw)
+		if err != nil || v < 0 {
+			return nil, fmt.Errorf("ANOMALY_COOLDOWN must be a non-negative duration")
+		}
+		cfg.Cooldown = v
+	}
+	return NewDetector(cfg), nil
+}
+
+func sanitizeConfig(cfg Config) Config {
+	def := DefaultConfig()
+	if cfg.Bucket <= 0 {
+		cfg.Bucket = def.Bucket
+	}
+	if cfg.Alpha <= 0 || cfg.Alpha > 1 {
+		cfg.Alpha = def.Alpha
+	}
+	if cfg.WarmupBuckets <= 0 {
+		cfg.WarmupBuckets = def.WarmupBuckets
+	}
+	if cfg.ZScore <= 0 {
+		cfg.ZScore = def.ZScore
+	}
+	if cfg.MinRequests <= 0 {
+		cfg.MinRequests = def.MinRequests
+	}
+	if cfg.MinErrorRateDelta <= 0 {
+		cfg.MinErrorRateDelta = def.MinErrorRateDelta
+	}
+	if cfg.Cooldown < 0 {
+		cfg.Cooldown = 0
+	}
+	return cfg
+}
+
+func (d *Detector) Config() Config {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.cfg
+}
+
+// Observe records one adapter call made at at. Buckets are judged when
+// they close, that is on the first call of a later bucket, so Observe
+// returns the findings of the bucket it just closed. An adapter that goes
+// silent is therefore reported as a drop once traffic resumes.
+func (d *Detector) Observe(adapter string, failed bool, at time.Time) []Finding {
+	adapter = strings.TrimSpace(adapter)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.cfg.Enabled || adapter == "" {
+		return nil
+	}
+	start := at.Truncate(d.cfg.Bucket)
+	st := d.adapters[adapter]
+	if st == nil {
+		st = &adapterState{start: start, lastFired: map[Kind]time.Time{}}
+		d.adapters[adapter] = st
+	}
+	var findings []Finding
+	if start.After(st.start) {
+		findings = d.closeLocked(adapter, st, start, at)
+	}
+	// Calls that arrive late for a closed bucket count towards the open one.
+	st.requests++
+	if failed {
+		st.errors++
+	}
+	return findings
+}
+
+// closeLocked judges the open bucket of st against its baseline, folds it
+// and any idle buckets up to next into the baseline, and opens next.
+func (d *Detector) closeLocked(adapter string, st *adapterState, next, at time.Time) []Finding {
+	findings := d.judgeLocked(adapter, st, at)
+	st.volume.add(float64(st.requests), d.cfg.Alpha)
+	if st.requests > 0 {
+		st.rate.add(float64(st.errors)/float64(st.requests), d.cfg.Alpha)
+	}
+	gaps := int(next.Sub(st.start)/d.cfg.Bucket) - 1
+	for i := 0; i < min(gaps, maxGapBuckets); i++ {
+		st.volume.add(0, d.cfg.Alpha)
+	}
+	st.start = next
+	st.requests = 0
+	st.errors = 0
+
+	kept := findings[:0]
+	for _, f := range findings {
+		if last, ok := st.lastFired[f.Kind]; ok && at.Sub(last) < d.cfg.Cooldown {
+			continue
+		}
+		st.lastFired[f.Kind] = at
+		kept = append(kept, f)
+		d.detected++
+		d.recent = append(d.recent, f)
+	}
+	if len(d.recent) > maxRecent {
+		d.recent = append([]Finding(nil), d.recent[len(d.recent)-maxRecent:]...)
+	}
+	return kept
+}
+
+func (d *Detector) judgeLocked(adapter string, st *adapterState, at time.Time) []Finding {
+	var findings []Finding
+	base := Finding{
+		Adapter:     adapter,
+		BucketStart: st.start,
+		Requests:    st.requests,
+		Errors:      st.errors,
+		DetectedAt:  at,
+	}
+	minRequests := float64(d.cfg.MinRequests)
+
+	if st.volume.n >= d.cfg.WarmupBuckets {
+		x := float64(st.requests)
+		// Request counts are roughly Poisson, so the deviation never drops
+		// below the square root of the mean, however steady the history.
+		sd := math.Max(st.volume.stddev(), math.Max(math.Sqrt(st.volume.mean), 1))
+		z := (x - st.volume.mean) / sd
+		switch {
+		case z >= d.cfg.ZScore && x >= minRequests:
+			findings = append(findings, d.finding(base, KindVolumeSpike, x, st.volume.mean, sd, z))
+		case z <= -d.cfg.ZScore && st.volume.mean >= minRequests:
+			findings = append(findings, d.finding(base, KindVolumeDrop, x, st.volume.mean, sd, z))
+		}
+	}
+
+	if st.rate.n >= d.cfg.WarmupBuckets && st.requests >= d.cfg.MinRequests {
+		x := float64(st.errors) / float64(st.requests)
+		sd := st.rate.stddev()
+		delta := x - st.rate.mean
+		if delta >= math.Max(d.cfg.ZScore*sd, d.cfg.MinErrorRateDelta) {
+			z := 0.0
+			if sd > 0 {
+				z = delta / sd
+			}
+			findings = append(findings, d.finding(base, KindErrorRate, x, st.rate.mean, sd, z))
+		}
+	}
+	return findings
+}
+
+func (d *Detector) finding(base Finding, kind Kind, observed, baseline, sd, z float64) Finding {
+	base.Kind = kind
+	base.Observed = round(observed)
+	base.Baseline = round(baseline)
+	base.StdDev = round(sd)
+	base.ZScore = round(z)
+	return base
+}
+
+// Snapshot reports the learned baselines and the findings; Active holds
+// the findings still inside their cooldown at now.
+func (d *Detector) Snapshot(now time.Time) Status {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	status := Status{
+		Enabled:  d.cfg.Enabled,
+		Bucket:   d.cfg.Bucket.String(),
+		Detected: d.detected,
+		Active:   []Finding{},
+		Recent:   make([]Finding, 0, len(d.recent)),
+		Adapters: make([]Baseline, 0, len(d.adapters)),
+	}
+	activeWindow := max(d.cfg.Cooldown, d.cfg.Bucket)
+	for i := len(d.recent) - 1; i >= 0; i-- {
+		f := d.recent[i]
+		status.Recent = append(status.Recent, f)
+		if now.Sub(f.DetectedAt) < activeWindow {
+			status.Active = append(status.Active, f)
+		}
+	}
+	for name, st := range d.adapters {
+		status.Adapters = append(status.Adapters, Baseline{
+			Adapter:         name,
+			Buckets:         st.volume.n,
+			WarmingUp:       st.volume.n < d.cfg.WarmupBuckets,
+			MeanRequests:    round(st.volume.mean),
+			StdDevRequests:  round(st.volume.stddev()),
+			MeanErrorRate:   round(st.rate.mean),
+			StdDevErrorRate: round(st.rate.stddev()),
+			CurrentRequests: st.requests,
+			CurrentErrors:   st.errors,
+		})
+	}
+	sort.Slice(status.Adapters, func(i, j int) bool { return status.Adapters[i].Adapter < status.Adapters[j].Adapter })
+	return status
+}
+
+func round(v float64) float64 {
+	return math.Round(v*10000) / 10000
+}
@@ -0,0 +1,177 @@
+package auditlog
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const (
+	redactedValue = "[REDACTED]"
+	maxChanges    = 200
+)
+
+// Change is a single field that differs between the before and after state.
+// Path uses dots for object keys and [key] for list items matched by id or
+// name.
+type Change struct {
+	Path   string `json:"path"`
+	Before any    `json:"before,omitempty"`
+	After  any    `json:"after,omitempty"`
+}
+
+// secretKeySuffixes marks fields whose values never reach the audit log.
+var secretKeySuffixes = []string{"api_key", "apikey", "token", "secret", "password", "authorization", "credential", "credentials", "private_key"}
+
+// ActorID returns a stable, non-reversible identifier for a credential.
+func ActorID(token string) string {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return "anonymous"
+	}
+	sum := sha256.Sum256([]byte(token))
+	return "sha256:" + hex.EncodeToString(sum[:8])
+}
+
+// Diff compares two decoded JSON documents. Secret fields are compared on
+// their real values but reported redacted.
+func Diff(before, after any) []Change {
+	var out []Change
+	diffValue("", before, after, false, &out)
+	return out
+}
+
+// Redact masks secret fields in a decoded JSON document.
+func Redact(v any) any {
+	return redactValue(v, false)
+}
+
+func diffValue(path string, before, after any, secret bool, out *[]Change) {
+	if len(*out) >= maxChanges || reflect.DeepEqual(before, after) {
+		return
+	}
+	bm, bok := before.(map[string]any)
+	am, aok := after.(map[string]any)
+	if bok && aok {
+		keys := make([]string, 0, len(bm)+len(am))
+		for k := range bm {
+			keys = append(keys, k)
+		}
+		for k := range am {
+			if _, ok := bm[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			diffValue(joinPath(path, k), bm[k], am[k], secret || isSecretKey(k), out)
+		}
+		return
+	}
+	bl, blok := before.([]any)
+	al, alok := after.([]any)
+	if blok && alok {
+		bk, bkeyed := keyedItems(bl)
+		ak, akeyed := keyedItems(al)
+		if bkeyed && akeyed {
+			keys := make([]string, 0, len(bk)+len(ak))
+			for k := range bk {
+				keys = append(keys, k)
+			}
+			for k := range ak {
+				if _, ok := bk[k]; !ok {
+					keys = append(keys, k)
+				}
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				diffValue(path+"["+k+"]", bk[k], ak[k], secret, out)
+			}
+			return
+		}
+	}
+	*out = append(*out, Change{
+		Path:   path,
+		Before: redactValue(before, secret),
+		After:  redactValue(after, secret),
+	})
+}
+
+// keyedItems indexes a list of objects by their id or name so reordering
+// and single-item edits show up as precise changes.
+func keyedItems(items []any) (map[string]any, bool) {
+	if len(items) == 0 {
+		return map[string]any{}, true
+	}
+	out := make(map[string]any, len(items))
+	for _, item := range items {
+		obj, ok := item.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		key := ""
+		for _, field := range []string{"id", "name"} {
+			if v, ok := obj[field]; ok {
+				key = fmt.Sprint(v)
+				break
+			}
+		}
+		if key == "" {
+			return nil, false
+		}
+		if _, dup := out[key]; dup {
+			return nil, false
+		}
+		out[key] = item
+	}
+	return out, true
+}
+
+func redactValue(v any, secret bool) any {
+	if v == nil {
+		return nil
+	}
+	if secret {
+		switch v.(type) {
+		case map[string]any, []any:
+		default:
+			return redactedValue
+		}
+	}
+	switch val := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(val))
+		for k, item := range val {
+			out[k] = redactValue(item, secret || isSecretKey(k))
+		}
+		return out
+	case []any:
+		out := make([]any, len(val))
+		for i, item := range val {
+			out[i] = redactValue(item, secret)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+func isSecretKey(key string) bool {
+	key = strings.ReplaceAll(strings.ToLower(strings.TrimSpace(key)), "-", "_")
+	for _, suffix := range secretKeySuffixes {
+		if strings.HasSuffix(key, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
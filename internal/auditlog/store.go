@@ -0,0 +1,195 @@
+package auditlog
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxMemoryEntries bounds how much history is kept queryable in memory; the
+// file itself is never truncated.
+const maxMemoryEntries = 100000
+
+// Entry is one audited admin mutation.
+type Entry struct {
+	ID          string    `json:"id"`
+	Timestamp   time.Time `json:"timestamp"`
+	Actor       string    `json:"actor"`
+	ClientIP    string    `json:"client_ip,omitempty"`
+	Method      string    `json:"method"`
+	Path        string    `json:"path"`
+	Query       string    `json:"query,omitempty"`
+	Status      int       `json:"status"`
+	DurationMS  int64     `json:"duration_ms"`
+	RequestBody any       `json:"request_body,omitempty"`
+	Changes     []Change  `json:"changes,omitempty"`
+	// Snapshot reports whether before/after state could be captured for
+	// the endpoint; when false only the request body is recorded.
+	Snapshot bool `json:"snapshot"`
+}
+
+// Query filters List results. Path matches as a prefix.
+type Query struct {
+	Path   string
+	Actor  string
+	Method string
+	Since  time.Time
+	Until  time.Time
+	Limit  int
+	Offset int
+}
+
+// Store is an append-only audit log backed by a JSON-lines file.
+type Store struct {
+	mu      sync.RWMutex
+	path    string
+	entries []Entry
+	seq     uint64
+}
+
+// NewStore opens (or creates) the log at path and loads existing entries.
+// An empty path keeps the log in memory only.
+func NewStore(path string) (*Store, error) {
+	s := &Store{path: strings.TrimSpace(path)}
+	if s.path == "" {
+		return s, nil
+	}
+	s.path = filepath.Clean(s.path)
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return nil, fmt.Errorf("create audit log dir: %w", err)
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// NewFromEnv reads ADMIN_AUDIT_LOG_PATH (default logs/admin-audit.jsonl).
+func NewFromEnv() (*Store, error) {
+	path := strings.TrimSpace(os.Getenv("ADMIN_AUDIT_LOG_PATH"))
+	if path == "" {
+		path = "logs/admin-audit.jsonl"
+	}
+	return NewStore(path)
+}
+
+func (s *Store) load() error {
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("open audit log: %w", err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16<<20)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			continue
+		}
+		s.seq++
+		s.entries = append(s.entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("read audit log: %w", err)
+	}
+	s.trimLocked()
+	return nil
+}
+
+// Append assigns an ID and timestamp, writes the entry to disk and makes it
+// queryable. The entry is only kept if the write succeeds.
+func (s *Store) Append(e Entry) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq++
+	e.ID = fmt.Sprintf("aud_%08d", s.seq)
+	if e.Timestamp.IsZero() {
+		e.Timestamp = time.Now().UTC()
+	}
+	if s.path != "" {
+		raw, err := json.Marshal(e)
+		if err != nil {
+			s.seq--
+			return Entry{}, err
+		}
+		if err := appendLine(s.path, raw); err != nil {
+			s.seq--
+			return Entry{}, err
+		}
+	}
+	s.entries = append(s.entries, e)
+	s.trimLocked()
+	return e, nil
+}
+
+func appendLine(path string, raw []byte) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.Write(append(raw, '\n')); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+func (s *Store) trimLocked() {
+	if over := len(s.entries) - maxMemoryEntries; over > 0 {
+		s.entries = append([]Entry(nil), s.entries[over:]...)
+	}
+}
+
+// List returns matching entries newest first, plus the total match count.
+func (s *Store) List(q Query) ([]Entry, int) {
+	s.mu.RLock()
+	matched := make([]Entry, 0)
+	for i := len(s.entries) - 1; i >= 0; i-- {
+		if q.matches(s.entries[i]) {
+			matched = append(matched, s.entries[i])
+		}
+	}
+	s.mu.RUnlock()
+	total := len(matched)
+	if q.Offset > 0 {
+		if q.Offset >= total {
+			return []Entry{}, total
+		}
+		matched = matched[q.Offset:]
+	}
+	if q.Limit > 0 && q.Limit < len(matched) {
+		matched = matched[:q.Limit]
+	}
+	return matched, total
+}
+
+func (q Query) matches(e Entry) bool {
+	if p := strings.TrimSpace(q.Path); p != "" && !strings.HasPrefix(e.Path, p) {
+		return false
+	}
+	if a := strings.TrimSpace(q.Actor); a != "" && e.Actor != a {
+		return false
+	}
+	if m := strings.TrimSpace(q.Method); m != "" && !strings.EqualFold(e.Method, m) {
+		return false
+	}
+	if !q.Since.IsZero() && e.Timestamp.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && !e.Timestamp.Before(q.Until) {
+		return false
+	}
+	return true
+}
@@ -29,6 +29,10 @@ type Service interface {
 	// SSO
 	LinkGitHub(userID, githubID string) error
 	LinkWeChat(userID, wechatID string) error
+
+	// Request defaults
+	GetPreferences(userID string) (Preferences, error)
+	SetPreferences(userID string, prefs Preferences) (Preferences, error)
 }
 
 // InMemoryService implements Service using memory map
@@ -38,7 +42,10 @@ type InMemoryService struct {
 	byGitHub  map[string]*User
 	byWeChat  map[string]*User
 	byAffCode map[string]*User
-	mu        sync.RWMutex
+	// deleted keeps the pre-delete state of soft-deleted users so they
+	// can be restored.
+	deleted map[string]*User
+	mu      sync.RWMutex
 }
 
 func NewInMemoryService() *InMemoryService {
@@ -48,6 +55,7 @@ func NewInMemoryService() *InMemoryService {
 		byGitHub:  make(map[string]*User),
 		byWeChat:  make(map[string]*User),
 		byAffCode: make(map[string]*User),
+		deleted:   make(map[string]*User),
 	}
 }
 
@@ -270,8 +278,13 @@ func (s *InMemoryService) Delete(id string) error {
 	if !ok {
 		return ErrUserNotFound
 	}
+	s.tombstoneLocked(user)
+	return nil
+}
 
-	// Soft delete
+// tombstoneLocked disables the user and frees its username and login
+// identities; the record itself stays so historical references resolve.
+func (s *InMemoryService) tombstoneLocked(user *User) {
 	user.Status = StatusDeleted
 	user.Username = fmt.Sprintf("deleted_%s", user.ID)
 	if email := strings.TrimSpace(user.Email); email != "" {
@@ -286,7 +299,104 @@ func (s *InMemoryService) Delete(id string) error {
 	if affCode := strings.TrimSpace(user.AffCode); affCode != "" {
 		delete(s.byAffCode, affCode)
 	}
+}
+
+// SoftDelete tombstones the user like Delete but keeps a snapshot so
+// Restore can bring back its username, email and linked logins.
+func (s *InMemoryService) SoftDelete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok || user.Status == StatusDeleted {
+		return ErrUserNotFound
+	}
+	snapshot := cloneUser(user)
+	now := time.Now()
+	snapshot.DeletedAt = &now
+	s.deleted[id] = snapshot
+	s.tombstoneLocked(user)
+	return nil
+}
+
+// Restore undoes SoftDelete. It fails with ErrUserAlreadyExists when the
+// username or email has been taken since.
+func (s *InMemoryService) Restore(id string) (*User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshot, ok := s.deleted[id]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	user, ok := s.users[id]
+	if !ok {
+		delete(s.deleted, id)
+		return nil, ErrUserNotFound
+	}
+	// IDs follow usernames, so a re-registered user can sit on the same ID.
+	if user.Status != StatusDeleted {
+		return nil, ErrUserAlreadyExists
+	}
+	for _, u := range s.users {
+		if u.ID != id && u.Username == snapshot.Username {
+			return nil, ErrUserAlreadyExists
+		}
+	}
+	if email := strings.TrimSpace(snapshot.Email); email != "" {
+		if other, taken := s.byEmail[email]; taken && other.ID != id {
+			return nil, ErrUserAlreadyExists
+		}
+	}
+
+	*user = *cloneUser(snapshot)
+	user.DeletedAt = nil
+	user.UpdatedAt = time.Now()
+	if email := strings.TrimSpace(user.Email); email != "" {
+		s.byEmail[email] = user
+	}
+	if githubID := strings.TrimSpace(user.GitHubID); githubID != "" {
+		if _, taken := s.byGitHub[githubID]; !taken {
+			s.byGitHub[githubID] = user
+		} else {
+			user.GitHubID = ""
+		}
+	}
+	if wechatID := strings.TrimSpace(user.WeChatID); wechatID != "" {
+		if _, taken := s.byWeChat[wechatID]; !taken {
+			s.byWeChat[wechatID] = user
+		} else {
+			user.WeChatID = ""
+		}
+	}
+	if affCode := strings.TrimSpace(user.AffCode); affCode != "" {
+		s.byAffCode[affCode] = user
+	}
+	delete(s.deleted, id)
+	return cloneUser(user), nil
+}
 
+// ListDeleted returns soft-deleted users as they were before deletion.
+func (s *InMemoryService) ListDeleted() []*User {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	list := make([]*User, 0, len(s.deleted))
+	for _, u := range s.deleted {
+		list = append(list, cloneUser(u))
+	}
+	return list
+}
+
+// Purge forgets a soft-deleted user's snapshot; the tombstone stays.
+func (s *InMemoryService) Purge(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.deleted[id]; !ok {
+		return ErrUserNotFound
+	}
+	delete(s.deleted, id)
 	return nil
 }
 
@@ -385,6 +495,36 @@ func (s *InMemoryService) LinkWeChat(userID, wechatID string) error {
 	return nil
 }
 
+func (s *InMemoryService) GetPreferences(userID string) (Preferences, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, ok := s.users[strings.TrimSpace(userID)]
+	if !ok {
+		return Preferences{}, ErrUserNotFound
+	}
+	return clonePreferences(user.Preferences), nil
+}
+
+func (s *InMemoryService) SetPreferences(userID string, prefs Preferences) (Preferences, error) {
+	prefs, err := prefs.Normalize()
+	if err != nil {
+		return Preferences{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[strings.TrimSpace(userID)]
+	if !ok {
+		return Preferences{}, ErrUserNotFound
+	}
+	user.Preferences = prefs
+	user.UpdatedAt = time.Now()
+
+	return clonePreferences(prefs), nil
+}
+
 // Helper functions
 func generateAffCode(username string) string {
 	// Simple generation - could be enhanced
@@ -399,5 +539,14 @@ func cloneUser(u *User) *User {
 		return nil
 	}
 	cp := *u
+	cp.Preferences = clonePreferences(u.Preferences)
 	return &cp
 }
+
+func clonePreferences(p Preferences) Preferences {
+	if p.Temperature != nil {
+		t := *p.Temperature
+		p.Temperature = &t
+	}
+	return p
+}
@@ -2,14 +2,17 @@ package auth
 
 import (
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 )
 
 const (
-	RoleGuest  = "guest"
-	RoleUser   = "user"
-	RoleAdmin  = "admin"
-	RoleRoot   = "root"
+	RoleGuest   = "guest"
+	RoleUser    = "user"
+	RoleAdmin   = "admin"
+	RoleRoot    = "root"
+	RoleAnalyst = "analyst" // read-only access to sampled, redacted traffic
 )
 
 const (
@@ -40,12 +43,47 @@ type User struct {
 	// Access token for API
 	AccessToken string `json:"access_token,omitempty"`
 
+	// Request defaults applied when a request omits them
+	Preferences Preferences `json:"preferences"`
+
 	// Invitation system
 	AffCode   string `json:"aff_code,omitempty"`   // User's invitation code
 	InviterID string `json:"inviter_id,omitempty"`  // Who invited this user
 
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at,omitempty"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// MaxSystemPromptBytes bounds Preferences.SystemPrompt.
+const MaxSystemPromptBytes = 32 << 10
+
+// Preferences are per-user defaults for mode, model and sampling, applied by
+// the gateway to requests that leave them unset. SystemPrompt is put in
+// front of every request's own system prompt.
+type Preferences struct {
+	Mode         string   `json:"mode,omitempty"`
+	Model        string   `json:"model,omitempty"`
+	Temperature  *float64 `json:"temperature,omitempty"`
+	SystemPrompt string   `json:"system_prompt,omitempty"`
+}
+
+// Normalize trims the preference values and rejects out-of-range ones.
+func (p Preferences) Normalize() (Preferences, error) {
+	p.Mode = strings.ToLower(strings.TrimSpace(p.Mode))
+	p.Model = strings.TrimSpace(p.Model)
+	p.SystemPrompt = strings.TrimSpace(p.SystemPrompt)
+	if len(p.SystemPrompt) > MaxSystemPromptBytes {
+		return p, fmt.Errorf("system_prompt must be at most %d bytes", MaxSystemPromptBytes)
+	}
+	if p.Temperature != nil {
+		if *p.Temperature < 0 || *p.Temperature > 2 {
+			return p, fmt.Errorf("temperature must be between 0 and 2")
+		}
+		t := *p.Temperature
+		p.Temperature = &t
+	}
+	return p, nil
 }
 
 var (
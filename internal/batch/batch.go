@@ -0,0 +1,605 @@
+// Package batch runs OpenAI-style batches: a JSONL file of requests is
+// validated, executed in the background under a shared concurrency limit
+// and turned into output and error JSONL files.
+package batch
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Batch statuses, as in the OpenAI API.
+const (
+	StatusValidating = "validating"
+	StatusFailed     = "failed"
+	StatusInProgress = "in_progress"
+	StatusFinalizing = "finalizing"
+	StatusCompleted  = "completed"
+	StatusExpired    = "expired"
+	StatusCancelling = "cancelling"
+	StatusCancelled  = "cancelled"
+)
+
+const (
+	defaultConcurrency = 4
+	defaultMaxLines    = 50000
+	defaultRetention   = 7 * 24 * time.Hour
+	defaultMaxAttempts = 3
+	defaultBackoff     = time.Second
+	defaultWindow      = 24 * time.Hour
+)
+
+// Endpoints lists the URLs a batch may target.
+var Endpoints = []string{"/v1/chat/completions", "/v1/responses", "/v1/messages"}
+
+var (
+	ErrNotFound        = errors.New("batch not found")
+	ErrInvalidEndpoint = errors.New("endpoint must be one of /v1/chat/completions, /v1/responses, /v1/messages")
+	ErrInvalidWindow   = errors.New("completion_window must be a duration such as 24h")
+	ErrFinished        = errors.New("batch has already finished")
+)
+
+// Batch mirrors the OpenAI batch object. Timestamps are Unix seconds.
+type Batch struct {
+	ID               string            `json:"id"`
+	Object           string            `json:"object"`
+	Endpoint         string            `json:"endpoint"`
+	Errors           *ErrorList        `json:"errors"`
+	InputFileID      string            `json:"input_file_id"`
+	CompletionWindow string            `json:"completion_window"`
+	Status           string            `json:"status"`
+	OutputFileID     *string           `json:"output_file_id"`
+	ErrorFileID      *string           `json:"error_file_id"`
+	CreatedAt        int64             `json:"created_at"`
+	InProgressAt     *int64            `json:"in_progress_at"`
+	ExpiresAt        int64             `json:"expires_at"`
+	FinalizingAt     *int64            `json:"finalizing_at"`
+	CompletedAt      *int64            `json:"completed_at"`
+	FailedAt         *int64            `json:"failed_at"`
+	ExpiredAt        *int64            `json:"expired_at"`
+	CancellingAt     *int64            `json:"cancelling_at"`
+	CancelledAt      *int64            `json:"cancelled_at"`
+	RequestCounts    RequestCounts     `json:"request_counts"`
+	Metadata         map[string]string `json:"metadata"`
+}
+
+type RequestCounts struct {
+	Total     int `json:"total"`
+	Completed int `json:"completed"`
+	Failed    int `json:"failed"`
+}
+
+// ErrorList holds the validation errors of a failed batch.
+type ErrorList struct {
+	Object string      `json:"object"`
+	Data   []LineError `json:"data"`
+}
+
+type LineError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Line    int    `json:"line,omitempty"`
+}
+
+// Line is one request of the input file.
+type Line struct {
+	CustomID string          `json:"custom_id"`
+	Method   string          `json:"method"`
+	URL      string          `json:"url"`
+	Body     json.RawMessage `json:"body"`
+}
+
+// Response is what one request returned.
+type Response struct {
+	StatusCode int             `json:"status_code"`
+	RequestID  string          `json:"request_id"`
+	Body       json.RawMessage `json:"body"`
+}
+
+// ResultLine is one line of the output or error file.
+type ResultLine struct {
+	ID       string     `json:"id"`
+	CustomID string     `json:"custom_id"`
+	Response *Response  `json:"response"`
+	Error    *LineError `json:"error"`
+}
+
+// CreateInput describes a new batch. Input is the JSONL request file;
+// Owner scopes the batch to one caller.
+type CreateInput struct {
+	Endpoint         string
+	CompletionWindow string
+	InputFileID      string
+	Metadata         map[string]string
+	Owner            string
+	ProjectID        string
+	Input            []byte
+}
+
+// Executor performs one request against endpoint.
+type Executor func(ctx context.Context, endpoint string, body json.RawMessage) Response
+
+// Config tunes batch processing. Concurrency bounds requests in flight
+// across all batches (default 4); MaxLines caps an input file (default
+// 50000); Retention is how long finished batches stay readable (default
+// 7 days). Requests answered 429 or 503 are retried up to MaxAttempts
+// times (default 3) with doubling Backoff (default 1s).
+type Config struct {
+	Concurrency int
+	MaxLines    int
+	Retention   time.Duration
+	MaxAttempts int
+	Backoff     time.Duration
+}
+
+// ListFilter pages List newest first, after the batch with ID After.
+type ListFilter struct {
+	Owner string
+	After string
+	Limit int
+}
+
+type entry struct {
+	batch     Batch
+	owner     string
+	projectID string
+	lines     []Line
+	output    []ResultLine
+	failures  []ResultLine
+	ctx       context.Context
+	cancel    context.CancelFunc
+	finished  time.Time
+}
+
+// Manager holds batches and runs them. It is safe for concurrent use.
+type Manager struct {
+	cfg  Config
+	sem  chan struct{}
+	seq  atomic.Uint64
+	wg   sync.WaitGroup
+	exec atomic.Pointer[Executor]
+
+	mu      sync.Mutex
+	batches map[string]*entry
+}
+
+func NewManager(cfg Config) *Manager {
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = defaultConcurrency
+	}
+	if cfg.MaxLines <= 0 {
+		cfg.MaxLines = defaultMaxLines
+	}
+	if cfg.Retention <= 0 {
+		cfg.Retention = defaultRetention
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = defaultMaxAttempts
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = defaultBackoff
+	}
+	return &Manager{cfg: cfg, sem: make(chan struct{}, cfg.Concurrency), batches: map[string]*entry{}}
+}
+
+// NewFromEnv reads BATCH_CONCURRENCY, BATCH_MAX_LINES and BATCH_RETENTION.
+func NewFromEnv() (*Manager, error) {
+	var cfg Config
+	for key, dst := range map[string]*int{"BATCH_CONCURRENCY": &cfg.Concurrency, "BATCH_MAX_LINES": &cfg.MaxLines} {
+		if raw := strings.TrimSpace(os.Getenv(key)); raw != "" {
+			n, err := strconv.Atoi(raw)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("invalid %s %q", key, raw)
+			}
+			*dst = n
+		}
+	}
+	if raw := strings.TrimSpace(os.Getenv("BATCH_RETENTION")); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid BATCH_RETENTION %q", raw)
+		}
+		cfg.Retention = d
+	}
+	return NewManager(cfg), nil
+}
+
+// SetExecutor installs the function that performs batch requests.
+func (m *Manager) SetExecutor(fn Executor) {
+	m.exec.Store(&fn)
+}
+
+// Create validates the input file and starts the batch. An input that
+// fails validation still yields a batch, in status failed with Errors set.
+// ctx supplies request-scoped values such as the caller's credentials;
+// its cancellation does not stop the batch.
+func (m *Manager) Create(ctx context.Context, in CreateInput) (Batch, error) {
+	endpoint := strings.TrimSpace(in.Endpoint)
+	if !validEndpoint(endpoint) {
+		return Batch{}, ErrInvalidEndpoint
+	}
+	windowText := strings.TrimSpace(in.CompletionWindow)
+	if windowText == "" {
+		windowText = "24h"
+	}
+	window, err := time.ParseDuration(windowText)
+	if err != nil || window <= 0 {
+		return Batch{}, ErrInvalidWindow
+	}
+
+	now := time.Now().UTC()
+	id := fmt.Sprintf("batch_%d%03d", now.UnixNano(), m.seq.Add(1)%1000)
+	e := &entry{
+		owner:     in.Owner,
+		projectID: in.ProjectID,
+		batch: Batch{
+			ID:               id,
+			Object:           "batch",
+			Endpoint:         endpoint,
+			InputFileID:      in.InputFileID,
+			CompletionWindow: windowText,
+			Status:           StatusValidating,
+			CreatedAt:        now.Unix(),
+			ExpiresAt:        now.Add(window).Unix(),
+			Metadata:         in.Metadata,
+		},
+	}
+	lines, lineErrs := Parse(in.Input, endpoint, m.cfg.MaxLines)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pruneLocked(now)
+	m.batches[id] = e
+	if len(lineErrs) > 0 {
+		e.batch.Status = StatusFailed
+		e.batch.FailedAt = unix(now)
+		e.batch.Errors = &ErrorList{Object: "list", Data: lineErrs}
+		e.finished = now
+		return e.batch, nil
+	}
+	e.lines = lines
+	e.batch.RequestCounts.Total = len(lines)
+	e.batch.Status = StatusInProgress
+	e.batch.InProgressAt = unix(now)
+	e.ctx, e.cancel = context.WithDeadline(context.WithoutCancel(ctx), now.Add(window))
+	m.wg.Add(1)
+	go m.run(e)
+	return e.batch, nil
+}
+
+// Get returns batch id if it belongs to owner.
+func (m *Manager) Get(owner, id string) (Batch, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.batches[id]
+	if !ok || e.owner != owner {
+		return Batch{}, false
+	}
+	return cloneBatch(e.batch), true
+}
+
+// List returns owner's batches newest first.
+func (m *Manager) List(filter ListFilter) (items []Batch, hasMore bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pruneLocked(time.Now().UTC())
+	all := make([]*entry, 0, len(m.batches))
+	for _, e := range m.batches {
+		if e.owner == filter.Owner {
+			all = append(all, e)
+		}
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].batch.CreatedAt != all[j].batch.CreatedAt {
+			return all[i].batch.CreatedAt > all[j].batch.CreatedAt
+		}
+		return all[i].batch.ID > all[j].batch.ID
+	})
+	start := 0
+	if filter.After != "" {
+		for i, e := range all {
+			if e.batch.ID == filter.After {
+				start = i + 1
+				break
+			}
+		}
+	}
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 20
+	}
+	for _, e := range all[start:] {
+		if len(items) == limit {
+			return items, true
+		}
+		items = append(items, cloneBatch(e.batch))
+	}
+	return items, false
+}
+
+// Cancel stops a running batch. Requests already sent finish; the rest
+// are not executed.
+func (m *Manager) Cancel(owner, id string) (Batch, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.batches[id]
+	if !ok || e.owner != owner {
+		return Batch{}, ErrNotFound
+	}
+	switch e.batch.Status {
+	case StatusInProgress, StatusValidating:
+	case StatusCancelling:
+		return cloneBatch(e.batch), nil
+	default:
+		return Batch{}, ErrFinished
+	}
+	e.batch.Status = StatusCancelling
+	e.batch.CancellingAt = unix(time.Now().UTC())
+	e.cancel()
+	return cloneBatch(e.batch), nil
+}
+
+// DeleteWhere stops and forgets every batch whose owner and project
+// match, with its results, and returns how many were removed.
+func (m *Manager) DeleteWhere(match func(owner, projectID string) bool) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := 0
+	for id, e := range m.batches {
+		if !match(e.owner, e.projectID) {
+			continue
+		}
+		if e.cancel != nil {
+			e.cancel()
+		}
+		delete(m.batches, id)
+		n++
+	}
+	return n
+}
+
+// Results returns the output file (errors false) or error file (errors
+// true) of a finished batch as JSONL.
+func (m *Manager) Results(owner, id string, errorFile bool) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.batches[id]
+	if !ok || e.owner != owner {
+		return nil, ErrNotFound
+	}
+	if e.finished.IsZero() {
+		return nil, fmt.Errorf("batch %s is %s; results are available once it finishes", id, e.batch.Status)
+	}
+	lines := e.output
+	if errorFile {
+		lines = e.failures
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, line := range lines {
+		_ = enc.Encode(line)
+	}
+	return buf.Bytes(), nil
+}
+
+// Wait blocks until running batches finish.
+func (m *Manager) Wait() {
+	m.wg.Wait()
+}
+
+func (m *Manager) run(e *entry) {
+	defer m.wg.Done()
+	var wg sync.WaitGroup
+	for i := range e.lines {
+		acquired := false
+		select {
+		case m.sem <- struct{}{}:
+			acquired = true
+		case <-e.ctx.Done():
+		}
+		if e.ctx.Err() != nil {
+			if acquired {
+				<-m.sem
+			}
+			break
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-m.sem }()
+			m.record(e, i, m.execute(e.ctx, e.batch.Endpoint, e.lines[i].Body))
+		}(i)
+	}
+	wg.Wait()
+
+	now := time.Now().UTC()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e.batch.FinalizingAt = unix(now)
+	expired := errors.Is(e.ctx.Err(), context.DeadlineExceeded)
+	recorded := len(e.output) + len(e.failures)
+	if expired && recorded < len(e.lines) {
+		seen := map[string]bool{}
+		for _, r := range append(append([]ResultLine(nil), e.output...), e.failures...) {
+			seen[r.CustomID] = true
+		}
+		for _, line := range e.lines {
+			if !seen[line.CustomID] {
+				e.failures = append(e.failures, ResultLine{
+					ID:       m.lineID(),
+					CustomID: line.CustomID,
+					Error:    &LineError{Code: "batch_expired", Message: "This request could not be executed before the completion window expired."},
+				})
+			}
+		}
+	}
+	switch {
+	case e.batch.Status == StatusCancelling:
+		e.batch.Status = StatusCancelled
+		e.batch.CancelledAt = unix(now)
+	case expired:
+		e.batch.Status = StatusExpired
+		e.batch.ExpiredAt = unix(now)
+	default:
+		e.batch.Status = StatusCompleted
+		e.batch.CompletedAt = unix(now)
+	}
+	if len(e.output) > 0 {
+		id := e.batch.ID + "_output"
+		e.batch.OutputFileID = &id
+	}
+	if len(e.failures) > 0 {
+		id := e.batch.ID + "_errors"
+		e.batch.ErrorFileID = &id
+	}
+	e.cancel()
+	e.finished = now
+}
+
+// execute runs one request, retrying while the gateway is saturated.
+func (m *Manager) execute(ctx context.Context, endpoint string, body json.RawMessage) Response {
+	fn := m.exec.Load()
+	if fn == nil {
+		return Response{StatusCode: 500, Body: json.RawMessage(`{"error":{"message":"no executor configured"}}`)}
+	}
+	backoff := m.cfg.Backoff
+	var resp Response
+	for attempt := 1; ; attempt++ {
+		resp = (*fn)(ctx, endpoint, body)
+		if resp.StatusCode != 429 && resp.StatusCode != 503 || attempt >= m.cfg.MaxAttempts {
+			return resp
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return resp
+		}
+		backoff *= 2
+	}
+}
+
+func (m *Manager) record(e *entry, i int, resp Response) {
+	line := ResultLine{ID: m.lineID(), CustomID: e.lines[i].CustomID, Response: &resp}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		e.output = append(e.output, line)
+		e.batch.RequestCounts.Completed++
+		return
+	}
+	line.Error = &LineError{Code: strconv.Itoa(resp.StatusCode), Message: errorMessage(resp.Body)}
+	e.failures = append(e.failures, line)
+	e.batch.RequestCounts.Failed++
+}
+
+func (m *Manager) lineID() string {
+	return fmt.Sprintf("batch_req_%d", m.seq.Add(1))
+}
+
+// pruneLocked forgets batches finished longer than the retention ago.
+func (m *Manager) pruneLocked(now time.Time) {
+	cutoff := now.Add(-m.cfg.Retention)
+	for id, e := range m.batches {
+		if !e.finished.IsZero() && e.finished.Before(cutoff) {
+			delete(m.batches, id)
+		}
+	}
+}
+
+// Parse reads a JSONL input file. Every line needs a unique custom_id,
+// method POST, url equal to endpoint and a JSON object body that does not
+// stream; blank lines are skipped.
+func Parse(input []byte, endpoint string, maxLines int) ([]Line, []LineError) {
+	var lines []Line
+	var errs []LineError
+	seen := map[string]bool{}
+	scanner := bufio.NewScanner(bytes.NewReader(input))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16<<20)
+	n := 0
+	for scanner.Scan() {
+		n++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		fail := func(code, msg string) { errs = append(errs, LineError{Code: code, Message: msg, Line: n}) }
+		var line Line
+		if err := json.Unmarshal([]byte(text), &line); err != nil {
+			fail("invalid_json_line", "line is not a JSON object")
+			continue
+		}
+		var body map[string]any
+		switch {
+		case line.CustomID == "":
+			fail("missing_custom_id", "custom_id is required")
+		case seen[line.CustomID]:
+			fail("duplicate_custom_id", fmt.Sprintf("custom_id %q appears more than once", line.CustomID))
+		case !strings.EqualFold(line.Method, "POST"):
+			fail("invalid_method", "method must be POST")
+		case line.URL != endpoint:
+			fail("mismatched_url", fmt.Sprintf("url must be %s, the batch endpoint", endpoint))
+		case json.Unmarshal(line.Body, &body) != nil || body == nil:
+			fail("invalid_body", "body must be a JSON object")
+		case body["stream"] == true:
+			fail("invalid_body", "batch requests cannot stream")
+		default:
+			seen[line.CustomID] = true
+			lines = append(lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		errs = append(errs, LineError{Code: "invalid_file", Message: err.Error()})
+	}
+	if len(lines)+len(errs) == 0 {
+		errs = append(errs, LineError{Code: "empty_file", Message: "input file has no requests"})
+	}
+	if len(lines) > maxLines {
+		errs = append(errs, LineError{Code: "too_many_requests", Message: fmt.Sprintf("input file has %d requests; the limit is %d", len(lines), maxLines)})
+	}
+	return lines, errs
+}
+
+func validEndpoint(endpoint string) bool {
+	for _, e := range Endpoints {
+		if e == endpoint {
+			return true
+		}
+	}
+	return false
+}
+
+func errorMessage(body json.RawMessage) string {
+	var env struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &env) == nil && env.Error.Message != "" {
+		return env.Error.Message
+	}
+	return "request failed"
+}
+
+func unix(t time.Time) *int64 {
+	v := t.Unix()
+	return &v
+}
+
+func cloneBatch(b Batch) Batch {
+	if b.Errors != nil {
+		errs := *b.Errors
+		errs.Data = append([]LineError(nil), b.Errors.Data...)
+		b.Errors = &errs
+	}
+	return b
+}
@@ -0,0 +1,630 @@
+package billing
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"ccgateway/internal/costtrack"
+)
+
+// maxLedgerRecords bounds the in-memory window; the file keeps everything.
+const maxLedgerRecords = 200000
+
+// Group-by dimensions accepted by Summarize.
+const (
+	GroupByUser    = "user"
+	GroupByToken   = "token"
+	GroupByModel   = "model"
+	GroupByDay     = "day"
+	GroupByChannel = "channel"
+)
+
+var ErrInvalidGroupBy = errors.New("group_by must be one of user, token, model, day, channel")
+
+// UsageRecord is one billed request. Costs are computed with the price
+// table in force when the request finished and never recomputed.
+type UsageRecord struct {
+	ID            string    `json:"id"`
+	Timestamp     time.Time `json:"timestamp"`
+	RunID         string    `json:"run_id,omitempty"`
+	Path          string    `json:"path,omitempty"`
+	UserID        string    `json:"user_id,omitempty"`
+	ProjectID     string    `json:"project_id,omitempty"`
+	TokenID       int64     `json:"token_id,omitempty"`
+	TokenName     string    `json:"token_name,omitempty"`
+	ClientModel   string    `json:"client_model,omitempty"`
+	UpstreamModel string    `json:"upstream_model"`
+	Adapter       string    `json:"adapter,omitempty"`
+	Stream        bool      `json:"stream,omitempty"`
+	InputTokens   int       `json:"input_tokens"`
+	OutputTokens  int       `json:"output_tokens"`
+	InputCostUSD  float64   `json:"input_cost_usd"`
+	OutputCostUSD float64   `json:"output_cost_usd"`
+	CostUSD       float64   `json:"cost_usd"`
+	// PriceKey is the price table entry that matched UpstreamModel, or
+	// "channel:<id>" when the serving channel carried its own prices.
+	PriceKey string `json:"price_key,omitempty"`
+	// ChannelID is the channel that served the request; Group and
+	// GroupRatio record the billing group markup applied to the cost.
+	ChannelID  int64   `json:"channel_id,omitempty"`
+	Group      string  `json:"group,omitempty"`
+	GroupRatio float64 `json:"group_ratio,omitempty"`
+}
+
+// UsageQuery filters ledger reads. Zero values match everything.
+type UsageQuery struct {
+	Since     time.Time
+	Until     time.Time
+	UserID    string
+	ProjectID string
+	TokenID   int64
+	Model     string
+	Limit     int
+	Offset    int
+}
+
+// UsageGroup is one row of a grouped summary.
+type UsageGroup struct {
+	Key          string  `json:"key"`
+	Requests     int     `json:"requests"`
+	InputTokens  int64   `json:"input_tokens"`
+	OutputTokens int64   `json:"output_tokens"`
+	CostUSD      float64 `json:"cost_usd"`
+}
+
+// Ledger records per-request usage and cost in a JSON-lines file.
+type Ledger struct {
+	mu      sync.RWMutex
+	path    string
+	file    *os.File
+	prices  map[string]costtrack.ModelPricing
+	ratios  map[string]float64
+	records []UsageRecord
+	seq     uint64
+}
+
+// NewLedger opens (or creates) the ledger at path and loads existing
+// records. An empty path keeps the ledger in memory only. A nil price table
+// falls back to costtrack.DefaultPricing.
+func NewLedger(file string, prices map[string]costtrack.ModelPricing) (*Ledger, error) {
+	if prices == nil {
+		prices = costtrack.DefaultPricing()
+	}
+	l := &Ledger{path: strings.TrimSpace(file), prices: clonePrices(prices)}
+	if l.path == "" {
+		return l, nil
+	}
+	l.path = filepath.Clean(l.path)
+	if err := os.MkdirAll(filepath.Dir(l.path), 0o755); err != nil {
+		return nil, fmt.Errorf("create usage ledger dir: %w", err)
+	}
+	if err := l.load(); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open usage ledger: %w", err)
+	}
+	l.file = f
+	return l, nil
+}
+
+// LedgerFromEnv reads BILLING_LEDGER_PATH (default logs/usage-ledger.jsonl),
+// prices from PricesFromEnv and group ratios from BILLING_GROUP_RATIOS_JSON
+// (e.g. {"vip":0.8,"default":1.2}).
+func LedgerFromEnv() (*Ledger, error) {
+	prices, err := PricesFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	var ratios map[string]float64
+	if raw := strings.TrimSpace(os.Getenv("BILLING_GROUP_RATIOS_JSON")); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &ratios); err != nil {
+			return nil, fmt.Errorf("invalid BILLING_GROUP_RATIOS_JSON: %w", err)
+		}
+	}
+	file := strings.TrimSpace(os.Getenv("BILLING_LEDGER_PATH"))
+	if file == "" {
+		file = "logs/usage-ledger.jsonl"
+	}
+	l, err := NewLedger(file, prices)
+	if err != nil {
+		return nil, err
+	}
+	if err := l.SetGroupRatios(ratios); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// PricesFromEnv returns the default price table overlaid with
+// MODEL_PRICING_JSON, the same table the cost tracker uses.
+func PricesFromEnv() (map[string]costtrack.ModelPricing, error) {
+	prices := costtrack.DefaultPricing()
+	if raw := strings.TrimSpace(os.Getenv("MODEL_PRICING_JSON")); raw != "" {
+		var custom map[string]costtrack.ModelPricing
+		if err := json.Unmarshal([]byte(raw), &custom); err != nil {
+			return nil, fmt.Errorf("invalid MODEL_PRICING_JSON: %w", err)
+		}
+		for k, v := range custom {
+			prices[k] = v
+		}
+	}
+	return prices, nil
+}
+
+func (l *Ledger) load() error {
+	f, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("open usage ledger: %w", err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4<<20)
+	for scanner.Scan() {
+		var rec UsageRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			continue
+		}
+		l.seq++
+		l.records = append(l.records, rec)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("read usage ledger: %w", err)
+	}
+	l.trimLocked()
+	return nil
+}
+
+// Record prices rec against the current table, assigns an ID and appends
+// it. The record is only kept if the write succeeds.
+func (l *Ledger) Record(rec UsageRecord) (UsageRecord, error) {
+	return l.RecordServed(rec, nil)
+}
+
+// RecordServed is Record for a request served by a channel with its own
+// prices: a non-nil channelPrice replaces the model price table. Either way
+// the cost is multiplied by the ratio of rec.Group.
+func (l *Ledger) RecordServed(rec UsageRecord, channelPrice *costtrack.ModelPricing) (UsageRecord, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if rec.Timestamp.IsZero() {
+		rec.Timestamp = time.Now().UTC()
+	}
+	var price costtrack.ModelPricing
+	if channelPrice != nil {
+		price = *channelPrice
+		rec.PriceKey = "channel:" + strconv.FormatInt(rec.ChannelID, 10)
+	} else {
+		rec.PriceKey, price = matchPrice(l.prices, rec.UpstreamModel)
+	}
+	ratio := 1.0
+	if r, ok := l.ratios[rec.Group]; ok {
+		ratio = r
+	}
+	rec.GroupRatio = 0
+	if ratio != 1 {
+		rec.GroupRatio = ratio
+	}
+	rec.InputCostUSD = roundUSD(float64(rec.InputTokens) / 1_000_000 * price.InputPer1M * ratio)
+	rec.OutputCostUSD = roundUSD(float64(rec.OutputTokens) / 1_000_000 * price.OutputPer1M * ratio)
+	rec.CostUSD = roundUSD(rec.InputCostUSD + rec.OutputCostUSD)
+	l.seq++
+	rec.ID = fmt.Sprintf("use_%010d", l.seq)
+	if l.file != nil {
+		raw, err := json.Marshal(rec)
+		if err != nil {
+			l.seq--
+			return UsageRecord{}, err
+		}
+		if _, err := l.file.Write(append(raw, '\n')); err != nil {
+			l.seq--
+			return UsageRecord{}, fmt.Errorf("write usage ledger: %w", err)
+		}
+	}
+	l.records = append(l.records, rec)
+	l.trimLocked()
+	return rec, nil
+}
+
+// List returns matching records newest first plus the total match count.
+func (l *Ledger) List(q UsageQuery) ([]UsageRecord, int) {
+	matched := l.match(q)
+	sort.SliceStable(matched, func(i, j int) bool { return matched[i].Timestamp.After(matched[j].Timestamp) })
+	total := len(matched)
+	if q.Offset > 0 {
+		if q.Offset >= len(matched) {
+			return []UsageRecord{}, total
+		}
+		matched = matched[q.Offset:]
+	}
+	if q.Limit > 0 && len(matched) > q.Limit {
+		matched = matched[:q.Limit]
+	}
+	return matched, total
+}
+
+// Summarize groups matching records by groupBy and returns the groups
+// (highest cost first; chronological for day) and the overall totals.
+func (l *Ledger) Summarize(q UsageQuery, groupBy string) ([]UsageGroup, UsageGroup, error) {
+	keyOf, err := groupKeyFunc(groupBy)
+	if err != nil {
+		return nil, UsageGroup{}, err
+	}
+	groups := map[string]*UsageGroup{}
+	totals := UsageGroup{Key: "total"}
+	for _, rec := range l.match(q) {
+		key := keyOf(rec)
+		g := groups[key]
+		if g == nil {
+			g = &UsageGroup{Key: key}
+			groups[key] = g
+		}
+		for _, agg := range []*UsageGroup{g, &totals} {
+			agg.Requests++
+			agg.InputTokens += int64(rec.InputTokens)
+			agg.OutputTokens += int64(rec.OutputTokens)
+			agg.CostUSD += rec.CostUSD
+		}
+	}
+	out := make([]UsageGroup, 0, len(groups))
+	for _, g := range groups {
+		g.CostUSD = roundUSD(g.CostUSD)
+		out = append(out, *g)
+	}
+	totals.CostUSD = roundUSD(totals.CostUSD)
+	sort.Slice(out, func(i, j int) bool {
+		if groupBy == GroupByDay {
+			return out[i].Key < out[j].Key
+		}
+		if out[i].CostUSD != out[j].CostUSD {
+			return out[i].CostUSD > out[j].CostUSD
+		}
+		return out[i].Key < out[j].Key
+	})
+	return out, totals, nil
+}
+
+// Prices returns a copy of the price table.
+func (l *Ledger) Prices() map[string]costtrack.ModelPricing {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return clonePrices(l.prices)
+}
+
+// SetPrices replaces the price table for requests recorded from now on.
+func (l *Ledger) SetPrices(prices map[string]costtrack.ModelPricing) error {
+	for model, p := range prices {
+		if strings.TrimSpace(model) == "" {
+			return fmt.Errorf("price table keys must be non-empty model names or patterns")
+		}
+		if _, err := path.Match(model, ""); err != nil {
+			return fmt.Errorf("invalid model pattern %q", model)
+		}
+		if p.InputPer1M < 0 || p.OutputPer1M < 0 {
+			return fmt.Errorf("prices for %q must be non-negative", model)
+		}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.prices = clonePrices(prices)
+	return nil
+}
+
+// GroupRatios returns a copy of the billing group markups.
+func (l *Ledger) GroupRatios() map[string]float64 {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	out := make(map[string]float64, len(l.ratios))
+	for k, v := range l.ratios {
+		out[k] = v
+	}
+	return out
+}
+
+// ValidateGroupRatios checks group ratios without installing them.
+func ValidateGroupRatios(ratios map[string]float64) error {
+	for group, ratio := range ratios {
+		if strings.TrimSpace(group) == "" {
+			return fmt.Errorf("group ratio keys must be non-empty group names")
+		}
+		if ratio < 0 || math.IsNaN(ratio) || math.IsInf(ratio, 0) {
+			return fmt.Errorf("ratio for group %q must be a non-negative number", group)
+		}
+	}
+	return nil
+}
+
+// SetGroupRatios replaces the cost multipliers per billing group for
+// requests recorded from now on. Groups without a ratio pay 1x.
+func (l *Ledger) SetGroupRatios(ratios map[string]float64) error {
+	if err := ValidateGroupRatios(ratios); err != nil {
+		return err
+	}
+	out := make(map[string]float64, len(ratios))
+	for group, ratio := range ratios {
+		out[strings.TrimSpace(group)] = ratio
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.ratios = out
+	return nil
+}
+
+// DeleteWhere removes every record for which match returns true, from
+// memory and from the ledger file, and returns how many were removed. The
+// file is rewritten in place, so records older than the in-memory window
+// are purged too.
+func (l *Ledger) DeleteWhere(match func(UsageRecord) bool) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	kept := make([]UsageRecord, 0, len(l.records))
+	for _, rec := range l.records {
+		if !match(rec) {
+			kept = append(kept, rec)
+		}
+	}
+	removed := len(l.records) - len(kept)
+	if l.file == nil {
+		l.records = kept
+		return removed, nil
+	}
+	fileRemoved, err := l.rewriteLocked(match)
+	if err != nil {
+		return 0, err
+	}
+	l.records = kept
+	return fileRemoved, nil
+}
+
+// rewriteLocked copies the ledger file without the matching records and
+// swaps it in, reopening the append handle.
+func (l *Ledger) rewriteLocked(match func(UsageRecord) bool) (int, error) {
+	src, err := os.Open(l.path)
+	if err != nil {
+		return 0, fmt.Errorf("open usage ledger: %w", err)
+	}
+	defer src.Close()
+	tmp := l.path + ".tmp"
+	dst, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return 0, fmt.Errorf("rewrite usage ledger: %w", err)
+	}
+	removed := 0
+	scanner := bufio.NewScanner(src)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4<<20)
+	w := bufio.NewWriter(dst)
+	for scanner.Scan() {
+		var rec UsageRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err == nil && match(rec) {
+			removed++
+			continue
+		}
+		_, _ = w.Write(scanner.Bytes())
+		_ = w.WriteByte('\n')
+	}
+	err = scanner.Err()
+	if err == nil {
+		err = w.Flush()
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return 0, fmt.Errorf("rewrite usage ledger: %w", err)
+	}
+	if err := os.Rename(tmp, l.path); err != nil {
+		_ = os.Remove(tmp)
+		return 0, fmt.Errorf("rewrite usage ledger: %w", err)
+	}
+	_ = l.file.Close()
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		l.file = nil
+		return removed, fmt.Errorf("open usage ledger: %w", err)
+	}
+	l.file = f
+	return removed, nil
+}
+
+// Close releases the ledger file.
+func (l *Ledger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+func (l *Ledger) match(q UsageQuery) []UsageRecord {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	model := strings.TrimSpace(q.Model)
+	out := make([]UsageRecord, 0)
+	for _, rec := range l.records {
+		if !q.Since.IsZero() && rec.Timestamp.Before(q.Since) {
+			continue
+		}
+		if !q.Until.IsZero() && rec.Timestamp.After(q.Until) {
+			continue
+		}
+		if q.UserID != "" && rec.UserID != q.UserID {
+			continue
+		}
+		if q.ProjectID != "" && rec.ProjectID != q.ProjectID {
+			continue
+		}
+		if q.TokenID != 0 && rec.TokenID != q.TokenID {
+			continue
+		}
+		if model != "" && rec.UpstreamModel != model {
+			continue
+		}
+		out = append(out, rec)
+	}
+	return out
+}
+
+func (l *Ledger) trimLocked() {
+	if over := len(l.records) - maxLedgerRecords; over > 0 {
+		l.records = append([]UsageRecord(nil), l.records[over:]...)
+	}
+}
+
+func groupKeyFunc(groupBy string) (func(UsageRecord) string, error) {
+	orDash := func(s string) string {
+		if strings.TrimSpace(s) == "" {
+			return "-"
+		}
+		return s
+	}
+	switch strings.ToLower(strings.TrimSpace(groupBy)) {
+	case GroupByUser:
+		return func(r UsageRecord) string { return orDash(r.UserID) }, nil
+	case GroupByToken:
+		return func(r UsageRecord) string {
+			if r.TokenID == 0 {
+				return "-"
+			}
+			return strconv.FormatInt(r.TokenID, 10)
+		}, nil
+	case GroupByModel:
+		return func(r UsageRecord) string { return orDash(r.UpstreamModel) }, nil
+	case GroupByDay:
+		return func(r UsageRecord) string { return r.Timestamp.UTC().Format("2006-01-02") }, nil
+	case GroupByChannel:
+		return func(r UsageRecord) string {
+			if r.ChannelID == 0 {
+				return "-"
+			}
+			return strconv.FormatInt(r.ChannelID, 10)
+		}, nil
+	}
+	return nil, ErrInvalidGroupBy
+}
+
+// matchPrice picks the exact entry, then the most specific glob pattern,
+// then "*". Unpriced models cost nothing.
+func matchPrice(prices map[string]costtrack.ModelPricing, model string) (string, costtrack.ModelPricing) {
+	model = strings.TrimSpace(model)
+	if p, ok := prices[model]; ok && model != "" {
+		return model, p
+	}
+	bestKey, bestLen := "", -1
+	for pattern := range prices {
+		if pattern == "*" || !strings.ContainsAny(pattern, "*?[") {
+			continue
+		}
+		if ok, _ := path.Match(pattern, model); !ok {
+			continue
+		}
+		specificity := len(strings.Trim(pattern, "*?"))
+		if specificity > bestLen || (specificity == bestLen && pattern < bestKey) {
+			bestKey, bestLen = pattern, specificity
+		}
+	}
+	if bestKey != "" {
+		return bestKey, prices[bestKey]
+	}
+	if p, ok := prices["*"]; ok {
+		return "*", p
+	}
+	return "", costtrack.ModelPricing{}
+}
+
+// WriteRecordsCSV writes records with a header row.
+func WriteRecordsCSV(w io.Writer, records []UsageRecord) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"id", "timestamp", "run_id", "path", "user_id", "token_id", "token_name", "client_model", "upstream_model", "adapter", "stream", "input_tokens", "output_tokens", "input_cost_usd", "output_cost_usd", "cost_usd", "price_key", "channel_id", "group", "group_ratio"})
+	for _, r := range records {
+		tokenID := ""
+		if r.TokenID != 0 {
+			tokenID = strconv.FormatInt(r.TokenID, 10)
+		}
+		channelID, groupRatio := "", ""
+		if r.ChannelID != 0 {
+			channelID = strconv.FormatInt(r.ChannelID, 10)
+		}
+		if r.GroupRatio != 0 {
+			groupRatio = strconv.FormatFloat(r.GroupRatio, 'f', -1, 64)
+		}
+		_ = cw.Write([]string{
+			r.ID,
+			r.Timestamp.UTC().Format(time.RFC3339),
+			r.RunID,
+			r.Path,
+			r.UserID,
+			tokenID,
+			r.TokenName,
+			r.ClientModel,
+			r.UpstreamModel,
+			r.Adapter,
+			strconv.FormatBool(r.Stream),
+			strconv.Itoa(r.InputTokens),
+			strconv.Itoa(r.OutputTokens),
+			formatUSD(r.InputCostUSD),
+			formatUSD(r.OutputCostUSD),
+			formatUSD(r.CostUSD),
+			r.PriceKey,
+			channelID,
+			r.Group,
+			groupRatio,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteGroupsCSV writes a grouped summary with a header row named after
+// the grouping dimension.
+func WriteGroupsCSV(w io.Writer, groupBy string, groups []UsageGroup) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{groupBy, "requests", "input_tokens", "output_tokens", "cost_usd"})
+	for _, g := range groups {
+		_ = cw.Write([]string{
+			g.Key,
+			strconv.Itoa(g.Requests),
+			strconv.FormatInt(g.InputTokens, 10),
+			strconv.FormatInt(g.OutputTokens, 10),
+			formatUSD(g.CostUSD),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func clonePrices(in map[string]costtrack.ModelPricing) map[string]costtrack.ModelPricing {
+	out := make(map[string]costtrack.ModelPricing, len(in))
+	for k, v := range in {
+		out[strings.TrimSpace(k)] = v
+	}
+	return out
+}
+
+func roundUSD(v float64) float64 {
+	return math.Round(v*1e8) / 1e8
+}
+
+func formatUSD(v float64) string {
+	return strconv.FormatFloat(v, 'f', 8, 64)
+}
@@ -0,0 +1,231 @@
+package ccevent
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"ccgateway/internal/logging"
+)
+
+const (
+	// defaultMaxEvents bounds the store when EVENT_RETENTION_MAX_EVENTS is
+	// unset, so a long-running gateway no longer grows without limit.
+	defaultMaxEvents = 100000
+	// compactMinStale is how many dropped events the file may carry before
+	// retention triggers a rewrite.
+	compactMinStale = 1024
+)
+
+// Retention bounds how many events are kept and for how long. Zero values
+// disable the respective limit.
+type Retention struct {
+	MaxEvents int
+	MaxAge    time.Duration
+}
+
+// Open loads the event log at path and keeps appending to it. Events
+// dropped by retention are compacted out of the file once enough have
+// accumulated; explicit deletes rewrite it at once. An empty path keeps
+// the store in memory only.
+func Open(path string, retention Retention) (*Store, error) {
+	s := NewStore()
+	s.retention = retention
+	s.path = strings.TrimSpace(path)
+	if s.path == "" {
+		return s, nil
+	}
+	s.path = filepath.Clean(s.path)
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return nil, fmt.Errorf("create event store dir: %w", err)
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	s.pruneLocked(time.Now().UTC())
+	if s.stale > 0 {
+		if err := s.compactLocked(); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// NewFromEnv reads EVENT_STORE_PATH (empty keeps events in memory),
+// EVENT_RETENTION_MAX_EVENTS (default 100000, 0 disables) and
+// EVENT_RETENTION_MAX_AGE (a Go duration such as 720h; default unlimited).
+func NewFromEnv() (*Store, error) {
+	retention := Retention{MaxEvents: defaultMaxEvents}
+	if raw := strings.TrimSpace(os.Getenv("EVENT_RETENTION_MAX_EVENTS")); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid EVENT_RETENTION_MAX_EVENTS %q", raw)
+		}
+		retention.MaxEvents = n
+	}
+	if raw := strings.TrimSpace(os.Getenv("EVENT_RETENTION_MAX_AGE")); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid EVENT_RETENTION_MAX_AGE %q", raw)
+		}
+		retention.MaxAge = d
+	}
+	return Open(os.Getenv("EVENT_STORE_PATH"), retention)
+}
+
+// Retention reports the limits the store enforces.
+func (s *Store) Retention() Retention {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.retention
+}
+
+// DeleteBefore removes events created before the cutoff, optionally only
+// those of one event type, and returns how many were removed.
+func (s *Store) DeleteBefore(before time.Time, eventType string) int {
+	eventType = strings.TrimSpace(eventType)
+	return s.DeleteWhere(func(e Event) bool {
+		if eventType != "" && e.EventType != eventType {
+			return false
+		}
+		return e.CreatedAt.Before(before)
+	})
+}
+
+// Compact applies retention and rewrites the backing file so it holds only
+// the events still kept.
+func (s *Store) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneLocked(time.Now().UTC())
+	return s.compactLocked()
+}
+
+func (s *Store) load() error {
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("open event store: %w", err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16<<20)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var e Event
+		if err := json.Unmarshal([]byte(line), &e); err != nil || e.ID == "" {
+			s.stale++
+			continue
+		}
+		s.events = append(s.events, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("read event store: %w", err)
+	}
+	s.counter = uint64(len(s.events))
+	return nil
+}
+
+// persistLocked appends one event to the backing file.
+func (s *Store) persistLocked(e Event) error {
+	if s.path == "" {
+		return nil
+	}
+	raw, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("append event: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(raw, '\n')); err != nil {
+		return fmt.Errorf("append event: %w", err)
+	}
+	return nil
+}
+
+// pruneLocked drops events beyond the retention limits. Events are kept in
+// creation order, so both limits only ever cut from the front.
+func (s *Store) pruneLocked(now time.Time) {
+	drop := 0
+	if s.retention.MaxAge > 0 {
+		cutoff := now.Add(-s.retention.MaxAge)
+		for drop < len(s.events) && s.events[drop].CreatedAt.Before(cutoff) {
+			drop++
+		}
+	}
+	if max := s.retention.MaxEvents; max > 0 && len(s.events)-drop > max {
+		drop = len(s.events) - max
+	}
+	if drop == 0 {
+		return
+	}
+	clear(s.events[:drop])
+	s.events = s.events[drop:]
+	if s.path != "" {
+		s.stale += drop
+	}
+}
+
+// maybeCompactLocked rewrites the file once it carries more dropped events
+// than live ones.
+func (s *Store) maybeCompactLocked() {
+	if s.path == "" || s.stale < compactMinStale || s.stale < len(s.events) {
+		return
+	}
+	if err := s.compactLocked(); err != nil {
+		logging.Component("ccevent").Warn("event store compaction failed", "path", s.path, "error", err)
+	}
+}
+
+// compactLocked writes the kept events to a temporary file and renames it
+// over the log, so a crash leaves either the old or the new file.
+func (s *Store) compactLocked() error {
+	if s.path == "" {
+		return nil
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("compact event store: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	for _, e := range s.events {
+		if err := enc.Encode(e); err != nil {
+			tmp.Close()
+			return fmt.Errorf("compact event store: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("compact event store: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("compact event store: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("compact event store: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0o600); err != nil {
+		return fmt.Errorf("compact event store: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("compact event store: %w", err)
+	}
+	s.stale = 0
+	return nil
+}
@@ -6,6 +6,8 @@ import (
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"ccgateway/internal/logging"
 )
 
 type Event struct {
@@ -49,6 +51,12 @@ type Store struct {
 	events  []Event
 	counter uint64
 	subs    *SubscriberRegistry
+
+	// path is the append-only JSON-lines log behind the store; empty keeps
+	// events in memory only. stale counts lines in it no longer kept.
+	path      string
+	retention Retention
+	stale     int
 }
 
 func NewStore() *Store {
@@ -88,7 +96,12 @@ func (s *Store) Append(in AppendInput) (Event, error) {
 	if e.TeamID == "" {
 		e.TeamID = strings.TrimSpace(valueAsString(in.Data["team_id"]))
 	}
+	if err := s.persistLocked(e); err != nil {
+		return Event{}, err
+	}
 	s.events = append(s.events, e)
+	s.pruneLocked(e.CreatedAt)
+	s.maybeCompactLocked()
 	// Notify SSE subscribers outside the lock
 	cloned := cloneEvent(e)
 	go s.subs.Notify(cloned)
@@ -111,9 +124,17 @@ func (s *Store) List(filter ListFilter) []Event {
 	teamID := strings.TrimSpace(filter.TeamID)
 	subagentID := strings.TrimSpace(filter.SubagentID)
 
+	var cutoff time.Time
+	if s.retention.MaxAge > 0 {
+		cutoff = time.Now().UTC().Add(-s.retention.MaxAge)
+	}
+
 	out := make([]Event, 0, limit)
 	for i := len(s.events) - 1; i >= 0 && len(out) < limit; i-- {
 		e := s.events[i]
+		if e.CreatedAt.Before(cutoff) {
+			break
+		}
 		if eventType != "" && e.EventType != eventType {
 			continue
 		}
@@ -140,6 +161,74 @@ func (s *Store) List(filter ListFilter) []Event {
 	return out
 }
 
+// After returns the events appended after the one with id cursor that
+// match filter, oldest first; Limit keeps the newest ones. ok is false when
+// the cursor is no longer retained, in which case nothing is returned.
+func (s *Store) After(cursor string, filter ListFilter) ([]Event, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	cursor = strings.TrimSpace(cursor)
+	start := -1
+	for i := len(s.events) - 1; i >= 0; i-- {
+		if s.events[i].ID == cursor {
+			start = i + 1
+			break
+		}
+	}
+	if start < 0 {
+		return nil, false
+	}
+	match := trimFilter(filter)
+	out := make([]Event, 0)
+	for _, e := range s.events[start:] {
+		if matchesFilter(e, match) {
+			out = append(out, cloneEvent(e))
+		}
+	}
+	if filter.Limit > 0 && len(out) > filter.Limit {
+		out = out[len(out)-filter.Limit:]
+	}
+	return out, true
+}
+
+func trimFilter(f ListFilter) ListFilter {
+	f.EventType = strings.TrimSpace(f.EventType)
+	f.SessionID = strings.TrimSpace(f.SessionID)
+	f.RunID = strings.TrimSpace(f.RunID)
+	f.PlanID = strings.TrimSpace(f.PlanID)
+	f.TodoID = strings.TrimSpace(f.TodoID)
+	f.TeamID = strings.TrimSpace(f.TeamID)
+	f.SubagentID = strings.TrimSpace(f.SubagentID)
+	return f
+}
+
+// DeleteWhere removes every event for which match returns true and
+// returns how many were removed. A persistent store rewrites its file so
+// the events stay gone after a restart.
+func (s *Store) DeleteWhere(match func(Event) bool) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	kept := s.events[:0]
+	for _, e := range s.events {
+		if !match(e) {
+			kept = append(kept, e)
+		}
+	}
+	removed := len(s.events) - len(kept)
+	for i := len(kept); i < len(s.events); i++ {
+		s.events[i] = Event{}
+	}
+	s.events = kept
+	if removed > 0 && s.path != "" {
+		s.stale += removed
+		if err := s.compactLocked(); err != nil {
+			logging.Component("ccevent").Warn("event store compaction failed", "path", s.path, "error", err)
+		}
+	}
+	return removed
+}
+
 func (s *Store) nextIDLocked() string {
 	n := atomic.AddUint64(&s.counter, 1)
 	return fmt.Sprintf("evt_%d_%x", time.Now().Unix(), n)
@@ -32,13 +32,14 @@ func (r *SubscriberRegistry) Subscribe(filter ListFilter) (<-chan Event, func())
 	r.mu.Unlock()
 
 	cancel := func() {
-		r.mu.Lock()
-		delete(r.subs, sub)
-		r.mu.Unlock()
+		r.remove(sub)
 		// Drain remaining events
 		for {
 			select {
-			case <-ch:
+			case _, ok := <-ch:
+				if !ok {
+					return
+				}
 			default:
 				return
 			}
@@ -47,20 +48,39 @@ func (r *SubscriberRegistry) Subscribe(filter ListFilter) (<-chan Event, func())
 	return ch, cancel
 }
 
-// Notify sends an event to all matching subscribers.
+// Notify sends an event to all matching subscribers. A subscriber whose
+// buffer is full is dropped and its channel closed rather than silently
+// missing the event, so one stuck consumer cannot fall behind unnoticed;
+// SSE clients reconnect and resume from their last event id.
 func (r *SubscriberRegistry) Notify(e Event) {
+	var slow []*Subscriber
 	r.mu.RLock()
-	defer r.mu.RUnlock()
-
 	for sub := range r.subs {
 		if matchesFilter(e, sub.filter) {
 			select {
 			case sub.ch <- e:
 			default:
-				// Drop if subscriber is slow — avoid blocking
+				slow = append(slow, sub)
 			}
 		}
 	}
+	r.mu.RUnlock()
+
+	for _, sub := range slow {
+		r.remove(sub)
+	}
+}
+
+// remove unsubscribes sub and closes its channel; it is safe to call more
+// than once.
+func (r *SubscriberRegistry) remove(sub *Subscriber) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.subs[sub]; !ok {
+		return
+	}
+	delete(r.subs, sub)
+	close(sub.ch)
 }
 
 // matchesFilter checks if an event matches a subscriber's filter.
@@ -0,0 +1,162 @@
+package ccrun
+
+import (
+	"strings"
+	"time"
+	"unicode"
+)
+
+// SearchQuery selects runs for incident investigation. Empty fields do not
+// filter. Text is split into tokens that must all appear in the run's
+// record_text; the last token also matches as a prefix so partially typed
+// words still find runs.
+type SearchQuery struct {
+	Text          string
+	Status        string
+	Model         string // client, requested or upstream model
+	Adapter       string
+	Mode          string
+	SessionID     string
+	ErrorContains string
+	MinDurationMS int64
+	MaxDurationMS int64
+	Since         time.Time
+	Until         time.Time
+	Limit         int
+	Offset        int
+}
+
+// Search returns the page of matching runs, newest first, and the number
+// of matches.
+func (s *Store) Search(q SearchQuery) ([]Run, int) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var candidates map[string]struct{}
+	if tokens := textTokens(q.Text); len(tokens) > 0 {
+		candidates = s.matchTokensLocked(tokens)
+		if len(candidates) == 0 {
+			return []Run{}, 0
+		}
+	}
+	status := strings.ToLower(strings.TrimSpace(q.Status))
+	model := strings.TrimSpace(q.Model)
+	adapter := strings.TrimSpace(q.Adapter)
+	mode := strings.TrimSpace(q.Mode)
+	sessionID := strings.TrimSpace(q.SessionID)
+	errText := strings.ToLower(strings.TrimSpace(q.ErrorContains))
+
+	out := []Run{}
+	total := 0
+	for i := len(s.order) - 1; i >= 0; i-- {
+		id := s.order[i]
+		if candidates != nil {
+			if _, ok := candidates[id]; !ok {
+				continue
+			}
+		}
+		run, ok := s.runs[id]
+		if !ok {
+			continue
+		}
+		if status != "" && string(run.Status) != status {
+			continue
+		}
+		if model != "" && run.ClientModel != model && run.RequestedModel != model && run.UpstreamModel != model {
+			continue
+		}
+		if adapter != "" && !strings.EqualFold(run.Adapter, adapter) {
+			continue
+		}
+		if mode != "" && run.Mode != mode {
+			continue
+		}
+		if sessionID != "" && run.SessionID != sessionID {
+			continue
+		}
+		if errText != "" && !strings.Contains(strings.ToLower(run.Error), errText) {
+			continue
+		}
+		if q.MinDurationMS > 0 && (run.CompletedAt == nil || run.DurationMS < q.MinDurationMS) {
+			continue
+		}
+		if q.MaxDurationMS > 0 && (run.CompletedAt == nil || run.DurationMS > q.MaxDurationMS) {
+			continue
+		}
+		if !q.Since.IsZero() && run.CreatedAt.Before(q.Since) {
+			continue
+		}
+		if !q.Until.IsZero() && !run.CreatedAt.Before(q.Until) {
+			continue
+		}
+		total++
+		if total > q.Offset && (q.Limit <= 0 || len(out) < q.Limit) {
+			out = append(out, cloneRun(run))
+		}
+	}
+	return out, total
+}
+
+// matchTokensLocked intersects the index entries of tokens. The last token
+// matches every indexed token it prefixes.
+func (s *Store) matchTokensLocked(tokens []string) map[string]struct{} {
+	var out map[string]struct{}
+	for i, token := range tokens {
+		ids := s.byToken[token]
+		if i == len(tokens)-1 {
+			ids = map[string]struct{}{}
+			for indexed, set := range s.byToken {
+				if strings.HasPrefix(indexed, token) {
+					for id := range set {
+						ids[id] = struct{}{}
+					}
+				}
+			}
+		}
+		if out == nil {
+			out = make(map[string]struct{}, len(ids))
+			for id := range ids {
+				out[id] = struct{}{}
+			}
+		} else {
+			for id := range out {
+				if _, ok := ids[id]; !ok {
+					delete(out, id)
+				}
+			}
+		}
+		if len(out) == 0 {
+			return out
+		}
+	}
+	return out
+}
+
+func (s *Store) indexTextLocked(id, text string) {
+	for _, token := range textTokens(text) {
+		set := s.byToken[token]
+		if set == nil {
+			set = map[string]struct{}{}
+			s.byToken[token] = set
+		}
+		set[id] = struct{}{}
+	}
+}
+
+// textTokens lowercases text and splits it into distinct words of letters
+// and digits.
+func textTokens(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	seen := make(map[string]struct{}, len(fields))
+	out := make([]string, 0, len(fields))
+	for _, f := range fields {
+		if _, ok := seen[f]; ok {
+			continue
+		}
+		seen[f] = struct{}{}
+		out = append(out, f)
+	}
+	return out
+}
@@ -6,6 +6,7 @@ import (
 	"sync"
 	"sync/atomic"
 	"time"
+	"unicode/utf8"
 )
 
 type Status string
@@ -14,12 +15,16 @@ const (
 	StatusRunning   Status = "running"
 	StatusCompleted Status = "completed"
 	StatusFailed    Status = "failed"
+	StatusCanceled  Status = "canceled"
 )
 
 type Run struct {
 	ID             string         `json:"id"`
 	Type           string         `json:"type"`
 	SessionID      string         `json:"session_id,omitempty"`
+	ProjectID      string         `json:"project_id,omitempty"`
+	UserID         string         `json:"user_id,omitempty"`
+	CorrelationID  string         `json:"correlation_id,omitempty"`
 	Path           string         `json:"path"`
 	Mode           string         `json:"mode,omitempty"`
 	ClientModel    string         `json:"client_model,omitempty"`
@@ -31,14 +36,58 @@ type Run struct {
 	StatusCode     int            `json:"status_code"`
 	Error          string         `json:"error,omitempty"`
 	Metadata       map[string]any `json:"metadata,omitempty"`
-	CreatedAt      time.Time      `json:"created_at"`
-	UpdatedAt      time.Time      `json:"updated_at"`
-	CompletedAt    *time.Time     `json:"completed_at,omitempty"`
+	PromptText     string         `json:"prompt_text,omitempty"`
+	OutputText     string         `json:"output_text,omitempty"`
+	Scores         []Score        `json:"scores,omitempty"`
+	Feedback       []Feedback     `json:"feedback,omitempty"`
+	CostUSD        float64        `json:"cost_usd,omitempty"` // billed cost from the usage ledger
+	// TextSealed marks PromptText and OutputText as encrypted at rest. It
+	// is only ever set on persisted copies.
+	TextSealed bool `json:"text_sealed,omitempty"`
+	// Adapter is the upstream adapter that served the run, when known.
+	Adapter string `json:"adapter,omitempty"`
+	// RecordText is the one-line summary also written to the run log; it
+	// is what Search matches free text against.
+	RecordText string `json:"record_text,omitempty"`
+	DurationMS int64  `json:"duration_ms,omitempty"`
+	// Truncated marks a stream that ended abnormally; TruncationReason
+	// says how.
+	Truncated        bool       `json:"truncated,omitempty"`
+	TruncationReason string     `json:"truncation_reason,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+	CompletedAt      *time.Time `json:"completed_at,omitempty"`
 }
 
+// Score is one judge verdict on a run's output. A run keeps at most one
+// score per judge version so versions can be compared on the same runs.
+type Score struct {
+	JudgeVersion string             `json:"judge_version"`
+	Score        float64            `json:"score"`
+	Criteria     map[string]float64 `json:"criteria,omitempty"`
+	Analysis     string             `json:"analysis,omitempty"`
+	JobID        string             `json:"job_id,omitempty"`
+	ScoredAt     time.Time          `json:"scored_at"`
+}
+
+// Feedback is a caller's rating of a run's answer: +1 (good) or -1 (bad).
+// A run keeps one rating per user; anonymous ratings share one slot.
+type Feedback struct {
+	Rating    int       `json:"rating"`
+	Comment   string    `json:"comment,omitempty"`
+	UserID    string    `json:"user_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// maxStoredTextBytes caps the prompt and output kept on a run.
+const maxStoredTextBytes = 16 << 10
+
 type CreateInput struct {
 	ID             string         `json:"id,omitempty"`
 	SessionID      string         `json:"session_id,omitempty"`
+	ProjectID      string         `json:"project_id,omitempty"`
+	UserID         string         `json:"user_id,omitempty"`
+	CorrelationID  string         `json:"correlation_id,omitempty"`
 	Path           string         `json:"path"`
 	Mode           string         `json:"mode,omitempty"`
 	ClientModel    string         `json:"client_model,omitempty"`
@@ -52,13 +101,20 @@ type CreateInput struct {
 type CompleteInput struct {
 	StatusCode int    `json:"status_code"`
 	Error      string `json:"error,omitempty"`
+	Prompt     string `json:"prompt,omitempty"`
+	Output     string `json:"output,omitempty"`
+	RecordText string `json:"record_text,omitempty"`
 }
 
 type ListFilter struct {
 	Limit     int
 	SessionID string
+	ProjectID string
+	UserID    string
 	Status    string
 	Path      string
+	// CorrelationID is answered from an index rather than a scan.
+	CorrelationID string
 }
 
 type StoreState struct {
@@ -73,12 +129,18 @@ type Store struct {
 	order    []string
 	counter  uint64
 	onChange func()
+	// byCorrelation maps a correlation ID to its run IDs, oldest first.
+	byCorrelation map[string][]string
+	// byToken is the full-text index over record_text: token -> run IDs.
+	byToken map[string]map[string]struct{}
 }
 
 func NewStore() *Store {
 	return &Store{
-		runs:  map[string]Run{},
-		order: []string{},
+		runs:          map[string]Run{},
+		order:         []string{},
+		byCorrelation: map[string][]string{},
+		byToken:       map[string]map[string]struct{}{},
 	}
 }
 
@@ -109,6 +171,9 @@ func (s *Store) createLocked(in CreateInput) (Run, error) {
 		ID:             id,
 		Type:           "run",
 		SessionID:      strings.TrimSpace(in.SessionID),
+		ProjectID:      strings.TrimSpace(in.ProjectID),
+		UserID:         strings.TrimSpace(in.UserID),
+		CorrelationID:  strings.TrimSpace(in.CorrelationID),
 		Path:           path,
 		Mode:           strings.TrimSpace(in.Mode),
 		ClientModel:    strings.TrimSpace(in.ClientModel),
@@ -123,6 +188,9 @@ func (s *Store) createLocked(in CreateInput) (Run, error) {
 	}
 	s.runs[id] = run
 	s.order = append(s.order, id)
+	if run.CorrelationID != "" {
+		s.byCorrelation[run.CorrelationID] = append(s.byCorrelation[run.CorrelationID], id)
+	}
 	return cloneRun(run), nil
 }
 
@@ -154,6 +222,11 @@ func (s *Store) completeLocked(id string, in CompleteInput) (Run, error) {
 	run.Error = strings.TrimSpace(in.Error)
 	run.UpdatedAt = now
 	run.CompletedAt = &now
+	run.PromptText = truncateText(in.Prompt, maxStoredTextBytes)
+	run.OutputText = truncateText(in.Output, maxStoredTextBytes)
+	run.RecordText = strings.TrimSpace(in.RecordText)
+	run.DurationMS = now.Sub(run.CreatedAt).Milliseconds()
+	s.indexTextLocked(id, run.RecordText)
 	if in.StatusCode >= 400 {
 		run.Status = StatusFailed
 	} else {
@@ -163,6 +236,175 @@ func (s *Store) completeLocked(id string, in CompleteInput) (Run, error) {
 	return cloneRun(run), nil
 }
 
+// RecordFeedback stores fb on run id, replacing any earlier rating from the
+// same user.
+func (s *Store) RecordFeedback(id string, fb Feedback) (Run, error) {
+	id = strings.TrimSpace(id)
+	if fb.Rating != 1 && fb.Rating != -1 {
+		return Run{}, fmt.Errorf("rating must be 1 or -1")
+	}
+	fb.UserID = strings.TrimSpace(fb.UserID)
+	fb.Comment = truncateText(strings.TrimSpace(fb.Comment), 2000)
+	if fb.CreatedAt.IsZero() {
+		fb.CreatedAt = time.Now().UTC()
+	}
+	s.mu.Lock()
+	run, ok := s.runs[id]
+	if !ok {
+		s.mu.Unlock()
+		return Run{}, fmt.Errorf("run %q not found", id)
+	}
+	feedback := make([]Feedback, 0, len(run.Feedback)+1)
+	for _, existing := range run.Feedback {
+		if existing.UserID != fb.UserID {
+			feedback = append(feedback, existing)
+		}
+	}
+	run.Feedback = append(feedback, fb)
+	s.runs[id] = run
+	out := cloneRun(run)
+	s.mu.Unlock()
+	s.notifyChanged()
+	return out, nil
+}
+
+// RecordScore stores score on run id, replacing any earlier score from the
+// same judge version.
+func (s *Store) RecordScore(id string, score Score) (Run, error) {
+	id = strings.TrimSpace(id)
+	score.JudgeVersion = strings.TrimSpace(score.JudgeVersion)
+	if score.JudgeVersion == "" {
+		return Run{}, fmt.Errorf("judge version is required")
+	}
+	if score.ScoredAt.IsZero() {
+		score.ScoredAt = time.Now().UTC()
+	}
+	s.mu.Lock()
+	run, ok := s.runs[id]
+	if !ok {
+		s.mu.Unlock()
+		return Run{}, fmt.Errorf("run %q not found", id)
+	}
+	scores := make([]Score, 0, len(run.Scores)+1)
+	for _, existing := range run.Scores {
+		if existing.JudgeVersion != score.JudgeVersion {
+			scores = append(scores, existing)
+		}
+	}
+	run.Scores = append(scores, cloneScore(score))
+	s.runs[id] = run
+	out := cloneRun(run)
+	s.mu.Unlock()
+	s.notifyChanged()
+	return out, nil
+}
+
+// MarkTruncated flags run id as a truncated stream.
+func (s *Store) MarkTruncated(id, reason string) (Run, error) {
+	id = strings.TrimSpace(id)
+	s.mu.Lock()
+	run, ok := s.runs[id]
+	if !ok {
+		s.mu.Unlock()
+		return Run{}, fmt.Errorf("run %q not found", id)
+	}
+	run.Truncated = true
+	run.TruncationReason = strings.TrimSpace(reason)
+	run.UpdatedAt = time.Now().UTC()
+	s.runs[id] = run
+	out := cloneRun(run)
+	s.mu.Unlock()
+	s.notifyChanged()
+	return out, nil
+}
+
+// RecordAdapter stores the upstream adapter that served run id.
+func (s *Store) RecordAdapter(id, adapter string) (Run, error) {
+	id = strings.TrimSpace(id)
+	s.mu.Lock()
+	run, ok := s.runs[id]
+	if !ok {
+		s.mu.Unlock()
+		return Run{}, fmt.Errorf("run %q not found", id)
+	}
+	run.Adapter = strings.TrimSpace(adapter)
+	run.UpdatedAt = time.Now().UTC()
+	s.runs[id] = run
+	out := cloneRun(run)
+	s.mu.Unlock()
+	s.notifyChanged()
+	return out, nil
+}
+
+// RecordCost stores the billed cost of run id.
+func (s *Store) RecordCost(id string, costUSD float64) (Run, error) {
+	id = strings.TrimSpace(id)
+	s.mu.Lock()
+	run, ok := s.runs[id]
+	if !ok {
+		s.mu.Unlock()
+		return Run{}, fmt.Errorf("run %q not found", id)
+	}
+	run.CostUSD = costUSD
+	run.UpdatedAt = time.Now().UTC()
+	s.runs[id] = run
+	out := cloneRun(run)
+	s.mu.Unlock()
+	s.notifyChanged()
+	return out, nil
+}
+
+// Annotate merges metadata into run id, replacing keys that already exist.
+func (s *Store) Annotate(id string, metadata map[string]any) (Run, error) {
+	id = strings.TrimSpace(id)
+	s.mu.Lock()
+	run, ok := s.runs[id]
+	if !ok {
+		s.mu.Unlock()
+		return Run{}, fmt.Errorf("run %q not found", id)
+	}
+	merged := copyMetadata(run.Metadata)
+	if merged == nil {
+		merged = map[string]any{}
+	}
+	for k, v := range metadata {
+		merged[k] = v
+	}
+	run.Metadata = merged
+	run.UpdatedAt = time.Now().UTC()
+	s.runs[id] = run
+	out := cloneRun(run)
+	s.mu.Unlock()
+	s.notifyChanged()
+	return out, nil
+}
+
+// Cancel closes a running run as canceled. A run that already finished is
+// returned unchanged.
+func (s *Store) Cancel(id, reason string) (Run, error) {
+	id = strings.TrimSpace(id)
+	s.mu.Lock()
+	run, ok := s.runs[id]
+	if !ok {
+		s.mu.Unlock()
+		return Run{}, fmt.Errorf("run %q not found", id)
+	}
+	if run.Status != StatusRunning {
+		s.mu.Unlock()
+		return cloneRun(run), nil
+	}
+	now := time.Now().UTC()
+	run.Status = StatusCanceled
+	run.Error = strings.TrimSpace(reason)
+	run.UpdatedAt = now
+	run.CompletedAt = &now
+	s.runs[id] = run
+	out := cloneRun(run)
+	s.mu.Unlock()
+	s.notifyChanged()
+	return out, nil
+}
+
 func (s *Store) Get(id string) (Run, bool) {
 	id = strings.TrimSpace(id)
 	if id == "" {
@@ -181,17 +423,23 @@ func (s *Store) List(filter ListFilter) []Run {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
+	order := s.order
+	if correlationID := strings.TrimSpace(filter.CorrelationID); correlationID != "" {
+		order = s.byCorrelation[correlationID]
+	}
 	limit := filter.Limit
-	if limit <= 0 || limit > len(s.order) {
-		limit = len(s.order)
+	if limit <= 0 || limit > len(order) {
+		limit = len(order)
 	}
 	sessionID := strings.TrimSpace(filter.SessionID)
+	projectID := strings.TrimSpace(filter.ProjectID)
+	userID := strings.TrimSpace(filter.UserID)
 	status := strings.TrimSpace(strings.ToLower(filter.Status))
 	path := strings.TrimSpace(filter.Path)
 
 	out := make([]Run, 0, limit)
-	for i := len(s.order) - 1; i >= 0 && len(out) < limit; i-- {
-		id := s.order[i]
+	for i := len(order) - 1; i >= 0 && len(out) < limit; i-- {
+		id := order[i]
 		run, ok := s.runs[id]
 		if !ok {
 			continue
@@ -199,6 +447,12 @@ func (s *Store) List(filter ListFilter) []Run {
 		if sessionID != "" && run.SessionID != sessionID {
 			continue
 		}
+		if projectID != "" && run.ProjectID != projectID {
+			continue
+		}
+		if userID != "" && run.UserID != userID {
+			continue
+		}
 		if status != "" && string(run.Status) != status {
 			continue
 		}
@@ -210,6 +464,32 @@ func (s *Store) List(filter ListFilter) []Run {
 	return out
 }
 
+// DeleteWhere removes every run for which match returns true and returns
+// the removed runs.
+func (s *Store) DeleteWhere(match func(Run) bool) []Run {
+	s.mu.Lock()
+	var removed []Run
+	order := s.order[:0]
+	for _, id := range s.order {
+		run, ok := s.runs[id]
+		if ok && match(run) {
+			removed = append(removed, cloneRun(run))
+			delete(s.runs, id)
+			continue
+		}
+		order = append(order, id)
+	}
+	s.order = order
+	if len(removed) > 0 {
+		s.reindexLocked()
+	}
+	s.mu.Unlock()
+	if len(removed) > 0 {
+		s.notifyChanged()
+	}
+	return removed
+}
+
 func (s *Store) Snapshot() StoreState {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -251,9 +531,25 @@ func (s *Store) Restore(state StoreState) error {
 	s.runs = next
 	s.order = order
 	s.counter = state.Counter
+	s.reindexLocked()
 	return nil
 }
 
+func (s *Store) reindexLocked() {
+	s.byCorrelation = map[string][]string{}
+	s.byToken = map[string]map[string]struct{}{}
+	for _, id := range s.order {
+		run, ok := s.runs[id]
+		if !ok {
+			continue
+		}
+		if run.CorrelationID != "" {
+			s.byCorrelation[run.CorrelationID] = append(s.byCorrelation[run.CorrelationID], id)
+		}
+		s.indexTextLocked(id, run.RecordText)
+	}
+}
+
 func (s *Store) SetOnChange(fn func()) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -277,6 +573,13 @@ func (s *Store) nextIDLocked() string {
 func cloneRun(in Run) Run {
 	out := in
 	out.Metadata = copyMetadata(in.Metadata)
+	out.Feedback = append([]Feedback(nil), in.Feedback...)
+	if in.Scores != nil {
+		out.Scores = make([]Score, len(in.Scores))
+		for i, sc := range in.Scores {
+			out.Scores[i] = cloneScore(sc)
+		}
+	}
 	if in.CompletedAt != nil {
 		t := *in.CompletedAt
 		out.CompletedAt = &t
@@ -284,6 +587,28 @@ func cloneRun(in Run) Run {
 	return out
 }
 
+func cloneScore(in Score) Score {
+	out := in
+	if in.Criteria != nil {
+		out.Criteria = make(map[string]float64, len(in.Criteria))
+		for k, v := range in.Criteria {
+			out.Criteria[k] = v
+		}
+	}
+	return out
+}
+
+func truncateText(text string, max int) string {
+	if len(text) <= max {
+		return text
+	}
+	cut := max
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	return text[:cut]
+}
+
 func copyMetadata(in map[string]any) map[string]any {
 	if len(in) == 0 {
 		return map[string]any{}
@@ -2,12 +2,14 @@ package channel
 
 import (
 	"errors"
+	"sort"
 	"sync"
 	"time"
 )
 
 var (
 	ErrChannelNotFound = errors.New("channel not found")
+	ErrChannelExists   = errors.New("channel id is already in use")
 )
 
 // Ability represents a channel's ability to handle a specific model
@@ -25,6 +27,7 @@ type AbilityStore struct {
 	channels  map[int64]*Channel
 	abilities map[string]*Ability // key: group:model
 	byChannel map[int64][]string  // channelID -> []key
+	deleted   map[int64]*Channel  // soft-deleted channels, restorable
 	nextID    int64
 }
 
@@ -33,6 +36,7 @@ func NewAbilityStore() *AbilityStore {
 		channels:  make(map[int64]*Channel),
 		abilities: make(map[string]*Ability),
 		byChannel: make(map[int64][]string),
+		deleted:   make(map[int64]*Channel),
 		nextID:    1,
 	}
 }
@@ -48,6 +52,8 @@ func (s *AbilityStore) AddChannel(c *Channel) error {
 	if c.ID == 0 {
 		c.ID = s.nextID
 		s.nextID++
+	} else if c.ID >= s.nextID {
+		s.nextID = c.ID + 1
 	}
 	now := time.Now()
 	stored := cloneChannel(c)
@@ -93,6 +99,11 @@ func (s *AbilityStore) DeleteChannel(id int64) error {
 	if !ok {
 		return ErrChannelNotFound
 	}
+	s.removeLocked(id)
+	return nil
+}
+
+func (s *AbilityStore) removeLocked(id int64) {
 	delete(s.channels, id)
 
 	// Remove related abilities
@@ -102,6 +113,66 @@ func (s *AbilityStore) DeleteChannel(id int64) error {
 		}
 	}
 	delete(s.byChannel, id)
+}
+
+// SoftDeleteChannel moves a channel to the trash. It stops serving
+// immediately but keeps its ID and config until restored or purged.
+func (s *AbilityStore) SoftDeleteChannel(id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.channels[id]
+	if !ok {
+		return ErrChannelNotFound
+	}
+	s.removeLocked(id)
+	now := time.Now()
+	c.DeletedAt = &now
+	s.deleted[id] = c
+	return nil
+}
+
+// RestoreChannel brings a soft-deleted channel back under its old ID.
+func (s *AbilityStore) RestoreChannel(id int64) (*Channel, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.deleted[id]
+	if !ok {
+		return nil, ErrChannelNotFound
+	}
+	if _, taken := s.channels[id]; taken {
+		return nil, ErrChannelExists
+	}
+	delete(s.deleted, id)
+	c.DeletedAt = nil
+	c.UpdatedAt = time.Now()
+	s.channels[id] = c
+	s.rebuildAbilitiesLocked(c)
+	return cloneChannel(c), nil
+}
+
+// DeletedChannels returns the channels in the trash.
+func (s *AbilityStore) DeletedChannels() []*Channel {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]*Channel, 0, len(s.deleted))
+	for _, c := range s.deleted {
+		result = append(result, cloneChannel(c))
+	}
+	return result
+}
+
+// PurgeChannel drops a soft-deleted channel for good.
+func (s *AbilityStore) PurgeChannel(id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.deleted[id]; !ok {
+		return ErrChannelNotFound
+	}
+	delete(s.deleted, id)
 	return nil
 }
 
@@ -161,6 +232,41 @@ func (s *AbilityStore) GetChannelByGroupAndModel(group, model string) (*Channel,
 	return nil, false
 }
 
+// ChannelsByGroupAndModel returns every enabled channel of the group that
+// serves the model, limited to the highest priority present and ordered by
+// id. Unlike GetChannelByGroupAndModel it keeps channels that share a model
+// so callers can balance between them.
+func (s *AbilityStore) ChannelsByGroupAndModel(group, model string) []*Channel {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var out []*Channel
+	for _, c := range s.channels {
+		if !c.IsEnabled() || !containsString(splitAndTrim(c.Group, ","), group) {
+			continue
+		}
+		serves := false
+		for _, pattern := range splitAndTrim(c.Models, ",") {
+			if matchModel(pattern, model) {
+				serves = true
+				break
+			}
+		}
+		if !serves {
+			continue
+		}
+		if len(out) > 0 && c.Priority < out[0].Priority {
+			continue
+		}
+		if len(out) > 0 && c.Priority > out[0].Priority {
+			out = out[:0]
+		}
+		out = append(out, cloneChannel(c))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
 // GetEnabledModels returns all enabled models for a group
 func (s *AbilityStore) GetEnabledModels(group string) []string {
 	s.mu.RLock()
@@ -242,9 +348,22 @@ func cloneChannel(in *Channel) *Channel {
 		v := *in.ModelMapping
 		out.ModelMapping = &v
 	}
+	if in.DeletedAt != nil {
+		v := *in.DeletedAt
+		out.DeletedAt = &v
+	}
 	return &out
 }
 
+func containsString(list []string, v string) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
+
 func splitKey(s string) []string {
 	for i := 0; i < len(s); i++ {
 		if s[i] == ':' {
@@ -37,10 +37,16 @@ type Channel struct {
 
 	UsedQuota   int64     `json:"used_quota"` // Total used quota
 
+	// Prices in USD per 1k tokens; when either is set, requests served by
+	// this channel are billed at these rates instead of the model table.
+	PromptPricePer1K     float64 `json:"prompt_price_per_1k,omitempty"`
+	CompletionPricePer1K float64 `json:"completion_price_per_1k,omitempty"`
+
 	Config      string    `json:"config,omitempty"` // Additional config as JSON
 
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"` // Set while the channel is in the trash
 }
 
 // IsEnabled checks if channel is available
@@ -70,6 +76,11 @@ func (c *Channel) GetWeight() uint {
 	return c.Weight
 }
 
+// HasPricing reports whether the channel overrides model prices.
+func (c *Channel) HasPricing() bool {
+	return c.PromptPricePer1K > 0 || c.CompletionPricePer1K > 0
+}
+
 func splitAndTrim(s, sep string) []string {
 	var result []string
 	for _, part := range strings.Split(s, sep) {
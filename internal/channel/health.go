@@ -0,0 +1,332 @@
+package channel
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HealthConfig tunes how observed outcomes move a channel's effective
+// weight. Success rate and latency are smoothed with an EWMA; the score
+// (0..1) maps linearly onto [MinFactor, MaxFactor] of the configured weight.
+type HealthConfig struct {
+	Enabled         bool    `json:"enabled"`
+	Alpha           float64 `json:"alpha,omitempty"`
+	TargetLatencyMS float64 `json:"target_latency_ms,omitempty"`
+	MinFactor       float64 `json:"min_factor,omitempty"`
+	MaxFactor       float64 `json:"max_factor,omitempty"`
+	MinSamples      int     `json:"min_samples,omitempty"`
+	HistorySize     int     `json:"history_size,omitempty"`
+	HistoryInterval string  `json:"history_interval,omitempty"`
+}
+
+// DefaultHealthConfig adjusts weights between 10% and 100% of the
+// configured value once five outcomes have been seen.
+func DefaultHealthConfig() HealthConfig {
+	return HealthConfig{
+		Enabled:         true,
+		Alpha:           0.2,
+		TargetLatencyMS: 3000,
+		MinFactor:       0.1,
+		MaxFactor:       1,
+		MinSamples:      5,
+		HistorySize:     120,
+		HistoryInterval: "1m",
+	}
+}
+
+func (c HealthConfig) normalize() (HealthConfig, time.Duration, error) {
+	def := DefaultHealthConfig()
+	if c.Alpha == 0 {
+		c.Alpha = def.Alpha
+	}
+	if c.TargetLatencyMS == 0 {
+		c.TargetLatencyMS = def.TargetLatencyMS
+	}
+	if c.MaxFactor == 0 {
+		c.MaxFactor = def.MaxFactor
+	}
+	if c.MinSamples == 0 {
+		c.MinSamples = def.MinSamples
+	}
+	if c.HistorySize == 0 {
+		c.HistorySize = def.HistorySize
+	}
+	if strings.TrimSpace(c.HistoryInterval) == "" {
+		c.HistoryInterval = def.HistoryInterval
+	}
+	switch {
+	case c.Alpha <= 0 || c.Alpha > 1:
+		return c, 0, fmt.Errorf("channel health alpha must be in (0, 1]")
+	case c.TargetLatencyMS < 0:
+		return c, 0, fmt.Errorf("channel health target_latency_ms must be positive")
+	case c.MinFactor < 0 || c.MaxFactor < c.MinFactor:
+		return c, 0, fmt.Errorf("channel health factors must satisfy 0 <= min_factor <= max_factor")
+	case c.MinSamples < 0 || c.HistorySize < 0:
+		return c, 0, fmt.Errorf("channel health min_samples and history_size must not be negative")
+	}
+	interval, err := time.ParseDuration(c.HistoryInterval)
+	if err != nil || interval < 0 {
+		return c, 0, fmt.Errorf("channel health history_interval must be a duration")
+	}
+	return c, interval, nil
+}
+
+// HealthPoint is one entry of a channel's score history.
+type HealthPoint struct {
+	Time            time.Time `json:"time"`
+	Score           float64   `json:"score"`
+	SuccessRate     float64   `json:"success_rate"`
+	LatencyMS       float64   `json:"latency_ms"`
+	Factor          float64   `json:"factor"`
+	EffectiveWeight float64   `json:"effective_weight"`
+	Samples         int64     `json:"samples"`
+}
+
+// Health is the current state of one channel.
+type Health struct {
+	ChannelID       int64         `json:"channel_id"`
+	Samples         int64         `json:"samples"`
+	Failures        int64         `json:"failures"`
+	SuccessRate     float64       `json:"success_rate"`
+	LatencyMS       float64       `json:"latency_ms"`
+	Score           float64       `json:"score"`
+	Factor          float64       `json:"factor"`
+	BaseWeight      uint          `json:"base_weight"`
+	EffectiveWeight float64       `json:"effective_weight"`
+	LastSource      string        `json:"last_source,omitempty"`
+	LastError       string        `json:"last_error,omitempty"`
+	UpdatedAt       time.Time     `json:"updated_at,omitempty"`
+	History         []HealthPoint `json:"history"`
+}
+
+type channelHealth struct {
+	samples     int64
+	failures    int64
+	successRate float64
+	latencyMS   float64
+	lastSource  string
+	lastError   string
+	updatedAt   time.Time
+	history     []HealthPoint
+}
+
+// HealthTracker turns run and probe outcomes into per-channel scores and
+// effective weights. It is safe for concurrent use; a nil tracker leaves
+// weights untouched.
+type HealthTracker struct {
+	mu       sync.Mutex
+	cfg      HealthConfig
+	interval time.Duration
+	channels map[int64]*channelHealth
+}
+
+func NewHealthTracker(cfg HealthConfig) (*HealthTracker, error) {
+	h := &HealthTracker{channels: map[int64]*channelHealth{}}
+	if err := h.Update(cfg); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// NewHealthTrackerFromEnv starts from DefaultHealthConfig, replaced by
+// CHANNEL_HEALTH_JSON when set.
+func NewHealthTrackerFromEnv() (*HealthTracker, error) {
+	cfg := DefaultHealthConfig()
+	if raw := strings.TrimSpace(os.Getenv("CHANNEL_HEALTH_JSON")); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &cfg); err != nil {
+			return nil, fmt.Errorf("invalid CHANNEL_HEALTH_JSON: %w", err)
+		}
+	}
+	return NewHealthTracker(cfg)
+}
+
+// Config returns the current configuration.
+func (h *HealthTracker) Config() HealthConfig {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.cfg
+}
+
+// Update validates and installs cfg; collected samples are kept.
+func (h *HealthTracker) Update(cfg HealthConfig) error {
+	cfg, interval, err := cfg.normalize()
+	if err != nil {
+		return err
+	}
+	h.mu.Lock()
+	h.cfg = cfg
+	h.interval = interval
+	h.mu.Unlock()
+	return nil
+}
+
+// Record feeds one outcome for a channel. source names where it came from
+// ("run", "probe"); errText is kept for display on failures.
+func (h *HealthTracker) Record(c *Channel, success bool, latency time.Duration, source, errText string) {
+	if h == nil || c == nil || c.ID == 0 {
+		return
+	}
+	now := time.Now()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	st, ok := h.channels[c.ID]
+	if !ok {
+		st = &channelHealth{successRate: 1}
+		h.channels[c.ID] = st
+	}
+	outcome := 0.0
+	if success {
+		outcome = 1
+	} else {
+		st.failures++
+		st.lastError = errText
+	}
+	ms := float64(latency.Milliseconds())
+	if st.samples == 0 {
+		st.successRate = outcome
+		st.latencyMS = ms
+	} else {
+		st.successRate += h.cfg.Alpha * (outcome - st.successRate)
+		st.latencyMS += h.cfg.Alpha * (ms - st.latencyMS)
+	}
+	st.samples++
+	st.lastSource = source
+	st.updatedAt = now
+
+	score, factor := h.scoreLocked(st)
+	point := HealthPoint{
+		Time:            now,
+		Score:           score,
+		SuccessRate:     round3(st.successRate),
+		LatencyMS:       math.Round(st.latencyMS),
+		Factor:          factor,
+		EffectiveWeight: round3(float64(baseWeight(c)) * factor),
+		Samples:         st.samples,
+	}
+	// Points closer together than the history interval replace the last one
+	// so a busy channel keeps a readable timeline.
+	if n := len(st.history); n > 0 && now.Sub(st.history[n-1].Time) < h.interval {
+		st.history[n-1] = point
+	} else {
+		st.history = append(st.history, point)
+	}
+	if h.cfg.HistorySize > 0 && len(st.history) > h.cfg.HistorySize {
+		st.history = append([]HealthPoint(nil), st.history[len(st.history)-h.cfg.HistorySize:]...)
+	}
+}
+
+// scoreLocked returns the 0..1 score and the weight factor it maps to.
+// Channels with fewer than MinSamples outcomes keep the full weight.
+func (h *HealthTracker) scoreLocked(st *channelHealth) (float64, float64) {
+	latencyScore := 1.0
+	if h.cfg.TargetLatencyMS > 0 && st.latencyMS > h.cfg.TargetLatencyMS {
+		latencyScore = h.cfg.TargetLatencyMS / st.latencyMS
+	}
+	score := round3(st.successRate * latencyScore)
+	if !h.cfg.Enabled || st.samples < int64(h.cfg.MinSamples) {
+		return score, 1
+	}
+	return score, round3(h.cfg.MinFactor + (h.cfg.MaxFactor-h.cfg.MinFactor)*score)
+}
+
+// EffectiveWeight is the channel's configured weight (1 when unset) scaled
+// by its health factor.
+func (h *HealthTracker) EffectiveWeight(c *Channel) float64 {
+	if c == nil || !c.IsEnabled() {
+		return 0
+	}
+	base := float64(baseWeight(c))
+	if h == nil {
+		return base
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	st, ok := h.channels[c.ID]
+	if !ok {
+		return base
+	}
+	_, factor := h.scoreLocked(st)
+	return round3(base * factor)
+}
+
+// Pick chooses one channel at random in proportion to effective weight. It
+// returns nil for an empty list; when every weight is zero the first
+// channel wins.
+func (h *HealthTracker) Pick(channels []*Channel) *Channel {
+	if len(channels) == 0 {
+		return nil
+	}
+	weights := make([]float64, len(channels))
+	total := 0.0
+	for i, c := range channels {
+		weights[i] = h.EffectiveWeight(c)
+		total += weights[i]
+	}
+	if total <= 0 {
+		return channels[0]
+	}
+	pick := rand.Float64() * total
+	for i, w := range weights {
+		if pick < w {
+			return channels[i]
+		}
+		pick -= w
+	}
+	return channels[len(channels)-1]
+}
+
+// Snapshot reports the health of c, including its score history.
+func (h *HealthTracker) Snapshot(c *Channel) Health {
+	out := Health{BaseWeight: baseWeight(c), Factor: 1, SuccessRate: 1, Score: 1, History: []HealthPoint{}}
+	if c == nil {
+		return out
+	}
+	out.ChannelID = c.ID
+	out.EffectiveWeight = h.EffectiveWeight(c)
+	if h == nil {
+		return out
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	st, ok := h.channels[c.ID]
+	if !ok {
+		return out
+	}
+	out.Score, out.Factor = h.scoreLocked(st)
+	out.Samples = st.samples
+	out.Failures = st.failures
+	out.SuccessRate = round3(st.successRate)
+	out.LatencyMS = math.Round(st.latencyMS)
+	out.LastSource = st.lastSource
+	out.LastError = st.lastError
+	out.UpdatedAt = st.updatedAt
+	out.History = append(out.History, st.history...)
+	return out
+}
+
+// Forget drops the samples of a deleted channel.
+func (h *HealthTracker) Forget(id int64) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	delete(h.channels, id)
+	h.mu.Unlock()
+}
+
+func baseWeight(c *Channel) uint {
+	if c == nil || c.Weight == 0 {
+		return 1
+	}
+	return c.Weight
+}
+
+func round3(v float64) float64 {
+	return math.Round(v*1000) / 1000
+}
@@ -0,0 +1,177 @@
+package configfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"ccgateway/internal/channel"
+	"ccgateway/internal/toolcatalog"
+	"ccgateway/internal/upstream"
+)
+
+// File is the on-disk configuration. Every section is optional; a section
+// that is absent leaves the running value alone.
+type File struct {
+	Adapters     []upstream.AdapterSpec `json:"adapters,omitempty"`
+	DefaultRoute []string               `json:"default_route,omitempty"`
+	ModelRoutes  map[string][]string    `json:"model_routes,omitempty"`
+	Settings     json.RawMessage        `json:"settings,omitempty"`
+	Tools        []toolcatalog.ToolSpec `json:"tools,omitempty"`
+	Channels     []Channel              `json:"channels,omitempty"`
+}
+
+// Channel is a channel entry. Channels are matched by ID so reloading the
+// same file updates them in place; the secret comes from Key or the
+// variable named by KeyEnv.
+type Channel struct {
+	channel.Channel
+	Key    string `json:"key,omitempty"`
+	KeyEnv string `json:"key_env,omitempty"`
+}
+
+// Resolve returns the channel with its key filled in.
+func (c Channel) Resolve() channel.Channel {
+	out := c.Channel
+	out.Key = c.Key
+	if c.KeyEnv != "" {
+		out.Key = strings.TrimSpace(os.Getenv(c.KeyEnv))
+	}
+	return out
+}
+
+// Sections reported in reload results.
+const (
+	SectionUpstream = "upstream"
+	SectionSettings = "settings"
+	SectionTools    = "tools"
+	SectionChannels = "channels"
+)
+
+var ErrUnsupportedFormat = errors.New("config file must end in .json, .yaml or .yml")
+
+// Load reads and decodes path. The format follows the extension. Unknown
+// fields are rejected so typos do not pass silently.
+func Load(path string) (File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return File{}, err
+	}
+	return Decode(data, filepath.Ext(path))
+}
+
+// Decode parses a config document; ext is ".json", ".yaml" or ".yml".
+func Decode(data []byte, ext string) (File, error) {
+	switch strings.ToLower(ext) {
+	case ".json":
+	case ".yaml", ".yml":
+		tree, err := parseYAML(data)
+		if err != nil {
+			return File{}, fmt.Errorf("invalid YAML: %w", err)
+		}
+		if data, err = json.Marshal(tree); err != nil {
+			return File{}, err
+		}
+	default:
+		return File{}, ErrUnsupportedFormat
+	}
+	var f File
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&f); err != nil {
+		return File{}, fmt.Errorf("invalid config file: %w", err)
+	}
+	if len(f.Settings) > 0 && !bytes.HasPrefix(bytes.TrimSpace(f.Settings), []byte("{")) {
+		if string(bytes.TrimSpace(f.Settings)) == "null" {
+			f.Settings = nil
+		} else {
+			return File{}, errors.New("invalid config file: settings must be an object")
+		}
+	}
+	for i, c := range f.Channels {
+		if c.ID <= 0 {
+			return File{}, fmt.Errorf("invalid config file: channel %d needs a positive id", i)
+		}
+	}
+	return f, nil
+}
+
+// WithEnv applies the environment on top of f: each env variable that is
+// set replaces its section, except RUNTIME_SETTINGS_JSON, which is merged
+// key by key over the file's settings. It returns the variables that took
+// effect.
+func (f File) WithEnv() (File, []string, error) {
+	var used []string
+	if os.Getenv("UPSTREAM_ADAPTERS_JSON") != "" {
+		specs, err := upstream.ParseAdapterSpecsFromEnv()
+		if err != nil {
+			return File{}, nil, err
+		}
+		f.Adapters = specs
+		used = append(used, "UPSTREAM_ADAPTERS_JSON")
+	}
+	if strings.TrimSpace(os.Getenv("UPSTREAM_DEFAULT_ROUTE")) != "" {
+		f.DefaultRoute = upstream.ParseListEnv("UPSTREAM_DEFAULT_ROUTE", nil)
+		used = append(used, "UPSTREAM_DEFAULT_ROUTE")
+	}
+	if strings.TrimSpace(os.Getenv("UPSTREAM_MODEL_ROUTES_JSON")) != "" {
+		routes, err := upstream.ParseRoutesFromEnv()
+		if err != nil {
+			return File{}, nil, err
+		}
+		f.ModelRoutes = routes
+		used = append(used, "UPSTREAM_MODEL_ROUTES_JSON")
+	}
+	if raw := strings.TrimSpace(os.Getenv("RUNTIME_SETTINGS_JSON")); raw != "" {
+		merged, err := mergeJSONObjects(f.Settings, []byte(raw))
+		if err != nil {
+			return File{}, nil, fmt.Errorf("invalid RUNTIME_SETTINGS_JSON: %w", err)
+		}
+		f.Settings = merged
+		used = append(used, "RUNTIME_SETTINGS_JSON")
+	}
+	if raw := strings.TrimSpace(os.Getenv("TOOL_CATALOG_JSON")); raw != "" {
+		var tools []toolcatalog.ToolSpec
+		if err := json.Unmarshal([]byte(raw), &tools); err != nil {
+			return File{}, nil, fmt.Errorf("invalid TOOL_CATALOG_JSON: %w", err)
+		}
+		f.Tools = tools
+		used = append(used, "TOOL_CATALOG_JSON")
+	}
+	return f, used, nil
+}
+
+// mergeJSONObjects overlays top onto base, recursing into nested objects.
+func mergeJSONObjects(base, top []byte) (json.RawMessage, error) {
+	var b, t map[string]any
+	if len(bytes.TrimSpace(base)) > 0 {
+		if err := json.Unmarshal(base, &b); err != nil {
+			return nil, err
+		}
+	}
+	if err := json.Unmarshal(top, &t); err != nil {
+		return nil, err
+	}
+	return json.Marshal(mergeMaps(b, t))
+}
+
+func mergeMaps(base, top map[string]any) map[string]any {
+	out := make(map[string]any, len(base)+len(top))
+	for k, v := range base {
+		out[k] = v
+	}
+	for k, v := range top {
+		bm, bok := out[k].(map[string]any)
+		tm, tok := v.(map[string]any)
+		if bok && tok {
+			out[k] = mergeMaps(bm, tm)
+			continue
+		}
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,189 @@
+package configfile
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"ccgateway/internal/channel"
+	"ccgateway/internal/settings"
+	"ccgateway/internal/toolcatalog"
+	"ccgateway/internal/upstream"
+)
+
+// Targets are the live components a reload writes to. Nil targets are
+// skipped.
+type Targets struct {
+	Upstream interface {
+		UpdateUpstreamConfig(upstream.UpstreamAdminConfig) (upstream.UpstreamAdminConfig, error)
+	}
+	Settings *settings.Store
+	Tools    interface{ Replace([]toolcatalog.ToolSpec) }
+	Channels *channel.AbilityStore
+}
+
+// Result describes one load of the config file.
+type Result struct {
+	Path         string    `json:"path"`
+	LoadedAt     time.Time `json:"loaded_at"`
+	Trigger      string    `json:"trigger,omitempty"`
+	Applied      []string  `json:"applied"`
+	EnvOverrides []string  `json:"env_overrides,omitempty"`
+	Error        string    `json:"error,omitempty"`
+}
+
+// Reloader loads the config file and applies it to the running gateway.
+type Reloader struct {
+	path    string
+	targets Targets
+
+	mu       sync.Mutex
+	last     Result
+	reloads  int
+	onReload []func(Result)
+	now      func() time.Time
+}
+
+func NewReloader(path string, targets Targets) *Reloader {
+	return &Reloader{path: path, targets: targets, now: time.Now}
+}
+
+// FromEnv returns a reloader for CONFIG_FILE, or nil when it is not set.
+func FromEnv(targets Targets) *Reloader {
+	path := strings.TrimSpace(os.Getenv("CONFIG_FILE"))
+	if path == "" {
+		return nil
+	}
+	return NewReloader(path, targets)
+}
+
+func (r *Reloader) Path() string {
+	return r.path
+}
+
+// Reload reads the file, overlays the environment and applies each present
+// section. Everything is parsed before anything is applied, and the
+// upstream section goes first, so a bad file changes nothing; a failure in
+// a later section is reported with the sections already applied.
+func (r *Reloader) Reload(trigger string) (Result, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	res := Result{Path: r.path, LoadedAt: r.now().UTC(), Trigger: trigger, Applied: []string{}}
+	err := r.apply(&res)
+	if err != nil {
+		res.Error = err.Error()
+	}
+	r.last = res
+	r.reloads++
+	for _, fn := range r.onReload {
+		fn(res)
+	}
+	return res, err
+}
+
+// OnReload registers fn to run after every reload, failed or not.
+func (r *Reloader) OnReload(fn func(Result)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onReload = append(r.onReload, fn)
+}
+
+func (r *Reloader) apply(res *Result) error {
+	f, err := Load(r.path)
+	if err != nil {
+		return err
+	}
+	f, res.EnvOverrides, err = f.WithEnv()
+	if err != nil {
+		return err
+	}
+	var rs *settings.RuntimeSettings
+	if len(f.Settings) > 0 && r.targets.Settings != nil {
+		parsed, err := settings.Parse(f.Settings)
+		if err != nil {
+			return fmt.Errorf("invalid settings: %w", err)
+		}
+		rs = &parsed
+	}
+	channels := make([]channel.Channel, 0, len(f.Channels))
+	for _, c := range f.Channels {
+		channels = append(channels, c.Resolve())
+	}
+
+	if r.targets.Upstream != nil && (len(f.Adapters) > 0 || len(f.DefaultRoute) > 0 || f.ModelRoutes != nil) {
+		if _, err := r.targets.Upstream.UpdateUpstreamConfig(upstream.UpstreamAdminConfig{
+			Adapters:     f.Adapters,
+			DefaultRoute: f.DefaultRoute,
+			ModelRoutes:  f.ModelRoutes,
+		}); err != nil {
+			return fmt.Errorf("upstream: %w", err)
+		}
+		res.Applied = append(res.Applied, SectionUpstream)
+	}
+	if rs != nil {
+		r.targets.Settings.Put(*rs)
+		res.Applied = append(res.Applied, SectionSettings)
+	}
+	if f.Tools != nil && r.targets.Tools != nil {
+		r.targets.Tools.Replace(f.Tools)
+		res.Applied = append(res.Applied, SectionTools)
+	}
+	if len(channels) > 0 && r.targets.Channels != nil {
+		if err := UpsertChannels(r.targets.Channels, channels); err != nil {
+			return fmt.Errorf("channels: %w", err)
+		}
+		res.Applied = append(res.Applied, SectionChannels)
+	}
+	return nil
+}
+
+// ChannelUpserter is the part of the channel store UpsertChannels needs.
+type ChannelUpserter interface {
+	GetChannel(id int64) (*channel.Channel, bool)
+	AddChannel(c *channel.Channel) error
+	UpdateChannel(c *channel.Channel) error
+}
+
+// UpsertChannels adds or updates channels by ID. Other channels, such as
+// those created through the admin API, are left alone, and runtime
+// counters of existing channels survive. A channel without a key keeps its
+// current one.
+func UpsertChannels(store ChannelUpserter, channels []channel.Channel) error {
+	for i := range channels {
+		c := &channels[i]
+		existing, ok := store.GetChannel(c.ID)
+		if !ok {
+			if err := store.AddChannel(c); err != nil {
+				return err
+			}
+			continue
+		}
+		if c.Key == "" {
+			c.Key = existing.Key
+		}
+		c.UsedQuota = existing.UsedQuota
+		c.ResponseTime = existing.ResponseTime
+		c.TestTime = existing.TestTime
+		c.Balance = existing.Balance
+		if err := store.UpdateChannel(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Status reports the last reload and how many have run.
+func (r *Reloader) Status() map[string]any {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := map[string]any{
+		"path":    r.path,
+		"reloads": r.reloads,
+	}
+	if r.reloads > 0 {
+		out["last"] = r.last
+	}
+	return out
+}
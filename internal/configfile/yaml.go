@@ -0,0 +1,454 @@
+package configfile
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseYAML reads the YAML subset config files need: block mappings and
+// sequences, plain and quoted scalars, flow collections, literal (|) and
+// folded (>) scalars and comments. Anchors, tags and multi-document
+// streams are not supported. The result is plain JSON-compatible values.
+func parseYAML(data []byte) (any, error) {
+	p := &yamlParser{}
+	for i, raw := range strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n") {
+		trimmed := strings.TrimSpace(raw)
+		if i == 0 && trimmed == "---" {
+			continue
+		}
+		content := strings.TrimRight(stripYAMLComment(raw), " \t")
+		indent := len(content) - len(strings.TrimLeft(content, " "))
+		if strings.HasPrefix(content[indent:], "\t") {
+			return nil, fmt.Errorf("line %d: tabs are not allowed for indentation", i+1)
+		}
+		p.lines = append(p.lines, yamlLine{num: i + 1, indent: indent, text: content[indent:], raw: raw})
+	}
+	p.skipBlank()
+	if p.pos >= len(p.lines) {
+		return map[string]any{}, nil
+	}
+	v, err := p.parseBlock(p.lines[p.pos].indent)
+	if err != nil {
+		return nil, err
+	}
+	p.skipBlank()
+	if p.pos < len(p.lines) {
+		return nil, fmt.Errorf("line %d: unexpected content", p.lines[p.pos].num)
+	}
+	return v, nil
+}
+
+type yamlLine struct {
+	num    int
+	indent int
+	text   string
+	raw    string
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+func (p *yamlParser) skipBlank() {
+	for p.pos < len(p.lines) && p.lines[p.pos].text == "" {
+		p.pos++
+	}
+}
+
+func (p *yamlParser) parseBlock(indent int) (any, error) {
+	p.skipBlank()
+	if isSeqItem(p.lines[p.pos].text) {
+		return p.parseSeq(indent)
+	}
+	return p.parseMap(indent)
+}
+
+func (p *yamlParser) parseSeq(indent int) (any, error) {
+	out := []any{}
+	for {
+		p.skipBlank()
+		if p.pos >= len(p.lines) {
+			return out, nil
+		}
+		line := &p.lines[p.pos]
+		if line.indent < indent || !isSeqItem(line.text) {
+			return out, nil
+		}
+		if line.indent > indent {
+			return nil, fmt.Errorf("line %d: bad indentation", line.num)
+		}
+		rest := strings.TrimLeft(line.text[1:], " ")
+		if rest == "" {
+			p.pos++
+			p.skipBlank()
+			if p.pos >= len(p.lines) || p.lines[p.pos].indent <= indent {
+				out = append(out, nil)
+				continue
+			}
+			v, err := p.parseBlock(p.lines[p.pos].indent)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, v)
+			continue
+		}
+		// "- key: value" opens a mapping whose keys line up with "key".
+		if _, _, ok := splitYAMLKey(rest); ok || isSeqItem(rest) {
+			line.indent += len(line.text) - len(rest)
+			line.text = rest
+			v, err := p.parseBlock(line.indent)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, v)
+			continue
+		}
+		p.pos++
+		v, err := p.parseValue(rest, indent, line.num)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+}
+
+func (p *yamlParser) parseMap(indent int) (any, error) {
+	out := map[string]any{}
+	for {
+		p.skipBlank()
+		if p.pos >= len(p.lines) {
+			return out, nil
+		}
+		line := p.lines[p.pos]
+		if line.indent < indent {
+			return out, nil
+		}
+		if line.indent > indent {
+			return nil, fmt.Errorf("line %d: bad indentation", line.num)
+		}
+		if isSeqItem(line.text) {
+			return out, nil
+		}
+		key, rest, ok := splitYAMLKey(line.text)
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected \"key: value\"", line.num)
+		}
+		if _, dup := out[key]; dup {
+			return nil, fmt.Errorf("line %d: duplicate key %q", line.num, key)
+		}
+		p.pos++
+		if rest != "" {
+			v, err := p.parseValue(rest, indent, line.num)
+			if err != nil {
+				return nil, err
+			}
+			out[key] = v
+			continue
+		}
+		p.skipBlank()
+		switch {
+		case p.pos < len(p.lines) && p.lines[p.pos].indent > indent:
+			v, err := p.parseBlock(p.lines[p.pos].indent)
+			if err != nil {
+				return nil, err
+			}
+			out[key] = v
+		case p.pos < len(p.lines) && p.lines[p.pos].indent == indent && isSeqItem(p.lines[p.pos].text):
+			// Sequences may sit at the same indent as their key.
+			v, err := p.parseSeq(indent)
+			if err != nil {
+				return nil, err
+			}
+			out[key] = v
+		default:
+			out[key] = nil
+		}
+	}
+}
+
+// parseValue reads the inline value after "key:" or "- ", consuming the
+// following lines for block scalars.
+func (p *yamlParser) parseValue(text string, indent, num int) (any, error) {
+	if text == "|" || text == "|-" || text == ">" || text == ">-" {
+		return p.parseBlockScalar(text, indent), nil
+	}
+	if strings.HasPrefix(text, "[") || strings.HasPrefix(text, "{") {
+		fp := &flowParser{s: text}
+		v, err := fp.parse()
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", num, err)
+		}
+		return v, nil
+	}
+	return parseYAMLScalar(text, num)
+}
+
+func (p *yamlParser) parseBlockScalar(style string, indent int) string {
+	var lines []string
+	blockIndent := -1
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		raw := strings.TrimRight(line.raw, " \t\r")
+		if strings.TrimSpace(raw) == "" {
+			lines = append(lines, "")
+			p.pos++
+			continue
+		}
+		rawIndent := len(raw) - len(strings.TrimLeft(raw, " "))
+		if rawIndent <= indent {
+			break
+		}
+		if blockIndent < 0 {
+			blockIndent = rawIndent
+		}
+		if rawIndent < blockIndent {
+			break
+		}
+		lines = append(lines, raw[blockIndent:])
+		p.pos++
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	sep := "\n"
+	if strings.HasPrefix(style, ">") {
+		sep = " "
+	}
+	out := strings.Join(lines, sep)
+	if !strings.HasSuffix(style, "-") && out != "" {
+		out += "\n"
+	}
+	return out
+}
+
+func isSeqItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// splitYAMLKey splits "key: value" outside quotes.
+func splitYAMLKey(text string) (string, string, bool) {
+	if text == "" || text[0] == '[' || text[0] == '{' {
+		return "", "", false
+	}
+	if text[0] == '"' || text[0] == '\'' {
+		end := closingQuote(text)
+		if end < 0 || end+1 >= len(text) || text[end+1] != ':' || (end+2 < len(text) && text[end+2] != ' ') {
+			return "", "", false
+		}
+		key, err := unquoteYAML(text[:end+1])
+		if err != nil {
+			return "", "", false
+		}
+		return key, strings.TrimSpace(text[end+2:]), true
+	}
+	for i := 0; i < len(text); i++ {
+		if text[i] == ':' && (i+1 == len(text) || text[i+1] == ' ') {
+			return strings.TrimSpace(text[:i]), strings.TrimSpace(text[i+1:]), true
+		}
+	}
+	return "", "", false
+}
+
+func closingQuote(text string) int {
+	q := text[0]
+	for i := 1; i < len(text); i++ {
+		switch {
+		case q == '"' && text[i] == '\\':
+			i++
+		case text[i] == q:
+			if q == '\'' && i+1 < len(text) && text[i+1] == '\'' {
+				i++
+				continue
+			}
+			return i
+		}
+	}
+	return -1
+}
+
+func unquoteYAML(text string) (string, error) {
+	if text[0] == '\'' {
+		return strings.ReplaceAll(text[1:len(text)-1], "''", "'"), nil
+	}
+	return strconv.Unquote(text)
+}
+
+func stripYAMLComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			if i == 0 || line[i-1] == ' ' || line[i-1] == '[' || line[i-1] == '{' || line[i-1] == ',' || line[i-1] == ':' || line[i-1] == '-' {
+				quote = c
+			}
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+func parseYAMLScalar(text string, num int) (any, error) {
+	if text[0] == '"' || text[0] == '\'' {
+		end := closingQuote(text)
+		if end != len(text)-1 {
+			return nil, fmt.Errorf("line %d: unterminated or trailing text after quoted string", num)
+		}
+		s, err := unquoteYAML(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", num, err)
+		}
+		return s, nil
+	}
+	switch text {
+	case "null", "Null", "NULL", "~":
+		return nil, nil
+	case "true", "True", "TRUE":
+		return true, nil
+	case "false", "False", "FALSE":
+		return false, nil
+	}
+	if n, err := strconv.ParseInt(text, 10, 64); err == nil {
+		return n, nil
+	}
+	if f, err := strconv.ParseFloat(text, 64); err == nil && strings.ContainsAny(text, "0123456789") {
+		return json.Number(strconv.FormatFloat(f, 'g', -1, 64)), nil
+	}
+	return text, nil
+}
+
+// flowParser reads [a, b] and {k: v} collections, which may nest.
+type flowParser struct {
+	s   string
+	pos int
+}
+
+func (f *flowParser) parse() (any, error) {
+	v, err := f.value()
+	if err != nil {
+		return nil, err
+	}
+	f.space()
+	if f.pos != len(f.s) {
+		return nil, fmt.Errorf("unexpected %q after flow collection", f.s[f.pos:])
+	}
+	return v, nil
+}
+
+func (f *flowParser) space() {
+	for f.pos < len(f.s) && f.s[f.pos] == ' ' {
+		f.pos++
+	}
+}
+
+func (f *flowParser) value() (any, error) {
+	f.space()
+	if f.pos >= len(f.s) {
+		return nil, fmt.Errorf("unterminated flow collection")
+	}
+	switch f.s[f.pos] {
+	case '[':
+		f.pos++
+		out := []any{}
+		for {
+			f.space()
+			if f.pos < len(f.s) && f.s[f.pos] == ']' {
+				f.pos++
+				return out, nil
+			}
+			v, err := f.value()
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, v)
+			if err := f.separator(']'); err != nil {
+				return nil, err
+			}
+		}
+	case '{':
+		f.pos++
+		out := map[string]any{}
+		for {
+			f.space()
+			if f.pos < len(f.s) && f.s[f.pos] == '}' {
+				f.pos++
+				return out, nil
+			}
+			k, err := f.scalar(true)
+			if err != nil {
+				return nil, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				key = fmt.Sprint(k)
+			}
+			f.space()
+			if f.pos >= len(f.s) || f.s[f.pos] != ':' {
+				return nil, fmt.Errorf("expected ':' after %q", key)
+			}
+			f.pos++
+			v, err := f.value()
+			if err != nil {
+				return nil, err
+			}
+			out[key] = v
+			if err := f.separator('}'); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return f.scalar(false)
+}
+
+func (f *flowParser) separator(end byte) error {
+	f.space()
+	if f.pos >= len(f.s) {
+		return fmt.Errorf("unterminated flow collection")
+	}
+	if f.s[f.pos] == ',' {
+		f.pos++
+		return nil
+	}
+	if f.s[f.pos] != end {
+		return fmt.Errorf("expected ',' or %q", end)
+	}
+	return nil
+}
+
+func (f *flowParser) scalar(isKey bool) (any, error) {
+	f.space()
+	start := f.pos
+	if f.pos < len(f.s) && (f.s[f.pos] == '"' || f.s[f.pos] == '\'') {
+		end := closingQuote(f.s[f.pos:])
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated string")
+		}
+		f.pos += end + 1
+		return unquoteYAML(f.s[start:f.pos])
+	}
+	for f.pos < len(f.s) {
+		c := f.s[f.pos]
+		if c == ',' || c == ']' || c == '}' || (isKey && c == ':') {
+			break
+		}
+		f.pos++
+	}
+	text := strings.TrimSpace(f.s[start:f.pos])
+	if text == "" {
+		return nil, fmt.Errorf("empty value in flow collection")
+	}
+	if isKey {
+		return text, nil
+	}
+	return parseYAMLScalar(text, 0)
+}
@@ -0,0 +1,498 @@
+// Package cron runs recurring gateway requests. Admins register a cron
+// expression plus a request template; the scheduler fires each job on
+// time through an executor the gateway provides, so every execution is
+// stored as a regular run.
+package cron
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"ccgateway/internal/logging"
+)
+
+const (
+	defaultTimeout = 10 * time.Minute
+	// maxIdle bounds how long Run sleeps, so clock jumps are noticed.
+	maxIdle = time.Minute
+)
+
+// Paths lists the endpoints a job may call.
+var Paths = []string{"/v1/messages", "/v1/chat/completions", "/v1/responses"}
+
+var (
+	ErrNotFound        = errors.New("cron job not found")
+	ErrInvalidSchedule = errors.New("invalid cron schedule")
+	ErrInvalidTimezone = errors.New("unknown timezone")
+	ErrInvalidPath     = errors.New("path must be one of /v1/messages, /v1/chat/completions, /v1/responses")
+	ErrInvalidRequest  = errors.New("request must be a non-streaming JSON object")
+	ErrRunning         = errors.New("cron job is already running")
+)
+
+// Job is one recurring request. Request is the body posted to Path;
+// Schedule is evaluated in Timezone (UTC when empty).
+type Job struct {
+	ID        string          `json:"id"`
+	Name      string          `json:"name,omitempty"`
+	Schedule  string          `json:"schedule"`
+	Timezone  string          `json:"timezone,omitempty"`
+	Path      string          `json:"path"`
+	Request   json.RawMessage `json:"request"`
+	Enabled   bool            `json:"enabled"`
+	Running   bool            `json:"running"`
+	NextRunAt *time.Time      `json:"next_run_at,omitempty"`
+	LastRun   *Execution      `json:"last_run,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// Execution is the outcome of one firing of a job.
+type Execution struct {
+	RunID      string    `json:"run_id,omitempty"`
+	Trigger    string    `json:"trigger"`
+	StatusCode int       `json:"status_code"`
+	Error      string    `json:"error,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+}
+
+// Input creates or updates a job. Nil fields are left unchanged on update;
+// Path defaults to /v1/messages and Enabled to true on create.
+type Input struct {
+	Name     *string          `json:"name"`
+	Schedule *string          `json:"schedule"`
+	Timezone *string          `json:"timezone"`
+	Path     *string          `json:"path"`
+	Request  *json.RawMessage `json:"request"`
+	Enabled  *bool            `json:"enabled"`
+}
+
+// Result is what the executor reports for one execution.
+type Result struct {
+	RunID      string
+	StatusCode int
+	Error      string
+}
+
+// Executor performs a job's request.
+type Executor func(ctx context.Context, job Job) Result
+
+// Config tunes the scheduler. Timeout bounds each execution (default
+// 10m); Path persists jobs and their last run as JSON.
+type Config struct {
+	Path    string
+	Timeout time.Duration
+}
+
+// Scheduler holds the jobs and fires them. It is safe for concurrent use.
+type Scheduler struct {
+	cfg Config
+
+	mu       sync.Mutex
+	jobs     map[string]*Job
+	exec     Executor
+	seq      atomic.Uint64
+	wake     chan struct{}
+	inflight sync.WaitGroup
+}
+
+// New builds a scheduler and loads jobs from cfg.Path when set. Next run
+// times are computed from now; firings missed while the gateway was down
+// are skipped.
+func New(cfg Config) (*Scheduler, error) {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	cfg.Path = strings.TrimSpace(cfg.Path)
+	s := &Scheduler{cfg: cfg, jobs: map[string]*Job{}, wake: make(chan struct{}, 1)}
+	if cfg.Path != "" {
+		if err := s.load(); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// NewFromEnv reads CRON_STORE_PATH (jobs file; empty keeps them in memory)
+// and CRON_RUN_TIMEOUT.
+func NewFromEnv() (*Scheduler, error) {
+	cfg := Config{Path: os.Getenv("CRON_STORE_PATH")}
+	if raw := strings.TrimSpace(os.Getenv("CRON_RUN_TIMEOUT")); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid CRON_RUN_TIMEOUT %q", raw)
+		}
+		cfg.Timeout = d
+	}
+	return New(cfg)
+}
+
+// SetExecutor installs the function that performs job requests.
+func (s *Scheduler) SetExecutor(fn Executor) {
+	s.mu.Lock()
+	s.exec = fn
+	s.mu.Unlock()
+}
+
+func (s *Scheduler) Create(in Input) (Job, error) {
+	now := time.Now().UTC()
+	job := Job{
+		ID:        fmt.Sprintf("cron_%d_%d", now.UnixNano(), s.seq.Add(1)),
+		Path:      Paths[0],
+		Enabled:   true,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if in.Schedule == nil {
+		return Job{}, fmt.Errorf("%w: schedule is required", ErrInvalidSchedule)
+	}
+	if in.Request == nil {
+		return Job{}, ErrInvalidRequest
+	}
+	if err := applyInput(&job, in); err != nil {
+		return Job{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	scheduleNext(&job, now)
+	s.jobs[job.ID] = &job
+	if err := s.saveLocked(); err != nil {
+		delete(s.jobs, job.ID)
+		return Job{}, err
+	}
+	s.poke()
+	return cloneJob(&job), nil
+}
+
+func (s *Scheduler) Update(id string, in Input) (Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prev, ok := s.jobs[strings.TrimSpace(id)]
+	if !ok {
+		return Job{}, ErrNotFound
+	}
+	next := *prev
+	if err := applyInput(&next, in); err != nil {
+		return Job{}, err
+	}
+	next.UpdatedAt = time.Now().UTC()
+	scheduleNext(&next, next.UpdatedAt)
+	saved := *prev
+	*prev = next
+	if err := s.saveLocked(); err != nil {
+		*prev = saved
+		return Job{}, err
+	}
+	s.poke()
+	return cloneJob(prev), nil
+}
+
+func (s *Scheduler) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prev, ok := s.jobs[strings.TrimSpace(id)]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(s.jobs, prev.ID)
+	if err := s.saveLocked(); err != nil {
+		s.jobs[prev.ID] = prev
+		return err
+	}
+	return nil
+}
+
+func (s *Scheduler) Get(id string) (Job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[strings.TrimSpace(id)]
+	if !ok {
+		return Job{}, false
+	}
+	return cloneJob(job), true
+}
+
+// List returns all jobs, soonest next run first; disabled jobs last.
+func (s *Scheduler) List() []Job {
+	s.mu.Lock()
+	out := make([]Job, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		out = append(out, cloneJob(job))
+	}
+	s.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i].NextRunAt, out[j].NextRunAt
+		switch {
+		case a != nil && b != nil && !a.Equal(*b):
+			return a.Before(*b)
+		case (a == nil) != (b == nil):
+			return a != nil
+		}
+		return out[i].CreatedAt.Before(out[j].CreatedAt)
+	})
+	return out
+}
+
+// Trigger fires job id now, outside its schedule. It fails when the job
+// is already running.
+func (s *Scheduler) Trigger(ctx context.Context, id string) (Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[strings.TrimSpace(id)]
+	if !ok {
+		return Job{}, ErrNotFound
+	}
+	if job.Running {
+		return Job{}, ErrRunning
+	}
+	s.startLocked(ctx, job, "manual")
+	return cloneJob(job), nil
+}
+
+// Run fires due jobs until ctx ends.
+func (s *Scheduler) Run(ctx context.Context) {
+	for {
+		timer := time.NewTimer(s.untilNext(time.Now()))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-s.wake:
+			timer.Stop()
+		case <-timer.C:
+		}
+		s.RunDue(ctx, time.Now())
+	}
+}
+
+// RunDue starts every enabled job whose next run is at or before now and
+// advances its schedule. A job still running from its previous firing is
+// skipped for this one.
+func (s *Scheduler) RunDue(ctx context.Context, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, job := range s.jobs {
+		if !job.Enabled || job.NextRunAt == nil || job.NextRunAt.After(now) {
+			continue
+		}
+		scheduleNext(job, now)
+		if job.Running {
+			logging.Component("cron").Warn("cron job still running, skipping firing", "job_id", job.ID)
+			continue
+		}
+		s.startLocked(ctx, job, "schedule")
+	}
+}
+
+// Wait blocks until running executions finish.
+func (s *Scheduler) Wait() {
+	s.inflight.Wait()
+}
+
+func (s *Scheduler) startLocked(ctx context.Context, job *Job, trigger string) {
+	job.Running = true
+	snapshot := cloneJob(job)
+	exec := s.exec
+	s.inflight.Add(1)
+	go func() {
+		defer s.inflight.Done()
+		started := time.Now().UTC()
+		var res Result
+		if exec == nil {
+			res = Result{StatusCode: 500, Error: "no executor configured"}
+		} else {
+			runCtx, cancel := context.WithTimeout(ctx, s.cfg.Timeout)
+			res = exec(runCtx, snapshot)
+			cancel()
+		}
+		s.finish(snapshot.ID, Execution{
+			RunID:      res.RunID,
+			Trigger:    trigger,
+			StatusCode: res.StatusCode,
+			Error:      res.Error,
+			StartedAt:  started,
+			FinishedAt: time.Now().UTC(),
+		})
+	}()
+}
+
+func (s *Scheduler) finish(id string, ex Execution) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		return
+	}
+	job.Running = false
+	job.LastRun = &ex
+	if err := s.saveLocked(); err != nil {
+		logging.Component("cron").Warn("cron store save failed", "path", s.cfg.Path, "error", err)
+	}
+}
+
+func (s *Scheduler) untilNext(now time.Time) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	wait := maxIdle
+	for _, job := range s.jobs {
+		if !job.Enabled || job.NextRunAt == nil {
+			continue
+		}
+		if d := job.NextRunAt.Sub(now); d < wait {
+			wait = max(d, 0)
+		}
+	}
+	return wait
+}
+
+// poke wakes Run so it picks up a changed schedule.
+func (s *Scheduler) poke() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func scheduleNext(job *Job, now time.Time) {
+	job.NextRunAt = nil
+	if !job.Enabled {
+		return
+	}
+	sched, err := Parse(job.Schedule)
+	if err != nil {
+		return
+	}
+	loc := time.UTC
+	if job.Timezone != "" {
+		if l, err := time.LoadLocation(job.Timezone); err == nil {
+			loc = l
+		}
+	}
+	if next := sched.Next(now.In(loc)); !next.IsZero() {
+		next = next.UTC()
+		job.NextRunAt = &next
+	}
+}
+
+func applyInput(job *Job, in Input) error {
+	if in.Name != nil {
+		job.Name = strings.TrimSpace(*in.Name)
+	}
+	if in.Schedule != nil {
+		expr := strings.TrimSpace(*in.Schedule)
+		if _, err := Parse(expr); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidSchedule, err)
+		}
+		job.Schedule = expr
+	}
+	if in.Timezone != nil {
+		tz := strings.TrimSpace(*in.Timezone)
+		if tz != "" {
+			if _, err := time.LoadLocation(tz); err != nil {
+				return fmt.Errorf("%w %q", ErrInvalidTimezone, tz)
+			}
+		}
+		job.Timezone = tz
+	}
+	if in.Path != nil {
+		path := strings.TrimSpace(*in.Path)
+		valid := false
+		for _, p := range Paths {
+			valid = valid || p == path
+		}
+		if !valid {
+			return ErrInvalidPath
+		}
+		job.Path = path
+	}
+	if in.Request != nil {
+		var body map[string]any
+		if err := json.Unmarshal(*in.Request, &body); err != nil || body == nil {
+			return ErrInvalidRequest
+		}
+		if stream, _ := body["stream"].(bool); stream {
+			return ErrInvalidRequest
+		}
+		job.Request = append(json.RawMessage(nil), *in.Request...)
+	}
+	if in.Enabled != nil {
+		job.Enabled = *in.Enabled
+	}
+	return nil
+}
+
+func cloneJob(job *Job) Job {
+	out := *job
+	out.Request = append(json.RawMessage(nil), job.Request...)
+	if job.NextRunAt != nil {
+		t := *job.NextRunAt
+		out.NextRunAt = &t
+	}
+	if job.LastRun != nil {
+		ex := *job.LastRun
+		out.LastRun = &ex
+	}
+	return out
+}
+
+func (s *Scheduler) load() error {
+	raw, err := os.ReadFile(s.cfg.Path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read cron store: %w", err)
+	}
+	var jobs []Job
+	if err := json.Unmarshal(raw, &jobs); err != nil {
+		return fmt.Errorf("parse cron store: %w", err)
+	}
+	now := time.Now().UTC()
+	for i := range jobs {
+		job := jobs[i]
+		if job.ID == "" {
+			continue
+		}
+		job.Running = false
+		scheduleNext(&job, now)
+		s.jobs[job.ID] = &job
+	}
+	return nil
+}
+
+// saveLocked rewrites the jobs file through a temporary file so a crash
+// never leaves it half written.
+func (s *Scheduler) saveLocked() error {
+	if s.cfg.Path == "" {
+		return nil
+	}
+	jobs := make([]Job, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		jobs = append(jobs, cloneJob(job))
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].CreatedAt.Before(jobs[j].CreatedAt) })
+	raw, err := json.MarshalIndent(jobs, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.cfg.Path), 0o755); err != nil {
+		return fmt.Errorf("create cron store dir: %w", err)
+	}
+	tmp := s.cfg.Path + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0o600); err != nil {
+		return fmt.Errorf("write cron store: %w", err)
+	}
+	if err := os.Rename(tmp, s.cfg.Path); err != nil {
+		return fmt.Errorf("write cron store: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+// Package dataprotect encrypts persisted user content with per-project
+// keys.
+package dataprotect
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"ccgateway/internal/requestctx"
+)
+
+// sealedPrefix tags the format of a sealed value. It is not a marker of
+// whether a value is sealed: callers record that separately, since
+// plaintext may start with the same characters.
+const sealedPrefix = "enc:v1:"
+
+var (
+	ErrInvalidKey = errors.New("encryption keys must be 32 bytes, base64 encoded")
+	ErrNoKey      = errors.New("no encryption key for project")
+	ErrCorrupt    = errors.New("encrypted value is corrupt or was sealed with another key")
+)
+
+// Keyring holds the data keys. A project uses its own key when one is
+// configured and otherwise a key derived from the master key, so every
+// project's data is sealed under a distinct key either way.
+type Keyring struct {
+	mu       sync.RWMutex
+	master   []byte
+	projects map[string][]byte
+}
+
+// NewKeyring builds a keyring from a master key (may be nil) and explicit
+// per-project keys. Every key must be 32 bytes.
+func NewKeyring(master []byte, projects map[string][]byte) (*Keyring, error) {
+	if master != nil && len(master) != 32 {
+		return nil, ErrInvalidKey
+	}
+	k := &Keyring{master: append([]byte(nil), master...), projects: map[string][]byte{}}
+	for id, key := range projects {
+		if len(key) != 32 {
+			return nil, fmt.Errorf("project %q: %w", id, ErrInvalidKey)
+		}
+		k.projects[requestctx.NormalizeProjectID(id)] = append([]byte(nil), key...)
+	}
+	return k, nil
+}
+
+// KeyringFromEnv reads DATA_ENCRYPTION_KEY (base64 master key) and
+// DATA_ENCRYPTION_KEYS_JSON ({"project": "base64 key"}). It returns nil
+// when neither is set, which leaves persisted data unencrypted.
+func KeyringFromEnv() (*Keyring, error) {
+	rawMaster := strings.TrimSpace(os.Getenv("DATA_ENCRYPTION_KEY"))
+	rawProjects := strings.TrimSpace(os.Getenv("DATA_ENCRYPTION_KEYS_JSON"))
+	if rawMaster == "" && rawProjects == "" {
+		return nil, nil
+	}
+	var master []byte
+	if rawMaster != "" {
+		key, err := base64.StdEncoding.DecodeString(rawMaster)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DATA_ENCRYPTION_KEY: %w", ErrInvalidKey)
+		}
+		master = key
+	}
+	projects := map[string][]byte{}
+	if rawProjects != "" {
+		var encoded map[string]string
+		if err := json.Unmarshal([]byte(rawProjects), &encoded); err != nil {
+			return nil, fmt.Errorf("invalid DATA_ENCRYPTION_KEYS_JSON: %w", err)
+		}
+		for id, v := range encoded {
+			key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(v))
+			if err != nil {
+				return nil, fmt.Errorf("invalid DATA_ENCRYPTION_KEYS_JSON: project %q: %w", id, ErrInvalidKey)
+			}
+			projects[id] = key
+		}
+	}
+	k, err := NewKeyring(master, projects)
+	if err != nil {
+		return nil, fmt.Errorf("invalid data encryption keys: %w", err)
+	}
+	return k, nil
+}
+
+// Encrypt seals plaintext for projectID. Empty input stays empty.
+func (k *Keyring) Encrypt(projectID, plaintext string) (string, error) {
+	if plaintext == "" {
+		return plaintext, nil
+	}
+	projectID = requestctx.NormalizeProjectID(projectID)
+	keys, err := k.keys(projectID)
+	if err != nil {
+		return "", err
+	}
+	aead, err := newAEAD(keys[0])
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), []byte(projectID))
+	return sealedPrefix + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt opens a value sealed by Encrypt. Empty input stays empty. The
+// project's own key is tried first, then the one derived from the master
+// key, so values sealed while the project had no key of its own still
+// open once it has one.
+func (k *Keyring) Decrypt(projectID, value string) (string, error) {
+	if value == "" {
+		return value, nil
+	}
+	if !strings.HasPrefix(value, sealedPrefix) {
+		return "", ErrCorrupt
+	}
+	projectID = requestctx.NormalizeProjectID(projectID)
+	keys, err := k.keys(projectID)
+	if err != nil {
+		return "", err
+	}
+	raw, err := base64.RawStdEncoding.DecodeString(strings.TrimPrefix(value, sealedPrefix))
+	if err != nil {
+		return "", ErrCorrupt
+	}
+	for _, key := range keys {
+		aead, err := newAEAD(key)
+		if err != nil {
+			return "", err
+		}
+		if len(raw) < aead.NonceSize() {
+			return "", ErrCorrupt
+		}
+		nonce, body := raw[:aead.NonceSize()], raw[aead.NonceSize():]
+		if plain, err := aead.Open(nil, nonce, body, []byte(projectID)); err == nil {
+			return string(plain), nil
+		}
+	}
+	return "", ErrCorrupt
+}
+
+// HasProjectKey reports whether projectID has its own configured key.
+func (k *Keyring) HasProjectKey(projectID string) bool {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	_, ok := k.projects[requestctx.NormalizeProjectID(projectID)]
+	return ok
+}
+
+// ForgetProject drops projectID's own key from this process: values sealed
+// under it can no longer be read here, and the project falls back to the
+// key derived from the master key. The key is configured through
+// DATA_ENCRYPTION_KEYS_JSON and returns on restart unless it is removed
+// there too; only destroying every copy of it keeps copies of the data the
+// gateway cannot reach, such as backups, unreadable.
+func (k *Keyring) ForgetProject(projectID string) bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	projectID = requestctx.NormalizeProjectID(projectID)
+	key, ok := k.projects[projectID]
+	if !ok {
+		return false
+	}
+	for i := range key {
+		key[i] = 0
+	}
+	delete(k.projects, projectID)
+	return true
+}
+
+// keys returns the keys projectID's values may be sealed under, the one
+// to seal new values with first.
+func (k *Keyring) keys(projectID string) ([][]byte, error) {
+	k.mu.RLock()
+	own, ok := k.projects[projectID]
+	master := k.master
+	k.mu.RUnlock()
+	var keys [][]byte
+	if ok {
+		keys = append(keys, own)
+	}
+	if master != nil {
+		mac := hmac.New(sha256.New, master)
+		mac.Write([]byte("ccgateway/project/" + projectID))
+		keys = append(keys, mac.Sum(nil))
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%w %q", ErrNoKey, projectID)
+	}
+	return keys, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,140 @@
+package degrade
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"ccgateway/internal/settings"
+)
+
+// maxWindow bounds how far back the monitor keeps outcome buckets.
+const maxWindow = 600
+
+// Load is the gateway load the ladder is evaluated against.
+type Load struct {
+	InFlight  int64   `json:"in_flight"`
+	Requests  int     `json:"requests"`
+	Errors    int     `json:"errors"`
+	ErrorRate float64 `json:"error_rate"`
+	// Upstream counts individual adapter calls, including retries and
+	// fallbacks; Saturated is how many of them were answered 429/529.
+	UpstreamCalls  int     `json:"upstream_calls"`
+	Saturated      int     `json:"saturated"`
+	SaturationRate float64 `json:"saturation_rate"`
+}
+
+// Monitor counts in-flight model requests and keeps per-second outcome
+// buckets for the upstream error rate. It is safe for concurrent use.
+type Monitor struct {
+	inflight atomic.Int64
+
+	mu      sync.Mutex
+	buckets [maxWindow]bucket
+	now     func() time.Time
+}
+
+type bucket struct {
+	second    int64
+	requests  int
+	errors    int
+	calls     int
+	saturated int
+}
+
+func NewMonitor() *Monitor {
+	return &Monitor{now: time.Now}
+}
+
+// Begin marks a request in flight. The returned func must be called once
+// with whether the request failed upstream.
+func (m *Monitor) Begin() func(upstreamFailed bool) {
+	m.inflight.Add(1)
+	var once sync.Once
+	return func(upstreamFailed bool) {
+		once.Do(func() {
+			m.inflight.Add(-1)
+			m.record(upstreamFailed)
+		})
+	}
+}
+
+func (m *Monitor) record(failed bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b := m.bucketLocked()
+	b.requests++
+	if failed {
+		b.errors++
+	}
+}
+
+// ObserveUpstream records one adapter call and whether the upstream
+// answered it as saturated.
+func (m *Monitor) ObserveUpstream(saturated bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b := m.bucketLocked()
+	b.calls++
+	if saturated {
+		b.saturated++
+	}
+}
+
+func (m *Monitor) bucketLocked() *bucket {
+	sec := m.now().Unix()
+	b := &m.buckets[sec%maxWindow]
+	if b.second != sec {
+		*b = bucket{second: sec}
+	}
+	return b
+}
+
+// Snapshot reports current in-flight requests and outcomes over the last
+// window.
+func (m *Monitor) Snapshot(window time.Duration) Load {
+	seconds := int64(window / time.Second)
+	if seconds <= 0 {
+		seconds = 1
+	}
+	if seconds > maxWindow {
+		seconds = maxWindow
+	}
+	load := Load{InFlight: m.inflight.Load()}
+	now := m.now().Unix()
+	m.mu.Lock()
+	for _, b := range m.buckets {
+		if b.second > now-seconds && b.second <= now {
+			load.Requests += b.requests
+			load.Errors += b.errors
+			load.UpstreamCalls += b.calls
+			load.Saturated += b.saturated
+		}
+	}
+	m.mu.Unlock()
+	if load.Requests > 0 {
+		load.ErrorRate = math.Round(float64(load.Errors)/float64(load.Requests)*10000) / 10000
+	}
+	if load.UpstreamCalls > 0 {
+		load.SaturationRate = math.Round(float64(load.Saturated)/float64(load.UpstreamCalls)*10000) / 10000
+	}
+	return load
+}
+
+// Level returns how many ladder steps are active: the highest step whose
+// in-flight or error-rate trigger is met. Error-rate triggers need at least
+// minSamples requests in the window.
+func Level(ladder []settings.DegradationStep, load Load, minSamples int) int {
+	level := 0
+	for i, step := range ladder {
+		hit := step.InFlight > 0 && load.InFlight >= int64(step.InFlight)
+		if !hit && step.ErrorRate > 0 && load.Requests >= minSamples {
+			hit = load.ErrorRate >= step.ErrorRate
+		}
+		if hit {
+			level = i + 1
+		}
+	}
+	return level
+}
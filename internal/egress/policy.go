@@ -0,0 +1,419 @@
+package egress
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+var (
+	ErrBlocked          = errors.New("egress blocked")
+	ErrResponseTooLarge = errors.New("egress response exceeds max size")
+)
+
+// Config controls gateway-initiated outbound calls (tool executors, MCP
+// clients, marketplace fetches). Deny CIDRs always win; when allow CIDRs are
+// set, only those ranges are reachable and they override BlockPrivate.
+type Config struct {
+	AllowCIDRs       []string `json:"allow_cidrs"`
+	DenyCIDRs        []string `json:"deny_cidrs"`
+	BlockPrivate     bool     `json:"block_private"`
+	MaxResponseBytes int64    `json:"max_response_bytes"`
+}
+
+type ConfigPatch struct {
+	AllowCIDRs       []string `json:"allow_cidrs,omitempty"`
+	DenyCIDRs        []string `json:"deny_cidrs,omitempty"`
+	BlockPrivate     *bool    `json:"block_private,omitempty"`
+	MaxResponseBytes *int64   `json:"max_response_bytes,omitempty"`
+}
+
+type Policy struct {
+	mu    sync.RWMutex
+	cfg   Config
+	allow []*net.IPNet
+	deny  []*net.IPNet
+
+	blocked uint64
+}
+
+func NewPolicy(cfg Config) (*Policy, error) {
+	p := &Policy{}
+	if err := p.apply(cfg); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func NewFromEnv() (*Policy, error) {
+	cfg := Config{
+		AllowCIDRs: splitList(os.Getenv("EGRESS_ALLOW_CIDRS")),
+		DenyCIDRs:  splitList(os.Getenv("EGRESS_DENY_CIDRS")),
+	}
+	if raw := strings.TrimSpace(os.Getenv("EGRESS_BLOCK_PRIVATE")); raw != "" {
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid EGRESS_BLOCK_PRIVATE: %w", err)
+		}
+		cfg.BlockPrivate = v
+	}
+	if raw := strings.TrimSpace(os.Getenv("EGRESS_MAX_RESPONSE_BYTES")); raw != "" {
+		v, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || v < 0 {
+			return nil, fmt.Errorf("invalid EGRESS_MAX_RESPONSE_BYTES: %q", raw)
+		}
+		cfg.MaxResponseBytes = v
+	}
+	p, err := NewPolicy(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid egress config: %w", err)
+	}
+	return p, nil
+}
+
+var (
+	defaultMu     sync.RWMutex
+	defaultPolicy = &Policy{}
+)
+
+// Default returns the process-wide policy used by callers that have no
+// explicit policy injected. It allows everything until SetDefault is called.
+func Default() *Policy {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultPolicy
+}
+
+func SetDefault(p *Policy) {
+	if p == nil {
+		p = &Policy{}
+	}
+	defaultMu.Lock()
+	defaultPolicy = p
+	defaultMu.Unlock()
+}
+
+func (p *Policy) Config() Config {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return cloneConfig(p.cfg)
+}
+
+func (p *Policy) Snapshot() map[string]any {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return map[string]any{
+		"config":  cloneConfig(p.cfg),
+		"blocked": p.blocked,
+	}
+}
+
+func (p *Policy) UpdateConfigPatch(patch ConfigPatch) (Config, error) {
+	next := p.Config()
+	if patch.AllowCIDRs != nil {
+		next.AllowCIDRs = patch.AllowCIDRs
+	}
+	if patch.DenyCIDRs != nil {
+		next.DenyCIDRs = patch.DenyCIDRs
+	}
+	if patch.BlockPrivate != nil {
+		next.BlockPrivate = *patch.BlockPrivate
+	}
+	if patch.MaxResponseBytes != nil {
+		if *patch.MaxResponseBytes < 0 {
+			return p.Config(), fmt.Errorf("max_response_bytes must be >= 0")
+		}
+		next.MaxResponseBytes = *patch.MaxResponseBytes
+	}
+	if err := p.apply(next); err != nil {
+		return p.Config(), err
+	}
+	return p.Config(), nil
+}
+
+// Strict returns a copy of the policy that additionally blocks private and
+// local networks regardless of the BlockPrivate setting.
+func (p *Policy) Strict() *Policy {
+	cfg := p.Config()
+	cfg.BlockPrivate = true
+	cfg.AllowCIDRs = nil
+	out, err := NewPolicy(cfg)
+	if err != nil {
+		return &Policy{cfg: Config{BlockPrivate: true}}
+	}
+	return out
+}
+
+func (p *Policy) apply(cfg Config) error {
+	cfg.AllowCIDRs = normalizeList(cfg.AllowCIDRs)
+	cfg.DenyCIDRs = normalizeList(cfg.DenyCIDRs)
+	allow, err := parseCIDRs(cfg.AllowCIDRs)
+	if err != nil {
+		return fmt.Errorf("allow_cidrs: %w", err)
+	}
+	deny, err := parseCIDRs(cfg.DenyCIDRs)
+	if err != nil {
+		return fmt.Errorf("deny_cidrs: %w", err)
+	}
+	p.mu.Lock()
+	p.cfg = cfg
+	p.allow = allow
+	p.deny = deny
+	p.mu.Unlock()
+	return nil
+}
+
+// CheckIP reports whether ip may be contacted.
+func (p *Policy) CheckIP(ip net.IP) error {
+	if ip == nil {
+		return fmt.Errorf("%w: invalid address", ErrBlocked)
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	for _, n := range p.deny {
+		if n.Contains(ip) {
+			return fmt.Errorf("%w: %s is in denied range %s", ErrBlocked, ip, n)
+		}
+	}
+	if len(p.allow) > 0 {
+		for _, n := range p.allow {
+			if n.Contains(ip) {
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: %s is not in an allowed range", ErrBlocked, ip)
+	}
+	if p.cfg.BlockPrivate && !IsPublicIP(ip) {
+		return fmt.Errorf("%w: private network address %s", ErrBlocked, ip)
+	}
+	return nil
+}
+
+// CheckHost validates a host name before connecting. It is a fast pre-flight
+// check only; the dialer re-validates the address actually connected to, so
+// a DNS answer that changes between check and connect is still caught.
+func (p *Policy) CheckHost(ctx context.Context, host string) error {
+	host = strings.TrimSpace(host)
+	if host == "" {
+		return fmt.Errorf("%w: host is required", ErrBlocked)
+	}
+	if ip := net.ParseIP(strings.Trim(host, "[]")); ip != nil {
+		return p.record(p.CheckIP(ip))
+	}
+	p.mu.RLock()
+	blockPrivate := p.cfg.BlockPrivate && len(p.allow) == 0
+	p.mu.RUnlock()
+	if blockPrivate && IsLikelyLocalHostname(host) {
+		return p.record(fmt.Errorf("%w: private network host %s", ErrBlocked, host))
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return fmt.Errorf("resolve %s failed: %w", host, err)
+	}
+	if len(addrs) == 0 {
+		return fmt.Errorf("resolve %s returned no addresses", host)
+	}
+	for _, addr := range addrs {
+		if err := p.CheckIP(addr.IP); err != nil {
+			return p.record(err)
+		}
+	}
+	return nil
+}
+
+// HTTPClient returns a client whose connections are validated against the
+// policy at dial time and whose response bodies are capped.
+func (p *Policy) HTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: p.Transport(nil),
+	}
+}
+
+// Transport wraps base (http.DefaultTransport when nil) with the policy.
+// Dial-time address checks need an *http.Transport; other round trippers
+// only get the response size cap.
+func (p *Policy) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	t, ok := base.(*http.Transport)
+	if !ok {
+		return &limitedTransport{base: base, policy: p}
+	}
+	t = t.Clone()
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				host = address
+			}
+			return p.record(p.CheckIP(net.ParseIP(host)))
+		},
+	}
+	t.DialContext = dialer.DialContext
+	// Proxies would hide the real destination from the dial-time check.
+	t.Proxy = nil
+	return &limitedTransport{base: t, policy: p}
+}
+
+func (p *Policy) record(err error) error {
+	if err != nil && errors.Is(err, ErrBlocked) {
+		p.mu.Lock()
+		p.blocked++
+		p.mu.Unlock()
+	}
+	return err
+}
+
+type limitedTransport struct {
+	base   http.RoundTripper
+	policy *Policy
+}
+
+func (t *limitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	max := t.policy.Config().MaxResponseBytes
+	if max <= 0 {
+		return resp, nil
+	}
+	if resp.ContentLength > max {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: content-length %d > %d", ErrResponseTooLarge, resp.ContentLength, max)
+	}
+	resp.Body = &limitedBody{limitedReader: limitedReader{r: resp.Body, remaining: max}, closer: resp.Body}
+	return resp, nil
+}
+
+type limitedReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (l *limitedReader) Read(b []byte) (int, error) {
+	if l.remaining < 0 {
+		return 0, ErrResponseTooLarge
+	}
+	// Read one byte past the limit so an exact-size body is still accepted.
+	if int64(len(b)) > l.remaining+1 {
+		b = b[:l.remaining+1]
+	}
+	n, err := l.r.Read(b)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return n + int(l.remaining), ErrResponseTooLarge
+	}
+	return n, err
+}
+
+type limitedBody struct {
+	limitedReader
+	closer io.Closer
+}
+
+func (b *limitedBody) Close() error { return b.closer.Close() }
+
+// IsPublicIP reports whether ip is a globally routable unicast address.
+func IsPublicIP(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return false
+	}
+
+	if ipv4 := ip.To4(); ipv4 != nil {
+		// CGNAT 100.64.0.0/10
+		if ipv4[0] == 100 && (ipv4[1]&0xc0) == 64 {
+			return false
+		}
+		// Benchmark testing 198.18.0.0/15
+		if ipv4[0] == 198 && (ipv4[1] == 18 || ipv4[1] == 19) {
+			return false
+		}
+		// Documentation-only ranges
+		if ipv4[0] == 192 && ipv4[1] == 0 && ipv4[2] == 2 {
+			return false
+		}
+		if ipv4[0] == 198 && ipv4[1] == 51 && ipv4[2] == 100 {
+			return false
+		}
+		if ipv4[0] == 203 && ipv4[1] == 0 && ipv4[2] == 113 {
+			return false
+		}
+	}
+	return true
+}
+
+func IsLikelyLocalHostname(host string) bool {
+	h := strings.ToLower(strings.TrimSpace(host))
+	if h == "localhost" || h == "localhost.localdomain" {
+		return true
+	}
+	return strings.HasSuffix(h, ".local") || strings.HasSuffix(h, ".internal")
+}
+
+func parseCIDRs(items []string) ([]*net.IPNet, error) {
+	out := make([]*net.IPNet, 0, len(items))
+	for _, item := range items {
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid cidr %q", item)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				bits = 32
+			}
+			item = fmt.Sprintf("%s/%d", item, bits)
+		}
+		_, n, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cidr %q", item)
+		}
+		out = append(out, n)
+	}
+	return out, nil
+}
+
+func splitList(raw string) []string {
+	if strings.TrimSpace(raw) == "" {
+		return nil
+	}
+	return strings.Split(raw, ",")
+}
+
+func normalizeList(items []string) []string {
+	out := make([]string, 0, len(items))
+	for _, item := range items {
+		if v := strings.TrimSpace(item); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func cloneConfig(cfg Config) Config {
+	cfg.AllowCIDRs = append([]string(nil), cfg.AllowCIDRs...)
+	cfg.DenyCIDRs = append([]string(nil), cfg.DenyCIDRs...)
+	return cfg
+}
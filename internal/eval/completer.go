@@ -0,0 +1,45 @@
+package eval
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"ccgateway/internal/orchestrator"
+)
+
+// ServiceCompleter adapts an orchestrator.Service to Completer so the judge
+// runs through the gateway's normal upstream routing.
+type ServiceCompleter struct {
+	svc       orchestrator.Service
+	maxTokens int
+}
+
+func NewServiceCompleter(svc orchestrator.Service) *ServiceCompleter {
+	return &ServiceCompleter{svc: svc, maxTokens: 1024}
+}
+
+func (c *ServiceCompleter) CompleteSimple(ctx context.Context, model, system, user string) (string, error) {
+	if c == nil || c.svc == nil {
+		return "", fmt.Errorf("no orchestrator configured")
+	}
+	req := orchestrator.Request{
+		Model:     model,
+		MaxTokens: c.maxTokens,
+		Messages:  []orchestrator.Message{{Role: "user", Content: user}},
+	}
+	if strings.TrimSpace(system) != "" {
+		req.System = system
+	}
+	resp, err := c.svc.Complete(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	var parts []string
+	for _, b := range resp.Blocks {
+		if b.Type == "text" && b.Text != "" {
+			parts = append(parts, b.Text)
+		}
+	}
+	return strings.Join(parts, "\n"), nil
+}
@@ -2,6 +2,8 @@ package eval
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"math"
@@ -51,6 +53,13 @@ const evalSystemPrompt = `You are an expert AI response evaluator. Score the giv
 Return ONLY valid JSON:
 {"accuracy":N,"completeness":N,"reasoning":N,"code_quality":N,"instruction_following":N,"analysis":"brief 2-3 sentence analysis"}`
 
+// Version identifies the judge (model plus rubric) so scores produced by
+// different judges can be told apart and compared.
+func (e *Evaluator) Version() string {
+	sum := sha256.Sum256([]byte(evalSystemPrompt))
+	return e.judgeModel + "@" + hex.EncodeToString(sum[:4])
+}
+
 // Evaluate scores a model's response quality using a judge model.
 func (e *Evaluator) Evaluate(ctx context.Context, model, prompt, response string) (EvalResult, error) {
 	if e.completer == nil {
@@ -0,0 +1,321 @@
+package eval
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"ccgateway/internal/ccrun"
+)
+
+const (
+	RescoreStatusRunning   = "running"
+	RescoreStatusCompleted = "completed"
+	RescoreStatusCancelled = "cancelled"
+
+	maxRescoreErrors = 20
+	maxRescoreJobs   = 50
+)
+
+// RunScoreStore is the slice of the run store a rescore job needs.
+type RunScoreStore interface {
+	List(filter ccrun.ListFilter) []ccrun.Run
+	RecordScore(id string, score ccrun.Score) (ccrun.Run, error)
+}
+
+// Scorer judges one prompt/response pair; Version names the judge.
+type Scorer interface {
+	Evaluate(ctx context.Context, model, prompt, response string) (EvalResult, error)
+	Version() string
+}
+
+// RescoreRequest selects the stored runs to re-judge. Runs already scored
+// by the current judge version are skipped unless Force is set.
+type RescoreRequest struct {
+	Since time.Time `json:"since,omitempty"`
+	Until time.Time `json:"until,omitempty"`
+	Path  string    `json:"path,omitempty"`
+	Mode  string    `json:"mode,omitempty"`
+	Model string    `json:"model,omitempty"`
+	Limit int       `json:"limit,omitempty"`
+	Force bool      `json:"force,omitempty"`
+}
+
+// ScoreSummary aggregates one judge version's scores over a job's runs.
+type ScoreSummary struct {
+	Count int     `json:"count"`
+	Mean  float64 `json:"mean"`
+}
+
+type RescoreJob struct {
+	ID           string                  `json:"id"`
+	Status       string                  `json:"status"`
+	JudgeVersion string                  `json:"judge_version"`
+	Request      RescoreRequest          `json:"request"`
+	Total        int                     `json:"total"`
+	Scored       int                     `json:"scored"`
+	Skipped      int                     `json:"skipped"`
+	Failed       int                     `json:"failed"`
+	Errors       []string                `json:"errors,omitempty"`
+	Summary      map[string]ScoreSummary `json:"summary,omitempty"`
+	CreatedAt    time.Time               `json:"created_at"`
+	CompletedAt  *time.Time              `json:"completed_at,omitempty"`
+}
+
+// Rescorer runs background jobs that re-judge stored run outputs and write
+// the new scores next to the old ones, keyed by judge version.
+type Rescorer struct {
+	runs    RunScoreStore
+	scorer  Scorer
+	counter atomic.Uint64
+
+	mu      sync.Mutex
+	jobs    map[string]*RescoreJob
+	order   []string
+	cancels map[string]context.CancelFunc
+}
+
+func NewRescorer(runs RunScoreStore, scorer Scorer) *Rescorer {
+	return &Rescorer{
+		runs:    runs,
+		scorer:  scorer,
+		jobs:    map[string]*RescoreJob{},
+		cancels: map[string]context.CancelFunc{},
+	}
+}
+
+// Start selects the runs for req and scores them in the background.
+func (r *Rescorer) Start(req RescoreRequest) (RescoreJob, error) {
+	if r == nil || r.runs == nil || r.scorer == nil {
+		return RescoreJob{}, fmt.Errorf("rescoring is not configured")
+	}
+	if !req.Since.IsZero() && !req.Until.IsZero() && !req.Until.After(req.Since) {
+		return RescoreJob{}, fmt.Errorf("until must be after since")
+	}
+	if req.Limit < 0 {
+		return RescoreJob{}, fmt.Errorf("limit must be >= 0")
+	}
+	req.Path = strings.TrimSpace(req.Path)
+	req.Mode = strings.TrimSpace(req.Mode)
+	req.Model = strings.TrimSpace(req.Model)
+
+	runs := r.selectRuns(req)
+	job := &RescoreJob{
+		ID:           fmt.Sprintf("rescore_%d_%x", time.Now().Unix(), r.counter.Add(1)),
+		Status:       RescoreStatusRunning,
+		JudgeVersion: r.scorer.Version(),
+		Request:      req,
+		Total:        len(runs),
+		CreatedAt:    time.Now().UTC(),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	r.mu.Lock()
+	r.jobs[job.ID] = job
+	r.order = append(r.order, job.ID)
+	r.cancels[job.ID] = cancel
+	r.pruneLocked()
+	out := cloneRescoreJob(job)
+	r.mu.Unlock()
+
+	go r.run(ctx, job, runs)
+	return out, nil
+}
+
+func (r *Rescorer) Get(id string) (RescoreJob, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	job, ok := r.jobs[strings.TrimSpace(id)]
+	if !ok {
+		return RescoreJob{}, false
+	}
+	return cloneRescoreJob(job), true
+}
+
+// List returns jobs newest first.
+func (r *Rescorer) List() []RescoreJob {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]RescoreJob, 0, len(r.order))
+	for i := len(r.order) - 1; i >= 0; i-- {
+		if job, ok := r.jobs[r.order[i]]; ok {
+			out = append(out, cloneRescoreJob(job))
+		}
+	}
+	return out
+}
+
+// Cancel stops a running job; runs scored so far keep their new scores.
+func (r *Rescorer) Cancel(id string) (RescoreJob, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	id = strings.TrimSpace(id)
+	job, ok := r.jobs[id]
+	if !ok {
+		return RescoreJob{}, false
+	}
+	if cancel := r.cancels[id]; cancel != nil {
+		cancel()
+	}
+	return cloneRescoreJob(job), true
+}
+
+func (r *Rescorer) selectRuns(req RescoreRequest) []ccrun.Run {
+	all := r.runs.List(ccrun.ListFilter{Status: string(ccrun.StatusCompleted), Path: req.Path})
+	out := make([]ccrun.Run, 0, len(all))
+	for _, run := range all {
+		if !req.Since.IsZero() && run.CreatedAt.Before(req.Since) {
+			continue
+		}
+		if !req.Until.IsZero() && !run.CreatedAt.Before(req.Until) {
+			continue
+		}
+		if req.Mode != "" && run.Mode != req.Mode {
+			continue
+		}
+		if req.Model != "" && run.UpstreamModel != req.Model && run.ClientModel != req.Model {
+			continue
+		}
+		out = append(out, run)
+		if req.Limit > 0 && len(out) >= req.Limit {
+			break
+		}
+	}
+	return out
+}
+
+func (r *Rescorer) run(ctx context.Context, job *RescoreJob, runs []ccrun.Run) {
+	version := job.JudgeVersion
+	summary := map[string][]float64{}
+	status := RescoreStatusCompleted
+	for _, run := range runs {
+		if ctx.Err() != nil {
+			status = RescoreStatusCancelled
+			break
+		}
+		existing, hasCurrent := scoreForVersion(run.Scores, version)
+		for _, sc := range run.Scores {
+			if sc.JudgeVersion != version {
+				summary[sc.JudgeVersion] = append(summary[sc.JudgeVersion], sc.Score)
+			}
+		}
+		if strings.TrimSpace(run.OutputText) == "" || (hasCurrent && !job.Request.Force) {
+			if hasCurrent {
+				summary[version] = append(summary[version], existing.Score)
+			}
+			r.update(job.ID, func(j *RescoreJob) { j.Skipped++ })
+			continue
+		}
+		model := run.UpstreamModel
+		if model == "" {
+			model = run.ClientModel
+		}
+		result, err := r.scorer.Evaluate(ctx, model, run.PromptText, run.OutputText)
+		if err == nil {
+			_, err = r.runs.RecordScore(run.ID, ccrun.Score{
+				JudgeVersion: version,
+				Score:        result.Score,
+				Criteria:     result.Criteria,
+				Analysis:     result.Analysis,
+				JobID:        job.ID,
+			})
+		}
+		if err != nil {
+			msg := fmt.Sprintf("%s: %v", run.ID, err)
+			r.update(job.ID, func(j *RescoreJob) {
+				j.Failed++
+				if len(j.Errors) < maxRescoreErrors {
+					j.Errors = append(j.Errors, msg)
+				}
+			})
+			continue
+		}
+		summary[version] = append(summary[version], result.Score)
+		r.update(job.ID, func(j *RescoreJob) { j.Scored++ })
+	}
+
+	now := time.Now().UTC()
+	r.update(job.ID, func(j *RescoreJob) {
+		j.Status = status
+		j.CompletedAt = &now
+		j.Summary = summarizeScores(summary)
+	})
+	r.mu.Lock()
+	if cancel := r.cancels[job.ID]; cancel != nil {
+		cancel()
+		delete(r.cancels, job.ID)
+	}
+	r.mu.Unlock()
+}
+
+func (r *Rescorer) update(id string, fn func(*RescoreJob)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if job, ok := r.jobs[id]; ok {
+		fn(job)
+	}
+}
+
+// pruneLocked drops the oldest finished jobs beyond maxRescoreJobs.
+func (r *Rescorer) pruneLocked() {
+	for len(r.order) > maxRescoreJobs {
+		dropped := false
+		for i, id := range r.order {
+			if r.jobs[id].Status != RescoreStatusRunning {
+				delete(r.jobs, id)
+				r.order = append(r.order[:i], r.order[i+1:]...)
+				dropped = true
+				break
+			}
+		}
+		if !dropped {
+			return
+		}
+	}
+}
+
+func scoreForVersion(scores []ccrun.Score, version string) (ccrun.Score, bool) {
+	for _, sc := range scores {
+		if sc.JudgeVersion == version {
+			return sc, true
+		}
+	}
+	return ccrun.Score{}, false
+}
+
+func summarizeScores(in map[string][]float64) map[string]ScoreSummary {
+	out := make(map[string]ScoreSummary, len(in))
+	for version, scores := range in {
+		if len(scores) == 0 {
+			continue
+		}
+		total := 0.0
+		for _, v := range scores {
+			total += v
+		}
+		out[version] = ScoreSummary{
+			Count: len(scores),
+			Mean:  math.Round(total/float64(len(scores))*100) / 100,
+		}
+	}
+	return out
+}
+
+func cloneRescoreJob(in *RescoreJob) RescoreJob {
+	out := *in
+	out.Errors = append([]string(nil), in.Errors...)
+	if in.Summary != nil {
+		out.Summary = make(map[string]ScoreSummary, len(in.Summary))
+		for k, v := range in.Summary {
+			out.Summary[k] = v
+		}
+	}
+	if in.CompletedAt != nil {
+		t := *in.CompletedAt
+		out.CompletedAt = &t
+	}
+	return out
+}
@@ -0,0 +1,273 @@
+package featureflag
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Built-in flags consulted by the gateway. Each guards one subsystem and is
+// on unless an operator turns it off.
+const (
+	ToolLoop           = "tool_loop"
+	Reflection         = "reflection"
+	Judge              = "judge"
+	ParallelCandidates = "parallel_candidates"
+	ToolsCache         = "tools_cache"
+)
+
+var (
+	ErrNotFound    = errors.New("feature flag not found")
+	ErrBuiltIn     = errors.New("built-in feature flags cannot be deleted")
+	ErrInvalidName = errors.New("feature flag name must be 1-64 chars of [a-z0-9_.-]")
+)
+
+var builtIns = map[string]string{
+	ToolLoop:           "gateway-side tool loop, tool emulation and tool-support fallback",
+	Reflection:         "reflection passes over upstream responses",
+	Judge:              "response judge when several candidates run",
+	ParallelCandidates: "fan-out to more than one upstream candidate",
+	ToolsCache:         "MCP tools/list cache used for tool injection",
+}
+
+// Flag is one switch. Enabled is the default for requests that do not
+// override it. Killed is the kill switch: the flag reads as off everywhere
+// and per-request overrides are ignored until it is cleared.
+type Flag struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Enabled     bool      `json:"enabled"`
+	Killed      bool      `json:"killed"`
+	BuiltIn     bool      `json:"built_in"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Store holds the flag set. It is safe for concurrent use.
+type Store struct {
+	mu    sync.RWMutex
+	flags map[string]Flag
+}
+
+// NewStore returns a store with every built-in flag enabled.
+func NewStore() *Store {
+	now := time.Now().UTC()
+	s := &Store{flags: make(map[string]Flag, len(builtIns))}
+	for name, desc := range builtIns {
+		s.flags[name] = Flag{Name: name, Description: desc, Enabled: true, BuiltIn: true, UpdatedAt: now}
+	}
+	return s
+}
+
+// NewFromEnv seeds the store from FEATURE_FLAGS ("name=on,other=off") and
+// FEATURE_KILL_SWITCHES ("judge,reflection"). Unknown names create custom
+// flags.
+func NewFromEnv() (*Store, error) {
+	s := NewStore()
+	if raw := strings.TrimSpace(os.Getenv("FEATURE_FLAGS")); raw != "" {
+		for _, item := range strings.Split(raw, ",") {
+			item = strings.TrimSpace(item)
+			if item == "" {
+				continue
+			}
+			name, value, ok := strings.Cut(item, "=")
+			if !ok {
+				return nil, fmt.Errorf("invalid FEATURE_FLAGS entry %q: want name=on|off", item)
+			}
+			enabled, err := parseSwitch(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid FEATURE_FLAGS entry %q: %v", item, err)
+			}
+			if _, err := s.upsert(name, func(f *Flag) { f.Enabled = enabled }); err != nil {
+				return nil, fmt.Errorf("invalid FEATURE_FLAGS entry %q: %w", item, err)
+			}
+		}
+	}
+	if raw := strings.TrimSpace(os.Getenv("FEATURE_KILL_SWITCHES")); raw != "" {
+		for _, name := range strings.Split(raw, ",") {
+			if strings.TrimSpace(name) == "" {
+				continue
+			}
+			if _, err := s.upsert(name, func(f *Flag) { f.Killed = true }); err != nil {
+				return nil, fmt.Errorf("invalid FEATURE_KILL_SWITCHES entry %q: %w", name, err)
+			}
+		}
+	}
+	return s, nil
+}
+
+// List returns all flags sorted by name.
+func (s *Store) List() []Flag {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]Flag, 0, len(s.flags))
+	for _, f := range s.flags {
+		out = append(out, f)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+func (s *Store) Get(name string) (Flag, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	f, ok := s.flags[normalizeName(name)]
+	return f, ok
+}
+
+// Put creates or replaces a flag's enabled/killed state and description.
+// Built-in flags keep their built-in marker.
+func (s *Store) Put(in Flag) (Flag, error) {
+	return s.upsert(in.Name, func(f *Flag) {
+		f.Enabled = in.Enabled
+		f.Killed = in.Killed
+		if desc := strings.TrimSpace(in.Description); desc != "" {
+			f.Description = desc
+		}
+	})
+}
+
+// SetKilled flips the kill switch of an existing flag.
+func (s *Store) SetKilled(name string, killed bool) (Flag, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := normalizeName(name)
+	f, ok := s.flags[key]
+	if !ok {
+		return Flag{}, ErrNotFound
+	}
+	f.Killed = killed
+	f.UpdatedAt = time.Now().UTC()
+	s.flags[key] = f
+	return f, nil
+}
+
+// Delete removes a custom flag.
+func (s *Store) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := normalizeName(name)
+	f, ok := s.flags[key]
+	if !ok {
+		return ErrNotFound
+	}
+	if f.BuiltIn {
+		return ErrBuiltIn
+	}
+	delete(s.flags, key)
+	return nil
+}
+
+// Enabled reports whether name is on for a request carrying overrides.
+// Killed flags are always off; unknown flags are off unless overridden.
+func (s *Store) Enabled(name string, overrides map[string]bool) bool {
+	key := normalizeName(name)
+	s.mu.RLock()
+	f, ok := s.flags[key]
+	s.mu.RUnlock()
+	if ok && f.Killed {
+		return false
+	}
+	if v, has := overrides[key]; has {
+		return v
+	}
+	return ok && f.Enabled
+}
+
+// Resolve returns the effective value of every known flag plus any
+// overridden unknown flags.
+func (s *Store) Resolve(overrides map[string]bool) map[string]bool {
+	s.mu.RLock()
+	out := make(map[string]bool, len(s.flags)+len(overrides))
+	killed := make(map[string]bool)
+	for name, f := range s.flags {
+		out[name] = f.Enabled && !f.Killed
+		if f.Killed {
+			killed[name] = true
+		}
+	}
+	s.mu.RUnlock()
+	for name, v := range overrides {
+		if killed[name] {
+			continue
+		}
+		out[name] = v
+	}
+	return out
+}
+
+// OverridesFromAny reads per-request overrides from a metadata value such
+// as {"judge": false, "reflection": "on"}. Unparseable entries are dropped.
+func OverridesFromAny(v any) map[string]bool {
+	raw, ok := v.(map[string]any)
+	if !ok || len(raw) == 0 {
+		return nil
+	}
+	out := make(map[string]bool, len(raw))
+	for name, value := range raw {
+		key := normalizeName(name)
+		if !validName(key) {
+			continue
+		}
+		switch x := value.(type) {
+		case bool:
+			out[key] = x
+		case string:
+			if b, err := parseSwitch(x); err == nil {
+				out[key] = b
+			}
+		case float64:
+			out[key] = x != 0
+		}
+	}
+	return out
+}
+
+func (s *Store) upsert(name string, apply func(*Flag)) (Flag, error) {
+	key := normalizeName(name)
+	if !validName(key) {
+		return Flag{}, ErrInvalidName
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, ok := s.flags[key]
+	if !ok {
+		f = Flag{Name: key}
+	}
+	apply(&f)
+	f.UpdatedAt = time.Now().UTC()
+	s.flags[key] = f
+	return f, nil
+}
+
+func parseSwitch(raw string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(raw)) {
+	case "on", "enabled", "enable", "yes":
+		return true, nil
+	case "off", "disabled", "disable", "no":
+		return false, nil
+	}
+	return strconv.ParseBool(strings.TrimSpace(raw))
+}
+
+func normalizeName(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+func validName(name string) bool {
+	if name == "" || len(name) > 64 {
+		return false
+	}
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '_', r == '.', r == '-':
+		default:
+			return false
+		}
+	}
+	return true
+}
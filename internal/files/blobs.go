@@ -0,0 +1,127 @@
+package files
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"ccgateway/internal/storage"
+)
+
+// MemoryBlobs keeps objects in process memory; they are lost on restart.
+type MemoryBlobs struct {
+	mu   sync.RWMutex
+	data map[string][]byte
+}
+
+func NewMemoryBlobs() *MemoryBlobs {
+	return &MemoryBlobs{data: map[string][]byte{}}
+}
+
+func (m *MemoryBlobs) PutObject(_ context.Context, key string, data []byte, _ string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.data[key] = append([]byte(nil), data...)
+	return nil
+}
+
+func (m *MemoryBlobs) GetObject(_ context.Context, key string) ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	data, ok := m.data[key]
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+	return append([]byte(nil), data...), nil
+}
+
+func (m *MemoryBlobs) DeleteObject(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.data, key)
+	return nil
+}
+
+func (m *MemoryBlobs) List(_ context.Context, prefix string) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var keys []string
+	for k := range m.data {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	return keys, nil
+}
+
+// DirBlobs stores each object as a file in one directory.
+type DirBlobs struct {
+	dir string
+}
+
+func NewDirBlobs(dir string) (*DirBlobs, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create files dir: %w", err)
+	}
+	return &DirBlobs{dir: dir}, nil
+}
+
+func (d *DirBlobs) path(key string) (string, error) {
+	if key == "" || strings.ContainsAny(key, "/\\") || strings.HasPrefix(key, ".") {
+		return "", fmt.Errorf("invalid object key %q", key)
+	}
+	return filepath.Join(d.dir, key), nil
+}
+
+func (d *DirBlobs) PutObject(_ context.Context, key string, data []byte, _ string) error {
+	p, err := d.path(key)
+	if err != nil {
+		return err
+	}
+	tmp := p + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, p)
+}
+
+func (d *DirBlobs) GetObject(_ context.Context, key string) ([]byte, error) {
+	p, err := d.path(key)
+	if err != nil {
+		return nil, storage.ErrNotFound
+	}
+	data, err := os.ReadFile(p)
+	if os.IsNotExist(err) {
+		return nil, storage.ErrNotFound
+	}
+	return data, err
+}
+
+func (d *DirBlobs) DeleteObject(_ context.Context, key string) error {
+	p, err := d.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (d *DirBlobs) List(_ context.Context, prefix string) ([]string, error) {
+	entries, err := os.ReadDir(d.dir)
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for _, e := range entries {
+		name := e.Name()
+		if !e.IsDir() && strings.HasPrefix(name, prefix) && !strings.HasSuffix(name, ".tmp") {
+			keys = append(keys, name)
+		}
+	}
+	return keys, nil
+}
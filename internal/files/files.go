@@ -0,0 +1,409 @@
+// Package files stores uploaded files for /v1/files: metadata and content
+// live side by side in a pluggable blob store (memory, a local directory
+// or an S3-compatible bucket), and every file belongs to one owner.
+package files
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"ccgateway/internal/storage"
+)
+
+const (
+	defaultMaxBytes = 32 << 20
+	metaSuffix      = ".meta.json"
+)
+
+// DefaultAllowedTypes are the media types accepted when Config leaves
+// AllowedTypes empty. A trailing "/*" matches a whole family.
+var DefaultAllowedTypes = []string{
+	"image/*",
+	"text/*",
+	"application/pdf",
+	"application/json",
+	"application/jsonl",
+	"application/x-ndjson",
+}
+
+// Purposes lists the accepted purpose values; uploads without one get
+// user_data.
+var Purposes = []string{"user_data", "assistants", "batch", "vision"}
+
+var (
+	ErrNotFound        = errors.New("file not found")
+	ErrTooLarge        = errors.New("file exceeds the size limit")
+	ErrTypeNotAllowed  = errors.New("file type is not allowed")
+	ErrInvalidPurpose  = errors.New("purpose must be one of user_data, assistants, batch, vision")
+	ErrMissingFilename = errors.New("filename is required")
+)
+
+// File mirrors the OpenAI file object, plus the detected media type.
+type File struct {
+	ID        string `json:"id"`
+	Object    string `json:"object"`
+	Bytes     int64  `json:"bytes"`
+	CreatedAt int64  `json:"created_at"`
+	Filename  string `json:"filename"`
+	Purpose   string `json:"purpose"`
+	MimeType  string `json:"mime_type"`
+}
+
+// record is the persisted metadata of one file.
+type record struct {
+	File
+	Owner     string `json:"owner"`
+	ProjectID string `json:"project_id,omitempty"`
+}
+
+// Blobs is the byte store behind the service. *storage.S3Backend
+// implements it.
+type Blobs interface {
+	PutObject(ctx context.Context, key string, data []byte, contentType string) error
+	GetObject(ctx context.Context, key string) ([]byte, error)
+	DeleteObject(ctx context.Context, key string) error
+	List(ctx context.Context, prefix string) ([]string, error)
+}
+
+// Config bounds uploads. MaxBytes defaults to 32 MiB and AllowedTypes to
+// DefaultAllowedTypes.
+type Config struct {
+	MaxBytes     int64
+	AllowedTypes []string
+}
+
+// UploadInput is one file to store. MimeType may be empty or generic, in
+// which case it is inferred from the filename and content.
+type UploadInput struct {
+	Owner     string
+	ProjectID string
+	Filename  string
+	Purpose   string
+	MimeType  string
+	Data      []byte
+}
+
+// ListFilter pages List newest first, after the file with ID After.
+type ListFilter struct {
+	Owner   string
+	Purpose string
+	After   string
+	Limit   int
+}
+
+// Service is safe for concurrent use.
+type Service struct {
+	cfg   Config
+	blobs Blobs
+	seq   atomic.Uint64
+
+	mu    sync.Mutex
+	files map[string]record
+}
+
+// New returns a service over blobs, loading the metadata already stored.
+func New(cfg Config, blobs Blobs) (*Service, error) {
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = defaultMaxBytes
+	}
+	if len(cfg.AllowedTypes) == 0 {
+		cfg.AllowedTypes = DefaultAllowedTypes
+	}
+	s := &Service{cfg: cfg, blobs: blobs, files: map[string]record{}}
+	keys, err := blobs.List(context.Background(), "")
+	if err != nil {
+		return nil, fmt.Errorf("list files: %w", err)
+	}
+	for _, key := range keys {
+		if !strings.HasSuffix(key, metaSuffix) {
+			continue
+		}
+		if rec, err := s.loadRecord(context.Background(), strings.TrimSuffix(key, metaSuffix)); err == nil {
+			s.files[rec.ID] = rec
+		}
+	}
+	return s, nil
+}
+
+// NewFromEnv picks the store from FILES_STORAGE: memory (default), dir
+// (FILES_DIR), or s3 or gcs (FILES_S3_BUCKET, FILES_S3_PREFIX and the
+// shared bucket credentials). FILES_MAX_BYTES and FILES_ALLOWED_TYPES
+// (comma separated) bound uploads.
+func NewFromEnv() (*Service, error) {
+	var cfg Config
+	if raw := strings.TrimSpace(os.Getenv("FILES_MAX_BYTES")); raw != "" {
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid FILES_MAX_BYTES %q", raw)
+		}
+		cfg.MaxBytes = n
+	}
+	for _, t := range strings.Split(os.Getenv("FILES_ALLOWED_TYPES"), ",") {
+		if t = strings.ToLower(strings.TrimSpace(t)); t != "" {
+			cfg.AllowedTypes = append(cfg.AllowedTypes, t)
+		}
+	}
+	var blobs Blobs
+	switch kind := strings.ToLower(strings.TrimSpace(os.Getenv("FILES_STORAGE"))); kind {
+	case "", "memory":
+		blobs = NewMemoryBlobs()
+	case "dir":
+		dir := strings.TrimSpace(os.Getenv("FILES_DIR"))
+		if dir == "" {
+			return nil, fmt.Errorf("FILES_DIR is required when FILES_STORAGE=dir")
+		}
+		d, err := NewDirBlobs(dir)
+		if err != nil {
+			return nil, err
+		}
+		blobs = d
+	case "s3", "gcs":
+		b, err := storage.NewObjectBackendFromEnv(kind, os.Getenv("FILES_S3_BUCKET"), os.Getenv("FILES_S3_PREFIX"))
+		if err != nil {
+			return nil, err
+		}
+		blobs = b
+	default:
+		return nil, fmt.Errorf("unknown FILES_STORAGE %q", kind)
+	}
+	return New(cfg, blobs)
+}
+
+// Config returns the upload limits in effect.
+func (s *Service) Config() Config {
+	return s.cfg
+}
+
+// Upload validates and stores a file.
+func (s *Service) Upload(ctx context.Context, in UploadInput) (File, error) {
+	filename := path.Base(strings.ReplaceAll(strings.TrimSpace(in.Filename), "\\", "/"))
+	if filename == "" || filename == "." || filename == "/" {
+		return File{}, ErrMissingFilename
+	}
+	purpose := strings.TrimSpace(in.Purpose)
+	if purpose == "" {
+		purpose = "user_data"
+	}
+	if !validPurpose(purpose) {
+		return File{}, ErrInvalidPurpose
+	}
+	if int64(len(in.Data)) > s.cfg.MaxBytes {
+		return File{}, fmt.Errorf("%w (%d bytes)", ErrTooLarge, s.cfg.MaxBytes)
+	}
+	mimeType := DetectType(filename, in.MimeType, in.Data)
+	if !s.allowed(mimeType) {
+		return File{}, fmt.Errorf("%w: %s", ErrTypeNotAllowed, mimeType)
+	}
+
+	now := time.Now().UTC()
+	rec := record{
+		File: File{
+			ID:        fmt.Sprintf("file-%d%03d", now.UnixNano(), s.seq.Add(1)%1000),
+			Object:    "file",
+			Bytes:     int64(len(in.Data)),
+			CreatedAt: now.Unix(),
+			Filename:  filename,
+			Purpose:   purpose,
+			MimeType:  mimeType,
+		},
+		Owner:     in.Owner,
+		ProjectID: in.ProjectID,
+	}
+	if err := s.blobs.PutObject(ctx, rec.ID, in.Data, mimeType); err != nil {
+		return File{}, err
+	}
+	meta, _ := json.Marshal(rec)
+	if err := s.blobs.PutObject(ctx, rec.ID+metaSuffix, meta, "application/json"); err != nil {
+		_ = s.blobs.DeleteObject(ctx, rec.ID)
+		return File{}, err
+	}
+	s.mu.Lock()
+	s.files[rec.ID] = rec
+	s.mu.Unlock()
+	return rec.File, nil
+}
+
+// Get returns the metadata of owner's file id. Files uploaded through
+// another gateway sharing the store are found on first access.
+func (s *Service) Get(ctx context.Context, owner, id string) (File, error) {
+	s.mu.Lock()
+	rec, ok := s.files[id]
+	s.mu.Unlock()
+	if !ok {
+		loaded, err := s.loadRecord(ctx, id)
+		if err != nil {
+			return File{}, ErrNotFound
+		}
+		s.mu.Lock()
+		s.files[id] = loaded
+		s.mu.Unlock()
+		rec = loaded
+	}
+	if rec.Owner != owner {
+		return File{}, ErrNotFound
+	}
+	return rec.File, nil
+}
+
+// Content returns a file's bytes and metadata.
+func (s *Service) Content(ctx context.Context, owner, id string) ([]byte, File, error) {
+	f, err := s.Get(ctx, owner, id)
+	if err != nil {
+		return nil, File{}, err
+	}
+	data, err := s.blobs.GetObject(ctx, id)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, File{}, ErrNotFound
+	}
+	if err != nil {
+		return nil, File{}, err
+	}
+	return data, f, nil
+}
+
+// Delete removes owner's file id.
+func (s *Service) Delete(ctx context.Context, owner, id string) error {
+	if _, err := s.Get(ctx, owner, id); err != nil {
+		return err
+	}
+	if err := s.blobs.DeleteObject(ctx, id+metaSuffix); err != nil {
+		return err
+	}
+	_ = s.blobs.DeleteObject(ctx, id)
+	s.mu.Lock()
+	delete(s.files, id)
+	s.mu.Unlock()
+	return nil
+}
+
+// DeleteWhere removes every file whose owner and project match, and
+// returns how many were removed.
+func (s *Service) DeleteWhere(ctx context.Context, match func(owner, projectID string) bool) (int, error) {
+	s.mu.Lock()
+	var ids []string
+	for id, rec := range s.files {
+		if match(rec.Owner, rec.ProjectID) {
+			ids = append(ids, id)
+		}
+	}
+	s.mu.Unlock()
+	n := 0
+	for _, id := range ids {
+		if err := s.blobs.DeleteObject(ctx, id+metaSuffix); err != nil {
+			return n, err
+		}
+		_ = s.blobs.DeleteObject(ctx, id)
+		s.mu.Lock()
+		delete(s.files, id)
+		s.mu.Unlock()
+		n++
+	}
+	return n, nil
+}
+
+// List returns owner's files newest first.
+func (s *Service) List(filter ListFilter) (items []File, hasMore bool) {
+	s.mu.Lock()
+	all := make([]File, 0, len(s.files))
+	for _, rec := range s.files {
+		if rec.Owner == filter.Owner && (filter.Purpose == "" || rec.Purpose == filter.Purpose) {
+			all = append(all, rec.File)
+		}
+	}
+	s.mu.Unlock()
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].CreatedAt != all[j].CreatedAt {
+			return all[i].CreatedAt > all[j].CreatedAt
+		}
+		return all[i].ID > all[j].ID
+	})
+	start := 0
+	if filter.After != "" {
+		for i, f := range all {
+			if f.ID == filter.After {
+				start = i + 1
+				break
+			}
+		}
+	}
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 20
+	}
+	for _, f := range all[start:] {
+		if len(items) == limit {
+			return items, true
+		}
+		items = append(items, f)
+	}
+	return items, false
+}
+
+func (s *Service) loadRecord(ctx context.Context, id string) (record, error) {
+	if strings.ContainsAny(id, "/\\") || strings.HasPrefix(id, ".") {
+		return record{}, ErrNotFound
+	}
+	data, err := s.blobs.GetObject(ctx, id+metaSuffix)
+	if err != nil {
+		return record{}, err
+	}
+	var rec record
+	if err := json.Unmarshal(data, &rec); err != nil || rec.ID != id {
+		return record{}, ErrNotFound
+	}
+	return rec, nil
+}
+
+func (s *Service) allowed(mimeType string) bool {
+	for _, pattern := range s.cfg.AllowedTypes {
+		if pattern == "*/*" || pattern == mimeType {
+			return true
+		}
+		if family, ok := strings.CutSuffix(pattern, "/*"); ok && strings.HasPrefix(mimeType, family+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// DetectType returns the media type of an upload: the declared type when
+// it is specific, else the filename extension, else the content.
+func DetectType(filename, declared string, data []byte) string {
+	if mt, _, err := mime.ParseMediaType(declared); err == nil && mt != "application/octet-stream" {
+		return mt
+	}
+	switch ext := strings.ToLower(path.Ext(filename)); ext {
+	case ".jsonl":
+		return "application/jsonl"
+	case ".md", ".markdown":
+		return "text/markdown"
+	case "":
+	default:
+		if mt, _, err := mime.ParseMediaType(mime.TypeByExtension(ext)); err == nil {
+			return mt
+		}
+	}
+	mt, _, _ := mime.ParseMediaType(http.DetectContentType(data))
+	return mt
+}
+
+func validPurpose(purpose string) bool {
+	for _, p := range Purposes {
+		if p == purpose {
+			return true
+		}
+	}
+	return false
+}
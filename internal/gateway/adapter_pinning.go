@@ -0,0 +1,122 @@
+package gateway
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"ccgateway/internal/token"
+	"ccgateway/internal/upstream"
+)
+
+// Request headers that override the upstream route of one request.
+const (
+	headerPinAdapter      = "x-cc-adapter"
+	headerExcludeAdapters = "x-cc-exclude-adapters"
+)
+
+// adapterOverrideError rejects an x-cc-adapter or x-cc-exclude-adapters
+// header.
+type adapterOverrideError struct {
+	status int
+	kind   string
+	msg    string
+}
+
+func (e *adapterOverrideError) Error() string { return e.msg }
+
+// applyAdapterOverride pins the request to the adapter named by
+// x-cc-adapter and drops the ones in x-cc-exclude-adapters from its route.
+// Both must name configured adapters the caller's token may route to. A
+// pin replaces channel and canary routes. It returns the adapters named,
+// for the policy check.
+func (s *server) applyAdapterOverride(r *http.Request, metadata map[string]any) (map[string]any, []string, error) {
+	pin := strings.TrimSpace(r.Header.Get(headerPinAdapter))
+	var exclude []string
+	for _, name := range strings.Split(r.Header.Get(headerExcludeAdapters), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			exclude = append(exclude, name)
+		}
+	}
+	if pin == "" && len(exclude) == 0 {
+		return metadata, nil, nil
+	}
+
+	var named []string
+	resolve := func(name, header string) (string, error) {
+		canonical, ok := s.canonicalAdapterName(name)
+		if !ok {
+			return "", &adapterOverrideError{http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("%s: unknown adapter %q", header, name)}
+		}
+		if tk, ok := r.Context().Value(tokenContextKey).(*token.Token); ok && tk != nil && !tk.CanRouteAdapter(canonical) {
+			return "", &adapterOverrideError{http.StatusForbidden, "permission_error", fmt.Sprintf("%s: token is not allowed to route to adapter %q", header, canonical)}
+		}
+		named = append(named, canonical)
+		return canonical, nil
+	}
+	if pin != "" {
+		canonical, err := resolve(pin, headerPinAdapter)
+		if err != nil {
+			return metadata, nil, err
+		}
+		pin = canonical
+	}
+	for i, name := range exclude {
+		canonical, err := resolve(name, headerExcludeAdapters)
+		if err != nil {
+			return metadata, nil, err
+		}
+		if canonical == pin {
+			return metadata, nil, &adapterOverrideError{http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("adapter %q is both pinned and excluded", pin)}
+		}
+		exclude[i] = canonical
+	}
+
+	out := make(map[string]any, len(metadata)+2)
+	for k, v := range metadata {
+		out[k] = v
+	}
+	if pin != "" {
+		out["routing_adapter_route"] = []string{pin}
+		out["routing_route_source"] = "pinned"
+		delete(out, "routing_channel_id")
+		delete(out, "canary_name")
+		delete(out, "canary_variant")
+		delete(out, "canary_cohort")
+	}
+	if len(exclude) > 0 {
+		out["routing_exclude_adapters"] = exclude
+	}
+	return out, named, nil
+}
+
+// writeAdapterOverrideError answers a rejected route override and returns
+// the status.
+func (s *server) writeAdapterOverrideError(w http.ResponseWriter, err error) int {
+	status, kind := http.StatusBadRequest, "invalid_request_error"
+	if oe, ok := err.(*adapterOverrideError); ok {
+		status, kind = oe.status, oe.kind
+	}
+	s.writeError(w, status, kind, err.Error())
+	return status
+}
+
+// canonicalAdapterName returns the configured spelling of adapter name.
+// Orchestrators that do not expose their adapters accept any name.
+func (s *server) canonicalAdapterName(name string) (string, bool) {
+	if s.orchestrator == nil {
+		return "", false
+	}
+	provider, ok := s.orchestrator.(interface {
+		GetUpstreamConfig() upstream.UpstreamAdminConfig
+	})
+	if !ok {
+		return name, true
+	}
+	for _, spec := range provider.GetUpstreamConfig().Adapters {
+		if strings.EqualFold(strings.TrimSpace(spec.Name), name) {
+			return strings.TrimSpace(spec.Name), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,145 @@
+package gateway
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"ccgateway/internal/analytics"
+	"ccgateway/internal/billing"
+	"ccgateway/internal/ccrun"
+)
+
+// analyticsBucketSteps are the bucket widths picked automatically: the
+// smallest that keeps the window within analyticsAutoBuckets buckets.
+var analyticsBucketSteps = []time.Duration{time.Minute, 5 * time.Minute, 15 * time.Minute, time.Hour, 6 * time.Hour, 24 * time.Hour}
+
+const analyticsAutoBuckets = 60
+
+// handleAdminAnalytics aggregates finished runs into time buckets for the
+// dashboard charts: requests, error rate, tokens, cost, latency percentiles
+// and tool-loop rounds, optionally split per model, adapter or mode.
+// GET /admin/analytics?window=24h|since=&until=&bucket=&group_by=&model=&adapter=&mode=
+func (s *server) handleAdminAnalytics(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	if s.runStore == nil {
+		s.writeError(w, http.StatusNotImplemented, "api_error", "run store is not configured")
+		return
+	}
+	if r.Method != http.MethodGet {
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+		return
+	}
+	values := r.URL.Query()
+	since, until, err := parseReportWindow(values)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	if until.IsZero() {
+		until = time.Now().UTC()
+	}
+	if since.IsZero() {
+		since = until.Add(-24 * time.Hour)
+	}
+	q := analytics.Query{
+		Since:   since,
+		Until:   until,
+		GroupBy: strings.ToLower(strings.TrimSpace(values.Get("group_by"))),
+	}
+	if raw := strings.TrimSpace(values.Get("bucket")); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d < time.Second {
+			s.writeError(w, http.StatusBadRequest, "invalid_request_error", "bucket must be a duration of at least 1s such as 1h")
+			return
+		}
+		q.Bucket = d
+	} else {
+		q.Bucket = autoAnalyticsBucket(until.Sub(since))
+	}
+	if _, err := q.Validate(); err != nil {
+		s.writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+
+	report, err := analytics.Aggregate(q, s.analyticsSamples(since, until, values.Get("model"), values.Get("adapter"), values.Get("mode")))
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(report)
+}
+
+func autoAnalyticsBucket(window time.Duration) time.Duration {
+	// window=1h arrives a few microseconds long, since the bounds are
+	// taken from separate clock reads.
+	window = window.Round(time.Second)
+	for _, step := range analyticsBucketSteps {
+		if window <= step*analyticsAutoBuckets {
+			return step
+		}
+	}
+	return analyticsBucketSteps[len(analyticsBucketSteps)-1]
+}
+
+// analyticsSamples turns the finished runs created in [since, until) into
+// samples, taking token counts from the usage ledger when one is
+// configured.
+func (s *server) analyticsSamples(since, until time.Time, model, adapter, mode string) []analytics.Sample {
+	model, adapter, mode = strings.TrimSpace(model), strings.TrimSpace(adapter), strings.TrimSpace(mode)
+	tokens := map[string][2]int64{}
+	if s.usageLedger != nil {
+		records, _ := s.usageLedger.List(billing.UsageQuery{Since: since, Until: until})
+		for _, rec := range records {
+			if rec.RunID == "" {
+				continue
+			}
+			t := tokens[rec.RunID]
+			t[0] += int64(rec.InputTokens)
+			t[1] += int64(rec.OutputTokens)
+			tokens[rec.RunID] = t
+		}
+	}
+	var samples []analytics.Sample
+	for _, run := range s.runStore.List(ccrun.ListFilter{}) {
+		if run.Status == ccrun.StatusRunning || run.CreatedAt.Before(since) || !run.CreatedAt.Before(until) {
+			continue
+		}
+		runModel := run.UpstreamModel
+		if runModel == "" {
+			runModel = run.RequestedModel
+		}
+		if runModel == "" {
+			runModel = run.ClientModel
+		}
+		if model != "" && run.ClientModel != model && run.RequestedModel != model && run.UpstreamModel != model {
+			continue
+		}
+		if adapter != "" && !strings.EqualFold(run.Adapter, adapter) {
+			continue
+		}
+		if mode != "" && run.Mode != mode {
+			continue
+		}
+		rounds, _ := parseInt(run.Metadata["tool_loop_rounds"])
+		t := tokens[run.ID]
+		samples = append(samples, analytics.Sample{
+			Time:           run.CreatedAt,
+			Model:          runModel,
+			Adapter:        run.Adapter,
+			Mode:           run.Mode,
+			Failed:         run.Status == ccrun.StatusFailed || run.StatusCode >= 400,
+			LatencyMS:      run.DurationMS,
+			InputTokens:    t[0],
+			OutputTokens:   t[1],
+			CostUSD:        run.CostUSD,
+			ToolLoopRounds: rounds,
+		})
+	}
+	return samples
+}
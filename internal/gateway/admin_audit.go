@@ -0,0 +1,246 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"ccgateway/internal/auditlog"
+	"ccgateway/internal/ipaccess"
+)
+
+const (
+	maxAuditBodyBytes     = 64 << 10
+	maxAuditSnapshotBytes = 4 << 20
+)
+
+// AdminAuditLog records admin mutations and serves them back for review.
+type AdminAuditLog interface {
+	Append(entry auditlog.Entry) (auditlog.Entry, error)
+	List(q auditlog.Query) ([]auditlog.Entry, int)
+}
+
+// withAdminAudit writes every PUT/POST/PATCH/DELETE under /admin/ and the
+// one-api management API to the audit log. Where the same path answers GET with JSON, the state is read
+// before and after the mutation so the entry carries a field-level diff.
+func (s *server) withAdminAudit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.adminAudit == nil || !isAdminMutation(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		body := captureAuditBody(r)
+		before, snapshot := s.adminStateSnapshot(next, r)
+
+		started := time.Now()
+		aw := &auditResponseWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(aw, r)
+
+		entry := auditlog.Entry{
+			Timestamp:   started.UTC(),
+			Actor:       auditlog.ActorID(auditActorToken(r)),
+			ClientIP:    requestClientIP(r),
+			Method:      r.Method,
+			Path:        r.URL.Path,
+			Query:       r.URL.RawQuery,
+			Status:      aw.status,
+			DurationMS:  time.Since(started).Milliseconds(),
+			RequestBody: body,
+		}
+		if snapshot {
+			if after, ok := s.adminStateSnapshot(next, r); ok {
+				entry.Snapshot = true
+				entry.Changes = auditlog.Diff(before, after)
+			}
+		}
+		if _, err := s.adminAudit.Append(entry); err != nil {
+			s.logger.ErrorContext(r.Context(), "admin audit: failed to record", "method", r.Method, "path", r.URL.Path, "error", err)
+		}
+	})
+}
+
+// auditActorToken is the admin token r presents, read the way the
+// endpoint it targets reads it.
+func auditActorToken(r *http.Request) string {
+	if strings.HasPrefix(r.URL.Path, "/api/") {
+		return oneAPIAdminToken(r)
+	}
+	return adminTokenFromRequest(r)
+}
+
+func isAdminMutation(r *http.Request) bool {
+	if !ipaccess.IsAdminPath(r.URL.Path) {
+		return false
+	}
+	switch r.Method {
+	case http.MethodPut, http.MethodPost, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// captureAuditBody returns the redacted JSON request body and leaves r.Body
+// readable for the handler. Oversized or non-JSON bodies are not recorded.
+func captureAuditBody(r *http.Request) any {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+	prefix, err := io.ReadAll(io.LimitReader(r.Body, maxAuditBodyBytes+1))
+	r.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(prefix), r.Body), Closer: r.Body}
+	if err != nil || len(prefix) == 0 || len(prefix) > maxAuditBodyBytes {
+		return nil
+	}
+	var v any
+	if err := json.Unmarshal(prefix, &v); err != nil {
+		return nil
+	}
+	return auditlog.Redact(v)
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// adminStateSnapshot replays r as a GET against the admin mux and returns
+// the decoded JSON response, if the endpoint has one.
+func (s *server) adminStateSnapshot(next http.Handler, r *http.Request) (any, bool) {
+	if strings.HasSuffix(r.URL.Path, "/stream") {
+		return nil, false
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	req := r.Clone(ctx)
+	req.Method = http.MethodGet
+	req.Body = http.NoBody
+	req.ContentLength = 0
+	req.Header.Del("content-type")
+	req.Header.Del("content-length")
+
+	sw := &snapshotWriter{header: http.Header{}, status: http.StatusOK}
+	next.ServeHTTP(sw, req)
+	if sw.status != http.StatusOK || sw.overflow {
+		return nil, false
+	}
+	if !strings.Contains(strings.ToLower(sw.header.Get("content-type")), "json") {
+		return nil, false
+	}
+	var v any
+	if err := json.Unmarshal(sw.body.Bytes(), &v); err != nil {
+		return nil, false
+	}
+	return v, true
+}
+
+type snapshotWriter struct {
+	header   http.Header
+	status   int
+	wrote    bool
+	body     bytes.Buffer
+	overflow bool
+}
+
+func (w *snapshotWriter) Header() http.Header { return w.header }
+
+func (w *snapshotWriter) WriteHeader(status int) {
+	if w.wrote {
+		return
+	}
+	w.wrote = true
+	w.status = status
+}
+
+func (w *snapshotWriter) Write(p []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	if w.body.Len()+len(p) > maxAuditSnapshotBytes {
+		w.overflow = true
+		return len(p), nil
+	}
+	return w.body.Write(p)
+}
+
+type auditResponseWriter struct {
+	http.ResponseWriter
+	status int
+	wrote  bool
+}
+
+func (w *auditResponseWriter) WriteHeader(status int) {
+	if !w.wrote {
+		w.wrote = true
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *auditResponseWriter) Write(p []byte) (int, error) {
+	if !w.wrote {
+		w.wrote = true
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *auditResponseWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+func (w *auditResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// handleAdminAudit pages through the admin audit log, newest first.
+// GET /admin/audit?path=&actor=&method=&since=&until=&limit=&cursor=|offset=
+func (s *server) handleAdminAudit(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	if s.adminAudit == nil {
+		s.writeError(w, http.StatusNotImplemented, "api_error", "admin audit log is not configured")
+		return
+	}
+	if r.Method != http.MethodGet {
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+		return
+	}
+	values := r.URL.Query()
+	page, err := parseAdminListQuery(values, adminAuditListSpec)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	q := auditlog.Query{
+		Path:   page.Filters["path"],
+		Actor:  page.Filters["actor"],
+		Method: page.Filters["method"],
+		Limit:  page.Limit,
+		Offset: page.Offset,
+	}
+	for _, bound := range []struct {
+		name string
+		dst  *time.Time
+	}{{"since", &q.Since}, {"until", &q.Until}} {
+		raw := strings.TrimSpace(values.Get(bound.name))
+		if raw == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, "invalid_request_error", bound.name+" must be an RFC3339 timestamp")
+			return
+		}
+		*bound.dst = t
+	}
+	entries, total := s.adminAudit.List(q)
+	s.writeAdminList(w, page, entries, len(entries), total)
+}
+
+// The audit log is paged newest first by the store itself.
+var adminAuditListSpec = adminListSpec{
+	DefaultLimit: 50,
+	MaxLimit:     500,
+	Filters:      []string{"path", "actor", "method", "since", "until"},
+}
@@ -0,0 +1,489 @@
+package gateway
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"ccgateway/internal/ccevent"
+	"ccgateway/internal/channel"
+	"ccgateway/internal/configfile"
+	"ccgateway/internal/mcpregistry"
+	"ccgateway/internal/settings"
+	"ccgateway/internal/toolcatalog"
+	"ccgateway/internal/upstream"
+)
+
+// handleAdminConfigReload re-reads the config file and applies it, the same
+// as sending SIGHUP. GET reports the last reload.
+// GET/POST /admin/config/reload
+func (s *server) handleAdminConfigReload(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	if s.configReloader == nil {
+		s.writeError(w, http.StatusNotImplemented, "api_error", "config file is not configured; set CONFIG_FILE")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("content-type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(s.configReloader.Status())
+	case http.MethodPost:
+		res, err := s.configReloader.Reload("admin_api")
+		if err != nil {
+			s.writeError(w, http.StatusUnprocessableEntity, "invalid_request_error", "config reload failed: "+err.Error())
+			return
+		}
+		w.Header().Set("content-type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(res)
+	default:
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+	}
+}
+
+// appendConfigReloadEvent records a config reload, whichever way it was
+// triggered.
+func (s *server) appendConfigReloadEvent(res configfile.Result) {
+	eventType := "config.reloaded"
+	if res.Error != "" {
+		eventType = "config.reload_failed"
+	}
+	s.appendEvent(ccevent.AppendInput{
+		EventType: eventType,
+		Data: map[string]any{
+			"path":          res.Path,
+			"trigger":       res.Trigger,
+			"applied":       res.Applied,
+			"env_overrides": res.EnvOverrides,
+			"error":         res.Error,
+		},
+	})
+}
+
+// configBundleVersion is bumped when the bundle layout changes
+// incompatibly; imports of newer bundles are refused.
+const configBundleVersion = 1
+
+// secretMask stands in for exported secrets. Importing it keeps the value
+// already configured for the same adapter, channel or MCP server.
+const secretMask = "***"
+
+// configBundle is the snapshot exchanged by /admin/config/export and
+// /admin/config/import. Sections left out of an import are not touched.
+type configBundle struct {
+	Version      int                           `json:"version"`
+	ExportedAt   *time.Time                    `json:"exported_at,omitempty"`
+	Settings     json.RawMessage               `json:"settings,omitempty"`
+	Upstream     *upstream.UpstreamAdminConfig `json:"upstream,omitempty"`
+	ModelMapping *modelMappingBundle           `json:"model_mapping,omitempty"`
+	Tools        []toolcatalog.ToolSpec        `json:"tools,omitempty"`
+	Channels     []configfile.Channel          `json:"channels,omitempty"`
+	MCPServers   []mcpregistry.RegisterInput   `json:"mcp_servers,omitempty"`
+}
+
+type modelMappingBundle struct {
+	ModelMappings    map[string]string `json:"model_mappings"`
+	ModelMapStrict   bool              `json:"model_map_strict"`
+	ModelMapFallback string            `json:"model_map_fallback"`
+}
+
+// configSectionReport is the outcome of one bundle section on import.
+type configSectionReport struct {
+	Section string `json:"section"`
+	Items   int    `json:"items"`
+	Status  string `json:"status"` // valid, invalid, applied, failed, not_applied
+	Error   string `json:"error,omitempty"`
+}
+
+type upstreamConfigAdmin interface {
+	UpdateUpstreamConfig(cfg upstream.UpstreamAdminConfig) (upstream.UpstreamAdminConfig, error)
+	ValidateUpstreamConfig(cfg upstream.UpstreamAdminConfig) error
+}
+
+// handleAdminConfigExport returns every configurable section as one bundle.
+// Secrets are masked unless include_secrets=true.
+// GET /admin/config/export?include_secrets=
+func (s *server) handleAdminConfigExport(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	if r.Method != http.MethodGet {
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+		return
+	}
+	includeSecrets := strings.EqualFold(strings.TrimSpace(r.URL.Query().Get("include_secrets")), "true")
+	now := time.Now().UTC()
+	bundle := configBundle{Version: configBundleVersion, ExportedAt: &now}
+	if s.settings != nil {
+		cfg := s.settings.Get()
+		bundle.Settings, _ = json.Marshal(cfg)
+		bundle.ModelMapping = &modelMappingBundle{
+			ModelMappings:    cfg.ModelMappings,
+			ModelMapStrict:   cfg.ModelMapStrict,
+			ModelMapFallback: cfg.ModelMapFallback,
+		}
+	}
+	if snap, ok := s.orchestrator.(interface {
+		UpstreamConfigSnapshot(maskSecrets bool) upstream.UpstreamAdminConfig
+	}); ok {
+		cfg := snap.UpstreamConfigSnapshot(!includeSecrets)
+		cfg.Offline = false
+		bundle.Upstream = &cfg
+	} else if get, ok := s.orchestrator.(interface {
+		GetUpstreamConfig() upstream.UpstreamAdminConfig
+	}); ok {
+		cfg := get.GetUpstreamConfig()
+		cfg.Offline = false
+		bundle.Upstream = &cfg
+	}
+	if s.toolCatalog != nil {
+		bundle.Tools = s.toolCatalog.Snapshot()
+	}
+	if s.channelStore != nil {
+		list := s.channelStore.ListChannels()
+		sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+		for _, c := range list {
+			entry := configfile.Channel{Channel: *c}
+			if c.Key != "" {
+				entry.Key = secretMask
+				if includeSecrets {
+					entry.Key = c.Key
+				}
+			}
+			bundle.Channels = append(bundle.Channels, entry)
+		}
+	}
+	if s.mcpRegistry != nil {
+		for _, srv := range s.mcpRegistry.List(0) {
+			enabled := srv.Enabled
+			in := mcpregistry.RegisterInput{
+				ID:            srv.ID,
+				Name:          srv.Name,
+				Transport:     srv.Transport,
+				URL:           srv.URL,
+				Command:       srv.Command,
+				Args:          srv.Args,
+				Env:           srv.Env,
+				Headers:       srv.Headers,
+				TimeoutMS:     srv.TimeoutMS,
+				Retries:       srv.Retries,
+				IdleTimeoutMS: srv.IdleTimeoutMS,
+				Enabled:       &enabled,
+				Metadata:      srv.Metadata,
+			}
+			if !includeSecrets {
+				in.Env = maskStringMap(in.Env)
+				in.Headers = maskStringMap(in.Headers)
+			}
+			bundle.MCPServers = append(bundle.MCPServers, in)
+		}
+	}
+	w.Header().Set("content-type", "application/json")
+	w.Header().Set("content-disposition", `attachment; filename="cc-gateway-config.json"`)
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(bundle)
+}
+
+// handleAdminConfigImport validates a bundle and, unless dry_run=true,
+// applies it. Every section is checked before any is applied, so an
+// invalid bundle changes nothing.
+// POST /admin/config/import?dry_run=
+func (s *server) handleAdminConfigImport(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	if r.Method != http.MethodPost {
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+		return
+	}
+	var bundle configBundle
+	if err := decodeJSONBodyStrict(r, &bundle, false); err != nil {
+		s.reportRequestDecodeIssue(r, err)
+		s.writeError(w, http.StatusBadRequest, "invalid_request_error", "invalid JSON body")
+		return
+	}
+	if bundle.Version > configBundleVersion {
+		s.writeError(w, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("bundle version %d is newer than supported version %d", bundle.Version, configBundleVersion))
+		return
+	}
+	dryRun := strings.EqualFold(strings.TrimSpace(r.URL.Query().Get("dry_run")), "true")
+
+	plan, reports := s.planConfigImport(bundle)
+	valid := true
+	for _, rep := range reports {
+		if rep.Status == "invalid" {
+			valid = false
+		}
+	}
+	status := http.StatusOK
+	if valid && !dryRun {
+		s.applyConfigImport(plan, reports)
+		for _, rep := range reports {
+			if rep.Status == "failed" {
+				valid = false
+			}
+		}
+		sections := make([]string, 0, len(reports))
+		for _, rep := range reports {
+			if rep.Status == "applied" {
+				sections = append(sections, rep.Section)
+			}
+		}
+		s.appendEvent(ccevent.AppendInput{
+			EventType: "config.imported",
+			Data:      map[string]any{"sections": sections, "ok": valid},
+		})
+	}
+	if !valid && !dryRun {
+		status = http.StatusUnprocessableEntity
+	}
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"dry_run":  dryRun,
+		"valid":    valid,
+		"sections": reports,
+	})
+}
+
+// configImportPlan holds the validated sections ready to apply.
+type configImportPlan struct {
+	settings *settings.RuntimeSettings
+	upstream *upstream.UpstreamAdminConfig
+	tools    []toolcatalog.ToolSpec
+	channels []channel.Channel
+	mcp      []mcpregistry.RegisterInput
+}
+
+func (s *server) planConfigImport(b configBundle) (configImportPlan, []*configSectionReport) {
+	var plan configImportPlan
+	var reports []*configSectionReport
+	report := func(section string, items int, err error) {
+		rep := &configSectionReport{Section: section, Items: items, Status: "valid"}
+		if err != nil {
+			rep.Status = "invalid"
+			rep.Error = err.Error()
+		}
+		reports = append(reports, rep)
+	}
+
+	if len(b.Settings) > 0 || b.ModelMapping != nil {
+		var err error
+		switch {
+		case s.settings == nil:
+			err = errors.New("settings store is not configured")
+		case len(b.Settings) > 0:
+			var parsed settings.RuntimeSettings
+			if parsed, err = settings.Parse(b.Settings); err == nil {
+				plan.settings = &parsed
+			}
+		default:
+			cur := s.settings.Get()
+			plan.settings = &cur
+		}
+		if err == nil && b.ModelMapping != nil {
+			plan.settings.ModelMappings = b.ModelMapping.ModelMappings
+			plan.settings.ModelMapStrict = b.ModelMapping.ModelMapStrict
+			plan.settings.ModelMapFallback = strings.TrimSpace(b.ModelMapping.ModelMapFallback)
+		}
+		if len(b.Settings) > 0 {
+			report("settings", 1, err)
+		}
+		if b.ModelMapping != nil {
+			report("model_mapping", len(b.ModelMapping.ModelMappings), err)
+		}
+	}
+
+	if b.Upstream != nil {
+		admin, ok := s.orchestrator.(upstreamConfigAdmin)
+		var err error
+		if !ok {
+			err = errors.New("orchestrator does not support upstream admin config")
+		} else if err = admin.ValidateUpstreamConfig(*b.Upstream); err == nil {
+			plan.upstream = b.Upstream
+		}
+		report("upstream", len(b.Upstream.Adapters), err)
+	}
+
+	if b.Tools != nil {
+		var err error
+		if s.toolCatalog == nil {
+			err = errors.New("tool catalog is not configured")
+		}
+		for i, t := range b.Tools {
+			if err == nil && strings.TrimSpace(t.Name) == "" {
+				err = fmt.Errorf("tools[%d]: name is required", i)
+			}
+		}
+		if err == nil {
+			plan.tools = b.Tools
+		}
+		report("tools", len(b.Tools), err)
+	}
+
+	if b.Channels != nil {
+		var err error
+		if s.channelStore == nil {
+			err = errors.New("channel store is not configured")
+		}
+		for i, c := range b.Channels {
+			if err != nil {
+				break
+			}
+			switch {
+			case c.ID <= 0:
+				err = fmt.Errorf("channels[%d]: id must be positive", i)
+			case strings.TrimSpace(c.Name) == "":
+				err = fmt.Errorf("channels[%d]: name is required", i)
+			}
+			ch := c.Resolve()
+			if ch.Key == secretMask {
+				ch.Key = ""
+			}
+			plan.channels = append(plan.channels, ch)
+		}
+		if err != nil {
+			plan.channels = nil
+		}
+		report("channels", len(b.Channels), err)
+	}
+
+	if b.MCPServers != nil {
+		var err error
+		if s.mcpRegistry == nil {
+			err = errors.New("mcp registry is not configured")
+		}
+		for i, in := range b.MCPServers {
+			if err != nil {
+				break
+			}
+			if strings.TrimSpace(in.ID) == "" {
+				err = fmt.Errorf("mcp_servers[%d]: id is required", i)
+			} else if verr := mcpregistry.ValidateRegisterInput(in); verr != nil {
+				err = fmt.Errorf("mcp_servers[%d]: %w", i, verr)
+			}
+		}
+		if err == nil {
+			plan.mcp = b.MCPServers
+		}
+		report("mcp_servers", len(b.MCPServers), err)
+	}
+	return plan, reports
+}
+
+// applyConfigImport applies a validated plan, upstream first since it is
+// the section most likely to be refused at apply time.
+func (s *server) applyConfigImport(plan configImportPlan, reports []*configSectionReport) {
+	mark := func(section string, err error) {
+		for _, rep := range reports {
+			if rep.Section != section {
+				continue
+			}
+			rep.Status = "applied"
+			if err != nil {
+				rep.Status = "failed"
+				rep.Error = err.Error()
+			}
+		}
+	}
+	if plan.upstream != nil {
+		_, err := s.orchestrator.(upstreamConfigAdmin).UpdateUpstreamConfig(*plan.upstream)
+		mark("upstream", err)
+		if err != nil {
+			for _, rep := range reports {
+				if rep.Status == "valid" {
+					rep.Status = "not_applied"
+				}
+			}
+			return
+		}
+	}
+	if plan.settings != nil {
+		s.settings.Put(*plan.settings)
+		mark("settings", nil)
+		mark("model_mapping", nil)
+	}
+	if plan.tools != nil {
+		s.toolCatalog.Replace(plan.tools)
+		mark("tools", nil)
+	}
+	if plan.channels != nil {
+		mark("channels", configfile.UpsertChannels(s.channelStore, plan.channels))
+	}
+	if plan.mcp != nil {
+		mark("mcp_servers", s.importMCPServers(plan.mcp))
+	}
+}
+
+// importMCPServers registers new servers and updates existing ones by id.
+// Masked env and header values keep the server's current value.
+func (s *server) importMCPServers(servers []mcpregistry.RegisterInput) error {
+	for _, in := range servers {
+		existing, ok := s.mcpRegistry.Get(in.ID)
+		if !ok {
+			in.Env = dropMasked(in.Env)
+			in.Headers = dropMasked(in.Headers)
+			if _, err := s.mcpRegistry.Register(in); err != nil {
+				return fmt.Errorf("%s: %w", in.ID, err)
+			}
+			continue
+		}
+		env := unmaskStringMap(in.Env, existing.Env)
+		headers := unmaskStringMap(in.Headers, existing.Headers)
+		args := in.Args
+		metadata := in.Metadata
+		if _, err := s.mcpRegistry.Update(in.ID, mcpregistry.UpdateInput{
+			Name:          &in.Name,
+			Transport:     &in.Transport,
+			URL:           &in.URL,
+			Command:       &in.Command,
+			Args:          &args,
+			Env:           &env,
+			Headers:       &headers,
+			TimeoutMS:     &in.TimeoutMS,
+			Retries:       &in.Retries,
+			IdleTimeoutMS: &in.IdleTimeoutMS,
+			Enabled:       in.Enabled,
+			Metadata:      &metadata,
+		}); err != nil {
+			return fmt.Errorf("%s: %w", in.ID, err)
+		}
+	}
+	return nil
+}
+
+func maskStringMap(in map[string]string) map[string]string {
+	if len(in) == 0 {
+		return in
+	}
+	out := make(map[string]string, len(in))
+	for k := range in {
+		out[k] = secretMask
+	}
+	return out
+}
+
+func unmaskStringMap(in, current map[string]string) map[string]string {
+	out := make(map[string]string, len(in))
+	for k, v := range in {
+		if v == secretMask {
+			if cur, ok := current[k]; ok {
+				out[k] = cur
+			}
+			continue
+		}
+		out[k] = v
+	}
+	return out
+}
+
+func dropMasked(in map[string]string) map[string]string {
+	return unmaskStringMap(in, nil)
+}
@@ -0,0 +1,180 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"ccgateway/internal/auditlog"
+	"ccgateway/internal/ccevent"
+	"ccgateway/internal/cron"
+)
+
+// handleAdminCron lists or creates scheduled jobs.
+// GET/POST /admin/cron
+func (s *server) handleAdminCron(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	if s.cron == nil {
+		s.writeError(w, http.StatusNotImplemented, "api_error", "cron is not configured")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		jobs := s.cron.List()
+		w.Header().Set("content-type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"data":  jobs,
+			"total": len(jobs),
+		})
+	case http.MethodPost:
+		var in cron.Input
+		if err := decodeJSONBodyStrict(r, &in, false); err != nil {
+			s.reportRequestDecodeIssue(r, err)
+			s.writeError(w, http.StatusBadRequest, "invalid_request_error", "invalid JSON body")
+			return
+		}
+		job, err := s.cron.Create(in)
+		if err != nil {
+			s.writeCronError(w, err)
+			return
+		}
+		s.appendCronEvent(r, "cron.created", job)
+		w.Header().Set("content-type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(job)
+	default:
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+	}
+}
+
+// handleAdminCronByPath manages one job.
+// GET/PUT/DELETE /admin/cron/{id}, POST /admin/cron/{id}/run
+func (s *server) handleAdminCronByPath(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	if s.cron == nil {
+		s.writeError(w, http.StatusNotImplemented, "api_error", "cron is not configured")
+		return
+	}
+	id, action, _ := strings.Cut(strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/cron/"), "/"), "/")
+	current, ok := s.cron.Get(id)
+	if !ok {
+		s.writeError(w, http.StatusNotFound, "not_found_error", cron.ErrNotFound.Error())
+		return
+	}
+	switch action {
+	case "":
+	case "run":
+		if r.Method != http.MethodPost {
+			s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+			return
+		}
+		// The execution outlives this request.
+		job, err := s.cron.Trigger(context.Background(), current.ID)
+		if err != nil {
+			s.writeCronError(w, err)
+			return
+		}
+		w.Header().Set("content-type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		_ = json.NewEncoder(w).Encode(job)
+		return
+	default:
+		s.writeError(w, http.StatusNotFound, "not_found_error", "unknown cron action")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("content-type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(current)
+	case http.MethodPut:
+		var in cron.Input
+		if err := decodeJSONBodyStrict(r, &in, false); err != nil {
+			s.reportRequestDecodeIssue(r, err)
+			s.writeError(w, http.StatusBadRequest, "invalid_request_error", "invalid JSON body")
+			return
+		}
+		job, err := s.cron.Update(current.ID, in)
+		if err != nil {
+			s.writeCronError(w, err)
+			return
+		}
+		s.appendCronEvent(r, "cron.updated", job)
+		w.Header().Set("content-type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(job)
+	case http.MethodDelete:
+		if err := s.cron.Delete(current.ID); err != nil {
+			s.writeCronError(w, err)
+			return
+		}
+		s.appendCronEvent(r, "cron.deleted", current)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+	}
+}
+
+func (s *server) writeCronError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, cron.ErrNotFound):
+		s.writeError(w, http.StatusNotFound, "not_found_error", err.Error())
+	case errors.Is(err, cron.ErrRunning):
+		s.writeError(w, http.StatusConflict, "invalid_request_error", err.Error())
+	case errors.Is(err, cron.ErrInvalidSchedule), errors.Is(err, cron.ErrInvalidTimezone),
+		errors.Is(err, cron.ErrInvalidPath), errors.Is(err, cron.ErrInvalidRequest):
+		s.writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+	default:
+		s.writeError(w, http.StatusInternalServerError, "api_error", err.Error())
+	}
+}
+
+func (s *server) appendCronEvent(r *http.Request, eventType string, job cron.Job) {
+	s.appendEvent(ccevent.AppendInput{
+		EventType: eventType,
+		Data: map[string]any{
+			"cron_job_id": job.ID,
+			"name":        job.Name,
+			"schedule":    job.Schedule,
+			"path":        job.Path,
+			"enabled":     job.Enabled,
+			"actor":       auditlog.ActorID(adminTokenFromRequest(r)),
+		},
+	})
+}
+
+// executeCronJob posts a job's request through the regular handler, so
+// the execution is recorded as a run like any client request.
+func (s *server) executeCronJob(ctx context.Context, job cron.Job) cron.Result {
+	runID, rec, ok := s.dispatchInternal(ctx, job.Path, job.Request, nil)
+	if !ok {
+		return cron.Result{StatusCode: http.StatusBadRequest, Error: cron.ErrInvalidPath.Error()}
+	}
+	res := cron.Result{RunID: runID, StatusCode: rec.status}
+	if rec.status >= 400 {
+		res.Error = asyncErrorMessage(rec.body.Bytes())
+		if res.Error == "" {
+			res.Error = http.StatusText(rec.status)
+		}
+	}
+	s.appendEvent(ccevent.AppendInput{
+		EventType: "cron.executed",
+		RunID:     runID,
+		Data: map[string]any{
+			"cron_job_id": job.ID,
+			"name":        job.Name,
+			"path":        job.Path,
+			"status":      res.StatusCode,
+			"error":       res.Error,
+		},
+	})
+	return res
+}
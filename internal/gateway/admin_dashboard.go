@@ -8,6 +8,7 @@ import (
 	"path"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
 //go:embed static/dashboard.html
@@ -95,7 +96,33 @@ func (s *server) handleAdminStatus(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	status := map[string]any{
-		"health": true,
+		"health":  true,
+		"offline": s.offlineStatus(),
+	}
+	if degradation := s.degradationStatus(); degradation != nil {
+		status["degradation"] = degradation
+	}
+	if shedding := s.loadSheddingStatus(); shedding != nil {
+		status["load_shedding"] = shedding
+	}
+	if admission := s.admissionStatus(); admission != nil {
+		status["admission"] = admission
+	}
+	if latency := s.streamLatencyStatus(); latency != nil {
+		status["stream_latency"] = latency
+	}
+	if s.persistence != nil {
+		persistence := map[string]any{"health": s.persistence.Health()}
+		if repairer, ok := s.persistence.(persistenceRepairer); ok {
+			persistence["repairs"] = repairer.Repairs()
+		}
+		status["persistence"] = persistence
+	}
+	if s.notifications != nil {
+		status["notifications"] = s.notifications.Counts()
+	}
+	if s.anomalies != nil {
+		status["anomalies"] = s.anomalies.Snapshot(time.Now())
 	}
 	if s.settings != nil {
 		status["settings"] = s.settings.Get()
@@ -106,6 +133,15 @@ func (s *server) handleAdminStatus(w http.ResponseWriter, r *http.Request) {
 	if s.probeStatus != nil {
 		status["probe"] = s.probeStatus.Snapshot()
 	}
+	if s.featureFlags != nil {
+		killed := []string{}
+		for _, f := range s.featureFlags.List() {
+			if f.Killed {
+				killed = append(killed, f.Name)
+			}
+		}
+		status["kill_switches"] = killed
+	}
 	if snapshot, err := s.buildAdminCapabilitiesSnapshot(r.Context(), "chat", "", false); err == nil {
 		if overview, ok := snapshot["overview"]; ok {
 			status["capabilities_overview"] = overview
@@ -0,0 +1,329 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"ccgateway/internal/billing"
+	"ccgateway/internal/ccevent"
+	"ccgateway/internal/ccrun"
+	"ccgateway/internal/requestctx"
+	"ccgateway/internal/runlog"
+	"ccgateway/internal/session"
+)
+
+// dataSubject is the user or project a data export or deletion is about.
+type dataSubject struct {
+	Kind string `json:"kind"` // user or project
+	ID   string `json:"id"`
+}
+
+func (d dataSubject) ownsRun(run ccrun.Run) bool {
+	if d.Kind == "user" {
+		return run.UserID == d.ID
+	}
+	return requestctx.NormalizeProjectID(run.ProjectID) == d.ID
+}
+
+// ownsResource matches caller-owned resources such as files and batches,
+// which carry the requestOwner scope and the request's project.
+func (d dataSubject) ownsResource(owner, projectID string) bool {
+	if d.Kind == "user" {
+		return owner == "user:"+d.ID
+	}
+	return projectID != "" && requestctx.NormalizeProjectID(projectID) == d.ID
+}
+
+func (d dataSubject) ownsSession(sess session.Session) bool {
+	if d.Kind == "user" {
+		return sess.UserID == d.ID
+	}
+	return requestctx.NormalizeProjectID(sess.ProjectID) == d.ID
+}
+
+// ownsUsage matches ledger records by owner, and by run for records
+// written before they carried a project.
+func (d dataSubject) ownsUsage(rec billing.UsageRecord, runIDs map[string]bool) bool {
+	if runIDs[rec.RunID] && rec.RunID != "" {
+		return true
+	}
+	if d.Kind == "user" {
+		return rec.UserID == d.ID
+	}
+	return rec.ProjectID != "" && rec.ProjectID == d.ID
+}
+
+// parseDataSubject reads exactly one of user_id or project_id.
+func (s *server) parseDataSubject(w http.ResponseWriter, r *http.Request) (dataSubject, bool) {
+	userID := strings.TrimSpace(r.URL.Query().Get("user_id"))
+	projectID := strings.TrimSpace(r.URL.Query().Get("project_id"))
+	switch {
+	case userID != "" && projectID == "":
+		return dataSubject{Kind: "user", ID: userID}, true
+	case projectID != "" && userID == "":
+		return dataSubject{Kind: "project", ID: requestctx.NormalizeProjectID(projectID)}, true
+	}
+	s.writeError(w, http.StatusBadRequest, "invalid_request_error", "exactly one of user_id or project_id is required")
+	return dataSubject{}, false
+}
+
+// subjectData is everything held about one subject.
+type subjectData struct {
+	Runs     []ccrun.Run           `json:"runs"`
+	Sessions []session.Session     `json:"sessions"`
+	Events   []ccevent.Event       `json:"events"`
+	Usage    []billing.UsageRecord `json:"usage"`
+}
+
+func (s *server) collectSubjectData(subject dataSubject) subjectData {
+	out := subjectData{
+		Runs:     []ccrun.Run{},
+		Sessions: []session.Session{},
+		Events:   []ccevent.Event{},
+		Usage:    []billing.UsageRecord{},
+	}
+	runIDs := map[string]bool{}
+	sessionIDs := map[string]bool{}
+	if s.runStore != nil {
+		for _, run := range s.runStore.List(ccrun.ListFilter{}) {
+			if subject.ownsRun(run) {
+				out.Runs = append(out.Runs, run)
+				runIDs[run.ID] = true
+			}
+		}
+	}
+	if s.sessionStore != nil {
+		for _, sess := range s.sessionStore.List(0) {
+			if subject.ownsSession(sess) {
+				out.Sessions = append(out.Sessions, sess)
+				sessionIDs[sess.ID] = true
+			}
+		}
+	}
+	if s.eventStore != nil {
+		for _, ev := range s.eventStore.List(ccevent.ListFilter{}) {
+			if runIDs[ev.RunID] || sessionIDs[ev.SessionID] {
+				out.Events = append(out.Events, ev)
+			}
+		}
+	}
+	if s.usageLedger != nil {
+		records, _ := s.usageLedger.List(billing.UsageQuery{})
+		for _, rec := range records {
+			if subject.ownsUsage(rec, runIDs) {
+				out.Usage = append(out.Usage, rec)
+			}
+		}
+	}
+	return out
+}
+
+// handleAdminDataExport returns all runs, sessions, events and usage
+// records held about a user or project.
+// GET /admin/data/export?user_id= | ?project_id=
+func (s *server) handleAdminDataExport(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	if r.Method != http.MethodGet {
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+		return
+	}
+	subject, ok := s.parseDataSubject(w, r)
+	if !ok {
+		return
+	}
+	data := s.collectSubjectData(subject)
+	s.appendEvent(ccevent.AppendInput{
+		EventType: "data.exported",
+		Data:      map[string]any{"subject_kind": subject.Kind, "subject_id": subject.ID},
+	})
+	w.Header().Set("content-type", "application/json")
+	w.Header().Set("content-disposition", `attachment; filename="`+subject.Kind+"-"+subject.ID+`-data.json"`)
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"subject":     subject,
+		"exported_at": time.Now().UTC(),
+		"runs":        data.Runs,
+		"sessions":    data.Sessions,
+		"events":      data.Events,
+		"usage":       data.Usage,
+	})
+}
+
+// handleAdminDataDelete erases a user's or project's data: its runs and
+// sessions, the events, run log entries, session memory, wire captures,
+// stream-resume buffers and judge verdicts attached to them, its uploaded
+// files and batches, and its usage ledger records. forget_key=true also
+// drops the project's own encryption key from this process; the operator
+// still has to remove it from DATA_ENCRYPTION_KEYS_JSON.
+// POST /admin/data/delete?user_id= | ?project_id=&forget_key=
+func (s *server) handleAdminDataDelete(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+		return
+	}
+	subject, ok := s.parseDataSubject(w, r)
+	if !ok {
+		return
+	}
+	forgetKey := strings.EqualFold(strings.TrimSpace(r.URL.Query().Get("forget_key")), "true")
+	if forgetKey && subject.Kind != "project" {
+		s.writeError(w, http.StatusBadRequest, "invalid_request_error", "forget_key applies to project_id only")
+		return
+	}
+
+	deleted := map[string]int{
+		"runs": 0, "sessions": 0, "events": 0, "usage_records": 0,
+		"run_log_entries": 0, "memories": 0, "files": 0, "batches": 0,
+		"wire_captures": 0, "stream_buffers": 0, "judge_verdicts": 0,
+	}
+	var unsupported []string
+	failed := map[string]string{}
+	// Everything keyed by run or session goes first, so that after a
+	// partial failure the runs and sessions are still there for a retry.
+	data := s.collectSubjectData(subject)
+	runIDs := map[string]bool{}
+	sessionIDs := map[string]bool{}
+	// memorySessions also covers sessions only named by the subject's runs.
+	memorySessions := map[string]bool{}
+	for _, run := range data.Runs {
+		runIDs[run.ID] = true
+		if run.SessionID != "" {
+			memorySessions[run.SessionID] = true
+		}
+	}
+	for _, sess := range data.Sessions {
+		sessionIDs[sess.ID] = true
+		memorySessions[sess.ID] = true
+	}
+	if s.eventStore != nil {
+		if store, ok := s.eventStore.(interface {
+			DeleteWhere(match func(ccevent.Event) bool) int
+		}); ok {
+			deleted["events"] = store.DeleteWhere(func(ev ccevent.Event) bool {
+				return runIDs[ev.RunID] || sessionIDs[ev.SessionID]
+			})
+		} else {
+			unsupported = append(unsupported, "events")
+		}
+	}
+	if s.usageLedger != nil {
+		n, err := s.usageLedger.DeleteWhere(func(rec billing.UsageRecord) bool {
+			return subject.ownsUsage(rec, runIDs)
+		})
+		if err != nil {
+			failed["usage_records"] = err.Error()
+		}
+		deleted["usage_records"] = n
+	}
+	if s.runLogger != nil {
+		if eraser, ok := s.runLogger.(runlog.Eraser); ok {
+			n, err := eraser.DeleteRuns(r.Context(), runIDs)
+			if err != nil {
+				failed["run_log_entries"] = err.Error()
+			}
+			deleted["run_log_entries"] = n
+		} else {
+			unsupported = append(unsupported, "run_log_entries")
+		}
+	}
+	if s.memoryStore != nil {
+		if store, ok := s.memoryStore.(interface {
+			DeleteSessions(ctx context.Context, sessionIDs map[string]bool) int
+			DeleteLongTermMemory(ctx context.Context, userID string) bool
+		}); ok {
+			deleted["memories"] = store.DeleteSessions(r.Context(), memorySessions)
+			if subject.Kind == "user" && store.DeleteLongTermMemory(r.Context(), subject.ID) {
+				deleted["memories"]++
+			}
+		} else {
+			unsupported = append(unsupported, "memories")
+		}
+	}
+	if s.files != nil {
+		n, err := s.files.DeleteWhere(r.Context(), subject.ownsResource)
+		if err != nil {
+			failed["files"] = err.Error()
+		}
+		deleted["files"] = n
+	}
+	if s.batches != nil {
+		deleted["batches"] = s.batches.DeleteWhere(subject.ownsResource)
+	}
+	deleted["wire_captures"] = s.wireCaptures.deleteRuns(runIDs)
+	deleted["stream_buffers"] = s.streamBuffers.deleteWhere(func(buf *streamBuffer) bool {
+		if runIDs[buf.runID] {
+			return true
+		}
+		if subject.Kind == "user" {
+			return buf.userID == subject.ID
+		}
+		return requestctx.NormalizeProjectID(buf.projectID) == subject.ID
+	})
+	if store, ok := s.orchestrator.(interface {
+		DeleteJudgeVerdicts(runIDs map[string]bool) (int, error)
+	}); ok {
+		n, err := store.DeleteJudgeVerdicts(runIDs)
+		if err != nil {
+			failed["judge_verdicts"] = err.Error()
+		}
+		deleted["judge_verdicts"] = n
+	}
+	if len(failed) == 0 && s.runStore != nil {
+		if store, ok := s.runStore.(interface {
+			DeleteWhere(match func(ccrun.Run) bool) []ccrun.Run
+		}); ok {
+			deleted["runs"] = len(store.DeleteWhere(subject.ownsRun))
+		} else {
+			unsupported = append(unsupported, "runs")
+		}
+	}
+	if len(failed) == 0 && s.sessionStore != nil {
+		if store, ok := s.sessionStore.(interface {
+			DeleteWhere(match func(session.Session) bool) []session.Session
+		}); ok {
+			deleted["sessions"] = len(store.DeleteWhere(subject.ownsSession))
+		} else {
+			unsupported = append(unsupported, "sessions")
+		}
+	}
+	keyForgotten := false
+	if forgetKey && s.dataKeys != nil {
+		keyForgotten = s.dataKeys.ForgetProject(subject.ID)
+	}
+
+	s.appendEvent(ccevent.AppendInput{
+		EventType: "data.deleted",
+		Data: map[string]any{
+			"subject_kind":  subject.Kind,
+			"subject_id":    subject.ID,
+			"deleted":       deleted,
+			"key_forgotten": keyForgotten,
+		},
+	})
+	resp := map[string]any{
+		"subject":       subject,
+		"deleted":       deleted,
+		"key_forgotten": keyForgotten,
+	}
+	if len(unsupported) > 0 {
+		resp["unsupported"] = unsupported
+	}
+	// After a partial failure the runs and sessions are kept, so the same
+	// request can be retried to finish the rest.
+	status := http.StatusOK
+	if len(failed) > 0 {
+		resp["errors"] = failed
+		status = http.StatusInternalServerError
+	}
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,76 @@
+package gateway
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"ccgateway/internal/upstream"
+)
+
+// handleAdminDispatchAnalytics summarizes historical dispatch decisions and
+// scheduler elections: escalation rates, outcomes per target and classifier
+// level, election score deltas, and replays of MinScoreDifference and
+// long-context thresholds against the recorded traffic.
+// GET /admin/dispatch/analytics?since=&until=&window=
+func (s *server) handleAdminDispatchAnalytics(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	if r.Method != http.MethodGet {
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+		return
+	}
+	analyzer, ok := s.orchestrator.(interface {
+		DispatchAnalytics(q upstream.DispatchAnalyticsQuery) (upstream.DispatchAnalytics, error)
+	})
+	if !ok {
+		s.writeError(w, http.StatusNotImplemented, "api_error", "dispatch analytics are not supported by the orchestrator")
+		return
+	}
+	since, until, err := parseReportWindow(r.URL.Query())
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	q := upstream.DispatchAnalyticsQuery{Since: since, Until: until}
+	report, err := analyzer.DispatchAnalytics(q)
+	if err != nil {
+		s.writeError(w, http.StatusNotImplemented, "api_error", err.Error())
+		return
+	}
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(report)
+}
+
+// parseReportWindow reads the window (a duration back from now) and
+// since/until (RFC3339) query parameters shared by the admin reports;
+// since overrides window.
+func parseReportWindow(values url.Values) (since, until time.Time, err error) {
+	if raw := strings.TrimSpace(values.Get("window")); raw != "" {
+		d, perr := time.ParseDuration(raw)
+		if perr != nil || d <= 0 {
+			return since, until, errors.New("window must be a positive duration such as 24h")
+		}
+		since = time.Now().Add(-d)
+	}
+	for _, bound := range []struct {
+		name string
+		dst  *time.Time
+	}{{"since", &since}, {"until", &until}} {
+		raw := strings.TrimSpace(values.Get(bound.name))
+		if raw == "" {
+			continue
+		}
+		t, perr := time.Parse(time.RFC3339, raw)
+		if perr != nil {
+			return since, until, errors.New(bound.name + " must be an RFC3339 timestamp")
+		}
+		*bound.dst = t
+	}
+	return since, until, nil
+}
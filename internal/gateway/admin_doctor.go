@@ -0,0 +1,435 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"ccgateway/internal/upstream"
+)
+
+const (
+	doctorPass = "pass"
+	doctorWarn = "warn"
+	doctorFail = "fail"
+)
+
+const (
+	// doctorTimeout bounds the live calls of one doctor run.
+	doctorTimeout = 15 * time.Second
+	// Clock skew against the providers' Date headers, which only carry
+	// whole seconds.
+	doctorSkewWarn = 10 * time.Second
+	doctorSkewFail = time.Minute
+)
+
+// doctorCheck is one finding of GET /admin/doctor.
+type doctorCheck struct {
+	Name        string `json:"name"`
+	Category    string `json:"category"`
+	Status      string `json:"status"`
+	Message     string `json:"message"`
+	Remediation string `json:"remediation,omitempty"`
+}
+
+type doctorReport struct {
+	checks []doctorCheck
+}
+
+func (d *doctorReport) add(category, name, status, message, remediation string) {
+	d.checks = append(d.checks, doctorCheck{
+		Name:        name,
+		Category:    category,
+		Status:      status,
+		Message:     message,
+		Remediation: remediation,
+	})
+}
+
+// adapterCheckRunner is implemented by orchestrators that can make a live
+// call to each configured adapter.
+type adapterCheckRunner interface {
+	CheckAdapters(ctx context.Context) []upstream.AdapterCheck
+}
+
+// persistenceWriteChecker is implemented by persistence managers that can
+// prove their backend accepts writes.
+type persistenceWriteChecker interface {
+	CheckWritable() error
+}
+
+// handleAdminDoctor runs the self-diagnostics: adapters reachable and
+// accepting their keys, routes and model mappings pointing at adapters
+// that exist, MCP servers answering, persistence writable and the local
+// clock agreeing with the providers. Every check is pass, warn or fail
+// with a remediation hint; the response status is 200 either way so the
+// report itself is always readable.
+func (s *server) handleAdminDoctor(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	if r.Method != http.MethodGet {
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+		return
+	}
+	started := time.Now()
+	ctx, cancel := context.WithTimeout(r.Context(), doctorTimeout)
+	defer cancel()
+
+	var report doctorReport
+	var cfg upstream.UpstreamAdminConfig
+	provider, hasConfig := s.orchestrator.(interface {
+		GetUpstreamConfig() upstream.UpstreamAdminConfig
+	})
+	if hasConfig {
+		cfg = provider.GetUpstreamConfig()
+	}
+	adapterChecks := s.doctorAdapters(ctx, &report, cfg, hasConfig)
+	if hasConfig {
+		s.doctorRoutes(&report, cfg)
+		s.doctorModelMappings(&report, cfg)
+	}
+	s.doctorMCP(ctx, &report)
+	s.doctorPersistence(&report)
+	doctorClock(&report, adapterChecks)
+
+	summary := map[string]int{doctorPass: 0, doctorWarn: 0, doctorFail: 0}
+	overall := doctorPass
+	for _, c := range report.checks {
+		summary[c.Status]++
+		if c.Status == doctorFail || (c.Status == doctorWarn && overall == doctorPass) {
+			overall = c.Status
+		}
+	}
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"status":      overall,
+		"summary":     summary,
+		"checks":      report.checks,
+		"checked_at":  started.UTC(),
+		"duration_ms": time.Since(started).Milliseconds(),
+	})
+}
+
+func (s *server) doctorAdapters(ctx context.Context, report *doctorReport, cfg upstream.UpstreamAdminConfig, hasConfig bool) []upstream.AdapterCheck {
+	if s.offlineStatus().Enabled {
+		report.add("adapters", "adapters", doctorPass, "offline mode: adapters answer from fixtures and are not called", "")
+		return nil
+	}
+	runner, ok := s.orchestrator.(adapterCheckRunner)
+	if !ok {
+		report.add("adapters", "adapters", doctorWarn, "the orchestrator does not expose its adapters for checking", "configure upstream adapters with UPSTREAM_ADAPTERS_JSON or PUT /admin/upstream")
+		return nil
+	}
+	specs := map[string]upstream.AdapterSpec{}
+	if hasConfig {
+		for _, spec := range cfg.Adapters {
+			specs[spec.Name] = spec
+		}
+	}
+	checks := runner.CheckAdapters(ctx)
+	if len(checks) == 0 {
+		report.add("adapters", "adapters", doctorFail, "no upstream adapters are configured", "add adapters with UPSTREAM_ADAPTERS_JSON or PUT /admin/upstream")
+		return nil
+	}
+	for _, c := range checks {
+		name := "adapter:" + c.Adapter
+		if spec, ok := specs[c.Adapter]; ok && spec.APIKey == "" {
+			if env := strings.TrimSpace(spec.APIKeyEnv); env != "" && strings.TrimSpace(os.Getenv(env)) == "" {
+				report.add("keys", name, doctorFail,
+					fmt.Sprintf("api_key_env %s of adapter %s is not set", env, c.Adapter),
+					fmt.Sprintf("export %s in the gateway environment or set api_key on the adapter", env))
+				continue
+			}
+		}
+		if !c.Checkable {
+			report.add("adapters", name, doctorWarn,
+				fmt.Sprintf("adapter %s cannot be checked without a real completion", c.Adapter),
+				"watch its results in GET /admin/probe, or give http adapters a health_check")
+			continue
+		}
+		if c.Error != "" {
+			report.add("adapters", name, doctorFail,
+				fmt.Sprintf("cannot reach %s: %s", c.URL, c.Error),
+				"check base_url, DNS, TLS and the egress policy (GET /admin/egress) for this host")
+			continue
+		}
+		latency := fmt.Sprintf("%d in %dms", c.StatusCode, c.LatencyMS)
+		switch {
+		case c.StatusCode == http.StatusUnauthorized || c.StatusCode == http.StatusForbidden:
+			report.add("adapters", name, doctorPass, fmt.Sprintf("%s answered %s", c.URL, latency), "")
+			report.add("keys", name, doctorFail,
+				fmt.Sprintf("adapter %s key was rejected with %d", c.Adapter, c.StatusCode),
+				"replace api_key (or the variable named by api_key_env) of this adapter with a valid key")
+		case c.StatusCode == http.StatusTooManyRequests:
+			report.add("adapters", name, doctorWarn,
+				fmt.Sprintf("%s answered %s: the provider is rate limiting this key", c.URL, latency),
+				"lower traffic to this adapter, set max_concurrency, or add keys to spread the load")
+		case c.StatusCode >= 500:
+			report.add("adapters", name, doctorWarn,
+				fmt.Sprintf("%s answered %s: the provider is failing", c.URL, latency),
+				"check the provider's status page; requests fall back to the next adapter on the route")
+		case c.StatusCode >= 400:
+			report.add("adapters", name, doctorPass, fmt.Sprintf("%s answered %s", c.URL, latency), "")
+			report.add("keys", name, doctorWarn,
+				fmt.Sprintf("adapter %s key could not be verified: the check endpoint answered %d", c.Adapter, c.StatusCode),
+				"give the adapter a health_check on an authenticated path the provider serves")
+		default:
+			report.add("adapters", name, doctorPass, fmt.Sprintf("%s answered %s", c.URL, latency), "")
+			report.add("keys", name, doctorPass, fmt.Sprintf("adapter %s key was accepted", c.Adapter), "")
+		}
+	}
+	return checks
+}
+
+func (s *server) doctorRoutes(report *doctorReport, cfg upstream.UpstreamAdminConfig) {
+	known := map[string]struct{}{}
+	for _, spec := range cfg.Adapters {
+		known[strings.TrimSpace(spec.Name)] = struct{}{}
+	}
+	sources := map[string][]string{}
+	if route := cleanRouteLocal(cfg.DefaultRoute); len(route) > 0 {
+		sources["upstream.default_route"] = route
+	}
+	for model, route := range cfg.ModelRoutes {
+		sources["upstream.model_routes."+model] = cleanRouteLocal(route)
+	}
+	if s.settings != nil {
+		for mode, route := range s.settings.Get().Routing.ModeRoutes {
+			sources["runtime.routing.mode_routes."+mode] = cleanRouteLocal(route)
+		}
+	}
+	if len(sources) == 0 {
+		report.add("routes", "routes", doctorWarn, "no route is configured; requests use every adapter in configuration order",
+			"set upstream default_route or model_routes with PUT /admin/upstream")
+		return
+	}
+	names := make([]string, 0, len(sources))
+	for source := range sources {
+		names = append(names, source)
+	}
+	sort.Strings(names)
+	broken := false
+	for _, source := range names {
+		var missing []string
+		for _, adapter := range sources[source] {
+			if _, ok := known[adapter]; !ok {
+				missing = append(missing, adapter)
+			}
+		}
+		if len(missing) > 0 {
+			broken = true
+			report.add("routes", "route:"+source, doctorFail,
+				fmt.Sprintf("%s references unknown adapters: %s", source, strings.Join(missing, ", ")),
+				"remove them from the route or add adapters with these names")
+		}
+	}
+	if !broken {
+		report.add("routes", "routes", doctorPass, fmt.Sprintf("%d routes reference existing adapters", len(sources)), "")
+	}
+}
+
+func (s *server) doctorModelMappings(report *doctorReport, cfg upstream.UpstreamAdminConfig) {
+	if s.settings == nil {
+		return
+	}
+	settingsCfg := s.settings.Get()
+	targets := map[string]string{}
+	for from, to := range settingsCfg.ModelMappings {
+		if to = strings.TrimSpace(to); to != "" {
+			targets[from] = to
+		}
+	}
+	if fallback := strings.TrimSpace(settingsCfg.ModelMapFallback); fallback != "" {
+		targets["(fallback)"] = fallback
+	}
+	if len(targets) == 0 {
+		return
+	}
+	specs := map[string]upstream.AdapterSpec{}
+	for _, spec := range cfg.Adapters {
+		specs[strings.TrimSpace(spec.Name)] = spec
+	}
+	// Model lists the providers reported earlier; nothing is fetched here.
+	advertised := map[string]map[string]struct{}{}
+	if lister, ok := s.orchestrator.(interface {
+		CapabilitySnapshot() []upstream.CapabilityStatus
+	}); ok {
+		for _, caps := range lister.CapabilitySnapshot() {
+			if caps.Error != "" {
+				continue
+			}
+			models := make(map[string]struct{}, len(caps.Models))
+			for _, m := range caps.Models {
+				models[m] = struct{}{}
+			}
+			for _, adapter := range caps.Adapters {
+				advertised[adapter] = models
+			}
+		}
+	}
+
+	froms := make([]string, 0, len(targets))
+	for from := range targets {
+		froms = append(froms, from)
+	}
+	sort.Strings(froms)
+	unresolved := 0
+	for _, from := range froms {
+		to := targets[from]
+		name := "model_mapping:" + from
+		route, _ := resolveRouteByModelWithSource(cfg, to)
+		if len(route) == 0 {
+			route = make([]string, 0, len(cfg.Adapters))
+			for _, spec := range cfg.Adapters {
+				route = append(route, spec.Name)
+			}
+		}
+		var existing []string
+		for _, adapter := range route {
+			if _, ok := specs[adapter]; ok {
+				existing = append(existing, adapter)
+			}
+		}
+		if len(existing) == 0 {
+			unresolved++
+			report.add("model_mappings", name, doctorFail,
+				fmt.Sprintf("%s maps to %s, which no configured adapter serves", from, to),
+				fmt.Sprintf("add upstream model_routes for %s or map %s to a routed model", to, from))
+			continue
+		}
+		if !modelAdvertised(to, existing, specs, advertised) {
+			unresolved++
+			report.add("model_mappings", name, doctorWarn,
+				fmt.Sprintf("%s maps to %s, which none of %s list among their models", from, to, strings.Join(existing, ", ")),
+				"check the target model name against GET /admin/upstream/capabilities")
+		}
+	}
+	if unresolved == 0 {
+		report.add("model_mappings", "model_mappings", doctorPass, fmt.Sprintf("%d model mappings resolve to adapters", len(targets)), "")
+	}
+}
+
+// modelAdvertised reports whether model is served by one of adapters:
+// pinned through the adapter's model, listed by its provider, or unknown
+// because the provider's model list has not been fetched.
+func modelAdvertised(model string, adapters []string, specs map[string]upstream.AdapterSpec, advertised map[string]map[string]struct{}) bool {
+	for _, adapter := range adapters {
+		if strings.TrimSpace(specs[adapter].Model) != "" {
+			return true
+		}
+		models, ok := advertised[adapter]
+		if !ok {
+			return true
+		}
+		if _, ok := models[model]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *server) doctorMCP(ctx context.Context, report *doctorReport) {
+	if s.mcpRegistry == nil {
+		return
+	}
+	servers := s.mcpRegistry.List(0)
+	checked := 0
+	for _, server := range servers {
+		if !server.Enabled {
+			continue
+		}
+		checked++
+		name := "mcp:" + server.ID
+		updated, err := s.mcpRegistry.CheckHealth(ctx, server.ID)
+		if err == nil && !updated.Status.Healthy {
+			err = fmt.Errorf("%s", updated.Status.LastError)
+		}
+		if err != nil {
+			report.add("mcp", name, doctorFail,
+				fmt.Sprintf("MCP server %s does not respond: %v", server.Name, err),
+				"check its url or command, then reconnect it with POST /v1/cc/mcp/servers/{id}/reconnect or disable it")
+			continue
+		}
+		report.add("mcp", name, doctorPass, fmt.Sprintf("MCP server %s responded in %dms", server.Name, updated.Status.LastLatencyMS), "")
+	}
+	if checked == 0 {
+		report.add("mcp", "mcp", doctorPass, "no MCP servers are enabled", "")
+	}
+}
+
+func (s *server) doctorPersistence(report *doctorReport) {
+	if s.persistence == nil {
+		report.add("persistence", "persistence", doctorWarn, "state persistence is disabled; runs, plans and todos are lost on restart",
+			"set STATE_PERSIST_DIR to keep state across restarts")
+		return
+	}
+	if checker, ok := s.persistence.(persistenceWriteChecker); ok {
+		if err := checker.CheckWritable(); err != nil {
+			report.add("persistence", "persistence", doctorFail, "state cannot be written: "+err.Error(),
+				"check permissions and free space of the state directory or bucket")
+			return
+		}
+	}
+	health := s.persistence.Health()
+	switch {
+	case health.Degraded:
+		report.add("persistence", "persistence", doctorFail,
+			fmt.Sprintf("persistence is degraded after %d failed saves: %s", health.ConsecutiveFailures, health.LastError),
+			"fix the storage error, then POST /admin/persistence/repairs")
+	case health.PendingRepairs > 0:
+		report.add("persistence", "persistence", doctorWarn,
+			fmt.Sprintf("%d stores are waiting to be written again", health.PendingRepairs),
+			"POST /admin/persistence/repairs to retry them now")
+	default:
+		report.add("persistence", "persistence", doctorPass, "state is writable", "")
+	}
+}
+
+// doctorClock compares the local clock with the providers' Date headers.
+// The median skew is used so one provider with a wrong clock does not
+// condemn the gateway's.
+func doctorClock(report *doctorReport, checks []upstream.AdapterCheck) {
+	var skews []time.Duration
+	for _, c := range checks {
+		if c.ServerDate.IsZero() || c.ReceivedAt.IsZero() {
+			continue
+		}
+		// The Date header was set about halfway through the round trip.
+		local := c.ReceivedAt.Add(-time.Duration(c.LatencyMS) * time.Millisecond / 2)
+		skews = append(skews, local.Sub(c.ServerDate))
+	}
+	if len(skews) == 0 {
+		report.add("clock", "clock_skew", doctorWarn, "no provider returned a Date header, so clock skew was not measured",
+			"make sure the host keeps time with NTP")
+		return
+	}
+	sort.Slice(skews, func(i, j int) bool { return skews[i] < skews[j] })
+	skew := skews[len(skews)/2]
+	abs := skew
+	if abs < 0 {
+		abs = -abs
+	}
+	msg := fmt.Sprintf("local clock is %s %s the providers (median of %d)", abs.Round(time.Second), aheadOrBehind(skew), len(skews))
+	switch {
+	case abs >= doctorSkewFail:
+		report.add("clock", "clock_skew", doctorFail, msg, "sync the host clock with NTP; signed requests and token expiry depend on it")
+	case abs >= doctorSkewWarn:
+		report.add("clock", "clock_skew", doctorWarn, msg, "sync the host clock with NTP")
+	default:
+		report.add("clock", "clock_skew", doctorPass, msg, "")
+	}
+}
+
+func aheadOrBehind(skew time.Duration) string {
+	if skew < 0 {
+		return "behind"
+	}
+	return "ahead of"
+}
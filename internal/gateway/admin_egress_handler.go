@@ -0,0 +1,43 @@
+package gateway
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"ccgateway/internal/egress"
+)
+
+// handleAdminEgress reads or patches the outbound egress policy applied to
+// tool executors, MCP clients and marketplace fetches.
+// GET/PUT /admin/egress
+func (s *server) handleAdminEgress(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	if s.egressPolicy == nil {
+		s.writeError(w, http.StatusNotImplemented, "api_error", "egress policy is not configured")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var patch egress.ConfigPatch
+		if err := decodeJSONBodyStrict(r, &patch, false); err != nil {
+			s.reportRequestDecodeIssue(r, err)
+			s.writeError(w, http.StatusBadRequest, "invalid_request_error", "invalid JSON body")
+			return
+		}
+		if _, err := s.egressPolicy.UpdateConfigPatch(patch); err != nil {
+			s.writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+			return
+		}
+	default:
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+		return
+	}
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"egress": s.egressPolicy.Snapshot(),
+	})
+}
@@ -0,0 +1,217 @@
+package gateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"ccgateway/internal/ccevent"
+)
+
+// maxEventStreamBacklog caps how many past events a new firehose
+// connection may ask to replay.
+const maxEventStreamBacklog = 1000
+
+type eventPruner interface {
+	DeleteBefore(before time.Time, eventType string) int
+}
+
+type eventReplayer interface {
+	After(cursor string, filter ccevent.ListFilter) ([]ccevent.Event, bool)
+}
+
+var adminEventListSpec = adminListSpec{
+	DefaultLimit: 100,
+	MaxLimit:     1000,
+	SortKeys:     []string{"created_at"},
+	DefaultSort:  "-created_at",
+	Filters:      []string{"event_type", "session_id", "run_id", "plan_id", "todo_id", "team_id", "subagent_id"},
+}
+
+// handleAdminEvents pages through stored events with GET /admin/events
+// (see adminEventListSpec) and serves
+// DELETE /admin/events?before=RFC3339[&event_type=] for manual cleanup on
+// top of the store's retention limits.
+func (s *server) handleAdminEvents(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	if r.Method == http.MethodGet {
+		s.handleAdminEventList(w, r)
+		return
+	}
+	if r.Method != http.MethodDelete {
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+		return
+	}
+	pruner, ok := s.eventStore.(eventPruner)
+	if !ok {
+		s.writeError(w, http.StatusNotImplemented, "api_error", "event store does not support deletion")
+		return
+	}
+	raw := strings.TrimSpace(r.URL.Query().Get("before"))
+	if raw == "" {
+		s.writeError(w, http.StatusBadRequest, "invalid_request_error", "before is required")
+		return
+	}
+	before, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, "invalid_request_error", "before must be an RFC3339 timestamp")
+		return
+	}
+	eventType := strings.TrimSpace(r.URL.Query().Get("event_type"))
+	deleted := pruner.DeleteBefore(before, eventType)
+	resp := map[string]any{
+		"deleted": deleted,
+		"before":  before.UTC(),
+	}
+	if eventType != "" {
+		resp["event_type"] = eventType
+	}
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func (s *server) handleAdminEventList(w http.ResponseWriter, r *http.Request) {
+	if s.eventStore == nil {
+		s.writeError(w, http.StatusNotImplemented, "api_error", "event store is not configured")
+		return
+	}
+	q, err := parseAdminListQuery(r.URL.Query(), adminEventListSpec)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	events := s.eventStore.List(ccevent.ListFilter{
+		EventType:  q.Filters["event_type"],
+		SessionID:  q.Filters["session_id"],
+		RunID:      q.Filters["run_id"],
+		PlanID:     q.Filters["plan_id"],
+		TodoID:     q.Filters["todo_id"],
+		TeamID:     q.Filters["team_id"],
+		SubagentID: q.Filters["subagent_id"],
+	})
+	sort.SliceStable(events, q.order(map[string]func(i, j int) bool{
+		"created_at": func(i, j int) bool { return events[i].CreatedAt.Before(events[j].CreatedAt) },
+	}))
+	start, end := q.window(len(events))
+	s.writeAdminList(w, q, events[start:end], end-start, len(events))
+}
+
+// handleAdminEventsStream tails every gateway event over SSE:
+// GET /admin/events/stream?event_type=&session_id=&run_id=&backlog=N
+// Each event carries its id, so a client that reconnects with the
+// Last-Event-ID header (or ?cursor=) gets what it missed before the live
+// tail resumes. Without a cursor, backlog replays the newest N events.
+func (s *server) handleAdminEventsStream(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	if s.eventStore == nil {
+		s.writeError(w, http.StatusNotImplemented, "api_error", "event store is not configured")
+		return
+	}
+	if r.Method != http.MethodGet {
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+		return
+	}
+	w, r, stopGuard := s.guardStream(w, r, commentPingFrame)
+	defer stopGuard()
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		s.writeError(w, http.StatusInternalServerError, "api_error", "streaming unsupported")
+		return
+	}
+	query := r.URL.Query()
+	backlog, ok := parseNonNegativeInt(query.Get("backlog"))
+	if !ok {
+		s.writeError(w, http.StatusBadRequest, "invalid_request_error", "backlog must be an integer >= 0")
+		return
+	}
+	backlog = min(backlog, maxEventStreamBacklog)
+	cursor := strings.TrimSpace(query.Get("cursor"))
+	if cursor == "" {
+		cursor = strings.TrimSpace(r.Header.Get("last-event-id"))
+	}
+	filter := ccevent.ListFilter{
+		EventType:  query.Get("event_type"),
+		SessionID:  query.Get("session_id"),
+		RunID:      query.Get("run_id"),
+		PlanID:     query.Get("plan_id"),
+		TodoID:     query.Get("todo_id"),
+		TeamID:     query.Get("team_id"),
+		SubagentID: query.Get("subagent_id"),
+	}
+
+	// Subscribe before replaying so nothing appended in between is lost;
+	// replayed ids are skipped when they also arrive live.
+	ch, cancel := s.eventStore.Subscribe(filter)
+	defer cancel()
+
+	var replay []ccevent.Event
+	expired := false
+	if cursor != "" {
+		replayer, ok := s.eventStore.(eventReplayer)
+		if !ok {
+			s.writeError(w, http.StatusNotImplemented, "api_error", "event store does not support resuming")
+			return
+		}
+		replay, ok = replayer.After(cursor, filter)
+		expired = !ok
+	} else if backlog > 0 {
+		filter.Limit = backlog
+		replay = s.eventStore.List(filter)
+		for i, j := 0, len(replay)-1; i < j; i, j = i+1, j-1 {
+			replay[i], replay[j] = replay[j], replay[i]
+		}
+	}
+
+	w.Header().Set("content-type", "text/event-stream")
+	w.Header().Set("cache-control", "no-cache")
+	w.Header().Set("connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	if expired {
+		_ = writeSSE(w, "cursor_expired", map[string]any{"cursor": cursor})
+	}
+	sent := make(map[string]struct{}, len(replay))
+	for _, ev := range replay {
+		if err := writeEventSSE(w, ev); err != nil {
+			return
+		}
+		sent[ev.ID] = struct{}{}
+	}
+	flusher.Flush()
+
+	for {
+		select {
+		case ev, ok := <-ch:
+			if !ok {
+				return
+			}
+			if _, dup := sent[ev.ID]; dup {
+				delete(sent, ev.ID)
+				continue
+			}
+			if err := writeEventSSE(w, ev); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// writeEventSSE writes one event with its id so EventSource clients resume
+// from it on reconnect.
+func writeEventSSE(w io.Writer, ev ccevent.Event) error {
+	if _, err := fmt.Fprintf(w, "id: %s\n", ev.ID); err != nil {
+		return err
+	}
+	return writeSSE(w, ev.EventType, ev)
+}
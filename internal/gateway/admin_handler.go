@@ -3,6 +3,7 @@ package gateway
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"path"
@@ -10,7 +11,9 @@ import (
 	"strings"
 	"time"
 
+	"ccgateway/internal/auditlog"
 	"ccgateway/internal/ccevent"
+	"ccgateway/internal/policy"
 	"ccgateway/internal/probe"
 	"ccgateway/internal/scheduler"
 	"ccgateway/internal/settings"
@@ -51,6 +54,10 @@ func (s *server) handleAdminSettings(w http.ResponseWriter, r *http.Request) {
 			s.writeError(w, http.StatusBadRequest, "invalid_request_error", "invalid JSON body")
 			return
 		}
+		if err := policy.ValidateRules(req.Policy.Rules); err != nil {
+			s.writeError(w, http.StatusBadRequest, "invalid_request_error", "policy: "+err.Error())
+			return
+		}
 		s.settings.Put(req)
 
 		// Propagate intelligent dispatch settings to dispatcher if available
@@ -159,6 +166,107 @@ func (s *server) handleAdminUpstream(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// handleAdminUpstreamStatus reports the adapter set version and, per adapter
+// instance, what the last apply did and whether retired instances are still
+// draining in-flight calls.
+func (s *server) handleAdminUpstreamStatus(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	if r.Method != http.MethodGet {
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+		return
+	}
+	statusProvider, ok := s.orchestrator.(interface {
+		ApplyStatus() upstream.UpstreamApplyStatus
+	})
+	if !ok {
+		s.writeError(w, http.StatusNotImplemented, "api_error", "orchestrator does not support upstream apply status")
+		return
+	}
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(statusProvider.ApplyStatus())
+}
+
+// handleAdminUpstreamConnections reports connection pool reuse per adapter.
+func (s *server) handleAdminUpstreamConnections(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	if r.Method != http.MethodGet {
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+		return
+	}
+	provider, ok := s.orchestrator.(interface {
+		ConnectionStats() []upstream.ConnectionStats
+	})
+	if !ok {
+		s.writeError(w, http.StatusNotImplemented, "api_error", "orchestrator does not support connection stats")
+		return
+	}
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(map[string]any{"adapters": provider.ConnectionStats()})
+}
+
+// handleAdminUpstreamCapabilities lists provider capabilities cached per
+// base_url. ?adapter= resolves one adapter, probing its provider on a miss
+// (refresh=true forces a new probe); DELETE drops ?base_url= or everything.
+func (s *server) handleAdminUpstreamCapabilities(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	provider, ok := s.orchestrator.(interface {
+		AdapterCapabilities(ctx context.Context, name string, refresh bool) (upstream.Capabilities, error)
+		CapabilitySnapshot() []upstream.CapabilityStatus
+		InvalidateCapabilities(baseURL string) int
+	})
+	if !ok {
+		s.writeError(w, http.StatusNotImplemented, "api_error", "orchestrator does not support capability detection")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		name := strings.TrimSpace(r.URL.Query().Get("adapter"))
+		if name == "" {
+			w.Header().Set("content-type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			_ = json.NewEncoder(w).Encode(map[string]any{"data": provider.CapabilitySnapshot()})
+			return
+		}
+		caps, err := provider.AdapterCapabilities(r.Context(), name, parseQueryBool(r.URL.Query().Get("refresh")))
+		if errors.Is(err, upstream.ErrUnknownAdapter) {
+			s.writeError(w, http.StatusNotFound, "not_found_error", err.Error())
+			return
+		}
+		if err != nil && caps.BaseURL == "" {
+			s.writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+			return
+		}
+		// A failed probe is still cached and reported with its error.
+		w.Header().Set("content-type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(caps)
+	case http.MethodDelete:
+		baseURL := strings.TrimSpace(r.URL.Query().Get("base_url"))
+		dropped := provider.InvalidateCapabilities(baseURL)
+		s.appendEvent(ccevent.AppendInput{
+			EventType: "upstream.capabilities_invalidated",
+			Data: map[string]any{
+				"base_url": baseURL,
+				"dropped":  dropped,
+				"actor":    auditlog.ActorID(adminTokenFromRequest(r)),
+			},
+		})
+		w.Header().Set("content-type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(map[string]any{"dropped": dropped})
+	default:
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+	}
+}
+
 func (s *server) handleAdminCapabilities(w http.ResponseWriter, r *http.Request) {
 	if !s.authorizeAdmin(w, r) {
 		return
@@ -235,7 +343,11 @@ func (s *server) handleAdminTools(w http.ResponseWriter, r *http.Request) {
 			})
 			return
 		}
+		before := s.toolCatalog.Snapshot()
 		s.toolCatalog.Replace(req.Tools)
+		for _, name := range s.trash.trackToolReplace(before, s.toolCatalog.Snapshot()) {
+			s.recordSoftDelete(r, "tool", name)
+		}
 		w.Header().Set("content-type", "application/json")
 		w.WriteHeader(http.StatusOK)
 		_ = json.NewEncoder(w).Encode(map[string]any{
@@ -508,6 +620,107 @@ func (s *server) handleAdminProbe(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// handleAdminIntelligence reports the intelligence evaluation schedule, the
+// scores the election ranks on and per adapter/model score trends.
+// GET /admin/intelligence?adapter=&model=&limit=
+func (s *server) handleAdminIntelligence(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	if r.Method != http.MethodGet {
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+		return
+	}
+	reporter, ok := s.probeStatus.(interface {
+		IntelligenceReport(adapter, model string, limit int) probe.IntelligenceReport
+	})
+	if !ok {
+		s.writeError(w, http.StatusNotImplemented, "api_error", "intelligence evaluation is not configured")
+		return
+	}
+	query := r.URL.Query()
+	limit := 50
+	if raw := strings.TrimSpace(query.Get("limit")); raw != "" {
+		n, ok := parseNonNegativeInt(raw)
+		if !ok {
+			s.writeError(w, http.StatusBadRequest, "invalid_request_error", "limit must be an integer >= 0")
+			return
+		}
+		limit = n
+	}
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(reporter.IntelligenceReport(query.Get("adapter"), query.Get("model"), limit))
+}
+
+type probeTaskManager interface {
+	IntelligenceTasks() probe.ProbeTaskSet
+	SetIntelligenceTasks(tasks []probe.ProbeTask) (probe.ProbeTaskSet, error)
+}
+
+// handleAdminProbeTasks reads or replaces the intelligence probe suite.
+// PUT with an empty task list restores the built-in questions; a new suite
+// is evaluated straight away when the schedule is running.
+// GET|PUT /admin/probe/tasks
+func (s *server) handleAdminProbeTasks(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	manager, ok := s.probeStatus.(probeTaskManager)
+	if !ok {
+		s.writeError(w, http.StatusNotImplemented, "api_error", "probe tasks are not supported")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("content-type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(manager.IntelligenceTasks())
+	case http.MethodPut:
+		var req struct {
+			Tasks []probe.ProbeTask `json:"tasks"`
+		}
+		if err := decodeJSONBodyStrict(r, &req, false); err != nil {
+			s.reportRequestDecodeIssue(r, err)
+			s.writeError(w, http.StatusBadRequest, "invalid_request_error", "invalid JSON body")
+			return
+		}
+		set, err := manager.SetIntelligenceTasks(req.Tasks)
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+			return
+		}
+		s.appendEvent(ccevent.AppendInput{
+			EventType: "probe.tasks_updated",
+			Data: map[string]any{
+				"custom": set.Custom,
+				"tasks":  len(set.Tasks),
+				"actor":  auditlog.ActorID(adminTokenFromRequest(r)),
+			},
+		})
+		if evaluator, ok := s.probeStatus.(interface {
+			IntelligenceConfig() probe.IntelligenceConfig
+			EvaluateIntelligence(ctx context.Context) []probe.IntelligenceResult
+		}); ok && evaluator.IntelligenceConfig().Enabled {
+			go evaluator.EvaluateIntelligence(context.Background())
+		}
+		w.Header().Set("content-type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(set)
+	default:
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+	}
+}
+
+// dispatchAdapterPrices converts the settings price list for the dispatcher.
+func dispatchAdapterPrices(in map[string]settings.AdapterPrice) map[string]upstream.AdapterPrice {
+	out := make(map[string]upstream.AdapterPrice, len(in))
+	for name, p := range in {
+		out[name] = upstream.AdapterPrice(p)
+	}
+	return out
+}
+
 // handleAdminIntelligentDispatch manages intelligent dispatch settings
 func (s *server) handleAdminIntelligentDispatch(w http.ResponseWriter, r *http.Request) {
 	if !s.authorizeAdmin(w, r) {
@@ -534,12 +747,14 @@ func (s *server) handleAdminIntelligentDispatch(w http.ResponseWriter, r *http.R
 		})
 	case http.MethodPut:
 		var req struct {
-			Enabled              *bool                     `json:"enabled,omitempty"`
-			MinScoreDifference   *float64                  `json:"min_score_difference,omitempty"`
-			ReElectIntervalMS    *int64                    `json:"re_elect_interval_ms,omitempty"`
-			FallbackToScheduler  *bool                     `json:"fallback_to_scheduler,omitempty"`
-			ModelPolicies        map[string]modelPolicyReq `json:"model_policies,omitempty"`
-			ComplexityThresholds *complexityThresholdReq   `json:"complexity_thresholds,omitempty"`
+			Enabled                        *bool                            `json:"enabled,omitempty"`
+			MinScoreDifference             *float64                         `json:"min_score_difference,omitempty"`
+			ReElectIntervalMS              *int64                           `json:"re_elect_interval_ms,omitempty"`
+			FallbackToScheduler            *bool                            `json:"fallback_to_scheduler,omitempty"`
+			ModelPolicies                  map[string]modelPolicyReq        `json:"model_policies,omitempty"`
+			ComplexityThresholds           *complexityThresholdReq          `json:"complexity_thresholds,omitempty"`
+			AdapterPrices                  map[string]settings.AdapterPrice `json:"adapter_prices,omitempty"`
+			PreferCheapestWithinScoreDelta *float64                         `json:"prefer_cheapest_within_score_delta,omitempty"`
 		}
 		if err := decodeJSONBodyStrict(r, &req, false); err != nil {
 			s.reportRequestDecodeIssue(r, err)
@@ -577,17 +792,30 @@ func (s *server) handleAdminIntelligentDispatch(w http.ResponseWriter, r *http.R
 				cfg.IntelligentDispatch.ComplexityThresholds.ToolCountThreshold = *req.ComplexityThresholds.ToolCountThreshold
 			}
 		}
+		if req.AdapterPrices != nil {
+			cfg.IntelligentDispatch.AdapterPrices = req.AdapterPrices
+		}
+		if req.PreferCheapestWithinScoreDelta != nil {
+			if *req.PreferCheapestWithinScoreDelta < 0 {
+				s.writeError(w, http.StatusBadRequest, "invalid_request_error", "prefer_cheapest_within_score_delta must be >= 0")
+				return
+			}
+			cfg.IntelligentDispatch.PreferCheapestWithinScoreDelta = *req.PreferCheapestWithinScoreDelta
+		}
 		s.settings.Put(cfg)
+		cfg = s.settings.Get()
 
 		// Try to update dispatcher if available
 		if dispUpd, ok := s.orchestrator.(interface {
 			UpdateDispatchConfigFull(cfg upstream.DispatchConfig) error
 		}); ok {
 			_ = dispUpd.UpdateDispatchConfigFull(upstream.DispatchConfig{
-				Enabled:             cfg.IntelligentDispatch.Enabled,
-				FallbackToScheduler: cfg.IntelligentDispatch.FallbackToScheduler,
-				MinScoreDifference:  cfg.IntelligentDispatch.MinScoreDifference,
-				ReElectIntervalMS:   cfg.IntelligentDispatch.ReElectIntervalMS,
+				Enabled:                        cfg.IntelligentDispatch.Enabled,
+				FallbackToScheduler:            cfg.IntelligentDispatch.FallbackToScheduler,
+				MinScoreDifference:             cfg.IntelligentDispatch.MinScoreDifference,
+				ReElectIntervalMS:              cfg.IntelligentDispatch.ReElectIntervalMS,
+				AdapterPrices:                  dispatchAdapterPrices(cfg.IntelligentDispatch.AdapterPrices),
+				PreferCheapestWithinScoreDelta: cfg.IntelligentDispatch.PreferCheapestWithinScoreDelta,
 			})
 		}
 
@@ -0,0 +1,81 @@
+package gateway
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"ccgateway/internal/upstream"
+)
+
+// handleAdminJudgeReport summarizes response-judge verdicts: how often each
+// adapter wins the contests it enters, overall and per request model.
+// GET /admin/judge/report?window=24h&since=&until=&model=
+func (s *server) handleAdminJudgeReport(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	if r.Method != http.MethodGet {
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+		return
+	}
+	reporter, ok := s.orchestrator.(interface {
+		JudgeReport(q upstream.JudgeReportQuery) (upstream.JudgeReport, error)
+	})
+	if !ok {
+		s.writeError(w, http.StatusNotImplemented, "api_error", "judge reports are not supported by the orchestrator")
+		return
+	}
+	since, until, err := parseReportWindow(r.URL.Query())
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	report, err := reporter.JudgeReport(upstream.JudgeReportQuery{
+		Since: since,
+		Until: until,
+		Model: strings.TrimSpace(r.URL.Query().Get("model")),
+	})
+	if err != nil {
+		s.writeError(w, http.StatusNotImplemented, "api_error", err.Error())
+		return
+	}
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(report)
+}
+
+// handleAdminJudgeVerdicts lists recorded verdicts, newest first.
+// GET /admin/judge/verdicts?run_id=&limit=
+func (s *server) handleAdminJudgeVerdicts(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	if r.Method != http.MethodGet {
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+		return
+	}
+	lister, ok := s.orchestrator.(interface {
+		JudgeVerdicts(runID string, limit int) ([]upstream.JudgeVerdict, error)
+	})
+	if !ok {
+		s.writeError(w, http.StatusNotImplemented, "api_error", "judge verdicts are not supported by the orchestrator")
+		return
+	}
+	limit, ok := parseNonNegativeInt(r.URL.Query().Get("limit"))
+	if !ok {
+		s.writeError(w, http.StatusBadRequest, "invalid_request_error", "limit must be an integer >= 0")
+		return
+	}
+	if limit == 0 {
+		limit = 100
+	}
+	items, err := lister.JudgeVerdicts(r.URL.Query().Get("run_id"), limit)
+	if err != nil {
+		s.writeError(w, http.StatusNotImplemented, "api_error", err.Error())
+		return
+	}
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(map[string]any{"data": items})
+}
@@ -0,0 +1,199 @@
+package gateway
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// adminListSpec declares the paging, sorting and filtering an admin list
+// endpoint accepts. Every list answers with the same envelope:
+//
+//	{"data":[...],"total":N,"limit":L,"offset":O,"has_more":bool,"next_cursor":"..."}
+//
+// next_cursor is opaque and only valid for the sort and filters it was
+// issued with; offset is still accepted for clients that page by number.
+type adminListSpec struct {
+	DefaultLimit int
+	MaxLimit     int
+	// SortKeys lists the accepted ?sort= keys; a leading "-" sorts
+	// descending. DefaultSort uses the same syntax.
+	SortKeys    []string
+	DefaultSort string
+	// Filters lists the query parameters that narrow the list. They are
+	// collected into adminListQuery.Filters and bound into cursors.
+	Filters []string
+}
+
+type adminListQuery struct {
+	Limit   int
+	Offset  int
+	Sort    string
+	Desc    bool
+	Filters map[string]string
+
+	fingerprint string
+}
+
+type adminListCursor struct {
+	Offset      int    `json:"o"`
+	Fingerprint string `json:"f"`
+}
+
+func parseAdminListQuery(values url.Values, spec adminListSpec) (adminListQuery, error) {
+	q := adminListQuery{Limit: spec.DefaultLimit, Filters: map[string]string{}}
+	if raw := strings.TrimSpace(values.Get("limit")); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			return q, errors.New("limit must be a positive integer")
+		}
+		q.Limit = n
+	}
+	if spec.MaxLimit > 0 && q.Limit > spec.MaxLimit {
+		q.Limit = spec.MaxLimit
+	}
+
+	sortKey := strings.TrimSpace(values.Get("sort"))
+	if sortKey == "" {
+		sortKey = spec.DefaultSort
+	}
+	if sortKey != "" {
+		q.Desc = strings.HasPrefix(sortKey, "-")
+		q.Sort = strings.TrimPrefix(sortKey, "-")
+		known := false
+		for _, key := range spec.SortKeys {
+			known = known || key == q.Sort
+		}
+		if !known {
+			if len(spec.SortKeys) == 0 {
+				return q, errors.New("this list does not support sort")
+			}
+			return q, fmt.Errorf("sort must be one of %s (prefix - for descending)", strings.Join(spec.SortKeys, ", "))
+		}
+	}
+
+	for _, name := range spec.Filters {
+		if v := strings.TrimSpace(values.Get(name)); v != "" {
+			q.Filters[name] = v
+		}
+	}
+	q.fingerprint = q.computeFingerprint()
+
+	if raw := strings.TrimSpace(values.Get("cursor")); raw != "" {
+		offset, err := decodeAdminListCursor(raw, q.fingerprint)
+		if err != nil {
+			return q, err
+		}
+		q.Offset = offset
+	} else if raw := strings.TrimSpace(values.Get("offset")); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			return q, errors.New("offset must be a non-negative integer")
+		}
+		q.Offset = n
+	}
+	return q, nil
+}
+
+// computeFingerprint identifies the sort and filters so a cursor cannot be
+// replayed against a different ordering of the list.
+func (q adminListQuery) computeFingerprint() string {
+	names := make([]string, 0, len(q.Filters))
+	for name := range q.Filters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	h := sha256.New()
+	fmt.Fprintf(h, "%s|%t", q.Sort, q.Desc)
+	for _, name := range names {
+		fmt.Fprintf(h, "|%s=%s", name, q.Filters[name])
+	}
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+func encodeAdminListCursor(offset int, fingerprint string) string {
+	raw, _ := json.Marshal(adminListCursor{Offset: offset, Fingerprint: fingerprint})
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeAdminListCursor(raw, fingerprint string) (int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return 0, errors.New("cursor is invalid")
+	}
+	var c adminListCursor
+	if err := json.Unmarshal(data, &c); err != nil || c.Offset < 0 {
+		return 0, errors.New("cursor is invalid")
+	}
+	if c.Fingerprint != fingerprint {
+		return 0, errors.New("cursor does not match the current sort or filters")
+	}
+	return c.Offset, nil
+}
+
+// order returns a less function for sort.SliceStable that applies the
+// requested sort key from keys, reversed when descending. keys holds the
+// ascending order of every key in the spec.
+func (q adminListQuery) order(keys map[string]func(i, j int) bool) func(i, j int) bool {
+	less, ok := keys[q.Sort]
+	if !ok {
+		return func(int, int) bool { return false }
+	}
+	if q.Desc {
+		return func(i, j int) bool { return less(j, i) }
+	}
+	return less
+}
+
+// window returns the bounds of the requested page within total items.
+func (q adminListQuery) window(total int) (int, int) {
+	start := min(q.Offset, total)
+	end := total
+	if q.Limit > 0 {
+		end = min(start+q.Limit, total)
+	}
+	return start, end
+}
+
+// envelope wraps one page of data that starts at q.Offset in the shared
+// list response.
+func (q adminListQuery) envelope(data any, count, total int) map[string]any {
+	out := map[string]any{
+		"data":     data,
+		"total":    total,
+		"limit":    q.Limit,
+		"offset":   q.Offset,
+		"has_more": false,
+	}
+	if q.Sort != "" {
+		sortKey := q.Sort
+		if q.Desc {
+			sortKey = "-" + sortKey
+		}
+		out["sort"] = sortKey
+	}
+	if next := q.Offset + count; count > 0 && next < total {
+		out["has_more"] = true
+		out["next_cursor"] = encodeAdminListCursor(next, q.fingerprint)
+	}
+	return out
+}
+
+func (s *server) writeAdminList(w http.ResponseWriter, q adminListQuery, data any, count, total int) {
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(q.envelope(data, count, total))
+}
+
+// containsSubstringFold reports whether s contains substr, ignoring case.
+func containsSubstringFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
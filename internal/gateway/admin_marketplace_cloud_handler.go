@@ -3,14 +3,15 @@ package gateway
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"net"
 	"net/http"
 	"net/url"
 	"strings"
 	"time"
 
+	"ccgateway/internal/egress"
 	"ccgateway/internal/marketplace"
 	"ccgateway/internal/plugin"
 	"ccgateway/internal/requestctx"
@@ -128,6 +129,7 @@ func (s *server) handleAdminMarketplaceCloudInstall(w http.ResponseWriter, r *ht
 			Skills:      manifest.Skills,
 			Hooks:       manifest.Hooks,
 			MCPServers:  manifest.MCPServers,
+			Middleware:  manifest.Middleware,
 			Enabled:     true,
 		}
 		if err := s.pluginStore.Install(p); err != nil {
@@ -163,7 +165,7 @@ func fetchCloudManifests(rawURL string) ([]marketplace.PluginManifest, error) {
 	if err := validateCloudManifestURL(parsed); err != nil {
 		return nil, err
 	}
-	client := &http.Client{Timeout: 20 * time.Second}
+	client := cloudManifestEgress().HTTPClient(20 * time.Second)
 	resp, err := client.Get(rawURL)
 	if err != nil {
 		return nil, fmt.Errorf("fetch cloud manifests failed: %w", err)
@@ -199,69 +201,17 @@ func validateCloudManifestURL(parsed *url.URL) error {
 	if host == "" {
 		return fmt.Errorf("url host is required")
 	}
-	if isLikelyLocalHostname(host) {
-		return fmt.Errorf("private network hosts are not allowed")
-	}
-	if ip := net.ParseIP(host); ip != nil {
-		if !isPublicIP(ip) {
+	if err := cloudManifestEgress().CheckHost(context.Background(), host); err != nil {
+		if errors.Is(err, egress.ErrBlocked) {
 			return fmt.Errorf("private network hosts are not allowed")
 		}
-		return nil
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-
-	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
-	if err != nil {
 		return fmt.Errorf("resolve cloud host failed: %w", err)
 	}
-	if len(addrs) == 0 {
-		return fmt.Errorf("resolve cloud host returned no addresses")
-	}
-	for _, addr := range addrs {
-		if !isPublicIP(addr.IP) {
-			return fmt.Errorf("private network hosts are not allowed")
-		}
-	}
 	return nil
 }
 
-func isLikelyLocalHostname(host string) bool {
-	h := strings.ToLower(strings.TrimSpace(host))
-	if h == "localhost" || h == "localhost.localdomain" {
-		return true
-	}
-	return strings.HasSuffix(h, ".local") || strings.HasSuffix(h, ".internal")
-}
-
-func isPublicIP(ip net.IP) bool {
-	if ip == nil {
-		return false
-	}
-	if !ip.IsGlobalUnicast() || ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
-		return false
-	}
-
-	if ipv4 := ip.To4(); ipv4 != nil {
-		// CGNAT 100.64.0.0/10
-		if ipv4[0] == 100 && (ipv4[1]&0xc0) == 64 {
-			return false
-		}
-		// Benchmark testing 198.18.0.0/15
-		if ipv4[0] == 198 && (ipv4[1] == 18 || ipv4[1] == 19) {
-			return false
-		}
-		// Documentation-only ranges
-		if ipv4[0] == 192 && ipv4[1] == 0 && ipv4[2] == 2 {
-			return false
-		}
-		if ipv4[0] == 198 && ipv4[1] == 51 && ipv4[2] == 100 {
-			return false
-		}
-		if ipv4[0] == 203 && ipv4[1] == 0 && ipv4[2] == 113 {
-			return false
-		}
-	}
-	return true
+// cloudManifestEgress derives the marketplace policy from the process egress
+// policy, always blocking private networks for user-supplied manifest URLs.
+func cloudManifestEgress() *egress.Policy {
+	return egress.Default().Strict()
 }
@@ -0,0 +1,85 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"ccgateway/internal/ccevent"
+	"ccgateway/internal/mcpregistry"
+)
+
+// mcpToolSyncer is implemented by registries that keep tool lists warm in
+// the background.
+type mcpToolSyncer interface {
+	SyncTools(ctx context.Context, id string) (mcpregistry.ToolSyncStatus, error)
+	SyncAllTools(ctx context.Context) []mcpregistry.ToolSyncStatus
+	ToolSyncStatuses() []mcpregistry.ToolSyncStatus
+	ToolSyncInterval() time.Duration
+}
+
+// handleAdminMCPSync inspects or forces the MCP tool list sync.
+// GET/POST /admin/mcp/sync
+func (s *server) handleAdminMCPSync(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	syncer, ok := s.mcpRegistry.(mcpToolSyncer)
+	if s.mcpRegistry == nil || !ok {
+		s.writeError(w, http.StatusNotImplemented, "api_error", "mcp tool sync is not configured")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var req struct {
+			ServerID string `json:"server_id"`
+		}
+		if err := decodeJSONBodyStrict(r, &req, true); err != nil {
+			s.reportRequestDecodeIssue(r, err)
+			s.writeError(w, http.StatusBadRequest, "invalid_request_error", "invalid JSON body")
+			return
+		}
+		var synced []mcpregistry.ToolSyncStatus
+		if id := strings.TrimSpace(req.ServerID); id != "" {
+			status, err := syncer.SyncTools(r.Context(), id)
+			if errors.Is(err, mcpregistry.ErrNotFound) {
+				s.writeError(w, http.StatusNotFound, "not_found_error", err.Error())
+				return
+			}
+			if status.ServerID == "" && err != nil {
+				s.writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+				return
+			}
+			synced = []mcpregistry.ToolSyncStatus{status}
+		} else {
+			synced = syncer.SyncAllTools(r.Context())
+		}
+		failed := 0
+		for _, status := range synced {
+			if status.LastError != "" {
+				failed++
+			}
+		}
+		s.appendEvent(ccevent.AppendInput{
+			EventType: "mcp.tools_synced",
+			Data: map[string]any{
+				"server_id": strings.TrimSpace(req.ServerID),
+				"servers":   len(synced),
+				"failed":    failed,
+			},
+		})
+	default:
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+		return
+	}
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"interval_ms": syncer.ToolSyncInterval().Milliseconds(),
+		"servers":     syncer.ToolSyncStatuses(),
+	})
+}
@@ -0,0 +1,191 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"ccgateway/internal/policy"
+	"ccgateway/internal/requestctx"
+)
+
+type policyTestCase struct {
+	Name     string                 `json:"name,omitempty"`
+	Path     string                 `json:"path,omitempty"`
+	Model    string                 `json:"model,omitempty"`
+	Mode     string                 `json:"mode,omitempty"`
+	Tools    []string               `json:"tools,omitempty"`
+	Adapters []string               `json:"adapters,omitempty"`
+	Project  string                 `json:"project,omitempty"`
+	Token    policy.TokenAttributes `json:"token,omitempty"`
+	Expect   string                 `json:"expect,omitempty"`
+}
+
+type policyTestResult struct {
+	Name        string       `json:"name,omitempty"`
+	Index       int          `json:"index"`
+	Allowed     bool         `json:"allowed"`
+	Effect      string       `json:"effect"`
+	MatchedRule *policy.Rule `json:"matched_rule,omitempty"`
+	MatchedTool string       `json:"matched_tool,omitempty"`
+	Reason      string       `json:"reason,omitempty"`
+	Expect      string       `json:"expect,omitempty"`
+	Passed      *bool        `json:"passed,omitempty"`
+}
+
+// handleAdminPolicyTest evaluates fixture actions against candidate rules
+// or, when no rules are supplied, the live policy engine with its rules
+// from the runtime settings, without side effects. Each case's token
+// attributes and project stand in for the caller's.
+// POST /admin/policy/test
+func (s *server) handleAdminPolicyTest(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	if r.Method != http.MethodPost {
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+		return
+	}
+	var req struct {
+		Rules         []policy.Rule    `json:"rules,omitempty"`
+		DefaultEffect string           `json:"default_effect,omitempty"`
+		Cases         []policyTestCase `json:"cases"`
+	}
+	if err := decodeJSONBodyStrict(r, &req, false); err != nil {
+		s.reportRequestDecodeIssue(r, err)
+		s.writeError(w, http.StatusBadRequest, "invalid_request_error", "invalid JSON body")
+		return
+	}
+	if len(req.Cases) == 0 {
+		s.writeError(w, http.StatusBadRequest, "invalid_request_error", "cases are required")
+		return
+	}
+	defaultEffect := strings.ToLower(strings.TrimSpace(req.DefaultEffect))
+	switch defaultEffect {
+	case "":
+		defaultEffect = policy.EffectAllow
+	case policy.EffectAllow, policy.EffectDeny:
+	default:
+		s.writeError(w, http.StatusBadRequest, "invalid_request_error", "default_effect must be allow or deny")
+		return
+	}
+	for i, c := range req.Cases {
+		switch strings.ToLower(strings.TrimSpace(c.Expect)) {
+		case "", policy.EffectAllow, policy.EffectDeny:
+		default:
+			s.writeError(w, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("cases[%d]: expect must be allow or deny", i))
+			return
+		}
+	}
+	useCandidates := req.Rules != nil
+	if useCandidates {
+		if err := policy.ValidateRules(req.Rules); err != nil {
+			s.writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+			return
+		}
+	}
+
+	results := make([]policyTestResult, 0, len(req.Cases))
+	allowed, passed, failed := 0, 0, 0
+	for i, c := range req.Cases {
+		action := policy.Action{
+			Path:      strings.TrimSpace(c.Path),
+			Model:     strings.TrimSpace(c.Model),
+			Mode:      strings.ToLower(strings.TrimSpace(c.Mode)),
+			ToolNames: c.Tools,
+			Adapters:  c.Adapters,
+			Project:   requestctx.NormalizeProjectID(c.Project),
+		}
+		if action.Path == "" {
+			action.Path = "/v1/messages"
+		}
+		if action.Mode == "" {
+			action.Mode = "chat"
+		}
+		var decision policy.Decision
+		switch {
+		case !tokenAttributesAllowModel(c.Token, action.Model):
+			decision = policy.Decision{
+				Effect: policy.EffectDeny,
+				Reason: fmt.Sprintf("token is not allowed to access model %q", action.Model),
+			}
+		case useCandidates:
+			decision = policy.EvaluateRules(req.Rules, action, c.Token, defaultEffect)
+		default:
+			ctx := policy.WithTokenAttributes(requestctx.WithProjectID(r.Context(), action.Project), c.Token)
+			if live, ok := s.policy.(policyDecider); ok {
+				decision = live.Decide(ctx, action)
+				break
+			}
+			decision = policy.Decision{Allowed: true, Effect: policy.EffectAllow, Reason: "live policy engine allowed"}
+			if err := s.policy.Authorize(ctx, action); err != nil {
+				decision = policy.Decision{Effect: policy.EffectDeny, Reason: err.Error()}
+			}
+		}
+		row := policyTestResult{
+			Name:        strings.TrimSpace(c.Name),
+			Index:       i,
+			Allowed:     decision.Allowed,
+			Effect:      decision.Effect,
+			MatchedRule: decision.MatchedRule,
+			MatchedTool: decision.MatchedTool,
+			Reason:      decision.Reason,
+			Expect:      strings.ToLower(strings.TrimSpace(c.Expect)),
+		}
+		if decision.Allowed {
+			allowed++
+		}
+		if row.Expect != "" {
+			ok := row.Expect == row.Effect
+			row.Passed = &ok
+			if ok {
+				passed++
+			} else {
+				failed++
+			}
+		}
+		results = append(results, row)
+	}
+
+	source := "live_engine"
+	if useCandidates {
+		source = "candidate_rules"
+	} else if live, ok := s.policy.(policyDecider); ok {
+		defaultEffect = live.DefaultEffect()
+	}
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"source":         source,
+		"default_effect": defaultEffect,
+		"ok":             failed == 0,
+		"results":        results,
+		"summary": map[string]any{
+			"total":   len(results),
+			"allowed": allowed,
+			"denied":  len(results) - allowed,
+			"passed":  passed,
+			"failed":  failed,
+		},
+	})
+}
+
+// policyDecider is a policy engine that reports the rule behind a decision.
+type policyDecider interface {
+	Decide(ctx context.Context, action policy.Action) policy.Decision
+	DefaultEffect() string
+}
+
+func tokenAttributesAllowModel(tk policy.TokenAttributes, model string) bool {
+	if len(tk.Models) == 0 || strings.TrimSpace(model) == "" {
+		return true
+	}
+	for _, m := range tk.Models {
+		if strings.TrimSpace(m) == strings.TrimSpace(model) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,295 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"ccgateway/internal/auditlog"
+	"ccgateway/internal/ccevent"
+	"ccgateway/internal/project"
+	"ccgateway/internal/requestctx"
+	"ccgateway/internal/settings"
+	"ccgateway/internal/token"
+)
+
+// projectFor returns the registered project the request belongs to.
+func (s *server) projectFor(ctx context.Context) (project.Project, bool) {
+	if s.projects == nil {
+		return project.Project{}, false
+	}
+	return s.projects.Get(requestctx.ProjectID(ctx))
+}
+
+// settingsFor returns the runtime settings in effect for the request: the
+// global settings with the project's overrides and routes applied on top.
+func (s *server) settingsFor(ctx context.Context) *settings.Store {
+	if s.settings == nil {
+		return nil
+	}
+	p, ok := s.projectFor(ctx)
+	if !ok || (len(p.Settings) == 0 && len(p.Routes) == 0) {
+		return s.settings
+	}
+	scoped, err := projectSettings(s.settings, p)
+	if err != nil {
+		// Overrides are validated on write, so this only happens when the
+		// global settings changed underneath them.
+		s.logger.Warn("project settings overlay failed", "project_id", p.ID, "error", err)
+		return s.settings
+	}
+	return scoped
+}
+
+func projectSettings(global *settings.Store, p project.Project) (*settings.Store, error) {
+	scoped := global
+	if len(p.Settings) > 0 {
+		overlay, err := global.Overlay(p.Settings)
+		if err != nil {
+			return nil, err
+		}
+		scoped = overlay
+	}
+	if len(p.Routes) > 0 {
+		cfg := scoped.Get()
+		if cfg.Routing.ModeRoutes == nil {
+			cfg.Routing.ModeRoutes = map[string][]string{}
+		}
+		for mode, adapters := range p.Routes {
+			cfg.Routing.ModeRoutes[mode] = adapters
+		}
+		scoped = settings.NewStore(cfg)
+	}
+	return scoped, nil
+}
+
+// bindTokenProject puts a token's bound project into ctx. Naming another
+// project on the request is refused.
+func (s *server) bindTokenProject(ctx context.Context, r *http.Request, tk *token.Token) (context.Context, error) {
+	if s.projects == nil || tk == nil {
+		return ctx, nil
+	}
+	p, ok := s.projects.ForToken(tk.ID)
+	if !ok {
+		return ctx, nil
+	}
+	if named := explicitProjectID(r); named != "" && named != p.ID {
+		return ctx, fmt.Errorf("token is bound to project %q", p.ID)
+	}
+	return requestctx.WithProjectID(ctx, p.ID), nil
+}
+
+// admitProjectRequest enforces the project's request rate and token budget.
+func (s *server) admitProjectRequest(w http.ResponseWriter, r *http.Request) bool {
+	if s.projects == nil {
+		return true
+	}
+	id := requestctx.ProjectID(r.Context())
+	switch err := s.projects.Admit(id); {
+	case err == nil:
+		return true
+	case errors.Is(err, project.ErrRateLimited):
+		if wait := s.projects.RetryAfter(id); wait > 0 {
+			w.Header().Set("retry-after", strconv.FormatInt(int64(wait.Seconds())+1, 10))
+		}
+		s.writeError(w, http.StatusTooManyRequests, "rate_limit_error", err.Error())
+	default:
+		s.writeError(w, http.StatusForbidden, "quota_error", err.Error())
+	}
+	return false
+}
+
+func (s *server) chargeProject(ctx context.Context, tokens int64) {
+	if s.projects != nil {
+		s.projects.Charge(requestctx.ProjectID(ctx), tokens)
+	}
+}
+
+// handleAdminProjects lists or creates projects.
+// GET/POST /admin/projects
+func (s *server) handleAdminProjects(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	if s.projects == nil {
+		s.writeError(w, http.StatusNotImplemented, "api_error", "projects are not configured")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		projects := s.projects.List()
+		w.Header().Set("content-type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"data":  projects,
+			"total": len(projects),
+		})
+	case http.MethodPost:
+		var in project.Input
+		if err := decodeJSONBodyStrict(r, &in, false); err != nil {
+			s.reportRequestDecodeIssue(r, err)
+			s.writeError(w, http.StatusBadRequest, "invalid_request_error", "invalid JSON body")
+			return
+		}
+		if err := s.validateProjectInput(in); err != nil {
+			s.writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+			return
+		}
+		p, err := s.projects.Create(in)
+		if err != nil {
+			s.writeProjectError(w, err)
+			return
+		}
+		s.appendProjectEvent(r, "project.created", p)
+		w.Header().Set("content-type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(p)
+	default:
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+	}
+}
+
+// handleAdminProjectByPath manages one project.
+// GET/PUT/DELETE /admin/projects/{id}, GET /admin/projects/{id}/settings
+// (effective settings), POST /admin/projects/{id}/reset-usage
+func (s *server) handleAdminProjectByPath(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	if s.projects == nil {
+		s.writeError(w, http.StatusNotImplemented, "api_error", "projects are not configured")
+		return
+	}
+	id, action, _ := strings.Cut(strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/projects/"), "/"), "/")
+	current, ok := s.projects.Get(id)
+	if !ok {
+		s.writeError(w, http.StatusNotFound, "not_found_error", project.ErrNotFound.Error())
+		return
+	}
+	switch action {
+	case "":
+	case "settings":
+		if r.Method != http.MethodGet {
+			s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+			return
+		}
+		if s.settings == nil {
+			s.writeError(w, http.StatusNotImplemented, "api_error", "settings store is not configured")
+			return
+		}
+		scoped, err := projectSettings(s.settings, current)
+		if err != nil {
+			s.writeError(w, http.StatusConflict, "invalid_request_error", err.Error())
+			return
+		}
+		w.Header().Set("content-type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(scoped.Get())
+		return
+	case "reset-usage":
+		if r.Method != http.MethodPost {
+			s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+			return
+		}
+		p, err := s.projects.ResetUsage(current.ID)
+		if err != nil {
+			s.writeProjectError(w, err)
+			return
+		}
+		s.appendProjectEvent(r, "project.usage_reset", p)
+		w.Header().Set("content-type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(p)
+		return
+	default:
+		s.writeError(w, http.StatusNotFound, "not_found_error", "unknown project action")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("content-type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(current)
+	case http.MethodPut:
+		var in project.Input
+		if err := decodeJSONBodyStrict(r, &in, false); err != nil {
+			s.reportRequestDecodeIssue(r, err)
+			s.writeError(w, http.StatusBadRequest, "invalid_request_error", "invalid JSON body")
+			return
+		}
+		if err := s.validateProjectInput(in); err != nil {
+			s.writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+			return
+		}
+		p, err := s.projects.Update(current.ID, in)
+		if err != nil {
+			s.writeProjectError(w, err)
+			return
+		}
+		s.appendProjectEvent(r, "project.updated", p)
+		w.Header().Set("content-type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(p)
+	case http.MethodDelete:
+		if err := s.projects.Delete(current.ID); err != nil {
+			s.writeProjectError(w, err)
+			return
+		}
+		s.appendProjectEvent(r, "project.deleted", current)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+	}
+}
+
+// validateProjectInput checks what the project store cannot: that the
+// settings overrides apply and the routed adapters exist.
+func (s *server) validateProjectInput(in project.Input) error {
+	if in.Settings != nil && s.settings != nil {
+		if raw := strings.TrimSpace(string(*in.Settings)); raw != "" && raw != "null" {
+			if _, err := s.settings.Overlay(*in.Settings); err != nil {
+				return err
+			}
+		}
+	}
+	if in.Routes != nil {
+		for mode, adapters := range *in.Routes {
+			for _, name := range adapters {
+				if name = strings.TrimSpace(name); name != "" && !s.isKnownAdapterName(name) {
+					return fmt.Errorf("routes[%s]: unknown adapter %q", mode, name)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (s *server) writeProjectError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, project.ErrNotFound):
+		s.writeError(w, http.StatusNotFound, "not_found_error", err.Error())
+	case errors.Is(err, project.ErrExists), errors.Is(err, project.ErrTokenBound):
+		s.writeError(w, http.StatusConflict, "invalid_request_error", err.Error())
+	case errors.Is(err, project.ErrInvalidID), errors.Is(err, project.ErrInvalidInput):
+		s.writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+	default:
+		s.writeError(w, http.StatusInternalServerError, "api_error", err.Error())
+	}
+}
+
+func (s *server) appendProjectEvent(r *http.Request, eventType string, p project.Project) {
+	s.appendEvent(ccevent.AppendInput{
+		EventType: eventType,
+		Data: map[string]any{
+			"project_id": p.ID,
+			"name":       p.Name,
+			"token_ids":  p.TokenIDs,
+			"actor":      auditlog.ActorID(adminTokenFromRequest(r)),
+		},
+	})
+}
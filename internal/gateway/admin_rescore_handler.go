@@ -0,0 +1,101 @@
+package gateway
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"ccgateway/internal/ccevent"
+	"ccgateway/internal/eval"
+)
+
+// handleAdminRescore starts or lists jobs that re-run the current judge over
+// stored run outputs.
+// GET/POST /admin/runs/rescore
+func (s *server) handleAdminRescore(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	if s.rescorer == nil {
+		s.writeError(w, http.StatusNotImplemented, "api_error", "rescoring requires an evaluator and run store")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		s.writeRescoreJSON(w, http.StatusOK, map[string]any{
+			"judge_version": s.evaluator.Version(),
+			"data":          s.rescorer.List(),
+		})
+	case http.MethodPost:
+		var req eval.RescoreRequest
+		if err := decodeJSONBodyStrict(r, &req, true); err != nil {
+			s.reportRequestDecodeIssue(r, err)
+			s.writeError(w, http.StatusBadRequest, "invalid_request_error", "invalid JSON body")
+			return
+		}
+		job, err := s.rescorer.Start(req)
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+			return
+		}
+		s.appendEvent(ccevent.AppendInput{
+			EventType: "eval.rescore_started",
+			Data: map[string]any{
+				"job_id":        job.ID,
+				"judge_version": job.JudgeVersion,
+				"total":         job.Total,
+			},
+		})
+		s.writeRescoreJSON(w, http.StatusAccepted, job)
+	default:
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+	}
+}
+
+// handleAdminRescoreByPath reads or cancels one rescore job.
+// GET/DELETE /admin/runs/rescore/{id}
+func (s *server) handleAdminRescoreByPath(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	if s.rescorer == nil {
+		s.writeError(w, http.StatusNotImplemented, "api_error", "rescoring requires an evaluator and run store")
+		return
+	}
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/runs/rescore/"), "/")
+	if id == "" || strings.Contains(id, "/") {
+		s.writeError(w, http.StatusNotFound, "not_found_error", "rescore job not found")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		job, ok := s.rescorer.Get(id)
+		if !ok {
+			s.writeError(w, http.StatusNotFound, "not_found_error", "rescore job not found")
+			return
+		}
+		s.writeRescoreJSON(w, http.StatusOK, job)
+	case http.MethodDelete:
+		job, ok := s.rescorer.Cancel(id)
+		if !ok {
+			s.writeError(w, http.StatusNotFound, "not_found_error", "rescore job not found")
+			return
+		}
+		s.appendEvent(ccevent.AppendInput{
+			EventType: "eval.rescore_cancelled",
+			Data: map[string]any{
+				"job_id": job.ID,
+				"scored": job.Scored,
+			},
+		})
+		s.writeRescoreJSON(w, http.StatusOK, job)
+	default:
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+	}
+}
+
+func (s *server) writeRescoreJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,144 @@
+package gateway
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"ccgateway/internal/ccrun"
+)
+
+var adminRunListSpec = adminListSpec{
+	DefaultLimit: 100,
+	MaxLimit:     1000,
+	SortKeys:     []string{"created_at", "updated_at", "cost_usd", "tool_count", "status_code"},
+	DefaultSort:  "-created_at",
+	Filters:      []string{"session_id", "project_id", "user_id", "status", "path", "correlation_id", "mode", "model"},
+}
+
+// handleAdminRuns pages through runs across every project and user.
+// GET /admin/runs?status=&mode=&model=&session_id=&sort=-created_at&limit=&cursor=
+func (s *server) handleAdminRuns(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	if s.runStore == nil {
+		s.writeError(w, http.StatusNotImplemented, "api_error", "run store is not configured")
+		return
+	}
+	if r.Method != http.MethodGet {
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+		return
+	}
+	q, err := parseAdminListQuery(r.URL.Query(), adminRunListSpec)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	listed := s.runStore.List(ccrun.ListFilter{
+		SessionID:     q.Filters["session_id"],
+		ProjectID:     q.Filters["project_id"],
+		UserID:        q.Filters["user_id"],
+		Status:        q.Filters["status"],
+		Path:          q.Filters["path"],
+		CorrelationID: q.Filters["correlation_id"],
+	})
+	runs := make([]ccrun.Run, 0, len(listed))
+	for _, run := range listed {
+		if mode := q.Filters["mode"]; mode != "" && run.Mode != mode {
+			continue
+		}
+		if model := q.Filters["model"]; model != "" && run.ClientModel != model && run.RequestedModel != model && run.UpstreamModel != model {
+			continue
+		}
+		runs = append(runs, run)
+	}
+	sort.SliceStable(runs, q.order(map[string]func(i, j int) bool{
+		"created_at":  func(i, j int) bool { return runs[i].CreatedAt.Before(runs[j].CreatedAt) },
+		"updated_at":  func(i, j int) bool { return runs[i].UpdatedAt.Before(runs[j].UpdatedAt) },
+		"cost_usd":    func(i, j int) bool { return runs[i].CostUSD < runs[j].CostUSD },
+		"tool_count":  func(i, j int) bool { return runs[i].ToolCount < runs[j].ToolCount },
+		"status_code": func(i, j int) bool { return runs[i].StatusCode < runs[j].StatusCode },
+	}))
+	start, end := q.window(len(runs))
+	s.writeAdminList(w, q, runs[start:end], end-start, len(runs))
+}
+
+type runSearcher interface {
+	Search(q ccrun.SearchQuery) ([]ccrun.Run, int)
+}
+
+// Search results are newest first; the store's text index answers q.
+var adminRunSearchSpec = adminListSpec{
+	DefaultLimit: 50,
+	MaxLimit:     500,
+	Filters: []string{"q", "status", "model", "adapter", "mode", "session_id", "error",
+		"min_duration_ms", "max_duration_ms", "since", "until"},
+}
+
+// handleAdminRunSearch finds runs by structured filters and free text over
+// their record text.
+// GET /admin/runs/search?q=&status=&model=&adapter=&mode=&session_id=&error=&min_duration_ms=&max_duration_ms=&since=&until=
+func (s *server) handleAdminRunSearch(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	searcher, ok := s.runStore.(runSearcher)
+	if !ok {
+		s.writeError(w, http.StatusNotImplemented, "api_error", "run store does not support search")
+		return
+	}
+	if r.Method != http.MethodGet {
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+		return
+	}
+	page, err := parseAdminListQuery(r.URL.Query(), adminRunSearchSpec)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	q := ccrun.SearchQuery{
+		Text:          page.Filters["q"],
+		Status:        page.Filters["status"],
+		Model:         page.Filters["model"],
+		Adapter:       page.Filters["adapter"],
+		Mode:          page.Filters["mode"],
+		SessionID:     page.Filters["session_id"],
+		ErrorContains: page.Filters["error"],
+		Limit:         page.Limit,
+		Offset:        page.Offset,
+	}
+	for _, bound := range []struct {
+		name string
+		dst  *int64
+	}{{"min_duration_ms", &q.MinDurationMS}, {"max_duration_ms", &q.MaxDurationMS}} {
+		raw := page.Filters[bound.name]
+		if raw == "" {
+			continue
+		}
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || n < 0 {
+			s.writeError(w, http.StatusBadRequest, "invalid_request_error", bound.name+" must be a non-negative integer")
+			return
+		}
+		*bound.dst = n
+	}
+	for _, bound := range []struct {
+		name string
+		dst  *time.Time
+	}{{"since", &q.Since}, {"until", &q.Until}} {
+		raw := page.Filters[bound.name]
+		if raw == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, "invalid_request_error", bound.name+" must be an RFC3339 timestamp")
+			return
+		}
+		*bound.dst = t
+	}
+	runs, total := searcher.Search(q)
+	s.writeAdminList(w, page, runs, len(runs), total)
+}
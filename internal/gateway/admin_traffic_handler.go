@@ -0,0 +1,187 @@
+package gateway
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"ccgateway/internal/auth"
+	"ccgateway/internal/ccevent"
+	"ccgateway/internal/runlog"
+	"ccgateway/internal/trafficsample"
+)
+
+// handleAdminTrafficConfig reads or patches the traffic sampler configuration.
+// GET/PUT /admin/traffic/config
+func (s *server) handleAdminTrafficConfig(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	if s.trafficSampler == nil {
+		s.writeError(w, http.StatusNotImplemented, "api_error", "traffic sampler is not configured")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("content-type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"traffic": s.trafficSampler.Snapshot(),
+		})
+	case http.MethodPut:
+		var patch trafficsample.ConfigPatch
+		if err := decodeJSONBodyStrict(r, &patch, false); err != nil {
+			s.reportRequestDecodeIssue(r, err)
+			s.writeError(w, http.StatusBadRequest, "invalid_request_error", "invalid JSON body")
+			return
+		}
+		if _, err := s.trafficSampler.UpdateConfigPatch(patch); err != nil {
+			s.writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+			return
+		}
+		w.Header().Set("content-type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"traffic": s.trafficSampler.Snapshot(),
+		})
+	default:
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+	}
+}
+
+// handleAdminTrafficStream streams sampled, redacted prompts/responses over SSE.
+// Only users with the analyst role may connect; every attempt is audited.
+// GET /admin/traffic/stream
+func (s *server) handleAdminTrafficStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+		return
+	}
+	if s.trafficSampler == nil {
+		s.writeError(w, http.StatusNotImplemented, "api_error", "traffic sampler is not configured")
+		return
+	}
+	user, status, reason := s.resolveAnalyst(r)
+	s.auditTrafficAccess(r, user, status, reason)
+	if status != http.StatusOK {
+		kind := "permission_error"
+		if status == http.StatusUnauthorized {
+			kind = "authentication_error"
+		}
+		s.writeError(w, status, kind, reason)
+		return
+	}
+
+	w, r, stopGuard := s.guardStream(w, r, commentPingFrame)
+	defer stopGuard()
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		s.writeError(w, http.StatusInternalServerError, "api_error", "streaming unsupported")
+		return
+	}
+	ch, cancel := s.trafficSampler.Subscribe()
+	defer cancel()
+
+	w.Header().Set("content-type", "text/event-stream")
+	w.Header().Set("cache-control", "no-cache")
+	w.Header().Set("connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	_ = writeSSE(w, "traffic.config", s.trafficSampler.Config())
+	flusher.Flush()
+
+	for {
+		select {
+		case sample, ok := <-ch:
+			if !ok {
+				return
+			}
+			if err := writeSSE(w, "traffic.sample", sample); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (s *server) resolveAnalyst(r *http.Request) (*auth.User, int, string) {
+	if s.tokenService == nil || s.authService == nil {
+		return nil, http.StatusNotImplemented, "analyst access requires auth and token services"
+	}
+	raw := bearerToken(r.Header.Get("authorization"))
+	if raw == "" {
+		return nil, http.StatusUnauthorized, "analyst token is required"
+	}
+	tk, err := s.tokenService.Validate(raw)
+	if err != nil {
+		return nil, http.StatusUnauthorized, "invalid authentication credentials"
+	}
+	user, err := s.authService.Get(tk.UserID)
+	if err != nil || user == nil {
+		return nil, http.StatusForbidden, "token owner not found"
+	}
+	if !user.IsEnabled() {
+		return user, http.StatusForbidden, "user is disabled"
+	}
+	if user.Role != auth.RoleAnalyst {
+		return user, http.StatusForbidden, "traffic sampling requires the analyst role"
+	}
+	return user, http.StatusOK, ""
+}
+
+func (s *server) auditTrafficAccess(r *http.Request, user *auth.User, status int, reason string) {
+	userID := ""
+	if user != nil {
+		userID = user.ID
+	}
+	granted := status == http.StatusOK
+	s.logger.InfoContext(r.Context(), "traffic sample stream access", "audit", true, "user", userID, "ip", requestClientIP(r), "granted", granted)
+	s.appendEvent(ccevent.AppendInput{
+		EventType: "audit.traffic_sample_access",
+		Data: map[string]any{
+			"path":      "/admin/traffic/stream",
+			"user_id":   userID,
+			"client_ip": requestClientIP(r),
+			"granted":   granted,
+			"status":    status,
+			"reason":    reason,
+		},
+	})
+	s.logRun(runlog.Entry{
+		Path:   "/admin/traffic/stream",
+		Reason: "audit.traffic_sample_access",
+		Status: status,
+		Error:  reason,
+	})
+}
+
+// offerTrafficSample hands a finished exchange to the sampler, if configured.
+func (s *server) offerTrafficSample(runID, sessionID, path, mode, model string, status int, stream bool, prompt, response string, metadata map[string]any) {
+	if s.trafficSampler == nil || runID == "" {
+		return
+	}
+	s.trafficSampler.Offer(trafficsample.Sample{
+		RunID:     runID,
+		SessionID: sessionID,
+		Path:      path,
+		Mode:      mode,
+		Model:     model,
+		Status:    status,
+		Stream:    stream,
+		Prompt:    prompt,
+		Response:  response,
+		Metadata:  metadata,
+		CreatedAt: time.Now().UTC(),
+	})
+}
+
+func lastUserPromptText(messages []MessageParam) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if strings.EqualFold(strings.TrimSpace(messages[i].Role), "user") {
+			return contentToMemoryText(messages[i].Content)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,408 @@
+package gateway
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"ccgateway/internal/auditlog"
+	"ccgateway/internal/auth"
+	"ccgateway/internal/ccevent"
+	"ccgateway/internal/channel"
+	"ccgateway/internal/mcpregistry"
+	"ccgateway/internal/token"
+	"ccgateway/internal/toolcatalog"
+)
+
+// defaultTrashRetention is how long soft-deleted resources stay
+// restorable when Dependencies.TrashRetention is unset.
+const defaultTrashRetention = 7 * 24 * time.Hour
+
+var (
+	errTrashNotFound = errors.New("not in trash")
+	errToolExists    = errors.New("a tool with this name already exists")
+)
+
+// trashItem is one soft-deleted resource as listed by /admin/trash.
+type trashItem struct {
+	Kind      string    `json:"kind"`
+	ID        string    `json:"id"`
+	Name      string    `json:"name,omitempty"`
+	DeletedAt time.Time `json:"deleted_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Resource  any       `json:"resource"`
+}
+
+// trashKind adapts one store's soft-delete methods to the trash endpoints.
+type trashKind struct {
+	list    func() []trashItem
+	restore func(id string) (any, error)
+	purge   func(id string) error
+}
+
+type deletedTool struct {
+	spec      toolcatalog.ToolSpec
+	deletedAt time.Time
+}
+
+// trashBin holds the trash settings and the tools removed from the global
+// catalog, which has no soft delete of its own.
+type trashBin struct {
+	retention time.Duration
+
+	mu    sync.Mutex
+	tools map[string]deletedTool
+}
+
+func newTrashBin(retention time.Duration) *trashBin {
+	if retention <= 0 {
+		retention = defaultTrashRetention
+	}
+	return &trashBin{retention: retention, tools: map[string]deletedTool{}}
+}
+
+// trackToolReplace moves tools dropped by a catalog replace into the trash
+// and forgets trashed tools that were added back. It returns the names of
+// the dropped tools.
+func (t *trashBin) trackToolReplace(before, after []toolcatalog.ToolSpec) []string {
+	kept := make(map[string]bool, len(after))
+	for _, spec := range after {
+		kept[spec.Name] = true
+	}
+	now := time.Now().UTC()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for name := range kept {
+		delete(t.tools, name)
+	}
+	var removed []string
+	for _, spec := range before {
+		if !kept[spec.Name] {
+			t.tools[spec.Name] = deletedTool{spec: spec, deletedAt: now}
+			removed = append(removed, spec.Name)
+		}
+	}
+	return removed
+}
+
+// trashKinds lists the resource kinds whose stores support soft delete.
+func (s *server) trashKinds() map[string]trashKind {
+	kinds := map[string]trashKind{}
+	if store, ok := s.channelStore.(interface {
+		DeletedChannels() []*channel.Channel
+		RestoreChannel(id int64) (*channel.Channel, error)
+		PurgeChannel(id int64) error
+	}); ok {
+		kinds["channel"] = trashKind{
+			list: func() []trashItem {
+				var out []trashItem
+				for _, c := range store.DeletedChannels() {
+					if c.DeletedAt != nil {
+						out = append(out, trashItem{ID: strconv.FormatInt(c.ID, 10), Name: c.Name, DeletedAt: *c.DeletedAt, Resource: c})
+					}
+				}
+				return out
+			},
+			restore: func(id string) (any, error) {
+				n, err := parseTrashInt(id)
+				if err != nil {
+					return nil, err
+				}
+				return store.RestoreChannel(n)
+			},
+			purge: func(id string) error {
+				n, err := parseTrashInt(id)
+				if err != nil {
+					return err
+				}
+				return store.PurgeChannel(n)
+			},
+		}
+	}
+	if store, ok := s.tokenService.(interface {
+		ListDeleted() []*token.Token
+		RestoreByID(id int64) (*token.Token, error)
+		PurgeByID(id int64) error
+	}); ok {
+		kinds["token"] = trashKind{
+			list: func() []trashItem {
+				var out []trashItem
+				for _, tk := range store.ListDeleted() {
+					if tk.DeletedAt != nil {
+						out = append(out, trashItem{ID: strconv.FormatInt(tk.ID, 10), Name: tk.Name, DeletedAt: *tk.DeletedAt, Resource: tk})
+					}
+				}
+				return out
+			},
+			restore: func(id string) (any, error) {
+				n, err := parseTrashInt(id)
+				if err != nil {
+					return nil, err
+				}
+				return store.RestoreByID(n)
+			},
+			purge: func(id string) error {
+				n, err := parseTrashInt(id)
+				if err != nil {
+					return err
+				}
+				return store.PurgeByID(n)
+			},
+		}
+	}
+	if store, ok := s.authService.(interface {
+		ListDeleted() []*auth.User
+		Restore(id string) (*auth.User, error)
+		Purge(id string) error
+	}); ok {
+		kinds["user"] = trashKind{
+			list: func() []trashItem {
+				var out []trashItem
+				for _, u := range store.ListDeleted() {
+					if u.DeletedAt != nil {
+						out = append(out, trashItem{ID: u.ID, Name: u.Username, DeletedAt: *u.DeletedAt, Resource: u})
+					}
+				}
+				return out
+			},
+			restore: func(id string) (any, error) { return store.Restore(id) },
+			purge:   store.Purge,
+		}
+	}
+	if store, ok := s.mcpRegistry.(interface {
+		ListDeleted() []mcpregistry.Server
+		Restore(id string) (mcpregistry.Server, error)
+		Purge(id string) error
+	}); ok {
+		kinds["mcp_server"] = trashKind{
+			list: func() []trashItem {
+				var out []trashItem
+				for _, srv := range store.ListDeleted() {
+					if srv.DeletedAt != nil {
+						out = append(out, trashItem{ID: srv.ID, Name: srv.Name, DeletedAt: *srv.DeletedAt, Resource: srv})
+					}
+				}
+				return out
+			},
+			restore: func(id string) (any, error) { return store.Restore(id) },
+			purge:   store.Purge,
+		}
+	}
+	if s.toolCatalog != nil {
+		kinds["tool"] = trashKind{
+			list:    s.listDeletedTools,
+			restore: s.restoreTool,
+			purge:   s.purgeTool,
+		}
+	}
+	return kinds
+}
+
+func parseTrashInt(id string) (int64, error) {
+	n, err := strconv.ParseInt(id, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, errTrashNotFound
+	}
+	return n, nil
+}
+
+func (s *server) listDeletedTools() []trashItem {
+	s.trash.mu.Lock()
+	defer s.trash.mu.Unlock()
+	out := make([]trashItem, 0, len(s.trash.tools))
+	for name, t := range s.trash.tools {
+		out = append(out, trashItem{ID: name, Name: name, DeletedAt: t.deletedAt, Resource: t.spec})
+	}
+	return out
+}
+
+func (s *server) restoreTool(name string) (any, error) {
+	s.trash.mu.Lock()
+	defer s.trash.mu.Unlock()
+	t, ok := s.trash.tools[name]
+	if !ok {
+		return nil, errTrashNotFound
+	}
+	current := s.toolCatalog.Snapshot()
+	for _, spec := range current {
+		if spec.Name == name {
+			return nil, errToolExists
+		}
+	}
+	s.toolCatalog.Replace(append(current, t.spec))
+	delete(s.trash.tools, name)
+	return t.spec, nil
+}
+
+func (s *server) purgeTool(name string) error {
+	s.trash.mu.Lock()
+	defer s.trash.mu.Unlock()
+	if _, ok := s.trash.tools[name]; !ok {
+		return errTrashNotFound
+	}
+	delete(s.trash.tools, name)
+	return nil
+}
+
+// recordSoftDelete records who moved a resource to the trash.
+func (s *server) recordSoftDelete(r *http.Request, kind, id string) {
+	s.appendEvent(ccevent.AppendInput{
+		EventType: "resource.soft_deleted",
+		Data: map[string]any{
+			"kind":  kind,
+			"id":    id,
+			"actor": auditlog.ActorID(adminTokenFromRequest(r)),
+		},
+	})
+	s.purgeExpiredTrash()
+}
+
+// purgeExpiredTrash drops items older than the retention window. It runs
+// lazily on trash requests and deletes rather than on a timer.
+func (s *server) purgeExpiredTrash() {
+	cutoff := time.Now().Add(-s.trash.retention)
+	for name, kind := range s.trashKinds() {
+		for _, item := range kind.list() {
+			if !item.DeletedAt.Before(cutoff) {
+				continue
+			}
+			if err := kind.purge(item.ID); err == nil {
+				s.appendEvent(ccevent.AppendInput{
+					EventType: "resource.purged",
+					Data:      map[string]any{"kind": name, "id": item.ID, "reason": "retention"},
+				})
+			}
+		}
+	}
+}
+
+// handleAdminTrash lists soft-deleted resources, optionally of one kind.
+// GET /admin/trash?kind=channel|token|user|mcp_server|tool
+func (s *server) handleAdminTrash(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	if r.Method != http.MethodGet {
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+		return
+	}
+	s.purgeExpiredTrash()
+	kinds := s.trashKinds()
+	filter := strings.TrimSpace(r.URL.Query().Get("kind"))
+	if _, ok := kinds[filter]; filter != "" && !ok {
+		s.writeError(w, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("unknown trash kind %q", filter))
+		return
+	}
+	items := []trashItem{}
+	for name, kind := range kinds {
+		if filter != "" && name != filter {
+			continue
+		}
+		for _, item := range kind.list() {
+			items = append(items, s.trashEntry(name, item))
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if !items[i].DeletedAt.Equal(items[j].DeletedAt) {
+			return items[i].DeletedAt.After(items[j].DeletedAt)
+		}
+		return items[i].Kind+items[i].ID < items[j].Kind+items[j].ID
+	})
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"data":              items,
+		"retention_seconds": int64(s.trash.retention / time.Second),
+	})
+}
+
+// handleAdminTrashByPath serves one trashed resource.
+// GET    /admin/trash/{kind}/{id}
+// POST   /admin/trash/{kind}/{id}/restore
+// DELETE /admin/trash/{kind}/{id}  (purge)
+func (s *server) handleAdminTrashByPath(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/trash/"), "/"), "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" || (len(parts) == 3 && parts[2] != "restore") {
+		s.writeError(w, http.StatusNotFound, "not_found_error", "trash endpoint not found")
+		return
+	}
+	s.purgeExpiredTrash()
+	name, id := parts[0], parts[1]
+	kind, ok := s.trashKinds()[name]
+	if !ok {
+		s.writeError(w, http.StatusNotFound, "not_found_error", fmt.Sprintf("unknown trash kind %q", name))
+		return
+	}
+	actor := auditlog.ActorID(adminTokenFromRequest(r))
+
+	if len(parts) == 3 {
+		if r.Method != http.MethodPost {
+			s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+			return
+		}
+		restored, err := kind.restore(id)
+		if err != nil {
+			s.writeTrashError(w, err)
+			return
+		}
+		s.appendEvent(ccevent.AppendInput{
+			EventType: "resource.restored",
+			Data:      map[string]any{"kind": name, "id": id, "actor": actor},
+		})
+		w.Header().Set("content-type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(restored)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		for _, item := range kind.list() {
+			if item.ID == id {
+				w.Header().Set("content-type", "application/json")
+				w.WriteHeader(http.StatusOK)
+				_ = json.NewEncoder(w).Encode(s.trashEntry(name, item))
+				return
+			}
+		}
+		s.writeTrashError(w, errTrashNotFound)
+	case http.MethodDelete:
+		if err := kind.purge(id); err != nil {
+			s.writeTrashError(w, err)
+			return
+		}
+		s.appendEvent(ccevent.AppendInput{
+			EventType: "resource.purged",
+			Data:      map[string]any{"kind": name, "id": id, "actor": actor},
+		})
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+	}
+}
+
+func (s *server) trashEntry(kind string, item trashItem) trashItem {
+	item.Kind = kind
+	item.DeletedAt = item.DeletedAt.UTC()
+	item.ExpiresAt = item.DeletedAt.Add(s.trash.retention)
+	return item
+}
+
+func (s *server) writeTrashError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errToolExists), errors.Is(err, channel.ErrChannelExists),
+		errors.Is(err, auth.ErrUserAlreadyExists), errors.Is(err, mcpregistry.ErrAlreadyExists):
+		s.writeError(w, http.StatusConflict, "invalid_request_error", err.Error())
+	default:
+		s.writeError(w, http.StatusNotFound, "not_found_error", "resource not found in trash")
+	}
+}
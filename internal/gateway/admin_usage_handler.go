@@ -0,0 +1,257 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"ccgateway/internal/billing"
+	"ccgateway/internal/ccevent"
+	"ccgateway/internal/ccrun"
+	"ccgateway/internal/costtrack"
+	"ccgateway/internal/orchestrator"
+	"ccgateway/internal/requestctx"
+	"ccgateway/internal/token"
+)
+
+// recordUsage writes one finished request to the usage ledger. servedModel
+// and adapter come from the response trace when the request was not
+// streamed; streamed requests fall back to the mapped upstream model.
+func (s *server) recordUsage(ctx context.Context, creq orchestrator.Request, usage orchestrator.Usage, servedModel, adapter string, stream bool) {
+	if s.usageLedger == nil {
+		return
+	}
+	rec := billing.UsageRecord{
+		RunID:         creq.RunID,
+		UserID:        requestUserID(ctx),
+		ProjectID:     projectIDFromContext(ctx),
+		UpstreamModel: strings.TrimSpace(servedModel),
+		Adapter:       strings.TrimSpace(adapter),
+		Stream:        stream,
+		InputTokens:   usage.InputTokens,
+		OutputTokens:  usage.OutputTokens,
+	}
+	if tk, ok := ctx.Value(tokenContextKey).(*token.Token); ok && tk != nil {
+		rec.TokenID = tk.ID
+		rec.TokenName = tk.Name
+	}
+	if v, ok := creq.Metadata["request_path"].(string); ok {
+		rec.Path = v
+	}
+	if v, ok := creq.Metadata["client_model"].(string); ok {
+		rec.ClientModel = v
+	}
+	if rec.UpstreamModel == "" {
+		if v, ok := creq.Metadata["upstream_model"].(string); ok && strings.TrimSpace(v) != "" {
+			rec.UpstreamModel = strings.TrimSpace(v)
+		} else {
+			rec.UpstreamModel = creq.Model
+		}
+	}
+	rec.Group = s.resolveUserGroup(ctx)
+	channelPrice := s.servingChannelPrice(creq.Metadata, &rec)
+	saved, err := s.usageLedger.RecordServed(rec, channelPrice)
+	if err != nil || creq.RunID == "" {
+		return
+	}
+	if coster, ok := s.runStore.(interface {
+		RecordCost(id string, costUSD float64) (ccrun.Run, error)
+	}); ok {
+		_, _ = coster.RecordCost(creq.RunID, saved.CostUSD)
+	}
+}
+
+// servingChannelPrice sets rec.ChannelID when a channel served the request
+// and returns its prices when it has any. A request that fell back to a
+// different adapter than the routed channel is billed at model prices.
+func (s *server) servingChannelPrice(metadata map[string]any, rec *billing.UsageRecord) *costtrack.ModelPricing {
+	id := routedChannelID(metadata)
+	if id == 0 || s.channelStore == nil {
+		return nil
+	}
+	ch, ok := s.channelStore.GetChannel(id)
+	if !ok || (rec.Adapter != "" && !strings.EqualFold(rec.Adapter, strings.TrimSpace(ch.Name))) {
+		return nil
+	}
+	rec.ChannelID = id
+	if !ch.HasPricing() {
+		return nil
+	}
+	return &costtrack.ModelPricing{
+		InputPer1M:  ch.PromptPricePer1K * 1000,
+		OutputPer1M: ch.CompletionPricePer1K * 1000,
+	}
+}
+
+// handleAdminUsage reads the usage ledger. Without group_by it lists raw
+// records; with group_by it returns per-user, per-token, per-model or
+// per-day totals. format=csv returns the same rows as CSV.
+// GET /admin/usage?group_by=&since=&until=&user_id=&project_id=&token_id=&model=&limit=&offset=&format=
+func (s *server) handleAdminUsage(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	if s.usageLedger == nil {
+		s.writeError(w, http.StatusNotImplemented, "api_error", "usage ledger is not configured")
+		return
+	}
+	if r.Method != http.MethodGet {
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+		return
+	}
+	values := r.URL.Query()
+	q := billing.UsageQuery{
+		UserID: strings.TrimSpace(values.Get("user_id")),
+		Model:  strings.TrimSpace(values.Get("model")),
+		Limit:  100,
+	}
+	if raw := strings.TrimSpace(values.Get("project_id")); raw != "" {
+		q.ProjectID = requestctx.NormalizeProjectID(raw)
+	}
+	if raw := strings.TrimSpace(values.Get("token_id")); raw != "" {
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || n <= 0 {
+			s.writeError(w, http.StatusBadRequest, "invalid_request_error", "token_id must be a positive integer")
+			return
+		}
+		q.TokenID = n
+	}
+	page, err := parseAdminListQuery(values, adminUsageListSpec)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	q.Limit, q.Offset = page.Limit, page.Offset
+	for _, bound := range []struct {
+		name string
+		dst  *time.Time
+	}{{"since", &q.Since}, {"until", &q.Until}} {
+		raw := strings.TrimSpace(values.Get(bound.name))
+		if raw == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, "invalid_request_error", bound.name+" must be an RFC3339 timestamp")
+			return
+		}
+		*bound.dst = t
+	}
+	format := strings.ToLower(strings.TrimSpace(values.Get("format")))
+	if format != "" && format != "json" && format != "csv" {
+		s.writeError(w, http.StatusBadRequest, "invalid_request_error", "format must be json or csv")
+		return
+	}
+	csvOut := format == "csv"
+
+	groupBy := strings.ToLower(strings.TrimSpace(values.Get("group_by")))
+	if groupBy != "" {
+		groups, totals, err := s.usageLedger.Summarize(q, groupBy)
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+			return
+		}
+		if csvOut {
+			writeUsageCSVHeaders(w, "usage-by-"+groupBy+".csv")
+			_ = billing.WriteGroupsCSV(w, groupBy, groups)
+			return
+		}
+		w.Header().Set("content-type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"group_by": groupBy,
+			"data":     groups,
+			"total":    len(groups),
+			"totals":   totals,
+		})
+		return
+	}
+
+	if csvOut {
+		// Exports are not paginated unless the caller asks for it.
+		if strings.TrimSpace(values.Get("limit")) == "" {
+			q.Limit = 0
+		}
+		records, _ := s.usageLedger.List(q)
+		writeUsageCSVHeaders(w, "usage.csv")
+		_ = billing.WriteRecordsCSV(w, records)
+		return
+	}
+	records, total := s.usageLedger.List(q)
+	s.writeAdminList(w, page, records, len(records), total)
+}
+
+// Usage records are paged newest first by the ledger itself.
+var adminUsageListSpec = adminListSpec{
+	DefaultLimit: 100,
+	MaxLimit:     10000,
+	Filters:      []string{"user_id", "model", "project_id", "token_id", "since", "until"},
+}
+
+// handleAdminUsagePrices reads or replaces the price table and the group
+// ratios used to cost new usage records. Recorded costs are not recomputed.
+// GET/PUT /admin/usage/prices
+func (s *server) handleAdminUsagePrices(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	if s.usageLedger == nil {
+		s.writeError(w, http.StatusNotImplemented, "api_error", "usage ledger is not configured")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("content-type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(map[string]any{"prices": s.usageLedger.Prices(), "group_ratios": s.usageLedger.GroupRatios()})
+	case http.MethodPut:
+		var req struct {
+			Prices      map[string]costtrack.ModelPricing `json:"prices"`
+			GroupRatios map[string]float64                `json:"group_ratios"`
+		}
+		if err := decodeJSONBodyStrict(r, &req, false); err != nil {
+			s.reportRequestDecodeIssue(r, err)
+			s.writeError(w, http.StatusBadRequest, "invalid_request_error", "invalid JSON body")
+			return
+		}
+		if len(req.Prices) == 0 && req.GroupRatios == nil {
+			s.writeError(w, http.StatusBadRequest, "invalid_request_error", "prices or group_ratios is required")
+			return
+		}
+		// Validate both before applying either so a bad ratio leaves the
+		// price table untouched.
+		if req.GroupRatios != nil {
+			if err := billing.ValidateGroupRatios(req.GroupRatios); err != nil {
+				s.writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+				return
+			}
+		}
+		if len(req.Prices) > 0 {
+			if err := s.usageLedger.SetPrices(req.Prices); err != nil {
+				s.writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+				return
+			}
+		}
+		if req.GroupRatios != nil {
+			_ = s.usageLedger.SetGroupRatios(req.GroupRatios)
+		}
+		s.appendEvent(ccevent.AppendInput{
+			EventType: "billing.prices_updated",
+			Data:      map[string]any{"models": len(req.Prices), "group_ratios": len(req.GroupRatios)},
+		})
+		w.Header().Set("content-type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(map[string]any{"prices": s.usageLedger.Prices(), "group_ratios": s.usageLedger.GroupRatios()})
+	default:
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+	}
+}
+
+func writeUsageCSVHeaders(w http.ResponseWriter, filename string) {
+	w.Header().Set("content-type", "text/csv; charset=utf-8")
+	w.Header().Set("content-disposition", `attachment; filename="`+filename+`"`)
+	w.WriteHeader(http.StatusOK)
+}
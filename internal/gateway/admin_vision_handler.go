@@ -0,0 +1,43 @@
+package gateway
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"ccgateway/internal/imageproc"
+)
+
+// handleAdminVisionImages reads or patches the vision image preprocessing
+// limits (max dimension/bytes, JPEG quality, URL proxying).
+// GET/PUT /admin/vision/images
+func (s *server) handleAdminVisionImages(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	if s.imageProcessor == nil {
+		s.writeError(w, http.StatusNotImplemented, "api_error", "image preprocessing is not configured")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var patch imageproc.ConfigPatch
+		if err := decodeJSONBodyStrict(r, &patch, false); err != nil {
+			s.reportRequestDecodeIssue(r, err)
+			s.writeError(w, http.StatusBadRequest, "invalid_request_error", "invalid JSON body")
+			return
+		}
+		if _, err := s.imageProcessor.UpdateConfigPatch(patch); err != nil {
+			s.writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+			return
+		}
+	default:
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+		return
+	}
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"images": s.imageProcessor.Config(),
+	})
+}
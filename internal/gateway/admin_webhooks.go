@@ -0,0 +1,182 @@
+package gateway
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"ccgateway/internal/auditlog"
+	"ccgateway/internal/ccevent"
+	"ccgateway/internal/webhook"
+)
+
+// handleAdminWebhooks lists or creates webhook subscriptions.
+// GET/POST /admin/webhooks
+func (s *server) handleAdminWebhooks(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	if s.webhooks == nil {
+		s.writeError(w, http.StatusNotImplemented, "api_error", "webhooks are not configured")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		subs := s.webhooks.List()
+		w.Header().Set("content-type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"data":  subs,
+			"total": len(subs),
+		})
+	case http.MethodPost:
+		var in webhook.Input
+		if err := decodeJSONBodyStrict(r, &in, false); err != nil {
+			s.reportRequestDecodeIssue(r, err)
+			s.writeError(w, http.StatusBadRequest, "invalid_request_error", "invalid JSON body")
+			return
+		}
+		sub, err := s.webhooks.Create(in)
+		if err != nil {
+			s.writeWebhookError(w, err)
+			return
+		}
+		s.appendWebhookEvent(r, "webhook.created", sub)
+		w.Header().Set("content-type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(sub)
+	default:
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+	}
+}
+
+// handleAdminWebhookByPath manages one subscription and its deliveries.
+// GET/PUT/DELETE /admin/webhooks/{id}, GET /admin/webhooks/{id}/deliveries,
+// POST /admin/webhooks/{id}/test, GET /admin/webhooks/deliveries
+func (s *server) handleAdminWebhookByPath(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	if s.webhooks == nil {
+		s.writeError(w, http.StatusNotImplemented, "api_error", "webhooks are not configured")
+		return
+	}
+	id, action, _ := strings.Cut(strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/webhooks/"), "/"), "/")
+	if id == "deliveries" && action == "" {
+		s.handleAdminWebhookDeliveries(w, r, "")
+		return
+	}
+	current, ok := s.webhooks.Get(id)
+	if !ok {
+		s.writeError(w, http.StatusNotFound, "not_found_error", webhook.ErrNotFound.Error())
+		return
+	}
+	switch action {
+	case "":
+	case "deliveries":
+		s.handleAdminWebhookDeliveries(w, r, current.ID)
+		return
+	case "test":
+		if r.Method != http.MethodPost {
+			s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+			return
+		}
+		delivery, err := s.webhooks.Test(current.ID)
+		if err != nil {
+			s.writeWebhookError(w, err)
+			return
+		}
+		w.Header().Set("content-type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		_ = json.NewEncoder(w).Encode(delivery)
+		return
+	default:
+		s.writeError(w, http.StatusNotFound, "not_found_error", "unknown webhook action")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("content-type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(current)
+	case http.MethodPut:
+		var in webhook.Input
+		if err := decodeJSONBodyStrict(r, &in, false); err != nil {
+			s.reportRequestDecodeIssue(r, err)
+			s.writeError(w, http.StatusBadRequest, "invalid_request_error", "invalid JSON body")
+			return
+		}
+		sub, err := s.webhooks.Update(current.ID, in)
+		if err != nil {
+			s.writeWebhookError(w, err)
+			return
+		}
+		s.appendWebhookEvent(r, "webhook.updated", sub)
+		w.Header().Set("content-type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(sub)
+	case http.MethodDelete:
+		if err := s.webhooks.Delete(current.ID); err != nil {
+			s.writeWebhookError(w, err)
+			return
+		}
+		s.appendWebhookEvent(r, "webhook.deleted", current)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+	}
+}
+
+func (s *server) handleAdminWebhookDeliveries(w http.ResponseWriter, r *http.Request, subscriptionID string) {
+	if r.Method != http.MethodGet {
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+		return
+	}
+	limit, ok := parseNonNegativeInt(r.URL.Query().Get("limit"))
+	if !ok {
+		s.writeError(w, http.StatusBadRequest, "invalid_request_error", "limit must be an integer >= 0")
+		return
+	}
+	if limit == 0 {
+		limit = 100
+	}
+	deliveries := s.webhooks.Deliveries(webhook.DeliveryFilter{
+		SubscriptionID: subscriptionID,
+		Status:         r.URL.Query().Get("status"),
+		Limit:          limit,
+	})
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"data":  deliveries,
+		"total": len(deliveries),
+	})
+}
+
+func (s *server) writeWebhookError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, webhook.ErrNotFound):
+		s.writeError(w, http.StatusNotFound, "not_found_error", err.Error())
+	case errors.Is(err, webhook.ErrInvalidURL), errors.Is(err, webhook.ErrNoEvents),
+		errors.Is(err, webhook.ErrInvalidFormat), errors.Is(err, webhook.ErrInvalidTemplate):
+		s.writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+	default:
+		s.writeError(w, http.StatusInternalServerError, "api_error", err.Error())
+	}
+}
+
+func (s *server) appendWebhookEvent(r *http.Request, eventType string, sub webhook.Subscription) {
+	s.appendEvent(ccevent.AppendInput{
+		EventType: eventType,
+		Data: map[string]any{
+			"webhook_id": sub.ID,
+			"url":        sub.URL,
+			"events":     sub.Events,
+			"format":     sub.Format,
+			"enabled":    sub.Enabled,
+			"actor":      auditlog.ActorID(adminTokenFromRequest(r)),
+		},
+	})
+}
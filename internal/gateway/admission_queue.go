@@ -0,0 +1,59 @@
+package gateway
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"ccgateway/internal/orchestrator"
+	"ccgateway/internal/upstream"
+)
+
+type admissionReporter interface {
+	AdmissionStatus() []upstream.AdmissionStatus
+}
+
+// admissionPriority ranks the request in the queues of adapters at their
+// max_concurrency: the priority class when the caller sent one, otherwise
+// the tier of the token's user group.
+func (s *server) admissionPriority(r *http.Request, metadata map[string]any) int {
+	if s.settings == nil {
+		return 0
+	}
+	class := requestPriorityClass(r, metadata, "")
+	return s.settings.Get().Routing.Admission.Priority(class, s.resolveUserGroup(r.Context()))
+}
+
+// admissionStatus reports queue depth and wait times per limited adapter
+// for /admin/status.
+func (s *server) admissionStatus() []upstream.AdmissionStatus {
+	if reporter, ok := s.orchestrator.(admissionReporter); ok {
+		return reporter.AdmissionStatus()
+	}
+	return nil
+}
+
+// queuePingPayload is the Anthropic "ping" event sent while the request
+// waits for an upstream slot.
+func queuePingPayload(ev orchestrator.StreamEvent) map[string]any {
+	payload := map[string]any{"type": "ping"}
+	if ev.Queue != nil {
+		payload["queue"] = map[string]any{
+			"adapter":   ev.Queue.Adapter,
+			"depth":     ev.Queue.Depth,
+			"waited_ms": ev.Queue.Waited.Milliseconds(),
+		}
+	}
+	return payload
+}
+
+// writeQueueComment keeps an OpenAI-style stream alive with an SSE comment,
+// which clients ignore.
+func writeQueueComment(w io.Writer, ev orchestrator.StreamEvent) error {
+	text := "ping"
+	if ev.Queue != nil {
+		text = fmt.Sprintf("ping queued adapter=%s depth=%d waited_ms=%d", ev.Queue.Adapter, ev.Queue.Depth, ev.Queue.Waited.Milliseconds())
+	}
+	_, err := fmt.Fprintf(w, ": %s\n\n", text)
+	return err
+}
@@ -0,0 +1,40 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"ccgateway/internal/ccevent"
+)
+
+// watchAnomalies feeds one adapter call into the anomaly detector and
+// publishes its findings as anomaly.detected events, which webhooks
+// subscribed to that type deliver as well. Calls the client abandoned say
+// nothing about the adapter and are left out.
+func (s *server) watchAnomalies(adapter string, err error) {
+	adapter = strings.TrimSpace(adapter)
+	if s.anomalies == nil || adapter == "" {
+		return
+	}
+	if err != nil && (errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)) {
+		return
+	}
+	for _, f := range s.anomalies.Observe(adapter, err != nil, time.Now()) {
+		s.appendEvent(ccevent.AppendInput{
+			EventType: "anomaly.detected",
+			Data: map[string]any{
+				"adapter":      f.Adapter,
+				"kind":         string(f.Kind),
+				"bucket_start": f.BucketStart,
+				"observed":     f.Observed,
+				"baseline":     f.Baseline,
+				"stddev":       f.StdDev,
+				"z_score":      f.ZScore,
+				"requests":     f.Requests,
+				"errors":       f.Errors,
+			},
+		})
+	}
+}
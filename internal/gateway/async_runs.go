@@ -0,0 +1,442 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"ccgateway/internal/ccevent"
+	"ccgateway/internal/ccrun"
+)
+
+const (
+	defaultAsyncRunWorkers   = 4
+	defaultAsyncRunQueueSize = 256
+	defaultAsyncRunRetention = time.Hour
+)
+
+// Async run states. A job is queued until a worker picks it up and ends in
+// one of completed, failed or canceled.
+const (
+	asyncRunQueued    = "queued"
+	asyncRunRunning   = "running"
+	asyncRunCompleted = "completed"
+	asyncRunFailed    = "failed"
+	asyncRunCanceled  = "canceled"
+)
+
+// reservedRunIDKey carries a run id handed out before the handler runs, as
+// for async and scheduled runs.
+const reservedRunIDKey contextKey = "reserved_run_id"
+
+// AsyncRunConfig sizes the worker pool behind ?async=true requests.
+type AsyncRunConfig struct {
+	// Workers is how many async runs execute at once (default 4).
+	Workers int
+	// QueueSize bounds runs waiting for a worker (default 256); submits
+	// beyond it are rejected with 503.
+	QueueSize int
+	// Retention is how long a finished run's result stays pollable
+	// (default 1h).
+	Retention time.Duration
+}
+
+type asyncRunJob struct {
+	id        string
+	path      string
+	sessionID string
+	req       *http.Request
+	handler   http.HandlerFunc
+	cancel    context.CancelFunc
+	// projectID and userID are the submitter's; only they see the run.
+	projectID string
+	userID    string
+
+	status     string
+	statusCode int
+	result     json.RawMessage
+	errText    string
+	queuedAt   time.Time
+	startedAt  time.Time
+	finishedAt time.Time
+}
+
+// asyncRunView is the async part of GET /v1/cc/runs/{id}.
+type asyncRunView struct {
+	Status     string          `json:"status"`
+	StatusCode int             `json:"status_code,omitempty"`
+	Result     json.RawMessage `json:"result,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	QueuedAt   time.Time       `json:"queued_at"`
+	StartedAt  *time.Time      `json:"started_at,omitempty"`
+	FinishedAt *time.Time      `json:"finished_at,omitempty"`
+}
+
+type asyncRunResponse struct {
+	ccrun.Run
+	Async asyncRunView `json:"async"`
+}
+
+type asyncRunQueue struct {
+	cfg   AsyncRunConfig
+	start sync.Once
+	queue chan *asyncRunJob
+
+	mu   sync.Mutex
+	jobs map[string]*asyncRunJob
+}
+
+func newAsyncRunQueue(cfg AsyncRunConfig) *asyncRunQueue {
+	if cfg.Workers <= 0 {
+		cfg.Workers = defaultAsyncRunWorkers
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = defaultAsyncRunQueueSize
+	}
+	if cfg.Retention <= 0 {
+		cfg.Retention = defaultAsyncRunRetention
+	}
+	return &asyncRunQueue{
+		cfg:   cfg,
+		queue: make(chan *asyncRunJob, cfg.QueueSize),
+		jobs:  map[string]*asyncRunJob{},
+	}
+}
+
+// submit enqueues job, starting the workers on first use. It reports false
+// when the queue is full.
+func (q *asyncRunQueue) submit(job *asyncRunJob) bool {
+	q.start.Do(func() {
+		for i := 0; i < q.cfg.Workers; i++ {
+			go q.work()
+		}
+	})
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.pruneLocked(time.Now())
+	select {
+	case q.queue <- job:
+		q.jobs[job.id] = job
+		return true
+	default:
+		return false
+	}
+}
+
+func (q *asyncRunQueue) work() {
+	for job := range q.queue {
+		q.mu.Lock()
+		if job.status != asyncRunQueued {
+			q.mu.Unlock()
+			continue
+		}
+		job.status = asyncRunRunning
+		job.startedAt = time.Now().UTC()
+		q.mu.Unlock()
+
+		rec := &asyncResponseWriter{header: http.Header{}, status: http.StatusOK}
+		job.handler(rec, job.req)
+		canceled := job.req.Context().Err() != nil
+		job.cancel()
+
+		q.mu.Lock()
+		job.finishedAt = time.Now().UTC()
+		job.statusCode = rec.status
+		if body := bytes.TrimSpace(rec.body.Bytes()); json.Valid(body) {
+			job.result = append(json.RawMessage(nil), body...)
+		}
+		switch {
+		case canceled:
+			job.status = asyncRunCanceled
+		case rec.status >= 400:
+			job.status = asyncRunFailed
+			job.errText = asyncErrorMessage(job.result)
+		default:
+			job.status = asyncRunCompleted
+		}
+		q.mu.Unlock()
+	}
+}
+
+func (q *asyncRunQueue) get(id string) (asyncRunJob, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.pruneLocked(time.Now())
+	job, ok := q.jobs[id]
+	if !ok {
+		return asyncRunJob{}, false
+	}
+	return *job, true
+}
+
+// cancel stops a queued or running job. It reports whether the job was
+// still active.
+func (q *asyncRunQueue) cancel(id string) (asyncRunJob, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job, ok := q.jobs[id]
+	if !ok {
+		return asyncRunJob{}, false
+	}
+	switch job.status {
+	case asyncRunQueued:
+		job.status = asyncRunCanceled
+		job.finishedAt = time.Now().UTC()
+	case asyncRunRunning:
+	default:
+		return *job, false
+	}
+	job.cancel()
+	return *job, true
+}
+
+// pruneLocked forgets finished jobs older than the retention.
+func (q *asyncRunQueue) pruneLocked(now time.Time) {
+	cutoff := now.Add(-q.cfg.Retention)
+	for id, job := range q.jobs {
+		if !job.finishedAt.IsZero() && job.finishedAt.Before(cutoff) {
+			delete(q.jobs, id)
+		}
+	}
+}
+
+func (j asyncRunJob) view() asyncRunView {
+	out := asyncRunView{
+		Status:     j.status,
+		StatusCode: j.statusCode,
+		Result:     j.result,
+		Error:      j.errText,
+		QueuedAt:   j.queuedAt,
+	}
+	if !j.startedAt.IsZero() {
+		t := j.startedAt
+		out.StartedAt = &t
+	}
+	if !j.finishedAt.IsZero() {
+		t := j.finishedAt
+		out.FinishedAt = &t
+	}
+	return out
+}
+
+func asyncErrorMessage(body json.RawMessage) string {
+	var env ErrorEnvelope
+	if json.Unmarshal(body, &env) == nil {
+		return env.Error.Message
+	}
+	return ""
+}
+
+// asyncResponseWriter buffers a handler's response for later polling.
+type asyncResponseWriter struct {
+	header http.Header
+	status int
+	wrote  bool
+	body   bytes.Buffer
+}
+
+func (w *asyncResponseWriter) Header() http.Header { return w.header }
+
+func (w *asyncResponseWriter) WriteHeader(status int) {
+	if !w.wrote {
+		w.wrote = true
+		w.status = status
+	}
+}
+
+func (w *asyncResponseWriter) Write(p []byte) (int, error) {
+	w.wrote = true
+	return w.body.Write(p)
+}
+
+// withAsyncRun runs requests carrying ?async=true in the background: the
+// caller gets a run id at once and polls GET /v1/cc/runs/{id} for the
+// result or cancels with DELETE.
+func (s *server) withAsyncRun(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		async, _ := strconv.ParseBool(r.URL.Query().Get("async"))
+		if !async {
+			next(w, r)
+			return
+		}
+		s.submitAsyncRun(w, r, next)
+	}
+}
+
+func (s *server) submitAsyncRun(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	if r.Method != http.MethodPost {
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, "invalid_request_error", "invalid JSON body")
+		return
+	}
+	var probe struct {
+		Stream   bool           `json:"stream"`
+		Metadata map[string]any `json:"metadata"`
+	}
+	if json.Unmarshal(body, &probe) == nil && probe.Stream {
+		s.writeError(w, http.StatusBadRequest, "invalid_request_error", "async runs cannot stream; drop stream or async")
+		return
+	}
+
+	runID := s.nextID("run")
+	// The run outlives this request, so it keeps the caller's context
+	// values (auth, project, token) but not its cancellation.
+	ctx, cancel := context.WithCancel(context.WithoutCancel(r.Context()))
+	ctx = context.WithValue(ctx, reservedRunIDKey, runID)
+	req := r.Clone(ctx)
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+	query := req.URL.Query()
+	query.Del("async")
+	req.URL.RawQuery = query.Encode()
+
+	job := &asyncRunJob{
+		id:        runID,
+		path:      r.URL.Path,
+		sessionID: requestSessionID(r, probe.Metadata),
+		req:       req,
+		handler:   next,
+		cancel:    cancel,
+		status:    asyncRunQueued,
+		queuedAt:  time.Now().UTC(),
+		projectID: projectIDFromContext(r.Context()),
+		userID:    requestUserID(r.Context()),
+	}
+	if !s.asyncRuns.submit(job) {
+		cancel()
+		s.writeError(w, http.StatusServiceUnavailable, "overloaded_error", "async run queue is full; retry later")
+		return
+	}
+	s.appendEvent(ccevent.AppendInput{
+		EventType: "run.queued",
+		SessionID: job.sessionID,
+		RunID:     runID,
+		Data: map[string]any{
+			"path": r.URL.Path,
+		},
+	})
+	w.Header().Set("content-type", "application/json")
+	w.Header().Set("x-cc-run-id", runID)
+	w.Header().Set("location", "/v1/cc/runs/"+runID)
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"id":     runID,
+		"type":   "run",
+		"path":   r.URL.Path,
+		"status": asyncRunQueued,
+	})
+}
+
+// newRunID returns the id reserved for this request, or a fresh one.
+func (s *server) newRunID(r *http.Request) string {
+	if id, ok := r.Context().Value(reservedRunIDKey).(string); ok && id != "" {
+		return id
+	}
+	return s.nextID("run")
+}
+
+type runCanceler interface {
+	Cancel(id, reason string) (ccrun.Run, error)
+}
+
+func (j asyncRunJob) visibleTo(projectID, userID string) bool {
+	return j.projectID == projectID && j.userID == userID
+}
+
+// serveAsyncRun answers GET and DELETE /v1/cc/runs/{id} for async runs. It
+// reports false when id is not an async run. Runs submitted from another
+// project or by another user are not found.
+func (s *server) serveAsyncRun(w http.ResponseWriter, r *http.Request, id string) bool {
+	job, ok := s.asyncRuns.get(id)
+	if !ok {
+		return false
+	}
+	if !job.visibleTo(projectIDFromContext(r.Context()), requestUserID(r.Context())) {
+		s.writeError(w, http.StatusNotFound, "not_found_error", "run not found")
+		return true
+	}
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodDelete:
+		job, active := s.asyncRuns.cancel(id)
+		if !active {
+			s.writeError(w, http.StatusConflict, "invalid_request_error", "run already finished")
+			return true
+		}
+		if canceler, ok := s.runStore.(runCanceler); ok {
+			_, _ = canceler.Cancel(id, "canceled by client")
+		}
+		s.appendEvent(ccevent.AppendInput{
+			EventType: "run.canceled",
+			SessionID: job.sessionID,
+			RunID:     id,
+			Data: map[string]any{
+				"path":   job.path,
+				"status": job.status,
+			},
+		})
+	default:
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+		return true
+	}
+
+	job, _ = s.asyncRuns.get(id)
+	out := asyncRunResponse{Async: job.view()}
+	if s.runStore != nil {
+		out.Run, _ = s.runStore.Get(id)
+	}
+	if out.Run.ID == "" {
+		out.Run = ccrun.Run{ID: id, Type: "run", Path: job.path, Status: ccrun.Status(job.status), CreatedAt: job.queuedAt}
+	}
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(out)
+	return true
+}
+
+// modelHandler returns the handler behind a model endpoint path.
+func (s *server) modelHandler(path string) (http.HandlerFunc, bool) {
+	switch path {
+	case "/v1/messages":
+		return s.handleMessages, true
+	case "/v1/chat/completions":
+		return s.handleOpenAIChatCompletions, true
+	case "/v1/responses":
+		return s.handleOpenAIResponses, true
+	}
+	return nil, false
+}
+
+// dispatchInternal posts body to a model endpoint through its regular
+// handler under a reserved run id, so gateway-initiated requests are
+// recorded, shed and billed like client ones. ctx supplies the caller's
+// credentials, if any. It reports false for an unknown path.
+func (s *server) dispatchInternal(ctx context.Context, path string, body []byte, header http.Header) (string, *asyncResponseWriter, bool) {
+	handler, ok := s.modelHandler(path)
+	if !ok {
+		return "", nil, false
+	}
+	runID := s.nextID("run")
+	rec := &asyncResponseWriter{header: http.Header{}, status: http.StatusOK}
+	req, err := http.NewRequestWithContext(context.WithValue(ctx, reservedRunIDKey, runID), http.MethodPost, path, bytes.NewReader(body))
+	if err != nil {
+		rec.status = http.StatusInternalServerError
+		return runID, rec, true
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("content-type", "application/json")
+	req.Header.Set("anthropic-version", "2023-06-01")
+	s.withTokenQuota(s.withLoadTracking(handler))(rec, req)
+	return runID, rec, true
+}
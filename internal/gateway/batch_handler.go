@@ -0,0 +1,264 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"ccgateway/internal/batch"
+	"ccgateway/internal/ccevent"
+)
+
+// batchMaxUploadBytes caps a batch input file, as OpenAI does.
+const batchMaxUploadBytes = 200 << 20
+
+// batchPriorityClass is the x-cc-priority batch requests run under, so
+// admission and load shedding can rank them below interactive traffic.
+const batchPriorityClass = "batch"
+
+type batchCreateRequest struct {
+	Endpoint         string            `json:"endpoint"`
+	InputFileID      string            `json:"input_file_id,omitempty"`
+	CompletionWindow string            `json:"completion_window,omitempty"`
+	Metadata         map[string]string `json:"metadata,omitempty"`
+	Requests         []batch.Line      `json:"requests,omitempty"`
+}
+
+// handleBatches creates and lists batches.
+// POST /v1/batches takes JSON naming an uploaded input_file_id (purpose
+// batch) or carrying the lines inline under "requests", multipart/form-data
+// (a "file" part with the JSONL input plus endpoint, completion_window and
+// metadata fields), or a raw JSONL body with endpoint and
+// completion_window in the query.
+// GET /v1/batches?limit=&after=
+func (s *server) handleBatches(w http.ResponseWriter, r *http.Request) {
+	if s.batches == nil {
+		s.writeError(w, http.StatusNotImplemented, "api_error", "batches are not configured")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		if limit <= 0 || limit > 100 {
+			limit = 20
+		}
+		items, hasMore := s.batches.List(batch.ListFilter{
+			Owner: requestOwner(r.Context()),
+			After: strings.TrimSpace(r.URL.Query().Get("after")),
+			Limit: limit,
+		})
+		if items == nil {
+			items = []batch.Batch{}
+		}
+		out := map[string]any{"object": "list", "data": items, "first_id": nil, "last_id": nil, "has_more": hasMore}
+		if len(items) > 0 {
+			out["first_id"] = items[0].ID
+			out["last_id"] = items[len(items)-1].ID
+		}
+		w.Header().Set("content-type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(out)
+	case http.MethodPost:
+		in, err := s.readBatchCreate(w, r)
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+			return
+		}
+		b, err := s.batches.Create(r.Context(), in)
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+			return
+		}
+		s.appendEvent(ccevent.AppendInput{
+			EventType: "batch.created",
+			Data: map[string]any{
+				"batch_id": b.ID,
+				"endpoint": b.Endpoint,
+				"status":   b.Status,
+				"total":    b.RequestCounts.Total,
+			},
+		})
+		w.Header().Set("content-type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(b)
+	default:
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+	}
+}
+
+// handleBatchByPath serves one batch.
+// GET /v1/batches/{id}
+// POST /v1/batches/{id}/cancel
+// GET /v1/batches/{id}/output and /v1/batches/{id}/errors download the
+// result JSONL once the batch has finished.
+func (s *server) handleBatchByPath(w http.ResponseWriter, r *http.Request) {
+	if s.batches == nil {
+		s.writeError(w, http.StatusNotImplemented, "api_error", "batches are not configured")
+		return
+	}
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/v1/batches/"), "/")
+	id, action, _ := strings.Cut(rest, "/")
+	if id == "" {
+		s.writeError(w, http.StatusNotFound, "not_found_error", "batch id is required")
+		return
+	}
+	owner := requestOwner(r.Context())
+	switch action {
+	case "":
+		if r.Method != http.MethodGet {
+			s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+			return
+		}
+		b, ok := s.batches.Get(owner, id)
+		if !ok {
+			s.writeError(w, http.StatusNotFound, "not_found_error", batch.ErrNotFound.Error())
+			return
+		}
+		w.Header().Set("content-type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(b)
+	case "cancel":
+		if r.Method != http.MethodPost {
+			s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+			return
+		}
+		b, err := s.batches.Cancel(owner, id)
+		if err != nil {
+			s.writeBatchError(w, err)
+			return
+		}
+		s.appendEvent(ccevent.AppendInput{
+			EventType: "batch.cancelled",
+			Data:      map[string]any{"batch_id": b.ID},
+		})
+		w.Header().Set("content-type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(b)
+	case "output", "errors":
+		if r.Method != http.MethodGet {
+			s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+			return
+		}
+		data, err := s.batches.Results(owner, id, action == "errors")
+		if err != nil {
+			s.writeBatchError(w, err)
+			return
+		}
+		w.Header().Set("content-type", "application/jsonl")
+		w.Header().Set("content-disposition", `attachment; filename="`+id+"_"+action+`.jsonl"`)
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(data)
+	default:
+		s.writeError(w, http.StatusNotFound, "not_found_error", "unknown batch action")
+	}
+}
+
+func (s *server) writeBatchError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, batch.ErrNotFound):
+		s.writeError(w, http.StatusNotFound, "not_found_error", err.Error())
+	default:
+		s.writeError(w, http.StatusConflict, "invalid_request_error", err.Error())
+	}
+}
+
+func (s *server) readBatchCreate(w http.ResponseWriter, r *http.Request) (batch.CreateInput, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, batchMaxUploadBytes)
+	in := batch.CreateInput{
+		Endpoint:         r.URL.Query().Get("endpoint"),
+		CompletionWindow: r.URL.Query().Get("completion_window"),
+		Owner:            requestOwner(r.Context()),
+		ProjectID:        projectIDFromContext(r.Context()),
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("content-type"))
+	switch mediaType {
+	case "multipart/form-data":
+		if err := r.ParseMultipartForm(32 << 20); err != nil {
+			return in, errors.New("invalid multipart body")
+		}
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			return in, errors.New("file is required")
+		}
+		defer file.Close()
+		if in.Input, err = io.ReadAll(file); err != nil {
+			return in, errors.New("could not read file")
+		}
+		if v := r.FormValue("endpoint"); v != "" {
+			in.Endpoint = v
+		}
+		if v := r.FormValue("completion_window"); v != "" {
+			in.CompletionWindow = v
+		}
+		if v := r.FormValue("metadata"); v != "" {
+			if err := json.Unmarshal([]byte(v), &in.Metadata); err != nil {
+				return in, errors.New("metadata must be a JSON object of strings")
+			}
+		}
+	case "application/jsonl", "application/x-ndjson", "application/x-jsonlines":
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			return in, errors.New("could not read body")
+		}
+		in.Input = data
+	default:
+		var req batchCreateRequest
+		if err := decodeJSONBodyStrict(r, &req, false); err != nil {
+			s.reportRequestDecodeIssue(r, err)
+			return in, errors.New("invalid JSON body")
+		}
+		in.Endpoint = req.Endpoint
+		in.CompletionWindow = req.CompletionWindow
+		in.Metadata = req.Metadata
+		if id := strings.TrimSpace(req.InputFileID); id != "" {
+			in.InputFileID = id
+			return in, s.readBatchInputFile(r, &in)
+		}
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		for _, line := range req.Requests {
+			_ = enc.Encode(line)
+		}
+		in.Input = buf.Bytes()
+	}
+	return in, nil
+}
+
+// readBatchInputFile loads the JSONL input of a batch from /v1/files.
+func (s *server) readBatchInputFile(r *http.Request, in *batch.CreateInput) error {
+	if s.files == nil {
+		return errors.New("input_file_id needs the files API, which is not configured")
+	}
+	data, f, err := s.files.Content(r.Context(), in.Owner, in.InputFileID)
+	if err != nil {
+		return err
+	}
+	if f.Purpose != "batch" {
+		return errors.New("input file must be uploaded with purpose batch")
+	}
+	in.Input = data
+	return nil
+}
+
+// executeBatchRequest runs one batch line through the regular handler at
+// batch priority. The batch context carries the creator's token, so quota
+// is checked and charged per line.
+func (s *server) executeBatchRequest(ctx context.Context, endpoint string, body json.RawMessage) batch.Response {
+	header := http.Header{}
+	header.Set("x-cc-priority", batchPriorityClass)
+	runID, rec, ok := s.dispatchInternal(ctx, endpoint, body, header)
+	if !ok {
+		return batch.Response{StatusCode: http.StatusBadRequest, Body: json.RawMessage(`{"error":{"message":"unsupported endpoint"}}`)}
+	}
+	resp := batch.Response{StatusCode: rec.status, RequestID: runID}
+	if data := bytes.TrimSpace(rec.body.Bytes()); json.Valid(data) {
+		resp.Body = append(json.RawMessage(nil), data...)
+	}
+	return resp
+}
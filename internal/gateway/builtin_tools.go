@@ -0,0 +1,125 @@
+package gateway
+
+import (
+	"fmt"
+	"strings"
+
+	"ccgateway/internal/orchestrator"
+)
+
+// Anthropic built-in client tools. Their declarations carry only a type
+// and name, so upstreams other than Anthropic get them as function tools
+// with the schema below.
+const (
+	builtinToolBash       = "bash"
+	builtinToolTextEditor = "text_editor"
+	builtinToolComputer   = "computer"
+)
+
+// builtinToolKind returns the built-in tool family of t's type, e.g.
+// "bash" for "bash_20250124", or "" for any other tool.
+func builtinToolKind(t ToolDefinition) string {
+	kind := strings.ToLower(strings.TrimSpace(t.Type))
+	for _, family := range []string{builtinToolBash, builtinToolTextEditor, builtinToolComputer} {
+		if strings.HasPrefix(kind, family+"_") {
+			return family
+		}
+	}
+	return ""
+}
+
+// builtinToolToCanonical keeps the declared type so Anthropic upstreams
+// receive the tool natively, and fills in the schema and description the
+// other upstreams need to call it as a function.
+func builtinToolToCanonical(t ToolDefinition, kind string) orchestrator.Tool {
+	out := orchestrator.Tool{
+		Name:        t.Name,
+		Description: t.Description,
+		InputSchema: t.InputSchema,
+		Type:        strings.TrimSpace(t.Type),
+	}
+	if out.InputSchema == nil {
+		out.InputSchema = builtinToolInputSchema(kind)
+	}
+	if strings.TrimSpace(out.Description) == "" {
+		out.Description = builtinToolDescription(kind, t)
+	}
+	if kind == builtinToolComputer {
+		out.Options = map[string]any{}
+		if t.DisplayWidthPx > 0 {
+			out.Options["display_width_px"] = t.DisplayWidthPx
+		}
+		if t.DisplayHeightPx > 0 {
+			out.Options["display_height_px"] = t.DisplayHeightPx
+		}
+		if t.DisplayNumber > 0 {
+			out.Options["display_number"] = t.DisplayNumber
+		}
+	}
+	return out
+}
+
+func builtinToolDescription(kind string, t ToolDefinition) string {
+	switch kind {
+	case builtinToolBash:
+		return "Run a command in a bash shell. The working directory persists between calls; set restart to true to start a fresh shell."
+	case builtinToolTextEditor:
+		return "View, create and edit files. view prints a file with line numbers (optionally view_range [start, end]) or lists a directory; " +
+			"create writes file_text to a new file; str_replace replaces the single exact occurrence of old_str with new_str; " +
+			"insert adds new_str after line insert_line (0 for the top); undo_edit reverts the last edit of path."
+	case builtinToolComputer:
+		desc := "Control the computer's screen, mouse and keyboard. Take a screenshot before acting; coordinates are [x, y] pixels."
+		if t.DisplayWidthPx > 0 && t.DisplayHeightPx > 0 {
+			desc += fmt.Sprintf(" The display is %dx%d pixels.", t.DisplayWidthPx, t.DisplayHeightPx)
+		}
+		return desc
+	}
+	return ""
+}
+
+func builtinToolInputSchema(kind string) map[string]any {
+	switch kind {
+	case builtinToolBash:
+		return map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"command": map[string]any{"type": "string", "description": "The bash command to run"},
+				"restart": map[string]any{"type": "boolean", "description": "Restart the shell instead of running a command"},
+			},
+		}
+	case builtinToolTextEditor:
+		return map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"command":     map[string]any{"type": "string", "enum": []any{"view", "create", "str_replace", "insert", "undo_edit"}},
+				"path":        map[string]any{"type": "string", "description": "Path of the file or directory"},
+				"file_text":   map[string]any{"type": "string", "description": "Content of the file to create"},
+				"old_str":     map[string]any{"type": "string", "description": "Exact text to replace"},
+				"new_str":     map[string]any{"type": "string", "description": "Replacement or inserted text"},
+				"insert_line": map[string]any{"type": "integer", "description": "Line after which new_str is inserted"},
+				"view_range":  map[string]any{"type": "array", "items": map[string]any{"type": "integer"}, "description": "[start, end] lines to view, end -1 for the rest of the file"},
+			},
+			"required": []any{"command", "path"},
+		}
+	case builtinToolComputer:
+		coordinate := map[string]any{"type": "array", "items": map[string]any{"type": "integer"}, "minItems": 2, "maxItems": 2}
+		return map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"action": map[string]any{"type": "string", "enum": []any{
+					"screenshot", "cursor_position", "mouse_move", "left_click", "right_click", "middle_click",
+					"double_click", "triple_click", "left_click_drag", "left_mouse_down", "left_mouse_up",
+					"scroll", "key", "type", "hold_key", "wait",
+				}},
+				"coordinate":       coordinate,
+				"start_coordinate": coordinate,
+				"text":             map[string]any{"type": "string", "description": "Text to type or key combination to press"},
+				"scroll_direction": map[string]any{"type": "string", "enum": []any{"up", "down", "left", "right"}},
+				"scroll_amount":    map[string]any{"type": "integer"},
+				"duration":         map[string]any{"type": "number", "description": "Seconds to wait or hold a key"},
+			},
+			"required": []any{"action"},
+		}
+	}
+	return map[string]any{"type": "object"}
+}
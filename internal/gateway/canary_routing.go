@@ -0,0 +1,141 @@
+package gateway
+
+import (
+	"context"
+	"hash/fnv"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"ccgateway/internal/settings"
+	"ccgateway/internal/token"
+)
+
+const (
+	canaryVariantCanary  = "canary"
+	canaryVariantControl = "control"
+	canaryCohortPinned   = "pinned"
+)
+
+type canaryAssignment struct {
+	Name    string
+	Variant string
+	Cohort  string
+}
+
+// assignCanary decides whether a request takes the canary route. Sticky
+// cohorts hash the user or token so the answer never changes mid-experiment;
+// callers without that identity fall back to per-request sampling.
+func assignCanary(ctx context.Context, cfg settings.CanarySettings, mode string) (canaryAssignment, bool) {
+	if !cfg.Enabled || len(cfg.Route) == 0 {
+		return canaryAssignment{}, false
+	}
+	if len(cfg.Modes) > 0 && !containsFold(cfg.Modes, mode) {
+		return canaryAssignment{}, false
+	}
+	userID := requestUserID(ctx)
+	tokenID, tokenName := requestTokenIdentity(ctx)
+	out := canaryAssignment{Name: cfg.Name, Variant: canaryVariantControl, Cohort: settings.CanaryStickyRequest}
+	if (userID != "" && containsFold(cfg.Users, userID)) ||
+		(tokenID != "" && containsFold(cfg.Tokens, tokenID)) ||
+		(tokenName != "" && containsFold(cfg.Tokens, tokenName)) {
+		out.Variant = canaryVariantCanary
+		out.Cohort = canaryCohortPinned
+		return out, true
+	}
+
+	key := ""
+	switch cfg.StickyBy {
+	case settings.CanaryStickyUser:
+		key = userID
+	case settings.CanaryStickyToken:
+		key = tokenID
+	}
+	var inCanary bool
+	if key != "" {
+		out.Cohort = cfg.StickyBy
+		inCanary = canaryBucket(cfg.Name, key) < int(cfg.Percent*100)
+	} else {
+		inCanary = rand.Float64()*100 < cfg.Percent
+	}
+	if inCanary {
+		out.Variant = canaryVariantCanary
+	}
+	return out, true
+}
+
+// canaryBucket maps an experiment and cohort key to 0..9999. Including the
+// experiment name reshuffles cohorts between experiments.
+func canaryBucket(name, key string) int {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(name))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(key))
+	return int(h.Sum64() % 10000)
+}
+
+func requestTokenIdentity(ctx context.Context) (string, string) {
+	tk, ok := ctx.Value(tokenContextKey).(*token.Token)
+	if !ok || tk == nil {
+		return "", ""
+	}
+	id := ""
+	if tk.ID != 0 {
+		id = strconv.FormatInt(tk.ID, 10)
+	}
+	return id, strings.TrimSpace(tk.Name)
+}
+
+func applyCanaryAssignment(out map[string]any, cfg settings.CanarySettings, a canaryAssignment) {
+	out["canary_name"] = a.Name
+	out["canary_variant"] = a.Variant
+	out["canary_cohort"] = a.Cohort
+	if a.Variant == canaryVariantCanary {
+		out["routing_adapter_route"] = append([]string(nil), cfg.Route...)
+		out["routing_route_source"] = "canary"
+	}
+}
+
+// experimentRunMetadata extracts the canary and prompt experiment
+// assignments for the run record so feedback and scores can be attributed
+// to the variant.
+func experimentRunMetadata(metadata map[string]any) map[string]any {
+	out := map[string]any{}
+	for _, keys := range [][3]string{
+		{"canary_name", "canary_variant", "canary_cohort"},
+		{"prompt_experiment", "prompt_variant", "prompt_cohort"},
+	} {
+		variant, _ := metadata[keys[1]].(string)
+		if variant == "" {
+			continue
+		}
+		out[keys[0]] = metadata[keys[0]]
+		out[keys[1]] = variant
+		out[keys[2]] = metadata[keys[2]]
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+func setCanaryHeaders(w http.ResponseWriter, metadata map[string]any) {
+	variant, _ := metadata["canary_variant"].(string)
+	if variant == "" {
+		return
+	}
+	name, _ := metadata["canary_name"].(string)
+	w.Header().Set("x-cc-canary", name)
+	w.Header().Set("x-cc-canary-variant", variant)
+}
+
+func containsFold(list []string, value string) bool {
+	value = strings.TrimSpace(value)
+	for _, item := range list {
+		if strings.EqualFold(strings.TrimSpace(item), value) {
+			return true
+		}
+	}
+	return false
+}
@@ -3,11 +3,14 @@ package gateway
 import (
 	"encoding/json"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 
 	"ccgateway/internal/ccevent"
+	"ccgateway/internal/ccrun"
 	"ccgateway/internal/session"
+	"ccgateway/internal/toolruntime"
 )
 
 func (s *server) handleCCSessions(w http.ResponseWriter, r *http.Request) {
@@ -23,6 +26,8 @@ func (s *server) handleCCSessions(w http.ResponseWriter, r *http.Request) {
 			s.writeError(w, http.StatusBadRequest, "invalid_request_error", "invalid JSON body")
 			return
 		}
+		req.ProjectID = projectIDFromContext(r.Context())
+		req.UserID = requestUserID(r.Context())
 		out, err := s.sessionStore.Create(req)
 		if err != nil {
 			writeSessionStoreError(w, err)
@@ -95,9 +100,51 @@ func (s *server) handleCCSessionByPath(w http.ResponseWriter, r *http.Request) {
 		s.handleCCSessionFork(w, r, parts[0])
 		return
 	}
+	if len(parts) == 2 && parts[1] == "tool-state" {
+		s.handleCCSessionToolState(w, r, parts[0])
+		return
+	}
+	if len(parts) == 2 && parts[1] == "export" {
+		s.handleCCSessionExport(w, r, parts[0])
+		return
+	}
 	s.writeError(w, http.StatusNotFound, "not_found_error", "session endpoint not found")
 }
 
+// handleCCSessionToolState shows (GET) or releases (DELETE) the per-session
+// tool state, including MCP session handles held for the session.
+func (s *server) handleCCSessionToolState(w http.ResponseWriter, r *http.Request, sessionID string) {
+	sessionID = strings.TrimSpace(sessionID)
+	switch r.Method {
+	case http.MethodGet:
+		info, ok := s.toolState.Info(sessionID)
+		if !ok {
+			info = toolruntime.SessionStateInfo{SessionID: sessionID, Keys: []string{}}
+		}
+		w.Header().Set("content-type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(info)
+	case http.MethodDelete:
+		released := s.toolState.Release(sessionID)
+		s.appendEvent(ccevent.AppendInput{
+			EventType: "tool.state_released",
+			SessionID: sessionID,
+			Data: map[string]any{
+				"reason":   "api",
+				"released": released,
+			},
+		})
+		w.Header().Set("content-type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"session_id": sessionID,
+			"released":   released,
+		})
+	default:
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+	}
+}
+
 func (s *server) handleCCSessionGet(w http.ResponseWriter, sessionID string) {
 	sessionID = strings.TrimSpace(sessionID)
 	if sessionID == "" {
@@ -121,29 +168,104 @@ func (s *server) handleCCSessionFork(w http.ResponseWriter, r *http.Request, ses
 		return
 	}
 	var req session.CreateInput
-	if err := decodeJSONBodyStrict(r, &req, false); err != nil {
+	if err := decodeJSONBodyStrict(r, &req, true); err != nil {
 		s.reportRequestDecodeIssue(r, err)
 		s.writeError(w, http.StatusBadRequest, "invalid_request_error", "invalid JSON body")
 		return
 	}
+	req.UserID = requestUserID(r.Context())
+	atRun := strings.TrimSpace(r.URL.Query().Get("at_run"))
+	if atRun != "" {
+		if s.runStore == nil {
+			s.writeError(w, http.StatusNotImplemented, "api_error", "run store is not configured")
+			return
+		}
+		parent, ok := s.sessionStore.Get(sessionID)
+		if !ok {
+			s.writeError(w, http.StatusNotFound, "not_found_error", "session not found")
+			return
+		}
+		run, ok := s.runStore.Get(atRun)
+		if !ok {
+			s.writeError(w, http.StatusNotFound, "not_found_error", "run not found")
+			return
+		}
+		if run.SessionID != parent.ID {
+			s.writeError(w, http.StatusBadRequest, "invalid_request_error", "run does not belong to session "+parent.ID)
+			return
+		}
+		req.Messages = s.sessionHistoryAtRun(parent, run)
+		if req.Metadata == nil {
+			req.Metadata = map[string]any{}
+			for k, v := range parent.Metadata {
+				req.Metadata[k] = v
+			}
+		}
+		req.Metadata["forked_at_run"] = run.ID
+	}
 	out, err := s.sessionStore.Fork(sessionID, req)
 	if err != nil {
 		writeSessionStoreError(w, err)
 		return
 	}
+	data := map[string]any{
+		"parent_id": out.ParentID,
+		"title":     out.Title,
+		"messages":  len(out.Messages),
+	}
+	if atRun != "" {
+		data["at_run"] = atRun
+	}
 	s.appendEvent(ccevent.AppendInput{
 		EventType: "session.forked",
 		SessionID: out.ID,
-		Data: map[string]any{
-			"parent_id": out.ParentID,
-			"title":     out.Title,
-		},
+		Data:      data,
 	})
 	w.Header().Set("content-type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	_ = json.NewEncoder(w).Encode(out)
 }
 
+// sessionHistoryAtRun returns the parent's history up to and including run.
+// Stored messages are cut at the run's completion; sessions without stored
+// messages get one user and one assistant turn per run, oldest first.
+func (s *server) sessionHistoryAtRun(parent session.Session, run ccrun.Run) []session.SessionMessage {
+	out := []session.SessionMessage{}
+	if len(parent.Messages) > 0 {
+		cutoff := run.UpdatedAt
+		if run.CompletedAt != nil {
+			cutoff = *run.CompletedAt
+		}
+		for _, msg := range parent.Messages {
+			if !msg.CreatedAt.After(cutoff) {
+				out = append(out, msg)
+			}
+		}
+		return out
+	}
+	runs := s.runStore.List(ccrun.ListFilter{SessionID: parent.ID})
+	sort.SliceStable(runs, func(i, j int) bool { return runs[i].CreatedAt.Before(runs[j].CreatedAt) })
+	for _, item := range runs {
+		if item.CreatedAt.After(run.CreatedAt) {
+			break
+		}
+		if item.PromptText != "" {
+			out = append(out, session.SessionMessage{Role: "user", Content: item.PromptText, CreatedAt: item.CreatedAt})
+		}
+		if item.OutputText != "" {
+			at := item.UpdatedAt
+			if item.CompletedAt != nil {
+				at = *item.CompletedAt
+			}
+			out = append(out, session.SessionMessage{Role: "assistant", Content: item.OutputText, CreatedAt: at})
+		}
+		if item.ID == run.ID {
+			break
+		}
+	}
+	return out
+}
+
 func writeSessionStoreError(w http.ResponseWriter, err error) {
 	msg := strings.TrimSpace(err.Error())
 	switch {
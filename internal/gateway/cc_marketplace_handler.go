@@ -129,6 +129,7 @@ func (s *server) handleCCMarketplacePluginInstall(w http.ResponseWriter, r *http
 			Skills:      manifest.Skills,
 			Hooks:       manifest.Hooks,
 			MCPServers:  manifest.MCPServers,
+			Middleware:  manifest.Middleware,
 			Enabled:     true,
 		}
 		if err := s.pluginStore.Install(projectPlugin); err != nil {
@@ -456,6 +457,7 @@ func (s *server) handleCCMarketplacePluginUpdate(w http.ResponseWriter, r *http.
 			Skills:      manifest.Skills,
 			Hooks:       manifest.Hooks,
 			MCPServers:  manifest.MCPServers,
+			Middleware:  manifest.Middleware,
 			Enabled:     current.Enabled,
 		}
 		if err := s.pluginStore.Install(updated); err != nil {
@@ -180,10 +180,17 @@ func (s *server) handleCCMCPServerResource(w http.ResponseWriter, r *http.Reques
 		w.WriteHeader(http.StatusOK)
 		_ = json.NewEncoder(w).Encode(mcpServerForProject(scopeSel.ProjectID, out))
 	case http.MethodDelete:
-		if err := s.mcpRegistry.Delete(storageID); err != nil {
+		var err error
+		if trash, ok := s.mcpRegistry.(interface{ SoftDelete(id string) error }); ok {
+			err = trash.SoftDelete(storageID)
+		} else {
+			err = s.mcpRegistry.Delete(storageID)
+		}
+		if err != nil {
 			writeMCPRegistryError(w, err)
 			return
 		}
+		s.recordSoftDelete(r, "mcp_server", storageID)
 		w.WriteHeader(http.StatusNoContent)
 	default:
 		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
@@ -25,22 +25,11 @@ func (s *server) handleCCPlans(w http.ResponseWriter, r *http.Request) {
 			s.writeError(w, http.StatusBadRequest, "invalid_request_error", "invalid JSON body")
 			return
 		}
-		out, err := s.planStore.Create(req)
+		out, err := s.createPlan(req)
 		if err != nil {
 			writePlanStoreError(w, err)
 			return
 		}
-		s.appendEvent(ccevent.AppendInput{
-			EventType: "plan.created",
-			SessionID: out.SessionID,
-			RunID:     out.RunID,
-			PlanID:    out.ID,
-			Data: map[string]any{
-				"status": out.Status,
-				"title":  out.Title,
-			},
-		})
-		s.ensurePlanTodos(out)
 		w.Header().Set("content-type", "application/json")
 		w.WriteHeader(http.StatusCreated)
 		_ = json.NewEncoder(w).Encode(out)
@@ -121,20 +110,11 @@ func (s *server) handleCCPlanApprove(w http.ResponseWriter, r *http.Request, pla
 		s.writeError(w, http.StatusBadRequest, "invalid_request_error", "invalid JSON body")
 		return
 	}
-	out, err := s.planStore.Approve(planID, req)
+	out, err := s.approvePlan(planID, req)
 	if err != nil {
 		writePlanStoreError(w, err)
 		return
 	}
-	s.appendEvent(ccevent.AppendInput{
-		EventType: "plan.approved",
-		SessionID: out.SessionID,
-		RunID:     out.RunID,
-		PlanID:    out.ID,
-		Data: map[string]any{
-			"status": out.Status,
-		},
-	})
 	w.Header().Set("content-type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	_ = json.NewEncoder(w).Encode(out)
@@ -147,12 +127,60 @@ func (s *server) handleCCPlanExecute(w http.ResponseWriter, r *http.Request, pla
 		s.writeError(w, http.StatusBadRequest, "invalid_request_error", "invalid JSON body")
 		return
 	}
-	out, err := s.planStore.Execute(planID, req)
+	out, err := s.executePlan(planID, req)
 	if err != nil {
 		writePlanStoreError(w, err)
 		return
 	}
-	out = s.syncPlanTodos(out, req)
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+// createPlan stores a plan, records plan.created and seeds step todos.
+func (s *server) createPlan(in plan.CreateInput) (plan.Plan, error) {
+	out, err := s.planStore.Create(in)
+	if err != nil {
+		return plan.Plan{}, err
+	}
+	s.appendEvent(ccevent.AppendInput{
+		EventType: "plan.created",
+		SessionID: out.SessionID,
+		RunID:     out.RunID,
+		PlanID:    out.ID,
+		Data: map[string]any{
+			"status": out.Status,
+			"title":  out.Title,
+		},
+	})
+	s.ensurePlanTodos(out)
+	return out, nil
+}
+
+func (s *server) approvePlan(planID string, in plan.ApproveInput) (plan.Plan, error) {
+	out, err := s.planStore.Approve(planID, in)
+	if err != nil {
+		return plan.Plan{}, err
+	}
+	s.appendEvent(ccevent.AppendInput{
+		EventType: "plan.approved",
+		SessionID: out.SessionID,
+		RunID:     out.RunID,
+		PlanID:    out.ID,
+		Data: map[string]any{
+			"status": out.Status,
+		},
+	})
+	return out, nil
+}
+
+// executePlan advances a plan and keeps its step todos in sync.
+func (s *server) executePlan(planID string, in plan.ExecuteInput) (plan.Plan, error) {
+	out, err := s.planStore.Execute(planID, in)
+	if err != nil {
+		return plan.Plan{}, err
+	}
+	out = s.syncPlanTodos(out, in)
 	eventType := "plan.executing"
 	if out.Status == plan.StatusCompleted {
 		eventType = "plan.completed"
@@ -168,9 +196,7 @@ func (s *server) handleCCPlanExecute(w http.ResponseWriter, r *http.Request, pla
 			"status": out.Status,
 		},
 	})
-	w.Header().Set("content-type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	_ = json.NewEncoder(w).Encode(out)
+	return out, nil
 }
 
 func (s *server) ensurePlanTodos(p plan.Plan) {
@@ -183,6 +209,9 @@ func (s *server) ensurePlanTodos(p plan.Plan) {
 		if title == "" {
 			continue
 		}
+		if step.TodoID != "" && s.linkPlanTodo(p, i, step) {
+			continue
+		}
 		td, err := s.todoStore.Create(todo.CreateInput{
 			SessionID:   p.SessionID,
 			RunID:       p.RunID,
@@ -225,6 +254,42 @@ func (s *server) ensurePlanTodos(p plan.Plan) {
 	}
 }
 
+// linkPlanTodo attaches the existing todo a step was derived from to the
+// plan instead of creating a new one. It reports false when the todo is
+// gone or already belongs to another plan.
+func (s *server) linkPlanTodo(p plan.Plan, index int, step plan.Step) bool {
+	td, ok := s.todoStore.Get(step.TodoID)
+	if !ok || (td.PlanID != "" && td.PlanID != p.ID) {
+		return false
+	}
+	metadata := make(map[string]any, len(td.Metadata)+3)
+	for k, v := range td.Metadata {
+		metadata[k] = v
+	}
+	metadata["source"] = "plan_step"
+	metadata["step_index"] = index
+	if step.ID != "" {
+		metadata["step_id"] = step.ID
+	}
+	next, err := s.todoStore.Update(td.ID, todo.UpdateInput{PlanID: &p.ID, Metadata: &metadata})
+	if err != nil {
+		return false
+	}
+	s.appendEvent(ccevent.AppendInput{
+		EventType: "todo.updated",
+		SessionID: next.SessionID,
+		RunID:     next.RunID,
+		PlanID:    next.PlanID,
+		TodoID:    next.ID,
+		Data: map[string]any{
+			"status":    next.Status,
+			"plan_id":   next.PlanID,
+			"plan_sync": true,
+		},
+	})
+	return true
+}
+
 func (s *server) syncPlanTodos(p plan.Plan, req plan.ExecuteInput) plan.Plan {
 	if s.todoStore == nil {
 		return p
@@ -4,6 +4,9 @@ import (
 	"encoding/json"
 	"net/http"
 	"strings"
+
+	"ccgateway/internal/ccevent"
+	"ccgateway/internal/ccrun"
 )
 
 func (s *server) handleCCRuns(w http.ResponseWriter, r *http.Request) {
@@ -32,16 +35,31 @@ func (s *server) handleCCRuns(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *server) handleCCRunByPath(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/v1/cc/runs/")
+	path = strings.Trim(path, "/")
+	if s.serveAsyncRun(w, r, path) {
+		return
+	}
+	if parts := strings.Split(path, "/"); len(parts) >= 2 && len(parts) <= 3 && parts[0] != "" && parts[1] == "approvals" {
+		callID := ""
+		if len(parts) == 3 {
+			callID = parts[2]
+		}
+		s.handleCCRunApprovals(w, r, parts[0], callID)
+		return
+	}
 	if s.runStore == nil {
 		s.writeError(w, http.StatusNotImplemented, "api_error", "run store is not configured")
 		return
 	}
+	if id, ok := strings.CutSuffix(path, "/feedback"); ok && id != "" && !strings.Contains(id, "/") {
+		s.handleCCRunFeedback(w, r, id)
+		return
+	}
 	if r.Method != http.MethodGet {
 		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
 		return
 	}
-	path := strings.TrimPrefix(r.URL.Path, "/v1/cc/runs/")
-	path = strings.Trim(path, "/")
 	if path == "" || strings.Contains(path, "/") {
 		s.writeError(w, http.StatusNotFound, "not_found_error", "run endpoint not found")
 		return
@@ -55,3 +73,63 @@ func (s *server) handleCCRunByPath(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	_ = json.NewEncoder(w).Encode(out)
 }
+
+type runFeedbackRecorder interface {
+	RecordFeedback(id string, fb ccrun.Feedback) (ccrun.Run, error)
+}
+
+// handleCCRunFeedback records a caller's thumbs up/down on a run. Runs
+// served under a prompt experiment feed the variant's score, so feedback
+// can trigger auto-promotion.
+func (s *server) handleCCRunFeedback(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodPost {
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+		return
+	}
+	recorder, ok := s.runStore.(runFeedbackRecorder)
+	if !ok {
+		s.writeError(w, http.StatusNotImplemented, "api_error", "run store does not support feedback")
+		return
+	}
+	var req struct {
+		Rating  int    `json:"rating"`
+		Comment string `json:"comment"`
+	}
+	if err := decodeJSONBodyStrict(r, &req, false); err != nil {
+		s.reportRequestDecodeIssue(r, err)
+		s.writeError(w, http.StatusBadRequest, "invalid_request_error", "invalid JSON body")
+		return
+	}
+	if req.Rating != 1 && req.Rating != -1 {
+		s.writeError(w, http.StatusBadRequest, "invalid_request_error", "rating must be 1 or -1")
+		return
+	}
+	if _, ok := s.runStore.Get(id); !ok {
+		s.writeError(w, http.StatusNotFound, "not_found_error", "run not found")
+		return
+	}
+	run, err := recorder.RecordFeedback(id, ccrun.Feedback{
+		Rating:  req.Rating,
+		Comment: req.Comment,
+		UserID:  requestUserID(r.Context()),
+	})
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	s.appendEvent(ccevent.AppendInput{
+		EventType: "run.feedback",
+		SessionID: run.SessionID,
+		RunID:     run.ID,
+		Data: map[string]any{
+			"rating":         req.Rating,
+			"prompt_variant": run.Metadata["prompt_variant"],
+		},
+	})
+	if mode, _ := run.Metadata["prompt_experiment"].(string); mode != "" {
+		s.maybePromotePromptVariant(mode)
+	}
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(run)
+}
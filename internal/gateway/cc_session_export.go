@@ -0,0 +1,281 @@
+package gateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"ccgateway/internal/ccevent"
+	"ccgateway/internal/ccrun"
+	"ccgateway/internal/session"
+)
+
+// sessionTranscript is a session with its runs in order, each run carrying
+// the tool calls the server tool loop made and the events recorded for it.
+type sessionTranscript struct {
+	Session    session.Session `json:"session"`
+	Runs       []transcriptRun `json:"runs"`
+	Events     []ccevent.Event `json:"events,omitempty"`
+	ExportedAt time.Time       `json:"exported_at"`
+}
+
+type transcriptRun struct {
+	ccrun.Run
+	ToolCalls []transcriptToolCall `json:"tool_calls,omitempty"`
+	Events    []ccevent.Event      `json:"events,omitempty"`
+}
+
+type transcriptToolCall struct {
+	ToolUseID string         `json:"tool_use_id"`
+	Tool      string         `json:"tool"`
+	Input     map[string]any `json:"input,omitempty"`
+	Output    string         `json:"output"`
+	IsError   bool           `json:"is_error,omitempty"`
+	At        time.Time      `json:"at"`
+}
+
+// handleCCSessionExport renders a session's transcript.
+// GET /v1/cc/sessions/{id}/export?format=markdown|json|html
+func (s *server) handleCCSessionExport(w http.ResponseWriter, r *http.Request, sessionID string) {
+	if r.Method != http.MethodGet {
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+		return
+	}
+	format := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("format")))
+	if format == "" {
+		format = "markdown"
+	}
+	if format != "markdown" && format != "json" && format != "html" {
+		s.writeError(w, http.StatusBadRequest, "invalid_request_error", "format must be markdown, json or html")
+		return
+	}
+	sess, ok := s.sessionStore.Get(strings.TrimSpace(sessionID))
+	if !ok {
+		s.writeError(w, http.StatusNotFound, "not_found_error", "session not found")
+		return
+	}
+	transcript := s.buildSessionTranscript(sess)
+	s.appendEvent(ccevent.AppendInput{
+		EventType: "session.exported",
+		SessionID: sess.ID,
+		Data:      map[string]any{"format": format, "runs": len(transcript.Runs)},
+	})
+
+	filename := "session-" + sess.ID
+	switch format {
+	case "json":
+		w.Header().Set("content-type", "application/json")
+		w.Header().Set("content-disposition", `attachment; filename="`+filename+`.json"`)
+		w.WriteHeader(http.StatusOK)
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(transcript)
+	case "html":
+		w.Header().Set("content-type", "text/html; charset=utf-8")
+		w.Header().Set("content-disposition", `attachment; filename="`+filename+`.html"`)
+		w.WriteHeader(http.StatusOK)
+		_ = transcriptHTML.Execute(w, transcript)
+	default:
+		w.Header().Set("content-type", "text/markdown; charset=utf-8")
+		w.Header().Set("content-disposition", `attachment; filename="`+filename+`.md"`)
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(renderTranscriptMarkdown(transcript)))
+	}
+}
+
+// buildSessionTranscript gathers the session's runs and events oldest
+// first. Events of runs outside the session stay at the session level.
+func (s *server) buildSessionTranscript(sess session.Session) sessionTranscript {
+	out := sessionTranscript{Session: sess, Runs: []transcriptRun{}, ExportedAt: time.Now().UTC()}
+	byID := map[string]int{}
+	if s.runStore != nil {
+		runs := s.runStore.List(ccrun.ListFilter{SessionID: sess.ID})
+		sort.SliceStable(runs, func(i, j int) bool { return runs[i].CreatedAt.Before(runs[j].CreatedAt) })
+		for _, run := range runs {
+			byID[run.ID] = len(out.Runs)
+			out.Runs = append(out.Runs, transcriptRun{Run: run})
+		}
+	}
+	if s.eventStore == nil {
+		return out
+	}
+	events := s.eventStore.List(ccevent.ListFilter{SessionID: sess.ID})
+	for i := len(events) - 1; i >= 0; i-- {
+		ev := events[i]
+		idx, ok := byID[ev.RunID]
+		if !ok {
+			out.Events = append(out.Events, ev)
+			continue
+		}
+		run := &out.Runs[idx]
+		if ev.EventType != "tool.executed" {
+			run.Events = append(run.Events, ev)
+			continue
+		}
+		call := transcriptToolCall{
+			ToolUseID: valueAsString(ev.Data["tool_use_id"]),
+			Tool:      valueAsString(ev.Data["tool"]),
+			Output:    valueAsString(ev.Data["output"]),
+			At:        ev.CreatedAt,
+		}
+		call.Input, _ = ev.Data["input"].(map[string]any)
+		call.IsError, _ = ev.Data["is_error"].(bool)
+		run.ToolCalls = append(run.ToolCalls, call)
+	}
+	return out
+}
+
+func renderTranscriptMarkdown(t sessionTranscript) string {
+	var b strings.Builder
+	title := strings.TrimSpace(t.Session.Title)
+	if title == "" {
+		title = t.Session.ID
+	}
+	fmt.Fprintf(&b, "# Session %s\n\n", title)
+	fmt.Fprintf(&b, "- ID: `%s`\n", t.Session.ID)
+	if t.Session.ParentID != "" {
+		fmt.Fprintf(&b, "- Forked from: `%s`\n", t.Session.ParentID)
+	}
+	if t.Session.ProjectID != "" {
+		fmt.Fprintf(&b, "- Project: `%s`\n", t.Session.ProjectID)
+	}
+	fmt.Fprintf(&b, "- Created: %s\n", t.Session.CreatedAt.UTC().Format(time.RFC3339))
+	fmt.Fprintf(&b, "- Exported: %s\n", t.ExportedAt.Format(time.RFC3339))
+
+	if len(t.Session.Messages) > 0 {
+		b.WriteString("\n## Messages\n")
+		for _, msg := range t.Session.Messages {
+			fmt.Fprintf(&b, "\n**%s** (%s)\n\n%s\n", msg.Role, msg.CreatedAt.UTC().Format(time.RFC3339), markdownBlock("text", msg.Content))
+		}
+	}
+
+	for i, run := range t.Runs {
+		fmt.Fprintf(&b, "\n## Run %d: `%s`\n\n", i+1, run.ID)
+		fmt.Fprintf(&b, "- Path: `%s`", run.Path)
+		if run.Mode != "" {
+			fmt.Fprintf(&b, " (mode `%s`)", run.Mode)
+		}
+		b.WriteString("\n")
+		if model := transcriptModel(run.Run); model != "" {
+			fmt.Fprintf(&b, "- Model: %s\n", model)
+		}
+		fmt.Fprintf(&b, "- Status: %s (%d)\n", run.Status, run.StatusCode)
+		if run.Error != "" {
+			fmt.Fprintf(&b, "- Error: %s\n", run.Error)
+		}
+		fmt.Fprintf(&b, "- Started: %s\n", run.CreatedAt.UTC().Format(time.RFC3339))
+		if run.PromptText != "" {
+			fmt.Fprintf(&b, "\n### Prompt\n\n%s\n", markdownBlock("text", run.PromptText))
+		}
+		for _, call := range run.ToolCalls {
+			status := ""
+			if call.IsError {
+				status = " (error)"
+			}
+			fmt.Fprintf(&b, "\n### Tool call: %s `%s`%s\n\n", call.Tool, call.ToolUseID, status)
+			input, _ := json.MarshalIndent(call.Input, "", "  ")
+			fmt.Fprintf(&b, "Input:\n\n%s\n\nResult:\n\n%s\n", markdownBlock("json", string(input)), markdownBlock("text", call.Output))
+		}
+		if run.OutputText != "" {
+			fmt.Fprintf(&b, "\n### Output\n\n%s\n", markdownBlock("text", run.OutputText))
+		}
+		if len(run.Events) > 0 {
+			b.WriteString("\n### Events\n\n")
+			for _, ev := range run.Events {
+				fmt.Fprintf(&b, "- %s %s\n", ev.CreatedAt.UTC().Format(time.RFC3339), transcriptEventText(ev))
+			}
+		}
+	}
+
+	if len(t.Events) > 0 {
+		b.WriteString("\n## Session events\n\n")
+		for _, ev := range t.Events {
+			fmt.Fprintf(&b, "- %s %s\n", ev.CreatedAt.UTC().Format(time.RFC3339), transcriptEventText(ev))
+		}
+	}
+	return b.String()
+}
+
+// markdownBlock fences text with more backticks than it contains in a row.
+func markdownBlock(lang, text string) string {
+	longest, run := 0, 0
+	for _, r := range text {
+		if r == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	fence := strings.Repeat("`", max(3, longest+1))
+	return fence + lang + "\n" + strings.TrimRight(text, "\n") + "\n" + fence
+}
+
+func transcriptModel(run ccrun.Run) string {
+	model := run.ClientModel
+	if run.UpstreamModel != "" && run.UpstreamModel != run.ClientModel {
+		if model != "" {
+			model += " -> "
+		}
+		model += run.UpstreamModel
+	}
+	return model
+}
+
+func transcriptEventText(ev ccevent.Event) string {
+	if text := strings.TrimSpace(valueAsString(ev.Data["record_text"])); text != "" {
+		return text
+	}
+	return ev.EventType
+}
+
+var transcriptHTML = template.Must(template.New("transcript").Funcs(template.FuncMap{
+	"time":  func(t time.Time) string { return t.UTC().Format(time.RFC3339) },
+	"model": transcriptModel,
+	"event": transcriptEventText,
+	"json": func(v any) string {
+		raw, _ := json.MarshalIndent(v, "", "  ")
+		return string(raw)
+	},
+}).Parse(`<!doctype html>
+<html><head><meta charset="utf-8"><title>Session {{or .Session.Title .Session.ID}}</title>
+<style>
+body{font-family:system-ui,sans-serif;max-width:960px;margin:2em auto;padding:0 1em;color:#222}
+pre{background:#f5f5f5;padding:.75em;overflow-x:auto;white-space:pre-wrap}
+section{border-top:1px solid #ddd;margin-top:1.5em}
+.error{color:#b00020}
+dt{font-weight:bold}
+</style></head>
+<body>
+<h1>Session {{or .Session.Title .Session.ID}}</h1>
+<dl>
+<dt>ID</dt><dd><code>{{.Session.ID}}</code></dd>
+{{if .Session.ParentID}}<dt>Forked from</dt><dd><code>{{.Session.ParentID}}</code></dd>{{end}}
+{{if .Session.ProjectID}}<dt>Project</dt><dd><code>{{.Session.ProjectID}}</code></dd>{{end}}
+<dt>Created</dt><dd>{{time .Session.CreatedAt}}</dd>
+<dt>Exported</dt><dd>{{time .ExportedAt}}</dd>
+</dl>
+{{if .Session.Messages}}<h2>Messages</h2>
+{{range .Session.Messages}}<p><strong>{{.Role}}</strong> ({{time .CreatedAt}})</p><pre>{{.Content}}</pre>
+{{end}}{{end}}
+{{range $i, $run := .Runs}}<section>
+<h2>Run: <code>{{$run.ID}}</code></h2>
+<p>{{$run.Path}}{{if $run.Mode}} (mode {{$run.Mode}}){{end}} &middot; {{model $run.Run}} &middot; {{$run.Status}} ({{$run.StatusCode}}) &middot; {{time $run.CreatedAt}}</p>
+{{if $run.Error}}<p class="error">{{$run.Error}}</p>{{end}}
+{{if $run.PromptText}}<h3>Prompt</h3><pre>{{$run.PromptText}}</pre>{{end}}
+{{range $run.ToolCalls}}<details open><summary>Tool call <strong>{{.Tool}}</strong> <code>{{.ToolUseID}}</code>{{if .IsError}} <span class="error">error</span>{{end}}</summary>
+<p>Input</p><pre>{{json .Input}}</pre>
+<p>Result</p><pre>{{.Output}}</pre>
+</details>
+{{end}}
+{{if $run.OutputText}}<h3>Output</h3><pre>{{$run.OutputText}}</pre>{{end}}
+{{if $run.Events}}<h3>Events</h3><ul>{{range $run.Events}}<li>{{time .CreatedAt}} {{event .}}</li>{{end}}</ul>{{end}}
+</section>
+{{end}}
+{{if .Events}}<section><h2>Session events</h2><ul>{{range .Events}}<li>{{time .CreatedAt}} {{event .}}</li>{{end}}</ul></section>{{end}}
+</body></html>
+`))
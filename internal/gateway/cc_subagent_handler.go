@@ -204,6 +204,8 @@ func (s *server) handleCCSubagentStream(w http.ResponseWriter, r *http.Request,
 		return
 	}
 
+	w, r, stopGuard := s.guardStream(w, r, commentPingFrame)
+	defer stopGuard()
 	flusher, ok := w.(http.Flusher)
 	if !ok {
 		s.writeError(w, http.StatusInternalServerError, "api_error", "streaming unsupported")
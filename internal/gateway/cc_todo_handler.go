@@ -23,23 +23,11 @@ func (s *server) handleCCTodos(w http.ResponseWriter, r *http.Request) {
 			s.writeError(w, http.StatusBadRequest, "invalid_request_error", "invalid JSON body")
 			return
 		}
-		out, err := s.todoStore.Create(req)
+		out, err := s.createTodo(req)
 		if err != nil {
 			writeTodoStoreError(w, err)
 			return
 		}
-		s.appendEvent(ccevent.AppendInput{
-			EventType: "todo.created",
-			SessionID: out.SessionID,
-			RunID:     out.RunID,
-			PlanID:    out.PlanID,
-			TodoID:    out.ID,
-			Data: map[string]any{
-				"status":  out.Status,
-				"title":   out.Title,
-				"plan_id": out.PlanID,
-			},
-		})
 		w.Header().Set("content-type", "application/json")
 		w.WriteHeader(http.StatusCreated)
 		_ = json.NewEncoder(w).Encode(out)
@@ -75,6 +63,10 @@ func (s *server) handleCCTodoByPath(w http.ResponseWriter, r *http.Request) {
 	}
 	path := strings.TrimPrefix(r.URL.Path, "/v1/cc/todos/")
 	path = strings.Trim(path, "/")
+	if path == "synthesize-plan" {
+		s.handleCCTodoSynthesizePlan(w, r)
+		return
+	}
 	if path == "" || strings.Contains(path, "/") {
 		s.writeError(w, http.StatusNotFound, "not_found_error", "todo endpoint not found")
 		return
@@ -96,26 +88,11 @@ func (s *server) handleCCTodoByPath(w http.ResponseWriter, r *http.Request) {
 			s.writeError(w, http.StatusBadRequest, "invalid_request_error", "invalid JSON body")
 			return
 		}
-		out, err := s.todoStore.Update(path, req)
+		out, err := s.updateTodo(path, req)
 		if err != nil {
 			writeTodoStoreError(w, err)
 			return
 		}
-		eventType := "todo.updated"
-		if out.Status == todo.StatusCompleted {
-			eventType = "todo.completed"
-		}
-		s.appendEvent(ccevent.AppendInput{
-			EventType: eventType,
-			SessionID: out.SessionID,
-			RunID:     out.RunID,
-			PlanID:    out.PlanID,
-			TodoID:    out.ID,
-			Data: map[string]any{
-				"status":  out.Status,
-				"plan_id": out.PlanID,
-			},
-		})
 		w.Header().Set("content-type", "application/json")
 		w.WriteHeader(http.StatusOK)
 		_ = json.NewEncoder(w).Encode(out)
@@ -124,6 +101,49 @@ func (s *server) handleCCTodoByPath(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (s *server) createTodo(in todo.CreateInput) (todo.Todo, error) {
+	out, err := s.todoStore.Create(in)
+	if err != nil {
+		return todo.Todo{}, err
+	}
+	s.appendEvent(ccevent.AppendInput{
+		EventType: "todo.created",
+		SessionID: out.SessionID,
+		RunID:     out.RunID,
+		PlanID:    out.PlanID,
+		TodoID:    out.ID,
+		Data: map[string]any{
+			"status":  out.Status,
+			"title":   out.Title,
+			"plan_id": out.PlanID,
+		},
+	})
+	return out, nil
+}
+
+func (s *server) updateTodo(id string, in todo.UpdateInput) (todo.Todo, error) {
+	out, err := s.todoStore.Update(id, in)
+	if err != nil {
+		return todo.Todo{}, err
+	}
+	eventType := "todo.updated"
+	if out.Status == todo.StatusCompleted {
+		eventType = "todo.completed"
+	}
+	s.appendEvent(ccevent.AppendInput{
+		EventType: eventType,
+		SessionID: out.SessionID,
+		RunID:     out.RunID,
+		PlanID:    out.PlanID,
+		TodoID:    out.ID,
+		Data: map[string]any{
+			"status":  out.Status,
+			"plan_id": out.PlanID,
+		},
+	})
+	return out, nil
+}
+
 func parseNonNegativeInt(raw string) (int, bool) {
 	text := strings.TrimSpace(raw)
 	if text == "" {
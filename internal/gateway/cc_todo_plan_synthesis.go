@@ -0,0 +1,241 @@
+package gateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"ccgateway/internal/ccevent"
+	"ccgateway/internal/orchestrator"
+	"ccgateway/internal/plan"
+	"ccgateway/internal/structured"
+	"ccgateway/internal/todo"
+)
+
+const todoPlanSynthesisMaxTokens = 2048
+
+const todoPlanSynthesisPrompt = `You turn a coding agent's todo list into an executable plan. Order the work so that every step comes after the steps it needs, merge nothing and drop nothing: each todo becomes exactly one step and carries its todo_id. You may add a step without a todo_id only when a prerequisite is clearly missing.
+Answer with JSON only, in this shape:
+{"title": "...", "summary": "...", "steps": [{"id": "s1", "title": "...", "description": "...", "todo_id": "...", "depends_on": ["..."]}]}
+depends_on lists the ids of earlier steps that must finish first.`
+
+type todoPlanSynthesisRequest struct {
+	SessionID    string   `json:"session_id,omitempty"`
+	RunID        string   `json:"run_id,omitempty"`
+	TodoIDs      []string `json:"todo_ids,omitempty"`
+	Title        string   `json:"title,omitempty"`
+	Model        string   `json:"model,omitempty"`
+	Instructions string   `json:"instructions,omitempty"`
+}
+
+type todoPlanSynthesisResponse struct {
+	Plan      plan.Plan   `json:"plan"`
+	Todos     []todo.Todo `json:"todos"`
+	Model     string      `json:"model"`
+	Unplanned []string    `json:"unplanned_todo_ids,omitempty"`
+}
+
+// handleCCTodoSynthesizePlan asks the planner model to order a session's
+// todos into a plan with dependencies and links the todos to it.
+// POST /v1/cc/todos/synthesize-plan
+func (s *server) handleCCTodoSynthesizePlan(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+		return
+	}
+	if s.planStore == nil {
+		s.writeError(w, http.StatusNotImplemented, "api_error", "plan store is not configured")
+		return
+	}
+	var req todoPlanSynthesisRequest
+	if err := decodeJSONBodyStrict(r, &req, false); err != nil {
+		s.reportRequestDecodeIssue(r, err)
+		s.writeError(w, http.StatusBadRequest, "invalid_request_error", "invalid JSON body")
+		return
+	}
+	req.SessionID = strings.TrimSpace(req.SessionID)
+	if req.SessionID == "" && len(req.TodoIDs) == 0 {
+		s.writeError(w, http.StatusBadRequest, "invalid_request_error", "session_id or todo_ids is required")
+		return
+	}
+	model := strings.TrimSpace(req.Model)
+	if model == "" && s.settings != nil {
+		model = s.settings.Get().ToolLoop.PlannerModel
+	}
+	if model == "" {
+		s.writeError(w, http.StatusBadRequest, "invalid_request_error", "no planner model: pass model or set tool_loop.planner_model")
+		return
+	}
+
+	todos, err := s.todosForSynthesis(req)
+	if err != nil {
+		writeTodoStoreError(w, err)
+		return
+	}
+	if len(todos) == 0 {
+		s.writeError(w, http.StatusBadRequest, "invalid_request_error", "no open todos to plan")
+		return
+	}
+	if req.SessionID == "" {
+		req.SessionID = todos[0].SessionID
+	}
+
+	draft, err := s.synthesizePlan(r, req, model, todos)
+	if err != nil {
+		s.writeError(w, http.StatusBadGateway, "api_error", "plan synthesis failed: "+err.Error())
+		return
+	}
+	out, err := s.createPlan(draft)
+	if err != nil {
+		writePlanStoreError(w, err)
+		return
+	}
+
+	planned := make(map[string]bool, len(out.Steps))
+	for _, step := range out.Steps {
+		planned[step.TodoID] = true
+	}
+	resp := todoPlanSynthesisResponse{Plan: out, Todos: s.planTodosOrdered(out.ID), Model: model}
+	todoIDs := make([]string, 0, len(todos))
+	for _, td := range todos {
+		todoIDs = append(todoIDs, td.ID)
+		if !planned[td.ID] {
+			resp.Unplanned = append(resp.Unplanned, td.ID)
+		}
+	}
+	s.appendEvent(ccevent.AppendInput{
+		EventType: "plan.synthesized",
+		SessionID: out.SessionID,
+		RunID:     out.RunID,
+		PlanID:    out.ID,
+		Data: map[string]any{
+			"model":           model,
+			"todo_ids":        todoIDs,
+			"step_count":      len(out.Steps),
+			"unplanned_todos": len(resp.Unplanned),
+		},
+	})
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// todosForSynthesis resolves the requested todos, or the session's open
+// todos that are not yet part of a plan.
+func (s *server) todosForSynthesis(req todoPlanSynthesisRequest) ([]todo.Todo, error) {
+	if len(req.TodoIDs) > 0 {
+		out := make([]todo.Todo, 0, len(req.TodoIDs))
+		seen := map[string]bool{}
+		for _, raw := range req.TodoIDs {
+			id := strings.TrimSpace(raw)
+			if id == "" || seen[id] {
+				continue
+			}
+			seen[id] = true
+			td, ok := s.todoStore.Get(id)
+			if !ok {
+				return nil, fmt.Errorf("todo %q not found", id)
+			}
+			if req.SessionID != "" && td.SessionID != req.SessionID {
+				return nil, fmt.Errorf("todo %q belongs to another session", id)
+			}
+			out = append(out, td)
+		}
+		return out, nil
+	}
+	items := s.todoStore.List(todo.ListFilter{SessionID: req.SessionID})
+	out := make([]todo.Todo, 0, len(items))
+	// List is newest first; the planner sees todos in the order they were
+	// written down.
+	for i := len(items) - 1; i >= 0; i-- {
+		td := items[i]
+		if td.PlanID != "" || (td.Status != todo.StatusPending && td.Status != todo.StatusInProgress) {
+			continue
+		}
+		out = append(out, td)
+	}
+	return out, nil
+}
+
+// synthesizePlan calls the planner and turns its answer into a plan draft
+// whose steps are in dependency order and only reference the given todos.
+func (s *server) synthesizePlan(r *http.Request, req todoPlanSynthesisRequest, model string, todos []todo.Todo) (plan.CreateInput, error) {
+	type todoView struct {
+		ID          string `json:"todo_id"`
+		Title       string `json:"title"`
+		Description string `json:"description,omitempty"`
+		Status      string `json:"status"`
+	}
+	views := make([]todoView, 0, len(todos))
+	known := make(map[string]bool, len(todos))
+	for _, td := range todos {
+		views = append(views, todoView{ID: td.ID, Title: td.Title, Description: td.Description, Status: string(td.Status)})
+		known[td.ID] = true
+	}
+	listing, _ := json.MarshalIndent(views, "", "  ")
+	prompt := "Todos:\n" + string(listing)
+	if text := strings.TrimSpace(req.Instructions); text != "" {
+		prompt += "\n\nAdditional instructions:\n" + text
+	}
+
+	resp, err := s.orchestrator.Complete(r.Context(), orchestrator.Request{
+		RunID:     strings.TrimSpace(req.RunID),
+		Model:     model,
+		MaxTokens: todoPlanSynthesisMaxTokens,
+		System:    todoPlanSynthesisPrompt,
+		Messages:  []orchestrator.Message{{Role: "user", Content: prompt}},
+		Metadata:  map[string]any{"mode": "plan", "purpose": "todo_plan_synthesis", "session_id": req.SessionID},
+	})
+	if err != nil {
+		return plan.CreateInput{}, err
+	}
+	text, _, err := structured.Repair(collectResponseText(resp))
+	if err != nil {
+		return plan.CreateInput{}, fmt.Errorf("planner did not return JSON")
+	}
+	var draft struct {
+		Title   string      `json:"title"`
+		Summary string      `json:"summary"`
+		Steps   []plan.Step `json:"steps"`
+	}
+	if err := json.Unmarshal([]byte(text), &draft); err != nil {
+		return plan.CreateInput{}, fmt.Errorf("planner returned malformed plan: %v", err)
+	}
+	used := map[string]bool{}
+	for i := range draft.Steps {
+		id := strings.TrimSpace(draft.Steps[i].TodoID)
+		if known[id] && !used[id] {
+			used[id] = true
+		} else {
+			id = ""
+		}
+		draft.Steps[i].TodoID = id
+	}
+	steps, err := plan.OrderSteps(draft.Steps)
+	if err != nil {
+		return plan.CreateInput{}, err
+	}
+	if len(steps) == 0 {
+		return plan.CreateInput{}, fmt.Errorf("planner returned no steps")
+	}
+
+	title := strings.TrimSpace(req.Title)
+	if title == "" {
+		title = strings.TrimSpace(draft.Title)
+	}
+	if title == "" {
+		title = "Plan from todos"
+	}
+	return plan.CreateInput{
+		SessionID: req.SessionID,
+		RunID:     strings.TrimSpace(req.RunID),
+		Title:     title,
+		Summary:   draft.Summary,
+		Steps:     steps,
+		Metadata: map[string]any{
+			"source":        "todo_synthesis",
+			"planner_model": model,
+		},
+	}, nil
+}
@@ -2,8 +2,11 @@ package gateway
 
 import (
 	"context"
+	"net/http"
 	"strings"
+	"time"
 
+	"ccgateway/internal/channel"
 	"ccgateway/internal/token"
 	"ccgateway/internal/upstream"
 )
@@ -11,26 +14,50 @@ import (
 const defaultChannelGroup = "default"
 
 func (s *server) applyChannelRoutePolicy(ctx context.Context, metadata map[string]any, model string) map[string]any {
-	route := s.resolveChannelRoute(ctx, model)
-	if len(route) == 0 {
+	ch := s.resolveChannel(ctx, model)
+	if ch == nil {
 		return metadata
 	}
-	out := make(map[string]any, len(metadata)+2)
+	out := make(map[string]any, len(metadata)+3)
 	for k, v := range metadata {
 		out[k] = v
 	}
-	out["routing_adapter_route"] = route
+	out["routing_adapter_route"] = []string{strings.TrimSpace(ch.Name)}
 	out["routing_route_source"] = "channel"
+	out["routing_channel_id"] = ch.ID
+	// A channel binding replaces any canary route, so the request no longer
+	// belongs to either experiment arm.
+	delete(out, "canary_name")
+	delete(out, "canary_variant")
+	delete(out, "canary_cohort")
 	return out
 }
 
-func (s *server) resolveChannelRoute(ctx context.Context, model string) []string {
+// resolveChannel picks the channel serving model for the caller's group.
+// With a health tracker and a store that lists every candidate, channels of
+// the top priority are balanced by their health-adjusted weights.
+func (s *server) resolveChannel(ctx context.Context, model string) *channel.Channel {
 	model = strings.TrimSpace(model)
 	if model == "" || s.channelStore == nil {
 		return nil
 	}
+	lister, balanced := s.channelStore.(interface {
+		ChannelsByGroupAndModel(group, model string) []*channel.Channel
+	})
 
 	for _, group := range channelCandidateGroups(s.resolveUserGroup(ctx)) {
+		if balanced && s.channelHealth != nil {
+			var candidates []*channel.Channel
+			for _, ch := range lister.ChannelsByGroupAndModel(group, model) {
+				if name := strings.TrimSpace(ch.Name); name != "" && s.isKnownAdapterName(name) {
+					candidates = append(candidates, ch)
+				}
+			}
+			if ch := s.channelHealth.Pick(candidates); ch != nil {
+				return ch
+			}
+			continue
+		}
 		ch, ok := s.channelStore.GetChannelByGroupAndModel(group, model)
 		if !ok || ch == nil {
 			continue
@@ -39,12 +66,40 @@ func (s *server) resolveChannelRoute(ctx context.Context, model string) []string
 		if adapterName == "" || !s.isKnownAdapterName(adapterName) {
 			continue
 		}
-		return []string{adapterName}
+		return ch
 	}
 	return nil
 }
 
+func routedChannelID(metadata map[string]any) int64 {
+	id, _ := metadata["routing_channel_id"].(int64)
+	return id
+}
+
+// recordChannelOutcome feeds a finished run into the channel health
+// tracker. Client errors say nothing about the channel and are skipped;
+// rate limits and 5xx count as failures.
+func (s *server) recordChannelOutcome(id int64, status int, errText string, latency time.Duration) {
+	if id == 0 || s.channelHealth == nil || s.channelStore == nil {
+		return
+	}
+	failed := status >= http.StatusInternalServerError || status == http.StatusTooManyRequests
+	if status >= http.StatusBadRequest && !failed {
+		return
+	}
+	ch, ok := s.channelStore.GetChannel(id)
+	if !ok {
+		return
+	}
+	s.channelHealth.Record(ch, !failed, latency, "run", errText)
+}
+
+// resolveUserGroup picks the channel group: the project's when it sets
+// one, else the token owner's.
 func (s *server) resolveUserGroup(ctx context.Context) string {
+	if p, ok := s.projectFor(ctx); ok && p.ChannelGroup != "" {
+		return p.ChannelGroup
+	}
 	if ctx == nil || s.authService == nil {
 		return defaultChannelGroup
 	}
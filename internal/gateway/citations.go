@@ -0,0 +1,173 @@
+package gateway
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"ccgateway/internal/orchestrator"
+)
+
+const (
+	// citationChunkChars is the target size of one citable chunk.
+	citationChunkChars = 800
+	// maxCitationChunks caps how many chunks one tool result is split into;
+	// the remainder stays in the last chunk.
+	maxCitationChunks = 20
+	// citationSnippetChars bounds the chunk text echoed in a citation.
+	citationSnippetChars = 300
+
+	citationInstruction = "[CC_CITATIONS]\nTool results are split into sources labelled [S1], [S2], ... When your answer uses information from a source, cite it inline right after the claim, e.g. \"... [S2].\" Cite several sources as [S1][S3]. Only cite sources you actually used and never invent labels."
+)
+
+var citationMarkerRE = regexp.MustCompile(`\[S(\d+)\]`)
+
+type citationSource struct {
+	id        string
+	toolUseID string
+	toolName  string
+	chunk     int
+	text      string
+}
+
+// citationTracker labels tool result chunks as they are fed back to the
+// model and resolves the markers in the final answer.
+type citationTracker struct {
+	sources []citationSource
+}
+
+// citationsEnabled reads metadata.tool_citations, set from
+// tool_loop.citations or by the client.
+func citationsEnabled(metadata map[string]any) bool {
+	switch v := metadata["tool_citations"].(type) {
+	case bool:
+		return v
+	case string:
+		enabled, _ := strconv.ParseBool(strings.TrimSpace(v))
+		return enabled
+	}
+	return false
+}
+
+// labelResults rewrites the content of successful tool results into
+// labelled chunks. names maps tool_use ids to tool names.
+func (t *citationTracker) labelResults(results []any, names map[string]string) {
+	if t == nil {
+		return
+	}
+	for _, item := range results {
+		block, ok := item.(map[string]any)
+		if !ok || block["type"] != "tool_result" {
+			continue
+		}
+		if isErr, _ := block["is_error"].(bool); isErr {
+			continue
+		}
+		content, _ := block["content"].(string)
+		if strings.TrimSpace(content) == "" {
+			continue
+		}
+		toolUseID, _ := block["tool_use_id"].(string)
+		block["content"] = t.label(toolUseID, names[toolUseID], content)
+	}
+}
+
+func (t *citationTracker) label(toolUseID, toolName, content string) string {
+	chunks := chunkToolResult(content)
+	parts := make([]string, 0, len(chunks))
+	for i, chunk := range chunks {
+		id := "S" + strconv.Itoa(len(t.sources)+1)
+		t.sources = append(t.sources, citationSource{id: id, toolUseID: toolUseID, toolName: toolName, chunk: i, text: chunk})
+		parts = append(parts, "["+id+"] "+chunk)
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// chunkToolResult splits content on blank lines and packs paragraphs into
+// chunks of about citationChunkChars; an oversized paragraph stays whole.
+func chunkToolResult(content string) []string {
+	var chunks []string
+	var cur strings.Builder
+	for _, para := range strings.Split(strings.TrimSpace(content), "\n\n") {
+		para = strings.TrimSpace(para)
+		if para == "" {
+			continue
+		}
+		if cur.Len() > 0 && cur.Len()+len(para) > citationChunkChars && len(chunks) < maxCitationChunks-1 {
+			chunks = append(chunks, cur.String())
+			cur.Reset()
+		}
+		if cur.Len() > 0 {
+			cur.WriteString("\n\n")
+		}
+		cur.WriteString(para)
+	}
+	if cur.Len() > 0 {
+		chunks = append(chunks, cur.String())
+	}
+	return chunks
+}
+
+// system appends the citation instruction once sources exist.
+func (t *citationTracker) system(system any) any {
+	if t == nil || len(t.sources) == 0 {
+		return system
+	}
+	base := strings.TrimSpace(systemToText(system))
+	if strings.Contains(base, "[CC_CITATIONS]") {
+		return base
+	}
+	if base == "" {
+		return citationInstruction
+	}
+	return base + "\n\n" + citationInstruction
+}
+
+// resolve returns the cited sources in order of first mention. Markers
+// that name no known source are ignored.
+func (t *citationTracker) resolve(blocks []orchestrator.AssistantBlock) []orchestrator.Citation {
+	if t == nil || len(t.sources) == 0 {
+		return nil
+	}
+	var out []orchestrator.Citation
+	index := map[int]int{}
+	for _, b := range blocks {
+		if b.Type != "text" {
+			continue
+		}
+		for _, m := range citationMarkerRE.FindAllStringSubmatch(b.Text, -1) {
+			n, err := strconv.Atoi(m[1])
+			if err != nil || n < 1 || n > len(t.sources) {
+				continue
+			}
+			if i, ok := index[n]; ok {
+				out[i].Mentions++
+				continue
+			}
+			src := t.sources[n-1]
+			index[n] = len(out)
+			out = append(out, orchestrator.Citation{
+				SourceID:  src.id,
+				ToolUseID: src.toolUseID,
+				ToolName:  src.toolName,
+				Chunk:     src.chunk,
+				Text:      truncateText(src.text, citationSnippetChars),
+				Mentions:  1,
+			})
+		}
+	}
+	return out
+}
+
+// responseMetadata exposes resp's citations; nil keeps the field out of
+// the response body.
+func responseMetadata(resp orchestrator.Response) *ResponseMetadata {
+	if len(resp.Citations) == 0 {
+		return nil
+	}
+	out := &ResponseMetadata{Citations: make([]Citation, 0, len(resp.Citations))}
+	for _, c := range resp.Citations {
+		out.Citations = append(out.Citations, Citation(c))
+	}
+	return out
+}
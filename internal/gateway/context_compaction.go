@@ -0,0 +1,316 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"ccgateway/internal/ccevent"
+	"ccgateway/internal/orchestrator"
+	"ccgateway/internal/settings"
+)
+
+const (
+	compactedToolResultText = "[tool result omitted to fit the context window]"
+	compactionSummaryTokens = 600
+)
+
+const compactionSummaryPrompt = `Summarize the earlier part of this conversation so it can replace those messages. Keep the user's goals, decisions, constraints, file names, identifiers and tool outcomes that later turns may rely on. Be concise and factual; answer with the summary only.`
+
+// compactionReport describes what applyContextCompaction did.
+type compactionReport struct {
+	ContextWindow      int
+	TokensBefore       int
+	TokensAfter        int
+	Applied            []string
+	DroppedToolResults int
+	SummarizedMessages int
+	DroppedMessages    int
+	SummarizeError     string
+	Fits               bool
+}
+
+// applyContextCompaction shrinks req when its estimated size plus
+// max_tokens exceeds the context window configured for the upstream model,
+// running the mode's strategies in order until it fits.
+func (s *server) applyContextCompaction(ctx context.Context, req orchestrator.Request) orchestrator.Request {
+	scoped := s.settingsFor(ctx)
+	if scoped == nil {
+		return req
+	}
+	cfg := scoped.Get().Compaction
+	if !cfg.Enabled {
+		return req
+	}
+	window := cfg.ContextWindow(req.Model)
+	mode := stringFromAny(req.Metadata["mode"])
+	strategies := cfg.StrategiesFor(mode)
+	if window <= 0 || len(strategies) == 0 {
+		return req
+	}
+	budget := window - max(req.MaxTokens, 0)
+	before := estimateRequestContextTokens(req)
+	if before <= budget {
+		return req
+	}
+
+	report := compactionReport{ContextWindow: window, TokensBefore: before}
+	out := req
+	out.Messages = append([]orchestrator.Message(nil), req.Messages...)
+	tokens := before
+	for _, strategy := range strategies {
+		if tokens <= budget {
+			break
+		}
+		changed := false
+		switch strategy {
+		case settings.CompactDropToolResults:
+			var n int
+			out.Messages, n = dropOldToolResults(out.Messages, cfg.KeepRecent, func(msgs []orchestrator.Message) bool {
+				return estimateMessagesTokens(msgs)+estimateRequestOverhead(out) <= budget
+			})
+			report.DroppedToolResults += n
+			changed = n > 0
+		case settings.CompactSummarize:
+			split := compactionSplit(out.Messages, cfg.KeepRecent)
+			if split <= 0 {
+				continue
+			}
+			summary, err := s.summarizeForCompaction(ctx, cfg, out, out.Messages[:split])
+			if err != nil {
+				report.SummarizeError = err.Error()
+				continue
+			}
+			report.SummarizedMessages += split
+			out.Messages = prependCompactionNote(out.Messages[split:], "[Summary of earlier conversation]\n"+summary)
+			changed = true
+		case settings.CompactDropOldest:
+			split := compactionSplit(out.Messages, cfg.KeepRecent)
+			if split <= 0 {
+				continue
+			}
+			report.DroppedMessages += split
+			out.Messages = prependCompactionNote(out.Messages[split:], fmt.Sprintf("[%d earlier messages were omitted to fit the context window]", split))
+			changed = true
+		}
+		if changed {
+			report.Applied = append(report.Applied, strategy)
+			tokens = estimateRequestContextTokens(out)
+		}
+	}
+	report.TokensAfter = tokens
+	report.Fits = tokens <= budget
+	if len(report.Applied) == 0 && report.SummarizeError == "" {
+		return req
+	}
+
+	meta := map[string]any{}
+	for k, v := range out.Metadata {
+		meta[k] = v
+	}
+	if len(report.Applied) > 0 {
+		meta["context_compacted"] = true
+	}
+	out.Metadata = meta
+	s.appendCompactionEvent(out, report)
+	if len(report.Applied) == 0 {
+		return req
+	}
+	return out
+}
+
+func (s *server) summarizeForCompaction(ctx context.Context, cfg settings.CompactionSettings, req orchestrator.Request, msgs []orchestrator.Message) (string, error) {
+	if s.orchestrator == nil {
+		return "", fmt.Errorf("no orchestrator configured")
+	}
+	model := cfg.SummarizerModel
+	if model == "" {
+		model = req.Model
+	}
+	var transcript strings.Builder
+	for _, msg := range msgs {
+		transcript.WriteString(msg.Role)
+		transcript.WriteString(": ")
+		transcript.WriteString(compactionContentText(msg.Content))
+		transcript.WriteString("\n\n")
+	}
+	resp, err := s.orchestrator.Complete(ctx, orchestrator.Request{
+		RunID:     req.RunID,
+		Model:     model,
+		MaxTokens: compactionSummaryTokens,
+		System:    compactionSummaryPrompt,
+		Messages:  []orchestrator.Message{{Role: "user", Content: transcript.String()}},
+		Metadata:  map[string]any{"mode": stringFromAny(req.Metadata["mode"]), "purpose": "context_compaction"},
+	})
+	if err != nil {
+		return "", err
+	}
+	summary := strings.TrimSpace(collectResponseText(resp))
+	if summary == "" {
+		return "", fmt.Errorf("empty compaction summary")
+	}
+	return summary, nil
+}
+
+func (s *server) appendCompactionEvent(req orchestrator.Request, report compactionReport) {
+	data := map[string]any{
+		"path":           stringFromAny(req.Metadata["request_path"]),
+		"mode":           stringFromAny(req.Metadata["mode"]),
+		"model":          req.Model,
+		"context_window": report.ContextWindow,
+		"tokens_before":  report.TokensBefore,
+		"tokens_after":   report.TokensAfter,
+		"strategies":     report.Applied,
+		"fits":           report.Fits,
+	}
+	if report.DroppedToolResults > 0 {
+		data["dropped_tool_results"] = report.DroppedToolResults
+	}
+	if report.SummarizedMessages > 0 {
+		data["summarized_messages"] = report.SummarizedMessages
+	}
+	if report.DroppedMessages > 0 {
+		data["dropped_messages"] = report.DroppedMessages
+	}
+	if report.SummarizeError != "" {
+		data["summarize_error"] = report.SummarizeError
+	}
+	s.appendEvent(ccevent.AppendInput{
+		EventType: "context.compaction_applied",
+		SessionID: stringFromAny(req.Metadata["session_id"]),
+		RunID:     req.RunID,
+		Data:      data,
+	})
+}
+
+// dropOldToolResults blanks tool_result contents oldest first, outside the
+// last keep messages, until fits reports true. The blocks stay in place so
+// tool_use ids remain paired.
+func dropOldToolResults(msgs []orchestrator.Message, keep int, fits func([]orchestrator.Message) bool) ([]orchestrator.Message, int) {
+	dropped := 0
+	for i := 0; i < len(msgs)-keep; i++ {
+		blocks, ok := msgs[i].Content.([]any)
+		if !ok {
+			continue
+		}
+		var next []any
+		for j, item := range blocks {
+			block, ok := item.(map[string]any)
+			if !ok || block["type"] != "tool_result" || block["content"] == compactedToolResultText {
+				continue
+			}
+			if next == nil {
+				next = append([]any(nil), blocks...)
+			}
+			clone := make(map[string]any, len(block))
+			for k, v := range block {
+				clone[k] = v
+			}
+			clone["content"] = compactedToolResultText
+			next[j] = clone
+			dropped++
+		}
+		if next != nil {
+			msgs[i].Content = next
+			if fits(msgs) {
+				break
+			}
+		}
+	}
+	return msgs, dropped
+}
+
+// compactionSplit returns how many leading messages can be replaced while
+// keeping at least the last keep ones, so that the kept history starts with
+// a plain user turn rather than an orphaned tool_result.
+func compactionSplit(msgs []orchestrator.Message, keep int) int {
+	for split := len(msgs) - keep; split > 0; split-- {
+		if msgs[split].Role == "user" && !hasToolResult(msgs[split].Content) {
+			return split
+		}
+	}
+	return 0
+}
+
+func hasToolResult(content any) bool {
+	blocks, _ := content.([]any)
+	for _, item := range blocks {
+		if block, ok := item.(map[string]any); ok && block["type"] == "tool_result" {
+			return true
+		}
+	}
+	return false
+}
+
+// prependCompactionNote adds note as a leading text block of the first
+// (user) message.
+func prependCompactionNote(msgs []orchestrator.Message, note string) []orchestrator.Message {
+	out := append([]orchestrator.Message(nil), msgs...)
+	first := out[0]
+	noteBlock := map[string]any{"type": "text", "text": note}
+	switch c := first.Content.(type) {
+	case string:
+		first.Content = []any{noteBlock, map[string]any{"type": "text", "text": c}}
+	case []any:
+		first.Content = append([]any{noteBlock}, c...)
+	default:
+		first.Content = []any{noteBlock}
+	}
+	out[0] = first
+	return out
+}
+
+// estimateRequestContextTokens approximates the prompt size at four
+// characters per token, counting tool schemas and tool traffic too.
+func estimateRequestContextTokens(req orchestrator.Request) int {
+	return estimateMessagesTokens(req.Messages) + estimateRequestOverhead(req)
+}
+
+func estimateRequestOverhead(req orchestrator.Request) int {
+	chars := len(systemToText(req.System))
+	for _, tool := range req.Tools {
+		chars += len(tool.Name) + len(tool.Description)
+		if raw, err := json.Marshal(tool.InputSchema); err == nil {
+			chars += len(raw)
+		}
+	}
+	return chars / 4
+}
+
+func estimateMessagesTokens(msgs []orchestrator.Message) int {
+	chars := 0
+	for _, msg := range msgs {
+		chars += len(compactionContentText(msg.Content))
+	}
+	return chars / 4
+}
+
+func compactionContentText(content any) string {
+	switch c := content.(type) {
+	case string:
+		return c
+	case []any:
+		var sb strings.Builder
+		for _, item := range c {
+			block, ok := item.(map[string]any)
+			if !ok {
+				continue
+			}
+			switch block["type"] {
+			case "text":
+				sb.WriteString(stringFromAny(block["text"]))
+			case "tool_use":
+				raw, _ := json.Marshal(block["input"])
+				sb.WriteString(fmt.Sprintf("[tool_use %s] %s", stringFromAny(block["name"]), raw))
+			case "tool_result":
+				sb.WriteString("[tool_result] ")
+				sb.WriteString(compactionContentText(block["content"]))
+			}
+			sb.WriteString("\n")
+		}
+		return sb.String()
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,130 @@
+package gateway
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"ccgateway/internal/degrade"
+	"ccgateway/internal/settings"
+)
+
+// degradation is the ladder state applied to one request.
+type degradation struct {
+	Enabled   bool
+	Level     int
+	Actions   []string
+	MaxTokens int
+	Model     string
+	Reject    bool
+}
+
+// withLoadTracking counts a model request as in flight and records whether
+// it failed upstream. Rejections by the ladder itself (503) are not counted
+// as failures so a rejecting level does not keep itself active.
+func (s *server) withLoadTracking(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		done := s.loadMonitor.Begin()
+		rw := &auditResponseWriter{ResponseWriter: w, status: http.StatusOK}
+		defer func() {
+			done(rw.status >= 500 && rw.status != http.StatusServiceUnavailable)
+		}()
+		next(rw, r)
+	}
+}
+
+// degradationFor evaluates mode's ladder against the current load.
+func (s *server) degradationFor(mode string) degradation {
+	if s.settings == nil {
+		return degradation{}
+	}
+	cfg := s.settings.Get().Routing.Degradation
+	if !cfg.Enabled {
+		return degradation{}
+	}
+	ladder := cfg.LadderFor(mode)
+	load := s.loadMonitor.Snapshot(time.Duration(cfg.WindowSeconds) * time.Second)
+	out := degradation{Enabled: true, Level: degrade.Level(ladder, load, cfg.MinSamples)}
+	for _, step := range ladder[:out.Level] {
+		out.Actions = append(out.Actions, step.Action)
+		switch step.Action {
+		case settings.DegradeShrinkMaxTokens:
+			if out.MaxTokens == 0 || step.MaxTokens < out.MaxTokens {
+				out.MaxTokens = step.MaxTokens
+			}
+		case settings.DegradeCheapModel:
+			out.Model = step.Model
+		case settings.DegradeReject:
+			out.Reject = true
+		}
+	}
+	return out
+}
+
+// applyDegradation resolves the active level for mode, marks the response
+// with it and turns off the degraded subsystems on the request. Callers
+// must reject the request when the result says so and switch to Model when
+// it is set.
+func (s *server) applyDegradation(w http.ResponseWriter, mode string, metadata map[string]any, maxTokens *int) degradation {
+	d := s.degradationFor(mode)
+	if !d.Enabled {
+		return d
+	}
+	w.Header().Set("x-cc-degradation-level", strconv.Itoa(d.Level))
+	if d.Level == 0 {
+		return d
+	}
+	w.Header().Set("x-cc-degradation", strings.Join(d.Actions, ","))
+	if metadata != nil {
+		metadata["degradation_level"] = d.Level
+		for _, action := range d.Actions {
+			switch action {
+			case settings.DegradeDisableJudge:
+				metadata["enable_response_judge"] = false
+			case settings.DegradeDisableReflection:
+				metadata["reflection_passes"] = 0
+			}
+		}
+	}
+	if d.MaxTokens > 0 && maxTokens != nil && *maxTokens > d.MaxTokens {
+		*maxTokens = d.MaxTokens
+	}
+	return d
+}
+
+func (s *server) writeDegradedRejection(w http.ResponseWriter) {
+	w.Header().Set("retry-after", "10")
+	s.writeError(w, http.StatusServiceUnavailable, "overloaded_error", "gateway is degraded under load; retry later")
+}
+
+// degradationStatus reports the load and the active level per configured
+// ladder for /admin/status.
+func (s *server) degradationStatus() map[string]any {
+	if s.settings == nil {
+		return nil
+	}
+	cfg := s.settings.Get().Routing.Degradation
+	load := s.loadMonitor.Snapshot(time.Duration(cfg.WindowSeconds) * time.Second)
+	levels := map[string]int{"default": degrade.Level(cfg.Ladder, load, cfg.MinSamples)}
+	modes := make([]string, 0, len(cfg.ModeLadders))
+	for mode := range cfg.ModeLadders {
+		modes = append(modes, mode)
+	}
+	sort.Strings(modes)
+	for _, mode := range modes {
+		levels[mode] = degrade.Level(cfg.ModeLadders[mode], load, cfg.MinSamples)
+	}
+	if !cfg.Enabled {
+		for k := range levels {
+			levels[k] = 0
+		}
+	}
+	return map[string]any{
+		"enabled":        cfg.Enabled,
+		"window_seconds": cfg.WindowSeconds,
+		"load":           load,
+		"levels":         levels,
+	}
+}
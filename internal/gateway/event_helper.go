@@ -13,6 +13,9 @@ func (s *server) appendEvent(in ccevent.AppendInput) {
 		return
 	}
 	in = withRecordText(in)
+	if s.secretScanner.MasksLogs() {
+		in.Data, _ = s.secretScanner.MaskValue(in.Data).(map[string]any)
+	}
 	_, _ = s.eventStore.Append(in)
 }
 
@@ -0,0 +1,215 @@
+package gateway
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"ccgateway/internal/ccevent"
+	"ccgateway/internal/featureflag"
+)
+
+// applyFeatureFlags resolves the request's feature flags against the store,
+// turns off the subsystems whose flag is off and records the resolved set
+// under metadata.feature_flags. Requests may override flags by sending
+// metadata.feature_flags; kill switches win over overrides.
+func (s *server) applyFeatureFlags(metadata map[string]any) {
+	if s.featureFlags == nil || metadata == nil {
+		return
+	}
+	resolved := s.featureFlags.Resolve(featureflag.OverridesFromAny(metadata["feature_flags"]))
+	metadata["feature_flags"] = resolved
+	if !resolved[featureflag.ToolLoop] {
+		metadata["tool_loop_mode"] = toolLoopModeClient
+		metadata["tool_fallback_mode"] = "off"
+		delete(metadata, "tool_emulation_mode")
+	}
+	if !resolved[featureflag.Reflection] {
+		metadata["reflection_passes"] = 0
+	}
+	if !resolved[featureflag.Judge] {
+		metadata["enable_response_judge"] = false
+	}
+	if !resolved[featureflag.ParallelCandidates] {
+		metadata["parallel_candidates"] = 1
+	}
+}
+
+// featureEnabled reads a flag resolved by applyFeatureFlags. Flags are on
+// when no store resolved them.
+func featureEnabled(metadata map[string]any, name string) bool {
+	resolved, ok := metadata["feature_flags"].(map[string]bool)
+	if !ok {
+		return true
+	}
+	v, ok := resolved[name]
+	return !ok || v
+}
+
+// handleAdminFlags lists or upserts feature flags.
+// GET/POST /admin/flags
+func (s *server) handleAdminFlags(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	if s.featureFlags == nil {
+		s.writeError(w, http.StatusNotImplemented, "api_error", "feature flags are not configured")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		flags := s.featureFlags.List()
+		w.Header().Set("content-type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"data":  flags,
+			"total": len(flags),
+		})
+	case http.MethodPost:
+		var req struct {
+			Name        string `json:"name"`
+			Description string `json:"description"`
+			Enabled     bool   `json:"enabled"`
+			Killed      bool   `json:"killed"`
+		}
+		if err := decodeJSONBodyStrict(r, &req, false); err != nil {
+			s.reportRequestDecodeIssue(r, err)
+			s.writeError(w, http.StatusBadRequest, "invalid_request_error", "invalid JSON body")
+			return
+		}
+		flag, err := s.featureFlags.Put(featureflag.Flag{
+			Name:        req.Name,
+			Description: req.Description,
+			Enabled:     req.Enabled,
+			Killed:      req.Killed,
+		})
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+			return
+		}
+		s.appendFlagEvent("feature_flag.updated", flag)
+		w.Header().Set("content-type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(flag)
+	default:
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+	}
+}
+
+// handleAdminFlagByPath manages one flag and its kill switch.
+// GET/PUT/DELETE /admin/flags/{name}, POST /admin/flags/{name}/kill|restore
+func (s *server) handleAdminFlagByPath(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	if s.featureFlags == nil {
+		s.writeError(w, http.StatusNotImplemented, "api_error", "feature flags are not configured")
+		return
+	}
+	name, action, _ := strings.Cut(strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/flags/"), "/"), "/")
+	if strings.TrimSpace(name) == "" {
+		s.writeError(w, http.StatusNotFound, "not_found_error", "feature flag not found")
+		return
+	}
+	if action != "" {
+		s.handleAdminFlagKillSwitch(w, r, name, action)
+		return
+	}
+	current, ok := s.featureFlags.Get(name)
+	switch r.Method {
+	case http.MethodGet:
+		if !ok {
+			s.writeError(w, http.StatusNotFound, "not_found_error", featureflag.ErrNotFound.Error())
+			return
+		}
+		w.Header().Set("content-type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(current)
+	case http.MethodPut:
+		var req struct {
+			Description *string `json:"description"`
+			Enabled     *bool   `json:"enabled"`
+			Killed      *bool   `json:"killed"`
+		}
+		if err := decodeJSONBodyStrict(r, &req, false); err != nil {
+			s.reportRequestDecodeIssue(r, err)
+			s.writeError(w, http.StatusBadRequest, "invalid_request_error", "invalid JSON body")
+			return
+		}
+		next := current
+		next.Name = name
+		if req.Description != nil {
+			next.Description = *req.Description
+		}
+		if req.Enabled != nil {
+			next.Enabled = *req.Enabled
+		}
+		if req.Killed != nil {
+			next.Killed = *req.Killed
+		}
+		flag, err := s.featureFlags.Put(next)
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+			return
+		}
+		s.appendFlagEvent("feature_flag.updated", flag)
+		w.Header().Set("content-type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(flag)
+	case http.MethodDelete:
+		if err := s.featureFlags.Delete(name); err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, featureflag.ErrNotFound) {
+				status = http.StatusNotFound
+			}
+			s.writeError(w, status, "invalid_request_error", err.Error())
+			return
+		}
+		s.appendFlagEvent("feature_flag.deleted", current)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+	}
+}
+
+func (s *server) handleAdminFlagKillSwitch(w http.ResponseWriter, r *http.Request, name, action string) {
+	if r.Method != http.MethodPost {
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+		return
+	}
+	var killed bool
+	switch action {
+	case "kill":
+		killed = true
+	case "restore":
+		killed = false
+	default:
+		s.writeError(w, http.StatusNotFound, "not_found_error", "unknown feature flag action")
+		return
+	}
+	flag, err := s.featureFlags.SetKilled(name, killed)
+	if err != nil {
+		s.writeError(w, http.StatusNotFound, "not_found_error", err.Error())
+		return
+	}
+	eventType := "feature_flag.restored"
+	if killed {
+		eventType = "feature_flag.killed"
+	}
+	s.appendFlagEvent(eventType, flag)
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(flag)
+}
+
+func (s *server) appendFlagEvent(eventType string, flag featureflag.Flag) {
+	s.appendEvent(ccevent.AppendInput{
+		EventType: eventType,
+		Data: map[string]any{
+			"name":    flag.Name,
+			"enabled": flag.Enabled,
+			"killed":  flag.Killed,
+		},
+	})
+}
@@ -0,0 +1,104 @@
+package gateway
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"ccgateway/internal/files"
+)
+
+// resolveFileReferences inlines content blocks that point at an uploaded
+// file, so upstreams that know nothing about /v1/files still see the
+// content. It understands the Anthropic form
+// ({"type":"image"|"document","source":{"type":"file","file_id":…}}), the
+// chat completions form ({"type":"file","file":{"file_id":…}}) and the
+// responses forms ({"type":"input_file"|"input_image","file_id":…}).
+func (s *server) resolveFileReferences(ctx context.Context, messages []MessageParam) error {
+	if s.files == nil {
+		return nil
+	}
+	owner := requestOwner(ctx)
+	for i, msg := range messages {
+		blocks, ok := msg.Content.([]any)
+		if !ok {
+			continue
+		}
+		var next []any
+		for j, item := range blocks {
+			block, ok := item.(map[string]any)
+			if !ok {
+				continue
+			}
+			id := fileReferenceID(block)
+			if id == "" {
+				continue
+			}
+			data, f, err := s.files.Content(ctx, owner, id)
+			if err != nil {
+				return fmt.Errorf("messages[%d].content[%d]: file %s: %w", i, j, id, err)
+			}
+			if next == nil {
+				next = append([]any(nil), blocks...)
+			}
+			next[j] = inlineFileBlock(block, f, data)
+		}
+		if next != nil {
+			messages[i].Content = next
+		}
+	}
+	return nil
+}
+
+func fileReferenceID(block map[string]any) string {
+	switch stringFromAny(block["type"]) {
+	case "image", "document":
+		source, _ := block["source"].(map[string]any)
+		if stringFromAny(source["type"]) == "file" {
+			return strings.TrimSpace(stringFromAny(source["file_id"]))
+		}
+	case "file":
+		file, _ := block["file"].(map[string]any)
+		return strings.TrimSpace(stringFromAny(file["file_id"]))
+	case "input_file", "input_image":
+		return strings.TrimSpace(stringFromAny(block["file_id"]))
+	}
+	return ""
+}
+
+// inlineFileBlock rewrites a file reference into the same block format
+// carrying the content: text documents as text, everything else base64.
+func inlineFileBlock(block map[string]any, f files.File, data []byte) map[string]any {
+	out := make(map[string]any, len(block))
+	for k, v := range block {
+		out[k] = v
+	}
+	encoded := base64.StdEncoding.EncodeToString(data)
+	dataURL := "data:" + f.MimeType + ";base64," + encoded
+	image := strings.HasPrefix(f.MimeType, "image/")
+	switch stringFromAny(block["type"]) {
+	case "image", "document":
+		if !image && strings.HasPrefix(f.MimeType, "text/") {
+			out["source"] = map[string]any{"type": "text", "media_type": "text/plain", "data": string(data)}
+		} else {
+			out["source"] = map[string]any{"type": "base64", "media_type": f.MimeType, "data": encoded}
+		}
+		if stringFromAny(block["type"]) == "document" && out["title"] == nil {
+			out["title"] = f.Filename
+		}
+	case "file":
+		if image {
+			return map[string]any{"type": "image_url", "image_url": map[string]any{"url": dataURL}}
+		}
+		out["file"] = map[string]any{"filename": f.Filename, "file_data": dataURL}
+	case "input_image":
+		delete(out, "file_id")
+		out["image_url"] = dataURL
+	case "input_file":
+		delete(out, "file_id")
+		out["filename"] = f.Filename
+		out["file_data"] = dataURL
+	}
+	return out
+}
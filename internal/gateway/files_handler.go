@@ -0,0 +1,194 @@
+package gateway
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"ccgateway/internal/ccevent"
+	"ccgateway/internal/files"
+)
+
+// handleFiles uploads and lists the caller's files.
+// POST /v1/files takes multipart/form-data with a "file" part and an
+// optional "purpose" field.
+// GET /v1/files?purpose=&limit=&after=
+func (s *server) handleFiles(w http.ResponseWriter, r *http.Request) {
+	if s.files == nil {
+		s.writeError(w, http.StatusNotImplemented, "api_error", "files are not configured")
+		return
+	}
+	owner := requestOwner(r.Context())
+	switch r.Method {
+	case http.MethodGet:
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		if limit <= 0 || limit > 10000 {
+			limit = 20
+		}
+		items, hasMore := s.files.List(files.ListFilter{
+			Owner:   owner,
+			Purpose: strings.TrimSpace(r.URL.Query().Get("purpose")),
+			After:   strings.TrimSpace(r.URL.Query().Get("after")),
+			Limit:   limit,
+		})
+		if items == nil {
+			items = []files.File{}
+		}
+		out := map[string]any{"object": "list", "data": items, "first_id": nil, "last_id": nil, "has_more": hasMore}
+		if len(items) > 0 {
+			out["first_id"] = items[0].ID
+			out["last_id"] = items[len(items)-1].ID
+		}
+		w.Header().Set("content-type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(out)
+	case http.MethodPost:
+		// Leave room for the multipart framing around the file itself.
+		r.Body = http.MaxBytesReader(w, r.Body, s.files.Config().MaxBytes+1<<20)
+		if err := r.ParseMultipartForm(32 << 20); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				s.writeFileError(w, files.ErrTooLarge)
+				return
+			}
+			s.writeError(w, http.StatusBadRequest, "invalid_request_error", "expected multipart/form-data with a file part")
+			return
+		}
+		part, header, err := r.FormFile("file")
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, "invalid_request_error", "file is required")
+			return
+		}
+		defer part.Close()
+		data, err := io.ReadAll(part)
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, "invalid_request_error", "could not read file")
+			return
+		}
+		f, err := s.files.Upload(r.Context(), files.UploadInput{
+			Owner:     owner,
+			ProjectID: projectIDFromContext(r.Context()),
+			Filename:  header.Filename,
+			Purpose:   r.FormValue("purpose"),
+			MimeType:  header.Header.Get("content-type"),
+			Data:      data,
+		})
+		if err != nil {
+			s.writeFileError(w, err)
+			return
+		}
+		s.appendEvent(ccevent.AppendInput{
+			EventType: "file.uploaded",
+			Data: map[string]any{
+				"file_id":   f.ID,
+				"purpose":   f.Purpose,
+				"mime_type": f.MimeType,
+				"bytes":     f.Bytes,
+			},
+		})
+		w.Header().Set("content-type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(f)
+	default:
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+	}
+}
+
+// handleFileByPath serves one file.
+// GET/DELETE /v1/files/{id}
+// GET /v1/files/{id}/content
+// Batch output and error files are readable here too, by the ids the
+// batch object reports.
+func (s *server) handleFileByPath(w http.ResponseWriter, r *http.Request) {
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/v1/files/"), "/")
+	id, action, _ := strings.Cut(rest, "/")
+	if id == "" || (action != "" && action != "content") {
+		s.writeError(w, http.StatusNotFound, "not_found_error", "not found")
+		return
+	}
+	if action == "content" && s.serveBatchResultFile(w, r, id) {
+		return
+	}
+	if s.files == nil {
+		s.writeError(w, http.StatusNotImplemented, "api_error", "files are not configured")
+		return
+	}
+	owner := requestOwner(r.Context())
+	switch {
+	case action == "content" && r.Method == http.MethodGet:
+		data, f, err := s.files.Content(r.Context(), owner, id)
+		if err != nil {
+			s.writeFileError(w, err)
+			return
+		}
+		w.Header().Set("content-type", f.MimeType)
+		w.Header().Set("content-disposition", `attachment; filename="`+strings.ReplaceAll(f.Filename, `"`, "")+`"`)
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(data)
+	case action == "" && r.Method == http.MethodGet:
+		f, err := s.files.Get(r.Context(), owner, id)
+		if err != nil {
+			s.writeFileError(w, err)
+			return
+		}
+		w.Header().Set("content-type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(f)
+	case action == "" && r.Method == http.MethodDelete:
+		if err := s.files.Delete(r.Context(), owner, id); err != nil {
+			s.writeFileError(w, err)
+			return
+		}
+		s.appendEvent(ccevent.AppendInput{
+			EventType: "file.deleted",
+			Data:      map[string]any{"file_id": id},
+		})
+		w.Header().Set("content-type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(map[string]any{"id": id, "object": "file", "deleted": true})
+	default:
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+	}
+}
+
+// serveBatchResultFile answers GET /v1/files/{id}/content for a batch's
+// output_file_id or error_file_id. It reports false for other ids.
+func (s *server) serveBatchResultFile(w http.ResponseWriter, r *http.Request, id string) bool {
+	if s.batches == nil || r.Method != http.MethodGet {
+		return false
+	}
+	batchID, errorFile := strings.CutSuffix(id, "_errors")
+	if !errorFile {
+		var ok bool
+		if batchID, ok = strings.CutSuffix(id, "_output"); !ok {
+			return false
+		}
+	}
+	data, err := s.batches.Results(requestOwner(r.Context()), batchID, errorFile)
+	if err != nil {
+		return false
+	}
+	w.Header().Set("content-type", "application/jsonl")
+	w.Header().Set("content-disposition", `attachment; filename="`+id+`.jsonl"`)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(data)
+	return true
+}
+
+func (s *server) writeFileError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, files.ErrNotFound):
+		s.writeError(w, http.StatusNotFound, "not_found_error", err.Error())
+	case errors.Is(err, files.ErrTooLarge):
+		s.writeError(w, http.StatusRequestEntityTooLarge, "request_too_large", err.Error())
+	case errors.Is(err, files.ErrTypeNotAllowed):
+		s.writeError(w, http.StatusUnsupportedMediaType, "invalid_request_error", err.Error())
+	case errors.Is(err, files.ErrInvalidPurpose), errors.Is(err, files.ErrMissingFilename):
+		s.writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+	default:
+		s.writeError(w, http.StatusInternalServerError, "api_error", err.Error())
+	}
+}
@@ -0,0 +1,117 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"ccgateway/internal/guardrail"
+	"ccgateway/internal/orchestrator"
+)
+
+// Stream formats a held stream's refusal is written in.
+const (
+	streamFormatAnthropic = "anthropic"
+	streamFormatChat      = "chat"
+	streamFormatResponses = "responses"
+)
+
+// heldStreamWriter buffers a stream instead of sending it, so the output
+// guardrails can see the whole completion before the client does. Pings
+// from the sseGuard it sits inside still reach the client meanwhile.
+type heldStreamWriter struct {
+	http.ResponseWriter
+	buf bytes.Buffer
+}
+
+func (w *heldStreamWriter) Write(p []byte) (int, error) { return w.buf.Write(p) }
+
+func (w *heldStreamWriter) Flush() {}
+
+func (w *heldStreamWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+// holdStreamForGuardrails holds the stream written to the returned writer
+// while an output rule can block it. The returned func must be called with
+// the generated text once the stream is done: it sends the held stream, or
+// a refusal in format when a rule blocks it, and returns the text to store
+// for the run.
+func (s *server) holdStreamForGuardrails(w http.ResponseWriter, req orchestrator.Request, format, outwardModel string) (http.ResponseWriter, func(ctx context.Context, text string) string) {
+	if !s.guardrails.Blocks(guardrail.StageOutput) {
+		return w, func(ctx context.Context, text string) string {
+			return s.checkStreamedOutput(ctx, req, text)
+		}
+	}
+	held := &heldStreamWriter{ResponseWriter: w}
+	return held, func(ctx context.Context, text string) string {
+		blocked := false
+		if text != "" {
+			res := s.guardrails.Check(ctx, guardrail.StageOutput, []string{text})
+			s.recordGuardrailResult(nil, req, res, true)
+			if res.Changed([]string{text}) {
+				text = res.Texts[0]
+			}
+			blocked = res.Blocked
+		}
+		if blocked && strings.HasPrefix(w.Header().Get("content-type"), "text/event-stream") {
+			text = s.guardrails.BlockMessage()
+			s.writeStreamRefusal(w, format, outwardModel, text)
+		} else {
+			_, _ = w.Write(held.buf.Bytes())
+		}
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+		return text
+	}
+}
+
+// writeStreamRefusal writes a whole stream carrying only message, ended
+// the way each API marks filtered output.
+func (s *server) writeStreamRefusal(w http.ResponseWriter, format, outwardModel, message string) {
+	switch format {
+	case streamFormatAnthropic:
+		messageID := s.nextID("msg")
+		for _, ev := range []orchestrator.StreamEvent{
+			{Type: "message_start"},
+			{Type: "content_block_start", Block: orchestrator.AssistantBlock{Type: "text"}},
+			{Type: "content_block_delta", DeltaText: message},
+			{Type: "content_block_stop"},
+			{Type: "message_delta", StopReason: "refusal"},
+			{Type: "message_stop"},
+		} {
+			_ = writeSSE(w, ev.Type, streamPayloadFromEvent(ev, outwardModel, messageID))
+		}
+	case streamFormatChat:
+		streamID := s.nextID("chatcmpl")
+		created := time.Now().Unix()
+		for _, choice := range []map[string]any{
+			{"index": 0, "delta": map[string]any{"role": "assistant", "content": message}, "finish_reason": nil},
+			{"index": 0, "delta": map[string]any{}, "finish_reason": "content_filter"},
+		} {
+			raw, _ := json.Marshal(map[string]any{
+				"id":      streamID,
+				"object":  "chat.completion.chunk",
+				"created": created,
+				"model":   outwardModel,
+				"choices": []map[string]any{choice},
+			})
+			_ = writeOpenAISSEData(w, string(raw))
+		}
+		_ = writeOpenAISSEData(w, "[DONE]")
+	case streamFormatResponses:
+		respID := s.nextID("resp")
+		created := time.Now().Unix()
+		for _, ev := range []map[string]any{
+			{"type": "response.created", "id": respID, "model": outwardModel, "created": created},
+			{"type": "response.output_text.delta", "response_id": respID, "delta": message},
+			{"type": "response.completed", "id": respID, "model": outwardModel, "created": created},
+		} {
+			raw, _ := json.Marshal(ev)
+			_ = writeOpenAISSEData(w, string(raw))
+		}
+		_ = writeOpenAISSEData(w, "[DONE]")
+	}
+}
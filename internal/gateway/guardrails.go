@@ -0,0 +1,323 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"ccgateway/internal/auditlog"
+	"ccgateway/internal/ccevent"
+	"ccgateway/internal/guardrail"
+	"ccgateway/internal/orchestrator"
+)
+
+var errGuardrailBlocked = errors.New("request blocked by guardrail")
+
+// applyInputGuardrails runs the input stage over the prompt text. Redacted
+// text replaces the original in a copy of the request; a block returns an
+// error naming the rule.
+func (s *server) applyInputGuardrails(ctx context.Context, w http.ResponseWriter, req orchestrator.Request) (orchestrator.Request, error) {
+	if !s.guardrails.Enabled() {
+		return req, nil
+	}
+	texts := requestGuardrailTexts(req)
+	res := s.guardrails.Check(ctx, guardrail.StageInput, texts)
+	s.recordGuardrailResult(w, req, res, false)
+	if res.Blocked {
+		return req, fmt.Errorf("%w: %s", errGuardrailBlocked, res.BlockedBy)
+	}
+	if res.Changed(texts) {
+		req = replaceRequestGuardrailTexts(req, res.Texts)
+	}
+	return req, nil
+}
+
+// applyOutputGuardrails runs the output stage over a finished completion.
+// A block replaces the text with the configured message and sets the stop
+// reason to "refusal".
+func (s *server) applyOutputGuardrails(ctx context.Context, w http.ResponseWriter, req orchestrator.Request, resp orchestrator.Response) orchestrator.Response {
+	if !s.guardrails.Enabled() {
+		return resp
+	}
+	var texts []string
+	for _, b := range resp.Blocks {
+		if b.Type == "text" {
+			texts = append(texts, b.Text)
+		}
+	}
+	res := s.guardrails.Check(ctx, guardrail.StageOutput, texts)
+	s.recordGuardrailResult(w, req, res, false)
+	if res.Blocked {
+		resp.Blocks = []orchestrator.AssistantBlock{{Type: "text", Text: s.guardrails.BlockMessage()}}
+		resp.StopReason = "refusal"
+		return resp
+	}
+	if res.Changed(texts) {
+		blocks := make([]orchestrator.AssistantBlock, len(resp.Blocks))
+		copy(blocks, resp.Blocks)
+		i := 0
+		for j := range blocks {
+			if blocks[j].Type == "text" {
+				blocks[j].Text = res.Texts[i]
+				i++
+			}
+		}
+		resp.Blocks = blocks
+	}
+	return resp
+}
+
+// guardedPromptText returns the last user prompt of req once the input
+// guardrails have run, so run records, memory and traffic samples keep the
+// redacted text rather than original.
+func (s *server) guardedPromptText(original string, req orchestrator.Request) string {
+	if !s.guardrails.Enabled() {
+		return original
+	}
+	for i := len(req.Messages) - 1; i >= 0; i-- {
+		if !strings.EqualFold(strings.TrimSpace(req.Messages[i].Role), "user") {
+			continue
+		}
+		var parts []string
+		walkGuardrailText(req.Messages[i].Content, func(text string) string {
+			if text = strings.TrimSpace(text); text != "" {
+				parts = append(parts, text)
+			}
+			return text
+		})
+		return strings.Join(parts, "\n")
+	}
+	return original
+}
+
+// checkStreamedOutput runs the output stage over a completion that has
+// already been streamed. Nothing sent can be changed any more, so findings
+// are recorded and the redacted text is returned for storage only.
+func (s *server) checkStreamedOutput(ctx context.Context, req orchestrator.Request, text string) string {
+	if !s.guardrails.Enabled() || text == "" {
+		return text
+	}
+	texts := []string{text}
+	res := s.guardrails.Check(ctx, guardrail.StageOutput, texts)
+	s.recordGuardrailResult(nil, req, res, true)
+	if res.Changed(texts) {
+		return res.Texts[0]
+	}
+	return text
+}
+
+func (s *server) recordGuardrailResult(w http.ResponseWriter, req orchestrator.Request, res guardrail.Result, streamed bool) {
+	if res.Err != nil {
+		s.logger.Warn("guardrail moderation failed", "run_id", req.RunID, "error", res.Err)
+	}
+	if len(res.Findings) == 0 {
+		return
+	}
+	sessionID, _ := req.Metadata["session_id"].(string)
+	path, _ := req.Metadata["request_path"].(string)
+	tags := make([]string, 0, len(res.Findings))
+	for _, f := range res.Findings {
+		tags = append(tags, f.Rule+"="+f.Action)
+		data := map[string]any{
+			"stage":        f.Stage,
+			"rule":         f.Rule,
+			"kind":         f.Kind,
+			"action":       f.Action,
+			"matches":      f.Matches,
+			"request_path": path,
+		}
+		if len(f.Categories) > 0 {
+			data["categories"] = f.Categories
+		}
+		if streamed {
+			data["streamed"] = true
+		}
+		s.appendEvent(ccevent.AppendInput{
+			EventType: "guardrail.triggered",
+			SessionID: sessionID,
+			RunID:     req.RunID,
+			Data:      data,
+		})
+	}
+	if w != nil {
+		if prev := w.Header().Get("x-cc-guardrails"); prev != "" {
+			tags = append([]string{prev}, tags...)
+		}
+		w.Header().Set("x-cc-guardrails", strings.Join(tags, ","))
+	}
+}
+
+// requestGuardrailTexts lists the prompt's text segments: the system
+// prompt, then every message's text content, in order.
+// replaceRequestGuardrailTexts walks the request the same way.
+func requestGuardrailTexts(req orchestrator.Request) []string {
+	var out []string
+	collect := func(content any) {
+		walkGuardrailText(content, func(text string) string {
+			out = append(out, text)
+			return text
+		})
+	}
+	collect(req.System)
+	for _, m := range req.Messages {
+		collect(m.Content)
+	}
+	return out
+}
+
+func replaceRequestGuardrailTexts(req orchestrator.Request, texts []string) orchestrator.Request {
+	i := 0
+	next := func(text string) string {
+		if i >= len(texts) {
+			return text
+		}
+		text = texts[i]
+		i++
+		return text
+	}
+	req.System = walkGuardrailText(req.System, next)
+	msgs := make([]orchestrator.Message, len(req.Messages))
+	for j, m := range req.Messages {
+		m.Content = walkGuardrailText(m.Content, next)
+		msgs[j] = m
+	}
+	req.Messages = msgs
+	return req
+}
+
+// walkGuardrailText calls fn on each text segment of content and returns a
+// copy with the results; content it does not understand is returned as is.
+func walkGuardrailText(content any, fn func(string) string) any {
+	switch v := content.(type) {
+	case string:
+		return fn(v)
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			if block, ok := item.(map[string]any); ok {
+				out[i] = walkGuardrailBlock(block, fn)
+			} else {
+				out[i] = item
+			}
+		}
+		return out
+	case []map[string]any:
+		out := make([]map[string]any, len(v))
+		for i, block := range v {
+			out[i] = walkGuardrailBlock(block, fn)
+		}
+		return out
+	default:
+		return content
+	}
+}
+
+func walkGuardrailBlock(block map[string]any, fn func(string) string) map[string]any {
+	text, ok := block["text"].(string)
+	if !ok || (block["type"] != "text" && block["type"] != "input_text") {
+		return block
+	}
+	out := make(map[string]any, len(block))
+	for k, v := range block {
+		out[k] = v
+	}
+	out["text"] = fn(text)
+	return out
+}
+
+// handleAdminGuardrails reads or replaces the guardrails pipeline.
+// GET/PUT /admin/guardrails
+func (s *server) handleAdminGuardrails(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	if s.guardrails == nil {
+		s.writeError(w, http.StatusNotImplemented, "api_error", "guardrails are not configured")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var cfg guardrail.Config
+		if err := decodeJSONBodyStrict(r, &cfg, false); err != nil {
+			s.reportRequestDecodeIssue(r, err)
+			s.writeError(w, http.StatusBadRequest, "invalid_request_error", "invalid JSON body")
+			return
+		}
+		if err := s.guardrails.Update(cfg); err != nil {
+			s.writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+			return
+		}
+		s.appendEvent(ccevent.AppendInput{
+			EventType: "guardrails.updated",
+			Data: map[string]any{
+				"enabled": cfg.Enabled,
+				"rules":   len(cfg.Rules),
+				"actor":   auditlog.ActorID(adminTokenFromRequest(r)),
+			},
+		})
+	default:
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+		return
+	}
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"config":        s.guardrails.Config(),
+		"pii_detectors": guardrail.PIIDetectors(),
+	})
+}
+
+// handleAdminGuardrailsTest runs the current pipeline over sample text
+// without recording anything.
+// POST /admin/guardrails/test
+func (s *server) handleAdminGuardrailsTest(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	if s.guardrails == nil {
+		s.writeError(w, http.StatusNotImplemented, "api_error", "guardrails are not configured")
+		return
+	}
+	if r.Method != http.MethodPost {
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+		return
+	}
+	var req struct {
+		Stage string `json:"stage"`
+		Text  string `json:"text"`
+	}
+	if err := decodeJSONBodyStrict(r, &req, false); err != nil {
+		s.reportRequestDecodeIssue(r, err)
+		s.writeError(w, http.StatusBadRequest, "invalid_request_error", "invalid JSON body")
+		return
+	}
+	stage := strings.ToLower(strings.TrimSpace(req.Stage))
+	switch stage {
+	case "":
+		stage = guardrail.StageInput
+	case guardrail.StageInput, guardrail.StageOutput:
+	default:
+		s.writeError(w, http.StatusBadRequest, "invalid_request_error", "stage must be input or output")
+		return
+	}
+	res := s.guardrails.Check(r.Context(), stage, []string{req.Text})
+	out := map[string]any{
+		"stage":    stage,
+		"text":     res.Texts[0],
+		"blocked":  res.Blocked,
+		"findings": res.Findings,
+	}
+	if res.BlockedBy != "" {
+		out["blocked_by"] = res.BlockedBy
+	}
+	if res.Err != nil {
+		out["moderation_error"] = res.Err.Error()
+	}
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(out)
+}
@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"ccgateway/internal/auth"
+	"ccgateway/internal/ccevent"
 	"ccgateway/internal/token"
 )
 
@@ -22,60 +23,21 @@ func (s *server) handleAdminUsers(w http.ResponseWriter, r *http.Request) {
 
 	switch r.Method {
 	case http.MethodGet:
-		// Support pagination and search
-		limit := 0
-		offset := 0
-		search := ""
-
-		if l := strings.TrimSpace(r.URL.Query().Get("limit")); l != "" {
-			if n, err := strconv.Atoi(l); err == nil && n > 0 {
-				limit = n
-			}
-		}
-		if o := strings.TrimSpace(r.URL.Query().Get("offset")); o != "" {
-			if n, err := strconv.Atoi(o); err == nil && n > 0 {
-				offset = n
-			}
-		}
-		if s := strings.TrimSpace(r.URL.Query().Get("search")); s != "" {
-			search = s
-		}
-
-		users := s.authService.List()
-
-		// Simple search filter
-		if search != "" {
-			filtered := make([]*auth.User, 0)
-			search = strings.ToLower(search)
-			for _, u := range users {
-				if strings.Contains(strings.ToLower(u.Username), search) ||
-					strings.Contains(strings.ToLower(u.Email), search) ||
-					strings.Contains(strings.ToLower(u.DisplayName), search) {
-					filtered = append(filtered, u)
-				}
-			}
-			users = filtered
+		q, err := parseAdminListQuery(r.URL.Query(), adminUserListSpec)
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+			return
 		}
+		users := filterUsersForAdmin(s.authService.List(), q.Filters)
 		sortUsersForAdmin(users)
-
-		// Simple pagination
-		total := len(users)
-		if offset > 0 {
-			if offset >= total {
-				users = []*auth.User{}
-			} else {
-				users = users[offset:]
-			}
-		}
-		if limit > 0 && limit < len(users) {
-			users = users[:limit]
-		}
-
-		w.Header().Set("content-type", "application/json")
-		json.NewEncoder(w).Encode(map[string]any{
-			"data":  users,
-			"total": total,
-		})
+		sort.SliceStable(users, q.order(map[string]func(i, j int) bool{
+			"username":      func(i, j int) bool { return strings.ToLower(users[i].Username) < strings.ToLower(users[j].Username) },
+			"created_at":    func(i, j int) bool { return users[i].CreatedAt.Before(users[j].CreatedAt) },
+			"request_count": func(i, j int) bool { return users[i].RequestCount < users[j].RequestCount },
+			"used_quota":    func(i, j int) bool { return users[i].UsedQuota < users[j].UsedQuota },
+		}))
+		start, end := q.window(len(users))
+		s.writeAdminList(w, q, users[start:end], end-start, len(users))
 	case http.MethodPost:
 		var req struct {
 			Username string `json:"username"`
@@ -158,6 +120,16 @@ func (s *server) handleAdminUserByPath(w http.ResponseWriter, r *http.Request) {
 	if len(parts) >= 2 {
 		switch parts[1] {
 		case "tokens":
+			// /admin/auth/users/{userID}/tokens/{tokenID}/rotate
+			if len(parts) == 4 && parts[3] == "rotate" && strings.TrimSpace(parts[2]) != "" {
+				s.handleAdminTokenRotate(w, r, userID, parts[2])
+				return
+			}
+			// /admin/auth/users/{userID}/tokens/{tokenID}/quota-windows/reset
+			if len(parts) == 5 && parts[3] == "quota-windows" && parts[4] == "reset" && strings.TrimSpace(parts[2]) != "" {
+				s.handleAdminTokenQuotaWindowReset(w, r, userID, parts[2])
+				return
+			}
 			// /admin/auth/users/{userID}/tokens/{tokenID}
 			if len(parts) >= 3 && strings.TrimSpace(parts[2]) != "" {
 				s.handleAdminTokenByID(w, r, userID, parts[2])
@@ -169,6 +141,9 @@ func (s *server) handleAdminUserByPath(w http.ResponseWriter, r *http.Request) {
 		case "quota":
 			s.handleAdminUserQuota(w, r, userID)
 			return
+		case "preferences":
+			s.handleAdminUserPreferences(w, r, userID)
+			return
 		}
 	}
 
@@ -231,11 +206,17 @@ func (s *server) handleAdminUserByPath(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("content-type", "application/json")
 		json.NewEncoder(w).Encode(user)
 	case http.MethodDelete:
-		err := s.authService.Delete(userID)
+		var err error
+		if trash, ok := s.authService.(interface{ SoftDelete(id string) error }); ok {
+			err = trash.SoftDelete(userID)
+		} else {
+			err = s.authService.Delete(userID)
+		}
 		if err != nil {
 			s.writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
 			return
 		}
+		s.recordSoftDelete(r, "user", userID)
 		w.WriteHeader(http.StatusNoContent)
 	default:
 		s.writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
@@ -334,8 +315,11 @@ func (s *server) handleAdminUserTokens(w http.ResponseWriter, r *http.Request, u
 			Name      string `json:"name"`
 			Models    string `json:"models"`
 			Subnet    string `json:"subnet"`
+			Adapters  string `json:"adapters"`
 			ExpiredAt int64  `json:"expired_at"` // Unix timestamp, -1 = never
 			Status    *int   `json:"status,omitempty"`
+			// QuotaWindows adds daily/monthly budgets on top of quota.
+			QuotaWindows []token.QuotaWindow `json:"quota_windows,omitempty"`
 		}
 		// Allow empty body for default token
 		if err := decodeJSONBodyStrict(r, &req, true); err != nil {
@@ -349,6 +333,9 @@ func (s *server) handleAdminUserTokens(w http.ResponseWriter, r *http.Request, u
 			s.writeError(w, http.StatusInternalServerError, "api_error", err.Error())
 			return
 		}
+		// Work on a copy: Update diffs quota windows against the stored token.
+		created := *tk
+		tk = &created
 
 		// Apply additional settings
 		if req.Name != "" {
@@ -360,17 +347,26 @@ func (s *server) handleAdminUserTokens(w http.ResponseWriter, r *http.Request, u
 		if req.Subnet != "" {
 			tk.Subnet = &req.Subnet
 		}
+		if req.Adapters != "" {
+			tk.Adapters = &req.Adapters
+		}
 		if req.ExpiredAt != 0 {
 			tk.ExpiredAt = req.ExpiredAt
 		}
 		if req.Status != nil {
 			tk.Status = normalizeTokenStatusInput(*req.Status)
 		}
+		tk.QuotaWindows = req.QuotaWindows
 
 		if err := s.tokenService.Update(tk); err != nil {
 			s.writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
 			return
 		}
+		if len(tk.QuotaWindows) > 0 {
+			if stored, err := s.getTokenByID(userID, strconv.FormatInt(tk.ID, 10)); err == nil {
+				tk.QuotaWindows = stored.QuotaWindows
+			}
+		}
 
 		w.Header().Set("content-type", "application/json")
 		w.WriteHeader(http.StatusCreated)
@@ -420,13 +416,20 @@ func (s *server) handleAdminTokenByID(w http.ResponseWriter, r *http.Request, us
 		w.Header().Set("content-type", "application/json")
 		json.NewEncoder(w).Encode(tk)
 	case http.MethodPut:
+		// Work on a copy: Update diffs quota windows against the stored token.
+		current := *tk
+		tk = &current
 		var req struct {
 			Name      *string `json:"name"`
 			Quota     *int64  `json:"quota"`
 			Status    *int    `json:"status"`
 			Models    *string `json:"models"`
 			Subnet    *string `json:"subnet"`
+			Adapters  *string `json:"adapters"`
 			ExpiredAt *int64  `json:"expired_at"`
+			// QuotaWindows replaces the windows; [] removes them. Usage in
+			// periods that stay configured is kept.
+			QuotaWindows *[]token.QuotaWindow `json:"quota_windows"`
 		}
 		if err := decodeJSONBodyStrict(r, &req, false); err != nil {
 			s.reportRequestDecodeIssue(r, err)
@@ -450,30 +453,158 @@ func (s *server) handleAdminTokenByID(w http.ResponseWriter, r *http.Request, us
 		if req.Subnet != nil {
 			tk.Subnet = req.Subnet
 		}
+		if req.Adapters != nil {
+			tk.Adapters = req.Adapters
+		}
 		if req.ExpiredAt != nil {
 			tk.ExpiredAt = *req.ExpiredAt
 		}
+		if req.QuotaWindows != nil {
+			tk.QuotaWindows = *req.QuotaWindows
+		}
 
 		err := s.tokenService.Update(tk)
 		if err != nil {
 			s.writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
 			return
 		}
+		if stored, err := s.getTokenByID(userID, tokenID); err == nil {
+			tk.QuotaWindows = stored.QuotaWindows
+		}
 
 		w.Header().Set("content-type", "application/json")
 		json.NewEncoder(w).Encode(tk)
 	case http.MethodDelete:
-		err := s.tokenService.Delete(tk.Value)
+		var err error
+		if trash, ok := s.tokenService.(interface{ SoftDeleteByID(id int64) error }); ok {
+			err = trash.SoftDeleteByID(tk.ID)
+		} else if byID, ok := s.tokenService.(interface{ DeleteByID(id int64) error }); ok {
+			err = byID.DeleteByID(tk.ID)
+		} else {
+			err = s.tokenService.Delete(tk.Value)
+		}
 		if err != nil {
 			s.writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
 			return
 		}
+		s.recordSoftDelete(r, "token", strconv.FormatInt(tk.ID, 10))
 		w.WriteHeader(http.StatusNoContent)
 	default:
 		s.writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
 	}
 }
 
+// tokenRotator is implemented by token services that can reissue a token's
+// value in place.
+type tokenRotator interface {
+	Rotate(id int64) (*token.Token, error)
+}
+
+// handleAdminTokenRotate issues a new value for a token and invalidates the
+// old one. The new value is only returned by this response.
+// POST /admin/auth/users/{userID}/tokens/{tokenID}/rotate
+func (s *server) handleAdminTokenRotate(w http.ResponseWriter, r *http.Request, userID, tokenID string) {
+	if r.Method != http.MethodPost {
+		s.writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
+		return
+	}
+	if s.tokenService == nil {
+		s.writeError(w, http.StatusNotImplemented, "api_error", "token service not configured")
+		return
+	}
+	rotator, ok := s.tokenService.(tokenRotator)
+	if !ok {
+		s.writeError(w, http.StatusNotImplemented, "api_error", "token service does not support rotation")
+		return
+	}
+	tk, err := s.getTokenByID(userID, tokenID)
+	if err != nil {
+		s.writeError(w, http.StatusNotFound, "not_found", err.Error())
+		return
+	}
+	previousPrefix := tk.Prefix
+	rotated, err := rotator.Rotate(tk.ID)
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, "api_error", err.Error())
+		return
+	}
+	s.appendEvent(ccevent.AppendInput{
+		EventType: "token.rotated",
+		Data: map[string]any{
+			"user_id":         userID,
+			"token_id":        rotated.ID,
+			"prefix":          rotated.Prefix,
+			"previous_prefix": previousPrefix,
+		},
+	})
+	w.Header().Set("content-type", "application/json")
+	json.NewEncoder(w).Encode(rotated)
+}
+
+// tokenQuotaWindowResetter is implemented by token services with windowed
+// quotas.
+type tokenQuotaWindowResetter interface {
+	ResetQuotaWindows(id int64, period string) (*token.Token, error)
+}
+
+// handleAdminTokenQuotaWindowReset clears a token's windowed usage ahead of
+// its schedule, e.g. to unblock a team that ran out of its monthly budget.
+// POST /admin/auth/users/{userID}/tokens/{tokenID}/quota-windows/reset?period=daily|monthly
+func (s *server) handleAdminTokenQuotaWindowReset(w http.ResponseWriter, r *http.Request, userID, tokenID string) {
+	if r.Method != http.MethodPost {
+		s.writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
+		return
+	}
+	if s.tokenService == nil {
+		s.writeError(w, http.StatusNotImplemented, "api_error", "token service not configured")
+		return
+	}
+	resetter, ok := s.tokenService.(tokenQuotaWindowResetter)
+	if !ok {
+		s.writeError(w, http.StatusNotImplemented, "api_error", "token service does not support quota windows")
+		return
+	}
+	tk, err := s.getTokenByID(userID, tokenID)
+	if err != nil {
+		s.writeError(w, http.StatusNotFound, "not_found", err.Error())
+		return
+	}
+	period := strings.TrimSpace(r.URL.Query().Get("period"))
+	reset, err := resetter.ResetQuotaWindows(tk.ID, period)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, "invalid_request_error", "token has no matching quota window")
+		return
+	}
+	s.appendEvent(ccevent.AppendInput{
+		EventType: "token.quota_window_reset",
+		Data: map[string]any{
+			"user_id":  userID,
+			"token_id": tk.ID,
+			"period":   period,
+		},
+	})
+	w.Header().Set("content-type", "application/json")
+	json.NewEncoder(w).Encode(reset)
+}
+
+// appendQuotaThresholdEvent records a token crossing a quota window's soft
+// limit.
+func (s *server) appendQuotaThresholdEvent(ev token.ThresholdEvent) {
+	s.appendEvent(ccevent.AppendInput{
+		EventType: "quota.threshold_reached",
+		Data: map[string]any{
+			"user_id":      ev.UserID,
+			"token_id":     ev.TokenID,
+			"token_name":   ev.TokenName,
+			"period":       ev.Period,
+			"limit":        ev.Limit,
+			"used":         ev.Used,
+			"warn_percent": ev.WarnPercent,
+			"reset_at":     ev.ResetAt,
+		},
+	})
+}
+
 // getTokenByID retrieves a token by user ID and token ID
 func (s *server) getTokenByID(userID, tokenID string) (*token.Token, error) {
 	if s.tokenService == nil {
@@ -521,6 +652,39 @@ func (s *server) cleanupCreatedUserOnAdminCreate(user *auth.User) {
 	_ = s.authService.Delete(user.ID)
 }
 
+var adminUserListSpec = adminListSpec{
+	DefaultLimit: 100,
+	MaxLimit:     1000,
+	SortKeys:     []string{"username", "created_at", "request_count", "used_quota"},
+	DefaultSort:  "username",
+	Filters:      []string{"search", "role", "group", "status"},
+}
+
+// filterUsersForAdmin applies the users list filters: search matches the
+// username, email or display name; role, group and status match exactly.
+func filterUsersForAdmin(users []*auth.User, filters map[string]string) []*auth.User {
+	out := make([]*auth.User, 0, len(users))
+	for _, u := range users {
+		if u == nil {
+			continue
+		}
+		if search := filters["search"]; search != "" && !containsSubstringFold(u.Username, search) && !containsSubstringFold(u.Email, search) && !containsSubstringFold(u.DisplayName, search) {
+			continue
+		}
+		if role := filters["role"]; role != "" && !strings.EqualFold(u.Role, role) {
+			continue
+		}
+		if group := filters["group"]; group != "" && u.Group != group {
+			continue
+		}
+		if status := filters["status"]; status != "" && strconv.Itoa(u.Status) != status {
+			continue
+		}
+		out = append(out, u)
+	}
+	return out
+}
+
 func sortUsersForAdmin(users []*auth.User) {
 	sort.Slice(users, func(i, j int) bool {
 		left := users[i]
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -25,11 +26,22 @@ func (s *server) handleAdminChannels(w http.ResponseWriter, r *http.Request) {
 
 	switch r.Method {
 	case http.MethodGet:
-		channels := s.channelStore.ListChannels()
-		w.Header().Set("content-type", "application/json")
-		json.NewEncoder(w).Encode(map[string]any{
-			"data": channels,
-		})
+		q, err := parseAdminListQuery(r.URL.Query(), adminChannelListSpec)
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+			return
+		}
+		channels := filterChannelsForAdmin(s.channelStore.ListChannels(), q.Filters)
+		sort.SliceStable(channels, q.order(map[string]func(i, j int) bool{
+			"id":               func(i, j int) bool { return channels[i].ID < channels[j].ID },
+			"name":             func(i, j int) bool { return strings.ToLower(channels[i].Name) < strings.ToLower(channels[j].Name) },
+			"priority":         func(i, j int) bool { return channels[i].Priority < channels[j].Priority },
+			"response_time_ms": func(i, j int) bool { return channels[i].ResponseTime < channels[j].ResponseTime },
+			"used_quota":       func(i, j int) bool { return channels[i].UsedQuota < channels[j].UsedQuota },
+			"created_at":       func(i, j int) bool { return channels[i].CreatedAt.Before(channels[j].CreatedAt) },
+		}))
+		start, end := q.window(len(channels))
+		s.writeAdminList(w, q, channels[start:end], end-start, len(channels))
 	case http.MethodPost:
 		var ch channel.Channel
 		if err := decodeJSONBodyStrict(r, &ch, false); err != nil {
@@ -51,6 +63,10 @@ func (s *server) handleAdminChannels(w http.ResponseWriter, r *http.Request) {
 		if ch.Group == "" {
 			ch.Group = "default"
 		}
+		if ch.PromptPricePer1K < 0 || ch.CompletionPricePer1K < 0 {
+			s.writeError(w, http.StatusBadRequest, "invalid_request_error", "channel prices must be non-negative")
+			return
+		}
 
 		err := s.channelStore.AddChannel(&ch)
 		if err != nil {
@@ -66,6 +82,43 @@ func (s *server) handleAdminChannels(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+var adminChannelListSpec = adminListSpec{
+	DefaultLimit: 100,
+	MaxLimit:     1000,
+	SortKeys:     []string{"id", "name", "priority", "response_time_ms", "used_quota", "created_at"},
+	DefaultSort:  "id",
+	Filters:      []string{"search", "type", "group", "status", "model"},
+}
+
+// filterChannelsForAdmin applies the channel list filters: search matches
+// the name, model keeps channels that can serve it, and type, group and
+// status match exactly.
+func filterChannelsForAdmin(channels []*channel.Channel, filters map[string]string) []*channel.Channel {
+	out := make([]*channel.Channel, 0, len(channels))
+	for _, ch := range channels {
+		if ch == nil {
+			continue
+		}
+		if search := filters["search"]; search != "" && !containsSubstringFold(ch.Name, search) {
+			continue
+		}
+		if typ := filters["type"]; typ != "" && !strings.EqualFold(ch.Type, typ) {
+			continue
+		}
+		if group := filters["group"]; group != "" && ch.Group != group {
+			continue
+		}
+		if status := filters["status"]; status != "" && strconv.Itoa(ch.Status) != status {
+			continue
+		}
+		if model := filters["model"]; model != "" && !ch.CanHandleModel(model) {
+			continue
+		}
+		out = append(out, ch)
+	}
+	return out
+}
+
 // handleAdminChannelByPath handles individual channel operations
 // GET /admin/channels/{id} - Get channel
 // PUT /admin/channels/{id} - Update channel
@@ -92,6 +145,9 @@ func (s *server) handleAdminChannelByPath(w http.ResponseWriter, r *http.Request
 		case "test":
 			s.handleAdminChannelTestByID(w, r, id)
 			return
+		case "health":
+			s.handleAdminChannelHealthByID(w, r, id)
+			return
 		default:
 			s.writeError(w, http.StatusNotFound, "not_found", "channel endpoint not found")
 			return
@@ -155,6 +211,12 @@ func (s *server) handleAdminChannelByPath(w http.ResponseWriter, r *http.Request
 		if req.ModelMapping != nil {
 			existing.ModelMapping = req.ModelMapping
 		}
+		if req.PromptPricePer1K > 0 {
+			existing.PromptPricePer1K = req.PromptPricePer1K
+		}
+		if req.CompletionPricePer1K > 0 {
+			existing.CompletionPricePer1K = req.CompletionPricePer1K
+		}
 
 		err = s.channelStore.UpdateChannel(existing)
 		if err != nil {
@@ -165,11 +227,17 @@ func (s *server) handleAdminChannelByPath(w http.ResponseWriter, r *http.Request
 		w.Header().Set("content-type", "application/json")
 		json.NewEncoder(w).Encode(existing)
 	case http.MethodDelete:
-		err = s.channelStore.DeleteChannel(id)
+		if trash, ok := s.channelStore.(interface{ SoftDeleteChannel(id int64) error }); ok {
+			err = trash.SoftDeleteChannel(id)
+		} else {
+			err = s.channelStore.DeleteChannel(id)
+		}
 		if err != nil {
 			s.writeError(w, http.StatusBadRequest, "api_error", err.Error())
 			return
 		}
+		s.channelHealth.Forget(id)
+		s.recordSoftDelete(r, "channel", strconv.FormatInt(id, 10))
 		w.WriteHeader(http.StatusNoContent)
 	default:
 		s.writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
@@ -263,55 +331,100 @@ func (s *server) handleAdminChannelTestByID(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	// Channels without base_url cannot be probed over network.
-	if ch.BaseURL == nil || strings.TrimSpace(*ch.BaseURL) == "" {
-		w.Header().Set("content-type", "application/json")
-		json.NewEncoder(w).Encode(map[string]any{
-			"status":  "skipped",
-			"message": "channel test skipped: base_url is empty",
-		})
+	probe, err := s.probeChannel(ch)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
 		return
 	}
+	out := map[string]any{"status": probe.Status}
+	switch probe.Status {
+	case "skipped":
+		out["message"] = probe.Message
+	case "error":
+		out["message"] = probe.Message
+		out["latency_ms"] = probe.Latency.Milliseconds()
+	default:
+		out["http_status"] = probe.HTTPStatus
+		out["latency_ms"] = probe.Latency.Milliseconds()
+		out["url"] = probe.URL
+	}
+	w.Header().Set("content-type", "application/json")
+	json.NewEncoder(w).Encode(out)
+}
+
+// channelProbe is the outcome of one connectivity check: status is ok,
+// degraded, error or skipped.
+type channelProbe struct {
+	Status     string
+	Message    string
+	HTTPStatus int
+	Latency    time.Duration
+	URL        string
+}
+
+// probeChannel sends a HEAD request to the channel's base_url and feeds the
+// outcome into the health tracker. Channels without base_url cannot be
+// probed over network and are skipped; a base_url that is not http(s) is
+// an error.
+func (s *server) probeChannel(ch *channel.Channel) (channelProbe, error) {
+	if ch.BaseURL == nil || strings.TrimSpace(*ch.BaseURL) == "" {
+		return channelProbe{Status: "skipped", Message: "channel test skipped: base_url is empty"}, nil
+	}
 
 	target := strings.TrimSpace(*ch.BaseURL)
 	if !strings.HasPrefix(target, "http://") && !strings.HasPrefix(target, "https://") {
-		s.writeError(w, http.StatusBadRequest, "invalid_request_error", "channel base_url must be http or https")
-		return
+		return channelProbe{}, fmt.Errorf("channel base_url must be http or https")
 	}
 
 	started := time.Now()
 	req, err := http.NewRequest(http.MethodHead, target, nil)
 	if err != nil {
-		s.writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
-		return
+		return channelProbe{}, err
 	}
 	if strings.TrimSpace(ch.Key) != "" {
 		req.Header.Set("authorization", "Bearer "+strings.TrimSpace(ch.Key))
 	}
 	resp, err := (&http.Client{Timeout: 5 * time.Second}).Do(req)
-	latencyMS := time.Since(started).Milliseconds()
+	latency := time.Since(started)
 	if err != nil {
-		w.Header().Set("content-type", "application/json")
-		json.NewEncoder(w).Encode(map[string]any{
-			"status":     "error",
-			"message":    err.Error(),
-			"latency_ms": latencyMS,
-		})
-		return
+		s.channelHealth.Record(ch, false, latency, "probe", err.Error())
+		return channelProbe{Status: "error", Message: err.Error(), Latency: latency, URL: target}, nil
 	}
 	defer resp.Body.Close()
 
-	result := "ok"
+	probe := channelProbe{Status: "ok", HTTPStatus: resp.StatusCode, Latency: latency, URL: target}
 	if resp.StatusCode >= 500 {
-		result = "degraded"
+		probe.Status = "degraded"
+		s.channelHealth.Record(ch, false, latency, "probe", resp.Status)
+	} else {
+		s.channelHealth.Record(ch, true, latency, "probe", "")
+	}
+	return probe, nil
+}
+
+// handleAdminChannelHealthByID reports the computed health score, effective
+// weight and score history of a channel.
+// GET /admin/channels/{id}/health
+func (s *server) handleAdminChannelHealthByID(w http.ResponseWriter, r *http.Request, id int64) {
+	if r.Method != http.MethodGet {
+		s.writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
+		return
+	}
+	ch, ok := s.channelStore.GetChannel(id)
+	if !ok {
+		s.writeError(w, http.StatusNotFound, "not_found", "channel not found")
+		return
+	}
+	health := s.channelHealth.Snapshot(ch)
+	out := map[string]any{
+		"health":   health,
+		"adaptive": s.channelHealth != nil && s.channelHealth.Config().Enabled,
+	}
+	if s.channelHealth != nil {
+		out["config"] = s.channelHealth.Config()
 	}
 	w.Header().Set("content-type", "application/json")
-	json.NewEncoder(w).Encode(map[string]any{
-		"status":      result,
-		"http_status": resp.StatusCode,
-		"latency_ms":  latencyMS,
-		"url":         target,
-	})
+	json.NewEncoder(w).Encode(out)
 }
 
 func parseChannelPath(rawPath string) (int64, string, error) {
@@ -0,0 +1,50 @@
+package gateway
+
+import (
+	"context"
+
+	"ccgateway/internal/ccevent"
+	"ccgateway/internal/settings"
+	"ccgateway/internal/upstream"
+)
+
+// applyHedging asks the router to hedge the request when hedging is enabled
+// for its mode.
+func applyHedging(out map[string]any, cfg settings.HedgeSettings, mode string) {
+	if !cfg.Enabled || cfg.AfterMS <= 0 {
+		return
+	}
+	if len(cfg.Modes) > 0 && !containsFold(cfg.Modes, mode) {
+		return
+	}
+	out["hedge_after_ms"] = cfg.AfterMS
+}
+
+// withHedgeRecorder records a run.hedge event for every hedged upstream
+// call of the run, whether or not the hedge fired, so the delay can be
+// tuned from the share of calls it fired on and won.
+func (s *server) withHedgeRecorder(ctx context.Context, runID, sessionID string) context.Context {
+	return upstream.WithHedgeObserver(ctx, func(o upstream.HedgeOutcome) {
+		data := map[string]any{
+			"primary":        o.Primary,
+			"secondary":      o.Secondary,
+			"winner":         o.Winner,
+			"fired":          o.Fired,
+			"stream":         o.Stream,
+			"after_ms":       o.AfterMS,
+			"first_token_ms": o.FirstToken.Milliseconds(),
+		}
+		if o.PrimaryError != "" {
+			data["primary_error"] = o.PrimaryError
+		}
+		if o.Error != "" {
+			data["error"] = o.Error
+		}
+		s.appendEvent(ccevent.AppendInput{
+			EventType: "run.hedge",
+			SessionID: sessionID,
+			RunID:     runID,
+			Data:      data,
+		})
+	})
+}
@@ -0,0 +1,192 @@
+package gateway
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+
+	"ccgateway/internal/ccevent"
+	"ccgateway/internal/orchestrator"
+)
+
+type imagePreprocessStats struct {
+	Seen        int
+	Proxied     int
+	Resized     int
+	Failed      int
+	BytesBefore int
+	BytesAfter  int
+}
+
+// applyImagePreprocess bounds image blocks before they reach an upstream:
+// inline images are downscaled/recompressed to the configured limits and,
+// when proxying is enabled, remote image URLs are fetched and inlined.
+// Blocks that cannot be processed are left untouched.
+func (s *server) applyImagePreprocess(ctx context.Context, req orchestrator.Request) orchestrator.Request {
+	if s.imageProcessor == nil || !s.imageProcessor.Config().Enabled {
+		return req
+	}
+	stats := imagePreprocessStats{}
+	out := req
+	out.Messages = append([]orchestrator.Message(nil), req.Messages...)
+	for i, msg := range out.Messages {
+		blocks, ok := msg.Content.([]any)
+		if !ok {
+			continue
+		}
+		var next []any
+		for j, item := range blocks {
+			block, ok := item.(map[string]any)
+			if !ok {
+				continue
+			}
+			rewritten, changed := s.preprocessImageBlock(ctx, block, &stats)
+			if !changed {
+				continue
+			}
+			if next == nil {
+				next = append([]any(nil), blocks...)
+			}
+			next[j] = rewritten
+		}
+		if next != nil {
+			msg.Content = next
+			out.Messages[i] = msg
+		}
+	}
+	if stats.Seen == 0 {
+		return req
+	}
+	if stats.Proxied > 0 || stats.Resized > 0 || stats.Failed > 0 {
+		s.appendEvent(ccevent.AppendInput{
+			EventType: "vision.image_preprocessed",
+			RunID:     req.RunID,
+			Data: map[string]any{
+				"model":        req.Model,
+				"images":       stats.Seen,
+				"proxied":      stats.Proxied,
+				"resized":      stats.Resized,
+				"failed":       stats.Failed,
+				"bytes_before": stats.BytesBefore,
+				"bytes_after":  stats.BytesAfter,
+			},
+		})
+	}
+	return out
+}
+
+func (s *server) preprocessImageBlock(ctx context.Context, block map[string]any, stats *imagePreprocessStats) (map[string]any, bool) {
+	blockType := strings.ToLower(strings.TrimSpace(stringFromAny(block["type"])))
+	var mediaType, remoteURL string
+	var data []byte
+	switch blockType {
+	case "image":
+		source, _ := block["source"].(map[string]any)
+		switch strings.ToLower(strings.TrimSpace(stringFromAny(source["type"]))) {
+		case "base64":
+			raw, err := base64.StdEncoding.DecodeString(stringFromAny(source["data"]))
+			if err != nil {
+				return nil, false
+			}
+			mediaType, data = stringFromAny(source["media_type"]), raw
+		case "url":
+			remoteURL = strings.TrimSpace(stringFromAny(source["url"]))
+		default:
+			return nil, false
+		}
+	case "image_url":
+		imageURL := imageURLFromBlock(block["image_url"])
+		if strings.HasPrefix(imageURL, "data:") {
+			mt, raw, ok := decodeImageDataURL(imageURL)
+			if !ok {
+				return nil, false
+			}
+			mediaType, data = mt, raw
+		} else {
+			remoteURL = imageURL
+		}
+	default:
+		return nil, false
+	}
+
+	stats.Seen++
+	proxied := false
+	if remoteURL != "" {
+		if !s.imageProcessor.Config().ProxyURLs {
+			return nil, false
+		}
+		mt, raw, err := s.imageProcessor.Fetch(ctx, remoteURL)
+		if err != nil {
+			stats.Failed++
+			return nil, false
+		}
+		mediaType, data, proxied = mt, raw, true
+	}
+	res, err := s.imageProcessor.Normalize(mediaType, data)
+	if err != nil && !proxied {
+		stats.Failed++
+		return nil, false
+	}
+	if err != nil {
+		// Still inline the proxied original; the upstream may accept it.
+		stats.Failed++
+	}
+	if !proxied && !res.Recompressed {
+		return nil, false
+	}
+	stats.BytesBefore += res.OriginalBytes
+	stats.BytesAfter += len(res.Data)
+	if proxied {
+		stats.Proxied++
+	}
+	if res.Resized || res.Recompressed {
+		stats.Resized++
+	}
+	encoded := base64.StdEncoding.EncodeToString(res.Data)
+
+	out := make(map[string]any, len(block))
+	for k, v := range block {
+		out[k] = v
+	}
+	if blockType == "image" {
+		out["source"] = map[string]any{
+			"type":       "base64",
+			"media_type": res.MediaType,
+			"data":       encoded,
+		}
+		return out, true
+	}
+	imageURL := map[string]any{"url": "data:" + res.MediaType + ";base64," + encoded}
+	if prev, ok := block["image_url"].(map[string]any); ok {
+		for k, v := range prev {
+			if k != "url" {
+				imageURL[k] = v
+			}
+		}
+	}
+	out["image_url"] = imageURL
+	return out, true
+}
+
+func imageURLFromBlock(raw any) string {
+	switch v := raw.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case map[string]any:
+		return strings.TrimSpace(stringFromAny(v["url"]))
+	}
+	return ""
+}
+
+func decodeImageDataURL(raw string) (string, []byte, bool) {
+	payload := strings.TrimPrefix(raw, "data:")
+	comma := strings.Index(payload, ",")
+	if comma <= 0 || !strings.HasSuffix(payload[:comma], ";base64") {
+		return "", nil, false
+	}
+	data, err := base64.StdEncoding.DecodeString(payload[comma+1:])
+	if err != nil {
+		return "", nil, false
+	}
+	return strings.TrimSuffix(payload[:comma], ";base64"), data, true
+}
@@ -0,0 +1,77 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"ccgateway/internal/ipaccess"
+)
+
+type clientIPKey struct{}
+
+// withIPAccess resolves the client address once per request and rejects
+// clients the IP access policy does not admit.
+func (s *server) withIPAccess(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.ipAccess == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ip := s.ipAccess.ClientIP(r)
+		if err := s.ipAccess.Check(r.URL.Path, ip); err != nil {
+			s.writeError(w, http.StatusForbidden, "permission_error", "client ip is not allowed")
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientIPKey{}, ip)))
+	})
+}
+
+// handleAdminIPAccess reads or patches the client IP access policy. A patch
+// that would lock the calling client out of the admin API is rejected.
+// GET/PUT /admin/ip-access
+func (s *server) handleAdminIPAccess(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	if s.ipAccess == nil {
+		s.writeError(w, http.StatusNotImplemented, "api_error", "ip access policy is not configured")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var patch ipaccess.ConfigPatch
+		if err := decodeJSONBodyStrict(r, &patch, false); err != nil {
+			s.reportRequestDecodeIssue(r, err)
+			s.writeError(w, http.StatusBadRequest, "invalid_request_error", "invalid JSON body")
+			return
+		}
+		next, err := s.ipAccess.Preview(patch)
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+			return
+		}
+		candidate, err := ipaccess.NewPolicy(next)
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+			return
+		}
+		if err := candidate.Check(r.URL.Path, candidate.ClientIP(r)); err != nil {
+			s.writeError(w, http.StatusBadRequest, "invalid_request_error", "patch would deny this client admin access: "+err.Error())
+			return
+		}
+		if _, err := s.ipAccess.UpdateConfigPatch(patch); err != nil {
+			s.writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+			return
+		}
+	default:
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+		return
+	}
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"ip_access": s.ipAccess.Snapshot(),
+	})
+}
@@ -0,0 +1,62 @@
+package gateway
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"ccgateway/internal/settings"
+	"ccgateway/internal/upstream"
+)
+
+// applyJudgeRubric passes the judge rubric for mode to the router, which
+// hands it to the LLM judge for this request.
+func applyJudgeRubric(out map[string]any, cfg settings.JudgeSettings, mode string) {
+	rubric := upstreamJudgeRubric(cfg.RubricFor(mode))
+	if rubric.IsZero() {
+		return
+	}
+	out["judge_rubric"] = rubric
+}
+
+func upstreamJudgeRubric(in settings.JudgeRubric) upstream.JudgeRubric {
+	out := upstream.JudgeRubric{Prompt: in.Prompt}
+	for _, d := range in.Dimensions {
+		out.Dimensions = append(out.Dimensions, upstream.JudgeDimension{
+			Name:        d.Name,
+			Weight:      d.Weight,
+			Description: d.Description,
+		})
+	}
+	return out
+}
+
+// handleAdminJudgeRubric reads or replaces the judge rubric settings.
+// GET/PUT /admin/judge/rubric
+func (s *server) handleAdminJudgeRubric(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	if s.settings == nil {
+		s.writeError(w, http.StatusNotImplemented, "api_error", "settings store is not configured")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var req settings.JudgeSettings
+		if err := decodeJSONBodyStrict(r, &req, false); err != nil {
+			s.reportRequestDecodeIssue(r, err)
+			s.writeError(w, http.StatusBadRequest, "invalid_request_error", "invalid JSON body")
+			return
+		}
+		cfg := s.settings.Get()
+		cfg.Routing.Judge = req
+		s.settings.Put(cfg)
+	default:
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+		return
+	}
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(s.settings.Get().Routing.Judge)
+}
@@ -0,0 +1,136 @@
+package gateway
+
+import (
+	"math/rand"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"ccgateway/internal/settings"
+	"ccgateway/internal/upstream"
+)
+
+// shedCounter counts shed requests per priority class.
+type shedCounter struct {
+	mu     sync.Mutex
+	counts map[string]int64
+	last   time.Time
+}
+
+func newShedCounter() *shedCounter {
+	return &shedCounter{counts: map[string]int64{}}
+}
+
+func (c *shedCounter) add(class string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts[class]++
+	c.last = time.Now().UTC()
+}
+
+func (c *shedCounter) snapshot() (map[string]int64, int64, time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make(map[string]int64, len(c.counts))
+	var total int64
+	for class, n := range c.counts {
+		out[class] = n
+		total += n
+	}
+	return out, total, c.last
+}
+
+// observeUpstreamAttempt feeds adapter outcomes into the saturation rate,
+// the adapter health notifications and the anomaly detector.
+func (s *server) observeUpstreamAttempt(adapter string, err error) {
+	s.loadMonitor.ObserveUpstream(upstream.SaturationStatus(err) != 0)
+	s.watchAdapterHealth(adapter, err)
+	s.watchAnomalies(adapter, err)
+}
+
+// requestPriorityClass reads the caller's priority class from the
+// x-cc-priority header, then metadata.priority.
+func requestPriorityClass(r *http.Request, metadata map[string]any, fallback string) string {
+	class := strings.TrimSpace(r.Header.Get("x-cc-priority"))
+	if class == "" {
+		class = strings.TrimSpace(valueAsString(metadata["priority"]))
+	}
+	if class == "" {
+		return fallback
+	}
+	return strings.ToLower(class)
+}
+
+// shedRequest decides whether to drop the request because the upstreams
+// are saturated and its priority class is configured to give way.
+func (s *server) shedRequest(r *http.Request, metadata map[string]any) (string, bool) {
+	if s.settings == nil {
+		return "", false
+	}
+	cfg := s.settings.Get().Routing.LoadShedding
+	if !cfg.Enabled {
+		return "", false
+	}
+	class := requestPriorityClass(r, metadata, cfg.DefaultClass)
+	policy, ok := cfg.Classes[class]
+	if !ok {
+		return class, false
+	}
+	load := s.loadMonitor.Snapshot(time.Duration(cfg.WindowSeconds) * time.Second)
+	if !sheddingActive(policy, load.UpstreamCalls, load.SaturationRate, cfg.MinSamples) {
+		return class, false
+	}
+	if policy.Percent < 100 && rand.Float64()*100 >= policy.Percent {
+		return class, false
+	}
+	s.shedCounts.add(class)
+	return class, true
+}
+
+func sheddingActive(p settings.ShedPolicy, calls int, rate float64, minSamples int) bool {
+	return calls >= minSamples && rate >= p.SaturationRate
+}
+
+func (s *server) writeShedRejection(w http.ResponseWriter, class string) {
+	w.Header().Set("retry-after", "30")
+	w.Header().Set("x-cc-shed-class", class)
+	s.writeError(w, http.StatusServiceUnavailable, "overloaded_error", "upstreams are saturated; "+class+" priority traffic is being shed, retry later")
+}
+
+// loadSheddingStatus reports saturation, which classes are being shed and
+// shed counts for /admin/status.
+func (s *server) loadSheddingStatus() map[string]any {
+	if s.settings == nil {
+		return nil
+	}
+	cfg := s.settings.Get().Routing.LoadShedding
+	load := s.loadMonitor.Snapshot(time.Duration(cfg.WindowSeconds) * time.Second)
+	counts, total, last := s.shedCounts.snapshot()
+	classes := make([]string, 0, len(cfg.Classes))
+	for class := range cfg.Classes {
+		classes = append(classes, class)
+	}
+	sort.Strings(classes)
+	active := []string{}
+	for _, class := range classes {
+		if cfg.Enabled && sheddingActive(cfg.Classes[class], load.UpstreamCalls, load.SaturationRate, cfg.MinSamples) {
+			active = append(active, class)
+		}
+	}
+	out := map[string]any{
+		"enabled":         cfg.Enabled,
+		"window_seconds":  cfg.WindowSeconds,
+		"upstream_calls":  load.UpstreamCalls,
+		"saturated":       load.Saturated,
+		"saturation_rate": load.SaturationRate,
+		"shedding":        active,
+		"shed_total":      total,
+		"shed_by_class":   counts,
+	}
+	if !last.IsZero() {
+		out["last_shed_at"] = last
+	}
+	return out
+}
@@ -0,0 +1,444 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"ccgateway/internal/ccrun"
+	"ccgateway/internal/plan"
+	"ccgateway/internal/todo"
+)
+
+const (
+	mcpServerName = "cc-gateway"
+
+	jsonRPCParseError     = -32700
+	jsonRPCInvalidRequest = -32600
+	jsonRPCMethodNotFound = -32601
+	jsonRPCInvalidParams  = -32602
+)
+
+// mcpServerProtocolVersions lists the MCP revisions /mcp speaks, newest
+// first.
+var mcpServerProtocolVersions = []string{"2025-03-26", "2024-11-05"}
+
+// negotiateMCPProtocolVersion answers a client's initialize: its own
+// revision when the server speaks it, otherwise the server's latest.
+func negotiateMCPProtocolVersion(requested string) string {
+	requested = strings.TrimSpace(requested)
+	for _, v := range mcpServerProtocolVersions {
+		if v == requested {
+			return v
+		}
+	}
+	return mcpServerProtocolVersions[0]
+}
+
+// gatewayMCPTool is one gateway capability exposed over /mcp.
+type gatewayMCPTool struct {
+	name        string
+	description string
+	schema      map[string]any
+	// writes marks tools that change persisted state; they are refused while
+	// persistence is read-only.
+	writes bool
+	call   func(ctx context.Context, args json.RawMessage) (any, error)
+}
+
+type jsonRPCRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type jsonRPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// handleMCPServer serves the gateway's own runs, plans, todos and tool
+// catalog as an MCP server over streamable HTTP (JSON responses only).
+// POST /mcp
+func (s *server) handleMCPServer(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("allow", http.MethodPost)
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+		return
+	}
+	var req jsonRPCRequest
+	if err := decodeJSONBody(r, &req, false, false); err != nil {
+		writeJSONRPC(w, nil, nil, &jsonRPCError{Code: jsonRPCParseError, Message: "invalid JSON-RPC body"})
+		return
+	}
+	if req.JSONRPC != "2.0" || strings.TrimSpace(req.Method) == "" {
+		writeJSONRPC(w, req.ID, nil, &jsonRPCError{Code: jsonRPCInvalidRequest, Message: "invalid JSON-RPC request"})
+		return
+	}
+	// Notifications carry no id and get no response body.
+	if len(req.ID) == 0 {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	switch req.Method {
+	case "initialize":
+		var params struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		if len(req.Params) > 0 {
+			_ = json.Unmarshal(req.Params, &params)
+		}
+		writeJSONRPC(w, req.ID, map[string]any{
+			"protocolVersion": negotiateMCPProtocolVersion(params.ProtocolVersion),
+			"capabilities": map[string]any{
+				"tools": map[string]any{"listChanged": false},
+			},
+			"serverInfo": map[string]any{"name": mcpServerName, "version": "1"},
+		}, nil)
+	case "ping":
+		writeJSONRPC(w, req.ID, map[string]any{}, nil)
+	case "tools/list":
+		tools := s.gatewayMCPTools()
+		out := make([]map[string]any, 0, len(tools))
+		for _, t := range tools {
+			out = append(out, map[string]any{
+				"name":        t.name,
+				"description": t.description,
+				"inputSchema": t.schema,
+			})
+		}
+		writeJSONRPC(w, req.ID, map[string]any{"tools": out}, nil)
+	case "tools/call":
+		var params struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil || strings.TrimSpace(params.Name) == "" {
+			writeJSONRPC(w, req.ID, nil, &jsonRPCError{Code: jsonRPCInvalidParams, Message: "tools/call requires a tool name"})
+			return
+		}
+		tool, ok := findGatewayMCPTool(s.gatewayMCPTools(), params.Name)
+		if !ok {
+			writeJSONRPC(w, req.ID, nil, &jsonRPCError{Code: jsonRPCInvalidParams, Message: "unknown tool: " + params.Name})
+			return
+		}
+		writeJSONRPC(w, req.ID, s.callGatewayMCPTool(r.Context(), tool, params.Arguments), nil)
+	default:
+		writeJSONRPC(w, req.ID, nil, &jsonRPCError{Code: jsonRPCMethodNotFound, Message: "method not found: " + req.Method})
+	}
+}
+
+// callGatewayMCPTool runs tool and wraps the outcome as an MCP tool result;
+// tool failures are reported in-band with isError rather than as RPC errors.
+func (s *server) callGatewayMCPTool(ctx context.Context, tool gatewayMCPTool, args json.RawMessage) map[string]any {
+	if tool.writes && s.persistence != nil && s.persistence.Health().ReadOnly {
+		return mcpToolErrorResult("state persistence is degraded; gateway state is read-only")
+	}
+	if len(args) == 0 || string(args) == "null" {
+		args = json.RawMessage(`{}`)
+	}
+	value, err := tool.call(ctx, args)
+	if err != nil {
+		return mcpToolErrorResult(err.Error())
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return mcpToolErrorResult(err.Error())
+	}
+	return map[string]any{
+		"content": []map[string]any{{"type": "text", "text": string(raw)}},
+		"isError": false,
+	}
+}
+
+func mcpToolErrorResult(msg string) map[string]any {
+	return map[string]any{
+		"content": []map[string]any{{"type": "text", "text": msg}},
+		"isError": true,
+	}
+}
+
+func findGatewayMCPTool(tools []gatewayMCPTool, name string) (gatewayMCPTool, bool) {
+	name = strings.TrimSpace(name)
+	for _, t := range tools {
+		if t.name == name {
+			return t, true
+		}
+	}
+	return gatewayMCPTool{}, false
+}
+
+func writeJSONRPC(w http.ResponseWriter, id json.RawMessage, result any, rpcErr *jsonRPCError) {
+	body := map[string]any{"jsonrpc": "2.0"}
+	if len(id) > 0 {
+		body["id"] = id
+	} else {
+		body["id"] = nil
+	}
+	if rpcErr != nil {
+		body["error"] = rpcErr
+	} else {
+		body["result"] = result
+	}
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// decodeMCPArgs unmarshals tool arguments strictly into dst.
+func decodeMCPArgs(args json.RawMessage, dst any) error {
+	dec := json.NewDecoder(strings.NewReader(string(args)))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(dst); err != nil {
+		return fmt.Errorf("invalid arguments: %v", err)
+	}
+	return nil
+}
+
+func mcpObjectSchema(required []string, props map[string]any) map[string]any {
+	schema := map[string]any{"type": "object", "properties": props}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+var (
+	mcpStringProp = map[string]any{"type": "string"}
+	mcpIntProp    = map[string]any{"type": "integer", "minimum": 0}
+	mcpBoolProp   = map[string]any{"type": "boolean"}
+)
+
+// gatewayMCPTools lists the capabilities backed by the stores this gateway
+// was built with.
+func (s *server) gatewayMCPTools() []gatewayMCPTool {
+	var tools []gatewayMCPTool
+	if s.runStore != nil {
+		tools = append(tools,
+			gatewayMCPTool{
+				name:        "runs_list",
+				description: "List recent gateway runs, newest first.",
+				schema: mcpObjectSchema(nil, map[string]any{
+					"limit": mcpIntProp, "session_id": mcpStringProp, "status": mcpStringProp, "path": mcpStringProp,
+				}),
+				call: func(_ context.Context, args json.RawMessage) (any, error) {
+					var in struct {
+						Limit     int    `json:"limit"`
+						SessionID string `json:"session_id"`
+						Status    string `json:"status"`
+						Path      string `json:"path"`
+					}
+					if err := decodeMCPArgs(args, &in); err != nil {
+						return nil, err
+					}
+					if in.Limit <= 0 {
+						in.Limit = 20
+					}
+					items := s.runStore.List(ccrun.ListFilter{Limit: in.Limit, SessionID: in.SessionID, Status: in.Status, Path: in.Path})
+					return map[string]any{"data": items, "count": len(items)}, nil
+				},
+			},
+			gatewayMCPTool{
+				name:        "runs_get",
+				description: "Get one gateway run by id.",
+				schema:      mcpObjectSchema([]string{"id"}, map[string]any{"id": mcpStringProp}),
+				call: func(_ context.Context, args json.RawMessage) (any, error) {
+					var in struct {
+						ID string `json:"id"`
+					}
+					if err := decodeMCPArgs(args, &in); err != nil {
+						return nil, err
+					}
+					run, ok := s.runStore.Get(in.ID)
+					if !ok {
+						return nil, fmt.Errorf("run %q not found", in.ID)
+					}
+					return run, nil
+				},
+			},
+		)
+	}
+	if s.planStore != nil {
+		stepSchema := map[string]any{
+			"type": "array",
+			"items": mcpObjectSchema([]string{"title"}, map[string]any{
+				"title": mcpStringProp, "description": mcpStringProp,
+			}),
+		}
+		tools = append(tools,
+			gatewayMCPTool{
+				name:        "plans_list",
+				description: "List plans, optionally filtered by status or session.",
+				schema: mcpObjectSchema(nil, map[string]any{
+					"limit": mcpIntProp, "status": mcpStringProp, "session_id": mcpStringProp, "run_id": mcpStringProp,
+				}),
+				call: func(_ context.Context, args json.RawMessage) (any, error) {
+					var in struct {
+						Limit     int    `json:"limit"`
+						Status    string `json:"status"`
+						SessionID string `json:"session_id"`
+						RunID     string `json:"run_id"`
+					}
+					if err := decodeMCPArgs(args, &in); err != nil {
+						return nil, err
+					}
+					items := s.planStore.List(plan.ListFilter{Limit: in.Limit, Status: in.Status, SessionID: in.SessionID, RunID: in.RunID})
+					return map[string]any{"data": items, "count": len(items)}, nil
+				},
+			},
+			gatewayMCPTool{
+				name:        "plans_get",
+				description: "Get one plan by id.",
+				schema:      mcpObjectSchema([]string{"id"}, map[string]any{"id": mcpStringProp}),
+				call: func(_ context.Context, args json.RawMessage) (any, error) {
+					var in struct {
+						ID string `json:"id"`
+					}
+					if err := decodeMCPArgs(args, &in); err != nil {
+						return nil, err
+					}
+					p, ok := s.planStore.Get(in.ID)
+					if !ok {
+						return nil, fmt.Errorf("plan %q not found", in.ID)
+					}
+					return p, nil
+				},
+			},
+			gatewayMCPTool{
+				name:        "plans_create",
+				description: "Create a draft plan; each step also becomes a todo.",
+				schema: mcpObjectSchema([]string{"title"}, map[string]any{
+					"title": mcpStringProp, "summary": mcpStringProp, "session_id": mcpStringProp, "run_id": mcpStringProp, "steps": stepSchema,
+				}),
+				writes: true,
+				call: func(_ context.Context, args json.RawMessage) (any, error) {
+					var in plan.CreateInput
+					if err := decodeMCPArgs(args, &in); err != nil {
+						return nil, err
+					}
+					return s.createPlan(in)
+				},
+			},
+			gatewayMCPTool{
+				name:        "plans_approve",
+				description: "Approve a draft plan.",
+				schema:      mcpObjectSchema([]string{"id"}, map[string]any{"id": mcpStringProp}),
+				writes:      true,
+				call: func(_ context.Context, args json.RawMessage) (any, error) {
+					var in struct {
+						ID string `json:"id"`
+					}
+					if err := decodeMCPArgs(args, &in); err != nil {
+						return nil, err
+					}
+					return s.approvePlan(in.ID, plan.ApproveInput{})
+				},
+			},
+			gatewayMCPTool{
+				name:        "plans_execute",
+				description: "Advance an approved plan; set complete or failed to finish it.",
+				schema: mcpObjectSchema([]string{"id"}, map[string]any{
+					"id": mcpStringProp, "complete": mcpBoolProp, "failed": mcpBoolProp,
+				}),
+				writes: true,
+				call: func(_ context.Context, args json.RawMessage) (any, error) {
+					var in struct {
+						ID       string `json:"id"`
+						Complete bool   `json:"complete"`
+						Failed   bool   `json:"failed"`
+					}
+					if err := decodeMCPArgs(args, &in); err != nil {
+						return nil, err
+					}
+					return s.executePlan(in.ID, plan.ExecuteInput{Complete: in.Complete, Failed: in.Failed})
+				},
+			},
+		)
+	}
+	if s.todoStore != nil {
+		statusProp := map[string]any{"type": "string", "enum": []string{
+			string(todo.StatusPending), string(todo.StatusInProgress), string(todo.StatusCompleted), string(todo.StatusBlocked), string(todo.StatusCanceled),
+		}}
+		tools = append(tools,
+			gatewayMCPTool{
+				name:        "todos_list",
+				description: "List todos, optionally filtered by status, session or plan.",
+				schema: mcpObjectSchema(nil, map[string]any{
+					"limit": mcpIntProp, "status": mcpStringProp, "session_id": mcpStringProp, "run_id": mcpStringProp, "plan_id": mcpStringProp,
+				}),
+				call: func(_ context.Context, args json.RawMessage) (any, error) {
+					var in struct {
+						Limit     int    `json:"limit"`
+						Status    string `json:"status"`
+						SessionID string `json:"session_id"`
+						RunID     string `json:"run_id"`
+						PlanID    string `json:"plan_id"`
+					}
+					if err := decodeMCPArgs(args, &in); err != nil {
+						return nil, err
+					}
+					items := s.todoStore.List(todo.ListFilter{Limit: in.Limit, Status: in.Status, SessionID: in.SessionID, RunID: in.RunID, PlanID: in.PlanID})
+					return map[string]any{"data": items, "count": len(items)}, nil
+				},
+			},
+			gatewayMCPTool{
+				name:        "todos_create",
+				description: "Create a todo.",
+				schema: mcpObjectSchema([]string{"title"}, map[string]any{
+					"title": mcpStringProp, "description": mcpStringProp, "status": statusProp,
+					"session_id": mcpStringProp, "run_id": mcpStringProp, "plan_id": mcpStringProp,
+				}),
+				writes: true,
+				call: func(_ context.Context, args json.RawMessage) (any, error) {
+					var in todo.CreateInput
+					if err := decodeMCPArgs(args, &in); err != nil {
+						return nil, err
+					}
+					return s.createTodo(in)
+				},
+			},
+			gatewayMCPTool{
+				name:        "todos_update",
+				description: "Update a todo's title, description or status.",
+				schema: mcpObjectSchema([]string{"id"}, map[string]any{
+					"id": mcpStringProp, "title": mcpStringProp, "description": mcpStringProp, "status": statusProp,
+				}),
+				writes: true,
+				call: func(_ context.Context, args json.RawMessage) (any, error) {
+					var in struct {
+						ID          string  `json:"id"`
+						Title       *string `json:"title"`
+						Description *string `json:"description"`
+						Status      *string `json:"status"`
+					}
+					if err := decodeMCPArgs(args, &in); err != nil {
+						return nil, err
+					}
+					return s.updateTodo(in.ID, todo.UpdateInput{Title: in.Title, Description: in.Description, Status: in.Status})
+				},
+			},
+		)
+	}
+	if s.toolCatalog != nil {
+		tools = append(tools, gatewayMCPTool{
+			name:        "tool_catalog_list",
+			description: "List the gateway tool catalog with each tool's support status.",
+			schema:      mcpObjectSchema(nil, map[string]any{}),
+			call: func(_ context.Context, args json.RawMessage) (any, error) {
+				var in struct{}
+				if err := decodeMCPArgs(args, &in); err != nil {
+					return nil, err
+				}
+				specs := s.toolCatalog.Snapshot()
+				sort.Slice(specs, func(i, j int) bool { return specs[i].Name < specs[j].Name })
+				return map[string]any{"data": specs, "count": len(specs)}, nil
+			},
+		})
+	}
+	return tools
+}
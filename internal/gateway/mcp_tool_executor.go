@@ -36,7 +36,7 @@ func (e *mcpAwareExecutor) Execute(ctx context.Context, call toolruntime.Call) (
 	if e.mcp == nil {
 		return toolruntime.Result{}, toolruntime.ErrToolNotImplemented
 	}
-	remote, err := callScopedMCPToolAny(ctx, e.mcp, call.Name, call.Input)
+	remote, err := callScopedMCPToolAny(mcpregistry.WithToolSession(ctx, call.SessionID), e.mcp, call.Name, call.Input)
 	if err != nil {
 		return toolruntime.Result{}, err
 	}
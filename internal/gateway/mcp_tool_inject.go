@@ -0,0 +1,85 @@
+package gateway
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"ccgateway/internal/featureflag"
+	"ccgateway/internal/mcpregistry"
+	"ccgateway/internal/policy"
+	"ccgateway/internal/requestctx"
+)
+
+// maxInjectedMCPTools bounds how many discovered MCP tools one request can
+// carry so a large registry does not blow up the prompt.
+const maxInjectedMCPTools = 64
+
+// injectMCPTools appends tools from enabled MCP servers in the caller's
+// project to declared when auto_inject_mcp_tools is on and the gateway runs
+// the tool loop itself. Client declarations win on name clashes and every
+// injected tool must pass the tool policy on its own.
+func (s *server) injectMCPTools(ctx context.Context, path, mode, model string, metadata map[string]any, declared []ToolDefinition) ([]ToolDefinition, []string) {
+	if scoped := s.settingsFor(ctx); scoped == nil || s.mcpRegistry == nil || !scoped.Get().AutoInjectMCPTools {
+		return declared, nil
+	}
+	if !toolLoopConfigFromMetadata(metadata).enabled {
+		return declared, nil
+	}
+	policyCtx := s.policyContext(ctx)
+	seen := make(map[string]struct{}, len(declared))
+	for _, t := range declared {
+		seen[strings.ToLower(strings.TrimSpace(t.Name))] = struct{}{}
+	}
+
+	listCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	if !featureEnabled(metadata, featureflag.ToolsCache) {
+		listCtx = mcpregistry.WithoutToolsCache(listCtx)
+	}
+	projectID := requestctx.ProjectID(ctx)
+	out := declared
+	var injected []string
+	for _, server := range s.mcpRegistry.List(0) {
+		if !server.Enabled || !mcpServerBelongsToProject(projectID, server) {
+			continue
+		}
+		tools, err := s.mcpRegistry.ListTools(listCtx, server.ID)
+		if err != nil {
+			continue
+		}
+		for _, tool := range tools {
+			name := strings.TrimSpace(tool.Name)
+			key := strings.ToLower(name)
+			if name == "" {
+				continue
+			}
+			if _, dup := seen[key]; dup {
+				continue
+			}
+			seen[key] = struct{}{}
+			if err := s.policy.Authorize(policyCtx, policy.Action{
+				Path:      path,
+				Model:     model,
+				Mode:      mode,
+				ToolNames: []string{name},
+			}); err != nil {
+				continue
+			}
+			schema := tool.InputSchema
+			if schema == nil {
+				schema = map[string]any{"type": "object"}
+			}
+			out = append(out, ToolDefinition{
+				Name:        name,
+				Description: tool.Description,
+				InputSchema: schema,
+			})
+			injected = append(injected, name)
+			if len(injected) >= maxInjectedMCPTools {
+				return out, injected
+			}
+		}
+	}
+	return out, injected
+}
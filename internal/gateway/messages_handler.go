@@ -1,7 +1,6 @@
 package gateway
 
 import (
-	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -10,10 +9,11 @@ import (
 
 	"ccgateway/internal/ccevent"
 	"ccgateway/internal/ccrun"
-	"ccgateway/internal/memory"
+	"ccgateway/internal/logging"
 	"ccgateway/internal/orchestrator"
 	"ccgateway/internal/policy"
 	"ccgateway/internal/runlog"
+	"ccgateway/internal/tokenizer"
 )
 
 func (s *server) handleMessages(w http.ResponseWriter, r *http.Request) {
@@ -29,6 +29,10 @@ func (s *server) handleMessages(w http.ResponseWriter, r *http.Request) {
 	toolCount := 0
 	sessionID := ""
 	generatedText := ""
+	promptText := ""
+	memoryOn := false
+	channelID := int64(0)
+	var sampleMetadata map[string]any
 	defer func() {
 		recordText := buildRunRecordText("/v1/messages", mode, statusCode, streamMode, generatedText, errText)
 		s.logRun(runlog.Entry{
@@ -45,8 +49,9 @@ func (s *server) handleMessages(w http.ResponseWriter, r *http.Request) {
 			RecordText:     recordText,
 			DurationMS:     time.Since(started).Milliseconds(),
 		})
+		s.recordChannelOutcome(channelID, statusCode, errText, time.Since(started))
 		if runID != "" {
-			s.completeRunIfConfigured(runID, statusCode, errText)
+			s.completeRunIfConfigured(runID, statusCode, errText, promptText, generatedText, recordText)
 		}
 		if runID != "" {
 			eventType := "run.completed"
@@ -68,6 +73,10 @@ func (s *server) handleMessages(w http.ResponseWriter, r *http.Request) {
 				},
 			})
 		}
+		s.offerTrafficSample(runID, sessionID, "/v1/messages", mode, upstreamModel, statusCode, streamMode, promptText, generatedText, sampleMetadata)
+		if memoryOn && statusCode < 400 {
+			s.rememberSessionExchange(sessionID, runID, promptText, generatedText)
+		}
 	}()
 
 	if r.Method != http.MethodPost {
@@ -92,6 +101,8 @@ func (s *server) handleMessages(w http.ResponseWriter, r *http.Request) {
 		s.writeError(w, http.StatusBadRequest, "invalid_request_error", "invalid JSON body")
 		return
 	}
+	prefs := s.requestUserPreferences(r.Context())
+	applyPreferredDefaults(prefs, &req.Model, &req.Temperature)
 	if err := validateMessagesRequest(req); err != nil {
 		statusCode = http.StatusBadRequest
 		errText = err.Error()
@@ -101,113 +112,103 @@ func (s *server) handleMessages(w http.ResponseWriter, r *http.Request) {
 	if err := s.enforceTokenModelAccess(r.Context(), req.Model); err != nil {
 		statusCode = http.StatusForbidden
 		errText = err.Error()
-		s.writeError(w, http.StatusForbidden, "permission_error", err.Error())
+		s.writeModelAccessError(w, err)
+		return
+	}
+	if err := s.resolveFileReferences(r.Context(), req.Messages); err != nil {
+		statusCode = http.StatusBadRequest
+		errText = err.Error()
+		s.writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
 		return
 	}
-	mode = requestMode(r, req.Metadata)
+	mode = requestModeOr(r, req.Metadata, prefs.Mode)
 	clientModel = req.Model
 	streamMode = req.Stream
 	toolCount = len(req.Tools)
 	sessionID = requestSessionID(r, req.Metadata)
-	req.System = s.applySystemPromptPrefix(mode, req.System)
-	req.Metadata = s.applyRoutingPolicy(mode, req.Metadata)
-
-	// --- Memory Integration Start ---
-	if s.memoryStore != nil && sessionID != "" {
-		ctx := r.Context()
-		// 1. Get working memory
-		wm, err := s.memoryStore.GetWorkingMemory(ctx, sessionID)
-		if err != nil {
-			s.appendEvent(ccevent.AppendInput{
-				EventType: "memory.error",
-				SessionID: sessionID,
-				RunID:     runID,
-				Data: map[string]any{
-					"stage": "get_working_memory",
-					"error": err.Error(),
-				},
-			})
-		}
-
-		// 2. Append current user message
-		if wm != nil {
-			lastUserMsg := req.Messages[len(req.Messages)-1]
-			wm.Messages = append(wm.Messages, memory.Message{
-				Role:      lastUserMsg.Role,
-				Content:   contentToMemoryText(lastUserMsg.Content),
-				Timestamp: time.Now(),
-			})
-			_ = s.memoryStore.UpdateWorkingMemory(ctx, wm)
-		}
-
-		// 3. Summarization Check
-		if wm != nil && len(wm.Messages) > 10 && s.summarizer != nil {
-			go func(sid string, msgs []memory.Message) {
-				// Async summarization to avoid blocking response
-				summary, err := s.summarizer.SummarizeRecent(context.Background(), msgs)
-				if err == nil {
-					sm, _ := s.memoryStore.GetSessionMemory(context.Background(), sid)
-					if sm == nil {
-						sm = &memory.SessionMemory{SessionID: sid}
-					}
-					sm.Summary = summary
-					_ = s.memoryStore.UpdateSessionMemory(context.Background(), sm)
-
-					// Trucate working memory (keep last 4 messages + current)
-					// Verify concurrency safety in real implementation
-					wmPayload, _ := s.memoryStore.GetWorkingMemory(context.Background(), sid)
-					if wmPayload != nil && len(wmPayload.Messages) > 4 {
-						wmPayload.Messages = wmPayload.Messages[len(wmPayload.Messages)-4:]
-						_ = s.memoryStore.UpdateWorkingMemory(context.Background(), wmPayload)
-					}
-				}
-			}(sessionID, wm.Messages)
-		}
-
-		// 4. Context Construction (Optional - for this implementation we just logging it)
-		// To truly use it, we would replace req.Messages with buildContextMessages(wm, sm)
-		// However, since we are a gateway, strictly replacing messages might break client expectations
-		// So we will inject the summary into System prompt if available
-		sm, _ := s.memoryStore.GetSessionMemory(ctx, sessionID)
-		if sm != nil && sm.Summary != "" {
-			summarySystemMsg := fmt.Sprintf("\n\nPrevious Conversation Summary:\n%s", sm.Summary)
-			if req.System == nil {
-				req.System = summarySystemMsg
-			} else {
-				req.System = fmt.Sprintf("%v%s", req.System, summarySystemMsg)
-			}
-		}
+	promptText = lastUserPromptText(req.Messages)
+	sampleMetadata = req.Metadata
+	req.Metadata = s.applyRoutingPolicy(r.Context(), mode, req.Metadata)
+	req.System = applyPreferredSystemPrompt(prefs, req.System)
+	req.System = s.applySystemPromptPrefix(r.Context(), mode, req.System, req.Metadata)
+	if memoryOn = s.sessionMemoryEnabled(sessionID, req.Metadata); memoryOn {
+		req.System = s.injectSessionMemory(r.Context(), sessionID, req.System)
+	}
+	if class, shed := s.shedRequest(r, req.Metadata); shed {
+		statusCode = http.StatusServiceUnavailable
+		errText = "shed under upstream saturation (" + class + " priority)"
+		s.writeShedRejection(w, class)
+		return
+	}
+	deg := s.applyDegradation(w, mode, req.Metadata, &req.MaxTokens)
+	if deg.Reject {
+		statusCode = http.StatusServiceUnavailable
+		errText = "rejected by degradation ladder"
+		s.writeDegradedRejection(w)
+		return
 	}
-	// --- Memory Integration End ---
 
-	requestedModel, mappedModel, err := s.resolveUpstreamModel(mode, clientModel)
+	requestedModel, targets, err := s.resolveUpstreamTargets(r.Context(), mode, clientModel)
 	if err != nil {
 		statusCode = http.StatusBadRequest
 		errText = err.Error()
 		s.writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
 		return
 	}
+	mappedModel := targets[0]
+	if deg.Model != "" {
+		mappedModel = deg.Model
+		targets = nil
+	}
 	upstreamModel = mappedModel
 	req.Model = mappedModel
+	req.Metadata = applyModelFallbacks(req.Metadata, targets)
 	req.Metadata = s.applyChannelRoutePolicy(r.Context(), req.Metadata, mappedModel)
+	var routedAdapters []string
+	req.Metadata, routedAdapters, err = s.applyAdapterOverride(r, req.Metadata)
+	if err != nil {
+		errText = err.Error()
+		statusCode = s.writeAdapterOverrideError(w, err)
+		return
+	}
+	channelID = routedChannelID(req.Metadata)
 
 	action := policy.Action{
 		Path:      "/v1/messages",
 		Model:     req.Model,
 		Mode:      mode,
 		ToolNames: toolNames(req.Tools),
+		Adapters:  routedAdapters,
 	}
-	if err := s.policy.Authorize(r.Context(), action); err != nil {
+	if err := s.policy.Authorize(s.policyContext(r.Context()), action); err != nil {
 		statusCode = http.StatusForbidden
 		errText = err.Error()
 		s.writeError(w, http.StatusForbidden, "permission_error", err.Error())
 		return
 	}
+	var injectedTools []string
+	req.Tools, injectedTools = s.injectMCPTools(r.Context(), "/v1/messages", mode, req.Model, req.Metadata, req.Tools)
 
-	runID = s.nextID("run")
+	correlationID, err := requestCorrelationID(r)
+	if err != nil {
+		statusCode = http.StatusBadRequest
+		errText = err.Error()
+		s.writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	runID = s.newRunID(r)
+	r = r.WithContext(logging.WithRun(r.Context(), runID, sessionID))
+	if len(targets) > 1 {
+		r = r.WithContext(s.withModelTargetRecorder(r.Context(), runID, sessionID))
+	}
+	r = r.WithContext(s.withHedgeRecorder(r.Context(), runID, sessionID))
+	r = r.WithContext(s.withWireCapture(r, runID))
 	s.createRunIfConfigured(ccrun.CreateInput{
 		ID:             runID,
 		SessionID:      sessionID,
+		ProjectID:      projectIDFromContext(r.Context()),
+		UserID:         requestUserID(r.Context()),
+		CorrelationID:  correlationID,
 		Path:           "/v1/messages",
 		Mode:           mode,
 		ClientModel:    clientModel,
@@ -215,6 +216,7 @@ func (s *server) handleMessages(w http.ResponseWriter, r *http.Request) {
 		UpstreamModel:  mappedModel,
 		Stream:         streamMode,
 		ToolCount:      toolCount,
+		Metadata:       experimentRunMetadata(req.Metadata),
 	})
 	s.appendEvent(ccevent.AppendInput{
 		EventType: "run.created",
@@ -227,14 +229,30 @@ func (s *server) handleMessages(w http.ResponseWriter, r *http.Request) {
 			"requested_model": requestedModel,
 			"upstream_model":  mappedModel,
 			"stream":          streamMode,
+			"correlation_id":  correlationID,
 		},
 	})
+	if len(injectedTools) > 0 {
+		s.appendEvent(ccevent.AppendInput{
+			EventType: "tool.mcp_injected",
+			SessionID: sessionID,
+			RunID:     runID,
+			Data: map[string]any{
+				"tools": injectedTools,
+				"count": len(injectedTools),
+			},
+		})
+	}
 	w.Header().Set("request-id", runID)
 	w.Header().Set("x-cc-run-id", runID)
+	if correlationID != "" {
+		w.Header().Set("x-correlation-id", correlationID)
+	}
 	w.Header().Set("x-cc-mode", mode)
 	w.Header().Set("x-cc-client-model", clientModel)
 	w.Header().Set("x-cc-requested-model", requestedModel)
 	w.Header().Set("x-cc-upstream-model", mappedModel)
+	setCanaryHeaders(w, req.Metadata)
 
 	creq := toCanonicalRequest(runID, req, r)
 	if creq.Metadata == nil {
@@ -246,13 +264,31 @@ func (s *server) handleMessages(w http.ResponseWriter, r *http.Request) {
 	creq.Metadata["client_model"] = clientModel
 	creq.Metadata["requested_model"] = requestedModel
 	creq.Metadata["upstream_model"] = mappedModel
-	reservedQuota := estimateReservedQuota(req.MaxTokens, req.System, req.Messages)
+	creq.Metadata["admission_priority"] = s.admissionPriority(r, req.Metadata)
+	creq, err = s.applyRequestPlugins(r.Context(), creq)
+	if err != nil {
+		statusCode = http.StatusBadGateway
+		errText = err.Error()
+		s.writeError(w, http.StatusBadGateway, "api_error", err.Error())
+		return
+	}
+	guarded, err := s.applyInputGuardrails(r.Context(), w, creq)
+	if err != nil {
+		statusCode = http.StatusBadRequest
+		errText = err.Error()
+		s.writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	creq = guarded
+	promptText = s.guardedPromptText(promptText, creq)
+	reservedQuota := estimateReservedQuota(s.tokenizerFor(req.Model), req.MaxTokens, req.System, req.Messages, req.Tools)
 	if err := s.reserveQuotaFromRequestContext(r.Context(), reservedQuota); err != nil {
 		statusCode = http.StatusForbidden
 		errText = err.Error()
 		s.writeError(w, http.StatusForbidden, "quota_error", err.Error())
 		return
 	}
+	creq = s.applyContextCompaction(r.Context(), creq)
 	if req.Stream {
 		if _, ok := creq.Metadata["strict_stream_passthrough"]; !ok {
 			creq.Metadata["strict_stream_passthrough"] = true
@@ -261,13 +297,21 @@ func (s *server) handleMessages(w http.ResponseWriter, r *http.Request) {
 			creq.Metadata["strict_stream_passthrough_soft"] = true
 		}
 		creq = s.applyVisionFallback(r.Context(), creq)
+		creq = s.applyImagePreprocess(r.Context(), creq)
 		creq = s.applyToolSupportFallback(creq)
+		w, r, stopGuard := s.guardStream(w, r, anthropicPingFrame)
+		w, r, finishStream := s.resumableStream(w, r, runID, sessionID)
+		w, releaseOutput := s.holdStreamForGuardrails(w, creq, streamFormatAnthropic, requestedModel)
 		var usage orchestrator.Usage
 		if s.shouldStreamWithToolLoop(creq) {
 			generatedText, usage = s.streamMessagesWithToolLoop(w, r, creq, requestedModel)
 		} else {
 			generatedText, usage = s.streamMessages(w, r, creq, requestedModel)
 		}
+		generatedText = releaseOutput(r.Context(), generatedText)
+		finishStream()
+		stopGuard()
+		s.recordUsage(r.Context(), creq, usage, "", "", true)
 		if err := s.settleQuotaFromRequestContext(r.Context(), reservedQuota, usageToQuotaAmount(usage.InputTokens, usage.OutputTokens)); err != nil {
 			statusCode = http.StatusForbidden
 			errText = err.Error()
@@ -276,8 +320,17 @@ func (s *server) handleMessages(w http.ResponseWriter, r *http.Request) {
 	}
 
 	creq = s.applyVisionFallback(r.Context(), creq)
+	creq = s.applyImagePreprocess(r.Context(), creq)
 	creq = s.applyToolSupportFallback(creq)
 	resp, err := s.completeWithToolLoop(r.Context(), creq)
+	if err != nil {
+		_ = s.refundQuotaFromRequestContext(r.Context(), reservedQuota)
+		errText = err.Error()
+		statusCode = s.writeUpstreamError(w, err)
+		return
+	}
+	resp = s.applyResponseFormatRepair(sessionID, runID, "/v1/messages", creq, resp)
+	resp, err = s.applyResponsePlugins(r.Context(), creq, resp)
 	if err != nil {
 		_ = s.refundQuotaFromRequestContext(r.Context(), reservedQuota)
 		statusCode = http.StatusBadGateway
@@ -285,7 +338,10 @@ func (s *server) handleMessages(w http.ResponseWriter, r *http.Request) {
 		s.writeError(w, http.StatusBadGateway, "api_error", err.Error())
 		return
 	}
+	resp = s.applyOutputGuardrails(r.Context(), w, creq, resp)
 	generatedText = collectResponseText(resp)
+	s.recordRunAdapter(creq.RunID, resp.Trace.Provider)
+	s.recordUsage(r.Context(), creq, resp.Usage, resp.Trace.Model, resp.Trace.Provider, false)
 	if err := s.settleQuotaFromRequestContext(r.Context(), reservedQuota, usageToQuotaAmount(resp.Usage.InputTokens, resp.Usage.OutputTokens)); err != nil {
 		_ = s.refundQuotaFromRequestContext(r.Context(), reservedQuota)
 		statusCode = http.StatusForbidden
@@ -316,16 +372,29 @@ func (s *server) streamMessages(w http.ResponseWriter, r *http.Request, req orch
 	w.WriteHeader(http.StatusOK)
 
 	events, errs := s.orchestrator.Stream(r.Context(), req)
+	check := newStreamCheck()
 
 	for {
 		select {
 		case ev, ok := <-events:
 			if !ok {
+				if replay, ok := s.finishStream(r.Context(), req, check, nil); ok {
+					events, errs = replay, nil
+					continue
+				}
 				return generated.String(), usage
 			}
+			if ev.Type == "ping" {
+				if err := writeSSE(w, "ping", queuePingPayload(ev)); err != nil {
+					return generated.String(), usage
+				}
+				flusher.Flush()
+				continue
+			}
 			appendStreamText(&generated, ev)
-			if ev.Usage.InputTokens > 0 || ev.Usage.OutputTokens > 0 {
-				usage = ev.Usage
+			check.observe(ev)
+			if check.usage.InputTokens > 0 || check.usage.OutputTokens > 0 {
+				usage = check.usage
 			}
 			if ev.PassThrough && len(ev.RawData) > 0 {
 				raw := ev.RawData
@@ -351,13 +420,11 @@ func (s *server) streamMessages(w http.ResponseWriter, r *http.Request, req orch
 			if !ok || err == nil {
 				continue
 			}
-			_ = writeSSE(w, "error", map[string]any{
-				"type": "error",
-				"error": map[string]any{
-					"type":    "api_error",
-					"message": err.Error(),
-				},
-			})
+			if replay, ok := s.finishStream(r.Context(), req, check, err); ok {
+				events, errs = replay, nil
+				continue
+			}
+			_ = writeSSE(w, "error", upstreamErrorPayload(err))
 			flusher.Flush()
 			return generated.String(), usage
 		case <-r.Context().Done():
@@ -411,7 +478,13 @@ func (s *server) handleCountTokens(w http.ResponseWriter, r *http.Request) {
 	if err := s.enforceTokenModelAccess(r.Context(), req.Model); err != nil {
 		statusCode = http.StatusForbidden
 		errText = err.Error()
-		s.writeError(w, http.StatusForbidden, "permission_error", err.Error())
+		s.writeModelAccessError(w, err)
+		return
+	}
+	if err := s.resolveFileReferences(r.Context(), req.Messages); err != nil {
+		statusCode = http.StatusBadRequest
+		errText = err.Error()
+		s.writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
 		return
 	}
 	if len(req.Messages) == 0 {
@@ -422,7 +495,7 @@ func (s *server) handleCountTokens(w http.ResponseWriter, r *http.Request) {
 	}
 	mode := requestMode(r, nil)
 	clientModel := req.Model
-	requestedModel, mappedModel, err := s.resolveUpstreamModel(mode, clientModel)
+	requestedModel, mappedModel, err := s.resolveUpstreamModel(r.Context(), mode, clientModel)
 	if err != nil {
 		statusCode = http.StatusBadRequest
 		errText = err.Error()
@@ -435,13 +508,9 @@ func (s *server) handleCountTokens(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("x-cc-requested-model", requestedModel)
 	w.Header().Set("x-cc-upstream-model", mappedModel)
 
-	tokens := 0
-	for _, m := range req.Messages {
-		tokens += estimateContentTokens(m.Content)
-	}
-	if req.System != nil {
-		tokens += estimateContentTokens(req.System)
-	}
+	tok := s.tokenizerFor(mappedModel)
+	tokens := estimatePromptTokens(tok, req.System, req.Messages, req.Tools)
+	w.Header().Set("x-cc-tokenizer", tok.Name())
 
 	w.Header().Set("content-type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -462,11 +531,43 @@ func validateMessagesRequest(req MessagesRequest) error {
 		if strings.TrimSpace(t.Name) == "" {
 			return fmt.Errorf("tool name is required")
 		}
-		if t.InputSchema == nil {
+		if t.InputSchema == nil && !isServerToolDefinition(t) {
 			return fmt.Errorf("tool %q input_schema is required", t.Name)
 		}
 	}
-	return nil
+	return validateOutputFormat(req.OutputFormat)
+}
+
+// isServerToolDefinition reports whether t is an Anthropic server tool
+// declaration such as {"type":"web_search_20250305","name":"web_search"}.
+func isServerToolDefinition(t ToolDefinition) bool {
+	kind := strings.ToLower(strings.TrimSpace(t.Type))
+	return kind != "" && kind != "custom"
+}
+
+// serverToolInputSchema supplies the schema upstreams need to call a server
+// tool that the gateway executes itself.
+func serverToolInputSchema(name string) map[string]any {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "web_search":
+		return map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"query": map[string]any{"type": "string", "description": "The search query"},
+			},
+			"required": []any{"query"},
+		}
+	case "code_execution":
+		return map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"code":     map[string]any{"type": "string", "description": "The source code to run"},
+				"language": map[string]any{"type": "string", "enum": []any{"python", "javascript"}},
+			},
+			"required": []any{"code"},
+		}
+	}
+	return map[string]any{"type": "object"}
 }
 
 func toolNames(tools []ToolDefinition) []string {
@@ -487,10 +588,18 @@ func toCanonicalRequest(runID string, req MessagesRequest, r *http.Request) orch
 	}
 	tools := make([]orchestrator.Tool, 0, len(req.Tools))
 	for _, t := range req.Tools {
+		if kind := builtinToolKind(t); kind != "" {
+			tools = append(tools, builtinToolToCanonical(t, kind))
+			continue
+		}
+		schema := t.InputSchema
+		if schema == nil && isServerToolDefinition(t) {
+			schema = serverToolInputSchema(t.Name)
+		}
 		tools = append(tools, orchestrator.Tool{
 			Name:        t.Name,
 			Description: t.Description,
-			InputSchema: t.InputSchema,
+			InputSchema: schema,
 		})
 	}
 
@@ -514,19 +623,21 @@ func toCanonicalRequest(runID string, req MessagesRequest, r *http.Request) orch
 	if req.TopP != nil {
 		metadata["top_p"] = *req.TopP
 	}
+	maxTokens := applyModeParams(metadata, req.MaxTokens)
 	if len(metadata) == 0 {
 		metadata = nil
 	}
 
 	return orchestrator.Request{
-		RunID:     runID,
-		Model:     req.Model,
-		MaxTokens: req.MaxTokens,
-		System:    req.System,
-		Messages:  msgs,
-		Tools:     tools,
-		Metadata:  metadata,
-		Headers:   headers,
+		RunID:          runID,
+		Model:          req.Model,
+		MaxTokens:      maxTokens,
+		System:         req.System,
+		Messages:       msgs,
+		Tools:          tools,
+		ResponseFormat: toCanonicalResponseFormat(req.OutputFormat),
+		Metadata:       metadata,
+		Headers:        headers,
 	}
 }
 
@@ -555,6 +666,7 @@ func fromCanonicalResponse(messageID string, resp orchestrator.Response) Message
 			InputTokens:  resp.Usage.InputTokens,
 			OutputTokens: resp.Usage.OutputTokens,
 		},
+		Metadata: responseMetadata(resp),
 	}
 }
 
@@ -651,10 +763,37 @@ func rewriteAnthropicStreamModel(eventType string, raw []byte, outwardModel stri
 	return encoded, true
 }
 
-func estimateContentTokens(content any) int {
+// messageTokenOverhead is what chat formats add around each message (role
+// and separators) and once to prime the reply.
+const messageTokenOverhead = 3
+
+// estimatePromptTokens counts a request's prompt with tok: system, each
+// message with its framing and the tool definitions.
+func estimatePromptTokens(tok tokenizer.Tokenizer, system any, messages []MessageParam, tools []ToolDefinition) int {
+	total := messageTokenOverhead
+	if system != nil {
+		total += estimateContentTokens(tok, system)
+	}
+	for _, m := range messages {
+		total += messageTokenOverhead + estimateContentTokens(tok, m.Content)
+	}
+	for _, t := range tools {
+		total += tok.Count(t.Name) + tok.Count(t.Description)
+		if len(t.InputSchema) > 0 {
+			if raw, err := json.Marshal(t.InputSchema); err == nil {
+				total += tok.Count(string(raw))
+			}
+		}
+	}
+	return total
+}
+
+// estimateContentTokens counts text, tool calls and tool results; other
+// blocks (images, documents) cost a token each here.
+func estimateContentTokens(tok tokenizer.Tokenizer, content any) int {
 	switch c := content.(type) {
 	case string:
-		return tokenCount(c)
+		return tok.Count(c)
 	case []any:
 		total := 0
 		for _, item := range c {
@@ -662,24 +801,34 @@ func estimateContentTokens(content any) int {
 			if !ok {
 				continue
 			}
-			if text, ok := block["text"].(string); ok {
-				total += tokenCount(text)
+			switch block["type"] {
+			case "text":
+				text, _ := block["text"].(string)
+				total += tok.Count(text)
+			case "tool_use":
+				name, _ := block["name"].(string)
+				raw, _ := json.Marshal(block["input"])
+				total += tok.Count(name) + tok.Count(string(raw))
+			case "tool_result":
+				total += estimateContentTokens(tok, block["content"])
+			default:
+				if text, ok := block["text"].(string); ok {
+					total += tok.Count(text)
+				} else {
+					total++
+				}
 			}
 		}
 		return total
+	case map[string]any:
+		return estimateContentTokens(tok, []any{c})
+	case nil:
+		return 0
 	default:
 		return 1
 	}
 }
 
-func tokenCount(text string) int {
-	text = strings.TrimSpace(text)
-	if text == "" {
-		return 1
-	}
-	return len(strings.Fields(text))
-}
-
 func max(a, b int) int {
 	if a > b {
 		return a
@@ -687,54 +836,14 @@ func max(a, b int) int {
 	return b
 }
 
-func estimateReservedQuota(maxTokens int, system any, messages []MessageParam) int64 {
-	reserved := int64(max(maxTokens, 1))
-	for _, m := range messages {
-		reserved += int64(max(estimateContentTokens(m.Content), 0))
-	}
-	if system != nil {
-		reserved += int64(max(estimateContentTokens(system), 0))
-	}
+func estimateReservedQuota(tok tokenizer.Tokenizer, maxTokens int, system any, messages []MessageParam, tools []ToolDefinition) int64 {
+	reserved := int64(max(maxTokens, 1)) + int64(max(estimatePromptTokens(tok, system, messages, tools), 0))
 	if reserved <= 0 {
 		return 1
 	}
 	return reserved
 }
 
-func buildContextMessages(wm *memory.WorkingMemory, sm *memory.SessionMemory) []orchestrator.Message {
-	messages := []orchestrator.Message{}
-
-	// Add session summary as system message if it exists
-	if sm != nil && sm.Summary != "" {
-		messages = append(messages, orchestrator.Message{
-			Role: "system",
-			Content: []any{
-				map[string]any{
-					"type": "text",
-					"text": fmt.Sprintf("Conversation Summary:\n%s", sm.Summary),
-				},
-			},
-		})
-	}
-
-	// Add working memory messages
-	if wm != nil {
-		for _, msg := range wm.Messages {
-			messages = append(messages, orchestrator.Message{
-				Role: msg.Role,
-				Content: []any{
-					map[string]any{
-						"type": "text",
-						"text": msg.Content,
-					},
-				},
-			})
-		}
-	}
-
-	return messages
-}
-
 func contentToMemoryText(content any) string {
 	switch c := content.(type) {
 	case string:
@@ -760,3 +869,8 @@ func contentToMemoryText(content any) string {
 		return strings.TrimSpace(fmt.Sprint(content))
 	}
 }
+
+// tokenizerFor returns the tokenizer for an upstream model.
+func (s *server) tokenizerFor(model string) tokenizer.Tokenizer {
+	return s.tokenizers.ForModel(model)
+}
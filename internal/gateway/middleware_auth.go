@@ -2,10 +2,14 @@ package gateway
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"ccgateway/internal/token"
 )
@@ -50,9 +54,20 @@ func (s *server) withAuth(next http.HandlerFunc) http.HandlerFunc {
 					return
 				}
 				ctx := context.WithValue(r.Context(), tokenContextKey, tk)
+				ctx, err := s.bindTokenProject(ctx, r, tk)
+				if err != nil {
+					s.writeError(w, http.StatusForbidden, "permission_error", err.Error())
+					return
+				}
 				next(w, r.WithContext(ctx))
 				return
 			}
+			var windowErr *token.QuotaWindowError
+			if errors.As(err, &windowErr) {
+				setQuotaWindowRetryAfter(w, windowErr)
+				s.writeError(w, http.StatusForbidden, "quota_error", err.Error())
+				return
+			}
 		}
 
 		// 3. Unauthorized.
@@ -60,9 +75,13 @@ func (s *server) withAuth(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
-// withTokenQuota performs a pre-check to block obviously exhausted tokens.
+// withTokenQuota performs a pre-check to block obviously exhausted tokens
+// and admits the request against its project's quota.
 func (s *server) withTokenQuota(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.admitProjectRequest(w, r) {
+			return
+		}
 		tk, ok := r.Context().Value(tokenContextKey).(*token.Token)
 		if !ok || tk == nil {
 			// If no user token (e.g. Admin Token used), skip quota check.
@@ -112,6 +131,7 @@ func (s *server) settleQuotaFromRequestContext(ctx context.Context, reserved, ac
 	if actual <= 0 {
 		actual = 1
 	}
+	s.chargeProject(ctx, actual)
 	switch {
 	case reserved == 0:
 		return s.reserveQuotaFromRequestContext(ctx, actual)
@@ -124,6 +144,14 @@ func (s *server) settleQuotaFromRequestContext(ctx context.Context, reserved, ac
 	}
 }
 
+// setQuotaWindowRetryAfter sets retry-after to the time left until the spent
+// window resets.
+func setQuotaWindowRetryAfter(w http.ResponseWriter, err *token.QuotaWindowError) {
+	if wait := time.Until(err.ResetAt); wait > 0 {
+		w.Header().Set("retry-after", strconv.FormatInt(int64(wait.Seconds())+1, 10))
+	}
+}
+
 func usageToQuotaAmount(inputTokens, outputTokens int) int64 {
 	total := inputTokens + outputTokens
 	if total <= 0 {
@@ -144,7 +172,41 @@ func (s *server) enforceTokenModelAccess(ctx context.Context, model string) erro
 	if tk.CanUseModel(model) {
 		return nil
 	}
-	return fmt.Errorf("token is not allowed to access model %q", model)
+	return &modelAccessError{Model: model, Allowed: tk.AllowedModels()}
+}
+
+// modelAccessError reports a model outside the token's declared list and
+// carries that list so clients can self-correct.
+type modelAccessError struct {
+	Model   string
+	Allowed []string
+}
+
+func (e *modelAccessError) Error() string {
+	return fmt.Sprintf("token is not allowed to access model %q; allowed models: %s", e.Model, strings.Join(e.Allowed, ", "))
+}
+
+// writeModelAccessError writes a 403 permission_error; token model
+// restrictions include the allowed list in error.details.
+func (s *server) writeModelAccessError(w http.ResponseWriter, err error) {
+	var accessErr *modelAccessError
+	if !errors.As(err, &accessErr) {
+		s.writeError(w, http.StatusForbidden, "permission_error", err.Error())
+		return
+	}
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	_ = json.NewEncoder(w).Encode(ErrorEnvelope{
+		Type: "error",
+		Error: ErrorResponse{
+			Type:    "permission_error",
+			Message: accessErr.Error(),
+			Details: map[string]any{
+				"model":          accessErr.Model,
+				"allowed_models": accessErr.Allowed,
+			},
+		},
+	})
 }
 
 func bearerToken(authHeader string) string {
@@ -172,15 +234,15 @@ func enforceTokenIPAccess(tk *token.Token, r *http.Request) error {
 	return fmt.Errorf("token is not allowed from client ip %q", clientIP)
 }
 
+// requestClientIP is the client address resolved by withIPAccess, which
+// only honors forwarding headers from trusted proxies. Requests that did
+// not pass through it are attributed to their peer address.
 func requestClientIP(r *http.Request) string {
 	if r == nil {
 		return ""
 	}
-	if forwarded := firstHeaderValue(r.Header.Get("x-forwarded-for")); forwarded != "" {
-		return forwarded
-	}
-	if realIP := strings.TrimSpace(r.Header.Get("x-real-ip")); realIP != "" {
-		return realIP
+	if ip, ok := r.Context().Value(clientIPKey{}).(string); ok && ip != "" {
+		return ip
 	}
 	host, _, err := net.SplitHostPort(strings.TrimSpace(r.RemoteAddr))
 	if err == nil {
@@ -188,14 +250,3 @@ func requestClientIP(r *http.Request) string {
 	}
 	return strings.TrimSpace(r.RemoteAddr)
 }
-
-func firstHeaderValue(raw string) string {
-	raw = strings.TrimSpace(raw)
-	if raw == "" {
-		return ""
-	}
-	if idx := strings.IndexByte(raw, ','); idx >= 0 {
-		raw = raw[:idx]
-	}
-	return strings.TrimSpace(raw)
-}
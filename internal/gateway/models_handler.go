@@ -0,0 +1,234 @@
+package gateway
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"ccgateway/internal/token"
+	"ccgateway/internal/upstream"
+)
+
+type modelListEntry struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Created int64  `json:"created"`
+	OwnedBy string `json:"owned_by"`
+}
+
+// anthropicModelEntry is the Anthropic Models API shape.
+type anthropicModelEntry struct {
+	Type        string `json:"type"`
+	ID          string `json:"id"`
+	DisplayName string `json:"display_name"`
+	CreatedAt   string `json:"created_at"`
+}
+
+const (
+	anthropicModelsDefaultLimit = 20
+	anthropicModelsMaxLimit     = 1000
+)
+
+// handleModels lists the models the caller may use. Tokens with a Models
+// restriction see exactly their declared list. Requests carrying an
+// anthropic-version header get the Anthropic shape, others the OpenAI one.
+// GET /v1/models
+func (s *server) handleModels(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+		return
+	}
+	ids, restricted := s.visibleModels(r)
+	if wantsAnthropicModels(r) {
+		s.writeAnthropicModelList(w, r, ids)
+		return
+	}
+	data := make([]modelListEntry, 0, len(ids))
+	for _, id := range ids {
+		data = append(data, newModelListEntry(id))
+	}
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"object":     "list",
+		"data":       data,
+		"restricted": restricted,
+	})
+}
+
+// handleModelByPath reports whether the caller may use one model. Ids the
+// models list would not show are not found.
+// GET /v1/models/{id}
+func (s *server) handleModelByPath(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+		return
+	}
+	id := strings.TrimSpace(strings.TrimPrefix(r.URL.Path, "/v1/models/"))
+	if id == "" {
+		s.writeError(w, http.StatusNotFound, "not_found_error", "model id is required")
+		return
+	}
+	if err := s.enforceTokenModelAccess(r.Context(), id); err != nil {
+		s.writeModelAccessError(w, err)
+		return
+	}
+	ids, _ := s.visibleModels(r)
+	if i := sort.SearchStrings(ids, id); i == len(ids) || ids[i] != id {
+		s.writeError(w, http.StatusNotFound, "not_found_error", "model not found: "+id)
+		return
+	}
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if wantsAnthropicModels(r) {
+		_ = json.NewEncoder(w).Encode(newAnthropicModelEntry(id))
+		return
+	}
+	_ = json.NewEncoder(w).Encode(newModelListEntry(id))
+}
+
+// writeAnthropicModelList pages ids the way the Anthropic Models API does:
+// limit (default 20, max 1000) with after_id / before_id cursors.
+func (s *server) writeAnthropicModelList(w http.ResponseWriter, r *http.Request, ids []string) {
+	q := r.URL.Query()
+	limit := anthropicModelsDefaultLimit
+	if raw := strings.TrimSpace(q.Get("limit")); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > anthropicModelsMaxLimit {
+			s.writeError(w, http.StatusBadRequest, "invalid_request_error", "limit must be between 1 and 1000")
+			return
+		}
+		limit = n
+	}
+	afterID := strings.TrimSpace(q.Get("after_id"))
+	beforeID := strings.TrimSpace(q.Get("before_id"))
+	if afterID != "" && beforeID != "" {
+		s.writeError(w, http.StatusBadRequest, "invalid_request_error", "after_id and before_id are mutually exclusive")
+		return
+	}
+	start, end := 0, len(ids)
+	if afterID != "" {
+		start = sort.SearchStrings(ids, afterID)
+		if start < len(ids) && ids[start] == afterID {
+			start++
+		}
+	}
+	if beforeID != "" {
+		end = sort.SearchStrings(ids, beforeID)
+	}
+	page := ids[start:end]
+	hasMore := false
+	if len(page) > limit {
+		hasMore = true
+		if beforeID != "" {
+			page = page[len(page)-limit:]
+		} else {
+			page = page[:limit]
+		}
+	}
+	data := make([]anthropicModelEntry, 0, len(page))
+	for _, id := range page {
+		data = append(data, newAnthropicModelEntry(id))
+	}
+	var firstID, lastID any
+	if len(page) > 0 {
+		firstID, lastID = page[0], page[len(page)-1]
+	}
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"data":     data,
+		"has_more": hasMore,
+		"first_id": firstID,
+		"last_id":  lastID,
+	})
+}
+
+func wantsAnthropicModels(r *http.Request) bool {
+	return strings.TrimSpace(r.Header.Get("anthropic-version")) != ""
+}
+
+func (s *server) visibleModels(r *http.Request) ([]string, bool) {
+	if tk, ok := r.Context().Value(tokenContextKey).(*token.Token); ok && tk != nil {
+		if allowed := tk.AllowedModels(); len(allowed) > 0 {
+			return uniqueSortedModels(allowed), true
+		}
+	}
+	var ids []string
+	if s.settings != nil {
+		for name := range s.settings.Get().ModelMappings {
+			ids = append(ids, name)
+		}
+	}
+	ids = append(ids, s.adapterModelHints()...)
+	if s.channelStore != nil {
+		for _, group := range channelCandidateGroups(s.resolveUserGroup(r.Context())) {
+			ids = append(ids, s.channelStore.GetEnabledModels(group)...)
+		}
+	}
+	concrete := ids[:0]
+	for _, id := range ids {
+		if !strings.ContainsAny(id, "*?[") {
+			concrete = append(concrete, id)
+		}
+	}
+	return uniqueSortedModels(concrete), false
+}
+
+// adapterModelHints returns the client-facing model names the upstream
+// router knows about: model route keys and each adapter's pinned model.
+func (s *server) adapterModelHints() []string {
+	provider, ok := s.orchestrator.(interface {
+		GetUpstreamConfig() upstream.UpstreamAdminConfig
+	})
+	if !ok {
+		return nil
+	}
+	cfg := provider.GetUpstreamConfig()
+	out := make([]string, 0, len(cfg.ModelRoutes)+len(cfg.Adapters))
+	for name := range cfg.ModelRoutes {
+		out = append(out, name)
+	}
+	for _, spec := range cfg.Adapters {
+		out = append(out, spec.Model)
+	}
+	return out
+}
+
+func uniqueSortedModels(in []string) []string {
+	seen := map[string]struct{}{}
+	out := make([]string, 0, len(in))
+	for _, id := range in {
+		id = strings.TrimSpace(id)
+		if id == "" {
+			continue
+		}
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		out = append(out, id)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func newModelListEntry(id string) modelListEntry {
+	return modelListEntry{
+		ID:      id,
+		Object:  "model",
+		OwnedBy: "ccgateway",
+	}
+}
+
+func newAnthropicModelEntry(id string) anthropicModelEntry {
+	return anthropicModelEntry{
+		Type:        "model",
+		ID:          id,
+		DisplayName: id,
+		CreatedAt:   time.Unix(0, 0).UTC().Format(time.RFC3339),
+	}
+}
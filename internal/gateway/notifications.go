@@ -0,0 +1,260 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"ccgateway/internal/auditlog"
+	"ccgateway/internal/ccevent"
+	"ccgateway/internal/notification"
+)
+
+// Notification kinds raised by the gateway itself.
+const (
+	notifyAdminTokenDefault = "admin_token_default"
+	notifyAdapterUnhealthy  = "adapter_unhealthy"
+	notifyToolGapSpike      = "tool_gap_spike"
+	notifyPromptPromoted    = "prompt_variant_promoted"
+)
+
+const (
+	// adapterUnhealthyAfter is how many calls in a row an adapter has to
+	// fail before it is reported.
+	adapterUnhealthyAfter = 5
+	// toolGapSpikeCount gaps within toolGapSpikeWindow raise a spike.
+	toolGapSpikeCount  = 10
+	toolGapSpikeWindow = 5 * time.Minute
+)
+
+// notificationWatch holds the counters behind the derived notifications.
+type notificationWatch struct {
+	mu       sync.Mutex
+	failures map[string]int
+	lastErr  map[string]string
+	gaps     []time.Time
+}
+
+func newNotificationWatch() *notificationWatch {
+	return &notificationWatch{failures: map[string]int{}, lastErr: map[string]string{}}
+}
+
+// notifyDefaultAdminToken flags a gateway that still accepts the built-in
+// admin token.
+func (s *server) notifyDefaultAdminToken() {
+	if s.notifications == nil || s.adminToken != DefaultAdminToken {
+		return
+	}
+	s.notifications.Raise(notification.RaiseInput{
+		Kind:     notifyAdminTokenDefault,
+		Severity: notification.SeverityCritical,
+		Title:    "Default admin token in use",
+		Message:  "ADMIN_TOKEN is unset or uses the built-in default; anyone who knows it can change gateway configuration.",
+	})
+}
+
+// watchAdapterHealth tracks consecutive failures per adapter and raises
+// adapter_unhealthy once an adapter keeps failing; the first success
+// resolves it. Cancelled calls say nothing about the adapter.
+func (s *server) watchAdapterHealth(adapter string, err error) {
+	adapter = strings.TrimSpace(adapter)
+	if s.notifications == nil || adapter == "" {
+		return
+	}
+	if err != nil && (errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)) {
+		return
+	}
+	key := notifyAdapterUnhealthy + ":" + adapter
+	s.notifyWatch.mu.Lock()
+	if err == nil {
+		delete(s.notifyWatch.failures, adapter)
+		delete(s.notifyWatch.lastErr, adapter)
+		s.notifyWatch.mu.Unlock()
+		if s.notifications.IsOpen(key) {
+			if n, ok := s.notifications.Resolve(key); ok {
+				s.appendNotificationEvent("notification.resolved", n, "")
+			}
+		}
+		return
+	}
+	s.notifyWatch.failures[adapter]++
+	failures := s.notifyWatch.failures[adapter]
+	s.notifyWatch.lastErr[adapter] = err.Error()
+	s.notifyWatch.mu.Unlock()
+	if failures < adapterUnhealthyAfter {
+		return
+	}
+	s.notifications.Raise(notification.RaiseInput{
+		Key:      key,
+		Kind:     notifyAdapterUnhealthy,
+		Severity: notification.SeverityWarning,
+		Title:    "Adapter " + adapter + " is failing",
+		Message:  err.Error(),
+		Data: map[string]any{
+			"adapter":              adapter,
+			"consecutive_failures": failures,
+		},
+	})
+}
+
+// watchToolGaps raises tool_gap_spike when gaps cluster; single gaps stay
+// in /admin/tools/gaps only.
+func (s *server) watchToolGaps(toolName string) {
+	if s.notifications == nil {
+		return
+	}
+	now := time.Now()
+	s.notifyWatch.mu.Lock()
+	kept := s.notifyWatch.gaps[:0]
+	for _, at := range s.notifyWatch.gaps {
+		if now.Sub(at) < toolGapSpikeWindow {
+			kept = append(kept, at)
+		}
+	}
+	s.notifyWatch.gaps = append(kept, now)
+	count := len(s.notifyWatch.gaps)
+	s.notifyWatch.mu.Unlock()
+	if count < toolGapSpikeCount {
+		return
+	}
+	s.notifications.Raise(notification.RaiseInput{
+		Kind:     notifyToolGapSpike,
+		Severity: notification.SeverityWarning,
+		Title:    "Tool gaps are spiking",
+		Message:  "Clients are calling tools the gateway cannot serve; see /admin/tools/gaps.",
+		Data: map[string]any{
+			"gaps":           count,
+			"window_seconds": int(toolGapSpikeWindow / time.Second),
+			"last_tool":      strings.TrimSpace(toolName),
+		},
+	})
+}
+
+// handleAdminNotifications lists notifications with their badge counts.
+// GET /admin/notifications?status=&severity=&kind=&limit=
+func (s *server) handleAdminNotifications(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	if s.notifications == nil {
+		s.writeError(w, http.StatusNotImplemented, "api_error", "notifications are not configured")
+		return
+	}
+	if r.Method != http.MethodGet {
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+		return
+	}
+	limit, ok := parseNonNegativeInt(r.URL.Query().Get("limit"))
+	if !ok {
+		s.writeError(w, http.StatusBadRequest, "invalid_request_error", "limit must be an integer >= 0")
+		return
+	}
+	items, err := s.notifications.List(notification.ListFilter{
+		Status:   r.URL.Query().Get("status"),
+		Severity: r.URL.Query().Get("severity"),
+		Kind:     r.URL.Query().Get("kind"),
+		Limit:    limit,
+	})
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"data":   items,
+		"counts": s.notifications.Counts(),
+	})
+}
+
+// handleAdminNotificationByPath reads or updates one notification.
+// GET /admin/notifications/{id}, POST /admin/notifications/{id}/read|acknowledge,
+// POST /admin/notifications/read-all
+func (s *server) handleAdminNotificationByPath(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	if s.notifications == nil {
+		s.writeError(w, http.StatusNotImplemented, "api_error", "notifications are not configured")
+		return
+	}
+	id, action, _ := strings.Cut(strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/notifications/"), "/"), "/")
+	if id == "read-all" && action == "" {
+		if r.Method != http.MethodPost {
+			s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+			return
+		}
+		changed := s.notifications.ReadAll()
+		w.Header().Set("content-type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"updated": changed,
+			"counts":  s.notifications.Counts(),
+		})
+		return
+	}
+	if strings.TrimSpace(id) == "" {
+		s.writeError(w, http.StatusNotFound, "not_found_error", "notification not found")
+		return
+	}
+
+	var (
+		n   notification.Notification
+		err error
+	)
+	switch action {
+	case "":
+		if r.Method != http.MethodGet {
+			s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+			return
+		}
+		var found bool
+		if n, found = s.notifications.Get(id); !found {
+			err = notification.ErrNotFound
+		}
+	case "read":
+		if r.Method != http.MethodPost {
+			s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+			return
+		}
+		n, err = s.notifications.MarkRead(id)
+	case "acknowledge":
+		if r.Method != http.MethodPost {
+			s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+			return
+		}
+		actor := auditlog.ActorID(adminTokenFromRequest(r))
+		n, err = s.notifications.Acknowledge(id, actor)
+		if err == nil {
+			s.appendNotificationEvent("notification.acknowledged", n, actor)
+		}
+	default:
+		s.writeError(w, http.StatusNotFound, "not_found_error", "unknown notification action")
+		return
+	}
+	if err != nil {
+		s.writeError(w, http.StatusNotFound, "not_found_error", err.Error())
+		return
+	}
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(n)
+}
+
+func (s *server) appendNotificationEvent(eventType string, n notification.Notification, actor string) {
+	data := map[string]any{
+		"id":       n.ID,
+		"key":      n.Key,
+		"kind":     n.Kind,
+		"severity": n.Severity,
+		"count":    n.Count,
+	}
+	if actor != "" {
+		data["actor"] = actor
+	}
+	s.appendEvent(ccevent.AppendInput{EventType: eventType, Data: data})
+}
@@ -0,0 +1,29 @@
+package gateway
+
+import (
+	"net/http"
+
+	"ccgateway/internal/upstream"
+)
+
+type offlineReporter interface {
+	OfflineStatus() upstream.OfflineStatus
+}
+
+func (s *server) offlineStatus() upstream.OfflineStatus {
+	if reporter, ok := s.orchestrator.(offlineReporter); ok {
+		return reporter.OfflineStatus()
+	}
+	return upstream.OfflineStatus{}
+}
+
+// withOfflineHeader marks every response while upstream adapters are offline
+// stand-ins, so clients never mistake fixture output for a real provider.
+func (s *server) withOfflineHeader(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.offlineStatus().Enabled {
+			w.Header().Set("x-cc-offline", "true")
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,779 @@
+package gateway
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"ccgateway/internal/auth"
+	"ccgateway/internal/channel"
+	"ccgateway/internal/token"
+)
+
+// The /api/channel, /api/token and /api/user endpoints mirror one-api's
+// management REST shapes so dashboards and scripts written against one-api
+// keep working during a migration. Every answer is wrapped as
+// {"success":bool,"message":string,"data":...}; like one-api, failures
+// other than authentication are reported with HTTP 200 and success=false.
+// Access requires the admin token, sent either as a bearer token or raw in
+// the authorization header the way one-api access tokens are.
+
+const oneAPIPageSize = 10
+
+// one-api channel type numbers for the adapter types this gateway knows.
+var oneAPIChannelTypes = map[int]string{
+	1:  "openai",
+	3:  "azure",
+	8:  "custom",
+	14: "anthropic",
+	24: "gemini",
+	33: "aws",
+	41: "vertex",
+}
+
+// one-api role levels.
+const (
+	oneAPIRoleGuest  = 0
+	oneAPIRoleCommon = 1
+	oneAPIRoleAdmin  = 10
+	oneAPIRoleRoot   = 100
+)
+
+type oneAPIChannel struct {
+	ID           int64   `json:"id"`
+	Type         int     `json:"type"`
+	Key          string  `json:"key,omitempty"`
+	Status       int     `json:"status"`
+	Name         string  `json:"name"`
+	Weight       *uint   `json:"weight"`
+	CreatedTime  int64   `json:"created_time"`
+	TestTime     int64   `json:"test_time"`
+	ResponseTime int     `json:"response_time"`
+	BaseURL      *string `json:"base_url"`
+	Balance      float64 `json:"balance"`
+	Models       string  `json:"models"`
+	Group        string  `json:"group"`
+	UsedQuota    int64   `json:"used_quota"`
+	ModelMapping *string `json:"model_mapping"`
+	Priority     *int64  `json:"priority"`
+	Config       string  `json:"config"`
+}
+
+type oneAPIToken struct {
+	ID             int64   `json:"id"`
+	UserID         string  `json:"user_id"`
+	Key            string  `json:"key"`
+	Status         int     `json:"status"`
+	Name           string  `json:"name"`
+	CreatedTime    int64   `json:"created_time"`
+	AccessedTime   int64   `json:"accessed_time"`
+	ExpiredTime    int64   `json:"expired_time"`
+	RemainQuota    int64   `json:"remain_quota"`
+	UnlimitedQuota bool    `json:"unlimited_quota"`
+	UsedQuota      int64   `json:"used_quota"`
+	Models         *string `json:"models"`
+	Subnet         *string `json:"subnet"`
+}
+
+type oneAPIUser struct {
+	ID           string `json:"id"`
+	Username     string `json:"username"`
+	Password     string `json:"password,omitempty"`
+	DisplayName  string `json:"display_name"`
+	Role         int    `json:"role"`
+	Status       int    `json:"status"`
+	Email        string `json:"email"`
+	GitHubID     string `json:"github_id"`
+	WeChatID     string `json:"wechat_id"`
+	LarkID       string `json:"lark_id"`
+	Quota        int64  `json:"quota"`
+	UsedQuota    int64  `json:"used_quota"`
+	RequestCount int    `json:"request_count"`
+	Group        string `json:"group"`
+}
+
+func (s *server) writeOneAPI(w http.ResponseWriter, data any) {
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	out := map[string]any{"success": true, "message": ""}
+	if data != nil {
+		out["data"] = data
+	}
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+func (s *server) writeOneAPIError(w http.ResponseWriter, message string) {
+	s.writeOneAPIStatus(w, http.StatusOK, message)
+}
+
+// writeOneAPIStatus writes a one-api failure envelope under an HTTP error
+// status, for failures one-api clients expect to see in the status line.
+func (s *server) writeOneAPIStatus(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]any{"success": false, "message": message})
+}
+
+// authorizeOneAPI accepts the admin token as "Bearer <token>", as a raw
+// authorization value or via x-admin-token.
+func (s *server) authorizeOneAPI(w http.ResponseWriter, r *http.Request) bool {
+	if s.adminToken == "" {
+		return true
+	}
+	if oneAPIAdminToken(r) != s.adminToken {
+		s.writeOneAPIStatus(w, http.StatusUnauthorized, "admin token is invalid")
+		return false
+	}
+	return true
+}
+
+// oneAPIAdminToken is the admin token a one-api request carries.
+func oneAPIAdminToken(r *http.Request) string {
+	if got := adminTokenFromRequest(r); got != "" {
+		return got
+	}
+	return strings.TrimSpace(r.Header.Get("authorization"))
+}
+
+// oneAPIPath splits /api/<resource>/<rest> into the remaining segments.
+func oneAPIPath(r *http.Request, resource string) []string {
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/"+resource), "/")
+	if rest == "" {
+		return nil
+	}
+	return strings.Split(rest, "/")
+}
+
+// oneAPIPage applies one-api's p (0-based page) and page_size parameters.
+func oneAPIPage(r *http.Request, total int) (int, int) {
+	page, _ := strconv.Atoi(r.URL.Query().Get("p"))
+	size, _ := strconv.Atoi(r.URL.Query().Get("page_size"))
+	if page < 0 {
+		page = 0
+	}
+	if size <= 0 {
+		size = oneAPIPageSize
+	}
+	start := min(page*size, total)
+	return start, min(start+size, total)
+}
+
+// handleOneAPIChannel serves
+// GET/POST/PUT /api/channel/, GET /api/channel/search?keyword=,
+// GET/DELETE /api/channel/{id} and GET /api/channel/test/{id}.
+func (s *server) handleOneAPIChannel(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeOneAPI(w, r) {
+		return
+	}
+	if s.channelStore == nil {
+		s.writeOneAPIError(w, "channel store not configured")
+		return
+	}
+	parts := oneAPIPath(r, "channel")
+	switch {
+	case len(parts) == 0 && r.Method == http.MethodGet:
+		channels := s.channelStore.ListChannels()
+		sort.Slice(channels, func(i, j int) bool { return channels[i].ID < channels[j].ID })
+		start, end := oneAPIPage(r, len(channels))
+		out := make([]oneAPIChannel, 0, end-start)
+		for _, ch := range channels[start:end] {
+			out = append(out, toOneAPIChannel(ch))
+		}
+		s.writeOneAPI(w, out)
+	case len(parts) == 0 && (r.Method == http.MethodPost || r.Method == http.MethodPut):
+		s.saveOneAPIChannel(w, r)
+	case len(parts) == 1 && parts[0] == "search" && r.Method == http.MethodGet:
+		keyword := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("keyword")))
+		channels := s.channelStore.ListChannels()
+		sort.Slice(channels, func(i, j int) bool { return channels[i].ID < channels[j].ID })
+		out := []oneAPIChannel{}
+		for _, ch := range channels {
+			if keyword == "" || strings.Contains(strings.ToLower(ch.Name), keyword) || strconv.FormatInt(ch.ID, 10) == keyword {
+				out = append(out, toOneAPIChannel(ch))
+			}
+		}
+		s.writeOneAPI(w, out)
+	case len(parts) == 2 && parts[0] == "test" && r.Method == http.MethodGet:
+		ch, ok := s.oneAPIChannelByID(w, parts[1])
+		if !ok {
+			return
+		}
+		probe, err := s.probeChannel(ch)
+		if err != nil {
+			s.writeOneAPIError(w, err.Error())
+			return
+		}
+		w.Header().Set("content-type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"success": probe.Status == "ok" || probe.Status == "skipped",
+			"message": probe.Message,
+			"time":    probe.Latency.Seconds(),
+		})
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		ch, ok := s.oneAPIChannelByID(w, parts[0])
+		if !ok {
+			return
+		}
+		s.writeOneAPI(w, toOneAPIChannel(ch))
+	case len(parts) == 1 && r.Method == http.MethodDelete:
+		id, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil {
+			s.writeOneAPIError(w, "invalid channel id")
+			return
+		}
+		if trash, ok := s.channelStore.(interface{ SoftDeleteChannel(id int64) error }); ok {
+			err = trash.SoftDeleteChannel(id)
+		} else {
+			err = s.channelStore.DeleteChannel(id)
+		}
+		if err != nil {
+			s.writeOneAPIError(w, err.Error())
+			return
+		}
+		s.channelHealth.Forget(id)
+		s.recordSoftDelete(r, "channel", parts[0])
+		s.writeOneAPI(w, nil)
+	default:
+		s.writeOneAPIStatus(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (s *server) oneAPIChannelByID(w http.ResponseWriter, raw string) (*channel.Channel, bool) {
+	id, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		s.writeOneAPIError(w, "invalid channel id")
+		return nil, false
+	}
+	ch, ok := s.channelStore.GetChannel(id)
+	if !ok {
+		s.writeOneAPIError(w, channel.ErrChannelNotFound.Error())
+		return nil, false
+	}
+	return ch, true
+}
+
+// saveOneAPIChannel creates (POST) or updates (PUT, id in the body) a
+// channel. Zero and empty fields keep their current value on update, as in
+// one-api; a multi-line key on create adds one channel per line.
+func (s *server) saveOneAPIChannel(w http.ResponseWriter, r *http.Request) {
+	var req oneAPIChannel
+	if err := decodeJSONBodySingle(r, &req, false); err != nil {
+		s.reportRequestDecodeIssue(r, err)
+		s.writeOneAPIError(w, "invalid json")
+		return
+	}
+	if r.Method == http.MethodPut {
+		existing, ok := s.channelStore.GetChannel(req.ID)
+		if !ok {
+			s.writeOneAPIError(w, channel.ErrChannelNotFound.Error())
+			return
+		}
+		updated := *existing
+		applyOneAPIChannel(&updated, req)
+		if err := s.channelStore.UpdateChannel(&updated); err != nil {
+			s.writeOneAPIError(w, err.Error())
+			return
+		}
+		if saved, ok := s.channelStore.GetChannel(updated.ID); ok {
+			updated = *saved
+		}
+		s.writeOneAPI(w, toOneAPIChannel(&updated))
+		return
+	}
+
+	if strings.TrimSpace(req.Name) == "" {
+		s.writeOneAPIError(w, "name is required")
+		return
+	}
+	keys := []string{""}
+	if lines := splitLines(req.Key); len(lines) > 0 {
+		keys = lines
+	}
+	for _, key := range keys {
+		ch := &channel.Channel{Type: "openai", Status: channel.StatusEnabled, Group: defaultChannelGroup}
+		req.Key = key
+		applyOneAPIChannel(ch, req)
+		if err := s.channelStore.AddChannel(ch); err != nil {
+			s.writeOneAPIError(w, err.Error())
+			return
+		}
+	}
+	s.writeOneAPI(w, nil)
+}
+
+func applyOneAPIChannel(ch *channel.Channel, req oneAPIChannel) {
+	if strings.TrimSpace(req.Name) != "" {
+		ch.Name = strings.TrimSpace(req.Name)
+	}
+	if req.Type != 0 {
+		ch.Type = channelTypeFromOneAPI(req.Type)
+	}
+	if strings.TrimSpace(req.Key) != "" {
+		ch.Key = strings.TrimSpace(req.Key)
+	}
+	if req.Status != 0 {
+		ch.Status = req.Status
+	}
+	if req.BaseURL != nil {
+		ch.BaseURL = req.BaseURL
+	}
+	if req.Models != "" {
+		ch.Models = req.Models
+	}
+	if req.Group != "" {
+		ch.Group = req.Group
+	}
+	if req.Weight != nil {
+		ch.Weight = *req.Weight
+	}
+	if req.Priority != nil {
+		ch.Priority = *req.Priority
+	}
+	if req.ModelMapping != nil {
+		ch.ModelMapping = req.ModelMapping
+	}
+	if req.Config != "" {
+		ch.Config = req.Config
+	}
+}
+
+func toOneAPIChannel(ch *channel.Channel) oneAPIChannel {
+	weight, priority := ch.Weight, ch.Priority
+	return oneAPIChannel{
+		ID:           ch.ID,
+		Type:         channelTypeToOneAPI(ch.Type),
+		Status:       ch.Status,
+		Name:         ch.Name,
+		Weight:       &weight,
+		CreatedTime:  ch.CreatedAt.Unix(),
+		TestTime:     ch.TestTime,
+		ResponseTime: ch.ResponseTime,
+		BaseURL:      ch.BaseURL,
+		Balance:      ch.Balance,
+		Models:       ch.Models,
+		Group:        ch.Group,
+		UsedQuota:    ch.UsedQuota,
+		ModelMapping: ch.ModelMapping,
+		Priority:     &priority,
+		Config:       ch.Config,
+	}
+}
+
+func channelTypeFromOneAPI(n int) string {
+	if name, ok := oneAPIChannelTypes[n]; ok {
+		return name
+	}
+	return "custom"
+}
+
+func channelTypeToOneAPI(name string) int {
+	for n, known := range oneAPIChannelTypes {
+		if strings.EqualFold(known, strings.TrimSpace(name)) {
+			return n
+		}
+	}
+	return 8
+}
+
+func splitLines(s string) []string {
+	var out []string
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			out = append(out, line)
+		}
+	}
+	return out
+}
+
+// handleOneAPIToken serves
+// GET/POST/PUT /api/token/, GET /api/token/search?keyword= and
+// GET/DELETE /api/token/{id}. Listing covers every user's tokens unless
+// user_id is given; creating a token needs user_id since the admin token
+// has no user of its own.
+func (s *server) handleOneAPIToken(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeOneAPI(w, r) {
+		return
+	}
+	if s.tokenService == nil {
+		s.writeOneAPIError(w, "token service not configured")
+		return
+	}
+	parts := oneAPIPath(r, "token")
+	switch {
+	case len(parts) == 0 && r.Method == http.MethodGet:
+		tokens := s.oneAPITokens(strings.TrimSpace(r.URL.Query().Get("user_id")))
+		start, end := oneAPIPage(r, len(tokens))
+		out := make([]oneAPIToken, 0, end-start)
+		for _, tk := range tokens[start:end] {
+			out = append(out, toOneAPIToken(tk))
+		}
+		s.writeOneAPI(w, out)
+	case len(parts) == 1 && parts[0] == "search" && r.Method == http.MethodGet:
+		keyword := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("keyword")))
+		out := []oneAPIToken{}
+		for _, tk := range s.oneAPITokens(strings.TrimSpace(r.URL.Query().Get("user_id"))) {
+			if keyword == "" || strings.Contains(strings.ToLower(tk.Name), keyword) {
+				out = append(out, toOneAPIToken(tk))
+			}
+		}
+		s.writeOneAPI(w, out)
+	case len(parts) == 0 && r.Method == http.MethodPost:
+		s.createOneAPIToken(w, r)
+	case len(parts) == 0 && r.Method == http.MethodPut:
+		s.updateOneAPIToken(w, r)
+	case len(parts) == 1 && (r.Method == http.MethodGet || r.Method == http.MethodDelete):
+		tk, err := s.oneAPITokenByID(parts[0])
+		if err != nil {
+			s.writeOneAPIError(w, err.Error())
+			return
+		}
+		if r.Method == http.MethodGet {
+			s.writeOneAPI(w, toOneAPIToken(tk))
+			return
+		}
+		if trash, ok := s.tokenService.(interface{ SoftDeleteByID(id int64) error }); ok {
+			err = trash.SoftDeleteByID(tk.ID)
+		} else {
+			err = s.tokenService.Delete(tk.Value)
+		}
+		if err != nil {
+			s.writeOneAPIError(w, err.Error())
+			return
+		}
+		s.recordSoftDelete(r, "token", strconv.FormatInt(tk.ID, 10))
+		s.writeOneAPI(w, nil)
+	default:
+		s.writeOneAPIStatus(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// oneAPITokens lists the tokens of userID, or of every known user, by id.
+func (s *server) oneAPITokens(userID string) []*token.Token {
+	var out []*token.Token
+	if userID != "" {
+		out = s.tokenService.List(userID)
+	} else if s.authService != nil {
+		for _, u := range s.authService.List() {
+			out = append(out, s.tokenService.List(u.ID)...)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+func (s *server) oneAPITokenByID(raw string) (*token.Token, error) {
+	id, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return nil, errors.New("invalid token id")
+	}
+	for _, tk := range s.oneAPITokens("") {
+		if tk.ID == id {
+			return tk, nil
+		}
+	}
+	return nil, token.ErrInvalidToken
+}
+
+type oneAPITokenRequest struct {
+	ID             int64   `json:"id"`
+	UserID         string  `json:"user_id"`
+	Name           *string `json:"name"`
+	Status         int     `json:"status"`
+	ExpiredTime    *int64  `json:"expired_time"`
+	RemainQuota    *int64  `json:"remain_quota"`
+	UnlimitedQuota *bool   `json:"unlimited_quota"`
+	Models         *string `json:"models"`
+	Subnet         *string `json:"subnet"`
+}
+
+func (s *server) createOneAPIToken(w http.ResponseWriter, r *http.Request) {
+	var req oneAPITokenRequest
+	if err := decodeJSONBodySingle(r, &req, false); err != nil {
+		s.reportRequestDecodeIssue(r, err)
+		s.writeOneAPIError(w, "invalid json")
+		return
+	}
+	userID := strings.TrimSpace(req.UserID)
+	if userID == "" {
+		s.writeOneAPIError(w, "user_id is required")
+		return
+	}
+	if s.authService != nil {
+		if _, err := s.authService.Get(userID); err != nil {
+			s.writeOneAPIError(w, err.Error())
+			return
+		}
+	}
+	quota := int64(0)
+	if req.RemainQuota != nil && (req.UnlimitedQuota == nil || !*req.UnlimitedQuota) {
+		quota = *req.RemainQuota
+	}
+	tk, err := s.tokenService.Generate(userID, quota)
+	if err != nil {
+		s.writeOneAPIError(w, err.Error())
+		return
+	}
+	// Work on a copy: Update diffs quota windows against the stored token.
+	created := *tk
+	tk = &created
+	applyOneAPIToken(tk, req)
+	if err := s.tokenService.Update(tk); err != nil {
+		s.writeOneAPIError(w, err.Error())
+		return
+	}
+	s.writeOneAPI(w, toOneAPIToken(tk))
+}
+
+// updateOneAPIToken applies a PUT with the id in the body. status_only=1
+// changes nothing but the status, as one-api's enable/disable buttons do.
+func (s *server) updateOneAPIToken(w http.ResponseWriter, r *http.Request) {
+	var req oneAPITokenRequest
+	if err := decodeJSONBodySingle(r, &req, false); err != nil {
+		s.reportRequestDecodeIssue(r, err)
+		s.writeOneAPIError(w, "invalid json")
+		return
+	}
+	stored, err := s.oneAPITokenByID(strconv.FormatInt(req.ID, 10))
+	if err != nil {
+		s.writeOneAPIError(w, err.Error())
+		return
+	}
+	current := *stored
+	tk := &current
+	if parseQueryBool(r.URL.Query().Get("status_only")) {
+		tk.Status = normalizeTokenStatusInput(req.Status)
+	} else {
+		applyOneAPIToken(tk, req)
+	}
+	if err := s.tokenService.Update(tk); err != nil {
+		s.writeOneAPIError(w, err.Error())
+		return
+	}
+	s.writeOneAPI(w, toOneAPIToken(tk))
+}
+
+func applyOneAPIToken(tk *token.Token, req oneAPITokenRequest) {
+	if req.Name != nil {
+		tk.Name = *req.Name
+	}
+	if req.Status != 0 {
+		tk.Status = normalizeTokenStatusInput(req.Status)
+	}
+	if req.ExpiredTime != nil {
+		tk.ExpiredAt = *req.ExpiredTime
+	}
+	if req.RemainQuota != nil {
+		tk.Quota = *req.RemainQuota
+	}
+	if req.UnlimitedQuota != nil {
+		tk.UnlimitedQuota = *req.UnlimitedQuota
+	}
+	if req.Models != nil {
+		tk.Models = req.Models
+	}
+	if req.Subnet != nil {
+		tk.Subnet = req.Subnet
+	}
+}
+
+// toOneAPIToken drops the "sk-" prefix from the key because one-api
+// clients add it back when displaying or copying.
+func toOneAPIToken(tk *token.Token) oneAPIToken {
+	out := oneAPIToken{
+		ID:             tk.ID,
+		UserID:         tk.UserID,
+		Key:            strings.TrimPrefix(tk.Value, "sk-"),
+		Status:         tk.Status,
+		Name:           tk.Name,
+		CreatedTime:    tk.CreatedAt.Unix(),
+		ExpiredTime:    tk.ExpiredAt,
+		RemainQuota:    tk.Quota,
+		UnlimitedQuota: tk.UnlimitedQuota,
+		UsedQuota:      tk.Used,
+		Models:         tk.Models,
+		Subnet:         tk.Subnet,
+	}
+	if !tk.AccessedAt.IsZero() {
+		out.AccessedTime = tk.AccessedAt.Unix()
+	}
+	return out
+}
+
+// handleOneAPIUser serves
+// GET/POST/PUT /api/user/, GET /api/user/search?keyword= and
+// GET/DELETE /api/user/{id}.
+func (s *server) handleOneAPIUser(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeOneAPI(w, r) {
+		return
+	}
+	if s.authService == nil {
+		s.writeOneAPIError(w, "auth service not configured")
+		return
+	}
+	parts := oneAPIPath(r, "user")
+	switch {
+	case len(parts) == 0 && r.Method == http.MethodGet:
+		users := s.authService.List()
+		sortUsersForAdmin(users)
+		start, end := oneAPIPage(r, len(users))
+		out := make([]oneAPIUser, 0, end-start)
+		for _, u := range users[start:end] {
+			out = append(out, toOneAPIUser(u))
+		}
+		s.writeOneAPI(w, out)
+	case len(parts) == 1 && parts[0] == "search" && r.Method == http.MethodGet:
+		keyword := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("keyword")))
+		users := s.authService.List()
+		sortUsersForAdmin(users)
+		out := []oneAPIUser{}
+		for _, u := range users {
+			if keyword == "" || u.ID == keyword ||
+				strings.Contains(strings.ToLower(u.Username), keyword) ||
+				strings.Contains(strings.ToLower(u.Email), keyword) ||
+				strings.Contains(strings.ToLower(u.DisplayName), keyword) {
+				out = append(out, toOneAPIUser(u))
+			}
+		}
+		s.writeOneAPI(w, out)
+	case len(parts) == 0 && (r.Method == http.MethodPost || r.Method == http.MethodPut):
+		s.saveOneAPIUser(w, r)
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		u, err := s.authService.Get(parts[0])
+		if err != nil {
+			s.writeOneAPIError(w, err.Error())
+			return
+		}
+		s.writeOneAPI(w, toOneAPIUser(u))
+	case len(parts) == 1 && r.Method == http.MethodDelete:
+		var err error
+		if trash, ok := s.authService.(interface{ SoftDelete(id string) error }); ok {
+			err = trash.SoftDelete(parts[0])
+		} else {
+			err = s.authService.Delete(parts[0])
+		}
+		if err != nil {
+			s.writeOneAPIError(w, err.Error())
+			return
+		}
+		s.recordSoftDelete(r, "user", parts[0])
+		s.writeOneAPI(w, nil)
+	default:
+		s.writeOneAPIStatus(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// saveOneAPIUser creates (POST) or updates (PUT, id in the body) a user.
+// A quota in an update replaces the total quota as one-api does.
+func (s *server) saveOneAPIUser(w http.ResponseWriter, r *http.Request) {
+	var req oneAPIUser
+	if err := decodeJSONBodySingle(r, &req, false); err != nil {
+		s.reportRequestDecodeIssue(r, err)
+		s.writeOneAPIError(w, "invalid json")
+		return
+	}
+	var user *auth.User
+	var err error
+	if r.Method == http.MethodPost {
+		role := roleFromOneAPI(req.Role)
+		if req.Email != "" {
+			user, err = s.authService.RegisterWithEmail(req.Username, req.Email, req.Password, role)
+		} else {
+			user, err = s.authService.Register(req.Username, req.Password, role)
+		}
+	} else {
+		user, err = s.authService.Get(req.ID)
+		if err == nil && req.Username != "" {
+			user.Username = req.Username
+		}
+		if err == nil && req.Role != 0 {
+			user.Role = roleFromOneAPI(req.Role)
+		}
+		if err == nil && req.Quota != 0 {
+			if req.Quota < user.UsedQuota {
+				err = errors.New("quota cannot be lower than used_quota")
+			} else {
+				user.Quota = req.Quota
+			}
+		}
+		if err == nil && req.Status != 0 {
+			user.Status = normalizeUserStatusInput(req.Status)
+		}
+		if err == nil && req.Email != "" {
+			user.Email = req.Email
+		}
+	}
+	if err != nil {
+		s.writeOneAPIError(w, err.Error())
+		return
+	}
+	if req.DisplayName != "" {
+		user.DisplayName = req.DisplayName
+	}
+	if req.Group != "" {
+		user.Group = req.Group
+	}
+	if err := s.authService.Update(user); err != nil {
+		if r.Method == http.MethodPost {
+			s.cleanupCreatedUserOnAdminCreate(user)
+		}
+		s.writeOneAPIError(w, err.Error())
+		return
+	}
+	if r.Method == http.MethodPost && req.Quota > 0 {
+		if err := s.authService.AddQuota(user.ID, req.Quota); err != nil {
+			s.cleanupCreatedUserOnAdminCreate(user)
+			s.writeOneAPIError(w, err.Error())
+			return
+		}
+		if updated, err := s.authService.Get(user.ID); err == nil {
+			user = updated
+		}
+	}
+	s.writeOneAPI(w, toOneAPIUser(user))
+}
+
+func toOneAPIUser(u *auth.User) oneAPIUser {
+	return oneAPIUser{
+		ID:           u.ID,
+		Username:     u.Username,
+		DisplayName:  u.DisplayName,
+		Role:         roleToOneAPI(u.Role),
+		Status:       u.Status,
+		Email:        u.Email,
+		GitHubID:     u.GitHubID,
+		WeChatID:     u.WeChatID,
+		LarkID:       u.LarkID,
+		Quota:        u.Quota,
+		UsedQuota:    u.UsedQuota,
+		RequestCount: u.RequestCount,
+		Group:        u.Group,
+	}
+}
+
+func roleToOneAPI(role string) int {
+	switch role {
+	case auth.RoleRoot:
+		return oneAPIRoleRoot
+	case auth.RoleAdmin:
+		return oneAPIRoleAdmin
+	case auth.RoleGuest:
+		return oneAPIRoleGuest
+	default:
+		return oneAPIRoleCommon
+	}
+}
+
+func roleFromOneAPI(level int) string {
+	switch {
+	case level >= oneAPIRoleRoot:
+		return auth.RoleRoot
+	case level >= oneAPIRoleAdmin:
+		return auth.RoleAdmin
+	default:
+		return auth.RoleUser
+	}
+}
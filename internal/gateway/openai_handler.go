@@ -9,6 +9,7 @@ import (
 
 	"ccgateway/internal/ccevent"
 	"ccgateway/internal/ccrun"
+	"ccgateway/internal/logging"
 	"ccgateway/internal/orchestrator"
 	"ccgateway/internal/policy"
 	"ccgateway/internal/runlog"
@@ -27,6 +28,10 @@ func (s *server) handleOpenAIChatCompletions(w http.ResponseWriter, r *http.Requ
 	toolCount := 0
 	sessionID := ""
 	generatedText := ""
+	promptText := ""
+	memoryOn := false
+	channelID := int64(0)
+	var sampleMetadata map[string]any
 	defer func() {
 		recordText := buildRunRecordText("/v1/chat/completions", mode, statusCode, streamMode, generatedText, errText)
 		s.logRun(runlog.Entry{
@@ -43,8 +48,9 @@ func (s *server) handleOpenAIChatCompletions(w http.ResponseWriter, r *http.Requ
 			RecordText:     recordText,
 			DurationMS:     time.Since(started).Milliseconds(),
 		})
+		s.recordChannelOutcome(channelID, statusCode, errText, time.Since(started))
 		if runID != "" {
-			s.completeRunIfConfigured(runID, statusCode, errText)
+			s.completeRunIfConfigured(runID, statusCode, errText, promptText, generatedText, recordText)
 		}
 		if runID != "" {
 			eventType := "run.completed"
@@ -66,6 +72,10 @@ func (s *server) handleOpenAIChatCompletions(w http.ResponseWriter, r *http.Requ
 				},
 			})
 		}
+		s.offerTrafficSample(runID, sessionID, "/v1/chat/completions", mode, upstreamModel, statusCode, streamMode, promptText, generatedText, sampleMetadata)
+		if memoryOn && statusCode < 400 {
+			s.rememberSessionExchange(sessionID, runID, promptText, generatedText)
+		}
 	}()
 
 	if r.Method != http.MethodPost {
@@ -83,6 +93,8 @@ func (s *server) handleOpenAIChatCompletions(w http.ResponseWriter, r *http.Requ
 		s.writeError(w, http.StatusBadRequest, "invalid_request_error", "invalid JSON body")
 		return
 	}
+	prefs := s.requestUserPreferences(r.Context())
+	applyPreferredDefaults(prefs, &req.Model, &req.Temperature)
 	msgReq, err := openAIChatToMessagesRequest(req)
 	if err != nil {
 		statusCode = http.StatusBadRequest
@@ -93,46 +105,102 @@ func (s *server) handleOpenAIChatCompletions(w http.ResponseWriter, r *http.Requ
 	if err := s.enforceTokenModelAccess(r.Context(), msgReq.Model); err != nil {
 		statusCode = http.StatusForbidden
 		errText = err.Error()
-		s.writeError(w, http.StatusForbidden, "permission_error", err.Error())
+		s.writeModelAccessError(w, err)
+		return
+	}
+	if err := s.resolveFileReferences(r.Context(), msgReq.Messages); err != nil {
+		statusCode = http.StatusBadRequest
+		errText = err.Error()
+		s.writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
 		return
 	}
 
-	mode = requestMode(r, msgReq.Metadata)
+	mode = requestModeOr(r, msgReq.Metadata, prefs.Mode)
 	clientModel = msgReq.Model
 	streamMode = msgReq.Stream
 	toolCount = len(msgReq.Tools)
 	sessionID = requestSessionID(r, msgReq.Metadata)
-	msgReq.System = s.applySystemPromptPrefix(mode, msgReq.System)
-	msgReq.Metadata = s.applyRoutingPolicy(mode, msgReq.Metadata)
+	promptText = lastUserPromptText(msgReq.Messages)
+	sampleMetadata = msgReq.Metadata
+	msgReq.Metadata = s.applyRoutingPolicy(r.Context(), mode, msgReq.Metadata)
+	msgReq.System = applyPreferredSystemPrompt(prefs, msgReq.System)
+	msgReq.System = s.applySystemPromptPrefix(r.Context(), mode, msgReq.System, msgReq.Metadata)
+	if memoryOn = s.sessionMemoryEnabled(sessionID, msgReq.Metadata); memoryOn {
+		msgReq.System = s.injectSessionMemory(r.Context(), sessionID, msgReq.System)
+	}
+	if class, shed := s.shedRequest(r, msgReq.Metadata); shed {
+		statusCode = http.StatusServiceUnavailable
+		errText = "shed under upstream saturation (" + class + " priority)"
+		s.writeShedRejection(w, class)
+		return
+	}
+	deg := s.applyDegradation(w, mode, msgReq.Metadata, &msgReq.MaxTokens)
+	if deg.Reject {
+		statusCode = http.StatusServiceUnavailable
+		errText = "rejected by degradation ladder"
+		s.writeDegradedRejection(w)
+		return
+	}
 
-	requestedModel, mappedModel, err := s.resolveUpstreamModel(mode, clientModel)
+	requestedModel, targets, err := s.resolveUpstreamTargets(r.Context(), mode, clientModel)
 	if err != nil {
 		statusCode = http.StatusBadRequest
 		errText = err.Error()
 		s.writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
 		return
 	}
+	mappedModel := targets[0]
+	if deg.Model != "" {
+		mappedModel = deg.Model
+		targets = nil
+	}
 	upstreamModel = mappedModel
 	msgReq.Model = mappedModel
+	msgReq.Metadata = applyModelFallbacks(msgReq.Metadata, targets)
 	msgReq.Metadata = s.applyChannelRoutePolicy(r.Context(), msgReq.Metadata, mappedModel)
+	var routedAdapters []string
+	msgReq.Metadata, routedAdapters, err = s.applyAdapterOverride(r, msgReq.Metadata)
+	if err != nil {
+		errText = err.Error()
+		statusCode = s.writeAdapterOverrideError(w, err)
+		return
+	}
+	channelID = routedChannelID(msgReq.Metadata)
 
 	action := policy.Action{
 		Path:      "/v1/chat/completions",
 		Model:     msgReq.Model,
 		Mode:      mode,
 		ToolNames: toolNames(msgReq.Tools),
+		Adapters:  routedAdapters,
 	}
-	if err := s.policy.Authorize(r.Context(), action); err != nil {
+	if err := s.policy.Authorize(s.policyContext(r.Context()), action); err != nil {
 		statusCode = http.StatusForbidden
 		errText = err.Error()
 		s.writeError(w, http.StatusForbidden, "permission_error", err.Error())
 		return
 	}
 
-	runID = s.nextID("run")
+	correlationID, err := requestCorrelationID(r)
+	if err != nil {
+		statusCode = http.StatusBadRequest
+		errText = err.Error()
+		s.writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	runID = s.newRunID(r)
+	r = r.WithContext(logging.WithRun(r.Context(), runID, sessionID))
+	if len(targets) > 1 {
+		r = r.WithContext(s.withModelTargetRecorder(r.Context(), runID, sessionID))
+	}
+	r = r.WithContext(s.withHedgeRecorder(r.Context(), runID, sessionID))
+	r = r.WithContext(s.withWireCapture(r, runID))
 	s.createRunIfConfigured(ccrun.CreateInput{
 		ID:             runID,
 		SessionID:      sessionID,
+		ProjectID:      projectIDFromContext(r.Context()),
+		UserID:         requestUserID(r.Context()),
+		CorrelationID:  correlationID,
 		Path:           "/v1/chat/completions",
 		Mode:           mode,
 		ClientModel:    clientModel,
@@ -140,6 +208,7 @@ func (s *server) handleOpenAIChatCompletions(w http.ResponseWriter, r *http.Requ
 		UpstreamModel:  mappedModel,
 		Stream:         streamMode,
 		ToolCount:      toolCount,
+		Metadata:       experimentRunMetadata(msgReq.Metadata),
 	})
 	s.appendEvent(ccevent.AppendInput{
 		EventType: "run.created",
@@ -152,14 +221,19 @@ func (s *server) handleOpenAIChatCompletions(w http.ResponseWriter, r *http.Requ
 			"requested_model": requestedModel,
 			"upstream_model":  mappedModel,
 			"stream":          streamMode,
+			"correlation_id":  correlationID,
 		},
 	})
 	w.Header().Set("request-id", runID)
 	w.Header().Set("x-cc-run-id", runID)
+	if correlationID != "" {
+		w.Header().Set("x-correlation-id", correlationID)
+	}
 	w.Header().Set("x-cc-mode", mode)
 	w.Header().Set("x-cc-client-model", clientModel)
 	w.Header().Set("x-cc-requested-model", requestedModel)
 	w.Header().Set("x-cc-upstream-model", mappedModel)
+	setCanaryHeaders(w, msgReq.Metadata)
 
 	creq := toCanonicalRequest(runID, msgReq, r)
 	if creq.Metadata == nil {
@@ -171,23 +245,47 @@ func (s *server) handleOpenAIChatCompletions(w http.ResponseWriter, r *http.Requ
 	creq.Metadata["client_model"] = clientModel
 	creq.Metadata["requested_model"] = requestedModel
 	creq.Metadata["upstream_model"] = mappedModel
-	reservedQuota := estimateReservedQuota(msgReq.MaxTokens, msgReq.System, msgReq.Messages)
+	creq.Metadata["admission_priority"] = s.admissionPriority(r, msgReq.Metadata)
+	creq, err = s.applyRequestPlugins(r.Context(), creq)
+	if err != nil {
+		statusCode = http.StatusBadGateway
+		errText = err.Error()
+		s.writeError(w, http.StatusBadGateway, "api_error", err.Error())
+		return
+	}
+	guarded, err := s.applyInputGuardrails(r.Context(), w, creq)
+	if err != nil {
+		statusCode = http.StatusBadRequest
+		errText = err.Error()
+		s.writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	creq = guarded
+	promptText = s.guardedPromptText(promptText, creq)
+	reservedQuota := estimateReservedQuota(s.tokenizerFor(msgReq.Model), msgReq.MaxTokens, msgReq.System, msgReq.Messages, msgReq.Tools)
 	if err := s.reserveQuotaFromRequestContext(r.Context(), reservedQuota); err != nil {
 		statusCode = http.StatusForbidden
 		errText = err.Error()
 		s.writeError(w, http.StatusForbidden, "quota_error", err.Error())
 		return
 	}
+	creq = s.applyContextCompaction(r.Context(), creq)
 
 	if msgReq.Stream {
 		creq = s.applyVisionFallback(r.Context(), creq)
+		creq = s.applyImagePreprocess(r.Context(), creq)
 		creq = s.applyToolSupportFallback(creq)
+		sw, sr, stopGuard := s.guardStream(w, r, commentPingFrame)
+		sw, releaseOutput := s.holdStreamForGuardrails(sw, creq, streamFormatChat, requestedModel)
 		var usage orchestrator.Usage
 		if s.shouldStreamWithToolLoop(creq) {
-			generatedText, usage = s.streamOpenAIChatCompletionsWithToolLoop(w, r, creq, requestedModel)
+			generatedText, usage = s.streamOpenAIChatCompletionsWithToolLoop(sw, sr, creq, requestedModel)
 		} else {
-			generatedText, usage = s.streamOpenAIChatCompletions(w, r, creq, requestedModel)
+			generatedText, usage = s.streamOpenAIChatCompletions(sw, sr, creq, requestedModel)
 		}
+		generatedText = releaseOutput(r.Context(), generatedText)
+		stopGuard()
+		s.recordUsage(r.Context(), creq, usage, "", "", true)
 		if err := s.settleQuotaFromRequestContext(r.Context(), reservedQuota, usageToQuotaAmount(usage.InputTokens, usage.OutputTokens)); err != nil {
 			statusCode = http.StatusForbidden
 			errText = err.Error()
@@ -196,8 +294,17 @@ func (s *server) handleOpenAIChatCompletions(w http.ResponseWriter, r *http.Requ
 	}
 
 	creq = s.applyVisionFallback(r.Context(), creq)
+	creq = s.applyImagePreprocess(r.Context(), creq)
 	creq = s.applyToolSupportFallback(creq)
 	resp, err := s.completeWithToolLoop(r.Context(), creq)
+	if err != nil {
+		_ = s.refundQuotaFromRequestContext(r.Context(), reservedQuota)
+		errText = err.Error()
+		statusCode = s.writeUpstreamError(w, err)
+		return
+	}
+	resp = s.applyResponseFormatRepair(sessionID, runID, "/v1/chat/completions", creq, resp)
+	resp, err = s.applyResponsePlugins(r.Context(), creq, resp)
 	if err != nil {
 		_ = s.refundQuotaFromRequestContext(r.Context(), reservedQuota)
 		statusCode = http.StatusBadGateway
@@ -205,7 +312,10 @@ func (s *server) handleOpenAIChatCompletions(w http.ResponseWriter, r *http.Requ
 		s.writeError(w, http.StatusBadGateway, "api_error", err.Error())
 		return
 	}
+	resp = s.applyOutputGuardrails(r.Context(), w, creq, resp)
 	generatedText = collectResponseText(resp)
+	s.recordRunAdapter(creq.RunID, resp.Trace.Provider)
+	s.recordUsage(r.Context(), creq, resp.Usage, resp.Trace.Model, resp.Trace.Provider, false)
 	if err := s.settleQuotaFromRequestContext(r.Context(), reservedQuota, usageToQuotaAmount(resp.Usage.InputTokens, resp.Usage.OutputTokens)); err != nil {
 		_ = s.refundQuotaFromRequestContext(r.Context(), reservedQuota)
 		statusCode = http.StatusForbidden
@@ -237,18 +347,31 @@ func (s *server) streamOpenAIChatCompletions(w http.ResponseWriter, r *http.Requ
 	streamID := s.nextID("chatcmpl")
 	created := time.Now().Unix()
 	events, errs := s.orchestrator.Stream(r.Context(), req)
+	check := newStreamCheck()
 
 	for {
 		select {
 		case ev, ok := <-events:
 			if !ok {
+				if replay, ok := s.finishStream(r.Context(), req, check, nil); ok {
+					events, errs = replay, nil
+					continue
+				}
 				_ = writeOpenAISSEData(w, "[DONE]")
 				flusher.Flush()
 				return generated.String(), usage
 			}
+			if ev.Type == "ping" {
+				if err := writeQueueComment(w, ev); err != nil {
+					return generated.String(), usage
+				}
+				flusher.Flush()
+				continue
+			}
 			appendStreamText(&generated, ev)
-			if ev.Usage.InputTokens > 0 || ev.Usage.OutputTokens > 0 {
-				usage = ev.Usage
+			check.observe(ev)
+			if check.usage.InputTokens > 0 || check.usage.OutputTokens > 0 {
+				usage = check.usage
 			}
 			chunk := openAIChatChunkFromEvent(streamID, outwardModel, created, ev)
 			if chunk == nil {
@@ -263,7 +386,11 @@ func (s *server) streamOpenAIChatCompletions(w http.ResponseWriter, r *http.Requ
 			if !ok || err == nil {
 				continue
 			}
-			_ = writeOpenAISSEData(w, fmt.Sprintf(`{"error":{"message":%q}}`, err.Error()))
+			if replay, ok := s.finishStream(r.Context(), req, check, err); ok {
+				events, errs = replay, nil
+				continue
+			}
+			_ = writeOpenAISSEData(w, openAIStreamErrorData(err, false))
 			flusher.Flush()
 			return generated.String(), usage
 		case <-r.Context().Done():
@@ -285,6 +412,10 @@ func (s *server) handleOpenAIResponses(w http.ResponseWriter, r *http.Request) {
 	toolCount := 0
 	sessionID := ""
 	generatedText := ""
+	promptText := ""
+	memoryOn := false
+	channelID := int64(0)
+	var sampleMetadata map[string]any
 	defer func() {
 		recordText := buildRunRecordText("/v1/responses", mode, statusCode, streamMode, generatedText, errText)
 		s.logRun(runlog.Entry{
@@ -301,8 +432,9 @@ func (s *server) handleOpenAIResponses(w http.ResponseWriter, r *http.Request) {
 			RecordText:     recordText,
 			DurationMS:     time.Since(started).Milliseconds(),
 		})
+		s.recordChannelOutcome(channelID, statusCode, errText, time.Since(started))
 		if runID != "" {
-			s.completeRunIfConfigured(runID, statusCode, errText)
+			s.completeRunIfConfigured(runID, statusCode, errText, promptText, generatedText, recordText)
 		}
 		if runID != "" {
 			eventType := "run.completed"
@@ -324,6 +456,10 @@ func (s *server) handleOpenAIResponses(w http.ResponseWriter, r *http.Request) {
 				},
 			})
 		}
+		s.offerTrafficSample(runID, sessionID, "/v1/responses", mode, upstreamModel, statusCode, streamMode, promptText, generatedText, sampleMetadata)
+		if memoryOn && statusCode < 400 {
+			s.rememberSessionExchange(sessionID, runID, promptText, generatedText)
+		}
 	}()
 
 	if r.Method != http.MethodPost {
@@ -341,6 +477,8 @@ func (s *server) handleOpenAIResponses(w http.ResponseWriter, r *http.Request) {
 		s.writeError(w, http.StatusBadRequest, "invalid_request_error", "invalid JSON body")
 		return
 	}
+	prefs := s.requestUserPreferences(r.Context())
+	applyPreferredDefaults(prefs, &req.Model, &req.Temperature)
 	msgReq, err := openAIResponsesToMessagesRequest(req)
 	if err != nil {
 		statusCode = http.StatusBadRequest
@@ -351,46 +489,102 @@ func (s *server) handleOpenAIResponses(w http.ResponseWriter, r *http.Request) {
 	if err := s.enforceTokenModelAccess(r.Context(), msgReq.Model); err != nil {
 		statusCode = http.StatusForbidden
 		errText = err.Error()
-		s.writeError(w, http.StatusForbidden, "permission_error", err.Error())
+		s.writeModelAccessError(w, err)
+		return
+	}
+	if err := s.resolveFileReferences(r.Context(), msgReq.Messages); err != nil {
+		statusCode = http.StatusBadRequest
+		errText = err.Error()
+		s.writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
 		return
 	}
 
-	mode = requestMode(r, msgReq.Metadata)
+	mode = requestModeOr(r, msgReq.Metadata, prefs.Mode)
 	clientModel = msgReq.Model
 	streamMode = msgReq.Stream
 	toolCount = len(msgReq.Tools)
 	sessionID = requestSessionID(r, msgReq.Metadata)
-	msgReq.System = s.applySystemPromptPrefix(mode, msgReq.System)
-	msgReq.Metadata = s.applyRoutingPolicy(mode, msgReq.Metadata)
+	promptText = lastUserPromptText(msgReq.Messages)
+	sampleMetadata = msgReq.Metadata
+	msgReq.Metadata = s.applyRoutingPolicy(r.Context(), mode, msgReq.Metadata)
+	msgReq.System = applyPreferredSystemPrompt(prefs, msgReq.System)
+	msgReq.System = s.applySystemPromptPrefix(r.Context(), mode, msgReq.System, msgReq.Metadata)
+	if memoryOn = s.sessionMemoryEnabled(sessionID, msgReq.Metadata); memoryOn {
+		msgReq.System = s.injectSessionMemory(r.Context(), sessionID, msgReq.System)
+	}
+	if class, shed := s.shedRequest(r, msgReq.Metadata); shed {
+		statusCode = http.StatusServiceUnavailable
+		errText = "shed under upstream saturation (" + class + " priority)"
+		s.writeShedRejection(w, class)
+		return
+	}
+	deg := s.applyDegradation(w, mode, msgReq.Metadata, &msgReq.MaxTokens)
+	if deg.Reject {
+		statusCode = http.StatusServiceUnavailable
+		errText = "rejected by degradation ladder"
+		s.writeDegradedRejection(w)
+		return
+	}
 
-	requestedModel, mappedModel, err := s.resolveUpstreamModel(mode, clientModel)
+	requestedModel, targets, err := s.resolveUpstreamTargets(r.Context(), mode, clientModel)
 	if err != nil {
 		statusCode = http.StatusBadRequest
 		errText = err.Error()
 		s.writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
 		return
 	}
+	mappedModel := targets[0]
+	if deg.Model != "" {
+		mappedModel = deg.Model
+		targets = nil
+	}
 	upstreamModel = mappedModel
 	msgReq.Model = mappedModel
+	msgReq.Metadata = applyModelFallbacks(msgReq.Metadata, targets)
 	msgReq.Metadata = s.applyChannelRoutePolicy(r.Context(), msgReq.Metadata, mappedModel)
+	var routedAdapters []string
+	msgReq.Metadata, routedAdapters, err = s.applyAdapterOverride(r, msgReq.Metadata)
+	if err != nil {
+		errText = err.Error()
+		statusCode = s.writeAdapterOverrideError(w, err)
+		return
+	}
+	channelID = routedChannelID(msgReq.Metadata)
 
 	action := policy.Action{
 		Path:      "/v1/responses",
 		Model:     msgReq.Model,
 		Mode:      mode,
 		ToolNames: toolNames(msgReq.Tools),
+		Adapters:  routedAdapters,
 	}
-	if err := s.policy.Authorize(r.Context(), action); err != nil {
+	if err := s.policy.Authorize(s.policyContext(r.Context()), action); err != nil {
 		statusCode = http.StatusForbidden
 		errText = err.Error()
 		s.writeError(w, http.StatusForbidden, "permission_error", err.Error())
 		return
 	}
 
-	runID = s.nextID("run")
+	correlationID, err := requestCorrelationID(r)
+	if err != nil {
+		statusCode = http.StatusBadRequest
+		errText = err.Error()
+		s.writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	runID = s.newRunID(r)
+	r = r.WithContext(logging.WithRun(r.Context(), runID, sessionID))
+	if len(targets) > 1 {
+		r = r.WithContext(s.withModelTargetRecorder(r.Context(), runID, sessionID))
+	}
+	r = r.WithContext(s.withHedgeRecorder(r.Context(), runID, sessionID))
+	r = r.WithContext(s.withWireCapture(r, runID))
 	s.createRunIfConfigured(ccrun.CreateInput{
 		ID:             runID,
 		SessionID:      sessionID,
+		ProjectID:      projectIDFromContext(r.Context()),
+		UserID:         requestUserID(r.Context()),
+		CorrelationID:  correlationID,
 		Path:           "/v1/responses",
 		Mode:           mode,
 		ClientModel:    clientModel,
@@ -398,6 +592,7 @@ func (s *server) handleOpenAIResponses(w http.ResponseWriter, r *http.Request) {
 		UpstreamModel:  mappedModel,
 		Stream:         streamMode,
 		ToolCount:      toolCount,
+		Metadata:       experimentRunMetadata(msgReq.Metadata),
 	})
 	s.appendEvent(ccevent.AppendInput{
 		EventType: "run.created",
@@ -410,14 +605,19 @@ func (s *server) handleOpenAIResponses(w http.ResponseWriter, r *http.Request) {
 			"requested_model": requestedModel,
 			"upstream_model":  mappedModel,
 			"stream":          streamMode,
+			"correlation_id":  correlationID,
 		},
 	})
 	w.Header().Set("request-id", runID)
 	w.Header().Set("x-cc-run-id", runID)
+	if correlationID != "" {
+		w.Header().Set("x-correlation-id", correlationID)
+	}
 	w.Header().Set("x-cc-mode", mode)
 	w.Header().Set("x-cc-client-model", clientModel)
 	w.Header().Set("x-cc-requested-model", requestedModel)
 	w.Header().Set("x-cc-upstream-model", mappedModel)
+	setCanaryHeaders(w, msgReq.Metadata)
 
 	creq := toCanonicalRequest(runID, msgReq, r)
 	if creq.Metadata == nil {
@@ -429,23 +629,47 @@ func (s *server) handleOpenAIResponses(w http.ResponseWriter, r *http.Request) {
 	creq.Metadata["client_model"] = clientModel
 	creq.Metadata["requested_model"] = requestedModel
 	creq.Metadata["upstream_model"] = mappedModel
-	reservedQuota := estimateReservedQuota(msgReq.MaxTokens, msgReq.System, msgReq.Messages)
+	creq.Metadata["admission_priority"] = s.admissionPriority(r, msgReq.Metadata)
+	creq, err = s.applyRequestPlugins(r.Context(), creq)
+	if err != nil {
+		statusCode = http.StatusBadGateway
+		errText = err.Error()
+		s.writeError(w, http.StatusBadGateway, "api_error", err.Error())
+		return
+	}
+	guarded, err := s.applyInputGuardrails(r.Context(), w, creq)
+	if err != nil {
+		statusCode = http.StatusBadRequest
+		errText = err.Error()
+		s.writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	creq = guarded
+	promptText = s.guardedPromptText(promptText, creq)
+	reservedQuota := estimateReservedQuota(s.tokenizerFor(msgReq.Model), msgReq.MaxTokens, msgReq.System, msgReq.Messages, msgReq.Tools)
 	if err := s.reserveQuotaFromRequestContext(r.Context(), reservedQuota); err != nil {
 		statusCode = http.StatusForbidden
 		errText = err.Error()
 		s.writeError(w, http.StatusForbidden, "quota_error", err.Error())
 		return
 	}
+	creq = s.applyContextCompaction(r.Context(), creq)
 
 	if msgReq.Stream {
 		creq = s.applyVisionFallback(r.Context(), creq)
+		creq = s.applyImagePreprocess(r.Context(), creq)
 		creq = s.applyToolSupportFallback(creq)
+		sw, sr, stopGuard := s.guardStream(w, r, commentPingFrame)
+		sw, releaseOutput := s.holdStreamForGuardrails(sw, creq, streamFormatResponses, requestedModel)
 		var usage orchestrator.Usage
 		if s.shouldStreamWithToolLoop(creq) {
-			generatedText, usage = s.streamOpenAIResponsesWithToolLoop(w, r, creq, requestedModel)
+			generatedText, usage = s.streamOpenAIResponsesWithToolLoop(sw, sr, creq, requestedModel)
 		} else {
-			generatedText, usage = s.streamOpenAIResponses(w, r, creq, requestedModel)
+			generatedText, usage = s.streamOpenAIResponses(sw, sr, creq, requestedModel)
 		}
+		generatedText = releaseOutput(r.Context(), generatedText)
+		stopGuard()
+		s.recordUsage(r.Context(), creq, usage, "", "", true)
 		if err := s.settleQuotaFromRequestContext(r.Context(), reservedQuota, usageToQuotaAmount(usage.InputTokens, usage.OutputTokens)); err != nil {
 			statusCode = http.StatusForbidden
 			errText = err.Error()
@@ -454,8 +678,17 @@ func (s *server) handleOpenAIResponses(w http.ResponseWriter, r *http.Request) {
 	}
 
 	creq = s.applyVisionFallback(r.Context(), creq)
+	creq = s.applyImagePreprocess(r.Context(), creq)
 	creq = s.applyToolSupportFallback(creq)
 	resp, err := s.completeWithToolLoop(r.Context(), creq)
+	if err != nil {
+		_ = s.refundQuotaFromRequestContext(r.Context(), reservedQuota)
+		errText = err.Error()
+		statusCode = s.writeUpstreamError(w, err)
+		return
+	}
+	resp = s.applyResponseFormatRepair(sessionID, runID, "/v1/responses", creq, resp)
+	resp, err = s.applyResponsePlugins(r.Context(), creq, resp)
 	if err != nil {
 		_ = s.refundQuotaFromRequestContext(r.Context(), reservedQuota)
 		statusCode = http.StatusBadGateway
@@ -463,7 +696,10 @@ func (s *server) handleOpenAIResponses(w http.ResponseWriter, r *http.Request) {
 		s.writeError(w, http.StatusBadGateway, "api_error", err.Error())
 		return
 	}
+	resp = s.applyOutputGuardrails(r.Context(), w, creq, resp)
 	generatedText = collectResponseText(resp)
+	s.recordRunAdapter(creq.RunID, resp.Trace.Provider)
+	s.recordUsage(r.Context(), creq, resp.Usage, resp.Trace.Model, resp.Trace.Provider, false)
 	if err := s.settleQuotaFromRequestContext(r.Context(), reservedQuota, usageToQuotaAmount(resp.Usage.InputTokens, resp.Usage.OutputTokens)); err != nil {
 		_ = s.refundQuotaFromRequestContext(r.Context(), reservedQuota)
 		statusCode = http.StatusForbidden
@@ -505,10 +741,15 @@ func (s *server) streamOpenAIResponses(w http.ResponseWriter, r *http.Request, r
 	flusher.Flush()
 
 	events, errs := s.orchestrator.Stream(r.Context(), req)
+	check := newStreamCheck()
 	for {
 		select {
 		case ev, ok := <-events:
 			if !ok {
+				if replay, ok := s.finishStream(r.Context(), req, check, nil); ok {
+					events, errs = replay, nil
+					continue
+				}
 				completed := map[string]any{
 					"type":    "response.completed",
 					"id":      respID,
@@ -521,9 +762,17 @@ func (s *server) streamOpenAIResponses(w http.ResponseWriter, r *http.Request, r
 				flusher.Flush()
 				return generated.String(), usage
 			}
+			if ev.Type == "ping" {
+				if err := writeQueueComment(w, ev); err != nil {
+					return generated.String(), usage
+				}
+				flusher.Flush()
+				continue
+			}
 			appendStreamText(&generated, ev)
-			if ev.Usage.InputTokens > 0 || ev.Usage.OutputTokens > 0 {
-				usage = ev.Usage
+			check.observe(ev)
+			if check.usage.InputTokens > 0 || check.usage.OutputTokens > 0 {
+				usage = check.usage
 			}
 			item := openAIResponseStreamEvent(respID, ev)
 			if item == nil {
@@ -538,7 +787,11 @@ func (s *server) streamOpenAIResponses(w http.ResponseWriter, r *http.Request, r
 			if !ok || err == nil {
 				continue
 			}
-			_ = writeOpenAISSEData(w, fmt.Sprintf(`{"type":"error","error":{"message":%q}}`, err.Error()))
+			if replay, ok := s.finishStream(r.Context(), req, check, err); ok {
+				events, errs = replay, nil
+				continue
+			}
+			_ = writeOpenAISSEData(w, openAIStreamErrorData(err, true))
 			flusher.Flush()
 			return generated.String(), usage
 		case <-r.Context().Done():
@@ -587,22 +840,28 @@ func openAIChatToMessagesRequest(req OpenAIChatCompletionsRequest) (MessagesRequ
 		})
 	}
 
+	outputFormat := outputFormatFromOpenAI(req.ResponseFormat)
+	if err := validateOutputFormat(outputFormat); err != nil {
+		return MessagesRequest{}, err
+	}
+
 	var system any
 	if len(systemParts) > 0 {
 		system = strings.Join(systemParts, "\n")
 	}
 
 	return MessagesRequest{
-		Model:       req.Model,
-		MaxTokens:   maxTokens,
-		System:      system,
-		Messages:    msgs,
-		Stream:      req.Stream,
-		Tools:       tools,
-		ToolChoice:  req.ToolChoice,
-		Temperature: req.Temperature,
-		TopP:        req.TopP,
-		Metadata:    mergeMetadata(req.Metadata, req.StreamOptions),
+		Model:        req.Model,
+		MaxTokens:    maxTokens,
+		System:       system,
+		Messages:     msgs,
+		Stream:       req.Stream,
+		Tools:        tools,
+		ToolChoice:   req.ToolChoice,
+		Temperature:  req.Temperature,
+		TopP:         req.TopP,
+		OutputFormat: outputFormat,
+		Metadata:     mergeMetadata(req.Metadata, req.StreamOptions),
 	}, nil
 }
 
@@ -752,16 +1011,22 @@ func openAIResponsesToMessagesRequest(req OpenAIResponsesRequest) (MessagesReque
 		})
 	}
 
+	outputFormat := outputFormatFromResponsesText(req.Text)
+	if err := validateOutputFormat(outputFormat); err != nil {
+		return MessagesRequest{}, err
+	}
+
 	return MessagesRequest{
-		Model:       req.Model,
-		MaxTokens:   maxTokens,
-		Messages:    msgs,
-		Stream:      req.Stream,
-		Tools:       tools,
-		ToolChoice:  req.ToolChoice,
-		Temperature: req.Temperature,
-		TopP:        req.TopP,
-		Metadata:    mergeMetadata(req.Metadata, req.StreamOptions),
+		Model:        req.Model,
+		MaxTokens:    maxTokens,
+		Messages:     msgs,
+		Stream:       req.Stream,
+		Tools:        tools,
+		ToolChoice:   req.ToolChoice,
+		Temperature:  req.Temperature,
+		TopP:         req.TopP,
+		OutputFormat: outputFormat,
+		Metadata:     mergeMetadata(req.Metadata, req.StreamOptions),
 	}, nil
 }
 
@@ -976,6 +1241,8 @@ func toOpenAIChatCompletionsResponse(id, outwardModel string, resp orchestrator.
 	finish := "stop"
 	if resp.StopReason == "tool_use" || len(toolCalls) > 0 {
 		finish = "tool_calls"
+	} else if resp.StopReason == "refusal" {
+		finish = "content_filter"
 	}
 
 	return OpenAIChatCompletionsResponse{
@@ -999,6 +1266,7 @@ func toOpenAIChatCompletionsResponse(id, outwardModel string, resp orchestrator.
 			CompletionTokens: resp.Usage.OutputTokens,
 			TotalTokens:      resp.Usage.InputTokens + resp.Usage.OutputTokens,
 		},
+		Metadata: responseMetadata(resp),
 	}
 }
 
@@ -1049,6 +1317,7 @@ func toOpenAIResponsesResponse(id, outwardModel string, resp orchestrator.Respon
 			CompletionTokens: resp.Usage.OutputTokens,
 			TotalTokens:      resp.Usage.InputTokens + resp.Usage.OutputTokens,
 		},
+		Metadata: responseMetadata(resp),
 	}
 }
 
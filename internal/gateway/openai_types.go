@@ -1,16 +1,29 @@
 package gateway
 
 type OpenAIChatCompletionsRequest struct {
-	Model         string              `json:"model"`
-	Messages      []OpenAIChatMessage `json:"messages"`
-	MaxTokens     int                 `json:"max_tokens,omitempty"`
-	Stream        bool                `json:"stream,omitempty"`
-	StreamOptions map[string]any      `json:"stream_options,omitempty"`
-	Tools         []OpenAIChatTool    `json:"tools,omitempty"`
-	ToolChoice    any                 `json:"tool_choice,omitempty"`
-	Temperature   *float64            `json:"temperature,omitempty"`
-	TopP          *float64            `json:"top_p,omitempty"`
-	Metadata      map[string]any      `json:"metadata,omitempty"`
+	Model          string                `json:"model"`
+	Messages       []OpenAIChatMessage   `json:"messages"`
+	MaxTokens      int                   `json:"max_tokens,omitempty"`
+	Stream         bool                  `json:"stream,omitempty"`
+	StreamOptions  map[string]any        `json:"stream_options,omitempty"`
+	Tools          []OpenAIChatTool      `json:"tools,omitempty"`
+	ToolChoice     any                   `json:"tool_choice,omitempty"`
+	Temperature    *float64              `json:"temperature,omitempty"`
+	TopP           *float64              `json:"top_p,omitempty"`
+	ResponseFormat *OpenAIResponseFormat `json:"response_format,omitempty"`
+	Metadata       map[string]any        `json:"metadata,omitempty"`
+}
+
+type OpenAIResponseFormat struct {
+	Type       string            `json:"type"`
+	JSONSchema *OpenAIJSONSchema `json:"json_schema,omitempty"`
+}
+
+type OpenAIJSONSchema struct {
+	Name        string         `json:"name,omitempty"`
+	Description string         `json:"description,omitempty"`
+	Schema      map[string]any `json:"schema,omitempty"`
+	Strict      bool           `json:"strict,omitempty"`
 }
 
 type OpenAIChatMessage struct {
@@ -33,12 +46,13 @@ type OpenAIChatToolDetails struct {
 }
 
 type OpenAIChatCompletionsResponse struct {
-	ID      string                       `json:"id"`
-	Object  string                       `json:"object"`
-	Created int64                        `json:"created"`
-	Model   string                       `json:"model"`
-	Choices []OpenAIChatCompletionChoice `json:"choices"`
-	Usage   OpenAIUsage                  `json:"usage"`
+	ID       string                       `json:"id"`
+	Object   string                       `json:"object"`
+	Created  int64                        `json:"created"`
+	Model    string                       `json:"model"`
+	Choices  []OpenAIChatCompletionChoice `json:"choices"`
+	Usage    OpenAIUsage                  `json:"usage"`
+	Metadata *ResponseMetadata            `json:"metadata,omitempty"`
 }
 
 type OpenAIChatCompletionChoice struct {
@@ -71,26 +85,42 @@ type OpenAIUsage struct {
 }
 
 type OpenAIResponsesRequest struct {
-	Model           string           `json:"model"`
-	Input           any              `json:"input"`
-	MaxOutputTokens int              `json:"max_output_tokens,omitempty"`
-	Stream          bool             `json:"stream,omitempty"`
-	StreamOptions   map[string]any   `json:"stream_options,omitempty"`
-	Tools           []OpenAIChatTool `json:"tools,omitempty"`
-	ToolChoice      any              `json:"tool_choice,omitempty"`
-	Temperature     *float64         `json:"temperature,omitempty"`
-	TopP            *float64         `json:"top_p,omitempty"`
-	Metadata        map[string]any   `json:"metadata,omitempty"`
+	Model           string               `json:"model"`
+	Input           any                  `json:"input"`
+	MaxOutputTokens int                  `json:"max_output_tokens,omitempty"`
+	Stream          bool                 `json:"stream,omitempty"`
+	StreamOptions   map[string]any       `json:"stream_options,omitempty"`
+	Tools           []OpenAIChatTool     `json:"tools,omitempty"`
+	ToolChoice      any                  `json:"tool_choice,omitempty"`
+	Temperature     *float64             `json:"temperature,omitempty"`
+	TopP            *float64             `json:"top_p,omitempty"`
+	Text            *OpenAIResponsesText `json:"text,omitempty"`
+	Metadata        map[string]any       `json:"metadata,omitempty"`
+}
+
+type OpenAIResponsesText struct {
+	Format *OpenAIResponsesTextFormat `json:"format,omitempty"`
+}
+
+// OpenAIResponsesTextFormat is the Responses API flavour of response_format,
+// with the json_schema fields inlined.
+type OpenAIResponsesTextFormat struct {
+	Type        string         `json:"type"`
+	Name        string         `json:"name,omitempty"`
+	Description string         `json:"description,omitempty"`
+	Schema      map[string]any `json:"schema,omitempty"`
+	Strict      bool           `json:"strict,omitempty"`
 }
 
 type OpenAIResponsesResponse struct {
-	ID      string                 `json:"id"`
-	Object  string                 `json:"object"`
-	Created int64                  `json:"created"`
-	Model   string                 `json:"model"`
-	Status  string                 `json:"status"`
-	Output  []OpenAIResponseOutput `json:"output"`
-	Usage   OpenAIUsage            `json:"usage"`
+	ID       string                 `json:"id"`
+	Object   string                 `json:"object"`
+	Created  int64                  `json:"created"`
+	Model    string                 `json:"model"`
+	Status   string                 `json:"status"`
+	Output   []OpenAIResponseOutput `json:"output"`
+	Usage    OpenAIUsage            `json:"usage"`
+	Metadata *ResponseMetadata      `json:"metadata,omitempty"`
 }
 
 type OpenAIResponseOutput struct {
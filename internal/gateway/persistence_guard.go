@@ -0,0 +1,111 @@
+package gateway
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"ccgateway/internal/auditlog"
+	"ccgateway/internal/ccevent"
+	"ccgateway/internal/statepersist"
+)
+
+// persistedStatePrefixes are the /v1/cc endpoints whose writes end up in
+// persisted stores; they go read-only while persistence is degraded.
+var persistedStatePrefixes = []string{
+	"/v1/cc/sessions",
+	"/v1/cc/runs",
+	"/v1/cc/plans",
+	"/v1/cc/todos",
+	"/v1/cc/teams",
+	"/v1/cc/subagents",
+}
+
+// withPersistenceGuard rejects writes to stateful endpoints while the state
+// persistence manager reports read-only mode.
+func (s *server) withPersistenceGuard(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.persistence != nil && isStateWrite(r) && s.persistence.Health().ReadOnly {
+			w.Header().Set("retry-after", "30")
+			s.writeError(w, http.StatusServiceUnavailable, "api_error", "state persistence is degraded; stateful endpoints are read-only")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func isStateWrite(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	for _, prefix := range persistedStatePrefixes {
+		if r.URL.Path == prefix || strings.HasPrefix(r.URL.Path, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// handleReadyz fails readiness while state persistence is degraded so load
+// balancers stop sending traffic that cannot be saved.
+func (s *server) handleReadyz(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("content-type", "application/json")
+	if s.persistence == nil {
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`{"ready":true}`))
+		return
+	}
+	health := s.persistence.Health()
+	status := http.StatusOK
+	if health.Degraded {
+		status = http.StatusServiceUnavailable
+	}
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"ready":       !health.Degraded,
+		"persistence": health,
+	})
+}
+
+// persistenceRepairer is implemented by persistence managers that queue
+// failed saves for retry.
+type persistenceRepairer interface {
+	Repairs() statepersist.RepairStatus
+	FlushRepairs() statepersist.RepairStatus
+}
+
+// handleAdminPersistenceRepairs lists the persisted keys waiting to be
+// written again; POST retries them all now, ignoring backoff.
+func (s *server) handleAdminPersistenceRepairs(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	repairer, ok := s.persistence.(persistenceRepairer)
+	if !ok {
+		s.writeError(w, http.StatusNotImplemented, "api_error", "state persistence is not configured")
+		return
+	}
+	var status statepersist.RepairStatus
+	switch r.Method {
+	case http.MethodGet:
+		status = repairer.Repairs()
+	case http.MethodPost:
+		before := repairer.Repairs().Pending
+		status = repairer.FlushRepairs()
+		s.appendEvent(ccevent.AppendInput{
+			EventType: "persistence.repairs_flushed",
+			Data: map[string]any{
+				"attempted": before,
+				"pending":   status.Pending,
+				"actor":     auditlog.ActorID(adminTokenFromRequest(r)),
+			},
+		})
+	default:
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+		return
+	}
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(status)
+}
@@ -0,0 +1,81 @@
+package gateway
+
+import (
+	"context"
+	"strings"
+
+	"ccgateway/internal/ccevent"
+	"ccgateway/internal/orchestrator"
+	"ccgateway/internal/plugin"
+	"ccgateway/internal/requestctx"
+)
+
+// middlewarePlugins returns the plugins whose middleware may run for the
+// request's project: global plugins (optionally limited by their projects
+// list) and the project's own scoped plugins.
+func (s *server) middlewarePlugins(ctx context.Context) []plugin.Plugin {
+	if s.pluginStore == nil || s.pluginMiddleware == nil {
+		return nil
+	}
+	projectID := requestctx.NormalizeProjectID(requestctx.ProjectID(ctx))
+	var out []plugin.Plugin
+	for _, p := range s.pluginStore.List() {
+		if p.Middleware == nil || !p.Enabled {
+			continue
+		}
+		if strings.HasPrefix(p.Name, "prj_") {
+			if projectID == requestctx.DefaultProjectID || !pluginBelongsToProject(projectID, p.Name) {
+				continue
+			}
+		} else if len(p.Middleware.Projects) > 0 && !containsFold(p.Middleware.Projects, projectID) {
+			continue
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+// applyRequestPlugins runs the request-stage middleware chain. Only a
+// plugin with failure_mode "abort" can fail the request.
+func (s *server) applyRequestPlugins(ctx context.Context, req orchestrator.Request) (orchestrator.Request, error) {
+	plugins := s.middlewarePlugins(ctx)
+	if len(plugins) == 0 {
+		return req, nil
+	}
+	out, outcomes, err := s.pluginMiddleware.RewriteRequest(ctx, plugins, req)
+	s.recordPluginOutcomes(req, outcomes)
+	return out, err
+}
+
+// applyResponsePlugins runs the response-stage middleware chain before the
+// response is converted to the client's wire format.
+func (s *server) applyResponsePlugins(ctx context.Context, req orchestrator.Request, resp orchestrator.Response) (orchestrator.Response, error) {
+	plugins := s.middlewarePlugins(ctx)
+	if len(plugins) == 0 {
+		return resp, nil
+	}
+	out, outcomes, err := s.pluginMiddleware.RewriteResponse(ctx, plugins, req, resp)
+	s.recordPluginOutcomes(req, outcomes)
+	return out, err
+}
+
+func (s *server) recordPluginOutcomes(req orchestrator.Request, outcomes []plugin.Outcome) {
+	sessionID, _ := req.Metadata["session_id"].(string)
+	for _, o := range outcomes {
+		if o.Error == "" {
+			continue
+		}
+		s.logger.Warn("plugin middleware failed", "run_id", req.RunID, "plugin", o.Plugin, "stage", o.Stage, "error", o.Error)
+		s.appendEvent(ccevent.AppendInput{
+			EventType: "plugin.middleware_failed",
+			SessionID: sessionID,
+			RunID:     req.RunID,
+			Data: map[string]any{
+				"plugin":      o.Plugin,
+				"stage":       o.Stage,
+				"error":       o.Error,
+				"duration_ms": o.DurationMS,
+			},
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package gateway
+
+import (
+	"context"
+	"strings"
+
+	"ccgateway/internal/policy"
+	"ccgateway/internal/token"
+)
+
+// policyContext returns ctx carrying the attributes of the user token that
+// authenticated the request, for matching policy rules. Admin-token and
+// open-mode requests carry none.
+func (s *server) policyContext(ctx context.Context) context.Context {
+	tk, ok := ctx.Value(tokenContextKey).(*token.Token)
+	if !ok || tk == nil {
+		return ctx
+	}
+	attrs := policy.TokenAttributes{
+		ID:     tk.ID,
+		UserID: strings.TrimSpace(tk.UserID),
+		Name:   strings.TrimSpace(tk.Name),
+		Group:  s.resolveUserGroup(ctx),
+		Models: tk.AllowedModels(),
+	}
+	if attrs.UserID != "" && s.authService != nil {
+		if user, err := s.authService.Get(attrs.UserID); err == nil {
+			attrs.Role = user.Role
+		}
+	}
+	return policy.WithTokenAttributes(ctx, attrs)
+}
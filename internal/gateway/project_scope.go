@@ -31,13 +31,24 @@ func projectIDFromRequest(r *http.Request) string {
 	if r == nil {
 		return requestctx.DefaultProjectID
 	}
+	if id := explicitProjectID(r); id != "" {
+		return id
+	}
+	return requestctx.ProjectID(r.Context())
+}
+
+// explicitProjectID is the project the caller named through the project_id
+// query parameter or the x-cc-project / x-project-id header, if any.
+func explicitProjectID(r *http.Request) string {
 	if raw := strings.TrimSpace(r.URL.Query().Get("project_id")); raw != "" {
 		return requestctx.NormalizeProjectID(raw)
 	}
-	if raw := strings.TrimSpace(r.Header.Get("x-project-id")); raw != "" {
-		return requestctx.NormalizeProjectID(raw)
+	for _, name := range []string{"x-cc-project", "x-project-id"} {
+		if raw := strings.TrimSpace(r.Header.Get(name)); raw != "" {
+			return requestctx.NormalizeProjectID(raw)
+		}
 	}
-	return requestctx.ProjectID(r.Context())
+	return ""
 }
 
 func projectIDFromContext(ctx context.Context) string {
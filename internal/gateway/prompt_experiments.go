@@ -0,0 +1,422 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strings"
+
+	"ccgateway/internal/ccevent"
+	"ccgateway/internal/ccrun"
+	"ccgateway/internal/notification"
+	"ccgateway/internal/settings"
+)
+
+const promptCohortPromoted = "promoted"
+
+type promptAssignment struct {
+	Experiment string
+	Variant    string
+	Cohort     string
+}
+
+// assignPromptVariant picks the prompt variant for a request. A promoted
+// variant takes all traffic; otherwise traffic is split by weight, sticky
+// per user or token when the experiment asks for it.
+func assignPromptVariant(ctx context.Context, mode string, exp settings.PromptExperiment) promptAssignment {
+	out := promptAssignment{Experiment: mode, Cohort: settings.CanaryStickyRequest}
+	if exp.Promoted != "" {
+		out.Variant = exp.Promoted
+		out.Cohort = promptCohortPromoted
+		return out
+	}
+	key := ""
+	switch exp.StickyBy {
+	case settings.CanaryStickyUser:
+		key = requestUserID(ctx)
+	case settings.CanaryStickyToken:
+		key, _ = requestTokenIdentity(ctx)
+	}
+	var total float64
+	for _, v := range exp.Variants {
+		total += v.Weight
+	}
+	var pick float64
+	if key != "" {
+		out.Cohort = exp.StickyBy
+		pick = float64(canaryBucket("prompt:"+mode, key)) / 10000 * total
+	} else {
+		pick = rand.Float64() * total
+	}
+	out.Variant = exp.Variants[len(exp.Variants)-1].Name
+	for _, v := range exp.Variants {
+		if pick < v.Weight {
+			out.Variant = v.Name
+			break
+		}
+		pick -= v.Weight
+	}
+	return out
+}
+
+func (s *server) applyPromptExperiment(ctx context.Context, out map[string]any, mode string) {
+	exp, ok := s.settings.PromptExperiment(mode)
+	if !ok {
+		return
+	}
+	a := assignPromptVariant(ctx, normalizePromptMode(mode), exp)
+	out["prompt_experiment"] = a.Experiment
+	out["prompt_variant"] = a.Variant
+	out["prompt_cohort"] = a.Cohort
+}
+
+// promptVariantPrefix returns the prefix of the variant assigned in
+// metadata, if the experiment still has it.
+func (s *server) promptVariantPrefix(mode string, metadata map[string]any) (string, bool) {
+	variant, _ := metadata["prompt_variant"].(string)
+	if variant == "" {
+		return "", false
+	}
+	exp, ok := s.settings.PromptExperiment(mode)
+	if !ok {
+		return "", false
+	}
+	v, ok := exp.Variant(variant)
+	if !ok {
+		return "", false
+	}
+	return v.Prefix, true
+}
+
+func normalizePromptMode(mode string) string {
+	mode = strings.ToLower(strings.TrimSpace(mode))
+	if mode == "" {
+		return "chat"
+	}
+	return mode
+}
+
+// promptVariantStats is how one variant has done so far. Mean is the
+// outcome on 0..1: user feedback when a run has any, else the latest judge
+// score scaled down from 0..10.
+type promptVariantStats struct {
+	Variant       string   `json:"variant"`
+	Weight        float64  `json:"weight"`
+	Runs          int      `json:"runs"`
+	Scored        int      `json:"scored"`
+	FeedbackUp    int      `json:"feedback_up"`
+	FeedbackDown  int      `json:"feedback_down"`
+	AvgJudgeScore *float64 `json:"avg_judge_score,omitempty"`
+	Mean          float64  `json:"mean"`
+	StdDev        float64  `json:"stddev"`
+
+	judgeSum   float64
+	judgeCount int
+	outcomes   []float64
+}
+
+type promptExperimentReport struct {
+	Mode        string               `json:"mode"`
+	Enabled     bool                 `json:"enabled"`
+	AutoPromote bool                 `json:"auto_promote"`
+	MinSamples  int                  `json:"min_samples"`
+	Threshold   float64              `json:"confidence_threshold"`
+	Promoted    string               `json:"promoted,omitempty"`
+	Leader      string               `json:"leader,omitempty"`
+	Confidence  float64              `json:"confidence"`
+	Ready       bool                 `json:"ready"`
+	Variants    []promptVariantStats `json:"variants"`
+}
+
+// promptExperimentReport tallies the runs attributed to mode's experiment.
+// Confidence is the smallest one-sided probability (Welch z-test) that the
+// leader beats a rival; Ready means the experiment could be promoted now.
+func (s *server) promptExperimentReport(mode string, exp settings.PromptExperiment) promptExperimentReport {
+	out := promptExperimentReport{
+		Mode:        mode,
+		Enabled:     exp.Enabled,
+		AutoPromote: exp.AutoPromote,
+		MinSamples:  exp.MinSamples,
+		Threshold:   exp.Confidence,
+		Promoted:    exp.Promoted,
+		Variants:    []promptVariantStats{},
+	}
+	if len(exp.Variants) == 0 {
+		return out
+	}
+	stats := make([]promptVariantStats, len(exp.Variants))
+	index := map[string]int{}
+	for i, v := range exp.Variants {
+		stats[i] = promptVariantStats{Variant: v.Name, Weight: v.Weight}
+		index[v.Name] = i
+	}
+	if s.runStore != nil {
+		for _, run := range s.runStore.List(ccrun.ListFilter{}) {
+			if experiment, _ := run.Metadata["prompt_experiment"].(string); experiment != mode {
+				continue
+			}
+			variant, _ := run.Metadata["prompt_variant"].(string)
+			i, ok := index[variant]
+			if !ok {
+				continue
+			}
+			stats[i].add(run)
+		}
+	}
+	for i := range stats {
+		stats[i].finish()
+	}
+
+	leader := -1
+	for i, st := range stats {
+		if st.Scored == 0 {
+			continue
+		}
+		if leader < 0 || st.Mean > stats[leader].Mean {
+			leader = i
+		}
+	}
+	ready := leader >= 0 && len(stats) > 1
+	if leader >= 0 {
+		out.Leader = stats[leader].Variant
+		out.Confidence = 1
+		for i, st := range stats {
+			if st.Scored < exp.MinSamples {
+				ready = false
+			}
+			if i != leader {
+				out.Confidence = math.Min(out.Confidence, beatsConfidence(stats[leader], st))
+			}
+		}
+		out.Confidence = round2(out.Confidence)
+	}
+	out.Ready = ready && out.Confidence >= exp.Confidence
+	out.Variants = stats
+	return out
+}
+
+func (st *promptVariantStats) add(run ccrun.Run) {
+	st.Runs++
+	var up, down int
+	for _, fb := range run.Feedback {
+		if fb.Rating > 0 {
+			up++
+		} else {
+			down++
+		}
+	}
+	st.FeedbackUp += up
+	st.FeedbackDown += down
+	var judge float64
+	hasJudge := false
+	if n := len(run.Scores); n > 0 {
+		latest := run.Scores[0]
+		for _, sc := range run.Scores[1:] {
+			if sc.ScoredAt.After(latest.ScoredAt) {
+				latest = sc
+			}
+		}
+		judge, hasJudge = latest.Score, true
+		st.judgeSum += judge
+		st.judgeCount++
+	}
+	switch {
+	case up+down > 0:
+		st.outcomes = append(st.outcomes, float64(up)/float64(up+down))
+	case hasJudge:
+		st.outcomes = append(st.outcomes, math.Max(0, math.Min(1, judge/10)))
+	}
+}
+
+func (st *promptVariantStats) finish() {
+	st.Scored = len(st.outcomes)
+	if st.judgeCount > 0 {
+		avg := round2(st.judgeSum / float64(st.judgeCount))
+		st.AvgJudgeScore = &avg
+	}
+	if st.Scored == 0 {
+		return
+	}
+	var sum float64
+	for _, v := range st.outcomes {
+		sum += v
+	}
+	mean := sum / float64(st.Scored)
+	var sq float64
+	for _, v := range st.outcomes {
+		sq += (v - mean) * (v - mean)
+	}
+	st.Mean = round4(mean)
+	if st.Scored > 1 {
+		st.StdDev = round4(math.Sqrt(sq / float64(st.Scored-1)))
+	}
+	st.outcomes = nil
+}
+
+// beatsConfidence is P(a's true mean > b's) under a normal approximation.
+func beatsConfidence(a, b promptVariantStats) float64 {
+	if a.Scored == 0 || b.Scored == 0 {
+		return 0
+	}
+	diff := a.Mean - b.Mean
+	se := math.Sqrt(a.StdDev*a.StdDev/float64(a.Scored) + b.StdDev*b.StdDev/float64(b.Scored))
+	if se == 0 {
+		switch {
+		case diff > 0:
+			return 1
+		case diff < 0:
+			return 0
+		}
+		return 0.5
+	}
+	return 0.5 * (1 + math.Erf(diff/se/math.Sqrt2))
+}
+
+func round2(v float64) float64 { return math.Round(v*100) / 100 }
+
+func round4(v float64) float64 { return math.Round(v*10000) / 10000 }
+
+// maybePromotePromptVariant promotes the leader of mode's experiment once
+// the report says it is ready and auto-promotion is on.
+func (s *server) maybePromotePromptVariant(mode string) {
+	if s.settings == nil {
+		return
+	}
+	exp, ok := s.settings.PromptExperiment(mode)
+	if !ok || !exp.AutoPromote || exp.Promoted != "" {
+		return
+	}
+	report := s.promptExperimentReport(normalizePromptMode(mode), exp)
+	if !report.Ready {
+		return
+	}
+	if err := s.promotePromptVariant(report.Mode, report.Leader); err != nil {
+		return
+	}
+	s.appendEvent(ccevent.AppendInput{
+		EventType: "prompt_experiment.promoted",
+		Data: map[string]any{
+			"mode":       report.Mode,
+			"variant":    report.Leader,
+			"confidence": report.Confidence,
+			"auto":       true,
+		},
+	})
+	if s.notifications != nil {
+		s.notifications.Raise(notification.RaiseInput{
+			Key:      "prompt_experiment:" + report.Mode,
+			Kind:     notifyPromptPromoted,
+			Severity: notification.SeverityInfo,
+			Title:    "Prompt variant " + report.Leader + " promoted for " + report.Mode,
+			Message:  fmt.Sprintf("Variant %q beat every rival with %.0f%% confidence and now takes all %s traffic.", report.Leader, report.Confidence*100, report.Mode),
+			Data: map[string]any{
+				"mode":       report.Mode,
+				"variant":    report.Leader,
+				"confidence": report.Confidence,
+			},
+		})
+	}
+}
+
+// promotePromptVariant stores variant as mode's promoted variant; an empty
+// variant resumes the traffic split.
+func (s *server) promotePromptVariant(mode, variant string) error {
+	cfg := s.settings.Get()
+	exp, ok := cfg.PromptExperiments[mode]
+	if !ok {
+		return fmt.Errorf("no prompt experiment for mode %q", mode)
+	}
+	if variant != "" {
+		if _, ok := exp.Variant(variant); !ok {
+			return fmt.Errorf("prompt experiment %q has no variant %q", mode, variant)
+		}
+	}
+	exp.Promoted = variant
+	cfg.PromptExperiments[mode] = exp
+	s.settings.Put(cfg)
+	return nil
+}
+
+func (s *server) handleAdminPromptExperiments(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	if r.Method != http.MethodGet {
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+		return
+	}
+	if s.settings == nil {
+		s.writeError(w, http.StatusNotImplemented, "api_error", "settings store is not configured")
+		return
+	}
+	filter := strings.TrimSpace(r.URL.Query().Get("mode"))
+	if filter != "" {
+		filter = normalizePromptMode(filter)
+	}
+	for mode := range s.settings.Get().PromptExperiments {
+		if filter == "" || mode == filter {
+			s.maybePromotePromptVariant(mode)
+		}
+	}
+	items := []promptExperimentReport{}
+	for mode, exp := range s.settings.Get().PromptExperiments {
+		if filter != "" && mode != filter {
+			continue
+		}
+		items = append(items, s.promptExperimentReport(mode, exp))
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Mode < items[j].Mode })
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(map[string]any{"data": items})
+}
+
+func (s *server) handleAdminPromptExperimentByPath(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	if s.settings == nil {
+		s.writeError(w, http.StatusNotImplemented, "api_error", "settings store is not configured")
+		return
+	}
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/prompt-experiments/"), "/")
+	mode, action, _ := strings.Cut(path, "/")
+	if mode == "" || action != "promote" {
+		s.writeError(w, http.StatusNotFound, "not_found_error", "prompt experiment endpoint not found")
+		return
+	}
+	if r.Method != http.MethodPost {
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+		return
+	}
+	var req struct {
+		Variant string `json:"variant"`
+	}
+	if err := decodeJSONBodyStrict(r, &req, true); err != nil {
+		s.reportRequestDecodeIssue(r, err)
+		s.writeError(w, http.StatusBadRequest, "invalid_request_error", "invalid JSON body")
+		return
+	}
+	mode = normalizePromptMode(mode)
+	variant := strings.TrimSpace(req.Variant)
+	if err := s.promotePromptVariant(mode, variant); err != nil {
+		s.writeError(w, http.StatusNotFound, "not_found_error", err.Error())
+		return
+	}
+	s.appendEvent(ccevent.AppendInput{
+		EventType: "prompt_experiment.promoted",
+		Data: map[string]any{
+			"mode":    mode,
+			"variant": variant,
+			"auto":    false,
+		},
+	})
+	exp := s.settings.Get().PromptExperiments[mode]
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(s.promptExperimentReport(mode, exp))
+}
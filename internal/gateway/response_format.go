@@ -0,0 +1,148 @@
+package gateway
+
+import (
+	"fmt"
+	"strings"
+
+	"ccgateway/internal/ccevent"
+	"ccgateway/internal/orchestrator"
+	"ccgateway/internal/structured"
+)
+
+func outputFormatFromOpenAI(in *OpenAIResponseFormat) *OutputFormat {
+	if in == nil {
+		return nil
+	}
+	out := &OutputFormat{Type: in.Type}
+	if in.JSONSchema != nil {
+		out.Name = in.JSONSchema.Name
+		out.Schema = in.JSONSchema.Schema
+		out.Strict = in.JSONSchema.Strict
+	}
+	return out
+}
+
+func outputFormatFromResponsesText(in *OpenAIResponsesText) *OutputFormat {
+	if in == nil || in.Format == nil {
+		return nil
+	}
+	return &OutputFormat{
+		Type:   in.Format.Type,
+		Name:   in.Format.Name,
+		Schema: in.Format.Schema,
+		Strict: in.Format.Strict,
+	}
+}
+
+func validateOutputFormat(f *OutputFormat) error {
+	if f == nil {
+		return nil
+	}
+	switch strings.ToLower(strings.TrimSpace(f.Type)) {
+	case "", "text", "json_object":
+		return nil
+	case "json_schema":
+		if len(f.Schema) == 0 {
+			return fmt.Errorf("response_format json_schema requires a schema")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported response_format type %q", f.Type)
+	}
+}
+
+func toCanonicalResponseFormat(f *OutputFormat) *orchestrator.ResponseFormat {
+	if f == nil {
+		return nil
+	}
+	kind := strings.ToLower(strings.TrimSpace(f.Type))
+	if kind == "" || kind == "text" {
+		return nil
+	}
+	return &orchestrator.ResponseFormat{
+		Type:   kind,
+		Name:   strings.TrimSpace(f.Name),
+		Schema: f.Schema,
+		Strict: f.Strict,
+	}
+}
+
+// applyResponseFormatRepair validates the text of a non-streamed response
+// that was asked to be JSON. Invalid JSON is repaired in place when a cheap
+// deterministic fix exists (format.repair_applied); anything still invalid or
+// not matching the schema is recorded as format.validation_failed.
+func (s *server) applyResponseFormatRepair(sessionID, runID, path string, req orchestrator.Request, resp orchestrator.Response) orchestrator.Response {
+	format := req.ResponseFormat
+	if !format.WantsJSON() || len(toolUseBlocks(resp.Blocks)) > 0 {
+		return resp
+	}
+	text := collectResponseText(resp)
+	repaired, strategies, err := structured.Repair(text)
+	if err != nil {
+		s.appendEvent(ccevent.AppendInput{
+			EventType: "format.validation_failed",
+			SessionID: sessionID,
+			RunID:     runID,
+			Data: map[string]any{
+				"path":        path,
+				"format_type": format.Type,
+				"strategies":  strategies,
+				"errors":      []string{err.Error()},
+			},
+		})
+		return resp
+	}
+	if len(strategies) > 0 {
+		resp.Blocks = replaceTextBlocks(resp.Blocks, repaired)
+		s.appendEvent(ccevent.AppendInput{
+			EventType: "format.repair_applied",
+			SessionID: sessionID,
+			RunID:     runID,
+			Data: map[string]any{
+				"path":           path,
+				"format_type":    format.Type,
+				"strategies":     strategies,
+				"original_chars": len(text),
+				"repaired_chars": len(repaired),
+			},
+		})
+	}
+	if format.Type == "json_schema" {
+		if violations := structured.ValidateText(repaired, format.Schema); len(violations) > 0 {
+			s.appendEvent(ccevent.AppendInput{
+				EventType: "format.validation_failed",
+				SessionID: sessionID,
+				RunID:     runID,
+				Data: map[string]any{
+					"path":        path,
+					"format_type": format.Type,
+					"schema_name": format.Name,
+					"errors":      violations,
+				},
+			})
+		}
+	}
+	return resp
+}
+
+// replaceTextBlocks collapses all text blocks into a single block holding
+// text, at the position of the first one; other blocks are kept in order.
+func replaceTextBlocks(blocks []orchestrator.AssistantBlock, text string) []orchestrator.AssistantBlock {
+	out := make([]orchestrator.AssistantBlock, 0, len(blocks))
+	placed := false
+	for _, b := range blocks {
+		if b.Type != "text" {
+			out = append(out, b)
+			continue
+		}
+		if !placed {
+			b.Text = text
+			out = append(out, b)
+			placed = true
+		}
+	}
+	if !placed {
+		out = append(out, orchestrator.AssistantBlock{Type: "text", Text: text})
+	}
+	return out
+}
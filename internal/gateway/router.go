@@ -4,33 +4,57 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
+	"log/slog"
 	"net/http"
 	"strings"
-	"sync/atomic"
 	"time"
 
 	"ccgateway/internal/agentteam"
+	"ccgateway/internal/anomaly"
 	"ccgateway/internal/auth"
+	"ccgateway/internal/batch"
+	"ccgateway/internal/billing"
 	"ccgateway/internal/ccevent"
 	"ccgateway/internal/ccrun"
 	"ccgateway/internal/channel"
+	"ccgateway/internal/configfile"
+	"ccgateway/internal/cron"
+	"ccgateway/internal/dataprotect"
+	"ccgateway/internal/degrade"
+	"ccgateway/internal/egress"
 	"ccgateway/internal/eval"
+	"ccgateway/internal/featureflag"
+	"ccgateway/internal/files"
+	"ccgateway/internal/guardrail"
+	"ccgateway/internal/idgen"
+	"ccgateway/internal/imageproc"
+	"ccgateway/internal/ipaccess"
+	"ccgateway/internal/logging"
 	"ccgateway/internal/mcpregistry"
 	"ccgateway/internal/memory"
 	"ccgateway/internal/modelmap"
+	"ccgateway/internal/notification"
 	"ccgateway/internal/orchestrator"
 	"ccgateway/internal/plan"
 	"ccgateway/internal/plugin"
 	"ccgateway/internal/policy"
+	"ccgateway/internal/project"
 	"ccgateway/internal/runlog"
+	"ccgateway/internal/secretscan"
+	"ccgateway/internal/servertools"
 	"ccgateway/internal/session"
 	"ccgateway/internal/settings"
+	"ccgateway/internal/statepersist"
 	"ccgateway/internal/subagent"
 	"ccgateway/internal/todo"
 	"ccgateway/internal/token"
+	"ccgateway/internal/tokenizer"
 	"ccgateway/internal/toolcatalog"
 	"ccgateway/internal/toolruntime"
+	"ccgateway/internal/trafficsample"
+	"ccgateway/internal/upstream"
+	"ccgateway/internal/webhook"
+	"ccgateway/internal/workspace"
 )
 
 type Dependencies struct {
@@ -49,6 +73,7 @@ type Dependencies struct {
 	SubagentStore      SubagentStore
 	MCPRegistry        MCPRegistry
 	PluginStore        PluginStore
+	PluginMiddleware   *plugin.Executor
 	MarketplaceService MarketplaceService
 	SkillEngine        SkillEngine
 	CostTracker        CostTracker
@@ -59,15 +84,59 @@ type Dependencies struct {
 	RunLogger          runlog.Logger
 	MemoryStore        memory.MemoryStore
 	Summarizer         memory.Summarizer
+	Tokenizers         *tokenizer.Registry
 	AuthService        auth.Service
 	TokenService       token.Service
 	ChannelStore       ChannelStore
+	ChannelHealth      *channel.HealthTracker
+	TrafficSampler     *trafficsample.Sampler
+	EgressPolicy       *egress.Policy
+	IPAccess           *ipaccess.Policy
+	Anomalies          *anomaly.Detector
+	ImageProcessor     *imageproc.Processor
+	ServerTools        []servertools.Tool
+	Workspaces         *workspace.Manager
+	ToolState          *toolruntime.StateStore
+	Persistence        PersistenceHealth
+	AdminAudit         AdminAuditLog
+	FeatureFlags       *featureflag.Store
+	UsageLedger        *billing.Ledger
+	ConfigReloader     ConfigReloader
+	DataKeys           *dataprotect.Keyring
+	Notifications      *notification.Center
+	Webhooks           *webhook.Dispatcher
+	Cron               *cron.Scheduler
+	Batches            *batch.Manager
+	Files              *files.Service
+	Projects           *project.Store
+	Guardrails         *guardrail.Engine
+	SecretScanner      *secretscan.Scanner
+	IDGenerator        idgen.Generator
+	// Logger receives gateway errors and diagnostics; defaults to the
+	// process logger tagged component=gateway.
+	Logger *slog.Logger
+	// TrashRetention is how long soft-deleted resources stay restorable
+	// (default 7 days).
+	TrashRetention time.Duration
+	// AsyncRuns sizes the worker pool behind ?async=true requests.
+	AsyncRuns AsyncRunConfig
+	// StreamResume keeps streamed responses resumable after a disconnect.
+	StreamResume StreamResumeConfig
+	// WireCapture keeps the upstream payloads of runs that ask for it.
+	WireCapture WireCaptureConfig
+	// SSE sets stream heartbeats and per-connection write timeouts.
+	SSE SSEConfig
 }
 
 type StatusProvider interface {
 	Snapshot() map[string]any
 }
 
+// PersistenceHealth reports whether state autosave is keeping up.
+type PersistenceHealth interface {
+	Health() statepersist.HealthStatus
+}
+
 type SessionStore interface {
 	Create(in session.CreateInput) (session.Session, error)
 	Fork(parentID string, in session.CreateInput) (session.Session, error)
@@ -165,6 +234,12 @@ type ChannelStore interface {
 	UpdateChannelStatus(id int64, status int) error
 }
 
+// ConfigReloader re-reads the config file and applies it.
+type ConfigReloader interface {
+	Reload(trigger string) (configfile.Result, error)
+	Status() map[string]any
+}
+
 type ToolCatalogStore interface {
 	Snapshot() []toolcatalog.ToolSpec
 	Replace([]toolcatalog.ToolSpec)
@@ -177,6 +252,7 @@ type server struct {
 	settings           *settings.Store
 	toolCatalog        ToolCatalogStore
 	toolExecutor       toolruntime.Executor
+	toolState          *toolruntime.StateStore
 	sessionStore       SessionStore
 	runStore           RunStore
 	todoStore          TodoStore
@@ -186,20 +262,55 @@ type server struct {
 	subagentStore      SubagentStore
 	mcpRegistry        MCPRegistry
 	pluginStore        PluginStore
+	pluginMiddleware   *plugin.Executor
 	marketplaceService MarketplaceService
 	skillEngine        SkillEngine
 	costTracker        CostTracker
 	evaluator          *eval.Evaluator
+	rescorer           *eval.Rescorer
 	schedulerStatus    StatusProvider
 	probeStatus        StatusProvider
 	adminToken         string
 	runLogger          runlog.Logger
 	memoryStore        memory.MemoryStore
 	summarizer         memory.Summarizer
+	tokenizers         *tokenizer.Registry
+	memoryWork         *sessionMemoryWork
 	authService        auth.Service
 	tokenService       token.Service
 	channelStore       ChannelStore
-	idCounter          uint64
+	channelHealth      *channel.HealthTracker
+	trafficSampler     *trafficsample.Sampler
+	egressPolicy       *egress.Policy
+	ipAccess           *ipaccess.Policy
+	anomalies          *anomaly.Detector
+	imageProcessor     *imageproc.Processor
+	persistence        PersistenceHealth
+	adminAudit         AdminAuditLog
+	featureFlags       *featureflag.Store
+	usageLedger        *billing.Ledger
+	configReloader     ConfigReloader
+	dataKeys           *dataprotect.Keyring
+	loadMonitor        *degrade.Monitor
+	shedCounts         *shedCounter
+	notifications      *notification.Center
+	notifyWatch        *notificationWatch
+	webhooks           *webhook.Dispatcher
+	cron               *cron.Scheduler
+	batches            *batch.Manager
+	files              *files.Service
+	projects           *project.Store
+	guardrails         *guardrail.Engine
+	secretScanner      *secretscan.Scanner
+	shadowStats        *shadowStats
+	ids                idgen.Generator
+	trash              *trashBin
+	asyncRuns          *asyncRunQueue
+	streamBuffers      *streamBuffers
+	wireCaptures       *wireCaptures
+	sseConfig          SSEConfig
+	toolApprovals      *toolApprovals
+	logger             *slog.Logger
 }
 
 func NewRouter(deps Dependencies) http.Handler {
@@ -213,7 +324,49 @@ func NewRouter(deps Dependencies) http.Handler {
 		deps.ModelMapper = modelmap.NewIdentityMapper()
 	}
 	if deps.ToolExecutor == nil {
-		deps.ToolExecutor = newMCPAwareExecutor(toolruntime.NewDefaultExecutor(), deps.MCPRegistry)
+		// First-party server tools take precedence over the built-in
+		// handlers; anything still unimplemented falls through to MCP.
+		local := toolruntime.NewDefaultExecutor()
+		servertools.Register(local, deps.ServerTools...)
+		deps.ToolExecutor = newMCPAwareExecutor(local, deps.MCPRegistry)
+	}
+
+	if deps.Anomalies == nil {
+		deps.Anomalies = anomaly.NewDetector(anomaly.DefaultConfig())
+	}
+	if deps.IDGenerator == nil {
+		deps.IDGenerator = idgen.NewULID()
+	}
+	if deps.IPAccess == nil {
+		// No trusted proxies and no lists: every client is admitted and
+		// identified by its peer address.
+		deps.IPAccess, _ = ipaccess.NewPolicy(ipaccess.Config{})
+	}
+	if deps.Logger == nil {
+		deps.Logger = logging.Component("gateway")
+	}
+	if deps.Notifications == nil {
+		deps.Notifications = notification.NewCenter()
+	}
+	if deps.ToolState == nil {
+		deps.ToolState = toolruntime.NewStateStore(0)
+	}
+	if deps.PluginMiddleware == nil {
+		deps.PluginMiddleware = plugin.NewExecutor()
+	}
+	if releaser, ok := deps.MCPRegistry.(interface {
+		ReleaseToolSession(ctx context.Context, sessionID string) int
+	}); ok {
+		deps.ToolState.OnRelease(func(sessionID string) {
+			releaser.ReleaseToolSession(context.Background(), sessionID)
+		})
+	}
+	if deps.Workspaces != nil {
+		// Workspaces are scratch space: they go with the session's tool
+		// state, on release or idle expiry.
+		deps.ToolState.OnRelease(func(sessionID string) {
+			_ = deps.Workspaces.Release(sessionID)
+		})
 	}
 
 	s := &server{
@@ -223,6 +376,7 @@ func NewRouter(deps Dependencies) http.Handler {
 		settings:           deps.Settings,
 		toolCatalog:        deps.ToolCatalog,
 		toolExecutor:       deps.ToolExecutor,
+		toolState:          deps.ToolState,
 		sessionStore:       deps.SessionStore,
 		runStore:           deps.RunStore,
 		todoStore:          deps.TodoStore,
@@ -232,6 +386,7 @@ func NewRouter(deps Dependencies) http.Handler {
 		subagentStore:      deps.SubagentStore,
 		mcpRegistry:        deps.MCPRegistry,
 		pluginStore:        deps.PluginStore,
+		pluginMiddleware:   deps.PluginMiddleware,
 		marketplaceService: deps.MarketplaceService,
 		skillEngine:        deps.SkillEngine,
 		costTracker:        deps.CostTracker,
@@ -242,20 +397,98 @@ func NewRouter(deps Dependencies) http.Handler {
 		runLogger:          deps.RunLogger,
 		memoryStore:        deps.MemoryStore,
 		summarizer:         deps.Summarizer,
+		memoryWork:         newSessionMemoryWork(),
+		tokenizers:         deps.Tokenizers,
 		authService:        deps.AuthService,
 		tokenService:       deps.TokenService,
 		channelStore:       deps.ChannelStore,
+		channelHealth:      deps.ChannelHealth,
+		trafficSampler:     deps.TrafficSampler,
+		egressPolicy:       deps.EgressPolicy,
+		ipAccess:           deps.IPAccess,
+		anomalies:          deps.Anomalies,
+		imageProcessor:     deps.ImageProcessor,
+		persistence:        deps.Persistence,
+		adminAudit:         deps.AdminAudit,
+		featureFlags:       deps.FeatureFlags,
+		usageLedger:        deps.UsageLedger,
+		configReloader:     deps.ConfigReloader,
+		dataKeys:           deps.DataKeys,
+		loadMonitor:        degrade.NewMonitor(),
+		shedCounts:         newShedCounter(),
+		notifications:      deps.Notifications,
+		notifyWatch:        newNotificationWatch(),
+		webhooks:           deps.Webhooks,
+		cron:               deps.Cron,
+		batches:            deps.Batches,
+		files:              deps.Files,
+		projects:           deps.Projects,
+		guardrails:         deps.Guardrails,
+		secretScanner:      deps.SecretScanner,
+		shadowStats:        newShadowStats(),
+		ids:                deps.IDGenerator,
+		trash:              newTrashBin(deps.TrashRetention),
+		asyncRuns:          newAsyncRunQueue(deps.AsyncRuns),
+		streamBuffers:      newStreamBuffers(deps.StreamResume),
+		wireCaptures:       newWireCaptures(deps.WireCapture),
+		sseConfig:          deps.SSE,
+		toolApprovals:      newToolApprovals(),
+		logger:             deps.Logger,
+	}
+	s.notifyDefaultAdminToken()
+	if deps.Cron != nil {
+		deps.Cron.SetExecutor(s.executeCronJob)
+	}
+	if deps.Batches != nil {
+		deps.Batches.SetExecutor(s.executeBatchRequest)
+	}
+
+	if notifier, ok := deps.ConfigReloader.(interface {
+		OnReload(fn func(configfile.Result))
+	}); ok {
+		notifier.OnReload(s.appendConfigReloadEvent)
+	}
+	if notifier, ok := deps.Orchestrator.(interface {
+		OnUpstreamAttempt(fn func(adapter string, err error))
+	}); ok {
+		notifier.OnUpstreamAttempt(s.observeUpstreamAttempt)
+	}
+	if notifier, ok := deps.Orchestrator.(interface {
+		OnShadowResult(fn func(upstream.ShadowResult))
+	}); ok {
+		notifier.OnShadowResult(s.recordShadowResult)
+	}
+	if notifier, ok := deps.TokenService.(interface {
+		OnQuotaThreshold(fn func(token.ThresholdEvent))
+	}); ok {
+		notifier.OnQuotaThreshold(s.appendQuotaThresholdEvent)
+	}
+
+	if deps.Evaluator != nil {
+		if runs, ok := deps.RunStore.(eval.RunScoreStore); ok {
+			s.rescorer = eval.NewRescorer(runs, deps.Evaluator)
+		}
 	}
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", s.handleRootHome)
 	mux.HandleFunc("/home", s.handleRootHome)
 	mux.HandleFunc("/healthz", s.handleHealthz)
+	mux.HandleFunc("/readyz", s.handleReadyz)
 	// Messages API - Authenticated & Quota Managed
-	mux.HandleFunc("/v1/messages", s.withAuth(s.withTokenQuota(s.handleMessages)))
+	mux.HandleFunc("/v1/messages", s.withAuth(s.withTokenQuota(s.withAsyncRun(s.withLoadTracking(s.handleMessages)))))
 	mux.HandleFunc("/v1/messages/count_tokens", s.withAuth(s.handleCountTokens))
-	mux.HandleFunc("/v1/chat/completions", s.withAuth(s.withTokenQuota(s.handleOpenAIChatCompletions)))
-	mux.HandleFunc("/v1/responses", s.withAuth(s.withTokenQuota(s.handleOpenAIResponses)))
+	mux.HandleFunc("/v1/messages/stream/", s.withAuth(s.handleMessagesStreamResume))
+	mux.HandleFunc("/v1/chat/completions", s.withAuth(s.withTokenQuota(s.withAsyncRun(s.withLoadTracking(s.handleOpenAIChatCompletions)))))
+	mux.HandleFunc("/v1/responses", s.withAuth(s.withTokenQuota(s.withAsyncRun(s.withLoadTracking(s.handleOpenAIResponses)))))
+	mux.HandleFunc("/v1/batches", s.withAuth(s.handleBatches))
+	mux.HandleFunc("/v1/batches/", s.withAuth(s.handleBatchByPath))
+	mux.HandleFunc("/v1/files", s.withAuth(s.handleFiles))
+	mux.HandleFunc("/v1/files/", s.withAuth(s.handleFileByPath))
+	mux.HandleFunc("/v1/models", s.withAuth(s.handleModels))
+	mux.HandleFunc("/v1/models/", s.withAuth(s.handleModelByPath))
+	mux.HandleFunc("/v1/me/preferences", s.withAuth(s.handleMePreferences))
+	mux.HandleFunc("/mcp", s.withAuth(s.handleMCPServer))
 
 	// CC System API - Authenticated
 	// Sessions
@@ -281,14 +514,49 @@ func NewRouter(deps Dependencies) http.Handler {
 	mux.HandleFunc("/admin/settings", s.handleAdminSettings)
 	mux.HandleFunc("/admin/model-mapping", s.handleAdminModelMapping)
 	mux.HandleFunc("/admin/upstream", s.handleAdminUpstream)
+	mux.HandleFunc("/admin/upstream/status", s.handleAdminUpstreamStatus)
+	mux.HandleFunc("/admin/upstream/capabilities", s.handleAdminUpstreamCapabilities)
+	mux.HandleFunc("/admin/upstream/connections", s.handleAdminUpstreamConnections)
 	mux.HandleFunc("/admin/capabilities", s.handleAdminCapabilities)
 	mux.HandleFunc("/v1/cc/skills", s.withAuth(s.handleCCSkills))
 	mux.HandleFunc("/v1/cc/skills/", s.withAuth(s.handleCCSkillByPath))
 	mux.HandleFunc("/admin/tools/gaps", s.handleAdminToolGaps)
 	mux.HandleFunc("/admin/tools", s.handleAdminTools)
+	mux.HandleFunc("/admin/policy/test", s.handleAdminPolicyTest)
 	mux.HandleFunc("/admin/scheduler", s.handleAdminScheduler)
 	mux.HandleFunc("/admin/intelligent-dispatch", s.handleAdminIntelligentDispatch)
+	mux.HandleFunc("/admin/analytics", s.handleAdminAnalytics)
+	mux.HandleFunc("/admin/dispatch/analytics", s.handleAdminDispatchAnalytics)
+	mux.HandleFunc("/admin/routing/explain", s.handleAdminRoutingExplain)
+	mux.HandleFunc("/admin/judge/report", s.handleAdminJudgeReport)
+	mux.HandleFunc("/admin/judge/verdicts", s.handleAdminJudgeVerdicts)
+	mux.HandleFunc("/admin/judge/rubric", s.handleAdminJudgeRubric)
+	mux.HandleFunc("/admin/usage", s.handleAdminUsage)
+	mux.HandleFunc("/admin/usage/prices", s.handleAdminUsagePrices)
+	mux.HandleFunc("/admin/config/reload", s.handleAdminConfigReload)
+	mux.HandleFunc("/admin/config/export", s.handleAdminConfigExport)
+	mux.HandleFunc("/admin/config/import", s.handleAdminConfigImport)
+	mux.HandleFunc("/admin/data/export", s.handleAdminDataExport)
+	mux.HandleFunc("/admin/data/delete", s.handleAdminDataDelete)
+	mux.HandleFunc("/admin/shadow", s.handleAdminShadow)
+	mux.HandleFunc("/admin/notifications", s.handleAdminNotifications)
+	mux.HandleFunc("/admin/notifications/", s.handleAdminNotificationByPath)
+	mux.HandleFunc("/admin/webhooks", s.handleAdminWebhooks)
+	mux.HandleFunc("/admin/webhooks/", s.handleAdminWebhookByPath)
+	mux.HandleFunc("/admin/projects", s.handleAdminProjects)
+	mux.HandleFunc("/admin/projects/", s.handleAdminProjectByPath)
+	mux.HandleFunc("/admin/guardrails", s.handleAdminGuardrails)
+	mux.HandleFunc("/admin/guardrails/test", s.handleAdminGuardrailsTest)
+	mux.HandleFunc("/admin/secret-scan", s.handleAdminSecretScan)
+	mux.HandleFunc("/admin/cron", s.handleAdminCron)
+	mux.HandleFunc("/admin/cron/", s.handleAdminCronByPath)
+	mux.HandleFunc("/admin/trash", s.handleAdminTrash)
+	mux.HandleFunc("/admin/trash/", s.handleAdminTrashByPath)
 	mux.HandleFunc("/admin/probe", s.handleAdminProbe)
+	mux.HandleFunc("/admin/probe/tasks", s.handleAdminProbeTasks)
+	mux.HandleFunc("/admin/intelligence", s.handleAdminIntelligence)
+	mux.HandleFunc("/admin/prompt-experiments", s.handleAdminPromptExperiments)
+	mux.HandleFunc("/admin/prompt-experiments/", s.handleAdminPromptExperimentByPath)
 	mux.HandleFunc("/admin/bootstrap/apply", s.handleAdminBootstrapApply)
 	mux.HandleFunc("/admin/marketplace/cloud/list", s.handleAdminMarketplaceCloudList)
 	mux.HandleFunc("/admin/marketplace/cloud/install", s.handleAdminMarketplaceCloudInstall)
@@ -298,11 +566,36 @@ func NewRouter(deps Dependencies) http.Handler {
 	mux.HandleFunc("/admin/auth/tokens/", s.handleAdminTokenByPath) // Individual token operations
 	mux.HandleFunc("/admin/channels", s.handleAdminChannels)        // List/Create channels
 	mux.HandleFunc("/admin/channels/", s.handleAdminChannelByPath)  // Channel CRUD operations
+	mux.HandleFunc("/api/channel", s.handleOneAPIChannel)           // one-api compatible management API
+	mux.HandleFunc("/api/channel/", s.handleOneAPIChannel)
+	mux.HandleFunc("/api/token", s.handleOneAPIToken)
+	mux.HandleFunc("/api/token/", s.handleOneAPIToken)
+	mux.HandleFunc("/api/user", s.handleOneAPIUser)
+	mux.HandleFunc("/api/user/", s.handleOneAPIUser)
 	mux.HandleFunc("/admin/cost", s.handleAdminCost)
+	mux.HandleFunc("/admin/runs", s.handleAdminRuns)
+	mux.HandleFunc("/admin/runs/search", s.handleAdminRunSearch)
+	mux.HandleFunc("/admin/runs/rescore", s.handleAdminRescore)
+	mux.HandleFunc("/admin/mcp/sync", s.handleAdminMCPSync)
+	mux.HandleFunc("/admin/runs/rescore/", s.handleAdminRescoreByPath)
+	mux.HandleFunc("/admin/runs/", s.handleAdminRunByPath)
+	mux.HandleFunc("/admin/wire-capture", s.handleAdminWireCapture)
+	mux.HandleFunc("/admin/traffic/config", s.handleAdminTrafficConfig)
+	mux.HandleFunc("/admin/traffic/stream", s.handleAdminTrafficStream)
+	mux.HandleFunc("/admin/egress", s.handleAdminEgress)
+	mux.HandleFunc("/admin/ip-access", s.handleAdminIPAccess)
+	mux.HandleFunc("/admin/vision/images", s.handleAdminVisionImages)
 	mux.HandleFunc("/admin/status", s.handleAdminStatus)
+	mux.HandleFunc("/admin/doctor", s.handleAdminDoctor)
+	mux.HandleFunc("/admin/persistence/repairs", s.handleAdminPersistenceRepairs)
+	mux.HandleFunc("/admin/audit", s.handleAdminAudit)
+	mux.HandleFunc("/admin/events", s.handleAdminEvents)
+	mux.HandleFunc("/admin/events/stream", s.handleAdminEventsStream)
+	mux.HandleFunc("/admin/flags", s.handleAdminFlags)
+	mux.HandleFunc("/admin/flags/", s.handleAdminFlagByPath)
 	mux.HandleFunc("/admin/", s.handleAdminDashboard)
 	mux.HandleFunc("/v1/cc/eval", s.withAuth(s.handleCCEval))
-	return withCommonHeaders(withProjectContext(mux))
+	return withCommonHeaders(s.withIPAccess(s.withOfflineHeader(withProjectContext(s.withPersistenceGuard(s.withAdminAudit(mux))))))
 }
 
 func withCommonHeaders(next http.Handler) http.Handler {
@@ -313,11 +606,11 @@ func withCommonHeaders(next http.Handler) http.Handler {
 }
 
 func (s *server) nextID(prefix string) string {
-	n := atomic.AddUint64(&s.idCounter, 1)
-	return fmt.Sprintf("%s_%d_%x", prefix, time.Now().Unix(), n)
+	return s.ids.New(prefix)
 }
 
 func (s *server) writeError(w http.ResponseWriter, status int, kind, message string) {
+	s.logError(w, status, kind, message)
 	w.Header().Set("content-type", "application/json")
 	w.WriteHeader(status)
 	_ = json.NewEncoder(w).Encode(ErrorEnvelope{
@@ -338,6 +631,19 @@ func requireAnthropicVersion(r *http.Request) error {
 
 func (s *server) handleHealthz(w http.ResponseWriter, _ *http.Request) {
 	w.Header().Set("content-type", "application/json")
+	if s.persistence == nil {
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`{"ok":true}`))
+		return
+	}
+	// Liveness stays green while degraded: restarting would drop the state
+	// that failed to persist.
+	health := s.persistence.Health()
 	w.WriteHeader(http.StatusOK)
-	_, _ = w.Write([]byte(`{"ok":true}`))
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"ok":          true,
+		"ready":       !health.Degraded,
+		"degraded":    health.Degraded,
+		"persistence": health,
+	})
 }
@@ -0,0 +1,168 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"ccgateway/internal/orchestrator"
+	"ccgateway/internal/upstream"
+)
+
+// routingExplainRequest is the sample request to explain. Headers carries
+// the routing headers a client would send (x-cc-mode, x-cc-adapter,
+// x-cc-exclude-adapters).
+type routingExplainRequest struct {
+	Model    string            `json:"model"`
+	Mode     string            `json:"mode,omitempty"`
+	Stream   bool              `json:"stream,omitempty"`
+	Tools    []ToolDefinition  `json:"tools,omitempty"`
+	Messages []MessageParam    `json:"messages,omitempty"`
+	Metadata map[string]any    `json:"metadata,omitempty"`
+	Headers  map[string]string `json:"headers,omitempty"`
+}
+
+// routingExplainStep is one stage of the routing decision. Applied is set
+// when the stage changed the model or the route.
+type routingExplainStep struct {
+	Step    string         `json:"step"`
+	Applied bool           `json:"applied"`
+	Detail  map[string]any `json:"detail,omitempty"`
+}
+
+// handleAdminRoutingExplain runs a sample request through model mapping,
+// the mode route, channel policy, route overrides, the dispatcher and the
+// capability fallbacks, and reports every decision without calling any
+// upstream.
+// POST /admin/routing/explain
+func (s *server) handleAdminRoutingExplain(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	if r.Method != http.MethodPost {
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+		return
+	}
+	explainer, ok := s.orchestrator.(interface {
+		ExplainRoute(ctx context.Context, req orchestrator.Request, wantStream bool) upstream.RouteExplanation
+	})
+	if !ok {
+		s.writeError(w, http.StatusNotImplemented, "api_error", "routing explanation is not supported by the orchestrator")
+		return
+	}
+	var in routingExplainRequest
+	if err := decodeJSONBodyStrict(r, &in, false); err != nil {
+		s.reportRequestDecodeIssue(r, err)
+		s.writeError(w, http.StatusBadRequest, "invalid_request_error", "invalid JSON body")
+		return
+	}
+	if strings.TrimSpace(in.Model) == "" {
+		s.writeError(w, http.StatusBadRequest, "invalid_request_error", "model is required")
+		return
+	}
+
+	// The sample's headers stand in for the client's; the admin request's
+	// own headers take no part in routing.
+	probe := r.Clone(r.Context())
+	probe.Header = http.Header{}
+	for k, v := range in.Headers {
+		probe.Header.Set(k, v)
+	}
+	mode := strings.ToLower(strings.TrimSpace(in.Mode))
+	if mode == "" {
+		mode = requestMode(probe, in.Metadata)
+	}
+	ctx := r.Context()
+	var trace []routingExplainStep
+
+	metadata := s.applyRoutingPolicy(ctx, mode, in.Metadata)
+	modeRoute := routeFromMetadataLocal(metadata)
+	policyDetail := map[string]any{"mode": mode}
+	if len(modeRoute) > 0 {
+		policyDetail["route"] = modeRoute
+	}
+	for _, key := range []string{"canary_name", "canary_variant", "canary_cohort", "hedge_after_ms", "parallel_candidates", "routing_retries", "routing_timeout_ms"} {
+		if v, ok := metadata[key]; ok {
+			policyDetail[key] = v
+		}
+	}
+	trace = append(trace, routingExplainStep{Step: "mode_route", Applied: len(modeRoute) > 0, Detail: policyDetail})
+
+	requested, targets, err := s.resolveUpstreamTargets(ctx, mode, in.Model)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	model := targets[0]
+	mappingDetail := map[string]any{"client_model": in.Model, "mode_model": requested, "upstream_model": model}
+	if len(targets) > 1 {
+		mappingDetail["fallback_models"] = targets[1:]
+	}
+	trace = append(trace, routingExplainStep{Step: "model_mapping", Applied: model != in.Model || len(targets) > 1, Detail: mappingDetail})
+
+	metadata = applyModelFallbacks(metadata, targets)
+	metadata = s.applyChannelRoutePolicy(ctx, metadata, model)
+	channelStep := routingExplainStep{Step: "channel_policy"}
+	if source, _ := metadata["routing_route_source"].(string); source == "channel" {
+		channelStep.Applied = true
+		channelStep.Detail = map[string]any{"channel_id": metadata["routing_channel_id"], "route": routeFromMetadataLocal(metadata)}
+	}
+	trace = append(trace, channelStep)
+
+	metadata, named, err := s.applyAdapterOverride(probe, metadata)
+	if err != nil {
+		s.writeAdapterOverrideError(w, err)
+		return
+	}
+	overrideStep := routingExplainStep{Step: "adapter_override", Applied: len(named) > 0}
+	if len(named) > 0 {
+		overrideStep.Detail = map[string]any{"adapters": named}
+		if pinned, _ := metadata["routing_route_source"].(string); pinned == "pinned" {
+			overrideStep.Detail["pinned"] = routeFromMetadataLocal(metadata)[0]
+		}
+		if excluded := stringListFromMetadata(metadata, "routing_exclude_adapters"); len(excluded) > 0 {
+			overrideStep.Detail["excluded"] = excluded
+		}
+	}
+	trace = append(trace, overrideStep)
+
+	sample := MessagesRequest{Model: model, Messages: in.Messages, Tools: in.Tools, Stream: in.Stream, Metadata: metadata}
+	creq := toCanonicalRequest("", sample, probe)
+	route := explainer.ExplainRoute(ctx, creq, in.Stream)
+	trace = append(trace, routingExplainStep{Step: "dispatcher", Applied: route.Dispatch != nil, Detail: map[string]any{
+		"source":   route.Source,
+		"route":    route.Route,
+		"decision": route.Dispatch,
+	}})
+	trace = append(trace, routingExplainStep{Step: "adapter_selection", Applied: len(route.Candidates) > 0, Detail: map[string]any{
+		"candidates":  route.Candidates,
+		"unavailable": route.Unavailable,
+	}})
+
+	images := len(collectVisionMessageRefs(creq.Messages)) > 0
+	vision := images && s.shouldApplyVisionFallback(creq)
+	toolReason, toolFallback := s.toolSupportFallbackReason(creq)
+	capabilityDetail := map[string]any{
+		"images":          images,
+		"vision_fallback": vision,
+		"tools":           len(creq.Tools),
+		"tool_fallback":   toolFallback,
+	}
+	if toolFallback {
+		capabilityDetail["tool_fallback_reason"] = toolReason
+	}
+	trace = append(trace, routingExplainStep{Step: "capability_fallbacks", Applied: vision || toolFallback, Detail: capabilityDetail})
+
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"model":          in.Model,
+		"mode":           mode,
+		"stream":         in.Stream,
+		"upstream_model": model,
+		"adapters":       route.Candidates,
+		"route":          route,
+		"trace":          trace,
+	})
+}
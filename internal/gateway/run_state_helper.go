@@ -1,20 +1,46 @@
 package gateway
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
 
 	"ccgateway/internal/ccrun"
 )
 
 func runListFilterFromRequest(r *http.Request, limit int) ccrun.ListFilter {
 	return ccrun.ListFilter{
-		Limit:     limit,
-		SessionID: r.URL.Query().Get("session_id"),
-		Status:    r.URL.Query().Get("status"),
-		Path:      r.URL.Query().Get("path"),
+		Limit:         limit,
+		SessionID:     r.URL.Query().Get("session_id"),
+		Status:        r.URL.Query().Get("status"),
+		Path:          r.URL.Query().Get("path"),
+		CorrelationID: r.URL.Query().Get("correlation_id"),
 	}
 }
 
+// maxCorrelationIDLen bounds client-supplied correlation IDs.
+const maxCorrelationIDLen = 128
+
+// requestCorrelationID returns the caller's x-correlation-id, which is
+// stored on the run and echoed back. It must be 1-128 printable ASCII
+// characters without spaces; an absent header is not an error.
+func requestCorrelationID(r *http.Request) (string, error) {
+	id := strings.TrimSpace(r.Header.Get("x-correlation-id"))
+	if id == "" {
+		return "", nil
+	}
+	if len(id) > maxCorrelationIDLen {
+		return "", fmt.Errorf("x-correlation-id must be at most %d characters", maxCorrelationIDLen)
+	}
+	for _, c := range id {
+		if c <= ' ' || c > '~' {
+			return "", errors.New("x-correlation-id must be printable ASCII without spaces")
+		}
+	}
+	return id, nil
+}
+
 func (s *server) createRunIfConfigured(in ccrun.CreateInput) {
 	if s.runStore == nil {
 		return
@@ -22,12 +48,43 @@ func (s *server) createRunIfConfigured(in ccrun.CreateInput) {
 	_, _ = s.runStore.Create(in)
 }
 
-func (s *server) completeRunIfConfigured(runID string, statusCode int, errText string) {
+// completeRunIfConfigured closes the run record, keeping the prompt and output
+// text so the run can be re-scored later and the record text for search.
+func (s *server) completeRunIfConfigured(runID string, statusCode int, errText, promptText, outputText, recordText string) {
 	if s.runStore == nil {
 		return
 	}
 	_, _ = s.runStore.Complete(runID, ccrun.CompleteInput{
 		StatusCode: statusCode,
 		Error:      errText,
+		Prompt:     promptText,
+		Output:     outputText,
+		RecordText: recordText,
 	})
 }
+
+// recordRunAdapter notes which upstream adapter served the run, for run
+// search.
+func (s *server) recordRunAdapter(runID, adapter string) {
+	if runID == "" || adapter == "" {
+		return
+	}
+	if recorder, ok := s.runStore.(interface {
+		RecordAdapter(id, adapter string) (ccrun.Run, error)
+	}); ok {
+		_, _ = recorder.RecordAdapter(runID, adapter)
+	}
+}
+
+// recordToolLoopRounds stores on the run how many tool rounds the server
+// loop executed, as metadata tool_loop_rounds.
+func (s *server) recordToolLoopRounds(runID string, rounds int) {
+	if runID == "" || rounds == 0 {
+		return
+	}
+	if annotator, ok := s.runStore.(interface {
+		Annotate(id string, metadata map[string]any) (ccrun.Run, error)
+	}); ok {
+		_, _ = annotator.Annotate(runID, map[string]any{"tool_loop_rounds": rounds})
+	}
+}
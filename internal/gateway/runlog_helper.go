@@ -1,10 +1,43 @@
 package gateway
 
-import "ccgateway/internal/runlog"
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"ccgateway/internal/runlog"
+)
 
 func (s *server) logRun(entry runlog.Entry) {
 	if s.runLogger == nil {
 		return
 	}
-	_ = s.runLogger.Log(entry)
+	if s.secretScanner.MasksLogs() {
+		entry.RecordText, _ = s.secretScanner.Mask(entry.RecordText)
+		entry.Error, _ = s.secretScanner.Mask(entry.Error)
+		entry.RequestBody, _ = s.secretScanner.Mask(entry.RequestBody)
+		entry.CurlCommand, _ = s.secretScanner.Mask(entry.CurlCommand)
+	}
+	if err := s.runLogger.Log(entry); err != nil {
+		s.logger.Warn("run log write failed", "run_id", entry.RunID, "error", err)
+	}
+}
+
+// logError records an error answer: 5xx at error, the rest at warn. The
+// run is taken from the x-cc-run-id header when the handler already
+// created one.
+func (s *server) logError(w http.ResponseWriter, status int, kind, message string) {
+	level := slog.LevelWarn
+	if status >= http.StatusInternalServerError {
+		level = slog.LevelError
+	}
+	attrs := []slog.Attr{
+		slog.Int("status", status),
+		slog.String("error_type", kind),
+		slog.String("error", message),
+	}
+	if runID := w.Header().Get("x-cc-run-id"); runID != "" {
+		attrs = append(attrs, slog.String("run_id", runID))
+	}
+	s.logger.LogAttrs(context.Background(), level, "request failed", attrs...)
 }
@@ -1,12 +1,25 @@
 package gateway
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"strings"
+
+	"ccgateway/internal/ccevent"
+	"ccgateway/internal/ccrun"
+	"ccgateway/internal/modelmap"
+	"ccgateway/internal/settings"
+	"ccgateway/internal/upstream"
 )
 
 func requestMode(r *http.Request, metadata map[string]any) string {
+	return requestModeOr(r, metadata, "")
+}
+
+// requestModeOr resolves the mode from the x-cc-mode header or cc_mode
+// metadata, falling back to preferred (e.g. the user's default) and then chat.
+func requestModeOr(r *http.Request, metadata map[string]any, preferred string) string {
 	mode := strings.ToLower(strings.TrimSpace(r.Header.Get("x-cc-mode")))
 	if mode != "" {
 		return mode
@@ -19,46 +32,123 @@ func requestMode(r *http.Request, metadata map[string]any) string {
 			}
 		}
 	}
+	if preferred = strings.ToLower(strings.TrimSpace(preferred)); preferred != "" {
+		return preferred
+	}
 	return "chat"
 }
 
-func (s *server) resolveModelByMode(mode, requested string) string {
+func (s *server) resolveModelByMode(ctx context.Context, mode, requested string) string {
 	requested = strings.TrimSpace(requested)
-	if s.settings == nil {
+	cfg := s.settingsFor(ctx)
+	if cfg == nil {
 		return requested
 	}
-	return s.settings.ResolveModel(mode, requested)
+	return cfg.ResolveModel(mode, requested)
+}
+
+func (s *server) resolveUpstreamModel(ctx context.Context, mode, clientModel string) (string, string, error) {
+	requested, targets, err := s.resolveUpstreamTargets(ctx, mode, clientModel)
+	if err != nil {
+		return requested, "", err
+	}
+	return requested, targets[0], nil
 }
 
-func (s *server) resolveUpstreamModel(mode, clientModel string) (string, string, error) {
-	requested := s.resolveModelByMode(mode, clientModel)
+// resolveUpstreamTargets resolves clientModel to its upstream models. The
+// list has more than one entry when the model mapper maps it to an alias
+// group; later entries are fallbacks tried in order.
+func (s *server) resolveUpstreamTargets(ctx context.Context, mode, clientModel string) (string, []string, error) {
+	requested := s.resolveModelByMode(ctx, mode, clientModel)
 	mapped := requested
 
-	if s.settings != nil {
-		m, err := s.settings.ResolveModelMapping(requested)
+	if cfg := s.settingsFor(ctx); cfg != nil {
+		m, err := cfg.ResolveModelMapping(requested)
 		if err != nil {
-			return requested, "", err
+			return requested, nil, err
 		}
 		mapped = strings.TrimSpace(m)
 	}
 	if strings.TrimSpace(mapped) == "" {
-		return requested, "", fmt.Errorf("model is required")
+		return requested, nil, fmt.Errorf("model is required")
+	}
+	if grouped, ok := s.modelMapper.(modelmap.GroupMapper); ok {
+		targets, err := grouped.ResolveGroup(mapped)
+		if err != nil {
+			return requested, nil, err
+		}
+		return requested, targets, nil
 	}
 	if s.modelMapper != nil {
 		finalMapped, err := s.modelMapper.Resolve(mapped)
 		if err != nil {
-			return requested, "", err
+			return requested, nil, err
 		}
 		mapped = finalMapped
 	}
-	return requested, mapped, nil
+	return requested, []string{mapped}, nil
 }
 
-func (s *server) applySystemPromptPrefix(mode string, system any) any {
-	if s.settings == nil {
+// applyModelFallbacks lists the alias group targets after the first in
+// metadata for the upstream router to fail over to.
+func applyModelFallbacks(metadata map[string]any, targets []string) map[string]any {
+	if len(targets) < 2 {
+		return metadata
+	}
+	if metadata == nil {
+		metadata = map[string]any{}
+	}
+	metadata["model_fallbacks"] = append([]string(nil), targets[1:]...)
+	return metadata
+}
+
+// withModelTargetRecorder records on the run which alias group target
+// served it, and an event when that was not the first target.
+func (s *server) withModelTargetRecorder(ctx context.Context, runID, sessionID string) context.Context {
+	return upstream.WithModelTargetObserver(ctx, func(t upstream.ModelTarget) {
+		if annotator, ok := s.runStore.(interface {
+			Annotate(id string, metadata map[string]any) (ccrun.Run, error)
+		}); ok && runID != "" {
+			_, _ = annotator.Annotate(runID, map[string]any{
+				"model_target":       t.Model,
+				"model_target_index": t.Index,
+				"model_targets":      t.Targets,
+			})
+		}
+		if t.Index == 0 {
+			return
+		}
+		s.appendEvent(ccevent.AppendInput{
+			EventType: "run.model_fallback",
+			SessionID: sessionID,
+			RunID:     runID,
+			Data: map[string]any{
+				"model":   t.Model,
+				"index":   t.Index,
+				"targets": t.Targets,
+				"error":   t.LastError,
+			},
+		})
+	})
+}
+
+// applySystemPromptPrefix prepends the mode's prompt prefix, or the prompt
+// experiment variant already assigned in metadata.
+func (s *server) applySystemPromptPrefix(ctx context.Context, mode string, system any, metadata map[string]any) any {
+	scoped := s.settingsFor(ctx)
+	if scoped == nil {
 		return system
 	}
-	prefix := strings.TrimSpace(s.settings.PromptPrefix(mode))
+	prefix, ok := s.promptVariantPrefix(mode, metadata)
+	if !ok {
+		prefix = strings.TrimSpace(scoped.PromptPrefix(mode))
+	}
+	return prependSystemText(prefix, system)
+}
+
+// prependSystemText puts prefix in front of the system prompt, flattening
+// block-form prompts to text.
+func prependSystemText(prefix string, system any) any {
 	if prefix == "" {
 		return system
 	}
@@ -69,15 +159,21 @@ func (s *server) applySystemPromptPrefix(mode string, system any) any {
 	return prefix + "\n\n" + existing
 }
 
-func (s *server) applyRoutingPolicy(mode string, metadata map[string]any) map[string]any {
+func (s *server) applyRoutingPolicy(ctx context.Context, mode string, metadata map[string]any) map[string]any {
 	out := map[string]any{}
 	for k, v := range metadata {
 		out[k] = v
 	}
-	if s.settings == nil {
+	delete(out, "mode_params")
+	delete(out, "tool_result_limits")
+	delete(out, "tool_approval")
+	delete(out, "hedge_after_ms")
+	scoped := s.settingsFor(ctx)
+	if scoped == nil {
+		s.applyFeatureFlags(out)
 		return out
 	}
-	cfg := s.settings.Get()
+	cfg := scoped.Get()
 	out["routing_retries"] = cfg.Routing.Retries
 	out["routing_timeout_ms"] = cfg.Routing.TimeoutMS
 	out["reflection_passes"] = cfg.Routing.ReflectionPasses
@@ -89,18 +185,76 @@ func (s *server) applyRoutingPolicy(mode string, metadata map[string]any) map[st
 	if len(cfg.ToolAliases) > 0 {
 		out["tool_aliases"] = cfg.ToolAliases
 	}
+	if _, set := out["tool_citations"]; !set && cfg.ToolLoop.Citations {
+		out["tool_citations"] = true
+	}
 	if strings.TrimSpace(cfg.ToolLoop.PlannerModel) != "" {
 		out["tool_planner_model"] = cfg.ToolLoop.PlannerModel
 	}
-	if route := s.settings.ModeRoute(mode); len(route) > 0 {
+	if _, set := out["tool_loop_stream"]; !set {
+		out["tool_loop_stream"] = cfg.ToolLoop.Stream
+	}
+	if len(cfg.ToolLoop.ResultLimits) > 0 {
+		out["tool_result_limits"] = cfg.ToolLoop.ResultLimits
+	}
+	if len(cfg.ToolLoop.Approval.Tools) > 0 {
+		out["tool_approval"] = cfg.ToolLoop.Approval
+	}
+	if route := scoped.ModeRoute(mode); len(route) > 0 {
 		out["routing_adapter_route"] = route
 	}
+	if params, ok := scoped.ModeParamsFor(mode); ok {
+		out["mode_params"] = params
+	}
+	if a, ok := assignCanary(ctx, cfg.Routing.Canary, mode); ok {
+		applyCanaryAssignment(out, cfg.Routing.Canary, a)
+	}
+	s.applyPromptExperiment(ctx, out, mode)
+	applyShadowSampling(out, cfg.Routing.Shadow, mode)
+	applyHedging(out, cfg.Routing.Hedge, mode)
+	applyJudgeRubric(out, cfg.Routing.Judge, mode)
+	s.applyFeatureFlags(out)
 	if len(out) == 0 {
 		return nil
 	}
 	return out
 }
 
+// applyModeParams folds the mode's sampling overrides, left in metadata by
+// applyRoutingPolicy, over the request's temperature, top_p and stop
+// sequences, and returns max_tokens capped by the mode.
+func applyModeParams(metadata map[string]any, maxTokens int) int {
+	params, ok := metadata["mode_params"].(settings.ModeParams)
+	if !ok {
+		return maxTokens
+	}
+	delete(metadata, "mode_params")
+	if params.Temperature != nil {
+		metadata["temperature"] = *params.Temperature
+	}
+	if params.TopP != nil {
+		metadata["top_p"] = *params.TopP
+	}
+	if len(params.StopSequences) > 0 {
+		stops := stringListFromMetadata(metadata, "stop_sequences")
+		seen := map[string]bool{}
+		for _, stop := range stops {
+			seen[stop] = true
+		}
+		for _, stop := range params.StopSequences {
+			if !seen[stop] {
+				seen[stop] = true
+				stops = append(stops, stop)
+			}
+		}
+		metadata["stop_sequences"] = stops
+	}
+	if params.MaxTokens > 0 && (maxTokens <= 0 || maxTokens > params.MaxTokens) {
+		maxTokens = params.MaxTokens
+	}
+	return maxTokens
+}
+
 func systemToText(system any) string {
 	switch s := system.(type) {
 	case nil:
@@ -0,0 +1,79 @@
+package gateway
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"ccgateway/internal/auditlog"
+	"ccgateway/internal/ccevent"
+	"ccgateway/internal/orchestrator"
+	"ccgateway/internal/secretscan"
+)
+
+// maskToolResult masks secrets in a tool result before it goes back into
+// the model context, unless the tool is exempt.
+func (s *server) maskToolResult(req orchestrator.Request, tool, callID, content string) string {
+	if !s.secretScanner.Enabled() || s.secretScanner.Exempt(tool) {
+		return content
+	}
+	masked, hits := s.secretScanner.Mask(content)
+	if len(hits) == 0 {
+		return content
+	}
+	sessionID, _ := req.Metadata["session_id"].(string)
+	s.appendEvent(ccevent.AppendInput{
+		EventType: "tool.secrets_masked",
+		SessionID: sessionID,
+		RunID:     req.RunID,
+		Data: map[string]any{
+			"tool":        tool,
+			"tool_use_id": callID,
+			"hits":        hits,
+		},
+	})
+	return masked
+}
+
+// handleAdminSecretScan reads or replaces the secret scanner config.
+// GET/PUT /admin/secret-scan
+func (s *server) handleAdminSecretScan(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	if s.secretScanner == nil {
+		s.writeError(w, http.StatusNotImplemented, "api_error", "secret scanning is not configured")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var cfg secretscan.Config
+		if err := decodeJSONBodyStrict(r, &cfg, false); err != nil {
+			s.reportRequestDecodeIssue(r, err)
+			s.writeError(w, http.StatusBadRequest, "invalid_request_error", "invalid JSON body")
+			return
+		}
+		if err := s.secretScanner.Update(cfg); err != nil {
+			s.writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+			return
+		}
+		s.appendEvent(ccevent.AppendInput{
+			EventType: "secret_scan.updated",
+			Data: map[string]any{
+				"enabled":      cfg.Enabled,
+				"patterns":     len(cfg.Patterns),
+				"exempt_tools": cfg.ExemptTools,
+				"actor":        auditlog.ActorID(adminTokenFromRequest(r)),
+			},
+		})
+	default:
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+		return
+	}
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"config":   s.secretScanner.Config(),
+		"builtins": secretscan.Builtins(),
+	})
+}
@@ -0,0 +1,213 @@
+package gateway
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"ccgateway/internal/ccevent"
+	"ccgateway/internal/memory"
+	"ccgateway/internal/settings"
+)
+
+const memoryDistillTimeout = 60 * time.Second
+
+// sessionMemoryWork serializes memory updates per gateway and keeps at most
+// one distillation in flight per session.
+type sessionMemoryWork struct {
+	mu         sync.Mutex
+	distilling map[string]bool
+}
+
+func newSessionMemoryWork() *sessionMemoryWork {
+	return &sessionMemoryWork{distilling: map[string]bool{}}
+}
+
+func (s *server) memorySettings() (settings.MemorySettings, bool) {
+	if s.settings == nil || s.memoryStore == nil || s.memoryWork == nil {
+		return settings.MemorySettings{}, false
+	}
+	cfg := s.settings.Get().Memory
+	return cfg, cfg.Enabled
+}
+
+// sessionMemoryEnabled reports whether the request takes part in session
+// memory: it needs a session id, memory enabled in the runtime settings and
+// no "memory": false in its metadata.
+func (s *server) sessionMemoryEnabled(sessionID string, metadata map[string]any) bool {
+	if strings.TrimSpace(sessionID) == "" {
+		return false
+	}
+	if _, ok := s.memorySettings(); !ok {
+		return false
+	}
+	if v, ok := metadata["memory"].(bool); ok && !v {
+		return false
+	}
+	return true
+}
+
+// injectSessionMemory appends the session's summary and facts to the
+// system prompt.
+func (s *server) injectSessionMemory(ctx context.Context, sessionID string, system any) any {
+	sm, err := s.memoryStore.GetSessionMemory(ctx, sessionID)
+	if err != nil {
+		return system
+	}
+	s.memoryWork.mu.Lock()
+	block := memory.RenderContext(sm)
+	s.memoryWork.mu.Unlock()
+	if block == "" {
+		return system
+	}
+	existing := strings.TrimSpace(systemToText(system))
+	if existing == "" {
+		return block
+	}
+	return existing + "\n\n" + block
+}
+
+// rememberSessionExchange records a finished turn and starts a
+// distillation once enough messages have piled up.
+func (s *server) rememberSessionExchange(sessionID, runID, prompt, reply string) {
+	cfg, ok := s.memorySettings()
+	if !ok {
+		return
+	}
+	prompt, reply = strings.TrimSpace(prompt), strings.TrimSpace(reply)
+	if prompt == "" && reply == "" {
+		return
+	}
+	ctx := context.Background()
+	now := time.Now()
+
+	s.memoryWork.mu.Lock()
+	wm, err := s.memoryStore.GetWorkingMemory(ctx, sessionID)
+	if err != nil {
+		s.memoryWork.mu.Unlock()
+		s.memoryError(sessionID, runID, "get_working_memory", err)
+		return
+	}
+	next := &memory.WorkingMemory{SessionID: sessionID, Messages: append([]memory.Message(nil), wm.Messages...), LastUpdate: now}
+	if prompt != "" {
+		next.Messages = append(next.Messages, memory.Message{Role: "user", Content: prompt, Timestamp: now})
+	}
+	if reply != "" {
+		next.Messages = append(next.Messages, memory.Message{Role: "assistant", Content: reply, Timestamp: now})
+	}
+	err = s.memoryStore.UpdateWorkingMemory(ctx, next)
+	start := err == nil && s.summarizer != nil && len(next.Messages) >= cfg.SummarizeAfter && !s.memoryWork.distilling[sessionID]
+	if start {
+		s.memoryWork.distilling[sessionID] = true
+	}
+	s.memoryWork.mu.Unlock()
+	if err != nil {
+		s.memoryError(sessionID, runID, "update_working_memory", err)
+		return
+	}
+	if start {
+		go s.distillSessionMemory(sessionID, runID, cfg)
+	}
+}
+
+func (s *server) distillSessionMemory(sessionID, runID string, cfg settings.MemorySettings) {
+	defer func() {
+		s.memoryWork.mu.Lock()
+		delete(s.memoryWork.distilling, sessionID)
+		s.memoryWork.mu.Unlock()
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), memoryDistillTimeout)
+	defer cancel()
+
+	s.memoryWork.mu.Lock()
+	wm, err := s.memoryStore.GetWorkingMemory(ctx, sessionID)
+	var sm *memory.SessionMemory
+	if err == nil {
+		sm, err = s.memoryStore.GetSessionMemory(ctx, sessionID)
+	}
+	var msgs []memory.Message
+	var summary string
+	var facts []memory.Fact
+	if err == nil {
+		msgs = append(msgs, wm.Messages...)
+		summary = sm.Summary
+		facts = append(facts, sm.Facts...)
+	}
+	s.memoryWork.mu.Unlock()
+	if err != nil {
+		s.memoryError(sessionID, runID, "load", err)
+		return
+	}
+	// The latest messages stay verbatim for the next round.
+	distilled := len(msgs) - cfg.KeepRecent
+	if distilled <= 0 {
+		return
+	}
+
+	var out memory.Distillation
+	if d, ok := s.summarizer.(memory.Distiller); ok {
+		out, err = d.Distill(ctx, memory.DistillRequest{
+			Model:           cfg.SummarizerModel,
+			PreviousSummary: summary,
+			Facts:           memory.FactTexts(facts),
+			Messages:        msgs[:distilled],
+		})
+	} else {
+		out.Summary, err = s.summarizer.SummarizeRecent(ctx, msgs[:distilled])
+		out.Facts = memory.FactTexts(facts)
+	}
+	if err != nil {
+		s.memoryError(sessionID, runID, "distill", err)
+		return
+	}
+
+	now := time.Now()
+	s.memoryWork.mu.Lock()
+	updated := &memory.SessionMemory{SessionID: sessionID, ProjectMeta: map[string]interface{}{}, UserPrefs: map[string]string{}}
+	if cur, err := s.memoryStore.GetSessionMemory(ctx, sessionID); err == nil && cur != nil {
+		copied := *cur
+		updated = &copied
+	}
+	if strings.TrimSpace(out.Summary) != "" {
+		updated.Summary = strings.TrimSpace(out.Summary)
+	}
+	updated.Facts = memory.MergeFacts(facts, out.Facts, runID, cfg.MaxFacts, now)
+	updated.LastUpdate = now
+	err = s.memoryStore.UpdateSessionMemory(ctx, updated)
+	if err == nil {
+		// Drop what was distilled; turns recorded meanwhile are kept.
+		if cur, gerr := s.memoryStore.GetWorkingMemory(ctx, sessionID); gerr == nil && len(cur.Messages) >= distilled {
+			rest := append([]memory.Message(nil), cur.Messages[distilled:]...)
+			err = s.memoryStore.UpdateWorkingMemory(ctx, &memory.WorkingMemory{SessionID: sessionID, Messages: rest, LastUpdate: now})
+		}
+	}
+	factCount := len(updated.Facts)
+	s.memoryWork.mu.Unlock()
+	if err != nil {
+		s.memoryError(sessionID, runID, "update_session_memory", err)
+		return
+	}
+	s.appendEvent(ccevent.AppendInput{
+		EventType: "memory.updated",
+		SessionID: sessionID,
+		RunID:     runID,
+		Data: map[string]any{
+			"distilled_messages": distilled,
+			"facts":              factCount,
+			"summary_chars":      len(updated.Summary),
+		},
+	})
+}
+
+func (s *server) memoryError(sessionID, runID, stage string, err error) {
+	s.appendEvent(ccevent.AppendInput{
+		EventType: "memory.error",
+		SessionID: sessionID,
+		RunID:     runID,
+		Data: map[string]any{
+			"stage": stage,
+			"error": err.Error(),
+		},
+	})
+}
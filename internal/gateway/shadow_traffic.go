@@ -0,0 +1,176 @@
+package gateway
+
+import (
+	"encoding/json"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"ccgateway/internal/ccevent"
+	"ccgateway/internal/settings"
+	"ccgateway/internal/upstream"
+)
+
+// shadowResponseChars bounds the shadow answer kept in shadow.completed
+// events.
+const shadowResponseChars = 2000
+
+// shadowAdapterStats aggregates mirrored calls to one shadow adapter.
+type shadowAdapterStats struct {
+	Adapter          string    `json:"adapter"`
+	Calls            int64     `json:"calls"`
+	Errors           int64     `json:"errors"`
+	Judged           int64     `json:"judged"`
+	Wins             int64     `json:"wins"`
+	WinRate          float64   `json:"win_rate"`
+	AvgLatencyMS     int64     `json:"avg_latency_ms"`
+	AvgPrimaryMS     int64     `json:"avg_primary_latency_ms"`
+	InputTokens      int64     `json:"input_tokens"`
+	OutputTokens     int64     `json:"output_tokens"`
+	LastAt           time.Time `json:"last_at"`
+	latencyTotal     time.Duration
+	primaryLatencies time.Duration
+	successes        int64
+}
+
+type shadowStats struct {
+	mu       sync.Mutex
+	adapters map[string]*shadowAdapterStats
+}
+
+func newShadowStats() *shadowStats {
+	return &shadowStats{adapters: map[string]*shadowAdapterStats{}}
+}
+
+func (st *shadowStats) add(res upstream.ShadowResult) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	a, ok := st.adapters[res.Adapter]
+	if !ok {
+		a = &shadowAdapterStats{Adapter: res.Adapter}
+		st.adapters[res.Adapter] = a
+	}
+	a.Calls++
+	a.LastAt = time.Now().UTC()
+	if res.Err != nil {
+		a.Errors++
+		return
+	}
+	a.successes++
+	a.latencyTotal += res.Latency
+	a.primaryLatencies += res.PrimaryLatency
+	a.InputTokens += int64(res.Response.Usage.InputTokens)
+	a.OutputTokens += int64(res.Response.Usage.OutputTokens)
+	if res.Judged {
+		a.Judged++
+		if res.ShadowWon {
+			a.Wins++
+		}
+	}
+}
+
+func (st *shadowStats) snapshot() []shadowAdapterStats {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	out := make([]shadowAdapterStats, 0, len(st.adapters))
+	for _, a := range st.adapters {
+		item := *a
+		if item.successes > 0 {
+			item.AvgLatencyMS = (item.latencyTotal / time.Duration(item.successes)).Milliseconds()
+			item.AvgPrimaryMS = (item.primaryLatencies / time.Duration(item.successes)).Milliseconds()
+		}
+		if item.Judged > 0 {
+			item.WinRate = float64(item.Wins) / float64(item.Judged)
+		}
+		out = append(out, item)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Adapter < out[j].Adapter })
+	return out
+}
+
+func (st *shadowStats) reset() {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.adapters = map[string]*shadowAdapterStats{}
+}
+
+// applyShadowSampling marks a sampled request for mirroring to the shadow
+// adapters; the router does the mirroring once the primary has answered.
+func applyShadowSampling(out map[string]any, cfg settings.ShadowSettings, mode string) {
+	if !cfg.Enabled || len(cfg.Adapters) == 0 || cfg.Percent <= 0 {
+		return
+	}
+	if len(cfg.Modes) > 0 && !containsFold(cfg.Modes, mode) {
+		return
+	}
+	if cfg.Percent < 100 && rand.Float64()*100 >= cfg.Percent {
+		return
+	}
+	out["shadow_adapters"] = append([]string(nil), cfg.Adapters...)
+	if cfg.TimeoutMS > 0 {
+		out["shadow_timeout_ms"] = cfg.TimeoutMS
+	}
+}
+
+// recordShadowResult keeps the shadow outcome for /admin/shadow and as a
+// shadow.completed event tied to the primary run.
+func (s *server) recordShadowResult(res upstream.ShadowResult) {
+	s.shadowStats.add(res)
+	data := map[string]any{
+		"path":               valueAsString(res.Request.Metadata["request_path"]),
+		"model":              res.Request.Model,
+		"adapter":            res.Adapter,
+		"primary_adapter":    res.Primary,
+		"latency_ms":         res.Latency.Milliseconds(),
+		"primary_latency_ms": res.PrimaryLatency.Milliseconds(),
+		"judged":             res.Judged,
+		"shadow_won":         res.ShadowWon,
+	}
+	if res.Err != nil {
+		data["error"] = res.Err.Error()
+	} else {
+		data["stop_reason"] = res.Response.StopReason
+		data["input_tokens"] = res.Response.Usage.InputTokens
+		data["output_tokens"] = res.Response.Usage.OutputTokens
+		var text strings.Builder
+		for _, block := range res.Response.Blocks {
+			if block.Type == "text" {
+				text.WriteString(block.Text)
+			}
+		}
+		data["response_text"] = truncateText(text.String(), shadowResponseChars)
+	}
+	s.appendEvent(ccevent.AppendInput{
+		EventType: "shadow.completed",
+		SessionID: valueAsString(res.Request.Metadata["session_id"]),
+		RunID:     res.Request.RunID,
+		Data:      data,
+	})
+}
+
+// handleAdminShadow reports shadow mirroring config and per-adapter
+// results.
+// GET/DELETE /admin/shadow
+func (s *server) handleAdminShadow(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		body := map[string]any{"adapters": s.shadowStats.snapshot()}
+		if s.settings != nil {
+			body["config"] = s.settings.Get().Routing.Shadow
+		}
+		w.Header().Set("content-type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(body)
+	case http.MethodDelete:
+		s.shadowStats.reset()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+	}
+}
@@ -0,0 +1,237 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"ccgateway/internal/ccevent"
+)
+
+const (
+	defaultSSEPingInterval = 15 * time.Second
+	defaultSSEWriteTimeout = 30 * time.Second
+)
+
+// SSEConfig keeps long streams alive through proxies and bounds how long a
+// slow client may hold up its stream.
+type SSEConfig struct {
+	// PingInterval is the idle time after which a ping event is sent
+	// (default 15s). Negative disables pings.
+	PingInterval time.Duration
+	// WriteTimeout bounds each write and flush to the client (default 30s).
+	// A client that does not drain in time is dropped. Negative disables it.
+	WriteTimeout time.Duration
+}
+
+func (c SSEConfig) withDefaults() SSEConfig {
+	if c.PingInterval == 0 {
+		c.PingInterval = defaultSSEPingInterval
+	}
+	if c.WriteTimeout == 0 {
+		c.WriteTimeout = defaultSSEWriteTimeout
+	}
+	return c
+}
+
+var (
+	// anthropicPingFrame matches the ping events Anthropic streams carry.
+	anthropicPingFrame = []byte("event: ping\ndata: {\"type\":\"ping\"}\n\n")
+	// commentPingFrame is an SSE comment, ignored by OpenAI-style clients
+	// and EventSource alike.
+	commentPingFrame = []byte(": ping\n\n")
+)
+
+var errSSEClientDropped = errors.New("sse client dropped: write timed out")
+
+// sseGuard wraps the ResponseWriter of an SSE stream. It forwards whole
+// events only, so a ping never lands in the middle of one, sends a ping
+// whenever the stream has been idle for the ping interval, and drops the
+// client when a write or flush outlasts the write timeout.
+type sseGuard struct {
+	http.ResponseWriter
+	cfg    SSEConfig
+	ping   []byte
+	rc     *http.ResponseController
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu        sync.Mutex
+	pending   []byte
+	streaming bool
+	lastWrite time.Time
+	err       error
+
+	dropped atomic.Bool
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// guardStream wraps w for an SSE stream. The returned request's context is
+// cancelled when the client is dropped, stopping the producer; call the
+// returned func before the handler returns.
+func (s *server) guardStream(w http.ResponseWriter, r *http.Request, ping []byte) (http.ResponseWriter, *http.Request, func()) {
+	if _, ok := w.(http.Flusher); !ok {
+		return w, r, func() {}
+	}
+	cfg := s.sseConfig.withDefaults()
+	ctx, cancel := context.WithCancel(r.Context())
+	g := &sseGuard{
+		ResponseWriter: w,
+		cfg:            cfg,
+		ping:           ping,
+		rc:             http.NewResponseController(w),
+		ctx:            ctx,
+		cancel:         cancel,
+		stop:           make(chan struct{}),
+		done:           make(chan struct{}),
+	}
+	if cfg.PingInterval > 0 {
+		go g.heartbeat()
+	} else {
+		close(g.done)
+	}
+	return g, r.WithContext(ctx), func() {
+		close(g.stop)
+		<-g.done
+		g.mu.Lock()
+		if len(g.pending) > 0 && g.err == nil {
+			_ = g.writeLocked(g.pending, true)
+			g.pending = nil
+		}
+		g.mu.Unlock()
+		if g.dropped.Load() {
+			s.appendEvent(ccevent.AppendInput{
+				EventType: "stream.client_dropped",
+				Data: map[string]any{
+					"path":             r.URL.Path,
+					"write_timeout_ms": cfg.WriteTimeout.Milliseconds(),
+				},
+			})
+		}
+		cancel()
+	}
+}
+
+func (g *sseGuard) Unwrap() http.ResponseWriter { return g.ResponseWriter }
+
+func (g *sseGuard) WriteHeader(status int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.streaming = status == http.StatusOK && strings.HasPrefix(g.Header().Get("content-type"), "text/event-stream")
+	g.lastWrite = time.Now()
+	g.ResponseWriter.WriteHeader(status)
+}
+
+func (g *sseGuard) Write(p []byte) (int, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.err != nil {
+		return 0, g.err
+	}
+	if !g.streaming {
+		return g.ResponseWriter.Write(p)
+	}
+	g.pending = append(g.pending, p...)
+	end := bytes.LastIndex(g.pending, []byte("\n\n"))
+	if end < 0 {
+		return len(p), nil
+	}
+	frames := g.pending[:end+2]
+	if err := g.writeLocked(frames, false); err != nil {
+		return 0, err
+	}
+	g.pending = append(g.pending[:0], g.pending[end+2:]...)
+	return len(p), nil
+}
+
+func (g *sseGuard) Flush() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.err != nil {
+		return
+	}
+	_ = g.writeLocked(nil, true)
+}
+
+// writeLocked writes p and optionally flushes it, under the write timeout.
+func (g *sseGuard) writeLocked(p []byte, flush bool) error {
+	if g.cfg.WriteTimeout > 0 {
+		// The deadline unblocks a stuck write on a real connection; the
+		// timer cancels the stream even where deadlines are unsupported.
+		_ = g.rc.SetWriteDeadline(time.Now().Add(g.cfg.WriteTimeout))
+		timer := time.AfterFunc(g.cfg.WriteTimeout, g.drop)
+		defer func() {
+			timer.Stop()
+			_ = g.rc.SetWriteDeadline(time.Time{})
+		}()
+	}
+	var err error
+	if len(p) > 0 {
+		_, err = g.ResponseWriter.Write(p)
+	}
+	if err == nil && flush {
+		err = g.rc.Flush()
+	}
+	if err == nil && g.dropped.Load() {
+		err = errSSEClientDropped
+	}
+	if err != nil {
+		g.err = err
+		g.cancel()
+		return err
+	}
+	g.lastWrite = time.Now()
+	return nil
+}
+
+// drop gives up on a client whose write timed out.
+func (g *sseGuard) drop() {
+	g.dropped.Store(true)
+	g.cancel()
+}
+
+func (g *sseGuard) heartbeat() {
+	defer close(g.done)
+	timer := time.NewTimer(g.cfg.PingInterval)
+	defer timer.Stop()
+	for {
+		select {
+		case <-g.stop:
+			return
+		case <-g.ctx.Done():
+			return
+		case <-timer.C:
+		}
+		wait, ok := g.pingIfIdle()
+		if !ok {
+			return
+		}
+		timer.Reset(wait)
+	}
+}
+
+// pingIfIdle sends a ping when nothing was written for the ping interval
+// and reports how long to wait before checking again.
+func (g *sseGuard) pingIfIdle() (time.Duration, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.err != nil {
+		return 0, false
+	}
+	if !g.streaming {
+		return g.cfg.PingInterval, true
+	}
+	if idle := time.Since(g.lastWrite); idle < g.cfg.PingInterval {
+		return g.cfg.PingInterval - idle, true
+	}
+	if err := g.writeLocked(g.ping, true); err != nil {
+		return 0, false
+	}
+	return g.cfg.PingInterval, true
+}
@@ -0,0 +1,212 @@
+package gateway
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+	"strings"
+
+	"ccgateway/internal/ccevent"
+	"ccgateway/internal/ccrun"
+	"ccgateway/internal/orchestrator"
+)
+
+// Stream truncation reasons.
+const (
+	truncationUpstreamError = "upstream_error"
+	truncationMissingStop   = "missing_stop"
+	truncationUsageMismatch = "usage_mismatch"
+)
+
+// usageMismatchMinTokens is the smallest reported output for which the
+// content length is compared against usage; short answers are too noisy.
+const usageMismatchMinTokens = 32
+
+// streamCheck follows the content and end-of-stream signals of one stream
+// so a stream that broke off can be told apart from a short answer.
+type streamCheck struct {
+	sum        hash.Hash
+	bytes      int
+	events     int
+	started    bool
+	content    bool
+	stopped    bool
+	stopReason string
+	usage      orchestrator.Usage
+	replayed   bool
+}
+
+type rawStreamUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+func newStreamCheck() *streamCheck {
+	return &streamCheck{sum: sha256.New()}
+}
+
+// observe records ev. Pass-through events are read from their raw
+// Anthropic payload, which carries the stop reason and usage the parsed
+// fields leave empty.
+func (c *streamCheck) observe(ev orchestrator.StreamEvent) {
+	c.events++
+	kind := ev.Type
+	text := ev.DeltaText + ev.DeltaJSON
+	stopReason := ev.StopReason
+	usage := ev.Usage
+	if ev.PassThrough && len(ev.RawData) > 0 {
+		var raw struct {
+			Type  string `json:"type"`
+			Delta struct {
+				Text        string `json:"text"`
+				PartialJSON string `json:"partial_json"`
+				Thinking    string `json:"thinking"`
+				StopReason  string `json:"stop_reason"`
+			} `json:"delta"`
+			Usage   rawStreamUsage `json:"usage"`
+			Message struct {
+				Usage rawStreamUsage `json:"usage"`
+			} `json:"message"`
+		}
+		if err := json.Unmarshal(ev.RawData, &raw); err == nil {
+			if raw.Type != "" {
+				kind = raw.Type
+			}
+			text = raw.Delta.Text + raw.Delta.PartialJSON + raw.Delta.Thinking
+			stopReason = raw.Delta.StopReason
+			usage = orchestrator.Usage{InputTokens: raw.Usage.InputTokens, OutputTokens: raw.Usage.OutputTokens}
+			if kind == "message_start" {
+				usage = orchestrator.Usage{InputTokens: raw.Message.Usage.InputTokens, OutputTokens: raw.Message.Usage.OutputTokens}
+			}
+		}
+	}
+	switch kind {
+	case "message_start":
+		c.started = true
+	case "content_block_delta":
+		if text != "" {
+			c.sum.Write([]byte(text))
+			c.bytes += len(text)
+			c.content = true
+		}
+	case "message_delta":
+		if stopReason != "" {
+			c.stopReason = stopReason
+		}
+	case "message_stop":
+		c.stopped = true
+	}
+	if usage.InputTokens > 0 {
+		c.usage.InputTokens = usage.InputTokens
+	}
+	if usage.OutputTokens > 0 {
+		c.usage.OutputTokens = usage.OutputTokens
+	}
+}
+
+// truncation returns why the stream looks cut short, or "" when it ended
+// cleanly.
+func (c *streamCheck) truncation(upstreamErr error) string {
+	switch {
+	case upstreamErr != nil:
+		return truncationUpstreamError
+	case !c.stopped && c.stopReason == "":
+		return truncationMissingStop
+	case c.usage.OutputTokens >= usageMismatchMinTokens && c.bytes*2 < c.usage.OutputTokens:
+		// Every output token yields at least a byte or so of content;
+		// far less than that means deltas went missing.
+		return truncationUsageMismatch
+	}
+	return ""
+}
+
+func (c *streamCheck) checksum() string {
+	return hex.EncodeToString(c.sum.Sum(nil))
+}
+
+// finishStream runs when a stream ends, normally or with upstreamErr. A
+// truncated stream is reported; when no content has reached the client
+// yet and routing.retry_truncated_streams is on, the request is re-run
+// without streaming and the returned events replace the rest of the
+// stream. Callers keep writing those in their own format.
+func (s *server) finishStream(ctx context.Context, req orchestrator.Request, check *streamCheck, upstreamErr error) (<-chan orchestrator.StreamEvent, bool) {
+	if check.replayed || ctx.Err() != nil {
+		return nil, false
+	}
+	reason := check.truncation(upstreamErr)
+	if reason == "" {
+		return nil, false
+	}
+	scoped := s.settingsFor(ctx)
+	retry := !check.content && scoped != nil && scoped.Get().Routing.RetryTruncatedStreams
+	var replay <-chan orchestrator.StreamEvent
+	retryErr := ""
+	if retry {
+		resp, err := s.orchestrator.Complete(ctx, req)
+		if err != nil {
+			retryErr = err.Error()
+		} else {
+			replay = responseStreamEvents(resp, check.started)
+		}
+	}
+	data := map[string]any{
+		"path":           valueAsString(req.Metadata["request_path"]),
+		"upstream_model": req.Model,
+		"reason":         reason,
+		"events":         check.events,
+		"content_bytes":  check.bytes,
+		"content_sha256": check.checksum(),
+		"stop_reason":    check.stopReason,
+		"output_tokens":  check.usage.OutputTokens,
+		"retried":        retry,
+		"recovered":      replay != nil,
+	}
+	if upstreamErr != nil {
+		data["error"] = upstreamErr.Error()
+	}
+	if retryErr != "" {
+		data["retry_error"] = retryErr
+	}
+	s.appendEvent(ccevent.AppendInput{
+		EventType: "run.stream_truncated",
+		SessionID: valueAsString(req.Metadata["session_id"]),
+		RunID:     req.RunID,
+		Data:      data,
+	})
+	if replay == nil {
+		if marker, ok := s.runStore.(interface {
+			MarkTruncated(id, reason string) (ccrun.Run, error)
+		}); ok && strings.TrimSpace(req.RunID) != "" {
+			_, _ = marker.MarkTruncated(req.RunID, reason)
+		}
+		return nil, false
+	}
+	check.replayed = true
+	return replay, true
+}
+
+// responseStreamEvents turns a complete response into the canonical stream
+// events, leaving out message_start when the client already has one.
+func responseStreamEvents(resp orchestrator.Response, skipStart bool) <-chan orchestrator.StreamEvent {
+	out := make(chan orchestrator.StreamEvent, 3*len(resp.Blocks)+3)
+	if !skipStart {
+		out <- orchestrator.StreamEvent{Type: "message_start", Usage: orchestrator.Usage{InputTokens: resp.Usage.InputTokens}}
+	}
+	for i, block := range resp.Blocks {
+		out <- orchestrator.StreamEvent{Type: "content_block_start", Index: i, Block: block}
+		switch block.Type {
+		case "text":
+			out <- orchestrator.StreamEvent{Type: "content_block_delta", Index: i, DeltaText: block.Text}
+		case "tool_use":
+			raw, _ := json.Marshal(block.Input)
+			out <- orchestrator.StreamEvent{Type: "content_block_delta", Index: i, DeltaJSON: string(raw)}
+		}
+		out <- orchestrator.StreamEvent{Type: "content_block_stop", Index: i}
+	}
+	out <- orchestrator.StreamEvent{Type: "message_delta", StopReason: resp.StopReason, Usage: resp.Usage}
+	out <- orchestrator.StreamEvent{Type: "message_stop"}
+	close(out)
+	return out
+}
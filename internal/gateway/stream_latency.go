@@ -0,0 +1,16 @@
+package gateway
+
+import "ccgateway/internal/upstream"
+
+type streamLatencyReporter interface {
+	StreamLatencyStatus() []upstream.StreamLatencyStatus
+}
+
+// streamLatencyStatus reports time-to-first-token and tokens-per-second
+// percentiles per adapter for /admin/status.
+func (s *server) streamLatencyStatus() []upstream.StreamLatencyStatus {
+	if reporter, ok := s.orchestrator.(streamLatencyReporter); ok {
+		return reporter.StreamLatencyStatus()
+	}
+	return nil
+}
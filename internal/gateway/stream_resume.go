@@ -0,0 +1,319 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"ccgateway/internal/ccevent"
+)
+
+const defaultStreamResumeMaxBytes = 4 << 20
+
+// StreamResumeConfig keeps streamed /v1/messages responses replayable after
+// the client disconnects.
+type StreamResumeConfig struct {
+	// Window is how long a finished stream stays resumable. Zero disables
+	// buffering, and generation then stops when the client goes away.
+	Window time.Duration
+	// MaxBytes caps the buffered events of one run (default 4MB); a stream
+	// growing beyond it is no longer resumable.
+	MaxBytes int
+}
+
+// streamBuffers holds the SSE events of recent streamed runs by run id.
+type streamBuffers struct {
+	cfg  StreamResumeConfig
+	mu   sync.Mutex
+	runs map[string]*streamBuffer
+}
+
+type streamBuffer struct {
+	runID     string
+	projectID string
+	userID    string
+	maxBytes  int
+
+	mu           sync.Mutex
+	events       [][]byte
+	size         int
+	overflow     bool
+	done         bool
+	disconnected bool
+	finishedAt   time.Time
+	// changed is closed and replaced whenever events arrive or the stream
+	// finishes, waking resumed readers.
+	changed chan struct{}
+}
+
+func newStreamBuffers(cfg StreamResumeConfig) *streamBuffers {
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = defaultStreamResumeMaxBytes
+	}
+	return &streamBuffers{cfg: cfg, runs: map[string]*streamBuffer{}}
+}
+
+// open starts buffering the stream of runID, or returns nil when resuming
+// is disabled.
+func (b *streamBuffers) open(runID, projectID, userID string) *streamBuffer {
+	if b == nil || b.cfg.Window <= 0 || runID == "" {
+		return nil
+	}
+	buf := &streamBuffer{
+		runID:     runID,
+		projectID: projectID,
+		userID:    userID,
+		maxBytes:  b.cfg.MaxBytes,
+		changed:   make(chan struct{}),
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.pruneLocked(time.Now())
+	b.runs[runID] = buf
+	return buf
+}
+
+func (b *streamBuffers) get(runID string) (*streamBuffer, bool) {
+	if b == nil {
+		return nil, false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.pruneLocked(time.Now())
+	buf, ok := b.runs[runID]
+	return buf, ok
+}
+
+// deleteWhere drops the buffered streams match selects, finished or not,
+// and returns how many were dropped.
+func (b *streamBuffers) deleteWhere(match func(buf *streamBuffer) bool) int {
+	if b == nil {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	n := 0
+	for id, buf := range b.runs {
+		if match(buf) {
+			delete(b.runs, id)
+			n++
+		}
+	}
+	return n
+}
+
+// pruneLocked forgets streams that finished more than the window ago.
+func (b *streamBuffers) pruneLocked(now time.Time) {
+	cutoff := now.Add(-b.cfg.Window)
+	for id, buf := range b.runs {
+		buf.mu.Lock()
+		expired := buf.done && buf.finishedAt.Before(cutoff)
+		buf.mu.Unlock()
+		if expired {
+			delete(b.runs, id)
+		}
+	}
+}
+
+func (buf *streamBuffer) append(frame []byte) {
+	buf.mu.Lock()
+	defer buf.mu.Unlock()
+	if buf.overflow {
+		return
+	}
+	if buf.size+len(frame) > buf.maxBytes {
+		buf.overflow = true
+		buf.events, buf.size = nil, 0
+	} else {
+		buf.events = append(buf.events, append([]byte(nil), frame...))
+		buf.size += len(frame)
+	}
+	close(buf.changed)
+	buf.changed = make(chan struct{})
+}
+
+func (buf *streamBuffer) finish() {
+	buf.mu.Lock()
+	defer buf.mu.Unlock()
+	buf.done = true
+	buf.finishedAt = time.Now()
+	close(buf.changed)
+	buf.changed = make(chan struct{})
+}
+
+// since returns the events from index from on, the number buffered so far
+// and a channel closed on the next change.
+func (buf *streamBuffer) since(from int) (events [][]byte, total int, done, overflow bool, changed <-chan struct{}) {
+	buf.mu.Lock()
+	defer buf.mu.Unlock()
+	if from < len(buf.events) {
+		events = append(events, buf.events[from:]...)
+	}
+	return events, len(buf.events), buf.done, buf.overflow, buf.changed
+}
+
+func (buf *streamBuffer) visibleTo(projectID, userID string) bool {
+	return buf.projectID == projectID && buf.userID == userID
+}
+
+// resumableStreamWriter copies each SSE event written to the client into
+// the run's buffer and keeps accepting writes once the client is gone, so
+// the stream runs to the end.
+type resumableStreamWriter struct {
+	http.ResponseWriter
+	buf     *streamBuffer
+	client  context.Context
+	pending []byte
+	gone    bool
+}
+
+func (w *resumableStreamWriter) Write(p []byte) (int, error) {
+	w.pending = append(w.pending, p...)
+	for {
+		i := bytes.Index(w.pending, []byte("\n\n"))
+		if i < 0 {
+			break
+		}
+		w.buf.append(w.pending[:i+2])
+		w.pending = w.pending[i+2:]
+	}
+	if !w.gone && w.client.Err() != nil {
+		w.gone = true
+	}
+	if !w.gone {
+		if _, err := w.ResponseWriter.Write(p); err != nil {
+			w.gone = true
+		}
+	}
+	return len(p), nil
+}
+
+func (w *resumableStreamWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+func (w *resumableStreamWriter) Flush() {
+	if w.gone {
+		return
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// resumableStream buffers the stream of runID when resuming is enabled. The
+// returned request no longer ends with the client's connection; call the
+// returned func once the stream is written.
+func (s *server) resumableStream(w http.ResponseWriter, r *http.Request, runID, sessionID string) (http.ResponseWriter, *http.Request, func()) {
+	buf := s.streamBuffers.open(runID, projectIDFromContext(r.Context()), requestUserID(r.Context()))
+	if buf == nil {
+		return w, r, func() {}
+	}
+	rw := &resumableStreamWriter{ResponseWriter: w, buf: buf, client: r.Context()}
+	w.Header().Set("x-cc-stream-resume", "/v1/messages/stream/"+runID)
+	return rw, r.WithContext(context.WithoutCancel(r.Context())), func() {
+		buf.finish()
+		if !rw.gone && rw.client.Err() == nil {
+			return
+		}
+		_, total, _, overflow, _ := buf.since(0)
+		s.appendEvent(ccevent.AppendInput{
+			EventType: "stream.client_disconnected",
+			SessionID: sessionID,
+			RunID:     runID,
+			Data: map[string]any{
+				"events":    total,
+				"resumable": !overflow,
+			},
+		})
+	}
+}
+
+// handleMessagesStreamResume replays a buffered stream from an event index
+// and follows it until the run finishes.
+// GET /v1/messages/stream/{run_id}?from_event=N
+func (s *server) handleMessagesStreamResume(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+		return
+	}
+	runID := strings.Trim(strings.TrimPrefix(r.URL.Path, "/v1/messages/stream/"), "/")
+	if runID == "" || strings.Contains(runID, "/") {
+		s.writeError(w, http.StatusNotFound, "not_found_error", "stream not found")
+		return
+	}
+	from := 0
+	if raw := strings.TrimSpace(r.URL.Query().Get("from_event")); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			s.writeError(w, http.StatusBadRequest, "invalid_request_error", "from_event must be a non-negative integer")
+			return
+		}
+		from = n
+	}
+	buf, ok := s.streamBuffers.get(runID)
+	if !ok || !buf.visibleTo(projectIDFromContext(r.Context()), requestUserID(r.Context())) {
+		s.writeError(w, http.StatusNotFound, "not_found_error", "stream not found or no longer resumable")
+		return
+	}
+	events, total, done, overflow, changed := buf.since(from)
+	if overflow {
+		s.writeError(w, http.StatusGone, "invalid_request_error", "stream exceeded the resume buffer")
+		return
+	}
+	if done && from > total {
+		s.writeError(w, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("from_event is past the end of the stream (%d events)", total))
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		s.writeError(w, http.StatusInternalServerError, "api_error", "streaming unsupported")
+		return
+	}
+	s.appendEvent(ccevent.AppendInput{
+		EventType: "stream.resumed",
+		RunID:     runID,
+		Data: map[string]any{
+			"from_event": from,
+			"buffered":   total,
+		},
+	})
+
+	w.Header().Set("content-type", "text/event-stream")
+	w.Header().Set("cache-control", "no-cache")
+	w.Header().Set("connection", "keep-alive")
+	w.Header().Set("x-cc-run-id", runID)
+	w.WriteHeader(http.StatusOK)
+	for {
+		for _, frame := range events {
+			if _, err := w.Write(frame); err != nil {
+				return
+			}
+		}
+		from += len(events)
+		flusher.Flush()
+		if done {
+			return
+		}
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+		events, _, done, overflow, changed = buf.since(from)
+		if overflow {
+			_ = writeSSE(w, "error", map[string]any{
+				"type": "error",
+				"error": map[string]any{
+					"type":    "api_error",
+					"message": "stream exceeded the resume buffer",
+				},
+			})
+			flusher.Flush()
+			return
+		}
+	}
+}
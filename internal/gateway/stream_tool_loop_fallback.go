@@ -2,7 +2,6 @@ package gateway
 
 import (
 	"encoding/json"
-	"fmt"
 	"net/http"
 	"strings"
 	"time"
@@ -19,6 +18,11 @@ func (s *server) shouldStreamWithToolLoop(req orchestrator.Request) bool {
 }
 
 func (s *server) streamMessagesWithToolLoop(w http.ResponseWriter, r *http.Request, req orchestrator.Request, outwardModel string) (string, orchestrator.Usage) {
+	// Citations are resolved over the whole loop, so they keep the buffered
+	// replay.
+	if toolLoopStreamsIncrementally(req.Metadata) && !citationsEnabled(req.Metadata) {
+		return s.streamMessagesWithIncrementalToolLoop(w, r, req, outwardModel)
+	}
 	var usage orchestrator.Usage
 	flusher, ok := w.(http.Flusher)
 	if !ok {
@@ -33,13 +37,7 @@ func (s *server) streamMessagesWithToolLoop(w http.ResponseWriter, r *http.Reque
 
 	resp, err := s.completeWithToolLoop(r.Context(), req)
 	if err != nil {
-		_ = writeSSE(w, "error", map[string]any{
-			"type": "error",
-			"error": map[string]any{
-				"type":    "api_error",
-				"message": err.Error(),
-			},
-		})
+		_ = writeSSE(w, "error", upstreamErrorPayload(err))
 		flusher.Flush()
 		return "", usage
 	}
@@ -133,7 +131,7 @@ func (s *server) streamOpenAIChatCompletionsWithToolLoop(w http.ResponseWriter,
 
 	resp, err := s.completeWithToolLoop(r.Context(), req)
 	if err != nil {
-		_ = writeOpenAISSEData(w, fmt.Sprintf(`{"error":{"message":%q}}`, err.Error()))
+		_ = writeOpenAISSEData(w, openAIStreamErrorData(err, false))
 		flusher.Flush()
 		return "", usage
 	}
@@ -280,7 +278,7 @@ func (s *server) streamOpenAIResponsesWithToolLoop(w http.ResponseWriter, r *htt
 
 	resp, err := s.completeWithToolLoop(r.Context(), req)
 	if err != nil {
-		_ = writeOpenAISSEData(w, fmt.Sprintf(`{"type":"error","error":{"message":%q}}`, err.Error()))
+		_ = writeOpenAISSEData(w, openAIStreamErrorData(err, true))
 		flusher.Flush()
 		return "", usage
 	}
@@ -0,0 +1,287 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"ccgateway/internal/orchestrator"
+	"ccgateway/internal/settings"
+)
+
+// toolLoopStreamsIncrementally reports whether a streamed server-loop
+// request asked for the incremental mode (metadata tool_loop_stream).
+func toolLoopStreamsIncrementally(metadata map[string]any) bool {
+	return strings.EqualFold(stringFromAny(metadata["tool_loop_stream"]), settings.ToolLoopStreamIncremental)
+}
+
+// incrementalLoopWriter forwards the rounds of one tool loop as a single
+// Anthropic message. Upstream block indexes restart every round, so each
+// forwarded block gets the next client index.
+type incrementalLoopWriter struct {
+	w         http.ResponseWriter
+	flusher   http.Flusher
+	model     string
+	messageID string
+	next      int
+	text      strings.Builder
+	broken    bool
+}
+
+func (lw *incrementalLoopWriter) write(event string, payload any) bool {
+	if lw.broken {
+		return false
+	}
+	if err := writeSSE(lw.w, event, payload); err != nil {
+		lw.broken = true
+		return false
+	}
+	lw.flusher.Flush()
+	return true
+}
+
+func (lw *incrementalLoopWriter) writeEvent(ev orchestrator.StreamEvent) bool {
+	return lw.write(ev.Type, streamPayloadFromEvent(ev, lw.model, lw.messageID))
+}
+
+// loopRound is what one upstream call of the loop produced.
+type loopRound struct {
+	blocks     []orchestrator.AssistantBlock
+	stopReason string
+	usage      orchestrator.Usage
+}
+
+// forwardRound relays one round's stream to the client and assembles its
+// blocks. Text goes out as it arrives; tool calls the gateway is about to
+// run go out as server_tool_use blocks so clients do not execute them.
+func (lw *incrementalLoopWriter) forwardRound(events <-chan orchestrator.StreamEvent, errs <-chan error) (loopRound, error) {
+	var round loopRound
+	clientIndex := map[int]int{}
+	blockAt := map[int]int{}
+	partial := map[int]*strings.Builder{}
+	for ev := range events {
+		switch ev.Type {
+		case "ping":
+			lw.write("ping", map[string]any{"type": "ping"})
+		case "message_start", "message_delta":
+			// Upstreams report running totals, so the latest count wins.
+			if ev.StopReason != "" {
+				round.stopReason = ev.StopReason
+			}
+			if ev.Usage.InputTokens > 0 {
+				round.usage.InputTokens = ev.Usage.InputTokens
+			}
+			if ev.Usage.OutputTokens > 0 {
+				round.usage.OutputTokens = ev.Usage.OutputTokens
+			}
+		case "content_block_start":
+			if ev.Block.Type != "text" && ev.Block.Type != "tool_use" {
+				continue
+			}
+			idx := lw.next
+			lw.next++
+			clientIndex[ev.Index] = idx
+			blockAt[ev.Index] = len(round.blocks)
+			block := ev.Block
+			if block.Type == "tool_use" {
+				partial[ev.Index] = &strings.Builder{}
+				lw.write("content_block_start", map[string]any{
+					"type":  "content_block_start",
+					"index": idx,
+					"content_block": map[string]any{
+						"type":  "server_tool_use",
+						"id":    block.ID,
+						"name":  block.Name,
+						"input": map[string]any{},
+					},
+				})
+			} else {
+				lw.writeEvent(orchestrator.StreamEvent{Type: "content_block_start", Index: idx, Block: block})
+			}
+			round.blocks = append(round.blocks, block)
+		case "content_block_delta":
+			idx, ok := clientIndex[ev.Index]
+			if !ok {
+				continue
+			}
+			pos := blockAt[ev.Index]
+			if buf, isTool := partial[ev.Index]; isTool {
+				buf.WriteString(ev.DeltaJSON)
+			} else {
+				round.blocks[pos].Text += ev.DeltaText
+				lw.text.WriteString(ev.DeltaText)
+			}
+			ev.Index = idx
+			lw.writeEvent(ev)
+		case "content_block_stop":
+			idx, ok := clientIndex[ev.Index]
+			if !ok {
+				continue
+			}
+			if buf, isTool := partial[ev.Index]; isTool {
+				round.blocks[blockAt[ev.Index]].Input = parseToolInput(buf.String(), round.blocks[blockAt[ev.Index]].Input)
+			}
+			lw.writeEvent(orchestrator.StreamEvent{Type: "content_block_stop", Index: idx})
+		}
+	}
+	if err := <-errs; err != nil {
+		return round, err
+	}
+	return round, nil
+}
+
+// writeToolResults emits one server_tool_result progress block per result
+// the gateway produced between rounds.
+func (lw *incrementalLoopWriter) writeToolResults(results []any) {
+	for _, item := range results {
+		result, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		idx := lw.next
+		lw.next++
+		isError, _ := result["is_error"].(bool)
+		lw.write("content_block_start", map[string]any{
+			"type":  "content_block_start",
+			"index": idx,
+			"content_block": map[string]any{
+				"type":        "server_tool_result",
+				"tool_use_id": stringFromAny(result["tool_use_id"]),
+				"is_error":    isError,
+				"content":     renderToolResultContent(result["content"]),
+			},
+		})
+		lw.write("content_block_stop", map[string]any{"type": "content_block_stop", "index": idx})
+	}
+}
+
+func (s *server) streamMessagesWithIncrementalToolLoop(w http.ResponseWriter, r *http.Request, req orchestrator.Request, outwardModel string) (string, orchestrator.Usage) {
+	var usage orchestrator.Usage
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		s.writeError(w, http.StatusInternalServerError, "api_error", "streaming unsupported")
+		return "", usage
+	}
+
+	w.Header().Set("content-type", "text/event-stream")
+	w.Header().Set("cache-control", "no-cache")
+	w.Header().Set("connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	lw := &incrementalLoopWriter{w: w, flusher: flusher, model: outwardModel, messageID: s.nextID("msg")}
+	if !lw.writeEvent(orchestrator.StreamEvent{Type: "message_start"}) {
+		return "", usage
+	}
+
+	cfg := toolLoopConfigFromMetadata(req.Metadata)
+	working := req
+	working.Messages = append([]orchestrator.Message(nil), req.Messages...)
+	// Blocks are re-indexed on the way out, so raw upstream frames cannot be
+	// passed through.
+	working.Metadata = make(map[string]any, len(req.Metadata)+1)
+	for k, v := range req.Metadata {
+		working.Metadata[k] = v
+	}
+	working.Metadata["strict_stream_passthrough"] = false
+	allowedTools := allowedToolNames(req.Tools)
+	stopReason := "max_turns"
+	executedTools := false
+	rounds := 0
+	defer func() { s.recordToolLoopRounds(req.RunID, rounds) }()
+
+	for step := 0; step < cfg.maxSteps; step++ {
+		callReq := working
+		callReq.Model = planningModel(req.Model, cfg.plannerModel)
+		callReq.System = withToolEmulationSystem(req.System, cfg.emulationMode, req.Tools)
+		round, err := s.runIncrementalRound(r.Context(), lw, callReq, cfg.emulationMode)
+		usage.InputTokens += round.usage.InputTokens
+		usage.OutputTokens += round.usage.OutputTokens
+		if err != nil {
+			lw.write("error", upstreamErrorPayload(err))
+			return lw.text.String(), usage
+		}
+		if lw.broken {
+			return lw.text.String(), usage
+		}
+
+		toolBlocks := toolUseBlocks(round.blocks)
+		if len(toolBlocks) == 0 {
+			stopReason = round.stopReason
+			if executedTools && shouldFinalizeWithPrimaryModel(req.Model, cfg.plannerModel) {
+				finalReq := working
+				finalReq.Model = req.Model
+				final, err := s.runIncrementalRound(r.Context(), lw, finalReq, toolEmulationNative)
+				usage.InputTokens += final.usage.InputTokens
+				usage.OutputTokens += final.usage.OutputTokens
+				if err != nil {
+					lw.write("error", upstreamErrorPayload(err))
+					return lw.text.String(), usage
+				}
+				stopReason = final.stopReason
+			}
+			break
+		}
+
+		executedTools = true
+		rounds++
+		working.Messages = append(working.Messages, orchestrator.Message{
+			Role:    "assistant",
+			Content: assistantBlocksToContent(round.blocks),
+		})
+		results := s.executeToolBlocks(r.Context(), working, toolBlocks, allowedTools)
+		lw.writeToolResults(results)
+		working.Messages = append(working.Messages, orchestrator.Message{
+			Role:    "user",
+			Content: results,
+		})
+	}
+
+	if strings.TrimSpace(stopReason) == "" {
+		stopReason = "end_turn"
+	}
+	if lw.writeEvent(orchestrator.StreamEvent{Type: "message_delta", StopReason: stopReason, Usage: usage}) {
+		lw.writeEvent(orchestrator.StreamEvent{Type: "message_stop"})
+	}
+	return lw.text.String(), usage
+}
+
+// runIncrementalRound streams one native round straight through. Emulated
+// rounds carry tool calls inside text, so they are completed first and
+// replayed with the parsed calls in place of the raw text.
+func (s *server) runIncrementalRound(ctx context.Context, lw *incrementalLoopWriter, req orchestrator.Request, emulationMode string) (loopRound, error) {
+	if normalizeToolEmulationMode(emulationMode) == toolEmulationNative {
+		events, errs := s.orchestrator.Stream(ctx, req)
+		return lw.forwardRound(events, errs)
+	}
+	resp, err := s.orchestrator.Complete(ctx, req)
+	if err != nil {
+		return loopRound{}, err
+	}
+	if len(toolUseBlocks(resp.Blocks)) == 0 {
+		if parsed, parsedBy := emulatedToolUseBlocks(resp.Blocks, emulationMode); len(parsed) > 0 {
+			s.appendToolEmulationEvent(req, emulationMode, parsedBy, parsed)
+			resp.Blocks = parsed
+		}
+	}
+	errs := make(chan error)
+	close(errs)
+	return lw.forwardRound(responseStreamEvents(resp, true), errs)
+}
+
+// parseToolInput decodes the streamed input JSON of a tool call, keeping
+// the input from content_block_start when nothing usable was streamed.
+func parseToolInput(raw string, fallback map[string]any) map[string]any {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		if fallback == nil {
+			return map[string]any{}
+		}
+		return fallback
+	}
+	var input map[string]any
+	if err := json.Unmarshal([]byte(raw), &input); err != nil {
+		return map[string]any{"_raw": raw}
+	}
+	return input
+}
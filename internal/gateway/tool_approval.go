@@ -0,0 +1,290 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"ccgateway/internal/auditlog"
+	"ccgateway/internal/ccevent"
+	"ccgateway/internal/orchestrator"
+	"ccgateway/internal/settings"
+)
+
+// toolApproval is a server-loop tool call waiting for a reviewer.
+type toolApproval struct {
+	RunID       string         `json:"run_id"`
+	SessionID   string         `json:"session_id,omitempty"`
+	ProjectID   string         `json:"project_id"`
+	ToolCallID  string         `json:"tool_call_id"`
+	Tool        string         `json:"tool"`
+	Input       map[string]any `json:"input"`
+	RequestedAt time.Time      `json:"requested_at"`
+	ExpiresAt   time.Time      `json:"expires_at"`
+
+	decided chan toolApprovalDecision
+}
+
+type toolApprovalDecision struct {
+	Approve bool
+	Input   map[string]any
+	Reason  string
+	Source  string
+	// DecidedBy identifies the reviewer who decided, for reviewer
+	// decisions.
+	DecidedBy string
+}
+
+// toolApprovals tracks pending approvals by run and tool call ID.
+type toolApprovals struct {
+	mu      sync.Mutex
+	pending map[string]*toolApproval
+}
+
+func newToolApprovals() *toolApprovals {
+	return &toolApprovals{pending: map[string]*toolApproval{}}
+}
+
+func toolApprovalKey(runID, callID string) string {
+	return runID + "/" + callID
+}
+
+func (a *toolApprovals) open(p *toolApproval) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	key := toolApprovalKey(p.RunID, p.ToolCallID)
+	if _, exists := a.pending[key]; exists {
+		return false
+	}
+	a.pending[key] = p
+	return true
+}
+
+func (a *toolApprovals) close(p *toolApproval) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	key := toolApprovalKey(p.RunID, p.ToolCallID)
+	if a.pending[key] == p {
+		delete(a.pending, key)
+	}
+}
+
+// resolve hands d to the waiting loop. It reports false when no call
+// visible to projectID is pending under runID and callID; an empty
+// projectID sees every project's calls.
+func (a *toolApprovals) resolve(runID, callID, projectID string, d toolApprovalDecision) (toolApproval, bool) {
+	a.mu.Lock()
+	p, ok := a.pending[toolApprovalKey(runID, callID)]
+	ok = ok && (projectID == "" || p.ProjectID == projectID)
+	if ok {
+		delete(a.pending, toolApprovalKey(runID, callID))
+	}
+	a.mu.Unlock()
+	if !ok {
+		return toolApproval{}, false
+	}
+	p.decided <- d
+	return *p, true
+}
+
+// list returns runID's pending calls visible to projectID, as for
+// resolve.
+func (a *toolApprovals) list(runID, projectID string) []toolApproval {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	out := []toolApproval{}
+	for _, p := range a.pending {
+		if p.RunID == runID && (projectID == "" || p.ProjectID == projectID) {
+			out = append(out, *p)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].RequestedAt.Before(out[j].RequestedAt)
+	})
+	return out
+}
+
+// toolNeedsApproval reports whether tool matches one of the configured
+// approval patterns.
+func toolNeedsApproval(cfg settings.ToolApprovalSettings, tool string) bool {
+	tool = strings.ToLower(strings.TrimSpace(tool))
+	for _, pattern := range cfg.Tools {
+		if ok, _ := path.Match(pattern, tool); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// awaitToolApproval blocks a server-loop tool call that needs approval
+// until a reviewer decides, the approval times out or the request ends.
+// It returns the input to run the tool with, or the tool result text to
+// send back instead when the call was denied.
+func (s *server) awaitToolApproval(ctx context.Context, req orchestrator.Request, tool, callID string, input map[string]any) (map[string]any, string) {
+	cfg, ok := req.Metadata["tool_approval"].(settings.ToolApprovalSettings)
+	if !ok || !toolNeedsApproval(cfg, tool) {
+		return input, ""
+	}
+	if strings.TrimSpace(req.RunID) == "" {
+		return nil, "tool call requires approval but the request has no run to approve it on"
+	}
+	sessionID, _ := req.Metadata["session_id"].(string)
+	now := time.Now().UTC()
+	timeout := time.Duration(cfg.TimeoutSeconds) * time.Second
+	pending := &toolApproval{
+		RunID:       req.RunID,
+		SessionID:   sessionID,
+		ProjectID:   projectIDFromContext(ctx),
+		ToolCallID:  callID,
+		Tool:        tool,
+		Input:       input,
+		RequestedAt: now,
+		ExpiresAt:   now.Add(timeout),
+		decided:     make(chan toolApprovalDecision, 1),
+	}
+	if !s.toolApprovals.open(pending) {
+		return nil, "a call with this id is already waiting for approval"
+	}
+	defer s.toolApprovals.close(pending)
+	s.appendEvent(ccevent.AppendInput{
+		EventType: "tool.approval_required",
+		SessionID: sessionID,
+		RunID:     req.RunID,
+		Data: map[string]any{
+			"tool":            tool,
+			"tool_use_id":     callID,
+			"input":           input,
+			"timeout_seconds": cfg.TimeoutSeconds,
+			"expires_at":      pending.ExpiresAt,
+		},
+	})
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	var decision toolApprovalDecision
+	select {
+	case decision = <-pending.decided:
+	case <-timer.C:
+		decision = toolApprovalDecision{Approve: cfg.OnTimeout == settings.ToolApprovalApprove, Source: "timeout"}
+	case <-ctx.Done():
+		decision = toolApprovalDecision{Reason: "request ended before approval", Source: "cancelled"}
+	}
+
+	data := map[string]any{
+		"tool":        tool,
+		"tool_use_id": callID,
+		"approved":    decision.Approve,
+		"source":      decision.Source,
+		"edited":      decision.Input != nil,
+	}
+	if decision.Reason != "" {
+		data["reason"] = decision.Reason
+	}
+	if decision.DecidedBy != "" {
+		data["decided_by"] = decision.DecidedBy
+	}
+	s.appendEvent(ccevent.AppendInput{
+		EventType: "tool.approval_resolved",
+		SessionID: sessionID,
+		RunID:     req.RunID,
+		Data:      data,
+	})
+	if !decision.Approve {
+		text := "tool call was denied by the reviewer"
+		if decision.Source == "timeout" {
+			text = "tool call was not approved in time"
+		}
+		if decision.Reason != "" {
+			text += ": " + decision.Reason
+		}
+		return nil, text
+	}
+	if decision.Input != nil {
+		return decision.Input, ""
+	}
+	return input, ""
+}
+
+// approvalReviewer returns the project whose approvals the caller may see
+// and decide, empty for the admin token, and the caller's identity for
+// the audit trail.
+func (s *server) approvalReviewer(r *http.Request) (projectID, decidedBy string) {
+	if admin := strings.TrimSpace(s.adminToken); admin != "" && bearerToken(r.Header.Get("authorization")) == admin {
+		return "", "admin:" + auditlog.ActorID(admin)
+	}
+	decidedBy = requestOwner(r.Context())
+	if decidedBy == "" {
+		decidedBy = "anonymous"
+	}
+	return projectIDFromContext(r.Context()), decidedBy
+}
+
+// handleCCRunApprovals lists a run's pending tool approvals or decides one.
+// Only the run's project, or the admin token, sees and decides them.
+// GET  /v1/cc/runs/{id}/approvals
+// POST /v1/cc/runs/{id}/approvals/{tool_call_id}
+func (s *server) handleCCRunApprovals(w http.ResponseWriter, r *http.Request, runID, callID string) {
+	projectID, decidedBy := s.approvalReviewer(r)
+	if callID == "" {
+		if r.Method != http.MethodGet {
+			s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+			return
+		}
+		items := s.toolApprovals.list(runID, projectID)
+		w.Header().Set("content-type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"data":  items,
+			"count": len(items),
+		})
+		return
+	}
+	if r.Method != http.MethodPost {
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+		return
+	}
+	var req struct {
+		Decision string         `json:"decision"`
+		Input    map[string]any `json:"input"`
+		Reason   string         `json:"reason"`
+	}
+	if err := decodeJSONBodyStrict(r, &req, false); err != nil {
+		s.reportRequestDecodeIssue(r, err)
+		s.writeError(w, http.StatusBadRequest, "invalid_request_error", "invalid JSON body")
+		return
+	}
+	decision := toolApprovalDecision{Reason: strings.TrimSpace(req.Reason), Source: "reviewer", DecidedBy: decidedBy}
+	switch strings.ToLower(strings.TrimSpace(req.Decision)) {
+	case "approve":
+		decision.Approve = true
+		decision.Input = req.Input
+	case "deny":
+		if req.Input != nil {
+			s.writeError(w, http.StatusBadRequest, "invalid_request_error", "input can only be edited when approving")
+			return
+		}
+	default:
+		s.writeError(w, http.StatusBadRequest, "invalid_request_error", "decision must be approve or deny")
+		return
+	}
+	approval, ok := s.toolApprovals.resolve(runID, callID, projectID, decision)
+	if !ok {
+		s.writeError(w, http.StatusNotFound, "not_found_error", "no pending approval for this tool call")
+		return
+	}
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"run_id":       approval.RunID,
+		"tool_call_id": approval.ToolCallID,
+		"tool":         approval.Tool,
+		"decision":     strings.ToLower(strings.TrimSpace(req.Decision)),
+		"edited":       decision.Input != nil,
+		"decided_by":   decidedBy,
+	})
+}
@@ -0,0 +1,123 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"ccgateway/internal/ccevent"
+	"ccgateway/internal/orchestrator"
+	"ccgateway/internal/requestctx"
+	"ccgateway/internal/toolcatalog"
+	"ccgateway/internal/toolruntime"
+)
+
+// toolRetryBackoff is the pause before the n-th retry, multiplied by n.
+const toolRetryBackoff = 100 * time.Millisecond
+
+var errToolTimeout = errors.New("tool call timed out")
+
+// toolExecPolicy returns the catalog entry carrying the execution policy
+// for name in the request's project.
+func (s *server) toolExecPolicy(ctx context.Context, name string) toolcatalog.ToolSpec {
+	if s.toolCatalog == nil {
+		return toolcatalog.ToolSpec{}
+	}
+	if scoped, ok := s.toolCatalog.(interface {
+		GetForProject(projectID, name string) (toolcatalog.ToolSpec, bool)
+	}); ok {
+		spec, _ := scoped.GetForProject(requestctx.ProjectID(ctx), name)
+		return spec
+	}
+	if cat, ok := s.toolCatalog.(interface {
+		Get(name string) (toolcatalog.ToolSpec, bool)
+	}); ok {
+		spec, _ := cat.Get(name)
+		return spec
+	}
+	return toolcatalog.ToolSpec{}
+}
+
+// executeTool runs one server-loop tool call under the tool's catalog
+// policy: each attempt is bounded by timeout_ms and idempotent tools are
+// retried on the failures listed in retry_on. The policy wraps the whole
+// executor chain, so it covers plugin, built-in and MCP fallback tools.
+func (s *server) executeTool(ctx context.Context, req orchestrator.Request, call toolruntime.Call) (toolruntime.Result, error) {
+	spec := s.toolExecPolicy(ctx, call.Name)
+	for attempt := 0; ; attempt++ {
+		result, err := s.executeToolAttempt(ctx, call, spec.TimeoutMS)
+		kind := ""
+		switch {
+		case err == nil && result.IsError:
+			kind = toolcatalog.RetryOnIsError
+		case errors.Is(err, errToolTimeout):
+			kind = toolcatalog.RetryOnTimeout
+		case err != nil && !errors.Is(err, toolruntime.ErrToolNotImplemented):
+			kind = toolcatalog.RetryOnError
+		}
+		if kind == "" || attempt >= spec.Retries || !spec.ShouldRetry(kind) || ctx.Err() != nil {
+			return result, err
+		}
+		reason := renderToolResultContent(result.Content)
+		if err != nil {
+			reason = err.Error()
+		}
+		s.appendToolRetryEvent(req, call, attempt+1, kind, reason)
+		select {
+		case <-ctx.Done():
+			return result, err
+		case <-time.After(time.Duration(attempt+1) * toolRetryBackoff):
+		}
+	}
+}
+
+func (s *server) executeToolAttempt(ctx context.Context, call toolruntime.Call, timeoutMS int) (toolruntime.Result, error) {
+	if timeoutMS <= 0 {
+		return s.toolExecutor.Execute(ctx, call)
+	}
+	attemptCtx, cancel := context.WithTimeout(ctx, time.Duration(timeoutMS)*time.Millisecond)
+	defer cancel()
+	type outcome struct {
+		result toolruntime.Result
+		err    error
+	}
+	// Executors that ignore ctx would otherwise stall the run, so the call
+	// is abandoned rather than awaited once the deadline passes.
+	done := make(chan outcome, 1)
+	go func() {
+		result, err := s.toolExecutor.Execute(attemptCtx, call)
+		done <- outcome{result: result, err: err}
+	}()
+	select {
+	case out := <-done:
+		if out.err != nil && errors.Is(attemptCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+			return toolruntime.Result{}, fmt.Errorf("%w: %s after %dms", errToolTimeout, call.Name, timeoutMS)
+		}
+		return out.result, out.err
+	case <-attemptCtx.Done():
+		if ctx.Err() != nil {
+			return toolruntime.Result{}, ctx.Err()
+		}
+		return toolruntime.Result{}, fmt.Errorf("%w: %s after %dms", errToolTimeout, call.Name, timeoutMS)
+	}
+}
+
+func (s *server) appendToolRetryEvent(req orchestrator.Request, call toolruntime.Call, attempt int, kind, reason string) {
+	if r := []rune(reason); len(r) > 512 {
+		reason = string(r[:512])
+	}
+	sessionID, _ := req.Metadata["session_id"].(string)
+	s.appendEvent(ccevent.AppendInput{
+		EventType: "tool.retried",
+		SessionID: sessionID,
+		RunID:     req.RunID,
+		Data: map[string]any{
+			"tool":        call.Name,
+			"tool_use_id": call.ID,
+			"attempt":     attempt,
+			"retry_on":    kind,
+			"reason":      reason,
+		},
+	})
+}
@@ -44,12 +44,18 @@ func (s *server) completeWithToolLoop(ctx context.Context, req orchestrator.Requ
 	allowedTools := allowedToolNames(req.Tools)
 	totalUsage := orchestrator.Usage{}
 	executedTools := false
+	rounds := 0
+	defer func() { s.recordToolLoopRounds(req.RunID, rounds) }()
 	var last orchestrator.Response
+	var citations *citationTracker
+	if citationsEnabled(req.Metadata) {
+		citations = &citationTracker{}
+	}
 
 	for i := 0; i < cfg.maxSteps; i++ {
 		callReq := working
 		callReq.Model = planningModel(req.Model, cfg.plannerModel)
-		callReq.System = withToolEmulationSystem(req.System, cfg.emulationMode, req.Tools)
+		callReq.System = citations.system(withToolEmulationSystem(req.System, cfg.emulationMode, req.Tools))
 
 		resp, err := s.orchestrator.Complete(ctx, callReq)
 		if err != nil {
@@ -71,7 +77,7 @@ func (s *server) completeWithToolLoop(ctx context.Context, req orchestrator.Requ
 			if executedTools && shouldFinalizeWithPrimaryModel(req.Model, cfg.plannerModel) {
 				finalReq := working
 				finalReq.Model = req.Model
-				finalReq.System = req.System
+				finalReq.System = citations.system(req.System)
 				finalResp, err := s.orchestrator.Complete(ctx, finalReq)
 				if err != nil {
 					return orchestrator.Response{}, err
@@ -79,13 +85,16 @@ func (s *server) completeWithToolLoop(ctx context.Context, req orchestrator.Requ
 				totalUsage.InputTokens += finalResp.Usage.InputTokens
 				totalUsage.OutputTokens += finalResp.Usage.OutputTokens
 				finalResp.Usage = totalUsage
+				finalResp.Citations = citations.resolve(finalResp.Blocks)
 				return finalResp, nil
 			}
 			last.Usage = totalUsage
+			last.Citations = citations.resolve(last.Blocks)
 			return last, nil
 		}
 
 		executedTools = true
+		rounds++
 		assistantBlocks := resp.Blocks
 		if parsedBy != "" {
 			assistantBlocks = toolBlocks
@@ -94,9 +103,11 @@ func (s *server) completeWithToolLoop(ctx context.Context, req orchestrator.Requ
 			Role:    "assistant",
 			Content: assistantBlocksToContent(assistantBlocks),
 		})
+		results := s.executeToolBlocks(ctx, working, toolBlocks, allowedTools)
+		citations.labelResults(results, toolNamesByID(toolBlocks))
 		working.Messages = append(working.Messages, orchestrator.Message{
 			Role:    "user",
-			Content: s.executeToolBlocks(ctx, working, toolBlocks, allowedTools),
+			Content: results,
 		})
 	}
 
@@ -643,6 +654,18 @@ func allowedToolNames(tools []orchestrator.Tool) map[string]struct{} {
 	return out
 }
 
+func toolNamesByID(calls []orchestrator.AssistantBlock) map[string]string {
+	out := make(map[string]string, len(calls))
+	for _, call := range calls {
+		id := strings.TrimSpace(call.ID)
+		if id == "" {
+			id = "toolu_auto"
+		}
+		out[id] = strings.TrimSpace(call.Name)
+	}
+	return out
+}
+
 func toolUseBlocks(blocks []orchestrator.AssistantBlock) []orchestrator.AssistantBlock {
 	out := make([]orchestrator.AssistantBlock, 0, len(blocks))
 	for _, b := range blocks {
@@ -685,6 +708,11 @@ func assistantBlocksToContent(blocks []orchestrator.AssistantBlock) []any {
 func (s *server) executeToolBlocks(ctx context.Context, req orchestrator.Request, calls []orchestrator.AssistantBlock, allowed map[string]struct{}) []any {
 	out := make([]any, 0, len(calls))
 	aliases := toolAliasesFromMetadata(req.Metadata)
+	sessionID := ""
+	if req.Metadata != nil {
+		sessionID = strings.TrimSpace(stringFromAny(req.Metadata["session_id"]))
+	}
+	state := s.toolState.Session(sessionID)
 	for _, call := range calls {
 		originalName := strings.ToLower(strings.TrimSpace(call.Name))
 		name := originalName
@@ -704,21 +732,35 @@ func (s *server) executeToolBlocks(ctx context.Context, req orchestrator.Request
 			continue
 		}
 
-		result, err := s.toolExecutor.Execute(ctx, toolruntime.Call{
-			ID:    callID,
-			Name:  name,
-			Input: call.Input,
+		input, denied := s.awaitToolApproval(ctx, req, name, callID, call.Input)
+		if denied != "" {
+			out = append(out, toolResultBlock(callID, denied, true))
+			continue
+		}
+		result, err := s.executeTool(ctx, req, toolruntime.Call{
+			ID:        callID,
+			Name:      name,
+			Input:     input,
+			SessionID: sessionID,
+			State:     state,
+			RunID:     req.RunID,
 		})
 		if err != nil {
 			reason := "tool_execution_error"
 			if errors.Is(err, toolruntime.ErrToolNotImplemented) {
 				reason = "tool_not_implemented"
+			} else if errors.Is(err, errToolTimeout) {
+				reason = "tool_timeout"
 			}
 			s.appendToolGapEvent(req, call.Name, call.Input, reason)
-			out = append(out, toolResultBlock(callID, err.Error(), true))
+			content := s.maskToolResult(req, name, callID, err.Error())
+			s.appendToolExecutedEvent(req, sessionID, name, callID, input, content, true)
+			out = append(out, toolResultBlock(callID, content, true))
 			continue
 		}
-		content := renderToolResultContent(result.Content)
+		content := s.maskToolResult(req, name, callID, renderToolResultContent(result.Content))
+		content = s.limitToolResult(ctx, req, name, callID, content)
+		s.appendToolExecutedEvent(req, sessionID, name, callID, input, content, result.IsError)
 		out = append(out, toolResultBlock(callID, content, result.IsError))
 	}
 	if len(out) == 0 {
@@ -727,6 +769,27 @@ func (s *server) executeToolBlocks(ctx context.Context, req orchestrator.Request
 	return out
 }
 
+// toolExecutedOutputChars caps the tool output kept on tool.executed
+// events; the full result only goes to the model.
+const toolExecutedOutputChars = 4000
+
+// appendToolExecutedEvent records one server-side tool call with its
+// input and (masked, truncated) output, so transcripts can show it.
+func (s *server) appendToolExecutedEvent(req orchestrator.Request, sessionID, tool, callID string, input map[string]any, output string, isError bool) {
+	s.appendEvent(ccevent.AppendInput{
+		EventType: "tool.executed",
+		SessionID: sessionID,
+		RunID:     req.RunID,
+		Data: map[string]any{
+			"tool":        tool,
+			"tool_use_id": callID,
+			"input":       input,
+			"output":      truncateText(output, toolExecutedOutputChars),
+			"is_error":    isError,
+		},
+	})
+}
+
 func (s *server) appendToolEmulationEvent(req orchestrator.Request, emulationMode, parser string, calls []orchestrator.AssistantBlock) {
 	if len(calls) == 0 {
 		return
@@ -804,6 +867,7 @@ func (s *server) appendToolGapEvent(req orchestrator.Request, toolName string, i
 			"input":           input,
 		},
 	})
+	s.watchToolGaps(toolName)
 }
 
 func (s *server) appendToolAliasEvent(req orchestrator.Request, fromName, toName string, input map[string]any) {
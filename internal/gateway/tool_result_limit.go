@@ -0,0 +1,114 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"ccgateway/internal/ccevent"
+	"ccgateway/internal/orchestrator"
+	"ccgateway/internal/settings"
+)
+
+const toolResultSummaryPrompt = "Condense the tool output below so a coding agent can keep working from it. " +
+	"Keep file paths, identifiers, numbers, error messages and anything that looks like an answer verbatim; " +
+	"drop repetition and boilerplate. Reply with the condensed output only."
+
+// toolResultLimitFor returns the cap for tool from the "tool_result_limits"
+// metadata, falling back to the "*" entry.
+func toolResultLimitFor(metadata map[string]any, tool string) (settings.ToolResultLimit, bool) {
+	limits, _ := metadata["tool_result_limits"].(map[string]settings.ToolResultLimit)
+	if len(limits) == 0 {
+		return settings.ToolResultLimit{}, false
+	}
+	if limit, ok := limits[strings.ToLower(strings.TrimSpace(tool))]; ok {
+		return limit, true
+	}
+	limit, ok := limits["*"]
+	return limit, ok
+}
+
+// limitToolResult applies the tool's result cap before the result goes back
+// into the loop. Truncated content carries a marker paragraph saying how
+// much was kept, and each truncation is recorded as tool.result_truncated.
+func (s *server) limitToolResult(ctx context.Context, req orchestrator.Request, tool, callID, content string) string {
+	limit, ok := toolResultLimitFor(req.Metadata, tool)
+	if !ok || limit.MaxChars <= 0 {
+		return content
+	}
+	runes := []rune(content)
+	if len(runes) <= limit.MaxChars {
+		return content
+	}
+
+	strategy := limit.Strategy
+	var out, summaryErr string
+	if strategy == settings.ToolResultSummarize {
+		summary, err := s.summarizeToolResult(ctx, req, limit, content)
+		if err == nil && len([]rune(summary)) <= limit.MaxChars {
+			out = fmt.Sprintf("[tool result summarized from %d characters]\n\n%s", len(runes), summary)
+		} else {
+			if err == nil {
+				err = fmt.Errorf("summary exceeds %d characters", limit.MaxChars)
+			}
+			summaryErr = err.Error()
+			strategy = settings.ToolResultHead
+		}
+	}
+	switch strategy {
+	case settings.ToolResultTail:
+		out = fmt.Sprintf("[tool result truncated: kept the last %d of %d characters]\n\n%s", limit.MaxChars, len(runes), string(runes[len(runes)-limit.MaxChars:]))
+	case settings.ToolResultHead:
+		out = fmt.Sprintf("%s\n\n[tool result truncated: kept the first %d of %d characters]", string(runes[:limit.MaxChars]), limit.MaxChars, len(runes))
+	}
+
+	data := map[string]any{
+		"tool":           tool,
+		"tool_use_id":    callID,
+		"strategy":       strategy,
+		"max_chars":      limit.MaxChars,
+		"original_chars": len(runes),
+		"result_chars":   len([]rune(out)),
+	}
+	if summaryErr != "" {
+		data["requested_strategy"] = settings.ToolResultSummarize
+		data["summarize_error"] = summaryErr
+	}
+	sessionID, _ := req.Metadata["session_id"].(string)
+	s.appendEvent(ccevent.AppendInput{
+		EventType: "tool.result_truncated",
+		SessionID: sessionID,
+		RunID:     req.RunID,
+		Data:      data,
+	})
+	return out
+}
+
+func (s *server) summarizeToolResult(ctx context.Context, req orchestrator.Request, limit settings.ToolResultLimit, content string) (string, error) {
+	if s.orchestrator == nil {
+		return "", fmt.Errorf("no orchestrator configured")
+	}
+	model := limit.Model
+	if model == "" {
+		model = req.Model
+	}
+	// Roughly four characters per token, with room for the model to stop
+	// on its own.
+	maxTokens := limit.MaxChars/4 + 64
+	resp, err := s.orchestrator.Complete(ctx, orchestrator.Request{
+		RunID:     req.RunID,
+		Model:     model,
+		MaxTokens: maxTokens,
+		System:    fmt.Sprintf("%s Stay under %d characters.", toolResultSummaryPrompt, limit.MaxChars),
+		Messages:  []orchestrator.Message{{Role: "user", Content: content}},
+		Metadata:  map[string]any{"mode": stringFromAny(req.Metadata["mode"]), "purpose": "tool_result_summary"},
+	})
+	if err != nil {
+		return "", err
+	}
+	summary := strings.TrimSpace(collectResponseText(resp))
+	if summary == "" {
+		return "", fmt.Errorf("empty tool result summary")
+	}
+	return summary, nil
+}
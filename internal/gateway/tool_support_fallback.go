@@ -11,28 +11,11 @@ import (
 )
 
 func (s *server) applyToolSupportFallback(req orchestrator.Request) orchestrator.Request {
-	if len(req.Tools) == 0 {
-		return req
-	}
-
-	mode := strings.ToLower(strings.TrimSpace(stringFromAny(req.Metadata["tool_fallback_mode"])))
-	switch mode {
-	case "off", "disabled", "none":
-		return req
-	}
-
-	force := mode == "force" || mode == "on" || mode == "always"
-	if !force && hasServerToolLoopMode(stringFromAny(req.Metadata["tool_loop_mode"])) {
+	reason, ok := s.toolSupportFallbackReason(req)
+	if !ok {
 		return req
 	}
 
-	supported, known := s.resolveToolsSupport(req)
-	if !force {
-		if !known || supported {
-			return req
-		}
-	}
-
 	out := req
 	meta := map[string]any{}
 	for k, v := range req.Metadata {
@@ -46,10 +29,6 @@ func (s *server) applyToolSupportFallback(req orchestrator.Request) orchestrator
 		meta["tool_emulation_mode"] = "hybrid"
 	}
 
-	reason := "forced"
-	if !force {
-		reason = "upstream_tools_unsupported"
-	}
 	meta["tool_fallback_applied"] = true
 	meta["tool_fallback_reason"] = reason
 	out.Metadata = meta
@@ -57,6 +36,29 @@ func (s *server) applyToolSupportFallback(req orchestrator.Request) orchestrator
 	return out
 }
 
+// toolSupportFallbackReason reports whether req's tools fall back to the
+// server tool loop, and why: "forced" or "upstream_tools_unsupported".
+func (s *server) toolSupportFallbackReason(req orchestrator.Request) (string, bool) {
+	if len(req.Tools) == 0 {
+		return "", false
+	}
+
+	mode := strings.ToLower(strings.TrimSpace(stringFromAny(req.Metadata["tool_fallback_mode"])))
+	switch mode {
+	case "off", "disabled", "none":
+		return "", false
+	case "force", "on", "always":
+		return "forced", true
+	}
+	if hasServerToolLoopMode(stringFromAny(req.Metadata["tool_loop_mode"])) {
+		return "", false
+	}
+	if supported, known := s.resolveToolsSupport(req); !known || supported {
+		return "", false
+	}
+	return "upstream_tools_unsupported", true
+}
+
 func hasServerToolLoopMode(mode string) bool {
 	mode = strings.ToLower(strings.TrimSpace(mode))
 	switch mode {
@@ -1,16 +1,26 @@
 package gateway
 
 type MessagesRequest struct {
-	Model       string           `json:"model"`
-	MaxTokens   int              `json:"max_tokens"`
-	Messages    []MessageParam   `json:"messages"`
-	System      any              `json:"system,omitempty"`
-	Stream      bool             `json:"stream,omitempty"`
-	Temperature *float64         `json:"temperature,omitempty"`
-	TopP        *float64         `json:"top_p,omitempty"`
-	Tools       []ToolDefinition `json:"tools,omitempty"`
-	ToolChoice  any              `json:"tool_choice,omitempty"`
-	Metadata    map[string]any   `json:"metadata,omitempty"`
+	Model        string           `json:"model"`
+	MaxTokens    int              `json:"max_tokens"`
+	Messages     []MessageParam   `json:"messages"`
+	System       any              `json:"system,omitempty"`
+	Stream       bool             `json:"stream,omitempty"`
+	Temperature  *float64         `json:"temperature,omitempty"`
+	TopP         *float64         `json:"top_p,omitempty"`
+	Tools        []ToolDefinition `json:"tools,omitempty"`
+	ToolChoice   any              `json:"tool_choice,omitempty"`
+	OutputFormat *OutputFormat    `json:"output_format,omitempty"`
+	Metadata     map[string]any   `json:"metadata,omitempty"`
+}
+
+// OutputFormat is the Anthropic structured-output request field. OpenAI
+// response_format / text.format values are converted into it.
+type OutputFormat struct {
+	Type   string         `json:"type"`
+	Name   string         `json:"name,omitempty"`
+	Schema map[string]any `json:"schema,omitempty"`
+	Strict bool           `json:"strict,omitempty"`
 }
 
 type MessageParam struct {
@@ -19,20 +29,44 @@ type MessageParam struct {
 }
 
 type ToolDefinition struct {
+	// Type is set for Anthropic server tools (e.g. "web_search_20250305")
+	// and built-in client tools (e.g. "bash_20250124"), which carry no
+	// input_schema.
+	Type        string         `json:"type,omitempty"`
 	Name        string         `json:"name"`
 	Description string         `json:"description,omitempty"`
 	InputSchema map[string]any `json:"input_schema"`
+	// Display fields of the built-in computer tool.
+	DisplayWidthPx  int `json:"display_width_px,omitempty"`
+	DisplayHeightPx int `json:"display_height_px,omitempty"`
+	DisplayNumber   int `json:"display_number,omitempty"`
 }
 
 type MessageResponse struct {
-	ID           string         `json:"id"`
-	Type         string         `json:"type"`
-	Role         string         `json:"role"`
-	Model        string         `json:"model"`
-	Content      []ContentBlock `json:"content"`
-	StopReason   string         `json:"stop_reason,omitempty"`
-	StopSequence *string        `json:"stop_sequence"`
-	Usage        UsageResponse  `json:"usage"`
+	ID           string            `json:"id"`
+	Type         string            `json:"type"`
+	Role         string            `json:"role"`
+	Model        string            `json:"model"`
+	Content      []ContentBlock    `json:"content"`
+	StopReason   string            `json:"stop_reason,omitempty"`
+	StopSequence *string           `json:"stop_sequence"`
+	Usage        UsageResponse     `json:"usage"`
+	Metadata     *ResponseMetadata `json:"metadata,omitempty"`
+}
+
+// ResponseMetadata carries gateway additions to a response body.
+type ResponseMetadata struct {
+	Citations []Citation `json:"citations,omitempty"`
+}
+
+// Citation is a tool result chunk the answer cites inline as [SourceID].
+type Citation struct {
+	SourceID  string `json:"source_id"`
+	ToolUseID string `json:"tool_use_id"`
+	ToolName  string `json:"tool_name,omitempty"`
+	Chunk     int    `json:"chunk"`
+	Text      string `json:"text"`
+	Mentions  int    `json:"mentions"`
 }
 
 type ContentBlock struct {
@@ -49,9 +83,10 @@ type UsageResponse struct {
 }
 
 type CountTokensRequest struct {
-	Model    string         `json:"model"`
-	Messages []MessageParam `json:"messages"`
-	System   any            `json:"system,omitempty"`
+	Model    string           `json:"model"`
+	Messages []MessageParam   `json:"messages"`
+	System   any              `json:"system,omitempty"`
+	Tools    []ToolDefinition `json:"tools,omitempty"`
 }
 
 type CountTokensResponse struct {
@@ -64,6 +99,10 @@ type ErrorEnvelope struct {
 }
 
 type ErrorResponse struct {
-	Type    string `json:"type"`
-	Message string `json:"message"`
+	Type string `json:"type"`
+	// Code is a machine-readable reason, set for normalized upstream
+	// errors such as rate_limited or model_not_found.
+	Code    string         `json:"code,omitempty"`
+	Message string         `json:"message"`
+	Details map[string]any `json:"details,omitempty"`
 }
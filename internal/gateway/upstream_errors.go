@@ -0,0 +1,67 @@
+package gateway
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"ccgateway/internal/upstream"
+)
+
+// writeUpstreamError answers a failed upstream call with the status, error
+// type and code from the provider error catalog, and returns the status.
+func (s *server) writeUpstreamError(w http.ResponseWriter, err error) int {
+	norm := upstream.ClassifyError(err)
+	if norm.RetryAfter != "" {
+		w.Header().Set("retry-after", norm.RetryAfter)
+	}
+	s.logError(w, norm.Status, norm.Type, err.Error())
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(norm.Status)
+	_ = json.NewEncoder(w).Encode(ErrorEnvelope{
+		Type:  "error",
+		Error: upstreamErrorResponse(norm, err),
+	})
+	return norm.Status
+}
+
+// upstreamErrorPayload is the SSE error event for an upstream failure once
+// the stream has started and the status can no longer change.
+func upstreamErrorPayload(err error) map[string]any {
+	return map[string]any{
+		"type":  "error",
+		"error": upstreamErrorResponse(upstream.ClassifyError(err), err),
+	}
+}
+
+// openAIStreamErrorData is the same error as an OpenAI stream chunk; the
+// Responses API also tags it with "type":"error".
+func openAIStreamErrorData(err error, typed bool) string {
+	payload := upstreamErrorPayload(err)
+	if !typed {
+		delete(payload, "type")
+	}
+	raw, _ := json.Marshal(payload)
+	return string(raw)
+}
+
+func upstreamErrorResponse(norm upstream.NormalizedError, err error) ErrorResponse {
+	details := map[string]any{}
+	if norm.Adapter != "" {
+		details["adapter"] = norm.Adapter
+	}
+	if norm.UpstreamStatus != 0 {
+		details["upstream_status"] = norm.UpstreamStatus
+	}
+	if norm.ProviderCode != "" {
+		details["provider_code"] = norm.ProviderCode
+	}
+	if len(details) == 0 {
+		details = nil
+	}
+	return ErrorResponse{
+		Type:    norm.Type,
+		Code:    norm.Code,
+		Message: err.Error(),
+		Details: details,
+	}
+}
@@ -0,0 +1,168 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"ccgateway/internal/auditlog"
+	"ccgateway/internal/auth"
+	"ccgateway/internal/ccevent"
+	"ccgateway/internal/token"
+)
+
+// requestUserID returns the owner of the user token that authenticated the
+// request; admin-token and open-mode requests have none.
+func requestUserID(ctx context.Context) string {
+	tk, ok := ctx.Value(tokenContextKey).(*token.Token)
+	if !ok || tk == nil {
+		return ""
+	}
+	return strings.TrimSpace(tk.UserID)
+}
+
+// requestOwner scopes caller-owned resources such as batches and files
+// to the token owner, or to the token itself when it has no user; admin
+// and open-mode callers share one scope.
+func requestOwner(ctx context.Context) string {
+	tk, ok := ctx.Value(tokenContextKey).(*token.Token)
+	if !ok || tk == nil {
+		return ""
+	}
+	if userID := strings.TrimSpace(tk.UserID); userID != "" {
+		return "user:" + userID
+	}
+	return "token:" + strconv.FormatInt(tk.ID, 10)
+}
+
+// requestUserPreferences loads the token owner's request defaults.
+func (s *server) requestUserPreferences(ctx context.Context) auth.Preferences {
+	userID := requestUserID(ctx)
+	if userID == "" || s.authService == nil {
+		return auth.Preferences{}
+	}
+	prefs, err := s.authService.GetPreferences(userID)
+	if err != nil {
+		return auth.Preferences{}
+	}
+	return prefs
+}
+
+// applyPreferredDefaults fills the model and temperature a request left
+// unset from the caller's preferences.
+func applyPreferredDefaults(prefs auth.Preferences, model *string, temperature **float64) {
+	if strings.TrimSpace(*model) == "" && prefs.Model != "" {
+		*model = prefs.Model
+	}
+	if *temperature == nil && prefs.Temperature != nil {
+		t := *prefs.Temperature
+		*temperature = &t
+	}
+}
+
+// applyPreferredSystemPrompt puts the caller's system prompt in front of
+// the request's own, so mode prefixes still come first.
+func applyPreferredSystemPrompt(prefs auth.Preferences, system any) any {
+	return prependSystemText(prefs.SystemPrompt, system)
+}
+
+// handleMePreferences manages the caller's request defaults.
+// GET/PUT /v1/me/preferences
+func (s *server) handleMePreferences(w http.ResponseWriter, r *http.Request) {
+	if s.authService == nil {
+		s.writeError(w, http.StatusNotImplemented, "api_error", "auth service is not configured")
+		return
+	}
+	userID := requestUserID(r.Context())
+	if userID == "" {
+		s.writeError(w, http.StatusUnauthorized, "auth_error", "preferences require a user token")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		prefs, err := s.authService.GetPreferences(userID)
+		if err != nil {
+			s.writePreferencesError(w, err)
+			return
+		}
+		s.writePreferences(w, userID, prefs)
+	case http.MethodPut:
+		var prefs auth.Preferences
+		if err := decodeJSONBodyStrict(r, &prefs, false); err != nil {
+			s.reportRequestDecodeIssue(r, err)
+			s.writeError(w, http.StatusBadRequest, "invalid_request_error", "invalid JSON body")
+			return
+		}
+		if err := s.enforceTokenModelAccess(r.Context(), prefs.Model); err != nil {
+			s.writeModelAccessError(w, err)
+			return
+		}
+		s.savePreferences(w, userID, prefs, "user:"+userID)
+	default:
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+	}
+}
+
+// handleAdminUserPreferences manages a user's request defaults on their
+// behalf.
+// GET/PUT /admin/auth/users/{user_id}/preferences
+func (s *server) handleAdminUserPreferences(w http.ResponseWriter, r *http.Request, userID string) {
+	switch r.Method {
+	case http.MethodGet:
+		prefs, err := s.authService.GetPreferences(userID)
+		if err != nil {
+			s.writePreferencesError(w, err)
+			return
+		}
+		s.writePreferences(w, userID, prefs)
+	case http.MethodPut:
+		var prefs auth.Preferences
+		if err := decodeJSONBodyStrict(r, &prefs, false); err != nil {
+			s.reportRequestDecodeIssue(r, err)
+			s.writeError(w, http.StatusBadRequest, "invalid_request_error", "invalid JSON body")
+			return
+		}
+		s.savePreferences(w, userID, prefs, auditlog.ActorID(adminTokenFromRequest(r)))
+	default:
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+	}
+}
+
+func (s *server) savePreferences(w http.ResponseWriter, userID string, prefs auth.Preferences, actor string) {
+	saved, err := s.authService.SetPreferences(userID, prefs)
+	if err != nil {
+		s.writePreferencesError(w, err)
+		return
+	}
+	s.appendEvent(ccevent.AppendInput{
+		EventType: "user.preferences_updated",
+		Data: map[string]any{
+			"user_id":       userID,
+			"mode":          saved.Mode,
+			"model":         saved.Model,
+			"system_prompt": saved.SystemPrompt != "",
+			"actor":         actor,
+		},
+	})
+	s.writePreferences(w, userID, saved)
+}
+
+func (s *server) writePreferences(w http.ResponseWriter, userID string, prefs auth.Preferences) {
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"user_id":     userID,
+		"preferences": prefs,
+	})
+}
+
+func (s *server) writePreferencesError(w http.ResponseWriter, err error) {
+	if errors.Is(err, auth.ErrUserNotFound) {
+		s.writeError(w, http.StatusNotFound, "not_found_error", err.Error())
+		return
+	}
+	s.writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+}
@@ -180,10 +180,16 @@ func (s *server) resolveVisionSupportFromUpstream(req orchestrator.Request) (sup
 }
 
 func routeFromMetadataLocal(metadata map[string]any) []string {
+	return stringListFromMetadata(metadata, "routing_adapter_route")
+}
+
+// stringListFromMetadata reads a list of non-blank strings stored under key,
+// whether set in-process ([]string) or decoded from JSON ([]any).
+func stringListFromMetadata(metadata map[string]any, key string) []string {
 	if metadata == nil {
 		return nil
 	}
-	raw, ok := metadata["routing_adapter_route"]
+	raw, ok := metadata[key]
 	if !ok {
 		return nil
 	}
@@ -0,0 +1,265 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"ccgateway/internal/upstream"
+)
+
+// headerDebugWire asks for the upstream wire payloads of one run to be
+// captured. It is honored only while an admin has wire capture enabled.
+const headerDebugWire = "x-cc-debug-wire"
+
+const defaultWireCaptureMaxRuns = 100
+
+// WireCaptureConfig controls the request inspector, which keeps the exact
+// payloads adapters send upstream and the raw responses for runs that ask
+// for it with x-cc-debug-wire.
+type WireCaptureConfig struct {
+	// Enabled is the initial state; admins toggle it at /admin/wire-capture.
+	Enabled bool
+	// MaxRuns is how many captured runs are kept (default 100); the oldest
+	// is dropped first.
+	MaxRuns int
+	// MaxBodyBytes caps each captured body (default 256KB).
+	MaxBodyBytes int
+}
+
+// wireCaptures holds the captured exchanges of recent runs by run id.
+type wireCaptures struct {
+	mu      sync.Mutex
+	enabled bool
+	maxRuns int
+	maxBody int
+	runs    map[string]*wireRun
+	order   []string
+}
+
+type wireRun struct {
+	CapturedAt time.Time
+	Exchanges  []upstream.WireExchange
+}
+
+func newWireCaptures(cfg WireCaptureConfig) *wireCaptures {
+	if cfg.MaxRuns <= 0 {
+		cfg.MaxRuns = defaultWireCaptureMaxRuns
+	}
+	return &wireCaptures{enabled: cfg.Enabled, maxRuns: cfg.MaxRuns, maxBody: cfg.MaxBodyBytes, runs: map[string]*wireRun{}}
+}
+
+func (c *wireCaptures) isEnabled() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.enabled
+}
+
+func (c *wireCaptures) setEnabled(enabled bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.enabled = enabled
+}
+
+func (c *wireCaptures) add(runID string, ex upstream.WireExchange) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	run, ok := c.runs[runID]
+	if !ok {
+		run = &wireRun{CapturedAt: time.Now().UTC()}
+		c.runs[runID] = run
+		c.order = append(c.order, runID)
+		for len(c.order) > c.maxRuns {
+			delete(c.runs, c.order[0])
+			c.order = c.order[1:]
+		}
+	}
+	run.Exchanges = append(run.Exchanges, ex)
+}
+
+func (c *wireCaptures) get(runID string) (wireRun, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	run, ok := c.runs[runID]
+	if !ok {
+		return wireRun{}, false
+	}
+	return wireRun{CapturedAt: run.CapturedAt, Exchanges: append([]upstream.WireExchange(nil), run.Exchanges...)}, true
+}
+
+// deleteRuns drops the captures of runIDs and returns how many were
+// dropped.
+func (c *wireCaptures) deleteRuns(runIDs map[string]bool) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	order := c.order[:0]
+	for _, id := range c.order {
+		if runIDs[id] {
+			delete(c.runs, id)
+			n++
+			continue
+		}
+		order = append(order, id)
+	}
+	c.order = order
+	return n
+}
+
+func (c *wireCaptures) count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.runs)
+}
+
+// withWireCapture captures the run's upstream exchanges when the request
+// carries x-cc-debug-wire and capture is enabled.
+func (s *server) withWireCapture(r *http.Request, runID string) context.Context {
+	ctx := r.Context()
+	if s.wireCaptures == nil || runID == "" || !s.wireCaptures.isEnabled() {
+		return ctx
+	}
+	if on, ok := boolFromAny(r.Header.Get(headerDebugWire)); !ok || !on {
+		return ctx
+	}
+	return upstream.WithWireObserver(ctx, s.wireCaptures.maxBody, func(ex upstream.WireExchange) {
+		s.wireCaptures.add(runID, ex)
+	})
+}
+
+// handleAdminWireCapture reports or toggles wire capture.
+// GET/PUT /admin/wire-capture
+func (s *server) handleAdminWireCapture(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var in struct {
+			Enabled *bool `json:"enabled"`
+		}
+		if err := decodeJSONBodyStrict(r, &in, false); err != nil {
+			s.reportRequestDecodeIssue(r, err)
+			s.writeError(w, http.StatusBadRequest, "invalid_request_error", "invalid JSON body")
+			return
+		}
+		if in.Enabled == nil {
+			s.writeError(w, http.StatusBadRequest, "invalid_request_error", "enabled is required")
+			return
+		}
+		s.wireCaptures.setEnabled(*in.Enabled)
+	default:
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+		return
+	}
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"enabled":  s.wireCaptures.isEnabled(),
+		"header":   headerDebugWire,
+		"max_runs": s.wireCaptures.maxRuns,
+		"runs":     s.wireCaptures.count(),
+	})
+}
+
+// handleAdminRunByPath serves per-run admin views.
+// GET /admin/runs/{id}/wire
+func (s *server) handleAdminRunByPath(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/runs/"), "/"), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] != "wire" {
+		s.writeError(w, http.StatusNotFound, "not_found_error", "not found")
+		return
+	}
+	if r.Method != http.MethodGet {
+		s.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+		return
+	}
+	run, ok := s.wireCaptures.get(parts[0])
+	if !ok {
+		s.writeError(w, http.StatusNotFound, "not_found_error", "no wire capture for run")
+		return
+	}
+	exchanges := make([]map[string]any, 0, len(run.Exchanges))
+	for _, ex := range run.Exchanges {
+		item := map[string]any{
+			"adapter":         ex.Adapter,
+			"method":          ex.Method,
+			"url":             ex.URL,
+			"request_headers": ex.RequestHeaders,
+			"request_body":    wireBodyValue(ex.RequestBody),
+			"started_at":      ex.StartedAt,
+			"duration_ms":     ex.DurationMS,
+		}
+		if ex.Status != 0 {
+			item["status"] = ex.Status
+			item["response_headers"] = ex.ResponseHeaders
+			item["response_body"] = wireBodyValue(ex.ResponseBody)
+			if events := wireSSEEvents(ex.ResponseBody); len(events) > 0 {
+				item["response_events"] = events
+			}
+		}
+		if ex.Truncated {
+			item["truncated"] = true
+		}
+		if ex.Error != "" {
+			item["error"] = ex.Error
+		}
+		exchanges = append(exchanges, item)
+	}
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(map[string]any{
+		"run_id":      parts[0],
+		"captured_at": run.CapturedAt,
+		"exchanges":   exchanges,
+	})
+}
+
+// wireBodyValue returns body as JSON when it is JSON, so it prints nested,
+// and as text otherwise.
+func wireBodyValue(body []byte) any {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil
+	}
+	if json.Valid(body) {
+		return json.RawMessage(body)
+	}
+	return string(body)
+}
+
+// wireSSEEvents splits a server-sent event stream into its events, decoding
+// JSON data lines. It returns nil for bodies that are not event streams.
+func wireSSEEvents(body []byte) []map[string]any {
+	var out []map[string]any
+	for _, frame := range strings.Split(strings.ReplaceAll(string(body), "\r\n", "\n"), "\n\n") {
+		var event string
+		var data []string
+		for _, line := range strings.Split(frame, "\n") {
+			switch {
+			case strings.HasPrefix(line, "event:"):
+				event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+			case strings.HasPrefix(line, "data:"):
+				data = append(data, strings.TrimSpace(strings.TrimPrefix(line, "data:")))
+			}
+		}
+		if len(data) == 0 {
+			continue
+		}
+		item := map[string]any{"data": wireBodyValue([]byte(strings.Join(data, "\n")))}
+		if event != "" {
+			item["event"] = event
+		}
+		out = append(out, item)
+	}
+	return out
+}
@@ -0,0 +1,416 @@
+// Package guardrail checks prompts before they reach an upstream and
+// completions before they reach the client. Each rule matches text with a
+// regular expression, a built-in PII detector or a moderation adapter and
+// then redacts the match, blocks the request or only annotates it.
+package guardrail
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Stages a rule can run at.
+const (
+	StageInput  = "input"
+	StageOutput = "output"
+)
+
+// Rule kinds.
+const (
+	KindRegex      = "regex"
+	KindPII        = "pii"
+	KindModeration = "moderation"
+)
+
+// Actions taken when a rule matches.
+const (
+	ActionRedact   = "redact"
+	ActionBlock    = "block"
+	ActionAnnotate = "annotate"
+)
+
+const defaultBlockMessage = "The content was blocked by a guardrail."
+
+var ErrInvalidConfig = errors.New("invalid guardrails config")
+
+// Rule is one check. Stages defaults to both stages. Regex rules use
+// Pattern; PII rules use the detectors named in PII (all when empty);
+// moderation rules act when the adapter flags one of Categories (any when
+// empty), or when a category score reaches Threshold if it is set.
+// Moderation rules cannot redact. Mask replaces redacted matches and
+// defaults to the detector's mask or [REDACTED].
+type Rule struct {
+	Name       string   `json:"name"`
+	Kind       string   `json:"kind"`
+	Action     string   `json:"action"`
+	Stages     []string `json:"stages,omitempty"`
+	Pattern    string   `json:"pattern,omitempty"`
+	PII        []string `json:"pii,omitempty"`
+	Categories []string `json:"categories,omitempty"`
+	Threshold  float64  `json:"threshold,omitempty"`
+	Mask       string   `json:"mask,omitempty"`
+	Disabled   bool     `json:"disabled,omitempty"`
+}
+
+// Config is the whole pipeline. BlockMessage is what a blocked completion
+// is replaced with; FailClosed blocks when the moderation adapter fails
+// instead of letting the text through.
+type Config struct {
+	Enabled      bool   `json:"enabled"`
+	Rules        []Rule `json:"rules"`
+	BlockMessage string `json:"block_message,omitempty"`
+	FailClosed   bool   `json:"fail_closed,omitempty"`
+}
+
+// Finding is one rule that matched.
+type Finding struct {
+	Rule       string   `json:"rule"`
+	Kind       string   `json:"kind"`
+	Action     string   `json:"action"`
+	Stage      string   `json:"stage"`
+	Matches    int      `json:"matches"`
+	Categories []string `json:"categories,omitempty"`
+}
+
+// Result is the outcome of checking a set of text segments. Texts holds
+// the segments after redaction, in the order they were passed.
+type Result struct {
+	Texts    []string  `json:"texts"`
+	Findings []Finding `json:"findings,omitempty"`
+	Blocked  bool      `json:"blocked"`
+	// BlockedBy names the rule that blocked, or "moderation_error" when
+	// FailClosed turned an adapter failure into a block.
+	BlockedBy string `json:"blocked_by,omitempty"`
+	// Err is a moderation adapter failure; the other rules still ran.
+	Err error `json:"-"`
+}
+
+// Changed reports whether redaction altered any segment.
+func (r Result) Changed(original []string) bool {
+	for i := range original {
+		if i < len(r.Texts) && r.Texts[i] != original[i] {
+			return true
+		}
+	}
+	return false
+}
+
+type compiledRule struct {
+	Rule
+	pattern   *regexp.Regexp
+	detectors []detector
+}
+
+// Engine runs the configured rules. It is safe for concurrent use.
+type Engine struct {
+	moderator Moderator
+
+	mu    sync.RWMutex
+	cfg   Config
+	rules []compiledRule
+}
+
+// New validates cfg and builds an engine. moderator may be nil when no
+// rule uses moderation.
+func New(cfg Config, moderator Moderator) (*Engine, error) {
+	e := &Engine{moderator: moderator}
+	if err := e.Update(cfg); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// NewFromEnv reads the rules from GUARDRAILS_JSON and the moderation
+// adapter from GUARDRAILS_MODERATION_URL, GUARDRAILS_MODERATION_API_KEY and
+// GUARDRAILS_MODERATION_MODEL.
+func NewFromEnv() (*Engine, error) {
+	var cfg Config
+	if raw := strings.TrimSpace(os.Getenv("GUARDRAILS_JSON")); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &cfg); err != nil {
+			return nil, fmt.Errorf("invalid GUARDRAILS_JSON: %w", err)
+		}
+	}
+	var moderator Moderator
+	if url := strings.TrimSpace(os.Getenv("GUARDRAILS_MODERATION_URL")); url != "" {
+		moderator = NewHTTPModerator(HTTPModeratorConfig{
+			URL:    url,
+			APIKey: os.Getenv("GUARDRAILS_MODERATION_API_KEY"),
+			Model:  os.Getenv("GUARDRAILS_MODERATION_MODEL"),
+		})
+	}
+	return New(cfg, moderator)
+}
+
+// Config returns the current pipeline.
+func (e *Engine) Config() Config {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	out := e.cfg
+	out.Rules = append([]Rule(nil), e.cfg.Rules...)
+	return out
+}
+
+// Update validates and installs cfg; the running config is kept on error.
+func (e *Engine) Update(cfg Config) error {
+	rules := make([]compiledRule, 0, len(cfg.Rules))
+	seen := map[string]bool{}
+	for i, r := range cfg.Rules {
+		c, err := compileRule(r, e.moderator != nil)
+		if err != nil {
+			return fmt.Errorf("%w: rules[%d]: %v", ErrInvalidConfig, i, err)
+		}
+		if seen[c.Name] {
+			return fmt.Errorf("%w: rules[%d]: duplicate name %q", ErrInvalidConfig, i, c.Name)
+		}
+		seen[c.Name] = true
+		cfg.Rules[i] = c.Rule
+		rules = append(rules, c)
+	}
+	cfg.BlockMessage = strings.TrimSpace(cfg.BlockMessage)
+	e.mu.Lock()
+	e.cfg = cfg
+	e.rules = rules
+	e.mu.Unlock()
+	return nil
+}
+
+// Enabled reports whether any rule would run.
+func (e *Engine) Enabled() bool {
+	if e == nil {
+		return false
+	}
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.cfg.Enabled && len(e.rules) > 0
+}
+
+// Blocks reports whether any enabled rule can block at stage, counting
+// moderation rules when FailClosed turns adapter failures into blocks.
+func (e *Engine) Blocks(stage string) bool {
+	if !e.Enabled() {
+		return false
+	}
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	for _, rule := range e.rules {
+		if rule.Disabled || !rule.appliesTo(stage) {
+			continue
+		}
+		if rule.Action == ActionBlock || (rule.Kind == KindModeration && e.cfg.FailClosed) {
+			return true
+		}
+	}
+	return false
+}
+
+// BlockMessage is the text a blocked completion is replaced with.
+func (e *Engine) BlockMessage() string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if e.cfg.BlockMessage != "" {
+		return e.cfg.BlockMessage
+	}
+	return defaultBlockMessage
+}
+
+// Check runs the rules for stage over texts. Rules run in order; redaction
+// feeds later rules and the first block stops the pipeline.
+func (e *Engine) Check(ctx context.Context, stage string, texts []string) Result {
+	res := Result{Texts: append([]string(nil), texts...)}
+	if !e.Enabled() {
+		return res
+	}
+	e.mu.RLock()
+	rules := e.rules
+	failClosed := e.cfg.FailClosed
+	e.mu.RUnlock()
+
+	var moderation *Moderation
+	moderated := false
+	for _, rule := range rules {
+		if rule.Disabled || !rule.appliesTo(stage) {
+			continue
+		}
+		finding := Finding{Rule: rule.Name, Kind: rule.Kind, Action: rule.Action, Stage: stage}
+		switch rule.Kind {
+		case KindModeration:
+			if !moderated {
+				moderated = true
+				m, err := e.moderator.Moderate(ctx, strings.Join(res.Texts, "\n\n"))
+				if err != nil {
+					res.Err = err
+					if failClosed {
+						res.Blocked = true
+						res.BlockedBy = "moderation_error"
+						return res
+					}
+				} else {
+					moderation = &m
+				}
+			}
+			if moderation == nil {
+				continue
+			}
+			finding.Categories = rule.moderationHits(*moderation)
+			finding.Matches = len(finding.Categories)
+		default:
+			for i, text := range res.Texts {
+				n, redacted := rule.match(text)
+				finding.Matches += n
+				if n > 0 && rule.Action == ActionRedact {
+					res.Texts[i] = redacted
+				}
+			}
+		}
+		if finding.Matches == 0 {
+			continue
+		}
+		res.Findings = append(res.Findings, finding)
+		if rule.Action == ActionBlock {
+			res.Blocked = true
+			res.BlockedBy = rule.Name
+			return res
+		}
+	}
+	return res
+}
+
+func compileRule(r Rule, haveModerator bool) (compiledRule, error) {
+	r.Name = strings.TrimSpace(r.Name)
+	r.Kind = strings.ToLower(strings.TrimSpace(r.Kind))
+	r.Action = strings.ToLower(strings.TrimSpace(r.Action))
+	if r.Name == "" {
+		return compiledRule{}, errors.New("name is required")
+	}
+	switch r.Action {
+	case ActionRedact, ActionBlock, ActionAnnotate:
+	default:
+		return compiledRule{}, fmt.Errorf("action must be redact, block or annotate")
+	}
+	for i, stage := range r.Stages {
+		stage = strings.ToLower(strings.TrimSpace(stage))
+		if stage != StageInput && stage != StageOutput {
+			return compiledRule{}, fmt.Errorf("stage must be input or output")
+		}
+		r.Stages[i] = stage
+	}
+	c := compiledRule{Rule: r}
+	switch r.Kind {
+	case KindRegex:
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil || r.Pattern == "" {
+			return compiledRule{}, fmt.Errorf("pattern must be a valid regular expression")
+		}
+		c.pattern = re
+	case KindPII:
+		names := r.PII
+		if len(names) == 0 {
+			names = PIIDetectors()
+		}
+		for _, name := range names {
+			d, ok := lookupDetector(name)
+			if !ok {
+				return compiledRule{}, fmt.Errorf("unknown pii detector %q", name)
+			}
+			c.detectors = append(c.detectors, d)
+		}
+	case KindModeration:
+		if !haveModerator {
+			return compiledRule{}, errors.New("moderation rules need GUARDRAILS_MODERATION_URL")
+		}
+		if r.Action == ActionRedact {
+			return compiledRule{}, errors.New("moderation rules can only block or annotate")
+		}
+		if r.Threshold < 0 || r.Threshold > 1 {
+			return compiledRule{}, errors.New("threshold must be between 0 and 1")
+		}
+	default:
+		return compiledRule{}, fmt.Errorf("kind must be regex, pii or moderation")
+	}
+	return c, nil
+}
+
+func (r compiledRule) appliesTo(stage string) bool {
+	if len(r.Stages) == 0 {
+		return true
+	}
+	for _, s := range r.Stages {
+		if s == stage {
+			return true
+		}
+	}
+	return false
+}
+
+// match counts matches in text and returns text with them masked.
+func (r compiledRule) match(text string) (int, string) {
+	if text == "" {
+		return 0, text
+	}
+	count := 0
+	if r.pattern != nil {
+		mask := r.Mask
+		if mask == "" {
+			mask = "[REDACTED]"
+		}
+		text = r.pattern.ReplaceAllStringFunc(text, func(string) string {
+			count++
+			return mask
+		})
+		return count, text
+	}
+	for _, d := range r.detectors {
+		mask := r.Mask
+		if mask == "" {
+			mask = d.mask
+		}
+		text = d.pattern.ReplaceAllStringFunc(text, func(m string) string {
+			if d.valid != nil && !d.valid(m) {
+				return m
+			}
+			count++
+			return mask
+		})
+	}
+	return count, text
+}
+
+func (r compiledRule) moderationHits(m Moderation) []string {
+	var hits []string
+	consider := func(category string) {
+		if r.Threshold > 0 {
+			if m.Scores[category] >= r.Threshold {
+				hits = append(hits, category)
+			}
+			return
+		}
+		if m.Categories[category] {
+			hits = append(hits, category)
+		}
+	}
+	if len(r.Categories) > 0 {
+		for _, c := range r.Categories {
+			consider(strings.TrimSpace(c))
+		}
+		return hits
+	}
+	if r.Threshold > 0 {
+		for c := range m.Scores {
+			consider(c)
+		}
+	} else {
+		for c := range m.Categories {
+			consider(c)
+		}
+	}
+	if len(hits) == 0 && r.Threshold == 0 && m.Flagged {
+		hits = append(hits, "flagged")
+	}
+	return sortStrings(hits)
+}
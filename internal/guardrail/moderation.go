@@ -0,0 +1,109 @@
+package guardrail
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Moderation is a classifier verdict for a piece of text.
+type Moderation struct {
+	Flagged    bool               `json:"flagged"`
+	Categories map[string]bool    `json:"categories,omitempty"`
+	Scores     map[string]float64 `json:"category_scores,omitempty"`
+}
+
+// Moderator classifies text into blocked topics.
+type Moderator interface {
+	Moderate(ctx context.Context, text string) (Moderation, error)
+}
+
+// HTTPModeratorConfig points at an OpenAI-compatible moderations endpoint.
+type HTTPModeratorConfig struct {
+	URL     string
+	APIKey  string
+	Model   string
+	Timeout time.Duration
+	Client  *http.Client
+}
+
+// HTTPModerator calls an OpenAI-compatible POST /v1/moderations endpoint.
+type HTTPModerator struct {
+	url    string
+	apiKey string
+	model  string
+	client *http.Client
+}
+
+func NewHTTPModerator(cfg HTTPModeratorConfig) *HTTPModerator {
+	client := cfg.Client
+	if client == nil {
+		timeout := cfg.Timeout
+		if timeout <= 0 {
+			timeout = 10 * time.Second
+		}
+		client = &http.Client{Timeout: timeout}
+	}
+	return &HTTPModerator{
+		url:    strings.TrimSpace(cfg.URL),
+		apiKey: strings.TrimSpace(cfg.APIKey),
+		model:  strings.TrimSpace(cfg.Model),
+		client: client,
+	}
+}
+
+func (m *HTTPModerator) Moderate(ctx context.Context, text string) (Moderation, error) {
+	payload := map[string]any{"input": text}
+	if m.model != "" {
+		payload["model"] = m.model
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return Moderation{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.url, bytes.NewReader(body))
+	if err != nil {
+		return Moderation{}, err
+	}
+	req.Header.Set("content-type", "application/json")
+	if m.apiKey != "" {
+		req.Header.Set("authorization", "Bearer "+m.apiKey)
+	}
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return Moderation{}, err
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return Moderation{}, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return Moderation{}, fmt.Errorf("moderation endpoint returned %d", resp.StatusCode)
+	}
+	var out struct {
+		Results []Moderation `json:"results"`
+	}
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return Moderation{}, fmt.Errorf("decode moderation response: %w", err)
+	}
+	// Several inputs are never sent, but merge defensively.
+	merged := Moderation{Categories: map[string]bool{}, Scores: map[string]float64{}}
+	for _, r := range out.Results {
+		merged.Flagged = merged.Flagged || r.Flagged
+		for c, v := range r.Categories {
+			merged.Categories[c] = merged.Categories[c] || v
+		}
+		for c, v := range r.Scores {
+			if v > merged.Scores[c] {
+				merged.Scores[c] = v
+			}
+		}
+	}
+	return merged, nil
+}
@@ -0,0 +1,69 @@
+package guardrail
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+type detector struct {
+	name    string
+	mask    string
+	pattern *regexp.Regexp
+	valid   func(string) bool
+}
+
+// Built-in PII detectors. Card numbers must also pass the Luhn check so
+// order and tracking numbers are left alone.
+var detectors = []detector{
+	{name: "email", mask: "[EMAIL]", pattern: regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)},
+	{name: "api_key", mask: "[API_KEY]", pattern: regexp.MustCompile(`\b(?:sk|pk|rk)-[A-Za-z0-9_\-]{16,}\b|\bAKIA[0-9A-Z]{16}\b|\bgh[pousr]_[A-Za-z0-9]{36,}\b`)},
+	{name: "credit_card", mask: "[CARD]", pattern: regexp.MustCompile(`\b(?:\d[ \-]?){12,18}\d\b`), valid: luhnValid},
+	{name: "ssn", mask: "[SSN]", pattern: regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`)},
+	{name: "phone", mask: "[PHONE]", pattern: regexp.MustCompile(`(?:\+\d{1,3}[ \-]?)?(?:\(\d{3}\)|\d{3})[ \-]\d{3}[ \-]\d{4}\b|\+?\b1[3-9]\d{9}\b`)},
+	{name: "ipv4", mask: "[IP]", pattern: regexp.MustCompile(`\b(?:(?:25[0-5]|2[0-4]\d|1?\d?\d)\.){3}(?:25[0-5]|2[0-4]\d|1?\d?\d)\b`)},
+}
+
+// PIIDetectors lists the built-in detector names in the order they run.
+func PIIDetectors() []string {
+	out := make([]string, 0, len(detectors))
+	for _, d := range detectors {
+		out = append(out, d.name)
+	}
+	return out
+}
+
+func lookupDetector(name string) (detector, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, d := range detectors {
+		if d.name == name {
+			return d, true
+		}
+	}
+	return detector{}, false
+}
+
+func luhnValid(s string) bool {
+	sum, n := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n >= 13 && sum%10 == 0
+}
+
+func sortStrings(in []string) []string {
+	sort.Strings(in)
+	return in
+}
@@ -0,0 +1,145 @@
+package idgen
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Formats accepted by New and ID_FORMAT.
+const (
+	FormatLegacy = "legacy"
+	FormatULID   = "ulid"
+	FormatKSUID  = "ksuid"
+)
+
+// Generator returns a new ID with the given prefix, as "<prefix>_<id>".
+type Generator interface {
+	New(prefix string) string
+}
+
+// New returns the generator for format; an empty format means ULID.
+func New(format string) (Generator, error) {
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case "", FormatULID:
+		return NewULID(), nil
+	case FormatKSUID:
+		return KSUID{}, nil
+	case FormatLegacy:
+		return &Legacy{}, nil
+	}
+	return nil, fmt.Errorf("unknown ID_FORMAT %q (want ulid, ksuid or legacy)", format)
+}
+
+// FromEnv builds the generator named by ID_FORMAT.
+func FromEnv() (Generator, error) {
+	return New(os.Getenv("ID_FORMAT"))
+}
+
+// Legacy is the original "<prefix>_<unix seconds>_<hex counter>" scheme.
+// IDs are only unique within one process.
+type Legacy struct {
+	counter atomic.Uint64
+}
+
+func (g *Legacy) New(prefix string) string {
+	n := g.counter.Add(1)
+	return fmt.Sprintf("%s_%d_%x", prefix, time.Now().Unix(), n)
+}
+
+// crockford is the ULID alphabet: base32 without I, L, O and U.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ULID generates 26-character ULIDs: a 48-bit millisecond timestamp
+// followed by 80 random bits. IDs from one generator sort in creation
+// order, including several within the same millisecond.
+type ULID struct {
+	mu   sync.Mutex
+	now  func() time.Time
+	last uint64
+	hi   uint16
+	lo   uint64
+}
+
+func NewULID() *ULID {
+	return &ULID{now: time.Now}
+}
+
+func (g *ULID) New(prefix string) string {
+	return prefix + "_" + g.next()
+}
+
+func (g *ULID) next() string {
+	g.mu.Lock()
+	ms := uint64(g.now().UnixMilli())
+	if ms <= g.last {
+		// Same (or an earlier) millisecond: keep the last timestamp and
+		// bump the random part so the order still holds.
+		ms = g.last
+		g.lo++
+		if g.lo == 0 {
+			g.hi++
+		}
+	} else {
+		var entropy [10]byte
+		_, _ = rand.Read(entropy[:])
+		g.last = ms
+		g.hi = binary.BigEndian.Uint16(entropy[:2])
+		g.lo = binary.BigEndian.Uint64(entropy[2:])
+	}
+	var raw [16]byte
+	raw[0] = byte(ms >> 40)
+	raw[1] = byte(ms >> 32)
+	raw[2] = byte(ms >> 24)
+	raw[3] = byte(ms >> 16)
+	raw[4] = byte(ms >> 8)
+	raw[5] = byte(ms)
+	binary.BigEndian.PutUint16(raw[6:8], g.hi)
+	binary.BigEndian.PutUint64(raw[8:], g.lo)
+	g.mu.Unlock()
+	return encodeULID(raw)
+}
+
+// encodeULID writes the 128 bits as 26 base32 digits, most significant
+// first; the leading digit carries only 3 bits.
+func encodeULID(raw [16]byte) string {
+	n := new(big.Int).SetBytes(raw[:])
+	out := make([]byte, 26)
+	mask := big.NewInt(31)
+	digit := new(big.Int)
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[digit.And(n, mask).Int64()]
+		n.Rsh(n, 5)
+	}
+	return string(out)
+}
+
+// ksuidEpoch is the KSUID timestamp origin (2014-05-13).
+const ksuidEpoch = 1400000000
+
+const base62 = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// KSUID generates 27-character KSUIDs: a 32-bit second timestamp and 128
+// random bits, base62-encoded. They sort by second only.
+type KSUID struct{}
+
+func (KSUID) New(prefix string) string {
+	var raw [20]byte
+	binary.BigEndian.PutUint32(raw[:4], uint32(time.Now().Unix()-ksuidEpoch))
+	_, _ = rand.Read(raw[4:])
+	n := new(big.Int).SetBytes(raw[:])
+	out := make([]byte, 27)
+	base := big.NewInt(62)
+	rem := new(big.Int)
+	for i := 26; i >= 0; i-- {
+		n.DivMod(n, base, rem)
+		out[i] = base62[rem.Int64()]
+	}
+	return prefix + "_" + string(out)
+}
@@ -0,0 +1,351 @@
+package imageproc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"ccgateway/internal/egress"
+)
+
+var ErrUnsupportedImage = errors.New("unsupported image format")
+
+// Config bounds images forwarded to vision upstreams. Images already within
+// both limits are passed through byte-for-byte.
+type Config struct {
+	Enabled      bool  `json:"enabled"`
+	MaxDimension int   `json:"max_dimension"`
+	MaxBytes     int64 `json:"max_bytes"`
+	JPEGQuality  int   `json:"jpeg_quality"`
+	// ProxyURLs fetches remote image URLs at the gateway and inlines them as
+	// base64, so upstreams never need to reach the original host.
+	ProxyURLs      bool  `json:"proxy_urls"`
+	FetchTimeoutMS int   `json:"fetch_timeout_ms"`
+	MaxFetchBytes  int64 `json:"max_fetch_bytes"`
+}
+
+type ConfigPatch struct {
+	Enabled        *bool  `json:"enabled,omitempty"`
+	MaxDimension   *int   `json:"max_dimension,omitempty"`
+	MaxBytes       *int64 `json:"max_bytes,omitempty"`
+	JPEGQuality    *int   `json:"jpeg_quality,omitempty"`
+	ProxyURLs      *bool  `json:"proxy_urls,omitempty"`
+	FetchTimeoutMS *int   `json:"fetch_timeout_ms,omitempty"`
+	MaxFetchBytes  *int64 `json:"max_fetch_bytes,omitempty"`
+}
+
+// Result describes one processed image.
+type Result struct {
+	MediaType     string
+	Data          []byte
+	OriginalBytes int
+	Width         int
+	Height        int
+	Resized       bool
+	Recompressed  bool
+}
+
+type Processor struct {
+	mu  sync.RWMutex
+	cfg Config
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Enabled:        true,
+		MaxDimension:   2048,
+		MaxBytes:       3 << 20,
+		JPEGQuality:    85,
+		FetchTimeoutMS: 10000,
+		MaxFetchBytes:  20 << 20,
+	}
+}
+
+func NewProcessor(cfg Config) *Processor {
+	return &Processor{cfg: sanitizeConfig(cfg)}
+}
+
+func NewFromEnv() (*Processor, error) {
+	cfg := DefaultConfig()
+	if raw := strings.TrimSpace(os.Getenv("VISION_IMAGE_PREPROCESS")); raw != "" {
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid VISION_IMAGE_PREPROCESS: %w", err)
+		}
+		cfg.Enabled = v
+	}
+	if raw := strings.TrimSpace(os.Getenv("VISION_IMAGE_PROXY_URLS")); raw != "" {
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid VISION_IMAGE_PROXY_URLS: %w", err)
+		}
+		cfg.ProxyURLs = v
+	}
+	if raw := strings.TrimSpace(os.Getenv("VISION_IMAGE_MAX_DIMENSION")); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v <= 0 {
+			return nil, fmt.Errorf("invalid VISION_IMAGE_MAX_DIMENSION: %q", raw)
+		}
+		cfg.MaxDimension = v
+	}
+	if raw := strings.TrimSpace(os.Getenv("VISION_IMAGE_MAX_BYTES")); raw != "" {
+		v, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || v <= 0 {
+			return nil, fmt.Errorf("invalid VISION_IMAGE_MAX_BYTES: %q", raw)
+		}
+		cfg.MaxBytes = v
+	}
+	if raw := strings.TrimSpace(os.Getenv("VISION_IMAGE_JPEG_QUALITY")); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v < 1 || v > 100 {
+			return nil, fmt.Errorf("invalid VISION_IMAGE_JPEG_QUALITY: %q", raw)
+		}
+		cfg.JPEGQuality = v
+	}
+	return NewProcessor(cfg), nil
+}
+
+func (p *Processor) Config() Config {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.cfg
+}
+
+func (p *Processor) UpdateConfigPatch(patch ConfigPatch) (Config, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	next := p.cfg
+	if patch.Enabled != nil {
+		next.Enabled = *patch.Enabled
+	}
+	if patch.ProxyURLs != nil {
+		next.ProxyURLs = *patch.ProxyURLs
+	}
+	if patch.MaxDimension != nil {
+		if *patch.MaxDimension <= 0 {
+			return p.cfg, fmt.Errorf("max_dimension must be > 0")
+		}
+		next.MaxDimension = *patch.MaxDimension
+	}
+	if patch.MaxBytes != nil {
+		if *patch.MaxBytes <= 0 {
+			return p.cfg, fmt.Errorf("max_bytes must be > 0")
+		}
+		next.MaxBytes = *patch.MaxBytes
+	}
+	if patch.JPEGQuality != nil {
+		if *patch.JPEGQuality < 1 || *patch.JPEGQuality > 100 {
+			return p.cfg, fmt.Errorf("jpeg_quality must be within [1,100]")
+		}
+		next.JPEGQuality = *patch.JPEGQuality
+	}
+	if patch.FetchTimeoutMS != nil {
+		next.FetchTimeoutMS = *patch.FetchTimeoutMS
+	}
+	if patch.MaxFetchBytes != nil {
+		next.MaxFetchBytes = *patch.MaxFetchBytes
+	}
+	p.cfg = sanitizeConfig(next)
+	return p.cfg, nil
+}
+
+// Fetch downloads a remote image under the strict egress policy (private
+// networks are never reachable from user-supplied URLs).
+func (p *Processor) Fetch(ctx context.Context, rawURL string) (string, []byte, error) {
+	cfg := p.Config()
+	parsed, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return "", nil, fmt.Errorf("invalid image url")
+	}
+	policy := egress.Default().Strict()
+	if err := policy.CheckHost(ctx, parsed.Hostname()); err != nil {
+		return "", nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, parsed.String(), nil)
+	if err != nil {
+		return "", nil, err
+	}
+	resp, err := policy.HTTPClient(time.Duration(cfg.FetchTimeoutMS) * time.Millisecond).Do(req)
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", nil, fmt.Errorf("image download status %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, cfg.MaxFetchBytes+1))
+	if err != nil {
+		return "", nil, err
+	}
+	if int64(len(body)) > cfg.MaxFetchBytes {
+		return "", nil, fmt.Errorf("image exceeds %d bytes", cfg.MaxFetchBytes)
+	}
+	if len(body) == 0 {
+		return "", nil, fmt.Errorf("empty image body")
+	}
+	mediaType := strings.TrimSpace(resp.Header.Get("content-type"))
+	if idx := strings.Index(mediaType, ";"); idx >= 0 {
+		mediaType = strings.TrimSpace(mediaType[:idx])
+	}
+	if mediaType == "" || mediaType == "application/octet-stream" {
+		mediaType = http.DetectContentType(body)
+	}
+	return mediaType, body, nil
+}
+
+// Normalize downscales and recompresses data until it fits the configured
+// dimension and byte limits. Images already within limits are returned as-is.
+func (p *Processor) Normalize(mediaType string, data []byte) (Result, error) {
+	cfg := p.Config()
+	res := Result{MediaType: mediaType, Data: data, OriginalBytes: len(data)}
+	imgCfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return res, fmt.Errorf("%w: %v", ErrUnsupportedImage, err)
+	}
+	res.Width, res.Height = imgCfg.Width, imgCfg.Height
+	if int64(len(data)) <= cfg.MaxBytes && maxInt(imgCfg.Width, imgCfg.Height) <= cfg.MaxDimension {
+		return res, nil
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return res, fmt.Errorf("%w: %v", ErrUnsupportedImage, err)
+	}
+	img := toNRGBA(src)
+	if w, h := fitWithin(img.Bounds().Dx(), img.Bounds().Dy(), cfg.MaxDimension); w != img.Bounds().Dx() || h != img.Bounds().Dy() {
+		img = downscale(img, w, h)
+		res.Resized = true
+	}
+
+	opaque := img.Opaque()
+	quality := cfg.JPEGQuality
+	for attempt := 0; attempt < 8; attempt++ {
+		var buf bytes.Buffer
+		outType := "image/jpeg"
+		if opaque {
+			err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality})
+		} else {
+			outType = "image/png"
+			err = (&png.Encoder{CompressionLevel: png.BestCompression}).Encode(&buf, img)
+		}
+		if err != nil {
+			return res, err
+		}
+		if int64(buf.Len()) <= cfg.MaxBytes || attempt == 7 {
+			res.MediaType = outType
+			res.Data = buf.Bytes()
+			res.Recompressed = true
+			res.Width, res.Height = img.Bounds().Dx(), img.Bounds().Dy()
+			if int64(buf.Len()) > cfg.MaxBytes {
+				return res, fmt.Errorf("image still exceeds %d bytes after recompression", cfg.MaxBytes)
+			}
+			return res, nil
+		}
+		// Lower JPEG quality first, then shrink dimensions.
+		if opaque && quality > 50 {
+			quality -= 15
+			continue
+		}
+		w, h := img.Bounds().Dx()*3/4, img.Bounds().Dy()*3/4
+		if w < 1 || h < 1 {
+			break
+		}
+		img = downscale(img, w, h)
+		res.Resized = true
+	}
+	return res, fmt.Errorf("image could not be reduced below %d bytes", cfg.MaxBytes)
+}
+
+func toNRGBA(src image.Image) *image.NRGBA {
+	if img, ok := src.(*image.NRGBA); ok && img.Rect.Min == (image.Point{}) {
+		return img
+	}
+	b := src.Bounds()
+	out := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(out, out.Bounds(), src, b.Min, draw.Src)
+	return out
+}
+
+// downscale resizes with an area-averaging (box) filter, which avoids the
+// aliasing of nearest-neighbour sampling when shrinking by large factors.
+func downscale(src *image.NRGBA, w, h int) *image.NRGBA {
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		y0 := y * sh / h
+		y1 := maxInt((y+1)*sh/h, y0+1)
+		for x := 0; x < w; x++ {
+			x0 := x * sw / w
+			x1 := maxInt((x+1)*sw/w, x0+1)
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					px := row[sx*4 : sx*4+4]
+					r += uint64(px[0])
+					g += uint64(px[1])
+					b += uint64(px[2])
+					a += uint64(px[3])
+					n++
+				}
+			}
+			i := y*dst.Stride + x*4
+			dst.Pix[i] = uint8(r / n)
+			dst.Pix[i+1] = uint8(g / n)
+			dst.Pix[i+2] = uint8(b / n)
+			dst.Pix[i+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
+
+func fitWithin(w, h, max int) (int, int) {
+	if max <= 0 || (w <= max && h <= max) {
+		return w, h
+	}
+	if w >= h {
+		return max, maxInt(1, h*max/w)
+	}
+	return maxInt(1, w*max/h), max
+}
+
+func sanitizeConfig(cfg Config) Config {
+	def := DefaultConfig()
+	if cfg.MaxDimension <= 0 {
+		cfg.MaxDimension = def.MaxDimension
+	}
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = def.MaxBytes
+	}
+	if cfg.JPEGQuality < 1 || cfg.JPEGQuality > 100 {
+		cfg.JPEGQuality = def.JPEGQuality
+	}
+	if cfg.FetchTimeoutMS <= 0 {
+		cfg.FetchTimeoutMS = def.FetchTimeoutMS
+	}
+	if cfg.MaxFetchBytes <= 0 {
+		cfg.MaxFetchBytes = def.MaxFetchBytes
+	}
+	return cfg
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,356 @@
+package ipaccess
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+var ErrDenied = errors.New("client ip not allowed")
+
+// Config controls which client addresses may reach the gateway. Deny lists
+// always win; a non-empty allow list admits only its ranges. Allow/Deny
+// apply to every request, AdminAllow additionally to admin paths (see
+// IsAdminPath), and the rule with the longest matching path_prefix on top of both.
+//
+// X-Forwarded-For and X-Real-IP are only honored when the connection comes
+// from a TrustedProxies range; otherwise the peer address is the client.
+type Config struct {
+	TrustedProxies []string `json:"trusted_proxies"`
+	Allow          []string `json:"allow"`
+	Deny           []string `json:"deny"`
+	AdminAllow     []string `json:"admin_allow"`
+	Rules          []Rule   `json:"rules"`
+}
+
+type Rule struct {
+	PathPrefix string   `json:"path_prefix"`
+	Allow      []string `json:"allow,omitempty"`
+	Deny       []string `json:"deny,omitempty"`
+}
+
+type ConfigPatch struct {
+	TrustedProxies []string `json:"trusted_proxies,omitempty"`
+	Allow          []string `json:"allow,omitempty"`
+	Deny           []string `json:"deny,omitempty"`
+	AdminAllow     []string `json:"admin_allow,omitempty"`
+	Rules          []Rule   `json:"rules,omitempty"`
+}
+
+type compiledRule struct {
+	prefix string
+	allow  []*net.IPNet
+	deny   []*net.IPNet
+}
+
+type Policy struct {
+	mu         sync.RWMutex
+	cfg        Config
+	trusted    []*net.IPNet
+	allow      []*net.IPNet
+	deny       []*net.IPNet
+	adminAllow []*net.IPNet
+	rules      []compiledRule
+
+	denied uint64
+}
+
+func NewPolicy(cfg Config) (*Policy, error) {
+	p := &Policy{}
+	if err := p.apply(cfg); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// NewFromEnv reads TRUSTED_PROXIES, IP_ALLOWLIST, IP_DENYLIST and
+// ADMIN_IP_ALLOWLIST (comma-separated IPs or CIDRs). Per-route rules are
+// set at runtime through the admin API.
+func NewFromEnv() (*Policy, error) {
+	p, err := NewPolicy(Config{
+		TrustedProxies: splitList(os.Getenv("TRUSTED_PROXIES")),
+		Allow:          splitList(os.Getenv("IP_ALLOWLIST")),
+		Deny:           splitList(os.Getenv("IP_DENYLIST")),
+		AdminAllow:     splitList(os.Getenv("ADMIN_IP_ALLOWLIST")),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid ip access config: %w", err)
+	}
+	return p, nil
+}
+
+func (p *Policy) Config() Config {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return cloneConfig(p.cfg)
+}
+
+func (p *Policy) Snapshot() map[string]any {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return map[string]any{
+		"config": cloneConfig(p.cfg),
+		"denied": atomic.LoadUint64(&p.denied),
+	}
+}
+
+func (p *Policy) UpdateConfigPatch(patch ConfigPatch) (Config, error) {
+	next, err := p.Preview(patch)
+	if err != nil {
+		return p.Config(), err
+	}
+	if err := p.apply(next); err != nil {
+		return p.Config(), err
+	}
+	return p.Config(), nil
+}
+
+// Preview returns the configuration patch would produce without applying
+// it.
+func (p *Policy) Preview(patch ConfigPatch) (Config, error) {
+	next := p.Config()
+	if patch.TrustedProxies != nil {
+		next.TrustedProxies = patch.TrustedProxies
+	}
+	if patch.Allow != nil {
+		next.Allow = patch.Allow
+	}
+	if patch.Deny != nil {
+		next.Deny = patch.Deny
+	}
+	if patch.AdminAllow != nil {
+		next.AdminAllow = patch.AdminAllow
+	}
+	if patch.Rules != nil {
+		next.Rules = patch.Rules
+	}
+	if _, err := NewPolicy(next); err != nil {
+		return p.Config(), err
+	}
+	return next, nil
+}
+
+func (p *Policy) apply(cfg Config) error {
+	cfg = cloneConfig(cfg)
+	cfg.TrustedProxies = normalizeList(cfg.TrustedProxies)
+	cfg.Allow = normalizeList(cfg.Allow)
+	cfg.Deny = normalizeList(cfg.Deny)
+	cfg.AdminAllow = normalizeList(cfg.AdminAllow)
+	trusted, err := parseCIDRs(cfg.TrustedProxies)
+	if err != nil {
+		return fmt.Errorf("trusted_proxies: %w", err)
+	}
+	allow, err := parseCIDRs(cfg.Allow)
+	if err != nil {
+		return fmt.Errorf("allow: %w", err)
+	}
+	deny, err := parseCIDRs(cfg.Deny)
+	if err != nil {
+		return fmt.Errorf("deny: %w", err)
+	}
+	adminAllow, err := parseCIDRs(cfg.AdminAllow)
+	if err != nil {
+		return fmt.Errorf("admin_allow: %w", err)
+	}
+	rules := make([]compiledRule, 0, len(cfg.Rules))
+	for i := range cfg.Rules {
+		rule := &cfg.Rules[i]
+		rule.PathPrefix = strings.TrimSpace(rule.PathPrefix)
+		rule.Allow = normalizeList(rule.Allow)
+		rule.Deny = normalizeList(rule.Deny)
+		if !strings.HasPrefix(rule.PathPrefix, "/") {
+			return fmt.Errorf("rules[%d]: path_prefix must start with /", i)
+		}
+		c := compiledRule{prefix: rule.PathPrefix}
+		if c.allow, err = parseCIDRs(rule.Allow); err != nil {
+			return fmt.Errorf("rules[%d].allow: %w", i, err)
+		}
+		if c.deny, err = parseCIDRs(rule.Deny); err != nil {
+			return fmt.Errorf("rules[%d].deny: %w", i, err)
+		}
+		rules = append(rules, c)
+	}
+	// Longest prefix first, so the first match is the most specific.
+	sort.SliceStable(rules, func(i, j int) bool { return len(rules[i].prefix) > len(rules[j].prefix) })
+	p.mu.Lock()
+	p.cfg = cfg
+	p.trusted = trusted
+	p.allow = allow
+	p.deny = deny
+	p.adminAllow = adminAllow
+	p.rules = rules
+	p.mu.Unlock()
+	return nil
+}
+
+// ClientIP returns the address of the client behind r. Forwarding headers
+// are walked right to left past trusted proxies only; a request that does
+// not come from a trusted proxy is attributed to its peer address.
+func (p *Policy) ClientIP(r *http.Request) string {
+	remote := strings.TrimSpace(r.RemoteAddr)
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		remote = host
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if !containsIP(p.trusted, net.ParseIP(remote)) {
+		return remote
+	}
+	var hops []string
+	for _, v := range r.Header.Values("x-forwarded-for") {
+		hops = append(hops, strings.Split(v, ",")...)
+	}
+	if len(hops) == 0 {
+		if realIP := parseHop(r.Header.Get("x-real-ip")); realIP != nil {
+			return realIP.String()
+		}
+		return remote
+	}
+	client := remote
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := parseHop(hops[i])
+		if ip == nil {
+			break
+		}
+		client = ip.String()
+		if !containsIP(p.trusted, ip) {
+			break
+		}
+	}
+	return client
+}
+
+// Check reports whether ip may request path.
+func (p *Policy) Check(path, ip string) error {
+	if err := p.check(path, net.ParseIP(ip)); err != nil {
+		atomic.AddUint64(&p.denied, 1)
+		return err
+	}
+	return nil
+}
+
+func (p *Policy) check(path string, ip net.IP) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if err := checkLists(ip, p.allow, p.deny); err != nil {
+		return err
+	}
+	if IsAdminPath(path) {
+		if err := checkLists(ip, p.adminAllow, nil); err != nil {
+			return err
+		}
+	}
+	for _, rule := range p.rules {
+		if strings.HasPrefix(path, rule.prefix) {
+			return checkLists(ip, rule.allow, rule.deny)
+		}
+	}
+	return nil
+}
+
+// adminPathRoots are the admin API roots: the gateway's own and the
+// one-api compatible management API.
+var adminPathRoots = []string{"/admin", "/api/channel", "/api/token", "/api/user"}
+
+// IsAdminPath reports whether path is under one of the admin API roots.
+func IsAdminPath(path string) bool {
+	for _, root := range adminPathRoots {
+		if path == root || strings.HasPrefix(path, root+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func checkLists(ip net.IP, allow, deny []*net.IPNet) error {
+	if containsIP(deny, ip) {
+		return fmt.Errorf("%w: %s is in a denied range", ErrDenied, ip)
+	}
+	if len(allow) > 0 && !containsIP(allow, ip) {
+		if ip == nil {
+			return fmt.Errorf("%w: unknown client address", ErrDenied)
+		}
+		return fmt.Errorf("%w: %s is not in an allowed range", ErrDenied, ip)
+	}
+	return nil
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseHop parses one forwarding entry, which may carry a port.
+func parseHop(raw string) net.IP {
+	raw = strings.TrimSpace(raw)
+	if host, _, err := net.SplitHostPort(raw); err == nil {
+		raw = host
+	}
+	return net.ParseIP(strings.Trim(raw, "[]"))
+}
+
+func parseCIDRs(items []string) ([]*net.IPNet, error) {
+	out := make([]*net.IPNet, 0, len(items))
+	for _, item := range items {
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid cidr %q", item)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				bits = 32
+			}
+			item = fmt.Sprintf("%s/%d", item, bits)
+		}
+		_, n, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cidr %q", item)
+		}
+		out = append(out, n)
+	}
+	return out, nil
+}
+
+func splitList(raw string) []string {
+	if strings.TrimSpace(raw) == "" {
+		return nil
+	}
+	return strings.Split(raw, ",")
+}
+
+func normalizeList(items []string) []string {
+	out := make([]string, 0, len(items))
+	for _, item := range items {
+		if v := strings.TrimSpace(item); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func cloneConfig(cfg Config) Config {
+	cfg.TrustedProxies = append([]string(nil), cfg.TrustedProxies...)
+	cfg.Allow = append([]string(nil), cfg.Allow...)
+	cfg.Deny = append([]string(nil), cfg.Deny...)
+	cfg.AdminAllow = append([]string(nil), cfg.AdminAllow...)
+	rules := make([]Rule, 0, len(cfg.Rules))
+	for _, r := range cfg.Rules {
+		rules = append(rules, Rule{PathPrefix: r.PathPrefix, Allow: append([]string(nil), r.Allow...), Deny: append([]string(nil), r.Deny...)})
+	}
+	cfg.Rules = rules
+	return cfg
+}
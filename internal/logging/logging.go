@@ -0,0 +1,163 @@
+// Package logging builds the gateway's structured logger: JSON lines (or
+// logfmt-style text) with a level, a component field and the run and
+// session of the request being served, ready for Loki or ELK.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// Config selects the level, format and destination of the logger.
+type Config struct {
+	Level  slog.Level
+	Format string
+	Output io.Writer
+}
+
+// ConfigFromEnv reads LOG_LEVEL (debug, info, warn, error; default info),
+// LOG_FORMAT (json or text; default json) and LOG_OUTPUT (stderr, stdout or
+// a file path appended to; default stderr).
+func ConfigFromEnv() (Config, error) {
+	cfg := Config{Format: FormatJSON, Output: os.Stderr}
+	level, err := ParseLevel(os.Getenv("LOG_LEVEL"))
+	if err != nil {
+		return Config{}, err
+	}
+	cfg.Level = level
+	switch format := strings.ToLower(strings.TrimSpace(os.Getenv("LOG_FORMAT"))); format {
+	case "", FormatJSON:
+	case FormatText:
+		cfg.Format = FormatText
+	default:
+		return Config{}, fmt.Errorf("invalid LOG_FORMAT %q: want json or text", format)
+	}
+	switch output := strings.TrimSpace(os.Getenv("LOG_OUTPUT")); output {
+	case "", "stderr":
+	case "stdout":
+		cfg.Output = os.Stdout
+	default:
+		if err := os.MkdirAll(filepath.Dir(output), 0o755); err != nil {
+			return Config{}, fmt.Errorf("create log dir: %w", err)
+		}
+		f, err := os.OpenFile(output, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			return Config{}, fmt.Errorf("open LOG_OUTPUT: %w", err)
+		}
+		cfg.Output = f
+	}
+	return cfg, nil
+}
+
+// ParseLevel maps a level name to a slog level; empty means info.
+func ParseLevel(raw string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(raw)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return 0, fmt.Errorf("invalid LOG_LEVEL %q: want debug, info, warn or error", raw)
+	}
+}
+
+// New builds a logger for cfg. Records logged with a context carry the
+// run_id and session_id attached by WithRun.
+func New(cfg Config) *slog.Logger {
+	out := cfg.Output
+	if out == nil {
+		out = os.Stderr
+	}
+	opts := &slog.HandlerOptions{Level: cfg.Level}
+	var h slog.Handler
+	if cfg.Format == FormatText {
+		h = slog.NewTextHandler(out, opts)
+	} else {
+		h = slog.NewJSONHandler(out, opts)
+	}
+	return slog.New(correlationHandler{h})
+}
+
+// FromEnv builds the logger described by ConfigFromEnv.
+func FromEnv() (*slog.Logger, error) {
+	cfg, err := ConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return New(cfg), nil
+}
+
+// Component returns the default logger tagged with a component name.
+func Component(name string) *slog.Logger {
+	return slog.Default().With("component", name)
+}
+
+type correlationKey struct{}
+
+type correlation struct {
+	runID     string
+	sessionID string
+}
+
+// WithRun attaches the run and session being served to ctx; empty values
+// keep what an outer call attached.
+func WithRun(ctx context.Context, runID, sessionID string) context.Context {
+	prev, _ := ctx.Value(correlationKey{}).(correlation)
+	if runID = strings.TrimSpace(runID); runID != "" {
+		prev.runID = runID
+	}
+	if sessionID = strings.TrimSpace(sessionID); sessionID != "" {
+		prev.sessionID = sessionID
+	}
+	return context.WithValue(ctx, correlationKey{}, prev)
+}
+
+// RunID returns the run attached to ctx by WithRun.
+func RunID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	c, _ := ctx.Value(correlationKey{}).(correlation)
+	return c.runID
+}
+
+// correlationHandler adds run_id and session_id from the record's context.
+type correlationHandler struct {
+	slog.Handler
+}
+
+func (h correlationHandler) Handle(ctx context.Context, r slog.Record) error {
+	if ctx != nil {
+		if c, ok := ctx.Value(correlationKey{}).(correlation); ok {
+			if c.runID != "" {
+				r.AddAttrs(slog.String("run_id", c.runID))
+			}
+			if c.sessionID != "" {
+				r.AddAttrs(slog.String("session_id", c.sessionID))
+			}
+		}
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h correlationHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return correlationHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h correlationHandler) WithGroup(name string) slog.Handler {
+	return correlationHandler{h.Handler.WithGroup(name)}
+}
@@ -179,6 +179,7 @@ func (s *Service) Install(name string, config map[string]string) error {
 		Skills:      manifest.Skills,
 		Hooks:       manifest.Hooks,
 		MCPServers:  manifest.MCPServers,
+		Middleware:  manifest.Middleware,
 		Enabled:     true,
 	}
 
@@ -295,6 +296,7 @@ func (s *Service) Update(name string) error {
 		Skills:      manifest.Skills,
 		Hooks:       manifest.Hooks,
 		MCPServers:  manifest.MCPServers,
+		Middleware:  manifest.Middleware,
 		Enabled:     backup.Enabled, // Preserve enabled state
 	}
 
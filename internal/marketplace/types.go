@@ -18,6 +18,7 @@ type PluginManifest struct {
 	Skills       []plugin.SkillConfig     `json:"skills,omitempty"`
 	Hooks        []plugin.HookConfig      `json:"hooks,omitempty"`
 	MCPServers   []plugin.MCPServerConfig `json:"mcp_servers,omitempty"`
+	Middleware   *plugin.MiddlewareConfig `json:"middleware,omitempty"`
 	ConfigSchema map[string]ConfigField   `json:"config_schema,omitempty"`
 }
 
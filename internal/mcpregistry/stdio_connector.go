@@ -15,9 +15,12 @@ import (
 	"time"
 )
 
+const defaultStdioIdleTimeout = 10 * time.Minute
+
 type stdioConnector struct {
-	mu    sync.Mutex
-	procs map[string]*stdioProcess
+	mu          sync.Mutex
+	procs       map[string]*stdioProcess
+	idleTimeout time.Duration
 }
 
 type stdioProcess struct {
@@ -28,14 +31,27 @@ type stdioProcess struct {
 	stderr *bytes.Buffer
 	nextID int64
 	ready  bool
+	// exited is set under the connector's mu once the reaper's Wait
+	// returns; ProcessState itself is not safe to read concurrently.
+	exited bool
+
+	lastUsed  time.Time
+	idleTimer *time.Timer
 }
 
 func newStdioConnector() *stdioConnector {
 	return &stdioConnector{
-		procs: map[string]*stdioProcess{},
+		procs:       map[string]*stdioProcess{},
+		idleTimeout: defaultStdioIdleTimeout,
 	}
 }
 
+func (c *stdioConnector) setIdleTimeout(d time.Duration) {
+	c.mu.Lock()
+	c.idleTimeout = d
+	c.mu.Unlock()
+}
+
 func (c *stdioConnector) Check(ctx context.Context, server Server) error {
 	return c.check(ctx, server, false)
 }
@@ -140,6 +156,7 @@ func (c *stdioConnector) checkOnce(ctx context.Context, server Server, restart b
 		c.mu.Unlock()
 		return err
 	}
+	c.touchLocked(proc, server)
 	c.mu.Unlock()
 	return nil
 }
@@ -183,13 +200,14 @@ func (c *stdioConnector) requestOnce(ctx context.Context, server Server, method
 		c.mu.Unlock()
 		return nil, err
 	}
+	c.touchLocked(proc, server)
 	c.mu.Unlock()
 	return result, nil
 }
 
 func (c *stdioConnector) ensureProcessLocked(server Server) (*stdioProcess, error) {
 	if proc, ok := c.procs[server.ID]; ok && proc != nil {
-		if proc.cmd != nil && proc.cmd.Process != nil && proc.cmd.ProcessState == nil {
+		if proc.cmd != nil && proc.cmd.Process != nil && !proc.exited {
 			return proc, nil
 		}
 		c.stopLocked(server.ID)
@@ -228,6 +246,7 @@ func (c *stdioConnector) ensureProcessLocked(server Server) (*stdioProcess, erro
 		_ = p.cmd.Wait()
 		c.mu.Lock()
 		defer c.mu.Unlock()
+		p.exited = true
 		if existing := c.procs[id]; existing == p {
 			delete(c.procs, id)
 		}
@@ -236,12 +255,41 @@ func (c *stdioConnector) ensureProcessLocked(server Server) (*stdioProcess, erro
 	return proc, nil
 }
 
+// touchLocked records a successful exchange and re-arms the idle shutdown.
+// The timer re-checks lastUsed because it may fire while a call holds mu.
+func (c *stdioConnector) touchLocked(proc *stdioProcess, server Server) {
+	proc.lastUsed = time.Now()
+	idle := c.idleTimeout
+	if server.IdleTimeoutMS > 0 {
+		idle = time.Duration(server.IdleTimeoutMS) * time.Millisecond
+	} else if server.IdleTimeoutMS < 0 {
+		idle = 0
+	}
+	if proc.idleTimer != nil {
+		proc.idleTimer.Stop()
+		proc.idleTimer = nil
+	}
+	if idle <= 0 {
+		return
+	}
+	proc.idleTimer = time.AfterFunc(idle, func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.procs[proc.id] == proc && time.Since(proc.lastUsed) >= idle {
+			c.stopLocked(proc.id)
+		}
+	})
+}
+
 func (c *stdioConnector) stopLocked(id string) {
 	proc, ok := c.procs[id]
 	if !ok || proc == nil {
 		return
 	}
 	delete(c.procs, id)
+	if proc.idleTimer != nil {
+		proc.idleTimer.Stop()
+	}
 	if proc.stdin != nil {
 		_ = proc.stdin.Close()
 	}
@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -63,51 +64,63 @@ type Server struct {
 	Headers   map[string]string `json:"headers,omitempty"`
 	TimeoutMS int               `json:"timeout_ms"`
 	Retries   int               `json:"retries"`
-	Enabled   bool              `json:"enabled"`
-	Metadata  map[string]any    `json:"metadata,omitempty"`
-	Status    HealthStatus      `json:"status"`
-	CreatedAt time.Time         `json:"created_at"`
-	UpdatedAt time.Time         `json:"updated_at"`
+	// IdleTimeoutMS stops an idle stdio server process; it is restarted on
+	// the next call. 0 uses the registry default, negative keeps it running.
+	IdleTimeoutMS int            `json:"idle_timeout_ms,omitempty"`
+	Enabled       bool           `json:"enabled"`
+	Metadata      map[string]any `json:"metadata,omitempty"`
+	Status        HealthStatus   `json:"status"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	DeletedAt     *time.Time     `json:"deleted_at,omitempty"`
 }
 
 type RegisterInput struct {
-	ID        string            `json:"id,omitempty"`
-	Name      string            `json:"name"`
-	Transport Transport         `json:"transport"`
-	URL       string            `json:"url,omitempty"`
-	Command   string            `json:"command,omitempty"`
-	Args      []string          `json:"args,omitempty"`
-	Env       map[string]string `json:"env,omitempty"`
-	Headers   map[string]string `json:"headers,omitempty"`
-	TimeoutMS int               `json:"timeout_ms,omitempty"`
-	Retries   int               `json:"retries,omitempty"`
-	Enabled   *bool             `json:"enabled,omitempty"`
-	Metadata  map[string]any    `json:"metadata,omitempty"`
+	ID            string            `json:"id,omitempty"`
+	Name          string            `json:"name"`
+	Transport     Transport         `json:"transport"`
+	URL           string            `json:"url,omitempty"`
+	Command       string            `json:"command,omitempty"`
+	Args          []string          `json:"args,omitempty"`
+	Env           map[string]string `json:"env,omitempty"`
+	Headers       map[string]string `json:"headers,omitempty"`
+	TimeoutMS     int               `json:"timeout_ms,omitempty"`
+	Retries       int               `json:"retries,omitempty"`
+	IdleTimeoutMS int               `json:"idle_timeout_ms,omitempty"`
+	Enabled       *bool             `json:"enabled,omitempty"`
+	Metadata      map[string]any    `json:"metadata,omitempty"`
 }
 
 type UpdateInput struct {
-	Name      *string            `json:"name,omitempty"`
-	Transport *Transport         `json:"transport,omitempty"`
-	URL       *string            `json:"url,omitempty"`
-	Command   *string            `json:"command,omitempty"`
-	Args      *[]string          `json:"args,omitempty"`
-	Env       *map[string]string `json:"env,omitempty"`
-	Headers   *map[string]string `json:"headers,omitempty"`
-	TimeoutMS *int               `json:"timeout_ms,omitempty"`
-	Retries   *int               `json:"retries,omitempty"`
-	Enabled   *bool              `json:"enabled,omitempty"`
-	Metadata  *map[string]any    `json:"metadata,omitempty"`
+	Name          *string            `json:"name,omitempty"`
+	Transport     *Transport         `json:"transport,omitempty"`
+	URL           *string            `json:"url,omitempty"`
+	Command       *string            `json:"command,omitempty"`
+	Args          *[]string          `json:"args,omitempty"`
+	Env           *map[string]string `json:"env,omitempty"`
+	Headers       *map[string]string `json:"headers,omitempty"`
+	TimeoutMS     *int               `json:"timeout_ms,omitempty"`
+	Retries       *int               `json:"retries,omitempty"`
+	IdleTimeoutMS *int               `json:"idle_timeout_ms,omitempty"`
+	Enabled       *bool              `json:"enabled,omitempty"`
+	Metadata      *map[string]any    `json:"metadata,omitempty"`
 }
 
 type Store struct {
 	mu            sync.RWMutex
 	servers       map[string]Server
+	deleted       map[string]Server
 	order         []string
 	counter       uint64
 	client        *http.Client
 	stdio         *stdioConnector
 	toolsCache    map[string]toolsCacheEntry
 	toolsCacheTTL time.Duration
+	// sessionHandles maps server id + gateway session id to the MCP session
+	// handle that server issued for it.
+	sessionHandles   map[string]string
+	toolSyncInterval time.Duration
+	toolSync         map[string]ToolSyncStatus
 }
 
 type toolsCacheEntry struct {
@@ -121,11 +134,15 @@ func NewStore(client *http.Client) *Store {
 	}
 	return &Store{
 		servers:       map[string]Server{},
+		deleted:       map[string]Server{},
 		order:         []string{},
 		client:        client,
 		stdio:         newStdioConnector(),
 		toolsCache:    map[string]toolsCacheEntry{},
 		toolsCacheTTL: defaultToolsCacheTTL,
+
+		sessionHandles: map[string]string{},
+		toolSync:       map[string]ToolSyncStatus{},
 	}
 }
 
@@ -138,6 +155,21 @@ func NewFromEnv(client *http.Client) (*Store, error) {
 		}
 		store.SetToolsCacheTTL(time.Duration(ms) * time.Millisecond)
 	}
+	if rawIdle := strings.TrimSpace(os.Getenv("MCP_STDIO_IDLE_TIMEOUT_MS")); rawIdle != "" {
+		ms, err := strconv.Atoi(rawIdle)
+		if err != nil || ms < 0 {
+			return nil, fmt.Errorf("invalid MCP_STDIO_IDLE_TIMEOUT_MS: %q", rawIdle)
+		}
+		store.SetStdioIdleTimeout(time.Duration(ms) * time.Millisecond)
+	}
+	store.SetToolSyncInterval(defaultToolSyncInterval)
+	if rawSync := strings.TrimSpace(os.Getenv("MCP_TOOL_SYNC_INTERVAL_MS")); rawSync != "" {
+		ms, err := strconv.Atoi(rawSync)
+		if err != nil || ms < 0 {
+			return nil, fmt.Errorf("invalid MCP_TOOL_SYNC_INTERVAL_MS: %q", rawSync)
+		}
+		store.SetToolSyncInterval(time.Duration(ms) * time.Millisecond)
+	}
 	raw := strings.TrimSpace(os.Getenv("MCP_SERVERS_JSON"))
 	if raw == "" {
 		return store, nil
@@ -163,6 +195,15 @@ func (s *Store) SetToolsCacheTTL(ttl time.Duration) {
 	s.toolsCacheTTL = ttl
 }
 
+// SetStdioIdleTimeout sets how long a stdio server process may sit unused
+// before it is stopped; 0 keeps processes running until removed.
+func (s *Store) SetStdioIdleTimeout(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	s.stdio.setIdleTimeout(d)
+}
+
 func (s *Store) Register(in RegisterInput) (Server, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -174,27 +215,7 @@ func (s *Store) Register(in RegisterInput) (Server, error) {
 	if _, exists := s.servers[id]; exists {
 		return Server{}, fmt.Errorf("%w: %s", ErrAlreadyExists, id)
 	}
-	now := time.Now().UTC()
-	server := Server{
-		ID:        id,
-		Type:      "mcp_server",
-		Name:      strings.TrimSpace(in.Name),
-		Transport: normalizeTransport(in.Transport),
-		URL:       strings.TrimSpace(in.URL),
-		Command:   strings.TrimSpace(in.Command),
-		Args:      sanitizeList(in.Args),
-		Env:       copyStringMap(in.Env),
-		Headers:   copyStringMap(in.Headers),
-		TimeoutMS: in.TimeoutMS,
-		Retries:   in.Retries,
-		Enabled:   true,
-		Metadata:  copyAnyMap(in.Metadata),
-		CreatedAt: now,
-		UpdatedAt: now,
-	}
-	if in.Enabled != nil {
-		server.Enabled = *in.Enabled
-	}
+	server := serverFromInput(id, in, time.Now().UTC())
 	if err := sanitizeAndValidate(&server); err != nil {
 		return Server{}, err
 	}
@@ -204,6 +225,38 @@ func (s *Store) Register(in RegisterInput) (Server, error) {
 	return cloneServer(server), nil
 }
 
+// ValidateRegisterInput reports whether Register would accept in, leaving
+// aside id collisions.
+func ValidateRegisterInput(in RegisterInput) error {
+	server := serverFromInput(in.ID, in, time.Time{})
+	return sanitizeAndValidate(&server)
+}
+
+func serverFromInput(id string, in RegisterInput, now time.Time) Server {
+	server := Server{
+		ID:            id,
+		Type:          "mcp_server",
+		Name:          strings.TrimSpace(in.Name),
+		Transport:     normalizeTransport(in.Transport),
+		URL:           strings.TrimSpace(in.URL),
+		Command:       strings.TrimSpace(in.Command),
+		Args:          sanitizeList(in.Args),
+		Env:           copyStringMap(in.Env),
+		Headers:       copyStringMap(in.Headers),
+		TimeoutMS:     in.TimeoutMS,
+		Retries:       in.Retries,
+		IdleTimeoutMS: in.IdleTimeoutMS,
+		Enabled:       true,
+		Metadata:      copyAnyMap(in.Metadata),
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if in.Enabled != nil {
+		server.Enabled = *in.Enabled
+	}
+	return server
+}
+
 func (s *Store) Update(id string, in UpdateInput) (Server, error) {
 	id = strings.TrimSpace(id)
 	if id == "" {
@@ -244,6 +297,9 @@ func (s *Store) Update(id string, in UpdateInput) (Server, error) {
 	if in.Retries != nil {
 		server.Retries = *in.Retries
 	}
+	if in.IdleTimeoutMS != nil {
+		server.IdleTimeoutMS = *in.IdleTimeoutMS
+	}
 	if in.Enabled != nil {
 		server.Enabled = *in.Enabled
 	}
@@ -272,11 +328,87 @@ func (s *Store) Delete(id string) error {
 	if _, ok := s.servers[id]; !ok {
 		return fmt.Errorf("%w: %s", ErrNotFound, id)
 	}
+	s.removeLocked(id)
+	return nil
+}
+
+// SoftDelete unregisters the server like Delete but keeps its definition
+// in the trash for Restore.
+func (s *Store) SoftDelete(id string) error {
+	id = strings.TrimSpace(id)
+	if id == "" {
+		return fmt.Errorf("server id is required")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	server, ok := s.servers[id]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	s.removeLocked(id)
+	now := time.Now().UTC()
+	server.DeletedAt = &now
+	s.deleted[id] = server
+	return nil
+}
+
+// Restore re-registers a soft-deleted server under its old id. Stdio
+// servers are started again on their next call.
+func (s *Store) Restore(id string) (Server, error) {
+	id = strings.TrimSpace(id)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	server, ok := s.deleted[id]
+	if !ok {
+		return Server{}, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	if _, exists := s.servers[id]; exists {
+		return Server{}, fmt.Errorf("%w: %s", ErrAlreadyExists, id)
+	}
+	delete(s.deleted, id)
+	server.DeletedAt = nil
+	server.UpdatedAt = time.Now().UTC()
+	s.servers[id] = server
+	s.order = append(s.order, id)
+	return cloneServer(server), nil
+}
+
+// ListDeleted returns the servers in the trash.
+func (s *Store) ListDeleted() []Server {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]Server, 0, len(s.deleted))
+	for _, server := range s.deleted {
+		out = append(out, cloneServer(server))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// Purge drops a soft-deleted server for good.
+func (s *Store) Purge(id string) error {
+	id = strings.TrimSpace(id)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.deleted[id]; !ok {
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	delete(s.deleted, id)
+	return nil
+}
+
+func (s *Store) removeLocked(id string) {
 	if existing, ok := s.servers[id]; ok && existing.Transport == TransportStdio {
 		s.stdio.Stop(id)
 	}
 	delete(s.servers, id)
+	delete(s.toolSync, id)
 	s.invalidateToolsCacheLocked(id)
+	for key := range s.sessionHandles {
+		if strings.HasPrefix(key, id+"\x00") {
+			delete(s.sessionHandles, key)
+		}
+	}
 	next := make([]string, 0, len(s.order))
 	for _, existing := range s.order {
 		if existing != id {
@@ -284,7 +416,6 @@ func (s *Store) Delete(id string) error {
 		}
 	}
 	s.order = next
-	return nil
 }
 
 func (s *Store) Get(id string) (Server, bool) {
@@ -408,8 +539,10 @@ func (s *Store) ListTools(ctx context.Context, id string) ([]Tool, error) {
 	if !server.Enabled {
 		return nil, fmt.Errorf("mcp server %q is disabled", server.ID)
 	}
-	if cached, ok := s.getCachedTools(server.ID); ok {
-		return cached, nil
+	if !toolsCacheBypassed(ctx) {
+		if cached, ok := s.getCachedTools(server.ID); ok {
+			return cached, nil
+		}
 	}
 	result, err := s.rpcRequest(ctx, server, "tools/list", map[string]any{})
 	if err != nil {
@@ -435,10 +568,14 @@ func (s *Store) CallTool(ctx context.Context, id, name string, input map[string]
 	if input == nil {
 		input = map[string]any{}
 	}
-	result, err := s.rpcRequest(ctx, server, "tools/call", map[string]any{
+	params := map[string]any{
 		"name":      name,
 		"arguments": input,
-	})
+	}
+	if sessionID := toolSessionFromContext(ctx); sessionID != "" {
+		params["_meta"] = map[string]any{"session_id": sessionID}
+	}
+	result, err := s.rpcRequest(ctx, server, "tools/call", params)
 	if err != nil {
 		if isToolNotFoundError(err) {
 			s.mu.Lock()
@@ -621,12 +758,21 @@ func (s *Store) requestHTTPRPC(ctx context.Context, server Server, method string
 			req.Header.Set(k, v)
 		}
 	}
+	sessionID := toolSessionFromContext(ctx)
+	if sessionID != "" {
+		if handle := s.sessionHandle(server.ID, sessionID); handle != "" {
+			req.Header.Set(mcpSessionHeader, handle)
+		}
+	}
 
 	resp, err := s.client.Do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
+	if sessionID != "" {
+		s.rememberSessionHandle(server.ID, sessionID, resp.Header.Get(mcpSessionHeader))
+	}
 	body, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
 	if err != nil {
 		return nil, err
@@ -650,6 +796,19 @@ func (s *Store) requestHTTPRPC(ctx context.Context, server Server, method string
 	return map[string]any{}, nil
 }
 
+type bypassToolsCacheKey struct{}
+
+// WithoutToolsCache makes ListTools skip cached tool lists for ctx and go
+// to the server; the fresh result still refreshes the cache.
+func WithoutToolsCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, bypassToolsCacheKey{}, true)
+}
+
+func toolsCacheBypassed(ctx context.Context) bool {
+	v, _ := ctx.Value(bypassToolsCacheKey{}).(bool)
+	return v
+}
+
 func (s *Store) getCachedTools(serverID string) ([]Tool, bool) {
 	serverID = strings.TrimSpace(serverID)
 	if serverID == "" {
@@ -676,7 +835,7 @@ func (s *Store) putCachedTools(serverID string, tools []Tool) {
 	defer s.mu.Unlock()
 	s.toolsCache[serverID] = toolsCacheEntry{
 		tools:     cloneTools(tools),
-		expiresAt: time.Now().Add(s.toolsCacheTTL),
+		expiresAt: time.Now().Add(s.toolsCacheLifetimeLocked()),
 	}
 }
 
@@ -0,0 +1,93 @@
+package mcpregistry
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// mcpSessionHeader is the streamable-HTTP MCP session header. Servers that
+// keep per-client state (browser tabs, shells, ...) hand it out on the first
+// call and expect it back on later ones.
+const mcpSessionHeader = "Mcp-Session-Id"
+
+type toolSessionKey struct{}
+
+// WithToolSession tags ctx with the gateway session issuing tool calls, so
+// each gateway session gets its own MCP session handle per server and the
+// session id is forwarded to the server in params._meta.
+func WithToolSession(ctx context.Context, sessionID string) context.Context {
+	sessionID = strings.TrimSpace(sessionID)
+	if sessionID == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, toolSessionKey{}, sessionID)
+}
+
+func toolSessionFromContext(ctx context.Context) string {
+	v, _ := ctx.Value(toolSessionKey{}).(string)
+	return v
+}
+
+func sessionHandleKey(serverID, sessionID string) string {
+	return serverID + "\x00" + sessionID
+}
+
+func (s *Store) sessionHandle(serverID, sessionID string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.sessionHandles[sessionHandleKey(serverID, sessionID)]
+}
+
+func (s *Store) rememberSessionHandle(serverID, sessionID, handle string) {
+	handle = strings.TrimSpace(handle)
+	if handle == "" {
+		return
+	}
+	s.mu.Lock()
+	s.sessionHandles[sessionHandleKey(serverID, sessionID)] = handle
+	s.mu.Unlock()
+}
+
+// ReleaseToolSession forgets every MCP session handle held for sessionID and
+// asks HTTP servers to terminate them. It returns how many were released.
+func (s *Store) ReleaseToolSession(ctx context.Context, sessionID string) int {
+	sessionID = strings.TrimSpace(sessionID)
+	if sessionID == "" {
+		return 0
+	}
+	type held struct {
+		server Server
+		handle string
+	}
+	var released []held
+	s.mu.Lock()
+	for key, handle := range s.sessionHandles {
+		serverID, sid, ok := strings.Cut(key, "\x00")
+		if !ok || sid != sessionID {
+			continue
+		}
+		delete(s.sessionHandles, key)
+		if server, ok := s.servers[serverID]; ok {
+			released = append(released, held{server: server, handle: handle})
+		}
+	}
+	s.mu.Unlock()
+
+	for _, h := range released {
+		if h.server.Transport != TransportHTTP {
+			continue
+		}
+		dctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+		req, err := http.NewRequestWithContext(dctx, http.MethodDelete, h.server.URL, nil)
+		if err == nil {
+			req.Header.Set(mcpSessionHeader, h.handle)
+			if resp, err := s.client.Do(req); err == nil {
+				resp.Body.Close()
+			}
+		}
+		cancel()
+	}
+	return len(released)
+}
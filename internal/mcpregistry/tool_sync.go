@@ -0,0 +1,193 @@
+package mcpregistry
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultToolSyncInterval applies to registries built from the
+	// environment; MCP_TOOL_SYNC_INTERVAL_MS=0 turns background sync off.
+	defaultToolSyncInterval = time.Minute
+	defaultToolSyncTimeout  = 10 * time.Second
+	minToolSyncInterval     = time.Second
+)
+
+// ToolSyncStatus records the last background or forced refresh of one
+// server's tool list.
+type ToolSyncStatus struct {
+	ServerID      string    `json:"server_id"`
+	ToolCount     int       `json:"tool_count"`
+	SchemaHash    string    `json:"schema_hash,omitempty"`
+	LastSyncAt    time.Time `json:"last_sync_at,omitempty"`
+	LastSuccessAt time.Time `json:"last_success_at,omitempty"`
+	LastChangedAt time.Time `json:"last_changed_at,omitempty"`
+	LastError     string    `json:"last_error,omitempty"`
+	LastLatencyMS int64     `json:"last_latency_ms"`
+	Syncs         int64     `json:"syncs"`
+	Failures      int64     `json:"failures"`
+}
+
+// SetToolSyncInterval records the background sync period. While it is set,
+// cached tool lists outlive the regular TTL so calls between syncs never
+// block on tools/list.
+func (s *Store) SetToolSyncInterval(d time.Duration) {
+	if d > 0 && d < minToolSyncInterval {
+		d = minToolSyncInterval
+	}
+	if d < 0 {
+		d = 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.toolSyncInterval = d
+}
+
+func (s *Store) ToolSyncInterval() time.Duration {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.toolSyncInterval
+}
+
+// StartToolSync refreshes every enabled server's tools once and then on each
+// sync interval until ctx is done. It is a no-op when no interval is set.
+func (s *Store) StartToolSync(ctx context.Context) {
+	interval := s.ToolSyncInterval()
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		s.SyncAllTools(ctx)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.SyncAllTools(ctx)
+			}
+		}
+	}()
+}
+
+// SyncAllTools refreshes the tool cache of every enabled server.
+func (s *Store) SyncAllTools(ctx context.Context) []ToolSyncStatus {
+	servers := s.List(0)
+	out := make([]ToolSyncStatus, 0, len(servers))
+	for _, server := range servers {
+		if !server.Enabled {
+			continue
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		status, _ := s.SyncTools(ctx, server.ID)
+		out = append(out, status)
+	}
+	sortToolSyncStatuses(out)
+	return out
+}
+
+// SyncTools fetches a server's tool list, bypassing the cache. On failure the
+// previously synced list keeps serving.
+func (s *Store) SyncTools(ctx context.Context, id string) (ToolSyncStatus, error) {
+	server, err := s.serverByID(id)
+	if err != nil {
+		return ToolSyncStatus{}, err
+	}
+	if !server.Enabled {
+		return ToolSyncStatus{}, fmt.Errorf("mcp server %q is disabled", server.ID)
+	}
+	timeout := defaultToolSyncTimeout
+	if server.TimeoutMS > 0 {
+		timeout = time.Duration(server.TimeoutMS) * time.Millisecond
+	}
+	syncCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	started := time.Now()
+	result, rpcErr := s.rpcRequest(syncCtx, server, "tools/list", map[string]any{})
+	now := time.Now().UTC()
+
+	var tools []Tool
+	if rpcErr == nil {
+		tools = parseTools(result)
+		s.putCachedTools(server.ID, tools)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.servers[server.ID]; !ok {
+		return ToolSyncStatus{}, fmt.Errorf("%w: %s", ErrNotFound, server.ID)
+	}
+	status := s.toolSync[server.ID]
+	status.ServerID = server.ID
+	status.LastSyncAt = now
+	status.LastLatencyMS = time.Since(started).Milliseconds()
+	status.Syncs++
+	if rpcErr != nil {
+		status.Failures++
+		status.LastError = rpcErr.Error()
+		s.toolSync[server.ID] = status
+		return status, rpcErr
+	}
+	hash := toolSchemaHash(tools)
+	if hash != status.SchemaHash {
+		status.LastChangedAt = now
+	}
+	status.SchemaHash = hash
+	status.ToolCount = len(tools)
+	status.LastSuccessAt = now
+	status.LastError = ""
+	s.toolSync[server.ID] = status
+	return status, nil
+}
+
+// ToolSyncStatuses returns the sync state of every server that has synced.
+func (s *Store) ToolSyncStatuses() []ToolSyncStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]ToolSyncStatus, 0, len(s.toolSync))
+	for _, status := range s.toolSync {
+		out = append(out, status)
+	}
+	sortToolSyncStatuses(out)
+	return out
+}
+
+// toolsCacheLifetimeLocked is how long a fetched tool list stays fresh. With
+// background sync on it covers two missed syncs before calls fall back to
+// fetching on demand.
+func (s *Store) toolsCacheLifetimeLocked() time.Duration {
+	ttl := s.toolsCacheTTL
+	if s.toolSyncInterval > 0 {
+		if synced := 2*s.toolSyncInterval + defaultToolSyncTimeout; synced > ttl {
+			ttl = synced
+		}
+	}
+	return ttl
+}
+
+func toolSchemaHash(tools []Tool) string {
+	sorted := cloneTools(tools)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	raw, err := json.Marshal(sorted)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:8])
+}
+
+func sortToolSyncStatuses(in []ToolSyncStatus) {
+	sort.Slice(in, func(i, j int) bool {
+		return strings.Compare(in[i].ServerID, in[j].ServerID) < 0
+	})
+}
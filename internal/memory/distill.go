@@ -0,0 +1,172 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"ccgateway/internal/orchestrator"
+)
+
+// Distiller folds a stretch of conversation into the session's rolling
+// summary and durable facts.
+type Distiller interface {
+	Distill(ctx context.Context, req DistillRequest) (Distillation, error)
+}
+
+// DistillRequest carries what is already remembered so the model updates
+// it instead of starting over. An empty Model uses the distiller's default.
+type DistillRequest struct {
+	Model           string
+	PreviousSummary string
+	Facts           []string
+	Messages        []Message
+}
+
+// Distillation is the updated summary and the facts worth keeping.
+type Distillation struct {
+	Summary string   `json:"summary"`
+	Facts   []string `json:"facts"`
+}
+
+const distillSystemPrompt = `You maintain the memory of an ongoing conversation between a user and an AI assistant.
+Given the previous summary, the facts already known and the latest messages, return JSON only:
+{"summary": "...", "facts": ["...", "..."]}
+
+- summary: the updated summary of the whole conversation (goals, progress, open issues), under 200 words.
+- facts: durable, self-contained facts worth remembering in later turns: user preferences, names, file paths, decisions, constraints. Keep still-valid known facts, drop outdated ones, at most 20.`
+
+// Distill implements Distiller with the summarizer's upstream client.
+func (s *LLMSummarizer) Distill(ctx context.Context, req DistillRequest) (Distillation, error) {
+	model := strings.TrimSpace(req.Model)
+	if model == "" {
+		model = s.model
+	}
+	resp, err := s.upstreamClient.Complete(ctx, orchestrator.Request{
+		Model:     model,
+		System:    distillSystemPrompt,
+		Messages:  []orchestrator.Message{{Role: "user", Content: buildDistillPrompt(req)}},
+		MaxTokens: 800,
+	})
+	if err != nil {
+		return Distillation{}, fmt.Errorf("distill failed: %w", err)
+	}
+	var text strings.Builder
+	for _, b := range resp.Blocks {
+		if b.Type == "" || b.Type == "text" {
+			text.WriteString(b.Text)
+		}
+	}
+	return ParseDistillation(text.String())
+}
+
+func buildDistillPrompt(req DistillRequest) string {
+	var sb strings.Builder
+	if strings.TrimSpace(req.PreviousSummary) != "" {
+		sb.WriteString("Previous summary:\n")
+		sb.WriteString(strings.TrimSpace(req.PreviousSummary))
+		sb.WriteString("\n\n")
+	}
+	if len(req.Facts) > 0 {
+		sb.WriteString("Known facts:\n")
+		for _, f := range req.Facts {
+			sb.WriteString("- ")
+			sb.WriteString(f)
+			sb.WriteString("\n")
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString("Latest messages:\n\n")
+	for _, msg := range req.Messages {
+		sb.WriteString(fmt.Sprintf("%s: %s\n\n", msg.Role, msg.Content))
+	}
+	return sb.String()
+}
+
+// ParseDistillation reads the model's JSON answer, tolerating code fences
+// and surrounding prose. An answer without JSON becomes the summary.
+func ParseDistillation(text string) (Distillation, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return Distillation{}, fmt.Errorf("empty distill response")
+	}
+	var out Distillation
+	start, end := strings.Index(text, "{"), strings.LastIndex(text, "}")
+	if start >= 0 && end > start && json.Unmarshal([]byte(text[start:end+1]), &out) == nil {
+		out.Summary = strings.TrimSpace(out.Summary)
+		out.Facts = cleanFacts(out.Facts)
+		return out, nil
+	}
+	return Distillation{Summary: text}, nil
+}
+
+func cleanFacts(in []string) []string {
+	var out []string
+	for _, f := range in {
+		if f = strings.TrimSpace(f); f != "" {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// MergeFacts replaces the fact list with the distilled one, keeping the
+// original timestamp of facts that survive and at most max entries (0
+// means no limit).
+func MergeFacts(existing []Fact, distilled []string, runID string, max int, now time.Time) []Fact {
+	known := make(map[string]Fact, len(existing))
+	for _, f := range existing {
+		known[strings.ToLower(f.Text)] = f
+	}
+	seen := map[string]bool{}
+	var out []Fact
+	for _, text := range cleanFacts(distilled) {
+		key := strings.ToLower(text)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if f, ok := known[key]; ok {
+			out = append(out, f)
+			continue
+		}
+		out = append(out, Fact{Text: text, RunID: runID, CreatedAt: now})
+	}
+	if max > 0 && len(out) > max {
+		out = out[len(out)-max:]
+	}
+	return out
+}
+
+// FactTexts returns the text of each fact.
+func FactTexts(facts []Fact) []string {
+	out := make([]string, 0, len(facts))
+	for _, f := range facts {
+		out = append(out, f.Text)
+	}
+	return out
+}
+
+// RenderContext formats the session memory for the system prompt; it is
+// empty when nothing has been remembered yet.
+func RenderContext(sm *SessionMemory) string {
+	if sm == nil || (strings.TrimSpace(sm.Summary) == "" && len(sm.Facts) == 0) {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("[CC_MEMORY]\nMemory of this conversation so far; use it, but prefer the latest messages when they disagree.")
+	if summary := strings.TrimSpace(sm.Summary); summary != "" {
+		sb.WriteString("\n\nSummary:\n")
+		sb.WriteString(summary)
+	}
+	if len(sm.Facts) > 0 {
+		sb.WriteString("\n\nKnown facts:")
+		for _, f := range sm.Facts {
+			sb.WriteString("\n- ")
+			sb.WriteString(f.Text)
+		}
+	}
+	return sb.String()
+}
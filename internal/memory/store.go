@@ -179,6 +179,37 @@ func (s *InMemoryStore) CleanupExpired(ctx context.Context, ttl time.Duration) e
 	return nil
 }
 
+// DeleteSessions 删除指定会话的工作记忆与会话记忆，返回删除的条数
+func (s *InMemoryStore) DeleteSessions(ctx context.Context, sessionIDs map[string]bool) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	for id := range sessionIDs {
+		if _, ok := s.workingMemory[id]; ok {
+			delete(s.workingMemory, id)
+			n++
+		}
+		if _, ok := s.sessionMemory[id]; ok {
+			delete(s.sessionMemory, id)
+			n++
+		}
+	}
+	return n
+}
+
+// DeleteLongTermMemory 删除用户的长期记忆
+func (s *InMemoryStore) DeleteLongTermMemory(ctx context.Context, userID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.longTermMemory[userID]; !ok {
+		return false
+	}
+	delete(s.longTermMemory, userID)
+	return true
+}
+
 // GetStats 获取统计信息
 func (s *InMemoryStore) GetStats() map[string]int {
 	s.mu.RLock()
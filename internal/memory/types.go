@@ -17,6 +17,7 @@ type SessionMemory struct {
 	FileOperations []FileOp               // 文件操作历史
 	UserPrefs      map[string]string      // 用户偏好
 	Summary        string                 // 会话摘要
+	Facts          []Fact                 // 提取的关键事实
 	LastUpdate     time.Time
 	TokenCount     int
 }
@@ -39,6 +40,13 @@ type Message struct {
 	TokenCount int
 }
 
+// Fact 会话中提取的一条关键事实
+type Fact struct {
+	Text      string
+	RunID     string // 提取时最后一轮的 run
+	CreatedAt time.Time
+}
+
 // FileOp 文件操作记录
 type FileOp struct {
 	Action    string // create, modify, delete
@@ -13,6 +13,12 @@ type Mapper interface {
 	Resolve(model string) (string, error)
 }
 
+// GroupMapper is implemented by mappers whose entries may name an ordered
+// list of upstream models; callers try each target until one serves.
+type GroupMapper interface {
+	ResolveGroup(model string) ([]string, error)
+}
+
 type IdentityMapper struct{}
 
 func NewIdentityMapper() *IdentityMapper {
@@ -27,7 +33,7 @@ func (m *IdentityMapper) Resolve(model string) (string, error) {
 }
 
 type StaticMapper struct {
-	mapping  map[string]string
+	mapping  map[string][]string
 	patterns []mapPattern
 	strict   bool
 	fallback string
@@ -35,27 +41,37 @@ type StaticMapper struct {
 
 type mapPattern struct {
 	pattern     string
-	target      string
+	targets     []string
 	specificity int
 }
 
 func NewStaticMapper(mapping map[string]string, strict bool, fallback string) *StaticMapper {
-	m := make(map[string]string, len(mapping))
+	groups := make(map[string][]string, len(mapping))
+	for k, v := range mapping {
+		groups[k] = []string{v}
+	}
+	return NewGroupMapper(groups, strict, fallback)
+}
+
+// NewGroupMapper builds a mapper whose entries are alias groups: ordered
+// upstream models tried in turn. Resolve returns the first target.
+func NewGroupMapper(mapping map[string][]string, strict bool, fallback string) *StaticMapper {
+	m := make(map[string][]string, len(mapping))
 	patterns := make([]mapPattern, 0)
 	for k, v := range mapping {
 		key := strings.TrimSpace(k)
-		val := strings.TrimSpace(v)
-		if key == "" || val == "" {
+		targets := cleanTargets(v)
+		if key == "" || len(targets) == 0 {
 			continue
 		}
 		if strings.Contains(key, "*") {
 			patterns = append(patterns, mapPattern{
 				pattern:     key,
-				target:      val,
+				targets:     targets,
 				specificity: len(strings.ReplaceAll(key, "*", "")),
 			})
 		} else {
-			m[key] = val
+			m[key] = targets
 		}
 	}
 	sort.Slice(patterns, func(i, j int) bool {
@@ -73,12 +89,21 @@ func NewStaticMapper(mapping map[string]string, strict bool, fallback string) *S
 }
 
 func (m *StaticMapper) Resolve(model string) (string, error) {
+	targets, err := m.ResolveGroup(model)
+	if err != nil {
+		return "", err
+	}
+	return targets[0], nil
+}
+
+// ResolveGroup returns every target of the entry matching model, in order.
+func (m *StaticMapper) ResolveGroup(model string) ([]string, error) {
 	model = strings.TrimSpace(model)
 	if model == "" {
-		return "", fmt.Errorf("model is required")
+		return nil, fmt.Errorf("model is required")
 	}
-	if target, ok := m.mapping[model]; ok {
-		return target, nil
+	if targets, ok := m.mapping[model]; ok {
+		return append([]string(nil), targets...), nil
 	}
 	for _, p := range m.patterns {
 		matched, err := path.Match(p.pattern, model)
@@ -86,16 +111,30 @@ func (m *StaticMapper) Resolve(model string) (string, error) {
 			continue
 		}
 		if matched {
-			return p.target, nil
+			return append([]string(nil), p.targets...), nil
 		}
 	}
 	if m.fallback != "" {
-		return m.fallback, nil
+		return []string{m.fallback}, nil
 	}
 	if m.strict {
-		return "", fmt.Errorf("model %q is not mapped", model)
+		return nil, fmt.Errorf("model %q is not mapped", model)
 	}
-	return model, nil
+	return []string{model}, nil
+}
+
+func cleanTargets(in []string) []string {
+	out := make([]string, 0, len(in))
+	seen := map[string]bool{}
+	for _, t := range in {
+		t = strings.TrimSpace(t)
+		if t == "" || seen[t] {
+			continue
+		}
+		seen[t] = true
+		out = append(out, t)
+	}
+	return out
 }
 
 func NewFromEnv() (Mapper, error) {
@@ -114,13 +153,28 @@ func NewFromEnv() (Mapper, error) {
 	if len(mapping) == 0 && !strict && fallback == "" {
 		return NewIdentityMapper(), nil
 	}
-	return NewStaticMapper(mapping, strict, fallback), nil
+	return NewGroupMapper(mapping, strict, fallback), nil
 }
 
-func parseModelMapJSON(raw string) (map[string]string, error) {
-	var m map[string]string
+// parseModelMapJSON accepts a single upstream model or an ordered list per
+// entry: {"claude-sonnet":["provider-a/large","provider-b/medium"]}.
+func parseModelMapJSON(raw string) (map[string][]string, error) {
+	var m map[string]json.RawMessage
 	if err := json.Unmarshal([]byte(raw), &m); err != nil {
 		return nil, fmt.Errorf("invalid MODEL_MAP_JSON: %w", err)
 	}
-	return m, nil
+	out := make(map[string][]string, len(m))
+	for k, v := range m {
+		var one string
+		if err := json.Unmarshal(v, &one); err == nil {
+			out[k] = []string{one}
+			continue
+		}
+		var group []string
+		if err := json.Unmarshal(v, &group); err != nil {
+			return nil, fmt.Errorf("invalid MODEL_MAP_JSON: entry %q must be a model or a list of models", k)
+		}
+		out[k] = group
+	}
+	return out, nil
 }
@@ -0,0 +1,371 @@
+package notification
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Severities, lowest first.
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// List statuses accepted by ListFilter.
+const (
+	StatusAll            = "all"
+	StatusUnread         = "unread"
+	StatusUnacknowledged = "unacknowledged"
+	StatusOpen           = "open"
+	StatusResolved       = "resolved"
+)
+
+// defaultMaxItems bounds how many notifications are kept; the oldest
+// resolved or acknowledged ones are dropped first.
+const defaultMaxItems = 500
+
+var (
+	ErrNotFound      = errors.New("notification not found")
+	ErrInvalidStatus = errors.New("status must be one of all, unread, unacknowledged, open, resolved")
+)
+
+// Notification is one operator-facing warning. Repeats of an open
+// notification with the same Key bump Count instead of adding a row, so a
+// condition that keeps firing shows up once.
+type Notification struct {
+	ID             string         `json:"id"`
+	Key            string         `json:"key"`
+	Kind           string         `json:"kind"`
+	Severity       string         `json:"severity"`
+	Title          string         `json:"title"`
+	Message        string         `json:"message,omitempty"`
+	Data           map[string]any `json:"data,omitempty"`
+	Count          int            `json:"count"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	ReadAt         *time.Time     `json:"read_at,omitempty"`
+	AcknowledgedAt *time.Time     `json:"acknowledged_at,omitempty"`
+	AcknowledgedBy string         `json:"acknowledged_by,omitempty"`
+	ResolvedAt     *time.Time     `json:"resolved_at,omitempty"`
+}
+
+// RaiseInput describes a condition. Key defaults to Kind.
+type RaiseInput struct {
+	Key      string
+	Kind     string
+	Severity string
+	Title    string
+	Message  string
+	Data     map[string]any
+}
+
+type ListFilter struct {
+	Status   string
+	Severity string
+	Kind     string
+	Limit    int
+}
+
+// Counts summarises the notifications that still need attention.
+type Counts struct {
+	Total          int            `json:"total"`
+	Unread         int            `json:"unread"`
+	Unacknowledged int            `json:"unacknowledged"`
+	BySeverity     map[string]int `json:"by_severity"`
+}
+
+// Center keeps notifications in memory. It is safe for concurrent use.
+type Center struct {
+	mu       sync.RWMutex
+	items    map[string]*Notification
+	open     map[string]string
+	maxItems int
+	seq      atomic.Uint64
+	now      func() time.Time
+}
+
+func NewCenter() *Center {
+	return &Center{
+		items:    map[string]*Notification{},
+		open:     map[string]string{},
+		maxItems: defaultMaxItems,
+		now:      time.Now,
+	}
+}
+
+// Raise records the condition. If an unresolved notification with the same
+// key exists it is updated in place; a repeat after acknowledgement is kept
+// acknowledged but marked unread again so the badge shows it changed.
+func (c *Center) Raise(in RaiseInput) Notification {
+	key := strings.TrimSpace(in.Key)
+	if key == "" {
+		key = strings.TrimSpace(in.Kind)
+	}
+	now := c.now().UTC()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if id, ok := c.open[key]; ok {
+		n := c.items[id]
+		n.Count++
+		n.UpdatedAt = now
+		n.ReadAt = nil
+		if in.Title != "" {
+			n.Title = in.Title
+		}
+		n.Message = in.Message
+		n.Data = cloneData(in.Data)
+		if sev := normalizeSeverity(in.Severity); severityRank(sev) > severityRank(n.Severity) {
+			n.Severity = sev
+			n.AcknowledgedAt = nil
+			n.AcknowledgedBy = ""
+		}
+		return cloneNotification(n)
+	}
+	n := &Notification{
+		ID:        fmt.Sprintf("ntf_%d_%d", now.UnixNano(), c.seq.Add(1)),
+		Key:       key,
+		Kind:      strings.TrimSpace(in.Kind),
+		Severity:  normalizeSeverity(in.Severity),
+		Title:     in.Title,
+		Message:   in.Message,
+		Data:      cloneData(in.Data),
+		Count:     1,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	c.items[n.ID] = n
+	c.open[key] = n.ID
+	c.pruneLocked()
+	return cloneNotification(n)
+}
+
+// Resolve closes the open notification for key, if any. Later raises of
+// the same key start a new notification.
+func (c *Center) Resolve(key string) (Notification, bool) {
+	key = strings.TrimSpace(key)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	id, ok := c.open[key]
+	if !ok {
+		return Notification{}, false
+	}
+	delete(c.open, key)
+	n := c.items[id]
+	now := c.now().UTC()
+	n.ResolvedAt = &now
+	n.UpdatedAt = now
+	return cloneNotification(n), true
+}
+
+// IsOpen reports whether key has an unresolved notification.
+func (c *Center) IsOpen(key string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	_, ok := c.open[strings.TrimSpace(key)]
+	return ok
+}
+
+func (c *Center) Get(id string) (Notification, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	n, ok := c.items[strings.TrimSpace(id)]
+	if !ok {
+		return Notification{}, false
+	}
+	return cloneNotification(n), true
+}
+
+func (c *Center) MarkRead(id string) (Notification, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n, ok := c.items[strings.TrimSpace(id)]
+	if !ok {
+		return Notification{}, ErrNotFound
+	}
+	if n.ReadAt == nil {
+		now := c.now().UTC()
+		n.ReadAt = &now
+	}
+	return cloneNotification(n), nil
+}
+
+// Acknowledge marks the notification handled by actor. Acknowledging also
+// marks it read.
+func (c *Center) Acknowledge(id, actor string) (Notification, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n, ok := c.items[strings.TrimSpace(id)]
+	if !ok {
+		return Notification{}, ErrNotFound
+	}
+	now := c.now().UTC()
+	if n.ReadAt == nil {
+		n.ReadAt = &now
+	}
+	if n.AcknowledgedAt == nil {
+		n.AcknowledgedAt = &now
+		n.AcknowledgedBy = strings.TrimSpace(actor)
+	}
+	return cloneNotification(n), nil
+}
+
+// ReadAll marks every unread notification read and returns how many
+// changed.
+func (c *Center) ReadAll() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now().UTC()
+	changed := 0
+	for _, n := range c.items {
+		if n.ReadAt == nil {
+			t := now
+			n.ReadAt = &t
+			changed++
+		}
+	}
+	return changed
+}
+
+// List returns matching notifications, most recently updated first.
+func (c *Center) List(filter ListFilter) ([]Notification, error) {
+	status := strings.ToLower(strings.TrimSpace(filter.Status))
+	if status == "" {
+		status = StatusAll
+	}
+	switch status {
+	case StatusAll, StatusUnread, StatusUnacknowledged, StatusOpen, StatusResolved:
+	default:
+		return nil, ErrInvalidStatus
+	}
+	severity := strings.ToLower(strings.TrimSpace(filter.Severity))
+	kind := strings.TrimSpace(filter.Kind)
+
+	c.mu.RLock()
+	out := make([]Notification, 0, len(c.items))
+	for _, n := range c.items {
+		if !matchesStatus(n, status) {
+			continue
+		}
+		if severity != "" && n.Severity != severity {
+			continue
+		}
+		if kind != "" && n.Kind != kind {
+			continue
+		}
+		out = append(out, cloneNotification(n))
+	}
+	c.mu.RUnlock()
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].UpdatedAt.Equal(out[j].UpdatedAt) {
+			return out[i].ID > out[j].ID
+		}
+		return out[i].UpdatedAt.After(out[j].UpdatedAt)
+	})
+	if filter.Limit > 0 && len(out) > filter.Limit {
+		out = out[:filter.Limit]
+	}
+	return out, nil
+}
+
+// Counts reports the badge numbers. Resolved notifications still count
+// until they are read or acknowledged, so a flap that cleared on its own
+// is not missed.
+func (c *Center) Counts() Counts {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	out := Counts{BySeverity: map[string]int{}}
+	for _, n := range c.items {
+		out.Total++
+		if n.ReadAt == nil {
+			out.Unread++
+		}
+		if n.AcknowledgedAt == nil {
+			out.Unacknowledged++
+			out.BySeverity[n.Severity]++
+		}
+	}
+	return out
+}
+
+func matchesStatus(n *Notification, status string) bool {
+	switch status {
+	case StatusUnread:
+		return n.ReadAt == nil
+	case StatusUnacknowledged:
+		return n.AcknowledgedAt == nil
+	case StatusOpen:
+		return n.ResolvedAt == nil
+	case StatusResolved:
+		return n.ResolvedAt != nil
+	}
+	return true
+}
+
+// pruneLocked drops the oldest closed notifications once over maxItems.
+// Open ones are never dropped.
+func (c *Center) pruneLocked() {
+	if c.maxItems <= 0 || len(c.items) <= c.maxItems {
+		return
+	}
+	closed := make([]*Notification, 0, len(c.items))
+	for _, n := range c.items {
+		if n.ResolvedAt != nil || n.AcknowledgedAt != nil {
+			if id, open := c.open[n.Key]; open && id == n.ID {
+				continue
+			}
+			closed = append(closed, n)
+		}
+	}
+	sort.Slice(closed, func(i, j int) bool { return closed[i].UpdatedAt.Before(closed[j].UpdatedAt) })
+	for _, n := range closed {
+		if len(c.items) <= c.maxItems {
+			return
+		}
+		delete(c.items, n.ID)
+	}
+}
+
+func normalizeSeverity(s string) string {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case SeverityCritical:
+		return SeverityCritical
+	case SeverityInfo:
+		return SeverityInfo
+	}
+	return SeverityWarning
+}
+
+func severityRank(s string) int {
+	switch s {
+	case SeverityCritical:
+		return 2
+	case SeverityWarning:
+		return 1
+	}
+	return 0
+}
+
+func cloneNotification(n *Notification) Notification {
+	out := *n
+	out.Data = cloneData(n.Data)
+	return out
+}
+
+func cloneData(in map[string]any) map[string]any {
+	if len(in) == 0 {
+		return nil
+	}
+	out := make(map[string]any, len(in))
+	for k, v := range in {
+		out[k] = v
+	}
+	return out
+}
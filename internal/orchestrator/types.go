@@ -1,6 +1,9 @@
 package orchestrator
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 type Service interface {
 	Complete(ctx context.Context, req Request) (Response, error)
@@ -8,14 +11,29 @@ type Service interface {
 }
 
 type Request struct {
-	RunID     string
-	Model     string
-	MaxTokens int
-	System    any
-	Messages  []Message
-	Tools     []Tool
-	Metadata  map[string]any
-	Headers   map[string]string
+	RunID          string
+	Model          string
+	MaxTokens      int
+	System         any
+	Messages       []Message
+	Tools          []Tool
+	ResponseFormat *ResponseFormat
+	Metadata       map[string]any
+	Headers        map[string]string
+}
+
+// ResponseFormat asks the upstream for structured output. Type is "text",
+// "json_object" or "json_schema"; Schema is only used with json_schema.
+type ResponseFormat struct {
+	Type   string         `json:"type"`
+	Name   string         `json:"name,omitempty"`
+	Schema map[string]any `json:"schema,omitempty"`
+	Strict bool           `json:"strict,omitempty"`
+}
+
+// WantsJSON reports whether the response must be a JSON document.
+func (f *ResponseFormat) WantsJSON() bool {
+	return f != nil && (f.Type == "json_object" || f.Type == "json_schema")
 }
 
 type Message struct {
@@ -27,6 +45,11 @@ type Tool struct {
 	Name        string
 	Description string
 	InputSchema map[string]any
+	// Type is the Anthropic built-in tool type (e.g. "bash_20250124") the
+	// client declared; empty for plain function tools. Options holds the
+	// declaration's extra fields, such as a computer tool's display size.
+	Type    string
+	Options map[string]any
 }
 
 type Response struct {
@@ -35,6 +58,19 @@ type Response struct {
 	StopReason string
 	Usage      Usage
 	Trace      Trace
+	// Citations lists the tool result chunks the answer cites inline.
+	Citations []Citation
+}
+
+// Citation ties an inline [S#] marker in the answer to the tool result
+// chunk it refers to.
+type Citation struct {
+	SourceID  string `json:"source_id"`
+	ToolUseID string `json:"tool_use_id"`
+	ToolName  string `json:"tool_name,omitempty"`
+	Chunk     int    `json:"chunk"`
+	Text      string `json:"text"`
+	Mentions  int    `json:"mentions"`
 }
 
 type AssistantBlock struct {
@@ -71,4 +107,14 @@ type StreamEvent struct {
 	RawEvent    string
 	RawData     []byte
 	PassThrough bool
+	// Queue is set on "ping" keep-alives sent while the request waits for
+	// a saturated upstream to admit it.
+	Queue *QueueInfo
+}
+
+// QueueInfo describes a request's wait in an upstream admission queue.
+type QueueInfo struct {
+	Adapter string
+	Depth   int
+	Waited  time.Duration
 }
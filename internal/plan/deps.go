@@ -0,0 +1,72 @@
+package plan
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// OrderSteps returns steps in an order that respects DependsOn, keeping the
+// given order wherever dependencies allow. Steps without an ID are numbered
+// s1, s2, ...; dependencies on unknown or repeated IDs and cycles are
+// errors.
+func OrderSteps(steps []Step) ([]Step, error) {
+	steps = cloneSteps(steps)
+	index := make(map[string]int, len(steps))
+	for i := range steps {
+		if steps[i].ID == "" {
+			steps[i].ID = "s" + strconv.Itoa(i+1)
+		}
+		if _, exists := index[steps[i].ID]; exists {
+			return nil, fmt.Errorf("duplicate step id %q", steps[i].ID)
+		}
+		index[steps[i].ID] = i
+	}
+	pending := make([]int, len(steps))
+	dependents := make([][]int, len(steps))
+	for i, step := range steps {
+		seen := map[string]bool{}
+		for _, dep := range step.DependsOn {
+			j, ok := index[dep]
+			if !ok {
+				return nil, fmt.Errorf("step %q depends on unknown step %q", step.ID, dep)
+			}
+			if j == i {
+				return nil, fmt.Errorf("step %q depends on itself", step.ID)
+			}
+			if seen[dep] {
+				continue
+			}
+			seen[dep] = true
+			pending[i]++
+			dependents[j] = append(dependents[j], i)
+		}
+	}
+
+	out := make([]Step, 0, len(steps))
+	done := make([]bool, len(steps))
+	for len(out) < len(steps) {
+		next := -1
+		for i := range steps {
+			if !done[i] && pending[i] == 0 {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			var stuck []string
+			for i := range steps {
+				if !done[i] {
+					stuck = append(stuck, steps[i].ID)
+				}
+			}
+			return nil, fmt.Errorf("steps have cyclic dependencies: %s", strings.Join(stuck, ", "))
+		}
+		done[next] = true
+		out = append(out, steps[next])
+		for _, d := range dependents[next] {
+			pending[d]--
+		}
+	}
+	return out, nil
+}
@@ -20,8 +20,11 @@ const (
 )
 
 type Step struct {
-	Title       string `json:"title"`
-	Description string `json:"description,omitempty"`
+	ID          string   `json:"id,omitempty"`
+	Title       string   `json:"title"`
+	Description string   `json:"description,omitempty"`
+	DependsOn   []string `json:"depends_on,omitempty"`
+	TodoID      string   `json:"todo_id,omitempty"`
 }
 
 type Plan struct {
@@ -360,10 +363,18 @@ func cloneSteps(in []Step) []Step {
 		if title == "" {
 			continue
 		}
-		out = append(out, Step{
+		step := Step{
+			ID:          strings.TrimSpace(s.ID),
 			Title:       title,
 			Description: strings.TrimSpace(s.Description),
-		})
+			TodoID:      strings.TrimSpace(s.TodoID),
+		}
+		for _, dep := range s.DependsOn {
+			if dep = strings.TrimSpace(dep); dep != "" {
+				step.DependsOn = append(step.DependsOn, dep)
+			}
+		}
+		out = append(out, step)
 	}
 	return out
 }
@@ -15,6 +15,7 @@ type Plugin struct {
 	Skills      []SkillConfig     `json:"skills,omitempty"`
 	Hooks       []HookConfig      `json:"hooks,omitempty"`
 	MCPServers  []MCPServerConfig `json:"mcp_servers,omitempty"`
+	Middleware  *MiddlewareConfig `json:"middleware,omitempty"`
 	Enabled     bool              `json:"enabled"`
 	InstalledAt time.Time         `json:"installed_at"`
 }
@@ -60,6 +61,14 @@ func (m *Manager) Install(p Plugin) error {
 	if name == "" {
 		return fmt.Errorf("plugin name is required")
 	}
+	if p.Middleware != nil {
+		mw := *p.Middleware
+		mw.Stages = append([]string(nil), mw.Stages...)
+		if err := mw.Normalize(); err != nil {
+			return fmt.Errorf("plugin %q: %w", name, err)
+		}
+		p.Middleware = &mw
+	}
 
 	m.mu.Lock()
 	defer m.mu.Unlock()
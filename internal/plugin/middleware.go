@@ -0,0 +1,424 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"ccgateway/internal/orchestrator"
+)
+
+const middlewareProtocolVersion = "ccgateway.plugin_middleware.v1"
+
+// Stages a middleware plugin can hook.
+const (
+	StageRequest  = "request"
+	StageResponse = "response"
+)
+
+// RuntimeScript runs a middleware entrypoint as a local command.
+const RuntimeScript = "script"
+
+// What happens when a middleware plugin fails.
+const (
+	FailureSkip  = "skip"
+	FailureAbort = "abort"
+)
+
+const (
+	defaultMiddlewareTimeout = 5 * time.Second
+	maxMiddlewareOutputBytes = 4 << 20
+)
+
+var (
+	// ErrRuntimeUnavailable is returned for entrypoints whose runtime is
+	// not registered in this build.
+	ErrRuntimeUnavailable = errors.New("plugin runtime is not available")
+	// ErrMiddlewareAborted wraps the failure of a plugin with
+	// failure_mode "abort".
+	ErrMiddlewareAborted = errors.New("plugin middleware aborted the request")
+)
+
+// MiddlewareConfig makes a plugin part of the request/response chain.
+// Entrypoint is the command the script runtime runs; script is the only
+// runtime, since WASM entrypoints need a sandbox with memory and time
+// limits that this build does not have. Plugins run in ascending Order,
+// then by name. Projects limits a global plugin to the listed projects;
+// project-scoped plugins only ever run for their own project.
+type MiddlewareConfig struct {
+	Runtime     string            `json:"runtime,omitempty"`
+	Entrypoint  string            `json:"entrypoint"`
+	Args        []string          `json:"args,omitempty"`
+	Env         map[string]string `json:"env,omitempty"`
+	Stages      []string          `json:"stages,omitempty"`
+	Order       int               `json:"order,omitempty"`
+	TimeoutMS   int               `json:"timeout_ms,omitempty"`
+	FailureMode string            `json:"failure_mode,omitempty"`
+	Projects    []string          `json:"projects,omitempty"`
+}
+
+// Normalize fills defaults and validates the config.
+func (c *MiddlewareConfig) Normalize() error {
+	c.Runtime = strings.ToLower(strings.TrimSpace(c.Runtime))
+	if c.Runtime == "" {
+		c.Runtime = RuntimeScript
+	}
+	c.Entrypoint = strings.TrimSpace(c.Entrypoint)
+	if c.Entrypoint == "" {
+		return fmt.Errorf("middleware entrypoint is required")
+	}
+	if c.Runtime != RuntimeScript {
+		return fmt.Errorf("middleware runtime must be script, got %q", c.Runtime)
+	}
+	for i, stage := range c.Stages {
+		stage = strings.ToLower(strings.TrimSpace(stage))
+		if stage != StageRequest && stage != StageResponse {
+			return fmt.Errorf("middleware stage must be request or response")
+		}
+		c.Stages[i] = stage
+	}
+	c.FailureMode = strings.ToLower(strings.TrimSpace(c.FailureMode))
+	switch c.FailureMode {
+	case "":
+		c.FailureMode = FailureSkip
+	case FailureSkip, FailureAbort:
+	default:
+		return fmt.Errorf("middleware failure_mode must be skip or abort")
+	}
+	if c.TimeoutMS < 0 {
+		return fmt.Errorf("middleware timeout_ms must not be negative")
+	}
+	return nil
+}
+
+// Handles reports whether the plugin runs at stage.
+func (c MiddlewareConfig) Handles(stage string) bool {
+	if len(c.Stages) == 0 {
+		return true
+	}
+	for _, s := range c.Stages {
+		if s == stage {
+			return true
+		}
+	}
+	return false
+}
+
+func (c MiddlewareConfig) timeout() time.Duration {
+	if c.TimeoutMS > 0 {
+		return time.Duration(c.TimeoutMS) * time.Millisecond
+	}
+	return defaultMiddlewareTimeout
+}
+
+// Ordered returns the enabled middleware plugins that handle stage, in
+// chain order.
+func Ordered(plugins []Plugin, stage string) []Plugin {
+	out := make([]Plugin, 0, len(plugins))
+	for _, p := range plugins {
+		if p.Enabled && p.Middleware != nil && p.Middleware.Handles(stage) {
+			out = append(out, p)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Middleware.Order != out[j].Middleware.Order {
+			return out[i].Middleware.Order < out[j].Middleware.Order
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
+// Runtime executes one middleware invocation: input and output are the
+// JSON envelopes of the middleware protocol.
+type Runtime interface {
+	Invoke(ctx context.Context, cfg MiddlewareConfig, input []byte) ([]byte, error)
+}
+
+// RuntimeFunc adapts a function to Runtime.
+type RuntimeFunc func(ctx context.Context, cfg MiddlewareConfig, input []byte) ([]byte, error)
+
+func (f RuntimeFunc) Invoke(ctx context.Context, cfg MiddlewareConfig, input []byte) ([]byte, error) {
+	return f(ctx, cfg, input)
+}
+
+// Outcome records one plugin invocation.
+type Outcome struct {
+	Plugin     string `json:"plugin"`
+	Stage      string `json:"stage"`
+	Changed    bool   `json:"changed"`
+	DurationMS int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+}
+
+// Executor runs middleware chains. Each plugin gets its own timeout, a
+// panic or error in one plugin is contained, and with failure_mode "skip"
+// the chain continues with the previous value.
+type Executor struct {
+	mu       sync.RWMutex
+	runtimes map[string]Runtime
+}
+
+// NewExecutor returns an executor with the script runtime registered.
+func NewExecutor() *Executor {
+	e := &Executor{runtimes: map[string]Runtime{}}
+	e.RegisterRuntime(RuntimeScript, ScriptRuntime{})
+	return e
+}
+
+// RegisterRuntime installs or replaces the runtime for name.
+func (e *Executor) RegisterRuntime(name string, rt Runtime) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.runtimes[strings.ToLower(strings.TrimSpace(name))] = rt
+}
+
+// RewriteRequest passes req through the request-stage plugins.
+func (e *Executor) RewriteRequest(ctx context.Context, plugins []Plugin, req orchestrator.Request) (orchestrator.Request, []Outcome, error) {
+	var outcomes []Outcome
+	for _, p := range Ordered(plugins, StageRequest) {
+		started := time.Now()
+		raw, err := e.invoke(ctx, p, middlewareEnvelope{
+			Version: middlewareProtocolVersion,
+			Stage:   StageRequest,
+			Plugin:  p.Name,
+			Request: toWireRequest(req),
+		})
+		outcome := Outcome{Plugin: p.Name, Stage: StageRequest}
+		if err == nil && raw.Request != nil {
+			if len(raw.Request.Messages) == 0 {
+				err = errors.New("plugin returned a request without messages")
+			} else {
+				req = raw.Request.apply(req)
+				outcome.Changed = true
+			}
+		}
+		outcome.DurationMS = time.Since(started).Milliseconds()
+		if err != nil {
+			outcome.Error = err.Error()
+		}
+		outcomes = append(outcomes, outcome)
+		if err != nil && p.Middleware.FailureMode == FailureAbort {
+			return req, outcomes, fmt.Errorf("%w: %s: %v", ErrMiddlewareAborted, p.Name, err)
+		}
+	}
+	return req, outcomes, nil
+}
+
+// RewriteResponse passes resp through the response-stage plugins. req is
+// sent along read-only for context.
+func (e *Executor) RewriteResponse(ctx context.Context, plugins []Plugin, req orchestrator.Request, resp orchestrator.Response) (orchestrator.Response, []Outcome, error) {
+	var outcomes []Outcome
+	for _, p := range Ordered(plugins, StageResponse) {
+		started := time.Now()
+		wireReq := toWireRequest(req)
+		wireResp := toWireResponse(resp)
+		raw, err := e.invoke(ctx, p, middlewareEnvelope{
+			Version:  middlewareProtocolVersion,
+			Stage:    StageResponse,
+			Plugin:   p.Name,
+			Request:  wireReq,
+			Response: wireResp,
+		})
+		outcome := Outcome{Plugin: p.Name, Stage: StageResponse}
+		if err == nil && raw.Response != nil {
+			resp = raw.Response.apply(resp)
+			outcome.Changed = true
+		}
+		outcome.DurationMS = time.Since(started).Milliseconds()
+		if err != nil {
+			outcome.Error = err.Error()
+		}
+		outcomes = append(outcomes, outcome)
+		if err != nil && p.Middleware.FailureMode == FailureAbort {
+			return resp, outcomes, fmt.Errorf("%w: %s: %v", ErrMiddlewareAborted, p.Name, err)
+		}
+	}
+	return resp, outcomes, nil
+}
+
+func (e *Executor) invoke(ctx context.Context, p Plugin, env middlewareEnvelope) (out middlewareResult, err error) {
+	cfg := *p.Middleware
+	e.mu.RLock()
+	rt, ok := e.runtimes[cfg.Runtime]
+	e.mu.RUnlock()
+	if !ok {
+		return out, fmt.Errorf("%w: %s", ErrRuntimeUnavailable, cfg.Runtime)
+	}
+	input, err := json.Marshal(env)
+	if err != nil {
+		return out, err
+	}
+	runCtx, cancel := context.WithTimeout(ctx, cfg.timeout())
+	defer cancel()
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("plugin panicked: %v", r)
+		}
+	}()
+	raw, err := rt.Invoke(runCtx, cfg, input)
+	if err != nil {
+		return out, err
+	}
+	if runCtx.Err() != nil {
+		return out, runCtx.Err()
+	}
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 {
+		return out, nil
+	}
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return out, fmt.Errorf("decode plugin output: %w", err)
+	}
+	if out.Error != "" {
+		return middlewareResult{}, errors.New(out.Error)
+	}
+	return out, nil
+}
+
+// ScriptRuntime runs the entrypoint as a command: the envelope is written
+// to stdin and the result read from stdout. Empty output leaves the value
+// unchanged.
+type ScriptRuntime struct{}
+
+func (ScriptRuntime) Invoke(ctx context.Context, cfg MiddlewareConfig, input []byte) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, cfg.Entrypoint, cfg.Args...)
+	cmd.Env = os.Environ()
+	for k, v := range cfg.Env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	cmd.Stdin = bytes.NewReader(input)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, err
+	}
+	if stdout.Len() > maxMiddlewareOutputBytes {
+		return nil, fmt.Errorf("plugin output exceeds %d bytes", maxMiddlewareOutputBytes)
+	}
+	return stdout.Bytes(), nil
+}
+
+// middlewareEnvelope is what a plugin receives. A plugin answers with
+// {"request":{...}} or {"response":{...}} holding the rewritten value,
+// {"error":"..."} to fail, or nothing to pass the value through.
+type middlewareEnvelope struct {
+	Version  string        `json:"version"`
+	Stage    string        `json:"stage"`
+	Plugin   string        `json:"plugin"`
+	Request  *wireRequest  `json:"request,omitempty"`
+	Response *wireResponse `json:"response,omitempty"`
+}
+
+type middlewareResult struct {
+	Request  *wireRequest  `json:"request,omitempty"`
+	Response *wireResponse `json:"response,omitempty"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// wireRequest is the part of the canonical request a plugin may see and
+// rewrite. Metadata is sent for context but not read back; credentials and
+// headers are never sent.
+type wireRequest struct {
+	Model     string         `json:"model"`
+	MaxTokens int            `json:"max_tokens"`
+	System    any            `json:"system,omitempty"`
+	Messages  []wireMessage  `json:"messages"`
+	Tools     []wireTool     `json:"tools,omitempty"`
+	Metadata  map[string]any `json:"metadata,omitempty"`
+}
+
+type wireMessage struct {
+	Role    string `json:"role"`
+	Content any    `json:"content"`
+}
+
+type wireTool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	InputSchema map[string]any `json:"input_schema,omitempty"`
+}
+
+type wireResponse struct {
+	Model      string      `json:"model"`
+	Blocks     []wireBlock `json:"blocks"`
+	StopReason string      `json:"stop_reason,omitempty"`
+}
+
+type wireBlock struct {
+	Type  string         `json:"type"`
+	Text  string         `json:"text,omitempty"`
+	ID    string         `json:"id,omitempty"`
+	Name  string         `json:"name,omitempty"`
+	Input map[string]any `json:"input,omitempty"`
+}
+
+func toWireRequest(req orchestrator.Request) *wireRequest {
+	out := &wireRequest{
+		Model:     req.Model,
+		MaxTokens: req.MaxTokens,
+		System:    req.System,
+		Messages:  make([]wireMessage, 0, len(req.Messages)),
+		Metadata:  req.Metadata,
+	}
+	for _, m := range req.Messages {
+		out.Messages = append(out.Messages, wireMessage{Role: m.Role, Content: m.Content})
+	}
+	for _, t := range req.Tools {
+		out.Tools = append(out.Tools, wireTool{Name: t.Name, Description: t.Description, InputSchema: t.InputSchema})
+	}
+	return out
+}
+
+func (w *wireRequest) apply(req orchestrator.Request) orchestrator.Request {
+	if w.Model != "" {
+		req.Model = w.Model
+	}
+	if w.MaxTokens > 0 {
+		req.MaxTokens = w.MaxTokens
+	}
+	req.System = w.System
+	req.Messages = make([]orchestrator.Message, 0, len(w.Messages))
+	for _, m := range w.Messages {
+		req.Messages = append(req.Messages, orchestrator.Message{Role: m.Role, Content: m.Content})
+	}
+	req.Tools = make([]orchestrator.Tool, 0, len(w.Tools))
+	for _, t := range w.Tools {
+		req.Tools = append(req.Tools, orchestrator.Tool{Name: t.Name, Description: t.Description, InputSchema: t.InputSchema})
+	}
+	return req
+}
+
+func toWireResponse(resp orchestrator.Response) *wireResponse {
+	out := &wireResponse{Model: resp.Model, StopReason: resp.StopReason, Blocks: make([]wireBlock, 0, len(resp.Blocks))}
+	for _, b := range resp.Blocks {
+		out.Blocks = append(out.Blocks, wireBlock{Type: b.Type, Text: b.Text, ID: b.ID, Name: b.Name, Input: b.Input})
+	}
+	return out
+}
+
+func (w *wireResponse) apply(resp orchestrator.Response) orchestrator.Response {
+	if w.Model != "" {
+		resp.Model = w.Model
+	}
+	if w.StopReason != "" {
+		resp.StopReason = w.StopReason
+	}
+	resp.Blocks = make([]orchestrator.AssistantBlock, 0, len(w.Blocks))
+	for _, b := range w.Blocks {
+		resp.Blocks = append(resp.Blocks, orchestrator.AssistantBlock{Type: b.Type, Text: b.Text, ID: b.ID, Name: b.Name, Input: b.Input})
+	}
+	return resp
+}
@@ -3,6 +3,7 @@ package policy
 import (
 	"context"
 	"errors"
+	"fmt"
 	"strings"
 
 	"ccgateway/internal/requestctx"
@@ -18,6 +19,11 @@ type Action struct {
 	Model     string
 	Mode      string
 	ToolNames []string
+	// Adapters are the upstreams the request pins or excludes.
+	Adapters []string
+	// Project is the request's project; the engine fills it from the
+	// context when empty.
+	Project string
 }
 
 type NoopEngine struct{}
@@ -55,7 +61,47 @@ func NewDynamicEngine(settingsStore *settings.Store, catalog ToolChecker) *Dynam
 	}
 }
 
+// Authorize checks action's tools against the catalog, then against the
+// policy rules in the runtime settings, matched with the caller token's
+// attributes (WithTokenAttributes) and the request's project.
 func (e *DynamicEngine) Authorize(ctx context.Context, action Action) error {
+	if err := e.checkTools(ctx, action); err != nil {
+		return err
+	}
+	if d := e.evaluateRules(ctx, action); !d.Allowed {
+		return fmt.Errorf("request denied by policy: %s", d.Reason)
+	}
+	return nil
+}
+
+// Decide is Authorize reporting which rule, if any, decided the action.
+func (e *DynamicEngine) Decide(ctx context.Context, action Action) Decision {
+	if err := e.checkTools(ctx, action); err != nil {
+		return Decision{Effect: EffectDeny, Reason: err.Error()}
+	}
+	return e.evaluateRules(ctx, action)
+}
+
+// DefaultEffect is the effect the live rules apply when none matches.
+func (e *DynamicEngine) DefaultEffect() string {
+	if e.settings == nil {
+		return EffectAllow
+	}
+	return e.settings.Get().Policy.DefaultEffect
+}
+
+func (e *DynamicEngine) evaluateRules(ctx context.Context, action Action) Decision {
+	if e.settings == nil {
+		return Decision{Allowed: true, Effect: EffectAllow, Reason: "no rules configured"}
+	}
+	if action.Project == "" {
+		action.Project = requestctx.ProjectID(ctx)
+	}
+	cfg := e.settings.Get().Policy
+	return EvaluateRules(cfg.Rules, action, TokenAttributesFrom(ctx), cfg.DefaultEffect)
+}
+
+func (e *DynamicEngine) checkTools(ctx context.Context, action Action) error {
 	for _, t := range action.ToolNames {
 		if strings.EqualFold(strings.TrimSpace(t), "forbidden_tool") {
 			return errors.New("tool forbidden by policy")
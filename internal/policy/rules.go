@@ -0,0 +1,182 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"ccgateway/internal/settings"
+)
+
+const (
+	EffectAllow = "allow"
+	EffectDeny  = "deny"
+)
+
+// TokenAttributes describes the caller token used when evaluating declarative rules.
+type TokenAttributes struct {
+	ID     int64    `json:"id,omitempty"`
+	UserID string   `json:"user_id,omitempty"`
+	Name   string   `json:"name,omitempty"`
+	Group  string   `json:"group,omitempty"`
+	Role   string   `json:"role,omitempty"`
+	Models []string `json:"models,omitempty"`
+}
+
+type tokenAttributesKey struct{}
+
+// WithTokenAttributes returns ctx carrying the caller token's attributes
+// for rule evaluation.
+func WithTokenAttributes(ctx context.Context, tk TokenAttributes) context.Context {
+	return context.WithValue(ctx, tokenAttributesKey{}, tk)
+}
+
+// TokenAttributesFrom returns the attributes WithTokenAttributes stored.
+func TokenAttributesFrom(ctx context.Context) TokenAttributes {
+	tk, _ := ctx.Value(tokenAttributesKey{}).(TokenAttributes)
+	return tk
+}
+
+// Rule is a declarative allow/deny rule; the live rules are kept in the
+// runtime settings' policy section.
+type Rule = settings.PolicyRule
+
+// Decision is the outcome of evaluating an action against a rule set.
+type Decision struct {
+	Allowed     bool   `json:"allowed"`
+	Effect      string `json:"effect"`
+	MatchedRule *Rule  `json:"matched_rule,omitempty"`
+	MatchedTool string `json:"matched_tool,omitempty"`
+	Reason      string `json:"reason,omitempty"`
+}
+
+// ValidateRules checks rule ids and effects before evaluation.
+func ValidateRules(rules []Rule) error {
+	seen := map[string]struct{}{}
+	for i, r := range rules {
+		id := strings.TrimSpace(r.ID)
+		if id == "" {
+			return fmt.Errorf("rules[%d]: id is required", i)
+		}
+		if _, ok := seen[id]; ok {
+			return fmt.Errorf("rules[%d]: duplicate id %q", i, id)
+		}
+		seen[id] = struct{}{}
+		switch normalizeEffect(r.Effect) {
+		case EffectAllow, EffectDeny:
+		default:
+			return fmt.Errorf("rules[%d]: effect must be allow or deny", i)
+		}
+		for _, list := range [][]string{r.Paths, r.Models, r.Modes, r.Tools, r.Adapters, r.Projects, r.UserIDs, r.Groups, r.Roles, r.TokenNames} {
+			for _, pattern := range list {
+				if _, err := path.Match(strings.ToLower(strings.TrimSpace(pattern)), ""); err != nil {
+					return fmt.Errorf("rules[%d]: invalid pattern %q", i, pattern)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// EvaluateRules returns the decision for action under rules. Rules are
+// checked by descending priority, then declaration order; the first match
+// wins. When nothing matches, defaultEffect applies (allow when empty).
+func EvaluateRules(rules []Rule, action Action, tk TokenAttributes, defaultEffect string) Decision {
+	ordered := orderRules(rules)
+	for i := range ordered {
+		r := ordered[i]
+		tool, ok := ruleMatches(r, action, tk)
+		if !ok {
+			continue
+		}
+		effect := normalizeEffect(r.Effect)
+		matched := r
+		return Decision{
+			Allowed:     effect == EffectAllow,
+			Effect:      effect,
+			MatchedRule: &matched,
+			MatchedTool: tool,
+			Reason:      "matched rule " + strings.TrimSpace(r.ID),
+		}
+	}
+	effect := normalizeEffect(defaultEffect)
+	if effect != EffectDeny {
+		effect = EffectAllow
+	}
+	return Decision{
+		Allowed: effect == EffectAllow,
+		Effect:  effect,
+		Reason:  "no rule matched; default " + effect,
+	}
+}
+
+func orderRules(rules []Rule) []Rule {
+	out := append([]Rule(nil), rules...)
+	// Stable insertion sort keeps declaration order among equal priorities.
+	for i := 1; i < len(out); i++ {
+		for j := i; j > 0 && out[j].Priority > out[j-1].Priority; j-- {
+			out[j], out[j-1] = out[j-1], out[j]
+		}
+	}
+	return out
+}
+
+func ruleMatches(r Rule, action Action, tk TokenAttributes) (string, bool) {
+	if !matchAny(r.Paths, action.Path) ||
+		!matchAny(r.Models, action.Model) ||
+		!matchAny(r.Modes, action.Mode) ||
+		!matchAny(r.Projects, action.Project) ||
+		!matchAny(r.UserIDs, tk.UserID) ||
+		!matchAny(r.Groups, tk.Group) ||
+		!matchAny(r.Roles, tk.Role) ||
+		!matchAny(r.TokenNames, tk.Name) {
+		return "", false
+	}
+	if len(r.Adapters) > 0 && !matchAnyOf(r.Adapters, action.Adapters) {
+		return "", false
+	}
+	if len(r.Tools) == 0 {
+		return "", true
+	}
+	for _, name := range action.ToolNames {
+		if matchAny(r.Tools, name) {
+			return strings.TrimSpace(name), true
+		}
+	}
+	return "", false
+}
+
+func matchAny(patterns []string, value string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	value = strings.ToLower(strings.TrimSpace(value))
+	for _, p := range patterns {
+		p = strings.ToLower(strings.TrimSpace(p))
+		if p == "" {
+			continue
+		}
+		if p == "*" || p == value {
+			return true
+		}
+		if ok, err := path.Match(p, value); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
+
+// matchAnyOf reports whether any of values matches patterns.
+func matchAnyOf(patterns, values []string) bool {
+	for _, v := range values {
+		if matchAny(patterns, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeEffect(effect string) string {
+	return strings.ToLower(strings.TrimSpace(effect))
+}
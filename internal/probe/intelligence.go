@@ -3,10 +3,12 @@ package probe
 import (
 	"context"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
 	"ccgateway/internal/orchestrator"
+	"ccgateway/internal/scheduler"
 	"ccgateway/internal/upstream"
 )
 
@@ -24,8 +26,9 @@ type IntelligenceResult struct {
 // QAScore holds the result of a single intelligence question.
 type QAScore struct {
 	Category string  `json:"category"`
+	Task     string  `json:"task,omitempty"`
 	Question string  `json:"question"`
-	Score    float64 `json:"score"` // 0-20
+	Score    float64 `json:"score"` // the question's share of the 0-100 total
 	Answer   string  `json:"answer,omitempty"`
 }
 
@@ -154,45 +157,65 @@ var defaultIntelligenceQuestions = []intelligenceQuestion{
 
 // ProbeIntelligence tests the intelligence of an adapter by sending benchmark questions.
 func ProbeIntelligence(ctx context.Context, adapter upstream.Adapter, model string, timeout time.Duration) IntelligenceResult {
+	return ProbeIntelligenceTasks(ctx, adapter, model, timeout, builtinProbeTasks())
+}
+
+// ProbeIntelligenceTasks runs a probe suite against adapter/model. Each
+// task contributes its graded answer times its share of the total weight,
+// so the score stays on 0-100 whatever the suite.
+func ProbeIntelligenceTasks(ctx context.Context, adapter upstream.Adapter, model string, timeout time.Duration, tasks []ProbeTask) IntelligenceResult {
 	started := time.Now()
 	result := IntelligenceResult{
 		AdapterName: adapter.Name(),
 		Model:       model,
 		TestedAt:    started,
-		Details:     make([]QAScore, 0, len(defaultIntelligenceQuestions)),
+		Details:     make([]QAScore, 0, len(tasks)),
+	}
+	var totalWeight float64
+	for _, t := range tasks {
+		totalWeight += t.Weight
 	}
 
 	totalScore := 0.0
-	for _, q := range defaultIntelligenceQuestions {
+	for _, t := range tasks {
+		system := t.System
+		if system == "" {
+			system = "Answer concisely and precisely. Follow instructions exactly."
+		}
+		maxTokens := t.MaxTokens
+		if maxTokens <= 0 {
+			maxTokens = defaultProbeTaskMaxTokens
+		}
 		qCtx, cancel := context.WithTimeout(ctx, timeout)
 		resp, err := adapter.Complete(qCtx, orchestrator.Request{
 			Model:     model,
-			MaxTokens: 256,
-			System:    "Answer concisely and precisely. Follow instructions exactly.",
+			MaxTokens: maxTokens,
+			System:    system,
 			Messages: []orchestrator.Message{
-				{Role: "user", Content: q.Question},
+				{Role: "user", Content: t.Prompt},
 			},
 		})
 		cancel()
 
 		qs := QAScore{
-			Category: q.Category,
-			Question: q.Question,
+			Category: t.Category,
+			Task:     t.Name,
+			Question: t.Prompt,
 		}
 
 		if err != nil {
 			qs.Score = 0
 			qs.Answer = fmt.Sprintf("error: %s", err.Error())
-		} else {
+		} else if totalWeight > 0 {
 			answerText := extractResponseText(resp)
 			qs.Answer = truncate(answerText, 500)
-			qs.Score = q.Checker(answerText)
+			qs.Score = roundScore(t.Grade(answerText) * t.Weight / totalWeight * 100)
 		}
 		totalScore += qs.Score
 		result.Details = append(result.Details, qs)
 	}
 
-	result.Score = totalScore
+	result.Score = roundScore(totalScore)
 	result.LatencyMS = time.Since(started).Milliseconds()
 	return result
 }
@@ -219,3 +242,221 @@ func truncate(s string, n int) string {
 	}
 	return s[:n]
 }
+
+// IntelligenceConfig schedules the intelligence evaluation on the runner.
+type IntelligenceConfig struct {
+	Enabled bool
+	// Interval between evaluations (default 10m).
+	Interval time.Duration
+	// Timeout per benchmark question (default 15s).
+	Timeout time.Duration
+	// InitialDelay lets the first health probes settle before the first
+	// evaluation (default 5s; negative starts at once).
+	InitialDelay time.Duration
+}
+
+type intelligenceState struct {
+	cfg          IntelligenceConfig
+	history      *IntelligenceHistory
+	onScores     func([]scheduler.IntelligenceScore)
+	runs         int64
+	running      bool
+	lastRunAt    time.Time
+	lastDuration time.Duration
+	nextRunAt    time.Time
+	// tasks is the uploaded probe suite; empty means the built-in one.
+	tasks     []ProbeTask
+	tasksPath string
+}
+
+// IntelligenceReport is served by /admin/intelligence.
+type IntelligenceReport struct {
+	Enabled           bool                          `json:"enabled"`
+	IntervalMS        int64                         `json:"interval_ms"`
+	TimeoutMS         int64                         `json:"timeout_ms"`
+	Runs              int64                         `json:"runs"`
+	Running           bool                          `json:"running"`
+	LastRunAt         *time.Time                    `json:"last_run_at,omitempty"`
+	LastRunDurationMS int64                         `json:"last_run_duration_ms"`
+	NextRunAt         *time.Time                    `json:"next_run_at,omitempty"`
+	ElectionScores    []scheduler.IntelligenceScore `json:"election_scores"`
+	Trends            []IntelligenceTrend           `json:"trends"`
+}
+
+// EnableIntelligence turns on periodic intelligence evaluation. Scores are
+// recorded in history and onScores receives, per adapter, the smoothed
+// score of its best model. Scores already in history are published
+// straight away so the election does not wait for the first run.
+func (r *Runner) EnableIntelligence(cfg IntelligenceConfig, history *IntelligenceHistory, onScores func([]scheduler.IntelligenceScore)) {
+	if r == nil {
+		return
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = 10 * time.Minute
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 15 * time.Second
+	}
+	if cfg.InitialDelay < 0 {
+		cfg.InitialDelay = 0
+	} else if cfg.InitialDelay == 0 {
+		cfg.InitialDelay = 5 * time.Second
+	}
+	if history == nil {
+		history, _ = NewIntelligenceHistory("")
+	}
+	cfg.Enabled = true
+	r.mu.Lock()
+	r.intel.cfg = cfg
+	r.intel.history = history
+	r.intel.onScores = onScores
+	r.mu.Unlock()
+	r.publishScores()
+}
+
+// IntelligenceConfig returns the intelligence schedule.
+func (r *Runner) IntelligenceConfig() IntelligenceConfig {
+	if r == nil {
+		return IntelligenceConfig{}
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.intel.cfg
+}
+
+func (r *Runner) intelligenceLoop(ctx context.Context) {
+	cfg := r.IntelligenceConfig()
+	r.setNextIntelligenceRun(time.Now().Add(cfg.InitialDelay))
+	timer := time.NewTimer(cfg.InitialDelay)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+			r.EvaluateIntelligence(ctx)
+			interval := r.IntelligenceConfig().Interval
+			r.setNextIntelligenceRun(time.Now().Add(interval))
+			timer.Reset(interval)
+		}
+	}
+}
+
+func (r *Runner) setNextIntelligenceRun(at time.Time) {
+	r.mu.Lock()
+	r.intel.nextRunAt = at
+	r.mu.Unlock()
+}
+
+// EvaluateIntelligence benchmarks every adapter/model now, records the
+// scores and republishes election scores.
+func (r *Runner) EvaluateIntelligence(ctx context.Context) []IntelligenceResult {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	if r.intel.running || !r.intel.cfg.Enabled {
+		r.mu.Unlock()
+		return nil
+	}
+	r.intel.running = true
+	timeout := r.intel.cfg.Timeout
+	history := r.intel.history
+	r.mu.Unlock()
+	tasks := r.IntelligenceTasks().Tasks
+
+	started := time.Now()
+	cfg := r.Config()
+	var results []IntelligenceResult
+	for _, adapter := range r.adapters {
+		if adapter == nil || strings.TrimSpace(adapter.Name()) == "" {
+			continue
+		}
+		models := r.modelsForAdapter(cfg, adapter.Name(), adapter)
+		if len(models) == 0 {
+			models = []string{"default"}
+		}
+		for _, model := range models {
+			if ctx.Err() != nil {
+				break
+			}
+			res := ProbeIntelligenceTasks(ctx, adapter, model, timeout, tasks)
+			history.Record(res)
+			results = append(results, res)
+		}
+	}
+
+	r.mu.Lock()
+	r.intel.running = false
+	r.intel.runs++
+	r.intel.lastRunAt = started
+	r.intel.lastDuration = time.Since(started)
+	r.mu.Unlock()
+	r.publishScores()
+	return results
+}
+
+// electionScores picks, per adapter, the model with the best smoothed
+// score.
+func electionScores(trends []IntelligenceTrend) []scheduler.IntelligenceScore {
+	best := map[string]IntelligenceTrend{}
+	for _, t := range trends {
+		if cur, ok := best[t.Adapter]; !ok || t.ElectionScore > cur.ElectionScore {
+			best[t.Adapter] = t
+		}
+	}
+	out := make([]scheduler.IntelligenceScore, 0, len(best))
+	for _, t := range best {
+		out = append(out, scheduler.IntelligenceScore{
+			AdapterName: t.Adapter,
+			Model:       t.Model,
+			Score:       t.ElectionScore,
+			TestedAt:    t.LastTestedAt,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].AdapterName < out[j].AdapterName })
+	return out
+}
+
+func (r *Runner) publishScores() {
+	r.mu.RLock()
+	history := r.intel.history
+	fn := r.intel.onScores
+	r.mu.RUnlock()
+	scores := electionScores(history.Trends("", "", 1))
+	if fn != nil && len(scores) > 0 {
+		fn(scores)
+	}
+}
+
+// IntelligenceReport returns the schedule and per adapter/model trends,
+// optionally filtered, with at most limit history points per trend.
+func (r *Runner) IntelligenceReport(adapter, model string, limit int) IntelligenceReport {
+	out := IntelligenceReport{ElectionScores: []scheduler.IntelligenceScore{}, Trends: []IntelligenceTrend{}}
+	if r == nil {
+		return out
+	}
+	r.mu.RLock()
+	state := r.intel
+	r.mu.RUnlock()
+	out.Enabled = state.cfg.Enabled
+	out.IntervalMS = state.cfg.Interval.Milliseconds()
+	out.TimeoutMS = state.cfg.Timeout.Milliseconds()
+	out.Runs = state.runs
+	out.Running = state.running
+	out.LastRunDurationMS = state.lastDuration.Milliseconds()
+	if !state.lastRunAt.IsZero() {
+		at := state.lastRunAt.UTC()
+		out.LastRunAt = &at
+	}
+	if !state.nextRunAt.IsZero() {
+		at := state.nextRunAt.UTC()
+		out.NextRunAt = &at
+	}
+	if state.history == nil {
+		return out
+	}
+	out.ElectionScores = electionScores(state.history.Trends("", "", 1))
+	out.Trends = state.history.Trends(adapter, model, limit)
+	return out
+}
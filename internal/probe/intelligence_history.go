@@ -0,0 +1,255 @@
+package probe
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxIntelligencePoints bounds the in-memory history per adapter/model.
+	maxIntelligencePoints = 200
+	// electionWindow is how many recent evaluations are averaged into the
+	// score handed to the election, so one noisy run cannot flip it.
+	electionWindow = 3
+)
+
+// IntelligencePoint is one recorded evaluation of an adapter/model pair.
+type IntelligencePoint struct {
+	Adapter    string             `json:"adapter"`
+	Model      string             `json:"model"`
+	Score      float64            `json:"score"`
+	LatencyMS  int64              `json:"latency_ms"`
+	TestedAt   time.Time          `json:"tested_at"`
+	Categories map[string]float64 `json:"categories,omitempty"`
+}
+
+func pointFromResult(res IntelligenceResult) IntelligencePoint {
+	p := IntelligencePoint{
+		Adapter:   res.AdapterName,
+		Model:     res.Model,
+		Score:     res.Score,
+		LatencyMS: res.LatencyMS,
+		TestedAt:  res.TestedAt.UTC(),
+	}
+	if len(res.Details) > 0 {
+		p.Categories = make(map[string]float64, len(res.Details))
+		for _, d := range res.Details {
+			p.Categories[d.Category] += d.Score
+		}
+	}
+	return p
+}
+
+// IntelligenceTrend summarizes the score history of one adapter/model.
+// ElectionScore is the mean of the last few evaluations and is what the
+// election ranks on.
+type IntelligenceTrend struct {
+	Adapter       string              `json:"adapter"`
+	Model         string              `json:"model"`
+	Samples       int                 `json:"samples"`
+	Latest        float64             `json:"latest"`
+	Previous      *float64            `json:"previous,omitempty"`
+	Delta         float64             `json:"delta"`
+	Direction     string              `json:"direction"`
+	Average       float64             `json:"average"`
+	Min           float64             `json:"min"`
+	Max           float64             `json:"max"`
+	ElectionScore float64             `json:"election_score"`
+	LastTestedAt  time.Time           `json:"last_tested_at"`
+	History       []IntelligencePoint `json:"history"`
+}
+
+// IntelligenceHistory keeps intelligence scores in a JSON-lines file so the
+// trend (and the election) survives restarts.
+type IntelligenceHistory struct {
+	mu     sync.RWMutex
+	path   string
+	file   *os.File
+	series map[[2]string][]IntelligencePoint
+}
+
+// NewIntelligenceHistory opens (or creates) the history at path. An empty
+// path keeps it in memory only.
+func NewIntelligenceHistory(path string) (*IntelligenceHistory, error) {
+	h := &IntelligenceHistory{path: strings.TrimSpace(path), series: map[[2]string][]IntelligencePoint{}}
+	if h.path == "" {
+		return h, nil
+	}
+	h.path = filepath.Clean(h.path)
+	if err := os.MkdirAll(filepath.Dir(h.path), 0o755); err != nil {
+		return nil, fmt.Errorf("create intelligence history dir: %w", err)
+	}
+	if err := h.load(); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open intelligence history: %w", err)
+	}
+	h.file = f
+	return h, nil
+}
+
+// IntelligenceHistoryFromEnv reads INTEL_HISTORY_PATH (default
+// logs/intelligence-history.jsonl).
+func IntelligenceHistoryFromEnv() (*IntelligenceHistory, error) {
+	path := strings.TrimSpace(os.Getenv("INTEL_HISTORY_PATH"))
+	if path == "" {
+		path = "logs/intelligence-history.jsonl"
+	}
+	return NewIntelligenceHistory(path)
+}
+
+func (h *IntelligenceHistory) load() error {
+	f, err := os.Open(h.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("open intelligence history: %w", err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for scanner.Scan() {
+		var p IntelligencePoint
+		if err := json.Unmarshal(scanner.Bytes(), &p); err != nil || p.Adapter == "" {
+			continue
+		}
+		h.appendLocked(p)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("read intelligence history: %w", err)
+	}
+	return nil
+}
+
+func (h *IntelligenceHistory) appendLocked(p IntelligencePoint) {
+	key := [2]string{p.Adapter, p.Model}
+	points := append(h.series[key], p)
+	if over := len(points) - maxIntelligencePoints; over > 0 {
+		points = append([]IntelligencePoint(nil), points[over:]...)
+	}
+	h.series[key] = points
+}
+
+// Record appends an evaluation result.
+func (h *IntelligenceHistory) Record(res IntelligenceResult) {
+	if h == nil {
+		return
+	}
+	p := pointFromResult(res)
+	if p.TestedAt.IsZero() {
+		p.TestedAt = time.Now().UTC()
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.appendLocked(p)
+	if h.file == nil {
+		return
+	}
+	raw, err := json.Marshal(p)
+	if err != nil {
+		return
+	}
+	_, _ = h.file.Write(append(raw, '\n'))
+}
+
+// Trends returns one trend per adapter/model, optionally filtered, with
+// at most limit history points each (newest last). limit <= 0 keeps all.
+func (h *IntelligenceHistory) Trends(adapter, model string, limit int) []IntelligenceTrend {
+	out := []IntelligenceTrend{}
+	if h == nil {
+		return out
+	}
+	adapter = strings.TrimSpace(adapter)
+	model = strings.TrimSpace(model)
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for key, points := range h.series {
+		if len(points) == 0 || (adapter != "" && key[0] != adapter) || (model != "" && key[1] != model) {
+			continue
+		}
+		out = append(out, buildTrend(key[0], key[1], points, limit))
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].ElectionScore != out[j].ElectionScore {
+			return out[i].ElectionScore > out[j].ElectionScore
+		}
+		return out[i].Adapter+"/"+out[i].Model < out[j].Adapter+"/"+out[j].Model
+	})
+	return out
+}
+
+func buildTrend(adapter, model string, points []IntelligencePoint, limit int) IntelligenceTrend {
+	last := points[len(points)-1]
+	t := IntelligenceTrend{
+		Adapter:      adapter,
+		Model:        model,
+		Samples:      len(points),
+		Latest:       last.Score,
+		Direction:    "flat",
+		Min:          last.Score,
+		Max:          last.Score,
+		LastTestedAt: last.TestedAt,
+	}
+	var sum float64
+	for _, p := range points {
+		sum += p.Score
+		t.Min = math.Min(t.Min, p.Score)
+		t.Max = math.Max(t.Max, p.Score)
+	}
+	t.Average = roundScore(sum / float64(len(points)))
+	if len(points) > 1 {
+		prev := points[len(points)-2].Score
+		t.Previous = &prev
+		t.Delta = roundScore(last.Score - prev)
+		switch {
+		case t.Delta >= 1:
+			t.Direction = "up"
+		case t.Delta <= -1:
+			t.Direction = "down"
+		}
+	}
+	window := points
+	if len(window) > electionWindow {
+		window = window[len(window)-electionWindow:]
+	}
+	var recent float64
+	for _, p := range window {
+		recent += p.Score
+	}
+	t.ElectionScore = roundScore(recent / float64(len(window)))
+	if limit > 0 && len(points) > limit {
+		points = points[len(points)-limit:]
+	}
+	t.History = append([]IntelligencePoint(nil), points...)
+	return t
+}
+
+func roundScore(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// Close releases the history file.
+func (h *IntelligenceHistory) Close() error {
+	if h == nil {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.file == nil {
+		return nil
+	}
+	err := h.file.Close()
+	h.file = nil
+	return err
+}
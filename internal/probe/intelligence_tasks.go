@@ -0,0 +1,314 @@
+package probe
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Probe task validators.
+const (
+	ValidatorExact       = "exact"
+	ValidatorContains    = "contains"
+	ValidatorContainsAll = "contains_all"
+	ValidatorContainsAny = "contains_any"
+	ValidatorRegex       = "regex"
+	ValidatorNumber      = "number"
+	// ValidatorBuiltin marks the hard-coded default questions, which are
+	// graded in code and cannot be uploaded.
+	ValidatorBuiltin = "builtin"
+)
+
+const (
+	defaultProbeTaskMaxTokens = 256
+	maxProbeTaskMaxTokens     = 4096
+	maxProbeTasks             = 200
+)
+
+// ProbeTask is one question of an intelligence probe suite. The answer is
+// graded by Validator against Expected (or Keywords for contains_all and
+// contains_any); contains_all gives partial credit per keyword. Weight is
+// the task's share of the 0-100 score.
+type ProbeTask struct {
+	Name      string   `json:"name,omitempty"`
+	Category  string   `json:"category"`
+	Prompt    string   `json:"prompt"`
+	System    string   `json:"system,omitempty"`
+	Expected  string   `json:"expected,omitempty"`
+	Keywords  []string `json:"keywords,omitempty"`
+	Validator string   `json:"validator"`
+	// Tolerance is the allowed absolute error for the number validator.
+	Tolerance float64 `json:"tolerance,omitempty"`
+	Weight    float64 `json:"weight"`
+	MaxTokens int     `json:"max_tokens,omitempty"`
+
+	check func(answer string) float64
+	re    *regexp.Regexp
+}
+
+// ProbeTaskSet is the suite the runner evaluates with. Custom is false
+// while the built-in questions are in use.
+type ProbeTaskSet struct {
+	Custom bool        `json:"custom"`
+	Tasks  []ProbeTask `json:"tasks"`
+}
+
+func builtinProbeTasks() []ProbeTask {
+	out := make([]ProbeTask, 0, len(defaultIntelligenceQuestions))
+	for _, q := range defaultIntelligenceQuestions {
+		checker := q.Checker
+		out = append(out, ProbeTask{
+			Name:      q.Category,
+			Category:  q.Category,
+			Prompt:    q.Question,
+			Validator: ValidatorBuiltin,
+			Weight:    1,
+			check:     func(answer string) float64 { return checker(answer) / 20 },
+		})
+	}
+	return out
+}
+
+// ValidateProbeTasks checks and normalizes an uploaded suite: a prompt and
+// a grading rule are required, the category defaults to "custom", the
+// weight to 1 and max_tokens to 256.
+func ValidateProbeTasks(in []ProbeTask) ([]ProbeTask, error) {
+	if len(in) > maxProbeTasks {
+		return nil, fmt.Errorf("at most %d probe tasks are allowed", maxProbeTasks)
+	}
+	out := make([]ProbeTask, 0, len(in))
+	for i, t := range in {
+		t.Name = strings.TrimSpace(t.Name)
+		if t.Name == "" {
+			t.Name = fmt.Sprintf("task_%d", i+1)
+		}
+		t.Category = strings.ToLower(strings.TrimSpace(t.Category))
+		if t.Category == "" {
+			t.Category = "custom"
+		}
+		t.Prompt = strings.TrimSpace(t.Prompt)
+		if t.Prompt == "" {
+			return nil, fmt.Errorf("probe task %q: prompt is required", t.Name)
+		}
+		t.System = strings.TrimSpace(t.System)
+		t.Expected = strings.TrimSpace(t.Expected)
+		t.Keywords = cleanKeywords(t.Keywords)
+		t.Validator = strings.ToLower(strings.TrimSpace(t.Validator))
+		if t.Validator == "" {
+			t.Validator = ValidatorContains
+			if len(t.Keywords) > 0 {
+				t.Validator = ValidatorContainsAll
+			}
+		}
+		switch t.Validator {
+		case ValidatorExact, ValidatorContains:
+			if t.Expected == "" {
+				return nil, fmt.Errorf("probe task %q: expected is required for %s", t.Name, t.Validator)
+			}
+		case ValidatorContainsAll, ValidatorContainsAny:
+			if len(t.Keywords) == 0 {
+				return nil, fmt.Errorf("probe task %q: keywords are required for %s", t.Name, t.Validator)
+			}
+		case ValidatorRegex:
+			re, err := regexp.Compile(t.Expected)
+			if err != nil || t.Expected == "" {
+				return nil, fmt.Errorf("probe task %q: expected must be a valid regex", t.Name)
+			}
+			t.re = re
+		case ValidatorNumber:
+			if _, err := strconv.ParseFloat(t.Expected, 64); err != nil {
+				return nil, fmt.Errorf("probe task %q: expected must be a number", t.Name)
+			}
+			if t.Tolerance < 0 {
+				return nil, fmt.Errorf("probe task %q: tolerance must be >= 0", t.Name)
+			}
+		default:
+			return nil, fmt.Errorf("probe task %q: unknown validator %q", t.Name, t.Validator)
+		}
+		if t.Weight < 0 {
+			return nil, fmt.Errorf("probe task %q: weight must be >= 0", t.Name)
+		}
+		if t.Weight == 0 {
+			t.Weight = 1
+		}
+		if t.MaxTokens <= 0 {
+			t.MaxTokens = defaultProbeTaskMaxTokens
+		}
+		if t.MaxTokens > maxProbeTaskMaxTokens {
+			t.MaxTokens = maxProbeTaskMaxTokens
+		}
+		out = append(out, t)
+	}
+	return out, nil
+}
+
+func cleanKeywords(in []string) []string {
+	var out []string
+	for _, k := range in {
+		if k = strings.TrimSpace(k); k != "" {
+			out = append(out, k)
+		}
+	}
+	return out
+}
+
+var numberPattern = regexp.MustCompile(`-?\d[\d,]*(?:\.\d+)?`)
+
+// Grade scores answer from 0 to 1.
+func (t ProbeTask) Grade(answer string) float64 {
+	if t.check != nil {
+		return clamp01(t.check(answer))
+	}
+	a := strings.TrimSpace(answer)
+	lower := strings.ToLower(a)
+	switch t.Validator {
+	case ValidatorExact:
+		if strings.EqualFold(strings.Trim(a, " \t\n.\"'`"), t.Expected) {
+			return 1
+		}
+	case ValidatorContains:
+		if strings.Contains(lower, strings.ToLower(t.Expected)) {
+			return 1
+		}
+	case ValidatorContainsAll:
+		hits := 0
+		for _, k := range t.Keywords {
+			if strings.Contains(lower, strings.ToLower(k)) {
+				hits++
+			}
+		}
+		return float64(hits) / float64(len(t.Keywords))
+	case ValidatorContainsAny:
+		for _, k := range t.Keywords {
+			if strings.Contains(lower, strings.ToLower(k)) {
+				return 1
+			}
+		}
+	case ValidatorRegex:
+		re := t.re
+		if re == nil {
+			re, _ = regexp.Compile(t.Expected)
+		}
+		if re != nil && re.MatchString(a) {
+			return 1
+		}
+	case ValidatorNumber:
+		want, err := strconv.ParseFloat(t.Expected, 64)
+		if err != nil {
+			return 0
+		}
+		for _, m := range numberPattern.FindAllString(a, -1) {
+			got, err := strconv.ParseFloat(strings.ReplaceAll(m, ",", ""), 64)
+			if err == nil && math.Abs(got-want) <= t.Tolerance {
+				return 1
+			}
+		}
+	}
+	return 0
+}
+
+func clamp01(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
+
+// SetIntelligenceTasks validates and installs a custom probe suite; an
+// empty suite restores the built-in questions. The suite is saved to the
+// tasks file when one is configured.
+func (r *Runner) SetIntelligenceTasks(tasks []ProbeTask) (ProbeTaskSet, error) {
+	clean, err := ValidateProbeTasks(tasks)
+	if err != nil {
+		return ProbeTaskSet{}, err
+	}
+	r.mu.Lock()
+	path := r.intel.tasksPath
+	r.intel.tasks = clean
+	r.mu.Unlock()
+	if path != "" {
+		if err := saveProbeTasks(path, clean); err != nil {
+			return ProbeTaskSet{}, err
+		}
+	}
+	return r.IntelligenceTasks(), nil
+}
+
+// IntelligenceTasks returns the suite the next evaluation will use.
+func (r *Runner) IntelligenceTasks() ProbeTaskSet {
+	if r == nil {
+		return ProbeTaskSet{Tasks: builtinProbeTasks()}
+	}
+	r.mu.RLock()
+	tasks := r.intel.tasks
+	r.mu.RUnlock()
+	if len(tasks) == 0 {
+		return ProbeTaskSet{Tasks: builtinProbeTasks()}
+	}
+	return ProbeTaskSet{Custom: true, Tasks: append([]ProbeTask(nil), tasks...)}
+}
+
+// LoadIntelligenceTasks reads a saved suite from path and keeps path for
+// later uploads. A missing file leaves the built-in questions in place.
+func (r *Runner) LoadIntelligenceTasks(path string) error {
+	path = strings.TrimSpace(path)
+	if r == nil || path == "" {
+		return nil
+	}
+	path = filepath.Clean(path)
+	r.mu.Lock()
+	r.intel.tasksPath = path
+	r.mu.Unlock()
+	raw, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read probe tasks: %w", err)
+	}
+	var tasks []ProbeTask
+	if err := json.Unmarshal(raw, &tasks); err != nil {
+		return fmt.Errorf("decode probe tasks: %w", err)
+	}
+	clean, err := ValidateProbeTasks(tasks)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.intel.tasks = clean
+	r.mu.Unlock()
+	return nil
+}
+
+// IntelligenceTasksPathFromEnv reads INTEL_TASKS_PATH (default
+// logs/intelligence-tasks.json).
+func IntelligenceTasksPathFromEnv() string {
+	path := strings.TrimSpace(os.Getenv("INTEL_TASKS_PATH"))
+	if path == "" {
+		path = "logs/intelligence-tasks.json"
+	}
+	return path
+}
+
+func saveProbeTasks(path string, tasks []ProbeTask) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("create probe tasks dir: %w", err)
+	}
+	if tasks == nil {
+		tasks = []ProbeTask{}
+	}
+	raw, err := json.MarshalIndent(tasks, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode probe tasks: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0o600); err != nil {
+		return fmt.Errorf("write probe tasks: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("write probe tasks: %w", err)
+	}
+	return nil
+}
@@ -42,6 +42,22 @@ type Runner struct {
 	lastRunDuration time.Duration
 	lastRunChecks   int
 	lastRunErrors   int
+	lastProbedAt    map[string]time.Time
+	healthChecks    map[string]HealthCheckStatus
+	intel           intelligenceState
+	passing         map[string]bool
+	onChange        func(Outcome)
+}
+
+// Outcome is a probe or health check result that flipped an adapter/model
+// between passing and failing. A first result only counts when it fails.
+type Outcome struct {
+	Adapter string
+	Model   string
+	Check   string
+	OK      bool
+	Error   string
+	At      time.Time
 }
 
 type modelHintAdapter interface {
@@ -49,26 +65,86 @@ type modelHintAdapter interface {
 	ModelHint() string
 }
 
+// healthCheckAdapter is implemented by adapters that declare a cheap
+// provider endpoint; the runner calls it instead of the chat probe.
+type healthCheckAdapter interface {
+	upstream.Adapter
+	HealthCheck() *upstream.HealthCheckSpec
+	CheckHealth(ctx context.Context) (upstream.HealthCheckResult, error)
+}
+
+// HealthCheckStatus is the last outcome of an adapter's custom health check.
+type HealthCheckStatus struct {
+	Method        string    `json:"method"`
+	Path          string    `json:"path"`
+	IntervalMS    int64     `json:"interval_ms"`
+	LastCheckedAt time.Time `json:"last_checked_at"`
+	OK            bool      `json:"ok"`
+	StatusCode    int       `json:"status_code,omitempty"`
+	LatencyMS     int64     `json:"latency_ms"`
+	Error         string    `json:"error,omitempty"`
+	Checks        int64     `json:"checks"`
+	Failures      int64     `json:"failures"`
+}
+
 func NewRunner(cfg Config, adapters []upstream.Adapter, health *scheduler.Engine) *Runner {
 	if health == nil {
 		return nil
 	}
 	cfg = sanitizeConfig(cfg)
 	return &Runner{
-		cfg:      cfg,
-		adapters: append([]upstream.Adapter(nil), adapters...),
-		health:   health,
+		cfg:          cfg,
+		adapters:     append([]upstream.Adapter(nil), adapters...),
+		health:       health,
+		lastProbedAt: map[string]time.Time{},
+		healthChecks: map[string]HealthCheckStatus{},
+		passing:      map[string]bool{},
 	}
 }
 
+// SetOnOutcomeChange registers a callback for probes that start failing or
+// recover.
+func (r *Runner) SetOnOutcomeChange(fn func(Outcome)) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onChange = fn
+}
+
+func (r *Runner) reportOutcome(o Outcome) {
+	key := o.Adapter + "|" + o.Model
+	r.mu.Lock()
+	prev, seen := r.passing[key]
+	r.passing[key] = o.OK
+	fn := r.onChange
+	r.mu.Unlock()
+	if fn == nil || (seen && prev == o.OK) || (!seen && o.OK) {
+		return
+	}
+	fn(o)
+}
+
 func (r *Runner) Start(ctx context.Context) {
-	if r == nil || !r.Config().Enabled {
+	if r == nil {
+		return
+	}
+	if r.IntelligenceConfig().Enabled {
+		go r.intelligenceLoop(ctx)
+	}
+	if !r.Config().Enabled {
 		return
 	}
 	go r.loop(ctx)
 }
 
+// RunOnce probes every adapter immediately, ignoring per-adapter intervals.
 func (r *Runner) RunOnce(ctx context.Context) {
+	r.run(ctx, true)
+}
+
+func (r *Runner) run(ctx context.Context, force bool) {
 	if r == nil {
 		return
 	}
@@ -77,6 +153,7 @@ func (r *Runner) RunOnce(ctx context.Context) {
 		return
 	}
 	started := time.Now()
+	tick := r.tickInterval(cfg)
 	checks := 0
 	errors := 0
 	for _, adapter := range r.adapters {
@@ -87,7 +164,26 @@ func (r *Runner) RunOnce(ctx context.Context) {
 		if name == "" {
 			continue
 		}
+		checker, hc := customHealthCheck(adapter)
+		interval := cfg.Interval
+		if hc != nil && hc.Interval() > 0 {
+			interval = hc.Interval()
+		}
+		if !force && !r.due(name, interval, tick, started) {
+			continue
+		}
+		r.mu.Lock()
+		r.lastProbedAt[name] = started
+		r.mu.Unlock()
+
 		models := r.modelsForAdapter(cfg, name, adapter)
+		if hc != nil {
+			checks++
+			if !r.healthCheckOne(ctx, cfg, checker, *hc, interval, models) {
+				errors++
+			}
+			continue
+		}
 		for _, model := range models {
 			model = strings.TrimSpace(model)
 			if model == "" {
@@ -99,6 +195,9 @@ func (r *Runner) RunOnce(ctx context.Context) {
 			}
 		}
 	}
+	if !force && checks == 0 {
+		return
+	}
 	r.mu.Lock()
 	r.totalRuns++
 	r.lastRunAt = time.Now()
@@ -110,18 +209,129 @@ func (r *Runner) RunOnce(ctx context.Context) {
 
 func (r *Runner) loop(ctx context.Context) {
 	r.RunOnce(ctx)
-	ticker := time.NewTicker(r.cfg.Interval)
+	ticker := time.NewTicker(r.tickInterval(r.Config()))
 	defer ticker.Stop()
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			r.RunOnce(ctx)
+			r.run(ctx, false)
 		}
 	}
 }
 
+// tickInterval is the shortest of the global probe interval and any custom
+// health check interval, so faster checks are not held back by slow ones.
+func (r *Runner) tickInterval(cfg Config) time.Duration {
+	tick := cfg.Interval
+	for _, adapter := range r.adapters {
+		if _, hc := customHealthCheck(adapter); hc != nil {
+			if d := hc.Interval(); d > 0 && d < tick {
+				tick = d
+			}
+		}
+	}
+	return tick
+}
+
+// due reports whether name was last probed at least interval ago. Half a
+// tick of slack keeps ticker jitter from skipping a whole cycle.
+func (r *Runner) due(name string, interval, tick time.Duration, now time.Time) bool {
+	r.mu.RLock()
+	last, ok := r.lastProbedAt[name]
+	r.mu.RUnlock()
+	if !ok {
+		return true
+	}
+	return now.Sub(last) >= interval-tick/2
+}
+
+func customHealthCheck(adapter upstream.Adapter) (healthCheckAdapter, *upstream.HealthCheckSpec) {
+	checker, ok := adapter.(healthCheckAdapter)
+	if !ok {
+		return nil, nil
+	}
+	hc := checker.HealthCheck()
+	if hc == nil {
+		return nil, nil
+	}
+	return checker, hc
+}
+
+// healthCheckOne runs an adapter's custom health check and records the
+// result for each probed model. Without known models the result feeds the
+// adapter-level success/failure counters instead.
+func (r *Runner) healthCheckOne(ctx context.Context, cfg Config, adapter healthCheckAdapter, hc upstream.HealthCheckSpec, interval time.Duration, models []string) bool {
+	started := time.Now()
+	timeout := cfg.Timeout
+	if hc.Timeout() > 0 {
+		timeout = hc.Timeout()
+	}
+	checkCtx, cancel := context.WithTimeout(ctx, timeout)
+	res, err := adapter.CheckHealth(checkCtx)
+	cancel()
+
+	name := adapter.Name()
+	pr := scheduler.ProbeResult{
+		CheckedAt: started,
+		Exists:    err == nil,
+		Latency:   res.Latency,
+	}
+	if err != nil {
+		pr.Error = err.Error()
+	}
+	recorded := false
+	for _, model := range models {
+		model = strings.TrimSpace(model)
+		if model == "" {
+			continue
+		}
+		r.health.UpdateProbe(name, model, pr)
+		recorded = true
+	}
+	if !recorded {
+		if err != nil {
+			r.health.ObserveFailure(name, "", err)
+		} else {
+			r.health.ObserveSuccess(name, "", res.Latency)
+		}
+	}
+
+	r.mu.Lock()
+	status := r.healthChecks[name]
+	status.Method = hc.Method
+	status.Path = hc.Path
+	status.IntervalMS = interval.Milliseconds()
+	status.LastCheckedAt = started
+	status.OK = err == nil
+	status.StatusCode = res.StatusCode
+	status.LatencyMS = res.Latency.Milliseconds()
+	status.Error = pr.Error
+	status.Checks++
+	if err != nil {
+		status.Failures++
+	}
+	r.healthChecks[name] = status
+	r.mu.Unlock()
+	r.reportOutcome(Outcome{Adapter: name, Check: "health_check", OK: err == nil, Error: pr.Error, At: started})
+	return err == nil
+}
+
+// HealthChecks returns the latest custom health check results by adapter.
+func (r *Runner) HealthChecks() map[string]HealthCheckStatus {
+	if r == nil {
+		return nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make(map[string]HealthCheckStatus, len(r.healthChecks))
+	for name, status := range r.healthChecks {
+		out[name] = status
+	}
+	return out
+}
+
 func (r *Runner) probeOne(ctx context.Context, cfg Config, adapter upstream.Adapter, model string) bool {
 	started := time.Now()
 	pr := scheduler.ProbeResult{
@@ -143,6 +353,7 @@ func (r *Runner) probeOne(ctx context.Context, cfg Config, adapter upstream.Adap
 		pr.Error = err.Error()
 		pr.Exists = false
 		r.health.UpdateProbe(adapter.Name(), model, pr)
+		r.reportOutcome(Outcome{Adapter: adapter.Name(), Model: model, Check: "probe", Error: pr.Error, At: started})
 		return false
 	}
 
@@ -166,19 +377,10 @@ func (r *Runner) probeOne(ctx context.Context, cfg Config, adapter upstream.Adap
 		}
 	}
 	r.health.UpdateProbe(adapter.Name(), model, pr)
-	if strings.TrimSpace(pr.Error) != "" {
-		return false
-	}
-	if !pr.Exists {
-		return false
-	}
-	if pr.StreamChecked && !pr.StreamOK {
-		return false
-	}
-	if pr.ToolChecked && !pr.ToolOK {
-		return false
-	}
-	return true
+	ok := strings.TrimSpace(pr.Error) == "" && pr.Exists &&
+		(!pr.StreamChecked || pr.StreamOK) && (!pr.ToolChecked || pr.ToolOK)
+	r.reportOutcome(Outcome{Adapter: adapter.Name(), Model: model, Check: "probe", OK: ok, Error: pr.Error, At: started})
+	return ok
 }
 
 func (r *Runner) streamSmoke(ctx context.Context, cfg Config, adapter upstream.Adapter, model string) bool {
@@ -290,6 +492,7 @@ func (r *Runner) Snapshot() map[string]any {
 	if r == nil {
 		return nil
 	}
+	healthChecks := r.HealthChecks()
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 	cfg := cloneConfig(r.cfg)
@@ -306,6 +509,7 @@ func (r *Runner) Snapshot() map[string]any {
 		"last_run_duration_ms": r.lastRunDuration.Milliseconds(),
 		"last_run_checks":      r.lastRunChecks,
 		"last_run_errors":      r.lastRunErrors,
+		"health_checks":        healthChecks,
 	}
 }
 
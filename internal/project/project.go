@@ -0,0 +1,449 @@
+// Package project holds tenant projects. A project scopes runtime settings
+// overrides, per-mode upstream routes, a channel group and a quota; requests
+// join one through a bound token or the x-cc-project header.
+package project
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"ccgateway/internal/requestctx"
+)
+
+var (
+	ErrNotFound      = errors.New("project not found")
+	ErrExists        = errors.New("project already exists")
+	ErrInvalidID     = errors.New("project id must be 1-64 characters of a-z, 0-9, '-', '_' or '.'")
+	ErrInvalidInput  = errors.New("invalid project")
+	ErrTokenBound    = errors.New("token is already bound to another project")
+	ErrRateLimited   = errors.New("project request rate limit exceeded")
+	ErrQuotaExceeded = errors.New("project token budget exhausted")
+)
+
+// Quota bounds a project's traffic; zero disables a limit. TokenBudget
+// counts input plus output tokens over the project's lifetime.
+type Quota struct {
+	RequestsPerMinute int   `json:"requests_per_minute,omitempty"`
+	TokenBudget       int64 `json:"token_budget,omitempty"`
+}
+
+// Usage is what a project has consumed so far.
+type Usage struct {
+	Requests int64 `json:"requests"`
+	Tokens   int64 `json:"tokens"`
+}
+
+// Project is one tenant. Settings is a partial runtime settings object
+// merged over the global settings; Routes maps a mode to the adapters it
+// is routed to and wins over routing.mode_routes; ChannelGroup replaces
+// the user's group when picking a channel.
+type Project struct {
+	ID           string              `json:"id"`
+	Name         string              `json:"name,omitempty"`
+	Description  string              `json:"description,omitempty"`
+	Settings     json.RawMessage     `json:"settings,omitempty"`
+	Routes       map[string][]string `json:"routes,omitempty"`
+	ChannelGroup string              `json:"channel_group,omitempty"`
+	Quota        Quota               `json:"quota"`
+	Usage        Usage               `json:"usage"`
+	TokenIDs     []int64             `json:"token_ids,omitempty"`
+	CreatedAt    time.Time           `json:"created_at"`
+	UpdatedAt    time.Time           `json:"updated_at"`
+}
+
+// Input creates or updates a project. Nil fields are left unchanged on
+// update; ID is only read on create.
+type Input struct {
+	ID           string               `json:"id"`
+	Name         *string              `json:"name"`
+	Description  *string              `json:"description"`
+	Settings     *json.RawMessage     `json:"settings"`
+	Routes       *map[string][]string `json:"routes"`
+	ChannelGroup *string              `json:"channel_group"`
+	Quota        *Quota               `json:"quota"`
+	TokenIDs     *[]int64             `json:"token_ids"`
+}
+
+// Config tunes the store. Path persists projects as JSON.
+type Config struct {
+	Path string
+}
+
+// Store is safe for concurrent use.
+type Store struct {
+	cfg Config
+
+	mu       sync.Mutex
+	projects map[string]*Project
+	tokens   map[int64]string
+	windows  map[string]*window
+}
+
+// window counts requests in the current minute.
+type window struct {
+	start time.Time
+	count int
+}
+
+// New builds a store and loads projects from cfg.Path when set.
+func New(cfg Config) (*Store, error) {
+	cfg.Path = strings.TrimSpace(cfg.Path)
+	s := &Store{
+		cfg:      cfg,
+		projects: map[string]*Project{},
+		tokens:   map[int64]string{},
+		windows:  map[string]*window{},
+	}
+	if cfg.Path != "" {
+		if err := s.load(); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// NewFromEnv reads PROJECTS_STORE_PATH (empty keeps projects in memory).
+func NewFromEnv() (*Store, error) {
+	return New(Config{Path: os.Getenv("PROJECTS_STORE_PATH")})
+}
+
+func (s *Store) Create(in Input) (Project, error) {
+	id := strings.TrimSpace(in.ID)
+	if id == "" || requestctx.NormalizeProjectID(id) != id {
+		return Project{}, ErrInvalidID
+	}
+	now := time.Now().UTC()
+	p := Project{ID: id, CreatedAt: now, UpdatedAt: now}
+	if err := applyInput(&p, in); err != nil {
+		return Project{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.projects[id]; ok {
+		return Project{}, ErrExists
+	}
+	if err := s.checkTokensLocked(id, p.TokenIDs); err != nil {
+		return Project{}, err
+	}
+	s.projects[id] = &p
+	s.indexLocked()
+	if err := s.saveLocked(); err != nil {
+		delete(s.projects, id)
+		s.indexLocked()
+		return Project{}, err
+	}
+	return cloneProject(&p), nil
+}
+
+func (s *Store) Update(id string, in Input) (Project, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prev, ok := s.projects[strings.TrimSpace(id)]
+	if !ok {
+		return Project{}, ErrNotFound
+	}
+	next := cloneProject(prev)
+	if err := applyInput(&next, in); err != nil {
+		return Project{}, err
+	}
+	if err := s.checkTokensLocked(next.ID, next.TokenIDs); err != nil {
+		return Project{}, err
+	}
+	next.UpdatedAt = time.Now().UTC()
+	saved := *prev
+	*prev = next
+	s.indexLocked()
+	if err := s.saveLocked(); err != nil {
+		*prev = saved
+		s.indexLocked()
+		return Project{}, err
+	}
+	return cloneProject(prev), nil
+}
+
+func (s *Store) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prev, ok := s.projects[strings.TrimSpace(id)]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(s.projects, prev.ID)
+	s.indexLocked()
+	if err := s.saveLocked(); err != nil {
+		s.projects[prev.ID] = prev
+		s.indexLocked()
+		return err
+	}
+	delete(s.windows, prev.ID)
+	return nil
+}
+
+func (s *Store) Get(id string) (Project, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.projects[strings.TrimSpace(id)]
+	if !ok {
+		return Project{}, false
+	}
+	return cloneProject(p), true
+}
+
+// List returns all projects ordered by ID.
+func (s *Store) List() []Project {
+	s.mu.Lock()
+	out := make([]Project, 0, len(s.projects))
+	for _, p := range s.projects {
+		out = append(out, cloneProject(p))
+	}
+	s.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// ForToken returns the project token id is bound to.
+func (s *Store) ForToken(tokenID int64) (Project, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id, ok := s.tokens[tokenID]
+	if !ok {
+		return Project{}, false
+	}
+	return cloneProject(s.projects[id]), true
+}
+
+// Admit counts one request against project id's quota. Unknown projects
+// are not limited.
+func (s *Store) Admit(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.projects[strings.TrimSpace(id)]
+	if !ok {
+		return nil
+	}
+	if p.Quota.TokenBudget > 0 && p.Usage.Tokens >= p.Quota.TokenBudget {
+		return ErrQuotaExceeded
+	}
+	if limit := p.Quota.RequestsPerMinute; limit > 0 {
+		now := time.Now()
+		w := s.windows[p.ID]
+		if w == nil || now.Sub(w.start) >= time.Minute {
+			w = &window{start: now}
+			s.windows[p.ID] = w
+		}
+		if w.count >= limit {
+			return ErrRateLimited
+		}
+		w.count++
+	}
+	p.Usage.Requests++
+	return nil
+}
+
+// RetryAfter reports how long until project id's request window resets.
+func (s *Store) RetryAfter(id string) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w := s.windows[strings.TrimSpace(id)]
+	if w == nil {
+		return 0
+	}
+	if d := time.Minute - time.Since(w.start); d > 0 {
+		return d
+	}
+	return 0
+}
+
+// Charge adds tokens to project id's usage. Usage is kept in memory only;
+// it is saved with the next change to the project.
+func (s *Store) Charge(id string, tokens int64) {
+	if tokens <= 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if p, ok := s.projects[strings.TrimSpace(id)]; ok {
+		p.Usage.Tokens += tokens
+	}
+}
+
+// ResetUsage zeroes project id's usage counters.
+func (s *Store) ResetUsage(id string) (Project, error) {
+	return s.mutate(id, func(p *Project) { p.Usage = Usage{} })
+}
+
+func (s *Store) mutate(id string, fn func(*Project)) (Project, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.projects[strings.TrimSpace(id)]
+	if !ok {
+		return Project{}, ErrNotFound
+	}
+	fn(p)
+	p.UpdatedAt = time.Now().UTC()
+	if err := s.saveLocked(); err != nil {
+		return Project{}, err
+	}
+	return cloneProject(p), nil
+}
+
+func applyInput(p *Project, in Input) error {
+	if in.Name != nil {
+		p.Name = strings.TrimSpace(*in.Name)
+	}
+	if in.Description != nil {
+		p.Description = strings.TrimSpace(*in.Description)
+	}
+	if in.Settings != nil {
+		raw := bytes.TrimSpace(*in.Settings)
+		switch {
+		case len(raw) == 0 || bytes.Equal(raw, []byte("null")):
+			p.Settings = nil
+		case raw[0] != '{' || !json.Valid(raw):
+			return fmt.Errorf("%w: settings must be a JSON object", ErrInvalidInput)
+		default:
+			p.Settings = append(json.RawMessage(nil), raw...)
+		}
+	}
+	if in.Routes != nil {
+		routes := map[string][]string{}
+		for mode, adapters := range *in.Routes {
+			mode = strings.ToLower(strings.TrimSpace(mode))
+			var clean []string
+			for _, a := range adapters {
+				if a = strings.TrimSpace(a); a != "" {
+					clean = append(clean, a)
+				}
+			}
+			if mode == "" || len(clean) == 0 {
+				return fmt.Errorf("%w: routes need a mode and at least one adapter", ErrInvalidInput)
+			}
+			routes[mode] = clean
+		}
+		if len(routes) == 0 {
+			routes = nil
+		}
+		p.Routes = routes
+	}
+	if in.ChannelGroup != nil {
+		p.ChannelGroup = strings.TrimSpace(*in.ChannelGroup)
+	}
+	if in.Quota != nil {
+		if in.Quota.RequestsPerMinute < 0 || in.Quota.TokenBudget < 0 {
+			return fmt.Errorf("%w: quota limits must not be negative", ErrInvalidInput)
+		}
+		p.Quota = *in.Quota
+	}
+	if in.TokenIDs != nil {
+		seen := map[int64]bool{}
+		var ids []int64
+		for _, id := range *in.TokenIDs {
+			if id > 0 && !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		p.TokenIDs = ids
+	}
+	return nil
+}
+
+func (s *Store) checkTokensLocked(projectID string, ids []int64) error {
+	for _, id := range ids {
+		if owner, ok := s.tokens[id]; ok && owner != projectID {
+			return fmt.Errorf("%w: token %d belongs to %s", ErrTokenBound, id, owner)
+		}
+	}
+	return nil
+}
+
+func (s *Store) indexLocked() {
+	s.tokens = map[int64]string{}
+	for _, p := range s.projects {
+		for _, id := range p.TokenIDs {
+			s.tokens[id] = p.ID
+		}
+	}
+}
+
+func cloneProject(p *Project) Project {
+	out := *p
+	out.Settings = append(json.RawMessage(nil), p.Settings...)
+	if len(p.Settings) == 0 {
+		out.Settings = nil
+	}
+	if p.Routes != nil {
+		out.Routes = make(map[string][]string, len(p.Routes))
+		for mode, adapters := range p.Routes {
+			out.Routes[mode] = append([]string(nil), adapters...)
+		}
+	}
+	out.TokenIDs = append([]int64(nil), p.TokenIDs...)
+	return out
+}
+
+func (s *Store) load() error {
+	raw, err := os.ReadFile(s.cfg.Path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read project store: %w", err)
+	}
+	var projects []Project
+	if err := json.Unmarshal(raw, &projects); err != nil {
+		return fmt.Errorf("parse project store: %w", err)
+	}
+	for i := range projects {
+		p := projects[i]
+		if p.ID == "" {
+			continue
+		}
+		if len(p.Settings) > 0 {
+			var compact bytes.Buffer
+			if err := json.Compact(&compact, p.Settings); err == nil {
+				p.Settings = compact.Bytes()
+			}
+		}
+		s.projects[p.ID] = &p
+	}
+	s.indexLocked()
+	return nil
+}
+
+// saveLocked rewrites the projects file through a temporary file so a
+// crash never leaves it half written.
+func (s *Store) saveLocked() error {
+	if s.cfg.Path == "" {
+		return nil
+	}
+	projects := make([]Project, 0, len(s.projects))
+	for _, p := range s.projects {
+		projects = append(projects, cloneProject(p))
+	}
+	sort.Slice(projects, func(i, j int) bool { return projects[i].ID < projects[j].ID })
+	raw, err := json.MarshalIndent(projects, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.cfg.Path), 0o755); err != nil {
+		return fmt.Errorf("create project store dir: %w", err)
+	}
+	tmp := s.cfg.Path + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0o600); err != nil {
+		return fmt.Errorf("write project store: %w", err)
+	}
+	if err := os.Rename(tmp, s.cfg.Path); err != nil {
+		return fmt.Errorf("write project store: %w", err)
+	}
+	return nil
+}
@@ -1,8 +1,12 @@
 package runlog
 
 import (
+	"bufio"
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"sync"
@@ -33,6 +37,12 @@ type Logger interface {
 	Log(entry Entry) error
 }
 
+// Eraser is implemented by loggers that can remove the entries of runs
+// they already hold, for data deletion requests.
+type Eraser interface {
+	DeleteRuns(ctx context.Context, runIDs map[string]bool) (int, error)
+}
+
 type FileLogger struct {
 	mu   sync.Mutex
 	path string
@@ -72,3 +82,135 @@ func (l *FileLogger) Log(entry Entry) error {
 	}
 	return nil
 }
+
+// DeleteRuns rewrites the log without the entries of runIDs.
+func (l *FileLogger) DeleteRuns(_ context.Context, runIDs map[string]bool) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	data, err := os.ReadFile(l.path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	kept, n := dropRunLines(data, runIDs)
+	if n == 0 {
+		return 0, nil
+	}
+	tmp := l.path + ".tmp"
+	if err := os.WriteFile(tmp, kept, 0o644); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(tmp, l.path); err != nil {
+		_ = os.Remove(tmp)
+		return 0, err
+	}
+	return n, nil
+}
+
+// dropRunLines returns the JSONL data without the lines whose run_id is
+// in runIDs, and how many lines were dropped.
+func dropRunLines(data []byte, runIDs map[string]bool) ([]byte, int) {
+	var kept bytes.Buffer
+	dropped := 0
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(data)+1)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		var entry struct {
+			RunID string `json:"run_id"`
+		}
+		if json.Unmarshal(line, &entry) == nil && entry.RunID != "" && runIDs[entry.RunID] {
+			dropped++
+			continue
+		}
+		kept.Write(line)
+		kept.WriteByte('\n')
+	}
+	return kept.Bytes(), dropped
+}
+
+// SlogLogger writes run entries to a structured logger: failed runs at
+// warn (4xx) or error (5xx), the rest at info.
+type SlogLogger struct {
+	logger *slog.Logger
+}
+
+func NewSlogLogger(logger *slog.Logger) *SlogLogger {
+	return &SlogLogger{logger: logger}
+}
+
+func (l *SlogLogger) Log(entry Entry) error {
+	level := slog.LevelInfo
+	switch {
+	case entry.Status >= 500:
+		level = slog.LevelError
+	case entry.Status >= 400:
+		level = slog.LevelWarn
+	}
+	attrs := []slog.Attr{
+		slog.String("path", entry.Path),
+		slog.Int("status", entry.Status),
+		slog.Int64("duration_ms", entry.DurationMS),
+		slog.Bool("stream", entry.Stream),
+	}
+	if entry.RunID != "" {
+		attrs = append(attrs, slog.String("run_id", entry.RunID))
+	}
+	for _, kv := range [][2]string{
+		{"mode", entry.Mode},
+		{"client_model", entry.ClientModel},
+		{"requested_model", entry.RequestedModel},
+		{"upstream_model", entry.UpstreamModel},
+		{"reason", entry.Reason},
+		{"error", entry.Error},
+	} {
+		if kv[1] != "" {
+			attrs = append(attrs, slog.String(kv[0], kv[1]))
+		}
+	}
+	if entry.ToolCount > 0 {
+		attrs = append(attrs, slog.Int("tool_count", entry.ToolCount))
+	}
+	l.logger.LogAttrs(context.Background(), level, "run", attrs...)
+	return nil
+}
+
+// Multi fans every entry out to each logger and returns the first error.
+type Multi []Logger
+
+func (m Multi) Log(entry Entry) error {
+	var first error
+	for _, l := range m {
+		if l == nil {
+			continue
+		}
+		if err := l.Log(entry); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// DeleteRuns erases the runs from every logger that can, and returns the
+// first error.
+func (m Multi) DeleteRuns(ctx context.Context, runIDs map[string]bool) (int, error) {
+	var (
+		total int
+		first error
+	)
+	for _, l := range m {
+		eraser, ok := l.(Eraser)
+		if !ok {
+			continue
+		}
+		n, err := eraser.DeleteRuns(ctx, runIDs)
+		total += n
+		if err != nil && first == nil {
+			first = err
+		}
+	}
+	return total, first
+}
@@ -0,0 +1,187 @@
+package runlog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultShipInterval = time.Minute
+	defaultShipBuffer   = 10000
+)
+
+// ObjectStore receives shipped log chunks. *storage.S3Backend implements
+// it.
+type ObjectStore interface {
+	PutObject(ctx context.Context, key string, data []byte, contentType string) error
+}
+
+// ShipConfig tunes a ShippingLogger. Interval is how often buffered
+// entries are uploaded (default 1m); MaxBuffered caps entries held while
+// uploads fail (default 10000), dropping the oldest beyond it.
+type ShipConfig struct {
+	Interval    time.Duration
+	MaxBuffered int
+}
+
+// ShippingLogger buffers entries and uploads them as JSONL chunks named
+// YYYY/MM/DD/HHMMSS-<host>-<seq>.jsonl, so run logs outlive the container.
+type ShippingLogger struct {
+	store ObjectStore
+	cfg   ShipConfig
+	host  string
+
+	mu      sync.Mutex
+	buf     [][]byte
+	seq     int
+	dropped int
+}
+
+func NewShippingLogger(store ObjectStore, cfg ShipConfig) *ShippingLogger {
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultShipInterval
+	}
+	if cfg.MaxBuffered <= 0 {
+		cfg.MaxBuffered = defaultShipBuffer
+	}
+	host, _ := os.Hostname()
+	if host == "" {
+		host = "gateway"
+	}
+	return &ShippingLogger{store: store, cfg: cfg, host: host}
+}
+
+func (l *ShippingLogger) Log(entry Entry) error {
+	if entry.Timestamp == "" {
+		entry.Timestamp = time.Now().UTC().Format(time.RFC3339Nano)
+	}
+	raw, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.buf = append(l.buf, raw)
+	l.trimLocked()
+	return nil
+}
+
+// Flush uploads the buffered entries as one chunk. On failure they are
+// kept for the next flush.
+func (l *ShippingLogger) Flush(ctx context.Context) error {
+	l.mu.Lock()
+	lines := l.buf
+	l.buf = nil
+	l.seq++
+	seq := l.seq
+	l.mu.Unlock()
+	if len(lines) == 0 {
+		return nil
+	}
+
+	var chunk bytes.Buffer
+	for _, line := range lines {
+		chunk.Write(line)
+		chunk.WriteByte('\n')
+	}
+	key := fmt.Sprintf("%s-%s-%06d.jsonl", time.Now().UTC().Format("2006/01/02/150405"), l.host, seq)
+	if err := l.store.PutObject(ctx, key, chunk.Bytes(), "application/jsonl"); err != nil {
+		l.mu.Lock()
+		l.buf = append(lines, l.buf...)
+		l.trimLocked()
+		l.mu.Unlock()
+		return fmt.Errorf("ship run log: %w", err)
+	}
+	return nil
+}
+
+// Stats reports entries waiting for upload and entries dropped because
+// the buffer was full.
+func (l *ShippingLogger) Stats() (buffered, dropped int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.buf), l.dropped
+}
+
+// Run flushes every interval until ctx ends, reporting failed uploads to
+// onError. Call Flush once more at shutdown.
+func (l *ShippingLogger) Run(ctx context.Context, onError func(error)) {
+	ticker := time.NewTicker(l.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := l.Flush(ctx); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+// DeleteRuns drops the runs' buffered entries and, when the store can
+// list and rewrite objects, their lines in chunks already shipped.
+func (l *ShippingLogger) DeleteRuns(ctx context.Context, runIDs map[string]bool) (int, error) {
+	l.mu.Lock()
+	kept := l.buf[:0]
+	n := 0
+	for _, line := range l.buf {
+		if _, dropped := dropRunLines(line, runIDs); dropped > 0 {
+			n++
+			continue
+		}
+		kept = append(kept, line)
+	}
+	l.buf = kept
+	l.mu.Unlock()
+
+	store, ok := l.store.(interface {
+		List(ctx context.Context, prefix string) ([]string, error)
+		GetObject(ctx context.Context, key string) ([]byte, error)
+		DeleteObject(ctx context.Context, key string) error
+	})
+	if !ok {
+		return n, fmt.Errorf("run log store cannot rewrite shipped chunks")
+	}
+	keys, err := store.List(ctx, "")
+	if err != nil {
+		return n, fmt.Errorf("list shipped run logs: %w", err)
+	}
+	for _, key := range keys {
+		if !strings.HasSuffix(key, ".jsonl") {
+			continue
+		}
+		data, err := store.GetObject(ctx, key)
+		if err != nil {
+			return n, fmt.Errorf("read shipped run log %s: %w", key, err)
+		}
+		rest, dropped := dropRunLines(data, runIDs)
+		if dropped == 0 {
+			continue
+		}
+		if len(rest) == 0 {
+			err = store.DeleteObject(ctx, key)
+		} else {
+			err = l.store.PutObject(ctx, key, rest, "application/jsonl")
+		}
+		if err != nil {
+			return n, fmt.Errorf("rewrite shipped run log %s: %w", key, err)
+		}
+		n += dropped
+	}
+	return n, nil
+}
+
+func (l *ShippingLogger) trimLocked() {
+	if over := len(l.buf) - l.cfg.MaxBuffered; over > 0 {
+		l.buf = l.buf[over:]
+		l.dropped += over
+	}
+}
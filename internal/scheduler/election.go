@@ -32,10 +32,10 @@ type Worker struct {
 
 // IntelligenceScore is the input to the election: one score per adapter.
 type IntelligenceScore struct {
-	AdapterName string
-	Model       string
-	Score       float64 // 0-100
-	TestedAt    time.Time
+	AdapterName string    `json:"adapter_name"`
+	Model       string    `json:"model"`
+	Score       float64   `json:"score"` // 0-100
+	TestedAt    time.Time `json:"tested_at"`
 }
 
 // Election manages the scheduler model election process.
@@ -16,6 +16,11 @@ type Config struct {
 	StrictProbeGate    bool
 	RequireStreamProbe bool
 	RequireToolProbe   bool
+	MaintenanceWindows []MaintenanceWindow
+	// FirstTokenSLO demotes adapters whose smoothed time to first token on
+	// real streams exceeds it; zero leaves streaming latency out of the
+	// score.
+	FirstTokenSLO time.Duration
 }
 
 type ConfigPatch struct {
@@ -24,6 +29,9 @@ type ConfigPatch struct {
 	StrictProbeGate    *bool  `json:"strict_probe_gate,omitempty"`
 	RequireStreamProbe *bool  `json:"require_stream_probe,omitempty"`
 	RequireToolProbe   *bool  `json:"require_tool_probe,omitempty"`
+	FirstTokenSLOMS    *int64 `json:"first_token_slo_ms,omitempty"`
+	// MaintenanceWindows replaces the whole window list when set.
+	MaintenanceWindows *[]MaintenanceWindow `json:"maintenance_windows,omitempty"`
 }
 
 type ProbeResult struct {
@@ -38,9 +46,21 @@ type ProbeResult struct {
 }
 
 type Engine struct {
-	mu       sync.RWMutex
-	cfg      Config
-	adapters map[string]*adapterState
+	mu          sync.RWMutex
+	cfg         Config
+	adapters    map[string]*adapterState
+	maintenance []maintenanceEntry
+	onHealth    func(HealthChange)
+}
+
+// HealthChange reports an adapter entering cooldown after FailureThreshold
+// failures in a row (Down) or its first success afterwards.
+type HealthChange struct {
+	Adapter             string
+	Down                bool
+	ConsecutiveFailures int
+	LastError           string
+	CooldownUntil       time.Time
 }
 
 type adapterState struct {
@@ -49,10 +69,14 @@ type adapterState struct {
 	failures            int64
 	consecutiveFailures int
 	lastLatency         time.Duration
+	firstToken          time.Duration
+	tokensPerSecond     float64
 	lastError           string
+	maintenanceFailures int64
 	lastSuccessAt       time.Time
 	lastFailureAt       time.Time
 	cooldownUntil       time.Time
+	down                bool
 	models              map[string]modelProbe
 }
 
@@ -82,9 +106,17 @@ func NewEngine(cfg Config, adapterNames []string) *Engine {
 	if cfg.Cooldown <= 0 {
 		cfg.Cooldown = 30 * time.Second
 	}
+	windows, entries, err := compileMaintenanceWindows(cfg.MaintenanceWindows)
+	if err != nil {
+		// NewFromEnv and UpdateConfigPatch validate windows up front; an
+		// invalid list passed directly is dropped rather than half-applied.
+		windows, entries = nil, nil
+	}
+	cfg.MaintenanceWindows = windows
 	e := &Engine{
-		cfg:      cfg,
-		adapters: map[string]*adapterState{},
+		cfg:         cfg,
+		adapters:    map[string]*adapterState{},
+		maintenance: entries,
 	}
 	for _, name := range adapterNames {
 		e.ensureAdapterLocked(name)
@@ -147,10 +179,48 @@ func (e *Engine) Order(req orchestrator.Request, candidates []string, wantStream
 	return out
 }
 
+// Available returns the candidates currently allowed for req, keeping their
+// order; cooled-down, in-maintenance and probe-gated adapters are left out.
+// Unlike Order it never falls back to the full list.
+func (e *Engine) Available(req orchestrator.Request, candidates []string, wantStream bool) []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := time.Now()
+	model := strings.TrimSpace(req.Model)
+	needTool := len(req.Tools) > 0
+	out := make([]string, 0, len(candidates))
+	for _, name := range candidates {
+		if e.allowed(e.ensureAdapterLocked(name), model, wantStream, needTool, now) {
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
+// SetOnHealthChange registers a callback for adapters going down and
+// recovering. It runs after the engine lock is released.
+func (e *Engine) SetOnHealthChange(fn func(HealthChange)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.onHealth = fn
+}
+
 func (e *Engine) ObserveSuccess(adapterName, model string, latency time.Duration) {
+	var change *HealthChange
+	var notify func(HealthChange)
+	defer func() {
+		if change != nil && notify != nil {
+			notify(*change)
+		}
+	}()
 	e.mu.Lock()
 	defer e.mu.Unlock()
 	st := e.ensureAdapterLocked(adapterName)
+	if st.down {
+		st.down = false
+		change, notify = &HealthChange{Adapter: st.name}, e.onHealth
+	}
 	st.successes++
 	st.consecutiveFailures = 0
 	st.lastLatency = latency
@@ -167,16 +237,64 @@ func (e *Engine) ObserveSuccess(adapterName, model string, latency time.Duration
 	}
 }
 
+// firstTokenSmoothing is the weight of a new stream in the moving averages
+// of time to first token and output speed.
+const firstTokenSmoothing = 0.2
+
+// ObserveStreamLatency folds a finished stream's time to first token and
+// output speed into the adapter's moving averages.
+func (e *Engine) ObserveStreamLatency(adapterName, _ string, firstToken time.Duration, tokensPerSecond float64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	st := e.ensureAdapterLocked(adapterName)
+	if st.firstToken == 0 {
+		st.firstToken = firstToken
+	} else {
+		st.firstToken += time.Duration(firstTokenSmoothing * float64(firstToken-st.firstToken))
+	}
+	if tokensPerSecond > 0 {
+		if st.tokensPerSecond == 0 {
+			st.tokensPerSecond = tokensPerSecond
+		} else {
+			st.tokensPerSecond += firstTokenSmoothing * (tokensPerSecond - st.tokensPerSecond)
+		}
+	}
+}
+
 func (e *Engine) ObserveFailure(adapterName, model string, err error) {
+	var change *HealthChange
+	var notify func(HealthChange)
+	defer func() {
+		if change != nil && notify != nil {
+			notify(*change)
+		}
+	}()
 	e.mu.Lock()
 	defer e.mu.Unlock()
 	st := e.ensureAdapterLocked(adapterName)
+	if _, ok := e.maintenanceAtLocked(st.name, time.Now()); ok {
+		// Failures during a scheduled window are expected; keep them out of
+		// the health score and cooldown.
+		st.maintenanceFailures++
+		return
+	}
 	st.failures++
 	st.consecutiveFailures++
 	st.lastFailureAt = time.Now()
 	st.lastError = strings.TrimSpace(errorText(err))
 	if st.consecutiveFailures >= e.cfg.FailureThreshold {
 		st.cooldownUntil = time.Now().Add(e.cfg.Cooldown)
+		if !st.down {
+			st.down = true
+			change = &HealthChange{
+				Adapter:             st.name,
+				Down:                true,
+				ConsecutiveFailures: st.consecutiveFailures,
+				LastError:           st.lastError,
+				CooldownUntil:       st.cooldownUntil,
+			}
+			notify = e.onHealth
+		}
 	}
 	model = strings.TrimSpace(model)
 	if model != "" {
@@ -225,6 +343,7 @@ func (e *Engine) UpdateProbe(adapterName, model string, result ProbeResult) {
 func (e *Engine) Snapshot() map[string]any {
 	e.mu.RLock()
 	defer e.mu.RUnlock()
+	now := time.Now()
 	out := map[string]any{}
 	for name, st := range e.adapters {
 		models := map[string]any{}
@@ -247,7 +366,11 @@ func (e *Engine) Snapshot() map[string]any {
 			"consecutive_failures": st.consecutiveFailures,
 			"last_error":           st.lastError,
 			"last_latency_ms":      st.lastLatency.Milliseconds(),
+			"first_token_ms":       st.firstToken.Milliseconds(),
+			"tokens_per_second":    st.tokensPerSecond,
 			"cooldown_until":       st.cooldownUntil,
+			"maintenance_failures": st.maintenanceFailures,
+			"in_maintenance":       e.inMaintenanceLocked(name, now),
 			"models":               models,
 		}
 	}
@@ -279,13 +402,28 @@ func (e *Engine) UpdateConfigPatch(patch ConfigPatch) (Config, error) {
 	if patch.RequireToolProbe != nil {
 		next.RequireToolProbe = *patch.RequireToolProbe
 	}
+	if patch.FirstTokenSLOMS != nil {
+		next.FirstTokenSLO = time.Duration(*patch.FirstTokenSLOMS) * time.Millisecond
+	}
 	if next.FailureThreshold <= 0 {
 		return e.cfg, errors.New("failure_threshold must be > 0")
 	}
 	if next.Cooldown <= 0 {
 		return e.cfg, errors.New("cooldown_ms must be > 0")
 	}
+	if next.FirstTokenSLO < 0 {
+		return e.cfg, errors.New("first_token_slo_ms must be >= 0")
+	}
+	entries := e.maintenance
+	if patch.MaintenanceWindows != nil {
+		windows, compiled, err := compileMaintenanceWindows(*patch.MaintenanceWindows)
+		if err != nil {
+			return e.cfg, err
+		}
+		next.MaintenanceWindows, entries = windows, compiled
+	}
 	e.cfg = next
+	e.maintenance = entries
 	return e.cfg, nil
 }
 
@@ -298,15 +436,65 @@ func (e *Engine) AdminSnapshot() map[string]any {
 			"strict_probe_gate":    cfg.StrictProbeGate,
 			"require_stream_probe": cfg.RequireStreamProbe,
 			"require_tool_probe":   cfg.RequireToolProbe,
+			"maintenance_windows":  cfg.MaintenanceWindows,
+			"first_token_slo_ms":   cfg.FirstTokenSLO.Milliseconds(),
 		},
 		"adapters": e.Snapshot(),
+		"maintenance": map[string]any{
+			"upcoming": e.UpcomingMaintenance(time.Now(), upcomingMaintenanceHorizon),
+		},
+	}
+}
+
+// UpcomingMaintenance lists windows active at now plus the next occurrence of
+// every window starting within horizon, ordered by start time.
+func (e *Engine) UpcomingMaintenance(now time.Time, horizon time.Duration) []MaintenanceOccurrence {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	out := []MaintenanceOccurrence{}
+	for _, m := range e.maintenance {
+		if occ, ok := m.activeAt(now); ok {
+			out = append(out, occ)
+		}
+		if occ, ok := m.nextAfter(now, horizon); ok {
+			out = append(out, occ)
+		}
+	}
+	sortOccurrences(out)
+	return out
+}
+
+// InMaintenance reports the window covering adapterName at t, if any.
+func (e *Engine) InMaintenance(adapterName string, t time.Time) (MaintenanceOccurrence, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.maintenanceAtLocked(strings.TrimSpace(adapterName), t)
+}
+
+func (e *Engine) maintenanceAtLocked(adapterName string, t time.Time) (MaintenanceOccurrence, bool) {
+	for _, m := range e.maintenance {
+		if m.window.Adapter != adapterName {
+			continue
+		}
+		if occ, ok := m.activeAt(t); ok {
+			return occ, true
+		}
 	}
+	return MaintenanceOccurrence{}, false
+}
+
+func (e *Engine) inMaintenanceLocked(adapterName string, t time.Time) bool {
+	_, ok := e.maintenanceAtLocked(adapterName, t)
+	return ok
 }
 
 func (e *Engine) allowed(st *adapterState, model string, wantStream, needTool bool, now time.Time) bool {
 	if !st.cooldownUntil.IsZero() && now.Before(st.cooldownUntil) {
 		return false
 	}
+	if e.inMaintenanceLocked(st.name, now) {
+		return false
+	}
 	model = strings.TrimSpace(model)
 	if model == "" {
 		return true
@@ -329,6 +517,10 @@ func (e *Engine) allowed(st *adapterState, model string, wantStream, needTool bo
 
 func (e *Engine) score(st *adapterState, model string, wantStream, needTool bool, now time.Time) float64 {
 	score := 100.0
+	if e.inMaintenanceLocked(st.name, now) {
+		// Below cooldown so the non-strict fallback still tries it last.
+		return -2000
+	}
 	if !st.cooldownUntil.IsZero() && now.Before(st.cooldownUntil) {
 		return -1000
 	}
@@ -340,6 +532,14 @@ func (e *Engine) score(st *adapterState, model string, wantStream, needTool bool
 		}
 		score -= penalty
 	}
+	if slo := e.cfg.FirstTokenSLO; slo > 0 && st.firstToken > slo {
+		// Up to 40 points once streams take twice the SLO to start.
+		penalty := 40 * (float64(st.firstToken)/float64(slo) - 1)
+		if penalty > 40 {
+			penalty = 40
+		}
+		score -= penalty
+	}
 	total := st.successes + st.failures
 	if total > 0 {
 		successRate := float64(st.successes) / float64(total)
@@ -1,6 +1,7 @@
 package scheduler
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"strings"
@@ -14,6 +15,7 @@ func NewFromEnv(adapterNames []string) (*Engine, error) {
 		StrictProbeGate:    envBool("SCHEDULER_STRICT_PROBE_GATE", false),
 		RequireStreamProbe: envBool("SCHEDULER_REQUIRE_STREAM_PROBE", false),
 		RequireToolProbe:   envBool("SCHEDULER_REQUIRE_TOOL_PROBE", false),
+		FirstTokenSLO:      envDuration("SCHEDULER_FIRST_TOKEN_SLO", 0),
 	}
 	if cfg.FailureThreshold <= 0 {
 		return nil, fmt.Errorf("SCHEDULER_FAILURE_THRESHOLD must be > 0")
@@ -21,6 +23,14 @@ func NewFromEnv(adapterNames []string) (*Engine, error) {
 	if cfg.Cooldown <= 0 {
 		return nil, fmt.Errorf("SCHEDULER_COOLDOWN must be > 0")
 	}
+	if raw := strings.TrimSpace(os.Getenv("SCHEDULER_MAINTENANCE_WINDOWS")); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &cfg.MaintenanceWindows); err != nil {
+			return nil, fmt.Errorf("invalid SCHEDULER_MAINTENANCE_WINDOWS: %w", err)
+		}
+		if err := ValidateMaintenanceWindows(cfg.MaintenanceWindows); err != nil {
+			return nil, fmt.Errorf("invalid SCHEDULER_MAINTENANCE_WINDOWS: %w", err)
+		}
+	}
 	return NewEngine(cfg, adapterNames), nil
 }
 
@@ -0,0 +1,264 @@
+package scheduler
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	maxMaintenanceDuration = 7 * 24 * time.Hour
+	// upcomingMaintenanceHorizon bounds how far ahead admin views look for
+	// the next occurrence of each recurring window.
+	upcomingMaintenanceHorizon = 7 * 24 * time.Hour
+)
+
+// MaintenanceWindow takes an adapter out of rotation, either once
+// (StartsAt..EndsAt) or on a recurring 5-field cron schedule lasting
+// DurationMS from each start. Cron schedules are evaluated in Timezone
+// (UTC by default).
+type MaintenanceWindow struct {
+	ID         string    `json:"id"`
+	Adapter    string    `json:"adapter"`
+	Cron       string    `json:"cron,omitempty"`
+	DurationMS int64     `json:"duration_ms,omitempty"`
+	Timezone   string    `json:"timezone,omitempty"`
+	StartsAt   time.Time `json:"starts_at,omitempty"`
+	EndsAt     time.Time `json:"ends_at,omitempty"`
+	Reason     string    `json:"reason,omitempty"`
+}
+
+// MaintenanceOccurrence is one concrete start/end of a window.
+type MaintenanceOccurrence struct {
+	WindowID string    `json:"window_id"`
+	Adapter  string    `json:"adapter"`
+	StartsAt time.Time `json:"starts_at"`
+	EndsAt   time.Time `json:"ends_at"`
+	Active   bool      `json:"active"`
+	Reason   string    `json:"reason,omitempty"`
+}
+
+type maintenanceEntry struct {
+	window   MaintenanceWindow
+	spec     *cronSpec
+	loc      *time.Location
+	duration time.Duration
+}
+
+// ValidateMaintenanceWindows reports the first invalid window, if any.
+func ValidateMaintenanceWindows(windows []MaintenanceWindow) error {
+	_, _, err := compileMaintenanceWindows(windows)
+	return err
+}
+
+func compileMaintenanceWindows(windows []MaintenanceWindow) ([]MaintenanceWindow, []maintenanceEntry, error) {
+	normalized := make([]MaintenanceWindow, 0, len(windows))
+	entries := make([]maintenanceEntry, 0, len(windows))
+	seen := map[string]bool{}
+	for i, w := range windows {
+		w.ID = strings.TrimSpace(w.ID)
+		if w.ID == "" {
+			w.ID = "mw_" + strconv.Itoa(i+1)
+		}
+		if seen[w.ID] {
+			return nil, nil, fmt.Errorf("duplicate maintenance window id %q", w.ID)
+		}
+		seen[w.ID] = true
+		w.Adapter = strings.TrimSpace(w.Adapter)
+		w.Cron = strings.TrimSpace(w.Cron)
+		w.Timezone = strings.TrimSpace(w.Timezone)
+		w.Reason = strings.TrimSpace(w.Reason)
+		if w.Adapter == "" {
+			return nil, nil, fmt.Errorf("maintenance window %s: adapter is required", w.ID)
+		}
+		entry := maintenanceEntry{loc: time.UTC}
+		if w.Cron != "" {
+			spec, err := parseCron(w.Cron)
+			if err != nil {
+				return nil, nil, fmt.Errorf("maintenance window %s: %w", w.ID, err)
+			}
+			entry.spec = spec
+			entry.duration = time.Duration(w.DurationMS) * time.Millisecond
+			if entry.duration < time.Minute || entry.duration > maxMaintenanceDuration {
+				return nil, nil, fmt.Errorf("maintenance window %s: duration_ms must be between 1 minute and 7 days", w.ID)
+			}
+			if w.Timezone != "" {
+				loc, err := time.LoadLocation(w.Timezone)
+				if err != nil {
+					return nil, nil, fmt.Errorf("maintenance window %s: invalid timezone %q", w.ID, w.Timezone)
+				}
+				entry.loc = loc
+			}
+		} else {
+			if w.StartsAt.IsZero() || !w.EndsAt.After(w.StartsAt) {
+				return nil, nil, fmt.Errorf("maintenance window %s: cron or starts_at < ends_at is required", w.ID)
+			}
+		}
+		entry.window = w
+		normalized = append(normalized, w)
+		entries = append(entries, entry)
+	}
+	return normalized, entries, nil
+}
+
+// activeAt returns the occurrence covering t, if any.
+func (m maintenanceEntry) activeAt(t time.Time) (MaintenanceOccurrence, bool) {
+	if m.spec == nil {
+		if !t.Before(m.window.StartsAt) && t.Before(m.window.EndsAt) {
+			return m.occurrence(m.window.StartsAt, m.window.EndsAt, true), true
+		}
+		return MaintenanceOccurrence{}, false
+	}
+	local := t.In(m.loc).Truncate(time.Minute)
+	earliest := t.Add(-m.duration)
+	for start := local; start.After(earliest); start = start.Add(-time.Minute) {
+		if m.spec.matches(start) {
+			return m.occurrence(start, start.Add(m.duration), true), true
+		}
+	}
+	return MaintenanceOccurrence{}, false
+}
+
+// nextAfter returns the first occurrence starting after t within horizon.
+func (m maintenanceEntry) nextAfter(t time.Time, horizon time.Duration) (MaintenanceOccurrence, bool) {
+	if m.spec == nil {
+		if m.window.StartsAt.After(t) && m.window.StartsAt.Before(t.Add(horizon)) {
+			return m.occurrence(m.window.StartsAt, m.window.EndsAt, false), true
+		}
+		return MaintenanceOccurrence{}, false
+	}
+	start := t.In(m.loc).Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(horizon)
+	for start.Before(limit) {
+		if !m.spec.matchesDay(start) {
+			start = time.Date(start.Year(), start.Month(), start.Day()+1, 0, 0, 0, 0, m.loc)
+			continue
+		}
+		if m.spec.matches(start) {
+			return m.occurrence(start, start.Add(m.duration), false), true
+		}
+		start = start.Add(time.Minute)
+	}
+	return MaintenanceOccurrence{}, false
+}
+
+func (m maintenanceEntry) occurrence(start, end time.Time, active bool) MaintenanceOccurrence {
+	return MaintenanceOccurrence{
+		WindowID: m.window.ID,
+		Adapter:  m.window.Adapter,
+		StartsAt: start.UTC(),
+		EndsAt:   end.UTC(),
+		Active:   active,
+		Reason:   m.window.Reason,
+	}
+}
+
+func sortOccurrences(in []MaintenanceOccurrence) {
+	sort.SliceStable(in, func(i, j int) bool {
+		if !in[i].StartsAt.Equal(in[j].StartsAt) {
+			return in[i].StartsAt.Before(in[j].StartsAt)
+		}
+		return in[i].WindowID < in[j].WindowID
+	})
+}
+
+// cronSpec is a standard 5-field cron expression: minute hour day-of-month
+// month day-of-week. Fields accept *, numbers, a-b ranges, lists and /step.
+type cronSpec struct {
+	minute, hour, dom, month, dow uint64
+	domAny, dowAny                bool
+}
+
+func parseCron(expr string) (*cronSpec, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron %q must have 5 fields", expr)
+	}
+	var spec cronSpec
+	var err error
+	if spec.minute, err = parseCronField(fields[0], 0, 59); err != nil {
+		return nil, err
+	}
+	if spec.hour, err = parseCronField(fields[1], 0, 23); err != nil {
+		return nil, err
+	}
+	if spec.dom, err = parseCronField(fields[2], 1, 31); err != nil {
+		return nil, err
+	}
+	if spec.month, err = parseCronField(fields[3], 1, 12); err != nil {
+		return nil, err
+	}
+	if spec.dow, err = parseCronField(fields[4], 0, 7); err != nil {
+		return nil, err
+	}
+	if spec.dow&(1<<7) != 0 {
+		spec.dow |= 1
+	}
+	spec.domAny = fields[2] == "*"
+	spec.dowAny = fields[4] == "*"
+	return &spec, nil
+}
+
+func parseCronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid cron step in %q", part)
+			}
+			rangePart, step = part[:i], n
+		}
+		lo, hi := min, max
+		if rangePart != "*" {
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err error
+			if lo, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, fmt.Errorf("invalid cron value %q", part)
+			}
+			hi = lo
+			if len(bounds) == 2 {
+				if hi, err = strconv.Atoi(bounds[1]); err != nil {
+					return 0, fmt.Errorf("invalid cron value %q", part)
+				}
+			} else if step > 1 {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("cron value %q out of range %d-%d", part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func (c *cronSpec) matchesDay(t time.Time) bool {
+	if c.month&(1<<uint(t.Month())) == 0 {
+		return false
+	}
+	domOK := c.dom&(1<<uint(t.Day())) != 0
+	dowOK := c.dow&(1<<uint(t.Weekday())) != 0
+	// Like cron, a restricted day-of-month and day-of-week match either.
+	switch {
+	case c.domAny && c.dowAny:
+		return true
+	case c.domAny:
+		return dowOK
+	case c.dowAny:
+		return domOK
+	default:
+		return domOK || dowOK
+	}
+}
+
+func (c *cronSpec) matches(t time.Time) bool {
+	return c.minute&(1<<uint(t.Minute())) != 0 &&
+		c.hour&(1<<uint(t.Hour())) != 0 &&
+		c.matchesDay(t)
+}
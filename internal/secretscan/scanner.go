@@ -0,0 +1,288 @@
+// Package secretscan finds credentials in free text (tool results, run
+// records, event payloads) and masks them before they travel any further.
+package secretscan
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var ErrInvalidConfig = errors.New("invalid secret scan config")
+
+// Pattern is an extra detector. Mask defaults to [SECRET:<name>]. When the
+// expression has a group named "secret" only that group is masked, so a
+// pattern can anchor on a key name without hiding it.
+type Pattern struct {
+	Name  string `json:"name"`
+	Regex string `json:"regex"`
+	Mask  string `json:"mask,omitempty"`
+}
+
+// Config controls the scanner. ExemptTools lists tools whose results are
+// passed through untouched; MaskLogs also masks run log records and event
+// payloads.
+type Config struct {
+	Enabled         bool      `json:"enabled"`
+	DisableBuiltins bool      `json:"disable_builtins,omitempty"`
+	Patterns        []Pattern `json:"patterns,omitempty"`
+	ExemptTools     []string  `json:"exempt_tools,omitempty"`
+	MaskLogs        bool      `json:"mask_logs"`
+}
+
+// DefaultConfig turns on the built-in detectors for tool results and logs.
+func DefaultConfig() Config {
+	return Config{Enabled: true, MaskLogs: true}
+}
+
+// Hit counts the matches of one detector.
+type Hit struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+type detector struct {
+	name    string
+	mask    string
+	pattern *regexp.Regexp
+}
+
+var builtinDetectors = []detector{
+	{name: "private_key", pattern: regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`)},
+	{name: "aws_access_key", pattern: regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{name: "aws_secret_key", pattern: regexp.MustCompile(`(?i)aws_?secret_?access_?key["']?\s*[:=]\s*["']?(?P<secret>[A-Za-z0-9/+=]{40})`)},
+	{name: "github_token", pattern: regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{40,})\b`)},
+	{name: "slack_token", pattern: regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9\-]{10,}\b`)},
+	{name: "google_api_key", pattern: regexp.MustCompile(`\bAIza[0-9A-Za-z_\-]{35}\b`)},
+	{name: "stripe_key", pattern: regexp.MustCompile(`\b[rs]k_(?:live|test)_[A-Za-z0-9]{16,}\b`)},
+	{name: "api_key", pattern: regexp.MustCompile(`\bsk-(?:ant-)?[A-Za-z0-9_\-]{20,}\b`)},
+	{name: "jwt", pattern: regexp.MustCompile(`\beyJ[A-Za-z0-9_\-]{8,}\.eyJ[A-Za-z0-9_\-]{8,}\.[A-Za-z0-9_\-]{8,}\b`)},
+	{name: "bearer_token", pattern: regexp.MustCompile(`(?i)\bbearer\s+(?P<secret>[A-Za-z0-9._~+/\-]{20,}=*)`)},
+	{name: "url_credentials", pattern: regexp.MustCompile(`\b[a-z][a-z0-9+.\-]*://[^\s:/@]+:(?P<secret>[^\s@/]+)@`)},
+	{name: "password_assignment", pattern: regexp.MustCompile(`(?i)\b(?:password|passwd|secret|api_?key|access_?token|client_?secret)["']?\s*[:=]\s*["']?(?P<secret>[^\s"',;]{8,})`)},
+}
+
+// Builtins lists the built-in detector names in the order they run.
+func Builtins() []string {
+	out := make([]string, 0, len(builtinDetectors))
+	for _, d := range builtinDetectors {
+		out = append(out, d.name)
+	}
+	return out
+}
+
+// Scanner masks secrets. It is safe for concurrent use; a nil scanner
+// masks nothing.
+type Scanner struct {
+	mu        sync.RWMutex
+	cfg       Config
+	detectors []detector
+	exempt    map[string]struct{}
+}
+
+func New(cfg Config) (*Scanner, error) {
+	s := &Scanner{}
+	if err := s.Update(cfg); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// NewFromEnv starts from DefaultConfig, replaced by SECRET_SCAN_JSON when
+// set. SECRET_SCAN_ENABLED=false turns scanning off and
+// SECRET_SCAN_EXEMPT_TOOLS adds comma-separated tool names.
+func NewFromEnv() (*Scanner, error) {
+	cfg := DefaultConfig()
+	if raw := strings.TrimSpace(os.Getenv("SECRET_SCAN_JSON")); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &cfg); err != nil {
+			return nil, fmt.Errorf("invalid SECRET_SCAN_JSON: %w", err)
+		}
+	}
+	if v := strings.TrimSpace(os.Getenv("SECRET_SCAN_ENABLED")); v != "" {
+		cfg.Enabled = v == "1" || strings.EqualFold(v, "true") || strings.EqualFold(v, "yes") || strings.EqualFold(v, "on")
+	}
+	for _, name := range strings.Split(os.Getenv("SECRET_SCAN_EXEMPT_TOOLS"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			cfg.ExemptTools = append(cfg.ExemptTools, name)
+		}
+	}
+	return New(cfg)
+}
+
+// Config returns the current configuration.
+func (s *Scanner) Config() Config {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := s.cfg
+	out.Patterns = append([]Pattern(nil), s.cfg.Patterns...)
+	out.ExemptTools = append([]string(nil), s.cfg.ExemptTools...)
+	return out
+}
+
+// Update validates and installs cfg; the running config is kept on error.
+func (s *Scanner) Update(cfg Config) error {
+	var detectors []detector
+	if !cfg.DisableBuiltins {
+		detectors = append(detectors, builtinDetectors...)
+	}
+	for i, p := range cfg.Patterns {
+		p.Name = strings.TrimSpace(p.Name)
+		if p.Name == "" {
+			return fmt.Errorf("%w: patterns[%d]: name is required", ErrInvalidConfig, i)
+		}
+		re, err := regexp.Compile(p.Regex)
+		if err != nil || p.Regex == "" {
+			return fmt.Errorf("%w: patterns[%d]: regex must be a valid regular expression", ErrInvalidConfig, i)
+		}
+		cfg.Patterns[i] = p
+		detectors = append(detectors, detector{name: p.Name, mask: p.Mask, pattern: re})
+	}
+	exempt := make(map[string]struct{}, len(cfg.ExemptTools))
+	tools := make([]string, 0, len(cfg.ExemptTools))
+	for _, name := range cfg.ExemptTools {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if _, dup := exempt[name]; !dup {
+			exempt[name] = struct{}{}
+			tools = append(tools, name)
+		}
+	}
+	cfg.ExemptTools = tools
+	s.mu.Lock()
+	s.cfg = cfg
+	s.detectors = detectors
+	s.exempt = exempt
+	s.mu.Unlock()
+	return nil
+}
+
+// Exempt reports whether results of the named tool skip scanning.
+func (s *Scanner) Exempt(tool string) bool {
+	if s == nil {
+		return true
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.exempt[strings.ToLower(strings.TrimSpace(tool))]
+	return ok
+}
+
+// Enabled reports whether Mask does anything.
+func (s *Scanner) Enabled() bool {
+	if s == nil {
+		return false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cfg.Enabled && len(s.detectors) > 0
+}
+
+// MasksLogs reports whether run records and events are masked too.
+func (s *Scanner) MasksLogs() bool {
+	if !s.Enabled() {
+		return false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cfg.MaskLogs
+}
+
+// Mask returns text with every detected secret replaced, and the hits per
+// detector sorted by name.
+func (s *Scanner) Mask(text string) (string, []Hit) {
+	if !s.Enabled() || strings.TrimSpace(text) == "" {
+		return text, nil
+	}
+	s.mu.RLock()
+	detectors := s.detectors
+	s.mu.RUnlock()
+
+	// Spans are collected on the original text so a mask is never matched
+	// again; earlier detectors win overlaps.
+	var spans []span
+	for _, d := range detectors {
+		spans = appendSpans(spans, d, text)
+	}
+	if len(spans) == 0 {
+		return text, nil
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+	counts := map[string]int{}
+	var b strings.Builder
+	last := 0
+	for _, sp := range spans {
+		b.WriteString(text[last:sp.start])
+		b.WriteString(sp.mask)
+		last = sp.end
+		counts[sp.name]++
+	}
+	b.WriteString(text[last:])
+
+	hits := make([]Hit, 0, len(counts))
+	for name, n := range counts {
+		hits = append(hits, Hit{Name: name, Count: n})
+	}
+	sort.Slice(hits, func(i, j int) bool { return hits[i].Name < hits[j].Name })
+	return b.String(), hits
+}
+
+// MaskValue masks every string inside v, descending into maps and slices
+// decoded from JSON. Other values are returned unchanged.
+func (s *Scanner) MaskValue(v any) any {
+	switch val := v.(type) {
+	case string:
+		out, _ := s.Mask(val)
+		return out
+	case map[string]any:
+		out := make(map[string]any, len(val))
+		for k, item := range val {
+			out[k] = s.MaskValue(item)
+		}
+		return out
+	case []any:
+		out := make([]any, len(val))
+		for i, item := range val {
+			out[i] = s.MaskValue(item)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+type span struct {
+	start, end int
+	name, mask string
+}
+
+func appendSpans(spans []span, d detector, text string) []span {
+	mask := d.mask
+	if mask == "" {
+		mask = "[SECRET:" + d.name + "]"
+	}
+	group := d.pattern.SubexpIndex("secret")
+	for _, m := range d.pattern.FindAllStringSubmatchIndex(text, -1) {
+		start, end := m[0], m[1]
+		if group > 0 && m[2*group] >= 0 {
+			start, end = m[2*group], m[2*group+1]
+		}
+		overlaps := false
+		for _, sp := range spans {
+			if start < sp.end && sp.start < end {
+				overlaps = true
+				break
+			}
+		}
+		if !overlaps {
+			spans = append(spans, span{start: start, end: end, name: d.name, mask: mask})
+		}
+	}
+	return spans
+}
@@ -0,0 +1,410 @@
+package servertools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"ccgateway/internal/toolruntime"
+	"ccgateway/internal/workspace"
+)
+
+// Names Claude Code declares the built-in text editor under, by version.
+const (
+	TextEditorName       = "str_replace_based_edit_tool"
+	LegacyTextEditorName = "str_replace_editor"
+)
+
+// stateKeyEditorUndo prefixes the per-path undo stacks kept in session state.
+const stateKeyEditorUndo = "text_editor.undo:"
+
+// editHistory is one path's undo stack; nil entries mark files the edit
+// created.
+type editHistory struct {
+	previous []*string
+}
+
+// BuiltinToolsConfig bounds the server-side bash tool. Bash and the text
+// editor both work in the session's workspace directory. Commands run on
+// the same backends as code_execution: BackendContainer (the default) runs
+// each one in a throwaway container without network access, with the
+// workspace mounted at /workspace; BackendSubprocess only confines them
+// with rlimits, so they run as the gateway's user and can read its
+// environment and files outside the workspace.
+type BuiltinToolsConfig struct {
+	Shell            string `json:"shell,omitempty"`
+	TimeoutMS        int    `json:"timeout_ms"`
+	MaxOutputBytes   int    `json:"max_output_bytes"`
+	Backend          string `json:"backend"`
+	ContainerRuntime string `json:"container_runtime,omitempty"`
+	Image            string `json:"image,omitempty"`
+	CPUSeconds       int    `json:"cpu_seconds"`
+	MemoryMB         int    `json:"memory_mb"`
+}
+
+// NewBuiltinTools returns the bash tool and the text editor under both of
+// its names, all working in ws.
+func NewBuiltinTools(ws *workspace.Manager, cfg BuiltinToolsConfig) []Tool {
+	if strings.TrimSpace(cfg.Shell) == "" {
+		cfg.Shell = "bash"
+	}
+	if cfg.TimeoutMS <= 0 {
+		cfg.TimeoutMS = 30000
+	}
+	if cfg.MaxOutputBytes <= 0 {
+		cfg.MaxOutputBytes = 64 << 10
+	}
+	if cfg.Backend = strings.ToLower(strings.TrimSpace(cfg.Backend)); cfg.Backend == "" {
+		cfg.Backend = BackendContainer
+	}
+	if strings.TrimSpace(cfg.ContainerRuntime) == "" {
+		cfg.ContainerRuntime = "docker"
+	}
+	if strings.TrimSpace(cfg.Image) == "" {
+		cfg.Image = "debian:stable-slim"
+	}
+	if cfg.CPUSeconds <= 0 {
+		cfg.CPUSeconds = (cfg.TimeoutMS + 999) / 1000
+	}
+	if cfg.MemoryMB <= 0 {
+		cfg.MemoryMB = 2048
+	}
+	return []Tool{
+		&Bash{ws: ws, cfg: cfg},
+		&TextEditor{ws: ws, name: TextEditorName},
+		&TextEditor{ws: ws, name: LegacyTextEditorName},
+	}
+}
+
+// NewBuiltinToolsFromEnv returns the tools when BUILTIN_TOOLS_ENABLED is
+// true, reading the related BUILTIN_TOOLS_* settings; BUILTIN_TOOLS_BACKEND
+// is container (default) or subprocess, which must be asked for. Executing
+// model-issued shell commands is opt-in and needs a workspace.
+func NewBuiltinToolsFromEnv(ws *workspace.Manager) ([]Tool, error) {
+	raw := strings.TrimSpace(os.Getenv("BUILTIN_TOOLS_ENABLED"))
+	if raw == "" {
+		return nil, nil
+	}
+	enabled, err := strconv.ParseBool(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid BUILTIN_TOOLS_ENABLED: %q", raw)
+	}
+	if !enabled {
+		return nil, nil
+	}
+	if ws == nil {
+		return nil, fmt.Errorf("BUILTIN_TOOLS_ENABLED requires WORKSPACE_ROOT")
+	}
+	cfg := BuiltinToolsConfig{
+		Shell:            os.Getenv("BUILTIN_TOOLS_SHELL"),
+		Backend:          os.Getenv("BUILTIN_TOOLS_BACKEND"),
+		ContainerRuntime: os.Getenv("BUILTIN_TOOLS_CONTAINER_RUNTIME"),
+		Image:            os.Getenv("BUILTIN_TOOLS_IMAGE"),
+	}
+	switch backend := strings.ToLower(strings.TrimSpace(cfg.Backend)); backend {
+	case "", BackendSubprocess, BackendContainer:
+	default:
+		return nil, fmt.Errorf("invalid BUILTIN_TOOLS_BACKEND: %q", cfg.Backend)
+	}
+	for key, dst := range map[string]*int{
+		"BUILTIN_TOOLS_TIMEOUT_MS":       &cfg.TimeoutMS,
+		"BUILTIN_TOOLS_MAX_OUTPUT_BYTES": &cfg.MaxOutputBytes,
+		"BUILTIN_TOOLS_CPU_SECONDS":      &cfg.CPUSeconds,
+		"BUILTIN_TOOLS_MEMORY_MB":        &cfg.MemoryMB,
+	} {
+		raw := strings.TrimSpace(os.Getenv(key))
+		if raw == "" {
+			continue
+		}
+		v, err := strconv.Atoi(raw)
+		if err != nil || v < 0 {
+			return nil, fmt.Errorf("invalid %s: %q", key, raw)
+		}
+		*dst = v
+	}
+	return NewBuiltinTools(ws, cfg), nil
+}
+
+// Bash runs the built-in bash tool's commands in the session workspace.
+// Unlike Claude Code's own shell, every command starts a fresh shell in the
+// workspace directory.
+type Bash struct {
+	ws      *workspace.Manager
+	cfg     BuiltinToolsConfig
+	counter atomic.Uint64
+}
+
+func (b *Bash) Name() string { return "bash" }
+
+func (b *Bash) Config() BuiltinToolsConfig { return b.cfg }
+
+func (b *Bash) Execute(ctx context.Context, call toolruntime.Call) (toolruntime.Result, error) {
+	touchWorkspace(b.ws, call)
+	if restart, _ := call.Input["restart"].(bool); restart {
+		return toolruntime.Result{Content: "tool has been restarted."}, nil
+	}
+	command, _ := call.Input["command"].(string)
+	if strings.TrimSpace(command) == "" {
+		return toolruntime.Result{IsError: true, Content: "bash requires command"}, nil
+	}
+	dir, err := b.ws.Dir(call.SessionID)
+	if err != nil {
+		return toolruntime.Result{}, err
+	}
+	timeout := time.Duration(b.cfg.TimeoutMS) * time.Millisecond
+	runCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	var containerName string
+	switch b.cfg.Backend {
+	case BackendContainer:
+		containerName = fmt.Sprintf("cc-bash-%d-%d", os.Getpid(), b.counter.Add(1))
+		mounts := []string{
+			"--volume", dir + ":/workspace",
+			"--workdir", "/workspace",
+			"--user", fmt.Sprintf("%d:%d", os.Getuid(), os.Getgid()),
+			"--env", "HOME=/workspace",
+		}
+		cmd = exec.CommandContext(runCtx, b.cfg.ContainerRuntime, containerRunArgs(containerName, b.cfg.Image, b.cfg.CPUSeconds, b.cfg.MemoryMB, mounts, b.cfg.Shell, "-c", command)...)
+	default:
+		cmd = exec.CommandContext(runCtx, "sh", rlimitShellArgs(b.cfg.CPUSeconds, b.cfg.MemoryMB, b.cfg.Shell, "-c", command)...)
+		cmd.Dir = dir
+		cmd.Env = []string{
+			"PATH=" + os.Getenv("PATH"),
+			"HOME=" + dir,
+			"TMPDIR=" + dir,
+			"LANG=C.UTF-8",
+		}
+	}
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		if containerName != "" {
+			_ = exec.Command(b.cfg.ContainerRuntime, "kill", containerName).Run()
+		}
+		if cmd.Process == nil {
+			return nil
+		}
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = time.Second
+	output := &cappedBuffer{limit: b.cfg.MaxOutputBytes}
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	runErr := cmd.Run()
+	text := output.String()
+	if output.truncated {
+		text += "\n[output truncated]"
+	}
+	if runCtx.Err() == context.DeadlineExceeded {
+		return toolruntime.Result{IsError: true, Content: strings.TrimSpace(text + fmt.Sprintf("\ncommand timed out after %dms", timeout.Milliseconds()))}, nil
+	}
+	if runErr != nil {
+		if exitErr, ok := runErr.(*exec.ExitError); ok {
+			return toolruntime.Result{IsError: true, Content: strings.TrimSpace(text + fmt.Sprintf("\nexit status %d", exitErr.ExitCode()))}, nil
+		}
+		return toolruntime.Result{IsError: true, Content: fmt.Sprintf("bash failed: %v", runErr)}, nil
+	}
+	if quota := b.ws.Config().QuotaBytes; quota > 0 {
+		if used, err := b.ws.Usage(call.SessionID); err == nil && used > quota {
+			return toolruntime.Result{IsError: true, Content: strings.TrimSpace(text + fmt.Sprintf("\nthe workspace uses %d bytes, over its %d byte quota; remove files", used, quota))}, nil
+		}
+	}
+	return toolruntime.Result{Content: text}, nil
+}
+
+// TextEditor implements the built-in text editor's view, create,
+// str_replace, insert and undo_edit commands on the session workspace.
+type TextEditor struct {
+	ws   *workspace.Manager
+	name string
+}
+
+func (e *TextEditor) Name() string { return e.name }
+
+func (e *TextEditor) Execute(_ context.Context, call toolruntime.Call) (toolruntime.Result, error) {
+	touchWorkspace(e.ws, call)
+	command, _ := call.Input["command"].(string)
+	rawPath, _ := call.Input["path"].(string)
+	path, err := e.ws.Resolve(call.SessionID, rawPath)
+	if err != nil {
+		return toolruntime.Result{IsError: true, Content: err.Error()}, nil
+	}
+	var out string
+	switch command {
+	case "view":
+		out, err = e.view(path, call.Input["view_range"])
+	case "create":
+		text, _ := call.Input["file_text"].(string)
+		if err = e.save(call, path, rawPath, text); err == nil {
+			out = "File created successfully at: " + rawPath
+		}
+	case "str_replace":
+		out, err = e.replace(call, path, rawPath)
+	case "insert":
+		out, err = e.insert(call, path, rawPath)
+	case "undo_edit":
+		out, err = e.undo(call, path, rawPath)
+	default:
+		err = fmt.Errorf("unknown text editor command %q", command)
+	}
+	if err != nil {
+		return toolruntime.Result{IsError: true, Content: err.Error()}, nil
+	}
+	return toolruntime.Result{Content: out}, nil
+}
+
+func (e *TextEditor) view(path string, viewRange any) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		var entries []string
+		root := path
+		_ = filepath.WalkDir(root, func(p string, d os.DirEntry, err error) error {
+			if err != nil || p == root {
+				return nil
+			}
+			rel, _ := filepath.Rel(root, p)
+			if strings.HasPrefix(d.Name(), ".") {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if d.IsDir() {
+				rel += "/"
+				if strings.Count(rel, "/") >= 2 {
+					entries = append(entries, rel)
+					return filepath.SkipDir
+				}
+			}
+			entries = append(entries, rel)
+			return nil
+		})
+		sort.Strings(entries)
+		return strings.Join(entries, "\n"), nil
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	lines := strings.Split(string(raw), "\n")
+	start, end := 1, len(lines)
+	if list, ok := viewRange.([]any); ok && len(list) == 2 {
+		s, _ := intFromAny(list[0])
+		t, _ := intFromAny(list[1])
+		if s < 1 || s > len(lines) || (t != -1 && t < s) {
+			return "", fmt.Errorf("invalid view_range [%d, %d] for a file of %d lines", s, t, len(lines))
+		}
+		start = s
+		if t != -1 && t < end {
+			end = t
+		}
+	}
+	var b strings.Builder
+	for i := start; i <= end; i++ {
+		fmt.Fprintf(&b, "%6d\t%s\n", i, lines[i-1])
+	}
+	return b.String(), nil
+}
+
+// save writes text to path through the workspace, so the quota applies,
+// and remembers the previous content for undo.
+func (e *TextEditor) save(call toolruntime.Call, path, rawPath, text string) error {
+	prev, err := os.ReadFile(path)
+	existed := err == nil
+	if err := e.ws.Write(call.SessionID, rawPath, []byte(text)); err != nil {
+		return err
+	}
+	if call.State != nil {
+		key := stateKeyEditorUndo + path
+		history, _ := call.State.Get(key)
+		h, ok := history.(*editHistory)
+		if !ok {
+			h = &editHistory{}
+			call.State.Set(key, h)
+		}
+		var entry *string
+		if existed {
+			s := string(prev)
+			entry = &s
+		}
+		h.previous = append(h.previous, entry)
+	}
+	return nil
+}
+
+func (e *TextEditor) replace(call toolruntime.Call, path, rawPath string) (string, error) {
+	oldStr, _ := call.Input["old_str"].(string)
+	newStr, _ := call.Input["new_str"].(string)
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	content := string(raw)
+	switch n := strings.Count(content, oldStr); {
+	case oldStr == "" || n == 0:
+		return "", fmt.Errorf("no match for old_str in %s", rawPath)
+	case n > 1:
+		return "", fmt.Errorf("old_str matches %d times in %s; make it unique", n, rawPath)
+	}
+	if err := e.save(call, path, rawPath, strings.Replace(content, oldStr, newStr, 1)); err != nil {
+		return "", err
+	}
+	return "The file " + rawPath + " has been edited.", nil
+}
+
+func (e *TextEditor) insert(call toolruntime.Call, path, rawPath string) (string, error) {
+	newStr, _ := call.Input["new_str"].(string)
+	line, ok := intFromAny(call.Input["insert_line"])
+	if !ok {
+		return "", fmt.Errorf("insert requires insert_line")
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	lines := strings.Split(string(raw), "\n")
+	if line < 0 || line > len(lines) {
+		return "", fmt.Errorf("insert_line %d is outside the file's %d lines", line, len(lines))
+	}
+	out := append(append(append([]string{}, lines[:line]...), strings.Split(newStr, "\n")...), lines[line:]...)
+	if err := e.save(call, path, rawPath, strings.Join(out, "\n")); err != nil {
+		return "", err
+	}
+	return "The file " + rawPath + " has been edited.", nil
+}
+
+func (e *TextEditor) undo(call toolruntime.Call, path, rawPath string) (string, error) {
+	if call.State == nil {
+		return "", fmt.Errorf("no edit history for %s", rawPath)
+	}
+	history, _ := call.State.Get(stateKeyEditorUndo + path)
+	h, ok := history.(*editHistory)
+	if !ok || len(h.previous) == 0 {
+		return "", fmt.Errorf("no edit history for %s", rawPath)
+	}
+	last := h.previous[len(h.previous)-1]
+	h.previous = h.previous[:len(h.previous)-1]
+	if last == nil {
+		if err := e.ws.Remove(call.SessionID, rawPath); err != nil {
+			return "", err
+		}
+		return "Last edit to " + rawPath + " undone; the file was removed.", nil
+	}
+	if err := e.ws.Write(call.SessionID, rawPath, []byte(*last)); err != nil {
+		return "", err
+	}
+	return "Last edit to " + rawPath + " undone.", nil
+}
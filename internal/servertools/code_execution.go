@@ -0,0 +1,426 @@
+package servertools
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"ccgateway/internal/toolruntime"
+)
+
+const (
+	// BackendSubprocess runs snippets as local child processes confined by
+	// rlimits, a scratch working directory and a scrubbed environment.
+	BackendSubprocess = "subprocess"
+	// BackendContainer runs each snippet in a throwaway container without
+	// network access, via docker or a compatible CLI.
+	BackendContainer = "container"
+)
+
+const (
+	LanguagePython     = "python"
+	LanguageJavaScript = "javascript"
+)
+
+// runBudgetIdle is how long the usage of a gateway run is kept after its
+// last execution.
+const runBudgetIdle = time.Hour
+
+// CodeExecutionConfig selects the sandbox backend and the budgets. TimeoutMS,
+// CPUSeconds, MemoryMB and MaxOutputBytes bound each execution; the Run*
+// fields bound all executions of one gateway run together, 0 meaning
+// unlimited.
+type CodeExecutionConfig struct {
+	Backend          string `json:"backend"`
+	PythonBin        string `json:"python_bin,omitempty"`
+	NodeBin          string `json:"node_bin,omitempty"`
+	ContainerRuntime string `json:"container_runtime,omitempty"`
+	PythonImage      string `json:"python_image,omitempty"`
+	NodeImage        string `json:"node_image,omitempty"`
+	TimeoutMS        int    `json:"timeout_ms"`
+	CPUSeconds       int    `json:"cpu_seconds"`
+	MemoryMB         int    `json:"memory_mb"`
+	MaxOutputBytes   int    `json:"max_output_bytes"`
+	RunTimeMS        int    `json:"run_time_ms"`
+	RunCPUSeconds    int    `json:"run_cpu_seconds"`
+	RunOutputBytes   int    `json:"run_output_bytes"`
+}
+
+// ExecutionResult is what one run hands back to the model. CPUMS is the CPU
+// time used; the container backend cannot see it and reports wall time.
+type ExecutionResult struct {
+	Language   string `json:"language"`
+	Stdout     string `json:"stdout"`
+	Stderr     string `json:"stderr"`
+	ReturnCode int    `json:"return_code"`
+	DurationMS int64  `json:"duration_ms"`
+	CPUMS      int64  `json:"cpu_ms"`
+	TimedOut   bool   `json:"timed_out,omitempty"`
+	Truncated  bool   `json:"truncated,omitempty"`
+}
+
+type CodeExecution struct {
+	cfg     CodeExecutionConfig
+	counter atomic.Uint64
+
+	mu   sync.Mutex
+	runs map[string]*runUsage
+}
+
+// runUsage is what the executions of one gateway run used so far.
+type runUsage struct {
+	durationMS  int64
+	cpuMS       int64
+	outputBytes int
+	touched     time.Time
+}
+
+// execLimits is the budget of one execution.
+type execLimits struct {
+	timeout     time.Duration
+	cpuSeconds  int
+	outputBytes int
+}
+
+func NewCodeExecution(cfg CodeExecutionConfig) (*CodeExecution, error) {
+	cfg.Backend = strings.ToLower(strings.TrimSpace(cfg.Backend))
+	switch cfg.Backend {
+	case BackendSubprocess, BackendContainer:
+	default:
+		return nil, fmt.Errorf("unsupported code_execution backend %q", cfg.Backend)
+	}
+	if strings.TrimSpace(cfg.PythonBin) == "" {
+		cfg.PythonBin = "python3"
+	}
+	if strings.TrimSpace(cfg.NodeBin) == "" {
+		cfg.NodeBin = "node"
+	}
+	if strings.TrimSpace(cfg.ContainerRuntime) == "" {
+		cfg.ContainerRuntime = "docker"
+	}
+	if strings.TrimSpace(cfg.PythonImage) == "" {
+		cfg.PythonImage = "python:3.12-slim"
+	}
+	if strings.TrimSpace(cfg.NodeImage) == "" {
+		cfg.NodeImage = "node:20-slim"
+	}
+	if cfg.TimeoutMS <= 0 {
+		cfg.TimeoutMS = 10000
+	}
+	if cfg.CPUSeconds <= 0 {
+		cfg.CPUSeconds = (cfg.TimeoutMS + 999) / 1000
+	}
+	if cfg.MemoryMB <= 0 {
+		cfg.MemoryMB = 256
+	}
+	if cfg.MaxOutputBytes <= 0 {
+		cfg.MaxOutputBytes = 64 << 10
+	}
+	for _, v := range []*int{&cfg.RunTimeMS, &cfg.RunCPUSeconds, &cfg.RunOutputBytes} {
+		if *v < 0 {
+			*v = 0
+		}
+	}
+	return &CodeExecution{cfg: cfg, runs: map[string]*runUsage{}}, nil
+}
+
+// NewCodeExecutionFromEnv reads CODE_EXECUTION_BACKEND and the related
+// CODE_EXECUTION_* budget settings. It returns nil when no backend is set:
+// running model-written code is opt-in.
+func NewCodeExecutionFromEnv() (*CodeExecution, error) {
+	cfg := CodeExecutionConfig{
+		Backend:          os.Getenv("CODE_EXECUTION_BACKEND"),
+		PythonBin:        os.Getenv("CODE_EXECUTION_PYTHON"),
+		NodeBin:          os.Getenv("CODE_EXECUTION_NODE"),
+		ContainerRuntime: os.Getenv("CODE_EXECUTION_CONTAINER_RUNTIME"),
+		PythonImage:      os.Getenv("CODE_EXECUTION_PYTHON_IMAGE"),
+		NodeImage:        os.Getenv("CODE_EXECUTION_NODE_IMAGE"),
+	}
+	if strings.TrimSpace(cfg.Backend) == "" {
+		return nil, nil
+	}
+	for key, dst := range map[string]*int{
+		"CODE_EXECUTION_TIMEOUT_MS":       &cfg.TimeoutMS,
+		"CODE_EXECUTION_CPU_SECONDS":      &cfg.CPUSeconds,
+		"CODE_EXECUTION_MEMORY_MB":        &cfg.MemoryMB,
+		"CODE_EXECUTION_MAX_OUTPUT_BYTES": &cfg.MaxOutputBytes,
+		"CODE_EXECUTION_RUN_TIME_MS":      &cfg.RunTimeMS,
+		"CODE_EXECUTION_RUN_CPU_SECONDS":  &cfg.RunCPUSeconds,
+		"CODE_EXECUTION_RUN_OUTPUT_BYTES": &cfg.RunOutputBytes,
+	} {
+		raw := strings.TrimSpace(os.Getenv(key))
+		if raw == "" {
+			continue
+		}
+		v, err := strconv.Atoi(raw)
+		if err != nil || v < 0 {
+			return nil, fmt.Errorf("invalid %s: %q", key, raw)
+		}
+		*dst = v
+	}
+	return NewCodeExecution(cfg)
+}
+
+func (c *CodeExecution) Name() string { return "code_execution" }
+
+func (c *CodeExecution) Config() CodeExecutionConfig { return c.cfg }
+
+// Execute accepts code and an optional language (python by default) and an
+// optional timeout_ms that may only shorten the configured budget. Each
+// execution gets what is left of its gateway run's budgets, capped by the
+// per-execution ones. Bad input, sandbox failures and exhausted budgets
+// come back as error results; a non-zero exit is a normal result carrying
+// return_code and stderr.
+func (c *CodeExecution) Execute(ctx context.Context, call toolruntime.Call) (toolruntime.Result, error) {
+	code, _ := call.Input["code"].(string)
+	if strings.TrimSpace(code) == "" {
+		return toolruntime.Result{IsError: true, Content: "code_execution requires code"}, nil
+	}
+	language, err := normalizeLanguage(firstString(call.Input, "language", "lang"))
+	if err != nil {
+		return toolruntime.Result{IsError: true, Content: err.Error()}, nil
+	}
+	limits := c.defaultLimits()
+	if ms, ok := intFromAny(call.Input["timeout_ms"]); ok && ms > 0 && time.Duration(ms)*time.Millisecond < limits.timeout {
+		limits.timeout = time.Duration(ms) * time.Millisecond
+	}
+	limits, exhausted := c.runLimits(call.RunID, limits)
+	if exhausted != "" {
+		return toolruntime.Result{IsError: true, Content: "code execution budget exhausted for this run: " + exhausted}, nil
+	}
+
+	res, err := c.run(ctx, language, code, limits)
+	c.charge(call.RunID, res)
+	if err != nil {
+		return toolruntime.Result{IsError: true, Content: fmt.Sprintf("code execution failed: %v", err)}, nil
+	}
+	content := map[string]any{
+		"tool":        "code_execution",
+		"language":    res.Language,
+		"stdout":      res.Stdout,
+		"stderr":      res.Stderr,
+		"return_code": res.ReturnCode,
+		"duration_ms": res.DurationMS,
+		"cpu_ms":      res.CPUMS,
+	}
+	if res.Truncated {
+		content["truncated"] = true
+	}
+	if res.TimedOut {
+		content["timed_out"] = true
+		return toolruntime.Result{IsError: true, Content: content}, nil
+	}
+	return toolruntime.Result{Content: content}, nil
+}
+
+func (c *CodeExecution) defaultLimits() execLimits {
+	return execLimits{
+		timeout:     time.Duration(c.cfg.TimeoutMS) * time.Millisecond,
+		cpuSeconds:  c.cfg.CPUSeconds,
+		outputBytes: c.cfg.MaxOutputBytes,
+	}
+}
+
+// runLimits narrows limits to what is left of runID's budgets, or names
+// the budget that is used up.
+func (c *CodeExecution) runLimits(runID string, limits execLimits) (execLimits, string) {
+	if runID == "" {
+		return limits, ""
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for id, usage := range c.runs {
+		if now.Sub(usage.touched) > runBudgetIdle {
+			delete(c.runs, id)
+		}
+	}
+	usage := c.runs[runID]
+	if usage == nil {
+		usage = &runUsage{}
+	}
+	if c.cfg.RunTimeMS > 0 {
+		left := time.Duration(int64(c.cfg.RunTimeMS)-usage.durationMS) * time.Millisecond
+		if left <= 0 {
+			return limits, fmt.Sprintf("%dms of execution time", c.cfg.RunTimeMS)
+		}
+		limits.timeout = min(limits.timeout, left)
+	}
+	if c.cfg.RunCPUSeconds > 0 {
+		leftMS := int64(c.cfg.RunCPUSeconds)*1000 - usage.cpuMS
+		if leftMS <= 0 {
+			return limits, fmt.Sprintf("%d CPU seconds", c.cfg.RunCPUSeconds)
+		}
+		limits.cpuSeconds = min(limits.cpuSeconds, int((leftMS+999)/1000))
+	}
+	if c.cfg.RunOutputBytes > 0 {
+		left := c.cfg.RunOutputBytes - usage.outputBytes
+		if left <= 0 {
+			return limits, fmt.Sprintf("%d bytes of output", c.cfg.RunOutputBytes)
+		}
+		limits.outputBytes = min(limits.outputBytes, left)
+	}
+	return limits, ""
+}
+
+// charge adds an execution's usage to its run.
+func (c *CodeExecution) charge(runID string, res ExecutionResult) {
+	if runID == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	usage := c.runs[runID]
+	if usage == nil {
+		usage = &runUsage{}
+		c.runs[runID] = usage
+	}
+	usage.durationMS += res.DurationMS
+	usage.cpuMS += res.CPUMS
+	usage.outputBytes += len(res.Stdout) + len(res.Stderr)
+	usage.touched = time.Now()
+}
+
+// Run executes code once under the configured per-execution budget.
+func (c *CodeExecution) Run(ctx context.Context, language, code string, timeout time.Duration) (ExecutionResult, error) {
+	limits := c.defaultLimits()
+	limits.timeout = timeout
+	return c.run(ctx, language, code, limits)
+}
+
+// run executes code under limits. The source is fed on stdin so nothing
+// model-written touches the gateway filesystem outside the scratch
+// directory.
+func (c *CodeExecution) run(ctx context.Context, language, code string, limits execLimits) (ExecutionResult, error) {
+	timeout := limits.timeout
+	workDir, err := os.MkdirTemp("", "cc-code-exec-")
+	if err != nil {
+		return ExecutionResult{}, err
+	}
+	defer os.RemoveAll(workDir)
+
+	runCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	var containerName string
+	switch c.cfg.Backend {
+	case BackendContainer:
+		containerName = fmt.Sprintf("cc-code-exec-%d-%d", os.Getpid(), c.counter.Add(1))
+		cmd = exec.CommandContext(runCtx, c.cfg.ContainerRuntime, c.containerArgs(containerName, language, limits)...)
+	default:
+		cmd = exec.CommandContext(runCtx, "sh", c.subprocessArgs(language, limits)...)
+		cmd.Dir = workDir
+		cmd.Env = []string{
+			"PATH=" + os.Getenv("PATH"),
+			"HOME=" + workDir,
+			"TMPDIR=" + workDir,
+			"LANG=C.UTF-8",
+		}
+	}
+	// Run in its own process group so a timeout takes down anything the
+	// snippet forked, not just the interpreter.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		if containerName != "" {
+			_ = exec.Command(c.cfg.ContainerRuntime, "kill", containerName).Run()
+		}
+		if cmd.Process == nil {
+			return nil
+		}
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = time.Second
+
+	stdout := &cappedBuffer{limit: limits.outputBytes}
+	stderr := &cappedBuffer{limit: limits.outputBytes}
+	cmd.Stdin = strings.NewReader(code)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	started := time.Now()
+	runErr := cmd.Run()
+	res := ExecutionResult{
+		Language:   language,
+		Stdout:     stdout.String(),
+		Stderr:     stderr.String(),
+		DurationMS: time.Since(started).Milliseconds(),
+		Truncated:  stdout.truncated || stderr.truncated,
+		TimedOut:   runCtx.Err() == context.DeadlineExceeded,
+	}
+	res.CPUMS = res.DurationMS
+	if containerName == "" && cmd.ProcessState != nil {
+		res.CPUMS = (cmd.ProcessState.UserTime() + cmd.ProcessState.SystemTime()).Milliseconds()
+	}
+	if runErr != nil {
+		exitErr, ok := runErr.(*exec.ExitError)
+		if !ok && !res.TimedOut {
+			return ExecutionResult{}, runErr
+		}
+		res.ReturnCode = -1
+		if ok && exitErr.ExitCode() >= 0 {
+			res.ReturnCode = exitErr.ExitCode()
+		}
+	}
+	if res.TimedOut {
+		res.Stderr = strings.TrimSpace(res.Stderr + fmt.Sprintf("\nexecution timed out after %dms", timeout.Milliseconds()))
+	}
+	return res, nil
+}
+
+// subprocessArgs wraps the interpreter in sh so rlimits apply to the child
+// only. Node reserves far more address space than it uses, so its memory
+// budget is enforced through the V8 heap limit instead of ulimit -v.
+func (c *CodeExecution) subprocessArgs(language string, budget execLimits) []string {
+	if language == LanguageJavaScript {
+		return rlimitShellArgs(budget.cpuSeconds, 0, c.cfg.NodeBin, "--max-old-space-size="+strconv.Itoa(c.cfg.MemoryMB), "-")
+	}
+	return rlimitShellArgs(budget.cpuSeconds, c.cfg.MemoryMB, c.cfg.PythonBin, "-")
+}
+
+func (c *CodeExecution) containerArgs(name, language string, budget execLimits) []string {
+	image, argv := c.cfg.PythonImage, []string{"python3", "-"}
+	if language == LanguageJavaScript {
+		image, argv = c.cfg.NodeImage, []string{"node", "-"}
+	}
+	return containerRunArgs(name, image, budget.cpuSeconds, c.cfg.MemoryMB, []string{"--workdir", "/tmp"}, argv...)
+}
+
+func normalizeLanguage(raw string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(raw)) {
+	case "", "python", "python3", "py":
+		return LanguagePython, nil
+	case "javascript", "js", "node", "nodejs":
+		return LanguageJavaScript, nil
+	}
+	return "", fmt.Errorf("unsupported code_execution language %q", raw)
+}
+
+// cappedBuffer keeps the first limit bytes and silently drops the rest so a
+// chatty snippet cannot block on a full pipe or exhaust gateway memory.
+type cappedBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.buf.Len(); room < len(p) {
+		b.truncated = true
+		if room > 0 {
+			b.buf.Write(p[:room])
+		}
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+func (b *cappedBuffer) String() string { return b.buf.String() }
@@ -0,0 +1,523 @@
+package servertools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"ccgateway/internal/ccevent"
+	"ccgateway/internal/requestctx"
+	"ccgateway/internal/toolruntime"
+	"ccgateway/internal/workspace"
+)
+
+// Names of the git server tools.
+const (
+	GitCloneName  = "git_clone"
+	GitStatusName = "git_status"
+	GitDiffName   = "git_diff"
+	GitCommitName = "git_commit"
+	GitBranchName = "git_branch"
+)
+
+var (
+	gitRefRE = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._/-]*$`)
+	scpURLRE = regexp.MustCompile(`^[A-Za-z0-9._-]+@([A-Za-z0-9.-]+):[A-Za-z0-9._~/-]+$`)
+)
+
+// EventAppender records audit events; *ccevent.Store satisfies it.
+type EventAppender interface {
+	Append(in ccevent.AppendInput) (ccevent.Event, error)
+}
+
+// GitConfig bounds the git tools. Clones are only allowed from hosts in
+// AllowedHosts ("github.com" or "*.corp.example"); a project listed in
+// ProjectHosts uses its own list instead.
+type GitConfig struct {
+	Binary         string              `json:"binary,omitempty"`
+	AllowedHosts   []string            `json:"allowed_hosts"`
+	ProjectHosts   map[string][]string `json:"project_hosts,omitempty"`
+	TimeoutMS      int                 `json:"timeout_ms"`
+	MaxOutputBytes int                 `json:"max_output_bytes"`
+	AuthorName     string              `json:"author_name,omitempty"`
+	AuthorEmail    string              `json:"author_email,omitempty"`
+}
+
+// GitTools runs git in the session workspaces, recording every operation
+// as a tool.git event.
+type GitTools struct {
+	ws     *workspace.Manager
+	cfg    GitConfig
+	events EventAppender
+}
+
+// NewGitTools applies the defaults; events may be nil.
+func NewGitTools(ws *workspace.Manager, cfg GitConfig, events EventAppender) *GitTools {
+	if strings.TrimSpace(cfg.Binary) == "" {
+		cfg.Binary = "git"
+	}
+	if cfg.TimeoutMS <= 0 {
+		cfg.TimeoutMS = 120000
+	}
+	if cfg.MaxOutputBytes <= 0 {
+		cfg.MaxOutputBytes = 64 << 10
+	}
+	if strings.TrimSpace(cfg.AuthorName) == "" {
+		cfg.AuthorName = "cc-gateway"
+	}
+	if strings.TrimSpace(cfg.AuthorEmail) == "" {
+		cfg.AuthorEmail = "cc-gateway@localhost"
+	}
+	cfg.AllowedHosts = normalizeHosts(cfg.AllowedHosts)
+	projects := make(map[string][]string, len(cfg.ProjectHosts))
+	for id, hosts := range cfg.ProjectHosts {
+		projects[requestctx.NormalizeProjectID(id)] = normalizeHosts(hosts)
+	}
+	cfg.ProjectHosts = projects
+	return &GitTools{ws: ws, cfg: cfg, events: events}
+}
+
+// NewGitToolsFromEnv returns the tools when GIT_TOOLS_ENABLED is true.
+// GIT_TOOLS_ALLOWED_HOSTS (comma separated) and GIT_TOOLS_PROJECT_HOSTS_JSON
+// ({"project": ["host", ...]}) allow clones; GIT_TOOLS_BINARY,
+// GIT_TOOLS_TIMEOUT_MS, GIT_TOOLS_AUTHOR_NAME and GIT_TOOLS_AUTHOR_EMAIL
+// tune the rest.
+func NewGitToolsFromEnv(ws *workspace.Manager, events EventAppender) (*GitTools, error) {
+	raw := strings.TrimSpace(os.Getenv("GIT_TOOLS_ENABLED"))
+	if raw == "" {
+		return nil, nil
+	}
+	enabled, err := strconv.ParseBool(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid GIT_TOOLS_ENABLED: %q", raw)
+	}
+	if !enabled {
+		return nil, nil
+	}
+	if ws == nil {
+		return nil, fmt.Errorf("GIT_TOOLS_ENABLED requires WORKSPACE_ROOT")
+	}
+	cfg := GitConfig{
+		Binary:       os.Getenv("GIT_TOOLS_BINARY"),
+		AllowedHosts: strings.Split(os.Getenv("GIT_TOOLS_ALLOWED_HOSTS"), ","),
+		AuthorName:   os.Getenv("GIT_TOOLS_AUTHOR_NAME"),
+		AuthorEmail:  os.Getenv("GIT_TOOLS_AUTHOR_EMAIL"),
+	}
+	if raw := strings.TrimSpace(os.Getenv("GIT_TOOLS_PROJECT_HOSTS_JSON")); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &cfg.ProjectHosts); err != nil {
+			return nil, fmt.Errorf("invalid GIT_TOOLS_PROJECT_HOSTS_JSON: %w", err)
+		}
+	}
+	if raw := strings.TrimSpace(os.Getenv("GIT_TOOLS_TIMEOUT_MS")); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v <= 0 {
+			return nil, fmt.Errorf("invalid GIT_TOOLS_TIMEOUT_MS: %q", raw)
+		}
+		cfg.TimeoutMS = v
+	}
+	return NewGitTools(ws, cfg, events), nil
+}
+
+func normalizeHosts(hosts []string) []string {
+	out := make([]string, 0, len(hosts))
+	for _, h := range hosts {
+		if h = strings.ToLower(strings.TrimSpace(h)); h != "" {
+			out = append(out, h)
+		}
+	}
+	return out
+}
+
+// Config returns the settings in effect.
+func (g *GitTools) Config() GitConfig { return g.cfg }
+
+// Tools returns the clone, status, diff, commit and branch tools.
+func (g *GitTools) Tools() []Tool {
+	return []Tool{
+		&gitTool{name: GitCloneName, ws: g.ws, run: g.clone},
+		&gitTool{name: GitStatusName, ws: g.ws, run: g.status},
+		&gitTool{name: GitDiffName, ws: g.ws, run: g.diff},
+		&gitTool{name: GitCommitName, ws: g.ws, run: g.commit},
+		&gitTool{name: GitBranchName, ws: g.ws, run: g.branch},
+	}
+}
+
+// gitOp is what one tool call did, for the result and the audit event.
+type gitOp struct {
+	repo   string
+	detail map[string]any
+	output string
+	err    error
+}
+
+type gitTool struct {
+	name string
+	ws   *workspace.Manager
+	run  func(ctx context.Context, call toolruntime.Call) gitOp
+}
+
+func (t *gitTool) Name() string { return t.name }
+
+func (t *gitTool) Execute(ctx context.Context, call toolruntime.Call) (toolruntime.Result, error) {
+	touchWorkspace(t.ws, call)
+	op := t.run(ctx, call)
+	return op.result(), nil
+}
+
+func (op gitOp) result() toolruntime.Result {
+	if op.err != nil {
+		return toolruntime.Result{IsError: true, Content: strings.TrimSpace(op.output + "\n" + op.err.Error())}
+	}
+	if strings.TrimSpace(op.output) == "" {
+		return toolruntime.Result{Content: "(no output)"}
+	}
+	return toolruntime.Result{Content: op.output}
+}
+
+// allowedHosts returns the clone allowlist for the calling project.
+func (g *GitTools) allowedHosts(ctx context.Context) []string {
+	if hosts, ok := g.cfg.ProjectHosts[requestctx.ProjectID(ctx)]; ok {
+		return hosts
+	}
+	return g.cfg.AllowedHosts
+}
+
+func hostAllowed(host string, allowed []string) bool {
+	host = strings.ToLower(host)
+	for _, pattern := range allowed {
+		if pattern == host {
+			return true
+		}
+		if suffix, ok := strings.CutPrefix(pattern, "*."); ok && strings.HasSuffix(host, "."+suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// cloneHost validates a clone URL and returns its host. Only http(s) and
+// ssh remotes are accepted, including scp-style git@host:org/repo.
+func cloneHost(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" || strings.HasPrefix(raw, "-") {
+		return "", fmt.Errorf("git_clone requires a repository url")
+	}
+	if !strings.Contains(raw, "://") {
+		if m := scpURLRE.FindStringSubmatch(raw); m != nil {
+			return m[1], nil
+		}
+		return "", fmt.Errorf("unsupported repository url %q", raw)
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", fmt.Errorf("invalid repository url: %w", err)
+	}
+	switch u.Scheme {
+	case "https", "http", "ssh":
+	default:
+		return "", fmt.Errorf("unsupported repository url scheme %q", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return "", fmt.Errorf("repository url has no host")
+	}
+	return u.Hostname(), nil
+}
+
+func (g *GitTools) clone(ctx context.Context, call toolruntime.Call) gitOp {
+	rawURL := firstString(call.Input, "url", "repository")
+	op := gitOp{detail: map[string]any{"url": redactURL(rawURL)}}
+	defer g.audit(ctx, call, GitCloneName, &op)
+	host, err := cloneHost(rawURL)
+	if err != nil {
+		op.err = err
+		return op
+	}
+	op.detail["host"] = host
+	if !hostAllowed(host, g.allowedHosts(ctx)) {
+		op.err = fmt.Errorf("cloning from %s is not allowed", host)
+		return op
+	}
+	dest := firstString(call.Input, "path", "directory")
+	if dest == "" {
+		dest = strings.TrimSuffix(path.Base(strings.TrimRight(strings.ReplaceAll(rawURL, ":", "/"), "/")), ".git")
+	}
+	full, err := g.ws.Resolve(call.SessionID, dest)
+	if err != nil {
+		op.err = err
+		return op
+	}
+	op.repo = dest
+	args := []string{"-c", "protocol.file.allow=never", "clone", "--no-recurse-submodules"}
+	if branch := firstString(call.Input, "branch"); branch != "" {
+		if !gitRefRE.MatchString(branch) {
+			op.err = fmt.Errorf("invalid branch name %q", branch)
+			return op
+		}
+		args = append(args, "--branch", branch)
+	}
+	if depth, ok := intFromAny(call.Input["depth"]); ok && depth > 0 {
+		args = append(args, "--depth", strconv.Itoa(depth))
+	}
+	args = append(args, "--", rawURL, full)
+	dir, err := g.ws.Dir(call.SessionID)
+	if err != nil {
+		op.err = err
+		return op
+	}
+	op.output, op.err = g.git(ctx, call.SessionID, dir, args...)
+	if op.err != nil {
+		return op
+	}
+	if used, err := g.ws.Usage(call.SessionID); err == nil && used > g.ws.Config().QuotaBytes {
+		_ = os.RemoveAll(full)
+		op.err = fmt.Errorf("%w: the clone needs %d bytes", workspace.ErrQuotaExceeded, used)
+		return op
+	}
+	op.output = strings.TrimSpace(op.output + "\nCloned into " + dest)
+	return op
+}
+
+func (g *GitTools) status(ctx context.Context, call toolruntime.Call) gitOp {
+	op := gitOp{detail: map[string]any{}}
+	defer g.audit(ctx, call, GitStatusName, &op)
+	dir, err := g.repoDir(call, &op)
+	if err != nil {
+		op.err = err
+		return op
+	}
+	op.output, op.err = g.git(ctx, call.SessionID, dir, "status", "--short", "--branch")
+	return op
+}
+
+func (g *GitTools) diff(ctx context.Context, call toolruntime.Call) gitOp {
+	op := gitOp{detail: map[string]any{}}
+	defer g.audit(ctx, call, GitDiffName, &op)
+	dir, err := g.repoDir(call, &op)
+	if err != nil {
+		op.err = err
+		return op
+	}
+	args := []string{"diff", "--no-ext-diff", "--no-textconv", "--no-color"}
+	if staged, _ := call.Input["staged"].(bool); staged {
+		args = append(args, "--cached")
+	}
+	if ref := firstString(call.Input, "ref"); ref != "" {
+		if !gitRefRE.MatchString(ref) {
+			op.err = fmt.Errorf("invalid ref %q", ref)
+			return op
+		}
+		args = append(args, ref)
+		op.detail["ref"] = ref
+	}
+	op.output, op.err = g.git(ctx, call.SessionID, dir, append(args, "--")...)
+	return op
+}
+
+func (g *GitTools) commit(ctx context.Context, call toolruntime.Call) gitOp {
+	op := gitOp{detail: map[string]any{}}
+	defer g.audit(ctx, call, GitCommitName, &op)
+	dir, err := g.repoDir(call, &op)
+	if err != nil {
+		op.err = err
+		return op
+	}
+	message := firstString(call.Input, "message")
+	if message == "" {
+		op.err = fmt.Errorf("git_commit requires message")
+		return op
+	}
+	if all, ok := call.Input["all"].(bool); !ok || all {
+		if op.output, op.err = g.git(ctx, call.SessionID, dir, "add", "-A"); op.err != nil {
+			return op
+		}
+	}
+	if op.output, op.err = g.git(ctx, call.SessionID, dir,
+		"-c", "user.name="+g.cfg.AuthorName, "-c", "user.email="+g.cfg.AuthorEmail,
+		"commit", "--no-verify", "-m", message); op.err != nil {
+		return op
+	}
+	if hash, err := g.git(ctx, call.SessionID, dir, "rev-parse", "HEAD"); err == nil {
+		op.detail["commit"] = strings.TrimSpace(hash)
+	}
+	return op
+}
+
+func (g *GitTools) branch(ctx context.Context, call toolruntime.Call) gitOp {
+	op := gitOp{detail: map[string]any{}}
+	defer g.audit(ctx, call, GitBranchName, &op)
+	dir, err := g.repoDir(call, &op)
+	if err != nil {
+		op.err = err
+		return op
+	}
+	name := firstString(call.Input, "name", "branch")
+	if name == "" {
+		op.output, op.err = g.git(ctx, call.SessionID, dir, "branch", "--list", "--no-color")
+		return op
+	}
+	if !gitRefRE.MatchString(name) {
+		op.err = fmt.Errorf("invalid branch name %q", name)
+		return op
+	}
+	op.detail["branch"] = name
+	args := []string{"switch", name}
+	if create, _ := call.Input["create"].(bool); create {
+		args = []string{"switch", "-c", name}
+	}
+	op.output, op.err = g.git(ctx, call.SessionID, dir, args...)
+	return op
+}
+
+// repoDir resolves the repository directory named by the call's path,
+// defaulting to the session root.
+func (g *GitTools) repoDir(call toolruntime.Call, op *gitOp) (string, error) {
+	repo := firstString(call.Input, "path", "repo")
+	if repo == "" {
+		repo = "/"
+	}
+	op.repo = repo
+	return g.ws.Resolve(call.SessionID, repo)
+}
+
+// gitSafetyConfig overrides settings with which a repository's own config
+// could make git run programs or reach out on its behalf. Command-line
+// config wins over every config file.
+var gitSafetyConfig = []string{
+	"core.hooksPath=/dev/null",
+	"core.fsmonitor=false",
+	"core.sshCommand=false",
+	"core.askPass=",
+	"credential.helper=",
+	"commit.gpgSign=false",
+	"tag.gpgSign=false",
+	"protocol.ext.allow=never",
+}
+
+// gitDriverKeyPattern matches the config keys naming filter and diff
+// driver commands, whose driver names are chosen by the repository.
+const gitDriverKeyPattern = `^(filter\..*\.(clean|smudge|process)|diff\..*\.(textconv|command))$`
+
+// git runs one git command in dir. Hooks, prompts, the user's global
+// configuration and any program the repository's config names are
+// disabled.
+func (g *GitTools) git(ctx context.Context, sessionID, dir string, args ...string) (string, error) {
+	home, err := g.ws.Dir(sessionID)
+	if err != nil {
+		return "", err
+	}
+	overrides, err := g.driverOverrides(ctx, home, dir)
+	if err != nil {
+		return "", err
+	}
+	return g.run(ctx, home, dir, append(overrides, args...))
+}
+
+// driverOverrides blanks every filter and diff driver command the
+// repository configures, so neither add, switch nor diff can run one.
+func (g *GitTools) driverOverrides(ctx context.Context, home, dir string) ([]string, error) {
+	var out []string
+	for _, kv := range gitSafetyConfig {
+		out = append(out, "-c", kv)
+	}
+	keys, err := g.run(ctx, home, dir, []string{"config", "--includes", "--name-only", "--get-regexp", gitDriverKeyPattern})
+	if err != nil {
+		// No matching key, or no repository yet.
+		return out, nil
+	}
+	for _, key := range strings.Split(strings.TrimSpace(keys), "\n") {
+		if key == "" {
+			continue
+		}
+		if strings.Contains(key, "=") || strings.HasPrefix(key, "[output truncated]") {
+			return nil, fmt.Errorf("repository config defines an unsupported driver %q", key)
+		}
+		out = append(out, "-c", key+"=")
+	}
+	return out, nil
+}
+
+func (g *GitTools) run(ctx context.Context, home, dir string, args []string) (string, error) {
+	timeout := time.Duration(g.cfg.TimeoutMS) * time.Millisecond
+	runCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	cmd := exec.CommandContext(runCtx, g.cfg.Binary, args...)
+	cmd.Dir = dir
+	cmd.Env = []string{
+		"PATH=" + os.Getenv("PATH"),
+		"HOME=" + home,
+		"GIT_TERMINAL_PROMPT=0",
+		"GIT_CONFIG_NOSYSTEM=1",
+		"GIT_CONFIG_GLOBAL=/dev/null",
+		"LANG=C.UTF-8",
+	}
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		if cmd.Process == nil {
+			return nil
+		}
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = time.Second
+	output := &cappedBuffer{limit: g.cfg.MaxOutputBytes}
+	cmd.Stdout = output
+	cmd.Stderr = output
+	runErr := cmd.Run()
+	text := output.String()
+	if output.truncated {
+		text += "\n[output truncated]"
+	}
+	if runCtx.Err() == context.DeadlineExceeded {
+		return text, fmt.Errorf("git timed out after %dms", timeout.Milliseconds())
+	}
+	if runErr != nil {
+		if exitErr, ok := runErr.(*exec.ExitError); ok {
+			return text, fmt.Errorf("git exited with status %d", exitErr.ExitCode())
+		}
+		return text, fmt.Errorf("git failed: %v", runErr)
+	}
+	return text, nil
+}
+
+// audit records the operation as a tool.git event.
+func (g *GitTools) audit(ctx context.Context, call toolruntime.Call, tool string, op *gitOp) {
+	if g.events == nil {
+		return
+	}
+	data := map[string]any{
+		"tool":        tool,
+		"tool_use_id": call.ID,
+		"project_id":  requestctx.ProjectID(ctx),
+		"repo":        op.repo,
+		"ok":          op.err == nil,
+	}
+	for k, v := range op.detail {
+		data[k] = v
+	}
+	if op.err != nil {
+		data["error"] = op.err.Error()
+	}
+	_, _ = g.events.Append(ccevent.AppendInput{
+		EventType: "tool.git",
+		SessionID: call.SessionID,
+		Data:      data,
+	})
+}
+
+// redactURL drops credentials embedded in a clone URL.
+func redactURL(raw string) string {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.User == nil {
+		return raw
+	}
+	u.User = nil
+	return u.String()
+}
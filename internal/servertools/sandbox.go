@@ -0,0 +1,42 @@
+package servertools
+
+import (
+	"strconv"
+	"strings"
+)
+
+// rlimitShellArgs returns sh arguments that set the CPU, file size and,
+// when memoryMB is positive, address space limits and then exec argv, so
+// the limits bind the child and everything it starts.
+func rlimitShellArgs(cpuSeconds, memoryMB int, argv ...string) []string {
+	limits := []string{
+		"ulimit -t " + strconv.Itoa(cpuSeconds),
+		"ulimit -f 10240",
+	}
+	if memoryMB > 0 {
+		limits = append(limits, "ulimit -v "+strconv.Itoa(memoryMB*1024))
+	}
+	script := strings.Join(limits, "; ") + `; exec "$@"`
+	return append([]string{"-c", script, "sh"}, argv...)
+}
+
+// containerRunArgs returns the arguments that run argv in a throwaway
+// container without network access, a read-only root and bounded memory,
+// CPU and processes. extra goes before the image, for mounts and the like.
+func containerRunArgs(name, image string, cpuSeconds, memoryMB int, extra []string, argv ...string) []string {
+	args := []string{
+		"run", "--rm", "-i",
+		"--name", name,
+		"--network", "none",
+		"--read-only",
+		"--tmpfs", "/tmp:rw,size=64m",
+		"--memory", strconv.Itoa(memoryMB) + "m",
+		"--cpus", "1",
+		"--pids-limit", "64",
+		"--ulimit", "cpu=" + strconv.Itoa(cpuSeconds),
+		"--security-opt", "no-new-privileges",
+	}
+	args = append(args, extra...)
+	args = append(args, image)
+	return append(args, argv...)
+}
@@ -0,0 +1,28 @@
+package servertools
+
+import (
+	"context"
+
+	"ccgateway/internal/toolruntime"
+)
+
+// Tool is a first-party tool the gateway executes itself inside the
+// server-side tool loop, ahead of any MCP server exposing the same name.
+type Tool interface {
+	Name() string
+	Execute(ctx context.Context, call toolruntime.Call) (toolruntime.Result, error)
+}
+
+// Register installs tools into reg, replacing any handler already registered
+// under the same name.
+func Register(reg *toolruntime.Registry, tools ...Tool) {
+	if reg == nil {
+		return
+	}
+	for _, tool := range tools {
+		if tool == nil {
+			continue
+		}
+		reg.Register(tool.Name(), tool.Execute)
+	}
+}
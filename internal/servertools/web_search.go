@@ -0,0 +1,375 @@
+package servertools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"ccgateway/internal/egress"
+	"ccgateway/internal/toolruntime"
+)
+
+const (
+	ProviderDuckDuckGo = "duckduckgo"
+	ProviderSearXNG    = "searxng"
+	ProviderBrave      = "brave"
+	ProviderTavily     = "tavily"
+	// ProviderCustom issues a GET to Endpoint with {query} substituted and
+	// reads a generic {"results":[{"title","url","snippet"}]} response.
+	ProviderCustom = "custom"
+)
+
+const maxSearchResponseBytes = 1 << 20
+
+// WebSearchConfig selects the search API backing the web_search tool.
+type WebSearchConfig struct {
+	Provider   string `json:"provider"`
+	Endpoint   string `json:"endpoint,omitempty"`
+	APIKey     string `json:"-"`
+	MaxResults int    `json:"max_results"`
+	TimeoutMS  int    `json:"timeout_ms"`
+}
+
+// SearchResult is one normalized hit returned to the model.
+type SearchResult struct {
+	Title   string `json:"title"`
+	URL     string `json:"url"`
+	Snippet string `json:"snippet,omitempty"`
+}
+
+type WebSearch struct {
+	cfg WebSearchConfig
+}
+
+func NewWebSearch(cfg WebSearchConfig) (*WebSearch, error) {
+	cfg.Provider = strings.ToLower(strings.TrimSpace(cfg.Provider))
+	cfg.Endpoint = strings.TrimSpace(cfg.Endpoint)
+	if cfg.Provider == "" {
+		cfg.Provider = ProviderDuckDuckGo
+		if cfg.Endpoint != "" {
+			cfg.Provider = ProviderCustom
+		}
+	}
+	switch cfg.Provider {
+	case ProviderDuckDuckGo, ProviderBrave, ProviderTavily:
+	case ProviderSearXNG, ProviderCustom:
+		if cfg.Endpoint == "" {
+			return nil, fmt.Errorf("web_search provider %q requires an endpoint", cfg.Provider)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported web_search provider %q", cfg.Provider)
+	}
+	if (cfg.Provider == ProviderBrave || cfg.Provider == ProviderTavily) && strings.TrimSpace(cfg.APIKey) == "" {
+		return nil, fmt.Errorf("web_search provider %q requires an api key", cfg.Provider)
+	}
+	if cfg.MaxResults <= 0 {
+		cfg.MaxResults = 5
+	}
+	if cfg.TimeoutMS <= 0 {
+		cfg.TimeoutMS = 10000
+	}
+	return &WebSearch{cfg: cfg}, nil
+}
+
+// NewWebSearchFromEnv reads WEB_SEARCH_PROVIDER, WEB_SEARCH_ENDPOINT (or the
+// legacy SEARCH_API_URL), WEB_SEARCH_API_KEY, WEB_SEARCH_MAX_RESULTS and
+// WEB_SEARCH_TIMEOUT_MS.
+func NewWebSearchFromEnv() (*WebSearch, error) {
+	cfg := WebSearchConfig{
+		Provider: os.Getenv("WEB_SEARCH_PROVIDER"),
+		Endpoint: os.Getenv("WEB_SEARCH_ENDPOINT"),
+		APIKey:   strings.TrimSpace(os.Getenv("WEB_SEARCH_API_KEY")),
+	}
+	if strings.TrimSpace(cfg.Endpoint) == "" {
+		cfg.Endpoint = os.Getenv("SEARCH_API_URL")
+	}
+	if raw := strings.TrimSpace(os.Getenv("WEB_SEARCH_MAX_RESULTS")); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v <= 0 {
+			return nil, fmt.Errorf("invalid WEB_SEARCH_MAX_RESULTS: %q", raw)
+		}
+		cfg.MaxResults = v
+	}
+	if raw := strings.TrimSpace(os.Getenv("WEB_SEARCH_TIMEOUT_MS")); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v <= 0 {
+			return nil, fmt.Errorf("invalid WEB_SEARCH_TIMEOUT_MS: %q", raw)
+		}
+		cfg.TimeoutMS = v
+	}
+	return NewWebSearch(cfg)
+}
+
+func (w *WebSearch) Name() string { return "web_search" }
+
+func (w *WebSearch) Config() WebSearchConfig { return w.cfg }
+
+// Execute accepts query (or q), an optional max_results, and the Anthropic
+// web_search options allowed_domains / blocked_domains. Backend failures are
+// returned as error results so the model can recover.
+func (w *WebSearch) Execute(ctx context.Context, call toolruntime.Call) (toolruntime.Result, error) {
+	query := firstString(call.Input, "query", "q", "keyword")
+	if query == "" {
+		return toolruntime.Result{}, fmt.Errorf("web_search requires query")
+	}
+	limit := w.cfg.MaxResults
+	if n, ok := intFromAny(call.Input["max_results"]); ok && n > 0 && n < limit {
+		limit = n
+	}
+	results, err := w.Search(ctx, query)
+	if err != nil {
+		return toolruntime.Result{IsError: true, Content: fmt.Sprintf("web search failed: %v", err)}, nil
+	}
+	results = filterDomains(results, stringList(call.Input["allowed_domains"]), stringList(call.Input["blocked_domains"]))
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return toolruntime.Result{
+		Content: map[string]any{
+			"tool":     "web_search",
+			"query":    query,
+			"provider": w.cfg.Provider,
+			"results":  results,
+		},
+	}, nil
+}
+
+// Search queries the configured backend and returns normalized results.
+func (w *WebSearch) Search(ctx context.Context, query string) ([]SearchResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(w.cfg.TimeoutMS)*time.Millisecond)
+	defer cancel()
+
+	var req *http.Request
+	var err error
+	switch w.cfg.Provider {
+	case ProviderDuckDuckGo:
+		endpoint := w.cfg.Endpoint
+		if endpoint == "" {
+			endpoint = "https://api.duckduckgo.com/"
+		}
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, withQuery(endpoint, url.Values{
+			"q": {query}, "format": {"json"}, "no_html": {"1"}, "skip_disambig": {"1"},
+		}), nil)
+	case ProviderSearXNG:
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, withQuery(w.cfg.Endpoint, url.Values{
+			"q": {query}, "format": {"json"},
+		}), nil)
+	case ProviderBrave:
+		endpoint := w.cfg.Endpoint
+		if endpoint == "" {
+			endpoint = "https://api.search.brave.com/res/v1/web/search"
+		}
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, withQuery(endpoint, url.Values{
+			"q": {query}, "count": {strconv.Itoa(w.cfg.MaxResults)},
+		}), nil)
+		if err == nil {
+			req.Header.Set("X-Subscription-Token", w.cfg.APIKey)
+		}
+	case ProviderTavily:
+		endpoint := w.cfg.Endpoint
+		if endpoint == "" {
+			endpoint = "https://api.tavily.com/search"
+		}
+		payload, _ := json.Marshal(map[string]any{"query": query, "max_results": w.cfg.MaxResults})
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+		if err == nil {
+			req.Header.Set("content-type", "application/json")
+			req.Header.Set("authorization", "Bearer "+w.cfg.APIKey)
+		}
+	case ProviderCustom:
+		endpoint := strings.ReplaceAll(w.cfg.Endpoint, "{query}", url.QueryEscape(query))
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+		if err == nil && w.cfg.APIKey != "" {
+			req.Header.Set("authorization", "Bearer "+w.cfg.APIKey)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("accept", "application/json")
+
+	resp, err := egress.Default().HTTPClient(0).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxSearchResponseBytes))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("search backend returned status %d", resp.StatusCode)
+	}
+	return parseResults(w.cfg.Provider, body)
+}
+
+func parseResults(provider string, body []byte) ([]SearchResult, error) {
+	switch provider {
+	case ProviderDuckDuckGo:
+		var doc struct {
+			Heading       string `json:"Heading"`
+			AbstractText  string `json:"AbstractText"`
+			AbstractURL   string `json:"AbstractURL"`
+			RelatedTopics []struct {
+				Text     string `json:"Text"`
+				FirstURL string `json:"FirstURL"`
+				Topics   []struct {
+					Text     string `json:"Text"`
+					FirstURL string `json:"FirstURL"`
+				} `json:"Topics"`
+			} `json:"RelatedTopics"`
+		}
+		if err := json.Unmarshal(body, &doc); err != nil {
+			return nil, fmt.Errorf("decode duckduckgo response: %w", err)
+		}
+		var out []SearchResult
+		if doc.AbstractURL != "" {
+			out = append(out, SearchResult{Title: doc.Heading, URL: doc.AbstractURL, Snippet: doc.AbstractText})
+		}
+		for _, topic := range doc.RelatedTopics {
+			if topic.FirstURL != "" {
+				out = append(out, SearchResult{Title: titleFromSnippet(topic.Text), URL: topic.FirstURL, Snippet: topic.Text})
+			}
+			for _, sub := range topic.Topics {
+				if sub.FirstURL != "" {
+					out = append(out, SearchResult{Title: titleFromSnippet(sub.Text), URL: sub.FirstURL, Snippet: sub.Text})
+				}
+			}
+		}
+		return out, nil
+	case ProviderBrave:
+		var doc struct {
+			Web struct {
+				Results []struct {
+					Title       string `json:"title"`
+					URL         string `json:"url"`
+					Description string `json:"description"`
+				} `json:"results"`
+			} `json:"web"`
+		}
+		if err := json.Unmarshal(body, &doc); err != nil {
+			return nil, fmt.Errorf("decode brave response: %w", err)
+		}
+		out := make([]SearchResult, 0, len(doc.Web.Results))
+		for _, r := range doc.Web.Results {
+			out = append(out, SearchResult{Title: r.Title, URL: r.URL, Snippet: r.Description})
+		}
+		return out, nil
+	default:
+		// SearXNG, Tavily and custom backends share a top-level results list.
+		var doc struct {
+			Results []map[string]any `json:"results"`
+		}
+		if err := json.Unmarshal(body, &doc); err != nil {
+			return nil, fmt.Errorf("decode %s response: %w", provider, err)
+		}
+		out := make([]SearchResult, 0, len(doc.Results))
+		for _, r := range doc.Results {
+			link := firstString(r, "url", "link", "href")
+			if link == "" {
+				continue
+			}
+			out = append(out, SearchResult{
+				Title:   firstString(r, "title", "name"),
+				URL:     link,
+				Snippet: firstString(r, "snippet", "content", "description"),
+			})
+		}
+		return out, nil
+	}
+}
+
+func filterDomains(results []SearchResult, allowed, blocked []string) []SearchResult {
+	if len(allowed) == 0 && len(blocked) == 0 {
+		return results
+	}
+	out := results[:0:0]
+	for _, r := range results {
+		parsed, err := url.Parse(r.URL)
+		if err != nil {
+			continue
+		}
+		host := strings.ToLower(parsed.Hostname())
+		if len(allowed) > 0 && !matchesAnyDomain(host, allowed) {
+			continue
+		}
+		if matchesAnyDomain(host, blocked) {
+			continue
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+func matchesAnyDomain(host string, domains []string) bool {
+	for _, d := range domains {
+		d = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(d), "*."))
+		if d != "" && (host == d || strings.HasSuffix(host, "."+d)) {
+			return true
+		}
+	}
+	return false
+}
+
+func withQuery(endpoint string, values url.Values) string {
+	sep := "?"
+	if strings.Contains(endpoint, "?") {
+		sep = "&"
+	}
+	return endpoint + sep + values.Encode()
+}
+
+func titleFromSnippet(text string) string {
+	if idx := strings.Index(text, " - "); idx > 0 {
+		return text[:idx]
+	}
+	return text
+}
+
+func firstString(m map[string]any, keys ...string) string {
+	for _, k := range keys {
+		if s, ok := m[k].(string); ok {
+			if s = strings.TrimSpace(s); s != "" {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+func stringList(v any) []string {
+	switch t := v.(type) {
+	case []string:
+		return t
+	case []any:
+		out := make([]string, 0, len(t))
+		for _, item := range t {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func intFromAny(v any) (int, bool) {
+	switch t := v.(type) {
+	case int:
+		return t, true
+	case float64:
+		return int(t), true
+	case json.Number:
+		n, err := t.Int64()
+		return int(n), err == nil
+	}
+	return 0, false
+}
@@ -0,0 +1,214 @@
+package servertools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+
+	"ccgateway/internal/toolruntime"
+	"ccgateway/internal/workspace"
+)
+
+// Names of the workspace file tools. Read, Write, Edit and LS match the
+// tools Claude Code declares, so server_loop can run its file edits on the
+// gateway-hosted workspace.
+const (
+	WorkspaceReadName  = "Read"
+	WorkspaceWriteName = "Write"
+	WorkspaceEditName  = "Edit"
+	WorkspaceListName  = "LS"
+	WorkspaceDiffName  = "workspace_diff"
+)
+
+const defaultReadLimit = 2000
+
+// stateKeyWorkspace marks sessions using a workspace, so the directory is
+// released together with the session's tool state.
+const stateKeyWorkspace = "workspace.dir"
+
+// NewWorkspaceTools returns the read, write, edit, list and diff tools
+// working on the session directories of ws.
+func NewWorkspaceTools(ws *workspace.Manager) []Tool {
+	if ws == nil {
+		return nil
+	}
+	return []Tool{
+		&WorkspaceRead{ws: ws},
+		&WorkspaceWrite{ws: ws},
+		&WorkspaceEdit{ws: ws},
+		&WorkspaceList{ws: ws},
+		&WorkspaceDiff{ws: ws},
+	}
+}
+
+// WorkspaceRead prints a file with line numbers, optionally from a
+// 1-based offset and for limit lines.
+type WorkspaceRead struct {
+	ws *workspace.Manager
+}
+
+func (t *WorkspaceRead) Name() string { return WorkspaceReadName }
+
+func (t *WorkspaceRead) Execute(_ context.Context, call toolruntime.Call) (toolruntime.Result, error) {
+	touchWorkspace(t.ws, call)
+	path := firstString(call.Input, "file_path", "path")
+	raw, err := t.ws.Read(call.SessionID, path)
+	if err != nil {
+		return workspaceError(path, err), nil
+	}
+	if len(raw) == 0 {
+		return toolruntime.Result{Content: "(empty file)"}, nil
+	}
+	lines := strings.Split(strings.TrimSuffix(string(raw), "\n"), "\n")
+	offset, ok := intFromAny(call.Input["offset"])
+	if !ok || offset < 1 {
+		offset = 1
+	}
+	limit, ok := intFromAny(call.Input["limit"])
+	if !ok || limit <= 0 {
+		limit = defaultReadLimit
+	}
+	if offset > len(lines) {
+		return toolruntime.Result{IsError: true, Content: fmt.Sprintf("offset %d is past the end of %s (%d lines)", offset, path, len(lines))}, nil
+	}
+	end := min(offset-1+limit, len(lines))
+	var b strings.Builder
+	for i := offset; i <= end; i++ {
+		fmt.Fprintf(&b, "%6d\t%s\n", i, lines[i-1])
+	}
+	return toolruntime.Result{Content: b.String()}, nil
+}
+
+// WorkspaceWrite creates or overwrites a file.
+type WorkspaceWrite struct {
+	ws *workspace.Manager
+}
+
+func (t *WorkspaceWrite) Name() string { return WorkspaceWriteName }
+
+func (t *WorkspaceWrite) Execute(_ context.Context, call toolruntime.Call) (toolruntime.Result, error) {
+	touchWorkspace(t.ws, call)
+	path := firstString(call.Input, "file_path", "path")
+	content, ok := call.Input["content"].(string)
+	if !ok {
+		return toolruntime.Result{IsError: true, Content: "Write requires content"}, nil
+	}
+	_, readErr := t.ws.Read(call.SessionID, path)
+	if err := t.ws.Write(call.SessionID, path, []byte(content)); err != nil {
+		return workspaceError(path, err), nil
+	}
+	if readErr == nil {
+		return toolruntime.Result{Content: "The file " + path + " has been updated."}, nil
+	}
+	return toolruntime.Result{Content: "File created successfully at: " + path}, nil
+}
+
+// WorkspaceEdit replaces old_string with new_string in a file; old_string
+// must match exactly once unless replace_all is set.
+type WorkspaceEdit struct {
+	ws *workspace.Manager
+}
+
+func (t *WorkspaceEdit) Name() string { return WorkspaceEditName }
+
+func (t *WorkspaceEdit) Execute(_ context.Context, call toolruntime.Call) (toolruntime.Result, error) {
+	touchWorkspace(t.ws, call)
+	path := firstString(call.Input, "file_path", "path")
+	oldStr, _ := call.Input["old_string"].(string)
+	newStr, _ := call.Input["new_string"].(string)
+	replaceAll, _ := call.Input["replace_all"].(bool)
+	if oldStr == newStr {
+		return toolruntime.Result{IsError: true, Content: "old_string and new_string are the same"}, nil
+	}
+	raw, err := t.ws.Read(call.SessionID, path)
+	if err != nil {
+		return workspaceError(path, err), nil
+	}
+	content := string(raw)
+	n := strings.Count(content, oldStr)
+	switch {
+	case oldStr == "" || n == 0:
+		return toolruntime.Result{IsError: true, Content: "old_string not found in " + path}, nil
+	case n > 1 && !replaceAll:
+		return toolruntime.Result{IsError: true, Content: fmt.Sprintf("old_string matches %d times in %s; add context to make it unique or set replace_all", n, path)}, nil
+	}
+	if !replaceAll {
+		n = 1
+	}
+	if err := t.ws.Write(call.SessionID, path, []byte(strings.Replace(content, oldStr, newStr, n))); err != nil {
+		return workspaceError(path, err), nil
+	}
+	return toolruntime.Result{Content: fmt.Sprintf("The file %s has been updated (%d replacement(s)).", path, n)}, nil
+}
+
+// WorkspaceList lists a directory, directories first with a trailing
+// slash.
+type WorkspaceList struct {
+	ws *workspace.Manager
+}
+
+func (t *WorkspaceList) Name() string { return WorkspaceListName }
+
+func (t *WorkspaceList) Execute(_ context.Context, call toolruntime.Call) (toolruntime.Result, error) {
+	touchWorkspace(t.ws, call)
+	path := firstString(call.Input, "path", "directory")
+	entries, err := t.ws.List(call.SessionID, path)
+	if err != nil {
+		return workspaceError(path, err), nil
+	}
+	if len(entries) == 0 {
+		return toolruntime.Result{Content: "(empty directory)"}, nil
+	}
+	var b strings.Builder
+	for _, entry := range entries {
+		if entry.IsDir {
+			fmt.Fprintf(&b, "- %s/\n", entry.Path)
+			continue
+		}
+		fmt.Fprintf(&b, "- %s (%d bytes)\n", entry.Path, entry.Size)
+	}
+	return toolruntime.Result{Content: b.String()}, nil
+}
+
+// WorkspaceDiff shows the session's changes as a unified diff, for one
+// path or for every file the session wrote.
+type WorkspaceDiff struct {
+	ws *workspace.Manager
+}
+
+func (t *WorkspaceDiff) Name() string { return WorkspaceDiffName }
+
+func (t *WorkspaceDiff) Execute(_ context.Context, call toolruntime.Call) (toolruntime.Result, error) {
+	touchWorkspace(t.ws, call)
+	path := firstString(call.Input, "path", "file_path")
+	diff, err := t.ws.Diff(call.SessionID, path)
+	if err != nil {
+		return workspaceError(path, err), nil
+	}
+	if diff == "" {
+		return toolruntime.Result{Content: "No changes."}, nil
+	}
+	return toolruntime.Result{Content: diff}, nil
+}
+
+// touchWorkspace records the session's workspace directory in its tool
+// state, keeping both alive for the same idle period.
+func touchWorkspace(ws *workspace.Manager, call toolruntime.Call) {
+	if call.State == nil {
+		return
+	}
+	if dir, err := ws.Dir(call.SessionID); err == nil {
+		call.State.Set(stateKeyWorkspace, dir)
+	}
+}
+
+// workspaceError reports a failed file operation to the model as an error
+// result rather than failing the tool loop.
+func workspaceError(path string, err error) toolruntime.Result {
+	if errors.Is(err, fs.ErrNotExist) {
+		return toolruntime.Result{IsError: true, Content: "file does not exist: " + path}
+	}
+	return toolruntime.Result{IsError: true, Content: err.Error()}
+}
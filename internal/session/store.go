@@ -12,6 +12,8 @@ type Session struct {
 	ID        string           `json:"id"`
 	Type      string           `json:"type"`
 	ParentID  string           `json:"parent_id,omitempty"`
+	ProjectID string           `json:"project_id,omitempty"`
+	UserID    string           `json:"user_id,omitempty"`
 	Title     string           `json:"title,omitempty"`
 	Metadata  map[string]any   `json:"metadata,omitempty"`
 	Messages  []SessionMessage `json:"messages,omitempty"`
@@ -30,6 +32,12 @@ type CreateInput struct {
 	ID       string         `json:"id,omitempty"`
 	Title    string         `json:"title,omitempty"`
 	Metadata map[string]any `json:"metadata,omitempty"`
+	// ProjectID and UserID are set by the server from the request, never
+	// by the client.
+	ProjectID string `json:"-"`
+	UserID    string `json:"-"`
+	// Messages seeds the history; forks copy the parent's when it is nil.
+	Messages []SessionMessage `json:"-"`
 }
 
 type Store struct {
@@ -72,6 +80,15 @@ func (s *Store) Fork(parentID string, in CreateInput) (Session, error) {
 	if in.Metadata == nil {
 		in.Metadata = copyMetadata(parent.Metadata)
 	}
+	if strings.TrimSpace(in.ProjectID) == "" {
+		in.ProjectID = parent.ProjectID
+	}
+	if strings.TrimSpace(in.UserID) == "" {
+		in.UserID = parent.UserID
+	}
+	if in.Messages == nil {
+		in.Messages = parent.Messages
+	}
 	return s.createLocked(parentID, in)
 }
 
@@ -106,6 +123,26 @@ func (s *Store) List(limit int) []Session {
 	return out
 }
 
+// DeleteWhere removes every session for which match returns true and
+// returns the removed sessions.
+func (s *Store) DeleteWhere(match func(Session) bool) []Session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var removed []Session
+	order := s.order[:0]
+	for _, id := range s.order {
+		sess, ok := s.sessions[id]
+		if ok && match(sess) {
+			removed = append(removed, cloneSession(sess))
+			delete(s.sessions, id)
+			continue
+		}
+		order = append(order, id)
+	}
+	s.order = order
+	return removed
+}
+
 // AppendMessage adds a message to a session's conversation history.
 func (s *Store) AppendMessage(sessionID string, msg SessionMessage) error {
 	sessionID = strings.TrimSpace(sessionID)
@@ -160,8 +197,11 @@ func (s *Store) createLocked(parentID string, in CreateInput) (Session, error) {
 		ID:        id,
 		Type:      "session",
 		ParentID:  strings.TrimSpace(parentID),
+		ProjectID: strings.TrimSpace(in.ProjectID),
+		UserID:    strings.TrimSpace(in.UserID),
 		Title:     strings.TrimSpace(in.Title),
 		Metadata:  copyMetadata(in.Metadata),
+		Messages:  cloneMessages(in.Messages),
 		CreatedAt: now,
 		UpdatedAt: now,
 	}
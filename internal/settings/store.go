@@ -18,20 +18,239 @@ type RuntimeSettings struct {
 	VisionSupportHints     map[string]bool             `json:"vision_support_hints"`
 	ToolAliases            map[string]string           `json:"tool_aliases"`
 	PromptPrefixes         map[string]string           `json:"prompt_prefixes"`
+	PromptExperiments      map[string]PromptExperiment `json:"prompt_experiments,omitempty"`
+	ModeParams             map[string]ModeParams       `json:"mode_params,omitempty"`
 	AllowExperimentalTools bool                        `json:"allow_experimental_tools"`
 	AllowUnknownTools      bool                        `json:"allow_unknown_tools"`
+	AutoInjectMCPTools     bool                        `json:"auto_inject_mcp_tools"`
 	Routing                RoutingSettings             `json:"routing"`
 	ToolLoop               ToolLoopSettings            `json:"tool_loop"`
 	IntelligentDispatch    IntelligentDispatchSettings `json:"intelligent_dispatch"`
+	Memory                 MemorySettings              `json:"memory"`
+	Compaction             CompactionSettings          `json:"compaction"`
+	Policy                 PolicySettings              `json:"policy"`
 }
 
 type RoutingSettings struct {
-	Retries             int                 `json:"retries"`
-	ReflectionPasses    int                 `json:"reflection_passes"`
-	TimeoutMS           int                 `json:"timeout_ms"`
-	ParallelCandidates  int                 `json:"parallel_candidates"`
-	EnableResponseJudge bool                `json:"enable_response_judge"`
-	ModeRoutes          map[string][]string `json:"mode_routes"`
+	Retries               int                  `json:"retries"`
+	ReflectionPasses      int                  `json:"reflection_passes"`
+	TimeoutMS             int                  `json:"timeout_ms"`
+	ParallelCandidates    int                  `json:"parallel_candidates"`
+	EnableResponseJudge   bool                 `json:"enable_response_judge"`
+	RetryTruncatedStreams bool                 `json:"retry_truncated_streams"`
+	ModeRoutes            map[string][]string  `json:"mode_routes"`
+	Canary                CanarySettings       `json:"canary"`
+	Degradation           DegradationSettings  `json:"degradation"`
+	LoadShedding          LoadSheddingSettings `json:"load_shedding"`
+	Shadow                ShadowSettings       `json:"shadow"`
+	Hedge                 HedgeSettings        `json:"hedge"`
+	Judge                 JudgeSettings        `json:"judge"`
+	Admission             AdmissionSettings    `json:"admission"`
+}
+
+// Degradation ladder actions, in the order they are usually stacked.
+const (
+	DegradeDisableJudge      = "disable_judge"
+	DegradeDisableReflection = "disable_reflection"
+	DegradeShrinkMaxTokens   = "shrink_max_tokens"
+	DegradeCheapModel        = "cheap_model"
+	DegradeReject            = "reject"
+)
+
+// DegradationStep is one rung of the ladder. It becomes active when the
+// gateway has at least InFlight requests in flight or the upstream error
+// rate over the window reaches ErrorRate (0-1); zero disables a trigger.
+// Level N applies steps 1..N.
+type DegradationStep struct {
+	Action    string  `json:"action"`
+	MaxTokens int     `json:"max_tokens,omitempty"` // shrink_max_tokens
+	Model     string  `json:"model,omitempty"`      // cheap_model, an upstream model
+	InFlight  int     `json:"in_flight,omitempty"`
+	ErrorRate float64 `json:"error_rate,omitempty"`
+}
+
+// DegradationSettings configures automatic graceful degradation. Ladder
+// applies to every mode without an entry in ModeLadders.
+type DegradationSettings struct {
+	Enabled       bool                         `json:"enabled"`
+	WindowSeconds int                          `json:"window_seconds"`
+	MinSamples    int                          `json:"min_samples"`
+	Ladder        []DegradationStep            `json:"ladder"`
+	ModeLadders   map[string][]DegradationStep `json:"mode_ladders,omitempty"`
+}
+
+// LadderFor returns the ladder for mode.
+func (d DegradationSettings) LadderFor(mode string) []DegradationStep {
+	if steps, ok := d.ModeLadders[normalizeMode(mode)]; ok {
+		return steps
+	}
+	return d.Ladder
+}
+
+// LoadSheddingSettings drops low-priority traffic at the gateway while the
+// upstreams are saturated, instead of queueing it and adding to the
+// overload. Saturation is the share of adapter calls over the window that
+// came back 429 or 529. A request's class comes from the x-cc-priority
+// header or metadata.priority, defaulting to DefaultClass; classes without
+// a policy are never shed.
+type LoadSheddingSettings struct {
+	Enabled       bool                  `json:"enabled"`
+	WindowSeconds int                   `json:"window_seconds"`
+	MinSamples    int                   `json:"min_samples"`
+	DefaultClass  string                `json:"default_class"`
+	Classes       map[string]ShedPolicy `json:"classes,omitempty"`
+}
+
+// ShedPolicy sheds Percent (0-100) of a class's requests once the
+// saturation rate reaches SaturationRate (0-1).
+type ShedPolicy struct {
+	SaturationRate float64 `json:"saturation_rate"`
+	Percent        float64 `json:"percent"`
+}
+
+// AdmissionSettings ranks requests waiting for an adapter that is at its
+// max_concurrency; higher priorities are admitted first. A request's class
+// (x-cc-priority header or metadata.priority) is looked up in Classes;
+// without one, the caller's user group is looked up in Groups.
+type AdmissionSettings struct {
+	Classes         map[string]int `json:"classes,omitempty"`
+	Groups          map[string]int `json:"groups,omitempty"`
+	DefaultPriority int            `json:"default_priority"`
+}
+
+// Priority returns the admission priority for a class and user group.
+func (a AdmissionSettings) Priority(class, group string) int {
+	if p, ok := a.Classes[strings.ToLower(strings.TrimSpace(class))]; ok {
+		return p
+	}
+	if p, ok := a.Groups[strings.TrimSpace(group)]; ok {
+		return p
+	}
+	return a.DefaultPriority
+}
+
+// ShadowSettings mirrors a share of successful requests to shadow adapters.
+// Their answers are judged against the one the client got and recorded,
+// but never returned, so a new upstream can be evaluated on real traffic.
+type ShadowSettings struct {
+	Enabled  bool     `json:"enabled"`
+	Adapters []string `json:"adapters"`
+	Percent  float64  `json:"percent"`
+	// Modes limits mirroring to these request modes; empty means all.
+	Modes     []string `json:"modes,omitempty"`
+	TimeoutMS int      `json:"timeout_ms"`
+}
+
+// HedgeSettings sends a request to the next adapter of its route when the
+// first has not produced a token within AfterMS, and keeps whichever
+// answers first. Hedging trades extra upstream spend for tail latency.
+type HedgeSettings struct {
+	Enabled bool `json:"enabled"`
+	AfterMS int  `json:"after_ms"`
+	// Modes limits hedging to these request modes; empty means all.
+	Modes []string `json:"modes,omitempty"`
+}
+
+// JudgeDimension is one axis the response judge scores candidates on.
+// Weight sets its share of the overall score.
+type JudgeDimension struct {
+	Name        string  `json:"name"`
+	Weight      float64 `json:"weight"`
+	Description string  `json:"description,omitempty"`
+}
+
+// JudgeRubric tells the LLM judge how to compare candidates. Prompt is a
+// system prompt template; {{mode}} and {{dimensions}} are expanded per
+// request. With no dimensions the judge only names a winner.
+type JudgeRubric struct {
+	Prompt     string           `json:"prompt,omitempty"`
+	Dimensions []JudgeDimension `json:"dimensions,omitempty"`
+}
+
+// JudgeSettings configures the response judge rubric. Rubric applies to
+// every mode without an entry in ModeRubrics.
+type JudgeSettings struct {
+	Rubric      JudgeRubric            `json:"rubric"`
+	ModeRubrics map[string]JudgeRubric `json:"mode_rubrics,omitempty"`
+}
+
+// RubricFor returns the rubric for mode.
+func (j JudgeSettings) RubricFor(mode string) JudgeRubric {
+	if r, ok := j.ModeRubrics[normalizeMode(mode)]; ok {
+		return r
+	}
+	return j.Rubric
+}
+
+// PromptVariant is one system prompt prefix in a prompt experiment.
+type PromptVariant struct {
+	Name   string `json:"name"`
+	Prefix string `json:"prefix"`
+	// Weight is the variant's share of traffic relative to the others.
+	Weight float64 `json:"weight"`
+}
+
+// PromptExperiment splits a mode's traffic across prompt prefix variants,
+// replacing prompt_prefixes for that mode while enabled. With AutoPromote
+// the gateway promotes the best-scoring variant once every variant has
+// MinSamples scored runs and the winner beats each rival with at least
+// Confidence; after that all traffic gets Promoted.
+type PromptExperiment struct {
+	Enabled     bool            `json:"enabled"`
+	Variants    []PromptVariant `json:"variants"`
+	StickyBy    string          `json:"sticky_by"`
+	AutoPromote bool            `json:"auto_promote"`
+	MinSamples  int             `json:"min_samples"`
+	Confidence  float64         `json:"confidence"`
+	Promoted    string          `json:"promoted,omitempty"`
+}
+
+// Variant returns the named variant.
+func (e PromptExperiment) Variant(name string) (PromptVariant, bool) {
+	for _, v := range e.Variants {
+		if v.Name == name {
+			return v, true
+		}
+	}
+	return PromptVariant{}, false
+}
+
+// ModeParams overrides sampling for one mode. Temperature and TopP replace
+// the request's values, MaxTokens caps max_tokens and StopSequences are
+// added to the request's own.
+type ModeParams struct {
+	Temperature   *float64 `json:"temperature,omitempty"`
+	TopP          *float64 `json:"top_p,omitempty"`
+	MaxTokens     int      `json:"max_tokens,omitempty"`
+	StopSequences []string `json:"stop_sequences,omitempty"`
+}
+
+// IsZero reports whether p overrides nothing.
+func (p ModeParams) IsZero() bool {
+	return p.Temperature == nil && p.TopP == nil && p.MaxTokens == 0 && len(p.StopSequences) == 0
+}
+
+// Canary cohort keys.
+const (
+	CanaryStickyRequest = "request"
+	CanaryStickyUser    = "user"
+	CanaryStickyToken   = "token"
+)
+
+// CanarySettings sends a share of traffic to an alternative adapter route.
+// With sticky_by user or token, whole users/tokens are hashed into the
+// canary cohort so each caller sees one variant for the whole experiment.
+type CanarySettings struct {
+	Enabled bool     `json:"enabled"`
+	Name    string   `json:"name"`
+	Percent float64  `json:"percent"`
+	Route   []string `json:"route"`
+	// Modes limits the canary to these request modes; empty means all.
+	Modes    []string `json:"modes,omitempty"`
+	StickyBy string   `json:"sticky_by"`
+	// Users and Tokens are always placed in the canary cohort.
+	Users  []string `json:"users,omitempty"`
+	Tokens []string `json:"tokens,omitempty"`
 }
 
 type ToolLoopSettings struct {
@@ -39,6 +258,148 @@ type ToolLoopSettings struct {
 	MaxSteps      int    `json:"max_steps"`
 	EmulationMode string `json:"emulation_mode"`
 	PlannerModel  string `json:"planner_model"`
+	// Citations labels server-loop tool results as [S#] sources, asks the
+	// model to cite them inline and returns the cited chunks in the
+	// response metadata.
+	Citations bool `json:"citations"`
+	// Stream selects how streamed server-loop requests reach the client:
+	// "buffered" replays the finished loop, "incremental" forwards each
+	// round's deltas as they arrive with tool progress blocks in between.
+	Stream string `json:"stream,omitempty"`
+	// ResultLimits caps the size of tool results fed back into the loop,
+	// keyed by tool name; "*" applies to tools without their own entry.
+	ResultLimits map[string]ToolResultLimit `json:"result_limits,omitempty"`
+	// Approval pauses the loop before matching tools run until a reviewer
+	// approves or denies the call.
+	Approval ToolApprovalSettings `json:"approval,omitempty"`
+}
+
+// ToolApprovalSettings lists the tools (names or path.Match globs) whose
+// server-loop calls wait for a human decision. Calls not decided within
+// TimeoutSeconds are resolved by OnTimeout: "deny" (the default) or
+// "approve".
+type ToolApprovalSettings struct {
+	Tools          []string `json:"tools,omitempty"`
+	TimeoutSeconds int      `json:"timeout_seconds,omitempty"`
+	OnTimeout      string   `json:"on_timeout,omitempty"`
+}
+
+// PolicySettings are the declarative allow/deny rules every model request
+// is checked against (see policy.EvaluateRules). DefaultEffect applies when
+// no rule matches: "allow" (the default) or "deny".
+type PolicySettings struct {
+	Rules         []PolicyRule `json:"rules,omitempty"`
+	DefaultEffect string       `json:"default_effect,omitempty"`
+}
+
+// PolicyRule is one declarative rule. Every non-empty matcher list must
+// match (glob patterns, case-insensitive); an empty list matches anything.
+type PolicyRule struct {
+	ID          string   `json:"id"`
+	Description string   `json:"description,omitempty"`
+	Effect      string   `json:"effect"`
+	Priority    int      `json:"priority,omitempty"`
+	Paths       []string `json:"paths,omitempty"`
+	Models      []string `json:"models,omitempty"`
+	Modes       []string `json:"modes,omitempty"`
+	Tools       []string `json:"tools,omitempty"`
+	Adapters    []string `json:"adapters,omitempty"`
+	Projects    []string `json:"projects,omitempty"`
+	UserIDs     []string `json:"user_ids,omitempty"`
+	Groups      []string `json:"groups,omitempty"`
+	Roles       []string `json:"roles,omitempty"`
+	TokenNames  []string `json:"token_names,omitempty"`
+}
+
+// Tool approval timeout outcomes.
+const (
+	ToolApprovalDeny    = "deny"
+	ToolApprovalApprove = "approve"
+)
+
+// ToolResultLimit caps one tool's result at MaxChars characters. Strategy
+// "head" keeps the start, "tail" the end and "summarize" asks Model (empty
+// uses the request model) to condense the result, falling back to head
+// when the summary fails or is still too long.
+type ToolResultLimit struct {
+	MaxChars int    `json:"max_chars"`
+	Strategy string `json:"strategy,omitempty"`
+	Model    string `json:"model,omitempty"`
+}
+
+// Tool result truncation strategies.
+const (
+	ToolResultHead      = "head"
+	ToolResultTail      = "tail"
+	ToolResultSummarize = "summarize"
+)
+
+// Tool loop stream modes.
+const (
+	ToolLoopStreamBuffered    = "buffered"
+	ToolLoopStreamIncremental = "incremental"
+)
+
+// MemorySettings controls per-session conversation memory. Once a session
+// has SummarizeAfter remembered messages, the older ones are distilled into
+// a summary and facts with SummarizerModel (empty uses the summarizer's
+// default) and injected into the system prompt of later requests; the
+// latest KeepRecent messages stay verbatim for the next distillation.
+type MemorySettings struct {
+	Enabled         bool   `json:"enabled"`
+	SummarizerModel string `json:"summarizer_model"`
+	SummarizeAfter  int    `json:"summarize_after"`
+	KeepRecent      int    `json:"keep_recent"`
+	MaxFacts        int    `json:"max_facts"`
+}
+
+// Context compaction strategies, applied in the configured order until the
+// request fits the target model's context window.
+const (
+	CompactDropToolResults = "drop_tool_results"
+	CompactSummarize       = "summarize"
+	CompactDropOldest      = "drop_oldest"
+)
+
+// CompactionSettings shrinks requests whose history no longer fits the
+// upstream model. ContextWindows maps upstream models to their window in
+// tokens; DefaultContextWindow covers the rest (0 leaves them alone).
+// ModeStrategies overrides Strategies per mode. The last KeepRecent
+// messages are never compacted; summaries use SummarizerModel, or the
+// request's model when empty.
+type CompactionSettings struct {
+	Enabled              bool                `json:"enabled"`
+	ContextWindows       map[string]int      `json:"context_windows,omitempty"`
+	DefaultContextWindow int                 `json:"default_context_window"`
+	Strategies           []string            `json:"strategies"`
+	ModeStrategies       map[string][]string `json:"mode_strategies,omitempty"`
+	SummarizerModel      string              `json:"summarizer_model"`
+	KeepRecent           int                 `json:"keep_recent"`
+}
+
+// StrategiesFor returns the compaction strategies for mode.
+func (c CompactionSettings) StrategiesFor(mode string) []string {
+	if list, ok := c.ModeStrategies[normalizeMode(mode)]; ok {
+		return list
+	}
+	return c.Strategies
+}
+
+// ContextWindow returns the window of model: an exact entry, else the
+// longest matching "prefix*" entry, else DefaultContextWindow.
+func (c CompactionSettings) ContextWindow(model string) int {
+	model = strings.TrimSpace(model)
+	if size, ok := c.ContextWindows[model]; ok {
+		return size
+	}
+	best, size := -1, c.DefaultContextWindow
+	for pattern, n := range c.ContextWindows {
+		prefix, ok := strings.CutSuffix(pattern, "*")
+		if ok && strings.HasPrefix(model, prefix) && len(prefix) > best {
+			best, size = len(prefix), n
+		}
+	}
+	return size
 }
 
 // IntelligentDispatchSettings 智能调度设置
@@ -49,6 +410,17 @@ type IntelligentDispatchSettings struct {
 	FallbackToScheduler  bool                           `json:"fallback_to_scheduler"` // 失败时回退到调度器
 	ModelPolicies        map[string]ModelDispatchPolicy `json:"model_policies"`        // 按模型配置调度策略
 	ComplexityThresholds ComplexityThresholds           `json:"complexity_thresholds"` // 复杂度阈值
+	// AdapterPrices lists each adapter's price; together with
+	// PreferCheapestWithinScoreDelta it sends simple requests to the cheapest
+	// worker scoring within that many points of the best one (0 disables).
+	AdapterPrices                  map[string]AdapterPrice `json:"adapter_prices"`
+	PreferCheapestWithinScoreDelta float64                 `json:"prefer_cheapest_within_score_delta"`
+}
+
+// AdapterPrice 适配器价格(美元/百万token)
+type AdapterPrice struct {
+	InputPerMTok  float64 `json:"input_per_mtok"`
+	OutputPerMTok float64 `json:"output_per_mtok"`
 }
 
 // ModelDispatchPolicy 模型调度策略
@@ -101,11 +473,21 @@ func DefaultRuntimeSettings() RuntimeSettings {
 			ReElectIntervalMS:   600000, // 10分钟
 			FallbackToScheduler: true,   // 失败时回退到调度器
 			ModelPolicies:       map[string]ModelDispatchPolicy{},
+			AdapterPrices:       map[string]AdapterPrice{},
 			ComplexityThresholds: ComplexityThresholds{
 				LongContextChars:   4000,
 				ToolCountThreshold: 1,
 			},
 		},
+		Memory: MemorySettings{
+			SummarizeAfter: 10,
+			KeepRecent:     4,
+			MaxFacts:       20,
+		},
+		Compaction: CompactionSettings{
+			Strategies: []string{CompactDropToolResults, CompactSummarize},
+			KeepRecent: 6,
+		},
 	}
 }
 
@@ -115,18 +497,28 @@ func NewStore(initial RuntimeSettings) *Store {
 }
 
 func NewFromEnv() (*Store, error) {
-	defaults := DefaultRuntimeSettings()
 	raw := strings.TrimSpace(os.Getenv("RUNTIME_SETTINGS_JSON"))
 	if raw == "" {
-		return NewStore(defaults), nil
+		return NewStore(DefaultRuntimeSettings()), nil
 	}
-	var rawMap map[string]json.RawMessage
-	if err := json.Unmarshal([]byte(raw), &rawMap); err != nil {
+	parsed, err := Parse([]byte(raw))
+	if err != nil {
 		return nil, fmt.Errorf("invalid RUNTIME_SETTINGS_JSON: %w", err)
 	}
+	return NewStore(parsed), nil
+}
+
+// Parse reads settings JSON the way RUNTIME_SETTINGS_JSON is read: fields
+// that are absent keep their defaults.
+func Parse(raw []byte) (RuntimeSettings, error) {
+	defaults := DefaultRuntimeSettings()
+	var rawMap map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &rawMap); err != nil {
+		return RuntimeSettings{}, err
+	}
 	var parsed RuntimeSettings
-	if err := json.Unmarshal([]byte(raw), &parsed); err != nil {
-		return nil, fmt.Errorf("invalid RUNTIME_SETTINGS_JSON: %w", err)
+	if err := json.Unmarshal(raw, &parsed); err != nil {
+		return RuntimeSettings{}, err
 	}
 	merged := merge(defaults, parsed)
 	if dispatchRaw, ok := rawMap["intelligent_dispatch"]; ok {
@@ -143,7 +535,7 @@ func NewFromEnv() (*Store, error) {
 		merged.IntelligentDispatch.Enabled = defaults.IntelligentDispatch.Enabled
 		merged.IntelligentDispatch.FallbackToScheduler = defaults.IntelligentDispatch.FallbackToScheduler
 	}
-	return NewStore(merged), nil
+	return sanitize(merged), nil
 }
 
 func (s *Store) Get() RuntimeSettings {
@@ -246,6 +638,31 @@ func (s *Store) PromptPrefix(mode string) string {
 	return strings.TrimSpace(cfg.PromptPrefixes["default"])
 }
 
+// PromptExperiment returns the enabled prompt experiment for mode.
+func (s *Store) PromptExperiment(mode string) (PromptExperiment, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	exp, ok := s.data.PromptExperiments[normalizeMode(mode)]
+	if !ok || !exp.Enabled || len(exp.Variants) == 0 {
+		return PromptExperiment{}, false
+	}
+	return clonePromptExperiment(exp), true
+}
+
+// ModeParamsFor returns the sampling overrides for mode, falling back to
+// the "default" entry.
+func (s *Store) ModeParamsFor(mode string) (ModeParams, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if p, ok := s.data.ModeParams[normalizeMode(mode)]; ok {
+		return cloneModeParams(p), true
+	}
+	if p, ok := s.data.ModeParams["default"]; ok {
+		return cloneModeParams(p), true
+	}
+	return ModeParams{}, false
+}
+
 func (s *Store) ModeRoute(mode string) []string {
 	mode = normalizeMode(mode)
 	cfg := s.Get()
@@ -261,6 +678,46 @@ func (s *Store) ModeRoute(mode string) []string {
 	return nil
 }
 
+// Overlay returns a detached store holding the current settings with raw, a
+// partial settings object, merged over them: nested objects merge key by
+// key, anything else replaces the current value.
+func (s *Store) Overlay(raw json.RawMessage) (*Store, error) {
+	base, err := json.Marshal(s.Get())
+	if err != nil {
+		return nil, err
+	}
+	var current map[string]any
+	if err := json.Unmarshal(base, &current); err != nil {
+		return nil, err
+	}
+	var patch map[string]any
+	if err := json.Unmarshal(raw, &patch); err != nil {
+		return nil, fmt.Errorf("settings overlay must be a JSON object: %w", err)
+	}
+	merged, err := json.Marshal(mergeObjects(current, patch))
+	if err != nil {
+		return nil, err
+	}
+	var out RuntimeSettings
+	if err := json.Unmarshal(merged, &out); err != nil {
+		return nil, fmt.Errorf("invalid settings overlay: %w", err)
+	}
+	return NewStore(out), nil
+}
+
+func mergeObjects(dst, src map[string]any) map[string]any {
+	for k, v := range src {
+		if next, ok := v.(map[string]any); ok {
+			if prev, ok := dst[k].(map[string]any); ok {
+				dst[k] = mergeObjects(prev, next)
+				continue
+			}
+		}
+		dst[k] = v
+	}
+	return dst
+}
+
 func normalizeMode(mode string) string {
 	mode = strings.ToLower(strings.TrimSpace(mode))
 	if mode == "" {
@@ -286,14 +743,24 @@ func merge(defaults, in RuntimeSettings) RuntimeSettings {
 	if in.PromptPrefixes != nil {
 		out.PromptPrefixes = copyStringMap(in.PromptPrefixes)
 	}
+	out.PromptExperiments = clonePromptExperiments(in.PromptExperiments)
+	out.ModeParams = copyModeParams(in.ModeParams)
 	if in.Routing.ModeRoutes != nil {
 		out.Routing.ModeRoutes = copyModeRoutes(in.Routing.ModeRoutes)
 	}
+	out.Routing.Canary = in.Routing.Canary
+	out.Routing.Degradation = in.Routing.Degradation
+	out.Routing.LoadShedding = in.Routing.LoadShedding
+	out.Routing.Shadow = in.Routing.Shadow
+	out.Routing.Hedge = in.Routing.Hedge
+	out.Routing.Judge = in.Routing.Judge
+	out.Routing.Admission = in.Routing.Admission
 	out.UseModeModelOverride = in.UseModeModelOverride
 	out.ModelMapStrict = in.ModelMapStrict
 	out.ModelMapFallback = strings.TrimSpace(in.ModelMapFallback)
 	out.AllowExperimentalTools = in.AllowExperimentalTools
 	out.AllowUnknownTools = in.AllowUnknownTools
+	out.AutoInjectMCPTools = in.AutoInjectMCPTools
 	if in.Routing.Retries != 0 {
 		out.Routing.Retries = in.Routing.Retries
 	}
@@ -307,6 +774,7 @@ func merge(defaults, in RuntimeSettings) RuntimeSettings {
 		out.Routing.ParallelCandidates = in.Routing.ParallelCandidates
 	}
 	out.Routing.EnableResponseJudge = in.Routing.EnableResponseJudge
+	out.Routing.RetryTruncatedStreams = in.Routing.RetryTruncatedStreams
 	if strings.TrimSpace(in.ToolLoop.Mode) != "" {
 		out.ToolLoop.Mode = strings.TrimSpace(in.ToolLoop.Mode)
 	}
@@ -319,6 +787,21 @@ func merge(defaults, in RuntimeSettings) RuntimeSettings {
 	if strings.TrimSpace(in.ToolLoop.PlannerModel) != "" {
 		out.ToolLoop.PlannerModel = strings.TrimSpace(in.ToolLoop.PlannerModel)
 	}
+	if strings.TrimSpace(in.ToolLoop.Stream) != "" {
+		out.ToolLoop.Stream = in.ToolLoop.Stream
+	}
+	if in.ToolLoop.ResultLimits != nil {
+		out.ToolLoop.ResultLimits = copyToolResultLimits(in.ToolLoop.ResultLimits)
+	}
+	if in.ToolLoop.Approval.Tools != nil {
+		out.ToolLoop.Approval.Tools = copyStringList(in.ToolLoop.Approval.Tools)
+	}
+	if in.ToolLoop.Approval.TimeoutSeconds != 0 {
+		out.ToolLoop.Approval.TimeoutSeconds = in.ToolLoop.Approval.TimeoutSeconds
+	}
+	if strings.TrimSpace(in.ToolLoop.Approval.OnTimeout) != "" {
+		out.ToolLoop.Approval.OnTimeout = in.ToolLoop.Approval.OnTimeout
+	}
 	// IntelligentDispatch settings - allow explicit false to disable
 	out.IntelligentDispatch.Enabled = in.IntelligentDispatch.Enabled
 	if in.IntelligentDispatch.MinScoreDifference > 0 {
@@ -340,6 +823,39 @@ func merge(defaults, in RuntimeSettings) RuntimeSettings {
 	if in.IntelligentDispatch.ComplexityThresholds.ToolCountThreshold > 0 {
 		out.IntelligentDispatch.ComplexityThresholds.ToolCountThreshold = in.IntelligentDispatch.ComplexityThresholds.ToolCountThreshold
 	}
+	if in.IntelligentDispatch.AdapterPrices != nil {
+		out.IntelligentDispatch.AdapterPrices = copyAdapterPrices(in.IntelligentDispatch.AdapterPrices)
+	}
+	out.IntelligentDispatch.PreferCheapestWithinScoreDelta = in.IntelligentDispatch.PreferCheapestWithinScoreDelta
+	out.Memory.Enabled = in.Memory.Enabled
+	out.Memory.SummarizerModel = strings.TrimSpace(in.Memory.SummarizerModel)
+	if in.Memory.SummarizeAfter != 0 {
+		out.Memory.SummarizeAfter = in.Memory.SummarizeAfter
+	}
+	if in.Memory.KeepRecent != 0 {
+		out.Memory.KeepRecent = in.Memory.KeepRecent
+	}
+	if in.Memory.MaxFacts != 0 {
+		out.Memory.MaxFacts = in.Memory.MaxFacts
+	}
+	out.Compaction.Enabled = in.Compaction.Enabled
+	if in.Compaction.ContextWindows != nil {
+		out.Compaction.ContextWindows = copyIntMap(in.Compaction.ContextWindows)
+	}
+	if in.Compaction.DefaultContextWindow != 0 {
+		out.Compaction.DefaultContextWindow = in.Compaction.DefaultContextWindow
+	}
+	if in.Compaction.Strategies != nil {
+		out.Compaction.Strategies = copyStringList(in.Compaction.Strategies)
+	}
+	if in.Compaction.ModeStrategies != nil {
+		out.Compaction.ModeStrategies = copyModeRoutes(in.Compaction.ModeStrategies)
+	}
+	out.Compaction.SummarizerModel = strings.TrimSpace(in.Compaction.SummarizerModel)
+	if in.Compaction.KeepRecent != 0 {
+		out.Compaction.KeepRecent = in.Compaction.KeepRecent
+	}
+	out.Policy = clonePolicy(in.Policy)
 	return sanitize(out)
 }
 
@@ -361,6 +877,8 @@ func sanitize(in RuntimeSettings) RuntimeSettings {
 	if out.PromptPrefixes == nil {
 		out.PromptPrefixes = map[string]string{}
 	}
+	out.PromptExperiments = sanitizePromptExperiments(out.PromptExperiments)
+	out.ModeParams = sanitizeModeParams(out.ModeParams)
 	if out.Routing.ModeRoutes == nil {
 		out.Routing.ModeRoutes = map[string][]string{}
 	}
@@ -400,6 +918,20 @@ func sanitize(in RuntimeSettings) RuntimeSettings {
 		out.ToolLoop.EmulationMode = "native"
 	}
 	out.ToolLoop.PlannerModel = strings.TrimSpace(out.ToolLoop.PlannerModel)
+	if stream := strings.ToLower(strings.TrimSpace(out.ToolLoop.Stream)); stream == ToolLoopStreamIncremental {
+		out.ToolLoop.Stream = stream
+	} else {
+		out.ToolLoop.Stream = ToolLoopStreamBuffered
+	}
+	out.ToolLoop.ResultLimits = sanitizeToolResultLimits(out.ToolLoop.ResultLimits)
+	out.ToolLoop.Approval = sanitizeToolApproval(out.ToolLoop.Approval)
+	out.Routing.Canary = sanitizeCanary(out.Routing.Canary)
+	out.Routing.Degradation = sanitizeDegradation(out.Routing.Degradation)
+	out.Routing.LoadShedding = sanitizeLoadShedding(out.Routing.LoadShedding)
+	out.Routing.Shadow = sanitizeShadow(out.Routing.Shadow)
+	out.Routing.Hedge = sanitizeHedge(out.Routing.Hedge)
+	out.Routing.Judge = sanitizeJudge(out.Routing.Judge)
+	out.Routing.Admission = sanitizeAdmission(out.Routing.Admission)
 	// IntelligentDispatch validation
 	if out.IntelligentDispatch.MinScoreDifference <= 0 {
 		out.IntelligentDispatch.MinScoreDifference = 5.0
@@ -416,6 +948,79 @@ func sanitize(in RuntimeSettings) RuntimeSettings {
 	if out.IntelligentDispatch.ComplexityThresholds.ToolCountThreshold <= 0 {
 		out.IntelligentDispatch.ComplexityThresholds.ToolCountThreshold = 1
 	}
+	out.IntelligentDispatch.AdapterPrices = copyAdapterPrices(out.IntelligentDispatch.AdapterPrices)
+	if out.IntelligentDispatch.PreferCheapestWithinScoreDelta < 0 {
+		out.IntelligentDispatch.PreferCheapestWithinScoreDelta = 0
+	}
+	out.Memory = sanitizeMemory(out.Memory)
+	out.Compaction = sanitizeCompaction(out.Compaction)
+	if strings.ToLower(strings.TrimSpace(out.Policy.DefaultEffect)) == "deny" {
+		out.Policy.DefaultEffect = "deny"
+	} else {
+		out.Policy.DefaultEffect = "allow"
+	}
+	return out
+}
+
+// sanitizeCompaction drops unknown strategies and non-positive windows and
+// normalizes mode keys.
+func sanitizeCompaction(in CompactionSettings) CompactionSettings {
+	out := in
+	windows := map[string]int{}
+	for model, size := range in.ContextWindows {
+		model = strings.TrimSpace(model)
+		if model != "" && size > 0 {
+			windows[model] = size
+		}
+	}
+	out.ContextWindows = windows
+	if out.DefaultContextWindow < 0 {
+		out.DefaultContextWindow = 0
+	}
+	out.Strategies = sanitizeCompactionStrategies(in.Strategies)
+	modes := map[string][]string{}
+	for mode, list := range in.ModeStrategies {
+		modes[normalizeMode(mode)] = sanitizeCompactionStrategies(list)
+	}
+	out.ModeStrategies = modes
+	out.SummarizerModel = strings.TrimSpace(out.SummarizerModel)
+	if out.KeepRecent <= 0 {
+		out.KeepRecent = 6
+	}
+	return out
+}
+
+func sanitizeCompactionStrategies(in []string) []string {
+	out := []string{}
+	seen := map[string]bool{}
+	for _, name := range in {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch name {
+		case CompactDropToolResults, CompactSummarize, CompactDropOldest:
+			if !seen[name] {
+				seen[name] = true
+				out = append(out, name)
+			}
+		}
+	}
+	return out
+}
+
+func sanitizeMemory(in MemorySettings) MemorySettings {
+	out := in
+	out.SummarizerModel = strings.TrimSpace(out.SummarizerModel)
+	if out.SummarizeAfter <= 0 {
+		out.SummarizeAfter = 10
+	}
+	if out.KeepRecent < 0 {
+		out.KeepRecent = 0
+	}
+	if out.KeepRecent >= out.SummarizeAfter {
+		out.KeepRecent = out.SummarizeAfter - 1
+	}
+	if out.MaxFacts <= 0 {
+		out.MaxFacts = 20
+	}
 	return out
 }
 
@@ -426,8 +1031,517 @@ func clone(in RuntimeSettings) RuntimeSettings {
 	out.VisionSupportHints = copyBoolMap(in.VisionSupportHints)
 	out.ToolAliases = copyStringMap(in.ToolAliases)
 	out.PromptPrefixes = copyStringMap(in.PromptPrefixes)
+	out.PromptExperiments = clonePromptExperiments(in.PromptExperiments)
+	out.ModeParams = copyModeParams(in.ModeParams)
+	out.ToolLoop.ResultLimits = copyToolResultLimits(in.ToolLoop.ResultLimits)
+	out.ToolLoop.Approval.Tools = copyStringList(in.ToolLoop.Approval.Tools)
 	out.Routing.ModeRoutes = copyModeRoutes(in.Routing.ModeRoutes)
+	out.Routing.Canary = cloneCanary(in.Routing.Canary)
+	out.Routing.Degradation = cloneDegradation(in.Routing.Degradation)
+	out.Routing.LoadShedding = cloneLoadShedding(in.Routing.LoadShedding)
+	out.Routing.Shadow = cloneShadow(in.Routing.Shadow)
+	out.Routing.Hedge = cloneHedge(in.Routing.Hedge)
+	out.Routing.Judge = cloneJudge(in.Routing.Judge)
+	out.Routing.Admission = cloneAdmission(in.Routing.Admission)
 	out.IntelligentDispatch.ModelPolicies = copyModelPolicies(in.IntelligentDispatch.ModelPolicies)
+	out.IntelligentDispatch.AdapterPrices = copyAdapterPrices(in.IntelligentDispatch.AdapterPrices)
+	out.Compaction.ContextWindows = copyIntMap(in.Compaction.ContextWindows)
+	out.Compaction.Strategies = copyStringList(in.Compaction.Strategies)
+	out.Compaction.ModeStrategies = copyModeRoutes(in.Compaction.ModeStrategies)
+	out.Policy = clonePolicy(in.Policy)
+	return out
+}
+
+func cloneCanary(in CanarySettings) CanarySettings {
+	out := in
+	out.Route = copyStringList(in.Route)
+	out.Modes = copyStringList(in.Modes)
+	out.Users = copyStringList(in.Users)
+	out.Tokens = copyStringList(in.Tokens)
+	return out
+}
+
+func sanitizeCanary(in CanarySettings) CanarySettings {
+	out := cloneCanary(in)
+	out.Name = strings.TrimSpace(out.Name)
+	if out.Name == "" {
+		out.Name = "canary"
+	}
+	if out.Percent < 0 {
+		out.Percent = 0
+	}
+	if out.Percent > 100 {
+		out.Percent = 100
+	}
+	for i, mode := range out.Modes {
+		out.Modes[i] = normalizeMode(mode)
+	}
+	switch sticky := strings.ToLower(strings.TrimSpace(out.StickyBy)); sticky {
+	case CanaryStickyUser, CanaryStickyToken:
+		out.StickyBy = sticky
+	default:
+		out.StickyBy = CanaryStickyRequest
+	}
+	return out
+}
+
+func cloneDegradation(in DegradationSettings) DegradationSettings {
+	out := in
+	out.Ladder = copySteps(in.Ladder)
+	out.ModeLadders = nil
+	if len(in.ModeLadders) > 0 {
+		out.ModeLadders = make(map[string][]DegradationStep, len(in.ModeLadders))
+		for mode, steps := range in.ModeLadders {
+			out.ModeLadders[mode] = copySteps(steps)
+		}
+	}
+	return out
+}
+
+func copySteps(in []DegradationStep) []DegradationStep {
+	if len(in) == 0 {
+		return nil
+	}
+	return append([]DegradationStep(nil), in...)
+}
+
+func sanitizeDegradation(in DegradationSettings) DegradationSettings {
+	out := cloneDegradation(in)
+	if out.WindowSeconds <= 0 {
+		out.WindowSeconds = 60
+	}
+	if out.WindowSeconds > 600 {
+		out.WindowSeconds = 600
+	}
+	if out.MinSamples <= 0 {
+		out.MinSamples = 20
+	}
+	out.Ladder = sanitizeSteps(out.Ladder)
+	if len(out.ModeLadders) > 0 {
+		ladders := make(map[string][]DegradationStep, len(out.ModeLadders))
+		for mode, steps := range out.ModeLadders {
+			ladders[normalizeMode(mode)] = sanitizeSteps(steps)
+		}
+		out.ModeLadders = ladders
+	}
+	return out
+}
+
+// sanitizeSteps drops steps with an unknown action or missing parameter.
+func sanitizeSteps(in []DegradationStep) []DegradationStep {
+	out := make([]DegradationStep, 0, len(in))
+	for _, step := range in {
+		step.Action = strings.ToLower(strings.TrimSpace(step.Action))
+		step.Model = strings.TrimSpace(step.Model)
+		switch step.Action {
+		case DegradeDisableJudge, DegradeDisableReflection, DegradeReject:
+		case DegradeShrinkMaxTokens:
+			if step.MaxTokens <= 0 {
+				continue
+			}
+		case DegradeCheapModel:
+			if step.Model == "" {
+				continue
+			}
+		default:
+			continue
+		}
+		if step.InFlight < 0 {
+			step.InFlight = 0
+		}
+		if step.ErrorRate < 0 {
+			step.ErrorRate = 0
+		}
+		if step.ErrorRate > 1 {
+			step.ErrorRate = 1
+		}
+		out = append(out, step)
+	}
+	return out
+}
+
+func cloneLoadShedding(in LoadSheddingSettings) LoadSheddingSettings {
+	out := in
+	out.Classes = nil
+	if len(in.Classes) > 0 {
+		out.Classes = make(map[string]ShedPolicy, len(in.Classes))
+		for class, p := range in.Classes {
+			out.Classes[class] = p
+		}
+	}
+	return out
+}
+
+// sanitizeLoadShedding lower-cases class names and drops policies with no
+// usable threshold.
+func sanitizeLoadShedding(in LoadSheddingSettings) LoadSheddingSettings {
+	out := in
+	if out.WindowSeconds <= 0 {
+		out.WindowSeconds = 30
+	}
+	if out.WindowSeconds > 600 {
+		out.WindowSeconds = 600
+	}
+	if out.MinSamples <= 0 {
+		out.MinSamples = 20
+	}
+	out.DefaultClass = strings.ToLower(strings.TrimSpace(out.DefaultClass))
+	if out.DefaultClass == "" {
+		out.DefaultClass = "normal"
+	}
+	out.Classes = nil
+	for class, p := range in.Classes {
+		class = strings.ToLower(strings.TrimSpace(class))
+		if class == "" || p.SaturationRate <= 0 || p.SaturationRate > 1 {
+			continue
+		}
+		if p.Percent <= 0 || p.Percent > 100 {
+			p.Percent = 100
+		}
+		if out.Classes == nil {
+			out.Classes = map[string]ShedPolicy{}
+		}
+		out.Classes[class] = p
+	}
+	return out
+}
+
+func cloneShadow(in ShadowSettings) ShadowSettings {
+	out := in
+	out.Adapters = copyStringList(in.Adapters)
+	out.Modes = copyStringList(in.Modes)
+	return out
+}
+
+func sanitizeShadow(in ShadowSettings) ShadowSettings {
+	out := in
+	out.Adapters = nil
+	for _, name := range in.Adapters {
+		if name = strings.TrimSpace(name); name != "" {
+			out.Adapters = append(out.Adapters, name)
+		}
+	}
+	out.Modes = nil
+	for _, mode := range in.Modes {
+		out.Modes = append(out.Modes, normalizeMode(mode))
+	}
+	if out.Percent < 0 {
+		out.Percent = 0
+	}
+	if out.Percent > 100 {
+		out.Percent = 100
+	}
+	if out.TimeoutMS < 0 {
+		out.TimeoutMS = 0
+	}
+	return out
+}
+
+func cloneHedge(in HedgeSettings) HedgeSettings {
+	out := in
+	out.Modes = copyStringList(in.Modes)
+	return out
+}
+
+func sanitizeHedge(in HedgeSettings) HedgeSettings {
+	out := in
+	out.Modes = nil
+	for _, mode := range in.Modes {
+		out.Modes = append(out.Modes, normalizeMode(mode))
+	}
+	if out.AfterMS < 0 {
+		out.AfterMS = 0
+	}
+	return out
+}
+
+func cloneAdmission(in AdmissionSettings) AdmissionSettings {
+	out := in
+	out.Classes = copyIntMap(in.Classes)
+	out.Groups = copyIntMap(in.Groups)
+	return out
+}
+
+// sanitizeAdmission lower-cases class names and fills in the default
+// ladder when no classes or groups are configured.
+func sanitizeAdmission(in AdmissionSettings) AdmissionSettings {
+	out := in
+	out.Classes = map[string]int{}
+	for class, p := range in.Classes {
+		if class = strings.ToLower(strings.TrimSpace(class)); class != "" {
+			out.Classes[class] = p
+		}
+	}
+	out.Groups = copyIntMap(in.Groups)
+	if len(out.Classes) == 0 && len(out.Groups) == 0 {
+		out.Classes = map[string]int{"critical": 30, "high": 20, "normal": 10, "low": 0}
+		out.Groups = map[string]int{"enterprise": 20, "vip": 15}
+		out.DefaultPriority = 10
+	}
+	return out
+}
+
+func cloneJudge(in JudgeSettings) JudgeSettings {
+	out := in
+	out.Rubric.Dimensions = append([]JudgeDimension(nil), in.Rubric.Dimensions...)
+	if in.ModeRubrics != nil {
+		out.ModeRubrics = make(map[string]JudgeRubric, len(in.ModeRubrics))
+		for mode, r := range in.ModeRubrics {
+			r.Dimensions = append([]JudgeDimension(nil), r.Dimensions...)
+			out.ModeRubrics[mode] = r
+		}
+	}
+	return out
+}
+
+func sanitizeJudge(in JudgeSettings) JudgeSettings {
+	out := JudgeSettings{Rubric: sanitizeJudgeRubric(in.Rubric)}
+	for mode, r := range in.ModeRubrics {
+		if out.ModeRubrics == nil {
+			out.ModeRubrics = map[string]JudgeRubric{}
+		}
+		out.ModeRubrics[normalizeMode(mode)] = sanitizeJudgeRubric(r)
+	}
+	return out
+}
+
+// sanitizeJudgeRubric lowercases dimension names, drops blank and repeated
+// ones, and gives unweighted dimensions weight 1.
+func sanitizeJudgeRubric(in JudgeRubric) JudgeRubric {
+	out := JudgeRubric{Prompt: strings.TrimSpace(in.Prompt)}
+	seen := map[string]bool{}
+	for _, d := range in.Dimensions {
+		d.Name = strings.ToLower(strings.TrimSpace(d.Name))
+		if d.Name == "" || seen[d.Name] {
+			continue
+		}
+		seen[d.Name] = true
+		if d.Weight <= 0 {
+			d.Weight = 1
+		}
+		d.Description = strings.TrimSpace(d.Description)
+		out.Dimensions = append(out.Dimensions, d)
+	}
+	return out
+}
+
+func clonePromptExperiment(in PromptExperiment) PromptExperiment {
+	out := in
+	out.Variants = append([]PromptVariant(nil), in.Variants...)
+	return out
+}
+
+func clonePolicy(in PolicySettings) PolicySettings {
+	out := in
+	if in.Rules == nil {
+		return out
+	}
+	out.Rules = make([]PolicyRule, len(in.Rules))
+	for i, r := range in.Rules {
+		r.Paths = copyStringList(r.Paths)
+		r.Models = copyStringList(r.Models)
+		r.Modes = copyStringList(r.Modes)
+		r.Tools = copyStringList(r.Tools)
+		r.Adapters = copyStringList(r.Adapters)
+		r.Projects = copyStringList(r.Projects)
+		r.UserIDs = copyStringList(r.UserIDs)
+		r.Groups = copyStringList(r.Groups)
+		r.Roles = copyStringList(r.Roles)
+		r.TokenNames = copyStringList(r.TokenNames)
+		out.Rules[i] = r
+	}
+	return out
+}
+
+func clonePromptExperiments(in map[string]PromptExperiment) map[string]PromptExperiment {
+	if in == nil {
+		return nil
+	}
+	out := make(map[string]PromptExperiment, len(in))
+	for mode, exp := range in {
+		out[mode] = clonePromptExperiment(exp)
+	}
+	return out
+}
+
+// sanitizePromptExperiments normalizes mode keys, drops unnamed and
+// repeated variants, and fills in the promotion defaults (30 samples, 95%
+// confidence). A promoted name that matches no variant is cleared.
+func sanitizePromptExperiments(in map[string]PromptExperiment) map[string]PromptExperiment {
+	if len(in) == 0 {
+		return nil
+	}
+	out := make(map[string]PromptExperiment, len(in))
+	for mode, exp := range in {
+		clean := PromptExperiment{
+			Enabled:     exp.Enabled,
+			AutoPromote: exp.AutoPromote,
+			MinSamples:  exp.MinSamples,
+			Confidence:  exp.Confidence,
+		}
+		seen := map[string]bool{}
+		for _, v := range exp.Variants {
+			v.Name = strings.TrimSpace(v.Name)
+			v.Prefix = strings.TrimSpace(v.Prefix)
+			if v.Name == "" || seen[v.Name] {
+				continue
+			}
+			seen[v.Name] = true
+			if v.Weight <= 0 {
+				v.Weight = 1
+			}
+			clean.Variants = append(clean.Variants, v)
+		}
+		switch sticky := strings.ToLower(strings.TrimSpace(exp.StickyBy)); sticky {
+		case CanaryStickyUser, CanaryStickyToken:
+			clean.StickyBy = sticky
+		default:
+			clean.StickyBy = CanaryStickyRequest
+		}
+		if clean.MinSamples <= 0 {
+			clean.MinSamples = 30
+		}
+		if clean.Confidence <= 0 || clean.Confidence >= 1 {
+			clean.Confidence = 0.95
+		}
+		if promoted := strings.TrimSpace(exp.Promoted); seen[promoted] {
+			clean.Promoted = promoted
+		}
+		out[normalizeMode(mode)] = clean
+	}
+	return out
+}
+
+func cloneModeParams(in ModeParams) ModeParams {
+	out := in
+	if in.Temperature != nil {
+		v := *in.Temperature
+		out.Temperature = &v
+	}
+	if in.TopP != nil {
+		v := *in.TopP
+		out.TopP = &v
+	}
+	out.StopSequences = append([]string(nil), in.StopSequences...)
+	return out
+}
+
+func copyModeParams(in map[string]ModeParams) map[string]ModeParams {
+	if in == nil {
+		return nil
+	}
+	out := make(map[string]ModeParams, len(in))
+	for mode, p := range in {
+		out[mode] = cloneModeParams(p)
+	}
+	return out
+}
+
+// sanitizeModeParams normalizes mode keys and drops values upstreams would
+// reject: temperature outside [0, 2], top_p outside (0, 1], negative
+// max_tokens and blank stop sequences. Entries left empty are removed.
+func sanitizeModeParams(in map[string]ModeParams) map[string]ModeParams {
+	if len(in) == 0 {
+		return nil
+	}
+	out := make(map[string]ModeParams, len(in))
+	for mode, p := range in {
+		if p.Temperature != nil && (*p.Temperature < 0 || *p.Temperature > 2) {
+			p.Temperature = nil
+		}
+		if p.TopP != nil && (*p.TopP <= 0 || *p.TopP > 1) {
+			p.TopP = nil
+		}
+		if p.MaxTokens < 0 {
+			p.MaxTokens = 0
+		}
+		p.StopSequences = copyStringList(p.StopSequences)
+		if p.IsZero() {
+			continue
+		}
+		out[normalizeMode(mode)] = p
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+func copyToolResultLimits(in map[string]ToolResultLimit) map[string]ToolResultLimit {
+	if in == nil {
+		return nil
+	}
+	out := make(map[string]ToolResultLimit, len(in))
+	for tool, limit := range in {
+		out[tool] = limit
+	}
+	return out
+}
+
+// sanitizeToolResultLimits lowercases tool names, drops entries without a
+// positive cap and defaults unknown strategies to head.
+func sanitizeToolResultLimits(in map[string]ToolResultLimit) map[string]ToolResultLimit {
+	if len(in) == 0 {
+		return nil
+	}
+	out := make(map[string]ToolResultLimit, len(in))
+	for tool, limit := range in {
+		tool = strings.ToLower(strings.TrimSpace(tool))
+		if tool == "" || limit.MaxChars <= 0 {
+			continue
+		}
+		switch strategy := strings.ToLower(strings.TrimSpace(limit.Strategy)); strategy {
+		case ToolResultTail, ToolResultSummarize:
+			limit.Strategy = strategy
+		default:
+			limit.Strategy = ToolResultHead
+		}
+		limit.Model = strings.TrimSpace(limit.Model)
+		out[tool] = limit
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+// sanitizeToolApproval lowercases tool patterns, drops invalid globs and
+// defaults the timeout to five minutes and the outcome to deny.
+func sanitizeToolApproval(in ToolApprovalSettings) ToolApprovalSettings {
+	var tools []string
+	for _, pattern := range in.Tools {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if pattern == "" {
+			continue
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			continue
+		}
+		tools = append(tools, pattern)
+	}
+	in.Tools = tools
+	if in.TimeoutSeconds <= 0 {
+		in.TimeoutSeconds = 300
+	}
+	if strings.ToLower(strings.TrimSpace(in.OnTimeout)) == ToolApprovalApprove {
+		in.OnTimeout = ToolApprovalApprove
+	} else {
+		in.OnTimeout = ToolApprovalDeny
+	}
+	return in
+}
+
+func copyStringList(in []string) []string {
+	if len(in) == 0 {
+		return nil
+	}
+	out := make([]string, 0, len(in))
+	for _, item := range in {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			out = append(out, item)
+		}
+	}
 	return out
 }
 
@@ -447,6 +1561,16 @@ func copyStringMap(in map[string]string) map[string]string {
 	return out
 }
 
+func copyIntMap(in map[string]int) map[string]int {
+	out := make(map[string]int, len(in))
+	for k, v := range in {
+		if k = strings.TrimSpace(k); k != "" {
+			out[k] = v
+		}
+	}
+	return out
+}
+
 func copyModeRoutes(in map[string][]string) map[string][]string {
 	if len(in) == 0 {
 		return map[string][]string{}
@@ -502,3 +1626,19 @@ func copyModelPolicies(in map[string]ModelDispatchPolicy) map[string]ModelDispat
 	}
 	return out
 }
+
+// copyAdapterPrices drops blank names and negative prices.
+func copyAdapterPrices(in map[string]AdapterPrice) map[string]AdapterPrice {
+	if len(in) == 0 {
+		return map[string]AdapterPrice{}
+	}
+	out := make(map[string]AdapterPrice, len(in))
+	for k, v := range in {
+		k = strings.TrimSpace(k)
+		if k == "" || v.InputPerMTok < 0 || v.OutputPerMTok < 0 {
+			continue
+		}
+		out[k] = v
+	}
+	return out
+}
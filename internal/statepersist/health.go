@@ -0,0 +1,172 @@
+package statepersist
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultFailureThreshold = 3
+
+// HealthConfig controls when repeated save failures escalate.
+type HealthConfig struct {
+	// FailureThreshold is the number of consecutive failed saves after which
+	// the manager reports itself degraded. Defaults to 3.
+	FailureThreshold int `json:"failure_threshold"`
+	// ReadOnlyOnDegraded asks stateful endpoints to reject writes while
+	// degraded, so no more state is accepted than can be persisted.
+	ReadOnlyOnDegraded bool `json:"read_only_on_degraded"`
+}
+
+// HealthStatus is the manager's view of the persistence backend.
+type HealthStatus struct {
+	Degraded            bool       `json:"degraded"`
+	ReadOnly            bool       `json:"read_only"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	TotalFailures       int64      `json:"total_failures"`
+	FailureThreshold    int        `json:"failure_threshold"`
+	LastError           string     `json:"last_error,omitempty"`
+	LastFailureAt       *time.Time `json:"last_failure_at,omitempty"`
+	LastSuccessAt       *time.Time `json:"last_success_at,omitempty"`
+	DegradedSince       *time.Time `json:"degraded_since,omitempty"`
+	// PendingRepairs counts keys whose last save failed and are queued for
+	// retry.
+	PendingRepairs int `json:"pending_repairs"`
+}
+
+// HealthConfigFromEnv reads STATE_PERSIST_FAILURE_THRESHOLD and
+// STATE_PERSIST_READONLY_ON_FAILURE.
+func HealthConfigFromEnv() (HealthConfig, error) {
+	var cfg HealthConfig
+	if raw := strings.TrimSpace(os.Getenv("STATE_PERSIST_FAILURE_THRESHOLD")); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			return HealthConfig{}, fmt.Errorf("invalid STATE_PERSIST_FAILURE_THRESHOLD: %q", raw)
+		}
+		cfg.FailureThreshold = n
+	}
+	if raw := strings.TrimSpace(os.Getenv("STATE_PERSIST_READONLY_ON_FAILURE")); raw != "" {
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return HealthConfig{}, fmt.Errorf("invalid STATE_PERSIST_READONLY_ON_FAILURE: %q", raw)
+		}
+		cfg.ReadOnlyOnDegraded = v
+	}
+	return normalizeHealthConfig(cfg), nil
+}
+
+func normalizeHealthConfig(cfg HealthConfig) HealthConfig {
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = defaultFailureThreshold
+	}
+	return cfg
+}
+
+// SetHealthConfig replaces the escalation settings.
+func (m *Manager) SetHealthConfig(cfg HealthConfig) {
+	m.healthMu.Lock()
+	defer m.healthMu.Unlock()
+	m.healthCfg = normalizeHealthConfig(cfg)
+}
+
+// SetOnHealthChange registers fn to run whenever the manager enters or
+// leaves the degraded state.
+func (m *Manager) SetOnHealthChange(fn func(HealthStatus)) {
+	m.healthMu.Lock()
+	defer m.healthMu.Unlock()
+	m.onHealthChange = fn
+}
+
+func (m *Manager) Health() HealthStatus {
+	m.healthMu.Lock()
+	out := m.healthLocked()
+	m.healthMu.Unlock()
+	out.PendingRepairs = m.repairs.pending()
+	return out
+}
+
+// ReadOnly reports whether stateful endpoints should refuse writes.
+func (m *Manager) ReadOnly() bool {
+	if m == nil {
+		return false
+	}
+	return m.Health().ReadOnly
+}
+
+func (m *Manager) healthLocked() HealthStatus {
+	out := m.health
+	out.FailureThreshold = m.healthCfg.FailureThreshold
+	out.ReadOnly = out.Degraded && m.healthCfg.ReadOnlyOnDegraded
+	out.LastFailureAt = cloneTime(m.health.LastFailureAt)
+	out.LastSuccessAt = cloneTime(m.health.LastSuccessAt)
+	out.DegradedSince = cloneTime(m.health.DegradedSince)
+	return out
+}
+
+// recordSave updates the failure counters and fires the health callback on
+// a transition into or out of the degraded state.
+func (m *Manager) recordSave(err error) {
+	now := time.Now().UTC()
+	m.healthMu.Lock()
+	wasDegraded := m.health.Degraded
+	if err != nil {
+		m.health.ConsecutiveFailures++
+		m.health.TotalFailures++
+		m.health.LastError = err.Error()
+		m.health.LastFailureAt = &now
+		if m.health.ConsecutiveFailures >= m.healthCfg.FailureThreshold && !wasDegraded {
+			m.health.Degraded = true
+			m.health.DegradedSince = &now
+		}
+	} else {
+		m.health.ConsecutiveFailures = 0
+		m.health.LastSuccessAt = &now
+		m.health.Degraded = false
+		m.health.DegradedSince = nil
+	}
+	changed := wasDegraded != m.health.Degraded
+	status := m.healthLocked()
+	fn := m.onHealthChange
+	m.healthMu.Unlock()
+	status.PendingRepairs = m.repairs.pending()
+	if changed && fn != nil {
+		fn(status)
+	}
+}
+
+func cloneTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	v := *t
+	return &v
+}
+
+// writeCheckKey is the backend key CheckWritable writes; it holds no state.
+const writeCheckKey = "write_check"
+
+// CheckWritable saves a marker through the backend and reads it back,
+// proving that state can be persisted right now. It does not touch the
+// health counters.
+func (m *Manager) CheckWritable() error {
+	if m.backend == nil {
+		return errors.New("no persistence backend")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	want := time.Now().UTC().Format(time.RFC3339Nano)
+	if err := m.backend.Save(writeCheckKey, map[string]string{"checked_at": want}); err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+	var got map[string]string
+	if err := m.backend.Load(writeCheckKey, &got); err != nil {
+		return fmt.Errorf("read back: %w", err)
+	}
+	if got["checked_at"] != want {
+		return errors.New("read back a different value than was written")
+	}
+	return nil
+}
@@ -2,7 +2,9 @@ package statepersist
 
 import (
 	"errors"
+	"fmt"
 	"sync"
+	"time"
 
 	"ccgateway/internal/ccrun"
 	"ccgateway/internal/plan"
@@ -27,21 +29,37 @@ type TodoStateStore interface {
 	SetOnChange(fn func())
 }
 
+// TextCipher seals run prompt and output text per project before it is
+// written to the backend.
+type TextCipher interface {
+	Encrypt(projectID, plaintext string) (string, error)
+	Decrypt(projectID, value string) (string, error)
+}
+
 type Manager struct {
 	mu      sync.Mutex
 	backend Backend
 	runs    RunStateStore
 	plans   PlanStateStore
 	todos   TodoStateStore
+	cipher  TextCipher
 	onError func(error)
+
+	healthMu       sync.Mutex
+	healthCfg      HealthConfig
+	health         HealthStatus
+	onHealthChange func(HealthStatus)
+
+	repairs repairQueue
 }
 
 func NewManager(backend Backend, runs RunStateStore, plans PlanStateStore, todos TodoStateStore) *Manager {
 	return &Manager{
-		backend: backend,
-		runs:    runs,
-		plans:   plans,
-		todos:   todos,
+		backend:   backend,
+		runs:      runs,
+		plans:     plans,
+		todos:     todos,
+		healthCfg: normalizeHealthConfig(HealthConfig{}),
 	}
 }
 
@@ -51,6 +69,14 @@ func (m *Manager) SetOnError(fn func(error)) {
 	m.onError = fn
 }
 
+// SetCipher encrypts run text at rest from the next save on. Set it before
+// LoadAll so sealed state can be read back.
+func (m *Manager) SetCipher(c TextCipher) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cipher = c
+}
+
 func (m *Manager) LoadAll() error {
 	if m.backend == nil {
 		return nil
@@ -60,6 +86,9 @@ func (m *Manager) LoadAll() error {
 		if err := m.backend.Load("runs", &state); err != nil && !errors.Is(err, ErrNotFound) {
 			return err
 		} else if err == nil {
+			if err := m.openRuns(&state); err != nil {
+				return err
+			}
 			if err := m.runs.Restore(state); err != nil {
 				return err
 			}
@@ -88,27 +117,104 @@ func (m *Manager) LoadAll() error {
 	return nil
 }
 
+// SaveAll writes every bound store; the outcome feeds the manager's health.
 func (m *Manager) SaveAll() error {
 	if m.backend == nil {
 		return nil
 	}
+	err := m.saveAll()
+	m.recordSave(err)
+	return err
+}
+
+// saveAll writes every bound store. A failed key goes on the repair queue
+// and the remaining keys are still written; the first error is returned.
+func (m *Manager) saveAll() error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if m.runs != nil {
-		if err := m.backend.Save("runs", m.runs.Snapshot()); err != nil {
-			return err
+	var firstErr error
+	for _, key := range m.boundKeys() {
+		err := m.saveKeyLocked(key)
+		m.repairs.record(key, err, time.Now())
+		if err != nil && firstErr == nil {
+			firstErr = err
 		}
 	}
+	return firstErr
+}
+
+// boundKeys lists the backend keys of the bound stores in repair priority
+// order.
+func (m *Manager) boundKeys() []string {
+	var keys []string
+	if m.runs != nil {
+		keys = append(keys, "runs")
+	}
 	if m.plans != nil {
-		if err := m.backend.Save("plans", m.plans.Snapshot()); err != nil {
-			return err
-		}
+		keys = append(keys, "plans")
 	}
 	if m.todos != nil {
-		if err := m.backend.Save("todos", m.todos.Snapshot()); err != nil {
+		keys = append(keys, "todos")
+	}
+	return keys
+}
+
+// saveKeyLocked writes the current snapshot of one store; m.mu is held.
+func (m *Manager) saveKeyLocked(key string) error {
+	switch key {
+	case "runs":
+		state := m.runs.Snapshot()
+		if err := m.sealRuns(&state); err != nil {
 			return err
 		}
+		return m.backend.Save("runs", state)
+	case "plans":
+		return m.backend.Save("plans", m.plans.Snapshot())
+	case "todos":
+		return m.backend.Save("todos", m.todos.Snapshot())
+	}
+	return fmt.Errorf("unknown persist key %q", key)
+}
+
+func (m *Manager) sealRuns(state *ccrun.StoreState) error {
+	if m.cipher == nil {
+		return nil
+	}
+	for i := range state.Runs {
+		run := &state.Runs[i]
+		var err error
+		if run.PromptText, err = m.cipher.Encrypt(run.ProjectID, run.PromptText); err != nil {
+			return fmt.Errorf("encrypt run %s: %w", run.ID, err)
+		}
+		if run.OutputText, err = m.cipher.Encrypt(run.ProjectID, run.OutputText); err != nil {
+			return fmt.Errorf("encrypt run %s: %w", run.ID, err)
+		}
+		run.TextSealed = true
+	}
+	return nil
+}
+
+func (m *Manager) openRuns(state *ccrun.StoreState) error {
+	m.mu.Lock()
+	c := m.cipher
+	m.mu.Unlock()
+	for i := range state.Runs {
+		run := &state.Runs[i]
+		if !run.TextSealed {
+			continue
+		}
+		if c == nil {
+			return fmt.Errorf("run %s is encrypted but no data encryption key is configured", run.ID)
+		}
+		var err error
+		if run.PromptText, err = c.Decrypt(run.ProjectID, run.PromptText); err != nil {
+			return fmt.Errorf("decrypt run %s: %w", run.ID, err)
+		}
+		if run.OutputText, err = c.Decrypt(run.ProjectID, run.OutputText); err != nil {
+			return fmt.Errorf("decrypt run %s: %w", run.ID, err)
+		}
+		run.TextSealed = false
 	}
 	return nil
 }
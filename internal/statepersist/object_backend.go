@@ -0,0 +1,130 @@
+package statepersist
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"ccgateway/internal/storage"
+)
+
+const defaultSnapshotInterval = 30 * time.Second
+
+// ObjectStore is the bucket an ObjectBackend snapshots to.
+// *storage.S3Backend implements it.
+type ObjectStore interface {
+	PutObject(ctx context.Context, key string, data []byte, contentType string) error
+	GetObject(ctx context.Context, key string) ([]byte, error)
+}
+
+// ObjectBackend keeps state in an object store for deployments without a
+// persistent volume. Saves land in memory and are uploaded as snapshots by
+// Flush, which Run calls on an interval; a failed upload stays pending for
+// the next one.
+type ObjectBackend struct {
+	store ObjectStore
+
+	mu      sync.Mutex
+	latest  map[string][]byte
+	pending map[string]bool
+}
+
+func NewObjectBackend(store ObjectStore) *ObjectBackend {
+	return &ObjectBackend{store: store, latest: map[string][]byte{}, pending: map[string]bool{}}
+}
+
+func (b *ObjectBackend) Load(key string, out any) error {
+	name, err := normalizeKey(key)
+	if err != nil {
+		return err
+	}
+	b.mu.Lock()
+	raw, ok := b.latest[name]
+	b.mu.Unlock()
+	if !ok {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		raw, err = b.store.GetObject(ctx, name+".json")
+		if errors.Is(err, storage.ErrNotFound) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return json.Unmarshal(raw, out)
+}
+
+func (b *ObjectBackend) Save(key string, value any) error {
+	name, err := normalizeKey(key)
+	if err != nil {
+		return err
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.latest[name] = raw
+	b.pending[name] = true
+	return nil
+}
+
+// Flush uploads every key saved since its last successful upload.
+func (b *ObjectBackend) Flush(ctx context.Context) error {
+	b.mu.Lock()
+	batch := make(map[string][]byte, len(b.pending))
+	for name := range b.pending {
+		batch[name] = b.latest[name]
+	}
+	b.mu.Unlock()
+
+	var firstErr error
+	for name, raw := range batch {
+		if err := b.store.PutObject(ctx, name+".json", raw, "application/json"); err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("upload %s snapshot: %w", name, err)
+			}
+			continue
+		}
+		b.mu.Lock()
+		// A save that raced the upload stays pending.
+		if string(b.latest[name]) == string(raw) {
+			delete(b.pending, name)
+		}
+		b.mu.Unlock()
+	}
+	return firstErr
+}
+
+// Pending reports how many keys await upload.
+func (b *ObjectBackend) Pending() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.pending)
+}
+
+// Run flushes every interval (default 30s) until ctx ends, reporting
+// failed uploads to onError. Call Flush once more at shutdown, after the
+// last state change.
+func (b *ObjectBackend) Run(ctx context.Context, interval time.Duration, onError func(error)) {
+	if interval <= 0 {
+		interval = defaultSnapshotInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := b.Flush(ctx); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package statepersist
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRepairBaseDelay = time.Second
+	defaultRepairMaxDelay  = 5 * time.Minute
+	repairTick             = time.Second
+)
+
+// repairPriority orders retries: runs carry usage and audit data, so they
+// are rewritten before plans and todos.
+var repairPriority = map[string]int{"runs": 0, "plans": 1, "todos": 2}
+
+// RepairItem is a persisted key whose last save failed and is waiting to be
+// written again. The retry always writes the store's current snapshot, so
+// changes made while the key was pending are not lost.
+type RepairItem struct {
+	Key           string    `json:"key"`
+	Priority      int       `json:"priority"`
+	Attempts      int       `json:"attempts"`
+	LastError     string    `json:"last_error"`
+	FirstFailedAt time.Time `json:"first_failed_at"`
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+}
+
+// RepairStatus is the repair queue as reported by /admin/status.
+type RepairStatus struct {
+	Pending  int          `json:"pending"`
+	Items    []RepairItem `json:"items"`
+	Queued   int64        `json:"queued_total"`
+	Repaired int64        `json:"repaired_total"`
+}
+
+type repairQueue struct {
+	mu       sync.Mutex
+	items    map[string]*RepairItem
+	queued   int64
+	repaired int64
+}
+
+// record updates key after a save attempt: a failure (re)queues it with
+// exponential backoff, a success drops it.
+func (q *repairQueue) record(key string, err error, now time.Time) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	item, pending := q.items[key]
+	if err == nil {
+		if pending {
+			delete(q.items, key)
+			q.repaired++
+		}
+		return
+	}
+	if !pending {
+		if q.items == nil {
+			q.items = map[string]*RepairItem{}
+		}
+		item = &RepairItem{Key: key, Priority: repairPriority[key], FirstFailedAt: now}
+		q.items[key] = item
+		q.queued++
+	}
+	item.Attempts++
+	item.LastError = err.Error()
+	item.NextAttemptAt = now.Add(repairBackoff(item.Attempts))
+}
+
+func repairBackoff(attempts int) time.Duration {
+	delay := defaultRepairBaseDelay
+	for i := 1; i < attempts && delay < defaultRepairMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > defaultRepairMaxDelay {
+		delay = defaultRepairMaxDelay
+	}
+	return delay
+}
+
+// due returns the pending keys whose backoff has elapsed, or all of them
+// when force is set, highest priority first.
+func (q *repairQueue) due(now time.Time, force bool) []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var keys []string
+	for key, item := range q.items {
+		if force || !now.Before(item.NextAttemptAt) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if pi, pj := repairPriority[keys[i]], repairPriority[keys[j]]; pi != pj {
+			return pi < pj
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+func (q *repairQueue) pending() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.items)
+}
+
+func (q *repairQueue) status() RepairStatus {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	out := RepairStatus{Pending: len(q.items), Items: make([]RepairItem, 0, len(q.items)), Queued: q.queued, Repaired: q.repaired}
+	for _, item := range q.items {
+		out.Items = append(out.Items, *item)
+	}
+	sort.Slice(out.Items, func(i, j int) bool {
+		if out.Items[i].Priority != out.Items[j].Priority {
+			return out.Items[i].Priority < out.Items[j].Priority
+		}
+		return out.Items[i].Key < out.Items[j].Key
+	})
+	return out
+}
+
+// Repairs reports the keys waiting to be written again.
+func (m *Manager) Repairs() RepairStatus {
+	return m.repairs.status()
+}
+
+// RetryRepairs rewrites the pending keys whose backoff has elapsed.
+func (m *Manager) RetryRepairs() RepairStatus {
+	return m.retryRepairs(false)
+}
+
+// FlushRepairs rewrites every pending key now, ignoring backoff.
+func (m *Manager) FlushRepairs() RepairStatus {
+	return m.retryRepairs(true)
+}
+
+func (m *Manager) retryRepairs(force bool) RepairStatus {
+	if m.backend == nil {
+		return m.Repairs()
+	}
+	keys := m.repairs.due(time.Now(), force)
+	if len(keys) == 0 {
+		return m.Repairs()
+	}
+	var firstErr error
+	m.mu.Lock()
+	for _, key := range keys {
+		err := m.saveKeyLocked(key)
+		m.repairs.record(key, err, time.Now())
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	m.mu.Unlock()
+	// Health only recovers once nothing is left to repair.
+	if firstErr != nil || m.repairs.pending() == 0 {
+		m.recordSave(firstErr)
+	}
+	if firstErr != nil {
+		m.dispatchError(firstErr)
+	}
+	return m.Repairs()
+}
+
+// StartRepairLoop retries pending keys in the background until ctx ends.
+func (m *Manager) StartRepairLoop(ctx context.Context) {
+	if m == nil || m.backend == nil {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(repairTick)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if m.repairs.pending() > 0 {
+					m.RetryRepairs()
+				}
+			}
+		}
+	}()
+}
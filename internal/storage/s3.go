@@ -0,0 +1,373 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// S3Config holds the connection details of an S3-compatible bucket.
+type S3Config struct {
+	// Endpoint is the service base URL; empty means AWS
+	// (https://s3.<region>.amazonaws.com). MinIO, R2 and similar services
+	// set their own.
+	Endpoint        string `json:"endpoint"`
+	Region          string `json:"region"`
+	Bucket          string `json:"bucket"`
+	Prefix          string `json:"prefix"`
+	AccessKeyID     string `json:"access_key_id"`
+	SecretAccessKey string `json:"-"`
+	SessionToken    string `json:"-"`
+	// PathStyle addresses the bucket as /<bucket>/<key> rather than as a
+	// <bucket>.<host> subdomain.
+	PathStyle bool `json:"path_style"`
+	// HTTPClient defaults to a client with a 60s timeout.
+	HTTPClient *http.Client `json:"-"`
+}
+
+// S3ConfigFromEnv reads the credentials shared by every S3 user:
+// S3_ENDPOINT, S3_REGION (or AWS_REGION), AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN and S3_FORCE_PATH_STYLE.
+// Bucket and prefix are left to the caller.
+func S3ConfigFromEnv() S3Config {
+	cfg := S3Config{
+		Endpoint:        strings.TrimSpace(os.Getenv("S3_ENDPOINT")),
+		Region:          strings.TrimSpace(os.Getenv("S3_REGION")),
+		AccessKeyID:     strings.TrimSpace(os.Getenv("AWS_ACCESS_KEY_ID")),
+		SecretAccessKey: strings.TrimSpace(os.Getenv("AWS_SECRET_ACCESS_KEY")),
+		SessionToken:    strings.TrimSpace(os.Getenv("AWS_SESSION_TOKEN")),
+	}
+	if cfg.Region == "" {
+		cfg.Region = strings.TrimSpace(os.Getenv("AWS_REGION"))
+	}
+	cfg.PathStyle, _ = strconv.ParseBool(os.Getenv("S3_FORCE_PATH_STYLE"))
+	return cfg
+}
+
+// NewObjectBackendFromEnv returns a bucket backend of the given kind:
+// "s3" uses S3ConfigFromEnv; "gcs" reaches Google Cloud Storage through
+// its S3-compatible XML API with HMAC keys from GCS_HMAC_ACCESS_ID and
+// GCS_HMAC_SECRET (GCS_ENDPOINT overrides the default
+// https://storage.googleapis.com).
+func NewObjectBackendFromEnv(kind, bucket, prefix string) (*S3Backend, error) {
+	var cfg S3Config
+	switch strings.ToLower(strings.TrimSpace(kind)) {
+	case "s3":
+		cfg = S3ConfigFromEnv()
+	case "gcs":
+		cfg = S3Config{
+			Endpoint:        strings.TrimSpace(os.Getenv("GCS_ENDPOINT")),
+			Region:          "auto",
+			AccessKeyID:     strings.TrimSpace(os.Getenv("GCS_HMAC_ACCESS_ID")),
+			SecretAccessKey: strings.TrimSpace(os.Getenv("GCS_HMAC_SECRET")),
+			PathStyle:       true,
+		}
+		if cfg.Endpoint == "" {
+			cfg.Endpoint = "https://storage.googleapis.com"
+		}
+	default:
+		return nil, fmt.Errorf("unknown object storage %q", kind)
+	}
+	cfg.Bucket = bucket
+	cfg.Prefix = prefix
+	return NewS3Backend(cfg)
+}
+
+// S3Backend implements Backend over an S3-compatible bucket and also
+// stores raw objects. Requests are signed with AWS Signature Version 4.
+type S3Backend struct {
+	cfg     S3Config
+	base    *url.URL
+	client  *http.Client
+	nowFunc func() time.Time
+}
+
+// NewS3Backend validates cfg and returns a backend; it does not contact
+// the service.
+func NewS3Backend(cfg S3Config) (*S3Backend, error) {
+	cfg.Bucket = strings.TrimSpace(cfg.Bucket)
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("s3 bucket is required")
+	}
+	if cfg.AccessKeyID == "" || cfg.SecretAccessKey == "" {
+		return nil, fmt.Errorf("s3 credentials are required")
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = "https://s3." + cfg.Region + ".amazonaws.com"
+	}
+	base, err := url.Parse(strings.TrimRight(endpoint, "/"))
+	if err != nil || base.Host == "" {
+		return nil, fmt.Errorf("invalid s3 endpoint %q", endpoint)
+	}
+	cfg.Prefix = strings.Trim(cfg.Prefix, "/")
+	client := cfg.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 60 * time.Second}
+	}
+	return &S3Backend{cfg: cfg, base: base, client: client, nowFunc: time.Now}, nil
+}
+
+// Config returns the backend configuration.
+func (s *S3Backend) Config() S3Config {
+	return s.cfg
+}
+
+func (s *S3Backend) Get(ctx context.Context, key string) (string, bool, error) {
+	data, err := s.GetObject(ctx, key)
+	if err == ErrNotFound {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return string(data), true, nil
+}
+
+func (s *S3Backend) Set(ctx context.Context, key, value string) error {
+	return s.PutObject(ctx, key, []byte(value), "text/plain; charset=utf-8")
+}
+
+func (s *S3Backend) Delete(ctx context.Context, key string) error {
+	return s.DeleteObject(ctx, key)
+}
+
+// List returns the keys under prefix, following continuation tokens.
+func (s *S3Backend) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {s.objectKey(prefix)}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		resp, err := s.do(ctx, http.MethodGet, "", query, nil, "")
+		if err != nil {
+			return nil, err
+		}
+		var page struct {
+			Contents []struct {
+				Key string `xml:"Key"`
+			} `xml:"Contents"`
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("s3 list: %w", err)
+		}
+		for _, c := range page.Contents {
+			keys = append(keys, s.stripPrefix(c.Key))
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return keys, nil
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+func (s *S3Backend) Close() error {
+	return nil
+}
+
+// PutObject uploads data under key.
+func (s *S3Backend) PutObject(ctx context.Context, key string, data []byte, contentType string) error {
+	resp, err := s.do(ctx, http.MethodPut, s.objectKey(key), nil, data, contentType)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// GetObject downloads key, returning ErrNotFound when it does not exist.
+func (s *S3Backend) GetObject(ctx context.Context, key string) ([]byte, error) {
+	resp, err := s.do(ctx, http.MethodGet, s.objectKey(key), nil, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+// DeleteObject removes key; deleting a missing key is not an error.
+func (s *S3Backend) DeleteObject(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, http.MethodDelete, s.objectKey(key), nil, nil, "")
+	if err == ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *S3Backend) objectKey(key string) string {
+	key = strings.TrimLeft(key, "/")
+	if s.cfg.Prefix == "" {
+		return key
+	}
+	return s.cfg.Prefix + "/" + key
+}
+
+func (s *S3Backend) stripPrefix(key string) string {
+	if s.cfg.Prefix == "" {
+		return key
+	}
+	return strings.TrimPrefix(key, s.cfg.Prefix+"/")
+}
+
+// do sends a signed request and maps 404 to ErrNotFound and other non-2xx
+// answers to errors carrying the service's error code.
+func (s *S3Backend) do(ctx context.Context, method, key string, query url.Values, body []byte, contentType string) (*http.Response, error) {
+	u := *s.base
+	if s.cfg.PathStyle {
+		u.Path = strings.TrimRight(u.Path, "/") + "/" + s.cfg.Bucket + "/" + key
+	} else {
+		u.Host = s.cfg.Bucket + "." + u.Host
+		u.Path = strings.TrimRight(u.Path, "/") + "/" + key
+	}
+	u.RawPath = awsEscape(u.Path, false)
+	u.RawQuery = canonicalQuery(query)
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(body))
+	if contentType != "" {
+		req.Header.Set("content-type", contentType)
+	}
+	s.sign(req, body)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("s3 %s %s: %w", strings.ToLower(method), key, err)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		var apiErr struct {
+			Code    string `xml:"Code"`
+			Message string `xml:"Message"`
+		}
+		_ = xml.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&apiErr)
+		if apiErr.Code == "" {
+			apiErr.Code = resp.Status
+		}
+		return nil, fmt.Errorf("s3 %s %s: %s %s", strings.ToLower(method), key, apiErr.Code, apiErr.Message)
+	}
+	return resp, nil
+}
+
+// sign adds AWS Signature Version 4 headers to req.
+func (s *S3Backend) sign(req *http.Request, body []byte) {
+	now := s.nowFunc().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("host", req.URL.Host)
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+	if s.cfg.SessionToken != "" {
+		req.Header.Set("x-amz-security-token", s.cfg.SessionToken)
+	}
+
+	names := make([]string, 0, len(req.Header))
+	for name := range req.Header {
+		names = append(names, strings.ToLower(name))
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(req.Header.Get(name)) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := day + "/" + s.cfg.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+	key := hmacSHA256([]byte("AWS4"+s.cfg.SecretAccessKey), day)
+	key = hmacSHA256(key, s.cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Del("host")
+	req.Header.Set("authorization", "AWS4-HMAC-SHA256 Credential="+s.cfg.AccessKeyID+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func canonicalQuery(query url.Values) string {
+	if len(query) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		for _, v := range query[k] {
+			parts = append(parts, awsEscape(k, true)+"="+awsEscape(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// awsEscape percent-encodes everything but unreserved characters, and
+// slashes too when encodeSlash is set, as SigV4 requires.
+func awsEscape(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
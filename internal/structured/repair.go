@@ -0,0 +1,144 @@
+package structured
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Repair strategies reported by Repair, in the order they are attempted.
+const (
+	StrategyCodeFence      = "strip_code_fence"
+	StrategyExtract        = "extract_json"
+	StrategyTrailingCommas = "strip_trailing_commas"
+	StrategyCloseBrackets  = "close_brackets"
+)
+
+var (
+	codeFencePattern     = regexp.MustCompile("(?s)^\\s*```[a-zA-Z0-9_-]*\\s*\n(.*?)\n?\\s*```\\s*$")
+	trailingCommaPattern = regexp.MustCompile(`,(\s*[}\]])`)
+)
+
+// Repair returns text as a valid JSON document. When text is already valid
+// it is returned unchanged with no strategies. Otherwise cheap, deterministic
+// fixes are applied in turn; the returned strategies list what was needed.
+func Repair(text string) (string, []string, error) {
+	if json.Valid([]byte(strings.TrimSpace(text))) && strings.TrimSpace(text) != "" {
+		return text, nil, nil
+	}
+	current := strings.TrimSpace(text)
+	applied := make([]string, 0, 4)
+
+	if m := codeFencePattern.FindStringSubmatch(current); m != nil {
+		current = strings.TrimSpace(m[1])
+		applied = append(applied, StrategyCodeFence)
+		if json.Valid([]byte(current)) {
+			return current, applied, nil
+		}
+	}
+	if extracted, ok := extractJSONSpan(current); ok && extracted != current {
+		current = extracted
+		applied = append(applied, StrategyExtract)
+		if json.Valid([]byte(current)) {
+			return current, applied, nil
+		}
+	}
+	if fixed := trailingCommaPattern.ReplaceAllString(current, "$1"); fixed != current {
+		current = fixed
+		applied = append(applied, StrategyTrailingCommas)
+		if json.Valid([]byte(current)) {
+			return current, applied, nil
+		}
+	}
+	if closed, ok := closeBrackets(current); ok {
+		current = trailingCommaPattern.ReplaceAllString(closed, "$1")
+		applied = append(applied, StrategyCloseBrackets)
+		if json.Valid([]byte(current)) {
+			return current, applied, nil
+		}
+	}
+	return text, applied, fmt.Errorf("response is not valid JSON")
+}
+
+// extractJSONSpan returns the text from the first '{' or '[' to its matching
+// closer, or to the end of input when the document is truncated.
+func extractJSONSpan(text string) (string, bool) {
+	start := strings.IndexAny(text, "{[")
+	if start < 0 {
+		return "", false
+	}
+	depth := 0
+	inString, escaped := false, false
+	for i := start; i < len(text); i++ {
+		c := text[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+			if depth == 0 {
+				return text[start : i+1], true
+			}
+		}
+	}
+	return text[start:], true
+}
+
+// closeBrackets appends the closers needed to balance a truncated document.
+func closeBrackets(text string) (string, bool) {
+	stack := make([]byte, 0, 8)
+	inString, escaped := false, false
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
+		case '{':
+			stack = append(stack, '}')
+		case '[':
+			stack = append(stack, ']')
+		case '}', ']':
+			if len(stack) == 0 || stack[len(stack)-1] != c {
+				return "", false
+			}
+			stack = stack[:len(stack)-1]
+		}
+	}
+	if len(stack) == 0 && !inString {
+		return "", false
+	}
+	var b strings.Builder
+	b.WriteString(strings.TrimRight(text, " \t\r\n"))
+	if inString {
+		b.WriteByte('"')
+	}
+	for i := len(stack) - 1; i >= 0; i-- {
+		b.WriteByte(stack[i])
+	}
+	return b.String(), true
+}
@@ -0,0 +1,159 @@
+package structured
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Validate checks doc against the commonly used subset of JSON Schema:
+// type, properties, required, additionalProperties (bool), items, enum and
+// const. Unsupported keywords are ignored. It returns one message per
+// violation, prefixed with the JSON path of the offending value.
+func Validate(doc any, schema map[string]any) []string {
+	var errs []string
+	validateAt("$", doc, schema, &errs)
+	return errs
+}
+
+// ValidateText decodes text and validates it against schema.
+func ValidateText(text string, schema map[string]any) []string {
+	var doc any
+	if err := json.Unmarshal([]byte(text), &doc); err != nil {
+		return []string{"$: invalid JSON: " + err.Error()}
+	}
+	if len(schema) == 0 {
+		return nil
+	}
+	return Validate(doc, schema)
+}
+
+func validateAt(path string, value any, schema map[string]any, errs *[]string) {
+	if len(schema) == 0 {
+		return
+	}
+	if want, ok := schema["type"]; ok && !matchesType(value, want) {
+		*errs = append(*errs, fmt.Sprintf("%s: expected type %v, got %s", path, want, jsonType(value)))
+		return
+	}
+	if enum, ok := schema["enum"].([]any); ok && !containsValue(enum, value) {
+		*errs = append(*errs, fmt.Sprintf("%s: value is not one of the allowed enum values", path))
+	}
+	if c, ok := schema["const"]; ok && !equalJSON(c, value) {
+		*errs = append(*errs, fmt.Sprintf("%s: value does not match const", path))
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		props, _ := schema["properties"].(map[string]any)
+		if required, ok := schema["required"].([]any); ok {
+			for _, item := range required {
+				name, _ := item.(string)
+				if _, present := v[name]; name != "" && !present {
+					*errs = append(*errs, fmt.Sprintf("%s: missing required property %q", path, name))
+				}
+			}
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			sub, known := props[k].(map[string]any)
+			if known {
+				validateAt(path+"."+k, v[k], sub, errs)
+				continue
+			}
+			switch extra := schema["additionalProperties"].(type) {
+			case bool:
+				if !extra {
+					*errs = append(*errs, fmt.Sprintf("%s: unexpected property %q", path, k))
+				}
+			case map[string]any:
+				validateAt(path+"."+k, v[k], extra, errs)
+			}
+		}
+	case []any:
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range v {
+				validateAt(fmt.Sprintf("%s[%d]", path, i), item, items, errs)
+			}
+		}
+	}
+}
+
+func matchesType(value any, want any) bool {
+	switch t := want.(type) {
+	case string:
+		return matchesSingleType(value, t)
+	case []any:
+		for _, item := range t {
+			if name, ok := item.(string); ok && matchesSingleType(value, name) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func matchesSingleType(value any, want string) bool {
+	switch want {
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		f, ok := value.(float64)
+		return ok && f == math.Trunc(f)
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "null":
+		return value == nil
+	}
+	return true
+}
+
+func jsonType(value any) string {
+	switch value.(type) {
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case nil:
+		return "null"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func containsValue(list []any, value any) bool {
+	for _, item := range list {
+		if equalJSON(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func equalJSON(a, b any) bool {
+	ra, errA := json.Marshal(a)
+	rb, errB := json.Marshal(b)
+	return errA == nil && errB == nil && string(ra) == string(rb)
+}
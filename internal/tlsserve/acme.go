@@ -0,0 +1,362 @@
+package tlsserve
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// acmeClient is a minimal RFC 8555 client: one account, http-01
+// challenges and ES256 signatures, which is all Let's Encrypt needs.
+type acmeClient struct {
+	directoryURL string
+	email        string
+	key          *ecdsa.PrivateKey
+	http         *http.Client
+	pollInterval time.Duration
+
+	dir   acmeDirectory
+	kid   string
+	nonce string
+}
+
+type acmeDirectory struct {
+	NewNonce   string `json:"newNonce"`
+	NewAccount string `json:"newAccount"`
+	NewOrder   string `json:"newOrder"`
+}
+
+type acmeProblem struct {
+	Type   string `json:"type"`
+	Detail string `json:"detail"`
+	Status int    `json:"status"`
+}
+
+func (p *acmeProblem) Error() string {
+	return fmt.Sprintf("acme: %s (%s)", p.Detail, p.Type)
+}
+
+type acmeOrder struct {
+	Status         string       `json:"status"`
+	Authorizations []string     `json:"authorizations"`
+	Finalize       string       `json:"finalize"`
+	Certificate    string       `json:"certificate"`
+	Error          *acmeProblem `json:"error"`
+}
+
+type acmeAuthorization struct {
+	Status     string `json:"status"`
+	Identifier struct {
+		Value string `json:"value"`
+	} `json:"identifier"`
+	Challenges []acmeChallenge `json:"challenges"`
+}
+
+type acmeChallenge struct {
+	Type   string       `json:"type"`
+	URL    string       `json:"url"`
+	Token  string       `json:"token"`
+	Status string       `json:"status"`
+	Error  *acmeProblem `json:"error"`
+}
+
+// challengeSolver publishes and withdraws http-01 key authorizations.
+type challengeSolver interface {
+	present(token, keyAuth string)
+	cleanup(token string)
+}
+
+// obtain orders a certificate for domains and returns the PEM chain and
+// the PEM private key.
+func (c *acmeClient) obtain(ctx context.Context, domains []string, solver challengeSolver) ([]byte, []byte, error) {
+	if err := c.register(ctx); err != nil {
+		return nil, nil, err
+	}
+	ids := make([]map[string]string, 0, len(domains))
+	for _, d := range domains {
+		ids = append(ids, map[string]string{"type": "dns", "value": d})
+	}
+	var order acmeOrder
+	resp, err := c.post(ctx, c.dir.NewOrder, map[string]any{"identifiers": ids}, &order)
+	if err != nil {
+		return nil, nil, fmt.Errorf("acme new order: %w", err)
+	}
+	orderURL := resp.Header.Get("location")
+
+	for _, authzURL := range order.Authorizations {
+		if err := c.authorize(ctx, authzURL, solver); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	certKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: domains[0]},
+		DNSNames: domains,
+	}, certKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := c.post(ctx, order.Finalize, map[string]string{"csr": b64(csr)}, &order); err != nil {
+		return nil, nil, fmt.Errorf("acme finalize: %w", err)
+	}
+	for order.Status != "valid" {
+		if order.Status == "invalid" {
+			return nil, nil, fmt.Errorf("acme order failed: %v", order.Error)
+		}
+		if err := c.wait(ctx); err != nil {
+			return nil, nil, err
+		}
+		if _, err := c.post(ctx, orderURL, nil, &order); err != nil {
+			return nil, nil, fmt.Errorf("acme order poll: %w", err)
+		}
+	}
+	var chain []byte
+	if _, err := c.post(ctx, order.Certificate, nil, &chain); err != nil {
+		return nil, nil, fmt.Errorf("acme certificate download: %w", err)
+	}
+	der, err := x509.MarshalECPrivateKey(certKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return chain, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), nil
+}
+
+func (c *acmeClient) register(ctx context.Context) error {
+	if c.kid != "" {
+		return nil
+	}
+	if err := c.get(ctx, c.directoryURL, &c.dir); err != nil {
+		return fmt.Errorf("acme directory: %w", err)
+	}
+	payload := map[string]any{"termsOfServiceAgreed": true}
+	if c.email != "" {
+		payload["contact"] = []string{"mailto:" + c.email}
+	}
+	resp, err := c.post(ctx, c.dir.NewAccount, payload, nil)
+	if err != nil {
+		return fmt.Errorf("acme account: %w", err)
+	}
+	c.kid = resp.Header.Get("location")
+	if c.kid == "" {
+		return errors.New("acme account: server returned no account URL")
+	}
+	return nil
+}
+
+func (c *acmeClient) authorize(ctx context.Context, authzURL string, solver challengeSolver) error {
+	var authz acmeAuthorization
+	if _, err := c.post(ctx, authzURL, nil, &authz); err != nil {
+		return fmt.Errorf("acme authorization: %w", err)
+	}
+	if authz.Status == "valid" {
+		return nil
+	}
+	var chal *acmeChallenge
+	for i := range authz.Challenges {
+		if authz.Challenges[i].Type == "http-01" {
+			chal = &authz.Challenges[i]
+		}
+	}
+	if chal == nil {
+		return fmt.Errorf("acme: no http-01 challenge offered for %s", authz.Identifier.Value)
+	}
+	solver.present(chal.Token, chal.Token+"."+c.thumbprint())
+	defer solver.cleanup(chal.Token)
+	if _, err := c.post(ctx, chal.URL, struct{}{}, nil); err != nil {
+		return fmt.Errorf("acme challenge: %w", err)
+	}
+	for {
+		if _, err := c.post(ctx, authzURL, nil, &authz); err != nil {
+			return fmt.Errorf("acme authorization poll: %w", err)
+		}
+		switch authz.Status {
+		case "valid":
+			return nil
+		case "pending", "processing":
+		default:
+			for _, ch := range authz.Challenges {
+				if ch.Error != nil {
+					return fmt.Errorf("acme: %s validation failed: %w", authz.Identifier.Value, ch.Error)
+				}
+			}
+			return fmt.Errorf("acme: authorization for %s is %s", authz.Identifier.Value, authz.Status)
+		}
+		if err := c.wait(ctx); err != nil {
+			return err
+		}
+	}
+}
+
+func (c *acmeClient) wait(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(c.pollInterval):
+		return nil
+	}
+}
+
+func (c *acmeClient) get(ctx context.Context, url string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func (c *acmeClient) fetchNonce(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, c.dir.NewNonce, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	c.nonce = resp.Header.Get("replay-nonce")
+	if c.nonce == "" {
+		return errors.New("acme: server returned no nonce")
+	}
+	return nil
+}
+
+// post sends a JWS-signed request; a nil payload makes it a POST-as-GET.
+// The response body is decoded into out, or copied when out is *[]byte.
+func (c *acmeClient) post(ctx context.Context, url string, payload any, out any) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := c.postOnce(ctx, url, payload)
+		if err != nil {
+			return nil, err
+		}
+		body, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode >= 400 {
+			problem := &acmeProblem{Status: resp.StatusCode}
+			_ = json.Unmarshal(body, problem)
+			if strings.HasSuffix(problem.Type, ":badNonce") && attempt == 0 {
+				continue
+			}
+			if problem.Detail == "" {
+				problem.Detail = fmt.Sprintf("status %d", resp.StatusCode)
+			}
+			return nil, problem
+		}
+		switch v := out.(type) {
+		case nil:
+		case *[]byte:
+			*v = body
+		default:
+			if err := json.Unmarshal(body, out); err != nil {
+				return nil, err
+			}
+		}
+		return resp, nil
+	}
+}
+
+func (c *acmeClient) postOnce(ctx context.Context, url string, payload any) (*http.Response, error) {
+	if c.nonce == "" {
+		if err := c.fetchNonce(ctx); err != nil {
+			return nil, err
+		}
+	}
+	protected := map[string]any{"alg": "ES256", "nonce": c.nonce, "url": url}
+	if c.kid != "" {
+		protected["kid"] = c.kid
+	} else {
+		protected["jwk"] = json.RawMessage(c.jwk())
+	}
+	c.nonce = ""
+	rawProtected, err := json.Marshal(protected)
+	if err != nil {
+		return nil, err
+	}
+	encPayload := ""
+	if payload != nil {
+		rawPayload, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		encPayload = b64(rawPayload)
+	}
+	encProtected := b64(rawProtected)
+	sig, err := c.sign([]byte(encProtected + "." + encPayload))
+	if err != nil {
+		return nil, err
+	}
+	body, _ := json.Marshal(map[string]string{"protected": encProtected, "payload": encPayload, "signature": b64(sig)})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("content-type", "application/jose+json")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	c.nonce = resp.Header.Get("replay-nonce")
+	return resp, nil
+}
+
+// sign returns the ES256 signature of data as the fixed-size r||s pair JWS
+// expects.
+func (c *acmeClient) sign(data []byte) ([]byte, error) {
+	digest := sha256.Sum256(data)
+	r, s, err := ecdsa.Sign(rand.Reader, c.key, digest[:])
+	if err != nil {
+		return nil, err
+	}
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	return sig, nil
+}
+
+// jwk is the account public key with members in the lexicographic order
+// the RFC 7638 thumbprint requires.
+func (c *acmeClient) jwk() string {
+	x := make([]byte, 32)
+	y := make([]byte, 32)
+	c.key.PublicKey.X.FillBytes(x)
+	c.key.PublicKey.Y.FillBytes(y)
+	return fmt.Sprintf(`{"crv":"P-256","kty":"EC","x":"%s","y":"%s"}`, b64(x), b64(y))
+}
+
+func (c *acmeClient) thumbprint() string {
+	sum := sha256.Sum256([]byte(c.jwk()))
+	return b64(sum[:])
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,381 @@
+// Package tlsserve terminates TLS for the gateway listener, from a
+// certificate/key pair on disk or from certificates it provisions and
+// renews itself over ACME (Let's Encrypt by default).
+package tlsserve
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"ccgateway/internal/upstream"
+)
+
+const (
+	DefaultACMEDirectory = "https://acme-v02.api.letsencrypt.org/directory"
+	challengePathPrefix  = "/.well-known/acme-challenge/"
+)
+
+type Config struct {
+	// CertFile and KeyFile serve a fixed certificate; the files are
+	// re-read when they change, so an external renewal needs no restart.
+	CertFile string
+	KeyFile  string
+	// ACMEDomains turns on automatic certificates for these hostnames.
+	ACMEDomains   []string
+	ACMEEmail     string
+	ACMEDirectory string
+	// ACMECacheDir keeps the account key and issued certificates across
+	// restarts.
+	ACMECacheDir string
+	// RenewBefore renews an ACME certificate this long before it expires.
+	RenewBefore time.Duration
+	// HTTPAddr, when set, serves plain HTTP that answers ACME challenges
+	// and redirects everything else to HTTPS.
+	HTTPAddr string
+	// HTTPSPort is the port redirects point to; empty or "443" is omitted.
+	HTTPSPort string
+}
+
+// Status is what the manager reports about the certificate it serves.
+type Status struct {
+	Mode      string    `json:"mode"`
+	Domains   []string  `json:"domains,omitempty"`
+	NotAfter  time.Time `json:"not_after,omitempty"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+type Manager struct {
+	cfg  Config
+	acme *acmeClient
+
+	mu         sync.RWMutex
+	cert       *tls.Certificate
+	leaf       *x509.Certificate
+	certMod    time.Time
+	keyMod     time.Time
+	lastErr    string
+	challenges map[string]string
+}
+
+// FromEnv returns nil unless TLS_CERT_FILE/TLS_KEY_FILE or ACME_DOMAINS is
+// set. httpsPort is the port the gateway listens on.
+func FromEnv(httpsPort string) (*Manager, error) {
+	cfg := Config{
+		CertFile:      strings.TrimSpace(os.Getenv("TLS_CERT_FILE")),
+		KeyFile:       strings.TrimSpace(os.Getenv("TLS_KEY_FILE")),
+		ACMEEmail:     strings.TrimSpace(os.Getenv("ACME_EMAIL")),
+		ACMEDirectory: strings.TrimSpace(os.Getenv("ACME_DIRECTORY_URL")),
+		ACMECacheDir:  strings.TrimSpace(os.Getenv("ACME_CACHE_DIR")),
+		RenewBefore:   upstream.ParseDurationEnv("ACME_RENEW_BEFORE", 30*24*time.Hour),
+		HTTPAddr:      strings.TrimSpace(os.Getenv("TLS_HTTP_ADDR")),
+		HTTPSPort:     httpsPort,
+	}
+	for _, d := range strings.Split(os.Getenv("ACME_DOMAINS"), ",") {
+		if d = strings.ToLower(strings.TrimSpace(d)); d != "" {
+			cfg.ACMEDomains = append(cfg.ACMEDomains, d)
+		}
+	}
+	if cfg.CertFile == "" && cfg.KeyFile == "" && len(cfg.ACMEDomains) == 0 {
+		return nil, nil
+	}
+	if len(cfg.ACMEDomains) > 0 && cfg.HTTPAddr == "" {
+		cfg.HTTPAddr = ":80"
+	}
+	return New(cfg)
+}
+
+func New(cfg Config) (*Manager, error) {
+	m := &Manager{cfg: cfg, challenges: map[string]string{}}
+	switch {
+	case len(cfg.ACMEDomains) > 0:
+		if cfg.CertFile != "" || cfg.KeyFile != "" {
+			return nil, errors.New("tls: set either TLS_CERT_FILE/TLS_KEY_FILE or ACME_DOMAINS, not both")
+		}
+		if m.cfg.ACMEDirectory == "" {
+			m.cfg.ACMEDirectory = DefaultACMEDirectory
+		}
+		if m.cfg.ACMECacheDir == "" {
+			m.cfg.ACMECacheDir = "acme-cache"
+		}
+		if m.cfg.RenewBefore <= 0 {
+			m.cfg.RenewBefore = 30 * 24 * time.Hour
+		}
+		if err := os.MkdirAll(m.cfg.ACMECacheDir, 0o700); err != nil {
+			return nil, fmt.Errorf("tls: acme cache dir: %w", err)
+		}
+		key, err := m.accountKey()
+		if err != nil {
+			return nil, err
+		}
+		m.acme = &acmeClient{
+			directoryURL: m.cfg.ACMEDirectory,
+			email:        cfg.ACMEEmail,
+			key:          key,
+			http:         &http.Client{Timeout: 30 * time.Second},
+			pollInterval: 2 * time.Second,
+		}
+		// A certificate cached by an earlier run is served right away;
+		// Run renews it if needed.
+		if chain, err := os.ReadFile(m.cachePath(".crt")); err == nil {
+			if keyPEM, err := os.ReadFile(m.cachePath(".key")); err == nil {
+				_ = m.install(chain, keyPEM)
+			}
+		}
+	case cfg.CertFile != "" && cfg.KeyFile != "":
+		if err := m.loadFiles(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("tls: TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	return m, nil
+}
+
+// TLSConfig is the listener configuration; certificates are picked per
+// handshake so renewals apply without a restart.
+func (m *Manager) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: m.GetCertificate,
+		NextProtos:     []string{"h2", "http/1.1"},
+	}
+}
+
+func (m *Manager) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.cert == nil {
+		return nil, errors.New("tls: certificate not provisioned yet")
+	}
+	return m.cert, nil
+}
+
+func (m *Manager) HTTPAddr() string {
+	return m.cfg.HTTPAddr
+}
+
+func (m *Manager) Status() Status {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	st := Status{Mode: "files", LastError: m.lastErr}
+	if m.acme != nil {
+		st.Mode = "acme"
+		st.Domains = append([]string(nil), m.cfg.ACMEDomains...)
+	}
+	if m.leaf != nil {
+		st.NotAfter = m.leaf.NotAfter
+	}
+	return st
+}
+
+// HTTPHandler answers ACME http-01 challenges and redirects every other
+// request to HTTPS.
+func (m *Manager) HTTPHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token, ok := strings.CutPrefix(r.URL.Path, challengePathPrefix); ok {
+			m.mu.RLock()
+			keyAuth, found := m.challenges[token]
+			m.mu.RUnlock()
+			if !found {
+				http.NotFound(w, r)
+				return
+			}
+			w.Header().Set("content-type", "text/plain")
+			_, _ = w.Write([]byte(keyAuth))
+			return
+		}
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if port := strings.TrimPrefix(m.cfg.HTTPSPort, ":"); port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
+
+// Run keeps the certificate current until ctx ends: ACME certificates are
+// obtained and renewed, certificate files are reloaded when they change.
+// Failures are reported to onError and retried.
+func (m *Manager) Run(ctx context.Context, onError func(error)) {
+	interval := time.Minute
+	if m.acme != nil {
+		interval = time.Hour
+	}
+	for {
+		if err := m.Refresh(ctx); err != nil && ctx.Err() == nil && onError != nil {
+			onError(err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// Refresh renews an ACME certificate that is missing or close to expiry,
+// or reloads changed certificate files.
+func (m *Manager) Refresh(ctx context.Context) error {
+	var err error
+	if m.acme != nil {
+		err = m.renewACME(ctx)
+	} else {
+		err = m.reloadFiles()
+	}
+	m.mu.Lock()
+	m.lastErr = ""
+	if err != nil {
+		m.lastErr = err.Error()
+	}
+	m.mu.Unlock()
+	return err
+}
+
+func (m *Manager) renewACME(ctx context.Context) error {
+	m.mu.RLock()
+	leaf := m.leaf
+	m.mu.RUnlock()
+	if leaf != nil && time.Until(leaf.NotAfter) > m.cfg.RenewBefore && coversDomains(leaf, m.cfg.ACMEDomains) {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+	chain, keyPEM, err := m.acme.obtain(ctx, m.cfg.ACMEDomains, m)
+	if err != nil {
+		return err
+	}
+	if err := m.install(chain, keyPEM); err != nil {
+		return err
+	}
+	if err := os.WriteFile(m.cachePath(".key"), keyPEM, 0o600); err != nil {
+		return fmt.Errorf("tls: cache certificate key: %w", err)
+	}
+	if err := os.WriteFile(m.cachePath(".crt"), chain, 0o600); err != nil {
+		return fmt.Errorf("tls: cache certificate: %w", err)
+	}
+	return nil
+}
+
+func (m *Manager) loadFiles() error {
+	certInfo, err := os.Stat(m.cfg.CertFile)
+	if err != nil {
+		return fmt.Errorf("tls: %w", err)
+	}
+	keyInfo, err := os.Stat(m.cfg.KeyFile)
+	if err != nil {
+		return fmt.Errorf("tls: %w", err)
+	}
+	chain, err := os.ReadFile(m.cfg.CertFile)
+	if err != nil {
+		return fmt.Errorf("tls: %w", err)
+	}
+	keyPEM, err := os.ReadFile(m.cfg.KeyFile)
+	if err != nil {
+		return fmt.Errorf("tls: %w", err)
+	}
+	if err := m.install(chain, keyPEM); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.certMod, m.keyMod = certInfo.ModTime(), keyInfo.ModTime()
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *Manager) reloadFiles() error {
+	certInfo, err := os.Stat(m.cfg.CertFile)
+	if err != nil {
+		return fmt.Errorf("tls: %w", err)
+	}
+	keyInfo, err := os.Stat(m.cfg.KeyFile)
+	if err != nil {
+		return fmt.Errorf("tls: %w", err)
+	}
+	m.mu.RLock()
+	unchanged := certInfo.ModTime().Equal(m.certMod) && keyInfo.ModTime().Equal(m.keyMod)
+	m.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+	return m.loadFiles()
+}
+
+// install parses a PEM chain and key and starts serving them.
+func (m *Manager) install(chain, keyPEM []byte) error {
+	cert, err := tls.X509KeyPair(chain, keyPEM)
+	if err != nil {
+		return fmt.Errorf("tls: %w", err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return fmt.Errorf("tls: %w", err)
+	}
+	m.mu.Lock()
+	m.cert, m.leaf = &cert, leaf
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *Manager) present(token, keyAuth string) {
+	m.mu.Lock()
+	m.challenges[token] = keyAuth
+	m.mu.Unlock()
+}
+
+func (m *Manager) cleanup(token string) {
+	m.mu.Lock()
+	delete(m.challenges, token)
+	m.mu.Unlock()
+}
+
+func (m *Manager) cachePath(ext string) string {
+	return filepath.Join(m.cfg.ACMECacheDir, m.cfg.ACMEDomains[0]+ext)
+}
+
+func (m *Manager) accountKey() (*ecdsa.PrivateKey, error) {
+	file := filepath.Join(m.cfg.ACMECacheDir, "account.key")
+	if raw, err := os.ReadFile(file); err == nil {
+		block, _ := pem.Decode(raw)
+		if block == nil {
+			return nil, fmt.Errorf("tls: %s is not a PEM key", file)
+		}
+		return x509.ParseECPrivateKey(block.Bytes)
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		return nil, fmt.Errorf("tls: save acme account key: %w", err)
+	}
+	return key, nil
+}
+
+func coversDomains(leaf *x509.Certificate, domains []string) bool {
+	for _, d := range domains {
+		if leaf.VerifyHostname(d) != nil {
+			return false
+		}
+	}
+	return true
+}
@@ -45,6 +45,7 @@ type CreateInput struct {
 }
 
 type UpdateInput struct {
+	PlanID      *string         `json:"plan_id,omitempty"`
 	Title       *string         `json:"title,omitempty"`
 	Description *string         `json:"description,omitempty"`
 	Status      *string         `json:"status,omitempty"`
@@ -124,6 +125,9 @@ func (s *Store) updateLocked(id string, in UpdateInput) (Todo, error) {
 		return Todo{}, fmt.Errorf("todo %q not found", id)
 	}
 
+	if in.PlanID != nil {
+		td.PlanID = strings.TrimSpace(*in.PlanID)
+	}
 	if in.Title != nil {
 		td.Title = strings.TrimSpace(*in.Title)
 		if td.Title == "" {
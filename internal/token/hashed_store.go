@@ -0,0 +1,621 @@
+package token
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	hashedValuePrefix  = "sk-cc-"
+	displayPrefixLen   = len(hashedValuePrefix) + 6
+	defaultUsageFlush  = 5 * time.Second
+	hashedStoreVersion = 1
+)
+
+// HashedFileService implements Service without ever persisting token values.
+// Each token keeps a random salt and sha256(salt||value); lookups narrow the
+// candidates by the display prefix and compare hashes in constant time.
+//
+// Structural changes (create, update, rotate, delete) are written through.
+// Usage counters and last-used timestamps are written at most once per
+// flush interval; call Flush on shutdown to persist the remainder.
+type HashedFileService struct {
+	mu        sync.Mutex
+	path      string
+	records   map[int64]*hashedRecord
+	deleted   map[int64]*hashedRecord
+	byPrefix  map[string][]int64
+	nextID    int64
+	dirty     bool
+	lastSave  time.Time
+	flushEach time.Duration
+	now       func() time.Time
+	// onThreshold receives soft-limit crossings under the store lock.
+	onThreshold func(ThresholdEvent)
+}
+
+type hashedRecord struct {
+	Token Token  `json:"token"`
+	Salt  string `json:"salt"`
+	Hash  string `json:"hash"`
+}
+
+type hashedFile struct {
+	Version int             `json:"version"`
+	NextID  int64           `json:"next_id"`
+	Tokens  []*hashedRecord `json:"tokens"`
+	Deleted []*hashedRecord `json:"deleted,omitempty"`
+}
+
+// NewHashedFileService loads the store at path. An empty path keeps the
+// hashed store in memory only.
+func NewHashedFileService(path string) (*HashedFileService, error) {
+	s := &HashedFileService{
+		path:      strings.TrimSpace(path),
+		records:   make(map[int64]*hashedRecord),
+		deleted:   make(map[int64]*hashedRecord),
+		byPrefix:  make(map[string][]int64),
+		nextID:    1,
+		flushEach: defaultUsageFlush,
+		now:       time.Now,
+	}
+	if s.path == "" {
+		return s, nil
+	}
+	raw, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read token store: %w", err)
+	}
+	var file hashedFile
+	if err := json.Unmarshal(raw, &file); err != nil {
+		return nil, fmt.Errorf("decode token store %s: %w", s.path, err)
+	}
+	for _, rec := range file.Tokens {
+		if rec == nil || rec.Token.ID <= 0 || rec.Hash == "" {
+			continue
+		}
+		rec.Token.Value = ""
+		s.records[rec.Token.ID] = rec
+		s.indexLocked(rec)
+		if rec.Token.ID >= s.nextID {
+			s.nextID = rec.Token.ID + 1
+		}
+	}
+	for _, rec := range file.Deleted {
+		if rec == nil || rec.Token.ID <= 0 || rec.Hash == "" {
+			continue
+		}
+		rec.Token.Value = ""
+		s.deleted[rec.Token.ID] = rec
+		if rec.Token.ID >= s.nextID {
+			s.nextID = rec.Token.ID + 1
+		}
+	}
+	if file.NextID > s.nextID {
+		s.nextID = file.NextID
+	}
+	return s, nil
+}
+
+// NewHashedFileServiceFromEnv opens the store named by TOKEN_STORE_PATH.
+// It returns nil when the variable is unset so callers can fall back to
+// the in-memory service.
+func NewHashedFileServiceFromEnv() (*HashedFileService, error) {
+	path := strings.TrimSpace(os.Getenv("TOKEN_STORE_PATH"))
+	if path == "" {
+		return nil, nil
+	}
+	return NewHashedFileService(path)
+}
+
+func (s *HashedFileService) Generate(userID string, quota int64) (*Token, error) {
+	userID = strings.TrimSpace(userID)
+	if userID == "" {
+		return nil, fmt.Errorf("user id is required")
+	}
+	value, salt, hash, err := newHashedValue()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	rec := &hashedRecord{
+		Token: Token{
+			ID:             s.nextID,
+			UserID:         userID,
+			Name:           "default",
+			Prefix:         displayPrefix(value),
+			Status:         StatusEnabled,
+			Quota:          maxInt64(0, quota),
+			UnlimitedQuota: quota <= 0,
+			CreatedAt:      now,
+			AccessedAt:     now,
+			ExpiredAt:      -1,
+		},
+		Salt: salt,
+		Hash: hash,
+	}
+	s.nextID++
+	s.records[rec.Token.ID] = rec
+	s.indexLocked(rec)
+	if err := s.saveLocked(); err != nil {
+		s.removeLocked(rec)
+		return nil, err
+	}
+	return rec.reveal(value), nil
+}
+
+func (s *HashedFileService) Validate(tokenValue string) (*Token, error) {
+	tokenValue = strings.TrimSpace(tokenValue)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec := s.lookupLocked(tokenValue)
+	if rec == nil {
+		return nil, ErrInvalidToken
+	}
+	tk := &rec.Token
+	status := normalizeTokenStatus(tk.Status)
+	if status == StatusDisabled {
+		return nil, ErrTokenDisabled
+	}
+	if status == StatusExpired || (tk.ExpiredAt > 0 && tk.ExpiredAt < s.now().Unix()) {
+		return nil, ErrTokenExpired
+	}
+	if status == StatusExhausted || (!tk.UnlimitedQuota && tk.Quota <= 0) {
+		return nil, ErrQuotaExceeded
+	}
+	rollWindows(tk.QuotaWindows, s.now())
+	if err := checkWindows(tk.QuotaWindows, 0); err != nil {
+		return nil, err
+	}
+	now := s.now()
+	tk.LastUsedAt = &now
+	s.touchLocked()
+	return rec.reveal(tokenValue), nil
+}
+
+func (s *HashedFileService) DeductQuota(tokenValue string, amount int64) error {
+	tokenValue = strings.TrimSpace(tokenValue)
+	if tokenValue == "" {
+		return ErrInvalidToken
+	}
+	if amount <= 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec := s.lookupLocked(tokenValue)
+	if rec == nil {
+		return ErrInvalidToken
+	}
+	tk := &rec.Token
+	rollWindows(tk.QuotaWindows, s.now())
+	if err := checkWindows(tk.QuotaWindows, amount); err != nil {
+		return err
+	}
+	if !tk.UnlimitedQuota && tk.Quota < amount {
+		tk.Status = StatusExhausted
+		s.touchLocked()
+		return ErrQuotaExceeded
+	}
+	tk.Used += amount
+	if !tk.UnlimitedQuota {
+		tk.Quota -= amount
+		if tk.Quota <= 0 {
+			tk.Status = StatusExhausted
+		}
+	}
+	for _, ev := range chargeWindows(tk, amount) {
+		if s.onThreshold != nil {
+			s.onThreshold(ev)
+		}
+	}
+	tk.AccessedAt = s.now()
+	s.touchLocked()
+	return nil
+}
+
+func (s *HashedFileService) RefundQuota(tokenValue string, amount int64) error {
+	tokenValue = strings.TrimSpace(tokenValue)
+	if tokenValue == "" {
+		return ErrInvalidToken
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec := s.lookupLocked(tokenValue)
+	if rec == nil {
+		return ErrInvalidToken
+	}
+	tk := &rec.Token
+	if amount < 0 {
+		tk.Used += amount
+		if tk.Used < 0 {
+			tk.Used = 0
+		}
+	} else if !tk.UnlimitedQuota {
+		tk.Quota += amount
+	}
+	refundWindows(tk.QuotaWindows, max(amount, -amount))
+	if tk.Status == StatusExhausted && tk.RemainingQuota() > 0 {
+		tk.Status = StatusEnabled
+	}
+	tk.AccessedAt = s.now()
+	s.touchLocked()
+	return nil
+}
+
+// List returns the user's tokens ordered by id. Values are masked to the
+// display prefix since the store cannot recover them.
+func (s *HashedFileService) List(userID string) []*Token {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]*Token, 0)
+	for _, rec := range s.records {
+		if rec.Token.UserID == userID {
+			list = append(list, rec.reveal(rec.Token.Prefix+"..."))
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+func (s *HashedFileService) Get(tokenValue string) (*Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec := s.lookupLocked(strings.TrimSpace(tokenValue))
+	if rec == nil {
+		return nil, ErrInvalidToken
+	}
+	return rec.reveal(tokenValue), nil
+}
+
+// Update matches by id, falling back to the token value, because values
+// returned by List are masked.
+func (s *HashedFileService) Update(token *Token) error {
+	if token == nil {
+		return ErrInvalidToken
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec := s.records[token.ID]
+	if rec == nil {
+		rec = s.lookupLocked(strings.TrimSpace(token.Value))
+	}
+	if rec == nil {
+		return ErrInvalidToken
+	}
+	existing := &rec.Token
+	windows, err := NormalizeQuotaWindows(existing.QuotaWindows, token.QuotaWindows, s.now())
+	if err != nil {
+		return err
+	}
+	existing.Name = token.Name
+	existing.Quota = maxInt64(0, token.Quota)
+	existing.UnlimitedQuota = token.UnlimitedQuota || token.Quota <= 0
+	status := normalizeTokenStatus(token.Status)
+	if status == StatusEnabled && !existing.UnlimitedQuota && existing.Quota <= 0 {
+		status = StatusExhausted
+	}
+	existing.Status = status
+	existing.Models = token.Models
+	existing.Subnet = token.Subnet
+	existing.Adapters = token.Adapters
+	existing.ExpiredAt = token.ExpiredAt
+	existing.QuotaWindows = windows
+	return s.saveLocked()
+}
+
+func (s *HashedFileService) Delete(tokenValue string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec := s.lookupLocked(strings.TrimSpace(tokenValue))
+	if rec == nil {
+		return ErrInvalidToken
+	}
+	s.removeLocked(rec)
+	return s.saveLocked()
+}
+
+// DeleteByID removes the token with the given id.
+func (s *HashedFileService) DeleteByID(id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec := s.records[id]
+	if rec == nil {
+		return ErrInvalidToken
+	}
+	s.removeLocked(rec)
+	return s.saveLocked()
+}
+
+// SoftDeleteByID moves the token to the trash. The trash is persisted with
+// the store, so the token can be restored after a restart until purged.
+func (s *HashedFileService) SoftDeleteByID(id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec := s.records[id]
+	if rec == nil {
+		return ErrInvalidToken
+	}
+	s.removeLocked(rec)
+	now := s.now()
+	rec.Token.DeletedAt = &now
+	s.deleted[id] = rec
+	return s.saveLocked()
+}
+
+// RestoreByID brings a soft-deleted token back; its old value validates
+// again.
+func (s *HashedFileService) RestoreByID(id int64) (*Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec := s.deleted[id]
+	if rec == nil {
+		return nil, ErrInvalidToken
+	}
+	delete(s.deleted, id)
+	rec.Token.DeletedAt = nil
+	s.records[id] = rec
+	s.indexLocked(rec)
+	if err := s.saveLocked(); err != nil {
+		return nil, err
+	}
+	return rec.reveal(rec.Token.Prefix + "..."), nil
+}
+
+// ListDeleted returns the tokens in the trash, values masked.
+func (s *HashedFileService) ListDeleted() []*Token {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]*Token, 0, len(s.deleted))
+	for _, rec := range s.deleted {
+		list = append(list, rec.reveal(rec.Token.Prefix+"..."))
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+// PurgeByID drops a soft-deleted token for good.
+func (s *HashedFileService) PurgeByID(id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.deleted[id] == nil {
+		return ErrInvalidToken
+	}
+	delete(s.deleted, id)
+	return s.saveLocked()
+}
+
+// Rotate issues a new value for the token with the given id, keeping its
+// quota, restrictions and usage. The returned token carries the new value;
+// it is the only time the value is available.
+func (s *HashedFileService) Rotate(id int64) (*Token, error) {
+	value, salt, hash, err := newHashedValue()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec := s.records[id]
+	if rec == nil {
+		return nil, ErrInvalidToken
+	}
+	prev := *rec
+	s.unindexLocked(rec)
+	now := s.now()
+	rec.Salt = salt
+	rec.Hash = hash
+	rec.Token.Prefix = displayPrefix(value)
+	rec.Token.RotatedAt = &now
+	s.indexLocked(rec)
+	if err := s.saveLocked(); err != nil {
+		s.unindexLocked(rec)
+		*rec = prev
+		s.indexLocked(rec)
+		return nil, err
+	}
+	return rec.reveal(value), nil
+}
+
+// ResetQuotaWindows clears the usage of one window period, or all windows
+// when period is empty.
+func (s *HashedFileService) ResetQuotaWindows(id int64, period string) (*Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec := s.records[id]
+	if rec == nil {
+		return nil, ErrInvalidToken
+	}
+	if !ResetQuotaWindows(rec.Token.QuotaWindows, period, s.now()) {
+		return nil, ErrInvalidQuotaWindow
+	}
+	if err := s.saveLocked(); err != nil {
+		return nil, err
+	}
+	return rec.reveal(rec.Token.Prefix + "..."), nil
+}
+
+// OnQuotaThreshold registers fn to receive soft-limit crossings. fn runs
+// while the store lock is held and must not call back into the store.
+func (s *HashedFileService) OnQuotaThreshold(fn func(ThresholdEvent)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onThreshold = fn
+}
+
+// Flush writes pending usage updates.
+func (s *HashedFileService) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.dirty {
+		return nil
+	}
+	return s.saveLocked()
+}
+
+func (s *HashedFileService) lookupLocked(value string) *hashedRecord {
+	if !strings.HasPrefix(value, hashedValuePrefix) || len(value) <= displayPrefixLen {
+		return nil
+	}
+	for _, id := range s.byPrefix[displayPrefix(value)] {
+		rec := s.records[id]
+		if rec == nil {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(hashValue(rec.Salt, value)), []byte(rec.Hash)) == 1 {
+			return rec
+		}
+	}
+	return nil
+}
+
+func (s *HashedFileService) indexLocked(rec *hashedRecord) {
+	p := rec.Token.Prefix
+	s.byPrefix[p] = append(s.byPrefix[p], rec.Token.ID)
+}
+
+func (s *HashedFileService) unindexLocked(rec *hashedRecord) {
+	p := rec.Token.Prefix
+	ids := s.byPrefix[p]
+	for i, id := range ids {
+		if id == rec.Token.ID {
+			ids = append(ids[:i], ids[i+1:]...)
+			break
+		}
+	}
+	if len(ids) == 0 {
+		delete(s.byPrefix, p)
+		return
+	}
+	s.byPrefix[p] = ids
+}
+
+func (s *HashedFileService) removeLocked(rec *hashedRecord) {
+	s.unindexLocked(rec)
+	delete(s.records, rec.Token.ID)
+}
+
+// touchLocked persists usage changes, throttled to the flush interval.
+func (s *HashedFileService) touchLocked() {
+	s.dirty = true
+	if s.now().Sub(s.lastSave) < s.flushEach {
+		return
+	}
+	_ = s.saveLocked()
+}
+
+func (s *HashedFileService) saveLocked() error {
+	if s.path == "" {
+		s.dirty = false
+		return nil
+	}
+	file := hashedFile{Version: hashedStoreVersion, NextID: s.nextID, Tokens: make([]*hashedRecord, 0, len(s.records))}
+	for _, rec := range s.records {
+		file.Tokens = append(file.Tokens, rec)
+	}
+	sort.Slice(file.Tokens, func(i, j int) bool { return file.Tokens[i].Token.ID < file.Tokens[j].Token.ID })
+	for _, rec := range s.deleted {
+		file.Deleted = append(file.Deleted, rec)
+	}
+	sort.Slice(file.Deleted, func(i, j int) bool { return file.Deleted[i].Token.ID < file.Deleted[j].Token.ID })
+	raw, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(s.path); dir != "" {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return fmt.Errorf("create token store dir: %w", err)
+		}
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0o600); err != nil {
+		return fmt.Errorf("write token store: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("write token store: %w", err)
+	}
+	s.dirty = false
+	s.lastSave = s.now()
+	return nil
+}
+
+func (rec *hashedRecord) reveal(value string) *Token {
+	out := rec.Token
+	out.Value = value
+	if rec.Token.Models != nil {
+		v := *rec.Token.Models
+		out.Models = &v
+	}
+	if rec.Token.Subnet != nil {
+		v := *rec.Token.Subnet
+		out.Subnet = &v
+	}
+	if rec.Token.Adapters != nil {
+		v := *rec.Token.Adapters
+		out.Adapters = &v
+	}
+	out.QuotaWindows = copyWindows(rec.Token.QuotaWindows)
+	return &out
+}
+
+func newHashedValue() (value, salt, hash string, err error) {
+	seed := make([]byte, 24)
+	if _, err = rand.Read(seed); err != nil {
+		return "", "", "", fmt.Errorf("generate token value: %w", err)
+	}
+	saltBytes := make([]byte, 16)
+	if _, err = rand.Read(saltBytes); err != nil {
+		return "", "", "", fmt.Errorf("generate token salt: %w", err)
+	}
+	value = hashedValuePrefix + hex.EncodeToString(seed)
+	salt = hex.EncodeToString(saltBytes)
+	return value, salt, hashValue(salt, value), nil
+}
+
+func hashValue(salt, value string) string {
+	sum := sha256.Sum256([]byte(salt + value))
+	return hex.EncodeToString(sum[:])
+}
+
+func displayPrefix(value string) string {
+	if len(value) <= displayPrefixLen {
+		return value
+	}
+	return value[:displayPrefixLen]
+}
@@ -0,0 +1,194 @@
+package token
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Quota window periods. Windows reset at UTC midnight and on the first of
+// the month (UTC) respectively.
+const (
+	PeriodDaily   = "daily"
+	PeriodMonthly = "monthly"
+)
+
+// defaultWarnPercent is the soft limit used when a window sets none.
+const defaultWarnPercent = 80
+
+// QuotaWindow is a budget that resets on a schedule, on top of the token's
+// lifetime quota. Used counts consumption since the window started;
+// ResetAt is when the next window starts.
+type QuotaWindow struct {
+	Period      string    `json:"period"`
+	Limit       int64     `json:"limit"`
+	Used        int64     `json:"used"`
+	WarnPercent int       `json:"warn_percent"`
+	Warned      bool      `json:"warned,omitempty"`
+	ResetAt     time.Time `json:"reset_at"`
+}
+
+// Remaining returns what is left in the window.
+func (w QuotaWindow) Remaining() int64 {
+	if w.Used >= w.Limit {
+		return 0
+	}
+	return w.Limit - w.Used
+}
+
+// QuotaWindowError reports a spent window. It matches ErrQuotaExceeded.
+type QuotaWindowError struct {
+	Period  string
+	Limit   int64
+	ResetAt time.Time
+}
+
+func (e *QuotaWindowError) Error() string {
+	return fmt.Sprintf("%s quota of %d exceeded; resets at %s", e.Period, e.Limit, e.ResetAt.UTC().Format(time.RFC3339))
+}
+
+func (e *QuotaWindowError) Is(target error) bool {
+	return target == ErrQuotaExceeded
+}
+
+// ThresholdEvent is raised once per window when usage crosses the window's
+// soft limit.
+type ThresholdEvent struct {
+	TokenID     int64     `json:"token_id"`
+	UserID      string    `json:"user_id"`
+	TokenName   string    `json:"token_name,omitempty"`
+	Period      string    `json:"period"`
+	Limit       int64     `json:"limit"`
+	Used        int64     `json:"used"`
+	WarnPercent int       `json:"warn_percent"`
+	ResetAt     time.Time `json:"reset_at"`
+}
+
+var ErrInvalidQuotaWindow = errors.New("quota windows need a period of daily or monthly, a positive limit and warn_percent between 0 and 100")
+
+// NormalizeQuotaWindows validates windows and carries usage over from
+// current for periods that keep existing, so changing a limit mid-window
+// does not forgive what was already spent.
+func NormalizeQuotaWindows(current, next []QuotaWindow, now time.Time) ([]QuotaWindow, error) {
+	if len(next) == 0 {
+		return nil, nil
+	}
+	prev := make(map[string]QuotaWindow, len(current))
+	for _, w := range current {
+		prev[w.Period] = w
+	}
+	out := make([]QuotaWindow, 0, len(next))
+	seen := make(map[string]bool, len(next))
+	for _, w := range next {
+		w.Period = strings.ToLower(strings.TrimSpace(w.Period))
+		if (w.Period != PeriodDaily && w.Period != PeriodMonthly) || w.Limit <= 0 || w.WarnPercent < 0 || w.WarnPercent > 100 || seen[w.Period] {
+			return nil, ErrInvalidQuotaWindow
+		}
+		seen[w.Period] = true
+		if w.WarnPercent == 0 {
+			w.WarnPercent = defaultWarnPercent
+		}
+		if p, ok := prev[w.Period]; ok {
+			w.Used = p.Used
+			w.ResetAt = p.ResetAt
+			w.Warned = p.Warned && p.Limit == w.Limit && p.WarnPercent == w.WarnPercent
+		} else {
+			w.Used = 0
+			w.Warned = false
+			w.ResetAt = nextReset(w.Period, now)
+		}
+		out = append(out, w)
+	}
+	rollWindows(out, now)
+	return out, nil
+}
+
+// ResetQuotaWindows clears usage for period, or every window when period
+// is empty, and starts a fresh window.
+func ResetQuotaWindows(windows []QuotaWindow, period string, now time.Time) bool {
+	period = strings.ToLower(strings.TrimSpace(period))
+	found := false
+	for i := range windows {
+		if period != "" && windows[i].Period != period {
+			continue
+		}
+		windows[i].Used = 0
+		windows[i].Warned = false
+		windows[i].ResetAt = nextReset(windows[i].Period, now)
+		found = true
+	}
+	return found
+}
+
+func nextReset(period string, now time.Time) time.Time {
+	now = now.UTC()
+	switch period {
+	case PeriodMonthly:
+		return time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+	}
+}
+
+// rollWindows starts a new window for every window whose reset time passed.
+func rollWindows(windows []QuotaWindow, now time.Time) {
+	for i := range windows {
+		if windows[i].ResetAt.IsZero() || !now.Before(windows[i].ResetAt) {
+			windows[i].Used = 0
+			windows[i].Warned = false
+			windows[i].ResetAt = nextReset(windows[i].Period, now)
+		}
+	}
+}
+
+// checkWindows returns the first window that cannot absorb amount.
+func checkWindows(windows []QuotaWindow, amount int64) error {
+	for _, w := range windows {
+		if w.Used+amount > w.Limit || (amount == 0 && w.Used >= w.Limit) {
+			return &QuotaWindowError{Period: w.Period, Limit: w.Limit, ResetAt: w.ResetAt}
+		}
+	}
+	return nil
+}
+
+// chargeWindows adds amount to every window and returns the soft limits it
+// crossed.
+func chargeWindows(tk *Token, amount int64) []ThresholdEvent {
+	var events []ThresholdEvent
+	for i := range tk.QuotaWindows {
+		w := &tk.QuotaWindows[i]
+		w.Used += amount
+		if w.Warned || w.Used*100 < w.Limit*int64(w.WarnPercent) {
+			continue
+		}
+		w.Warned = true
+		events = append(events, ThresholdEvent{
+			TokenID:     tk.ID,
+			UserID:      tk.UserID,
+			TokenName:   tk.Name,
+			Period:      w.Period,
+			Limit:       w.Limit,
+			Used:        w.Used,
+			WarnPercent: w.WarnPercent,
+			ResetAt:     w.ResetAt,
+		})
+	}
+	return events
+}
+
+func refundWindows(windows []QuotaWindow, amount int64) {
+	for i := range windows {
+		windows[i].Used -= amount
+		if windows[i].Used < 0 {
+			windows[i].Used = 0
+		}
+	}
+}
+
+func copyWindows(in []QuotaWindow) []QuotaWindow {
+	if in == nil {
+		return nil
+	}
+	return append([]QuotaWindow(nil), in...)
+}
@@ -23,16 +23,19 @@ type Service interface {
 
 // InMemoryService implements Service using memory map
 type InMemoryService struct {
-	tokens   map[string]*Token
-	tokenIDs map[int64]*Token
-	nextID   int64
-	mu       sync.RWMutex
+	tokens      map[string]*Token
+	tokenIDs    map[int64]*Token
+	deleted     map[int64]*Token
+	nextID      int64
+	onThreshold func(ThresholdEvent)
+	mu          sync.RWMutex
 }
 
 func NewInMemoryService() *InMemoryService {
 	return &InMemoryService{
 		tokens:   make(map[string]*Token),
 		tokenIDs: make(map[int64]*Token),
+		deleted:  make(map[int64]*Token),
 		nextID:   1,
 	}
 }
@@ -56,6 +59,7 @@ func (s *InMemoryService) Generate(userID string, quota int64) (*Token, error) {
 		Value:          tokenValue,
 		UserID:         userID,
 		Name:           "default",
+		Prefix:         displayPrefix(tokenValue),
 		Status:         StatusEnabled,
 		Quota:          maxInt64(0, quota),
 		UnlimitedQuota: quota <= 0,
@@ -77,8 +81,8 @@ func (s *InMemoryService) Validate(tokenValue string) (*Token, error) {
 		return nil, ErrInvalidToken
 	}
 
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
 	token, ok := s.tokens[tokenValue]
 	if !ok {
@@ -101,6 +105,10 @@ func (s *InMemoryService) Validate(tokenValue string) (*Token, error) {
 	if !token.UnlimitedQuota && token.Quota <= 0 {
 		return nil, ErrQuotaExceeded
 	}
+	rollWindows(token.QuotaWindows, time.Now())
+	if err := checkWindows(token.QuotaWindows, 0); err != nil {
+		return nil, err
+	}
 
 	return token, nil
 }
@@ -122,6 +130,11 @@ func (s *InMemoryService) DeductQuota(tokenValue string, amount int64) error {
 		return ErrInvalidToken
 	}
 
+	// Spent windows reset on their own, so they never mark the token exhausted.
+	rollWindows(token.QuotaWindows, time.Now())
+	if err := checkWindows(token.QuotaWindows, amount); err != nil {
+		return err
+	}
 	if !token.UnlimitedQuota && token.Quota < amount {
 		token.Status = StatusExhausted
 		return ErrQuotaExceeded
@@ -134,6 +147,7 @@ func (s *InMemoryService) DeductQuota(tokenValue string, amount int64) error {
 			token.Status = StatusExhausted
 		}
 	}
+	s.notifyThresholds(chargeWindows(token, amount))
 	token.AccessedAt = time.Now()
 
 	return nil
@@ -164,6 +178,7 @@ func (s *InMemoryService) RefundQuota(tokenValue string, amount int64) error {
 			token.Quota += amount
 		}
 	}
+	refundWindows(token.QuotaWindows, amount)
 
 	// Restore status if was exhausted
 	if token.Status == StatusExhausted && token.RemainingQuota() > 0 {
@@ -211,6 +226,10 @@ func (s *InMemoryService) Update(token *Token) error {
 	if !ok {
 		return ErrInvalidToken
 	}
+	windows, err := NormalizeQuotaWindows(existing.QuotaWindows, token.QuotaWindows, time.Now())
+	if err != nil {
+		return err
+	}
 
 	// Update fields
 	existing.Name = token.Name
@@ -223,7 +242,9 @@ func (s *InMemoryService) Update(token *Token) error {
 	existing.Status = status
 	existing.Models = token.Models
 	existing.Subnet = token.Subnet
+	existing.Adapters = token.Adapters
 	existing.ExpiredAt = token.ExpiredAt
+	existing.QuotaWindows = windows
 
 	return nil
 }
@@ -242,6 +263,136 @@ func (s *InMemoryService) Delete(tokenValue string) error {
 	return nil
 }
 
+// Rotate replaces the value of the token with the given id. The old value
+// stops validating immediately.
+func (s *InMemoryService) Rotate(id int64) (*Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.tokenIDs[id]
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	value, err := newTokenValue()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	delete(s.tokens, token.Value)
+	token.Value = value
+	token.Prefix = displayPrefix(value)
+	token.RotatedAt = &now
+	s.tokens[value] = token
+	return token, nil
+}
+
+// DeleteByID removes the token with the given id.
+func (s *InMemoryService) DeleteByID(id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.tokenIDs[id]
+	if !ok {
+		return ErrInvalidToken
+	}
+	delete(s.tokens, token.Value)
+	delete(s.tokenIDs, id)
+	return nil
+}
+
+// SoftDeleteByID moves the token to the trash. It stops validating at once
+// and can be restored with its old value until purged.
+func (s *InMemoryService) SoftDeleteByID(id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.tokenIDs[id]
+	if !ok {
+		return ErrInvalidToken
+	}
+	delete(s.tokens, token.Value)
+	delete(s.tokenIDs, id)
+	now := time.Now()
+	token.DeletedAt = &now
+	s.deleted[id] = token
+	return nil
+}
+
+// RestoreByID brings a soft-deleted token back.
+func (s *InMemoryService) RestoreByID(id int64) (*Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.deleted[id]
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	delete(s.deleted, id)
+	token.DeletedAt = nil
+	s.tokens[token.Value] = token
+	s.tokenIDs[id] = token
+	return token, nil
+}
+
+// ListDeleted returns the tokens in the trash with their values masked.
+func (s *InMemoryService) ListDeleted() []*Token {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	list := make([]*Token, 0, len(s.deleted))
+	for _, token := range s.deleted {
+		out := *token
+		out.Value = token.Prefix + "..."
+		list = append(list, &out)
+	}
+	return list
+}
+
+// PurgeByID drops a soft-deleted token for good.
+func (s *InMemoryService) PurgeByID(id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.deleted[id]; !ok {
+		return ErrInvalidToken
+	}
+	delete(s.deleted, id)
+	return nil
+}
+
+// ResetQuotaWindows clears the usage of one window period, or all windows
+// when period is empty.
+func (s *InMemoryService) ResetQuotaWindows(id int64, period string) (*Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.tokenIDs[id]
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	if !ResetQuotaWindows(token.QuotaWindows, period, time.Now()) {
+		return nil, ErrInvalidQuotaWindow
+	}
+	return token, nil
+}
+
+// OnQuotaThreshold registers fn to receive soft-limit crossings. fn runs
+// while the service lock is held and must not call back into the service.
+func (s *InMemoryService) OnQuotaThreshold(fn func(ThresholdEvent)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onThreshold = fn
+}
+
+func (s *InMemoryService) notifyThresholds(events []ThresholdEvent) {
+	if s.onThreshold == nil {
+		return
+	}
+	for _, ev := range events {
+		s.onThreshold(ev)
+	}
+}
+
 func newTokenValue() (string, error) {
 	seed := make([]byte, 24)
 	if _, err := rand.Read(seed); err != nil {
@@ -20,20 +20,34 @@ type Token struct {
 	Value  string `json:"value"` // sk-xxxx
 	UserID string `json:"user_id"`
 	Name   string `json:"name,omitempty"` // Token name for identification
+	// Prefix is the non-secret leading part of the value, safe to display
+	// once the value itself is no longer retrievable.
+	Prefix string `json:"prefix,omitempty"`
 
 	Status         int   `json:"status"` // enabled, disabled, expired, exhausted
 	Quota          int64 `json:"quota"`  // remaining quota (0 when exhausted)
 	UnlimitedQuota bool  `json:"unlimited_quota"`
 	Used           int64 `json:"used"` // total used
 
+	// QuotaWindows are daily/monthly budgets enforced on top of Quota.
+	QuotaWindows []QuotaWindow `json:"quota_windows,omitempty"`
+
 	// Restrictions
 	Models *string `json:"models,omitempty"` // Comma-separated allowed models (empty = all)
 	Subnet *string `json:"subnet,omitempty"` // Allowed IP addresses (empty = all)
+	// Adapters the token may pin or exclude per request with the
+	// x-cc-adapter and x-cc-exclude-adapters headers ("*" = any, empty = none).
+	Adapters *string `json:"adapters,omitempty"`
 
 	// Expiration
 	CreatedAt  time.Time `json:"created_at"`
 	AccessedAt time.Time `json:"accessed_at,omitempty"`
 	ExpiredAt  int64     `json:"expired_at"` // -1 = never expires, timestamp = expires at
+
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RotatedAt  *time.Time `json:"rotated_at,omitempty"`
+	// DeletedAt is set while the token is in the trash.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
 }
 
 var (
@@ -95,6 +109,27 @@ func (t *Token) CanUseModel(model string) bool {
 	return containsModel(allowed, model)
 }
 
+// AllowedModels returns the declared model list, or nil when unrestricted.
+func (t *Token) AllowedModels() []string {
+	if t.Models == nil {
+		return nil
+	}
+	return splitAndTrim(*t.Models, ",")
+}
+
+// CanRouteAdapter reports whether the token may pin or exclude adapter.
+func (t *Token) CanRouteAdapter(adapter string) bool {
+	if t.Adapters == nil {
+		return false
+	}
+	for _, allowed := range splitAndTrim(*t.Adapters, ",") {
+		if allowed == "*" || strings.EqualFold(allowed, adapter) {
+			return true
+		}
+	}
+	return false
+}
+
 // CanUseIP checks if token allows using from specific IP
 func (t *Token) CanUseIP(ip string) bool {
 	if t.Subnet == nil || *t.Subnet == "" {
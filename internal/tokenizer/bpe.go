@@ -0,0 +1,182 @@
+package tokenizer
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// BPE is a byte-level byte-pair encoder compatible with tiktoken rank
+// files: text is split with the cl100k/o200k pre-tokenizer and each piece
+// is merged by rank.
+type BPE struct {
+	name  string
+	ranks map[string]int
+}
+
+// NewBPE builds an encoder from token bytes to merge rank.
+func NewBPE(name string, ranks map[string]int) *BPE {
+	return &BPE{name: name, ranks: ranks}
+}
+
+// LoadTiktokenFile reads a tiktoken rank file.
+func LoadTiktokenFile(path, name string) (*BPE, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return LoadTiktoken(f, name)
+}
+
+// LoadTiktoken reads "<base64 token> <rank>" lines.
+func LoadTiktoken(r io.Reader, name string) (*BPE, error) {
+	ranks := map[string]int{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected token and rank", line)
+		}
+		token, err := base64.StdEncoding.DecodeString(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		rank, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		ranks[string(token)] = rank
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(ranks) == 0 {
+		return nil, fmt.Errorf("empty rank file")
+	}
+	return NewBPE(name, ranks), nil
+}
+
+func (b *BPE) Name() string { return b.name }
+
+// Count returns the number of tokens text encodes to.
+func (b *BPE) Count(text string) int {
+	total := 0
+	for _, piece := range preTokenize(text) {
+		total += b.countPiece(piece)
+	}
+	return total
+}
+
+func (b *BPE) countPiece(piece string) int {
+	if _, ok := b.ranks[piece]; ok {
+		return 1
+	}
+	// parts[i] is the start offset of the i-th part; merge the adjacent
+	// pair with the lowest rank until none is in the vocabulary.
+	parts := make([]int, len(piece)+1)
+	for i := range parts {
+		parts[i] = i
+	}
+	for len(parts) > 2 {
+		best, at := -1, -1
+		for i := 0; i+2 < len(parts); i++ {
+			if rank, ok := b.ranks[piece[parts[i]:parts[i+2]]]; ok && (best < 0 || rank < best) {
+				best, at = rank, i
+			}
+		}
+		if at < 0 {
+			break
+		}
+		parts = append(parts[:at+1], parts[at+2:]...)
+	}
+	return len(parts) - 1
+}
+
+// preTokenize splits text like the tiktoken cl100k_base pattern:
+// contractions, letter runs with one optional leading symbol, numbers of
+// up to three digits, symbol runs with an optional leading space, and
+// whitespace where a trailing space attaches to the next word.
+func preTokenize(text string) []string {
+	runes := []rune(text)
+	var out []string
+	for i := 0; i < len(runes); {
+		j := matchPiece(runes, i)
+		out = append(out, string(runes[i:j]))
+		i = j
+	}
+	return out
+}
+
+func matchPiece(rs []rune, i int) int {
+	n := len(rs)
+	r := rs[i]
+	if r == '\'' && i+1 < n {
+		for _, c := range []string{"s", "t", "re", "ve", "m", "ll", "d"} {
+			if j := i + 1 + len(c); j <= n && strings.EqualFold(string(rs[i+1:j]), c) {
+				return j
+			}
+		}
+	}
+	if isLetter(r) || (!isNewline(r) && !isLetter(r) && !isNumber(r) && i+1 < n && isLetter(rs[i+1])) {
+		j := i + 1
+		for j < n && isLetter(rs[j]) {
+			j++
+		}
+		return j
+	}
+	if isNumber(r) {
+		j := i + 1
+		for j < n && j < i+3 && isNumber(rs[j]) {
+			j++
+		}
+		return j
+	}
+	start := i
+	if r == ' ' && i+1 < n && isSymbol(rs[i+1]) {
+		start = i + 1
+	}
+	if isSymbol(rs[start]) {
+		j := start + 1
+		for j < n && isSymbol(rs[j]) {
+			j++
+		}
+		for j < n && isNewline(rs[j]) {
+			j++
+		}
+		return j
+	}
+	// Whitespace.
+	j := i
+	lastNewline := -1
+	for j < n && unicode.IsSpace(rs[j]) {
+		if isNewline(rs[j]) {
+			lastNewline = j
+		}
+		j++
+	}
+	if lastNewline >= 0 {
+		return lastNewline + 1
+	}
+	if j < n && j-i > 1 {
+		return j - 1
+	}
+	return j
+}
+
+func isLetter(r rune) bool  { return unicode.IsLetter(r) }
+func isNumber(r rune) bool  { return unicode.IsNumber(r) }
+func isNewline(r rune) bool { return r == '\n' || r == '\r' }
+func isSymbol(r rune) bool  { return !unicode.IsSpace(r) && !isLetter(r) && !isNumber(r) }
@@ -0,0 +1,125 @@
+package tokenizer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const spaceMarker = "▁"
+
+// SentencePiece is a unigram SentencePiece segmenter built from the
+// "<piece>\t<score>" .vocab file written next to a .model. Text is
+// normalized with the ▁ space marker and a dummy prefix, then segmented by
+// Viterbi over the piece scores; characters outside the vocabulary count
+// as one token per UTF-8 byte when the vocabulary has byte pieces.
+type SentencePiece struct {
+	name         string
+	scores       map[string]float64
+	maxLen       int
+	byteFallback bool
+	unkScore     float64
+}
+
+// LoadSentencePieceFile reads a SentencePiece .vocab file.
+func LoadSentencePieceFile(path, name string) (*SentencePiece, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return LoadSentencePiece(f, name)
+}
+
+// LoadSentencePiece reads "<piece>\t<score>" lines.
+func LoadSentencePiece(r io.Reader, name string) (*SentencePiece, error) {
+	sp := &SentencePiece{name: name, scores: map[string]float64{}}
+	minScore := 0.0
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := scanner.Text()
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		piece, rawScore, ok := strings.Cut(text, "\t")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected piece and score", line)
+		}
+		score, err := strconv.ParseFloat(strings.TrimSpace(rawScore), 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		switch {
+		case isBytePiece(piece):
+			sp.byteFallback = true
+			continue
+		case piece == "<unk>" || piece == "<s>" || piece == "</s>" || piece == "<pad>":
+			continue
+		}
+		sp.scores[piece] = score
+		if len(piece) > sp.maxLen {
+			sp.maxLen = len(piece)
+		}
+		minScore = math.Min(minScore, score)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(sp.scores) == 0 {
+		return nil, fmt.Errorf("empty vocabulary")
+	}
+	sp.unkScore = minScore - 10
+	return sp, nil
+}
+
+func isBytePiece(piece string) bool {
+	return len(piece) == 6 && strings.HasPrefix(piece, "<0x") && strings.HasSuffix(piece, ">")
+}
+
+func (sp *SentencePiece) Name() string { return sp.name }
+
+// Count returns the number of pieces text segments into.
+func (sp *SentencePiece) Count(text string) int {
+	if text == "" {
+		return 0
+	}
+	norm := spaceMarker + strings.ReplaceAll(text, " ", spaceMarker)
+	n := len(norm)
+	best := make([]float64, n+1)
+	count := make([]int, n+1)
+	for i := 1; i <= n; i++ {
+		best[i] = math.Inf(-1)
+	}
+	for i := 0; i < n; i++ {
+		if math.IsInf(best[i], -1) || !utf8.RuneStart(norm[i]) {
+			continue
+		}
+		for j := i + 1; j <= n && j-i <= sp.maxLen; j++ {
+			if j < n && !utf8.RuneStart(norm[j]) {
+				continue
+			}
+			if score, ok := sp.scores[norm[i:j]]; ok && best[i]+score > best[j] {
+				best[j], count[j] = best[i]+score, count[i]+1
+			}
+		}
+		// An unknown character costs one piece, or one per byte with
+		// byte fallback.
+		_, size := utf8.DecodeRuneInString(norm[i:])
+		pieces := 1
+		if sp.byteFallback {
+			pieces = size
+		}
+		if score := best[i] + sp.unkScore*float64(pieces); score > best[i+size] {
+			best[i+size], count[i+size] = score, count[i]+pieces
+		}
+	}
+	return count[n]
+}
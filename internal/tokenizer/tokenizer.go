@@ -0,0 +1,161 @@
+// Package tokenizer counts tokens the way each model family's upstream
+// does. Real vocabularies (tiktoken rank files, SentencePiece vocab files)
+// are loaded from TOKENIZER_DIR; families without one fall back to a
+// calibrated approximation.
+package tokenizer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Model families.
+const (
+	FamilyOpenAI = "openai"
+	FamilyClaude = "claude"
+	FamilyGemini = "gemini"
+	FamilyOther  = "other"
+)
+
+// Tokenizer counts the tokens of a piece of text.
+type Tokenizer interface {
+	Name() string
+	Count(text string) int
+}
+
+// Family classifies an upstream model name.
+func Family(model string) string {
+	m := strings.ToLower(strings.TrimSpace(model))
+	if i := strings.LastIndex(m, "/"); i >= 0 {
+		m = m[i+1:]
+	}
+	switch {
+	case strings.HasPrefix(m, "claude"):
+		return FamilyClaude
+	case strings.HasPrefix(m, "gemini"), strings.HasPrefix(m, "gemma"):
+		return FamilyGemini
+	case strings.HasPrefix(m, "gpt-"), strings.HasPrefix(m, "chatgpt"), strings.HasPrefix(m, "text-embedding"),
+		strings.HasPrefix(m, "o1"), strings.HasPrefix(m, "o3"), strings.HasPrefix(m, "o4"), strings.HasPrefix(m, "davinci"):
+		return FamilyOpenAI
+	}
+	return FamilyOther
+}
+
+// Encoding returns the vocabulary name a model is counted with: the
+// tiktoken encoding for OpenAI models, otherwise the family name.
+func Encoding(model string) string {
+	family := Family(model)
+	if family != FamilyOpenAI {
+		return family
+	}
+	m := strings.ToLower(strings.TrimSpace(model))
+	if i := strings.LastIndex(m, "/"); i >= 0 {
+		m = m[i+1:]
+	}
+	switch {
+	case strings.HasPrefix(m, "gpt-4o"), strings.HasPrefix(m, "gpt-4.1"), strings.HasPrefix(m, "gpt-4.5"),
+		strings.HasPrefix(m, "gpt-5"), strings.HasPrefix(m, "chatgpt-4o"),
+		strings.HasPrefix(m, "o1"), strings.HasPrefix(m, "o3"), strings.HasPrefix(m, "o4"):
+		return "o200k_base"
+	}
+	return "cl100k_base"
+}
+
+// Registry picks a tokenizer by model. It is safe for concurrent use.
+type Registry struct {
+	mu        sync.RWMutex
+	encodings map[string]Tokenizer
+}
+
+// NewRegistry returns a registry with no vocabularies loaded, so every
+// model is counted by approximation.
+func NewRegistry() *Registry {
+	return &Registry{encodings: map[string]Tokenizer{}}
+}
+
+// NewFromEnv loads the vocabularies found in TOKENIZER_DIR, if set.
+func NewFromEnv() (*Registry, error) {
+	r := NewRegistry()
+	dir := strings.TrimSpace(os.Getenv("TOKENIZER_DIR"))
+	if dir == "" {
+		return r, nil
+	}
+	if err := r.LoadDir(dir); err != nil {
+		return nil, fmt.Errorf("invalid TOKENIZER_DIR: %w", err)
+	}
+	return r, nil
+}
+
+// LoadDir registers every "<name>.tiktoken" rank file and "<name>.vocab"
+// SentencePiece vocabulary in dir under <name>, e.g. cl100k_base.tiktoken
+// or gemini.vocab.
+func (r *Registry) LoadDir(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		ext := filepath.Ext(entry.Name())
+		name := strings.TrimSuffix(entry.Name(), ext)
+		var load func(path, name string) (Tokenizer, error)
+		switch ext {
+		case ".tiktoken":
+			load = func(path, name string) (Tokenizer, error) { return LoadTiktokenFile(path, name) }
+		case ".vocab":
+			load = func(path, name string) (Tokenizer, error) { return LoadSentencePieceFile(path, name) }
+		default:
+			continue
+		}
+		tok, err := load(filepath.Join(dir, entry.Name()), name)
+		if err != nil {
+			return fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+		r.Register(name, tok)
+	}
+	return nil
+}
+
+// Register installs tok as the vocabulary called name.
+func (r *Registry) Register(name string, tok Tokenizer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.encodings[strings.TrimSpace(name)] = tok
+}
+
+// ForModel returns the loaded vocabulary for model, or the family's
+// approximation when none is loaded.
+func (r *Registry) ForModel(model string) Tokenizer {
+	if r != nil {
+		r.mu.RLock()
+		tok, ok := r.encodings[Encoding(model)]
+		r.mu.RUnlock()
+		if ok {
+			return tok
+		}
+	}
+	return Approximate(Family(model))
+}
+
+// Count counts text with the tokenizer for model.
+func (r *Registry) Count(model, text string) int {
+	return r.ForModel(model).Count(text)
+}
+
+// Loaded lists the registered vocabulary names.
+func (r *Registry) Loaded() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]string, 0, len(r.encodings))
+	for name := range r.encodings {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
@@ -17,10 +17,44 @@ const (
 	StatusUnsupported  Status = "unsupported"
 )
 
+// Retry conditions for ToolSpec.RetryOn.
+const (
+	RetryOnError   = "error"
+	RetryOnTimeout = "timeout"
+	// RetryOnIsError retries results the tool itself flagged is_error.
+	RetryOnIsError = "is_error"
+)
+
 type ToolSpec struct {
 	Name   string `json:"name"`
 	Status Status `json:"status"`
 	Notes  string `json:"notes,omitempty"`
+	// TimeoutMS bounds one call of the tool; zero leaves it unbounded.
+	TimeoutMS int `json:"timeout_ms,omitempty"`
+	// Retries is how many extra attempts a failed call gets. Only tools
+	// marked Idempotent are retried, since a timed out call may still have
+	// had side effects.
+	Retries    int      `json:"retries,omitempty"`
+	RetryOn    []string `json:"retry_on,omitempty"`
+	Idempotent bool     `json:"idempotent,omitempty"`
+}
+
+// ShouldRetry reports whether a failure of the given kind (one of the
+// RetryOn constants) may be retried. An empty RetryOn retries errors and
+// timeouts.
+func (t ToolSpec) ShouldRetry(kind string) bool {
+	if !t.Idempotent || t.Retries <= 0 {
+		return false
+	}
+	if len(t.RetryOn) == 0 {
+		return kind == RetryOnError || kind == RetryOnTimeout
+	}
+	for _, on := range t.RetryOn {
+		if on == kind {
+			return true
+		}
+	}
+	return false
 }
 
 type Catalog struct {
@@ -57,9 +91,13 @@ func (c *Catalog) Replace(tools []ToolSpec) {
 		}
 		st := normalizeStatus(t.Status)
 		next[name] = ToolSpec{
-			Name:   name,
-			Status: st,
-			Notes:  strings.TrimSpace(t.Notes),
+			Name:       name,
+			Status:     st,
+			Notes:      strings.TrimSpace(t.Notes),
+			TimeoutMS:  max(t.TimeoutMS, 0),
+			Retries:    max(t.Retries, 0),
+			RetryOn:    normalizeRetryOn(t.RetryOn),
+			Idempotent: t.Idempotent,
 		}
 	}
 	c.tools = next
@@ -121,6 +159,22 @@ func normalizeStatus(s Status) Status {
 	}
 }
 
+func normalizeRetryOn(in []string) []string {
+	var out []string
+	seen := map[string]bool{}
+	for _, on := range in {
+		on = strings.ToLower(strings.TrimSpace(on))
+		switch on {
+		case RetryOnError, RetryOnTimeout, RetryOnIsError:
+			if !seen[on] {
+				seen[on] = true
+				out = append(out, on)
+			}
+		}
+	}
+	return out
+}
+
 func normalizeToolName(name string) string {
 	return strings.ToLower(strings.TrimSpace(name))
 }
@@ -32,6 +32,14 @@ func (s *ScopedCatalog) CheckAllowed(name string, allowExperimental, allowUnknow
 	return s.CheckAllowedForProject(requestctx.DefaultProjectID, name, allowExperimental, allowUnknown)
 }
 
+func (s *ScopedCatalog) Get(name string) (ToolSpec, bool) {
+	return s.GetForProject(requestctx.DefaultProjectID, name)
+}
+
+func (s *ScopedCatalog) GetForProject(projectID, name string) (ToolSpec, bool) {
+	return s.catalogForProject(projectID, true).Get(name)
+}
+
 func (s *ScopedCatalog) SnapshotForProject(projectID string) []ToolSpec {
 	return s.catalogForProject(projectID, true).Snapshot()
 }
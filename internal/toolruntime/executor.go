@@ -12,8 +12,18 @@ type Call struct {
 	ID    string
 	Name  string
 	Input map[string]any
+	// SessionID and State identify the calling session; State is nil when
+	// the call carries no session.
+	SessionID string
+	State     *SessionState
+	// RunID is the gateway run the call belongs to, when known.
+	RunID string
 }
 
+// StateKeyWorkingDir holds the session working directory used by the file
+// tools to resolve relative paths.
+const StateKeyWorkingDir = "cwd"
+
 type Result struct {
 	Content any
 	IsError bool
@@ -48,6 +58,7 @@ func NewDefaultExecutor() *Registry {
 	r.Register("file_read", handleFileRead)
 	r.Register("file_write", handleFileWrite)
 	r.Register("file_list", handleFileList)
+	r.Register("set_working_directory", handleSetWorkingDirectory)
 	r.Register("rabbit_publish", handleRabbitPublish)
 	r.Register("rabbit_get", handleRabbitGet)
 	r.Register("rabbit_rpc", handleRabbitRPC)
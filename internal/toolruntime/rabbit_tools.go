@@ -13,6 +13,8 @@ import (
 	"os"
 	"strings"
 	"time"
+
+	"ccgateway/internal/egress"
 )
 
 type rabbitConfig struct {
@@ -344,7 +346,7 @@ func rabbitDoJSON(ctx context.Context, cfg rabbitConfig, method, endpoint string
 	req.SetBasicAuth(cfg.Username, cfg.Password)
 	req.Header.Set("content-type", "application/json")
 
-	client := &http.Client{Timeout: cfg.Timeout}
+	client := egress.Default().HTTPClient(cfg.Timeout)
 	resp, err := client.Do(req)
 	if err != nil {
 		return err
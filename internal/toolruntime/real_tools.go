@@ -4,11 +4,12 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
+
+	"ccgateway/internal/egress"
 )
 
 // --- Real Tool Implementations ---
@@ -31,7 +32,7 @@ func handleWebSearchReal(_ context.Context, call Call) (Result, error) {
 		apiURL = strings.ReplaceAll(apiURL, "{query}", query)
 	}
 
-	client := &http.Client{Timeout: 10 * time.Second}
+	client := egress.Default().HTTPClient(10 * time.Second)
 	resp, err := client.Get(apiURL)
 	if err != nil {
 		return Result{IsError: true, Content: fmt.Sprintf("search request failed: %v", err)}, nil
@@ -60,7 +61,7 @@ func handleFileRead(_ context.Context, call Call) (Result, error) {
 		return Result{}, fmt.Errorf("file_read requires path")
 	}
 
-	path = filepath.Clean(path)
+	path = resolveToolPath(call, path)
 	if err := validatePath(path); err != nil {
 		return Result{IsError: true, Content: err.Error()}, nil
 	}
@@ -97,7 +98,7 @@ func handleFileWrite(_ context.Context, call Call) (Result, error) {
 		return Result{}, fmt.Errorf("file_write requires content")
 	}
 
-	path = filepath.Clean(path)
+	path = resolveToolPath(call, path)
 	if err := validatePath(path); err != nil {
 		return Result{IsError: true, Content: err.Error()}, nil
 	}
@@ -128,7 +129,7 @@ func handleFileList(_ context.Context, call Call) (Result, error) {
 		return Result{}, fmt.Errorf("file_list requires path")
 	}
 
-	path = filepath.Clean(path)
+	path = resolveToolPath(call, path)
 	if err := validatePath(path); err != nil {
 		return Result{IsError: true, Content: err.Error()}, nil
 	}
@@ -162,6 +163,49 @@ func handleFileList(_ context.Context, call Call) (Result, error) {
 	}, nil
 }
 
+// handleSetWorkingDirectory pins a working directory in the caller's session
+// state; later file tools in the same session resolve relative paths
+// against it.
+func handleSetWorkingDirectory(_ context.Context, call Call) (Result, error) {
+	path := firstString(call.Input, "path", "directory", "dir")
+	if path == "" {
+		return Result{}, fmt.Errorf("set_working_directory requires path")
+	}
+	if call.State == nil {
+		return Result{IsError: true, Content: "set_working_directory requires a session"}, nil
+	}
+	path = resolveToolPath(call, path)
+	if err := validatePath(path); err != nil {
+		return Result{IsError: true, Content: err.Error()}, nil
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return Result{IsError: true, Content: "invalid path"}, nil
+	}
+	info, err := os.Stat(abs)
+	if err != nil || !info.IsDir() {
+		return Result{IsError: true, Content: fmt.Sprintf("%q is not a directory", abs)}, nil
+	}
+	call.State.Set(StateKeyWorkingDir, abs)
+	return Result{
+		Content: map[string]any{
+			"tool":              call.Name,
+			"working_directory": abs,
+		},
+	}, nil
+}
+
+// resolveToolPath joins relative paths onto the session working directory
+// when one has been set.
+func resolveToolPath(call Call, path string) string {
+	if !filepath.IsAbs(path) {
+		if cwd := call.State.GetString(StateKeyWorkingDir); cwd != "" {
+			path = filepath.Join(cwd, path)
+		}
+	}
+	return filepath.Clean(path)
+}
+
 // validatePath ensures path is safe (not a system directory).
 func validatePath(path string) error {
 	abs, err := filepath.Abs(path)
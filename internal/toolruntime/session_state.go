@@ -0,0 +1,224 @@
+package toolruntime
+
+import (
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultStateTTL = 30 * time.Minute
+
+// StateStore keeps per-session tool state (working directories, browser or
+// MCP session handles, ...) so multi-step tool workflows can reuse context
+// across calls. Sessions idle longer than the TTL are expired; values that
+// implement io.Closer are closed whenever they are replaced, deleted or
+// expired.
+type StateStore struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	sessions  map[string]*sessionState
+	lastSweep time.Time
+	onRelease []func(sessionID string)
+	now       func() time.Time
+}
+
+type sessionState struct {
+	values   map[string]any
+	lastUsed time.Time
+}
+
+// SessionStateInfo summarizes one session's tool state for admin views.
+type SessionStateInfo struct {
+	SessionID string    `json:"session_id"`
+	Keys      []string  `json:"keys"`
+	LastUsed  time.Time `json:"last_used"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func NewStateStore(ttl time.Duration) *StateStore {
+	if ttl <= 0 {
+		ttl = defaultStateTTL
+	}
+	return &StateStore{
+		ttl:      ttl,
+		sessions: map[string]*sessionState{},
+		now:      time.Now,
+	}
+}
+
+// OnRelease registers fn to run after a session's state is released, either
+// explicitly or by expiry. Used to tear down state held outside the store.
+func (s *StateStore) OnRelease(fn func(sessionID string)) {
+	if fn == nil {
+		return
+	}
+	s.mu.Lock()
+	s.onRelease = append(s.onRelease, fn)
+	s.mu.Unlock()
+}
+
+// Session returns a handle bound to sessionID, or nil when sessionID is empty.
+func (s *StateStore) Session(sessionID string) *SessionState {
+	sessionID = strings.TrimSpace(sessionID)
+	if s == nil || sessionID == "" {
+		return nil
+	}
+	s.Sweep()
+	return &SessionState{store: s, sessionID: sessionID}
+}
+
+func (s *StateStore) get(sessionID, key string) (any, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.sessions[sessionID]
+	if !ok {
+		return nil, false
+	}
+	st.lastUsed = s.now()
+	v, ok := st.values[key]
+	return v, ok
+}
+
+func (s *StateStore) set(sessionID, key string, value any) {
+	s.mu.Lock()
+	st, ok := s.sessions[sessionID]
+	if !ok {
+		st = &sessionState{values: map[string]any{}}
+		s.sessions[sessionID] = st
+	}
+	st.lastUsed = s.now()
+	prev, hadPrev := st.values[key]
+	st.values[key] = value
+	s.mu.Unlock()
+	if hadPrev && prev != value {
+		closeValue(prev)
+	}
+}
+
+func (s *StateStore) delete(sessionID, key string) {
+	s.mu.Lock()
+	st, ok := s.sessions[sessionID]
+	var prev any
+	if ok {
+		prev, ok = st.values[key]
+		delete(st.values, key)
+	}
+	s.mu.Unlock()
+	if ok {
+		closeValue(prev)
+	}
+}
+
+// Release drops all state of sessionID, closing its handles. It reports
+// whether the session had any state.
+func (s *StateStore) Release(sessionID string) bool {
+	sessionID = strings.TrimSpace(sessionID)
+	s.mu.Lock()
+	st, ok := s.sessions[sessionID]
+	delete(s.sessions, sessionID)
+	hooks := append([]func(string){}, s.onRelease...)
+	s.mu.Unlock()
+	if ok {
+		for _, v := range st.values {
+			closeValue(v)
+		}
+	}
+	for _, fn := range hooks {
+		fn(sessionID)
+	}
+	return ok
+}
+
+// Sweep expires sessions idle longer than the TTL and returns their IDs.
+// It runs at most once per quarter TTL; callers need not schedule it.
+func (s *StateStore) Sweep() []string {
+	now := s.now()
+	s.mu.Lock()
+	if now.Sub(s.lastSweep) < s.ttl/4 {
+		s.mu.Unlock()
+		return nil
+	}
+	s.lastSweep = now
+	var expired []string
+	for id, st := range s.sessions {
+		if now.Sub(st.lastUsed) > s.ttl {
+			expired = append(expired, id)
+		}
+	}
+	s.mu.Unlock()
+	for _, id := range expired {
+		s.Release(id)
+	}
+	return expired
+}
+
+// Info describes the state held for sessionID.
+func (s *StateStore) Info(sessionID string) (SessionStateInfo, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.sessions[strings.TrimSpace(sessionID)]
+	if !ok {
+		return SessionStateInfo{}, false
+	}
+	keys := make([]string, 0, len(st.values))
+	for k := range st.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return SessionStateInfo{
+		SessionID: strings.TrimSpace(sessionID),
+		Keys:      keys,
+		LastUsed:  st.lastUsed,
+		ExpiresAt: st.lastUsed.Add(s.ttl),
+	}, true
+}
+
+// SessionState is the view of a StateStore a tool handler receives for the
+// session that issued the call.
+type SessionState struct {
+	store     *StateStore
+	sessionID string
+}
+
+func (s *SessionState) SessionID() string {
+	if s == nil {
+		return ""
+	}
+	return s.sessionID
+}
+
+func (s *SessionState) Get(key string) (any, bool) {
+	if s == nil {
+		return nil, false
+	}
+	return s.store.get(s.sessionID, key)
+}
+
+func (s *SessionState) GetString(key string) string {
+	v, _ := s.Get(key)
+	str, _ := v.(string)
+	return str
+}
+
+// Set stores value under key; a previous io.Closer value is closed.
+func (s *SessionState) Set(key string, value any) {
+	if s == nil {
+		return
+	}
+	s.store.set(s.sessionID, key, value)
+}
+
+func (s *SessionState) Delete(key string) {
+	if s == nil {
+		return
+	}
+	s.store.delete(s.sessionID, key)
+}
+
+func closeValue(v any) {
+	if c, ok := v.(io.Closer); ok {
+		_ = c.Close()
+	}
+}
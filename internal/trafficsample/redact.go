@@ -0,0 +1,80 @@
+package trafficsample
+
+import (
+	"regexp"
+	"strings"
+)
+
+const redactedMask = "[REDACTED]"
+
+type redactRule struct {
+	name    string
+	pattern *regexp.Regexp
+	mask    string
+}
+
+var builtinRedactRules = []redactRule{
+	{name: "email", pattern: regexp.MustCompile(`(?i)[a-z0-9._%+\-]+@[a-z0-9.\-]+\.[a-z]{2,}`), mask: "[EMAIL]"},
+	{name: "api_key", pattern: regexp.MustCompile(`\b(?:sk|pk|rk)-[A-Za-z0-9_\-]{12,}\b`), mask: "[API_KEY]"},
+	{name: "bearer", pattern: regexp.MustCompile(`(?i)bearer\s+[A-Za-z0-9._\-]{12,}`), mask: "Bearer [TOKEN]"},
+	{name: "card", pattern: regexp.MustCompile(`\b(?:\d[ \-]?){13,19}\b`), mask: "[CARD]"},
+	{name: "ipv4", pattern: regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`), mask: "[IP]"},
+	{name: "phone", pattern: regexp.MustCompile(`\+?\d[\d\- ]{7,}\d`), mask: "[PHONE]"},
+}
+
+// RedactText masks common PII and credential patterns in free text.
+func RedactText(text string) string {
+	if strings.TrimSpace(text) == "" {
+		return text
+	}
+	out := text
+	for _, rule := range builtinRedactRules {
+		out = rule.pattern.ReplaceAllString(out, rule.mask)
+	}
+	return out
+}
+
+// RedactMap returns a copy of in where configured field names are fully masked
+// and every remaining string value has RedactText applied.
+func RedactMap(in map[string]any, fields []string) map[string]any {
+	if len(in) == 0 {
+		return nil
+	}
+	masked := make(map[string]struct{}, len(fields))
+	for _, f := range fields {
+		f = strings.ToLower(strings.TrimSpace(f))
+		if f != "" {
+			masked[f] = struct{}{}
+		}
+	}
+	return redactMapWith(in, masked)
+}
+
+func redactMapWith(in map[string]any, masked map[string]struct{}) map[string]any {
+	out := make(map[string]any, len(in))
+	for k, v := range in {
+		if _, ok := masked[strings.ToLower(strings.TrimSpace(k))]; ok {
+			out[k] = redactedMask
+			continue
+		}
+		out[k] = redactValue(v, masked)
+	}
+	return out
+}
+
+func redactValue(v any, masked map[string]struct{}) any {
+	switch val := v.(type) {
+	case string:
+		return RedactText(val)
+	case map[string]any:
+		return redactMapWith(val, masked)
+	case []any:
+		out := make([]any, 0, len(val))
+		for _, item := range val {
+			out = append(out, redactValue(item, masked))
+		}
+		return out
+	default:
+		return v
+	}
+}
@@ -0,0 +1,237 @@
+package trafficsample
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type Config struct {
+	Enabled      bool     `json:"enabled"`
+	SampleRate   float64  `json:"sample_rate"`
+	RedactFields []string `json:"redact_fields"`
+	MaxTextChars int      `json:"max_text_chars"`
+}
+
+type ConfigPatch struct {
+	Enabled      *bool    `json:"enabled,omitempty"`
+	SampleRate   *float64 `json:"sample_rate,omitempty"`
+	RedactFields []string `json:"redact_fields,omitempty"`
+	MaxTextChars *int     `json:"max_text_chars,omitempty"`
+}
+
+// Sample is a redacted view of a single request/response pair.
+type Sample struct {
+	ID        string         `json:"id"`
+	RunID     string         `json:"run_id,omitempty"`
+	SessionID string         `json:"session_id,omitempty"`
+	Path      string         `json:"path"`
+	Mode      string         `json:"mode,omitempty"`
+	Model     string         `json:"model,omitempty"`
+	Status    int            `json:"status"`
+	Stream    bool           `json:"stream"`
+	Prompt    string         `json:"prompt,omitempty"`
+	Response  string         `json:"response,omitempty"`
+	Metadata  map[string]any `json:"metadata,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// Sampler selects a fraction of live traffic, redacts it, and fans it out to
+// subscribers. Nothing is retained once every subscriber has read a sample.
+type Sampler struct {
+	mu      sync.RWMutex
+	cfg     Config
+	subs    map[chan Sample]struct{}
+	randf   func() float64
+	counter uint64
+	offered uint64
+	sampled uint64
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Enabled:      true,
+		SampleRate:   0.1,
+		RedactFields: []string{"user_id", "email", "api_key", "authorization"},
+		MaxTextChars: 4000,
+	}
+}
+
+func NewSampler(cfg Config) *Sampler {
+	return &Sampler{
+		cfg:   sanitizeConfig(cfg),
+		subs:  map[chan Sample]struct{}{},
+		randf: rand.Float64,
+	}
+}
+
+func NewFromEnv() (*Sampler, error) {
+	cfg := DefaultConfig()
+	if raw := strings.TrimSpace(os.Getenv("TRAFFIC_SAMPLE_ENABLED")); raw != "" {
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TRAFFIC_SAMPLE_ENABLED: %w", err)
+		}
+		cfg.Enabled = v
+	}
+	if raw := strings.TrimSpace(os.Getenv("TRAFFIC_SAMPLE_RATE")); raw != "" {
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil || v < 0 || v > 1 {
+			return nil, fmt.Errorf("TRAFFIC_SAMPLE_RATE must be within [0,1]")
+		}
+		cfg.SampleRate = v
+	}
+	if raw := strings.TrimSpace(os.Getenv("TRAFFIC_SAMPLE_REDACT_FIELDS")); raw != "" {
+		cfg.RedactFields = strings.Split(raw, ",")
+	}
+	return NewSampler(cfg), nil
+}
+
+func (s *Sampler) Config() Config {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return cloneConfig(s.cfg)
+}
+
+func (s *Sampler) UpdateConfigPatch(patch ConfigPatch) (Config, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	next := cloneConfig(s.cfg)
+	if patch.Enabled != nil {
+		next.Enabled = *patch.Enabled
+	}
+	if patch.SampleRate != nil {
+		if *patch.SampleRate < 0 || *patch.SampleRate > 1 {
+			return cloneConfig(s.cfg), fmt.Errorf("sample_rate must be within [0,1]")
+		}
+		next.SampleRate = *patch.SampleRate
+	}
+	if patch.RedactFields != nil {
+		next.RedactFields = patch.RedactFields
+	}
+	if patch.MaxTextChars != nil {
+		next.MaxTextChars = *patch.MaxTextChars
+	}
+	s.cfg = sanitizeConfig(next)
+	return cloneConfig(s.cfg), nil
+}
+
+// SetRandSource replaces the sampling random source (tests use a fixed source).
+func (s *Sampler) SetRandSource(fn func() float64) {
+	if fn == nil {
+		return
+	}
+	s.mu.Lock()
+	s.randf = fn
+	s.mu.Unlock()
+}
+
+// Offer considers in for sampling. It is cheap when nobody is subscribed.
+func (s *Sampler) Offer(in Sample) bool {
+	if s == nil {
+		return false
+	}
+	s.mu.RLock()
+	cfg := s.cfg
+	subscribed := len(s.subs) > 0
+	randf := s.randf
+	s.mu.RUnlock()
+	if !cfg.Enabled || !subscribed {
+		return false
+	}
+	atomic.AddUint64(&s.offered, 1)
+	if cfg.SampleRate <= 0 || randf() >= cfg.SampleRate {
+		return false
+	}
+	atomic.AddUint64(&s.sampled, 1)
+
+	out := in
+	out.ID = fmt.Sprintf("smp_%d_%x", time.Now().Unix(), atomic.AddUint64(&s.counter, 1))
+	out.Prompt = truncate(RedactText(in.Prompt), cfg.MaxTextChars)
+	out.Response = truncate(RedactText(in.Response), cfg.MaxTextChars)
+	out.Metadata = RedactMap(in.Metadata, cfg.RedactFields)
+	if out.CreatedAt.IsZero() {
+		out.CreatedAt = time.Now().UTC()
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for ch := range s.subs {
+		select {
+		case ch <- out:
+		default:
+			// Slow consumers miss samples rather than blocking live traffic.
+		}
+	}
+	return true
+}
+
+// Subscribe returns a channel of redacted samples and a cancel func.
+func (s *Sampler) Subscribe() (<-chan Sample, func()) {
+	ch := make(chan Sample, 32)
+	s.mu.Lock()
+	s.subs[ch] = struct{}{}
+	s.mu.Unlock()
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			s.mu.Lock()
+			delete(s.subs, ch)
+			s.mu.Unlock()
+		})
+	}
+}
+
+func (s *Sampler) Snapshot() map[string]any {
+	cfg := s.Config()
+	s.mu.RLock()
+	subscribers := len(s.subs)
+	s.mu.RUnlock()
+	return map[string]any{
+		"config":      cfg,
+		"subscribers": subscribers,
+		"offered":     atomic.LoadUint64(&s.offered),
+		"sampled":     atomic.LoadUint64(&s.sampled),
+	}
+}
+
+func sanitizeConfig(in Config) Config {
+	out := cloneConfig(in)
+	if out.SampleRate < 0 {
+		out.SampleRate = 0
+	}
+	if out.SampleRate > 1 {
+		out.SampleRate = 1
+	}
+	if out.MaxTextChars <= 0 {
+		out.MaxTextChars = 4000
+	}
+	fields := make([]string, 0, len(out.RedactFields))
+	for _, f := range out.RedactFields {
+		f = strings.ToLower(strings.TrimSpace(f))
+		if f != "" {
+			fields = append(fields, f)
+		}
+	}
+	out.RedactFields = fields
+	return out
+}
+
+func cloneConfig(in Config) Config {
+	out := in
+	out.RedactFields = append([]string(nil), in.RedactFields...)
+	return out
+}
+
+func truncate(text string, limit int) string {
+	runes := []rune(text)
+	if limit <= 0 || len(runes) <= limit {
+		return text
+	}
+	return string(runes[:limit]) + "..."
+}
@@ -19,3 +19,6 @@ type StreamingAdapter interface {
 }
 
 var ErrStrictPassthroughUnsupported = errors.New("strict anthropic passthrough unsupported")
+
+// ErrUnknownAdapter is returned when a name matches no configured adapter.
+var ErrUnknownAdapter = errors.New("unknown adapter")
@@ -0,0 +1,113 @@
+package upstream
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AdapterCheck is the outcome of one cheap authenticated call made to
+// verify that an adapter's provider is reachable and accepts its key.
+type AdapterCheck struct {
+	Adapter string      `json:"adapter"`
+	Kind    AdapterKind `json:"kind,omitempty"`
+	// Checkable is false for adapters that cannot be verified without a
+	// real completion, such as script and grpc adapters.
+	Checkable  bool   `json:"checkable"`
+	Method     string `json:"method,omitempty"`
+	URL        string `json:"url,omitempty"`
+	StatusCode int    `json:"status_code,omitempty"`
+	LatencyMS  int64  `json:"latency_ms"`
+	// ServerDate is the provider's Date header, for clock skew checks.
+	ServerDate time.Time `json:"server_date,omitempty"`
+	// ReceivedAt is the local time the response arrived.
+	ReceivedAt time.Time `json:"received_at,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// adapterChecker is implemented by adapters that can make such a call.
+type adapterChecker interface {
+	CheckAdapter(ctx context.Context) AdapterCheck
+}
+
+// CheckAdapter calls the adapter's custom health check, or its provider's
+// models endpoint when it has none. The response status is reported as is;
+// judging it is left to the caller.
+func (a *HTTPAdapter) CheckAdapter(ctx context.Context) AdapterCheck {
+	out := AdapterCheck{Adapter: a.name, Kind: a.kind, Method: http.MethodGet}
+	if hc := a.HealthCheck(); hc != nil {
+		out.Method = hc.Method
+		out.URL = hc.Path
+		if !strings.HasPrefix(out.URL, "http://") && !strings.HasPrefix(out.URL, "https://") {
+			if !strings.HasPrefix(out.URL, "/") {
+				out.URL = "/" + out.URL
+			}
+			out.URL = a.baseURL + out.URL
+		}
+	} else {
+		switch a.kind {
+		case AdapterKindOpenAI, AdapterKindAnthropic:
+			out.URL = a.baseURL + "/v1/models"
+		case AdapterKindGemini:
+			out.URL = a.baseURL + "/v1beta/models"
+		default:
+			return out
+		}
+	}
+	out.Checkable = true
+
+	httpReq, err := http.NewRequestWithContext(ctx, out.Method, out.URL, nil)
+	if err != nil {
+		out.Error = err.Error()
+		return out
+	}
+	a.applyRequestHeaders(httpReq, nil)
+	started := time.Now()
+	resp, err := a.client.Do(httpReq)
+	out.LatencyMS = time.Since(started).Milliseconds()
+	if err != nil {
+		out.Error = err.Error()
+		return out
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20))
+	out.ReceivedAt = time.Now()
+	out.StatusCode = resp.StatusCode
+	if date, err := http.ParseTime(resp.Header.Get("date")); err == nil {
+		out.ServerDate = date
+	}
+	return out
+}
+
+// CheckAdapters runs CheckAdapter on every configured adapter in parallel
+// and returns the results in configuration order.
+func (s *RouterService) CheckAdapters(ctx context.Context) []AdapterCheck {
+	s.mu.RLock()
+	adapters := make([]Adapter, 0, len(s.adapterOrder))
+	for _, name := range s.adapterOrder {
+		if m, ok := s.adapters[name]; ok {
+			adapters = append(adapters, m.adapter)
+		}
+	}
+	s.mu.RUnlock()
+
+	out := make([]AdapterCheck, len(adapters))
+	var wg sync.WaitGroup
+	for i, adapter := range adapters {
+		checker, ok := adapter.(adapterChecker)
+		if !ok {
+			out[i] = AdapterCheck{Adapter: adapter.Name()}
+			continue
+		}
+		wg.Add(1)
+		go func(i int, checker adapterChecker) {
+			defer wg.Done()
+			out[i] = checker.CheckAdapter(ctx)
+		}(i, checker)
+	}
+	wg.Wait()
+	return out
+}
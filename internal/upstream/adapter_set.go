@@ -0,0 +1,167 @@
+package upstream
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	ApplyActionAdded     = "added"
+	ApplyActionReplaced  = "replaced"
+	ApplyActionUnchanged = "unchanged"
+	ApplyActionRemoved   = "removed"
+
+	AdapterStateActive   = "active"
+	AdapterStateDraining = "draining"
+	AdapterStateClosed   = "closed"
+)
+
+const defaultDrainTimeout = 2 * time.Minute
+
+// AdapterApplyStatus reports what the last upstream apply did to one adapter
+// instance and whether a retired instance is still finishing calls.
+type AdapterApplyStatus struct {
+	Name     string    `json:"name"`
+	Version  uint64    `json:"version"`
+	Action   string    `json:"action"`
+	State    string    `json:"state"`
+	InFlight int64     `json:"in_flight"`
+	ClosedAt time.Time `json:"closed_at,omitempty"`
+}
+
+type UpstreamApplyStatus struct {
+	Version   uint64               `json:"version"`
+	AppliedAt time.Time            `json:"applied_at,omitempty"`
+	Adapters  []AdapterApplyStatus `json:"adapters"`
+}
+
+// Closer is implemented by adapters that hold transports or processes which
+// should be released once the instance has drained.
+type Closer interface {
+	Close() error
+}
+
+// managedAdapter wraps one adapter instance with an in-flight counter so a
+// replaced instance can finish active calls before it is closed, and with
+// the admission gate enforcing its max_concurrency.
+type managedAdapter struct {
+	adapter  Adapter
+	specKey  string
+	version  uint64
+	action   string
+	inflight atomic.Int64
+	gate     *admissionGate
+
+	mu       sync.Mutex
+	state    string
+	closedAt time.Time
+}
+
+func newManagedAdapter(adapter Adapter, version uint64, action string) *managedAdapter {
+	return &managedAdapter{
+		adapter: adapter,
+		specKey: adapterSpecKey(adapter),
+		version: version,
+		action:  action,
+		gate:    newAdmissionGate(snapshotAdapterSpec(adapter).MaxConcurrency),
+		state:   AdapterStateActive,
+	}
+}
+
+// acquire marks a call as in flight; the returned func must be called once
+// the call (including any stream) has finished.
+func (m *managedAdapter) acquire() func() {
+	m.inflight.Add(1)
+	var once sync.Once
+	return func() {
+		once.Do(func() { m.inflight.Add(-1) })
+	}
+}
+
+// admit waits for a slot under the adapter's max_concurrency and then marks
+// the call as in flight; the returned func releases both.
+func (m *managedAdapter) admit(ctx context.Context, a admission) (func(), error) {
+	if m.gate == nil {
+		return m.acquire(), nil
+	}
+	leave, err := m.gate.acquire(ctx, a)
+	if err != nil {
+		return nil, err
+	}
+	done := m.acquire()
+	return func() {
+		done()
+		leave()
+	}, nil
+}
+
+func (m *managedAdapter) status() AdapterApplyStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return AdapterApplyStatus{
+		Name:     m.adapter.Name(),
+		Version:  m.version,
+		Action:   m.action,
+		State:    m.state,
+		InFlight: m.inflight.Load(),
+		ClosedAt: m.closedAt,
+	}
+}
+
+func (m *managedAdapter) setState(state string) {
+	m.mu.Lock()
+	m.state = state
+	if state == AdapterStateClosed {
+		m.closedAt = time.Now().UTC()
+	}
+	m.mu.Unlock()
+}
+
+func (m *managedAdapter) isClosed() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.state == AdapterStateClosed
+}
+
+// drain waits for in-flight calls to finish (or timeout to elapse) and then
+// closes the adapter's transport.
+func (m *managedAdapter) drain(timeout time.Duration) {
+	m.setState(AdapterStateDraining)
+	deadline := time.Now().Add(timeout)
+	ticker := time.NewTicker(25 * time.Millisecond)
+	defer ticker.Stop()
+	for m.inflight.Load() > 0 && time.Now().Before(deadline) {
+		<-ticker.C
+	}
+	closeAdapter(m.adapter)
+	m.setState(AdapterStateClosed)
+}
+
+func closeAdapter(adapter Adapter) {
+	if c, ok := adapter.(Closer); ok {
+		_ = c.Close()
+	}
+}
+
+// adapterSpecKey identifies an adapter configuration so an apply can keep
+// instances whose spec did not change.
+func adapterSpecKey(adapter Adapter) string {
+	raw, err := json.Marshal(snapshotAdapterSpec(adapter))
+	if err != nil {
+		return ""
+	}
+	return string(raw)
+}
+
+func sortApplyStatuses(in []AdapterApplyStatus) {
+	sort.SliceStable(in, func(i, j int) bool {
+		if in[i].Name != in[j].Name {
+			return in[i].Name < in[j].Name
+		}
+		return in[i].Version > in[j].Version
+	})
+}
@@ -29,12 +29,31 @@ type AdapterSpec struct {
 	WorkDir            string            `json:"work_dir,omitempty"`
 	TimeoutMS          int               `json:"timeout_ms,omitempty"`
 	MaxOutputBytes     int               `json:"max_output_bytes,omitempty"`
+	HealthCheck        *HealthCheckSpec  `json:"health_check,omitempty"`
+	// Persistent keeps one script process running and talks JSON-RPC to
+	// it instead of spawning a process per call.
+	Persistent bool `json:"persistent,omitempty"`
+	// GRPCService, GRPCMethod and GRPCStreamMethod name the RPCs a grpc
+	// adapter calls; they default to proto/inference/v1/inference.proto.
+	GRPCService      string `json:"grpc_service,omitempty"`
+	GRPCMethod       string `json:"grpc_method,omitempty"`
+	GRPCStreamMethod string `json:"grpc_stream_method,omitempty"`
+	// PoolSize is the number of connections a grpc adapter spreads calls
+	// over. Zero means one.
+	PoolSize int `json:"pool_size,omitempty"`
+	// MaxConcurrency caps calls in flight on the adapter; further calls
+	// wait in a priority queue. Zero means unlimited.
+	MaxConcurrency int `json:"max_concurrency,omitempty"`
+	// Transport tunes the connection pool of http adapters.
+	Transport *TransportSpec `json:"transport,omitempty"`
 }
 
 type UpstreamAdminConfig struct {
 	Adapters     []AdapterSpec       `json:"adapters"`
 	DefaultRoute []string            `json:"default_route,omitempty"`
 	ModelRoutes  map[string][]string `json:"model_routes,omitempty"`
+	// Offline is reported on reads; it cannot be toggled through the API.
+	Offline bool `json:"offline,omitempty"`
 }
 
 func ParseAdapterSpecsFromEnv() ([]AdapterSpec, error) {
@@ -72,6 +91,9 @@ func BuildAdapterFromSpec(spec AdapterSpec) (Adapter, error) {
 	spec = sanitizeAdapterSpec(spec)
 	switch spec.Kind {
 	case AdapterKindScript:
+		if spec.HealthCheck != nil {
+			return nil, fmt.Errorf("adapter %q: health_check is only supported for http adapters", spec.Name)
+		}
 		return NewScriptAdapter(ScriptAdapterConfig{
 			Name:           spec.Name,
 			Command:        spec.Command,
@@ -83,7 +105,35 @@ func BuildAdapterFromSpec(spec AdapterSpec) (Adapter, error) {
 			SupportsTools:  cloneBoolPtr(spec.SupportsTools),
 			TimeoutMS:      spec.TimeoutMS,
 			MaxOutputBytes: spec.MaxOutputBytes,
+			MaxConcurrency: spec.MaxConcurrency,
+			Persistent:     spec.Persistent,
 		})
+	case AdapterKindGRPC:
+		if spec.HealthCheck != nil {
+			return nil, fmt.Errorf("adapter %q: health_check is only supported for http adapters", spec.Name)
+		}
+		apiKey := strings.TrimSpace(spec.APIKey)
+		if apiKey == "" && strings.TrimSpace(spec.APIKeyEnv) != "" {
+			apiKey = strings.TrimSpace(os.Getenv(spec.APIKeyEnv))
+		}
+		return NewGRPCAdapter(GRPCAdapterConfig{
+			Name:               spec.Name,
+			BaseURL:            spec.BaseURL,
+			Service:            spec.GRPCService,
+			Method:             spec.GRPCMethod,
+			StreamMethod:       spec.GRPCStreamMethod,
+			APIKey:             apiKey,
+			APIKeyHeader:       spec.APIKeyHeader,
+			Headers:            copyHeaders(spec.Headers),
+			Model:              spec.Model,
+			UserAgent:          spec.UserAgent,
+			SupportsVision:     cloneBoolPtr(spec.SupportsVision),
+			SupportsTools:      cloneBoolPtr(spec.SupportsTools),
+			InsecureSkipVerify: spec.InsecureSkipVerify,
+			TimeoutMS:          spec.TimeoutMS,
+			PoolSize:           spec.PoolSize,
+			MaxConcurrency:     spec.MaxConcurrency,
+		}, nil)
 	case AdapterKindOpenAI, AdapterKindAnthropic, AdapterKindGemini, AdapterKindCanonical:
 		apiKey := strings.TrimSpace(spec.APIKey)
 		if apiKey == "" && strings.TrimSpace(spec.APIKeyEnv) != "" {
@@ -104,6 +154,9 @@ func BuildAdapterFromSpec(spec AdapterSpec) (Adapter, error) {
 			ForceStream:        spec.ForceStream,
 			StreamOptions:      copyAnyMap(spec.StreamOptions),
 			InsecureSkipVerify: spec.InsecureSkipVerify,
+			HealthCheck:        spec.HealthCheck,
+			MaxConcurrency:     spec.MaxConcurrency,
+			Transport:          spec.Transport,
 		}, nil)
 	default:
 		return nil, fmt.Errorf("unsupported adapter kind %q", spec.Kind)
@@ -129,6 +182,17 @@ func sanitizeAdapterSpec(in AdapterSpec) AdapterSpec {
 	out.Args = append([]string(nil), in.Args...)
 	out.Env = copyHeaders(in.Env)
 	out.WorkDir = strings.TrimSpace(in.WorkDir)
+	out.HealthCheck = sanitizeHealthCheck(in.HealthCheck)
+	out.Transport = sanitizeTransportSpec(in.Transport)
+	out.GRPCService = strings.TrimSpace(in.GRPCService)
+	out.GRPCMethod = strings.TrimSpace(in.GRPCMethod)
+	out.GRPCStreamMethod = strings.TrimSpace(in.GRPCStreamMethod)
+	if out.PoolSize < 0 {
+		out.PoolSize = 0
+	}
+	if out.MaxConcurrency < 0 {
+		out.MaxConcurrency = 0
+	}
 	return out
 }
 
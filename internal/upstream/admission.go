@@ -0,0 +1,263 @@
+package upstream
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"ccgateway/internal/orchestrator"
+)
+
+const (
+	defaultQueueTimeout      = 30 * time.Second
+	defaultQueuePingInterval = 5 * time.Second
+	// shadowPriority keeps mirrored calls behind every real request.
+	shadowPriority = -1 << 30
+)
+
+// ErrAdmissionTimeout is returned when a call waited the whole queue
+// timeout for a slot on a saturated adapter; the router moves on to the
+// next candidate.
+var ErrAdmissionTimeout = errors.New("upstream admission queue timeout")
+
+// AdmissionStatus reports one concurrency-limited adapter's queue for
+// /admin/status.
+type AdmissionStatus struct {
+	Adapter        string  `json:"adapter"`
+	MaxConcurrency int     `json:"max_concurrency"`
+	Active         int     `json:"active"`
+	Queued         int     `json:"queued"`
+	Admitted       uint64  `json:"admitted"`
+	Waited         uint64  `json:"waited"`
+	TimedOut       uint64  `json:"timed_out"`
+	AvgWaitMS      float64 `json:"avg_wait_ms"`
+	MaxWaitMS      int64   `json:"max_wait_ms"`
+	OldestWaitMS   int64   `json:"oldest_wait_ms"`
+}
+
+// admissionGate bounds the calls in flight on one adapter. Calls over the
+// limit wait in a queue ordered by priority, then arrival; a released
+// slot is handed straight to the head of the queue.
+type admissionGate struct {
+	limit int
+
+	mu        sync.Mutex
+	active    int
+	waiters   waiterQueue
+	seq       uint64
+	admitted  uint64
+	waited    uint64
+	timedOut  uint64
+	waitTotal time.Duration
+	maxWait   time.Duration
+}
+
+// admission describes one call asking for a slot. While it is queued,
+// onWait (if set) is called every pingEvery with the queue depth and the
+// time waited so far.
+type admission struct {
+	priority  int
+	timeout   time.Duration
+	pingEvery time.Duration
+	onWait    func(depth int, waited time.Duration)
+}
+
+type admissionWaiter struct {
+	priority int
+	seq      uint64
+	enqueued time.Time
+	ready    chan struct{}
+	index    int
+}
+
+func newAdmissionGate(limit int) *admissionGate {
+	if limit <= 0 {
+		return nil
+	}
+	return &admissionGate{limit: limit}
+}
+
+// acquire waits for a slot; the returned func gives it back.
+func (g *admissionGate) acquire(ctx context.Context, a admission) (func(), error) {
+	g.mu.Lock()
+	if g.active < g.limit && g.waiters.Len() == 0 {
+		g.active++
+		g.admitted++
+		g.mu.Unlock()
+		return g.releaseOnce(), nil
+	}
+	g.seq++
+	w := &admissionWaiter{priority: a.priority, seq: g.seq, enqueued: time.Now(), ready: make(chan struct{})}
+	heap.Push(&g.waiters, w)
+	g.mu.Unlock()
+
+	timeout := a.timeout
+	if timeout <= 0 {
+		timeout = defaultQueueTimeout
+	}
+	pingEvery := a.pingEvery
+	if pingEvery <= 0 {
+		pingEvery = defaultQueuePingInterval
+	}
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(pingEvery)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.ready:
+			g.recordWait(time.Since(w.enqueued))
+			return g.releaseOnce(), nil
+		case <-ticker.C:
+			if a.onWait != nil {
+				a.onWait(g.queued(), time.Since(w.enqueued))
+			}
+		case <-deadline.C:
+			if g.abandon(w, true) {
+				return nil, ErrAdmissionTimeout
+			}
+			g.recordWait(time.Since(w.enqueued))
+			return g.releaseOnce(), nil
+		case <-ctx.Done():
+			if g.abandon(w, false) {
+				return nil, ctx.Err()
+			}
+			// The slot arrived as the caller gave up: pass it on.
+			g.release()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// abandon removes w from the queue. It reports false when w was already
+// handed a slot.
+func (g *admissionGate) abandon(w *admissionWaiter, timedOut bool) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if w.index < 0 {
+		return false
+	}
+	heap.Remove(&g.waiters, w.index)
+	if timedOut {
+		g.timedOut++
+	}
+	return true
+}
+
+func (g *admissionGate) recordWait(d time.Duration) {
+	g.mu.Lock()
+	g.admitted++
+	g.waited++
+	g.waitTotal += d
+	if d > g.maxWait {
+		g.maxWait = d
+	}
+	g.mu.Unlock()
+}
+
+func (g *admissionGate) releaseOnce() func() {
+	var once sync.Once
+	return func() { once.Do(g.release) }
+}
+
+func (g *admissionGate) release() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.waiters.Len() > 0 {
+		w := heap.Pop(&g.waiters).(*admissionWaiter)
+		close(w.ready)
+		return
+	}
+	g.active--
+}
+
+func (g *admissionGate) queued() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.waiters.Len()
+}
+
+func (g *admissionGate) status(name string) AdmissionStatus {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	out := AdmissionStatus{
+		Adapter:        name,
+		MaxConcurrency: g.limit,
+		Active:         g.active,
+		Queued:         g.waiters.Len(),
+		Admitted:       g.admitted,
+		Waited:         g.waited,
+		TimedOut:       g.timedOut,
+		MaxWaitMS:      g.maxWait.Milliseconds(),
+	}
+	if g.waited > 0 {
+		out.AvgWaitMS = float64(g.waitTotal.Milliseconds()) / float64(g.waited)
+	}
+	for _, w := range g.waiters {
+		if age := time.Since(w.enqueued).Milliseconds(); age > out.OldestWaitMS {
+			out.OldestWaitMS = age
+		}
+	}
+	return out
+}
+
+// waiterQueue is a heap with the highest priority, then the earliest
+// arrival, on top.
+type waiterQueue []*admissionWaiter
+
+func (q waiterQueue) Len() int { return len(q) }
+
+func (q waiterQueue) Less(i, j int) bool {
+	if q[i].priority != q[j].priority {
+		return q[i].priority > q[j].priority
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q waiterQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *waiterQueue) Push(x any) {
+	w := x.(*admissionWaiter)
+	w.index = len(*q)
+	*q = append(*q, w)
+}
+
+func (q *waiterQueue) Pop() any {
+	old := *q
+	w := old[len(old)-1]
+	old[len(old)-1] = nil
+	w.index = -1
+	*q = old[:len(old)-1]
+	return w
+}
+
+// admissionFor builds the admission for req from the priority the gateway
+// assigned to it.
+func (s *RouterService) admissionFor(req orchestrator.Request) admission {
+	a := admission{timeout: s.queueTimeout, pingEvery: s.queuePing}
+	if req.Metadata != nil {
+		a.priority, _ = intFromAny(req.Metadata["admission_priority"])
+	}
+	return a
+}
+
+// AdmissionStatus reports the queue of every concurrency-limited adapter.
+func (s *RouterService) AdmissionStatus() []AdmissionStatus {
+	s.mu.RLock()
+	out := make([]AdmissionStatus, 0, len(s.adapters))
+	for name, managed := range s.adapters {
+		if managed.gate != nil {
+			out = append(out, managed.gate.status(name))
+		}
+	}
+	s.mu.RUnlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].Adapter < out[j].Adapter })
+	return out
+}
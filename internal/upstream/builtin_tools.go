@@ -0,0 +1,83 @@
+package upstream
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"ccgateway/internal/orchestrator"
+)
+
+// anthropicBuiltinTool renders a built-in client tool (bash, text_editor,
+// computer) as Anthropic declares it: type and name plus its options,
+// without a schema.
+func anthropicBuiltinTool(t orchestrator.Tool) map[string]any {
+	out := map[string]any{
+		"type": t.Type,
+		"name": t.Name,
+	}
+	for k, v := range t.Options {
+		out[k] = v
+	}
+	return out
+}
+
+// restoreBuiltinToolInputs maps calls of built-in tools that came back
+// through function calling onto the input shapes Anthropic clients expect.
+// Function-calling models often send coordinates and ranges as strings
+// ("[10, 20]" or "10,20") and flags as "true".
+func restoreBuiltinToolInputs(tools []orchestrator.Tool, blocks []orchestrator.AssistantBlock) []orchestrator.AssistantBlock {
+	builtin := map[string]bool{}
+	for _, t := range tools {
+		if strings.TrimSpace(t.Type) != "" {
+			builtin[t.Name] = true
+		}
+	}
+	if len(builtin) == 0 {
+		return blocks
+	}
+	for i, block := range blocks {
+		if block.Type != "tool_use" || !builtin[block.Name] || block.Input == nil {
+			continue
+		}
+		for _, key := range []string{"coordinate", "start_coordinate", "view_range"} {
+			if raw, ok := block.Input[key].(string); ok {
+				if list, ok := intListFromString(raw); ok {
+					block.Input[key] = list
+				}
+			}
+		}
+		for _, key := range []string{"insert_line", "scroll_amount"} {
+			if raw, ok := block.Input[key].(string); ok {
+				if n, err := strconv.Atoi(strings.TrimSpace(raw)); err == nil {
+					block.Input[key] = n
+				}
+			}
+		}
+		if raw, ok := block.Input["restart"].(string); ok {
+			if b, err := strconv.ParseBool(strings.TrimSpace(raw)); err == nil {
+				block.Input["restart"] = b
+			}
+		}
+		blocks[i] = block
+	}
+	return blocks
+}
+
+func intListFromString(raw string) ([]any, bool) {
+	raw = strings.TrimSpace(raw)
+	var list []any
+	if err := json.Unmarshal([]byte(raw), &list); err == nil {
+		return list, true
+	}
+	parts := strings.Split(strings.Trim(raw, "()[] "), ",")
+	out := make([]any, 0, len(parts))
+	for _, part := range parts {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return nil, false
+		}
+		out = append(out, n)
+	}
+	return out, len(out) > 0
+}
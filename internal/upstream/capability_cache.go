@@ -0,0 +1,410 @@
+package upstream
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultCapabilityTTL = time.Hour
+
+// ModelConstraints are the per-model limits a provider reports in its
+// model list. Zero means the provider did not say.
+type ModelConstraints struct {
+	ContextWindow   int   `json:"context_window,omitempty"`
+	MaxOutputTokens int   `json:"max_output_tokens,omitempty"`
+	SupportsVision  *bool `json:"supports_vision,omitempty"`
+}
+
+// Capabilities is what a provider reports about itself at one base_url.
+// Adapters sharing the base_url (key pools, regions) share one entry.
+type Capabilities struct {
+	BaseURL     string                      `json:"base_url"`
+	Kind        AdapterKind                 `json:"kind"`
+	Models      []string                    `json:"models"`
+	Constraints map[string]ModelConstraints `json:"constraints,omitempty"`
+	DetectedBy  string                      `json:"detected_by"`
+	DetectedAt  time.Time                   `json:"detected_at"`
+	ExpiresAt   time.Time                   `json:"expires_at"`
+	Error       string                      `json:"error,omitempty"`
+	// Probes counts the provider calls made for this base_url; Hits counts
+	// lookups answered from the cache.
+	Probes int64 `json:"probes"`
+	Hits   int64 `json:"hits"`
+}
+
+// capabilityDetector is implemented by adapters that can ask their provider
+// for its model list.
+type capabilityDetector interface {
+	Adapter
+	CapabilityKey() (baseURL, fingerprint string)
+	DetectCapabilities(ctx context.Context) (Capabilities, error)
+}
+
+type capabilityEntry struct {
+	caps        Capabilities
+	fingerprint string
+	err         error
+	// ready is closed once the probe finishes; concurrent lookups for the
+	// same base_url wait on it instead of probing again.
+	ready chan struct{}
+}
+
+// CapabilityCache keeps detected capabilities per base_url so N adapters
+// pointing at the same provider cost one probe. Failed probes are cached
+// for the TTL too, so a broken provider is not hammered.
+type CapabilityCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]*capabilityEntry
+	now     func() time.Time
+}
+
+func NewCapabilityCache(ttl time.Duration) *CapabilityCache {
+	if ttl <= 0 {
+		ttl = defaultCapabilityTTL
+	}
+	return &CapabilityCache{ttl: ttl, entries: map[string]*capabilityEntry{}, now: time.Now}
+}
+
+// Lookup returns the capabilities of adapter's base_url, probing through
+// adapter on a miss, after expiry, or when the cached entry was detected
+// under a different kind or header set.
+func (c *CapabilityCache) Lookup(ctx context.Context, adapter Adapter) (Capabilities, error) {
+	detector, ok := adapter.(capabilityDetector)
+	if !ok {
+		return Capabilities{}, fmt.Errorf("adapter %s does not support capability detection", adapter.Name())
+	}
+	key, fingerprint := detector.CapabilityKey()
+
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	if ok && entry.fingerprint == fingerprint {
+		select {
+		case <-entry.ready:
+			if c.now().Before(entry.caps.ExpiresAt) {
+				entry.caps.Hits++
+				caps, err := cloneCapabilities(entry.caps), entry.err
+				c.mu.Unlock()
+				return caps, err
+			}
+		default:
+			c.mu.Unlock()
+			return c.wait(ctx, key, entry)
+		}
+	}
+	var probes int64
+	if ok {
+		probes = entry.caps.Probes
+	}
+	entry = &capabilityEntry{fingerprint: fingerprint, ready: make(chan struct{})}
+	c.entries[key] = entry
+	c.mu.Unlock()
+
+	caps, err := detector.DetectCapabilities(ctx)
+	now := c.now().UTC()
+	caps.BaseURL = key
+	caps.DetectedBy = adapter.Name()
+	caps.DetectedAt = now
+	caps.ExpiresAt = now.Add(c.ttl)
+	caps.Probes = probes + 1
+	if err != nil {
+		caps.Error = err.Error()
+	}
+	c.mu.Lock()
+	entry.caps, entry.err = caps, err
+	close(entry.ready)
+	// A probe cut short by its caller says nothing about the provider.
+	if ctx.Err() != nil && c.entries[key] == entry {
+		delete(c.entries, key)
+	}
+	c.mu.Unlock()
+	return cloneCapabilities(caps), err
+}
+
+func (c *CapabilityCache) wait(ctx context.Context, key string, entry *capabilityEntry) (Capabilities, error) {
+	select {
+	case <-ctx.Done():
+		return Capabilities{}, ctx.Err()
+	case <-entry.ready:
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries[key] == entry {
+		entry.caps.Hits++
+	}
+	return cloneCapabilities(entry.caps), entry.err
+}
+
+// Cached returns the entry for baseURL without probing.
+func (c *CapabilityCache) Cached(baseURL string) (Capabilities, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[normalizeCapabilityURL(baseURL)]
+	if !ok {
+		return Capabilities{}, false
+	}
+	select {
+	case <-entry.ready:
+		return cloneCapabilities(entry.caps), true
+	default:
+		return Capabilities{}, false
+	}
+}
+
+// Invalidate drops the entry for baseURL, or every entry when baseURL is
+// empty, and reports how many were dropped.
+func (c *CapabilityCache) Invalidate(baseURL string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if strings.TrimSpace(baseURL) == "" {
+		n := len(c.entries)
+		c.entries = map[string]*capabilityEntry{}
+		return n
+	}
+	key := normalizeCapabilityURL(baseURL)
+	if _, ok := c.entries[key]; !ok {
+		return 0
+	}
+	delete(c.entries, key)
+	return 1
+}
+
+// Sync drops entries that no adapter in adapters still points at with the
+// same kind and headers. It runs on every upstream config change.
+func (c *CapabilityCache) Sync(adapters []Adapter) []string {
+	live := map[string]map[string]bool{}
+	for _, adapter := range adapters {
+		detector, ok := adapter.(capabilityDetector)
+		if !ok {
+			continue
+		}
+		key, fingerprint := detector.CapabilityKey()
+		if live[key] == nil {
+			live[key] = map[string]bool{}
+		}
+		live[key][fingerprint] = true
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var dropped []string
+	for key, entry := range c.entries {
+		if !live[key][entry.fingerprint] {
+			delete(c.entries, key)
+			dropped = append(dropped, key)
+		}
+	}
+	sort.Strings(dropped)
+	return dropped
+}
+
+// Snapshot lists the finished entries ordered by base_url.
+func (c *CapabilityCache) Snapshot() []Capabilities {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make([]Capabilities, 0, len(c.entries))
+	for _, entry := range c.entries {
+		select {
+		case <-entry.ready:
+			out = append(out, cloneCapabilities(entry.caps))
+		default:
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].BaseURL < out[j].BaseURL })
+	return out
+}
+
+func cloneCapabilities(in Capabilities) Capabilities {
+	out := in
+	out.Models = append([]string(nil), in.Models...)
+	if in.Constraints != nil {
+		out.Constraints = make(map[string]ModelConstraints, len(in.Constraints))
+		for k, v := range in.Constraints {
+			v.SupportsVision = cloneBoolPtr(v.SupportsVision)
+			out.Constraints[k] = v
+		}
+	}
+	return out
+}
+
+func normalizeCapabilityURL(raw string) string {
+	return strings.ToLower(strings.TrimRight(strings.TrimSpace(raw), "/"))
+}
+
+// CapabilityKey identifies the provider behind the adapter: the base_url,
+// plus a fingerprint of the settings that change what the provider
+// reports. API keys are left out so a key pool shares one entry.
+func (a *HTTPAdapter) CapabilityKey() (string, string) {
+	headers := make([]string, 0, len(a.headers))
+	for k, v := range a.headers {
+		headers = append(headers, strings.ToLower(strings.TrimSpace(k))+"="+v)
+	}
+	sort.Strings(headers)
+	parts := append([]string{string(a.kind), strings.ToLower(a.apiKeyHeader)}, headers...)
+	return normalizeCapabilityURL(a.baseURL), strings.Join(parts, "|")
+}
+
+// DetectCapabilities lists the provider's models through its models
+// endpoint: /v1/models for OpenAI and Anthropic, /v1beta/models for Gemini.
+func (a *HTTPAdapter) DetectCapabilities(ctx context.Context) (Capabilities, error) {
+	var path string
+	switch a.kind {
+	case AdapterKindOpenAI, AdapterKindAnthropic:
+		path = "/v1/models"
+	case AdapterKindGemini:
+		path = "/v1beta/models"
+	default:
+		return Capabilities{Kind: a.kind}, fmt.Errorf("adapter %s: capability detection is not supported for kind %q", a.name, a.kind)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, a.baseURL+path, nil)
+	if err != nil {
+		return Capabilities{Kind: a.kind}, err
+	}
+	a.applyRequestHeaders(httpReq, nil)
+	resp, err := a.client.Do(httpReq)
+	if err != nil {
+		return Capabilities{Kind: a.kind}, err
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 8<<20))
+	if err != nil {
+		return Capabilities{Kind: a.kind}, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return Capabilities{Kind: a.kind}, fmt.Errorf("adapter %s models endpoint status %d", a.name, resp.StatusCode)
+	}
+	caps, err := parseModelList(a.kind, raw)
+	if err != nil {
+		return Capabilities{Kind: a.kind}, fmt.Errorf("adapter %s: %w", a.name, err)
+	}
+	return caps, nil
+}
+
+// parseModelList reads the OpenAI/Anthropic {"data":[...]} shape and the
+// Gemini {"models":[...]} shape. Limits come from the fields common
+// providers and OpenAI-compatible servers use.
+func parseModelList(kind AdapterKind, raw []byte) (Capabilities, error) {
+	var body struct {
+		Data []struct {
+			ID            string `json:"id"`
+			ContextLength int    `json:"context_length"`
+			MaxModelLen   int    `json:"max_model_len"`
+			MaxTokens     int    `json:"max_tokens"`
+			TopProvider   struct {
+				MaxCompletionTokens int `json:"max_completion_tokens"`
+			} `json:"top_provider"`
+			Architecture struct {
+				InputModalities []string `json:"input_modalities"`
+			} `json:"architecture"`
+		} `json:"data"`
+		Models []struct {
+			Name             string `json:"name"`
+			InputTokenLimit  int    `json:"inputTokenLimit"`
+			OutputTokenLimit int    `json:"outputTokenLimit"`
+		} `json:"models"`
+	}
+	if err := json.Unmarshal(raw, &body); err != nil {
+		return Capabilities{}, fmt.Errorf("decode model list: %w", err)
+	}
+	caps := Capabilities{Kind: kind, Constraints: map[string]ModelConstraints{}}
+	for _, m := range body.Data {
+		id := strings.TrimSpace(m.ID)
+		if id == "" {
+			continue
+		}
+		caps.Models = append(caps.Models, id)
+		c := ModelConstraints{ContextWindow: m.ContextLength, MaxOutputTokens: m.TopProvider.MaxCompletionTokens}
+		if c.ContextWindow == 0 {
+			c.ContextWindow = m.MaxModelLen
+		}
+		if c.MaxOutputTokens == 0 {
+			c.MaxOutputTokens = m.MaxTokens
+		}
+		if len(m.Architecture.InputModalities) > 0 {
+			vision := false
+			for _, mod := range m.Architecture.InputModalities {
+				vision = vision || strings.EqualFold(mod, "image")
+			}
+			c.SupportsVision = &vision
+		}
+		if c != (ModelConstraints{}) {
+			caps.Constraints[id] = c
+		}
+	}
+	for _, m := range body.Models {
+		id := strings.TrimPrefix(strings.TrimSpace(m.Name), "models/")
+		if id == "" {
+			continue
+		}
+		caps.Models = append(caps.Models, id)
+		if m.InputTokenLimit > 0 || m.OutputTokenLimit > 0 {
+			caps.Constraints[id] = ModelConstraints{ContextWindow: m.InputTokenLimit, MaxOutputTokens: m.OutputTokenLimit}
+		}
+	}
+	sort.Strings(caps.Models)
+	if len(caps.Constraints) == 0 {
+		caps.Constraints = nil
+	}
+	return caps, nil
+}
+
+// AdapterCapabilities returns the capabilities behind the named adapter,
+// probing its base_url on a cache miss. refresh drops the cached entry
+// first.
+func (s *RouterService) AdapterCapabilities(ctx context.Context, name string, refresh bool) (Capabilities, error) {
+	s.mu.RLock()
+	m, ok := s.adapters[strings.TrimSpace(name)]
+	s.mu.RUnlock()
+	if !ok {
+		return Capabilities{}, fmt.Errorf("%w: %s", ErrUnknownAdapter, name)
+	}
+	if refresh {
+		if detector, ok := m.adapter.(capabilityDetector); ok {
+			key, _ := detector.CapabilityKey()
+			s.capabilities.Invalidate(key)
+		}
+	}
+	return s.capabilities.Lookup(ctx, m.adapter)
+}
+
+// CapabilitySnapshot lists the cached capabilities with the adapters that
+// share each base_url.
+func (s *RouterService) CapabilitySnapshot() []CapabilityStatus {
+	s.mu.RLock()
+	byURL := map[string][]string{}
+	for _, name := range s.adapterOrder {
+		m, ok := s.adapters[name]
+		if !ok {
+			continue
+		}
+		if detector, ok := m.adapter.(capabilityDetector); ok {
+			key, _ := detector.CapabilityKey()
+			byURL[key] = append(byURL[key], name)
+		}
+	}
+	s.mu.RUnlock()
+	cached := s.capabilities.Snapshot()
+	out := make([]CapabilityStatus, 0, len(cached))
+	for _, caps := range cached {
+		out = append(out, CapabilityStatus{Capabilities: caps, Adapters: byURL[caps.BaseURL]})
+	}
+	return out
+}
+
+// InvalidateCapabilities drops the cached entry for baseURL, or all of
+// them when baseURL is empty.
+func (s *RouterService) InvalidateCapabilities(baseURL string) int {
+	return s.capabilities.Invalidate(baseURL)
+}
+
+// CapabilityStatus is a cache entry with the adapters using its base_url.
+type CapabilityStatus struct {
+	Capabilities
+	Adapters []string `json:"adapters"`
+}
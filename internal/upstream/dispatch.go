@@ -2,6 +2,7 @@ package upstream
 
 import (
 	"context"
+	"fmt"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -19,6 +20,12 @@ type DispatchConfig struct {
 	FallbackToScheduler bool    `json:"fallback_to_scheduler"` // 失败时回退到调度器
 	MinScoreDifference  float64 `json:"min_score_difference"` // 选举最小分数差
 	ReElectIntervalMS   int64   `json:"re_elect_interval_ms"` // 重新选举间隔(毫秒)
+	// AdapterPrices is the per-adapter price list used by the cost policy.
+	AdapterPrices map[string]AdapterPrice `json:"adapter_prices,omitempty"`
+	// PreferCheapestWithinScoreDelta sends simple requests to the cheapest
+	// worker scoring within this many points of the best worker; 0 keeps
+	// plain round-robin.
+	PreferCheapestWithinScoreDelta float64 `json:"prefer_cheapest_within_score_delta,omitempty"`
 }
 
 // DispatchStats 调度统计信息
@@ -49,6 +56,9 @@ type Dispatcher struct {
 	// Stats
 	stats DispatchStats
 
+	// history persists decisions and elections for analytics (optional)
+	history *DispatchHistory
+
 	// Event log (circular buffer)
 	eventsMu         sync.RWMutex
 	eventLog        []DispatchEvent
@@ -69,6 +79,7 @@ func NewDispatcher(cfg DispatchConfig, election *scheduler.Election) *Dispatcher
 	if cfg.ReElectIntervalMS <= 0 {
 		cfg.ReElectIntervalMS = 600000 // default 10 minutes
 	}
+	cfg.AdapterPrices = copyAdapterPrices(cfg.AdapterPrices)
 	d := &Dispatcher{
 		cfg:              cfg,
 		election:         election,
@@ -83,6 +94,12 @@ func NewDispatcher(cfg DispatchConfig, election *scheduler.Election) *Dispatcher
 // ClassifyComplexity determines if a request is "complex" (should go to scheduler model)
 // or "simple" (can go to any worker). Uses TaskClassifier for intelligent classification.
 func (d *Dispatcher) ClassifyComplexity(ctx context.Context, req orchestrator.Request) string {
+	complexity, _ := d.classify(ctx, req)
+	return complexity
+}
+
+// classify also returns the classifier level so decisions can be analyzed later.
+func (d *Dispatcher) classify(ctx context.Context, req orchestrator.Request) (string, TaskComplexity) {
 	// Use TaskClassifier for intelligent classification
 	complexity := d.classifier.ClassifyTask(ctx, req.Messages)
 
@@ -90,31 +107,36 @@ func (d *Dispatcher) ClassifyComplexity(ctx context.Context, req orchestrator.Re
 	switch complexity {
 	case ComplexityVeryHigh, ComplexityHigh:
 		// High complexity tasks need scheduler (high intelligence model)
-		return "complex"
+		return "complex", complexity
 	case ComplexityMedium:
 		// Medium complexity - check additional factors
 		if len(req.Tools) > 0 || d.hasLongContext(req) {
-			return "complex"
+			return "complex", complexity
 		}
-		return "simple"
+		return "simple", complexity
 	default:
 		// Low complexity - check if has tools (tool use is complex)
 		if len(req.Tools) > 0 {
-			return "complex"
+			return "complex", complexity
 		}
-		return "simple"
+		return "simple", complexity
 	}
 }
 
 // hasLongContext checks if the request has long context.
 func (d *Dispatcher) hasLongContext(req orchestrator.Request) bool {
+	return contextChars(req) > 4000
+}
+
+// contextChars counts the characters of plain-string message content.
+func contextChars(req orchestrator.Request) int {
 	totalLen := 0
 	for _, m := range req.Messages {
 		if s, ok := m.Content.(string); ok {
 			totalLen += len(s)
 		}
 	}
-	return totalLen > 4000
+	return totalLen
 }
 
 // ClassifyComplexityStatic determines complexity without dispatcher instance.
@@ -159,22 +181,55 @@ func ClassifyComplexityStatic(req orchestrator.Request) string {
 //
 // If dispatch is disabled or no election result, returns nil (use default routing).
 func (d *Dispatcher) RouteRequest(ctx context.Context, req orchestrator.Request, allAdapters []string) []string {
+	route, _ := d.Decide(ctx, req)
+	return route
+}
+
+// Decide is RouteRequest plus the decision record. The record is nil when
+// dispatch did not apply; pass it to RecordOutcome once the request finishes.
+func (d *Dispatcher) Decide(ctx context.Context, req orchestrator.Request) ([]string, *DispatchDecision) {
+	return d.decide(ctx, req, true)
+}
+
+// Preview is Decide without counting the request in the stats or advancing
+// the round-robin, for explaining a route without serving it.
+func (d *Dispatcher) Preview(ctx context.Context, req orchestrator.Request) ([]string, *DispatchDecision) {
+	return d.decide(ctx, req, false)
+}
+
+func (d *Dispatcher) decide(ctx context.Context, req orchestrator.Request, record bool) ([]string, *DispatchDecision) {
 	if d == nil || !d.cfg.Enabled || d.election == nil {
-		return nil
+		return nil, nil
 	}
 
 	result := d.election.Result()
 	if result == nil {
-		return nil
+		return nil, nil
 	}
 
-	complexity := d.ClassifyComplexity(ctx, req)
+	complexity, level := d.classify(ctx, req)
 	schedulerName := result.SchedulerAdapter
+	decide := func(route []string, target, reason string) ([]string, *DispatchDecision) {
+		return route, &DispatchDecision{
+			Timestamp:        time.Now().UTC(),
+			Model:            req.Model,
+			Complexity:       complexity,
+			TaskComplexity:   level.String(),
+			ContextChars:     contextChars(req),
+			ToolCount:        len(req.Tools),
+			Target:           target,
+			Reason:           reason,
+			Route:            append([]string(nil), route...),
+			SchedulerAdapter: schedulerName,
+			SchedulerScore:   result.SchedulerScore,
+			ElectionReason:   result.Reason,
+		}
+	}
 
 	switch complexity {
 	case "complex":
 		// Scheduler model handles complex requests, workers as fallback
-		atomic.AddInt64(&d.stats.ComplexRouted, 1)
+		d.count(&d.stats.ComplexRouted, record)
 
 		// Check if scheduler is healthy (not in cooldown)
 		if d.isSchedulerHealthy() {
@@ -182,27 +237,27 @@ func (d *Dispatcher) RouteRequest(ctx context.Context, req orchestrator.Request,
 			for _, w := range result.Workers {
 				out = append(out, w.AdapterName)
 			}
-			return out
+			return decide(out, "scheduler", "complex_to_scheduler")
 		}
 
 		// Scheduler not healthy, skip to workers
-		atomic.AddInt64(&d.stats.FallbackCount, 1)
+		d.count(&d.stats.FallbackCount, record)
 		if len(result.Workers) > 0 {
 			out := make([]string, 0, len(result.Workers))
 			for _, w := range result.Workers {
 				out = append(out, w.AdapterName)
 			}
-			return out
+			return decide(out, "worker", "scheduler_unhealthy")
 		}
 		// No workers, return scheduler as last resort
-		return []string{schedulerName}
+		return decide([]string{schedulerName}, "scheduler", "scheduler_last_resort")
 
 	default: // "simple"
 		workers := d.election.WorkerAdapters()
 		if len(workers) == 0 {
 			// Only scheduler exists, use it
-			atomic.AddInt64(&d.stats.SimpleRouted, 1)
-			return []string{schedulerName}
+			d.count(&d.stats.SimpleRouted, record)
+			return decide([]string{schedulerName}, "scheduler", "no_workers")
 		}
 
 		// Check if workers are healthy
@@ -210,16 +265,30 @@ func (d *Dispatcher) RouteRequest(ctx context.Context, req orchestrator.Request,
 		if len(healthyWorkers) == 0 {
 			// All workers unhealthy, fallback to scheduler if enabled
 			if d.cfg.FallbackToScheduler {
-				atomic.AddInt64(&d.stats.FallbackCount, 1)
-				return []string{schedulerName}
+				d.count(&d.stats.FallbackCount, record)
+				return decide([]string{schedulerName}, "scheduler", "workers_unhealthy")
 			}
 			// Fallback not enabled, return workers anyway
-			atomic.AddInt64(&d.stats.SimpleRouted, 1)
-			return workers
+			d.count(&d.stats.SimpleRouted, record)
+			return decide(workers, "worker", "workers_unhealthy_no_fallback")
+		}
+
+		// Cheapest capable worker first when the cost policy is on
+		if ordered, cost, ok := d.cheapestWorkers(healthyWorkers, result.Workers, req); ok {
+			if d.cfg.FallbackToScheduler {
+				ordered = append(ordered, schedulerName)
+			}
+			d.count(&d.stats.SimpleRouted, record)
+			route, decision := decide(ordered, "worker", "simple_to_cheapest")
+			decision.EstimatedCostUSD = cost
+			return route, decision
 		}
 
 		// Round-robin among healthy workers
-		idx := atomic.AddUint64(&d.counter, 1)
+		idx := atomic.LoadUint64(&d.counter) + 1
+		if record {
+			idx = atomic.AddUint64(&d.counter, 1)
+		}
 		n := len(healthyWorkers)
 		ordered := make([]string, 0, n+1)
 		for i := 0; i < n; i++ {
@@ -229,9 +298,84 @@ func (d *Dispatcher) RouteRequest(ctx context.Context, req orchestrator.Request,
 		if d.cfg.FallbackToScheduler {
 			ordered = append(ordered, schedulerName)
 		}
-		atomic.AddInt64(&d.stats.SimpleRouted, 1)
-		return ordered
+		d.count(&d.stats.SimpleRouted, record)
+		return decide(ordered, "worker", "simple_to_workers")
+	}
+}
+
+// count bumps a dispatch counter unless the decision is only a preview.
+func (d *Dispatcher) count(counter *int64, record bool) {
+	if record {
+		atomic.AddInt64(counter, 1)
+	}
+}
+
+// SetHistory enables persistence of decisions and elections.
+func (d *Dispatcher) SetHistory(h *DispatchHistory) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.history = h
+}
+
+func (d *Dispatcher) dispatchHistory() *DispatchHistory {
+	if d == nil {
+		return nil
+	}
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.history
+}
+
+// RecordOutcome completes a decision from Decide with the adapter that
+// served the request (empty on failure) and stores it.
+func (d *Dispatcher) RecordOutcome(decision *DispatchDecision, servedBy string, latency time.Duration, err error) {
+	h := d.dispatchHistory()
+	if h == nil || decision == nil {
+		return
+	}
+	rec := *decision
+	rec.ServedBy = servedBy
+	rec.LatencyMS = latency.Milliseconds()
+	if servedBy != "" {
+		rec.ServedByRole = "worker"
+		if servedBy == rec.SchedulerAdapter {
+			rec.ServedByRole = "scheduler"
+		}
+		rec.Escalated = rec.Target == "worker" && rec.ServedByRole == "scheduler"
+	}
+	if err != nil || servedBy == "" {
+		rec.Outcome = "error"
+		if err != nil {
+			rec.Error = err.Error()
+		}
+	} else {
+		rec.Outcome = "success"
+	}
+	h.RecordDecision(rec)
+}
+
+// RecordElection stores an election result together with the score gap
+// threshold in force.
+func (d *Dispatcher) RecordElection(result scheduler.ElectionResult) {
+	h := d.dispatchHistory()
+	if h == nil {
+		return
+	}
+	h.RecordElection(electionRecordFromResult(result, d.GetConfig().MinScoreDifference))
+}
+
+// Analytics summarizes recorded decisions and elections.
+func (d *Dispatcher) Analytics(q DispatchAnalyticsQuery) (DispatchAnalytics, error) {
+	h := d.dispatchHistory()
+	if h == nil {
+		return DispatchAnalytics{}, fmt.Errorf("dispatch history is not configured")
 	}
+	out := h.Analytics(q)
+	out.CurrentMinScoreGap = d.GetConfig().MinScoreDifference
+	return out, nil
 }
 
 // isSchedulerHealthy checks if the scheduler is healthy based on election status
@@ -284,6 +428,8 @@ func (d *Dispatcher) Snapshot() map[string]any {
 		"fallback_to_scheduler": cfg.FallbackToScheduler,
 		"min_score_difference":  cfg.MinScoreDifference,
 		"re_elect_interval_ms":  cfg.ReElectIntervalMS,
+		"prefer_cheapest_within_score_delta": cfg.PreferCheapestWithinScoreDelta,
+		"adapter_prices":        cfg.AdapterPrices,
 		"stats": map[string]int64{
 			"complex_routed": stats.ComplexRouted,
 			"simple_routed":  stats.SimpleRouted,
@@ -314,6 +460,7 @@ func (d *Dispatcher) UpdateConfig(cfg DispatchConfig) {
 	if cfg.ReElectIntervalMS <= 0 {
 		cfg.ReElectIntervalMS = 600000
 	}
+	cfg.AdapterPrices = copyAdapterPrices(cfg.AdapterPrices)
 	d.mu.Lock()
 	defer d.mu.Unlock()
 	d.cfg = cfg
@@ -0,0 +1,111 @@
+package upstream
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"ccgateway/internal/orchestrator"
+	"ccgateway/internal/scheduler"
+)
+
+// defaultCostOutputTokens stands in for max_tokens when a request leaves it
+// unset.
+const defaultCostOutputTokens = 1024
+
+// AdapterPrice is an adapter's list price in USD per million tokens.
+type AdapterPrice struct {
+	InputPerMTok  float64 `json:"input_per_mtok"`
+	OutputPerMTok float64 `json:"output_per_mtok"`
+}
+
+// EstimateCost prices a request of inputTokens and outputTokens.
+func (p AdapterPrice) EstimateCost(inputTokens, outputTokens int) float64 {
+	return (float64(inputTokens)*p.InputPerMTok + float64(outputTokens)*p.OutputPerMTok) / 1_000_000
+}
+
+// cheapestWorkers orders healthy for a simple request when the cost policy
+// is on. Workers scoring within PreferCheapestWithinScoreDelta of the best
+// healthy worker count as capable and come first, cheapest first; unpriced
+// capable workers follow them and the remaining workers keep their
+// election order. ok is false when the policy is off or no capable worker
+// has a price, leaving the caller to round-robin. cost is the estimate for
+// the first worker.
+func (d *Dispatcher) cheapestWorkers(healthy []string, elected []scheduler.Worker, req orchestrator.Request) (ordered []string, cost float64, ok bool) {
+	d.mu.RLock()
+	delta := d.cfg.PreferCheapestWithinScoreDelta
+	prices := d.cfg.AdapterPrices
+	d.mu.RUnlock()
+	if delta <= 0 || len(prices) == 0 || len(healthy) == 0 {
+		return nil, 0, false
+	}
+
+	scores := make(map[string]float64, len(elected))
+	for _, w := range elected {
+		scores[w.AdapterName] = w.Score
+	}
+	best := math.Inf(-1)
+	for _, name := range healthy {
+		best = math.Max(best, scores[name])
+	}
+
+	inputTokens := contextChars(req) / 4
+	outputTokens := req.MaxTokens
+	if outputTokens <= 0 {
+		outputTokens = defaultCostOutputTokens
+	}
+	type candidate struct {
+		name   string
+		score  float64
+		cost   float64
+		priced bool
+	}
+	var capable []candidate
+	var rest []string
+	priced := false
+	for _, name := range healthy {
+		if scores[name] < best-delta {
+			rest = append(rest, name)
+			continue
+		}
+		c := candidate{name: name, score: scores[name]}
+		if p, found := prices[name]; found {
+			c.cost, c.priced = p.EstimateCost(inputTokens, outputTokens), true
+			priced = true
+		}
+		capable = append(capable, c)
+	}
+	if !priced {
+		return nil, 0, false
+	}
+	sort.SliceStable(capable, func(i, j int) bool {
+		a, b := capable[i], capable[j]
+		if a.priced != b.priced {
+			return a.priced
+		}
+		if a.cost != b.cost {
+			return a.cost < b.cost
+		}
+		if a.score != b.score {
+			return a.score > b.score
+		}
+		return strings.Compare(a.name, b.name) < 0
+	})
+	ordered = make([]string, 0, len(healthy)+1)
+	for _, c := range capable {
+		ordered = append(ordered, c.name)
+	}
+	ordered = append(ordered, rest...)
+	return ordered, capable[0].cost, true
+}
+
+func copyAdapterPrices(in map[string]AdapterPrice) map[string]AdapterPrice {
+	if len(in) == 0 {
+		return nil
+	}
+	out := make(map[string]AdapterPrice, len(in))
+	for k, v := range in {
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,516 @@
+package upstream
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"ccgateway/internal/scheduler"
+)
+
+const (
+	maxDispatchDecisions = 50000
+	maxElectionRecords   = 5000
+)
+
+// minScoreSimulation and longContextSimulation are the candidate thresholds
+// the analytics report replays history against.
+var (
+	minScoreSimulation    = []float64{1, 2, 5, 10, 15, 20}
+	longContextSimulation = []int{1000, 2000, 4000, 8000, 16000, 32000}
+)
+
+// DispatchDecision is one dispatcher routing decision and how the request
+// it routed turned out.
+type DispatchDecision struct {
+	Timestamp        time.Time `json:"timestamp"`
+	Model            string    `json:"model,omitempty"`
+	Complexity       string    `json:"complexity"`
+	TaskComplexity   string    `json:"task_complexity"`
+	ContextChars     int       `json:"context_chars"`
+	ToolCount        int       `json:"tool_count"`
+	Target           string    `json:"target"` // scheduler, worker
+	Reason           string    `json:"reason"`
+	Route            []string  `json:"route"`
+	SchedulerAdapter string    `json:"scheduler_adapter"`
+	SchedulerScore   float64   `json:"scheduler_score"`
+	ElectionReason   string    `json:"election_reason,omitempty"`
+	Stream           bool      `json:"stream,omitempty"`
+	ServedBy         string    `json:"served_by,omitempty"`
+	ServedByRole     string    `json:"served_by_role,omitempty"` // scheduler, worker
+	Escalated        bool      `json:"escalated,omitempty"`      // routed to workers, served by the scheduler
+	Outcome          string    `json:"outcome"`                  // success, error
+	Error            string    `json:"error,omitempty"`
+	LatencyMS        int64     `json:"latency_ms"`
+	// EstimatedCostUSD is the first worker's estimated price when the
+	// cost policy picked the route.
+	EstimatedCostUSD float64 `json:"estimated_cost_usd,omitempty"`
+}
+
+// ElectionRecord is one scheduler election result.
+type ElectionRecord struct {
+	Timestamp          time.Time `json:"timestamp"`
+	SchedulerAdapter   string    `json:"scheduler_adapter"`
+	SchedulerModel     string    `json:"scheduler_model,omitempty"`
+	SchedulerScore     float64   `json:"scheduler_score"`
+	RunnerUpAdapter    string    `json:"runner_up_adapter,omitempty"`
+	RunnerUpScore      float64   `json:"runner_up_score,omitempty"`
+	ScoreDelta         float64   `json:"score_delta"`
+	Workers            int       `json:"workers"`
+	Reason             string    `json:"reason"`
+	MinScoreDifference float64   `json:"min_score_difference"`
+	Changed            bool      `json:"changed"`
+}
+
+type dispatchHistoryLine struct {
+	Kind     string            `json:"kind"`
+	Decision *DispatchDecision `json:"decision,omitempty"`
+	Election *ElectionRecord   `json:"election,omitempty"`
+}
+
+// DispatchHistory keeps every dispatch decision and election in a JSON-lines
+// file so analytics survive restarts. Only the most recent records are kept
+// in memory.
+type DispatchHistory struct {
+	mu        sync.RWMutex
+	path      string
+	file      *os.File
+	decisions []DispatchDecision
+	elections []ElectionRecord
+}
+
+// NewDispatchHistory opens (or creates) the history at path. An empty path
+// keeps it in memory only.
+func NewDispatchHistory(path string) (*DispatchHistory, error) {
+	h := &DispatchHistory{path: strings.TrimSpace(path)}
+	if h.path == "" {
+		return h, nil
+	}
+	h.path = filepath.Clean(h.path)
+	if err := os.MkdirAll(filepath.Dir(h.path), 0o755); err != nil {
+		return nil, fmt.Errorf("create dispatch history dir: %w", err)
+	}
+	if err := h.load(); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open dispatch history: %w", err)
+	}
+	h.file = f
+	return h, nil
+}
+
+// DispatchHistoryFromEnv reads DISPATCH_HISTORY_PATH (default
+// logs/dispatch-history.jsonl).
+func DispatchHistoryFromEnv() (*DispatchHistory, error) {
+	path := strings.TrimSpace(os.Getenv("DISPATCH_HISTORY_PATH"))
+	if path == "" {
+		path = "logs/dispatch-history.jsonl"
+	}
+	return NewDispatchHistory(path)
+}
+
+func (h *DispatchHistory) load() error {
+	f, err := os.Open(h.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("open dispatch history: %w", err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4<<20)
+	for scanner.Scan() {
+		var line dispatchHistoryLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			continue
+		}
+		switch {
+		case line.Decision != nil:
+			h.decisions = append(h.decisions, *line.Decision)
+		case line.Election != nil:
+			h.elections = append(h.elections, *line.Election)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("read dispatch history: %w", err)
+	}
+	h.trimLocked()
+	return nil
+}
+
+// RecordDecision appends a completed decision.
+func (h *DispatchHistory) RecordDecision(d DispatchDecision) {
+	if h == nil {
+		return
+	}
+	if d.Timestamp.IsZero() {
+		d.Timestamp = time.Now().UTC()
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.decisions = append(h.decisions, d)
+	h.trimLocked()
+	h.writeLocked(dispatchHistoryLine{Kind: "decision", Decision: &d})
+}
+
+// RecordElection appends an election result. Changed is derived from the
+// previous record.
+func (h *DispatchHistory) RecordElection(e ElectionRecord) {
+	if h == nil {
+		return
+	}
+	if e.Timestamp.IsZero() {
+		e.Timestamp = time.Now().UTC()
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if n := len(h.elections); n > 0 {
+		e.Changed = h.elections[n-1].SchedulerAdapter != e.SchedulerAdapter
+	} else {
+		e.Changed = true
+	}
+	h.elections = append(h.elections, e)
+	h.trimLocked()
+	h.writeLocked(dispatchHistoryLine{Kind: "election", Election: &e})
+}
+
+// Close releases the history file.
+func (h *DispatchHistory) Close() error {
+	if h == nil {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.file == nil {
+		return nil
+	}
+	err := h.file.Close()
+	h.file = nil
+	return err
+}
+
+func (h *DispatchHistory) writeLocked(line dispatchHistoryLine) {
+	if h.file == nil {
+		return
+	}
+	raw, err := json.Marshal(line)
+	if err != nil {
+		return
+	}
+	_, _ = h.file.Write(append(raw, '\n'))
+}
+
+func (h *DispatchHistory) trimLocked() {
+	if over := len(h.decisions) - maxDispatchDecisions; over > 0 {
+		h.decisions = append([]DispatchDecision(nil), h.decisions[over:]...)
+	}
+	if over := len(h.elections) - maxElectionRecords; over > 0 {
+		h.elections = append([]ElectionRecord(nil), h.elections[over:]...)
+	}
+}
+
+// electionRecordFromResult summarizes an election; workers arrive sorted by
+// score so the first one is the runner-up.
+func electionRecordFromResult(result scheduler.ElectionResult, minScoreDifference float64) ElectionRecord {
+	rec := ElectionRecord{
+		Timestamp:          result.ElectedAt.UTC(),
+		SchedulerAdapter:   result.SchedulerAdapter,
+		SchedulerModel:     result.SchedulerModel,
+		SchedulerScore:     result.SchedulerScore,
+		Workers:            len(result.Workers),
+		Reason:             result.Reason,
+		MinScoreDifference: minScoreDifference,
+	}
+	if len(result.Workers) > 0 {
+		rec.RunnerUpAdapter = result.Workers[0].AdapterName
+		rec.RunnerUpScore = result.Workers[0].Score
+		rec.ScoreDelta = result.SchedulerScore - result.Workers[0].Score
+	}
+	return rec
+}
+
+// DispatchAnalyticsQuery bounds the analytics window. Zero times are open.
+type DispatchAnalyticsQuery struct {
+	Since time.Time
+	Until time.Time
+}
+
+// DispatchBucket aggregates outcomes for one slice of decisions.
+type DispatchBucket struct {
+	Requests     int     `json:"requests"`
+	Success      int     `json:"success"`
+	Errors       int     `json:"errors"`
+	SuccessRate  float64 `json:"success_rate"`
+	AvgLatencyMS float64 `json:"avg_latency_ms"`
+	P95LatencyMS int64   `json:"p95_latency_ms"`
+}
+
+// ScoreStats summarizes election score deltas.
+type ScoreStats struct {
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+	Mean   float64 `json:"mean"`
+	Median float64 `json:"median"`
+}
+
+// MinScoreSimulation reports how many elections would have been close-score
+// tiebreaks under Threshold.
+type MinScoreSimulation struct {
+	Threshold      float64 `json:"threshold"`
+	CloseElections int     `json:"close_elections"`
+	Share          float64 `json:"share"`
+}
+
+// LongContextSimulation replays medium-complexity, tool-free requests (the
+// ones decided by context length) against a LongContextChars threshold.
+type LongContextSimulation struct {
+	Chars            int     `json:"chars"`
+	WouldEscalate    int     `json:"would_escalate"`
+	EscalationRate   float64 `json:"escalation_rate"`
+	WorkerAboveRate  float64 `json:"worker_success_rate_above"`
+	WorkerBelowRate  float64 `json:"worker_success_rate_below"`
+	WorkerAboveCount int     `json:"worker_requests_above"`
+}
+
+// ElectionAnalytics summarizes elections in the window.
+type ElectionAnalytics struct {
+	Count            int                  `json:"count"`
+	SchedulerChanges int                  `json:"scheduler_changes"`
+	CloseScoreCount  int                  `json:"close_score_count"`
+	ScoreDelta       ScoreStats           `json:"score_delta"`
+	MinScoreDiff     []MinScoreSimulation `json:"min_score_difference_simulation"`
+	Recent           []ElectionRecord     `json:"recent"`
+}
+
+// DispatchAnalytics is the report served by /admin/dispatch/analytics.
+type DispatchAnalytics struct {
+	Since              *time.Time                `json:"since,omitempty"`
+	Until              *time.Time                `json:"until,omitempty"`
+	Decisions          int                       `json:"decisions"`
+	SchedulerShare     float64                   `json:"scheduler_share"`
+	EscalationRate     float64                   `json:"escalation_rate"`
+	SuccessRate        float64                   `json:"success_rate"`
+	ByTarget           map[string]DispatchBucket `json:"by_target"`
+	ByTaskComplexity   map[string]DispatchBucket `json:"by_task_complexity"`
+	ByServedBy         map[string]DispatchBucket `json:"by_served_by"`
+	ByReason           map[string]int            `json:"by_reason"`
+	Elections          ElectionAnalytics         `json:"elections"`
+	LongContextChars   []LongContextSimulation   `json:"long_context_simulation"`
+	CurrentMinScoreGap float64                   `json:"current_min_score_difference,omitempty"`
+}
+
+// Analytics aggregates the decisions and elections inside q's window.
+func (h *DispatchHistory) Analytics(q DispatchAnalyticsQuery) DispatchAnalytics {
+	out := DispatchAnalytics{
+		ByTarget:         map[string]DispatchBucket{},
+		ByTaskComplexity: map[string]DispatchBucket{},
+		ByServedBy:       map[string]DispatchBucket{},
+		ByReason:         map[string]int{},
+	}
+	if !q.Since.IsZero() {
+		since := q.Since.UTC()
+		out.Since = &since
+	}
+	if !q.Until.IsZero() {
+		until := q.Until.UTC()
+		out.Until = &until
+	}
+	if h == nil {
+		return out
+	}
+	h.mu.RLock()
+	decisions := make([]DispatchDecision, 0, len(h.decisions))
+	for _, d := range h.decisions {
+		if inWindow(d.Timestamp, q) {
+			decisions = append(decisions, d)
+		}
+	}
+	elections := make([]ElectionRecord, 0)
+	for _, e := range h.elections {
+		if inWindow(e.Timestamp, q) {
+			elections = append(elections, e)
+		}
+	}
+	h.mu.RUnlock()
+
+	byTarget := map[string][]DispatchDecision{}
+	byComplexity := map[string][]DispatchDecision{}
+	byServed := map[string][]DispatchDecision{}
+	scheduled, escalated, workerRouted := 0, 0, 0
+	for _, d := range decisions {
+		byTarget[d.Target] = append(byTarget[d.Target], d)
+		byComplexity[d.TaskComplexity] = append(byComplexity[d.TaskComplexity], d)
+		if d.ServedBy != "" {
+			byServed[d.ServedBy] = append(byServed[d.ServedBy], d)
+		}
+		out.ByReason[d.Reason]++
+		if d.Target == "scheduler" {
+			scheduled++
+		} else {
+			workerRouted++
+			if d.Escalated {
+				escalated++
+			}
+		}
+	}
+	out.Decisions = len(decisions)
+	out.SchedulerShare = ratio(scheduled, len(decisions))
+	out.EscalationRate = ratio(escalated, workerRouted)
+	out.SuccessRate = bucketOf(decisions).SuccessRate
+	for k, v := range byTarget {
+		out.ByTarget[k] = bucketOf(v)
+	}
+	for k, v := range byComplexity {
+		out.ByTaskComplexity[k] = bucketOf(v)
+	}
+	for k, v := range byServed {
+		out.ByServedBy[k] = bucketOf(v)
+	}
+	out.LongContextChars = simulateLongContext(decisions)
+	out.Elections = analyzeElections(elections)
+	if n := len(elections); n > 0 {
+		out.CurrentMinScoreGap = elections[n-1].MinScoreDifference
+	}
+	return out
+}
+
+func inWindow(ts time.Time, q DispatchAnalyticsQuery) bool {
+	if !q.Since.IsZero() && ts.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && ts.After(q.Until) {
+		return false
+	}
+	return true
+}
+
+func bucketOf(decisions []DispatchDecision) DispatchBucket {
+	b := DispatchBucket{Requests: len(decisions)}
+	if len(decisions) == 0 {
+		return b
+	}
+	latencies := make([]int64, 0, len(decisions))
+	var sum int64
+	for _, d := range decisions {
+		if d.Outcome == "success" {
+			b.Success++
+		} else {
+			b.Errors++
+		}
+		latencies = append(latencies, d.LatencyMS)
+		sum += d.LatencyMS
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	b.SuccessRate = ratio(b.Success, b.Requests)
+	b.AvgLatencyMS = round2(float64(sum) / float64(len(latencies)))
+	b.P95LatencyMS = latencies[int(math.Ceil(0.95*float64(len(latencies))))-1]
+	return b
+}
+
+func simulateLongContext(decisions []DispatchDecision) []LongContextSimulation {
+	var lengthDecided, workers []DispatchDecision
+	for _, d := range decisions {
+		if d.TaskComplexity == ComplexityMedium.String() && d.ToolCount == 0 {
+			lengthDecided = append(lengthDecided, d)
+		}
+		if d.ServedByRole == "worker" {
+			workers = append(workers, d)
+		}
+	}
+	out := make([]LongContextSimulation, 0, len(longContextSimulation))
+	for _, chars := range longContextSimulation {
+		sim := LongContextSimulation{Chars: chars}
+		for _, d := range lengthDecided {
+			if d.ContextChars > chars {
+				sim.WouldEscalate++
+			}
+		}
+		sim.EscalationRate = ratio(sim.WouldEscalate, len(lengthDecided))
+		aboveOK, above, belowOK, below := 0, 0, 0, 0
+		for _, d := range workers {
+			if d.ContextChars > chars {
+				above++
+				if d.Outcome == "success" {
+					aboveOK++
+				}
+			} else {
+				below++
+				if d.Outcome == "success" {
+					belowOK++
+				}
+			}
+		}
+		sim.WorkerAboveCount = above
+		sim.WorkerAboveRate = ratio(aboveOK, above)
+		sim.WorkerBelowRate = ratio(belowOK, below)
+		out = append(out, sim)
+	}
+	return out
+}
+
+func analyzeElections(elections []ElectionRecord) ElectionAnalytics {
+	out := ElectionAnalytics{Count: len(elections), MinScoreDiff: make([]MinScoreSimulation, 0, len(minScoreSimulation))}
+	deltas := make([]float64, 0, len(elections))
+	for i, e := range elections {
+		if e.Changed && i > 0 {
+			out.SchedulerChanges++
+		}
+		if e.Reason == "close_scores_tiebreak" {
+			out.CloseScoreCount++
+		}
+		if e.Workers > 0 {
+			deltas = append(deltas, e.ScoreDelta)
+		}
+	}
+	if len(deltas) > 0 {
+		sorted := append([]float64(nil), deltas...)
+		sort.Float64s(sorted)
+		var sum float64
+		for _, v := range sorted {
+			sum += v
+		}
+		out.ScoreDelta = ScoreStats{
+			Min:    round2(sorted[0]),
+			Max:    round2(sorted[len(sorted)-1]),
+			Mean:   round2(sum / float64(len(sorted))),
+			Median: round2(sorted[len(sorted)/2]),
+		}
+	}
+	for _, threshold := range minScoreSimulation {
+		sim := MinScoreSimulation{Threshold: threshold}
+		for _, d := range deltas {
+			if d < threshold {
+				sim.CloseElections++
+			}
+		}
+		sim.Share = ratio(sim.CloseElections, len(deltas))
+		out.MinScoreDiff = append(out.MinScoreDiff, sim)
+	}
+	start := len(elections) - 10
+	if start < 0 {
+		start = 0
+	}
+	out.Recent = append([]ElectionRecord{}, elections[start:]...)
+	return out
+}
+
+func ratio(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return math.Round(float64(n)/float64(total)*10000) / 10000
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
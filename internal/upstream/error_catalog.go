@@ -0,0 +1,268 @@
+package upstream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Machine-readable codes of normalized upstream errors.
+const (
+	ErrorCodeRateLimited       = "rate_limited"
+	ErrorCodeQuotaExhausted    = "quota_exhausted"
+	ErrorCodeContentFiltered   = "content_filtered"
+	ErrorCodeInvalidAPIKey     = "invalid_api_key"
+	ErrorCodeModelNotFound     = "model_not_found"
+	ErrorCodeContextLength     = "context_length_exceeded"
+	ErrorCodeRequestTooLarge   = "request_too_large"
+	ErrorCodeOverloaded        = "overloaded"
+	ErrorCodeInvalidRequest    = "invalid_request"
+	ErrorCodePermissionDenied  = "upstream_permission_denied"
+	ErrorCodeUpstreamTimeout   = "upstream_timeout"
+	ErrorCodeUpstreamError     = "upstream_error"
+	ErrorCodeUpstreamCancelled = "upstream_canceled"
+)
+
+// HTTPStatusError is a non-2xx answer from a provider.
+type HTTPStatusError struct {
+	Adapter    string
+	Status     int
+	Body       string
+	RetryAfter string
+}
+
+func newHTTPStatusError(adapter string, resp *http.Response, body []byte) *HTTPStatusError {
+	return &HTTPStatusError{
+		Adapter:    adapter,
+		Status:     resp.StatusCode,
+		Body:       strings.TrimSpace(string(body)),
+		RetryAfter: strings.TrimSpace(resp.Header.Get("retry-after")),
+	}
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("adapter %s upstream status %d: %s", e.Adapter, e.Status, e.Body)
+}
+
+func (e *HTTPStatusError) UpstreamStatus() int { return e.Status }
+
+// NormalizedError is an upstream failure mapped to the status, Anthropic
+// error type and code the gateway answers with.
+type NormalizedError struct {
+	Status         int
+	Type           string
+	Code           string
+	Adapter        string
+	UpstreamStatus int
+	// ProviderCode is the provider's own error type or code, if any.
+	ProviderCode string
+	RetryAfter   string
+}
+
+// errorRule maps provider errors to a normalized error. A rule applies when
+// the upstream status is one of Statuses (any when empty) and the
+// provider's code is in Codes or its message contains one of Phrases; a
+// rule without codes and phrases matches on status alone. Errors without
+// an upstream status, such as stream error chunks, match on codes only.
+type errorRule struct {
+	Code     string
+	Type     string
+	Status   int
+	Statuses []int
+	Codes    []string
+	Phrases  []string
+}
+
+// errorCatalog is checked in order; the first matching rule wins. Failed
+// provider credentials and permissions are the gateway's fault, so they
+// answer 502 rather than telling the client its own key is bad.
+var errorCatalog = []errorRule{
+	{
+		Code: ErrorCodeQuotaExhausted, Type: "billing_error", Status: http.StatusPaymentRequired,
+		Codes:   []string{"insufficient_quota", "billing_error", "billing_hard_limit_reached"},
+		Phrases: []string{"credit balance is too low", "check your plan and billing", "billing details", "quota has been exhausted"},
+	},
+	{
+		Code: ErrorCodeInvalidAPIKey, Type: "api_error", Status: http.StatusBadGateway,
+		Statuses: []int{400, 401, 403},
+		Codes:    []string{"invalid_api_key", "authentication_error", "api_key_invalid", "unauthenticated"},
+		Phrases:  []string{"api key not valid", "invalid api key", "incorrect api key", "invalid x-api-key"},
+	},
+	{Code: ErrorCodeInvalidAPIKey, Type: "api_error", Status: http.StatusBadGateway, Statuses: []int{401}},
+	{
+		Code: ErrorCodeContentFiltered, Type: "invalid_request_error", Status: http.StatusBadRequest,
+		Codes:   []string{"content_filter", "content_policy_violation", "responsibleaipolicyviolation"},
+		Phrases: []string{"content management policy", "content filter", "safety settings", "content policy"},
+	},
+	{
+		Code: ErrorCodeContextLength, Type: "invalid_request_error", Status: http.StatusBadRequest,
+		Codes:   []string{"context_length_exceeded"},
+		Phrases: []string{"prompt is too long", "maximum context length", "context window", "input token count"},
+	},
+	{
+		Code: ErrorCodeModelNotFound, Type: "not_found_error", Status: http.StatusNotFound,
+		Statuses: []int{400, 404},
+		Codes:    []string{"model_not_found"},
+		Phrases:  []string{"model not found", "no such model", "is not found for api version", "model does not exist", "does not exist or you do not have access"},
+	},
+	{Code: ErrorCodeModelNotFound, Type: "not_found_error", Status: http.StatusNotFound, Statuses: []int{404}, Phrases: []string{"model"}},
+	{
+		Code: ErrorCodeRateLimited, Type: "rate_limit_error", Status: http.StatusTooManyRequests,
+		Codes: []string{"rate_limit_exceeded", "rate_limit_error", "resource_exhausted"},
+	},
+	{Code: ErrorCodeRateLimited, Type: "rate_limit_error", Status: http.StatusTooManyRequests, Statuses: []int{429}},
+	{
+		Code: ErrorCodeOverloaded, Type: "overloaded_error", Status: http.StatusServiceUnavailable,
+		Codes: []string{"overloaded_error", "unavailable"},
+	},
+	{Code: ErrorCodeOverloaded, Type: "overloaded_error", Status: http.StatusServiceUnavailable, Statuses: []int{503, StatusOverloaded}},
+	{Code: ErrorCodeRequestTooLarge, Type: "request_too_large", Status: http.StatusRequestEntityTooLarge, Statuses: []int{413}},
+	{Code: ErrorCodePermissionDenied, Type: "api_error", Status: http.StatusBadGateway, Statuses: []int{403}},
+	{Code: ErrorCodeInvalidRequest, Type: "invalid_request_error", Status: http.StatusBadRequest, Statuses: []int{400, 422}},
+}
+
+// ClassifyError maps an upstream failure to a normalized error. Errors the
+// catalog does not know stay a 502 api_error with code upstream_error.
+func ClassifyError(err error) NormalizedError {
+	out := NormalizedError{Status: http.StatusBadGateway, Type: "api_error", Code: ErrorCodeUpstreamError}
+	if err == nil {
+		return out
+	}
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()):
+		out.Status, out.Code = http.StatusGatewayTimeout, ErrorCodeUpstreamTimeout
+		return out
+	case errors.Is(err, context.Canceled):
+		out.Code = ErrorCodeUpstreamCancelled
+		return out
+	}
+
+	status, body := 0, err.Error()
+	var httpErr *HTTPStatusError
+	var se StatusError
+	switch {
+	case errors.As(err, &httpErr):
+		status, body = httpErr.Status, httpErr.Body
+		out.Adapter, out.RetryAfter = httpErr.Adapter, httpErr.RetryAfter
+	case errors.As(err, &se):
+		status = se.UpstreamStatus()
+	default:
+		if m := upstreamStatusPattern.FindStringSubmatch(body); m != nil {
+			status, _ = strconv.Atoi(m[1])
+		}
+	}
+	out.UpstreamStatus = status
+	codes, message := providerErrorFields(body)
+	if len(codes) > 0 {
+		out.ProviderCode = codes[0]
+	}
+	for _, rule := range errorCatalog {
+		if rule.matches(status, codes, message) {
+			out.Status, out.Type, out.Code = rule.Status, rule.Type, rule.Code
+			return out
+		}
+	}
+	return out
+}
+
+func (r errorRule) matches(status int, codes []string, message string) bool {
+	if len(r.Statuses) > 0 && !containsInt(r.Statuses, status) {
+		return false
+	}
+	for _, code := range codes {
+		if containsString(r.Codes, code) {
+			return true
+		}
+	}
+	if status == 0 {
+		// Without an upstream status only the provider's own codes count.
+		return false
+	}
+	if len(r.Codes) == 0 && len(r.Phrases) == 0 {
+		return true
+	}
+	for _, phrase := range r.Phrases {
+		if strings.Contains(message, phrase) {
+			return true
+		}
+	}
+	return false
+}
+
+// providerError is the error object of Anthropic, OpenAI and Gemini
+// bodies: Anthropic sets type, OpenAI code and type, Gemini status and
+// details[].reason.
+type providerError struct {
+	Type    string          `json:"type"`
+	Code    json.RawMessage `json:"code"`
+	Status  string          `json:"status"`
+	Message string          `json:"message"`
+	Details []struct {
+		Reason string `json:"reason"`
+	} `json:"details"`
+}
+
+// providerErrorFields pulls the lower-cased error codes and message out of
+// a provider error body, either wrapped in {"error": ...} or bare as in
+// OpenAI stream chunks. Bodies that are not JSON are matched as text.
+func providerErrorFields(body string) ([]string, string) {
+	start := strings.Index(body, "{")
+	if start < 0 {
+		return nil, strings.ToLower(body)
+	}
+	var wrapped struct {
+		Error *providerError `json:"error"`
+	}
+	var perr providerError
+	switch {
+	case json.Unmarshal([]byte(body[start:]), &wrapped) == nil && wrapped.Error != nil:
+		perr = *wrapped.Error
+	case json.Unmarshal([]byte(body[start:]), &perr) == nil:
+	default:
+		return nil, strings.ToLower(body)
+	}
+	var codes []string
+	add := func(v string) {
+		if v = strings.ToLower(strings.TrimSpace(v)); v != "" {
+			codes = append(codes, v)
+		}
+	}
+	var code string
+	if json.Unmarshal(perr.Code, &code) == nil {
+		add(code)
+	}
+	add(perr.Type)
+	add(perr.Status)
+	for _, detail := range perr.Details {
+		add(detail.Reason)
+	}
+	message := perr.Message
+	if message == "" {
+		message = body
+	}
+	return codes, strings.ToLower(message)
+}
+
+func containsInt(list []int, v int) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(list []string, v string) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,467 @@
+package upstream
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"ccgateway/internal/orchestrator"
+)
+
+const (
+	defaultGRPCService      = "ccgateway.inference.v1.Inference"
+	defaultGRPCMethod       = "Generate"
+	defaultGRPCStreamMethod = "GenerateStream"
+)
+
+// GRPCAdapterConfig configures an adapter for a model server speaking the
+// Inference service of proto/inference/v1/inference.proto over gRPC.
+//
+// Calls go over HTTP/2 with TLS (base_url must be https://); plaintext h2c
+// is not supported. PoolSize connections are used round-robin, and the
+// caller's deadline is sent as grpc-timeout.
+type GRPCAdapterConfig struct {
+	Name               string            `json:"name"`
+	BaseURL            string            `json:"base_url"`
+	Service            string            `json:"grpc_service,omitempty"`
+	Method             string            `json:"grpc_method,omitempty"`
+	StreamMethod       string            `json:"grpc_stream_method,omitempty"`
+	APIKey             string            `json:"api_key,omitempty"`
+	APIKeyHeader       string            `json:"api_key_header,omitempty"`
+	Headers            map[string]string `json:"headers,omitempty"`
+	Model              string            `json:"model,omitempty"`
+	UserAgent          string            `json:"user_agent,omitempty"`
+	SupportsVision     *bool             `json:"supports_vision,omitempty"`
+	SupportsTools      *bool             `json:"supports_tools,omitempty"`
+	InsecureSkipVerify bool              `json:"insecure_skip_verify,omitempty"`
+	TimeoutMS          int               `json:"timeout_ms,omitempty"`
+	PoolSize           int               `json:"pool_size,omitempty"`
+	MaxConcurrency     int               `json:"max_concurrency,omitempty"`
+}
+
+type GRPCAdapter struct {
+	name               string
+	baseURL            string
+	service            string
+	method             string
+	streamMethod       string
+	apiKey             string
+	apiKeyHeader       string
+	headers            map[string]string
+	model              string
+	userAgent          string
+	supportsVision     *bool
+	supportsTools      *bool
+	insecureSkipVerify bool
+	timeout            time.Duration
+	maxConcurrency     int
+	clients            []*http.Client
+	next               uint64
+	ownsTransport      bool
+}
+
+// NewGRPCAdapter builds a grpc adapter. A nil client gets a pool of
+// PoolSize (default 1) HTTP/2 connections; a supplied client is used for
+// every call and left open on Close.
+func NewGRPCAdapter(cfg GRPCAdapterConfig, client *http.Client) (*GRPCAdapter, error) {
+	name := strings.TrimSpace(cfg.Name)
+	if name == "" {
+		return nil, fmt.Errorf("adapter name is required")
+	}
+	base, err := url.Parse(strings.TrimSpace(cfg.BaseURL))
+	if err != nil || base.Host == "" {
+		return nil, fmt.Errorf("invalid base_url for grpc adapter %q", name)
+	}
+	if base.Scheme != "https" {
+		return nil, fmt.Errorf("grpc adapter %q: base_url must use https; plaintext h2c is not supported", name)
+	}
+	service := strings.Trim(strings.TrimSpace(cfg.Service), "/")
+	if service == "" {
+		service = defaultGRPCService
+	}
+	method := strings.TrimSpace(cfg.Method)
+	if method == "" {
+		method = defaultGRPCMethod
+	}
+	streamMethod := strings.TrimSpace(cfg.StreamMethod)
+	if streamMethod == "" {
+		streamMethod = defaultGRPCStreamMethod
+	}
+
+	a := &GRPCAdapter{
+		name:               name,
+		baseURL:            strings.TrimRight(strings.TrimSpace(cfg.BaseURL), "/"),
+		service:            service,
+		method:             method,
+		streamMethod:       streamMethod,
+		apiKey:             cfg.APIKey,
+		apiKeyHeader:       strings.TrimSpace(cfg.APIKeyHeader),
+		headers:            copyHeaders(cfg.Headers),
+		model:              strings.TrimSpace(cfg.Model),
+		userAgent:          strings.TrimSpace(cfg.UserAgent),
+		supportsVision:     cloneBoolPtr(cfg.SupportsVision),
+		supportsTools:      cloneBoolPtr(cfg.SupportsTools),
+		insecureSkipVerify: cfg.InsecureSkipVerify,
+		timeout:            time.Duration(cfg.TimeoutMS) * time.Millisecond,
+		maxConcurrency:     cfg.MaxConcurrency,
+	}
+	if client != nil {
+		a.clients = []*http.Client{client}
+		return a, nil
+	}
+	size := cfg.PoolSize
+	if size <= 0 {
+		size = 1
+	}
+	for i := 0; i < size; i++ {
+		// Each transport holds its own HTTP/2 connection, so streams are
+		// spread over size connections instead of multiplexed on one.
+		a.clients = append(a.clients, &http.Client{Transport: &http.Transport{
+			Proxy:               http.ProxyFromEnvironment,
+			ForceAttemptHTTP2:   true,
+			TLSClientConfig:     &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify}, //nolint:gosec
+			IdleConnTimeout:     90 * time.Second,
+			TLSHandshakeTimeout: 10 * time.Second,
+		}})
+	}
+	a.ownsTransport = true
+	return a, nil
+}
+
+// Close releases the pooled connections once the adapter has been retired.
+func (a *GRPCAdapter) Close() error {
+	if a.ownsTransport {
+		for _, c := range a.clients {
+			c.CloseIdleConnections()
+		}
+	}
+	return nil
+}
+
+func (a *GRPCAdapter) Name() string {
+	return a.name
+}
+
+func (a *GRPCAdapter) ModelHint() string {
+	return a.model
+}
+
+func (a *GRPCAdapter) AdminSpec() AdapterSpec {
+	return AdapterSpec{
+		Name:             a.name,
+		Kind:             AdapterKindGRPC,
+		BaseURL:          a.baseURL,
+		GRPCService:      a.service,
+		GRPCMethod:       a.method,
+		GRPCStreamMethod: a.streamMethod,
+		APIKey:           a.apiKey,
+		APIKeyHeader:     a.apiKeyHeader,
+		Headers:          copyHeaders(a.headers),
+		Model:            a.model,
+		UserAgent:        a.userAgent,
+		SupportsVision:   cloneBoolPtr(a.supportsVision),
+		SupportsTools:    cloneBoolPtr(a.supportsTools),
+		TimeoutMS:        int(a.timeout / time.Millisecond),
+		PoolSize:         len(a.clients),
+		MaxConcurrency:   a.maxConcurrency,
+	}
+}
+
+func (a *GRPCAdapter) Complete(ctx context.Context, req orchestrator.Request) (orchestrator.Response, error) {
+	ctx, cancel := a.withTimeout(ctx)
+	defer cancel()
+	resp, err := a.call(ctx, a.method, req)
+	if err != nil {
+		return orchestrator.Response{}, err
+	}
+	defer resp.Body.Close()
+
+	msg, err := ReadGRPCFrame(resp.Body)
+	if err == io.EOF {
+		return orchestrator.Response{}, a.status(resp)
+	}
+	if err != nil {
+		return orchestrator.Response{}, fmt.Errorf("grpc adapter %s: %w", a.name, err)
+	}
+	var out GRPCGenerateResponse
+	if err := out.Unmarshal(msg); err != nil {
+		return orchestrator.Response{}, fmt.Errorf("grpc adapter %s: %w", a.name, err)
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if err := a.status(resp); err != nil {
+		return orchestrator.Response{}, err
+	}
+	return orchestrator.Response{
+		Model:      a.responseModel(out.Model, req.Model),
+		Blocks:     []orchestrator.AssistantBlock{{Type: "text", Text: out.Text}},
+		StopReason: grpcStopReason(out.StopReason),
+		Usage:      out.Usage.canonical(),
+	}, nil
+}
+
+func (a *GRPCAdapter) Stream(ctx context.Context, req orchestrator.Request) (<-chan orchestrator.StreamEvent, <-chan error) {
+	events := make(chan orchestrator.StreamEvent, 16)
+	errs := make(chan error, 1)
+	go func() {
+		defer close(events)
+		defer close(errs)
+		ctx, cancel := a.withTimeout(ctx)
+		defer cancel()
+		if err := a.stream(ctx, req, events); err != nil {
+			errs <- err
+		}
+	}()
+	return events, errs
+}
+
+func (a *GRPCAdapter) stream(ctx context.Context, req orchestrator.Request, out chan<- orchestrator.StreamEvent) error {
+	resp, err := a.call(ctx, a.streamMethod, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var stopReason string
+	var usage orchestrator.Usage
+	started := false
+	for {
+		msg, err := ReadGRPCFrame(resp.Body)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("grpc adapter %s: %w", a.name, err)
+		}
+		var chunk GRPCGenerateResponse
+		if err := chunk.Unmarshal(msg); err != nil {
+			return fmt.Errorf("grpc adapter %s: %w", a.name, err)
+		}
+		if !started {
+			started = true
+			out <- orchestrator.StreamEvent{Type: "message_start"}
+			out <- orchestrator.StreamEvent{Type: "content_block_start", Index: 0, Block: orchestrator.AssistantBlock{Type: "text"}}
+		}
+		if chunk.Text != "" {
+			out <- orchestrator.StreamEvent{Type: "content_block_delta", Index: 0, DeltaText: chunk.Text}
+		}
+		if chunk.StopReason != "" {
+			stopReason = chunk.StopReason
+		}
+		if chunk.Usage != nil {
+			usage = chunk.Usage.canonical()
+		}
+	}
+	if err := a.status(resp); err != nil {
+		return err
+	}
+	if !started {
+		return fmt.Errorf("adapter %s returned empty stream", a.name)
+	}
+	out <- orchestrator.StreamEvent{Type: "content_block_stop", Index: 0}
+	out <- orchestrator.StreamEvent{Type: "message_delta", StopReason: grpcStopReason(stopReason), Usage: usage}
+	out <- orchestrator.StreamEvent{Type: "message_stop"}
+	return nil
+}
+
+// call starts the RPC and checks the response headers. A non-OK status
+// sent without a body (a trailers-only response) is returned as an error.
+func (a *GRPCAdapter) call(ctx context.Context, method string, req orchestrator.Request) (*http.Response, error) {
+	body := AppendGRPCFrame(nil, a.buildRequest(req).Marshal())
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, a.baseURL+"/"+a.service+"/"+method, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("content-type", "application/grpc")
+	httpReq.Header.Set("te", "trailers")
+	if deadline, ok := ctx.Deadline(); ok {
+		httpReq.Header.Set("grpc-timeout", grpcTimeout(time.Until(deadline)))
+	}
+	if a.userAgent != "" {
+		httpReq.Header.Set("user-agent", a.userAgent)
+	}
+	for k, v := range a.headers {
+		if strings.TrimSpace(k) != "" && strings.TrimSpace(v) != "" {
+			httpReq.Header.Set(k, v)
+		}
+	}
+	if a.apiKey != "" {
+		if a.apiKeyHeader != "" {
+			httpReq.Header.Set(a.apiKeyHeader, a.apiKey)
+		} else if httpReq.Header.Get("authorization") == "" {
+			httpReq.Header.Set("authorization", "Bearer "+a.apiKey)
+		}
+	}
+
+	client := a.clients[int(atomic.AddUint64(&a.next, 1)%uint64(len(a.clients)))]
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		raw, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		resp.Body.Close()
+		return nil, newHTTPStatusError(a.name, resp, raw)
+	}
+	if resp.ProtoMajor != 2 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("grpc adapter %s: upstream answered over HTTP/%d.%d, want HTTP/2", a.name, resp.ProtoMajor, resp.ProtoMinor)
+	}
+	if code := resp.Header.Get("grpc-status"); code != "" && code != "0" {
+		resp.Body.Close()
+		return nil, a.statusError(code, resp.Header.Get("grpc-message"))
+	}
+	return resp, nil
+}
+
+// status returns the error carried by the response trailers, once the body
+// has been read to the end.
+func (a *GRPCAdapter) status(resp *http.Response) error {
+	code := resp.Trailer.Get("grpc-status")
+	if code == "" {
+		code = resp.Header.Get("grpc-status")
+	}
+	if code == "" {
+		return fmt.Errorf("grpc adapter %s: response ended without grpc-status", a.name)
+	}
+	if code == "0" {
+		return nil
+	}
+	message := resp.Trailer.Get("grpc-message")
+	if message == "" {
+		message = resp.Header.Get("grpc-message")
+	}
+	return a.statusError(code, message)
+}
+
+// statusError maps a gRPC status to the HTTP status it corresponds to, so
+// retries, cooldowns and error normalization treat it like any upstream.
+func (a *GRPCAdapter) statusError(code, message string) error {
+	if decoded, err := url.PathUnescape(message); err == nil {
+		message = decoded
+	}
+	status := http.StatusBadGateway
+	if n, err := strconv.Atoi(code); err == nil {
+		switch n {
+		case 3, 9, 11: // INVALID_ARGUMENT, FAILED_PRECONDITION, OUT_OF_RANGE
+			status = http.StatusBadRequest
+		case 4: // DEADLINE_EXCEEDED
+			status = http.StatusGatewayTimeout
+		case 5: // NOT_FOUND
+			status = http.StatusNotFound
+		case 7: // PERMISSION_DENIED
+			status = http.StatusForbidden
+		case 8: // RESOURCE_EXHAUSTED
+			status = http.StatusTooManyRequests
+		case 12: // UNIMPLEMENTED
+			status = http.StatusNotImplemented
+		case 14: // UNAVAILABLE
+			status = http.StatusServiceUnavailable
+		case 16: // UNAUTHENTICATED
+			status = http.StatusUnauthorized
+		}
+	}
+	return &HTTPStatusError{Adapter: a.name, Status: status, Body: fmt.Sprintf("grpc status %s: %s", code, message)}
+}
+
+func (a *GRPCAdapter) buildRequest(req orchestrator.Request) GRPCGenerateRequest {
+	model := req.Model
+	if a.model != "" {
+		model = a.model
+	}
+	out := GRPCGenerateRequest{
+		Model:         model,
+		System:        renderSystemToString(req.System),
+		MaxTokens:     int32(req.MaxTokens),
+		StopSequences: stringListFromMetadata(req.Metadata, "stop_sequences"),
+	}
+	for _, m := range req.Messages {
+		out.Messages = append(out.Messages, GRPCMessage{Role: m.Role, Content: renderSystemToString(m.Content)})
+	}
+	if v, ok := floatFromAny(req.Metadata["temperature"]); ok {
+		out.Temperature = &v
+	}
+	if v, ok := floatFromAny(req.Metadata["top_p"]); ok {
+		out.TopP = &v
+	}
+	return out
+}
+
+func (a *GRPCAdapter) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if a.timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, a.timeout)
+}
+
+func (a *GRPCAdapter) responseModel(upstream, requested string) string {
+	if upstream != "" {
+		return upstream
+	}
+	if a.model != "" {
+		return a.model
+	}
+	return requested
+}
+
+func (u *GRPCUsage) canonical() orchestrator.Usage {
+	if u == nil {
+		return orchestrator.Usage{}
+	}
+	return orchestrator.Usage{InputTokens: int(u.InputTokens), OutputTokens: int(u.OutputTokens)}
+}
+
+func floatFromAny(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(n), 64)
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+func grpcStopReason(reason string) string {
+	switch reason {
+	case "max_tokens", "stop_sequence":
+		return reason
+	default:
+		return "end_turn"
+	}
+}
+
+// grpcTimeout encodes d as a grpc-timeout header value: at most eight
+// digits and a unit.
+func grpcTimeout(d time.Duration) string {
+	if d <= 0 {
+		return "1n"
+	}
+	for _, unit := range []struct {
+		suffix string
+		size   time.Duration
+	}{{"n", time.Nanosecond}, {"u", time.Microsecond}, {"m", time.Millisecond}, {"S", time.Second}, {"M", time.Minute}} {
+		if v := (d + unit.size - 1) / unit.size; v < 1e8 {
+			return strconv.FormatInt(int64(v), 10) + unit.suffix
+		}
+	}
+	return strconv.FormatInt(int64(d/time.Hour)+1, 10) + "H"
+}
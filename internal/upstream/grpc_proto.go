@@ -0,0 +1,269 @@
+package upstream
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Messages of proto/inference/v1/inference.proto with a hand-written
+// protobuf encoding, so the grpc adapter needs no generated code.
+
+// GRPCMessage is inference.v1.Message.
+type GRPCMessage struct {
+	Role    string
+	Content string
+}
+
+// GRPCGenerateRequest is inference.v1.GenerateRequest.
+type GRPCGenerateRequest struct {
+	Model         string
+	System        string
+	Messages      []GRPCMessage
+	MaxTokens     int32
+	Temperature   *float64
+	TopP          *float64
+	StopSequences []string
+}
+
+// GRPCUsage is inference.v1.Usage.
+type GRPCUsage struct {
+	InputTokens  int32
+	OutputTokens int32
+}
+
+// GRPCGenerateResponse is inference.v1.GenerateResponse.
+type GRPCGenerateResponse struct {
+	Model      string
+	Text       string
+	StopReason string
+	Usage      *GRPCUsage
+}
+
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errProtoTruncated = errors.New("protobuf: truncated message")
+
+type protoBuf []byte
+
+func (b protoBuf) tag(field, wire int) protoBuf {
+	return binary.AppendUvarint(b, uint64(field<<3|wire))
+}
+
+func (b protoBuf) str(field int, s string) protoBuf {
+	if s == "" {
+		return b
+	}
+	b = b.tag(field, wireBytes)
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+func (b protoBuf) msg(field int, m []byte) protoBuf {
+	b = b.tag(field, wireBytes)
+	b = binary.AppendUvarint(b, uint64(len(m)))
+	return append(b, m...)
+}
+
+func (b protoBuf) int32(field int, v int32) protoBuf {
+	if v == 0 {
+		return b
+	}
+	return binary.AppendUvarint(b.tag(field, wireVarint), uint64(int64(v)))
+}
+
+func (b protoBuf) double(field int, v float64) protoBuf {
+	return binary.LittleEndian.AppendUint64(b.tag(field, wireFixed64), math.Float64bits(v))
+}
+
+// protoFields walks the fields of an encoded message. fn gets the varint
+// or fixed value in num, or the bytes of length-delimited fields in raw.
+func protoFields(data []byte, fn func(field, wire int, num uint64, raw []byte) error) error {
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errProtoTruncated
+		}
+		data = data[n:]
+		field, wire := int(key>>3), int(key&7)
+		var num uint64
+		var raw []byte
+		switch wire {
+		case wireVarint:
+			num, n = binary.Uvarint(data)
+			if n <= 0 {
+				return errProtoTruncated
+			}
+			data = data[n:]
+		case wireFixed64:
+			if len(data) < 8 {
+				return errProtoTruncated
+			}
+			num, data = binary.LittleEndian.Uint64(data), data[8:]
+		case wireFixed32:
+			if len(data) < 4 {
+				return errProtoTruncated
+			}
+			num, data = uint64(binary.LittleEndian.Uint32(data)), data[4:]
+		case wireBytes:
+			size, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < size {
+				return errProtoTruncated
+			}
+			raw, data = data[n:n+int(size)], data[n+int(size):]
+		default:
+			return fmt.Errorf("protobuf: unsupported wire type %d", wire)
+		}
+		if err := fn(field, wire, num, raw); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m GRPCMessage) Marshal() []byte {
+	return protoBuf(nil).str(1, m.Role).str(2, m.Content)
+}
+
+func (m *GRPCMessage) Unmarshal(data []byte) error {
+	return protoFields(data, func(field, wire int, _ uint64, raw []byte) error {
+		switch {
+		case field == 1 && wire == wireBytes:
+			m.Role = string(raw)
+		case field == 2 && wire == wireBytes:
+			m.Content = string(raw)
+		}
+		return nil
+	})
+}
+
+func (m GRPCGenerateRequest) Marshal() []byte {
+	b := protoBuf(nil).str(1, m.Model).str(2, m.System)
+	for _, msg := range m.Messages {
+		b = b.msg(3, msg.Marshal())
+	}
+	b = b.int32(4, m.MaxTokens)
+	if m.Temperature != nil {
+		b = b.double(5, *m.Temperature)
+	}
+	if m.TopP != nil {
+		b = b.double(6, *m.TopP)
+	}
+	for _, stop := range m.StopSequences {
+		b = b.tag(7, wireBytes)
+		b = binary.AppendUvarint(b, uint64(len(stop)))
+		b = append(b, stop...)
+	}
+	return b
+}
+
+func (m *GRPCGenerateRequest) Unmarshal(data []byte) error {
+	return protoFields(data, func(field, wire int, num uint64, raw []byte) error {
+		switch {
+		case field == 1 && wire == wireBytes:
+			m.Model = string(raw)
+		case field == 2 && wire == wireBytes:
+			m.System = string(raw)
+		case field == 3 && wire == wireBytes:
+			var msg GRPCMessage
+			if err := msg.Unmarshal(raw); err != nil {
+				return err
+			}
+			m.Messages = append(m.Messages, msg)
+		case field == 4 && wire == wireVarint:
+			m.MaxTokens = int32(num)
+		case field == 5 && wire == wireFixed64:
+			v := math.Float64frombits(num)
+			m.Temperature = &v
+		case field == 6 && wire == wireFixed64:
+			v := math.Float64frombits(num)
+			m.TopP = &v
+		case field == 7 && wire == wireBytes:
+			m.StopSequences = append(m.StopSequences, string(raw))
+		}
+		return nil
+	})
+}
+
+func (m GRPCUsage) Marshal() []byte {
+	return protoBuf(nil).int32(1, m.InputTokens).int32(2, m.OutputTokens)
+}
+
+func (m *GRPCUsage) Unmarshal(data []byte) error {
+	return protoFields(data, func(field, wire int, num uint64, _ []byte) error {
+		switch {
+		case field == 1 && wire == wireVarint:
+			m.InputTokens = int32(num)
+		case field == 2 && wire == wireVarint:
+			m.OutputTokens = int32(num)
+		}
+		return nil
+	})
+}
+
+func (m GRPCGenerateResponse) Marshal() []byte {
+	b := protoBuf(nil).str(1, m.Model).str(2, m.Text).str(3, m.StopReason)
+	if m.Usage != nil {
+		b = b.msg(4, m.Usage.Marshal())
+	}
+	return b
+}
+
+func (m *GRPCGenerateResponse) Unmarshal(data []byte) error {
+	return protoFields(data, func(field, wire int, _ uint64, raw []byte) error {
+		switch {
+		case field == 1 && wire == wireBytes:
+			m.Model = string(raw)
+		case field == 2 && wire == wireBytes:
+			m.Text = string(raw)
+		case field == 3 && wire == wireBytes:
+			m.StopReason = string(raw)
+		case field == 4 && wire == wireBytes:
+			m.Usage = &GRPCUsage{}
+			return m.Usage.Unmarshal(raw)
+		}
+		return nil
+	})
+}
+
+// maxGRPCMessageBytes bounds one received gRPC message.
+const maxGRPCMessageBytes = 16 << 20
+
+// AppendGRPCFrame appends msg to b as a length-prefixed, uncompressed gRPC
+// message.
+func AppendGRPCFrame(b, msg []byte) []byte {
+	b = append(b, 0)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(msg)))
+	return append(b, msg...)
+}
+
+// ReadGRPCFrame reads one length-prefixed gRPC message from r. It returns
+// io.EOF at a clean end of stream.
+func ReadGRPCFrame(r io.Reader) ([]byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, errProtoTruncated
+		}
+		return nil, err
+	}
+	if header[0] != 0 {
+		return nil, errors.New("grpc: compressed messages are not supported")
+	}
+	size := binary.BigEndian.Uint32(header[1:])
+	if size > maxGRPCMessageBytes {
+		return nil, fmt.Errorf("grpc: message of %d bytes exceeds the %d byte limit", size, maxGRPCMessageBytes)
+	}
+	msg := make([]byte, size)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, errProtoTruncated
+	}
+	return msg, nil
+}
@@ -0,0 +1,126 @@
+package upstream
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// HealthCheckSpec describes a cheap provider endpoint (for example /health or
+// /v1/models) that the probe runner calls instead of the generic chat probe.
+type HealthCheckSpec struct {
+	// Method defaults to GET.
+	Method string `json:"method,omitempty"`
+	// Path is appended to the adapter base_url. Absolute URLs are used as is.
+	Path string `json:"path"`
+	// ExpectStatus is the required status code. Zero accepts any 2xx.
+	ExpectStatus int `json:"expect_status,omitempty"`
+	// ExpectBodyContains, when set, must appear in the response body.
+	ExpectBodyContains string `json:"expect_body_contains,omitempty"`
+	// IntervalMS overrides the probe interval for this adapter.
+	IntervalMS int `json:"interval_ms,omitempty"`
+	// TimeoutMS overrides the probe timeout for this adapter.
+	TimeoutMS int `json:"timeout_ms,omitempty"`
+}
+
+// HealthCheckResult is the outcome of a single custom health check.
+type HealthCheckResult struct {
+	StatusCode int
+	Latency    time.Duration
+}
+
+func (s HealthCheckSpec) Interval() time.Duration {
+	return time.Duration(s.IntervalMS) * time.Millisecond
+}
+
+func (s HealthCheckSpec) Timeout() time.Duration {
+	return time.Duration(s.TimeoutMS) * time.Millisecond
+}
+
+func sanitizeHealthCheck(in *HealthCheckSpec) *HealthCheckSpec {
+	if in == nil {
+		return nil
+	}
+	out := *in
+	out.Method = strings.ToUpper(strings.TrimSpace(in.Method))
+	if out.Method == "" {
+		out.Method = http.MethodGet
+	}
+	out.Path = strings.TrimSpace(in.Path)
+	if out.IntervalMS < 0 {
+		out.IntervalMS = 0
+	}
+	if out.TimeoutMS < 0 {
+		out.TimeoutMS = 0
+	}
+	return &out
+}
+
+func validateHealthCheck(name string, hc *HealthCheckSpec) error {
+	if hc == nil {
+		return nil
+	}
+	if hc.Path == "" {
+		return fmt.Errorf("adapter %q health_check.path is required", name)
+	}
+	switch hc.Method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodOptions:
+	default:
+		return fmt.Errorf("adapter %q health_check.method %q is not supported", name, hc.Method)
+	}
+	if hc.ExpectStatus != 0 && (hc.ExpectStatus < 100 || hc.ExpectStatus > 599) {
+		return fmt.Errorf("adapter %q health_check.expect_status %d is invalid", name, hc.ExpectStatus)
+	}
+	return nil
+}
+
+// HealthCheck returns the adapter's custom health check, if any.
+func (a *HTTPAdapter) HealthCheck() *HealthCheckSpec {
+	return sanitizeHealthCheck(a.healthCheck)
+}
+
+// CheckHealth runs the custom health check with the adapter's credentials.
+func (a *HTTPAdapter) CheckHealth(ctx context.Context) (HealthCheckResult, error) {
+	hc := a.HealthCheck()
+	if hc == nil {
+		return HealthCheckResult{}, fmt.Errorf("adapter %s has no health check", a.name)
+	}
+	target := hc.Path
+	if !strings.HasPrefix(target, "http://") && !strings.HasPrefix(target, "https://") {
+		if !strings.HasPrefix(target, "/") {
+			target = "/" + target
+		}
+		target = a.baseURL + target
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, hc.Method, target, nil)
+	if err != nil {
+		return HealthCheckResult{}, err
+	}
+	a.applyRequestHeaders(httpReq, nil)
+
+	started := time.Now()
+	resp, err := a.client.Do(httpReq)
+	if err != nil {
+		return HealthCheckResult{Latency: time.Since(started)}, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	result := HealthCheckResult{StatusCode: resp.StatusCode, Latency: time.Since(started)}
+	if err != nil {
+		return result, err
+	}
+	if hc.ExpectStatus != 0 {
+		if resp.StatusCode != hc.ExpectStatus {
+			return result, fmt.Errorf("adapter %s health check status %d, want %d", a.name, resp.StatusCode, hc.ExpectStatus)
+		}
+	} else if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return result, fmt.Errorf("adapter %s health check status %d", a.name, resp.StatusCode)
+	}
+	if hc.ExpectBodyContains != "" && !strings.Contains(string(body), hc.ExpectBodyContains) {
+		return result, fmt.Errorf("adapter %s health check body does not contain %q", a.name, hc.ExpectBodyContains)
+	}
+	return result, nil
+}
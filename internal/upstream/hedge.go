@@ -0,0 +1,341 @@
+package upstream
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"ccgateway/internal/orchestrator"
+)
+
+// HedgeOutcome reports how a hedged request went. Fired is set when the
+// primary had not answered within AfterMS and the secondary was started;
+// FirstToken is the time from the start of the primary to the winner's
+// first event, or its response for non-streaming calls. Error is set when
+// both adapters failed.
+type HedgeOutcome struct {
+	Primary      string
+	Secondary    string
+	Winner       string
+	Fired        bool
+	Stream       bool
+	AfterMS      int
+	FirstToken   time.Duration
+	PrimaryError string
+	Error        string
+}
+
+type hedgeObserverKey struct{}
+
+// WithHedgeObserver returns a context whose hedged requests report their
+// outcome to fn.
+func WithHedgeObserver(ctx context.Context, fn func(HedgeOutcome)) context.Context {
+	if fn == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, hedgeObserverKey{}, fn)
+}
+
+func observeHedge(ctx context.Context, outcome HedgeOutcome) {
+	if fn, ok := ctx.Value(hedgeObserverKey{}).(func(HedgeOutcome)); ok {
+		fn(outcome)
+	}
+}
+
+// hedgeDelay returns the "hedge_after_ms" delay of req, or zero when the
+// request is not hedged or has no second candidate to hedge to.
+func hedgeDelay(req orchestrator.Request, candidates []string) time.Duration {
+	if len(candidates) < 2 || req.Metadata == nil {
+		return 0
+	}
+	ms, ok := intFromAny(req.Metadata["hedge_after_ms"])
+	if !ok || ms <= 0 {
+		return 0
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// runHedged races the first two candidates: the second starts once the
+// first has not answered within delay, or as soon as it fails. The first
+// success wins and the other call is canceled. When both fail the
+// remaining candidates are tried in order.
+func (s *RouterService) runHedged(
+	ctx context.Context,
+	req orchestrator.Request,
+	candidates []string,
+	retries int,
+	timeout time.Duration,
+	delay time.Duration,
+) ([]candidateResult, error) {
+	outcome := HedgeOutcome{Primary: candidates[0], Secondary: candidates[1], AfterMS: int(delay.Milliseconds())}
+	started := time.Now()
+	out := make(chan candidateResult, 2)
+	var cancels []context.CancelFunc
+	defer func() {
+		for _, cancel := range cancels {
+			cancel()
+		}
+	}()
+	launch := func(order int) {
+		legCtx, cancel := context.WithCancel(ctx)
+		cancels = append(cancels, cancel)
+		go func() {
+			out <- s.runCandidate(legCtx, req, candidates[order], order, retries, timeout)
+		}()
+	}
+
+	launch(0)
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	var lastErr error
+	for pending := 1; pending > 0; {
+		select {
+		case <-timer.C:
+			if len(cancels) == 1 {
+				outcome.Fired = true
+				launch(1)
+				pending++
+			}
+		case r := <-out:
+			pending--
+			if r.err == nil {
+				outcome.Winner = r.candidateName
+				outcome.FirstToken = time.Since(started)
+				observeHedge(ctx, outcome)
+				return []candidateResult{r}, nil
+			}
+			lastErr = r.err
+			if r.order == 0 {
+				outcome.PrimaryError = r.err.Error()
+			}
+			if len(cancels) == 1 {
+				launch(1)
+				pending++
+			}
+		}
+	}
+	outcome.Error = lastErr.Error()
+	observeHedge(ctx, outcome)
+
+	for i := 2; i < len(candidates); i++ {
+		r := s.runCandidate(ctx, req, candidates[i], i, retries, timeout)
+		if r.err == nil {
+			return []candidateResult{r}, nil
+		}
+		lastErr = r.err
+	}
+	return nil, lastErr
+}
+
+// hedgeLeg is one adapter stream of a hedged request. Its goroutine owns
+// the stream until it reports the first event; whoever receives that
+// report must finish the leg.
+type hedgeLeg struct {
+	name    string
+	order   int
+	cancel  context.CancelFunc
+	release func()
+	events  <-chan orchestrator.StreamEvent
+	errs    <-chan error
+	started time.Time
+	meter   *streamMeter
+}
+
+type hedgeReport struct {
+	leg   *hedgeLeg
+	first orchestrator.StreamEvent
+	err   error
+}
+
+func (l *hedgeLeg) run(ctx context.Context, s *RouterService, managed *managedAdapter, req orchestrator.Request, reports chan<- hedgeReport) {
+	release, err := managed.admit(ctx, s.admissionFor(req))
+	if err != nil {
+		l.cancel()
+		reports <- hedgeReport{leg: l, err: fmt.Errorf("adapter %q: %w", l.name, err)}
+		return
+	}
+	l.release = release
+	l.started = time.Now()
+	l.meter = newStreamMeter()
+	l.events, l.errs = managed.adapter.(StreamingAdapter).Stream(ctx, req)
+	first, err := l.awaitFirst()
+	if err != nil {
+		_ = l.finish(nil)
+		reports <- hedgeReport{leg: l, err: err}
+		return
+	}
+	reports <- hedgeReport{leg: l, first: first}
+}
+
+// awaitFirst waits for the first event that is not a queue ping.
+func (l *hedgeLeg) awaitFirst() (orchestrator.StreamEvent, error) {
+	evCh, errCh := l.events, l.errs
+	for evCh != nil || errCh != nil {
+		select {
+		case ev, ok := <-evCh:
+			if !ok {
+				evCh = nil
+				continue
+			}
+			if ev.Type == "ping" {
+				continue
+			}
+			l.meter.observe(ev)
+			return ev, nil
+		case err, ok := <-errCh:
+			if !ok {
+				errCh = nil
+				continue
+			}
+			if err != nil {
+				return orchestrator.StreamEvent{}, err
+			}
+		}
+	}
+	return orchestrator.StreamEvent{}, fmt.Errorf("stream closed without events from adapter %q", l.name)
+}
+
+// finish forwards the rest of the stream to sink, or drops it when sink is
+// nil, then frees the adapter and returns the stream's error.
+func (l *hedgeLeg) finish(sink chan<- orchestrator.StreamEvent) error {
+	defer l.cancel()
+	defer l.release()
+	var streamErr error
+	evCh, errCh := l.events, l.errs
+	for evCh != nil || errCh != nil {
+		select {
+		case ev, ok := <-evCh:
+			if !ok {
+				evCh = nil
+				continue
+			}
+			if sink != nil {
+				l.meter.observe(ev)
+				sink <- ev
+			}
+		case err, ok := <-errCh:
+			if !ok {
+				errCh = nil
+				continue
+			}
+			if err != nil {
+				streamErr = err
+			}
+		}
+	}
+	return streamErr
+}
+
+// streamHedged is the streaming form of runHedged for the first two
+// candidates. handled is false when they cannot be hedged or both failed
+// before producing an event; the caller then goes on with the rest of
+// the route.
+func (s *RouterService) streamHedged(
+	ctx context.Context,
+	req orchestrator.Request,
+	candidates []string,
+	delay time.Duration,
+	events chan<- orchestrator.StreamEvent,
+) (servedBy string, handled bool, err error) {
+	managed := make([]*managedAdapter, 2)
+	s.mu.RLock()
+	for i := range managed {
+		managed[i] = s.adapters[candidates[i]]
+	}
+	s.mu.RUnlock()
+	for _, m := range managed {
+		if m == nil {
+			return "", false, nil
+		}
+		if _, ok := m.adapter.(StreamingAdapter); !ok {
+			return "", false, nil
+		}
+	}
+
+	outcome := HedgeOutcome{Primary: candidates[0], Secondary: candidates[1], Stream: true, AfterMS: int(delay.Milliseconds())}
+	started := time.Now()
+	reports := make(chan hedgeReport, 2)
+	var legs []*hedgeLeg
+	launch := func() {
+		order := len(legs)
+		legCtx, cancel := context.WithCancel(ctx)
+		leg := &hedgeLeg{name: candidates[order], order: order, cancel: cancel}
+		legs = append(legs, leg)
+		go leg.run(legCtx, s, managed[order], req, reports)
+	}
+	// abandon cancels every leg but keep and finishes the ones still
+	// running once they report, so their adapters are freed.
+	abandon := func(pending int, keep *hedgeLeg) {
+		for _, leg := range legs {
+			if leg != keep {
+				leg.cancel()
+			}
+		}
+		go func() {
+			for i := 0; i < pending; i++ {
+				if r := <-reports; r.err == nil {
+					_ = r.leg.finish(nil)
+				}
+			}
+		}()
+	}
+
+	launch()
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	var lastErr error
+	for pending := 1; pending > 0; {
+		select {
+		case <-timer.C:
+			if len(legs) == 1 {
+				outcome.Fired = true
+				launch()
+				pending++
+			}
+		case r := <-reports:
+			pending--
+			if r.err != nil {
+				lastErr = r.err
+				if ctx.Err() == nil {
+					s.observeAttempt(r.leg.name, r.err)
+					if s.selector != nil {
+						s.selector.ObserveFailure(r.leg.name, req.Model, r.err)
+					}
+				}
+				if r.leg.order == 0 {
+					outcome.PrimaryError = r.err.Error()
+				}
+				if len(legs) == 1 {
+					launch()
+					pending++
+				}
+				continue
+			}
+			winner := r.leg
+			abandon(pending, winner)
+			outcome.Winner = winner.name
+			outcome.FirstToken = time.Since(started)
+			observeHedge(ctx, outcome)
+			s.observeAttempt(winner.name, nil)
+			events <- r.first
+			err := winner.finish(events)
+			if s.selector != nil {
+				if err != nil {
+					s.selector.ObserveFailure(winner.name, req.Model, err)
+				} else {
+					s.selector.ObserveSuccess(winner.name, req.Model, time.Since(winner.started))
+				}
+			}
+			if err == nil {
+				s.recordStreamLatency(winner.name, req.Model, winner.meter)
+			}
+			return winner.name, true, err
+		case <-ctx.Done():
+			abandon(pending, nil)
+			return "", true, ctx.Err()
+		}
+	}
+	outcome.Error = lastErr.Error()
+	observeHedge(ctx, outcome)
+	return "", false, lastErr
+}
@@ -4,7 +4,6 @@ import (
 	"bufio"
 	"bytes"
 	"context"
-	"crypto/tls"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
@@ -27,6 +26,7 @@ const (
 	AdapterKindGemini    AdapterKind = "gemini"
 	AdapterKindCanonical AdapterKind = "canonical"
 	AdapterKindScript    AdapterKind = "script"
+	AdapterKindGRPC      AdapterKind = "grpc"
 )
 
 type HTTPAdapterConfig struct {
@@ -44,6 +44,9 @@ type HTTPAdapterConfig struct {
 	ForceStream        bool              `json:"force_stream,omitempty"`
 	StreamOptions      map[string]any    `json:"stream_options,omitempty"`
 	InsecureSkipVerify bool              `json:"insecure_skip_verify,omitempty"`
+	HealthCheck        *HealthCheckSpec  `json:"health_check,omitempty"`
+	MaxConcurrency     int               `json:"max_concurrency,omitempty"`
+	Transport          *TransportSpec    `json:"transport,omitempty"`
 }
 
 type HTTPAdapter struct {
@@ -60,7 +63,12 @@ type HTTPAdapter struct {
 	supportsTools  *bool
 	forceStream    bool
 	streamOptions  map[string]any
+	healthCheck    *HealthCheckSpec
+	maxConcurrency int
+	transport      *TransportSpec
 	client         *http.Client
+	ownsTransport  bool
+	conns          *connMetrics
 }
 
 func NewHTTPAdapter(cfg HTTPAdapterConfig, client *http.Client) (*HTTPAdapter, error) {
@@ -76,6 +84,10 @@ func NewHTTPAdapter(cfg HTTPAdapterConfig, client *http.Client) (*HTTPAdapter, e
 	if _, err := url.Parse(cfg.BaseURL); err != nil {
 		return nil, fmt.Errorf("invalid base_url for adapter %q: %w", cfg.Name, err)
 	}
+	healthCheck := sanitizeHealthCheck(cfg.HealthCheck)
+	if err := validateHealthCheck(cfg.Name, healthCheck); err != nil {
+		return nil, err
+	}
 
 	ep := strings.TrimSpace(cfg.Endpoint)
 	if ep == "" {
@@ -93,16 +105,18 @@ func NewHTTPAdapter(cfg HTTPAdapterConfig, client *http.Client) (*HTTPAdapter, e
 		}
 	}
 
+	transport := sanitizeTransportSpec(cfg.Transport)
+	conns := &connMetrics{}
+	ownsTransport := false
 	if client == nil {
-		if cfg.InsecureSkipVerify {
-			client = &http.Client{
-				Transport: &http.Transport{
-					TLSClientConfig: &tls.Config{InsecureSkipVerify: true}, //nolint:gosec
-				},
-			}
-		} else {
-			client = http.DefaultClient
+		// A private transport lets a retired adapter close its pooled
+		// connections without touching other adapters.
+		t, err := newAdapterTransport(cfg.Name, transport, cfg.InsecureSkipVerify, conns)
+		if err != nil {
+			return nil, err
 		}
+		client = &http.Client{Transport: t}
+		ownsTransport = true
 	}
 
 	return &HTTPAdapter{
@@ -119,10 +133,24 @@ func NewHTTPAdapter(cfg HTTPAdapterConfig, client *http.Client) (*HTTPAdapter, e
 		supportsTools:  cloneBoolPtr(cfg.SupportsTools),
 		forceStream:    cfg.ForceStream,
 		streamOptions:  copyAnyMap(cfg.StreamOptions),
+		healthCheck:    healthCheck,
+		maxConcurrency: cfg.MaxConcurrency,
+		transport:      transport,
 		client:         client,
+		ownsTransport:  ownsTransport,
+		conns:          conns,
 	}, nil
 }
 
+// Close releases idle pooled connections once the adapter has been retired.
+// Shared clients supplied by the caller are left alone.
+func (a *HTTPAdapter) Close() error {
+	if a.ownsTransport {
+		a.client.CloseIdleConnections()
+	}
+	return nil
+}
+
 func (a *HTTPAdapter) Name() string {
 	return a.name
 }
@@ -147,17 +175,24 @@ func (a *HTTPAdapter) AdminSpec() AdapterSpec {
 		ForceStream:        a.forceStream,
 		StreamOptions:      copyAnyMap(a.streamOptions),
 		InsecureSkipVerify: false,
+		HealthCheck:        sanitizeHealthCheck(a.healthCheck),
+		MaxConcurrency:     a.maxConcurrency,
+		Transport:          cloneTransportSpec(a.transport),
 	}
 }
 
 func (a *HTTPAdapter) Complete(ctx context.Context, req orchestrator.Request) (orchestrator.Response, error) {
 	switch a.kind {
 	case AdapterKindOpenAI:
-		return a.completeOpenAI(ctx, req)
+		resp, err := a.completeOpenAI(ctx, req)
+		resp.Blocks = restoreBuiltinToolInputs(req.Tools, resp.Blocks)
+		return resp, err
 	case AdapterKindAnthropic:
 		return a.completeAnthropic(ctx, req)
 	case AdapterKindGemini:
-		return a.completeGemini(ctx, req)
+		resp, err := a.completeGemini(ctx, req)
+		resp.Blocks = restoreBuiltinToolInputs(req.Tools, resp.Blocks)
+		return resp, err
 	case AdapterKindCanonical:
 		return a.completeCanonical(ctx, req)
 	default:
@@ -225,6 +260,12 @@ func (a *HTTPAdapter) completeOpenAI(ctx context.Context, req orchestrator.Reque
 	if v, ok := req.Metadata["top_p"]; ok {
 		payload["top_p"] = v
 	}
+	if stops := stringListFromMetadata(req.Metadata, "stop_sequences"); len(stops) > 0 {
+		payload["stop"] = stops
+	}
+	if format, ok := toOpenAIResponseFormat(req.ResponseFormat); ok {
+		payload["response_format"] = format
+	}
 
 	useStream := a.forceStream || boolFromAny(req.Metadata["upstream_force_stream"])
 	if useStream {
@@ -297,8 +338,16 @@ func (a *HTTPAdapter) completeAnthropic(ctx context.Context, req orchestrator.Re
 	if v, ok := req.Metadata["top_p"]; ok {
 		payload["top_p"] = v
 	}
+	if stops := stringListFromMetadata(req.Metadata, "stop_sequences"); len(stops) > 0 {
+		payload["stop_sequences"] = stops
+	}
+	headers := req.Headers
+	if format, ok := toAnthropicOutputFormat(req.ResponseFormat); ok {
+		payload["output_format"] = format
+		headers = withAnthropicBeta(headers, anthropicStructuredOutputsBeta)
+	}
 
-	raw, err := a.doJSON(ctx, payload, req.Headers, model)
+	raw, err := a.doJSON(ctx, payload, headers, model)
 	if err != nil {
 		return orchestrator.Response{}, err
 	}
@@ -383,6 +432,10 @@ func (a *HTTPAdapter) completeGemini(ctx context.Context, req orchestrator.Reque
 	if v, ok := req.Metadata["top_p"]; ok {
 		payload["generationConfig"].(map[string]any)["topP"] = v
 	}
+	if stops := stringListFromMetadata(req.Metadata, "stop_sequences"); len(stops) > 0 {
+		payload["generationConfig"].(map[string]any)["stopSequences"] = stops
+	}
+	applyGeminiResponseFormat(payload["generationConfig"].(map[string]any), req.ResponseFormat)
 	if len(req.Tools) > 0 {
 		payload["tools"] = []map[string]any{
 			{
@@ -463,6 +516,9 @@ func (a *HTTPAdapter) completeCanonical(ctx context.Context, req orchestrator.Re
 		"tools":      req.Tools,
 		"metadata":   req.Metadata,
 	}
+	if req.ResponseFormat != nil {
+		payload["response_format"] = req.ResponseFormat
+	}
 	raw, err := a.doJSON(ctx, payload, req.Headers, req.Model)
 	if err != nil {
 		return orchestrator.Response{}, err
@@ -516,12 +572,20 @@ func (a *HTTPAdapter) streamAnthropic(ctx context.Context, req orchestrator.Requ
 	if v, ok := req.Metadata["top_p"]; ok {
 		payload["top_p"] = v
 	}
+	if stops := stringListFromMetadata(req.Metadata, "stop_sequences"); len(stops) > 0 {
+		payload["stop_sequences"] = stops
+	}
+	headers := req.Headers
+	if format, ok := toAnthropicOutputFormat(req.ResponseFormat); ok {
+		payload["output_format"] = format
+		headers = withAnthropicBeta(headers, anthropicStructuredOutputsBeta)
+	}
 
-	httpReq, err := a.newJSONRequest(ctx, payload, req.Headers, model)
+	httpReq, err := a.newJSONRequest(ctx, payload, headers, model)
 	if err != nil {
 		return err
 	}
-	resp, err := a.client.Do(httpReq)
+	resp, err := a.do(httpReq)
 	if err != nil {
 		return err
 	}
@@ -529,7 +593,7 @@ func (a *HTTPAdapter) streamAnthropic(ctx context.Context, req orchestrator.Requ
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		body, _ := io.ReadAll(io.LimitReader(resp.Body, 8<<20))
-		return fmt.Errorf("adapter %s upstream status %d: %s", a.name, resp.StatusCode, strings.TrimSpace(string(body)))
+		return newHTTPStatusError(a.name, resp, body)
 	}
 
 	return readSSE(resp.Body, func(eventName string, data []byte) error {
@@ -587,6 +651,12 @@ func (a *HTTPAdapter) streamOpenAI(ctx context.Context, req orchestrator.Request
 	if v, ok := req.Metadata["top_p"]; ok {
 		payload["top_p"] = v
 	}
+	if stops := stringListFromMetadata(req.Metadata, "stop_sequences"); len(stops) > 0 {
+		payload["stop"] = stops
+	}
+	if format, ok := toOpenAIResponseFormat(req.ResponseFormat); ok {
+		payload["response_format"] = format
+	}
 	streamOptions := mergeStreamOptions(a.streamOptions, req.Metadata["stream_options"])
 	if len(streamOptions) == 0 {
 		streamOptions = map[string]any{"include_usage": true}
@@ -597,7 +667,7 @@ func (a *HTTPAdapter) streamOpenAI(ctx context.Context, req orchestrator.Request
 	if err != nil {
 		return err
 	}
-	resp, err := a.client.Do(httpReq)
+	resp, err := a.do(httpReq)
 	if err != nil {
 		return err
 	}
@@ -606,7 +676,7 @@ func (a *HTTPAdapter) streamOpenAI(ctx context.Context, req orchestrator.Request
 	ctype := strings.ToLower(strings.TrimSpace(resp.Header.Get("content-type")))
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		body, _ := io.ReadAll(io.LimitReader(resp.Body, 8<<20))
-		return fmt.Errorf("adapter %s upstream status %d: %s", a.name, resp.StatusCode, strings.TrimSpace(string(body)))
+		return newHTTPStatusError(a.name, resp, body)
 	}
 
 	// Some upstreams ignore stream=true and return normal JSON body.
@@ -650,7 +720,7 @@ func (a *HTTPAdapter) doJSON(ctx context.Context, payload any, reqHeaders map[st
 	if err != nil {
 		return nil, err
 	}
-	resp, err := a.client.Do(httpReq)
+	resp, err := a.do(httpReq)
 	if err != nil {
 		return nil, err
 	}
@@ -661,7 +731,7 @@ func (a *HTTPAdapter) doJSON(ctx context.Context, payload any, reqHeaders map[st
 		return nil, err
 	}
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("adapter %s upstream status %d: %s", a.name, resp.StatusCode, strings.TrimSpace(string(body)))
+		return nil, newHTTPStatusError(a.name, resp, body)
 	}
 	return body, nil
 }
@@ -671,7 +741,7 @@ func (a *HTTPAdapter) doOpenAIStream(ctx context.Context, payload any, reqHeader
 	if err != nil {
 		return openAIStreamAggregate{}, err
 	}
-	resp, err := a.client.Do(httpReq)
+	resp, err := a.do(httpReq)
 	if err != nil {
 		return openAIStreamAggregate{}, err
 	}
@@ -680,7 +750,7 @@ func (a *HTTPAdapter) doOpenAIStream(ctx context.Context, payload any, reqHeader
 	ctype := strings.ToLower(strings.TrimSpace(resp.Header.Get("content-type")))
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		body, _ := io.ReadAll(io.LimitReader(resp.Body, 8<<20))
-		return openAIStreamAggregate{}, fmt.Errorf("adapter %s upstream status %d: %s", a.name, resp.StatusCode, strings.TrimSpace(string(body)))
+		return openAIStreamAggregate{}, newHTTPStatusError(a.name, resp, body)
 	}
 	// Some upstreams may ignore stream=true and return JSON directly.
 	if !strings.Contains(ctype, "text/event-stream") {
@@ -785,6 +855,13 @@ func (a *HTTPAdapter) newJSONRequest(ctx context.Context, payload any, reqHeader
 		return nil, err
 	}
 	httpReq.Header.Set("content-type", "application/json")
+	a.applyRequestHeaders(httpReq, reqHeaders)
+	return httpReq, nil
+}
+
+// applyRequestHeaders sets the user agent, configured headers and the
+// provider-specific credentials on an outgoing request.
+func (a *HTTPAdapter) applyRequestHeaders(httpReq *http.Request, reqHeaders map[string]string) {
 	if a.userAgent != "" {
 		httpReq.Header.Set("user-agent", a.userAgent)
 	}
@@ -822,7 +899,6 @@ func (a *HTTPAdapter) newJSONRequest(ctx context.Context, payload any, reqHeader
 			httpReq.Header.Set("x-goog-api-key", a.apiKey)
 		}
 	}
-	return httpReq, nil
 }
 
 func emitResponseAsStream(events chan<- orchestrator.StreamEvent, resp orchestrator.Response) {
@@ -1507,6 +1583,10 @@ func canonicalToOpenAITools(tools []orchestrator.Tool) []map[string]any {
 func canonicalToAnthropicTools(tools []orchestrator.Tool) []map[string]any {
 	out := make([]map[string]any, 0, len(tools))
 	for _, t := range tools {
+		if strings.TrimSpace(t.Type) != "" {
+			out = append(out, anthropicBuiltinTool(t))
+			continue
+		}
 		out = append(out, map[string]any{
 			"name":         t.Name,
 			"description":  t.Description,
@@ -1546,6 +1626,7 @@ func canonicalToOpenAIMessages(system any, messages []orchestrator.Message) []ma
 			})
 		case []any:
 			textParts := make([]string, 0, len(c))
+			var imageParts []map[string]any
 			for _, item := range c {
 				block, ok := item.(map[string]any)
 				if !ok {
@@ -1557,6 +1638,13 @@ func canonicalToOpenAIMessages(system any, messages []orchestrator.Message) []ma
 					if text, ok := block["text"].(string); ok {
 						textParts = append(textParts, text)
 					}
+				case "image", "image_url":
+					if imageURL, ok := imageBlockToDataURL(block); ok {
+						imageParts = append(imageParts, map[string]any{
+							"type":      "image_url",
+							"image_url": map[string]any{"url": imageURL},
+						})
+					}
 				case "tool_result":
 					toolCallID, _ := block["tool_use_id"].(string)
 					content := fmt.Sprintf("%v", block["content"])
@@ -1585,7 +1673,19 @@ func canonicalToOpenAIMessages(system any, messages []orchestrator.Message) []ma
 					})
 				}
 			}
-			if len(textParts) > 0 {
+			if len(imageParts) > 0 {
+				parts := make([]map[string]any, 0, len(imageParts)+1)
+				if len(textParts) > 0 {
+					parts = append(parts, map[string]any{
+						"type": "text",
+						"text": strings.Join(textParts, "\n"),
+					})
+				}
+				out = append(out, map[string]any{
+					"role":    role,
+					"content": append(parts, imageParts...),
+				})
+			} else if len(textParts) > 0 {
 				out = append(out, map[string]any{
 					"role":    role,
 					"content": strings.Join(textParts, "\n"),
@@ -1703,6 +1803,41 @@ func openAIImageURLBlockToAnthropic(block map[string]any) (map[string]any, bool)
 	}, true
 }
 
+// imageBlockToDataURL renders an Anthropic image block or an OpenAI
+// image_url block as a URL usable in OpenAI image_url content parts. Remote
+// URLs are passed through; base64 sources become data URLs.
+func imageBlockToDataURL(block map[string]any) (string, bool) {
+	if blockType, _ := block["type"].(string); blockType == "image_url" {
+		return extractImageURL(block["image_url"])
+	}
+	source, _ := block["source"].(map[string]any)
+	switch sourceType, _ := source["type"].(string); sourceType {
+	case "base64":
+		mediaType, _ := source["media_type"].(string)
+		data, _ := source["data"].(string)
+		if strings.TrimSpace(data) == "" {
+			return "", false
+		}
+		return "data:" + mediaType + ";base64," + data, true
+	case "url":
+		urlStr, _ := source["url"].(string)
+		urlStr = strings.TrimSpace(urlStr)
+		return urlStr, urlStr != ""
+	}
+	return "", false
+}
+
+// imageBlockToBase64 resolves an image block to inline base64 data, fetching
+// remote URLs when needed (for upstreams such as Gemini that only accept
+// inline images).
+func imageBlockToBase64(block map[string]any) (string, string, bool) {
+	imageURL, ok := imageBlockToDataURL(block)
+	if !ok {
+		return "", "", false
+	}
+	return resolveImageURLToBase64(imageURL)
+}
+
 func extractImageURL(raw any) (string, bool) {
 	switch v := raw.(type) {
 	case string:
@@ -1862,6 +1997,15 @@ func canonicalToGeminiContents(messages []orchestrator.Message) []map[string]any
 					if content, ok := block["content"].(string); ok {
 						parts = append(parts, map[string]any{"text": content})
 					}
+				case "image", "image_url":
+					if mediaType, data, ok := imageBlockToBase64(block); ok {
+						parts = append(parts, map[string]any{
+							"inline_data": map[string]any{
+								"mime_type": mediaType,
+								"data":      data,
+							},
+						})
+					}
 				}
 			}
 			if len(parts) == 0 {
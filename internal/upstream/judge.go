@@ -2,6 +2,7 @@ package upstream
 
 import (
 	"context"
+	"fmt"
 	"strings"
 	"time"
 
@@ -12,6 +13,23 @@ type CandidateJudge interface {
 	Select(ctx context.Context, req orchestrator.Request, candidates []JudgedCandidate) (int, error)
 }
 
+// Verdict is a judge's full decision: the winning index, a score per
+// candidate (same order as the input) and why the winner was picked.
+// Dimensions holds per-dimension scores when a rubric asked for them.
+type Verdict struct {
+	Index      int
+	Scores     []float64
+	Dimensions []map[string]float64
+	Rationale  string
+}
+
+// VerdictJudge is a CandidateJudge that can explain its choice. The router
+// records verdicts from judges that implement it.
+type VerdictJudge interface {
+	CandidateJudge
+	Judge(ctx context.Context, req orchestrator.Request, candidates []JudgedCandidate) (Verdict, error)
+}
+
 type JudgedCandidate struct {
 	AdapterName string
 	Response    orchestrator.Response
@@ -25,32 +43,37 @@ func NewHeuristicJudge() *HeuristicJudge {
 	return &HeuristicJudge{}
 }
 
-func (j *HeuristicJudge) Select(_ context.Context, req orchestrator.Request, candidates []JudgedCandidate) (int, error) {
+func (j *HeuristicJudge) Select(ctx context.Context, req orchestrator.Request, candidates []JudgedCandidate) (int, error) {
+	v, err := j.Judge(ctx, req, candidates)
+	return v.Index, err
+}
+
+// Judge scores every candidate; ties go to the faster, then the earlier
+// one in route order.
+func (j *HeuristicJudge) Judge(_ context.Context, req orchestrator.Request, candidates []JudgedCandidate) (Verdict, error) {
 	if len(candidates) == 0 {
-		return -1, nil
+		return Verdict{Index: -1}, nil
+	}
+	scores := make([]float64, len(candidates))
+	for i := range candidates {
+		scores[i] = j.score(req, candidates[i])
 	}
 	best := 0
-	bestScore := j.score(req, candidates[0])
 	for i := 1; i < len(candidates); i++ {
-		score := j.score(req, candidates[i])
-		if score > bestScore {
+		switch {
+		case scores[i] > scores[best]:
+			best = i
+		case scores[i] == scores[best] && candidates[i].Latency < candidates[best].Latency:
+			best = i
+		case scores[i] == scores[best] && candidates[i].Latency == candidates[best].Latency && candidates[i].Order < candidates[best].Order:
 			best = i
-			bestScore = score
-			continue
-		}
-		if score == bestScore {
-			if candidates[i].Latency < candidates[best].Latency {
-				best = i
-				bestScore = score
-				continue
-			}
-			if candidates[i].Latency == candidates[best].Latency && candidates[i].Order < candidates[best].Order {
-				best = i
-				bestScore = score
-			}
 		}
 	}
-	return best, nil
+	return Verdict{
+		Index:     best,
+		Scores:    scores,
+		Rationale: fmt.Sprintf("heuristic score %.2f", scores[best]),
+	}, nil
 }
 
 func (j *HeuristicJudge) score(req orchestrator.Request, candidate JudgedCandidate) float64 {
@@ -7,6 +7,10 @@ import (
 	"time"
 )
 
+// NewJudgeFromEnv builds the judge named by JUDGE_MODE. For the LLM judge,
+// JUDGE_SYSTEM_PROMPT is the default rubric template and JUDGE_DIMENSIONS
+// ("accuracy:0.5,formatting:0.2,safety:0.3") its score dimensions; the
+// routing.judge runtime settings override both per mode.
 func NewJudgeFromEnv(adapters []Adapter, defaultRoute []string) (CandidateJudge, error) {
 	mode := strings.ToLower(strings.TrimSpace(os.Getenv("JUDGE_MODE")))
 	if mode == "" || mode == "heuristic" {
@@ -16,6 +20,10 @@ func NewJudgeFromEnv(adapters []Adapter, defaultRoute []string) (CandidateJudge,
 		return nil, fmt.Errorf("unsupported JUDGE_MODE %q", mode)
 	}
 
+	dims, err := ParseJudgeDimensions(os.Getenv("JUDGE_DIMENSIONS"))
+	if err != nil {
+		return nil, err
+	}
 	route := ParseListEnv("JUDGE_ROUTE", defaultRoute)
 	model := strings.TrimSpace(os.Getenv("JUDGE_MODEL"))
 	judge, err := NewLLMJudge(LLMJudgeConfig{
@@ -25,6 +33,7 @@ func NewJudgeFromEnv(adapters []Adapter, defaultRoute []string) (CandidateJudge,
 		Retries:      ParseIntEnv("JUDGE_RETRIES", 0),
 		MaxTokens:    ParseIntEnv("JUDGE_MAX_TOKENS", 64),
 		SystemPrompt: strings.TrimSpace(os.Getenv("JUDGE_SYSTEM_PROMPT")),
+		Rubric:       JudgeRubric{Dimensions: dims},
 	}, adapters)
 	if err != nil {
 		return nil, err
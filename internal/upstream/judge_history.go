@@ -0,0 +1,462 @@
+package upstream
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"ccgateway/internal/orchestrator"
+)
+
+const maxJudgeVerdicts = 50000
+
+// JudgeVerdict is one judged contest between parallel candidates.
+type JudgeVerdict struct {
+	Timestamp  time.Time        `json:"timestamp"`
+	RunID      string           `json:"run_id,omitempty"`
+	Model      string           `json:"model,omitempty"`
+	Judge      string           `json:"judge"`
+	Winner     string           `json:"winner"`
+	Rationale  string           `json:"rationale,omitempty"`
+	Candidates []JudgeCandidate `json:"candidates"`
+}
+
+// JudgeCandidate is one contestant in a verdict.
+type JudgeCandidate struct {
+	Adapter    string             `json:"adapter"`
+	Score      float64            `json:"score"`
+	Dimensions map[string]float64 `json:"dimensions,omitempty"`
+	LatencyMS  int64              `json:"latency_ms"`
+	Winner     bool               `json:"winner,omitempty"`
+}
+
+// JudgeHistory keeps judge verdicts in a JSON-lines file so the win-rate
+// report survives restarts. Only the most recent verdicts are kept in
+// memory.
+type JudgeHistory struct {
+	mu       sync.RWMutex
+	path     string
+	file     *os.File
+	verdicts []JudgeVerdict
+}
+
+// NewJudgeHistory opens (or creates) the history at path. An empty path
+// keeps it in memory only.
+func NewJudgeHistory(path string) (*JudgeHistory, error) {
+	h := &JudgeHistory{path: strings.TrimSpace(path)}
+	if h.path == "" {
+		return h, nil
+	}
+	h.path = filepath.Clean(h.path)
+	if err := os.MkdirAll(filepath.Dir(h.path), 0o755); err != nil {
+		return nil, fmt.Errorf("create judge history dir: %w", err)
+	}
+	if err := h.load(); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open judge history: %w", err)
+	}
+	h.file = f
+	return h, nil
+}
+
+// JudgeHistoryFromEnv reads JUDGE_HISTORY_PATH (default
+// logs/judge-history.jsonl).
+func JudgeHistoryFromEnv() (*JudgeHistory, error) {
+	path := strings.TrimSpace(os.Getenv("JUDGE_HISTORY_PATH"))
+	if path == "" {
+		path = "logs/judge-history.jsonl"
+	}
+	return NewJudgeHistory(path)
+}
+
+func (h *JudgeHistory) load() error {
+	f, err := os.Open(h.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("open judge history: %w", err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4<<20)
+	for scanner.Scan() {
+		var v JudgeVerdict
+		if err := json.Unmarshal(scanner.Bytes(), &v); err != nil || v.Winner == "" {
+			continue
+		}
+		h.verdicts = append(h.verdicts, v)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("read judge history: %w", err)
+	}
+	h.trimLocked()
+	return nil
+}
+
+// Record appends a verdict.
+func (h *JudgeHistory) Record(v JudgeVerdict) {
+	if h == nil {
+		return
+	}
+	if v.Timestamp.IsZero() {
+		v.Timestamp = time.Now().UTC()
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.verdicts = append(h.verdicts, v)
+	h.trimLocked()
+	if h.file == nil {
+		return
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	_, _ = h.file.Write(append(raw, '\n'))
+}
+
+// Verdicts returns the most recent verdicts first, optionally only those
+// for runID. limit <= 0 returns all of them.
+func (h *JudgeHistory) Verdicts(runID string, limit int) []JudgeVerdict {
+	out := []JudgeVerdict{}
+	if h == nil {
+		return out
+	}
+	runID = strings.TrimSpace(runID)
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for i := len(h.verdicts) - 1; i >= 0; i-- {
+		if runID != "" && h.verdicts[i].RunID != runID {
+			continue
+		}
+		out = append(out, h.verdicts[i])
+		if limit > 0 && len(out) >= limit {
+			break
+		}
+	}
+	return out
+}
+
+// DeleteRuns forgets the verdicts of runIDs and rewrites the history file
+// without them.
+func (h *JudgeHistory) DeleteRuns(runIDs map[string]bool) (int, error) {
+	if h == nil {
+		return 0, nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	kept := h.verdicts[:0]
+	for _, v := range h.verdicts {
+		if v.RunID == "" || !runIDs[v.RunID] {
+			kept = append(kept, v)
+		}
+	}
+	n := len(h.verdicts) - len(kept)
+	h.verdicts = kept
+	if n == 0 || h.file == nil {
+		return n, nil
+	}
+	return n, h.rewriteLocked()
+}
+
+// rewriteLocked replaces the history file with the verdicts in memory.
+// Verdicts trimmed from memory are dropped from the file as well.
+func (h *JudgeHistory) rewriteLocked() error {
+	var buf bytes.Buffer
+	for _, v := range h.verdicts {
+		raw, err := json.Marshal(v)
+		if err != nil {
+			continue
+		}
+		buf.Write(raw)
+		buf.WriteByte('\n')
+	}
+	tmp := h.path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o600); err != nil {
+		return fmt.Errorf("rewrite judge history: %w", err)
+	}
+	_ = h.file.Close()
+	h.file = nil
+	renameErr := os.Rename(tmp, h.path)
+	if renameErr != nil {
+		_ = os.Remove(tmp)
+	}
+	f, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err == nil {
+		h.file = f
+	}
+	if renameErr != nil {
+		return fmt.Errorf("rewrite judge history: %w", renameErr)
+	}
+	if err != nil {
+		return fmt.Errorf("open judge history: %w", err)
+	}
+	return nil
+}
+
+// Close releases the history file.
+func (h *JudgeHistory) Close() error {
+	if h == nil {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.file == nil {
+		return nil
+	}
+	err := h.file.Close()
+	h.file = nil
+	return err
+}
+
+func (h *JudgeHistory) trimLocked() {
+	if over := len(h.verdicts) - maxJudgeVerdicts; over > 0 {
+		h.verdicts = append([]JudgeVerdict(nil), h.verdicts[over:]...)
+	}
+}
+
+// JudgeReportQuery bounds the report window; zero times are open. Model,
+// when set, limits the report to that request model.
+type JudgeReportQuery struct {
+	Since time.Time
+	Until time.Time
+	Model string
+}
+
+// JudgeAdapterStats is how one adapter fared in judged contests.
+type JudgeAdapterStats struct {
+	Adapter      string  `json:"adapter"`
+	Model        string  `json:"model,omitempty"`
+	Contests     int     `json:"contests"`
+	Wins         int     `json:"wins"`
+	WinRate      float64 `json:"win_rate"`
+	AvgScore     float64 `json:"avg_score"`
+	AvgLatencyMS float64 `json:"avg_latency_ms"`
+}
+
+// JudgeReport is the report served by /admin/judge/report.
+type JudgeReport struct {
+	Since     *time.Time          `json:"since,omitempty"`
+	Until     *time.Time          `json:"until,omitempty"`
+	Model     string              `json:"model,omitempty"`
+	Verdicts  int                 `json:"verdicts"`
+	ByJudge   map[string]int      `json:"by_judge"`
+	ByAdapter []JudgeAdapterStats `json:"by_adapter"`
+	ByModel   []JudgeAdapterStats `json:"by_model"`
+	Recent    []JudgeVerdict      `json:"recent"`
+}
+
+type judgeTally struct {
+	contests, wins int
+	score          float64
+	latency        int64
+}
+
+// Report aggregates win rates per adapter and per model/adapter pair inside
+// q's window, best win rate first (within each model for ByModel).
+func (h *JudgeHistory) Report(q JudgeReportQuery) JudgeReport {
+	out := JudgeReport{
+		Model:     strings.TrimSpace(q.Model),
+		ByJudge:   map[string]int{},
+		ByAdapter: []JudgeAdapterStats{},
+		ByModel:   []JudgeAdapterStats{},
+		Recent:    []JudgeVerdict{},
+	}
+	if !q.Since.IsZero() {
+		since := q.Since.UTC()
+		out.Since = &since
+	}
+	if !q.Until.IsZero() {
+		until := q.Until.UTC()
+		out.Until = &until
+	}
+	if h == nil {
+		return out
+	}
+	window := DispatchAnalyticsQuery{Since: q.Since, Until: q.Until}
+	h.mu.RLock()
+	verdicts := make([]JudgeVerdict, 0, len(h.verdicts))
+	for _, v := range h.verdicts {
+		if !inWindow(v.Timestamp, window) {
+			continue
+		}
+		if out.Model != "" && v.Model != out.Model {
+			continue
+		}
+		verdicts = append(verdicts, v)
+	}
+	h.mu.RUnlock()
+
+	byAdapter := map[string]*judgeTally{}
+	byModel := map[[2]string]*judgeTally{}
+	for _, v := range verdicts {
+		out.ByJudge[v.Judge]++
+		for _, c := range v.Candidates {
+			for _, t := range []*judgeTally{
+				tallyFor(byAdapter, c.Adapter),
+				tallyFor(byModel, [2]string{v.Model, c.Adapter}),
+			} {
+				t.contests++
+				t.score += c.Score
+				t.latency += c.LatencyMS
+				if c.Winner {
+					t.wins++
+				}
+			}
+		}
+	}
+	out.Verdicts = len(verdicts)
+	for name, t := range byAdapter {
+		out.ByAdapter = append(out.ByAdapter, t.stats(name, ""))
+	}
+	for key, t := range byModel {
+		out.ByModel = append(out.ByModel, t.stats(key[1], key[0]))
+	}
+	sortJudgeStats(out.ByAdapter)
+	sortJudgeStats(out.ByModel)
+	start := len(verdicts) - 10
+	if start < 0 {
+		start = 0
+	}
+	for i := len(verdicts) - 1; i >= start; i-- {
+		out.Recent = append(out.Recent, verdicts[i])
+	}
+	return out
+}
+
+func tallyFor[K comparable](m map[K]*judgeTally, key K) *judgeTally {
+	t, ok := m[key]
+	if !ok {
+		t = &judgeTally{}
+		m[key] = t
+	}
+	return t
+}
+
+func (t *judgeTally) stats(adapter, model string) JudgeAdapterStats {
+	return JudgeAdapterStats{
+		Adapter:      adapter,
+		Model:        model,
+		Contests:     t.contests,
+		Wins:         t.wins,
+		WinRate:      ratio(t.wins, t.contests),
+		AvgScore:     round2(t.score / float64(t.contests)),
+		AvgLatencyMS: round2(float64(t.latency) / float64(t.contests)),
+	}
+}
+
+func sortJudgeStats(items []JudgeAdapterStats) {
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Model != items[j].Model {
+			return items[i].Model < items[j].Model
+		}
+		if items[i].WinRate != items[j].WinRate {
+			return items[i].WinRate > items[j].WinRate
+		}
+		return items[i].Adapter < items[j].Adapter
+	})
+}
+
+// judgeCandidates runs the configured judge and records its verdict. Judges
+// that cannot explain themselves are recorded with only the winner scored.
+func (s *RouterService) judgeCandidates(ctx context.Context, req orchestrator.Request, candidates []JudgedCandidate) (int, error) {
+	s.mu.RLock()
+	judge := s.judge
+	history := s.judgeHistory
+	s.mu.RUnlock()
+
+	var v Verdict
+	if vj, ok := judge.(VerdictJudge); ok {
+		var err error
+		if v, err = vj.Judge(ctx, req, candidates); err != nil {
+			return -1, err
+		}
+	} else {
+		idx, err := judge.Select(ctx, req, candidates)
+		if err != nil {
+			return -1, err
+		}
+		v = Verdict{Index: idx}
+	}
+	if history == nil || v.Index < 0 || v.Index >= len(candidates) {
+		return v.Index, nil
+	}
+	rec := JudgeVerdict{
+		RunID:      req.RunID,
+		Model:      req.Model,
+		Judge:      judgeName(judge),
+		Winner:     candidates[v.Index].AdapterName,
+		Rationale:  v.Rationale,
+		Candidates: make([]JudgeCandidate, 0, len(candidates)),
+	}
+	for i, c := range candidates {
+		item := JudgeCandidate{
+			Adapter:   c.AdapterName,
+			LatencyMS: c.Latency.Milliseconds(),
+			Winner:    i == v.Index,
+		}
+		if i < len(v.Scores) {
+			item.Score = round2(v.Scores[i])
+		} else if item.Winner {
+			item.Score = 1
+		}
+		if i < len(v.Dimensions) {
+			item.Dimensions = v.Dimensions[i]
+		}
+		rec.Candidates = append(rec.Candidates, item)
+	}
+	history.Record(rec)
+	return v.Index, nil
+}
+
+func judgeName(j CandidateJudge) string {
+	switch j.(type) {
+	case *HeuristicJudge:
+		return "heuristic"
+	case *LLMJudge:
+		return "llm"
+	}
+	return fmt.Sprintf("%T", j)
+}
+
+// JudgeReport summarizes recorded judge verdicts.
+func (s *RouterService) JudgeReport(q JudgeReportQuery) (JudgeReport, error) {
+	s.mu.RLock()
+	history := s.judgeHistory
+	s.mu.RUnlock()
+	if history == nil {
+		return JudgeReport{}, fmt.Errorf("judge history is not configured")
+	}
+	return history.Report(q), nil
+}
+
+// JudgeVerdicts lists recorded verdicts, newest first.
+func (s *RouterService) JudgeVerdicts(runID string, limit int) ([]JudgeVerdict, error) {
+	s.mu.RLock()
+	history := s.judgeHistory
+	s.mu.RUnlock()
+	if history == nil {
+		return nil, fmt.Errorf("judge history is not configured")
+	}
+	return history.Verdicts(runID, limit), nil
+}
+
+// DeleteJudgeVerdicts forgets the recorded verdicts of runIDs.
+func (s *RouterService) DeleteJudgeVerdicts(runIDs map[string]bool) (int, error) {
+	s.mu.RLock()
+	history := s.judgeHistory
+	s.mu.RUnlock()
+	return history.DeleteRuns(runIDs)
+}
@@ -0,0 +1,180 @@
+package upstream
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"ccgateway/internal/orchestrator"
+)
+
+const (
+	defaultJudgeSystemPrompt  = "You are a strict judge. Select the best candidate index for quality and task fit. Reply with ONLY the integer index."
+	defaultRubricSystemPrompt = "You are a strict judge comparing candidate answers to a {{mode}} request. Score every candidate from 0 to 10 on each dimension:\n{{dimensions}}\nReply with ONLY the JSON object you are asked for."
+	// rubricMaxTokens is the floor on judge output when it has to score
+	// every dimension of every candidate.
+	rubricMaxTokens = 512
+)
+
+// JudgeDimension is one axis the LLM judge scores candidates on.
+type JudgeDimension struct {
+	Name        string  `json:"name"`
+	Weight      float64 `json:"weight"`
+	Description string  `json:"description,omitempty"`
+}
+
+// JudgeRubric overrides how the LLM judge compares candidates. Prompt is a
+// system prompt template with {{mode}} and {{dimensions}} placeholders.
+// With Dimensions set the judge scores each candidate on every dimension
+// and the weighted mean becomes the candidate's score. The gateway passes
+// the rubric for the request mode in metadata.judge_rubric.
+type JudgeRubric struct {
+	Prompt     string           `json:"prompt,omitempty"`
+	Dimensions []JudgeDimension `json:"dimensions,omitempty"`
+}
+
+// IsZero reports whether the rubric changes nothing.
+func (r JudgeRubric) IsZero() bool {
+	return strings.TrimSpace(r.Prompt) == "" && len(r.Dimensions) == 0
+}
+
+// ParseJudgeDimensions parses "accuracy:0.5,formatting:0.2,safety" into
+// dimensions; a missing weight means 1.
+func ParseJudgeDimensions(raw string) ([]JudgeDimension, error) {
+	var out []JudgeDimension
+	seen := map[string]bool{}
+	for _, item := range strings.Split(raw, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, weightRaw, hasWeight := strings.Cut(item, ":")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			return nil, fmt.Errorf("judge dimension %q has no name", item)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("judge dimension %q listed twice", name)
+		}
+		seen[name] = true
+		weight := 1.0
+		if hasWeight {
+			w, err := strconv.ParseFloat(strings.TrimSpace(weightRaw), 64)
+			if err != nil || w <= 0 {
+				return nil, fmt.Errorf("judge dimension %q needs a positive weight", name)
+			}
+			weight = w
+		}
+		out = append(out, JudgeDimension{Name: name, Weight: weight})
+	}
+	return out, nil
+}
+
+// judgeRubricFromMetadata reads metadata.judge_rubric. Metadata that went
+// through JSON carries the rubric as a map.
+func judgeRubricFromMetadata(metadata map[string]any) (JudgeRubric, bool) {
+	switch v := metadata["judge_rubric"].(type) {
+	case JudgeRubric:
+		return v, !v.IsZero()
+	case *JudgeRubric:
+		if v != nil {
+			return *v, !v.IsZero()
+		}
+	case map[string]any:
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return JudgeRubric{}, false
+		}
+		var r JudgeRubric
+		if json.Unmarshal(raw, &r) != nil {
+			return JudgeRubric{}, false
+		}
+		return r, !r.IsZero()
+	}
+	return JudgeRubric{}, false
+}
+
+// rubricSystemPrompt expands the rubric template. A rubric without its own
+// prompt keeps JUDGE_SYSTEM_PROMPT unless that is the built-in index-only
+// prompt, which cannot ask for dimension scores.
+func rubricSystemPrompt(base string, rubric JudgeRubric, mode string) string {
+	prompt := strings.TrimSpace(rubric.Prompt)
+	if prompt == "" {
+		prompt = base
+		if len(rubric.Dimensions) > 0 && base == defaultJudgeSystemPrompt {
+			prompt = defaultRubricSystemPrompt
+		}
+	}
+	if len(rubric.Dimensions) > 0 && !strings.Contains(prompt, "{{dimensions}}") {
+		prompt += "\n\nScore every candidate from 0 to 10 on each dimension:\n{{dimensions}}"
+	}
+	if mode == "" {
+		mode = "chat"
+	}
+	return strings.NewReplacer("{{mode}}", mode, "{{dimensions}}", describeDimensions(rubric.Dimensions)).Replace(prompt)
+}
+
+func describeDimensions(dims []JudgeDimension) string {
+	lines := make([]string, 0, len(dims))
+	for _, d := range dims {
+		line := fmt.Sprintf("- %s (weight %g)", d.Name, d.Weight)
+		if d.Description != "" {
+			line += ": " + d.Description
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// parseRubricVerdict reads {"index":N,"scores":[{dim:score},...],"reason":...}.
+// A reply without usable scores falls back to the plain index reply.
+func parseRubricVerdict(resp orchestrator.Response, count int, dims []JudgeDimension) (Verdict, error) {
+	text := strings.TrimSpace(responseText(resp))
+	if start, end := strings.Index(text, "{"), strings.LastIndex(text, "}"); start >= 0 && end > start {
+		var obj struct {
+			Index  *int                 `json:"index"`
+			Scores []map[string]float64 `json:"scores"`
+			Reason string               `json:"reason"`
+		}
+		if err := json.Unmarshal([]byte(text[start:end+1]), &obj); err == nil && len(obj.Scores) == count {
+			v := Verdict{
+				Scores:     make([]float64, count),
+				Dimensions: make([]map[string]float64, count),
+				Rationale:  trimTo(strings.TrimSpace(obj.Reason), 500),
+			}
+			var totalWeight float64
+			for _, d := range dims {
+				totalWeight += d.Weight
+			}
+			best := 0
+			for i, raw := range obj.Scores {
+				v.Dimensions[i] = map[string]float64{}
+				var sum float64
+				for _, d := range dims {
+					score := raw[d.Name]
+					v.Dimensions[i][d.Name] = score
+					sum += d.Weight * score
+				}
+				if totalWeight > 0 {
+					v.Scores[i] = sum / totalWeight
+				}
+				if v.Scores[i] > v.Scores[best] {
+					best = i
+				}
+			}
+			v.Index = best
+			if obj.Index != nil && *obj.Index >= 0 && *obj.Index < count {
+				v.Index = *obj.Index
+			}
+			return v, nil
+		}
+	}
+	idx, err := parseJudgeIndex(resp, count)
+	if err != nil {
+		return Verdict{Index: -1}, err
+	}
+	scores := make([]float64, count)
+	scores[idx] = 1
+	return Verdict{Index: idx, Scores: scores, Rationale: judgeRationale(resp)}, nil
+}
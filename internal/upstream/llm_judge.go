@@ -21,6 +21,9 @@ type LLMJudgeConfig struct {
 	Retries      int
 	MaxTokens    int
 	SystemPrompt string
+	// Rubric is the default rubric; metadata.judge_rubric overrides it
+	// per request.
+	Rubric JudgeRubric
 }
 
 type LLMJudge struct {
@@ -58,16 +61,36 @@ func NewLLMJudge(cfg LLMJudgeConfig, adapters []Adapter) (*LLMJudge, error) {
 }
 
 func (j *LLMJudge) Select(ctx context.Context, req orchestrator.Request, candidates []JudgedCandidate) (int, error) {
+	v, err := j.Judge(ctx, req, candidates)
+	return v.Index, err
+}
+
+// Judge asks the judge model for the winning index. Under a rubric with
+// dimensions the model scores every candidate and each gets the weighted
+// mean; otherwise the model only names a winner, which scores 1 and the
+// rest 0. The rationale is the model's "reason" when it replies with JSON,
+// otherwise its reply text.
+func (j *LLMJudge) Judge(ctx context.Context, req orchestrator.Request, candidates []JudgedCandidate) (Verdict, error) {
 	if len(candidates) == 0 {
-		return -1, nil
+		return Verdict{Index: -1}, nil
 	}
 	if len(candidates) == 1 {
-		return 0, nil
+		return Verdict{Index: 0, Scores: []float64{1}, Rationale: "single candidate"}, nil
 	}
 
-	prompt, err := j.buildPrompt(req, candidates)
+	rubric := j.cfg.Rubric
+	if r, ok := judgeRubricFromMetadata(req.Metadata); ok {
+		rubric = r
+	}
+	prompt, err := j.buildPrompt(req, candidates, len(rubric.Dimensions) > 0)
 	if err != nil {
-		return -1, err
+		return Verdict{Index: -1}, err
+	}
+	mode, _ := req.Metadata["mode"].(string)
+	system := rubricSystemPrompt(j.cfg.SystemPrompt, rubric, strings.TrimSpace(mode))
+	maxTokens := j.cfg.MaxTokens
+	if len(rubric.Dimensions) > 0 && maxTokens < rubricMaxTokens {
+		maxTokens = rubricMaxTokens
 	}
 	var lastErr error
 	for _, adapterName := range j.cfg.Route {
@@ -84,8 +107,8 @@ func (j *LLMJudge) Select(ctx context.Context, req orchestrator.Request, candida
 			attemptCtx, cancel := context.WithTimeout(ctx, j.cfg.Timeout)
 			resp, err := adapter.Complete(attemptCtx, orchestrator.Request{
 				Model:     j.cfg.Model,
-				MaxTokens: j.cfg.MaxTokens,
-				System:    j.cfg.SystemPrompt,
+				MaxTokens: maxTokens,
+				System:    system,
 				Messages: []orchestrator.Message{
 					{Role: "user", Content: prompt},
 				},
@@ -95,18 +118,18 @@ func (j *LLMJudge) Select(ctx context.Context, req orchestrator.Request, candida
 				lastErr = err
 				continue
 			}
-			idx, err := parseJudgeIndex(resp, len(candidates))
+			v, err := parseRubricVerdict(resp, len(candidates), rubric.Dimensions)
 			if err != nil {
 				lastErr = err
 				continue
 			}
-			return idx, nil
+			return v, nil
 		}
 	}
 	if lastErr == nil {
 		lastErr = fmt.Errorf("judge failed with no available adapter")
 	}
-	return -1, lastErr
+	return Verdict{Index: -1}, lastErr
 }
 
 func sanitizeLLMJudgeConfig(cfg LLMJudgeConfig) LLMJudgeConfig {
@@ -121,7 +144,7 @@ func sanitizeLLMJudgeConfig(cfg LLMJudgeConfig) LLMJudgeConfig {
 	}
 	cfg.Model = strings.TrimSpace(cfg.Model)
 	if strings.TrimSpace(cfg.SystemPrompt) == "" {
-		cfg.SystemPrompt = "You are a strict judge. Select the best candidate index for quality and task fit. Reply with ONLY the integer index."
+		cfg.SystemPrompt = defaultJudgeSystemPrompt
 	}
 	cleanRoute := make([]string, 0, len(cfg.Route))
 	for _, item := range cfg.Route {
@@ -134,7 +157,7 @@ func sanitizeLLMJudgeConfig(cfg LLMJudgeConfig) LLMJudgeConfig {
 	return cfg
 }
 
-func (j *LLMJudge) buildPrompt(req orchestrator.Request, candidates []JudgedCandidate) (string, error) {
+func (j *LLMJudge) buildPrompt(req orchestrator.Request, candidates []JudgedCandidate, scored bool) (string, error) {
 	type candidatePayload struct {
 		Index      int      `json:"index"`
 		Adapter    string   `json:"adapter"`
@@ -168,6 +191,11 @@ func (j *LLMJudge) buildPrompt(req orchestrator.Request, candidates []JudgedCand
 	if err != nil {
 		return "", err
 	}
+	if scored {
+		return "Score each candidate from JSON and return JSON only, as " +
+			`{"index":<best candidate index>,"scores":[{"<dimension>":<0-10>,...} for each candidate in order],"reason":"<one sentence>"}` +
+			":\n" + string(raw), nil
+	}
 	return "Select one candidate index from JSON and return integer only:\n" + string(raw), nil
 }
 
@@ -243,6 +271,17 @@ func parseJudgeIndex(resp orchestrator.Response, count int) (int, error) {
 	return idx, nil
 }
 
+func judgeRationale(resp orchestrator.Response) string {
+	text := strings.TrimSpace(responseText(resp))
+	var obj struct {
+		Reason string `json:"reason"`
+	}
+	if err := json.Unmarshal([]byte(text), &obj); err == nil && strings.TrimSpace(obj.Reason) != "" {
+		text = strings.TrimSpace(obj.Reason)
+	}
+	return trimTo(text, 500)
+}
+
 func responseText(resp orchestrator.Response) string {
 	parts := make([]string, 0, len(resp.Blocks))
 	for _, b := range resp.Blocks {
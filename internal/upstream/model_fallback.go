@@ -0,0 +1,96 @@
+package upstream
+
+import (
+	"context"
+	"strings"
+
+	"ccgateway/internal/orchestrator"
+)
+
+// ModelTarget reports which entry of a model alias group served a request.
+// Targets is the whole group in order; LastError is the failure that moved
+// the request past the earlier targets.
+type ModelTarget struct {
+	Model     string
+	Index     int
+	Targets   []string
+	LastError string
+}
+
+type modelTargetObserverKey struct{}
+
+// WithModelTargetObserver returns a context whose requests report the alias
+// group target that served them to fn. Requests without fallbacks are not
+// reported.
+func WithModelTargetObserver(ctx context.Context, fn func(ModelTarget)) context.Context {
+	if fn == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, modelTargetObserverKey{}, fn)
+}
+
+func observeModelTarget(ctx context.Context, target ModelTarget) {
+	if fn, ok := ctx.Value(modelTargetObserverKey{}).(func(ModelTarget)); ok {
+		fn(target)
+	}
+}
+
+// modelTargets returns req.Model followed by the "model_fallbacks" listed
+// in metadata.
+func modelTargets(req orchestrator.Request) []string {
+	targets := []string{req.Model}
+	for _, m := range stringListFromMetadata(req.Metadata, "model_fallbacks") {
+		if m != req.Model {
+			targets = append(targets, m)
+		}
+	}
+	return targets
+}
+
+// targetRequest builds the request for the index-th target. The fallback
+// list is dropped so nested calls do not fail over again, and a route the
+// gateway pinned from a channel of the first target does not carry over.
+func targetRequest(req orchestrator.Request, model string, index int) orchestrator.Request {
+	out := req
+	out.Model = model
+	meta := make(map[string]any, len(req.Metadata))
+	for k, v := range req.Metadata {
+		meta[k] = v
+	}
+	delete(meta, "model_fallbacks")
+	if index > 0 {
+		meta["upstream_model"] = model
+		if source, _ := meta["routing_route_source"].(string); source == "channel" {
+			delete(meta, "routing_adapter_route")
+			delete(meta, "routing_route_source")
+			delete(meta, "routing_channel_id")
+		}
+	}
+	out.Metadata = meta
+	return out
+}
+
+// targetCooledDown reports whether the selector rules out every adapter on
+// the target's route, so the next target can be tried without a call.
+func (s *RouterService) targetCooledDown(req orchestrator.Request, wantStream bool) bool {
+	checker, ok := s.selector.(interface {
+		Available(req orchestrator.Request, candidates []string, wantStream bool) []string
+	})
+	if !ok {
+		return false
+	}
+	candidates := routeFromMetadata(req.Metadata)
+	if len(candidates) == 0 {
+		s.mu.RLock()
+		candidates = s.staticRouteLocked(req.Model)
+		s.mu.RUnlock()
+	}
+	return len(candidates) > 0 && len(checker.Available(req, candidates, wantStream)) == 0
+}
+
+func errorText(err error) string {
+	if err == nil {
+		return ""
+	}
+	return strings.TrimSpace(err.Error())
+}
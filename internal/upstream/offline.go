@@ -0,0 +1,182 @@
+package upstream
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"ccgateway/internal/orchestrator"
+)
+
+// OfflineMode replaces every configured adapter with a stand-in that replays
+// recorded fixtures and falls back to the mock adapter, so the gateway runs
+// end to end without provider credentials or network access.
+type OfflineMode struct {
+	FixturesPath string
+	Fixtures     []Fixture
+}
+
+// Fixture is a canned upstream reply. Model is a path.Match glob and
+// Contains a case-insensitive substring of the last user message; empty
+// fields match anything. The first matching fixture wins.
+type Fixture struct {
+	Name       string          `json:"name,omitempty"`
+	Adapter    string          `json:"adapter,omitempty"`
+	Model      string          `json:"model,omitempty"`
+	Contains   string          `json:"contains,omitempty"`
+	Text       string          `json:"text,omitempty"`
+	ToolUse    *FixtureToolUse `json:"tool_use,omitempty"`
+	StopReason string          `json:"stop_reason,omitempty"`
+	Error      string          `json:"error,omitempty"`
+}
+
+type FixtureToolUse struct {
+	Name  string         `json:"name"`
+	Input map[string]any `json:"input,omitempty"`
+}
+
+// OfflineStatus is what admin endpoints report while offline.
+type OfflineStatus struct {
+	Enabled      bool   `json:"enabled"`
+	FixturesPath string `json:"fixtures_path,omitempty"`
+	Fixtures     int    `json:"fixtures"`
+}
+
+// OfflineModeFromEnv returns nil unless OFFLINE_MODE is set. Fixtures are
+// read from OFFLINE_FIXTURES_PATH (a JSON array) when given.
+func OfflineModeFromEnv() (*OfflineMode, error) {
+	if !ParseBoolEnv("OFFLINE_MODE", false) {
+		return nil, nil
+	}
+	mode := &OfflineMode{FixturesPath: strings.TrimSpace(os.Getenv("OFFLINE_FIXTURES_PATH"))}
+	if mode.FixturesPath == "" {
+		return mode, nil
+	}
+	fixtures, err := LoadFixtures(mode.FixturesPath)
+	if err != nil {
+		return nil, err
+	}
+	mode.Fixtures = fixtures
+	return mode, nil
+}
+
+func LoadFixtures(file string) ([]Fixture, error) {
+	raw, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("read offline fixtures: %w", err)
+	}
+	var fixtures []Fixture
+	if err := json.Unmarshal(raw, &fixtures); err != nil {
+		return nil, fmt.Errorf("invalid offline fixtures %s: %w", file, err)
+	}
+	for i, f := range fixtures {
+		if f.Model != "" {
+			if _, err := path.Match(f.Model, ""); err != nil {
+				return nil, fmt.Errorf("offline fixture %d: invalid model pattern %q", i, f.Model)
+			}
+		}
+		if f.ToolUse != nil && strings.TrimSpace(f.ToolUse.Name) == "" {
+			return nil, fmt.Errorf("offline fixture %d: tool_use.name is required", i)
+		}
+	}
+	return fixtures, nil
+}
+
+func (m *OfflineMode) Status() OfflineStatus {
+	if m == nil {
+		return OfflineStatus{}
+	}
+	return OfflineStatus{Enabled: true, FixturesPath: m.FixturesPath, Fixtures: len(m.Fixtures)}
+}
+
+// BuildAdapters returns one offline stand-in per spec, keeping names and
+// declared capabilities so routes and admin views are unchanged.
+func (m *OfflineMode) BuildAdapters(specs []AdapterSpec) ([]Adapter, error) {
+	out := make([]Adapter, 0, len(specs))
+	for _, spec := range specs {
+		spec = sanitizeAdapterSpec(spec)
+		if spec.Name == "" {
+			return nil, fmt.Errorf("adapter name is required")
+		}
+		out = append(out, &OfflineAdapter{spec: spec, fixtures: m.Fixtures, mock: NewMockAdapter(spec.Name, false)})
+	}
+	return out, nil
+}
+
+// OfflineAdapter stands in for a real adapter while offline mode is on.
+type OfflineAdapter struct {
+	spec     AdapterSpec
+	fixtures []Fixture
+	mock     *MockAdapter
+}
+
+func (a *OfflineAdapter) Name() string {
+	return a.spec.Name
+}
+
+func (a *OfflineAdapter) ModelHint() string {
+	return a.spec.Model
+}
+
+func (a *OfflineAdapter) AdminSpec() AdapterSpec {
+	return sanitizeAdapterSpec(a.spec)
+}
+
+func (a *OfflineAdapter) Complete(ctx context.Context, req orchestrator.Request) (orchestrator.Response, error) {
+	last := extractLastUserText(req.Messages)
+	fixture, ok := a.match(req.Model, last)
+	if !ok {
+		return a.mock.Complete(ctx, req)
+	}
+	if fixture.Error != "" {
+		return orchestrator.Response{}, fmt.Errorf("adapter %s offline fixture %q: %s", a.spec.Name, fixture.Name, fixture.Error)
+	}
+	resp := orchestrator.Response{
+		Model:      req.Model,
+		StopReason: fixture.StopReason,
+	}
+	outputTokens := 0
+	if fixture.Text != "" {
+		resp.Blocks = append(resp.Blocks, orchestrator.AssistantBlock{Type: "text", Text: fixture.Text})
+		outputTokens += estimateTokens(fixture.Text)
+	}
+	if fixture.ToolUse != nil {
+		resp.Blocks = append(resp.Blocks, orchestrator.AssistantBlock{
+			Type:  "tool_use",
+			ID:    "toolu_offline",
+			Name:  fixture.ToolUse.Name,
+			Input: copyAnyMap(fixture.ToolUse.Input),
+		})
+		outputTokens += 8
+		if resp.StopReason == "" {
+			resp.StopReason = "tool_use"
+		}
+	}
+	if resp.StopReason == "" {
+		resp.StopReason = "end_turn"
+	}
+	resp.Usage = orchestrator.Usage{InputTokens: estimateTokens(last), OutputTokens: max(outputTokens, 1)}
+	return resp, nil
+}
+
+func (a *OfflineAdapter) match(model, lastUserText string) (Fixture, bool) {
+	lower := strings.ToLower(lastUserText)
+	for _, f := range a.fixtures {
+		if f.Adapter != "" && !strings.EqualFold(f.Adapter, a.spec.Name) {
+			continue
+		}
+		if f.Model != "" {
+			if ok, _ := path.Match(f.Model, model); !ok {
+				continue
+			}
+		}
+		if f.Contains != "" && !strings.Contains(lower, strings.ToLower(f.Contains)) {
+			continue
+		}
+		return f, true
+	}
+	return Fixture{}, false
+}
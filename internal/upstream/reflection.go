@@ -78,6 +78,7 @@ func (s *RouterService) applyReflectionLoop(ctx context.Context, resp orchestrat
 			Messages: []orchestrator.Message{
 				{Role: "user", Content: fmt.Sprintf(reflectionFixPrompt, currentText, critique)},
 			},
+			ResponseFormat: req.ResponseFormat,
 			Metadata: map[string]any{
 				"reflection_pass":   pass + 1,
 				"reflection_phase":  "fix",
@@ -110,7 +111,7 @@ func (s *RouterService) applyReflectionLoop(ctx context.Context, resp orchestrat
 
 // completeOnce performs a single completion without reflection or parallel candidates.
 func (s *RouterService) completeOnce(ctx context.Context, req orchestrator.Request) (orchestrator.Response, error) {
-	candidates := s.routeForRequest(ctx, req)
+	candidates, _ := s.routeForRequest(ctx, req)
 	if s.selector != nil {
 		candidates = s.selector.Order(req, candidates, false)
 	}
@@ -0,0 +1,80 @@
+package upstream
+
+import (
+	"strings"
+
+	"ccgateway/internal/orchestrator"
+)
+
+const anthropicStructuredOutputsBeta = "structured-outputs-2025-11-13"
+
+func toOpenAIResponseFormat(f *orchestrator.ResponseFormat) (map[string]any, bool) {
+	if f == nil {
+		return nil, false
+	}
+	switch f.Type {
+	case "json_object":
+		return map[string]any{"type": "json_object"}, true
+	case "json_schema":
+		name := strings.TrimSpace(f.Name)
+		if name == "" {
+			name = "response"
+		}
+		spec := map[string]any{
+			"name":   name,
+			"schema": responseFormatSchema(f),
+		}
+		if f.Strict {
+			spec["strict"] = true
+		}
+		return map[string]any{"type": "json_schema", "json_schema": spec}, true
+	}
+	return nil, false
+}
+
+// toAnthropicOutputFormat maps to Anthropic structured outputs. json_object
+// has no native equivalent, so it is sent as a schema accepting any object.
+func toAnthropicOutputFormat(f *orchestrator.ResponseFormat) (map[string]any, bool) {
+	if !f.WantsJSON() {
+		return nil, false
+	}
+	return map[string]any{
+		"type":   "json_schema",
+		"schema": responseFormatSchema(f),
+	}, true
+}
+
+func applyGeminiResponseFormat(generationConfig map[string]any, f *orchestrator.ResponseFormat) {
+	if !f.WantsJSON() {
+		return
+	}
+	generationConfig["responseMimeType"] = "application/json"
+	if f.Type == "json_schema" && len(f.Schema) > 0 {
+		generationConfig["responseJsonSchema"] = f.Schema
+	}
+}
+
+func responseFormatSchema(f *orchestrator.ResponseFormat) map[string]any {
+	if f.Type == "json_schema" && len(f.Schema) > 0 {
+		return f.Schema
+	}
+	return map[string]any{"type": "object"}
+}
+
+// withAnthropicBeta returns a copy of headers with beta appended to the
+// anthropic-beta list, keeping any client-supplied betas.
+func withAnthropicBeta(headers map[string]string, beta string) map[string]string {
+	out := copyHeaders(headers)
+	current := strings.TrimSpace(out["anthropic-beta"])
+	for _, part := range strings.Split(current, ",") {
+		if strings.TrimSpace(part) == beta {
+			return out
+		}
+	}
+	if current == "" {
+		out["anthropic-beta"] = beta
+	} else {
+		out["anthropic-beta"] = current + "," + beta
+	}
+	return out
+}
@@ -0,0 +1,94 @@
+package upstream
+
+import (
+	"context"
+
+	"ccgateway/internal/orchestrator"
+)
+
+// RouteExplanation is how the router would pick adapters for a request,
+// worked out without calling any of them.
+type RouteExplanation struct {
+	// Source is where the route came from: "metadata" when the gateway set
+	// one (mode, canary, channel or pinned route), "dispatcher" or "static".
+	Source   string            `json:"source"`
+	Route    []string          `json:"route"`
+	Excluded []string          `json:"excluded,omitempty"`
+	Dispatch *DispatchDecision `json:"dispatch,omitempty"`
+	// Candidates is the route in the order the selector would try it.
+	Candidates []string `json:"candidates"`
+	// Unavailable lists candidates the selector currently rules out
+	// (cooldown, maintenance, probe gate).
+	Unavailable        []string `json:"unavailable,omitempty"`
+	Retries            int      `json:"retries"`
+	TimeoutMS          int64    `json:"timeout_ms"`
+	ParallelCandidates int      `json:"parallel_candidates"`
+	HedgeAfterMS       int      `json:"hedge_after_ms,omitempty"`
+}
+
+// ExplainRoute resolves the route of req the way Complete and Stream would.
+// The dispatcher is only previewed, so its stats and round-robin are left
+// as they were.
+func (s *RouterService) ExplainRoute(ctx context.Context, req orchestrator.Request, wantStream bool) RouteExplanation {
+	out := RouteExplanation{Source: "metadata", Route: routeFromMetadata(req.Metadata)}
+	s.mu.RLock()
+	if len(out.Route) == 0 {
+		out.Source = "static"
+		if s.dispatcher != nil {
+			if route, decision := s.dispatcher.Preview(ctx, req); len(route) > 0 {
+				out.Source = "dispatcher"
+				out.Route = route
+				out.Dispatch = decision
+			}
+		}
+		if out.Dispatch == nil {
+			out.Route = s.staticRouteLocked(req.Model)
+		}
+	}
+	out.Retries = s.retries
+	out.TimeoutMS = s.timeout.Milliseconds()
+	out.ParallelCandidates = s.parallelCandidates
+	s.mu.RUnlock()
+
+	if excluded := stringListFromMetadata(req.Metadata, "routing_exclude_adapters"); len(excluded) > 0 {
+		out.Excluded = excluded
+		kept := out.Route[:0:0]
+		for _, name := range out.Route {
+			if !containsString(excluded, name) {
+				kept = append(kept, name)
+			}
+		}
+		out.Route = kept
+	}
+	out.Candidates = out.Route
+	if s.selector != nil {
+		out.Candidates = s.selector.Order(req, out.Route, wantStream)
+		if checker, ok := s.selector.(interface {
+			Available(req orchestrator.Request, candidates []string, wantStream bool) []string
+		}); ok {
+			available := checker.Available(req, out.Route, wantStream)
+			for _, name := range out.Route {
+				if !containsString(available, name) {
+					out.Unavailable = append(out.Unavailable, name)
+				}
+			}
+		}
+	}
+
+	if v, ok := intFromAny(req.Metadata["routing_retries"]); ok && v >= 0 {
+		out.Retries = v
+	}
+	if ms, ok := intFromAny(req.Metadata["routing_timeout_ms"]); ok && ms > 0 {
+		out.TimeoutMS = int64(ms)
+	}
+	if v, ok := intFromAny(req.Metadata["parallel_candidates"]); ok && v > 0 {
+		out.ParallelCandidates = v
+	}
+	if out.ParallelCandidates > len(out.Candidates) {
+		out.ParallelCandidates = len(out.Candidates)
+	}
+	if delay := hedgeDelay(req, out.Candidates); delay > 0 && out.ParallelCandidates <= 1 {
+		out.HedgeAfterMS = int(delay.Milliseconds())
+	}
+	return out
+}
@@ -25,11 +25,33 @@ type RouterConfig struct {
 	Judge               CandidateJudge
 	Selector            CandidateSelector
 	Dispatcher          *Dispatcher
+	// DrainTimeout bounds how long a replaced adapter may keep serving
+	// in-flight calls before its transport is closed anyway.
+	DrainTimeout time.Duration
+	// Offline, when set, builds offline stand-ins instead of real adapters
+	// for every upstream config applied through the admin API.
+	Offline *OfflineMode
+	// JudgeHistory, when set, records the verdict of every judged
+	// contest for /admin/judge/report.
+	JudgeHistory *JudgeHistory
+	// CapabilityTTL is how long detected provider capabilities stay cached
+	// (default 1h).
+	CapabilityTTL time.Duration
+	// QueueTimeout bounds how long a call waits for a slot on an adapter
+	// at its max_concurrency before trying the next candidate (default 30s).
+	QueueTimeout time.Duration
+	// QueuePingInterval is how often a queued stream gets a "ping"
+	// keep-alive (default 5s).
+	QueuePingInterval time.Duration
 }
 
 type RouterService struct {
 	mu                 sync.RWMutex
-	adapters           map[string]Adapter
+	adapters           map[string]*managedAdapter
+	retired            []*managedAdapter
+	version            uint64
+	appliedAt          time.Time
+	drainTimeout       time.Duration
 	adapterSpecs       []AdapterSpec
 	adapterOrder       []string
 	routesExact        map[string][]string
@@ -41,8 +63,16 @@ type RouterService struct {
 	parallelCandidates int
 	enableJudge        bool
 	judge              CandidateJudge
+	judgeHistory       *JudgeHistory
 	selector           CandidateSelector
 	dispatcher         *Dispatcher
+	offline            *OfflineMode
+	capabilities       *CapabilityCache
+	queueTimeout       time.Duration
+	queuePing          time.Duration
+	onAttempt          func(adapter string, err error)
+	onShadow           func(ShadowResult)
+	streamLatency      *streamLatencies
 }
 
 type routePattern struct {
@@ -58,7 +88,7 @@ type CandidateSelector interface {
 }
 
 func NewRouterService(cfg RouterConfig, adapters []Adapter) *RouterService {
-	adapterMap := make(map[string]Adapter, len(adapters))
+	adapterMap := make(map[string]*managedAdapter, len(adapters))
 	order := make([]string, 0, len(adapters))
 	specs := make([]AdapterSpec, 0, len(adapters))
 	for _, a := range adapters {
@@ -69,7 +99,7 @@ func NewRouterService(cfg RouterConfig, adapters []Adapter) *RouterService {
 		if name == "" {
 			continue
 		}
-		adapterMap[name] = a
+		adapterMap[name] = newManagedAdapter(a, 1, ApplyActionAdded)
 		order = append(order, name)
 		specs = append(specs, snapshotAdapterSpec(a))
 	}
@@ -90,10 +120,21 @@ func NewRouterService(cfg RouterConfig, adapters []Adapter) *RouterService {
 	if judge == nil {
 		judge = NewHeuristicJudge()
 	}
+	drainTimeout := cfg.DrainTimeout
+	if drainTimeout <= 0 {
+		drainTimeout = defaultDrainTimeout
+	}
+	queueTimeout := cfg.QueueTimeout
+	if queueTimeout <= 0 {
+		queueTimeout = defaultQueueTimeout
+	}
 
 	exact, patterns := splitRoutes(cfg.Routes)
 	return &RouterService{
 		adapters:           adapterMap,
+		version:            1,
+		appliedAt:          time.Now().UTC(),
+		drainTimeout:       drainTimeout,
 		adapterSpecs:       specs,
 		adapterOrder:       order,
 		routesExact:        exact,
@@ -105,13 +146,46 @@ func NewRouterService(cfg RouterConfig, adapters []Adapter) *RouterService {
 		parallelCandidates: parallelCandidates,
 		enableJudge:        cfg.EnableResponseJudge,
 		judge:              judge,
+		judgeHistory:       cfg.JudgeHistory,
 		selector:           cfg.Selector,
 		dispatcher:         cfg.Dispatcher,
+		offline:            cfg.Offline,
+		capabilities:       NewCapabilityCache(cfg.CapabilityTTL),
+		queueTimeout:       queueTimeout,
+		queuePing:          cfg.QueuePingInterval,
+		streamLatency:      newStreamLatencies(),
 	}
 }
 
 func (s *RouterService) Complete(ctx context.Context, req orchestrator.Request) (orchestrator.Response, error) {
-	candidates := s.routeForRequest(ctx, req)
+	targets := modelTargets(req)
+	if len(targets) <= 1 {
+		return s.completeModel(ctx, req)
+	}
+	var lastErr error
+	for i, model := range targets {
+		attempt := targetRequest(req, model, i)
+		if i < len(targets)-1 && s.targetCooledDown(attempt, false) {
+			lastErr = fmt.Errorf("model %q: every adapter is cooling down", model)
+			continue
+		}
+		resp, err := s.completeModel(ctx, attempt)
+		if err == nil {
+			resp.Trace.FallbackUsed = resp.Trace.FallbackUsed || i > 0
+			observeModelTarget(ctx, ModelTarget{Model: model, Index: i, Targets: targets, LastError: errorText(lastErr)})
+			return resp, nil
+		}
+		if ctx.Err() != nil {
+			return orchestrator.Response{}, err
+		}
+		lastErr = fmt.Errorf("model %q: %w", model, err)
+	}
+	return orchestrator.Response{}, lastErr
+}
+
+func (s *RouterService) completeModel(ctx context.Context, req orchestrator.Request) (orchestrator.Response, error) {
+	candidates, decision := s.routeForRequest(ctx, req)
+	started := time.Now()
 	if s.selector != nil {
 		candidates = s.selector.Order(req, candidates, false)
 	}
@@ -149,12 +223,20 @@ func (s *RouterService) Complete(ctx context.Context, req orchestrator.Request)
 		parallelCandidates = len(candidates)
 	}
 
-	results, err := s.runCandidates(ctx, req, candidates, retries, timeout, parallelCandidates)
+	var results []candidateResult
+	var err error
+	if delay := hedgeDelay(req, candidates); delay > 0 && parallelCandidates <= 1 {
+		results, err = s.runHedged(ctx, req, candidates, retries, timeout, delay)
+	} else {
+		results, err = s.runCandidates(ctx, req, candidates, retries, timeout, parallelCandidates)
+	}
 	if err != nil {
+		s.recordDispatchOutcome(ctx, decision, "", started, err)
 		return orchestrator.Response{}, err
 	}
 
 	chosen := s.pickCandidate(ctx, req, results, enableJudge)
+	s.recordDispatchOutcome(ctx, decision, chosen.adapterName, started, nil)
 	chosen.resp.Trace.Provider = chosen.adapterName
 	chosen.resp.Trace.Model = req.Model
 	chosen.resp.Trace.FallbackUsed = chosen.order > 0
@@ -164,10 +246,53 @@ func (s *RouterService) Complete(ctx context.Context, req orchestrator.Request)
 	if reflectPasses > 0 {
 		chosen.resp = s.applyReflectionLoop(ctx, chosen.resp, req, reflectPasses)
 	}
+	s.mirror(req, chosen.candidateName, chosen.resp, chosen.latency)
 	return chosen.resp, nil
 }
 
 func (s *RouterService) Stream(ctx context.Context, req orchestrator.Request) (<-chan orchestrator.StreamEvent, <-chan error) {
+	targets := modelTargets(req)
+	if len(targets) <= 1 {
+		return s.streamModel(ctx, req)
+	}
+	events := make(chan orchestrator.StreamEvent, 16)
+	errs := make(chan error, 1)
+	go func() {
+		defer close(events)
+		defer close(errs)
+		var lastErr error
+		for i, model := range targets {
+			attempt := targetRequest(req, model, i)
+			if i < len(targets)-1 && s.targetCooledDown(attempt, true) {
+				lastErr = fmt.Errorf("model %q: every adapter is cooling down", model)
+				continue
+			}
+			// A target may fail over only until it produced content; queue
+			// pings are forwarded but do not commit to the target.
+			committed := false
+			modelEvents, modelErrs := s.streamModel(ctx, attempt)
+			for ev := range modelEvents {
+				if !committed && ev.Type != "ping" {
+					committed = true
+					observeModelTarget(ctx, ModelTarget{Model: model, Index: i, Targets: targets, LastError: errorText(lastErr)})
+				}
+				events <- ev
+			}
+			err := <-modelErrs
+			if err == nil || committed || ctx.Err() != nil {
+				if err != nil {
+					errs <- err
+				}
+				return
+			}
+			lastErr = fmt.Errorf("model %q: %w", model, err)
+		}
+		errs <- lastErr
+	}()
+	return events, errs
+}
+
+func (s *RouterService) streamModel(ctx context.Context, req orchestrator.Request) (<-chan orchestrator.StreamEvent, <-chan error) {
 	events := make(chan orchestrator.StreamEvent, 16)
 	errs := make(chan error, 1)
 
@@ -175,7 +300,7 @@ func (s *RouterService) Stream(ctx context.Context, req orchestrator.Request) (<
 		defer close(events)
 		defer close(errs)
 
-		candidates := s.routeForRequest(ctx, req)
+		candidates, decision := s.routeForRequest(ctx, req)
 		if s.selector != nil {
 			candidates = s.selector.Order(req, candidates, true)
 		}
@@ -183,7 +308,25 @@ func (s *RouterService) Stream(ctx context.Context, req orchestrator.Request) (<
 			errs <- fmt.Errorf("no upstream adapter available")
 			return
 		}
+		// Nested Complete calls below belong to this decision.
+		innerCtx := withoutDispatchRecord(ctx)
+		dispatchStarted := time.Now()
+		servedBy := ""
+		var failErr error
+		if decision != nil {
+			decision.Stream = true
+			defer func() {
+				s.recordDispatchOutcome(ctx, decision, servedBy, dispatchStarted, failErr)
+			}()
+		}
 
+		// release ends the in-flight mark on the adapter currently streaming.
+		var release func()
+		defer func() {
+			if release != nil {
+				release()
+			}
+		}()
 		var lastErr error
 		strict := boolFromAny(req.Metadata["strict_stream_passthrough"])
 		strictSoft := true
@@ -192,32 +335,71 @@ func (s *RouterService) Stream(ctx context.Context, req orchestrator.Request) (<
 				strictSoft = boolFromAny(v)
 			}
 		}
+		if delay := hedgeDelay(req, candidates); delay > 0 {
+			name, handled, err := s.streamHedged(ctx, req, candidates, delay, events)
+			if handled {
+				servedBy = name
+				if err != nil {
+					failErr = err
+					errs <- err
+				}
+				return
+			}
+			if err != nil {
+				lastErr = err
+				candidates = candidates[2:]
+			}
+		}
 		for _, name := range candidates {
 			s.mu.RLock()
-			adapter, ok := s.adapters[name]
+			managed, ok := s.adapters[name]
 			s.mu.RUnlock()
 			if !ok {
 				lastErr = fmt.Errorf("adapter %q not registered", name)
 				continue
 			}
 
-			streaming, ok := adapter.(StreamingAdapter)
+			streaming, ok := managed.adapter.(StreamingAdapter)
 			if !ok {
 				if s.selector != nil {
 					s.selector.ObserveFailure(name, req.Model, fmt.Errorf("adapter does not support streaming"))
 				}
-				resp, err := s.Complete(ctx, req)
+				resp, err := s.Complete(innerCtx, req)
 				if err != nil {
 					lastErr = err
 					continue
 				}
+				servedBy = resp.Trace.Provider
 				emitSyntheticStream(events, resp)
 				return
 			}
 
+			admission := s.admissionFor(req)
+			admission.onWait = func(depth int, waited time.Duration) {
+				select {
+				case events <- orchestrator.StreamEvent{Type: "ping", Queue: &orchestrator.QueueInfo{Adapter: name, Depth: depth, Waited: waited}}:
+				default:
+				}
+			}
+			admitted, err := managed.admit(ctx, admission)
+			if err != nil {
+				if ctx.Err() != nil {
+					failErr = ctx.Err()
+					errs <- ctx.Err()
+					return
+				}
+				lastErr = fmt.Errorf("adapter %q: %w", name, err)
+				continue
+			}
+			release = admitted
+			meter := newStreamMeter()
 			streamEvents, streamErrs := streaming.Stream(ctx, req)
 			streamStarted := time.Now()
 			started := false
+			var collected *streamCollector
+			if len(stringListFromMetadata(req.Metadata, "shadow_adapters")) > 0 {
+				collected = newStreamCollector()
+			}
 			evCh := streamEvents
 			errCh := streamErrs
 
@@ -231,6 +413,10 @@ func (s *RouterService) Stream(ctx context.Context, req orchestrator.Request) (<
 								if s.selector != nil {
 									s.selector.ObserveSuccess(name, req.Model, time.Since(streamStarted))
 								}
+								s.recordStreamLatency(name, req.Model, meter)
+								if collected != nil {
+									s.mirror(req, name, collected.response(req.Model), time.Since(streamStarted))
+								}
 								return
 							}
 							lastErr = fmt.Errorf("stream ended before any event from adapter %q", name)
@@ -241,7 +427,15 @@ func (s *RouterService) Stream(ctx context.Context, req orchestrator.Request) (<
 						}
 						continue
 					}
+					if !started {
+						s.observeAttempt(name, nil)
+					}
 					started = true
+					servedBy = name
+					meter.observe(ev)
+					if collected != nil {
+						collected.observe(ev)
+					}
 					events <- ev
 				case err, ok := <-errCh:
 					if !ok {
@@ -251,6 +445,10 @@ func (s *RouterService) Stream(ctx context.Context, req orchestrator.Request) (<
 								if s.selector != nil {
 									s.selector.ObserveSuccess(name, req.Model, time.Since(streamStarted))
 								}
+								s.recordStreamLatency(name, req.Model, meter)
+								if collected != nil {
+									s.mirror(req, name, collected.response(req.Model), time.Since(streamStarted))
+								}
 								return
 							}
 							lastErr = fmt.Errorf("stream closed without events from adapter %q", name)
@@ -268,11 +466,12 @@ func (s *RouterService) Stream(ctx context.Context, req orchestrator.Request) (<
 						if s.selector != nil {
 							s.selector.ObserveFailure(name, req.Model, err)
 						}
+						failErr = err
 						errs <- err
 						return
 					}
 					if strict && strictSoft && errors.Is(err, ErrStrictPassthroughUnsupported) {
-						resp, cErr := s.Complete(ctx, req)
+						resp, cErr := s.Complete(innerCtx, req)
 						if cErr != nil {
 							lastErr = cErr
 							if s.selector != nil {
@@ -283,10 +482,12 @@ func (s *RouterService) Stream(ctx context.Context, req orchestrator.Request) (<
 						if s.selector != nil {
 							s.selector.ObserveSuccess(name, req.Model, time.Since(streamStarted))
 						}
+						servedBy = resp.Trace.Provider
 						emitSyntheticStream(events, resp)
 						return
 					}
 					lastErr = err
+					s.observeAttempt(name, err)
 					if s.selector != nil {
 						s.selector.ObserveFailure(name, req.Model, err)
 					}
@@ -295,16 +496,20 @@ func (s *RouterService) Stream(ctx context.Context, req orchestrator.Request) (<
 					if s.selector != nil {
 						s.selector.ObserveFailure(name, req.Model, ctx.Err())
 					}
+					failErr = ctx.Err()
 					errs <- ctx.Err()
 					return
 				}
 			}
 		nextAdapter:
+			release()
+			release = nil
 		}
 
 		if lastErr == nil {
 			lastErr = fmt.Errorf("all adapters failed")
 		}
+		failErr = lastErr
 		errs <- lastErr
 	}()
 
@@ -384,7 +589,7 @@ func (s *RouterService) runCandidate(
 	timeout time.Duration,
 ) candidateResult {
 	s.mu.RLock()
-	adapter, ok := s.adapters[name]
+	managed, ok := s.adapters[name]
 	s.mu.RUnlock()
 	if !ok {
 		return candidateResult{
@@ -395,12 +600,31 @@ func (s *RouterService) runCandidate(
 		}
 	}
 
+	adapter := managed.adapter
+	release, err := managed.admit(ctx, s.admissionFor(req))
+	if err != nil {
+		return candidateResult{
+			candidateName: name,
+			adapterName:   adapter.Name(),
+			order:         order,
+			err:           fmt.Errorf("adapter %q: %w", name, err),
+		}
+	}
+	defer release()
+
 	var lastErr error
 	for attempt := 0; attempt <= retries; attempt++ {
 		started := time.Now()
 		attemptCtx, cancel := context.WithTimeout(ctx, timeout)
 		resp, err := adapter.Complete(attemptCtx, req)
 		cancel()
+		if err != nil && ctx.Err() != nil {
+			// Canceled by the caller, e.g. a hedge that lost the race; that
+			// says nothing about the adapter.
+			lastErr = err
+			break
+		}
+		s.observeAttempt(name, err)
 		if err != nil {
 			if s.selector != nil {
 				s.selector.ObserveFailure(name, req.Model, err)
@@ -449,7 +673,7 @@ func (s *RouterService) pickCandidate(ctx context.Context, req orchestrator.Requ
 				Order:       c.order,
 			})
 		}
-		idx, err := s.judge.Select(ctx, req, judged)
+		idx, err := s.judgeCandidates(ctx, req, judged)
 		if err == nil && idx >= 0 && idx < len(candidates) {
 			chosen := candidates[idx]
 			chosen.selectedBy = "judge"
@@ -503,19 +727,43 @@ func emitSyntheticStream(events chan<- orchestrator.StreamEvent, resp orchestrat
 	events <- orchestrator.StreamEvent{Type: "message_stop"}
 }
 
-func (s *RouterService) routeForRequest(ctx context.Context, req orchestrator.Request) []string {
+// routeForRequest returns the candidate order and, when the dispatcher
+// picked it, the decision to complete with recordDispatchOutcome.
+// routeForRequest returns the candidate adapters for req, without the ones
+// listed in "routing_exclude_adapters".
+func (s *RouterService) routeForRequest(ctx context.Context, req orchestrator.Request) ([]string, *DispatchDecision) {
+	route, decision := s.baseRouteForRequest(ctx, req)
+	excluded := stringListFromMetadata(req.Metadata, "routing_exclude_adapters")
+	if len(excluded) == 0 {
+		return route, decision
+	}
+	out := route[:0:0]
+	for _, name := range route {
+		if !containsString(excluded, name) {
+			out = append(out, name)
+		}
+	}
+	return out, decision
+}
+
+func (s *RouterService) baseRouteForRequest(ctx context.Context, req orchestrator.Request) ([]string, *DispatchDecision) {
 	if route := routeFromMetadata(req.Metadata); len(route) > 0 {
-		return route
+		return route, nil
 	}
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	// Dispatcher-based routing: if enabled and election is done, use it
 	if s.dispatcher != nil {
-		if dispatched := s.dispatcher.RouteRequest(ctx, req, s.adapterOrder); len(dispatched) > 0 {
-			return dispatched
+		if dispatched, decision := s.dispatcher.Decide(ctx, req); len(dispatched) > 0 {
+			return dispatched, decision
 		}
 	}
-	model := req.Model
+	return s.staticRouteLocked(req.Model), nil
+}
+
+// staticRouteLocked resolves the configured route for model: exact routes,
+// then patterns, then "*", the default route and finally every adapter.
+func (s *RouterService) staticRouteLocked(model string) []string {
 	if seq, ok := s.routesExact[model]; ok && len(seq) > 0 {
 		return append([]string(nil), seq...)
 	}
@@ -537,6 +785,25 @@ func (s *RouterService) routeForRequest(ctx context.Context, req orchestrator.Re
 	return append([]string(nil), s.adapterOrder...)
 }
 
+type skipDispatchRecordKey struct{}
+
+func withoutDispatchRecord(ctx context.Context) context.Context {
+	return context.WithValue(ctx, skipDispatchRecordKey{}, true)
+}
+
+func (s *RouterService) recordDispatchOutcome(ctx context.Context, decision *DispatchDecision, servedBy string, started time.Time, err error) {
+	if decision == nil {
+		return
+	}
+	if skip, _ := ctx.Value(skipDispatchRecordKey{}).(bool); skip {
+		return
+	}
+	s.mu.RLock()
+	dispatcher := s.dispatcher
+	s.mu.RUnlock()
+	dispatcher.RecordOutcome(decision, servedBy, time.Since(started), err)
+}
+
 func splitRoutes(in map[string][]string) (map[string][]string, []routePattern) {
 	exact := map[string][]string{}
 	patterns := make([]routePattern, 0)
@@ -572,11 +839,27 @@ func (s *RouterService) snapshotUpstreamConfig(maskSecrets bool) UpstreamAdminCo
 		Adapters:     cloneAdapterSpecs(s.adapterSpecs, maskSecrets),
 		DefaultRoute: append([]string(nil), s.defaultRoute...),
 		ModelRoutes:  composeRoutesForAdmin(s.routesExact, s.routePatterns),
+		Offline:      s.offline != nil,
 	}
 	return out
 }
 
-func (s *RouterService) UpdateUpstreamConfig(cfg UpstreamAdminConfig) (UpstreamAdminConfig, error) {
+// preparedUpstream is a validated upstream config with its adapters built
+// but not yet serving.
+type preparedUpstream struct {
+	adapters     []Adapter
+	adapterMap   map[string]Adapter
+	order        []string
+	specs        []AdapterSpec
+	routes       map[string][]string
+	defaultRoute []string
+}
+
+// prepareUpstreamConfig fills the gaps in cfg from the running config,
+// builds its adapters and checks every route against them. An adapter
+// whose api_key is the "***" mask from GetUpstreamConfig keeps the key of
+// the running adapter with the same name.
+func (s *RouterService) prepareUpstreamConfig(cfg UpstreamAdminConfig) (preparedUpstream, error) {
 	current := s.snapshotUpstreamConfig(false)
 	if len(cfg.Adapters) == 0 {
 		cfg.Adapters = current.Adapters
@@ -587,13 +870,28 @@ func (s *RouterService) UpdateUpstreamConfig(cfg UpstreamAdminConfig) (UpstreamA
 	if len(cfg.DefaultRoute) == 0 {
 		cfg.DefaultRoute = current.DefaultRoute
 	}
+	currentKeys := make(map[string]string, len(current.Adapters))
+	for _, spec := range current.Adapters {
+		currentKeys[strings.TrimSpace(spec.Name)] = spec.APIKey
+	}
+	specsIn := make([]AdapterSpec, len(cfg.Adapters))
+	copy(specsIn, cfg.Adapters)
+	for i := range specsIn {
+		if strings.TrimSpace(specsIn[i].APIKey) == "***" {
+			specsIn[i].APIKey = currentKeys[strings.TrimSpace(specsIn[i].Name)]
+		}
+	}
 
-	adapters, err := BuildAdaptersFromSpecs(cfg.Adapters)
+	build := BuildAdaptersFromSpecs
+	if s.offline != nil {
+		build = s.offline.BuildAdapters
+	}
+	adapters, err := build(specsIn)
 	if err != nil {
-		return UpstreamAdminConfig{}, err
+		return preparedUpstream{}, err
 	}
 	if len(adapters) == 0 {
-		return UpstreamAdminConfig{}, fmt.Errorf("at least one adapter is required")
+		return preparedUpstream{}, fmt.Errorf("at least one adapter is required")
 	}
 
 	adapterMap := make(map[string]Adapter, len(adapters))
@@ -612,7 +910,8 @@ func (s *RouterService) UpdateUpstreamConfig(cfg UpstreamAdminConfig) (UpstreamA
 		specs = append(specs, snapshotAdapterSpec(adapter))
 	}
 	if len(order) == 0 {
-		return UpstreamAdminConfig{}, fmt.Errorf("no valid adapters")
+		closeAdapters(adapters)
+		return preparedUpstream{}, fmt.Errorf("no valid adapters")
 	}
 
 	routes := cleanModelRoutes(cfg.ModelRoutes)
@@ -622,7 +921,8 @@ func (s *RouterService) UpdateUpstreamConfig(cfg UpstreamAdminConfig) (UpstreamA
 		}
 		for _, adapterName := range route {
 			if _, ok := adapterMap[adapterName]; !ok {
-				return UpstreamAdminConfig{}, fmt.Errorf("route %q references unknown adapter %q", model, adapterName)
+				closeAdapters(adapters)
+				return preparedUpstream{}, fmt.Errorf("route %q references unknown adapter %q", model, adapterName)
 			}
 		}
 	}
@@ -633,24 +933,144 @@ func (s *RouterService) UpdateUpstreamConfig(cfg UpstreamAdminConfig) (UpstreamA
 	}
 	for _, adapterName := range defaultRoute {
 		if _, ok := adapterMap[adapterName]; !ok {
-			return UpstreamAdminConfig{}, fmt.Errorf("default route references unknown adapter %q", adapterName)
+			closeAdapters(adapters)
+			return preparedUpstream{}, fmt.Errorf("default route references unknown adapter %q", adapterName)
 		}
 	}
+	return preparedUpstream{
+		adapters:     adapters,
+		adapterMap:   adapterMap,
+		order:        order,
+		specs:        specs,
+		routes:       routes,
+		defaultRoute: defaultRoute,
+	}, nil
+}
+
+// ValidateUpstreamConfig reports whether UpdateUpstreamConfig would accept
+// cfg, without changing anything.
+func (s *RouterService) ValidateUpstreamConfig(cfg UpstreamAdminConfig) error {
+	prepared, err := s.prepareUpstreamConfig(cfg)
+	if err != nil {
+		return err
+	}
+	closeAdapters(prepared.adapters)
+	return nil
+}
+
+// UpstreamConfigSnapshot returns the running config, with api keys masked
+// unless maskSecrets is false.
+func (s *RouterService) UpstreamConfigSnapshot(maskSecrets bool) UpstreamAdminConfig {
+	return s.snapshotUpstreamConfig(maskSecrets)
+}
+
+func (s *RouterService) UpdateUpstreamConfig(cfg UpstreamAdminConfig) (UpstreamAdminConfig, error) {
+	prepared, err := s.prepareUpstreamConfig(cfg)
+	if err != nil {
+		return UpstreamAdminConfig{}, err
+	}
+	adapterMap := prepared.adapterMap
+	order := prepared.order
+	specs := prepared.specs
+	routes := prepared.routes
+	defaultRoute := prepared.defaultRoute
 
 	exact, patterns := splitRoutes(routes)
 
 	s.mu.Lock()
-	s.adapters = adapterMap
+	// Build the next adapter set: instances whose spec is unchanged are
+	// kept (with their warm connection pools); replaced and removed ones
+	// keep serving the calls they already accepted and are closed once
+	// drained, while new traffic only sees the new set.
+	version := s.version + 1
+	next := make(map[string]*managedAdapter, len(adapterMap))
+	var retiring []*managedAdapter
+	for name, adapter := range adapterMap {
+		prev, existed := s.adapters[name]
+		switch {
+		case existed && prev.specKey == adapterSpecKey(adapter):
+			next[name] = prev
+			prev.mu.Lock()
+			prev.action = ApplyActionUnchanged
+			prev.mu.Unlock()
+			closeAdapter(adapter)
+		case existed:
+			next[name] = newManagedAdapter(adapter, version, ApplyActionReplaced)
+			retiring = append(retiring, prev)
+		default:
+			next[name] = newManagedAdapter(adapter, version, ApplyActionAdded)
+		}
+	}
+	for name, prev := range s.adapters {
+		if _, kept := adapterMap[name]; !kept {
+			prev.mu.Lock()
+			prev.action = ApplyActionRemoved
+			prev.mu.Unlock()
+			retiring = append(retiring, prev)
+		}
+	}
+	retired := make([]*managedAdapter, 0, len(s.retired)+len(retiring))
+	for _, m := range s.retired {
+		if !m.isClosed() {
+			retired = append(retired, m)
+		}
+	}
+	retired = append(retired, retiring...)
+	for _, m := range retiring {
+		m.setState(AdapterStateDraining)
+	}
+	drainTimeout := s.drainTimeout
+	s.adapters = next
+	s.retired = retired
+	s.version = version
+	s.appliedAt = time.Now().UTC()
 	s.adapterOrder = order
 	s.adapterSpecs = specs
 	s.defaultRoute = defaultRoute
 	s.routesExact = exact
 	s.routePatterns = patterns
 	s.mu.Unlock()
+	s.capabilities.Sync(prepared.adapters)
 
+	for _, m := range retiring {
+		go m.drain(drainTimeout)
+	}
 	return s.GetUpstreamConfig(), nil
 }
 
+// OfflineStatus reports whether adapters are offline stand-ins.
+func (s *RouterService) OfflineStatus() OfflineStatus {
+	return s.offline.Status()
+}
+
+// ApplyStatus reports every live adapter instance and any retired instance
+// from earlier applies that is still draining or was closed since.
+func (s *RouterService) ApplyStatus() UpstreamApplyStatus {
+	s.mu.RLock()
+	out := UpstreamApplyStatus{
+		Version:   s.version,
+		AppliedAt: s.appliedAt,
+		Adapters:  make([]AdapterApplyStatus, 0, len(s.adapters)+len(s.retired)),
+	}
+	for _, m := range s.adapters {
+		out.Adapters = append(out.Adapters, m.status())
+	}
+	for _, m := range s.retired {
+		out.Adapters = append(out.Adapters, m.status())
+	}
+	s.mu.RUnlock()
+	sortApplyStatuses(out.Adapters)
+	return out
+}
+
+func closeAdapters(adapters []Adapter) {
+	for _, adapter := range adapters {
+		if adapter != nil {
+			closeAdapter(adapter)
+		}
+	}
+}
+
 func cloneAdapterSpecs(in []AdapterSpec, maskSecrets bool) []AdapterSpec {
 	if len(in) == 0 {
 		return nil
@@ -684,10 +1104,14 @@ func composeRoutesForAdmin(exact map[string][]string, patterns []routePattern) m
 }
 
 func routeFromMetadata(metadata map[string]any) []string {
+	return stringListFromMetadata(metadata, "routing_adapter_route")
+}
+
+func stringListFromMetadata(metadata map[string]any, key string) []string {
 	if metadata == nil {
 		return nil
 	}
-	raw, ok := metadata["routing_adapter_route"]
+	raw, ok := metadata[key]
 	if !ok {
 		return nil
 	}
@@ -803,6 +1227,18 @@ func (s *RouterService) GetDispatchStatus() map[string]any {
 	return dispatcher.Snapshot()
 }
 
+// DispatchAnalytics summarizes persisted dispatch decisions and elections.
+func (s *RouterService) DispatchAnalytics(q DispatchAnalyticsQuery) (DispatchAnalytics, error) {
+	s.mu.RLock()
+	dispatcher := s.dispatcher
+	s.mu.RUnlock()
+
+	if dispatcher == nil {
+		return DispatchAnalytics{}, fmt.Errorf("dispatcher is not configured")
+	}
+	return dispatcher.Analytics(q)
+}
+
 // TriggerDispatchRerun triggers a manual re-election
 func (s *RouterService) TriggerDispatchRerun() error {
 	s.mu.RLock()
@@ -0,0 +1,58 @@
+package upstream
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+)
+
+// Upstream status codes that signal saturation rather than a broken
+// request: rate limiting and Anthropic's "overloaded".
+const (
+	StatusRateLimited = 429
+	StatusOverloaded  = 529
+)
+
+var upstreamStatusPattern = regexp.MustCompile(`upstream status (\d{3})`)
+
+// StatusError carries the HTTP status an upstream answered with.
+type StatusError interface {
+	error
+	UpstreamStatus() int
+}
+
+// SaturationStatus returns 429 or 529 when err says the upstream is
+// saturated, and 0 otherwise.
+func SaturationStatus(err error) int {
+	if err == nil {
+		return 0
+	}
+	status := 0
+	var se StatusError
+	if errors.As(err, &se) {
+		status = se.UpstreamStatus()
+	} else if m := upstreamStatusPattern.FindStringSubmatch(err.Error()); m != nil {
+		status, _ = strconv.Atoi(m[1])
+	}
+	if status == StatusRateLimited || status == StatusOverloaded {
+		return status
+	}
+	return 0
+}
+
+// OnUpstreamAttempt registers fn to hear about every adapter call: err is
+// nil on success. fn runs on the request path and must return quickly.
+func (s *RouterService) OnUpstreamAttempt(fn func(adapter string, err error)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onAttempt = fn
+}
+
+func (s *RouterService) observeAttempt(adapter string, err error) {
+	s.mu.RLock()
+	fn := s.onAttempt
+	s.mu.RUnlock()
+	if fn != nil {
+		fn(adapter, err)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"os"
 	"os/exec"
 	"strings"
+	"sync"
 	"time"
 
 	"ccgateway/internal/orchestrator"
@@ -44,6 +45,9 @@ const (
 // {"type":"event","event":{...stream event...}}
 // {"type":"response","response":{...final canonical response...}}
 // If stream mode is not implemented, returning a single complete response is also supported.
+//
+// With Persistent set, one long-lived process serves every call over
+// newline-delimited JSON-RPC instead; see script_rpc.go.
 type ScriptAdapterConfig struct {
 	Name           string            `json:"name"`
 	Command        string            `json:"command"`
@@ -55,6 +59,8 @@ type ScriptAdapterConfig struct {
 	SupportsTools  *bool             `json:"supports_tools,omitempty"`
 	TimeoutMS      int               `json:"timeout_ms,omitempty"`
 	MaxOutputBytes int               `json:"max_output_bytes,omitempty"`
+	MaxConcurrency int               `json:"max_concurrency,omitempty"`
+	Persistent     bool              `json:"persistent,omitempty"`
 }
 
 type ScriptAdapter struct {
@@ -68,6 +74,11 @@ type ScriptAdapter struct {
 	supportsTools  *bool
 	timeout        time.Duration
 	maxOutputBytes int
+	maxConcurrency int
+	persistent     bool
+
+	procMu sync.Mutex
+	proc   *scriptProcess
 }
 
 func NewScriptAdapter(cfg ScriptAdapterConfig) (*ScriptAdapter, error) {
@@ -99,6 +110,8 @@ func NewScriptAdapter(cfg ScriptAdapterConfig) (*ScriptAdapter, error) {
 		supportsTools:  cloneBoolPtr(cfg.SupportsTools),
 		timeout:        timeout,
 		maxOutputBytes: maxOutput,
+		maxConcurrency: cfg.MaxConcurrency,
+		persistent:     cfg.Persistent,
 	}, nil
 }
 
@@ -124,10 +137,15 @@ func (a *ScriptAdapter) AdminSpec() AdapterSpec {
 		SupportsTools:  cloneBoolPtr(a.supportsTools),
 		TimeoutMS:      timeoutMS,
 		MaxOutputBytes: a.maxOutputBytes,
+		MaxConcurrency: a.maxConcurrency,
+		Persistent:     a.persistent,
 	}
 }
 
 func (a *ScriptAdapter) Complete(ctx context.Context, req orchestrator.Request) (orchestrator.Response, error) {
+	if a.persistent {
+		return a.completeRPC(ctx, req)
+	}
 	runCtx, cancel := a.withTimeout(ctx)
 	defer cancel()
 
@@ -157,6 +175,13 @@ func (a *ScriptAdapter) Stream(ctx context.Context, req orchestrator.Request) (<
 		defer close(events)
 		defer close(errs)
 
+		if a.persistent {
+			if err := a.streamRPC(ctx, req, events); err != nil {
+				errs <- err
+			}
+			return
+		}
+
 		runCtx, cancel := a.withTimeout(ctx)
 		defer cancel()
 
@@ -306,14 +331,15 @@ type scriptEnvelope struct {
 }
 
 type scriptRequestInput struct {
-	RunID     string               `json:"run_id,omitempty"`
-	Model     string               `json:"model"`
-	MaxTokens int                  `json:"max_tokens"`
-	System    any                  `json:"system,omitempty"`
-	Messages  []scriptMessageInput `json:"messages"`
-	Tools     []scriptToolInput    `json:"tools,omitempty"`
-	Metadata  map[string]any       `json:"metadata,omitempty"`
-	Headers   map[string]string    `json:"headers,omitempty"`
+	RunID          string                       `json:"run_id,omitempty"`
+	Model          string                       `json:"model"`
+	MaxTokens      int                          `json:"max_tokens"`
+	System         any                          `json:"system,omitempty"`
+	Messages       []scriptMessageInput         `json:"messages"`
+	Tools          []scriptToolInput            `json:"tools,omitempty"`
+	ResponseFormat *orchestrator.ResponseFormat `json:"response_format,omitempty"`
+	Metadata       map[string]any               `json:"metadata,omitempty"`
+	Headers        map[string]string            `json:"headers,omitempty"`
 }
 
 type scriptMessageInput struct {
@@ -348,14 +374,15 @@ func buildScriptRequest(req orchestrator.Request) scriptRequestInput {
 		})
 	}
 	return scriptRequestInput{
-		RunID:     strings.TrimSpace(req.RunID),
-		Model:     strings.TrimSpace(req.Model),
-		MaxTokens: req.MaxTokens,
-		System:    req.System,
-		Messages:  messages,
-		Tools:     tools,
-		Metadata:  copyAnyMap(req.Metadata),
-		Headers:   copyHeaders(req.Headers),
+		RunID:          strings.TrimSpace(req.RunID),
+		Model:          strings.TrimSpace(req.Model),
+		MaxTokens:      req.MaxTokens,
+		System:         req.System,
+		Messages:       messages,
+		Tools:          tools,
+		ResponseFormat: req.ResponseFormat,
+		Metadata:       copyAnyMap(req.Metadata),
+		Headers:        copyHeaders(req.Headers),
 	}
 }
 
@@ -0,0 +1,393 @@
+package upstream
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"ccgateway/internal/orchestrator"
+)
+
+// Persistent script adapters keep one process running and exchange
+// newline-delimited JSON-RPC 2.0 messages with it instead of spawning a
+// process per call.
+//
+// The gateway writes one request per line:
+//
+//	{"jsonrpc":"2.0","id":7,"method":"complete"|"stream","params":{"version":"ccgateway.script_adapter.v1","request":{...}}}
+//
+// and answers with {"id":7,"result":{...response...}} or
+// {"id":7,"error":{"code":-32000,"message":"..."}}. While streaming, the
+// script sends events before the result as notifications:
+//
+//	{"jsonrpc":"2.0","method":"event","params":{"id":7,"event":{...stream event...}}}
+//
+// A stream result may be null once events were sent; otherwise it is
+// turned into events like a complete response. Calls may be answered in
+// any order. When a caller gives up, the gateway sends
+// {"jsonrpc":"2.0","method":"cancel","params":{"id":7}}, which scripts are
+// free to ignore. A process that exits is restarted on the next call.
+
+const scriptStderrTailBytes = 4 << 10
+
+type scriptRPCRequest struct {
+	JSONRPC string  `json:"jsonrpc"`
+	ID      *uint64 `json:"id,omitempty"`
+	Method  string  `json:"method"`
+	Params  any     `json:"params"`
+}
+
+type scriptRPCParams struct {
+	Version string             `json:"version"`
+	Request scriptRequestInput `json:"request"`
+}
+
+type scriptRPCMessage struct {
+	ID     *uint64         `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *scriptRPCError `json:"error"`
+}
+
+type scriptRPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type scriptProcess struct {
+	name    string
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	writeMu sync.Mutex
+	stderr  *tailBuffer
+
+	mu      sync.Mutex
+	nextID  uint64
+	pending map[uint64]*scriptCall
+	done    chan struct{}
+	err     error
+}
+
+type scriptCall struct {
+	id   uint64
+	msgs chan scriptRPCMessage
+	done chan struct{}
+	once sync.Once
+}
+
+func (a *ScriptAdapter) startProcess() (*scriptProcess, error) {
+	cmd := exec.Command(a.command, a.args...)
+	cmd.Env = mergeEnv(os.Environ(), a.env)
+	if a.workDir != "" {
+		cmd.Dir = a.workDir
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("script adapter %q stdin pipe failed: %w", a.name, err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("script adapter %q stdout pipe failed: %w", a.name, err)
+	}
+	p := &scriptProcess{
+		name:    a.name,
+		cmd:     cmd,
+		stdin:   stdin,
+		stderr:  &tailBuffer{limit: scriptStderrTailBytes},
+		pending: make(map[uint64]*scriptCall),
+		done:    make(chan struct{}),
+	}
+	cmd.Stderr = p.stderr
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("script adapter %q start failed: %w", a.name, err)
+	}
+	go p.readLoop(stdout, a.maxOutputBytes)
+	return p, nil
+}
+
+// process returns the running process, starting one if there is none or
+// the previous one has exited.
+func (a *ScriptAdapter) process() (*scriptProcess, error) {
+	a.procMu.Lock()
+	defer a.procMu.Unlock()
+	if a.proc != nil {
+		select {
+		case <-a.proc.done:
+		default:
+			return a.proc, nil
+		}
+	}
+	p, err := a.startProcess()
+	if err != nil {
+		return nil, err
+	}
+	a.proc = p
+	return p, nil
+}
+
+// Close stops the persistent process, if any.
+func (a *ScriptAdapter) Close() error {
+	a.procMu.Lock()
+	p := a.proc
+	a.proc = nil
+	a.procMu.Unlock()
+	if p != nil {
+		p.kill()
+	}
+	return nil
+}
+
+func (p *scriptProcess) readLoop(stdout io.Reader, maxLine int) {
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLine)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var msg scriptRPCMessage
+		if err := json.Unmarshal(line, &msg); err != nil {
+			// Stray output such as a print left in the script; ignore it
+			// rather than failing every call in flight.
+			continue
+		}
+		id := msg.ID
+		if id == nil && msg.Method == "event" {
+			var params struct {
+				ID *uint64 `json:"id"`
+			}
+			_ = json.Unmarshal(msg.Params, &params)
+			id = params.ID
+		}
+		if id == nil {
+			continue
+		}
+		p.mu.Lock()
+		call := p.pending[*id]
+		p.mu.Unlock()
+		if call == nil {
+			continue
+		}
+		select {
+		case call.msgs <- msg:
+		case <-call.done:
+		}
+	}
+	err := scanner.Err()
+	if err != nil {
+		_ = p.cmd.Process.Kill()
+	}
+	waitErr := p.cmd.Wait()
+	if err == nil {
+		err = waitErr
+	}
+	if err == nil {
+		err = io.EOF
+	}
+	p.mu.Lock()
+	p.err = err
+	p.mu.Unlock()
+	close(p.done)
+}
+
+func (p *scriptProcess) kill() {
+	select {
+	case <-p.done:
+		return
+	default:
+	}
+	_ = p.stdin.Close()
+	_ = p.cmd.Process.Kill()
+	<-p.done
+}
+
+func (p *scriptProcess) exitError() error {
+	p.mu.Lock()
+	err := p.err
+	p.mu.Unlock()
+	return withScriptStderr(fmt.Errorf("script adapter %q process exited: %v", p.name, err), p.stderr.String())
+}
+
+func (p *scriptProcess) send(req scriptRPCRequest) error {
+	line, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	p.writeMu.Lock()
+	defer p.writeMu.Unlock()
+	_, err = p.stdin.Write(append(line, '\n'))
+	return err
+}
+
+func (p *scriptProcess) call(method string, req orchestrator.Request) (*scriptCall, error) {
+	p.mu.Lock()
+	p.nextID++
+	call := &scriptCall{id: p.nextID, msgs: make(chan scriptRPCMessage, 16), done: make(chan struct{})}
+	p.pending[call.id] = call
+	p.mu.Unlock()
+
+	id := call.id
+	err := p.send(scriptRPCRequest{
+		JSONRPC: "2.0",
+		ID:      &id,
+		Method:  method,
+		Params:  scriptRPCParams{Version: scriptAdapterProtocolVersion, Request: buildScriptRequest(req)},
+	})
+	if err != nil {
+		p.finish(call)
+		return nil, fmt.Errorf("script adapter %q write request failed: %w", p.name, err)
+	}
+	return call, nil
+}
+
+func (p *scriptProcess) finish(call *scriptCall) {
+	call.once.Do(func() {
+		p.mu.Lock()
+		delete(p.pending, call.id)
+		p.mu.Unlock()
+		close(call.done)
+	})
+}
+
+// abandon drops a call the caller stopped waiting for and tells the script.
+func (p *scriptProcess) abandon(call *scriptCall) {
+	p.finish(call)
+	_ = p.send(scriptRPCRequest{JSONRPC: "2.0", Method: "cancel", Params: map[string]uint64{"id": call.id}})
+}
+
+// next waits for the next message of call.
+func (p *scriptProcess) next(ctx context.Context, call *scriptCall) (scriptRPCMessage, error) {
+	select {
+	case msg := <-call.msgs:
+		if msg.Error != nil {
+			p.finish(call)
+			return msg, fmt.Errorf("script adapter %q rpc error %d: %s", p.name, msg.Error.Code, msg.Error.Message)
+		}
+		if msg.ID != nil {
+			p.finish(call)
+		}
+		return msg, nil
+	case <-p.done:
+		p.finish(call)
+		return scriptRPCMessage{}, p.exitError()
+	case <-ctx.Done():
+		p.abandon(call)
+		return scriptRPCMessage{}, ctx.Err()
+	}
+}
+
+func (a *ScriptAdapter) completeRPC(ctx context.Context, req orchestrator.Request) (orchestrator.Response, error) {
+	runCtx, cancel := a.withTimeout(ctx)
+	defer cancel()
+
+	p, err := a.process()
+	if err != nil {
+		return orchestrator.Response{}, err
+	}
+	call, err := p.call("complete", req)
+	if err != nil {
+		return orchestrator.Response{}, err
+	}
+	for {
+		msg, err := p.next(runCtx, call)
+		if err != nil {
+			return orchestrator.Response{}, err
+		}
+		if msg.ID == nil {
+			continue
+		}
+		resp, err := decodeScriptResponse(msg.Result)
+		if err != nil {
+			return orchestrator.Response{}, fmt.Errorf("script adapter %q response decode failed: %w", a.name, err)
+		}
+		if strings.TrimSpace(resp.Model) == "" {
+			resp.Model = req.Model
+		}
+		return resp, nil
+	}
+}
+
+func (a *ScriptAdapter) streamRPC(ctx context.Context, req orchestrator.Request, events chan<- orchestrator.StreamEvent) error {
+	runCtx, cancel := a.withTimeout(ctx)
+	defer cancel()
+
+	p, err := a.process()
+	if err != nil {
+		return err
+	}
+	call, err := p.call("stream", req)
+	if err != nil {
+		return err
+	}
+	emitted := false
+	for {
+		msg, err := p.next(runCtx, call)
+		if err != nil {
+			return err
+		}
+		if msg.ID == nil {
+			var params struct {
+				Event json.RawMessage `json:"event"`
+			}
+			if err := json.Unmarshal(msg.Params, &params); err != nil || len(params.Event) == 0 {
+				p.abandon(call)
+				return fmt.Errorf("script adapter %q stream decode failed: event notification without event", a.name)
+			}
+			ev, err := decodeStreamEvent(params.Event)
+			if err != nil {
+				p.abandon(call)
+				return fmt.Errorf("script adapter %q stream decode failed: %w", a.name, err)
+			}
+			events <- *ev
+			emitted = true
+			continue
+		}
+		if emitted {
+			return nil
+		}
+		result := bytes.TrimSpace(msg.Result)
+		if len(result) == 0 || bytes.Equal(result, []byte("null")) {
+			return fmt.Errorf("script adapter %q stream produced no events or response", a.name)
+		}
+		resp, err := decodeScriptResponse(result)
+		if err != nil {
+			return fmt.Errorf("script adapter %q response decode failed: %w", a.name, err)
+		}
+		if strings.TrimSpace(resp.Model) == "" {
+			resp.Model = req.Model
+		}
+		emitResponseAsStream(events, resp)
+		return nil
+	}
+}
+
+// tailBuffer keeps the last limit bytes written to it.
+type tailBuffer struct {
+	mu    sync.Mutex
+	limit int
+	buf   []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf = append(b.buf, p...)
+	if over := len(b.buf) - b.limit; over > 0 {
+		b.buf = append(b.buf[:0], b.buf[over:]...)
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.buf)
+}
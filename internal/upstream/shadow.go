@@ -0,0 +1,215 @@
+package upstream
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"ccgateway/internal/orchestrator"
+)
+
+// ShadowResult is the outcome of one mirrored call. Judged is false when
+// the shadow failed or the judge could not decide; ShadowWon says whether
+// the judge preferred the shadow answer over the one the client got.
+type ShadowResult struct {
+	Request        orchestrator.Request
+	Adapter        string
+	Primary        string
+	Response       orchestrator.Response
+	Err            error
+	Latency        time.Duration
+	PrimaryLatency time.Duration
+	Judged         bool
+	ShadowWon      bool
+}
+
+// OnShadowResult registers fn to receive mirrored call outcomes. Requests
+// are only mirrored while a listener is registered; fn runs on a
+// background goroutine.
+func (s *RouterService) OnShadowResult(fn func(ShadowResult)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onShadow = fn
+}
+
+// mirror sends req to the adapters listed in metadata.shadow_adapters,
+// fire-and-forget, once the primary answer is known. The shadow calls are
+// detached from the client request and never affect its response or the
+// selector's view of adapter health.
+func (s *RouterService) mirror(req orchestrator.Request, primaryAdapter string, primary orchestrator.Response, primaryLatency time.Duration) {
+	names := stringListFromMetadata(req.Metadata, "shadow_adapters")
+	if len(names) == 0 {
+		return
+	}
+	s.mu.RLock()
+	fn := s.onShadow
+	timeout := s.timeout
+	s.mu.RUnlock()
+	if fn == nil {
+		return
+	}
+	if ms, ok := intFromAny(req.Metadata["shadow_timeout_ms"]); ok && ms > 0 {
+		timeout = time.Duration(ms) * time.Millisecond
+	}
+	shadowReq := shadowRequest(req)
+	for _, name := range names {
+		if name == primaryAdapter {
+			continue
+		}
+		go func(name string) {
+			fn(s.runShadow(shadowReq, name, primaryAdapter, primary, primaryLatency, timeout))
+		}(name)
+	}
+}
+
+func (s *RouterService) runShadow(req orchestrator.Request, name, primaryAdapter string, primary orchestrator.Response, primaryLatency, timeout time.Duration) ShadowResult {
+	out := ShadowResult{Request: req, Adapter: name, Primary: primaryAdapter, PrimaryLatency: primaryLatency}
+	s.mu.RLock()
+	managed, ok := s.adapters[name]
+	judge := s.judge
+	s.mu.RUnlock()
+	if !ok {
+		out.Err = fmt.Errorf("adapter %q not registered", name)
+		return out
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	release, err := managed.admit(ctx, admission{priority: shadowPriority, timeout: timeout})
+	if err != nil {
+		out.Err = fmt.Errorf("adapter %q: %w", name, err)
+		return out
+	}
+	defer release()
+	started := time.Now()
+	resp, err := managed.adapter.Complete(ctx, req)
+	out.Latency = time.Since(started)
+	if err != nil {
+		out.Err = err
+		return out
+	}
+	out.Response = resp
+	if judge == nil {
+		return out
+	}
+	idx, err := judge.Select(ctx, req, []JudgedCandidate{
+		{AdapterName: primaryAdapter, Response: primary, Latency: primaryLatency, Order: 0},
+		{AdapterName: name, Response: resp, Latency: out.Latency, Order: 1},
+	})
+	if err == nil && (idx == 0 || idx == 1) {
+		out.Judged = true
+		out.ShadowWon = idx == 1
+	}
+	return out
+}
+
+// shadowRequest copies req for a shadow call: the routing hints that
+// picked the primary are dropped and the call is marked as a shadow.
+func shadowRequest(req orchestrator.Request) orchestrator.Request {
+	out := req
+	out.Metadata = make(map[string]any, len(req.Metadata)+1)
+	for k, v := range req.Metadata {
+		switch k {
+		case "shadow_adapters", "shadow_timeout_ms", "routing_adapter_route", "routing_exclude_adapters":
+			continue
+		}
+		out.Metadata[k] = v
+	}
+	out.Metadata["shadow"] = true
+	return out
+}
+
+// streamCollector rebuilds the response a stream delivered so it can be
+// judged against a shadow answer.
+type streamCollector struct {
+	resp   orchestrator.Response
+	blocks map[int]*orchestrator.AssistantBlock
+	inputs map[int]string
+	order  []int
+}
+
+func newStreamCollector() *streamCollector {
+	return &streamCollector{blocks: map[int]*orchestrator.AssistantBlock{}, inputs: map[int]string{}}
+}
+
+func (c *streamCollector) observe(ev orchestrator.StreamEvent) {
+	if ev.PassThrough && len(ev.RawData) > 0 {
+		ev = parseRawStreamEvent(ev.RawData)
+	}
+	switch ev.Type {
+	case "content_block_start":
+		block := ev.Block
+		c.blocks[ev.Index] = &block
+		c.order = append(c.order, ev.Index)
+	case "content_block_delta":
+		block, ok := c.blocks[ev.Index]
+		if !ok {
+			block = &orchestrator.AssistantBlock{Type: "text"}
+			c.blocks[ev.Index] = block
+			c.order = append(c.order, ev.Index)
+		}
+		block.Text += ev.DeltaText
+		c.inputs[ev.Index] += ev.DeltaJSON
+	case "message_delta":
+		if ev.StopReason != "" {
+			c.resp.StopReason = ev.StopReason
+		}
+	}
+	if ev.Usage.InputTokens > 0 {
+		c.resp.Usage.InputTokens = ev.Usage.InputTokens
+	}
+	if ev.Usage.OutputTokens > 0 {
+		c.resp.Usage.OutputTokens = ev.Usage.OutputTokens
+	}
+}
+
+func (c *streamCollector) response(model string) orchestrator.Response {
+	out := c.resp
+	out.Model = model
+	for _, idx := range c.order {
+		block := *c.blocks[idx]
+		if raw := c.inputs[idx]; raw != "" && block.Type == "tool_use" {
+			var input map[string]any
+			if json.Unmarshal([]byte(raw), &input) == nil {
+				block.Input = input
+			}
+		}
+		out.Blocks = append(out.Blocks, block)
+	}
+	return out
+}
+
+// parseRawStreamEvent reads the fields the collector needs from a raw
+// Anthropic stream payload.
+func parseRawStreamEvent(data []byte) orchestrator.StreamEvent {
+	var raw struct {
+		Type         string `json:"type"`
+		Index        int    `json:"index"`
+		ContentBlock struct {
+			Type string `json:"type"`
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"content_block"`
+		Delta struct {
+			Text        string `json:"text"`
+			PartialJSON string `json:"partial_json"`
+			StopReason  string `json:"stop_reason"`
+		} `json:"delta"`
+		Usage struct {
+			InputTokens  int `json:"input_tokens"`
+			OutputTokens int `json:"output_tokens"`
+		} `json:"usage"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return orchestrator.StreamEvent{}
+	}
+	return orchestrator.StreamEvent{
+		Type:       raw.Type,
+		Index:      raw.Index,
+		Block:      orchestrator.AssistantBlock{Type: raw.ContentBlock.Type, ID: raw.ContentBlock.ID, Name: raw.ContentBlock.Name},
+		DeltaText:  raw.Delta.Text,
+		DeltaJSON:  raw.Delta.PartialJSON,
+		StopReason: raw.Delta.StopReason,
+		Usage:      orchestrator.Usage{InputTokens: raw.Usage.InputTokens, OutputTokens: raw.Usage.OutputTokens},
+	}
+}
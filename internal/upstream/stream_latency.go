@@ -0,0 +1,166 @@
+package upstream
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"ccgateway/internal/orchestrator"
+)
+
+// streamLatencyWindow is how many recent streams per adapter the
+// percentiles are computed over.
+const streamLatencyWindow = 512
+
+// StreamLatencyObserver is implemented by selectors that take streaming
+// latency into account. The router reports every stream that finished.
+type StreamLatencyObserver interface {
+	ObserveStreamLatency(adapterName, model string, firstToken time.Duration, tokensPerSecond float64)
+}
+
+// LatencyPercentiles summarizes a latency or throughput distribution.
+type LatencyPercentiles struct {
+	P50 float64 `json:"p50"`
+	P90 float64 `json:"p90"`
+	P99 float64 `json:"p99"`
+}
+
+// StreamLatencyStatus is the time to first token and output speed of an
+// adapter's recent streams, for /admin/status.
+type StreamLatencyStatus struct {
+	Adapter         string             `json:"adapter"`
+	Samples         int                `json:"samples"`
+	FirstTokenMS    LatencyPercentiles `json:"first_token_ms"`
+	TokensPerSecond LatencyPercentiles `json:"tokens_per_second"`
+}
+
+type streamLatencySample struct {
+	firstToken      time.Duration
+	tokensPerSecond float64
+}
+
+// streamLatencies keeps a ring of recent samples per adapter.
+type streamLatencies struct {
+	mu      sync.Mutex
+	samples map[string][]streamLatencySample
+	next    map[string]int
+}
+
+func newStreamLatencies() *streamLatencies {
+	return &streamLatencies{samples: map[string][]streamLatencySample{}, next: map[string]int{}}
+}
+
+func (l *streamLatencies) add(adapter string, sample streamLatencySample) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	ring := l.samples[adapter]
+	if len(ring) < streamLatencyWindow {
+		l.samples[adapter] = append(ring, sample)
+		return
+	}
+	i := l.next[adapter]
+	ring[i] = sample
+	l.next[adapter] = (i + 1) % streamLatencyWindow
+}
+
+func (l *streamLatencies) status() []StreamLatencyStatus {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	out := make([]StreamLatencyStatus, 0, len(l.samples))
+	for adapter, ring := range l.samples {
+		ttft := make([]float64, 0, len(ring))
+		tps := make([]float64, 0, len(ring))
+		for _, sample := range ring {
+			ttft = append(ttft, float64(sample.firstToken)/float64(time.Millisecond))
+			if sample.tokensPerSecond > 0 {
+				tps = append(tps, sample.tokensPerSecond)
+			}
+		}
+		out = append(out, StreamLatencyStatus{
+			Adapter:         adapter,
+			Samples:         len(ring),
+			FirstTokenMS:    percentiles(ttft),
+			TokensPerSecond: percentiles(tps),
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Adapter < out[j].Adapter })
+	return out
+}
+
+func percentiles(values []float64) LatencyPercentiles {
+	if len(values) == 0 {
+		return LatencyPercentiles{}
+	}
+	sort.Float64s(values)
+	at := func(p float64) float64 {
+		return values[int(p*float64(len(values)-1)+0.5)]
+	}
+	return LatencyPercentiles{P50: at(0.50), P90: at(0.90), P99: at(0.99)}
+}
+
+// streamMeter times one adapter stream: the first content delta sets the
+// time to first token, and the output tokens reported in usage (or an
+// estimate from the streamed text) over the time after it give the speed.
+type streamMeter struct {
+	started    time.Time
+	firstAt    time.Time
+	lastAt     time.Time
+	tokens     int
+	textTokens int
+}
+
+func newStreamMeter() *streamMeter {
+	return &streamMeter{started: time.Now()}
+}
+
+func (m *streamMeter) observe(ev orchestrator.StreamEvent) {
+	switch ev.Type {
+	case "content_block_delta":
+		now := time.Now()
+		if m.firstAt.IsZero() {
+			m.firstAt = now
+		}
+		m.lastAt = now
+		m.textTokens += (len(ev.DeltaText) + len(ev.DeltaJSON) + 3) / 4
+	case "message_delta", "message_start":
+		if ev.Usage.OutputTokens > m.tokens {
+			m.tokens = ev.Usage.OutputTokens
+		}
+	}
+}
+
+// sample returns the stream's measurements; ok is false when it produced
+// no content.
+func (m *streamMeter) sample() (streamLatencySample, bool) {
+	if m == nil || m.firstAt.IsZero() {
+		return streamLatencySample{}, false
+	}
+	out := streamLatencySample{firstToken: m.firstAt.Sub(m.started)}
+	tokens := m.tokens
+	if tokens == 0 {
+		tokens = m.textTokens
+	}
+	if span := m.lastAt.Sub(m.firstAt); span > 0 && tokens > 1 {
+		out.tokensPerSecond = float64(tokens) / span.Seconds()
+	}
+	return out, true
+}
+
+// recordStreamLatency keeps the finished stream's measurements and passes
+// them on to the selector.
+func (s *RouterService) recordStreamLatency(adapter, model string, meter *streamMeter) {
+	sample, ok := meter.sample()
+	if !ok {
+		return
+	}
+	s.streamLatency.add(adapter, sample)
+	if observer, ok := s.selector.(StreamLatencyObserver); ok {
+		observer.ObserveStreamLatency(adapter, model, sample.firstToken, sample.tokensPerSecond)
+	}
+}
+
+// StreamLatencyStatus reports time-to-first-token and tokens-per-second
+// percentiles per adapter over its recent streams.
+func (s *RouterService) StreamLatencyStatus() []StreamLatencyStatus {
+	return s.streamLatency.status()
+}
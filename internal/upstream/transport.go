@@ -0,0 +1,311 @@
+package upstream
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultMaxIdleConnsPerHost replaces net/http's default of two idle
+// connections per host, which under load makes every burst above two open
+// (and later TIME_WAIT) fresh connections to the same upstream.
+const defaultMaxIdleConnsPerHost = 32
+
+// TransportSpec tunes the connection pool of one http adapter. Zero values
+// keep the net/http defaults, except MaxIdleConnsPerHost which defaults to
+// 32.
+type TransportSpec struct {
+	MaxIdleConns            int `json:"max_idle_conns,omitempty"`
+	MaxIdleConnsPerHost     int `json:"max_idle_conns_per_host,omitempty"`
+	MaxConnsPerHost         int `json:"max_conns_per_host,omitempty"`
+	IdleConnTimeoutMS       int `json:"idle_conn_timeout_ms,omitempty"`
+	DialTimeoutMS           int `json:"dial_timeout_ms,omitempty"`
+	KeepAliveMS             int `json:"keep_alive_ms,omitempty"`
+	TLSHandshakeTimeoutMS   int `json:"tls_handshake_timeout_ms,omitempty"`
+	ResponseHeaderTimeoutMS int `json:"response_header_timeout_ms,omitempty"`
+	// DisableHTTP2 keeps connections on HTTP/1.1 even when the upstream
+	// offers h2.
+	DisableHTTP2 bool `json:"disable_http2,omitempty"`
+	// ProxyURL sends traffic through an http(s) or socks5 proxy; "direct"
+	// ignores HTTP_PROXY and friends. Empty uses the environment.
+	ProxyURL string `json:"proxy_url,omitempty"`
+	// DNSCacheTTLMS caches resolved addresses for that long, saving a
+	// lookup per new connection.
+	DNSCacheTTLMS int `json:"dns_cache_ttl_ms,omitempty"`
+}
+
+// ConnectionStats reports how an adapter's requests used its pool.
+type ConnectionStats struct {
+	Adapter        string  `json:"adapter"`
+	Requests       int64   `json:"requests"`
+	ReusedConns    int64   `json:"reused_conns"`
+	NewConns       int64   `json:"new_conns"`
+	ReuseRatio     float64 `json:"reuse_ratio"`
+	OpenConns      int64   `json:"open_conns"`
+	DialErrors     int64   `json:"dial_errors"`
+	DNSCacheHits   int64   `json:"dns_cache_hits"`
+	DNSCacheMisses int64   `json:"dns_cache_misses"`
+	HTTP2          bool    `json:"http2"`
+	Proxy          string  `json:"proxy,omitempty"`
+}
+
+type connMetrics struct {
+	requests   atomic.Int64
+	reused     atomic.Int64
+	fresh      atomic.Int64
+	open       atomic.Int64
+	dialErrors atomic.Int64
+	dnsHits    atomic.Int64
+	dnsMisses  atomic.Int64
+}
+
+func sanitizeTransportSpec(in *TransportSpec) *TransportSpec {
+	if in == nil {
+		return nil
+	}
+	out := *in
+	for _, v := range []*int{&out.MaxIdleConns, &out.MaxIdleConnsPerHost, &out.MaxConnsPerHost, &out.IdleConnTimeoutMS,
+		&out.DialTimeoutMS, &out.KeepAliveMS, &out.TLSHandshakeTimeoutMS, &out.ResponseHeaderTimeoutMS, &out.DNSCacheTTLMS} {
+		if *v < 0 {
+			*v = 0
+		}
+	}
+	out.ProxyURL = strings.TrimSpace(in.ProxyURL)
+	if out == (TransportSpec{}) {
+		return nil
+	}
+	return &out
+}
+
+func cloneTransportSpec(in *TransportSpec) *TransportSpec {
+	if in == nil {
+		return nil
+	}
+	out := *in
+	return &out
+}
+
+// newAdapterTransport builds the private transport of one adapter from
+// net/http's defaults and spec, counting dials into metrics.
+func newAdapterTransport(name string, spec *TransportSpec, insecureSkipVerify bool, metrics *connMetrics) (*http.Transport, error) {
+	var t *http.Transport
+	if base, ok := http.DefaultTransport.(*http.Transport); ok {
+		t = base.Clone()
+	} else {
+		t = &http.Transport{Proxy: http.ProxyFromEnvironment, ForceAttemptHTTP2: true}
+	}
+	if insecureSkipVerify {
+		t.TLSClientConfig = &tls.Config{InsecureSkipVerify: true} //nolint:gosec
+	}
+	t.MaxIdleConnsPerHost = defaultMaxIdleConnsPerHost
+
+	s := TransportSpec{}
+	if spec != nil {
+		s = *spec
+	}
+	if s.MaxIdleConns > 0 {
+		t.MaxIdleConns = s.MaxIdleConns
+	}
+	if s.MaxIdleConnsPerHost > 0 {
+		t.MaxIdleConnsPerHost = s.MaxIdleConnsPerHost
+	}
+	if s.MaxConnsPerHost > 0 {
+		t.MaxConnsPerHost = s.MaxConnsPerHost
+	}
+	if s.IdleConnTimeoutMS > 0 {
+		t.IdleConnTimeout = time.Duration(s.IdleConnTimeoutMS) * time.Millisecond
+	}
+	if s.TLSHandshakeTimeoutMS > 0 {
+		t.TLSHandshakeTimeout = time.Duration(s.TLSHandshakeTimeoutMS) * time.Millisecond
+	}
+	if s.ResponseHeaderTimeoutMS > 0 {
+		t.ResponseHeaderTimeout = time.Duration(s.ResponseHeaderTimeoutMS) * time.Millisecond
+	}
+	if s.DisableHTTP2 {
+		t.ForceAttemptHTTP2 = false
+		t.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+	switch proxy := s.ProxyURL; {
+	case proxy == "":
+	case strings.EqualFold(proxy, "direct"):
+		t.Proxy = nil
+	default:
+		u, err := url.Parse(proxy)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("adapter %q: invalid transport.proxy_url %q", name, proxy)
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return nil, fmt.Errorf("adapter %q: transport.proxy_url scheme must be http, https or socks5", name)
+		}
+		t.Proxy = http.ProxyURL(u)
+	}
+
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	if s.DialTimeoutMS > 0 {
+		dialer.Timeout = time.Duration(s.DialTimeoutMS) * time.Millisecond
+	}
+	if s.KeepAliveMS > 0 {
+		dialer.KeepAlive = time.Duration(s.KeepAliveMS) * time.Millisecond
+	}
+	dial := dialer.DialContext
+	if s.DNSCacheTTLMS > 0 {
+		cache := &dnsCache{ttl: time.Duration(s.DNSCacheTTLMS) * time.Millisecond, metrics: metrics, entries: map[string]dnsEntry{}}
+		dial = cache.dialer(dialer)
+	}
+	t.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			metrics.dialErrors.Add(1)
+			return nil, err
+		}
+		metrics.open.Add(1)
+		return &countedConn{Conn: conn, open: &metrics.open}, nil
+	}
+	return t, nil
+}
+
+// countedConn keeps connMetrics.open in step with live connections.
+type countedConn struct {
+	net.Conn
+	open *atomic.Int64
+	once sync.Once
+}
+
+func (c *countedConn) Close() error {
+	c.once.Do(func() { c.open.Add(-1) })
+	return c.Conn.Close()
+}
+
+type dnsEntry struct {
+	addrs   []string
+	expires time.Time
+}
+
+type dnsCache struct {
+	ttl     time.Duration
+	metrics *connMetrics
+
+	mu      sync.Mutex
+	entries map[string]dnsEntry
+}
+
+func (c *dnsCache) lookup(ctx context.Context, host string) ([]string, error) {
+	now := time.Now()
+	c.mu.Lock()
+	entry, ok := c.entries[host]
+	c.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		c.metrics.dnsHits.Add(1)
+		return entry.addrs, nil
+	}
+	c.metrics.dnsMisses.Add(1)
+	addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.entries[host] = dnsEntry{addrs: addrs, expires: now.Add(c.ttl)}
+	c.mu.Unlock()
+	return addrs, nil
+}
+
+// dialer resolves through the cache and dials the addresses in order,
+// returning the first connection that succeeds.
+func (c *dnsCache) dialer(d *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil || net.ParseIP(host) != nil {
+			return d.DialContext(ctx, network, addr)
+		}
+		addrs, err := c.lookup(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		var lastErr error
+		for _, ip := range addrs {
+			conn, err := d.DialContext(ctx, network, net.JoinHostPort(ip, port))
+			if err == nil {
+				return conn, nil
+			}
+			lastErr = err
+		}
+		if lastErr == nil {
+			lastErr = fmt.Errorf("no addresses for %s", host)
+		}
+		return nil, lastErr
+	}
+}
+
+// traceConn counts whether the request got a pooled or a new connection.
+func (m *connMetrics) traceConn(req *http.Request) *http.Request {
+	m.requests.Add(1)
+	trace := &httptrace.ClientTrace{GotConn: func(info httptrace.GotConnInfo) {
+		if info.Reused {
+			m.reused.Add(1)
+		} else {
+			m.fresh.Add(1)
+		}
+	}}
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+}
+
+// ConnectionStats reports the connection reuse of the adapter. Dial and DNS
+// counters stay zero when the adapter was given a client.
+func (a *HTTPAdapter) ConnectionStats() ConnectionStats {
+	m := a.conns
+	out := ConnectionStats{
+		Adapter:        a.name,
+		Requests:       m.requests.Load(),
+		ReusedConns:    m.reused.Load(),
+		NewConns:       m.fresh.Load(),
+		OpenConns:      m.open.Load(),
+		DialErrors:     m.dialErrors.Load(),
+		DNSCacheHits:   m.dnsHits.Load(),
+		DNSCacheMisses: m.dnsMisses.Load(),
+		HTTP2:          a.transport == nil || !a.transport.DisableHTTP2,
+	}
+	if got := out.ReusedConns + out.NewConns; got > 0 {
+		out.ReuseRatio = float64(out.ReusedConns) / float64(got)
+	}
+	if a.transport != nil {
+		out.Proxy = redactProxyURL(a.transport.ProxyURL)
+	}
+	return out
+}
+
+func redactProxyURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.User == nil {
+		return raw
+	}
+	u.User = url.User(u.User.Username())
+	return u.String()
+}
+
+// ConnectionStats lists connection reuse for the active adapters that
+// report it, in route order.
+func (s *RouterService) ConnectionStats() []ConnectionStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]ConnectionStats, 0, len(s.adapterOrder))
+	for _, name := range s.adapterOrder {
+		m, ok := s.adapters[name]
+		if !ok {
+			continue
+		}
+		if reporter, ok := m.adapter.(interface{ ConnectionStats() ConnectionStats }); ok {
+			out = append(out, reporter.ConnectionStats())
+		}
+	}
+	return out
+}
@@ -0,0 +1,183 @@
+package upstream
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultWireBodyLimit caps each captured request and response body.
+	defaultWireBodyLimit = 256 << 10
+	wireRedacted         = "[REDACTED]"
+)
+
+// WireExchange is one HTTP call an adapter made upstream: the request as
+// sent after conversion to the provider format, and the raw response.
+// Credentials in headers and the query string are redacted.
+type WireExchange struct {
+	Adapter         string            `json:"adapter"`
+	Method          string            `json:"method"`
+	URL             string            `json:"url"`
+	RequestHeaders  map[string]string `json:"request_headers"`
+	RequestBody     []byte            `json:"-"`
+	Status          int               `json:"status,omitempty"`
+	ResponseHeaders map[string]string `json:"response_headers,omitempty"`
+	ResponseBody    []byte            `json:"-"`
+	// Truncated is set when a body was longer than the capture limit.
+	Truncated  bool      `json:"truncated,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	DurationMS int64     `json:"duration_ms"`
+}
+
+type wireObserverKey struct{}
+
+// WithWireObserver returns a context whose adapter HTTP calls are reported
+// to fn once their response body is closed. limit caps each captured body;
+// zero uses 256KB.
+func WithWireObserver(ctx context.Context, limit int, fn func(WireExchange)) context.Context {
+	if fn == nil {
+		return ctx
+	}
+	if limit <= 0 {
+		limit = defaultWireBodyLimit
+	}
+	return context.WithValue(ctx, wireObserverKey{}, wireObserver{limit: limit, fn: fn})
+}
+
+type wireObserver struct {
+	limit int
+	fn    func(WireExchange)
+}
+
+// do sends httpReq, capturing the exchange when the request's context has
+// a wire observer.
+func (a *HTTPAdapter) do(httpReq *http.Request) (*http.Response, error) {
+	httpReq = a.conns.traceConn(httpReq)
+	obs, ok := httpReq.Context().Value(wireObserverKey{}).(wireObserver)
+	if !ok {
+		return a.client.Do(httpReq)
+	}
+	ex := WireExchange{
+		Adapter:        a.name,
+		Method:         httpReq.Method,
+		URL:            redactWireURL(httpReq.URL),
+		RequestHeaders: a.redactWireHeaders(httpReq.Header),
+		StartedAt:      time.Now().UTC(),
+	}
+	if httpReq.GetBody != nil {
+		if body, err := httpReq.GetBody(); err == nil {
+			ex.RequestBody, ex.Truncated = readCapped(body, obs.limit)
+			body.Close()
+		}
+	}
+	resp, err := a.client.Do(httpReq)
+	if err != nil {
+		ex.Error = err.Error()
+		ex.DurationMS = time.Since(ex.StartedAt).Milliseconds()
+		obs.fn(ex)
+		return nil, err
+	}
+	ex.Status = resp.StatusCode
+	ex.ResponseHeaders = a.redactWireHeaders(resp.Header)
+	resp.Body = &wireBody{ReadCloser: resp.Body, ex: ex, limit: obs.limit, fn: obs.fn}
+	return resp, nil
+}
+
+// wireBody tees the response body into the capture and reports the
+// exchange when the adapter closes it.
+type wireBody struct {
+	io.ReadCloser
+	ex    WireExchange
+	buf   bytes.Buffer
+	limit int
+	fn    func(WireExchange)
+	once  sync.Once
+}
+
+func (b *wireBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if room := b.limit - b.buf.Len(); n > room {
+		b.buf.Write(p[:room])
+		b.ex.Truncated = true
+	} else {
+		b.buf.Write(p[:n])
+	}
+	return n, err
+}
+
+func (b *wireBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() {
+		b.ex.ResponseBody = b.buf.Bytes()
+		b.ex.DurationMS = time.Since(b.ex.StartedAt).Milliseconds()
+		b.fn(b.ex)
+	})
+	return err
+}
+
+func readCapped(r io.Reader, limit int) ([]byte, bool) {
+	data, _ := io.ReadAll(io.LimitReader(r, int64(limit)+1))
+	if len(data) > limit {
+		return data[:limit], true
+	}
+	return data, false
+}
+
+// redactWireHeaders flattens h, masking credentials: the adapter's API key
+// header and any header that looks like one.
+func (a *HTTPAdapter) redactWireHeaders(h http.Header) map[string]string {
+	out := make(map[string]string, len(h))
+	for name, values := range h {
+		value := strings.Join(values, ", ")
+		lower := strings.ToLower(name)
+		if (a.apiKeyHeader != "" && strings.EqualFold(name, a.apiKeyHeader)) ||
+			(a.apiKey != "" && strings.Contains(value, a.apiKey)) ||
+			isCredentialHeader(lower) {
+			value = wireRedacted
+		}
+		out[lower] = value
+	}
+	return out
+}
+
+func isCredentialHeader(name string) bool {
+	switch name {
+	case "authorization", "proxy-authorization", "cookie", "set-cookie":
+		return true
+	}
+	for _, marker := range []string{"api-key", "apikey", "secret"} {
+		if strings.Contains(name, marker) {
+			return true
+		}
+	}
+	return strings.HasSuffix(name, "token")
+}
+
+// redactWireURL masks key-like query parameters, such as Gemini's ?key=.
+func redactWireURL(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	q := u.Query()
+	changed := false
+	for name := range q {
+		lower := strings.ToLower(name)
+		if lower == "key" || isCredentialHeader(lower) {
+			q.Set(name, wireRedacted)
+			changed = true
+		}
+	}
+	if !changed {
+		return u.String()
+	}
+	out := *u
+	out.RawQuery = q.Encode()
+	return out.String()
+}
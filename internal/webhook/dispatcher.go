@@ -0,0 +1,669 @@
+// Package webhook delivers gateway events to external URLs. Subscriptions
+// pick event types; each matching event is POSTed as signed JSON and
+// retried with backoff, and every attempt is kept for the admin API.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"ccgateway/internal/ccevent"
+	"ccgateway/internal/egress"
+)
+
+// Delivery statuses. Suppressed deliveries were dropped by the
+// subscription's rate limit and never sent.
+const (
+	StatusPending    = "pending"
+	StatusSucceeded  = "succeeded"
+	StatusFailed     = "failed"
+	StatusSuppressed = "suppressed"
+)
+
+// Headers set on every json-format delivery. The signature is
+// "t=<unix>,v1=<hex hmac-sha256(secret, t + "." + body)>".
+const (
+	HeaderSignature = "X-CC-Signature"
+	HeaderEvent     = "X-CC-Event"
+	HeaderDelivery  = "X-CC-Delivery"
+)
+
+const (
+	defaultMaxAttempts   = 5
+	defaultTimeout       = 10 * time.Second
+	defaultBackoff       = time.Second
+	defaultMaxDeliveries = 1000
+)
+
+var (
+	ErrNotFound   = errors.New("webhook not found")
+	ErrInvalidURL = errors.New("url must be an absolute http or https URL")
+	ErrNoEvents   = errors.New("events must list at least one event type")
+)
+
+// Subscription sends the events it matches to URL. Event types match
+// exactly, "*" matches everything and "run.*" matches a prefix. Format
+// picks the payload shape; chat formats render Template (text/template
+// over the event) or a built-in message, and send at most RateLimit alerts
+// per minute (0 uses the chat default, negative disables the limit).
+type Subscription struct {
+	ID          string    `json:"id"`
+	URL         string    `json:"url"`
+	Events      []string  `json:"events"`
+	Format      string    `json:"format"`
+	Template    string    `json:"template,omitempty"`
+	RateLimit   int       `json:"rate_limit_per_minute,omitempty"`
+	Description string    `json:"description,omitempty"`
+	Enabled     bool      `json:"enabled"`
+	Secret      string    `json:"secret,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Redacted hides the signing secret for listings.
+func (s Subscription) Redacted() Subscription {
+	s.Secret = ""
+	return s
+}
+
+// Input creates or updates a subscription. Nil fields are left unchanged
+// on update; an empty Secret on create generates one for the json format.
+// Chat formats only sign when given the secret their provider issued.
+type Input struct {
+	URL         *string   `json:"url"`
+	Events      *[]string `json:"events"`
+	Format      *string   `json:"format"`
+	Template    *string   `json:"template"`
+	RateLimit   *int      `json:"rate_limit_per_minute"`
+	Description *string   `json:"description"`
+	Enabled     *bool     `json:"enabled"`
+	Secret      *string   `json:"secret"`
+}
+
+// Delivery is one event sent to one subscription, across its attempts.
+type Delivery struct {
+	ID             string    `json:"id"`
+	SubscriptionID string    `json:"subscription_id"`
+	EventID        string    `json:"event_id"`
+	EventType      string    `json:"event_type"`
+	URL            string    `json:"url"`
+	Status         string    `json:"status"`
+	Attempts       int       `json:"attempts"`
+	LastStatusCode int       `json:"last_status_code,omitempty"`
+	LastError      string    `json:"last_error,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// DeliveryFilter narrows Deliveries; results are newest first.
+type DeliveryFilter struct {
+	SubscriptionID string
+	Status         string
+	Limit          int
+}
+
+// Config tunes delivery. Zero values use the defaults: 5 attempts, a 10s
+// timeout per attempt, 1s backoff doubling per retry, 1000 deliveries kept.
+type Config struct {
+	Path          string
+	MaxAttempts   int
+	Timeout       time.Duration
+	Backoff       time.Duration
+	MaxDeliveries int
+	// Client overrides the egress-policy client built from Timeout.
+	Client *http.Client
+}
+
+// Dispatcher holds the subscriptions and the delivery history. It is safe
+// for concurrent use.
+type Dispatcher struct {
+	cfg Config
+
+	mu         sync.RWMutex
+	subs       map[string]Subscription
+	sent       map[string][]time.Time
+	deliveries []*Delivery
+	seq        atomic.Uint64
+	inflight   sync.WaitGroup
+}
+
+// NewDispatcher builds a dispatcher and loads subscriptions from
+// cfg.Path when set.
+func NewDispatcher(cfg Config) (*Dispatcher, error) {
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = defaultMaxAttempts
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = defaultBackoff
+	}
+	if cfg.MaxDeliveries <= 0 {
+		cfg.MaxDeliveries = defaultMaxDeliveries
+	}
+	cfg.Path = strings.TrimSpace(cfg.Path)
+	d := &Dispatcher{cfg: cfg, subs: map[string]Subscription{}, sent: map[string][]time.Time{}}
+	if cfg.Path != "" {
+		if err := d.load(); err != nil {
+			return nil, err
+		}
+	}
+	return d, nil
+}
+
+// NewFromEnv reads WEBHOOK_STORE_PATH (subscriptions file; empty keeps them
+// in memory), WEBHOOK_MAX_ATTEMPTS and WEBHOOK_TIMEOUT.
+func NewFromEnv() (*Dispatcher, error) {
+	cfg := Config{Path: os.Getenv("WEBHOOK_STORE_PATH")}
+	if raw := strings.TrimSpace(os.Getenv("WEBHOOK_MAX_ATTEMPTS")); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid WEBHOOK_MAX_ATTEMPTS %q", raw)
+		}
+		cfg.MaxAttempts = n
+	}
+	if raw := strings.TrimSpace(os.Getenv("WEBHOOK_TIMEOUT")); raw != "" {
+		t, err := time.ParseDuration(raw)
+		if err != nil || t <= 0 {
+			return nil, fmt.Errorf("invalid WEBHOOK_TIMEOUT %q", raw)
+		}
+		cfg.Timeout = t
+	}
+	return NewDispatcher(cfg)
+}
+
+// Create adds a subscription. The returned value carries the secret; later
+// reads do not.
+func (d *Dispatcher) Create(in Input) (Subscription, error) {
+	now := time.Now().UTC()
+	sub := Subscription{
+		ID:        fmt.Sprintf("whk_%d_%d", now.UnixNano(), d.seq.Add(1)),
+		Format:    FormatJSON,
+		Enabled:   true,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := applyInput(&sub, in); err != nil {
+		return Subscription{}, err
+	}
+	if sub.Secret == "" && sub.Format == FormatJSON {
+		sub.Secret = newSecret()
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.subs[sub.ID] = sub
+	if err := d.saveLocked(); err != nil {
+		delete(d.subs, sub.ID)
+		return Subscription{}, err
+	}
+	return sub, nil
+}
+
+// Update applies the non-nil fields of in.
+func (d *Dispatcher) Update(id string, in Input) (Subscription, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	prev, ok := d.subs[strings.TrimSpace(id)]
+	if !ok {
+		return Subscription{}, ErrNotFound
+	}
+	next := prev
+	if err := applyInput(&next, in); err != nil {
+		return Subscription{}, err
+	}
+	next.UpdatedAt = time.Now().UTC()
+	d.subs[next.ID] = next
+	if err := d.saveLocked(); err != nil {
+		d.subs[prev.ID] = prev
+		return Subscription{}, err
+	}
+	return next.Redacted(), nil
+}
+
+func (d *Dispatcher) Delete(id string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	prev, ok := d.subs[strings.TrimSpace(id)]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(d.subs, prev.ID)
+	delete(d.sent, prev.ID)
+	if err := d.saveLocked(); err != nil {
+		d.subs[prev.ID] = prev
+		return err
+	}
+	return nil
+}
+
+func (d *Dispatcher) Get(id string) (Subscription, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	sub, ok := d.subs[strings.TrimSpace(id)]
+	return sub.Redacted(), ok
+}
+
+// List returns the subscriptions oldest first, without secrets.
+func (d *Dispatcher) List() []Subscription {
+	d.mu.RLock()
+	out := make([]Subscription, 0, len(d.subs))
+	for _, sub := range d.subs {
+		out = append(out, sub.Redacted())
+	}
+	d.mu.RUnlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out
+}
+
+// Deliveries returns tracked deliveries, newest first.
+func (d *Dispatcher) Deliveries(filter DeliveryFilter) []Delivery {
+	subID := strings.TrimSpace(filter.SubscriptionID)
+	status := strings.TrimSpace(filter.Status)
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	out := make([]Delivery, 0)
+	for i := len(d.deliveries) - 1; i >= 0; i-- {
+		del := d.deliveries[i]
+		if subID != "" && del.SubscriptionID != subID {
+			continue
+		}
+		if status != "" && del.Status != status {
+			continue
+		}
+		out = append(out, *del)
+		if filter.Limit > 0 && len(out) >= filter.Limit {
+			break
+		}
+	}
+	return out
+}
+
+// Run delivers every event read from events until the channel closes or
+// ctx is done.
+func (d *Dispatcher) Run(ctx context.Context, events <-chan ccevent.Event) {
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				return
+			}
+			d.Dispatch(ctx, ev)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Dispatch starts a delivery of ev to every enabled subscription that
+// matches it and returns the deliveries created. Sending happens in the
+// background.
+func (d *Dispatcher) Dispatch(ctx context.Context, ev ccevent.Event) []Delivery {
+	d.mu.RLock()
+	targets := make([]Subscription, 0)
+	for _, sub := range d.subs {
+		if sub.Enabled && matches(sub.Events, ev.EventType) {
+			targets = append(targets, sub)
+		}
+	}
+	d.mu.RUnlock()
+	out := make([]Delivery, 0, len(targets))
+	for _, sub := range targets {
+		out = append(out, d.send(ctx, sub, ev))
+	}
+	return out
+}
+
+// Test sends a webhook.test event to one subscription regardless of its
+// event filter. The delivery outlives the caller's request.
+func (d *Dispatcher) Test(id string) (Delivery, error) {
+	d.mu.RLock()
+	sub, ok := d.subs[strings.TrimSpace(id)]
+	d.mu.RUnlock()
+	if !ok {
+		return Delivery{}, ErrNotFound
+	}
+	ev := ccevent.Event{
+		ID:        fmt.Sprintf("evt_test_%d", time.Now().UnixNano()),
+		Type:      "event",
+		EventType: "webhook.test",
+		Data:      map[string]any{"subscription_id": sub.ID},
+		CreatedAt: time.Now().UTC(),
+	}
+	return d.send(context.Background(), sub, ev), nil
+}
+
+// Wait blocks until in-flight deliveries finish; used on shutdown.
+func (d *Dispatcher) Wait() {
+	d.inflight.Wait()
+}
+
+func (d *Dispatcher) send(ctx context.Context, sub Subscription, ev ccevent.Event) Delivery {
+	now := time.Now().UTC()
+	del := &Delivery{
+		ID:             fmt.Sprintf("whd_%d_%d", now.UnixNano(), d.seq.Add(1)),
+		SubscriptionID: sub.ID,
+		EventID:        ev.ID,
+		EventType:      ev.EventType,
+		URL:            sub.URL,
+		Status:         StatusPending,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	d.mu.Lock()
+	d.deliveries = append(d.deliveries, del)
+	if over := len(d.deliveries) - d.cfg.MaxDeliveries; over > 0 {
+		d.deliveries = append([]*Delivery(nil), d.deliveries[over:]...)
+	}
+	if !d.allowLocked(sub, now) {
+		del.Status = StatusSuppressed
+		del.LastError = "rate limit reached"
+		snapshot := *del
+		d.mu.Unlock()
+		return snapshot
+	}
+	snapshot := *del
+	d.mu.Unlock()
+
+	d.inflight.Add(1)
+	go func() {
+		defer d.inflight.Done()
+		d.deliver(ctx, sub, del, ev)
+	}()
+	return snapshot
+}
+
+// allowLocked applies the subscription's per-minute limit over a sliding
+// window.
+func (d *Dispatcher) allowLocked(sub Subscription, now time.Time) bool {
+	limit := rateLimit(sub)
+	if limit <= 0 {
+		return true
+	}
+	window := d.sent[sub.ID]
+	kept := window[:0]
+	for _, at := range window {
+		if now.Sub(at) < time.Minute {
+			kept = append(kept, at)
+		}
+	}
+	if len(kept) >= limit {
+		d.sent[sub.ID] = kept
+		return false
+	}
+	d.sent[sub.ID] = append(kept, now)
+	return true
+}
+
+// deliver retries network errors, 429 and 5xx answers with doubling
+// backoff; other 4xx answers fail at once since a retry will not help.
+func (d *Dispatcher) deliver(ctx context.Context, sub Subscription, del *Delivery, ev ccevent.Event) {
+	backoff := d.cfg.Backoff
+	for attempt := 1; attempt <= d.cfg.MaxAttempts; attempt++ {
+		code, err := d.post(ctx, sub, del, ev)
+		d.mu.Lock()
+		del.Attempts = attempt
+		del.LastStatusCode = code
+		del.LastError = ""
+		if err != nil {
+			del.LastError = err.Error()
+		}
+		del.UpdatedAt = time.Now().UTC()
+		d.mu.Unlock()
+		if err == nil {
+			d.finish(del, StatusSucceeded, code, nil)
+			return
+		}
+		if code != 0 && code != http.StatusTooManyRequests && code < http.StatusInternalServerError {
+			break
+		}
+		if attempt < d.cfg.MaxAttempts {
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				d.finish(del, StatusFailed, code, ctx.Err())
+				return
+			}
+			backoff *= 2
+		}
+	}
+	d.mu.Lock()
+	del.Status = StatusFailed
+	d.mu.Unlock()
+}
+
+// post makes one attempt. The body is encoded per attempt so chat
+// signatures carry a fresh timestamp.
+func (d *Dispatcher) post(ctx context.Context, sub Subscription, del *Delivery, ev ccevent.Event) (int, error) {
+	now := time.Now()
+	body, target, err := encode(sub, ev, now)
+	if err != nil {
+		return 0, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, d.cfg.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("content-type", "application/json")
+	req.Header.Set("user-agent", "cc-gateway-webhook/1")
+	if sub.Format == FormatJSON {
+		req.Header.Set(HeaderEvent, del.EventType)
+		req.Header.Set(HeaderDelivery, del.ID)
+		req.Header.Set(HeaderSignature, Sign(sub.Secret, now, body))
+	}
+	client := d.cfg.Client
+	if client == nil {
+		client = egress.Default().HTTPClient(d.cfg.Timeout)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	answer, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint answered %d", resp.StatusCode)
+	}
+	if err := checkChatResponse(sub.Format, answer); err != nil {
+		// The provider rejected the message itself; deliver does not retry
+		// errors that come with a 2xx status.
+		return resp.StatusCode, err
+	}
+	return resp.StatusCode, nil
+}
+
+func (d *Dispatcher) finish(del *Delivery, status string, code int, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	del.Status = status
+	if code != 0 {
+		del.LastStatusCode = code
+	}
+	if err != nil {
+		del.LastError = err.Error()
+	}
+	del.UpdatedAt = time.Now().UTC()
+}
+
+// Sign computes the signature header for body sent at t.
+func Sign(secret string, t time.Time, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "t=" + ts + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a signature header against body; receivers may use it to
+// authenticate deliveries. Signatures older than tolerance are rejected
+// when tolerance is positive.
+func Verify(secret, header string, body []byte, tolerance time.Duration) bool {
+	var ts, sig string
+	for _, part := range strings.Split(header, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch k {
+		case "t":
+			ts = v
+		case "v1":
+			sig = v
+		}
+	}
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || sig == "" {
+		return false
+	}
+	if tolerance > 0 && time.Since(time.Unix(unix, 0)) > tolerance {
+		return false
+	}
+	want := Sign(secret, time.Unix(unix, 0), body)
+	return hmac.Equal([]byte(want), []byte("t="+ts+",v1="+sig))
+}
+
+func matches(patterns []string, eventType string) bool {
+	for _, p := range patterns {
+		switch {
+		case p == "*" || p == eventType:
+			return true
+		case strings.HasSuffix(p, ".*") && strings.HasPrefix(eventType, strings.TrimSuffix(p, "*")):
+			return true
+		}
+	}
+	return false
+}
+
+func applyInput(sub *Subscription, in Input) error {
+	if in.URL != nil {
+		raw := strings.TrimSpace(*in.URL)
+		u, err := url.Parse(raw)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return ErrInvalidURL
+		}
+		sub.URL = raw
+	}
+	if sub.URL == "" {
+		return ErrInvalidURL
+	}
+	if in.Events != nil {
+		events := make([]string, 0, len(*in.Events))
+		seen := map[string]bool{}
+		for _, e := range *in.Events {
+			e = strings.TrimSpace(e)
+			if e == "" || seen[e] {
+				continue
+			}
+			seen[e] = true
+			events = append(events, e)
+		}
+		sub.Events = events
+	}
+	if len(sub.Events) == 0 {
+		return ErrNoEvents
+	}
+	if in.Format != nil {
+		format, err := normalizeFormat(*in.Format)
+		if err != nil {
+			return err
+		}
+		sub.Format = format
+	}
+	if in.Template != nil {
+		text := strings.TrimSpace(*in.Template)
+		if text != "" {
+			if _, err := parseTemplate(text); err != nil {
+				return fmt.Errorf("%w: %v", ErrInvalidTemplate, err)
+			}
+		}
+		sub.Template = text
+	}
+	if in.RateLimit != nil {
+		sub.RateLimit = *in.RateLimit
+	}
+	if in.Description != nil {
+		sub.Description = strings.TrimSpace(*in.Description)
+	}
+	if in.Enabled != nil {
+		sub.Enabled = *in.Enabled
+	}
+	if in.Secret != nil {
+		sub.Secret = strings.TrimSpace(*in.Secret)
+	}
+	return nil
+}
+
+func newSecret() string {
+	buf := make([]byte, 24)
+	_, _ = rand.Read(buf)
+	return "whsec_" + hex.EncodeToString(buf)
+}
+
+func (d *Dispatcher) load() error {
+	raw, err := os.ReadFile(d.cfg.Path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read webhook store: %w", err)
+	}
+	var subs []Subscription
+	if err := json.Unmarshal(raw, &subs); err != nil {
+		return fmt.Errorf("parse webhook store: %w", err)
+	}
+	for _, sub := range subs {
+		if sub.Format == "" {
+			sub.Format = FormatJSON
+		}
+		if sub.ID != "" {
+			d.subs[sub.ID] = sub
+		}
+	}
+	return nil
+}
+
+// saveLocked rewrites the subscriptions file, secrets included, through a
+// temporary file so a crash never leaves it half written.
+func (d *Dispatcher) saveLocked() error {
+	if d.cfg.Path == "" {
+		return nil
+	}
+	subs := make([]Subscription, 0, len(d.subs))
+	for _, sub := range d.subs {
+		subs = append(subs, sub)
+	}
+	sort.Slice(subs, func(i, j int) bool { return subs[i].CreatedAt.Before(subs[j].CreatedAt) })
+	raw, err := json.MarshalIndent(subs, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(d.cfg.Path), 0o755); err != nil {
+		return fmt.Errorf("create webhook store dir: %w", err)
+	}
+	tmp := d.cfg.Path + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0o600); err != nil {
+		return fmt.Errorf("write webhook store: %w", err)
+	}
+	if err := os.Rename(tmp, d.cfg.Path); err != nil {
+		return fmt.Errorf("write webhook store: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"ccgateway/internal/ccevent"
+)
+
+// Subscription formats. FormatJSON posts the event itself; the others post
+// a human-readable message in the provider's incoming-webhook shape.
+const (
+	FormatJSON     = "json"
+	FormatSlack    = "slack"
+	FormatDingTalk = "dingtalk"
+	FormatFeishu   = "feishu"
+)
+
+// defaultChatRateLimit caps alerts per minute for chat formats when the
+// subscription sets no limit, so a flapping adapter cannot flood a channel.
+const defaultChatRateLimit = 20
+
+var (
+	ErrInvalidFormat   = errors.New("format must be one of json, slack, dingtalk, feishu")
+	ErrInvalidTemplate = errors.New("template does not parse")
+)
+
+// defaultTemplates render the events operators usually alert on; other
+// events fall back to genericTemplate.
+var defaultTemplates = map[string]string{
+	"probe.failed":               `[cc-gateway] Probe failed: {{.Data.adapter}}{{with .Data.model}} / {{.}}{{end}} ({{.Data.check}}){{with .Data.error}}: {{.}}{{end}}`,
+	"probe.recovered":            `[cc-gateway] Probe recovered: {{.Data.adapter}}{{with .Data.model}} / {{.}}{{end}}`,
+	"scheduler.election_changed": `[cc-gateway] Scheduler elected: {{.Data.scheduler_adapter}}{{with .Data.scheduler_model}} / {{.}}{{end}} (score {{.Data.scheduler_score}}, {{.Data.workers}} workers){{with .Data.reason}}, {{.}}{{end}}`,
+	"scheduler.adapter_down":     `[cc-gateway] Adapter down: {{.Data.adapter}} after {{.Data.consecutive_failures}} failures{{with .Data.last_error}}: {{.}}{{end}}`,
+	"scheduler.adapter_up":       `[cc-gateway] Adapter recovered: {{.Data.adapter}}`,
+	"run.failed":                 `[cc-gateway] Run failed: {{.RunID}} {{.Data.path}} status {{.Data.status}}{{with .Data.error}}: {{.}}{{end}}`,
+	"tool.gap_detected":          `[cc-gateway] Tool gap: {{with .Data.tool_name}}{{.}}{{else}}unknown tool{{end}}{{with .Data.reason}} ({{.}}){{end}}`,
+	"quota.threshold_reached":    `[cc-gateway] Quota {{.Data.period}} at {{.Data.used}}/{{.Data.limit}} for token {{with .Data.token_name}}{{.}}{{else}}{{.Data.token_id}}{{end}}`,
+}
+
+const genericTemplate = `[cc-gateway] {{.EventType}}{{range $k, $v := .Fields}} {{$k}}={{$v}}{{end}}`
+
+// templateView is what templates see: the event plus its data flattened to
+// sorted key=value pairs for the generic message.
+type templateView struct {
+	ccevent.Event
+	Fields map[string]string
+}
+
+func normalizeFormat(raw string) (string, error) {
+	switch f := strings.ToLower(strings.TrimSpace(raw)); f {
+	case "", FormatJSON:
+		return FormatJSON, nil
+	case FormatSlack, FormatDingTalk, FormatFeishu:
+		return f, nil
+	}
+	return "", ErrInvalidFormat
+}
+
+func parseTemplate(text string) (*template.Template, error) {
+	return template.New("alert").Option("missingkey=zero").Parse(text)
+}
+
+// renderText formats ev with the subscription template, the built-in one
+// for its type, or the generic one.
+func renderText(sub Subscription, ev ccevent.Event) string {
+	text := strings.TrimSpace(sub.Template)
+	if text == "" {
+		text = defaultTemplates[ev.EventType]
+	}
+	if text == "" {
+		text = genericTemplate
+	}
+	tmpl, err := parseTemplate(text)
+	if err != nil {
+		tmpl, _ = parseTemplate(genericTemplate)
+	}
+	view := templateView{Event: ev, Fields: map[string]string{}}
+	keys := make([]string, 0, len(ev.Data))
+	for k := range ev.Data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		view.Fields[k] = fmt.Sprint(ev.Data[k])
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, view); err != nil {
+		return "[cc-gateway] " + ev.EventType
+	}
+	return strings.ReplaceAll(buf.String(), "<no value>", "")
+}
+
+// encode builds the request body and target URL for sub. DingTalk signs
+// through query parameters and Feishu inside the body, each with the
+// subscription secret when one is set.
+func encode(sub Subscription, ev ccevent.Event, now time.Time) ([]byte, string, error) {
+	if sub.Format == "" || sub.Format == FormatJSON {
+		body, err := json.Marshal(ev)
+		return body, sub.URL, err
+	}
+	text := renderText(sub, ev)
+	switch sub.Format {
+	case FormatSlack:
+		body, err := json.Marshal(map[string]any{"text": text})
+		return body, sub.URL, err
+	case FormatDingTalk:
+		target := sub.URL
+		if sub.Secret != "" {
+			ts := strconv.FormatInt(now.UnixMilli(), 10)
+			mac := hmac.New(sha256.New, []byte(sub.Secret))
+			mac.Write([]byte(ts + "\n" + sub.Secret))
+			u, err := url.Parse(sub.URL)
+			if err != nil {
+				return nil, "", err
+			}
+			q := u.Query()
+			q.Set("timestamp", ts)
+			q.Set("sign", base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+			u.RawQuery = q.Encode()
+			target = u.String()
+		}
+		body, err := json.Marshal(map[string]any{
+			"msgtype":  "markdown",
+			"markdown": map[string]any{"title": ev.EventType, "text": text},
+		})
+		return body, target, err
+	case FormatFeishu:
+		payload := map[string]any{
+			"msg_type": "text",
+			"content":  map[string]any{"text": text},
+		}
+		if sub.Secret != "" {
+			ts := strconv.FormatInt(now.Unix(), 10)
+			mac := hmac.New(sha256.New, []byte(ts+"\n"+sub.Secret))
+			payload["timestamp"] = ts
+			payload["sign"] = base64.StdEncoding.EncodeToString(mac.Sum(nil))
+		}
+		body, err := json.Marshal(payload)
+		return body, sub.URL, err
+	}
+	return nil, "", ErrInvalidFormat
+}
+
+// checkChatResponse reads the provider status out of a 2xx answer:
+// DingTalk reports errcode and Feishu code, both 0 on success.
+func checkChatResponse(format string, body []byte) error {
+	var resp struct {
+		ErrCode *int   `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+		Code    *int   `json:"code"`
+		Msg     string `json:"msg"`
+	}
+	switch format {
+	case FormatDingTalk:
+		if json.Unmarshal(body, &resp) == nil && resp.ErrCode != nil && *resp.ErrCode != 0 {
+			return fmt.Errorf("dingtalk errcode %d: %s", *resp.ErrCode, resp.ErrMsg)
+		}
+	case FormatFeishu:
+		if json.Unmarshal(body, &resp) == nil && resp.Code != nil && *resp.Code != 0 {
+			return fmt.Errorf("feishu code %d: %s", *resp.Code, resp.Msg)
+		}
+	}
+	return nil
+}
+
+// rateLimit is the per-minute cap applied to sub; 0 means unlimited.
+func rateLimit(sub Subscription) int {
+	if sub.RateLimit > 0 {
+		return sub.RateLimit
+	}
+	if sub.RateLimit == 0 && sub.Format != "" && sub.Format != FormatJSON {
+		return defaultChatRateLimit
+	}
+	return 0
+}
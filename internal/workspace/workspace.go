@@ -0,0 +1,399 @@
+package workspace
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	defaultQuotaBytes   int64 = 100 << 20
+	defaultMaxFileBytes int64 = 10 << 20
+)
+
+var (
+	ErrOutsideWorkspace = errors.New("path is outside the workspace")
+	ErrQuotaExceeded    = errors.New("workspace quota exceeded")
+	ErrFileTooLarge     = errors.New("file exceeds the workspace file size limit")
+	// ErrProtectedPath rejects writes into git metadata: a repository's
+	// config decides which programs git runs.
+	ErrProtectedPath = errors.New("path is inside a .git directory")
+)
+
+var sessionNameRE = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// maxSessionPrefix bounds the readable part of a session directory name.
+const maxSessionPrefix = 64
+
+// Config bounds the gateway-hosted workspaces. Each session gets its own
+// directory under Root.
+type Config struct {
+	Root         string `json:"root"`
+	QuotaBytes   int64  `json:"quota_bytes"`
+	MaxFileBytes int64  `json:"max_file_bytes"`
+}
+
+// Entry is one item of a directory listing; Path is relative to the
+// session root and starts with a slash.
+type Entry struct {
+	Name  string `json:"name"`
+	Path  string `json:"path"`
+	IsDir bool   `json:"is_dir"`
+	Size  int64  `json:"size"`
+}
+
+// Manager hands out per-session scratch directories. Paths from tool
+// calls are always taken relative to the session directory, and writes
+// through the manager are checked against the quota and remembered so the
+// session's changes can be diffed. Manager is safe for concurrent use.
+type Manager struct {
+	cfg Config
+
+	mu sync.Mutex
+	// baselines holds, per session and path, the content a file had before
+	// the session first wrote it; nil means the file did not exist.
+	baselines map[string]map[string]*[]byte
+}
+
+// New creates the root directory and applies the default limits.
+func New(cfg Config) (*Manager, error) {
+	if strings.TrimSpace(cfg.Root) == "" {
+		return nil, fmt.Errorf("workspace root is required")
+	}
+	root, err := filepath.Abs(strings.TrimSpace(cfg.Root))
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("create workspace root: %w", err)
+	}
+	cfg.Root = root
+	if cfg.QuotaBytes <= 0 {
+		cfg.QuotaBytes = defaultQuotaBytes
+	}
+	if cfg.MaxFileBytes <= 0 {
+		cfg.MaxFileBytes = defaultMaxFileBytes
+	}
+	return &Manager{cfg: cfg, baselines: map[string]map[string]*[]byte{}}, nil
+}
+
+// NewFromEnv reads WORKSPACE_ROOT, WORKSPACE_QUOTA_BYTES and
+// WORKSPACE_MAX_FILE_BYTES. It returns nil when no root is set.
+func NewFromEnv() (*Manager, error) {
+	cfg := Config{Root: os.Getenv("WORKSPACE_ROOT")}
+	if strings.TrimSpace(cfg.Root) == "" {
+		return nil, nil
+	}
+	for key, dst := range map[string]*int64{
+		"WORKSPACE_QUOTA_BYTES":    &cfg.QuotaBytes,
+		"WORKSPACE_MAX_FILE_BYTES": &cfg.MaxFileBytes,
+	} {
+		raw := strings.TrimSpace(os.Getenv(key))
+		if raw == "" {
+			continue
+		}
+		v, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || v <= 0 {
+			return nil, fmt.Errorf("invalid %s: %q", key, raw)
+		}
+		*dst = v
+	}
+	return New(cfg)
+}
+
+// Config returns the limits in effect.
+func (m *Manager) Config() Config {
+	return m.cfg
+}
+
+// Dir returns the session's directory, creating it.
+func (m *Manager) Dir(sessionID string) (string, error) {
+	dir := m.sessionDir(sessionID)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+func (m *Manager) sessionDir(sessionID string) string {
+	return filepath.Join(m.cfg.Root, sessionName(sessionID))
+}
+
+// sessionName derives the directory name of a session: a readable prefix
+// of the id followed by a hash of the full id, so ids that sanitize alike,
+// such as "a/b" and "a_b", still get separate directories.
+func sessionName(sessionID string) string {
+	sessionID = strings.TrimSpace(sessionID)
+	if sessionID == "" {
+		return "default"
+	}
+	prefix := strings.Trim(sessionNameRE.ReplaceAllString(sessionID, "_"), ".")
+	if len(prefix) > maxSessionPrefix {
+		prefix = prefix[:maxSessionPrefix]
+	}
+	sum := sha256.Sum256([]byte(sessionID))
+	hash := hex.EncodeToString(sum[:8])
+	if prefix == "" {
+		return hash
+	}
+	return prefix + "-" + hash
+}
+
+// Resolve maps a path from a tool call into the session directory.
+// Absolute paths are taken relative to the session root, and nothing,
+// symlinks included, may point outside it.
+func (m *Manager) Resolve(sessionID, path string) (string, error) {
+	full, _, err := m.resolve(sessionID, path)
+	return full, err
+}
+
+// resolve is Resolve that also returns the path, symlinks resolved,
+// relative to the session directory.
+func (m *Manager) resolve(sessionID, path string) (string, string, error) {
+	if strings.TrimSpace(path) == "" {
+		return "", "", fmt.Errorf("path is required")
+	}
+	dir, err := m.Dir(sessionID)
+	if err != nil {
+		return "", "", err
+	}
+	full := filepath.Join(dir, filepath.Clean("/"+path))
+	real := full
+	for probe := full; ; probe = filepath.Dir(probe) {
+		if resolved, err := filepath.EvalSymlinks(probe); err == nil {
+			real = filepath.Join(resolved, strings.TrimPrefix(full, probe))
+			break
+		}
+		if probe == dir || probe == filepath.Dir(probe) {
+			break
+		}
+	}
+	realDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", "", err
+	}
+	if real != realDir && !strings.HasPrefix(real, realDir+string(filepath.Separator)) {
+		return "", "", fmt.Errorf("%w: %q", ErrOutsideWorkspace, path)
+	}
+	return full, strings.TrimPrefix(real, realDir), nil
+}
+
+// resolveWritable is resolve for paths about to be changed, which must
+// not lie inside a .git directory.
+func (m *Manager) resolveWritable(sessionID, path string) (string, error) {
+	full, rel, err := m.resolve(sessionID, path)
+	if err != nil {
+		return "", err
+	}
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		if strings.EqualFold(part, ".git") {
+			return "", fmt.Errorf("%w: %q", ErrProtectedPath, path)
+		}
+	}
+	return full, nil
+}
+
+// Read returns a file's content.
+func (m *Manager) Read(sessionID, path string) ([]byte, error) {
+	full, err := m.Resolve(sessionID, path)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(full)
+}
+
+// Write replaces a file's content, creating parent directories. The write
+// fails when the file or the session's total usage would exceed the
+// limits.
+func (m *Manager) Write(sessionID, path string, data []byte) error {
+	full, err := m.resolveWritable(sessionID, path)
+	if err != nil {
+		return err
+	}
+	if int64(len(data)) > m.cfg.MaxFileBytes {
+		return fmt.Errorf("%w (%d bytes, limit %d)", ErrFileTooLarge, len(data), m.cfg.MaxFileBytes)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	used, err := m.usage(sessionID)
+	if err != nil {
+		return err
+	}
+	prev, readErr := os.ReadFile(full)
+	if used-int64(len(prev))+int64(len(data)) > m.cfg.QuotaBytes {
+		return fmt.Errorf("%w (limit %d bytes)", ErrQuotaExceeded, m.cfg.QuotaBytes)
+	}
+	if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(full, data, 0o644); err != nil {
+		return err
+	}
+	if readErr != nil {
+		prev = nil
+	}
+	m.remember(sessionID, full, prev, readErr == nil)
+	return nil
+}
+
+// Remove deletes a file written in the session.
+func (m *Manager) Remove(sessionID, path string) error {
+	full, err := m.resolveWritable(sessionID, path)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	prev, readErr := os.ReadFile(full)
+	if err := os.Remove(full); err != nil {
+		return err
+	}
+	m.remember(sessionID, full, prev, readErr == nil)
+	return nil
+}
+
+// remember records the baseline of full the first time the session
+// changes it. Callers hold m.mu.
+func (m *Manager) remember(sessionID, full string, prev []byte, existed bool) {
+	name := sessionName(sessionID)
+	files := m.baselines[name]
+	if files == nil {
+		files = map[string]*[]byte{}
+		m.baselines[name] = files
+	}
+	if _, ok := files[full]; ok {
+		return
+	}
+	if existed {
+		files[full] = &prev
+	} else {
+		files[full] = nil
+	}
+}
+
+// List returns the entries of a directory, directories first.
+func (m *Manager) List(sessionID, path string) ([]Entry, error) {
+	if strings.TrimSpace(path) == "" {
+		path = "/"
+	}
+	full, err := m.Resolve(sessionID, path)
+	if err != nil {
+		return nil, err
+	}
+	items, err := os.ReadDir(full)
+	if err != nil {
+		return nil, err
+	}
+	dir := m.sessionDir(sessionID)
+	out := make([]Entry, 0, len(items))
+	for _, item := range items {
+		entry := Entry{Name: item.Name(), IsDir: item.IsDir(), Path: relPath(dir, filepath.Join(full, item.Name()))}
+		if info, err := item.Info(); err == nil && !item.IsDir() {
+			entry.Size = info.Size()
+		}
+		out = append(out, entry)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].IsDir != out[j].IsDir {
+			return out[i].IsDir
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out, nil
+}
+
+// Diff returns a unified diff of the session's changes since it first
+// wrote each file. An empty path covers every changed file.
+func (m *Manager) Diff(sessionID, path string) (string, error) {
+	var only string
+	if strings.TrimSpace(path) != "" {
+		full, err := m.Resolve(sessionID, path)
+		if err != nil {
+			return "", err
+		}
+		only = full
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	dir := m.sessionDir(sessionID)
+	files := m.baselines[sessionName(sessionID)]
+	paths := make([]string, 0, len(files))
+	for full := range files {
+		if only == "" || full == only {
+			paths = append(paths, full)
+		}
+	}
+	sort.Strings(paths)
+	var b strings.Builder
+	for _, full := range paths {
+		var before, after []byte
+		oldName, newName := "a"+relPath(dir, full), "b"+relPath(dir, full)
+		if base := files[full]; base != nil {
+			before = *base
+		} else {
+			oldName = "/dev/null"
+		}
+		current, err := os.ReadFile(full)
+		if err != nil {
+			if !errors.Is(err, fs.ErrNotExist) {
+				return "", err
+			}
+			newName = "/dev/null"
+		} else {
+			after = current
+		}
+		b.WriteString(unifiedDiff(oldName, newName, string(before), string(after)))
+	}
+	return b.String(), nil
+}
+
+// Usage returns the bytes the session's files take up.
+func (m *Manager) Usage(sessionID string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.usage(sessionID)
+}
+
+func (m *Manager) usage(sessionID string) (int64, error) {
+	var total int64
+	err := filepath.WalkDir(m.sessionDir(sessionID), func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				total += info.Size()
+			}
+		}
+		return nil
+	})
+	return total, err
+}
+
+// Release deletes the session's directory and change history.
+func (m *Manager) Release(sessionID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.baselines, sessionName(sessionID))
+	return os.RemoveAll(m.sessionDir(sessionID))
+}
+
+func relPath(dir, full string) string {
+	rel, err := filepath.Rel(dir, full)
+	if err != nil {
+		return full
+	}
+	return "/" + filepath.ToSlash(rel)
+}
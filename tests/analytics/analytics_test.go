@@ -0,0 +1,57 @@
+package analytics_test
+
+import (
+	"testing"
+	"time"
+
+	. "ccgateway/internal/analytics"
+)
+
+func TestAggregateBucketsAndGroups(t *testing.T) {
+	since := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	q := Query{Since: since, Until: since.Add(3 * time.Hour), Bucket: time.Hour, GroupBy: "model"}
+	samples := []Sample{
+		{Time: since.Add(10 * time.Minute), Model: "a", LatencyMS: 100, InputTokens: 10, OutputTokens: 5, CostUSD: 0.01},
+		{Time: since.Add(20 * time.Minute), Model: "a", LatencyMS: 300, Failed: true, ToolLoopRounds: 2},
+		{Time: since.Add(2*time.Hour + time.Minute), Model: "a", LatencyMS: 200, ToolLoopRounds: 4},
+		{Time: since.Add(90 * time.Minute), Model: "", LatencyMS: 50},
+		{Time: since.Add(-time.Minute), Model: "a"},
+		{Time: since.Add(3 * time.Hour), Model: "a"},
+	}
+	report, err := Aggregate(q, samples)
+	if err != nil {
+		t.Fatalf("aggregate: %v", err)
+	}
+	if report.Totals.Requests != 4 || report.Totals.Errors != 1 || report.Totals.ErrorRate != 0.25 {
+		t.Fatalf("unexpected totals: %+v", report.Totals)
+	}
+	if len(report.Series) != 2 || report.Series[0].Key != "a" || report.Series[1].Key != "unknown" {
+		t.Fatalf("unexpected series: %+v", report.Series)
+	}
+	a := report.Series[0]
+	if len(a.Buckets) != 3 || a.Buckets[0].Requests != 2 || a.Buckets[1].Requests != 0 || a.Buckets[2].Requests != 1 {
+		t.Fatalf("unexpected buckets: %+v", a.Buckets)
+	}
+	if !a.Buckets[2].Start.Equal(since.Add(2 * time.Hour)) {
+		t.Fatalf("unexpected bucket start: %v", a.Buckets[2].Start)
+	}
+	if a.Totals.LatencyMS.P50 != 200 || a.Totals.LatencyMS.P99 != 300 || a.Totals.LatencyMS.Avg != 200 {
+		t.Fatalf("unexpected latency: %+v", a.Totals.LatencyMS)
+	}
+	if a.Totals.ToolLoopRounds != 6 || a.Totals.AvgToolLoopRounds != 3 || a.Totals.InputTokens != 10 || a.Totals.CostUSD != 0.01 {
+		t.Fatalf("unexpected totals for a: %+v", a.Totals)
+	}
+}
+
+func TestQueryValidate(t *testing.T) {
+	since := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	if _, err := (Query{Since: since, Until: since.Add(24 * time.Hour), Bucket: time.Second}).Validate(); err == nil {
+		t.Fatalf("expected too many buckets to be rejected")
+	}
+	if _, err := (Query{Since: since, Until: since.Add(time.Hour), Bucket: time.Minute, GroupBy: "user"}).Validate(); err == nil {
+		t.Fatalf("expected unknown group_by to be rejected")
+	}
+	if n, err := (Query{Since: since, Until: since.Add(90 * time.Minute), Bucket: time.Hour}).Validate(); err != nil || n != 2 {
+		t.Fatalf("expected 2 buckets, got %d %v", n, err)
+	}
+}
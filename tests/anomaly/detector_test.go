@@ -0,0 +1,148 @@
+package anomaly_test
+
+import (
+	"testing"
+	"time"
+
+	. "ccgateway/internal/anomaly"
+)
+
+var epoch = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// feed records requests calls, the first errors of them failed, in the
+// bucket starting minute minutes after epoch, and returns the findings
+// the first call produced by closing the previous bucket.
+func feed(d *Detector, adapter string, minute, requests, errors int) []Finding {
+	at := epoch.Add(time.Duration(minute) * time.Minute)
+	var findings []Finding
+	for i := 0; i < requests; i++ {
+		got := d.Observe(adapter, i < errors, at.Add(time.Duration(i)*time.Millisecond))
+		if i == 0 {
+			findings = got
+		}
+	}
+	return findings
+}
+
+func newDetector() *Detector {
+	cfg := DefaultConfig()
+	cfg.WarmupBuckets = 5
+	cfg.MinRequests = 10
+	return NewDetector(cfg)
+}
+
+func train(t *testing.T, d *Detector, adapter string, minutes int) {
+	t.Helper()
+	for m := 0; m < minutes; m++ {
+		requests := 30
+		if m%2 == 1 {
+			requests = 34
+		}
+		if got := feed(d, adapter, m, requests, 1); len(got) != 0 {
+			t.Fatalf("minute %d: steady traffic must not be anomalous, got %+v", m, got)
+		}
+	}
+}
+
+func TestDetectorFlagsErrorRateSpike(t *testing.T) {
+	d := newDetector()
+	train(t, d, "primary", 10)
+	feed(d, "primary", 10, 30, 20)
+	findings := feed(d, "primary", 11, 30, 1)
+	if len(findings) != 1 || findings[0].Kind != KindErrorRate || findings[0].Adapter != "primary" {
+		t.Fatalf("expected an error rate finding, got %+v", findings)
+	}
+	if f := findings[0]; f.Requests != 30 || f.Errors != 20 || !f.BucketStart.Equal(epoch.Add(10*time.Minute)) {
+		t.Fatalf("unexpected finding: %+v", f)
+	}
+
+	feed(d, "primary", 12, 30, 20)
+	if findings := feed(d, "primary", 13, 30, 1); len(findings) != 0 {
+		t.Fatalf("cooldown must suppress the repeat, got %+v", findings)
+	}
+	status := d.Snapshot(epoch.Add(13 * time.Minute))
+	if status.Detected != 1 || len(status.Active) != 1 || len(status.Recent) != 1 {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+	if status.Active[0].Kind != KindErrorRate {
+		t.Fatalf("unexpected active finding: %+v", status.Active[0])
+	}
+	if status = d.Snapshot(epoch.Add(time.Hour)); len(status.Active) != 0 || len(status.Recent) != 1 {
+		t.Fatalf("finding must expire from active after the cooldown: %+v", status)
+	}
+}
+
+func TestDetectorFlagsVolumeSpikeAndDrop(t *testing.T) {
+	d := newDetector()
+	train(t, d, "busy", 10)
+	feed(d, "busy", 10, 200, 0)
+	findings := feed(d, "busy", 11, 30, 0)
+	if len(findings) != 1 || findings[0].Kind != KindVolumeSpike || findings[0].ZScore < 3 {
+		t.Fatalf("expected a volume spike, got %+v", findings)
+	}
+
+	train(t, d, "idle", 10)
+	feed(d, "idle", 10, 2, 0)
+	findings = feed(d, "idle", 11, 30, 0)
+	if len(findings) != 1 || findings[0].Kind != KindVolumeDrop || findings[0].Observed != 2 {
+		t.Fatalf("expected a volume drop, got %+v", findings)
+	}
+}
+
+func TestDetectorWarmsUpPerAdapter(t *testing.T) {
+	d := newDetector()
+	train(t, d, "primary", 10)
+	for m := 0; m < 3; m++ {
+		feed(d, "backup", m, 30, 0)
+	}
+	if findings := feed(d, "backup", 3, 30, 30); len(findings) != 0 {
+		t.Fatalf("closing a warm bucket must not report, got %+v", findings)
+	}
+	if findings := feed(d, "backup", 4, 30, 0); len(findings) != 0 {
+		t.Fatalf("an adapter still warming up must not report, got %+v", findings)
+	}
+	status := d.Snapshot(epoch.Add(5 * time.Minute))
+	if len(status.Adapters) != 2 || status.Adapters[0].Adapter != "backup" || !status.Adapters[0].WarmingUp {
+		t.Fatalf("unexpected baselines: %+v", status.Adapters)
+	}
+	if primary := status.Adapters[1]; primary.WarmingUp || primary.MeanRequests < 30 || primary.MeanRequests > 34 {
+		t.Fatalf("unexpected primary baseline: %+v", primary)
+	}
+}
+
+func TestDetectorIgnoresQuietAdapters(t *testing.T) {
+	d := newDetector()
+	for m := 0; m < 10; m++ {
+		feed(d, "quiet", m, 2, 0)
+	}
+	feed(d, "quiet", 10, 4, 4)
+	if findings := feed(d, "quiet", 11, 2, 0); len(findings) != 0 {
+		t.Fatalf("buckets below min requests must not report, got %+v", findings)
+	}
+}
+
+func TestDisabledDetectorObservesNothing(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Enabled = false
+	d := NewDetector(cfg)
+	feed(d, "primary", 0, 10, 10)
+	if status := d.Snapshot(epoch); status.Enabled || len(status.Adapters) != 0 {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+}
+
+func TestNewFromEnvValidates(t *testing.T) {
+	t.Setenv("ANOMALY_ZSCORE", "2.5")
+	t.Setenv("ANOMALY_BUCKET", "30s")
+	d, err := NewFromEnv()
+	if err != nil {
+		t.Fatalf("new from env: %v", err)
+	}
+	if cfg := d.Config(); cfg.ZScore != 2.5 || cfg.Bucket != 30*time.Second || !cfg.Enabled {
+		t.Fatalf("unexpected config: %+v", cfg)
+	}
+	t.Setenv("ANOMALY_MIN_REQUESTS", "0")
+	if _, err := NewFromEnv(); err == nil {
+		t.Fatalf("expected an error for ANOMALY_MIN_REQUESTS=0")
+	}
+}
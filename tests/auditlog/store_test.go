@@ -0,0 +1,91 @@
+package auditlog_test
+
+import (
+	. "ccgateway/internal/auditlog"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStoreAppendsPersistsAndPages(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	store, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, p := range []string{"/admin/settings", "/admin/upstream", "/admin/settings"} {
+		if _, err := store.Append(Entry{Timestamp: base.Add(time.Duration(i) * time.Minute), Method: "PUT", Path: p, Actor: "sha256:a"}); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+
+	reopened, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("reopen store: %v", err)
+	}
+	page, total := reopened.List(Query{Path: "/admin/settings", Limit: 1})
+	if total != 2 || len(page) != 1 {
+		t.Fatalf("expected 1 of 2 settings entries, got %d of %d", len(page), total)
+	}
+	if page[0].ID != "aud_00000003" {
+		t.Fatalf("expected newest entry first, got %+v", page[0])
+	}
+	page, _ = reopened.List(Query{Path: "/admin/settings", Limit: 1, Offset: 1})
+	if len(page) != 1 || page[0].ID != "aud_00000001" {
+		t.Fatalf("unexpected second page: %+v", page)
+	}
+	page, total = reopened.List(Query{Since: base.Add(30 * time.Second), Until: base.Add(90 * time.Second)})
+	if total != 1 || page[0].Path != "/admin/upstream" {
+		t.Fatalf("unexpected time window result: %+v", page)
+	}
+	next, err := reopened.Append(Entry{Method: "DELETE", Path: "/admin/channels/1"})
+	if err != nil {
+		t.Fatalf("append after reopen: %v", err)
+	}
+	if next.ID != "aud_00000004" {
+		t.Fatalf("expected sequence to continue after reopen, got %s", next.ID)
+	}
+}
+
+func TestDiffReportsKeyedChangesAndRedactsSecrets(t *testing.T) {
+	before := map[string]any{
+		"adapters": []any{
+			map[string]any{"name": "a", "base_url": "http://a", "api_key": "sk-old"},
+			map[string]any{"name": "b", "base_url": "http://b"},
+		},
+		"max_tokens": float64(10),
+	}
+	after := map[string]any{
+		"adapters": []any{
+			map[string]any{"name": "b", "base_url": "http://b"},
+			map[string]any{"name": "a", "base_url": "http://a2", "api_key": "sk-new"},
+		},
+		"max_tokens": float64(20),
+	}
+	changes := Diff(before, after)
+	got := map[string]Change{}
+	for _, c := range changes {
+		got[c.Path] = c
+	}
+	if len(got) != 3 {
+		t.Fatalf("expected 3 changes, got %+v", changes)
+	}
+	if c := got["adapters[a].base_url"]; c.Before != "http://a" || c.After != "http://a2" {
+		t.Fatalf("unexpected base_url change: %+v", c)
+	}
+	if c := got["adapters[a].api_key"]; c.Before != "[REDACTED]" || c.After != "[REDACTED]" {
+		t.Fatalf("expected redacted api key change, got %+v", c)
+	}
+	if c := got["max_tokens"]; c.After != float64(20) {
+		t.Fatalf("expected max_tokens left unredacted, got %+v", c)
+	}
+	redacted := Redact(map[string]any{"headers": map[string]any{"Authorization": "Bearer x"}}).(map[string]any)
+	if redacted["headers"].(map[string]any)["Authorization"] != "[REDACTED]" {
+		t.Fatalf("expected authorization header redacted, got %+v", redacted)
+	}
+	if id := ActorID("secret-admin"); !strings.HasPrefix(id, "sha256:") || strings.Contains(id, "secret") {
+		t.Fatalf("unexpected actor id %q", id)
+	}
+}
@@ -93,3 +93,87 @@ func TestLinkGitHubAndWeChatEnforceUniqueness(t *testing.T) {
 		t.Fatalf("expected wechat uniqueness conflict")
 	}
 }
+
+func TestPreferencesNormalizedAndValidated(t *testing.T) {
+	svc := auth.NewInMemoryService()
+	user, err := svc.Register("pref-user", "secret", auth.RoleUser)
+	if err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+	temp := 0.4
+	saved, err := svc.SetPreferences(user.ID, auth.Preferences{Mode: " Plan ", Model: " claude-x ", Temperature: &temp})
+	if err != nil {
+		t.Fatalf("set preferences: %v", err)
+	}
+	temp = 1.9
+	if saved.Mode != "plan" || saved.Model != "claude-x" || *saved.Temperature != 0.4 {
+		t.Fatalf("unexpected saved preferences: %+v", saved)
+	}
+	got, err := svc.Get(user.ID)
+	if err != nil || got.Preferences.Mode != "plan" || *got.Preferences.Temperature != 0.4 {
+		t.Fatalf("expected preferences on user record, got %+v (%v)", got, err)
+	}
+
+	bad := 2.5
+	if _, err := svc.SetPreferences(user.ID, auth.Preferences{Temperature: &bad}); err == nil {
+		t.Fatalf("expected out-of-range temperature to be rejected")
+	}
+	if _, err := svc.GetPreferences("missing"); err != auth.ErrUserNotFound {
+		t.Fatalf("expected ErrUserNotFound, got %v", err)
+	}
+}
+
+func TestSoftDeleteAndRestoreUser(t *testing.T) {
+	svc := auth.NewInMemoryService()
+	user, err := svc.RegisterWithEmail("alice", "alice@example.com", "secret-pass", auth.RoleUser)
+	if err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+	if err := svc.SoftDelete(user.ID); err != nil {
+		t.Fatalf("soft delete: %v", err)
+	}
+	if _, err := svc.Login("alice", "secret-pass"); err == nil {
+		t.Fatalf("soft-deleted user must not log in")
+	}
+	if got, err := svc.Get(user.ID); err != nil || got.Status != auth.StatusDeleted {
+		t.Fatalf("soft-deleted user should stay resolvable as deleted: %+v %v", got, err)
+	}
+	deleted := svc.ListDeleted()
+	if len(deleted) != 1 || deleted[0].Username != "alice" || deleted[0].DeletedAt == nil {
+		t.Fatalf("expected pre-delete snapshot in trash, got %+v", deleted)
+	}
+
+	restored, err := svc.Restore(user.ID)
+	if err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if restored.Username != "alice" || restored.Status == auth.StatusDeleted || restored.DeletedAt != nil {
+		t.Fatalf("unexpected restored user: %+v", restored)
+	}
+	if _, err := svc.Login("alice", "secret-pass"); err != nil {
+		t.Fatalf("restored user should log in: %v", err)
+	}
+	if _, err := svc.GetByEmail("alice@example.com"); err != nil {
+		t.Fatalf("restored email should be indexed: %v", err)
+	}
+}
+
+func TestRestoreUserFailsWhenUsernameTaken(t *testing.T) {
+	svc := auth.NewInMemoryService()
+	user, _ := svc.Register("bob", "secret-pass", auth.RoleUser)
+	if err := svc.SoftDelete(user.ID); err != nil {
+		t.Fatalf("soft delete: %v", err)
+	}
+	if _, err := svc.Register("bob", "other-pass", auth.RoleUser); err != nil {
+		t.Fatalf("username should be free after delete: %v", err)
+	}
+	if _, err := svc.Restore(user.ID); err != auth.ErrUserAlreadyExists {
+		t.Fatalf("expected ErrUserAlreadyExists, got %v", err)
+	}
+	if err := svc.Purge(user.ID); err != nil {
+		t.Fatalf("purge: %v", err)
+	}
+	if len(svc.ListDeleted()) != 0 {
+		t.Fatalf("trash should be empty after purge")
+	}
+}
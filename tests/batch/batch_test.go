@@ -0,0 +1,220 @@
+package batch_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "ccgateway/internal/batch"
+)
+
+func jsonl(lines ...string) []byte {
+	return []byte(strings.Join(lines, "\n") + "\n")
+}
+
+func chatLine(id string) string {
+	return `{"custom_id":"` + id + `","method":"POST","url":"/v1/chat/completions","body":{"model":"gpt-test","messages":[{"role":"user","content":"hi"}]}}`
+}
+
+func decodeResults(t *testing.T, data []byte) []ResultLine {
+	t.Helper()
+	var out []ResultLine
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		var line ResultLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("invalid result line %q: %v", scanner.Text(), err)
+		}
+		out = append(out, line)
+	}
+	return out
+}
+
+func waitFinished(t *testing.T, m *Manager, id string) Batch {
+	t.Helper()
+	m.Wait()
+	b, ok := m.Get("", id)
+	if !ok {
+		t.Fatalf("batch %s not found", id)
+	}
+	return b
+}
+
+func TestParseRejectsInvalidLines(t *testing.T) {
+	input := jsonl(
+		chatLine("a"),
+		chatLine("a"),
+		`not json`,
+		`{"custom_id":"b","method":"GET","url":"/v1/chat/completions","body":{}}`,
+		`{"custom_id":"c","method":"POST","url":"/v1/messages","body":{}}`,
+		`{"custom_id":"d","method":"POST","url":"/v1/chat/completions","body":{"stream":true}}`,
+		`{"method":"POST","url":"/v1/chat/completions","body":{}}`,
+	)
+	lines, errs := Parse(input, "/v1/chat/completions", 100)
+	if len(lines) != 1 || lines[0].CustomID != "a" {
+		t.Fatalf("expected only the first line accepted, got %+v", lines)
+	}
+	want := []string{"duplicate_custom_id", "invalid_json_line", "invalid_method", "mismatched_url", "invalid_body", "missing_custom_id"}
+	if len(errs) != len(want) {
+		t.Fatalf("expected %d errors, got %+v", len(want), errs)
+	}
+	for i, code := range want {
+		if errs[i].Code != code || errs[i].Line != i+2 {
+			t.Fatalf("error %d: expected %s on line %d, got %+v", i, code, i+2, errs[i])
+		}
+	}
+
+	if _, errs := Parse(jsonl(chatLine("a"), chatLine("b")), "/v1/chat/completions", 1); len(errs) != 1 || errs[0].Code != "too_many_requests" {
+		t.Fatalf("expected line limit error, got %+v", errs)
+	}
+}
+
+func TestCreateFailsBatchOnValidationErrors(t *testing.T) {
+	m := NewManager(Config{})
+	if _, err := m.Create(context.Background(), CreateInput{Endpoint: "/v1/embeddings", Input: jsonl(chatLine("a"))}); !errors.Is(err, ErrInvalidEndpoint) {
+		t.Fatalf("expected ErrInvalidEndpoint, got %v", err)
+	}
+	if _, err := m.Create(context.Background(), CreateInput{Endpoint: "/v1/chat/completions", CompletionWindow: "soon", Input: jsonl(chatLine("a"))}); !errors.Is(err, ErrInvalidWindow) {
+		t.Fatalf("expected ErrInvalidWindow, got %v", err)
+	}
+	b, err := m.Create(context.Background(), CreateInput{Endpoint: "/v1/chat/completions", Input: []byte("\n")})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if b.Status != StatusFailed || b.Errors == nil || b.Errors.Data[0].Code != "empty_file" || b.FailedAt == nil {
+		t.Fatalf("expected failed batch with errors, got %+v", b)
+	}
+}
+
+func TestBatchRunsLinesAndSplitsResults(t *testing.T) {
+	m := NewManager(Config{Concurrency: 2})
+	var inFlight, peak atomic.Int32
+	m.SetExecutor(func(ctx context.Context, endpoint string, body json.RawMessage) Response {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			old := peak.Load()
+			if n <= old || peak.CompareAndSwap(old, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		var req struct {
+			Messages []struct {
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		_ = json.Unmarshal(body, &req)
+		if req.Messages[0].Content == "fail" {
+			return Response{StatusCode: 400, RequestID: "run_bad", Body: json.RawMessage(`{"error":{"message":"bad model"}}`)}
+		}
+		return Response{StatusCode: 200, RequestID: "run_ok", Body: json.RawMessage(`{"object":"chat.completion"}`)}
+	})
+
+	input := jsonl(chatLine("a"), chatLine("b"), chatLine("c"), strings.Replace(chatLine("d"), `"hi"`, `"fail"`, 1))
+	b, err := m.Create(context.Background(), CreateInput{Endpoint: "/v1/chat/completions", Input: input, Metadata: map[string]string{"job": "nightly"}})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if b.Status != StatusInProgress || b.Object != "batch" || b.RequestCounts.Total != 4 || b.ExpiresAt-b.CreatedAt != 86400 {
+		t.Fatalf("unexpected new batch %+v", b)
+	}
+	b = waitFinished(t, m, b.ID)
+	if b.Status != StatusCompleted || b.CompletedAt == nil || b.RequestCounts.Completed != 3 || b.RequestCounts.Failed != 1 {
+		t.Fatalf("unexpected finished batch %+v", b)
+	}
+	if b.OutputFileID == nil || b.ErrorFileID == nil {
+		t.Fatalf("expected output and error files, got %+v", b)
+	}
+	if got := peak.Load(); got > 2 {
+		t.Fatalf("expected at most 2 requests in flight, got %d", got)
+	}
+
+	data, err := m.Results("", b.ID, false)
+	if err != nil {
+		t.Fatalf("results: %v", err)
+	}
+	output := decodeResults(t, data)
+	if len(output) != 3 || output[0].Response.StatusCode != 200 || output[0].Error != nil || !strings.HasPrefix(output[0].ID, "batch_req_") {
+		t.Fatalf("unexpected output %s", data)
+	}
+	data, _ = m.Results("", b.ID, true)
+	failures := decodeResults(t, data)
+	if len(failures) != 1 || failures[0].CustomID != "d" || failures[0].Error.Code != "400" || failures[0].Error.Message != "bad model" {
+		t.Fatalf("unexpected errors %s", data)
+	}
+
+	if _, ok := m.Get("someone-else", b.ID); ok {
+		t.Fatalf("expected batches scoped to their owner")
+	}
+}
+
+func TestBatchRetriesOverloadedRequests(t *testing.T) {
+	m := NewManager(Config{Backoff: time.Millisecond})
+	var calls atomic.Int32
+	m.SetExecutor(func(ctx context.Context, endpoint string, body json.RawMessage) Response {
+		if calls.Add(1) < 3 {
+			return Response{StatusCode: 503}
+		}
+		return Response{StatusCode: 200, Body: json.RawMessage(`{}`)}
+	})
+	b, _ := m.Create(context.Background(), CreateInput{Endpoint: "/v1/chat/completions", Input: jsonl(chatLine("a"))})
+	b = waitFinished(t, m, b.ID)
+	if calls.Load() != 3 || b.RequestCounts.Completed != 1 {
+		t.Fatalf("expected success on the third attempt, got calls=%d batch=%+v", calls.Load(), b)
+	}
+}
+
+func TestCancelStopsRemainingLines(t *testing.T) {
+	m := NewManager(Config{Concurrency: 1})
+	release := make(chan struct{})
+	var calls atomic.Int32
+	m.SetExecutor(func(ctx context.Context, endpoint string, body json.RawMessage) Response {
+		calls.Add(1)
+		<-release
+		return Response{StatusCode: 200, Body: json.RawMessage(`{}`)}
+	})
+	b, _ := m.Create(context.Background(), CreateInput{Endpoint: "/v1/chat/completions", Input: jsonl(chatLine("a"), chatLine("b"), chatLine("c"))})
+	for calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := m.Results("", b.ID, false); err == nil {
+		t.Fatalf("expected results unavailable while running")
+	}
+	cancelling, err := m.Cancel("", b.ID)
+	if err != nil || cancelling.Status != StatusCancelling {
+		t.Fatalf("expected cancelling, got %+v err=%v", cancelling, err)
+	}
+	close(release)
+	b = waitFinished(t, m, b.ID)
+	if b.Status != StatusCancelled || b.CancelledAt == nil || calls.Load() != 1 || b.RequestCounts.Completed != 1 {
+		t.Fatalf("expected cancelled after one request, got calls=%d batch=%+v", calls.Load(), b)
+	}
+	if _, err := m.Cancel("", b.ID); !errors.Is(err, ErrFinished) {
+		t.Fatalf("expected ErrFinished, got %v", err)
+	}
+}
+
+func TestExpiredBatchReportsUnexecutedLines(t *testing.T) {
+	m := NewManager(Config{Concurrency: 1})
+	m.SetExecutor(func(ctx context.Context, endpoint string, body json.RawMessage) Response {
+		<-ctx.Done()
+		return Response{StatusCode: 504, Body: json.RawMessage(`{"error":{"message":"deadline exceeded"}}`)}
+	})
+	b, _ := m.Create(context.Background(), CreateInput{Endpoint: "/v1/chat/completions", CompletionWindow: "50ms", Input: jsonl(chatLine("a"), chatLine("b"))})
+	b = waitFinished(t, m, b.ID)
+	if b.Status != StatusExpired || b.ExpiredAt == nil {
+		t.Fatalf("expected expired batch, got %+v", b)
+	}
+	data, _ := m.Results("", b.ID, true)
+	failures := decodeResults(t, data)
+	if len(failures) != 2 || failures[1].CustomID != "b" || failures[1].Error.Code != "batch_expired" {
+		t.Fatalf("expected the unexecuted line marked batch_expired, got %s", data)
+	}
+}
@@ -0,0 +1,175 @@
+package billing_test
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	. "ccgateway/internal/billing"
+	"ccgateway/internal/costtrack"
+)
+
+func TestLedgerPricesRecordsAndReloads(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.jsonl")
+	prices := map[string]costtrack.ModelPricing{
+		"claude-sonnet-4": {InputPer1M: 3, OutputPer1M: 15},
+		"gpt-4o*":         {InputPer1M: 2.5, OutputPer1M: 10},
+		"*":               {InputPer1M: 1, OutputPer1M: 1},
+	}
+	ledger, err := NewLedger(path, prices)
+	if err != nil {
+		t.Fatalf("open ledger: %v", err)
+	}
+	exact, err := ledger.Record(UsageRecord{UserID: "u1", TokenID: 7, UpstreamModel: "claude-sonnet-4", InputTokens: 1000, OutputTokens: 2000})
+	if err != nil {
+		t.Fatalf("record: %v", err)
+	}
+	if exact.CostUSD != 0.033 || exact.PriceKey != "claude-sonnet-4" {
+		t.Fatalf("unexpected exact pricing: %+v", exact)
+	}
+	glob, _ := ledger.Record(UsageRecord{UserID: "u2", UpstreamModel: "gpt-4o-mini", InputTokens: 1_000_000})
+	if glob.PriceKey != "gpt-4o*" || glob.CostUSD != 2.5 {
+		t.Fatalf("unexpected glob pricing: %+v", glob)
+	}
+	fallback, _ := ledger.Record(UsageRecord{UserID: "u1", UpstreamModel: "local", OutputTokens: 500_000})
+	if fallback.PriceKey != "*" || fallback.CostUSD != 0.5 {
+		t.Fatalf("unexpected fallback pricing: %+v", fallback)
+	}
+	if err := ledger.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	reopened, err := NewLedger(path, prices)
+	if err != nil {
+		t.Fatalf("reopen ledger: %v", err)
+	}
+	defer reopened.Close()
+	records, total := reopened.List(UsageQuery{UserID: "u1"})
+	if total != 2 || len(records) != 2 {
+		t.Fatalf("expected 2 records for u1 after reload, got %d", total)
+	}
+	next, _ := reopened.Record(UsageRecord{UserID: "u3", UpstreamModel: "local"})
+	if next.ID == exact.ID || next.ID == fallback.ID || next.ID == glob.ID {
+		t.Fatalf("expected a fresh id after reload, got %s", next.ID)
+	}
+}
+
+func TestLedgerSummarizeAndCSV(t *testing.T) {
+	ledger, err := NewLedger("", map[string]costtrack.ModelPricing{"*": {InputPer1M: 1, OutputPer1M: 2}})
+	if err != nil {
+		t.Fatalf("open ledger: %v", err)
+	}
+	day1 := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	day2 := day1.Add(24 * time.Hour)
+	for _, rec := range []UsageRecord{
+		{Timestamp: day1, UserID: "alice", TokenID: 1, UpstreamModel: "m1", InputTokens: 1_000_000},
+		{Timestamp: day1, UserID: "bob", TokenID: 2, UpstreamModel: "m2", OutputTokens: 1_000_000},
+		{Timestamp: day2, UserID: "alice", TokenID: 1, UpstreamModel: "m2", InputTokens: 1_000_000, OutputTokens: 1_000_000},
+	} {
+		if _, err := ledger.Record(rec); err != nil {
+			t.Fatalf("record: %v", err)
+		}
+	}
+
+	byUser, totals, err := ledger.Summarize(UsageQuery{}, GroupByUser)
+	if err != nil {
+		t.Fatalf("summarize: %v", err)
+	}
+	if len(byUser) != 2 || byUser[0].Key != "alice" || byUser[0].CostUSD != 4 || byUser[0].Requests != 2 {
+		t.Fatalf("unexpected user groups: %+v", byUser)
+	}
+	if totals.Requests != 3 || totals.CostUSD != 6 {
+		t.Fatalf("unexpected totals: %+v", totals)
+	}
+	byDay, _, _ := ledger.Summarize(UsageQuery{Since: day2}, GroupByDay)
+	if len(byDay) != 1 || byDay[0].Key != "2026-03-02" {
+		t.Fatalf("unexpected day groups: %+v", byDay)
+	}
+	if _, _, err := ledger.Summarize(UsageQuery{}, "region"); err != ErrInvalidGroupBy {
+		t.Fatalf("expected ErrInvalidGroupBy, got %v", err)
+	}
+
+	byModel, _, _ := ledger.Summarize(UsageQuery{}, GroupByModel)
+	var buf bytes.Buffer
+	if err := WriteGroupsCSV(&buf, GroupByModel, byModel); err != nil {
+		t.Fatalf("write csv: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 || lines[0] != "model,requests,input_tokens,output_tokens,cost_usd" || !strings.HasPrefix(lines[1], "m2,2,") {
+		t.Fatalf("unexpected csv: %q", buf.String())
+	}
+}
+
+func TestLedgerDeleteWherePurgesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.jsonl")
+	ledger, err := NewLedger(path, nil)
+	if err != nil {
+		t.Fatalf("open ledger: %v", err)
+	}
+	for _, user := range []string{"u1", "u2", "u1"} {
+		if _, err := ledger.Record(UsageRecord{UserID: user, ProjectID: "acme", UpstreamModel: "m", InputTokens: 10}); err != nil {
+			t.Fatalf("record: %v", err)
+		}
+	}
+	n, err := ledger.DeleteWhere(func(rec UsageRecord) bool { return rec.UserID == "u1" })
+	if err != nil || n != 2 {
+		t.Fatalf("delete = %d, %v", n, err)
+	}
+	if _, err := ledger.Record(UsageRecord{UserID: "u3", UpstreamModel: "m"}); err != nil {
+		t.Fatalf("record after delete: %v", err)
+	}
+	if records, total := ledger.List(UsageQuery{ProjectID: "acme"}); total != 1 || records[0].UserID != "u2" {
+		t.Fatalf("unexpected records after delete: %+v", records)
+	}
+	_ = ledger.Close()
+
+	reopened, err := NewLedger(path, nil)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer reopened.Close()
+	records, total := reopened.List(UsageQuery{})
+	if total != 2 {
+		t.Fatalf("expected 2 records on disk, got %+v", records)
+	}
+	for _, rec := range records {
+		if rec.UserID == "u1" {
+			t.Fatalf("deleted record survived on disk: %+v", rec)
+		}
+	}
+}
+
+func TestLedgerChannelPricesAndGroupRatios(t *testing.T) {
+	ledger, err := NewLedger("", map[string]costtrack.ModelPricing{"*": {InputPer1M: 1, OutputPer1M: 1}})
+	if err != nil {
+		t.Fatalf("open ledger: %v", err)
+	}
+	if err := ledger.SetGroupRatios(map[string]float64{"vip": 0.5, "": 2}); err == nil {
+		t.Fatal("expected an empty group name to be rejected")
+	}
+	if err := ledger.SetGroupRatios(map[string]float64{"vip": 0.5}); err != nil {
+		t.Fatalf("set ratios: %v", err)
+	}
+
+	channelPrice := &costtrack.ModelPricing{InputPer1M: 10, OutputPer1M: 20}
+	served, _ := ledger.RecordServed(UsageRecord{UpstreamModel: "m", ChannelID: 4, Group: "vip", InputTokens: 100_000, OutputTokens: 100_000}, channelPrice)
+	if served.PriceKey != "channel:4" || served.GroupRatio != 0.5 || served.InputCostUSD != 0.5 || served.CostUSD != 1.5 {
+		t.Fatalf("unexpected channel pricing: %+v", served)
+	}
+	plain, _ := ledger.Record(UsageRecord{UpstreamModel: "m", Group: "default", InputTokens: 1_000_000})
+	if plain.PriceKey != "*" || plain.GroupRatio != 0 || plain.CostUSD != 1 {
+		t.Fatalf("expected model prices without markup, got %+v", plain)
+	}
+
+	groups, _, err := ledger.Summarize(UsageQuery{}, GroupByChannel)
+	if err != nil || len(groups) != 2 || groups[0].Key != "4" || groups[1].Key != "-" {
+		t.Fatalf("unexpected channel summary %+v (%v)", groups, err)
+	}
+	var buf bytes.Buffer
+	_ = WriteRecordsCSV(&buf, []UsageRecord{served})
+	if !strings.HasSuffix(strings.TrimSpace(buf.String()), ",channel:4,4,vip,0.5") {
+		t.Fatalf("unexpected csv: %q", buf.String())
+	}
+}
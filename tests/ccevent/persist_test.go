@@ -0,0 +1,130 @@
+package ccevent_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	. "ccgateway/internal/ccevent"
+)
+
+func TestOpenReloadsPersistedEvents(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	st, err := Open(path, Retention{})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	for _, typ := range []string{"run.created", "run.completed"} {
+		if _, err := st.Append(AppendInput{EventType: typ, RunID: "run_1"}); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+
+	reopened, err := Open(path, Retention{})
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	got := reopened.List(ListFilter{RunID: "run_1"})
+	if len(got) != 2 || got[0].EventType != "run.completed" {
+		t.Fatalf("expected both events back newest first, got %+v", got)
+	}
+	next, err := reopened.Append(AppendInput{EventType: "run.created"})
+	if err != nil {
+		t.Fatalf("append after reload: %v", err)
+	}
+	for _, e := range got {
+		if e.ID == next.ID {
+			t.Fatalf("expected a fresh id after reload, got %s twice", next.ID)
+		}
+	}
+}
+
+func TestRetentionMaxEventsKeepsNewest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	st, err := Open(path, Retention{MaxEvents: 3})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		if _, err := st.Append(AppendInput{EventType: "tick", Data: map[string]any{"i": i}}); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	if got := st.List(ListFilter{}); len(got) != 3 || got[2].Data["i"] != 2 {
+		t.Fatalf("expected the three newest events, got %+v", got)
+	}
+
+	if err := st.Compact(); err != nil {
+		t.Fatalf("compact: %v", err)
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if lines := strings.Count(string(raw), "\n"); lines != 3 {
+		t.Fatalf("expected compaction to leave 3 lines, got %d", lines)
+	}
+}
+
+func TestRetentionMaxAgeDropsOldEventsOnLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	old := `{"id":"evt_1_1","type":"event","event_type":"old","created_at":"2020-01-01T00:00:00Z"}` + "\n"
+	if err := os.WriteFile(path, []byte(old), 0o600); err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	st, err := Open(path, Retention{MaxAge: time.Hour})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if _, err := st.Append(AppendInput{EventType: "new"}); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	if got := st.List(ListFilter{}); len(got) != 1 || got[0].EventType != "new" {
+		t.Fatalf("expected only the fresh event, got %+v", got)
+	}
+	raw, _ := os.ReadFile(path)
+	if strings.Contains(string(raw), `"old"`) {
+		t.Fatalf("expected the expired event compacted out of the file, got %s", raw)
+	}
+}
+
+func TestDeleteBeforeSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	st, err := Open(path, Retention{})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	for _, typ := range []string{"a", "b", "a"} {
+		if _, err := st.Append(AppendInput{EventType: typ}); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	if n := st.DeleteBefore(time.Now().Add(time.Minute), "a"); n != 2 {
+		t.Fatalf("expected 2 deleted, got %d", n)
+	}
+
+	reopened, err := Open(path, Retention{})
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if got := reopened.List(ListFilter{}); len(got) != 1 || got[0].EventType != "b" {
+		t.Fatalf("expected only event b after restart, got %+v", got)
+	}
+}
+
+func TestAfterReturnsEventsPastCursor(t *testing.T) {
+	st := NewStore()
+	first, _ := st.Append(AppendInput{EventType: "a", SessionID: "s1"})
+	_, _ = st.Append(AppendInput{EventType: "b", SessionID: "s2"})
+	third, _ := st.Append(AppendInput{EventType: "c", SessionID: "s1"})
+
+	got, ok := st.After(first.ID, ListFilter{SessionID: "s1"})
+	if !ok || len(got) != 1 || got[0].ID != third.ID {
+		t.Fatalf("expected only the later s1 event, got %+v ok=%v", got, ok)
+	}
+	if _, ok := st.After("evt_missing", ListFilter{}); ok {
+		t.Fatalf("expected an unknown cursor to report ok=false")
+	}
+}
@@ -99,3 +99,33 @@ func TestMatchesFilter(t *testing.T) {
 		})
 	}
 }
+
+func TestSubscriberRegistry_DropsSlowSubscriber(t *testing.T) {
+	reg := NewSubscriberRegistry()
+	slow, cancelSlow := reg.Subscribe(ListFilter{})
+	defer cancelSlow()
+	fast, cancelFast := reg.Subscribe(ListFilter{})
+	defer cancelFast()
+
+	received := 0
+	for i := 0; i < 100; i++ {
+		reg.Notify(Event{ID: "evt"})
+		select {
+		case <-fast:
+			received++
+		default:
+		}
+	}
+	if received != 100 {
+		t.Fatalf("expected the draining subscriber to get every event, got %d", received)
+	}
+
+	buffered := 0
+	for range slow {
+		buffered++
+	}
+	if buffered == 0 || buffered >= 100 {
+		t.Fatalf("expected the slow subscriber to be closed after its buffer filled, got %d events", buffered)
+	}
+	cancelSlow()
+}
@@ -0,0 +1,71 @@
+package ccrun_test
+
+import (
+	"testing"
+
+	. "ccgateway/internal/ccrun"
+)
+
+func seedSearchRuns(t *testing.T, st *Store) {
+	t.Helper()
+	for _, in := range []struct {
+		id, mode, model, adapter, errText, record string
+		status                                    int
+	}{
+		{"run_a", "chat", "claude-x", "primary", "", "/v1/messages | status=200 | output=\"weather in Paris is sunny\"", 200},
+		{"run_b", "plan", "claude-y", "backup", "upstream timeout after retries", "/v1/messages | status=504 | error=\"upstream timeout\"", 504},
+		{"run_c", "chat", "gpt-z", "primary", "", "/v1/chat/completions | status=200 | output=\"Paris weather tomorrow\"", 200},
+	} {
+		if _, err := st.Create(CreateInput{ID: in.id, Path: "/v1/messages", Mode: in.mode, ClientModel: in.model}); err != nil {
+			t.Fatalf("create %s: %v", in.id, err)
+		}
+		if _, err := st.RecordAdapter(in.id, in.adapter); err != nil {
+			t.Fatalf("adapter %s: %v", in.id, err)
+		}
+		if _, err := st.Complete(in.id, CompleteInput{StatusCode: in.status, Error: in.errText, RecordText: in.record}); err != nil {
+			t.Fatalf("complete %s: %v", in.id, err)
+		}
+	}
+}
+
+func TestStoreSearchFullTextAndFilters(t *testing.T) {
+	st := NewStore()
+	seedSearchRuns(t, st)
+
+	runs, total := st.Search(SearchQuery{Text: "paris WEATH"})
+	if total != 2 || len(runs) != 2 || runs[0].ID != "run_c" || runs[1].ID != "run_a" {
+		t.Fatalf("expected run_c, run_a newest first, got %d %+v", total, runs)
+	}
+	if runs, _ := st.Search(SearchQuery{Text: "paris", Model: "gpt-z"}); len(runs) != 1 || runs[0].ID != "run_c" {
+		t.Fatalf("unexpected model-filtered result: %+v", runs)
+	}
+	if runs, _ := st.Search(SearchQuery{Status: "failed", ErrorContains: "TIMEOUT", Adapter: "backup"}); len(runs) != 1 || runs[0].ID != "run_b" {
+		t.Fatalf("unexpected error-filtered result: %+v", runs)
+	}
+	if _, total := st.Search(SearchQuery{Text: "nowhere"}); total != 0 {
+		t.Fatalf("expected no match for unknown token, got %d", total)
+	}
+	if runs, total := st.Search(SearchQuery{Mode: "chat", Limit: 1, Offset: 1}); total != 2 || len(runs) != 1 || runs[0].ID != "run_a" {
+		t.Fatalf("unexpected paged result: %d %+v", total, runs)
+	}
+	if _, total := st.Search(SearchQuery{MinDurationMS: 60_000}); total != 0 {
+		t.Fatalf("expected duration filter to exclude fast runs, got %d", total)
+	}
+}
+
+func TestStoreSearchIndexSurvivesRestoreAndDelete(t *testing.T) {
+	st := NewStore()
+	seedSearchRuns(t, st)
+
+	restored := NewStore()
+	if err := restored.Restore(st.Snapshot()); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if _, total := restored.Search(SearchQuery{Text: "sunny"}); total != 1 {
+		t.Fatalf("expected restored index to find run_a, got %d", total)
+	}
+	restored.DeleteWhere(func(r Run) bool { return r.ID == "run_a" })
+	if _, total := restored.Search(SearchQuery{Text: "sunny"}); total != 0 {
+		t.Fatalf("expected deleted run to leave the index, got %d", total)
+	}
+}
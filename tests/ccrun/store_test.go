@@ -137,3 +137,62 @@ func TestStoreSnapshotRestoreAndOnChange(t *testing.T) {
 		t.Fatalf("unexpected restored runs: %+v", list)
 	}
 }
+
+func TestStoreCorrelationIndex(t *testing.T) {
+	s := NewStore()
+	for _, in := range []CreateInput{
+		{ID: "run_a", Path: "/v1/messages", CorrelationID: "trace-1"},
+		{ID: "run_b", Path: "/v1/messages"},
+		{ID: "run_c", Path: "/v1/messages", CorrelationID: "trace-1"},
+	} {
+		if _, err := s.Create(in); err != nil {
+			t.Fatalf("create %s: %v", in.ID, err)
+		}
+	}
+	got := s.List(ListFilter{CorrelationID: "trace-1"})
+	if len(got) != 2 || got[0].ID != "run_c" || got[1].ID != "run_a" {
+		t.Fatalf("unexpected correlated runs: %+v", got)
+	}
+	if got := s.List(ListFilter{CorrelationID: "trace-1", Limit: 1}); len(got) != 1 || got[0].ID != "run_c" {
+		t.Fatalf("limit not applied: %+v", got)
+	}
+
+	s.DeleteWhere(func(r Run) bool { return r.ID == "run_c" })
+	if got := s.List(ListFilter{CorrelationID: "trace-1"}); len(got) != 1 || got[0].ID != "run_a" {
+		t.Fatalf("index not updated after delete: %+v", got)
+	}
+
+	restored := NewStore()
+	if err := restored.Restore(s.Snapshot()); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if got := restored.List(ListFilter{CorrelationID: "trace-1"}); len(got) != 1 || got[0].CorrelationID != "trace-1" {
+		t.Fatalf("index not rebuilt on restore: %+v", got)
+	}
+}
+
+func TestStoreRecordFeedbackKeepsOneRatingPerUser(t *testing.T) {
+	s := NewStore()
+	if _, err := s.Create(CreateInput{ID: "run_a", Path: "/v1/messages"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, err := s.RecordFeedback("run_a", Feedback{Rating: 1, UserID: "alice"}); err != nil {
+		t.Fatalf("feedback: %v", err)
+	}
+	if _, err := s.RecordFeedback("run_a", Feedback{Rating: -1, UserID: "bob"}); err != nil {
+		t.Fatalf("feedback: %v", err)
+	}
+	run, err := s.RecordFeedback("run_a", Feedback{Rating: -1, UserID: "alice", Comment: " wrong "})
+	if err != nil {
+		t.Fatalf("feedback: %v", err)
+	}
+	if len(run.Feedback) != 2 || run.Feedback[1].UserID != "alice" || run.Feedback[1].Rating != -1 || run.Feedback[1].Comment != "wrong" {
+		t.Fatalf("unexpected feedback: %+v", run.Feedback)
+	}
+	if _, err := s.RecordFeedback("run_a", Feedback{Rating: 5}); err == nil {
+		t.Fatalf("expected invalid rating to fail")
+	}
+	if _, err := s.RecordFeedback("run_missing", Feedback{Rating: 1}); err == nil {
+		t.Fatalf("expected unknown run to fail")
+	}
+}
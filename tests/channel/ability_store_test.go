@@ -80,3 +80,50 @@ func TestAbilityStoreGetReturnsClone(t *testing.T) {
 		t.Fatalf("expected store to keep original model mapping, got mutated copy")
 	}
 }
+
+func TestAbilityStoreSoftDeleteAndRestore(t *testing.T) {
+	store := channel.NewAbilityStore()
+	if err := store.AddChannel(&channel.Channel{
+		Name:   "adapter-a",
+		Type:   "openai",
+		Models: "model-a",
+		Group:  "default",
+		Status: channel.StatusEnabled,
+	}); err != nil {
+		t.Fatalf("add channel: %v", err)
+	}
+	ch, _ := store.GetChannelByGroupAndModel("default", "model-a")
+
+	if err := store.SoftDeleteChannel(ch.ID); err != nil {
+		t.Fatalf("soft delete: %v", err)
+	}
+	if _, ok := store.GetChannel(ch.ID); ok {
+		t.Fatalf("soft-deleted channel must not be live")
+	}
+	if _, ok := store.GetChannelByGroupAndModel("default", "model-a"); ok {
+		t.Fatalf("soft-deleted channel must not route")
+	}
+	deleted := store.DeletedChannels()
+	if len(deleted) != 1 || deleted[0].DeletedAt == nil {
+		t.Fatalf("expected channel in trash, got %+v", deleted)
+	}
+
+	restored, err := store.RestoreChannel(ch.ID)
+	if err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if restored.ID != ch.ID || restored.DeletedAt != nil {
+		t.Fatalf("unexpected restored channel: %+v", restored)
+	}
+	if _, ok := store.GetChannelByGroupAndModel("default", "model-a"); !ok {
+		t.Fatalf("restored channel should route again")
+	}
+
+	_ = store.SoftDeleteChannel(ch.ID)
+	if err := store.PurgeChannel(ch.ID); err != nil {
+		t.Fatalf("purge: %v", err)
+	}
+	if _, err := store.RestoreChannel(ch.ID); err != channel.ErrChannelNotFound {
+		t.Fatalf("expected not found after purge, got %v", err)
+	}
+}
@@ -0,0 +1,68 @@
+package channel_test
+
+import (
+	"testing"
+	"time"
+
+	"ccgateway/internal/channel"
+)
+
+func TestHealthTrackerScalesWeightWithinBounds(t *testing.T) {
+	h, err := channel.NewHealthTracker(channel.HealthConfig{Enabled: true, Alpha: 0.5, TargetLatencyMS: 1000, MinFactor: 0.2, MinSamples: 2, HistoryInterval: "0s"})
+	if err != nil {
+		t.Fatalf("new tracker: %v", err)
+	}
+	ch := &channel.Channel{ID: 7, Status: channel.StatusEnabled, Weight: 100}
+
+	h.Record(ch, false, 100*time.Millisecond, "run", "boom")
+	if got := h.EffectiveWeight(ch); got != 100 {
+		t.Fatalf("expected the full weight before min_samples, got %v", got)
+	}
+	h.Record(ch, false, 100*time.Millisecond, "probe", "boom")
+	if got := h.EffectiveWeight(ch); got != 20 {
+		t.Fatalf("expected the weight to bottom out at min_factor, got %v", got)
+	}
+	for i := 0; i < 10; i++ {
+		h.Record(ch, true, 4000*time.Millisecond, "run", "")
+	}
+	snap := h.Snapshot(ch)
+	if snap.SuccessRate < 0.99 || snap.LatencyMS < 3900 || snap.Score > 0.26 {
+		t.Fatalf("expected a slow but successful channel to score ~0.25, got %+v", snap)
+	}
+	if snap.EffectiveWeight <= 20 || snap.EffectiveWeight >= 50 {
+		t.Fatalf("expected latency to keep the weight reduced, got %v", snap.EffectiveWeight)
+	}
+	if len(snap.History) != 12 || snap.Failures != 2 || snap.LastSource != "run" || snap.LastError != "boom" {
+		t.Fatalf("unexpected snapshot %+v", snap)
+	}
+
+	if _, err := channel.NewHealthTracker(channel.HealthConfig{MinFactor: 2, MaxFactor: 1}); err == nil {
+		t.Fatal("expected inverted factor bounds to be rejected")
+	}
+}
+
+func TestChannelsByGroupAndModelKeepsTopPriorityPeers(t *testing.T) {
+	store := channel.NewAbilityStore()
+	for _, c := range []*channel.Channel{
+		{Name: "a", Models: "claude-*", Group: "default", Status: channel.StatusEnabled, Priority: 5},
+		{Name: "b", Models: "claude-x", Group: "default,vip", Status: channel.StatusEnabled, Priority: 5},
+		{Name: "c", Models: "claude-x", Group: "default", Status: channel.StatusEnabled, Priority: 1},
+		{Name: "d", Models: "claude-x", Group: "default", Status: channel.StatusManuallyDisabled, Priority: 9},
+	} {
+		if err := store.AddChannel(c); err != nil {
+			t.Fatalf("add: %v", err)
+		}
+	}
+	got := store.ChannelsByGroupAndModel("default", "claude-x")
+	if len(got) != 2 || got[0].Name != "a" || got[1].Name != "b" {
+		t.Fatalf("expected [a b], got %+v", got)
+	}
+
+	h, _ := channel.NewHealthTracker(channel.HealthConfig{Enabled: true, MinFactor: 0, MaxFactor: 1, MinSamples: 1})
+	h.Record(got[0], false, time.Millisecond, "run", "down")
+	for i := 0; i < 20; i++ {
+		if pick := h.Pick(got); pick.Name != "b" {
+			t.Fatalf("expected the failing channel to lose all traffic, picked %s", pick.Name)
+		}
+	}
+}
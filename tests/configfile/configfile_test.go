@@ -0,0 +1,206 @@
+package configfile_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"ccgateway/internal/channel"
+	. "ccgateway/internal/configfile"
+	"ccgateway/internal/settings"
+	"ccgateway/internal/toolcatalog"
+	"ccgateway/internal/upstream"
+)
+
+const sampleYAML = `# gateway config
+adapters:
+  - name: primary
+    kind: openai
+    base_url: "https://api.example.com/v1"   # trailing comment
+    api_key_env: PRIMARY_KEY
+    headers: {x-team: "core", x-env: prod}
+default_route: [primary]
+model_routes:
+  claude-*:
+  - primary
+settings:
+  use_mode_model_override: true
+  mode_models:
+    plan: "model: planner"
+  routing:
+    retries: 3
+tools:
+  - {name: bash, status: supported}
+  - name: computer
+    status: experimental
+    notes: |
+      first line
+      second line
+channels:
+  - id: 7
+    name: vip
+    type: openai
+    models: gpt-4o,gpt-4o-mini
+    status: 1
+    group: vip
+    key_env: VIP_CHANNEL_KEY
+`
+
+func TestDecodeYAMLMatchesJSON(t *testing.T) {
+	f, err := Decode([]byte(sampleYAML), ".yaml")
+	if err != nil {
+		t.Fatalf("decode yaml: %v", err)
+	}
+	if len(f.Adapters) != 1 || f.Adapters[0].Name != "primary" || f.Adapters[0].BaseURL != "https://api.example.com/v1" {
+		t.Fatalf("unexpected adapters: %+v", f.Adapters)
+	}
+	if f.Adapters[0].Headers["x-team"] != "core" || f.Adapters[0].Headers["x-env"] != "prod" {
+		t.Fatalf("unexpected headers: %+v", f.Adapters[0].Headers)
+	}
+	if !reflect.DeepEqual(f.DefaultRoute, []string{"primary"}) || !reflect.DeepEqual(f.ModelRoutes["claude-*"], []string{"primary"}) {
+		t.Fatalf("unexpected routes: %v %v", f.DefaultRoute, f.ModelRoutes)
+	}
+	if len(f.Tools) != 2 || f.Tools[1].Notes != "first line\nsecond line\n" {
+		t.Fatalf("unexpected tools: %+v", f.Tools)
+	}
+	if len(f.Channels) != 1 || f.Channels[0].ID != 7 || f.Channels[0].KeyEnv != "VIP_CHANNEL_KEY" {
+		t.Fatalf("unexpected channels: %+v", f.Channels)
+	}
+	rs, err := settings.Parse(f.Settings)
+	if err != nil {
+		t.Fatalf("parse settings: %v", err)
+	}
+	if !rs.UseModeModelOverride || rs.ModeModels["plan"] != "model: planner" || rs.Routing.Retries != 3 {
+		t.Fatalf("unexpected settings: %+v", rs)
+	}
+
+	jsonFile, err := Decode([]byte(`{"default_route":["primary"],"tools":[{"name":"bash","status":"supported"}]}`), ".json")
+	if err != nil {
+		t.Fatalf("decode json: %v", err)
+	}
+	if !reflect.DeepEqual(jsonFile.DefaultRoute, f.DefaultRoute) || !reflect.DeepEqual(jsonFile.Tools[0], f.Tools[0]) {
+		t.Fatalf("json and yaml disagree: %+v vs %+v", jsonFile, f)
+	}
+}
+
+func TestDecodeRejectsUnknownFieldsAndFormats(t *testing.T) {
+	if _, err := Decode([]byte("adapterz: []\n"), ".yml"); err == nil {
+		t.Fatalf("expected unknown field error")
+	}
+	if _, err := Decode([]byte(`{}`), ".toml"); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Fatalf("expected ErrUnsupportedFormat, got %v", err)
+	}
+	if _, err := Decode([]byte("channels:\n  - name: no-id\n"), ".yaml"); err == nil {
+		t.Fatalf("expected missing channel id error")
+	}
+	if _, err := Decode([]byte("settings:\n\tretries: 1\n"), ".yaml"); err == nil {
+		t.Fatalf("expected tab indentation error")
+	}
+}
+
+func TestWithEnvOverridesSections(t *testing.T) {
+	t.Setenv("UPSTREAM_DEFAULT_ROUTE", "a, b")
+	t.Setenv("RUNTIME_SETTINGS_JSON", `{"routing":{"timeout_ms":5000}}`)
+	f, err := Decode([]byte(sampleYAML), ".yaml")
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	merged, used, err := f.WithEnv()
+	if err != nil {
+		t.Fatalf("with env: %v", err)
+	}
+	if !reflect.DeepEqual(used, []string{"UPSTREAM_DEFAULT_ROUTE", "RUNTIME_SETTINGS_JSON"}) {
+		t.Fatalf("unexpected overrides: %v", used)
+	}
+	if !reflect.DeepEqual(merged.DefaultRoute, []string{"a", "b"}) {
+		t.Fatalf("env default route should win: %v", merged.DefaultRoute)
+	}
+	rs, err := settings.Parse(merged.Settings)
+	if err != nil {
+		t.Fatalf("parse settings: %v", err)
+	}
+	if rs.Routing.Retries != 3 || rs.Routing.TimeoutMS != 5000 || !rs.UseModeModelOverride {
+		t.Fatalf("env settings should merge over file settings: %+v", rs.Routing)
+	}
+}
+
+type fakeUpstream struct {
+	got []upstream.UpstreamAdminConfig
+	err error
+}
+
+func (f *fakeUpstream) UpdateUpstreamConfig(cfg upstream.UpstreamAdminConfig) (upstream.UpstreamAdminConfig, error) {
+	if f.err != nil {
+		return upstream.UpstreamAdminConfig{}, f.err
+	}
+	f.got = append(f.got, cfg)
+	return cfg, nil
+}
+
+func TestReloaderAppliesSectionsAndKeepsStateOnFailure(t *testing.T) {
+	t.Setenv("VIP_CHANNEL_KEY", "sk-vip")
+	path := filepath.Join(t.TempDir(), "gateway.yaml")
+	if err := os.WriteFile(path, []byte(sampleYAML), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	up := &fakeUpstream{}
+	store := settings.NewStore(settings.DefaultRuntimeSettings())
+	tools := toolcatalog.NewCatalog(nil)
+	channels := channel.NewAbilityStore()
+	r := NewReloader(path, Targets{Upstream: up, Settings: store, Tools: tools, Channels: channels})
+	var seen []Result
+	r.OnReload(func(res Result) { seen = append(seen, res) })
+
+	res, err := r.Reload("test")
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	want := []string{SectionUpstream, SectionSettings, SectionTools, SectionChannels}
+	if !reflect.DeepEqual(res.Applied, want) {
+		t.Fatalf("applied = %v, want %v", res.Applied, want)
+	}
+	if len(up.got) != 1 || up.got[0].Adapters[0].Name != "primary" {
+		t.Fatalf("upstream not updated: %+v", up.got)
+	}
+	if got := store.Get(); !got.UseModeModelOverride || got.Routing.Retries != 3 {
+		t.Fatalf("settings not applied: %+v", got)
+	}
+	if len(tools.Snapshot()) != 2 {
+		t.Fatalf("tools not applied: %+v", tools.Snapshot())
+	}
+	ch, ok := channels.GetChannel(7)
+	if !ok || ch.Key != "sk-vip" || ch.Group != "vip" {
+		t.Fatalf("channel not applied: %+v", ch)
+	}
+
+	// Runtime counters survive a reload of the same channel.
+	ch.UsedQuota = 42
+	if err := channels.UpdateChannel(ch); err != nil {
+		t.Fatalf("update channel: %v", err)
+	}
+	if _, err := r.Reload("test"); err != nil {
+		t.Fatalf("second reload: %v", err)
+	}
+	if ch, _ := channels.GetChannel(7); ch.UsedQuota != 42 {
+		t.Fatalf("used quota lost on reload: %+v", ch)
+	}
+
+	// A broken file changes nothing.
+	if err := os.WriteFile(path, []byte("settings:\n  routing:\n    retries: 9\nbogus: true\n"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if _, err := r.Reload("test"); err == nil {
+		t.Fatalf("expected reload error")
+	}
+	if store.Get().Routing.Retries != 3 {
+		t.Fatalf("failed reload must not touch settings")
+	}
+	if len(seen) != 3 || seen[2].Error == "" {
+		t.Fatalf("unexpected reload notifications: %+v", seen)
+	}
+	if status := r.Status(); status["reloads"] != 3 {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+}
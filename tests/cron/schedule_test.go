@@ -0,0 +1,52 @@
+package cron_test
+
+import (
+	"testing"
+	"time"
+
+	. "ccgateway/internal/cron"
+)
+
+func TestScheduleNext(t *testing.T) {
+	base := time.Date(2026, 3, 14, 10, 7, 30, 0, time.UTC) // a Saturday
+	cases := []struct {
+		expr string
+		want time.Time
+	}{
+		{"*/15 * * * *", time.Date(2026, 3, 14, 10, 15, 0, 0, time.UTC)},
+		{"0 2 * * *", time.Date(2026, 3, 15, 2, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2026, 3, 14, 11, 0, 0, 0, time.UTC)},
+		{"30 9 * * mon-fri", time.Date(2026, 3, 16, 9, 30, 0, 0, time.UTC)},
+		{"0 0 1 jan,jul *", time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 12 * * 7", time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)},
+		// Both day fields restricted: either one matches.
+		{"0 0 20 * 1", time.Date(2026, 3, 16, 0, 0, 0, 0, time.UTC)},
+		{"0 0 31 2 *", time.Time{}},
+	}
+	for _, tc := range cases {
+		sched, err := Parse(tc.expr)
+		if err != nil {
+			t.Fatalf("parse %q: %v", tc.expr, err)
+		}
+		if got := sched.Next(base); !got.Equal(tc.want) {
+			t.Fatalf("%q: expected %v, got %v", tc.expr, tc.want, got)
+		}
+	}
+}
+
+func TestScheduleNextUsesLocation(t *testing.T) {
+	loc := time.FixedZone("UTC+8", 8*3600)
+	sched, _ := Parse("0 9 * * *")
+	got := sched.Next(time.Date(2026, 3, 14, 10, 0, 0, 0, loc))
+	if want := time.Date(2026, 3, 15, 1, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Fatalf("expected %v, got %v", want, got.UTC())
+	}
+}
+
+func TestParseRejectsBadExpressions(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "*/0 * * * *", "5-1 * * * *", "* * * foo *"} {
+		if _, err := Parse(expr); err == nil {
+			t.Fatalf("expected %q to be rejected", expr)
+		}
+	}
+}
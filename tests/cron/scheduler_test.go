@@ -0,0 +1,107 @@
+package cron_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "ccgateway/internal/cron"
+)
+
+func ptr[T any](v T) *T { return &v }
+
+var messageRequest = json.RawMessage(`{"model":"claude-test","max_tokens":64,"messages":[{"role":"user","content":"nightly report"}]}`)
+
+func TestCreateValidatesInput(t *testing.T) {
+	s, _ := New(Config{})
+	cases := []struct {
+		in   Input
+		want error
+	}{
+		{Input{Request: &messageRequest}, ErrInvalidSchedule},
+		{Input{Schedule: ptr("99 * * * *"), Request: &messageRequest}, ErrInvalidSchedule},
+		{Input{Schedule: ptr("@daily")}, ErrInvalidRequest},
+		{Input{Schedule: ptr("@daily"), Request: ptr(json.RawMessage(`{"stream":true}`))}, ErrInvalidRequest},
+		{Input{Schedule: ptr("@daily"), Request: &messageRequest, Path: ptr("/admin/status")}, ErrInvalidPath},
+		{Input{Schedule: ptr("@daily"), Request: &messageRequest, Timezone: ptr("Mars/Olympus")}, ErrInvalidTimezone},
+	}
+	for i, tc := range cases {
+		if _, err := s.Create(tc.in); !errors.Is(err, tc.want) {
+			t.Fatalf("case %d: expected %v, got %v", i, tc.want, err)
+		}
+	}
+}
+
+func TestRunDueFiresJobsAndRecordsLastRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cron.json")
+	s, _ := New(Config{Path: path})
+	var calls atomic.Int32
+	s.SetExecutor(func(ctx context.Context, job Job) Result {
+		calls.Add(1)
+		return Result{RunID: "run_1", StatusCode: 200}
+	})
+	job, err := s.Create(Input{Name: ptr("nightly"), Schedule: ptr("*/5 * * * *"), Request: &messageRequest})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if job.NextRunAt == nil || job.Path != "/v1/messages" || !job.Enabled {
+		t.Fatalf("unexpected job: %+v", job)
+	}
+	paused, _ := s.Create(Input{Schedule: ptr("* * * * *"), Request: &messageRequest, Enabled: ptr(false)})
+	if paused.NextRunAt != nil {
+		t.Fatalf("disabled jobs have no next run")
+	}
+
+	s.RunDue(context.Background(), job.NextRunAt.Add(-time.Second))
+	s.Wait()
+	if calls.Load() != 0 {
+		t.Fatalf("job fired early")
+	}
+	due := *job.NextRunAt
+	s.RunDue(context.Background(), due)
+	s.Wait()
+	got, _ := s.Get(job.ID)
+	if calls.Load() != 1 || got.LastRun == nil || got.LastRun.RunID != "run_1" || got.LastRun.Trigger != "schedule" || got.Running {
+		t.Fatalf("expected one recorded execution, got %+v", got)
+	}
+	if !got.NextRunAt.Equal(due.Add(5 * time.Minute)) {
+		t.Fatalf("expected next run advanced to %v, got %v", due.Add(5*time.Minute), got.NextRunAt)
+	}
+
+	reloaded, err := New(Config{Path: path})
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if again, ok := reloaded.Get(job.ID); !ok || again.LastRun == nil || again.Name != "nightly" {
+		t.Fatalf("expected job and last run persisted, got %+v", again)
+	}
+	if len(reloaded.List()) != 2 {
+		t.Fatalf("expected both jobs reloaded")
+	}
+}
+
+func TestTriggerRejectsOverlap(t *testing.T) {
+	s, _ := New(Config{})
+	release := make(chan struct{})
+	s.SetExecutor(func(ctx context.Context, job Job) Result {
+		<-release
+		return Result{StatusCode: 502, Error: "upstream down"}
+	})
+	job, _ := s.Create(Input{Schedule: ptr("@yearly"), Request: &messageRequest})
+	if _, err := s.Trigger(context.Background(), job.ID); err != nil {
+		t.Fatalf("trigger: %v", err)
+	}
+	if _, err := s.Trigger(context.Background(), job.ID); !errors.Is(err, ErrRunning) {
+		t.Fatalf("expected ErrRunning, got %v", err)
+	}
+	close(release)
+	s.Wait()
+	got, _ := s.Get(job.ID)
+	if got.LastRun == nil || got.LastRun.Trigger != "manual" || got.LastRun.Error != "upstream down" {
+		t.Fatalf("expected failed manual execution, got %+v", got.LastRun)
+	}
+}
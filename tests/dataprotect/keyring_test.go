@@ -0,0 +1,105 @@
+package dataprotect_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	. "ccgateway/internal/dataprotect"
+)
+
+func TestKeyringSealsPerProject(t *testing.T) {
+	master := bytes.Repeat([]byte{1}, 32)
+	own := bytes.Repeat([]byte{2}, 32)
+	k, err := NewKeyring(master, map[string][]byte{"Acme": own})
+	if err != nil {
+		t.Fatalf("new keyring: %v", err)
+	}
+	sealed, err := k.Encrypt("acme", "secret prompt")
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	if !strings.HasPrefix(sealed, "enc:v1:") || strings.Contains(sealed, "secret") {
+		t.Fatalf("unexpected sealed value %q", sealed)
+	}
+	if plain, err := k.Decrypt("acme", sealed); err != nil || plain != "secret prompt" {
+		t.Fatalf("decrypt = %q, %v", plain, err)
+	}
+	if _, err := k.Decrypt("other", sealed); !errors.Is(err, ErrCorrupt) {
+		t.Fatalf("another project must not open the value, got %v", err)
+	}
+	if _, err := k.Decrypt("acme", "legacy plaintext"); !errors.Is(err, ErrCorrupt) {
+		t.Fatalf("unsealed input must be rejected, got %v", err)
+	}
+	// Plaintext that looks sealed is still sealed, not passed through.
+	lookalike, err := k.Encrypt("acme", "enc:v1:x")
+	if err != nil || lookalike == "enc:v1:x" {
+		t.Fatalf("lookalike plaintext was not sealed: %q, %v", lookalike, err)
+	}
+	if plain, err := k.Decrypt("acme", lookalike); err != nil || plain != "enc:v1:x" {
+		t.Fatalf("lookalike round trip = %q, %v", plain, err)
+	}
+
+	// Projects without their own key use one derived from the master key.
+	derived, err := k.Encrypt("beta", "hello")
+	if err != nil {
+		t.Fatalf("encrypt derived: %v", err)
+	}
+	if plain, _ := k.Decrypt("beta", derived); plain != "hello" {
+		t.Fatalf("derived key round trip failed: %q", plain)
+	}
+
+	if !k.ForgetProject("acme") || k.HasProjectKey("acme") {
+		t.Fatalf("expected acme key to be forgotten")
+	}
+	if _, err := k.Decrypt("acme", sealed); !errors.Is(err, ErrCorrupt) {
+		t.Fatalf("forgotten key must make data unreadable, got %v", err)
+	}
+
+	// Values sealed after the key was dropped still open once it is
+	// configured again, as it is after a restart.
+	later, err := k.Encrypt("acme", "after forget")
+	if err != nil {
+		t.Fatalf("encrypt after forget: %v", err)
+	}
+	again, err := NewKeyring(bytes.Repeat([]byte{1}, 32), map[string][]byte{"Acme": own})
+	if err != nil {
+		t.Fatalf("new keyring: %v", err)
+	}
+	if plain, err := again.Decrypt("acme", later); err != nil || plain != "after forget" {
+		t.Fatalf("decrypt after restart = %q, %v", plain, err)
+	}
+}
+
+func TestKeyringWithoutMasterRequiresProjectKey(t *testing.T) {
+	k, err := NewKeyring(nil, map[string][]byte{"acme": bytes.Repeat([]byte{3}, 32)})
+	if err != nil {
+		t.Fatalf("new keyring: %v", err)
+	}
+	if _, err := k.Encrypt("other", "x"); !errors.Is(err, ErrNoKey) {
+		t.Fatalf("expected ErrNoKey, got %v", err)
+	}
+	if _, err := NewKeyring([]byte("short"), nil); !errors.Is(err, ErrInvalidKey) {
+		t.Fatalf("expected ErrInvalidKey, got %v", err)
+	}
+}
+
+func TestKeyringFromEnv(t *testing.T) {
+	t.Setenv("DATA_ENCRYPTION_KEY", "")
+	t.Setenv("DATA_ENCRYPTION_KEYS_JSON", "")
+	if k, err := KeyringFromEnv(); k != nil || err != nil {
+		t.Fatalf("expected no keyring, got %v, %v", k, err)
+	}
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{4}, 32))
+	t.Setenv("DATA_ENCRYPTION_KEYS_JSON", `{"acme":"`+key+`"}`)
+	k, err := KeyringFromEnv()
+	if err != nil || k == nil || !k.HasProjectKey("acme") {
+		t.Fatalf("expected acme key, got %v, %v", k, err)
+	}
+	t.Setenv("DATA_ENCRYPTION_KEY", "not-base64!")
+	if _, err := KeyringFromEnv(); err == nil {
+		t.Fatalf("expected invalid master key error")
+	}
+}
@@ -0,0 +1,64 @@
+package degrade_test
+
+import (
+	"testing"
+	"time"
+
+	. "ccgateway/internal/degrade"
+	"ccgateway/internal/settings"
+)
+
+func TestMonitorTracksInFlightAndErrorRate(t *testing.T) {
+	m := NewMonitor()
+	first := m.Begin()
+	second := m.Begin()
+	if load := m.Snapshot(time.Minute); load.InFlight != 2 || load.Requests != 0 {
+		t.Fatalf("unexpected load while in flight: %+v", load)
+	}
+	first(true)
+	first(true) // second call is ignored
+	second(false)
+	load := m.Snapshot(time.Minute)
+	if load.InFlight != 0 || load.Requests != 2 || load.Errors != 1 || load.ErrorRate != 0.5 {
+		t.Fatalf("unexpected load: %+v", load)
+	}
+}
+
+func TestMonitorTracksUpstreamSaturation(t *testing.T) {
+	m := NewMonitor()
+	m.ObserveUpstream(true)
+	m.ObserveUpstream(false)
+	m.ObserveUpstream(true)
+	m.ObserveUpstream(true)
+	load := m.Snapshot(time.Minute)
+	if load.UpstreamCalls != 4 || load.Saturated != 3 || load.SaturationRate != 0.75 {
+		t.Fatalf("unexpected saturation: %+v", load)
+	}
+	if load.Requests != 0 {
+		t.Fatalf("adapter calls must not count as gateway requests: %+v", load)
+	}
+}
+
+func TestLevelPicksHighestTriggeredStep(t *testing.T) {
+	ladder := []settings.DegradationStep{
+		{Action: settings.DegradeDisableJudge, InFlight: 10},
+		{Action: settings.DegradeDisableReflection, ErrorRate: 0.2},
+		{Action: settings.DegradeReject, InFlight: 100, ErrorRate: 0.9},
+	}
+	cases := []struct {
+		name string
+		load Load
+		want int
+	}{
+		{"idle", Load{}, 0},
+		{"busy", Load{InFlight: 12}, 1},
+		{"errors", Load{Requests: 50, ErrorRate: 0.3}, 2},
+		{"too few samples", Load{Requests: 5, ErrorRate: 1}, 0},
+		{"overloaded", Load{InFlight: 150}, 3},
+	}
+	for _, tc := range cases {
+		if got := Level(ladder, tc.load, 20); got != tc.want {
+			t.Fatalf("%s: expected level %d, got %d", tc.name, tc.want, got)
+		}
+	}
+}
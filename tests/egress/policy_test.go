@@ -0,0 +1,88 @@
+package egress_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	. "ccgateway/internal/egress"
+)
+
+func TestPolicyCheckIPRules(t *testing.T) {
+	p, err := NewPolicy(Config{BlockPrivate: true, DenyCIDRs: []string{"8.8.8.0/24"}})
+	if err != nil {
+		t.Fatalf("new policy: %v", err)
+	}
+	if err := p.CheckIP(net.ParseIP("10.0.0.1")); !errors.Is(err, ErrBlocked) {
+		t.Fatalf("expected private ip to be blocked, got %v", err)
+	}
+	if err := p.CheckIP(net.ParseIP("8.8.8.8")); !errors.Is(err, ErrBlocked) {
+		t.Fatalf("expected denied cidr to be blocked, got %v", err)
+	}
+	if err := p.CheckIP(net.ParseIP("1.1.1.1")); err != nil {
+		t.Fatalf("expected public ip to pass, got %v", err)
+	}
+
+	if _, err := p.UpdateConfigPatch(ConfigPatch{AllowCIDRs: []string{"10.1.0.0/16"}}); err != nil {
+		t.Fatalf("patch: %v", err)
+	}
+	if err := p.CheckIP(net.ParseIP("10.1.2.3")); err != nil {
+		t.Fatalf("expected allow list to override private block, got %v", err)
+	}
+	if err := p.CheckIP(net.ParseIP("1.1.1.1")); !errors.Is(err, ErrBlocked) {
+		t.Fatalf("expected address outside allow list to be blocked, got %v", err)
+	}
+	if _, err := p.UpdateConfigPatch(ConfigPatch{DenyCIDRs: []string{"not-a-cidr"}}); err == nil {
+		t.Fatalf("expected invalid cidr to be rejected")
+	}
+	if err := p.CheckHost(context.Background(), "localhost"); err == nil {
+		t.Fatalf("expected localhost outside allow list to be blocked")
+	}
+}
+
+func TestPolicyHTTPClientChecksDialedAddress(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, strings.Repeat("x", 64))
+	}))
+	defer srv.Close()
+
+	// The host name resolves at dial time, so the check applies to the real
+	// address even when no pre-flight CheckHost was done.
+	url := strings.Replace(srv.URL, "127.0.0.1", "localhost", 1)
+	p, _ := NewPolicy(Config{DenyCIDRs: []string{"127.0.0.0/8", "::1"}})
+	if _, err := p.HTTPClient(2 * time.Second).Get(url); err == nil || !errors.Is(err, ErrBlocked) {
+		t.Fatalf("expected dial to loopback to be blocked, got %v", err)
+	}
+	if got := p.Snapshot()["blocked"].(uint64); got == 0 {
+		t.Fatalf("expected blocked counter to increase")
+	}
+
+	p, _ = NewPolicy(Config{MaxResponseBytes: 16})
+	resp, err := p.HTTPClient(2 * time.Second).Get(srv.URL)
+	if err != nil {
+		if !errors.Is(err, ErrResponseTooLarge) {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return
+	}
+	defer resp.Body.Close()
+	if _, err := io.ReadAll(resp.Body); !errors.Is(err, ErrResponseTooLarge) {
+		t.Fatalf("expected oversized body to fail, got %v", err)
+	}
+
+	p, _ = NewPolicy(Config{MaxResponseBytes: 64})
+	resp, err = p.HTTPClient(2 * time.Second).Get(srv.URL)
+	if err != nil {
+		t.Fatalf("expected exact-size body to pass, got %v", err)
+	}
+	defer resp.Body.Close()
+	if body, err := io.ReadAll(resp.Body); err != nil || len(body) != 64 {
+		t.Fatalf("expected 64 bytes, got %d err=%v", len(body), err)
+	}
+}
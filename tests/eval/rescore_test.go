@@ -0,0 +1,99 @@
+package eval_test
+
+import (
+	"ccgateway/internal/ccrun"
+	. "ccgateway/internal/eval"
+	"strings"
+	"testing"
+	"time"
+)
+
+func waitRescoreJob(t *testing.T, r *Rescorer, id string) RescoreJob {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		job, ok := r.Get(id)
+		if !ok {
+			t.Fatalf("job %s not found", id)
+		}
+		if job.Status != RescoreStatusRunning {
+			return job
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("job %s did not finish", id)
+	return RescoreJob{}
+}
+
+func TestRescorerScoresRunsPerJudgeVersion(t *testing.T) {
+	store := ccrun.NewStore()
+	for i, output := range []string{"answer one", "answer two", ""} {
+		run, err := store.Create(ccrun.CreateInput{Path: "/v1/messages", Mode: "chat", ClientModel: "m1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := store.Complete(run.ID, ccrun.CompleteInput{StatusCode: 200, Prompt: "question", Output: output}); err != nil {
+			t.Fatalf("complete %d: %v", i, err)
+		}
+	}
+	pending, _ := store.Create(ccrun.CreateInput{Path: "/v1/messages", Mode: "chat"})
+
+	mc := &mockCompleter{
+		response: `{"accuracy":8,"completeness":8,"reasoning":8,"code_quality":8,"instruction_following":8,"analysis":"ok"}`,
+	}
+	ev := NewEvaluator(mc, "judge-a")
+	r := NewRescorer(store, ev)
+
+	job, err := r.Start(RescoreRequest{Mode: "chat"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.JudgeVersion != ev.Version() || !strings.HasPrefix(job.JudgeVersion, "judge-a@") {
+		t.Fatalf("unexpected judge version %q", job.JudgeVersion)
+	}
+	job = waitRescoreJob(t, r, job.ID)
+	if job.Status != RescoreStatusCompleted || job.Total != 3 || job.Scored != 2 || job.Skipped != 1 || job.Failed != 0 {
+		t.Fatalf("unexpected job: %+v", job)
+	}
+	if got := job.Summary[ev.Version()]; got.Count != 2 || got.Mean != 8 {
+		t.Fatalf("unexpected summary: %+v", job.Summary)
+	}
+	if run, _ := store.Get(pending.ID); len(run.Scores) != 0 {
+		t.Fatalf("running run must not be scored: %+v", run.Scores)
+	}
+
+	// Same judge version: already-scored runs are skipped.
+	again, _ := r.Start(RescoreRequest{})
+	again = waitRescoreJob(t, r, again.ID)
+	if again.Scored != 0 || again.Skipped != 3 {
+		t.Fatalf("expected rescore to skip scored runs: %+v", again)
+	}
+
+	// A new judge writes a second score next to the first.
+	mc.response = `{"accuracy":6,"completeness":6,"reasoning":6,"code_quality":6,"instruction_following":6,"analysis":"meh"}`
+	r2 := NewRescorer(store, NewEvaluator(mc, "judge-b"))
+	next, _ := r2.Start(RescoreRequest{Limit: 2})
+	next = waitRescoreJob(t, r2, next.ID)
+	if next.Total != 2 || len(next.Summary) != 2 {
+		t.Fatalf("expected two judge versions in summary: %+v", next)
+	}
+	for _, run := range store.List(ccrun.ListFilter{Status: "completed"}) {
+		if run.OutputText == "" {
+			continue
+		}
+		if run.Scores[0].JudgeVersion != job.JudgeVersion {
+			t.Fatalf("original score lost: %+v", run.Scores)
+		}
+	}
+	if len(r2.List()) != 1 {
+		t.Fatalf("expected one job, got %d", len(r2.List()))
+	}
+}
+
+func TestRescorerRejectsInvalidWindow(t *testing.T) {
+	r := NewRescorer(ccrun.NewStore(), NewEvaluator(&mockCompleter{}, "judge"))
+	now := time.Now()
+	if _, err := r.Start(RescoreRequest{Since: now, Until: now.Add(-time.Hour)}); err == nil {
+		t.Fatal("expected error for until before since")
+	}
+}
@@ -0,0 +1,57 @@
+package featureflag_test
+
+import (
+	"testing"
+
+	. "ccgateway/internal/featureflag"
+)
+
+func TestKillSwitchOverridesRequestOverrides(t *testing.T) {
+	store := NewStore()
+	if !store.Enabled(Judge, nil) {
+		t.Fatalf("built-in flags should default to enabled")
+	}
+	if store.Enabled(Judge, map[string]bool{Judge: false}) {
+		t.Fatalf("request override should turn the flag off")
+	}
+	if _, err := store.SetKilled(Judge, true); err != nil {
+		t.Fatalf("kill: %v", err)
+	}
+	if store.Enabled(Judge, map[string]bool{Judge: true}) {
+		t.Fatalf("killed flag must ignore request overrides")
+	}
+	resolved := store.Resolve(map[string]bool{Judge: true, "experiment.x": true})
+	if resolved[Judge] || !resolved["experiment.x"] || !resolved[Reflection] {
+		t.Fatalf("unexpected resolved flags: %+v", resolved)
+	}
+	if err := store.Delete(Judge); err != ErrBuiltIn {
+		t.Fatalf("expected built-in delete to fail, got %v", err)
+	}
+}
+
+func TestNewFromEnvSeedsFlagsAndKillSwitches(t *testing.T) {
+	t.Setenv("FEATURE_FLAGS", "reflection=off, beta_ui=on")
+	t.Setenv("FEATURE_KILL_SWITCHES", "tool_loop")
+	store, err := NewFromEnv()
+	if err != nil {
+		t.Fatalf("new from env: %v", err)
+	}
+	if store.Enabled(Reflection, nil) || !store.Enabled("beta_ui", nil) || store.Enabled(ToolLoop, nil) {
+		t.Fatalf("unexpected seeded flags: %+v", store.List())
+	}
+	if f, ok := store.Get("beta_ui"); !ok || f.BuiltIn {
+		t.Fatalf("expected custom flag, got %+v", f)
+	}
+
+	t.Setenv("FEATURE_FLAGS", "reflection")
+	if _, err := NewFromEnv(); err == nil {
+		t.Fatalf("expected error for entry without value")
+	}
+}
+
+func TestOverridesFromAny(t *testing.T) {
+	got := OverridesFromAny(map[string]any{"Judge": false, "reflection": "on", "bad name": true, "x": 1.0})
+	if len(got) != 3 || got[Judge] || !got[Reflection] || !got["x"] {
+		t.Fatalf("unexpected overrides: %+v", got)
+	}
+}
@@ -0,0 +1,98 @@
+package files_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	. "ccgateway/internal/files"
+)
+
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func TestUploadGetContentDelete(t *testing.T) {
+	ctx := context.Background()
+	s, err := New(Config{}, NewMemoryBlobs())
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := s.Upload(ctx, UploadInput{Owner: "user:1", Filename: "../notes/todo.md", Data: []byte("# todo")})
+	if err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+	if f.Object != "file" || f.Filename != "todo.md" || f.MimeType != "text/markdown" || f.Purpose != "user_data" || f.Bytes != 6 {
+		t.Fatalf("unexpected file %+v", f)
+	}
+	data, got, err := s.Content(ctx, "user:1", f.ID)
+	if err != nil || string(data) != "# todo" || got.ID != f.ID {
+		t.Fatalf("unexpected content %q %+v err=%v", data, got, err)
+	}
+	if _, err := s.Get(ctx, "user:2", f.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected another owner to get ErrNotFound, got %v", err)
+	}
+	if items, _ := s.List(ListFilter{Owner: "user:2"}); len(items) != 0 {
+		t.Fatalf("expected no files for another owner, got %+v", items)
+	}
+	if err := s.Delete(ctx, "user:1", f.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, _, err := s.Content(ctx, "user:1", f.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound after delete, got %v", err)
+	}
+}
+
+func TestUploadEnforcesLimits(t *testing.T) {
+	ctx := context.Background()
+	s, _ := New(Config{MaxBytes: 8, AllowedTypes: []string{"image/*"}}, NewMemoryBlobs())
+	if _, err := s.Upload(ctx, UploadInput{Filename: "a.txt", Data: []byte("hi")}); !errors.Is(err, ErrTypeNotAllowed) {
+		t.Fatalf("expected ErrTypeNotAllowed, got %v", err)
+	}
+	if _, err := s.Upload(ctx, UploadInput{Filename: "a.png", Data: pngHeader}); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("expected ErrTooLarge, got %v", err)
+	}
+	if _, err := s.Upload(ctx, UploadInput{Filename: "a.png", Purpose: "fine-tune", Data: pngHeader[:8]}); !errors.Is(err, ErrInvalidPurpose) {
+		t.Fatalf("expected ErrInvalidPurpose, got %v", err)
+	}
+	if f, err := s.Upload(ctx, UploadInput{Filename: "blob", Data: pngHeader[:8]}); err != nil || f.MimeType != "image/png" {
+		t.Fatalf("expected content sniffing to find image/png, got %+v err=%v", f, err)
+	}
+}
+
+func TestDetectType(t *testing.T) {
+	cases := []struct {
+		filename, declared, want string
+	}{
+		{"batch.jsonl", "application/octet-stream", "application/jsonl"},
+		{"doc.pdf", "", "application/pdf"},
+		{"x.bin", "text/csv; charset=utf-8", "text/csv"},
+		{"noext", "", "text/plain"},
+	}
+	for _, tc := range cases {
+		if got := DetectType(tc.filename, tc.declared, []byte("plain words")); got != tc.want {
+			t.Fatalf("%s/%s: expected %s, got %s", tc.filename, tc.declared, tc.want, got)
+		}
+	}
+}
+
+func TestDirBlobsSurviveRestart(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	blobs, err := NewDirBlobs(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, _ := New(Config{}, blobs)
+	f, err := s.Upload(ctx, UploadInput{Owner: "token:7", Filename: "data.json", Purpose: "batch", Data: []byte(`{"a":1}`)})
+	if err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+
+	reopened, err := New(Config{}, blobs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	items, _ := reopened.List(ListFilter{Owner: "token:7", Purpose: "batch"})
+	if len(items) != 1 || items[0].ID != f.ID || items[0].MimeType != "application/json" {
+		t.Fatalf("expected the file reloaded from disk, got %+v", items)
+	}
+}
@@ -0,0 +1,91 @@
+package gateway_test
+
+import (
+	. "ccgateway/internal/gateway"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ccgateway/internal/policy"
+	"ccgateway/internal/token"
+	"ccgateway/internal/upstream"
+)
+
+type denyAdapterPolicy struct{ adapter string }
+
+func (p denyAdapterPolicy) Authorize(_ context.Context, action policy.Action) error {
+	for _, name := range action.Adapters {
+		if name == p.adapter {
+			return errors.New("adapter forbidden by policy")
+		}
+	}
+	return nil
+}
+
+func TestAdapterPinningAndExclusionHeaders(t *testing.T) {
+	tokenSvc := token.NewInMemoryService()
+	power, err := tokenSvc.Generate("power-user", 1000000)
+	if err != nil {
+		t.Fatalf("generate token: %v", err)
+	}
+	allowed := "beta,gamma"
+	power.Adapters = &allowed
+	if err := tokenSvc.Update(power); err != nil {
+		t.Fatalf("update token: %v", err)
+	}
+	plain, err := tokenSvc.Generate("plain-user", 1000000)
+	if err != nil {
+		t.Fatalf("generate token: %v", err)
+	}
+	svc := upstream.NewRouterService(upstream.RouterConfig{DefaultRoute: []string{"alpha", "beta", "gamma"}}, []upstream.Adapter{
+		namedTextAdapter{name: "alpha", text: "alpha answer"},
+		namedTextAdapter{name: "beta", text: "beta answer"},
+		namedTextAdapter{name: "gamma", text: "gamma answer"},
+	})
+	router := newTestRouterWithDeps(t, Dependencies{
+		Orchestrator: svc,
+		Policy:       denyAdapterPolicy{adapter: "gamma"},
+		TokenService: tokenSvc,
+	})
+
+	send := func(key string, headers map[string]string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(`{"model":"claude-test","max_tokens":16,"messages":[{"role":"user","content":"hi"}]}`))
+		req.Header.Set("anthropic-version", "2023-06-01")
+		req.Header.Set("authorization", "Bearer "+key)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	for _, tc := range []struct {
+		name    string
+		key     string
+		headers map[string]string
+		status  int
+		answer  string
+	}{
+		{name: "default route", key: plain.Value, status: http.StatusOK, answer: "alpha answer"},
+		{name: "pinned", key: power.Value, headers: map[string]string{"x-cc-adapter": "BETA"}, status: http.StatusOK, answer: "beta answer"},
+		{name: "excluded", key: power.Value, headers: map[string]string{"x-cc-exclude-adapters": "beta"}, status: http.StatusOK, answer: "alpha answer"},
+		{name: "token without adapters", key: plain.Value, headers: map[string]string{"x-cc-adapter": "beta"}, status: http.StatusForbidden},
+		{name: "adapter outside token list", key: power.Value, headers: map[string]string{"x-cc-adapter": "alpha"}, status: http.StatusForbidden},
+		{name: "denied by policy", key: power.Value, headers: map[string]string{"x-cc-adapter": "gamma"}, status: http.StatusForbidden},
+		{name: "unknown adapter", key: power.Value, headers: map[string]string{"x-cc-adapter": "nope"}, status: http.StatusBadRequest},
+		{name: "pinned and excluded", key: power.Value, headers: map[string]string{"x-cc-adapter": "beta", "x-cc-exclude-adapters": "beta"}, status: http.StatusBadRequest},
+	} {
+		rr := send(tc.key, tc.headers)
+		if rr.Code != tc.status {
+			t.Fatalf("%s: expected %d, got %d; body=%s", tc.name, tc.status, rr.Code, rr.Body.String())
+		}
+		if tc.answer != "" && !strings.Contains(rr.Body.String(), tc.answer) {
+			t.Fatalf("%s: expected %q, got %s", tc.name, tc.answer, rr.Body.String())
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package gateway_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"ccgateway/internal/analytics"
+	"ccgateway/internal/ccrun"
+	. "ccgateway/internal/gateway"
+)
+
+func TestAdminAnalyticsGroupsFinishedRuns(t *testing.T) {
+	runs := ccrun.NewStore()
+	for _, in := range []struct {
+		id, mode string
+		status   int
+	}{{"run_1", "chat", 200}, {"run_2", "chat", 500}, {"run_3", "plan", 200}, {"run_4", "plan", 0}} {
+		if _, err := runs.Create(ccrun.CreateInput{ID: in.id, Path: "/v1/messages", Mode: in.mode, UpstreamModel: "m1"}); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+		if in.status == 0 {
+			continue
+		}
+		if _, err := runs.Complete(in.id, ccrun.CompleteInput{StatusCode: in.status}); err != nil {
+			t.Fatalf("complete: %v", err)
+		}
+	}
+	if _, err := runs.Annotate("run_3", map[string]any{"tool_loop_rounds": 3}); err != nil {
+		t.Fatalf("annotate: %v", err)
+	}
+	router := newTestRouterWithDeps(t, Dependencies{AdminToken: "secret-admin", RunStore: runs})
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/analytics?window=1h&group_by=mode", nil)
+	req.Header.Set("authorization", "Bearer secret-admin")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var report analytics.Report
+	if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if report.Bucket != "1m0s" || report.Totals.Requests != 3 || report.Totals.Errors != 1 {
+		t.Fatalf("unexpected report: bucket=%s totals=%+v", report.Bucket, report.Totals)
+	}
+	if len(report.Series) != 2 || report.Series[0].Key != "chat" || report.Series[1].Totals.ToolLoopRounds != 3 {
+		t.Fatalf("unexpected series: %+v", report.Series)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/admin/analytics?group_by=user", nil)
+	req.Header.Set("authorization", "Bearer secret-admin")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unknown group_by, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,82 @@
+package gateway_test
+
+import (
+	. "ccgateway/internal/gateway"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ccgateway/internal/auditlog"
+	"ccgateway/internal/settings"
+)
+
+func TestAdminMutationsAreAuditedWithDiff(t *testing.T) {
+	audit, err := auditlog.NewStore(filepath.Join(t.TempDir(), "audit.jsonl"))
+	if err != nil {
+		t.Fatalf("new audit store: %v", err)
+	}
+	router := newTestRouterWithDeps(t, Dependencies{
+		Settings:   settings.NewStore(settings.DefaultRuntimeSettings()),
+		AdminToken: "secret-admin",
+		AdminAudit: audit,
+	})
+
+	denied := httptest.NewRequest(http.MethodPut, "/admin/settings", strings.NewReader(`{}`))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, denied)
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", rr.Code)
+	}
+
+	put := httptest.NewRequest(http.MethodPut, "/admin/settings", strings.NewReader(`{"allow_experimental_tools":true,"mode_models":{"plan":"planner"}}`))
+	put.Header.Set("authorization", "Bearer secret-admin")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, put)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d; body=%s", rr.Code, rr.Body.String())
+	}
+
+	get := httptest.NewRequest(http.MethodGet, "/admin/audit?path=/admin/settings&limit=10", nil)
+	get.Header.Set("x-admin-token", "secret-admin")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, get)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 for audit query, got %d; body=%s", rr.Code, rr.Body.String())
+	}
+	var resp struct {
+		Data  []auditlog.Entry `json:"data"`
+		Total int              `json:"total"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode audit response: %v", err)
+	}
+	if resp.Total != 2 || len(resp.Data) != 2 {
+		t.Fatalf("expected 2 audit entries, got %+v", resp)
+	}
+	latest, rejected := resp.Data[0], resp.Data[1]
+	if rejected.Status != http.StatusUnauthorized || rejected.Actor != "anonymous" || rejected.Snapshot {
+		t.Fatalf("unexpected rejected entry: %+v", rejected)
+	}
+	if latest.Status != http.StatusOK || latest.Actor != auditlog.ActorID("secret-admin") || !latest.Snapshot {
+		t.Fatalf("unexpected mutation entry: %+v", latest)
+	}
+	paths := map[string]bool{}
+	for _, c := range latest.Changes {
+		paths[c.Path] = true
+	}
+	if !paths["allow_experimental_tools"] || !paths["mode_models.plan"] {
+		t.Fatalf("expected field-level diff, got %+v", latest.Changes)
+	}
+	if latest.RequestBody == nil {
+		t.Fatalf("expected request body to be recorded")
+	}
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, get)
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil || resp.Total != 2 {
+		t.Fatalf("expected audit reads not to be audited, got %+v", resp)
+	}
+}
@@ -0,0 +1,183 @@
+package gateway_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ccgateway/internal/ccevent"
+	"ccgateway/internal/channel"
+	. "ccgateway/internal/gateway"
+	"ccgateway/internal/mcpregistry"
+	"ccgateway/internal/settings"
+	"ccgateway/internal/toolcatalog"
+	"ccgateway/internal/upstream"
+)
+
+type configEnv struct {
+	router   http.Handler
+	upstream *upstream.RouterService
+	settings *settings.Store
+	tools    *toolcatalog.Catalog
+	channels *channel.AbilityStore
+	mcp      *mcpregistry.Store
+	events   *ccevent.Store
+}
+
+func newConfigEnv(t *testing.T) configEnv {
+	t.Helper()
+	env := configEnv{
+		upstream: upstream.NewRouterService(upstream.RouterConfig{}, nil),
+		settings: settings.NewStore(settings.DefaultRuntimeSettings()),
+		tools:    toolcatalog.NewCatalog(nil),
+		channels: channel.NewAbilityStore(),
+		mcp:      mcpregistry.NewStore(nil),
+		events:   ccevent.NewStore(),
+	}
+	env.router = newTestRouterWithDeps(t, Dependencies{
+		Orchestrator: env.upstream,
+		AdminToken:   "secret-admin",
+		Settings:     env.settings,
+		ToolCatalog:  env.tools,
+		ChannelStore: env.channels,
+		MCPRegistry:  env.mcp,
+		EventStore:   env.events,
+	})
+	return env
+}
+
+func (e configEnv) do(t *testing.T, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("authorization", "Bearer secret-admin")
+	rr := httptest.NewRecorder()
+	e.router.ServeHTTP(rr, req)
+	return rr
+}
+
+const sampleBundle = `{
+	"version": 1,
+	"settings": {"routing": {"retries": 5}},
+	"model_mapping": {"model_mappings": {"claude-*": "gpt-4o"}, "model_map_fallback": "gpt-4o-mini"},
+	"upstream": {
+		"adapters": [{"name": "primary", "kind": "openai", "base_url": "https://api.example.com/v1", "api_key": "sk-primary", "model": "gpt-4o"}],
+		"default_route": ["primary"]
+	},
+	"tools": [{"name": "bash", "status": "supported"}],
+	"channels": [{"id": 3, "name": "vip", "type": "openai", "models": "gpt-4o", "status": 1, "group": "vip", "key": "sk-channel"}],
+	"mcp_servers": [{"id": "files", "name": "files", "transport": "http", "url": "https://mcp.example.com", "headers": {"authorization": "Bearer mcp"}}]
+}`
+
+func TestAdminConfigImportAppliesBundleAndExportRoundTrips(t *testing.T) {
+	src := newConfigEnv(t)
+	rr := src.do(t, http.MethodPost, "/admin/config/import", sampleBundle)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("import: %d %s", rr.Code, rr.Body.String())
+	}
+	got := src.settings.Get()
+	if got.Routing.Retries != 5 || got.ModelMappings["claude-*"] != "gpt-4o" || got.ModelMapFallback != "gpt-4o-mini" {
+		t.Fatalf("settings not applied: %+v", got)
+	}
+	if cfg := src.upstream.GetUpstreamConfig(); len(cfg.Adapters) != 1 || cfg.Adapters[0].Name != "primary" {
+		t.Fatalf("upstream not applied: %+v", cfg)
+	}
+	if ch, ok := src.channels.GetChannel(3); !ok || ch.Key != "sk-channel" {
+		t.Fatalf("channel not applied: %+v", ch)
+	}
+	if srv, ok := src.mcp.Get("files"); !ok || srv.Headers["authorization"] != "Bearer mcp" {
+		t.Fatalf("mcp server not applied: %+v", srv)
+	}
+	if ev := src.events.List(ccevent.ListFilter{EventType: "config.imported", Limit: 10}); len(ev) != 1 {
+		t.Fatalf("expected config.imported event, got %d", len(ev))
+	}
+
+	// Masked export: secrets never leave, yet re-importing keeps them.
+	rr = src.do(t, http.MethodGet, "/admin/config/export", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("export: %d %s", rr.Code, rr.Body.String())
+	}
+	masked := rr.Body.String()
+	for _, secret := range []string{"sk-primary", "sk-channel", "Bearer mcp"} {
+		if strings.Contains(masked, secret) {
+			t.Fatalf("masked export leaked %q: %s", secret, masked)
+		}
+	}
+	if rr := src.do(t, http.MethodPost, "/admin/config/import", masked); rr.Code != http.StatusOK {
+		t.Fatalf("re-import: %d %s", rr.Code, rr.Body.String())
+	}
+	if ch, _ := src.channels.GetChannel(3); ch.Key != "sk-channel" {
+		t.Fatalf("masked import must keep channel key, got %q", ch.Key)
+	}
+	if srv, _ := src.mcp.Get("files"); srv.Headers["authorization"] != "Bearer mcp" {
+		t.Fatalf("masked import must keep mcp headers, got %+v", srv.Headers)
+	}
+	if cfg := src.upstream.UpstreamConfigSnapshot(false); cfg.Adapters[0].APIKey != "sk-primary" {
+		t.Fatalf("masked import must keep api key, got %q", cfg.Adapters[0].APIKey)
+	}
+
+	// Full export clones the environment.
+	full := src.do(t, http.MethodGet, "/admin/config/export?include_secrets=true", "").Body.String()
+	dst := newConfigEnv(t)
+	if rr := dst.do(t, http.MethodPost, "/admin/config/import", full); rr.Code != http.StatusOK {
+		t.Fatalf("clone import: %d %s", rr.Code, rr.Body.String())
+	}
+	if ch, ok := dst.channels.GetChannel(3); !ok || ch.Key != "sk-channel" || ch.Group != "vip" {
+		t.Fatalf("clone lost channel: %+v", ch)
+	}
+	if len(dst.tools.Snapshot()) != 1 || dst.settings.Get().Routing.Retries != 5 {
+		t.Fatalf("clone lost tools or settings")
+	}
+}
+
+func TestAdminConfigImportDryRunAndInvalidBundle(t *testing.T) {
+	env := newConfigEnv(t)
+	rr := env.do(t, http.MethodPost, "/admin/config/import?dry_run=true", sampleBundle)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("dry run: %d %s", rr.Code, rr.Body.String())
+	}
+	var report struct {
+		DryRun   bool `json:"dry_run"`
+		Valid    bool `json:"valid"`
+		Sections []struct {
+			Section string `json:"section"`
+			Status  string `json:"status"`
+			Error   string `json:"error"`
+		} `json:"sections"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !report.DryRun || !report.Valid || len(report.Sections) != 6 {
+		t.Fatalf("unexpected dry run report: %+v", report)
+	}
+	if env.settings.Get().Routing.Retries == 5 || len(env.tools.Snapshot()) != 0 {
+		t.Fatalf("dry run must not apply anything")
+	}
+
+	bad := strings.Replace(sampleBundle, `"url": "https://mcp.example.com"`, `"url": ""`, 1)
+	rr = env.do(t, http.MethodPost, "/admin/config/import", bad)
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	invalid := ""
+	for _, sec := range report.Sections {
+		if sec.Status == "invalid" {
+			invalid = sec.Section
+		}
+	}
+	if report.Valid || invalid != "mcp_servers" {
+		t.Fatalf("expected mcp_servers to be invalid: %+v", report)
+	}
+	if env.settings.Get().Routing.Retries == 5 {
+		t.Fatalf("invalid bundle must not apply valid sections")
+	}
+
+	if rr := env.do(t, http.MethodPost, "/admin/config/import", `{"version":99}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for newer bundle, got %d", rr.Code)
+	}
+}
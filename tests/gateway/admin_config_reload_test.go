@@ -0,0 +1,79 @@
+package gateway_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"ccgateway/internal/configfile"
+	. "ccgateway/internal/gateway"
+	"ccgateway/internal/settings"
+)
+
+func TestAdminConfigReloadAppliesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gateway.json")
+	if err := os.WriteFile(path, []byte(`{"settings":{"routing":{"retries":4}}}`), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	store := settings.NewStore(settings.DefaultRuntimeSettings())
+	router := newTestRouterWithDeps(t, Dependencies{
+		Orchestrator:   &captureService{},
+		AdminToken:     "secret-admin",
+		Settings:       store,
+		ConfigReloader: configfile.NewReloader(path, configfile.Targets{Settings: store}),
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/config/reload", nil)
+	req.Header.Set("authorization", "Bearer secret-admin")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var res configfile.Result
+	if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(res.Applied) != 1 || res.Applied[0] != configfile.SectionSettings || res.Trigger != "admin_api" {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if store.Get().Routing.Retries != 4 {
+		t.Fatalf("settings not reloaded: %+v", store.Get().Routing)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"settings":`), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for broken file, got %d", rr.Code)
+	}
+
+	status := httptest.NewRequest(http.MethodGet, "/admin/config/reload", nil)
+	status.Header.Set("authorization", "Bearer secret-admin")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, status)
+	var body map[string]any
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode status: %v", err)
+	}
+	last, _ := body["last"].(map[string]any)
+	if body["reloads"] != float64(2) || last["error"] == "" || last["error"] == nil {
+		t.Fatalf("unexpected status: %+v", body)
+	}
+}
+
+func TestAdminConfigReloadNotConfigured(t *testing.T) {
+	router := newTestRouterWithDeps(t, Dependencies{Orchestrator: &captureService{}, AdminToken: "secret-admin"})
+	req := httptest.NewRequest(http.MethodPost, "/admin/config/reload", nil)
+	req.Header.Set("authorization", "Bearer secret-admin")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotImplemented {
+		t.Fatalf("expected 501, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,69 @@
+package gateway_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ccgateway/internal/ccrun"
+	"ccgateway/internal/cron"
+	. "ccgateway/internal/gateway"
+	"ccgateway/internal/orchestrator"
+)
+
+func adminCronRequest(t *testing.T, router http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer secret-admin")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestAdminCronCreateRunAndInspect(t *testing.T) {
+	scheduler, _ := cron.New(cron.Config{})
+	runs := ccrun.NewStore()
+	router := newTestRouterWithDeps(t, Dependencies{
+		Orchestrator: orchestrator.NewSimpleService(),
+		RunStore:     runs,
+		Cron:         scheduler,
+		AdminToken:   "secret-admin",
+	})
+
+	rr := adminCronRequest(t, router, http.MethodPost, "/admin/cron", `{"name":"nightly","schedule":"0 2 * * *","timezone":"Asia/Shanghai","request":{"model":"claude-test","max_tokens":64,"messages":[{"role":"user","content":"report"}]}}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d; body=%s", rr.Code, rr.Body.String())
+	}
+	var job cron.Job
+	_ = json.Unmarshal(rr.Body.Bytes(), &job)
+	if job.NextRunAt == nil || job.NextRunAt.UTC().Hour() != 18 {
+		t.Fatalf("expected next run at 02:00 Shanghai (18:00 UTC), got %v", job.NextRunAt)
+	}
+
+	if rr := adminCronRequest(t, router, http.MethodPost, "/admin/cron/"+job.ID+"/run", ""); rr.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d; body=%s", rr.Code, rr.Body.String())
+	}
+	scheduler.Wait()
+
+	rr = adminCronRequest(t, router, http.MethodGet, "/admin/cron", "")
+	var list struct {
+		Data []cron.Job `json:"data"`
+	}
+	_ = json.Unmarshal(rr.Body.Bytes(), &list)
+	if len(list.Data) != 1 || list.Data[0].LastRun == nil || list.Data[0].LastRun.StatusCode != http.StatusOK {
+		t.Fatalf("expected last run recorded, got %s", rr.Body.String())
+	}
+	run, ok := runs.Get(list.Data[0].LastRun.RunID)
+	if !ok || run.Status != ccrun.StatusCompleted || run.Path != "/v1/messages" {
+		t.Fatalf("expected the execution stored as a run, got %+v", run)
+	}
+
+	if rr := adminCronRequest(t, router, http.MethodPut, "/admin/cron/"+job.ID, `{"schedule":"not cron"}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a bad schedule, got %d", rr.Code)
+	}
+	if rr := adminCronRequest(t, router, http.MethodDelete, "/admin/cron/"+job.ID, ""); rr.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,303 @@
+package gateway_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"ccgateway/internal/batch"
+	"ccgateway/internal/billing"
+	"ccgateway/internal/ccevent"
+	"ccgateway/internal/ccrun"
+	"ccgateway/internal/files"
+	. "ccgateway/internal/gateway"
+	"ccgateway/internal/memory"
+	"ccgateway/internal/runlog"
+	"ccgateway/internal/session"
+	"ccgateway/internal/upstream"
+)
+
+func TestAdminDataExportAndDeleteCascadeByProject(t *testing.T) {
+	runs := ccrun.NewStore()
+	sessions := session.NewStore()
+	events := ccevent.NewStore()
+	ledger, err := billing.NewLedger("", nil)
+	if err != nil {
+		t.Fatalf("ledger: %v", err)
+	}
+	router := newTestRouterWithDeps(t, Dependencies{
+		AdminToken:   "secret-admin",
+		RunStore:     runs,
+		SessionStore: sessions,
+		EventStore:   events,
+		UsageLedger:  ledger,
+	})
+	call := func(method, path, project, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("anthropic-version", "2023-06-01")
+		req.Header.Set("authorization", "Bearer secret-admin")
+		if project != "" {
+			req.Header.Set("x-project-id", project)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+	for _, project := range []string{"acme", "acme", "other"} {
+		rr := call(http.MethodPost, "/v1/cc/sessions", project, `{"title":"t"}`)
+		if rr.Code != http.StatusCreated && rr.Code != http.StatusOK {
+			t.Fatalf("create session: %d %s", rr.Code, rr.Body.String())
+		}
+		var sess session.Session
+		_ = json.Unmarshal(rr.Body.Bytes(), &sess)
+		body := `{"model":"claude-test","max_tokens":64,"metadata":{"session_id":"` + sess.ID + `"},"messages":[{"role":"user","content":"hello"}]}`
+		if rr := call(http.MethodPost, "/v1/messages", project, body); rr.Code != http.StatusOK {
+			t.Fatalf("messages: %d %s", rr.Code, rr.Body.String())
+		}
+	}
+
+	rr := call(http.MethodGet, "/admin/data/export?project_id=ACME", "", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("export: %d %s", rr.Code, rr.Body.String())
+	}
+	var export struct {
+		Runs     []ccrun.Run           `json:"runs"`
+		Sessions []session.Session     `json:"sessions"`
+		Events   []ccevent.Event       `json:"events"`
+		Usage    []billing.UsageRecord `json:"usage"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &export); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(export.Runs) != 2 || len(export.Sessions) != 2 || len(export.Usage) != 2 || len(export.Events) == 0 {
+		t.Fatalf("unexpected export: runs=%d sessions=%d usage=%d events=%d", len(export.Runs), len(export.Sessions), len(export.Usage), len(export.Events))
+	}
+	for _, ev := range export.Events {
+		if ev.RunID == "" && ev.SessionID == "" {
+			t.Fatalf("export included an unrelated event: %+v", ev)
+		}
+	}
+
+	rr = call(http.MethodPost, "/admin/data/delete?project_id=acme", "", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("delete: %d %s", rr.Code, rr.Body.String())
+	}
+	var deleted struct {
+		Deleted map[string]int `json:"deleted"`
+	}
+	_ = json.Unmarshal(rr.Body.Bytes(), &deleted)
+	if deleted.Deleted["runs"] != 2 || deleted.Deleted["sessions"] != 2 || deleted.Deleted["usage_records"] != 2 || deleted.Deleted["events"] != len(export.Events) {
+		t.Fatalf("unexpected deletion counts: %+v", deleted.Deleted)
+	}
+	if left := runs.List(ccrun.ListFilter{}); len(left) != 1 || left[0].ProjectID != "other" {
+		t.Fatalf("other project's run must survive: %+v", left)
+	}
+	if left := sessions.List(0); len(left) != 1 || left[0].ProjectID != "other" {
+		t.Fatalf("other project's session must survive: %+v", left)
+	}
+	if records, total := ledger.List(billing.UsageQuery{}); total != 1 || records[0].ProjectID != "other" {
+		t.Fatalf("other project's usage must survive: %+v", records)
+	}
+	if got := events.List(ccevent.ListFilter{EventType: "data.deleted"}); len(got) != 1 {
+		t.Fatalf("expected a data.deleted audit event, got %d", len(got))
+	}
+
+	if rr := call(http.MethodGet, "/admin/data/export?project_id=a&user_id=b", "", ""); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for two subjects, got %d", rr.Code)
+	}
+}
+
+func TestAdminDataDeleteLeavesNothingForTheSubject(t *testing.T) {
+	upstreamSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Stream bool `json:"stream"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if !body.Stream {
+			w.Header().Set("content-type", "application/json")
+			_, _ = w.Write([]byte(`{"id":"msg_1","type":"message","role":"assistant","model":"m","content":[{"type":"text","text":"ok"}],"stop_reason":"end_turn","usage":{"input_tokens":3,"output_tokens":1}}`))
+			return
+		}
+		w.Header().Set("content-type", "text/event-stream")
+		for _, frame := range []string{
+			`event: message_start` + "\n" + `data: {"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","model":"m","content":[],"usage":{"input_tokens":3,"output_tokens":0}}}`,
+			`event: content_block_start` + "\n" + `data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+			`event: content_block_delta` + "\n" + `data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"ok"}}`,
+			`event: content_block_stop` + "\n" + `data: {"type":"content_block_stop","index":0}`,
+			`event: message_delta` + "\n" + `data: {"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":1}}`,
+			`event: message_stop` + "\n" + `data: {"type":"message_stop"}`,
+		} {
+			_, _ = w.Write([]byte(frame + "\n\n"))
+		}
+	}))
+	defer upstreamSrv.Close()
+	adapter, err := upstream.NewHTTPAdapter(upstream.HTTPAdapterConfig{Name: "wire", Kind: upstream.AdapterKindAnthropic, BaseURL: upstreamSrv.URL, APIKey: "sk-upstream"}, nil)
+	if err != nil {
+		t.Fatalf("new adapter: %v", err)
+	}
+	dir := t.TempDir()
+	judgeHistory, err := upstream.NewJudgeHistory(filepath.Join(dir, "judge.jsonl"))
+	if err != nil {
+		t.Fatalf("judge history: %v", err)
+	}
+	defer judgeHistory.Close()
+	svc := upstream.NewRouterService(upstream.RouterConfig{DefaultRoute: []string{"wire"}, JudgeHistory: judgeHistory}, []upstream.Adapter{adapter})
+	logPath := filepath.Join(dir, "runs.log")
+	runLog, err := runlog.NewFileLogger(logPath)
+	if err != nil {
+		t.Fatalf("run log: %v", err)
+	}
+	fileStore, err := files.New(files.Config{}, files.NewMemoryBlobs())
+	if err != nil {
+		t.Fatalf("files: %v", err)
+	}
+	batches := batch.NewManager(batch.Config{})
+	memories := memory.NewInMemoryStore()
+	runs := ccrun.NewStore()
+	sessions := session.NewStore()
+	router := newTestRouterWithDeps(t, Dependencies{
+		Orchestrator: svc,
+		AdminToken:   "secret-admin",
+		RunStore:     runs,
+		SessionStore: sessions,
+		EventStore:   ccevent.NewStore(),
+		RunLogger:    runLog,
+		MemoryStore:  memories,
+		Files:        fileStore,
+		Batches:      batches,
+		StreamResume: StreamResumeConfig{Window: time.Minute},
+		WireCapture:  WireCaptureConfig{Enabled: true},
+	})
+	call := func(method, path, project, contentType string, body []byte) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, path, bytes.NewReader(body))
+		req.Header.Set("anthropic-version", "2023-06-01")
+		req.Header.Set("authorization", "Bearer secret-admin")
+		req.Header.Set("x-cc-debug-wire", "1")
+		if contentType != "" {
+			req.Header.Set("content-type", contentType)
+		}
+		if project != "" {
+			req.Header.Set("x-project-id", project)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	runIDs := map[string][]string{}
+	for _, project := range []string{"acme", "other"} {
+		rr := call(http.MethodPost, "/v1/cc/sessions", project, "", []byte(`{"title":"t"}`))
+		var sess session.Session
+		_ = json.Unmarshal(rr.Body.Bytes(), &sess)
+		if sess.ID == "" {
+			t.Fatalf("create session: %d %s", rr.Code, rr.Body.String())
+		}
+		_ = memories.UpdateWorkingMemory(context.Background(), &memory.WorkingMemory{SessionID: sess.ID})
+		_ = memories.UpdateSessionMemory(context.Background(), &memory.SessionMemory{SessionID: sess.ID, Summary: project + " secrets"})
+		for _, stream := range []string{"false", "true"} {
+			body := `{"model":"claude-test","max_tokens":64,"stream":` + stream + `,"metadata":{"session_id":"` + sess.ID + `"},"messages":[{"role":"user","content":"hello"}]}`
+			rr := call(http.MethodPost, "/v1/messages", project, "application/json", []byte(body))
+			runID := rr.Header().Get("x-cc-run-id")
+			if rr.Code != http.StatusOK || runID == "" {
+				t.Fatalf("messages: %d %s", rr.Code, rr.Body.String())
+			}
+			runIDs[project] = append(runIDs[project], runID)
+			judgeHistory.Record(upstream.JudgeVerdict{RunID: runID, Judge: "test", Winner: "wire"})
+		}
+		var form bytes.Buffer
+		mw := multipart.NewWriter(&form)
+		part, _ := mw.CreateFormFile("file", project+".txt")
+		_, _ = part.Write([]byte(project + " notes"))
+		_ = mw.Close()
+		if rr := call(http.MethodPost, "/v1/files", project, mw.FormDataContentType(), form.Bytes()); rr.Code != http.StatusOK {
+			t.Fatalf("upload: %d %s", rr.Code, rr.Body.String())
+		}
+		batchBody := `{"endpoint":"/v1/chat/completions","requests":[{"custom_id":"a","method":"POST","url":"/v1/chat/completions","body":{"model":"claude-test","messages":[{"role":"user","content":"hi"}]}}]}`
+		if rr := call(http.MethodPost, "/v1/batches", project, "application/json", []byte(batchBody)); rr.Code != http.StatusOK {
+			t.Fatalf("batch: %d %s", rr.Code, rr.Body.String())
+		}
+	}
+	batches.Wait()
+	for _, runID := range runIDs["acme"] {
+		if rr := call(http.MethodGet, "/admin/runs/"+runID+"/wire", "", "", nil); rr.Code != http.StatusOK {
+			t.Fatalf("expected a wire capture for %s before deletion, got %d", runID, rr.Code)
+		}
+	}
+
+	rr := call(http.MethodPost, "/admin/data/delete?project_id=acme", "", "", nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("delete: %d %s", rr.Code, rr.Body.String())
+	}
+	var out struct {
+		Deleted     map[string]int `json:"deleted"`
+		Unsupported []string       `json:"unsupported"`
+	}
+	_ = json.Unmarshal(rr.Body.Bytes(), &out)
+	if len(out.Unsupported) > 0 {
+		t.Fatalf("unexpected unsupported stores: %v", out.Unsupported)
+	}
+	for _, key := range []string{"runs", "sessions", "run_log_entries", "memories", "files", "batches", "wire_captures", "stream_buffers", "judge_verdicts"} {
+		if out.Deleted[key] == 0 {
+			t.Fatalf("expected %s to be deleted, got %+v", key, out.Deleted)
+		}
+	}
+
+	for _, run := range runs.List(ccrun.ListFilter{}) {
+		if run.ProjectID == "acme" {
+			t.Fatalf("acme run survived: %+v", run)
+		}
+	}
+	for _, sess := range sessions.List(0) {
+		if sess.ProjectID == "acme" {
+			t.Fatalf("acme session survived: %+v", sess)
+		}
+	}
+	logData, _ := os.ReadFile(logPath)
+	for _, runID := range runIDs["acme"] {
+		if bytes.Contains(logData, []byte(runID)) {
+			t.Fatalf("run log still holds %s", runID)
+		}
+		if rr := call(http.MethodGet, "/admin/runs/"+runID+"/wire", "", "", nil); rr.Code != http.StatusNotFound {
+			t.Fatalf("wire capture of %s survived: %d", runID, rr.Code)
+		}
+		if rr := call(http.MethodGet, "/v1/messages/stream/"+runID, "acme", "", nil); rr.Code != http.StatusNotFound {
+			t.Fatalf("stream buffer of %s survived: %d", runID, rr.Code)
+		}
+		if got := judgeHistory.Verdicts(runID, 0); len(got) != 0 {
+			t.Fatalf("judge verdicts of %s survived: %+v", runID, got)
+		}
+	}
+	if stats := memories.GetStats(); stats["working_memory_count"] != 1 || stats["session_memory_count"] != 1 {
+		t.Fatalf("expected only other's memory to survive: %+v", stats)
+	}
+	if left, _ := fileStore.List(files.ListFilter{}); len(left) != 1 || left[0].Filename != "other.txt" {
+		t.Fatalf("expected only other's file to survive: %+v", left)
+	}
+	if left, _ := batches.List(batch.ListFilter{}); len(left) != 1 {
+		t.Fatalf("expected only other's batch to survive: %+v", left)
+	}
+	journal, _ := os.ReadFile(filepath.Join(dir, "judge.jsonl"))
+
+	for _, runID := range runIDs["other"] {
+		if !bytes.Contains(logData, []byte(runID)) || !bytes.Contains(journal, []byte(runID)) {
+			t.Fatalf("other project's run %s must survive in the logs", runID)
+		}
+		if rr := call(http.MethodGet, "/admin/runs/"+runID+"/wire", "", "", nil); rr.Code != http.StatusOK {
+			t.Fatalf("other project's wire capture must survive: %d", rr.Code)
+		}
+	}
+	for _, runID := range runIDs["acme"] {
+		if bytes.Contains(journal, []byte(runID)) {
+			t.Fatalf("judge history file still holds %s", runID)
+		}
+	}
+}
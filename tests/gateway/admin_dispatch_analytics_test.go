@@ -0,0 +1,65 @@
+package gateway_test
+
+import (
+	. "ccgateway/internal/gateway"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ccgateway/internal/scheduler"
+	"ccgateway/internal/upstream"
+)
+
+func TestAdminDispatchAnalyticsReportsRecordedDecisions(t *testing.T) {
+	history, err := upstream.NewDispatchHistory("")
+	if err != nil {
+		t.Fatalf("open history: %v", err)
+	}
+	election := scheduler.NewElection(scheduler.ElectionConfig{Enabled: true})
+	dispatcher := upstream.NewDispatcher(upstream.DispatchConfig{Enabled: true}, election)
+	dispatcher.SetHistory(history)
+	election.SetOnChange(dispatcher.RecordElection)
+	election.UpdateScores([]scheduler.IntelligenceScore{
+		{AdapterName: "smart", Score: 95},
+		{AdapterName: "worker", Score: 60},
+	})
+	svc := upstream.NewRouterService(upstream.RouterConfig{Dispatcher: dispatcher}, []upstream.Adapter{
+		upstream.NewMockAdapter("smart", false),
+		upstream.NewMockAdapter("worker", false),
+	})
+	router := newTestRouterWithDeps(t, Dependencies{Orchestrator: svc, AdminToken: "secret-admin"})
+
+	msg := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(`{"model":"m","max_tokens":16,"messages":[{"role":"user","content":"hi"}]}`))
+	msg.Header.Set("anthropic-version", "2023-06-01")
+	msg.Header.Set("authorization", "Bearer secret-admin")
+	msgRR := httptest.NewRecorder()
+	router.ServeHTTP(msgRR, msg)
+	if msgRR.Code != http.StatusOK {
+		t.Fatalf("expected 200 for message, got %d; body=%s", msgRR.Code, msgRR.Body.String())
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/dispatch/analytics?window=1h", nil)
+	req.Header.Set("authorization", "Bearer secret-admin")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d; body=%s", rr.Code, rr.Body.String())
+	}
+	var report upstream.DispatchAnalytics
+	if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil {
+		t.Fatalf("decode report: %v", err)
+	}
+	if report.Decisions != 1 || report.ByTarget["worker"].Success != 1 || report.Elections.Count != 1 {
+		t.Fatalf("unexpected report: %s", rr.Body.String())
+	}
+
+	bad := httptest.NewRequest(http.MethodGet, "/admin/dispatch/analytics?since=yesterday", nil)
+	bad.Header.Set("authorization", "Bearer secret-admin")
+	badRR := httptest.NewRecorder()
+	router.ServeHTTP(badRR, bad)
+	if badRR.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for bad since, got %d", badRR.Code)
+	}
+}
@@ -0,0 +1,169 @@
+package gateway_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	. "ccgateway/internal/gateway"
+	"ccgateway/internal/mcpregistry"
+	"ccgateway/internal/settings"
+	"ccgateway/internal/statepersist"
+	"ccgateway/internal/upstream"
+)
+
+type doctorResult struct {
+	Status  string         `json:"status"`
+	Summary map[string]int `json:"summary"`
+	Checks  []struct {
+		Name        string `json:"name"`
+		Category    string `json:"category"`
+		Status      string `json:"status"`
+		Message     string `json:"message"`
+		Remediation string `json:"remediation"`
+	} `json:"checks"`
+}
+
+// find returns the status of the check in category named name, or "".
+func (d doctorResult) find(category, name string) string {
+	for _, c := range d.Checks {
+		if c.Category == category && c.Name == name {
+			return c.Status
+		}
+	}
+	return ""
+}
+
+func runDoctor(t *testing.T, router http.Handler) doctorResult {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/admin/doctor", nil)
+	req.Header.Set("authorization", "Bearer secret-admin")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("doctor: %d %s", rr.Code, rr.Body.String())
+	}
+	var out doctorResult
+	if err := json.Unmarshal(rr.Body.Bytes(), &out); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	for _, c := range out.Checks {
+		if c.Status != "pass" && c.Remediation == "" {
+			t.Fatalf("check %s/%s has no remediation: %+v", c.Category, c.Name, c)
+		}
+	}
+	return out
+}
+
+func TestAdminDoctorReportsChecks(t *testing.T) {
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/mcp-ok":
+			return
+		case "/mcp-down":
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("date", time.Now().Add(-5*time.Minute).UTC().Format(http.TimeFormat))
+		if r.Header.Get("authorization") != "Bearer good" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"data":[{"id":"gpt-real"}]}`))
+	}))
+	defer provider.Close()
+
+	var adapters []upstream.Adapter
+	for _, name := range []string{"good", "bad"} {
+		a, err := upstream.NewHTTPAdapter(upstream.HTTPAdapterConfig{Name: name, Kind: upstream.AdapterKindOpenAI, BaseURL: provider.URL, APIKey: name}, nil)
+		if err != nil {
+			t.Fatalf("adapter: %v", err)
+		}
+		adapters = append(adapters, a)
+	}
+	adapters = append(adapters, &flakyAdapter{})
+	svc := upstream.NewRouterService(upstream.RouterConfig{
+		DefaultRoute: []string{"good"},
+		Routes:       map[string][]string{"gpt-*": {"good", "bad"}, "legacy": {"ghost"}},
+	}, adapters)
+	if _, err := svc.AdapterCapabilities(context.Background(), "good", false); err != nil {
+		t.Fatalf("capabilities: %v", err)
+	}
+
+	cfg := settings.DefaultRuntimeSettings()
+	cfg.ModelMappings = map[string]string{"claude-x": "gpt-real", "claude-y": "gpt-missing"}
+	registry := mcpregistry.NewStore(nil)
+	for _, path := range []string{"/mcp-ok", "/mcp-down"} {
+		if _, err := registry.Register(mcpregistry.RegisterInput{ID: path[1:], Name: path[1:], Transport: mcpregistry.TransportHTTP, URL: provider.URL + path}); err != nil {
+			t.Fatalf("register: %v", err)
+		}
+	}
+	router := newTestRouterWithDeps(t, Dependencies{
+		Orchestrator: svc,
+		AdminToken:   "secret-admin",
+		Settings:     settings.NewStore(cfg),
+		MCPRegistry:  registry,
+	})
+
+	out := runDoctor(t, router)
+	if out.Status != "fail" || out.Summary["fail"] == 0 || out.Summary["pass"] == 0 {
+		t.Fatalf("unexpected overall result: %+v", out)
+	}
+	want := []struct{ category, name, status string }{
+		{"adapters", "adapter:good", "pass"},
+		{"keys", "adapter:good", "pass"},
+		{"adapters", "adapter:bad", "pass"},
+		{"keys", "adapter:bad", "fail"},
+		{"adapters", "adapter:flaky", "warn"},
+		{"routes", "route:upstream.model_routes.legacy", "fail"},
+		{"model_mappings", "model_mapping:claude-y", "warn"},
+		{"mcp", "mcp:mcp-ok", "pass"},
+		{"mcp", "mcp:mcp-down", "fail"},
+		{"persistence", "persistence", "warn"},
+		{"clock", "clock_skew", "fail"},
+	}
+	for _, w := range want {
+		if got := out.find(w.category, w.name); got != w.status {
+			t.Fatalf("%s/%s: expected %s, got %q in %+v", w.category, w.name, w.status, got, out.Checks)
+		}
+	}
+	if got := out.find("model_mappings", "model_mapping:claude-x"); got != "" {
+		t.Fatalf("a resolvable mapping must not be reported alone, got %q", got)
+	}
+}
+
+func TestAdminDoctorChecksPersistenceWrites(t *testing.T) {
+	dir := t.TempDir()
+	backend, err := statepersist.NewFileBackend(dir)
+	if err != nil {
+		t.Fatalf("backend: %v", err)
+	}
+	router := newTestRouterWithDeps(t, Dependencies{
+		AdminToken:  "secret-admin",
+		Persistence: statepersist.NewManager(backend, nil, nil, nil),
+	})
+	if got := runDoctor(t, router).find("persistence", "persistence"); got != "pass" {
+		t.Fatalf("expected writable persistence, got %q", got)
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	if got := runDoctor(t, router).find("persistence", "persistence"); got != "fail" {
+		t.Fatalf("expected unwritable persistence to fail, got %q", got)
+	}
+}
+
+func TestAdminDoctorRequiresGet(t *testing.T) {
+	router := newTestRouterWithDeps(t, Dependencies{AdminToken: "secret-admin"})
+	req := httptest.NewRequest(http.MethodPost, "/admin/doctor", nil)
+	req.Header.Set("authorization", "Bearer secret-admin")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405, got %d", rr.Code)
+	}
+}
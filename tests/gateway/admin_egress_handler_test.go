@@ -0,0 +1,50 @@
+package gateway_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ccgateway/internal/egress"
+	. "ccgateway/internal/gateway"
+)
+
+func TestAdminEgressConfigPatch(t *testing.T) {
+	policy, _ := egress.NewPolicy(egress.Config{})
+	router := newTestRouterWithDeps(t, Dependencies{
+		AdminToken:   "secret-admin",
+		EgressPolicy: policy,
+	})
+
+	req := httptest.NewRequest(http.MethodPut, "/admin/egress", strings.NewReader(`{"deny_cidrs":["169.254.0.0/16"],"block_private":true,"max_response_bytes":1024}`))
+	req.Header.Set("authorization", "Bearer secret-admin")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var body struct {
+		Egress struct {
+			Config egress.Config `json:"config"`
+		} `json:"egress"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !body.Egress.Config.BlockPrivate || body.Egress.Config.MaxResponseBytes != 1024 || len(body.Egress.Config.DenyCIDRs) != 1 {
+		t.Fatalf("unexpected config: %+v", body.Egress.Config)
+	}
+
+	req = httptest.NewRequest(http.MethodPut, "/admin/egress", strings.NewReader(`{"allow_cidrs":["bogus"]}`))
+	req.Header.Set("authorization", "Bearer secret-admin")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid cidr, got %d", rr.Code)
+	}
+	if len(policy.Config().AllowCIDRs) != 0 {
+		t.Fatalf("expected rejected patch to leave policy unchanged")
+	}
+}
@@ -0,0 +1,113 @@
+package gateway_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ccgateway/internal/ccevent"
+	. "ccgateway/internal/gateway"
+)
+
+func TestAdminEventsDeleteBefore(t *testing.T) {
+	store := ccevent.NewStore()
+	for _, typ := range []string{"run.created", "tool.gap", "run.created"} {
+		if _, err := store.Append(ccevent.AppendInput{EventType: typ}); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	router := newTestRouterWithDeps(t, Dependencies{EventStore: store, AdminToken: "secret-admin"})
+
+	do := func(target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodDelete, target, nil)
+		req.Header.Set("authorization", "Bearer secret-admin")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+	if rr := do("/admin/events"); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 without before, got %d", rr.Code)
+	}
+	if rr := do("/admin/events?before=yesterday"); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a bad timestamp, got %d", rr.Code)
+	}
+
+	before := time.Now().Add(time.Minute).UTC().Format(time.RFC3339)
+	rr := do("/admin/events?event_type=run.created&before=" + before)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var body struct {
+		Deleted int `json:"deleted"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil || body.Deleted != 2 {
+		t.Fatalf("expected 2 deleted, got %s", rr.Body.String())
+	}
+	if left := store.List(ccevent.ListFilter{}); len(left) != 1 || left[0].EventType != "tool.gap" {
+		t.Fatalf("expected only the tool.gap event left, got %+v", left)
+	}
+}
+
+func TestAdminEventsStreamResumesFromCursor(t *testing.T) {
+	store := ccevent.NewStore()
+	first, _ := store.Append(ccevent.AppendInput{EventType: "run.created", RunID: "run_1"})
+	_, _ = store.Append(ccevent.AppendInput{EventType: "run.created", RunID: "run_2"})
+	missed, _ := store.Append(ccevent.AppendInput{EventType: "run.completed", RunID: "run_1"})
+	router := newTestRouterWithDeps(t, Dependencies{EventStore: store, AdminToken: "secret-admin"})
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/admin/events/stream?run_id=run_1", nil)
+	req.Header.Set("authorization", "Bearer secret-admin")
+	req.Header.Set("last-event-id", first.ID)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("open stream: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+
+	ids := make(chan string, 16)
+	go func() {
+		sc := bufio.NewScanner(resp.Body)
+		for sc.Scan() {
+			if id, ok := strings.CutPrefix(sc.Text(), "id: "); ok {
+				ids <- id
+			}
+		}
+		close(ids)
+	}()
+	next := func() string {
+		select {
+		case id := <-ids:
+			return id
+		case <-time.After(3 * time.Second):
+			t.Fatalf("timed out waiting for an event")
+			return ""
+		}
+	}
+	if got := next(); got != missed.ID {
+		t.Fatalf("expected the missed run_1 event replayed first, got %s", got)
+	}
+	_, _ = store.Append(ccevent.AppendInput{EventType: "run.created", RunID: "run_3"})
+	live, _ := store.Append(ccevent.AppendInput{EventType: "run.failed", RunID: "run_1"})
+	if got := next(); got != live.ID {
+		t.Fatalf("expected the live run_1 event next, got %s", got)
+	}
+}
+
+func TestAdminEventsStreamRequiresAdmin(t *testing.T) {
+	router := newTestRouterWithDeps(t, Dependencies{EventStore: ccevent.NewStore(), AdminToken: "secret-admin"})
+	req := httptest.NewRequest(http.MethodGet, "/admin/events/stream", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without the admin token, got %d", rr.Code)
+	}
+}
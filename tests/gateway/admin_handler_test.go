@@ -652,7 +652,8 @@ func TestAdminSchedulerAndProbeStatus(t *testing.T) {
 		t.Fatalf("expected 200 for admin probe, got %d; body=%s", rrProbe.Code, rrProbe.Body.String())
 	}
 
-	putSchedulerBody := `{"failure_threshold":4,"cooldown_ms":12000,"strict_probe_gate":true}`
+	putSchedulerBody := `{"failure_threshold":4,"cooldown_ms":12000,"strict_probe_gate":true,
+		"maintenance_windows":[{"id":"a1-nightly","adapter":"a1","cron":"0 3 * * *","duration_ms":3600000}]}`
 	reqPutScheduler := httptest.NewRequest(http.MethodPut, "/admin/scheduler", strings.NewReader(putSchedulerBody))
 	reqPutScheduler.Header.Set("authorization", "Bearer secret-admin")
 	rrPutScheduler := httptest.NewRecorder()
@@ -660,6 +661,28 @@ func TestAdminSchedulerAndProbeStatus(t *testing.T) {
 	if rrPutScheduler.Code != http.StatusOK {
 		t.Fatalf("expected 200 for put admin scheduler, got %d; body=%s", rrPutScheduler.Code, rrPutScheduler.Body.String())
 	}
+	var putScheduler struct {
+		Scheduler struct {
+			Maintenance struct {
+				Upcoming []scheduler.MaintenanceOccurrence `json:"upcoming"`
+			} `json:"maintenance"`
+		} `json:"scheduler"`
+	}
+	if err := json.Unmarshal(rrPutScheduler.Body.Bytes(), &putScheduler); err != nil {
+		t.Fatalf("decode put admin scheduler: %v", err)
+	}
+	if up := putScheduler.Scheduler.Maintenance.Upcoming; len(up) == 0 || up[0].WindowID != "a1-nightly" || up[0].StartsAt.Hour() != 3 {
+		t.Fatalf("expected upcoming a1 maintenance window, got %+v", up)
+	}
+
+	badWindowBody := `{"maintenance_windows":[{"adapter":"a1","cron":"0 3 * *","duration_ms":3600000}]}`
+	reqBadWindow := httptest.NewRequest(http.MethodPut, "/admin/scheduler", strings.NewReader(badWindowBody))
+	reqBadWindow.Header.Set("authorization", "Bearer secret-admin")
+	rrBadWindow := httptest.NewRecorder()
+	router.ServeHTTP(rrBadWindow, reqBadWindow)
+	if rrBadWindow.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid maintenance cron, got %d; body=%s", rrBadWindow.Code, rrBadWindow.Body.String())
+	}
 
 	putProbeBody := `{"enabled":false,"interval_ms":45000,"timeout_ms":7000,"default_models":["x1","x2"],"stream_smoke":true}`
 	reqPutProbe := httptest.NewRequest(http.MethodPut, "/admin/probe", strings.NewReader(putProbeBody))
@@ -764,6 +787,25 @@ func TestAdminUpstreamUpdate(t *testing.T) {
 	if len(cfg.DefaultRoute) != 1 || cfg.DefaultRoute[0] != "script-a1" {
 		t.Fatalf("unexpected default route after update: %+v", cfg.DefaultRoute)
 	}
+
+	reqStatus := httptest.NewRequest(http.MethodGet, "/admin/upstream/status", nil)
+	reqStatus.Header.Set("authorization", "Bearer secret-admin")
+	rrStatus := httptest.NewRecorder()
+	router.ServeHTTP(rrStatus, reqStatus)
+	if rrStatus.Code != http.StatusOK {
+		t.Fatalf("expected 200 for upstream status, got %d; body=%s", rrStatus.Code, rrStatus.Body.String())
+	}
+	var status upstream.UpstreamApplyStatus
+	if err := json.Unmarshal(rrStatus.Body.Bytes(), &status); err != nil {
+		t.Fatalf("decode upstream status: %v", err)
+	}
+	actions := map[string]string{}
+	for _, a := range status.Adapters {
+		actions[a.Name] = a.Action
+	}
+	if status.Version != 2 || actions["script-a1"] != upstream.ApplyActionAdded || actions["mock-a"] != upstream.ApplyActionRemoved {
+		t.Fatalf("unexpected upstream apply status: %+v", status)
+	}
 }
 
 func TestAdminCapabilitiesMatrixByModelAndModeRoute(t *testing.T) {
@@ -1705,3 +1747,136 @@ func (s *failingAuthService) AddQuota(userID string, quota int64) error {
 	}
 	return s.Service.AddQuota(userID, quota)
 }
+
+func TestAdminUserTokenRotateWithHashedStore(t *testing.T) {
+	authSvc := auth.NewInMemoryService()
+	user, err := authSvc.Register("rotate-user", "secret", auth.RoleUser)
+	if err != nil {
+		t.Fatalf("register user: %v", err)
+	}
+	tokenSvc, err := token.NewHashedFileService(filepath.Join(t.TempDir(), "tokens.json"))
+	if err != nil {
+		t.Fatalf("open token store: %v", err)
+	}
+	tk, err := tokenSvc.Generate(user.ID, 100)
+	if err != nil {
+		t.Fatalf("generate token: %v", err)
+	}
+	router := newTestRouterWithDeps(t, Dependencies{
+		AdminToken:   "secret-admin",
+		AuthService:  authSvc,
+		TokenService: tokenSvc,
+	})
+	tokenPath := "/admin/auth/users/" + user.ID + "/tokens/" + strconv.FormatInt(tk.ID, 10)
+
+	reqGet := httptest.NewRequest(http.MethodGet, tokenPath, nil)
+	reqGet.Header.Set("authorization", "Bearer secret-admin")
+	rrGet := httptest.NewRecorder()
+	router.ServeHTTP(rrGet, reqGet)
+	if rrGet.Code != http.StatusOK {
+		t.Fatalf("expected 200 for token get, got %d; body=%s", rrGet.Code, rrGet.Body.String())
+	}
+	if strings.Contains(rrGet.Body.String(), tk.Value) || !strings.Contains(rrGet.Body.String(), tk.Prefix+"...") {
+		t.Fatalf("token get must only expose the prefix: %s", rrGet.Body.String())
+	}
+
+	reqRotate := httptest.NewRequest(http.MethodPost, tokenPath+"/rotate", nil)
+	reqRotate.Header.Set("authorization", "Bearer secret-admin")
+	rrRotate := httptest.NewRecorder()
+	router.ServeHTTP(rrRotate, reqRotate)
+	if rrRotate.Code != http.StatusOK {
+		t.Fatalf("expected 200 for rotate, got %d; body=%s", rrRotate.Code, rrRotate.Body.String())
+	}
+	var rotated token.Token
+	if err := json.Unmarshal(rrRotate.Body.Bytes(), &rotated); err != nil {
+		t.Fatalf("decode rotate response: %v", err)
+	}
+	if rotated.ID != tk.ID || rotated.Value == tk.Value || !strings.HasPrefix(rotated.Value, "sk-cc-") {
+		t.Fatalf("unexpected rotated token: %+v", rotated)
+	}
+	if _, err := tokenSvc.Validate(tk.Value); err == nil {
+		t.Fatalf("old token value must be invalid after rotation")
+	}
+	if _, err := tokenSvc.Validate(rotated.Value); err != nil {
+		t.Fatalf("rotated token value must validate: %v", err)
+	}
+
+	reqDelete := httptest.NewRequest(http.MethodDelete, tokenPath, nil)
+	reqDelete.Header.Set("authorization", "Bearer secret-admin")
+	rrDelete := httptest.NewRecorder()
+	router.ServeHTTP(rrDelete, reqDelete)
+	if rrDelete.Code != http.StatusNoContent {
+		t.Fatalf("expected 204 for token delete, got %d; body=%s", rrDelete.Code, rrDelete.Body.String())
+	}
+}
+
+func TestAdminTokenQuotaWindowsBlockWarnAndReset(t *testing.T) {
+	authSvc := auth.NewInMemoryService()
+	user, err := authSvc.Register("window-user", "secret", auth.RoleUser)
+	if err != nil {
+		t.Fatalf("register user: %v", err)
+	}
+	tokenSvc := token.NewInMemoryService()
+	eventStore := ccevent.NewStore()
+	router := newTestRouterWithDeps(t, Dependencies{
+		Orchestrator: &captureService{},
+		AdminToken:   "secret-admin",
+		AuthService:  authSvc,
+		TokenService: tokenSvc,
+		EventStore:   eventStore,
+	})
+	admin := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("authorization", "Bearer secret-admin")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	created := admin(http.MethodPost, "/admin/auth/users/"+user.ID+"/tokens", `{"name":"team","quota_windows":[{"period":"daily","limit":30,"warn_percent":50}]}`)
+	if created.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d; body=%s", created.Code, created.Body.String())
+	}
+	var tk token.Token
+	if err := json.Unmarshal(created.Body.Bytes(), &tk); err != nil {
+		t.Fatalf("decode token: %v", err)
+	}
+	if len(tk.QuotaWindows) != 1 || tk.QuotaWindows[0].ResetAt.IsZero() {
+		t.Fatalf("expected a scheduled daily window, got %+v", tk.QuotaWindows)
+	}
+
+	send := func(maxTokens int) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(`{"model":"claude-test","max_tokens":`+strconv.Itoa(maxTokens)+`,"messages":[{"role":"user","content":"hi"}]}`))
+		req.Header.Set("anthropic-version", "2023-06-01")
+		req.Header.Set("authorization", "Bearer "+tk.Value)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+	if rr := send(16); rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 within the window, got %d; body=%s", rr.Code, rr.Body.String())
+	}
+	if events := eventStore.List(ccevent.ListFilter{EventType: "quota.threshold_reached"}); len(events) != 1 || events[0].Data["period"] != "daily" {
+		t.Fatalf("expected one threshold event, got %+v", events)
+	}
+	blocked := send(100)
+	if blocked.Code != http.StatusForbidden || !strings.Contains(blocked.Body.String(), "daily quota of 30 exceeded") {
+		t.Fatalf("expected daily window rejection, got %d; body=%s", blocked.Code, blocked.Body.String())
+	}
+
+	tokenPath := "/admin/auth/users/" + user.ID + "/tokens/" + strconv.FormatInt(tk.ID, 10)
+	raised := admin(http.MethodPut, tokenPath, `{"quota_windows":[{"period":"daily","limit":500}]}`)
+	if raised.Code != http.StatusOK || !strings.Contains(raised.Body.String(), `"limit":500`) {
+		t.Fatalf("expected window override, got %d; body=%s", raised.Code, raised.Body.String())
+	}
+	if rr := send(100); rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 after raising the window, got %d; body=%s", rr.Code, rr.Body.String())
+	}
+	reset := admin(http.MethodPost, tokenPath+"/quota-windows/reset?period=daily", "")
+	if reset.Code != http.StatusOK || !strings.Contains(reset.Body.String(), `"used":0`) {
+		t.Fatalf("expected reset window, got %d; body=%s", reset.Code, reset.Body.String())
+	}
+	if bad := admin(http.MethodPut, tokenPath, `{"quota_windows":[{"period":"hourly","limit":5}]}`); bad.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unknown period, got %d", bad.Code)
+	}
+}
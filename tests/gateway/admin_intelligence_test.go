@@ -0,0 +1,94 @@
+package gateway_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	. "ccgateway/internal/gateway"
+	"ccgateway/internal/orchestrator"
+	"ccgateway/internal/probe"
+	"ccgateway/internal/scheduler"
+	"ccgateway/internal/upstream"
+)
+
+func TestAdminIntelligenceReportsTrends(t *testing.T) {
+	health := scheduler.NewEngine(scheduler.Config{}, []string{"a1"})
+	runner := probe.NewRunner(probe.Config{}, []upstream.Adapter{upstream.NewMockAdapter("a1", false)}, health)
+	history, _ := probe.NewIntelligenceHistory("")
+	for i, score := range []float64{50, 70} {
+		history.Record(probe.IntelligenceResult{AdapterName: "a1", Model: "m1", Score: score, TestedAt: time.Now().Add(time.Duration(i) * time.Minute)})
+	}
+	runner.EnableIntelligence(probe.IntelligenceConfig{Interval: time.Hour}, history, nil)
+
+	router := newTestRouterWithDeps(t, Dependencies{
+		Orchestrator: orchestrator.NewSimpleService(),
+		AdminToken:   "secret-admin",
+		ProbeStatus:  runner,
+	})
+	req := httptest.NewRequest(http.MethodGet, "/admin/intelligence?adapter=a1&limit=1", nil)
+	req.Header.Set("authorization", "Bearer secret-admin")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var report probe.IntelligenceReport
+	if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil {
+		t.Fatalf("decode report: %v", err)
+	}
+	if !report.Enabled || report.IntervalMS != time.Hour.Milliseconds() {
+		t.Fatalf("unexpected schedule: %+v", report)
+	}
+	if len(report.Trends) != 1 || report.Trends[0].Direction != "up" || len(report.Trends[0].History) != 1 {
+		t.Fatalf("unexpected trends: %+v", report.Trends)
+	}
+	if len(report.ElectionScores) != 1 || report.ElectionScores[0].Score != 60 {
+		t.Fatalf("unexpected election scores: %+v", report.ElectionScores)
+	}
+
+	bare := newTestRouterWithDeps(t, Dependencies{Orchestrator: orchestrator.NewSimpleService(), AdminToken: "secret-admin"})
+	rr = httptest.NewRecorder()
+	bare.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotImplemented {
+		t.Fatalf("expected 501 without a probe runner, got %d", rr.Code)
+	}
+}
+
+func TestAdminProbeTasksReplaceSuite(t *testing.T) {
+	health := scheduler.NewEngine(scheduler.Config{}, []string{"a1"})
+	runner := probe.NewRunner(probe.Config{}, []upstream.Adapter{upstream.NewMockAdapter("a1", false)}, health)
+	router := newTestRouterWithDeps(t, Dependencies{
+		Orchestrator: orchestrator.NewSimpleService(),
+		AdminToken:   "secret-admin",
+		ProbeStatus:  runner,
+	})
+	do := func(method, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/admin/probe/tasks", strings.NewReader(body))
+		req.Header.Set("authorization", "Bearer secret-admin")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := do(http.MethodPut, `{"tasks":[{"category":"chinese","prompt":"用一个词回答：中国的首都是？","expected":"北京","weight":2},{"category":"tool_use","prompt":"call the tool","keywords":["tool_use"]}]}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("put tasks: %d %s", rr.Code, rr.Body.String())
+	}
+	var set probe.ProbeTaskSet
+	if err := json.Unmarshal(do(http.MethodGet, "").Body.Bytes(), &set); err != nil {
+		t.Fatalf("decode tasks: %v", err)
+	}
+	if !set.Custom || len(set.Tasks) != 2 || set.Tasks[0].Validator != probe.ValidatorContains || set.Tasks[1].Validator != probe.ValidatorContainsAll {
+		t.Fatalf("unexpected task set: %+v", set)
+	}
+	if rr := do(http.MethodPut, `{"tasks":[{"prompt":"no rule"}]}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a task without a grading rule, got %d", rr.Code)
+	}
+	if rr := do(http.MethodDelete, ""); rr.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405, got %d", rr.Code)
+	}
+}
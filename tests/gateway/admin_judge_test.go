@@ -0,0 +1,85 @@
+package gateway_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "ccgateway/internal/gateway"
+	"ccgateway/internal/upstream"
+)
+
+func TestAdminJudgeReportAndVerdicts(t *testing.T) {
+	history, err := upstream.NewJudgeHistory("")
+	if err != nil {
+		t.Fatalf("open history: %v", err)
+	}
+	history.Record(upstream.JudgeVerdict{
+		Timestamp: time.Now().Add(-time.Hour),
+		RunID:     "run_a",
+		Model:     "m1",
+		Judge:     "heuristic",
+		Winner:    "smart",
+		Rationale: "more complete",
+		Candidates: []upstream.JudgeCandidate{
+			{Adapter: "smart", Score: 12, Winner: true},
+			{Adapter: "worker", Score: 3},
+		},
+	})
+	svc := upstream.NewRouterService(upstream.RouterConfig{JudgeHistory: history}, []upstream.Adapter{
+		upstream.NewMockAdapter("smart", false),
+	})
+	router := newTestRouterWithDeps(t, Dependencies{Orchestrator: svc, AdminToken: "secret-admin"})
+
+	get := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("authorization", "Bearer secret-admin")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := get("/admin/judge/report?window=24h")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d; body=%s", rr.Code, rr.Body.String())
+	}
+	var report upstream.JudgeReport
+	if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil {
+		t.Fatalf("decode report: %v", err)
+	}
+	if report.Verdicts != 1 || len(report.ByAdapter) != 2 || report.ByAdapter[0].Adapter != "smart" || report.ByAdapter[0].WinRate != 1 {
+		t.Fatalf("unexpected report: %s", rr.Body.String())
+	}
+
+	rr = get("/admin/judge/verdicts?run_id=run_a")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d; body=%s", rr.Code, rr.Body.String())
+	}
+	var list struct {
+		Data []upstream.JudgeVerdict `json:"data"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &list); err != nil {
+		t.Fatalf("decode verdicts: %v", err)
+	}
+	if len(list.Data) != 1 || list.Data[0].Rationale != "more complete" {
+		t.Fatalf("unexpected verdicts: %s", rr.Body.String())
+	}
+
+	if rr := get("/admin/judge/report?window=soon"); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for bad window, got %d", rr.Code)
+	}
+}
+
+func TestAdminJudgeReportWithoutHistory(t *testing.T) {
+	svc := upstream.NewRouterService(upstream.RouterConfig{}, []upstream.Adapter{upstream.NewMockAdapter("a", false)})
+	router := newTestRouterWithDeps(t, Dependencies{Orchestrator: svc, AdminToken: "secret-admin"})
+	req := httptest.NewRequest(http.MethodGet, "/admin/judge/report", nil)
+	req.Header.Set("authorization", "Bearer secret-admin")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotImplemented {
+		t.Fatalf("expected 501 without judge history, got %d", rr.Code)
+	}
+}
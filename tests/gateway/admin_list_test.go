@@ -0,0 +1,148 @@
+package gateway_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"ccgateway/internal/auth"
+	"ccgateway/internal/ccrun"
+	. "ccgateway/internal/gateway"
+)
+
+type adminListPage struct {
+	Data       []map[string]any `json:"data"`
+	Total      int              `json:"total"`
+	Limit      int              `json:"limit"`
+	HasMore    bool             `json:"has_more"`
+	NextCursor string           `json:"next_cursor"`
+	Sort       string           `json:"sort"`
+}
+
+func getAdminListPage(t *testing.T, router http.Handler, path string) adminListPage {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.Header.Set("authorization", "Bearer secret-admin")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("GET %s: expected 200, got %d: %s", path, rr.Code, rr.Body.String())
+	}
+	var page adminListPage
+	if err := json.Unmarshal(rr.Body.Bytes(), &page); err != nil {
+		t.Fatalf("decode %s: %v", path, err)
+	}
+	return page
+}
+
+func TestAdminRunsListCursorPaging(t *testing.T) {
+	runs := ccrun.NewStore()
+	for i := 0; i < 5; i++ {
+		mode := "chat"
+		if i%2 == 1 {
+			mode = "plan"
+		}
+		if _, err := runs.Create(ccrun.CreateInput{ID: fmt.Sprintf("run_%d", i), Path: "/v1/messages", Mode: mode, ToolCount: i}); err != nil {
+			t.Fatalf("create run: %v", err)
+		}
+	}
+	router := newTestRouterWithDeps(t, Dependencies{AdminToken: "secret-admin", RunStore: runs})
+
+	var seen []string
+	path := "/admin/runs?sort=tool_count&limit=2"
+	for {
+		page := getAdminListPage(t, router, path)
+		if page.Total != 5 || page.Sort != "tool_count" {
+			t.Fatalf("unexpected envelope: %+v", page)
+		}
+		for _, item := range page.Data {
+			seen = append(seen, item["id"].(string))
+		}
+		if !page.HasMore {
+			break
+		}
+		path = "/admin/runs?sort=tool_count&limit=2&cursor=" + url.QueryEscape(page.NextCursor)
+	}
+	if fmt.Sprint(seen) != "[run_0 run_1 run_2 run_3 run_4]" {
+		t.Fatalf("unexpected paging order: %v", seen)
+	}
+
+	page := getAdminListPage(t, router, "/admin/runs?mode=plan&sort=-tool_count")
+	if page.Total != 2 || page.Data[0]["id"] != "run_3" || page.HasMore {
+		t.Fatalf("unexpected filtered page: %+v", page)
+	}
+
+	first := getAdminListPage(t, router, "/admin/runs?limit=1")
+	req := httptest.NewRequest(http.MethodGet, "/admin/runs?limit=1&mode=chat&cursor="+url.QueryEscape(first.NextCursor), nil)
+	req.Header.Set("authorization", "Bearer secret-admin")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for cursor reused with other filters, got %d", rr.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/admin/runs?sort=bogus", nil)
+	req.Header.Set("authorization", "Bearer secret-admin")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unknown sort key, got %d", rr.Code)
+	}
+}
+
+func TestAdminUsersListFiltersAndCapsLimit(t *testing.T) {
+	authSvc := auth.NewInMemoryService()
+	for _, username := range []string{"ops-a", "ops-b", "dev-a"} {
+		if _, err := authSvc.Register(username, "secret", auth.RoleUser); err != nil {
+			t.Fatalf("register %s: %v", username, err)
+		}
+	}
+	router := newTestRouterWithDeps(t, Dependencies{AdminToken: "secret-admin", AuthService: authSvc})
+
+	page := getAdminListPage(t, router, "/admin/auth/users?search=ops&sort=-username&limit=100000")
+	if page.Total != 2 || page.Limit != 1000 {
+		t.Fatalf("unexpected envelope: %+v", page)
+	}
+	if page.Data[0]["username"] != "ops-b" || page.Data[1]["username"] != "ops-a" {
+		t.Fatalf("unexpected order: %+v", page.Data)
+	}
+}
+
+func TestAdminRunSearch(t *testing.T) {
+	runs := ccrun.NewStore()
+	for _, in := range []struct {
+		id, record string
+		status     int
+	}{
+		{"run_ok", "/v1/messages | status=200 | output=\"deploy finished\"", 200},
+		{"run_bad", "/v1/messages | status=502 | error=\"deploy upstream reset\"", 502},
+	} {
+		if _, err := runs.Create(ccrun.CreateInput{ID: in.id, Path: "/v1/messages", Mode: "chat"}); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+		if _, err := runs.Complete(in.id, ccrun.CompleteInput{StatusCode: in.status, Error: "upstream reset", RecordText: in.record}); err != nil {
+			t.Fatalf("complete: %v", err)
+		}
+	}
+	router := newTestRouterWithDeps(t, Dependencies{AdminToken: "secret-admin", RunStore: runs})
+
+	page := getAdminListPage(t, router, "/admin/runs/search?q=deploy&status=failed")
+	if page.Total != 1 || page.Data[0]["id"] != "run_bad" || page.Data[0]["record_text"] == nil {
+		t.Fatalf("unexpected search result: %+v", page)
+	}
+	page = getAdminListPage(t, router, "/admin/runs/search?q=deploy&limit=1")
+	if page.Total != 2 || !page.HasMore || page.NextCursor == "" {
+		t.Fatalf("expected a second page, got %+v", page)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/runs/search?min_duration_ms=-1", nil)
+	req.Header.Set("authorization", "Bearer secret-admin")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for negative duration, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,179 @@
+package gateway_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ccgateway/internal/auth"
+	. "ccgateway/internal/gateway"
+	"ccgateway/internal/modelmap"
+	"ccgateway/internal/orchestrator"
+	"ccgateway/internal/policy"
+	"ccgateway/internal/settings"
+	"ccgateway/internal/token"
+)
+
+func TestAdminPolicyTestCandidateRules(t *testing.T) {
+	router := NewRouter(Dependencies{
+		Orchestrator: orchestrator.NewSimpleService(),
+		Policy:       policy.NewNoopEngine(),
+		ModelMapper:  modelmap.NewIdentityMapper(),
+		AdminToken:   "secret-admin",
+	})
+
+	body := `{
+		"rules":[
+			{"id":"deny-opus-for-free","effect":"deny","models":["claude-*-opus*"],"groups":["free"]},
+			{"id":"deny-shell","effect":"deny","priority":5,"tools":["bash"]}
+		],
+		"cases":[
+			{"name":"free opus","model":"claude-3-opus","token":{"group":"free"},"expect":"deny"},
+			{"name":"paid opus","model":"claude-3-opus","token":{"group":"paid"},"expect":"allow"},
+			{"name":"shell","model":"x","tools":["bash"],"expect":"allow"},
+			{"name":"model restricted","model":"y","token":{"models":["x"]},"expect":"deny"}
+		]
+	}`
+	req := httptest.NewRequest(http.MethodPost, "/admin/policy/test", strings.NewReader(body))
+	req.Header.Set("authorization", "Bearer secret-admin")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d; body=%s", rr.Code, rr.Body.String())
+	}
+	var resp struct {
+		Source  string `json:"source"`
+		OK      bool   `json:"ok"`
+		Results []struct {
+			Name        string       `json:"name"`
+			Effect      string       `json:"effect"`
+			MatchedRule *policy.Rule `json:"matched_rule"`
+			Passed      *bool        `json:"passed"`
+		} `json:"results"`
+		Summary map[string]int `json:"summary"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.Source != "candidate_rules" || resp.OK {
+		t.Fatalf("expected candidate source with one failing expectation, got %+v", resp)
+	}
+	if resp.Results[0].MatchedRule == nil || resp.Results[0].MatchedRule.ID != "deny-opus-for-free" {
+		t.Fatalf("expected first case to match deny-opus-for-free, got %+v", resp.Results[0])
+	}
+	if resp.Results[2].Effect != "deny" || resp.Results[2].Passed == nil || *resp.Results[2].Passed {
+		t.Fatalf("expected shell case to deny and fail its expectation, got %+v", resp.Results[2])
+	}
+	if resp.Results[3].Effect != "deny" || resp.Results[3].MatchedRule != nil {
+		t.Fatalf("expected token model restriction deny, got %+v", resp.Results[3])
+	}
+	if resp.Summary["passed"] != 3 || resp.Summary["failed"] != 1 {
+		t.Fatalf("unexpected summary: %+v", resp.Summary)
+	}
+}
+
+func TestAdminPolicyTestLiveEngineAndValidation(t *testing.T) {
+	router := NewRouter(Dependencies{
+		Orchestrator: orchestrator.NewSimpleService(),
+		Policy:       policy.NewNoopEngine(),
+		ModelMapper:  modelmap.NewIdentityMapper(),
+		AdminToken:   "secret-admin",
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/policy/test", strings.NewReader(`{"cases":[{"tools":["forbidden_tool"],"expect":"deny"}]}`))
+	req.Header.Set("authorization", "Bearer secret-admin")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d; body=%s", rr.Code, rr.Body.String())
+	}
+	if !strings.Contains(rr.Body.String(), `"source":"live_engine"`) || !strings.Contains(rr.Body.String(), `"ok":true`) {
+		t.Fatalf("expected live engine deny to pass expectation, got %s", rr.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/admin/policy/test", strings.NewReader(`{"rules":[{"id":"a","effect":"nope"}],"cases":[{}]}`))
+	req.Header.Set("authorization", "Bearer secret-admin")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid rule effect, got %d", rr.Code)
+	}
+}
+
+func TestPolicyRulesInSettingsAreEnforcedLive(t *testing.T) {
+	authSvc := auth.NewInMemoryService()
+	user, err := authSvc.Register("freeloader", "secret", "user")
+	if err != nil {
+		t.Fatalf("register user: %v", err)
+	}
+	user.Group = "free"
+	if err := authSvc.Update(user); err != nil {
+		t.Fatalf("update user: %v", err)
+	}
+	tokenSvc := token.NewInMemoryService()
+	tk, err := tokenSvc.Generate(user.ID, 1000)
+	if err != nil {
+		t.Fatalf("generate token: %v", err)
+	}
+	st := settings.NewStore(settings.DefaultRuntimeSettings())
+	router := newTestRouterWithDeps(t, Dependencies{
+		Settings:     st,
+		Policy:       policy.NewDynamicEngine(st, nil),
+		AdminToken:   "secret-admin",
+		AuthService:  authSvc,
+		TokenService: tokenSvc,
+	})
+	admin := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("authorization", "Bearer secret-admin")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+	send := func() int {
+		req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(`{"model":"claude-3-opus","max_tokens":16,"messages":[{"role":"user","content":"hi"}]}`))
+		req.Header.Set("anthropic-version", "2023-06-01")
+		req.Header.Set("authorization", "Bearer "+tk.Value)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	if code := send(); code != http.StatusOK {
+		t.Fatalf("expected 200 before any rule, got %d", code)
+	}
+	cfg := st.Get()
+	cfg.Policy.Rules = []settings.PolicyRule{{ID: "deny-opus-for-free", Effect: "nope"}}
+	raw, _ := json.Marshal(cfg)
+	if rr := admin(http.MethodPut, "/admin/settings", string(raw)); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an invalid rule, got %d: %s", rr.Code, rr.Body.String())
+	}
+	cfg.Policy.Rules = []settings.PolicyRule{{ID: "deny-opus-for-free", Effect: "deny", Models: []string{"*opus*"}, Groups: []string{"free"}}}
+	raw, _ = json.Marshal(cfg)
+	if rr := admin(http.MethodPut, "/admin/settings", string(raw)); rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 saving rules, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if code := send(); code != http.StatusForbidden {
+		t.Fatalf("expected the live rule to deny the free group, got %d", code)
+	}
+
+	rr := admin(http.MethodPost, "/admin/policy/test", `{"cases":[
+		{"model":"claude-3-opus","token":{"group":"free"},"expect":"deny"},
+		{"model":"claude-3-opus","token":{"group":"paid"},"expect":"allow"}
+	]}`)
+	var resp struct {
+		Source  string `json:"source"`
+		OK      bool   `json:"ok"`
+		Results []struct {
+			MatchedRule *policy.Rule `json:"matched_rule"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.Source != "live_engine" || !resp.OK || resp.Results[0].MatchedRule == nil || resp.Results[0].MatchedRule.ID != "deny-opus-for-free" {
+		t.Fatalf("expected the live rule reported for the free case, got %s", rr.Body.String())
+	}
+}
@@ -0,0 +1,111 @@
+package gateway_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "ccgateway/internal/gateway"
+	"ccgateway/internal/project"
+	"ccgateway/internal/settings"
+	"ccgateway/internal/token"
+)
+
+func TestProjectScopesSettingsRoutesAndQuota(t *testing.T) {
+	tokenSvc := token.NewInMemoryService()
+	tk, err := tokenSvc.Generate("user-1", 0)
+	if err != nil {
+		t.Fatalf("generate token: %v", err)
+	}
+	projects, _ := project.New(project.Config{})
+	svc := &captureService{}
+	router := newTestRouterWithDeps(t, Dependencies{
+		Orchestrator: svc,
+		Settings:     settings.NewStore(settings.DefaultRuntimeSettings()),
+		TokenService: tokenSvc,
+		AdminToken:   "secret-admin",
+		Projects:     projects,
+	})
+
+	admin := func(method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("authorization", "Bearer secret-admin")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+	if rr := admin(http.MethodPost, "/admin/projects", `{"id":"bad","settings":{"routing":"fast"}}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid overrides, got %d: %s", rr.Code, rr.Body.String())
+	}
+	body := fmt.Sprintf(`{"id":"acme","name":"Acme","settings":{"model_mappings":{"claude-test":"acme-model"}},"routes":{"chat":["acme-adapter"]},"quota":{"requests_per_minute":2},"token_ids":[%d]}`, tk.ID)
+	if rr := admin(http.MethodPost, "/admin/projects", body); rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := admin(http.MethodPost, "/admin/projects", fmt.Sprintf(`{"id":"other","token_ids":[%d]}`, tk.ID)); rr.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for a token bound twice, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	send := func(auth, projectHeader string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(`{"model":"claude-test","max_tokens":16,"messages":[{"role":"user","content":"hi"}]}`))
+		req.Header.Set("anthropic-version", "2023-06-01")
+		req.Header.Set("authorization", "Bearer "+auth)
+		if projectHeader != "" {
+			req.Header.Set("x-cc-project", projectHeader)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := send(tk.Value, ""); rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if svc.capturedReq.Model != "acme-model" {
+		t.Fatalf("expected the project's model mapping, got %q", svc.capturedReq.Model)
+	}
+	if route, _ := svc.capturedReq.Metadata["routing_adapter_route"].([]string); len(route) != 1 || route[0] != "acme-adapter" {
+		t.Fatalf("expected the project's route, got %#v", svc.capturedReq.Metadata["routing_adapter_route"])
+	}
+	if rr := send(tk.Value, "globex"); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 when a bound token names another project, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	if rr := send("secret-admin", ""); rr.Code != http.StatusOK || svc.capturedReq.Model != "claude-test" {
+		t.Fatalf("expected global settings without a project, got %d model=%q", rr.Code, svc.capturedReq.Model)
+	}
+	if rr := send("secret-admin", "acme"); rr.Code != http.StatusOK || svc.capturedReq.Model != "acme-model" {
+		t.Fatalf("expected the header to select the project, got %d model=%q", rr.Code, svc.capturedReq.Model)
+	}
+	if rr := send(tk.Value, ""); rr.Code != http.StatusTooManyRequests || rr.Header().Get("retry-after") == "" {
+		t.Fatalf("expected 429 once the project rate is spent, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	rr := admin(http.MethodGet, "/admin/projects/acme", "")
+	var got project.Project
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode project: %v", err)
+	}
+	if got.Usage.Requests != 2 || got.Usage.Tokens == 0 {
+		t.Fatalf("expected usage recorded, got %+v", got.Usage)
+	}
+	rr = admin(http.MethodGet, "/admin/projects/acme/settings", "")
+	var effective settings.RuntimeSettings
+	if err := json.Unmarshal(rr.Body.Bytes(), &effective); err != nil {
+		t.Fatalf("decode settings: %v", err)
+	}
+	if effective.ModelMappings["claude-test"] != "acme-model" || effective.Routing.Retries != 1 {
+		t.Fatalf("expected overrides merged over defaults, got %+v", effective)
+	}
+
+	if rr := admin(http.MethodDelete, "/admin/projects/acme", ""); rr.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", rr.Code)
+	}
+	if rr := send(tk.Value, ""); rr.Code != http.StatusOK || svc.capturedReq.Model != "claude-test" {
+		t.Fatalf("expected global settings after delete, got %d model=%q", rr.Code, svc.capturedReq.Model)
+	}
+}
@@ -0,0 +1,104 @@
+package gateway_test
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ccgateway/internal/auth"
+	"ccgateway/internal/ccevent"
+	. "ccgateway/internal/gateway"
+	"ccgateway/internal/token"
+	"ccgateway/internal/trafficsample"
+)
+
+func TestAdminTrafficStreamRequiresAnalystRoleAndAudits(t *testing.T) {
+	authSvc := auth.NewInMemoryService()
+	tokenSvc := token.NewInMemoryService()
+	events := ccevent.NewStore()
+	plain, _ := authSvc.Register("plain", "secret", auth.RoleUser)
+	plainToken, _ := tokenSvc.Generate(plain.ID, 0)
+	analyst, _ := authSvc.Register("ana", "secret", auth.RoleAnalyst)
+	analystToken, _ := tokenSvc.Generate(analyst.ID, 0)
+
+	sampler := trafficsample.NewSampler(trafficsample.Config{Enabled: true, SampleRate: 1})
+	router := newTestRouterWithDeps(t, Dependencies{
+		AdminToken:     "secret-admin",
+		AuthService:    authSvc,
+		TokenService:   tokenSvc,
+		EventStore:     events,
+		TrafficSampler: sampler,
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/traffic/stream", nil)
+	req.Header.Set("authorization", "Bearer secret-admin")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected admin token alone to be rejected, got %d", rr.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/admin/traffic/stream", nil)
+	req.Header.Set("authorization", "Bearer "+plainToken.Value)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for non-analyst user, got %d", rr.Code)
+	}
+
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+	streamReq, _ := http.NewRequest(http.MethodGet, srv.URL+"/admin/traffic/stream", nil)
+	streamReq.Header.Set("authorization", "Bearer "+analystToken.Value)
+	resp, err := http.DefaultClient.Do(streamReq)
+	if err != nil {
+		t.Fatalf("open stream: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 for analyst, got %d", resp.StatusCode)
+	}
+
+	msgReq, _ := http.NewRequest(http.MethodPost, srv.URL+"/v1/messages", strings.NewReader(`{"model":"m","max_tokens":16,"messages":[{"role":"user","content":"reach me at bob@example.com"}]}`))
+	msgReq.Header.Set("anthropic-version", "2023-06-01")
+	msgReq.Header.Set("authorization", "Bearer secret-admin")
+	msgResp, err := http.DefaultClient.Do(msgReq)
+	if err != nil {
+		t.Fatalf("send message: %v", err)
+	}
+	msgResp.Body.Close()
+
+	lines := make(chan string, 16)
+	go func() {
+		sc := bufio.NewScanner(resp.Body)
+		for sc.Scan() {
+			lines <- sc.Text()
+		}
+		close(lines)
+	}()
+	deadline := time.After(3 * time.Second)
+	for found := false; !found; {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				t.Fatalf("stream closed before sample arrived")
+			}
+			if strings.HasPrefix(line, "data:") && strings.Contains(line, `"path":"/v1/messages"`) {
+				if strings.Contains(line, "bob@example.com") || !strings.Contains(line, "[EMAIL]") {
+					t.Fatalf("expected redacted prompt, got %s", line)
+				}
+				found = true
+			}
+		case <-deadline:
+			t.Fatalf("timed out waiting for traffic sample")
+		}
+	}
+
+	audits := events.List(ccevent.ListFilter{EventType: "audit.traffic_sample_access"})
+	if len(audits) != 3 {
+		t.Fatalf("expected every access attempt audited, got %d", len(audits))
+	}
+}
@@ -0,0 +1,199 @@
+package gateway_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"ccgateway/internal/ccevent"
+	"ccgateway/internal/channel"
+	. "ccgateway/internal/gateway"
+	"ccgateway/internal/mcpregistry"
+	"ccgateway/internal/orchestrator"
+	"ccgateway/internal/toolcatalog"
+)
+
+type trashEnv struct {
+	router   http.Handler
+	channels *channel.AbilityStore
+	tools    *toolcatalog.Catalog
+	mcp      *mcpregistry.Store
+	events   *ccevent.Store
+}
+
+func newTrashEnv(t *testing.T, retention time.Duration) trashEnv {
+	t.Helper()
+	env := trashEnv{
+		channels: channel.NewAbilityStore(),
+		tools:    toolcatalog.NewCatalog([]toolcatalog.ToolSpec{{Name: "bash", Status: "supported"}, {Name: "grep", Status: "supported"}}),
+		mcp:      mcpregistry.NewStore(nil),
+		events:   ccevent.NewStore(),
+	}
+	env.router = newTestRouterWithDeps(t, Dependencies{
+		Orchestrator:   orchestrator.NewSimpleService(),
+		AdminToken:     "secret-admin",
+		ChannelStore:   env.channels,
+		ToolCatalog:    env.tools,
+		MCPRegistry:    env.mcp,
+		EventStore:     env.events,
+		TrashRetention: retention,
+	})
+	return env
+}
+
+func (e trashEnv) do(t *testing.T, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("authorization", "Bearer secret-admin")
+	rr := httptest.NewRecorder()
+	e.router.ServeHTTP(rr, req)
+	return rr
+}
+
+type trashListing struct {
+	Data []struct {
+		Kind      string    `json:"kind"`
+		ID        string    `json:"id"`
+		Name      string    `json:"name"`
+		DeletedAt time.Time `json:"deleted_at"`
+		ExpiresAt time.Time `json:"expires_at"`
+	} `json:"data"`
+	RetentionSeconds int64 `json:"retention_seconds"`
+}
+
+func (e trashEnv) list(t *testing.T, query string) trashListing {
+	t.Helper()
+	rr := e.do(t, http.MethodGet, "/admin/trash"+query, "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("list trash: %d %s", rr.Code, rr.Body.String())
+	}
+	var out trashListing
+	if err := json.Unmarshal(rr.Body.Bytes(), &out); err != nil {
+		t.Fatalf("decode trash: %v", err)
+	}
+	return out
+}
+
+func TestAdminTrashRestoresDeletedChannel(t *testing.T) {
+	env := newTrashEnv(t, 0)
+	if err := env.channels.AddChannel(&channel.Channel{Name: "vip", Type: "openai", Models: "gpt-4o", Group: "default", Status: channel.StatusEnabled}); err != nil {
+		t.Fatalf("add channel: %v", err)
+	}
+	ch, _ := env.channels.GetChannelByGroupAndModel("default", "gpt-4o")
+	id := strconv.FormatInt(ch.ID, 10)
+
+	if rr := env.do(t, http.MethodDelete, "/admin/channels/"+id, ""); rr.Code != http.StatusNoContent {
+		t.Fatalf("delete channel: %d %s", rr.Code, rr.Body.String())
+	}
+	if _, ok := env.channels.GetChannel(ch.ID); ok {
+		t.Fatalf("deleted channel should not be live")
+	}
+
+	listing := env.list(t, "?kind=channel")
+	if listing.RetentionSeconds != int64(7*24*time.Hour/time.Second) {
+		t.Fatalf("unexpected default retention: %d", listing.RetentionSeconds)
+	}
+	if len(listing.Data) != 1 || listing.Data[0].ID != id || listing.Data[0].Name != "vip" {
+		t.Fatalf("unexpected trash listing: %+v", listing.Data)
+	}
+	if !listing.Data[0].ExpiresAt.After(listing.Data[0].DeletedAt) {
+		t.Fatalf("expires_at should follow deleted_at: %+v", listing.Data[0])
+	}
+
+	if rr := env.do(t, http.MethodGet, "/admin/trash/channel/"+id, ""); rr.Code != http.StatusOK {
+		t.Fatalf("get trashed channel: %d %s", rr.Code, rr.Body.String())
+	}
+	if rr := env.do(t, http.MethodPost, "/admin/trash/channel/"+id+"/restore", ""); rr.Code != http.StatusOK {
+		t.Fatalf("restore channel: %d %s", rr.Code, rr.Body.String())
+	}
+	if _, ok := env.channels.GetChannelByGroupAndModel("default", "gpt-4o"); !ok {
+		t.Fatalf("restored channel should route again")
+	}
+	if rr := env.do(t, http.MethodPost, "/admin/trash/channel/"+id+"/restore", ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("second restore should be 404, got %d", rr.Code)
+	}
+
+	var types []string
+	for _, ev := range env.events.List(ccevent.ListFilter{}) {
+		types = append(types, ev.EventType)
+	}
+	joined := strings.Join(types, ",")
+	if !strings.Contains(joined, "resource.soft_deleted") || !strings.Contains(joined, "resource.restored") {
+		t.Fatalf("expected soft delete and restore events, got %v", types)
+	}
+}
+
+func TestAdminTrashTracksRemovedTools(t *testing.T) {
+	env := newTrashEnv(t, 0)
+	if rr := env.do(t, http.MethodPut, "/admin/tools", `{"tools":[{"name":"bash","status":"supported"}]}`); rr.Code != http.StatusOK {
+		t.Fatalf("replace tools: %d %s", rr.Code, rr.Body.String())
+	}
+	listing := env.list(t, "?kind=tool")
+	if len(listing.Data) != 1 || listing.Data[0].ID != "grep" {
+		t.Fatalf("expected grep in trash, got %+v", listing.Data)
+	}
+
+	if rr := env.do(t, http.MethodPost, "/admin/trash/tool/grep/restore", ""); rr.Code != http.StatusOK {
+		t.Fatalf("restore tool: %d %s", rr.Code, rr.Body.String())
+	}
+	names := map[string]bool{}
+	for _, spec := range env.tools.Snapshot() {
+		names[spec.Name] = true
+	}
+	if !names["bash"] || !names["grep"] {
+		t.Fatalf("expected bash and grep after restore, got %v", names)
+	}
+	if got := env.list(t, "?kind=tool"); len(got.Data) != 0 {
+		t.Fatalf("tool trash should be empty, got %+v", got.Data)
+	}
+}
+
+func TestAdminTrashRestoreConflictAndPurge(t *testing.T) {
+	env := newTrashEnv(t, 0)
+	if _, err := env.mcp.Register(mcpregistry.RegisterInput{ID: "mcp_a", Name: "A", Transport: mcpregistry.TransportHTTP, URL: "http://127.0.0.1:1"}); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if rr := env.do(t, http.MethodDelete, "/v1/cc/mcp/servers/mcp_a", ""); rr.Code != http.StatusNoContent {
+		t.Fatalf("delete mcp server: %d %s", rr.Code, rr.Body.String())
+	}
+	if _, err := env.mcp.Register(mcpregistry.RegisterInput{ID: "mcp_a", Name: "A2", Transport: mcpregistry.TransportHTTP, URL: "http://127.0.0.1:2"}); err != nil {
+		t.Fatalf("re-register: %v", err)
+	}
+	if rr := env.do(t, http.MethodPost, "/admin/trash/mcp_server/mcp_a/restore", ""); rr.Code != http.StatusConflict {
+		t.Fatalf("expected 409 restoring over a live server, got %d %s", rr.Code, rr.Body.String())
+	}
+	if rr := env.do(t, http.MethodDelete, "/admin/trash/mcp_server/mcp_a", ""); rr.Code != http.StatusNoContent {
+		t.Fatalf("purge: %d %s", rr.Code, rr.Body.String())
+	}
+	if got := env.list(t, ""); len(got.Data) != 0 {
+		t.Fatalf("trash should be empty after purge, got %+v", got.Data)
+	}
+	if rr := env.do(t, http.MethodGet, "/admin/trash/widget", ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for malformed path, got %d", rr.Code)
+	}
+	if rr := env.do(t, http.MethodGet, "/admin/trash?kind=widget", ""); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unknown kind, got %d", rr.Code)
+	}
+}
+
+func TestAdminTrashPurgesAfterRetention(t *testing.T) {
+	env := newTrashEnv(t, time.Nanosecond)
+	if err := env.channels.AddChannel(&channel.Channel{Name: "old", Type: "openai", Models: "gpt-4o", Group: "default", Status: channel.StatusEnabled}); err != nil {
+		t.Fatalf("add channel: %v", err)
+	}
+	ch, _ := env.channels.GetChannelByGroupAndModel("default", "gpt-4o")
+	if err := env.channels.SoftDeleteChannel(ch.ID); err != nil {
+		t.Fatalf("soft delete: %v", err)
+	}
+	time.Sleep(time.Millisecond)
+	if got := env.list(t, ""); len(got.Data) != 0 {
+		t.Fatalf("expired items should be purged, got %+v", got.Data)
+	}
+	if len(env.channels.DeletedChannels()) != 0 {
+		t.Fatalf("expired channel should be purged from the store")
+	}
+}
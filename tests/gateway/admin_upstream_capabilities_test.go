@@ -0,0 +1,69 @@
+package gateway_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	. "ccgateway/internal/gateway"
+	"ccgateway/internal/upstream"
+)
+
+func TestAdminUpstreamCapabilitiesProbesOncePerBaseURL(t *testing.T) {
+	var probes int64
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&probes, 1)
+		_, _ = w.Write([]byte(`{"data":[{"id":"m1","context_length":8192}]}`))
+	}))
+	defer provider.Close()
+	var adapters []upstream.Adapter
+	for _, name := range []string{"pool-a", "pool-b"} {
+		a, err := upstream.NewHTTPAdapter(upstream.HTTPAdapterConfig{Name: name, Kind: upstream.AdapterKindOpenAI, BaseURL: provider.URL, APIKey: name}, nil)
+		if err != nil {
+			t.Fatalf("adapter: %v", err)
+		}
+		adapters = append(adapters, a)
+	}
+	router := newTestRouterWithDeps(t, Dependencies{
+		Orchestrator: upstream.NewRouterService(upstream.RouterConfig{}, adapters),
+		AdminToken:   "secret-admin",
+	})
+	do := func(method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("authorization", "Bearer secret-admin")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	for _, name := range []string{"pool-a", "pool-b"} {
+		rr := do(http.MethodGet, "/admin/upstream/capabilities?adapter="+name)
+		if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"context_window":8192`) {
+			t.Fatalf("%s: %d %s", name, rr.Code, rr.Body.String())
+		}
+	}
+	if atomic.LoadInt64(&probes) != 1 {
+		t.Fatalf("expected one probe, got %d", probes)
+	}
+
+	rr := do(http.MethodGet, "/admin/upstream/capabilities")
+	var list struct {
+		Data []upstream.CapabilityStatus `json:"data"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &list); err != nil || len(list.Data) != 1 || len(list.Data[0].Adapters) != 2 {
+		t.Fatalf("unexpected list: %s", rr.Body.String())
+	}
+	if rr := do(http.MethodGet, "/admin/upstream/capabilities?adapter=nope"); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rr.Code)
+	}
+	if rr := do(http.MethodDelete, "/admin/upstream/capabilities"); rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"dropped":1`) {
+		t.Fatalf("delete: %d %s", rr.Code, rr.Body.String())
+	}
+	do(http.MethodGet, "/admin/upstream/capabilities?adapter=pool-b")
+	if atomic.LoadInt64(&probes) != 2 {
+		t.Fatalf("expected a new probe after invalidation, got %d", probes)
+	}
+}
@@ -0,0 +1,71 @@
+package gateway_test
+
+import (
+	. "ccgateway/internal/gateway"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ccgateway/internal/billing"
+	"ccgateway/internal/costtrack"
+)
+
+func TestAdminUsageRecordsRequestsAndExportsCSV(t *testing.T) {
+	ledger, err := billing.NewLedger("", map[string]costtrack.ModelPricing{"*": {InputPer1M: 1, OutputPer1M: 1}})
+	if err != nil {
+		t.Fatalf("open ledger: %v", err)
+	}
+	router := newTestRouterWithDeps(t, Dependencies{
+		Orchestrator: &captureService{},
+		AdminToken:   "secret-admin",
+		UsageLedger:  ledger,
+	})
+
+	msg := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(`{"model":"claude-test","max_tokens":16,"messages":[{"role":"user","content":"hi"}]}`))
+	msg.Header.Set("anthropic-version", "2023-06-01")
+	msg.Header.Set("authorization", "Bearer secret-admin")
+	msgRR := httptest.NewRecorder()
+	router.ServeHTTP(msgRR, msg)
+	if msgRR.Code != http.StatusOK {
+		t.Fatalf("expected 200 for message, got %d; body=%s", msgRR.Code, msgRR.Body.String())
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/usage?group_by=model", nil)
+	req.Header.Set("authorization", "Bearer secret-admin")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d; body=%s", rr.Code, rr.Body.String())
+	}
+	var summary struct {
+		Data   []billing.UsageGroup `json:"data"`
+		Totals billing.UsageGroup   `json:"totals"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &summary); err != nil {
+		t.Fatalf("decode summary: %v", err)
+	}
+	if len(summary.Data) != 1 || summary.Totals.Requests != 1 || summary.Totals.InputTokens != 1 || summary.Totals.OutputTokens != 1 {
+		t.Fatalf("unexpected summary: %s", rr.Body.String())
+	}
+
+	csvReq := httptest.NewRequest(http.MethodGet, "/admin/usage?format=csv", nil)
+	csvReq.Header.Set("authorization", "Bearer secret-admin")
+	csvRR := httptest.NewRecorder()
+	router.ServeHTTP(csvRR, csvReq)
+	if csvRR.Code != http.StatusOK || !strings.HasPrefix(csvRR.Header().Get("content-type"), "text/csv") {
+		t.Fatalf("expected csv export, got %d %q", csvRR.Code, csvRR.Header().Get("content-type"))
+	}
+	if lines := strings.Split(strings.TrimSpace(csvRR.Body.String()), "\n"); len(lines) != 2 || !strings.Contains(lines[1], "/v1/messages") {
+		t.Fatalf("unexpected csv body: %q", csvRR.Body.String())
+	}
+
+	bad := httptest.NewRequest(http.MethodGet, "/admin/usage?group_by=region", nil)
+	bad.Header.Set("authorization", "Bearer secret-admin")
+	badRR := httptest.NewRecorder()
+	router.ServeHTTP(badRR, bad)
+	if badRR.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unknown group_by, got %d", badRR.Code)
+	}
+}
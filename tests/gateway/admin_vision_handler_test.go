@@ -0,0 +1,100 @@
+package gateway_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ccgateway/internal/ccevent"
+	. "ccgateway/internal/gateway"
+	"ccgateway/internal/imageproc"
+)
+
+func TestMessagesImagePreprocessDownscalesBase64(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 300, 150))
+	for y := 0; y < 150; y++ {
+		for x := 0; x < 300; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: 90, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	cfg := imageproc.DefaultConfig()
+	cfg.MaxDimension = 100
+	svc := &captureService{}
+	events := ccevent.NewStore()
+	router := newTestRouterWithDeps(t, Dependencies{
+		Orchestrator:   svc,
+		EventStore:     events,
+		ImageProcessor: imageproc.NewProcessor(cfg),
+	})
+
+	body := `{"model":"claude-test","max_tokens":32,"messages":[{"role":"user","content":[` +
+		`{"type":"text","text":"what is this"},` +
+		`{"type":"image","source":{"type":"base64","media_type":"image/png","data":"` + base64.StdEncoding.EncodeToString(buf.Bytes()) + `"}}]}]}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(body))
+	req.Header.Set("anthropic-version", "2023-06-01")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	blocks, _ := svc.capturedReq.Messages[0].Content.([]any)
+	source, _ := blocks[1].(map[string]any)["source"].(map[string]any)
+	if source["media_type"] != "image/jpeg" {
+		t.Fatalf("expected downscaled jpeg, got %#v", source["media_type"])
+	}
+	raw, err := base64.StdEncoding.DecodeString(source["data"].(string))
+	if err != nil {
+		t.Fatalf("decode forwarded image: %v", err)
+	}
+	decoded, _, err := image.DecodeConfig(bytes.NewReader(raw))
+	if err != nil || decoded.Width != 100 || decoded.Height != 50 {
+		t.Fatalf("expected 100x50 forwarded image, got %+v err=%v", decoded, err)
+	}
+	if got := events.List(ccevent.ListFilter{EventType: "vision.image_preprocessed"}); len(got) != 1 {
+		t.Fatalf("expected one vision.image_preprocessed event, got %d", len(got))
+	}
+}
+
+func TestAdminVisionImagesConfigPatch(t *testing.T) {
+	router := newTestRouterWithDeps(t, Dependencies{
+		AdminToken:     "secret-admin",
+		ImageProcessor: imageproc.NewProcessor(imageproc.DefaultConfig()),
+	})
+
+	req := httptest.NewRequest(http.MethodPut, "/admin/vision/images", strings.NewReader(`{"max_dimension":1024,"proxy_urls":true}`))
+	req.Header.Set("authorization", "Bearer secret-admin")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var body struct {
+		Images imageproc.Config `json:"images"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if body.Images.MaxDimension != 1024 || !body.Images.ProxyURLs {
+		t.Fatalf("unexpected config: %+v", body.Images)
+	}
+
+	req = httptest.NewRequest(http.MethodPut, "/admin/vision/images", strings.NewReader(`{"jpeg_quality":0}`))
+	req.Header.Set("authorization", "Bearer secret-admin")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid quality, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,58 @@
+package gateway_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "ccgateway/internal/gateway"
+	"ccgateway/internal/webhook"
+)
+
+func TestAdminWebhooksCRUD(t *testing.T) {
+	hooks, err := webhook.NewDispatcher(webhook.Config{})
+	if err != nil {
+		t.Fatalf("new dispatcher: %v", err)
+	}
+	router := newTestRouterWithDeps(t, Dependencies{Webhooks: hooks, AdminToken: "secret-admin"})
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("authorization", "Bearer secret-admin")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := do(http.MethodPost, "/admin/webhooks", `{"url":"https://hooks.example.com/x","events":[]}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 without events, got %d", rr.Code)
+	}
+	rr := do(http.MethodPost, "/admin/webhooks", `{"url":"https://hooks.example.com/x","events":["run.failed"]}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var created webhook.Subscription
+	_ = json.Unmarshal(rr.Body.Bytes(), &created)
+	if created.ID == "" || created.Secret == "" {
+		t.Fatalf("expected id and secret on create, got %+v", created)
+	}
+
+	rr = do(http.MethodGet, "/admin/webhooks", "")
+	if strings.Contains(rr.Body.String(), created.Secret) || !strings.Contains(rr.Body.String(), created.ID) {
+		t.Fatalf("expected the listing without the secret, got %s", rr.Body.String())
+	}
+	rr = do(http.MethodPut, "/admin/webhooks/"+created.ID, `{"events":["run.failed","tool.gap_detected"]}`)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "tool.gap_detected") {
+		t.Fatalf("expected the update applied, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := do(http.MethodGet, "/admin/webhooks/"+created.ID+"/deliveries", ""); rr.Code != http.StatusOK {
+		t.Fatalf("expected deliveries listing, got %d", rr.Code)
+	}
+	if rr := do(http.MethodDelete, "/admin/webhooks/"+created.ID, ""); rr.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", rr.Code)
+	}
+	if rr := do(http.MethodGet, "/admin/webhooks/"+created.ID, ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 after delete, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,100 @@
+package gateway_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	. "ccgateway/internal/gateway"
+	"ccgateway/internal/orchestrator"
+	"ccgateway/internal/settings"
+	"ccgateway/internal/upstream"
+)
+
+// queuedStreamService reports a queue wait before streaming its answer.
+type queuedStreamService struct {
+	captureService
+}
+
+func (s *queuedStreamService) Stream(_ context.Context, req orchestrator.Request) (<-chan orchestrator.StreamEvent, <-chan error) {
+	s.capturedReq = req
+	events := make(chan orchestrator.StreamEvent, 8)
+	errs := make(chan error)
+	events <- orchestrator.StreamEvent{Type: "ping", Queue: &orchestrator.QueueInfo{Adapter: "slow", Depth: 3, Waited: 1500 * time.Millisecond}}
+	events <- orchestrator.StreamEvent{Type: "message_start"}
+	events <- orchestrator.StreamEvent{Type: "content_block_start", Block: orchestrator.AssistantBlock{Type: "text"}}
+	events <- orchestrator.StreamEvent{Type: "content_block_delta", DeltaText: "ok"}
+	events <- orchestrator.StreamEvent{Type: "content_block_stop"}
+	events <- orchestrator.StreamEvent{Type: "message_delta", StopReason: "end_turn"}
+	events <- orchestrator.StreamEvent{Type: "message_stop"}
+	close(events)
+	close(errs)
+	return events, errs
+}
+
+func TestQueuedStreamWritesPingAndCarriesPriority(t *testing.T) {
+	svc := &queuedStreamService{}
+	router := newTestRouterWithDeps(t, Dependencies{Orchestrator: svc, Settings: settings.NewStore(settings.DefaultRuntimeSettings())})
+
+	body := `{"model":"claude-test","max_tokens":64,"stream":true,"messages":[{"role":"user","content":"hi"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(body))
+	req.Header.Set("anthropic-version", "2023-06-01")
+	req.Header.Set("x-cc-priority", "high")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if got := svc.capturedReq.Metadata["admission_priority"]; got != 20 {
+		t.Fatalf("expected the high class priority 20, got %v", got)
+	}
+	out := rr.Body.String()
+	if !strings.HasPrefix(out, "event: ping\ndata: ") || !strings.Contains(out, `"queue":{"adapter":"slow","depth":3,"waited_ms":1500}`) {
+		t.Fatalf("expected a queue ping before the message, got %s", out)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"model":"gpt-test","stream":true,"messages":[{"role":"user","content":"hi"}]}`))
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if !strings.HasPrefix(rr.Body.String(), ": ping queued adapter=slow depth=3 waited_ms=1500\n\n") {
+		t.Fatalf("expected an SSE comment keep-alive, got %s", rr.Body.String())
+	}
+	if got := svc.capturedReq.Metadata["admission_priority"]; got != 10 {
+		t.Fatalf("expected the default priority 10, got %v", got)
+	}
+}
+
+type limitedAdapter struct{}
+
+func (limitedAdapter) Name() string { return "slow" }
+
+func (limitedAdapter) AdminSpec() upstream.AdapterSpec {
+	return upstream.AdapterSpec{Name: "slow", Kind: upstream.AdapterKindCanonical, MaxConcurrency: 2}
+}
+
+func (limitedAdapter) Complete(_ context.Context, req orchestrator.Request) (orchestrator.Response, error) {
+	return orchestrator.Response{Model: req.Model, Blocks: []orchestrator.AssistantBlock{{Type: "text", Text: "ok"}}}, nil
+}
+
+func TestAdminStatusReportsAdmissionQueues(t *testing.T) {
+	svc := upstream.NewRouterService(upstream.RouterConfig{DefaultRoute: []string{"slow"}}, []upstream.Adapter{limitedAdapter{}})
+	router := newTestRouterWithDeps(t, Dependencies{Orchestrator: svc, AdminToken: "secret-admin"})
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/status", nil)
+	req.Header.Set("authorization", "Bearer secret-admin")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	var body struct {
+		Admission []upstream.AdmissionStatus `json:"admission"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode status: %v", err)
+	}
+	if len(body.Admission) != 1 || body.Admission[0].Adapter != "slow" || body.Admission[0].MaxConcurrency != 2 {
+		t.Fatalf("expected the slow adapter's queue, got %+v", body.Admission)
+	}
+}
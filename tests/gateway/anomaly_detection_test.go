@@ -0,0 +1,70 @@
+package gateway_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ccgateway/internal/anomaly"
+	"ccgateway/internal/ccevent"
+	. "ccgateway/internal/gateway"
+	"ccgateway/internal/upstream"
+)
+
+func TestAnomalyDetectedEventAndStatus(t *testing.T) {
+	cfg := anomaly.DefaultConfig()
+	cfg.WarmupBuckets = 3
+	cfg.MinRequests = 5
+	detector := anomaly.NewDetector(cfg)
+	// Learn a clean baseline a few minutes back, then leave a failing
+	// bucket open for the next live call to close.
+	start := time.Now().Add(-10 * time.Minute)
+	for m := 0; m < 4; m++ {
+		for i := 0; i < 10; i++ {
+			detector.Observe("flaky", m == 3, start.Add(time.Duration(m)*time.Minute))
+		}
+	}
+
+	events := ccevent.NewStore()
+	svc := upstream.NewRouterService(upstream.RouterConfig{DefaultRoute: []string{"flaky"}}, []upstream.Adapter{&flakyAdapter{}})
+	router := newTestRouterWithDeps(t, Dependencies{
+		Orchestrator: svc,
+		AdminToken:   "secret-admin",
+		EventStore:   events,
+		Anomalies:    detector,
+	})
+	body := `{"model":"claude-test","max_tokens":64,"messages":[{"role":"user","content":"hi"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(body))
+	req.Header.Set("anthropic-version", "2023-06-01")
+	req.Header.Set("authorization", "Bearer secret-admin")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	found := events.List(ccevent.ListFilter{EventType: "anomaly.detected"})
+	if len(found) != 1 || found[0].Data["adapter"] != "flaky" || found[0].Data["kind"] != "error_rate" {
+		t.Fatalf("expected one anomaly.detected event, got %+v", found)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/admin/status", nil)
+	req.Header.Set("authorization", "Bearer secret-admin")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status: %d %s", rr.Code, rr.Body.String())
+	}
+	var status struct {
+		Anomalies anomaly.Status `json:"anomalies"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &status); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(status.Anomalies.Active) != 1 || status.Anomalies.Active[0].Errors != 10 {
+		t.Fatalf("expected the finding in status, got %+v", status.Anomalies)
+	}
+}
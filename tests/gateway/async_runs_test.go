@@ -0,0 +1,192 @@
+package gateway_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ccgateway/internal/ccevent"
+	"ccgateway/internal/ccrun"
+	. "ccgateway/internal/gateway"
+	"ccgateway/internal/orchestrator"
+)
+
+// gatedService blocks each completion until release is closed or the
+// request context ends.
+type gatedService struct {
+	captureService
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s *gatedService) Complete(ctx context.Context, req orchestrator.Request) (orchestrator.Response, error) {
+	s.started <- struct{}{}
+	select {
+	case <-s.release:
+		return orchestrator.Response{Model: req.Model, Blocks: []orchestrator.AssistantBlock{{Type: "text", Text: "done in background"}}, StopReason: "end_turn"}, nil
+	case <-ctx.Done():
+		return orchestrator.Response{}, ctx.Err()
+	}
+}
+
+type asyncRunPoll struct {
+	ID     string       `json:"id"`
+	Status ccrun.Status `json:"status"`
+	Async  struct {
+		Status     string          `json:"status"`
+		StatusCode int             `json:"status_code"`
+		Result     json.RawMessage `json:"result"`
+	} `json:"async"`
+}
+
+func newAsyncRouter(t *testing.T) (http.Handler, *gatedService, *ccrun.Store, *ccevent.Store) {
+	t.Helper()
+	svc := &gatedService{started: make(chan struct{}, 4), release: make(chan struct{})}
+	runs := ccrun.NewStore()
+	events := ccevent.NewStore()
+	router := newTestRouterWithDeps(t, Dependencies{
+		Orchestrator: svc,
+		RunStore:     runs,
+		EventStore:   events,
+		AsyncRuns:    AsyncRunConfig{Workers: 1},
+	})
+	return router, svc, runs, events
+}
+
+func submitAsyncMessage(t *testing.T, router http.Handler, body string) (int, string) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages?async=true", strings.NewReader(body))
+	req.Header.Set("anthropic-version", "2023-06-01")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	var out struct {
+		ID string `json:"id"`
+	}
+	_ = json.Unmarshal(rr.Body.Bytes(), &out)
+	return rr.Code, out.ID
+}
+
+func pollAsyncRun(t *testing.T, router http.Handler, method, id string) (int, asyncRunPoll) {
+	t.Helper()
+	req := httptest.NewRequest(method, "/v1/cc/runs/"+id, nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	var out asyncRunPoll
+	_ = json.Unmarshal(rr.Body.Bytes(), &out)
+	return rr.Code, out
+}
+
+func waitAsyncStatus(t *testing.T, router http.Handler, id, want string) asyncRunPoll {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		_, out := pollAsyncRun(t, router, http.MethodGet, id)
+		if out.Async.Status == want {
+			return out
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("run %s never reached %q, last %+v", id, want, out)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+const asyncMessageBody = `{"model":"claude-test","max_tokens":64,"messages":[{"role":"user","content":"long job"}]}`
+
+func TestAsyncMessagesRunInBackgroundAndArePollable(t *testing.T) {
+	router, svc, runs, events := newAsyncRouter(t)
+
+	code, id := submitAsyncMessage(t, router, asyncMessageBody)
+	if code != http.StatusAccepted || id == "" {
+		t.Fatalf("expected 202 with a run id, got %d %q", code, id)
+	}
+	<-svc.started
+	running := waitAsyncStatus(t, router, id, "running")
+	if running.ID != id || running.Status != ccrun.StatusRunning {
+		t.Fatalf("expected the run record under the reserved id, got %+v", running)
+	}
+
+	close(svc.release)
+	done := waitAsyncStatus(t, router, id, "completed")
+	if done.Async.StatusCode != http.StatusOK || !strings.Contains(string(done.Async.Result), "done in background") {
+		t.Fatalf("expected the message in the result, got %+v", done.Async)
+	}
+	if run, ok := runs.Get(id); !ok || run.Status != ccrun.StatusCompleted {
+		t.Fatalf("expected completed run record, got %+v", run)
+	}
+	if got := events.List(ccevent.ListFilter{EventType: "run.queued", RunID: id}); len(got) != 1 {
+		t.Fatalf("expected run.queued event, got %+v", got)
+	}
+
+	if code, _ := pollAsyncRun(t, router, http.MethodDelete, id); code != http.StatusConflict {
+		t.Fatalf("expected 409 cancelling a finished run, got %d", code)
+	}
+}
+
+func TestAsyncMessagesCanBeCanceled(t *testing.T) {
+	router, svc, runs, _ := newAsyncRouter(t)
+
+	_, running := submitAsyncMessage(t, router, asyncMessageBody)
+	_, queued := submitAsyncMessage(t, router, asyncMessageBody)
+	<-svc.started
+
+	code, out := pollAsyncRun(t, router, http.MethodDelete, queued)
+	if code != http.StatusOK || out.Async.Status != "canceled" || out.Status != "canceled" {
+		t.Fatalf("expected queued run canceled, got %d %+v", code, out)
+	}
+
+	if code, _ := pollAsyncRun(t, router, http.MethodDelete, running); code != http.StatusOK {
+		t.Fatalf("expected 200 cancelling a running run, got %d", code)
+	}
+	waitAsyncStatus(t, router, running, "canceled")
+	if run, _ := runs.Get(running); run.Status != ccrun.StatusCanceled {
+		t.Fatalf("expected run record canceled, got %+v", run)
+	}
+	if _, ok := runs.Get(queued); ok {
+		t.Fatalf("a run canceled while queued should never start")
+	}
+}
+
+func TestAsyncMessagesRejectStreaming(t *testing.T) {
+	router, _, _, _ := newAsyncRouter(t)
+	code, _ := submitAsyncMessage(t, router, `{"model":"claude-test","max_tokens":64,"stream":true,"messages":[{"role":"user","content":"x"}]}`)
+	if code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for async streaming, got %d", code)
+	}
+}
+
+func TestAsyncRunsAreHiddenFromOtherProjects(t *testing.T) {
+	router, svc, _, _ := newAsyncRouter(t)
+	call := func(method, path, project, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("anthropic-version", "2023-06-01")
+		req.Header.Set("x-cc-project", project)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := call(http.MethodPost, "/v1/messages?async=true", "acme", asyncMessageBody)
+	var submitted struct {
+		ID string `json:"id"`
+	}
+	_ = json.Unmarshal(rr.Body.Bytes(), &submitted)
+	if rr.Code != http.StatusAccepted || submitted.ID == "" {
+		t.Fatalf("expected 202 with a run id, got %d: %s", rr.Code, rr.Body.String())
+	}
+	<-svc.started
+
+	for _, method := range []string{http.MethodGet, http.MethodDelete} {
+		if rr := call(method, "/v1/cc/runs/"+submitted.ID, "globex", ""); rr.Code != http.StatusNotFound {
+			t.Fatalf("expected 404 for %s from another project, got %d: %s", method, rr.Code, rr.Body.String())
+		}
+	}
+	if rr := call(http.MethodGet, "/v1/cc/runs/"+submitted.ID, "acme", ""); rr.Code != http.StatusOK {
+		t.Fatalf("expected the submitting project to see its run, got %d: %s", rr.Code, rr.Body.String())
+	}
+	close(svc.release)
+}
@@ -0,0 +1,130 @@
+package gateway_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ccgateway/internal/batch"
+	"ccgateway/internal/ccrun"
+	. "ccgateway/internal/gateway"
+	"ccgateway/internal/orchestrator"
+)
+
+func batchRequest(t *testing.T, router http.Handler, method, path, contentType string, body []byte) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer secret-admin")
+	if contentType != "" {
+		req.Header.Set("content-type", contentType)
+	}
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestBatchUploadRunAndDownloadResults(t *testing.T) {
+	batches := batch.NewManager(batch.Config{})
+	runs := ccrun.NewStore()
+	router := newTestRouterWithDeps(t, Dependencies{
+		Orchestrator: orchestrator.NewSimpleService(),
+		RunStore:     runs,
+		Batches:      batches,
+		AdminToken:   "secret-admin",
+	})
+
+	input := strings.Join([]string{
+		`{"custom_id":"q1","method":"POST","url":"/v1/messages","body":{"model":"claude-test","max_tokens":32,"messages":[{"role":"user","content":"one"}]}}`,
+		`{"custom_id":"q2","method":"POST","url":"/v1/messages","body":{"model":"claude-test","max_tokens":32,"messages":[{"role":"user","content":"two"}]}}`,
+		`{"custom_id":"q3","method":"POST","url":"/v1/messages","body":{"model":"claude-test","messages":[]}}`,
+	}, "\n")
+	var form bytes.Buffer
+	mw := multipart.NewWriter(&form)
+	part, _ := mw.CreateFormFile("file", "requests.jsonl")
+	_, _ = part.Write([]byte(input))
+	_ = mw.WriteField("endpoint", "/v1/messages")
+	_ = mw.WriteField("metadata", `{"job":"eval"}`)
+	_ = mw.Close()
+
+	rr := batchRequest(t, router, http.MethodPost, "/v1/batches", mw.FormDataContentType(), form.Bytes())
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d; body=%s", rr.Code, rr.Body.String())
+	}
+	var created batch.Batch
+	_ = json.Unmarshal(rr.Body.Bytes(), &created)
+	if created.Status != batch.StatusInProgress || created.RequestCounts.Total != 3 || created.Metadata["job"] != "eval" {
+		t.Fatalf("unexpected batch %s", rr.Body.String())
+	}
+	batches.Wait()
+
+	rr = batchRequest(t, router, http.MethodGet, "/v1/batches/"+created.ID, "", nil)
+	var done batch.Batch
+	_ = json.Unmarshal(rr.Body.Bytes(), &done)
+	if done.Status != batch.StatusCompleted || done.RequestCounts.Completed != 2 || done.RequestCounts.Failed != 1 {
+		t.Fatalf("unexpected finished batch %s", rr.Body.String())
+	}
+
+	rr = batchRequest(t, router, http.MethodGet, "/v1/batches/"+created.ID+"/output", "", nil)
+	if rr.Code != http.StatusOK || rr.Header().Get("content-type") != "application/jsonl" {
+		t.Fatalf("expected jsonl output, got %d %q", rr.Code, rr.Header().Get("content-type"))
+	}
+	lines := strings.Split(strings.TrimSpace(rr.Body.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 output lines, got %s", rr.Body.String())
+	}
+	var result batch.ResultLine
+	_ = json.Unmarshal([]byte(lines[0]), &result)
+	if result.Response == nil || result.Response.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected output line %s", lines[0])
+	}
+	if run, ok := runs.Get(result.Response.RequestID); !ok || run.Path != "/v1/messages" {
+		t.Fatalf("expected the line recorded as a run, got %+v", run)
+	}
+
+	rr = batchRequest(t, router, http.MethodGet, "/v1/batches/"+created.ID+"/errors", "", nil)
+	if !strings.Contains(rr.Body.String(), `"custom_id":"q3"`) {
+		t.Fatalf("expected q3 in the error file, got %s", rr.Body.String())
+	}
+
+	rr = batchRequest(t, router, http.MethodGet, "/v1/batches?limit=1", "", nil)
+	var list struct {
+		Object  string        `json:"object"`
+		Data    []batch.Batch `json:"data"`
+		FirstID string        `json:"first_id"`
+		HasMore bool          `json:"has_more"`
+	}
+	_ = json.Unmarshal(rr.Body.Bytes(), &list)
+	if list.Object != "list" || len(list.Data) != 1 || list.FirstID != created.ID || list.HasMore {
+		t.Fatalf("unexpected list %s", rr.Body.String())
+	}
+
+	if rr := batchRequest(t, router, http.MethodPost, "/v1/batches/"+created.ID+"/cancel", "", nil); rr.Code != http.StatusConflict {
+		t.Fatalf("expected 409 cancelling a finished batch, got %d", rr.Code)
+	}
+}
+
+func TestBatchCreateFromInlineRequests(t *testing.T) {
+	batches := batch.NewManager(batch.Config{})
+	router := newTestRouterWithDeps(t, Dependencies{
+		Orchestrator: orchestrator.NewSimpleService(),
+		Batches:      batches,
+		AdminToken:   "secret-admin",
+	})
+
+	body := `{"endpoint":"/v1/chat/completions","requests":[{"custom_id":"a","method":"POST","url":"/v1/responses","body":{"model":"gpt-test"}}]}`
+	rr := batchRequest(t, router, http.MethodPost, "/v1/batches", "application/json", []byte(body))
+	var b batch.Batch
+	_ = json.Unmarshal(rr.Body.Bytes(), &b)
+	if rr.Code != http.StatusOK || b.Status != batch.StatusFailed || b.Errors == nil || b.Errors.Data[0].Code != "mismatched_url" {
+		t.Fatalf("expected a failed batch with validation errors, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	rr = batchRequest(t, router, http.MethodPost, "/v1/batches?endpoint=/v1/embeddings", "application/jsonl", []byte(`{}`))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unsupported endpoint, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,45 @@
+package gateway_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "ccgateway/internal/gateway"
+)
+
+func TestMessagesTranslatesBuiltinToolDeclarations(t *testing.T) {
+	svc := &captureService{}
+	router := newTestRouterWithDeps(t, Dependencies{Orchestrator: svc})
+	body := `{"model":"claude-test","max_tokens":64,"messages":[{"role":"user","content":"list files"}],"tools":[` +
+		`{"type":"bash_20250124","name":"bash"},` +
+		`{"type":"computer_20250124","name":"computer","display_width_px":1024,"display_height_px":768}]}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(body))
+	req.Header.Set("anthropic-version", "2023-06-01")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	tools := svc.capturedReq.Tools
+	if len(tools) != 2 {
+		t.Fatalf("expected two tools, got %#v", tools)
+	}
+	bash := tools[0]
+	if bash.Type != "bash_20250124" || bash.Description == "" {
+		t.Fatalf("expected the bash type kept with a description, got %#v", bash)
+	}
+	props, _ := bash.InputSchema["properties"].(map[string]any)
+	if _, ok := props["command"]; !ok {
+		t.Fatalf("expected a bash schema with a command property, got %#v", bash.InputSchema)
+	}
+	computer := tools[1]
+	if computer.Options["display_width_px"] != 1024 || computer.Options["display_height_px"] != 768 {
+		t.Fatalf("expected display options kept, got %#v", computer.Options)
+	}
+	if !strings.Contains(computer.Description, "1024x768") {
+		t.Fatalf("expected the display size in the description, got %q", computer.Description)
+	}
+}
@@ -0,0 +1,82 @@
+package gateway_test
+
+import (
+	. "ccgateway/internal/gateway"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"ccgateway/internal/settings"
+	"ccgateway/internal/token"
+)
+
+func TestCanaryStickyUserCohorts(t *testing.T) {
+	tokenSvc := token.NewInMemoryService()
+	tokens := map[string]string{}
+	for i := 0; i < 20; i++ {
+		userID := fmt.Sprintf("user-%02d", i)
+		tk, err := tokenSvc.Generate(userID, 1000000)
+		if err != nil {
+			t.Fatalf("generate token: %v", err)
+		}
+		tokens[userID] = tk.Value
+	}
+	cfg := settings.DefaultRuntimeSettings()
+	cfg.Routing.Canary = settings.CanarySettings{
+		Enabled:  true,
+		Name:     "new-provider",
+		Percent:  50,
+		Route:    []string{"canary-adapter"},
+		StickyBy: settings.CanaryStickyUser,
+		Users:    []string{"user-00"},
+	}
+	svc := &captureService{}
+	router := newTestRouterWithDeps(t, Dependencies{
+		Orchestrator: svc,
+		Settings:     settings.NewStore(cfg),
+		TokenService: tokenSvc,
+	})
+
+	send := func(value string) (string, string, any) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(`{"model":"claude-test","max_tokens":16,"messages":[{"role":"user","content":"hi"}]}`))
+		req.Header.Set("anthropic-version", "2023-06-01")
+		req.Header.Set("authorization", "Bearer "+value)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d; body=%s", rr.Code, rr.Body.String())
+		}
+		return rr.Header().Get("x-cc-canary-variant"), svc.capturedReq.Metadata["canary_cohort"].(string), svc.capturedReq.Metadata["routing_adapter_route"]
+	}
+
+	counts := map[string]int{}
+	for userID, value := range tokens {
+		first, cohort, route := send(value)
+		for i := 0; i < 3; i++ {
+			if again, _, _ := send(value); again != first {
+				t.Fatalf("user %s switched variant %s -> %s", userID, first, again)
+			}
+		}
+		counts[first]++
+		if userID == "user-00" {
+			if first != "canary" || cohort != "pinned" {
+				t.Fatalf("expected pinned user in canary, got %s/%s", first, cohort)
+			}
+		} else if cohort != settings.CanaryStickyUser {
+			t.Fatalf("expected user cohort, got %q", cohort)
+		}
+		if first == "canary" && !reflect.DeepEqual(route, []string{"canary-adapter"}) {
+			t.Fatalf("expected canary route, got %#v", route)
+		}
+		if first == "control" && route != nil {
+			t.Fatalf("expected default route for control, got %#v", route)
+		}
+	}
+	if counts["canary"] == 0 || counts["control"] == 0 {
+		t.Fatalf("expected both variants across users, got %+v", counts)
+	}
+}
@@ -3,11 +3,14 @@ package gateway_test
 import (
 	. "ccgateway/internal/gateway"
 	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
+	"ccgateway/internal/ccrun"
 	"ccgateway/internal/eval"
 	"ccgateway/internal/modelmap"
 	"ccgateway/internal/orchestrator"
@@ -51,3 +54,54 @@ func TestCCEvalRejectTrailingJSON(t *testing.T) {
 		t.Fatalf("expected 400 for trailing JSON, got %d; body=%s", rr.Code, rr.Body.String())
 	}
 }
+
+func TestAdminRescoreJobScoresStoredRuns(t *testing.T) {
+	runs := ccrun.NewStore()
+	run, _ := runs.Create(ccrun.CreateInput{Path: "/v1/messages", Mode: "chat", ClientModel: "m"})
+	_, _ = runs.Complete(run.ID, ccrun.CompleteInput{StatusCode: 200, Prompt: "p", Output: "o"})
+	router := newTestRouterWithDeps(t, Dependencies{
+		AdminToken: "secret-admin",
+		RunStore:   runs,
+		Evaluator:  eval.NewEvaluator(stubEvalCompleter{}, "judge-model"),
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/runs/rescore", strings.NewReader(`{"mode":"chat"}`))
+	req.Header.Set("authorization", "Bearer secret-admin")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var job eval.RescoreJob
+	if err := json.Unmarshal(rr.Body.Bytes(), &job); err != nil {
+		t.Fatal(err)
+	}
+	if job.Total != 1 || job.JudgeVersion == "" {
+		t.Fatalf("unexpected job: %+v", job)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for job.Status == eval.RescoreStatusRunning && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+		req = httptest.NewRequest(http.MethodGet, "/admin/runs/rescore/"+job.ID, nil)
+		req.Header.Set("authorization", "Bearer secret-admin")
+		rr = httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		_ = json.Unmarshal(rr.Body.Bytes(), &job)
+	}
+	if job.Status != eval.RescoreStatusCompleted || job.Scored != 1 {
+		t.Fatalf("unexpected job: %+v", job)
+	}
+	got, _ := runs.Get(run.ID)
+	if len(got.Scores) != 1 || got.Scores[0].JudgeVersion != job.JudgeVersion || got.Scores[0].Score != 8 {
+		t.Fatalf("unexpected scores: %+v", got.Scores)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/admin/runs/rescore/missing", nil)
+	req.Header.Set("authorization", "Bearer secret-admin")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rr.Code)
+	}
+}
@@ -294,3 +294,49 @@ func TestCCMCPServersProjectScopeIsolation(t *testing.T) {
 		t.Fatalf("expected 404 for wrong project scope, got %d; body=%s", rrGetWrongScope.Code, rrGetWrongScope.Body.String())
 	}
 }
+
+func TestAdminMCPSyncForcesRefreshAndReportsStatus(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"jsonrpc": "2.0",
+			"id":      req["id"],
+			"result":  map[string]any{"tools": []map[string]any{{"name": "lookup"}, {"name": "fetch"}}},
+		})
+	}))
+	defer upstream.Close()
+	registry := mcpregistry.NewStore(upstream.Client())
+	if _, err := registry.Register(mcpregistry.RegisterInput{ID: "sync_srv", Name: "sync", Transport: mcpregistry.TransportHTTP, URL: upstream.URL}); err != nil {
+		t.Fatal(err)
+	}
+	router := newTestRouterWithDeps(t, Dependencies{
+		AdminToken:  "secret-admin",
+		MCPRegistry: registry,
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/mcp/sync", strings.NewReader(`{"server_id":"sync_srv"}`))
+	req.Header.Set("authorization", "Bearer secret-admin")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var body struct {
+		Servers []mcpregistry.ToolSyncStatus `json:"servers"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Servers) != 1 || body.Servers[0].ServerID != "sync_srv" || body.Servers[0].ToolCount != 2 {
+		t.Fatalf("unexpected sync status: %+v", body.Servers)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/admin/mcp/sync", strings.NewReader(`{"server_id":"missing"}`))
+	req.Header.Set("authorization", "Bearer secret-admin")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
@@ -0,0 +1,132 @@
+package gateway_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ccgateway/internal/ccevent"
+	. "ccgateway/internal/gateway"
+	"ccgateway/internal/orchestrator"
+	"ccgateway/internal/plan"
+	"ccgateway/internal/settings"
+	"ccgateway/internal/todo"
+)
+
+type plannerService struct {
+	captureService
+	answer string
+	calls  []orchestrator.Request
+}
+
+func (s *plannerService) Complete(ctx context.Context, req orchestrator.Request) (orchestrator.Response, error) {
+	s.calls = append(s.calls, req)
+	return orchestrator.Response{Model: req.Model, Blocks: []orchestrator.AssistantBlock{{Type: "text", Text: s.answer}}}, nil
+}
+
+func newPlannerRouter(t *testing.T, answer string) (http.Handler, *plannerService, *todo.Store, *ccevent.Store) {
+	t.Helper()
+	cfg := settings.DefaultRuntimeSettings()
+	cfg.ToolLoop.PlannerModel = "planner-model"
+	svc := &plannerService{answer: answer}
+	todos := todo.NewStore()
+	events := ccevent.NewStore()
+	router := newTestRouterWithDeps(t, Dependencies{
+		Orchestrator: svc,
+		Settings:     settings.NewStore(cfg),
+		TodoStore:    todos,
+		PlanStore:    plan.NewStore(),
+		EventStore:   events,
+	})
+	for _, id := range []string{"td_tests", "td_impl", "td_schema"} {
+		if _, err := todos.Create(todo.CreateInput{ID: id, SessionID: "sess_1", Title: strings.TrimPrefix(id, "td_")}); err != nil {
+			t.Fatalf("create todo: %v", err)
+		}
+	}
+	return router, svc, todos, events
+}
+
+func postSynthesizePlan(router http.Handler, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/v1/cc/todos/synthesize-plan", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestTodoSynthesizePlanOrdersStepsAndLinksTodos(t *testing.T) {
+	answer := "```json\n" + `{"title":"Ship feature","summary":"schema first","steps":[
+		{"id":"s1","title":"write tests","todo_id":"td_tests","depends_on":["s2"]},
+		{"id":"s2","title":"implement","todo_id":"td_impl","depends_on":["s3"]},
+		{"id":"s3","title":"migrate schema","todo_id":"td_schema"},
+		{"id":"s4","title":"bogus","todo_id":"td_other"}
+	]}` + "\n```"
+	router, svc, todos, events := newPlannerRouter(t, answer)
+
+	rr := postSynthesizePlan(router, `{"session_id":"sess_1"}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d; body=%s", rr.Code, rr.Body.String())
+	}
+	var out struct {
+		Plan  plan.Plan   `json:"plan"`
+		Todos []todo.Todo `json:"todos"`
+		Model string      `json:"model"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &out); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(svc.calls) != 1 || svc.calls[0].Model != "planner-model" || !strings.Contains(svc.calls[0].Messages[0].Content.(string), "td_schema") {
+		t.Fatalf("expected one planner call listing the todos, got %+v", svc.calls)
+	}
+	var order []string
+	for _, step := range out.Plan.Steps {
+		order = append(order, step.ID)
+	}
+	if strings.Join(order, ",") != "s3,s2,s1,s4" || out.Plan.Steps[3].TodoID != "" {
+		t.Fatalf("expected dependency order with unknown todo dropped, got %+v", out.Plan.Steps)
+	}
+	if out.Plan.Title != "Ship feature" || out.Plan.SessionID != "sess_1" || out.Model != "planner-model" {
+		t.Fatalf("unexpected plan: %+v", out.Plan)
+	}
+
+	if len(out.Todos) != 4 || out.Todos[0].ID != "td_schema" || out.Todos[1].ID != "td_impl" || out.Todos[2].ID != "td_tests" {
+		t.Fatalf("expected linked todos in step order plus one new todo, got %+v", out.Todos)
+	}
+	linked, _ := todos.Get("td_impl")
+	if linked.PlanID != out.Plan.ID || linked.Metadata["step_id"] != "s2" {
+		t.Fatalf("expected todo linked to plan, got %+v", linked)
+	}
+	if got := events.List(ccevent.ListFilter{EventType: "plan.synthesized"}); len(got) != 1 {
+		t.Fatalf("expected plan.synthesized event, got %+v", got)
+	}
+
+	// Linked todos are no longer open candidates for another synthesis.
+	rr = postSynthesizePlan(router, `{"session_id":"sess_1"}`)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 with no open todos, got %d; body=%s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestTodoSynthesizePlanRejectsCyclesAndMissingModel(t *testing.T) {
+	router, _, _, _ := newPlannerRouter(t, `{"steps":[{"id":"a","title":"a","depends_on":["b"]},{"id":"b","title":"b","depends_on":["a"]}]}`)
+	rr := postSynthesizePlan(router, `{"todo_ids":["td_impl"]}`)
+	if rr.Code != http.StatusBadGateway || !strings.Contains(rr.Body.String(), "cyclic") {
+		t.Fatalf("expected 502 for cyclic plan, got %d; body=%s", rr.Code, rr.Body.String())
+	}
+	rr = postSynthesizePlan(router, `{"todo_ids":["td_missing"]}`)
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown todo, got %d", rr.Code)
+	}
+
+	plain := newTestRouterWithDeps(t, Dependencies{
+		Orchestrator: &plannerService{},
+		TodoStore:    todo.NewStore(),
+		PlanStore:    plan.NewStore(),
+	})
+	rr = postSynthesizePlan(plain, `{"session_id":"sess_1"}`)
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "planner model") {
+		t.Fatalf("expected 400 without a planner model, got %d; body=%s", rr.Code, rr.Body.String())
+	}
+}
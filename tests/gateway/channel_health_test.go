@@ -0,0 +1,69 @@
+package gateway_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ccgateway/internal/channel"
+	. "ccgateway/internal/gateway"
+)
+
+func TestChannelHealthSteersRoutingAndIsReported(t *testing.T) {
+	store := channel.NewAbilityStore()
+	healthy := &channel.Channel{Name: "healthy", Models: "claude-test", Group: "default", Status: channel.StatusEnabled, Weight: 10}
+	broken := &channel.Channel{Name: "broken", Models: "claude-test", Group: "default", Status: channel.StatusEnabled, Weight: 10}
+	for _, c := range []*channel.Channel{healthy, broken} {
+		if err := store.AddChannel(c); err != nil {
+			t.Fatalf("add channel: %v", err)
+		}
+	}
+	tracker, err := channel.NewHealthTracker(channel.HealthConfig{Enabled: true, MinFactor: 0, MinSamples: 1, HistoryInterval: "0s"})
+	if err != nil {
+		t.Fatalf("new tracker: %v", err)
+	}
+	tracker.Record(broken, false, time.Second, "probe", "connection refused")
+
+	svc := &captureService{}
+	router := newTestRouterWithDeps(t, Dependencies{
+		Orchestrator:  svc,
+		AdminToken:    "secret-admin",
+		ChannelStore:  store,
+		ChannelHealth: tracker,
+	})
+	for i := 0; i < 5; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(`{"model":"claude-test","max_tokens":16,"messages":[{"role":"user","content":"hi"}]}`))
+		req.Header.Set("anthropic-version", "2023-06-01")
+		req.Header.Set("authorization", "Bearer secret-admin")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		if route, _ := svc.capturedReq.Metadata["routing_adapter_route"].([]string); len(route) != 1 || route[0] != "healthy" {
+			t.Fatalf("expected the healthy channel, got %#v", svc.capturedReq.Metadata["routing_adapter_route"])
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/admin/channels/%d/health", healthy.ID), nil)
+	req.Header.Set("authorization", "Bearer secret-admin")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var out struct {
+		Health   channel.Health `json:"health"`
+		Adaptive bool           `json:"adaptive"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &out); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !out.Adaptive || out.Health.Samples != 5 || out.Health.LastSource != "run" || len(out.Health.History) != 5 || out.Health.EffectiveWeight != 10 {
+		t.Fatalf("unexpected health report: %s", rr.Body.String())
+	}
+}
@@ -0,0 +1,70 @@
+package gateway_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ccgateway/internal/billing"
+	"ccgateway/internal/ccrun"
+	"ccgateway/internal/channel"
+	"ccgateway/internal/costtrack"
+	. "ccgateway/internal/gateway"
+)
+
+func TestUsageBillsServingChannelWithGroupMarkup(t *testing.T) {
+	ledger, err := billing.NewLedger("", map[string]costtrack.ModelPricing{"*": {InputPer1M: 1, OutputPer1M: 1}})
+	if err != nil {
+		t.Fatalf("open ledger: %v", err)
+	}
+	store := channel.NewAbilityStore()
+	ch := &channel.Channel{Name: "priced", Models: "claude-test", Group: "default", Status: channel.StatusEnabled, PromptPricePer1K: 1, CompletionPricePer1K: 2}
+	if err := store.AddChannel(ch); err != nil {
+		t.Fatalf("add channel: %v", err)
+	}
+	runs := ccrun.NewStore()
+	router := newTestRouterWithDeps(t, Dependencies{
+		Orchestrator: &captureService{},
+		AdminToken:   "secret-admin",
+		UsageLedger:  ledger,
+		ChannelStore: store,
+		RunStore:     runs,
+	})
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("authorization", "Bearer secret-admin")
+		req.Header.Set("anthropic-version", "2023-06-01")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := do(http.MethodPut, "/admin/usage/prices", `{"group_ratios":{"default":-1}}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a negative ratio, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := do(http.MethodPut, "/admin/usage/prices", `{"group_ratios":{"default":2}}`); rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"group_ratios":{"default":2}`) {
+		t.Fatalf("expected ratios to be stored, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := do(http.MethodPost, "/v1/messages", `{"model":"claude-test","max_tokens":16,"messages":[{"role":"user","content":"hi"}]}`); rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	rr := do(http.MethodGet, "/admin/usage", "")
+	var out struct {
+		Data []billing.UsageRecord `json:"data"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &out); err != nil || len(out.Data) != 1 {
+		t.Fatalf("unexpected usage list: %s", rr.Body.String())
+	}
+	rec := out.Data[0]
+	if rec.ChannelID != ch.ID || rec.Group != "default" || rec.GroupRatio != 2 || rec.PriceKey != "channel:1" || rec.CostUSD != 0.006 {
+		t.Fatalf("expected channel prices with a 2x markup, got %+v", rec)
+	}
+	list := runs.List(ccrun.ListFilter{})
+	if len(list) != 1 || list[0].CostUSD != 0.006 {
+		t.Fatalf("expected the run record to carry the cost, got %+v", list)
+	}
+}
@@ -0,0 +1,126 @@
+package gateway_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "ccgateway/internal/gateway"
+	"ccgateway/internal/orchestrator"
+	"ccgateway/internal/settings"
+	"ccgateway/internal/toolruntime"
+)
+
+type citingService struct {
+	calls       int
+	finalSystem string
+	toolContent string
+}
+
+func (s *citingService) Complete(_ context.Context, req orchestrator.Request) (orchestrator.Response, error) {
+	s.calls++
+	if s.calls == 1 {
+		return orchestrator.Response{
+			Model:      req.Model,
+			Blocks:     []orchestrator.AssistantBlock{{Type: "tool_use", ID: "toolu_1", Name: "kb_search", Input: map[string]any{"q": "refunds"}}},
+			StopReason: "tool_use",
+		}, nil
+	}
+	s.finalSystem, _ = req.System.(string)
+	last := req.Messages[len(req.Messages)-1]
+	if blocks, ok := last.Content.([]any); ok {
+		for _, item := range blocks {
+			if block, ok := item.(map[string]any); ok && block["type"] == "tool_result" {
+				s.toolContent, _ = block["content"].(string)
+			}
+		}
+	}
+	return orchestrator.Response{
+		Model:      req.Model,
+		Blocks:     []orchestrator.AssistantBlock{{Type: "text", Text: "Refunds take 5 days [S2]. Contact support first [S1][S2]. See also [S9]."}},
+		StopReason: "end_turn",
+	}, nil
+}
+
+func (s *citingService) Stream(context.Context, orchestrator.Request) (<-chan orchestrator.StreamEvent, <-chan error) {
+	events := make(chan orchestrator.StreamEvent)
+	errs := make(chan error)
+	close(events)
+	close(errs)
+	return events, errs
+}
+
+func TestServerToolLoopReturnsInlineCitations(t *testing.T) {
+	registry := toolruntime.NewRegistry()
+	registry.Register("kb_search", func(context.Context, toolruntime.Call) (toolruntime.Result, error) {
+		policy := "Contact support before requesting a refund. " + strings.Repeat("Keep the receipt. ", 45)
+		return toolruntime.Result{Content: policy + "\n\nRefunds are processed within 5 business days."}, nil
+	})
+	cfg := settings.DefaultRuntimeSettings()
+	cfg.ToolLoop.Mode = "server_loop"
+	cfg.ToolLoop.Citations = true
+	cfg.Routing.ReflectionPasses = -1
+	svc := &citingService{}
+	router := newTestRouterWithDeps(t, Dependencies{
+		Orchestrator: svc,
+		Settings:     settings.NewStore(cfg),
+		ToolExecutor: registry,
+	})
+
+	body := `{"model":"claude-test","max_tokens":128,"messages":[{"role":"user","content":"how do refunds work?"}],"tools":[{"name":"kb_search","input_schema":{"type":"object"}}]}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(body))
+	req.Header.Set("anthropic-version", "2023-06-01")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d; body=%s", rr.Code, rr.Body.String())
+	}
+	if !strings.Contains(svc.finalSystem, "[CC_CITATIONS]") {
+		t.Fatalf("expected citation instruction in synthesis prompt, got %q", svc.finalSystem)
+	}
+	if !strings.HasPrefix(svc.toolContent, "[S1] Contact support") || !strings.Contains(svc.toolContent, "\n\n[S2] Refunds are processed") {
+		t.Fatalf("expected labelled tool result, got %q", svc.toolContent)
+	}
+
+	var resp MessageResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.Metadata == nil || len(resp.Metadata.Citations) != 2 {
+		t.Fatalf("expected two cited sources, got %+v", resp.Metadata)
+	}
+	first, second := resp.Metadata.Citations[0], resp.Metadata.Citations[1]
+	if first.SourceID != "S2" || first.ToolUseID != "toolu_1" || first.ToolName != "kb_search" || first.Chunk != 1 || first.Mentions != 2 || first.Text != "Refunds are processed within 5 business days." {
+		t.Fatalf("unexpected first citation: %+v", first)
+	}
+	if second.SourceID != "S1" || second.Chunk != 0 || second.Mentions != 1 || !strings.HasSuffix(second.Text, "...") {
+		t.Fatalf("unexpected second citation: %+v", second)
+	}
+}
+
+func TestCitationsStayOffByDefault(t *testing.T) {
+	cfg := settings.DefaultRuntimeSettings()
+	cfg.ToolLoop.Mode = "server_loop"
+	cfg.Routing.ReflectionPasses = -1
+	svc := &citingService{}
+	registry := toolruntime.NewRegistry()
+	registry.Register("kb_search", func(context.Context, toolruntime.Call) (toolruntime.Result, error) {
+		return toolruntime.Result{Content: "plain"}, nil
+	})
+	router := newTestRouterWithDeps(t, Dependencies{Orchestrator: svc, Settings: settings.NewStore(cfg), ToolExecutor: registry})
+
+	body := `{"model":"claude-test","max_tokens":128,"messages":[{"role":"user","content":"q"}],"tools":[{"name":"kb_search","input_schema":{"type":"object"}}]}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(body))
+	req.Header.Set("anthropic-version", "2023-06-01")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d; body=%s", rr.Code, rr.Body.String())
+	}
+	if svc.toolContent != "plain" || strings.Contains(rr.Body.String(), `"citations"`) {
+		t.Fatalf("citations should be off by default: tool=%q body=%s", svc.toolContent, rr.Body.String())
+	}
+}
@@ -0,0 +1,126 @@
+package gateway_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ccgateway/internal/ccevent"
+	. "ccgateway/internal/gateway"
+	"ccgateway/internal/orchestrator"
+	"ccgateway/internal/settings"
+)
+
+type compactionService struct {
+	captureService
+	summaryCalls []orchestrator.Request
+}
+
+func (s *compactionService) Complete(ctx context.Context, req orchestrator.Request) (orchestrator.Response, error) {
+	if req.Metadata["purpose"] == "context_compaction" {
+		s.summaryCalls = append(s.summaryCalls, req)
+		return orchestrator.Response{Model: req.Model, Blocks: []orchestrator.AssistantBlock{{Type: "text", Text: "User asked about the deploy script."}}}, nil
+	}
+	return s.captureService.Complete(ctx, req)
+}
+
+func newCompactionRouter(t *testing.T, modeStrategies map[string][]string) (http.Handler, *compactionService, *ccevent.Store) {
+	t.Helper()
+	cfg := settings.DefaultRuntimeSettings()
+	cfg.Routing.ReflectionPasses = -1
+	cfg.Compaction = settings.CompactionSettings{
+		Enabled:         true,
+		ContextWindows:  map[string]int{"claude-*": 300},
+		Strategies:      []string{settings.CompactDropToolResults, settings.CompactSummarize},
+		ModeStrategies:  modeStrategies,
+		SummarizerModel: "cheap-model",
+		KeepRecent:      2,
+	}
+	svc := &compactionService{}
+	events := ccevent.NewStore()
+	router := newTestRouterWithDeps(t, Dependencies{
+		Orchestrator: svc,
+		Settings:     settings.NewStore(cfg),
+		EventStore:   events,
+	})
+	return router, svc, events
+}
+
+func postCompactionRequest(t *testing.T, router http.Handler, body string) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(body))
+	req.Header.Set("anthropic-version", "2023-06-01")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d; body=%s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestContextCompactionDropsOldToolResults(t *testing.T) {
+	router, svc, events := newCompactionRouter(t, nil)
+	postCompactionRequest(t, router, `{"model":"claude-test","max_tokens":16,"messages":[{"role":"user","content":"hi"}]}`)
+	if len(events.List(ccevent.ListFilter{EventType: "context.compaction_applied"})) != 0 {
+		t.Fatalf("small requests should not be compacted")
+	}
+
+	big := strings.Repeat("log line ", 250)
+	postCompactionRequest(t, router, `{"model":"claude-test","max_tokens":16,"messages":[
+		{"role":"user","content":"run the tests"},
+		{"role":"assistant","content":[{"type":"tool_use","id":"t1","name":"bash","input":{"cmd":"go test"}}]},
+		{"role":"user","content":[{"type":"tool_result","tool_use_id":"t1","content":"`+big+`"}]},
+		{"role":"assistant","content":"tests pass"},
+		{"role":"user","content":"thanks"}]}`)
+
+	msgs := svc.capturedReq.Messages
+	if len(msgs) != 5 {
+		t.Fatalf("expected the history shape to be kept, got %d messages", len(msgs))
+	}
+	block := msgs[2].Content.([]any)[0].(map[string]any)
+	if block["tool_use_id"] != "t1" || strings.Contains(block["content"].(string), "log line") {
+		t.Fatalf("expected the tool result to be blanked, got %+v", block)
+	}
+	if len(svc.summaryCalls) != 0 || svc.capturedReq.Metadata["context_compacted"] != true {
+		t.Fatalf("expected compaction without a summary, calls=%d meta=%v", len(svc.summaryCalls), svc.capturedReq.Metadata)
+	}
+	list := events.List(ccevent.ListFilter{EventType: "context.compaction_applied"})
+	if len(list) != 1 {
+		t.Fatalf("expected one compaction event, got %d", len(list))
+	}
+	data := list[0].Data
+	if data["context_window"] != 300 || data["dropped_tool_results"] != 1 || data["fits"] != true {
+		t.Fatalf("unexpected event data: %+v", data)
+	}
+}
+
+func TestContextCompactionSummarizesOldTurnsPerMode(t *testing.T) {
+	router, svc, events := newCompactionRouter(t, map[string][]string{"chat": {settings.CompactSummarize}})
+	turn := strings.Repeat("words ", 80)
+	postCompactionRequest(t, router, `{"model":"claude-test","max_tokens":16,"messages":[
+		{"role":"user","content":"`+turn+`"},
+		{"role":"assistant","content":"`+turn+`"},
+		{"role":"user","content":"`+turn+`"},
+		{"role":"assistant","content":"`+turn+`"},
+		{"role":"user","content":"second to last"},
+		{"role":"assistant","content":"sure"},
+		{"role":"user","content":"latest question"}]}`)
+
+	if len(svc.summaryCalls) != 1 || svc.summaryCalls[0].Model != "cheap-model" {
+		t.Fatalf("expected one summary call on the cheap model, got %+v", svc.summaryCalls)
+	}
+	msgs := svc.capturedReq.Messages
+	if len(msgs) != 3 || msgs[0].Role != "user" {
+		t.Fatalf("expected the last user turn onwards to be kept, got %+v", msgs)
+	}
+	first := msgs[0].Content.([]any)
+	note, _ := first[0].(map[string]any)["text"].(string)
+	if !strings.Contains(note, "User asked about the deploy script.") {
+		t.Fatalf("expected the summary to lead the kept history, got %q", note)
+	}
+	list := events.List(ccevent.ListFilter{EventType: "context.compaction_applied"})
+	if len(list) != 1 || list[0].Data["summarized_messages"] != 4 {
+		t.Fatalf("unexpected compaction events: %+v", list)
+	}
+}
@@ -0,0 +1,67 @@
+package gateway_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ccgateway/internal/ccrun"
+	. "ccgateway/internal/gateway"
+	"ccgateway/internal/idgen"
+)
+
+func TestCorrelationIDIsStoredEchoedAndSearchable(t *testing.T) {
+	runStore := ccrun.NewStore()
+	router := newTestRouterWithDeps(t, Dependencies{RunStore: runStore})
+	send := func(path, body, correlationID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("anthropic-version", "2023-06-01")
+		req.Header.Set("x-correlation-id", correlationID)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := send("/v1/messages", `{"model":"claude-test","max_tokens":32,"messages":[{"role":"user","content":"hi"}]}`, "client-trace:42")
+	if rr.Code != http.StatusOK || rr.Header().Get("x-correlation-id") != "client-trace:42" {
+		t.Fatalf("expected correlation id echoed, got %d %q", rr.Code, rr.Header().Get("x-correlation-id"))
+	}
+	runID := rr.Header().Get("x-cc-run-id")
+	if !strings.HasPrefix(runID, "run_") || len(runID) != len("run_")+26 {
+		t.Fatalf("expected a ULID run id, got %q", runID)
+	}
+	send("/v1/chat/completions", `{"model":"claude-test","messages":[{"role":"user","content":"hi"}]}`, "other")
+
+	list := httptest.NewRecorder()
+	router.ServeHTTP(list, httptest.NewRequest(http.MethodGet, "/v1/cc/runs?correlation_id=client-trace:42", nil))
+	var body struct {
+		Data []ccrun.Run `json:"data"`
+	}
+	if err := json.Unmarshal(list.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(body.Data) != 1 || body.Data[0].ID != runID || body.Data[0].CorrelationID != "client-trace:42" {
+		t.Fatalf("unexpected runs for correlation id: %+v", body.Data)
+	}
+
+	if rr := send("/v1/messages", `{"model":"claude-test","max_tokens":32,"messages":[{"role":"user","content":"hi"}]}`, "has space"); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid correlation id, got %d", rr.Code)
+	}
+}
+
+func TestIDGeneratorIsPluggable(t *testing.T) {
+	gen, err := idgen.New(idgen.FormatLegacy)
+	if err != nil {
+		t.Fatalf("legacy: %v", err)
+	}
+	router := newTestRouterWithDeps(t, Dependencies{IDGenerator: gen})
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(`{"model":"claude-test","max_tokens":32,"messages":[{"role":"user","content":"hi"}]}`))
+	req.Header.Set("anthropic-version", "2023-06-01")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if id := rr.Header().Get("x-cc-run-id"); strings.Count(id, "_") != 2 {
+		t.Fatalf("expected legacy run id, got %q", id)
+	}
+}
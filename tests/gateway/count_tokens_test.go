@@ -0,0 +1,59 @@
+package gateway_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "ccgateway/internal/gateway"
+	"ccgateway/internal/tokenizer"
+)
+
+func countTokens(t *testing.T, router http.Handler, body string) (CountTokensResponse, string) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages/count_tokens", strings.NewReader(body))
+	req.Header.Set("anthropic-version", "2023-06-01")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d; body=%s", rr.Code, rr.Body.String())
+	}
+	var resp CountTokensResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("unmarshal response: %v", err)
+	}
+	return resp, rr.Header().Get("x-cc-tokenizer")
+}
+
+func TestCountTokensUsesTokenizerForMappedModel(t *testing.T) {
+	// A byte-level vocabulary with no merges: one token per byte.
+	var ranks strings.Builder
+	for b := 0; b < 256; b++ {
+		fmt.Fprintf(&ranks, "%s %d\n", base64.StdEncoding.EncodeToString([]byte{byte(b)}), b)
+	}
+	bpe, err := tokenizer.LoadTiktoken(strings.NewReader(ranks.String()), "cl100k_base")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	registry := tokenizer.NewRegistry()
+	registry.Register("cl100k_base", bpe)
+	router := newTestRouterWithDeps(t, Dependencies{Tokenizers: registry})
+
+	resp, name := countTokens(t, router, `{"model":"gpt-4","system":"be brief","messages":[
+		{"role":"user","content":"hi"},
+		{"role":"assistant","content":[{"type":"tool_use","id":"t1","name":"ls","input":{}}]},
+		{"role":"user","content":[{"type":"tool_result","tool_use_id":"t1","content":"a.go"}]}]}`)
+	// 3 priming + system 8 + 3x3 framing + "hi" 2 + "ls" 2 + "{}" 2 + "a.go" 4.
+	if name != "cl100k_base" || resp.InputTokens != 30 {
+		t.Fatalf("expected 30 tokens from cl100k_base, got %d from %s", resp.InputTokens, name)
+	}
+
+	resp, name = countTokens(t, router, `{"model":"claude-test","messages":[{"role":"user","content":"hello world"}]}`)
+	if name != "approx:claude" || resp.InputTokens != 8 {
+		t.Fatalf("expected the claude approximation, got %d from %s", resp.InputTokens, name)
+	}
+}
@@ -0,0 +1,87 @@
+package gateway_test
+
+import (
+	. "ccgateway/internal/gateway"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ccgateway/internal/settings"
+)
+
+func TestDegradationLadderAppliesActiveStepsAndRejects(t *testing.T) {
+	cfg := settings.DefaultRuntimeSettings()
+	cfg.Routing.EnableResponseJudge = true
+	cfg.Routing.Degradation = settings.DegradationSettings{
+		Enabled: true,
+		Ladder: []settings.DegradationStep{
+			{Action: settings.DegradeDisableJudge, InFlight: 1},
+			{Action: settings.DegradeDisableReflection, InFlight: 1},
+			{Action: settings.DegradeShrinkMaxTokens, MaxTokens: 8, InFlight: 1},
+			{Action: settings.DegradeCheapModel, Model: "cheap-model", InFlight: 1},
+			{Action: settings.DegradeReject, InFlight: 1000},
+		},
+	}
+	st := settings.NewStore(cfg)
+	svc := &captureService{}
+	router := newTestRouterWithDeps(t, Dependencies{Orchestrator: svc, Settings: st, AdminToken: "secret-admin"})
+
+	send := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(`{"model":"claude-test","max_tokens":512,"messages":[{"role":"user","content":"hi"}]}`))
+		req.Header.Set("anthropic-version", "2023-06-01")
+		req.Header.Set("authorization", "Bearer secret-admin")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := send()
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d; body=%s", rr.Code, rr.Body.String())
+	}
+	if rr.Header().Get("x-cc-degradation-level") != "4" {
+		t.Fatalf("expected level 4, got %q", rr.Header().Get("x-cc-degradation-level"))
+	}
+	if got := rr.Header().Get("x-cc-degradation"); got != "disable_judge,disable_reflection,shrink_max_tokens,cheap_model" {
+		t.Fatalf("unexpected degradation actions %q", got)
+	}
+	if svc.capturedReq.Model != "cheap-model" || svc.capturedReq.MaxTokens != 8 {
+		t.Fatalf("expected cheap model with shrunk max_tokens, got model=%q max_tokens=%d", svc.capturedReq.Model, svc.capturedReq.MaxTokens)
+	}
+	if judge, _ := svc.capturedReq.Metadata["enable_response_judge"].(bool); judge {
+		t.Fatalf("expected judge disabled, metadata=%v", svc.capturedReq.Metadata)
+	}
+
+	cfg.Routing.Degradation.Ladder[4].InFlight = 1
+	st.Put(cfg)
+	rejected := send()
+	if rejected.Code != http.StatusServiceUnavailable || rejected.Header().Get("retry-after") == "" {
+		t.Fatalf("expected 503 with retry-after, got %d; body=%s", rejected.Code, rejected.Body.String())
+	}
+
+	statusReq := httptest.NewRequest(http.MethodGet, "/admin/status", nil)
+	statusReq.Header.Set("authorization", "Bearer secret-admin")
+	statusRR := httptest.NewRecorder()
+	router.ServeHTTP(statusRR, statusReq)
+	var status struct {
+		Degradation struct {
+			Enabled bool           `json:"enabled"`
+			Levels  map[string]int `json:"levels"`
+			Load    struct {
+				Requests int `json:"requests"`
+				Errors   int `json:"errors"`
+			} `json:"load"`
+		} `json:"degradation"`
+	}
+	if err := json.Unmarshal(statusRR.Body.Bytes(), &status); err != nil {
+		t.Fatalf("decode status: %v", err)
+	}
+	if !status.Degradation.Enabled || status.Degradation.Load.Requests != 2 || status.Degradation.Load.Errors != 0 {
+		t.Fatalf("unexpected degradation status: %s", statusRR.Body.String())
+	}
+	if _, ok := status.Degradation.Levels["default"]; !ok {
+		t.Fatalf("expected default ladder level in status: %s", statusRR.Body.String())
+	}
+}
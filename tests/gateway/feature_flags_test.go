@@ -0,0 +1,118 @@
+package gateway_test
+
+import (
+	. "ccgateway/internal/gateway"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ccgateway/internal/featureflag"
+	"ccgateway/internal/settings"
+)
+
+func TestFeatureFlagsDisableSubsystemsPerRequestAndByKillSwitch(t *testing.T) {
+	cfg := settings.DefaultRuntimeSettings()
+	cfg.Routing.ReflectionPasses = 2
+	cfg.Routing.EnableResponseJudge = true
+	cfg.ToolLoop.Mode = "server_loop"
+	svc := &captureService{}
+	flags := featureflag.NewStore()
+	router := newTestRouterWithDeps(t, Dependencies{
+		Orchestrator: svc,
+		Settings:     settings.NewStore(cfg),
+		FeatureFlags: flags,
+		AdminToken:   "secret-admin",
+	})
+
+	send := func(metadata string) map[string]any {
+		t.Helper()
+		body := `{"model":"claude-test","max_tokens":16,"messages":[{"role":"user","content":"hi"}]` + metadata + `}`
+		req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(body))
+		req.Header.Set("anthropic-version", "2023-06-01")
+		req.Header.Set("authorization", "Bearer secret-admin")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d; body=%s", rr.Code, rr.Body.String())
+		}
+		return svc.capturedReq.Metadata
+	}
+
+	meta := send("")
+	if meta["reflection_passes"] != 2 || meta["enable_response_judge"] != true || meta["tool_loop_mode"] != "server_loop" {
+		t.Fatalf("expected settings to pass through with flags on, got %+v", meta)
+	}
+
+	meta = send(`,"metadata":{"feature_flags":{"judge":false,"reflection":"off"}}`)
+	if meta["reflection_passes"] != 0 || meta["enable_response_judge"] != false {
+		t.Fatalf("expected request overrides to disable reflection and judge, got %+v", meta)
+	}
+
+	killReq := httptest.NewRequest(http.MethodPost, "/admin/flags/tool_loop/kill", nil)
+	killReq.Header.Set("authorization", "Bearer secret-admin")
+	killRR := httptest.NewRecorder()
+	router.ServeHTTP(killRR, killReq)
+	if killRR.Code != http.StatusOK {
+		t.Fatalf("expected 200 for kill, got %d; body=%s", killRR.Code, killRR.Body.String())
+	}
+
+	meta = send(`,"metadata":{"feature_flags":{"tool_loop":true}}`)
+	if meta["tool_loop_mode"] != "client_loop" || meta["tool_fallback_mode"] != "off" {
+		t.Fatalf("kill switch must win over request override, got %+v", meta)
+	}
+
+	listReq := httptest.NewRequest(http.MethodGet, "/admin/flags", nil)
+	listReq.Header.Set("authorization", "Bearer secret-admin")
+	listRR := httptest.NewRecorder()
+	router.ServeHTTP(listRR, listReq)
+	var list struct {
+		Data  []featureflag.Flag `json:"data"`
+		Total int                `json:"total"`
+	}
+	if err := json.Unmarshal(listRR.Body.Bytes(), &list); err != nil {
+		t.Fatalf("decode list: %v; body=%s", err, listRR.Body.String())
+	}
+	killed := false
+	for _, f := range list.Data {
+		if f.Name == featureflag.ToolLoop {
+			killed = f.Killed
+		}
+	}
+	if list.Total != len(list.Data) || !killed {
+		t.Fatalf("expected tool_loop killed in list, got %+v", list)
+	}
+}
+
+func TestAdminFlagsCRUD(t *testing.T) {
+	router := newTestRouterWithDeps(t, Dependencies{
+		FeatureFlags: featureflag.NewStore(),
+		AdminToken:   "secret-admin",
+	})
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("authorization", "Bearer secret-admin")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := do(http.MethodPost, "/admin/flags", `{"name":"beta.search","enabled":true}`); rr.Code != http.StatusOK {
+		t.Fatalf("create: %d %s", rr.Code, rr.Body.String())
+	}
+	rr := do(http.MethodPut, "/admin/flags/beta.search", `{"enabled":false}`)
+	var flag featureflag.Flag
+	if err := json.Unmarshal(rr.Body.Bytes(), &flag); err != nil || rr.Code != http.StatusOK || flag.Enabled {
+		t.Fatalf("update: %d %s", rr.Code, rr.Body.String())
+	}
+	if rr := do(http.MethodDelete, "/admin/flags/judge", ""); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected built-in delete to fail, got %d", rr.Code)
+	}
+	if rr := do(http.MethodDelete, "/admin/flags/beta.search", ""); rr.Code != http.StatusNoContent {
+		t.Fatalf("delete: %d %s", rr.Code, rr.Body.String())
+	}
+	if rr := do(http.MethodGet, "/admin/flags/beta.search", ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 after delete, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,152 @@
+package gateway_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ccgateway/internal/batch"
+	"ccgateway/internal/files"
+	. "ccgateway/internal/gateway"
+	"ccgateway/internal/orchestrator"
+)
+
+func uploadTestFile(t *testing.T, router http.Handler, filename, purpose string, data []byte) *httptest.ResponseRecorder {
+	t.Helper()
+	var form bytes.Buffer
+	mw := multipart.NewWriter(&form)
+	part, _ := mw.CreateFormFile("file", filename)
+	_, _ = part.Write(data)
+	if purpose != "" {
+		_ = mw.WriteField("purpose", purpose)
+	}
+	_ = mw.Close()
+	return batchRequest(t, router, http.MethodPost, "/v1/files", mw.FormDataContentType(), form.Bytes())
+}
+
+func newTestFiles(t *testing.T, cfg files.Config) *files.Service {
+	t.Helper()
+	svc, err := files.New(cfg, files.NewMemoryBlobs())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return svc
+}
+
+func TestFilesUploadListGetDelete(t *testing.T) {
+	router := newTestRouterWithDeps(t, Dependencies{
+		Orchestrator: orchestrator.NewSimpleService(),
+		Files:        newTestFiles(t, files.Config{MaxBytes: 64}),
+		AdminToken:   "secret-admin",
+	})
+
+	rr := uploadTestFile(t, router, "notes.txt", "", []byte("remember the milk"))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d; body=%s", rr.Code, rr.Body.String())
+	}
+	var f files.File
+	_ = json.Unmarshal(rr.Body.Bytes(), &f)
+	if !strings.HasPrefix(f.ID, "file-") || f.MimeType != "text/plain" || f.Purpose != "user_data" {
+		t.Fatalf("unexpected file %s", rr.Body.String())
+	}
+
+	if rr := uploadTestFile(t, router, "big.txt", "", bytes.Repeat([]byte("x"), 65)); rr.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413, got %d", rr.Code)
+	}
+	if rr := uploadTestFile(t, router, "tool.exe", "", []byte("MZ\x90\x00")); rr.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("expected 415, got %d", rr.Code)
+	}
+
+	rr = batchRequest(t, router, http.MethodGet, "/v1/files", "", nil)
+	if !strings.Contains(rr.Body.String(), f.ID) {
+		t.Fatalf("expected the file listed, got %s", rr.Body.String())
+	}
+	rr = batchRequest(t, router, http.MethodGet, "/v1/files/"+f.ID+"/content", "", nil)
+	if rr.Body.String() != "remember the milk" || rr.Header().Get("content-type") != "text/plain" {
+		t.Fatalf("unexpected content %q (%s)", rr.Body.String(), rr.Header().Get("content-type"))
+	}
+	if rr := batchRequest(t, router, http.MethodDelete, "/v1/files/"+f.ID, "", nil); rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"deleted":true`) {
+		t.Fatalf("unexpected delete response %d %s", rr.Code, rr.Body.String())
+	}
+	if rr := batchRequest(t, router, http.MethodGet, "/v1/files/"+f.ID, "", nil); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 after delete, got %d", rr.Code)
+	}
+}
+
+func TestMessagesResolveFileReferences(t *testing.T) {
+	svc := &captureService{}
+	router := newTestRouterWithDeps(t, Dependencies{
+		Orchestrator: svc,
+		Files:        newTestFiles(t, files.Config{}),
+		AdminToken:   "secret-admin",
+	})
+	rr := uploadTestFile(t, router, "spec.md", "", []byte("the spec"))
+	var f files.File
+	_ = json.Unmarshal(rr.Body.Bytes(), &f)
+
+	send := func(fileID string) *httptest.ResponseRecorder {
+		body := `{"model":"claude-test","max_tokens":64,"messages":[{"role":"user","content":[{"type":"document","source":{"type":"file","file_id":"` + fileID + `"}},{"type":"text","text":"summarize"}]}]}`
+		req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret-admin")
+		req.Header.Set("anthropic-version", "2023-06-01")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+	if rr := send(f.ID); rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d; body=%s", rr.Code, rr.Body.String())
+	}
+	blocks, _ := svc.capturedReq.Messages[0].Content.([]any)
+	doc, _ := blocks[0].(map[string]any)
+	source, _ := doc["source"].(map[string]any)
+	if source["type"] != "text" || source["data"] != "the spec" || doc["title"] != "spec.md" {
+		t.Fatalf("expected the document inlined, got %#v", doc)
+	}
+
+	if rr := send("file-missing"); rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "file-missing") {
+		t.Fatalf("expected 400 for an unknown file, got %d %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestBatchFromInputFileAndOutputViaFiles(t *testing.T) {
+	batches := batch.NewManager(batch.Config{})
+	router := newTestRouterWithDeps(t, Dependencies{
+		Orchestrator: orchestrator.NewSimpleService(),
+		Files:        newTestFiles(t, files.Config{}),
+		Batches:      batches,
+		AdminToken:   "secret-admin",
+	})
+	input := `{"custom_id":"q1","method":"POST","url":"/v1/messages","body":{"model":"claude-test","max_tokens":32,"messages":[{"role":"user","content":"hi"}]}}`
+	rr := uploadTestFile(t, router, "requests.jsonl", "user_data", []byte(input))
+	var wrongPurpose files.File
+	_ = json.Unmarshal(rr.Body.Bytes(), &wrongPurpose)
+	rr = batchRequest(t, router, http.MethodPost, "/v1/batches", "application/json", []byte(`{"endpoint":"/v1/messages","input_file_id":"`+wrongPurpose.ID+`"}`))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a non-batch file, got %d", rr.Code)
+	}
+
+	rr = uploadTestFile(t, router, "requests.jsonl", "batch", []byte(input))
+	var f files.File
+	_ = json.Unmarshal(rr.Body.Bytes(), &f)
+	rr = batchRequest(t, router, http.MethodPost, "/v1/batches", "application/json", []byte(`{"endpoint":"/v1/messages","input_file_id":"`+f.ID+`"}`))
+	var b batch.Batch
+	_ = json.Unmarshal(rr.Body.Bytes(), &b)
+	if rr.Code != http.StatusOK || b.InputFileID != f.ID || b.RequestCounts.Total != 1 {
+		t.Fatalf("unexpected batch %d %s", rr.Code, rr.Body.String())
+	}
+	batches.Wait()
+
+	rr = batchRequest(t, router, http.MethodGet, "/v1/batches/"+b.ID, "", nil)
+	_ = json.Unmarshal(rr.Body.Bytes(), &b)
+	if b.OutputFileID == nil {
+		t.Fatalf("expected an output file, got %s", rr.Body.String())
+	}
+	rr = batchRequest(t, router, http.MethodGet, "/v1/files/"+*b.OutputFileID+"/content", "", nil)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"custom_id":"q1"`) {
+		t.Fatalf("expected batch output through /v1/files, got %d %s", rr.Code, rr.Body.String())
+	}
+}
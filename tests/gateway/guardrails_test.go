@@ -0,0 +1,185 @@
+package gateway_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ccgateway/internal/ccevent"
+	"ccgateway/internal/ccrun"
+	. "ccgateway/internal/gateway"
+	"ccgateway/internal/guardrail"
+	"ccgateway/internal/orchestrator"
+)
+
+func TestGuardrailsRedactInputAndBlockOutput(t *testing.T) {
+	engine, err := guardrail.New(guardrail.Config{Enabled: true, Rules: []guardrail.Rule{
+		{Name: "pii", Kind: guardrail.KindPII, Action: guardrail.ActionRedact, Stages: []string{"input"}},
+		{Name: "forbidden", Kind: guardrail.KindRegex, Action: guardrail.ActionBlock, Pattern: "(?i)drop table", Stages: []string{"input"}},
+		{Name: "no-ok", Kind: guardrail.KindRegex, Action: guardrail.ActionBlock, Pattern: `^ok$`, Stages: []string{"output"}},
+	}}, nil)
+	if err != nil {
+		t.Fatalf("new engine: %v", err)
+	}
+	events := ccevent.NewStore()
+	svc := &captureService{}
+	router := newTestRouterWithDeps(t, Dependencies{
+		Orchestrator: svc,
+		EventStore:   events,
+		Guardrails:   engine,
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(`{"model":"claude-test","max_tokens":16,"system":"reply to bob@example.com","messages":[{"role":"user","content":[{"type":"text","text":"my card is 4111-1111-1111-1111"}]}]}`))
+	req.Header.Set("anthropic-version", "2023-06-01")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if got, _ := svc.capturedReq.System.(string); got != "reply to [EMAIL]" {
+		t.Fatalf("expected a redacted system prompt, got %#v", svc.capturedReq.System)
+	}
+	blocks, _ := svc.capturedReq.Messages[0].Content.([]any)
+	if block, _ := blocks[0].(map[string]any); block["text"] != "my card is [CARD]" {
+		t.Fatalf("expected a redacted message, got %#v", svc.capturedReq.Messages[0].Content)
+	}
+	var msg map[string]any
+	_ = json.Unmarshal(rr.Body.Bytes(), &msg)
+	if msg["stop_reason"] != "refusal" || !strings.Contains(rr.Body.String(), "blocked by a guardrail") {
+		t.Fatalf("expected the completion to be blocked, got %s", rr.Body.String())
+	}
+	if got := rr.Header().Get("x-cc-guardrails"); got != "pii=redact,no-ok=block" {
+		t.Fatalf("unexpected x-cc-guardrails header %q", got)
+	}
+	triggered := events.List(ccevent.ListFilter{EventType: "guardrail.triggered"})
+	if len(triggered) != 2 || triggered[0].RunID == "" {
+		t.Fatalf("expected two guardrail.triggered events with run ids, got %+v", triggered)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"model":"claude-test","messages":[{"role":"user","content":"please DROP TABLE users"}]}`))
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "forbidden") {
+		t.Fatalf("expected 400 naming the rule, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"model":"claude-test","messages":[{"role":"user","content":"hi"}]}`))
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"finish_reason":"content_filter"`) {
+		t.Fatalf("expected content_filter finish reason, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestGuardrailsHoldBlockedStreams(t *testing.T) {
+	engine, err := guardrail.New(guardrail.Config{Enabled: true, Rules: []guardrail.Rule{
+		{Name: "plan", Kind: guardrail.KindRegex, Action: guardrail.ActionBlock, Pattern: "secret-plan", Stages: []string{"output"}},
+	}}, nil)
+	if err != nil {
+		t.Fatalf("new engine: %v", err)
+	}
+	router := newTestRouterWithDeps(t, Dependencies{
+		Orchestrator: orchestrator.NewSimpleService(),
+		Guardrails:   engine,
+	})
+	cases := []struct {
+		path, body, marker string
+	}{
+		{"/v1/messages", `{"model":"claude-test","max_tokens":64,"stream":true,"messages":[{"role":"user","content":"the secret-plan"}]}`, `"stop_reason":"refusal"`},
+		{"/v1/chat/completions", `{"model":"claude-test","stream":true,"messages":[{"role":"user","content":"the secret-plan"}]}`, `"finish_reason":"content_filter"`},
+		{"/v1/responses", `{"model":"claude-test","stream":true,"input":"the secret-plan"}`, `"type":"response.completed"`},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodPost, tc.path, strings.NewReader(tc.body))
+		req.Header.Set("anthropic-version", "2023-06-01")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		body := rr.Body.String()
+		if rr.Code != http.StatusOK || strings.Contains(body, "secret-plan") {
+			t.Fatalf("%s: expected the blocked text to be held back, got %d: %s", tc.path, rr.Code, body)
+		}
+		if !strings.Contains(body, "blocked by a guardrail") || !strings.Contains(body, tc.marker) {
+			t.Fatalf("%s: expected a refusal stream, got %s", tc.path, body)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(`{"model":"claude-test","max_tokens":64,"stream":true,"messages":[{"role":"user","content":"hi"}]}`))
+	req.Header.Set("anthropic-version", "2023-06-01")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if body := rr.Body.String(); !strings.Contains(body, "Processed request: hi") || !strings.Contains(body, "event: message_stop") {
+		t.Fatalf("expected an allowed stream to be released, got %s", body)
+	}
+}
+
+func TestGuardrailsStoreRedactedPrompt(t *testing.T) {
+	engine, err := guardrail.New(guardrail.Config{Enabled: true, Rules: []guardrail.Rule{
+		{Name: "pii", Kind: guardrail.KindPII, Action: guardrail.ActionRedact},
+	}}, nil)
+	if err != nil {
+		t.Fatalf("new engine: %v", err)
+	}
+	runs := ccrun.NewStore()
+	router := newTestRouterWithDeps(t, Dependencies{
+		Orchestrator: orchestrator.NewSimpleService(),
+		RunStore:     runs,
+		Guardrails:   engine,
+	})
+	for _, stream := range []string{"false", "true"} {
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"model":"claude-test","stream":`+stream+`,"messages":[{"role":"user","content":"mail bob@example.com"}]}`))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+	}
+	list := runs.List(ccrun.ListFilter{})
+	if len(list) != 2 {
+		t.Fatalf("expected two runs, got %d", len(list))
+	}
+	for _, run := range list {
+		if run.PromptText != "mail [EMAIL]" || strings.Contains(run.OutputText, "bob@example.com") {
+			t.Fatalf("expected the run to store redacted text, got prompt %q output %q", run.PromptText, run.OutputText)
+		}
+	}
+}
+
+func TestAdminGuardrailsUpdateAndTest(t *testing.T) {
+	engine, _ := guardrail.New(guardrail.Config{}, nil)
+	router := newTestRouterWithDeps(t, Dependencies{
+		Orchestrator: &captureService{},
+		AdminToken:   "secret-admin",
+		Guardrails:   engine,
+	})
+	admin := func(method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("authorization", "Bearer secret-admin")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+	if rr := admin(http.MethodPut, "/admin/guardrails", `{"enabled":true,"rules":[{"name":"x","kind":"regex","action":"block","pattern":"("}]}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an invalid pattern, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := admin(http.MethodPut, "/admin/guardrails", `{"enabled":true,"rules":[{"name":"keys","kind":"pii","action":"redact","pii":["api_key"]}]}`); rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	rr := admin(http.MethodPost, "/admin/guardrails/test", `{"stage":"output","text":"use sk-abcdefghijklmnop1234"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var out struct {
+		Text     string              `json:"text"`
+		Blocked  bool                `json:"blocked"`
+		Findings []guardrail.Finding `json:"findings"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &out); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if out.Text != "use [API_KEY]" || out.Blocked || len(out.Findings) != 1 {
+		t.Fatalf("unexpected test result: %+v", out)
+	}
+}
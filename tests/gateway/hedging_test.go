@@ -0,0 +1,63 @@
+package gateway_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ccgateway/internal/ccevent"
+	. "ccgateway/internal/gateway"
+	"ccgateway/internal/orchestrator"
+	"ccgateway/internal/settings"
+	"ccgateway/internal/upstream"
+)
+
+// stalledAdapter never answers before its call is canceled.
+type stalledAdapter struct{ name string }
+
+func (a stalledAdapter) Name() string { return a.name }
+
+func (a stalledAdapter) Complete(ctx context.Context, _ orchestrator.Request) (orchestrator.Response, error) {
+	select {
+	case <-ctx.Done():
+		return orchestrator.Response{}, ctx.Err()
+	case <-time.After(5 * time.Second):
+		return orchestrator.Response{}, context.DeadlineExceeded
+	}
+}
+
+func TestHedgedRequestRecordsOutcomeEvent(t *testing.T) {
+	cfg := settings.DefaultRuntimeSettings()
+	cfg.Routing.Hedge = settings.HedgeSettings{Enabled: true, AfterMS: 20}
+	svc := upstream.NewRouterService(upstream.RouterConfig{DefaultRoute: []string{"stalled", "backup"}}, []upstream.Adapter{
+		stalledAdapter{name: "stalled"},
+		namedTextAdapter{name: "backup", text: "backup answer"},
+	})
+	eventStore := ccevent.NewStore()
+	router := newTestRouterWithDeps(t, Dependencies{
+		Orchestrator: svc,
+		Settings:     settings.NewStore(cfg),
+		EventStore:   eventStore,
+	})
+
+	body := `{"model":"claude-test","max_tokens":64,"messages":[{"role":"user","content":"hi"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(body))
+	req.Header.Set("anthropic-version", "2023-06-01")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "backup answer") {
+		t.Fatalf("expected the hedge to answer: %d %s", rr.Code, rr.Body.String())
+	}
+
+	events := eventStore.List(ccevent.ListFilter{EventType: "run.hedge", Limit: 10})
+	if len(events) != 1 {
+		t.Fatalf("expected one run.hedge event, got %d", len(events))
+	}
+	data := events[0].Data
+	if data["primary"] != "stalled" || data["winner"] != "backup" || data["fired"] != true || events[0].RunID == "" {
+		t.Fatalf("unexpected hedge event: %+v", events[0])
+	}
+}
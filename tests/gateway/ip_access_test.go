@@ -0,0 +1,72 @@
+package gateway_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "ccgateway/internal/gateway"
+	"ccgateway/internal/ipaccess"
+)
+
+func TestIPAccessDeniesClientsOutsideAllowlist(t *testing.T) {
+	policy, err := ipaccess.NewPolicy(ipaccess.Config{Allow: []string{"10.0.0.0/8"}})
+	if err != nil {
+		t.Fatalf("new policy: %v", err)
+	}
+	router := newTestRouterWithDeps(t, Dependencies{IPAccess: policy})
+
+	req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	req.RemoteAddr = "203.0.113.4:4000"
+	req.Header.Set("x-forwarded-for", "10.0.0.1")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for untrusted forwarded address, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	req.RemoteAddr = "10.2.3.4:4000"
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 for allowed client, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestAdminIPAccessRejectsSelfLockout(t *testing.T) {
+	policy, _ := ipaccess.NewPolicy(ipaccess.Config{})
+	router := newTestRouterWithDeps(t, Dependencies{
+		AdminToken: "secret-admin",
+		IPAccess:   policy,
+	})
+
+	// httptest requests come from 192.0.2.1.
+	req := httptest.NewRequest(http.MethodPut, "/admin/ip-access", strings.NewReader(`{"admin_allow":["10.0.0.0/8"]}`))
+	req.Header.Set("authorization", "Bearer secret-admin")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for lockout patch, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if len(policy.Config().AdminAllow) != 0 {
+		t.Fatalf("expected rejected patch to leave policy unchanged")
+	}
+
+	req = httptest.NewRequest(http.MethodPut, "/admin/ip-access", strings.NewReader(`{"admin_allow":["192.0.2.0/24"],"rules":[{"path_prefix":"/v1/","deny":["198.51.100.0/24"]}]}`))
+	req.Header.Set("authorization", "Bearer secret-admin")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/v1/models", nil)
+	req.RemoteAddr = "198.51.100.3:4000"
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 from route rule, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
@@ -0,0 +1,105 @@
+package gateway_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	. "ccgateway/internal/gateway"
+	"ccgateway/internal/orchestrator"
+	"ccgateway/internal/settings"
+	"ccgateway/internal/upstream"
+)
+
+type scoringJudgeAdapter struct {
+	mu     sync.Mutex
+	system string
+}
+
+func (a *scoringJudgeAdapter) Name() string { return "judge" }
+
+func (a *scoringJudgeAdapter) Complete(_ context.Context, req orchestrator.Request) (orchestrator.Response, error) {
+	a.mu.Lock()
+	a.system, _ = req.System.(string)
+	a.mu.Unlock()
+	// Candidates arrive in completion order; always prefer "second".
+	reply := `{"index":1,"scores":[{"safety":2},{"safety":9}],"reason":"safer"}`
+	prompt, _ := req.Messages[0].Content.(string)
+	if strings.Index(prompt, "second answer") < strings.Index(prompt, "first answer") {
+		reply = `{"index":0,"scores":[{"safety":9},{"safety":2}],"reason":"safer"}`
+	}
+	return orchestrator.Response{
+		Blocks:     []orchestrator.AssistantBlock{{Type: "text", Text: reply}},
+		StopReason: "end_turn",
+	}, nil
+}
+
+func TestAdminJudgeRubricAppliesPerMode(t *testing.T) {
+	judgeAdapter := &scoringJudgeAdapter{}
+	judge, err := upstream.NewLLMJudge(upstream.LLMJudgeConfig{Route: []string{"judge"}, Model: "judge-model"}, []upstream.Adapter{judgeAdapter})
+	if err != nil {
+		t.Fatalf("new judge: %v", err)
+	}
+	history, err := upstream.NewJudgeHistory("")
+	if err != nil {
+		t.Fatalf("open history: %v", err)
+	}
+	svc := upstream.NewRouterService(upstream.RouterConfig{
+		DefaultRoute: []string{"first", "second"},
+		Timeout:      2 * time.Second,
+		Judge:        judge,
+		JudgeHistory: history,
+	}, []upstream.Adapter{
+		namedTextAdapter{name: "first", text: "first answer"},
+		namedTextAdapter{name: "second", text: "second answer"},
+	})
+	cfg := settings.DefaultRuntimeSettings()
+	cfg.Routing.ParallelCandidates = 2
+	cfg.Routing.EnableResponseJudge = true
+	cfg.Routing.ReflectionPasses = -1 // keep the judged answer as-is
+	router := newTestRouterWithDeps(t, Dependencies{
+		Orchestrator: svc,
+		Settings:     settings.NewStore(cfg),
+		AdminToken:   "secret-admin",
+	})
+
+	put := httptest.NewRequest(http.MethodPut, "/admin/judge/rubric", strings.NewReader(`{
+		"rubric": {"dimensions": [{"name": "accuracy"}]},
+		"mode_rubrics": {"plan": {"prompt": "Plan review. {{dimensions}}", "dimensions": [{"name": "Safety", "weight": 2}]}}
+	}`))
+	put.Header.Set("authorization", "Bearer secret-admin")
+	putRR := httptest.NewRecorder()
+	router.ServeHTTP(putRR, put)
+	if putRR.Code != http.StatusOK || !strings.Contains(putRR.Body.String(), `"name":"safety"`) {
+		t.Fatalf("expected sanitized rubric back, got %d %s", putRR.Code, putRR.Body.String())
+	}
+
+	msg := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(`{"model":"m","max_tokens":16,"messages":[{"role":"user","content":"hi"}]}`))
+	msg.Header.Set("anthropic-version", "2023-06-01")
+	msg.Header.Set("authorization", "Bearer secret-admin")
+	msg.Header.Set("x-cc-mode", "plan")
+	msgRR := httptest.NewRecorder()
+	router.ServeHTTP(msgRR, msg)
+	if msgRR.Code != http.StatusOK || !strings.Contains(msgRR.Body.String(), "second answer") {
+		t.Fatalf("expected the judged winner, got %d %s", msgRR.Code, msgRR.Body.String())
+	}
+	judgeAdapter.mu.Lock()
+	system := judgeAdapter.system
+	judgeAdapter.mu.Unlock()
+	if !strings.HasPrefix(system, "Plan review.") || !strings.Contains(system, "- safety (weight 2)") {
+		t.Fatalf("expected the plan rubric, got %q", system)
+	}
+	verdicts := history.Verdicts("", 0)
+	if len(verdicts) != 1 || verdicts[0].Winner != "second" {
+		t.Fatalf("unexpected recorded verdicts: %+v", verdicts)
+	}
+	for _, c := range verdicts[0].Candidates {
+		if c.Winner && c.Dimensions["safety"] != 9 {
+			t.Fatalf("expected the winner's dimension scores, got %+v", c)
+		}
+	}
+}
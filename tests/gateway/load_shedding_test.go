@@ -0,0 +1,91 @@
+package gateway_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "ccgateway/internal/gateway"
+	"ccgateway/internal/orchestrator"
+	"ccgateway/internal/settings"
+	"ccgateway/internal/upstream"
+)
+
+type saturatedAdapter struct{}
+
+func (saturatedAdapter) Name() string { return "busy" }
+
+func (saturatedAdapter) Complete(context.Context, orchestrator.Request) (orchestrator.Response, error) {
+	return orchestrator.Response{}, fmt.Errorf("adapter busy upstream status 529: overloaded")
+}
+
+func TestLoadSheddingDropsLowPriorityWhenUpstreamsSaturated(t *testing.T) {
+	cfg := settings.DefaultRuntimeSettings()
+	cfg.Routing.LoadShedding = settings.LoadSheddingSettings{
+		Enabled:    true,
+		MinSamples: 2,
+		Classes:    map[string]settings.ShedPolicy{"low": {SaturationRate: 0.5}},
+	}
+	svc := upstream.NewRouterService(upstream.RouterConfig{DefaultRoute: []string{"busy"}}, []upstream.Adapter{saturatedAdapter{}})
+	router := newTestRouterWithDeps(t, Dependencies{
+		Orchestrator: svc,
+		Settings:     settings.NewStore(cfg),
+		AdminToken:   "secret-admin",
+	})
+	send := func(priority string) *httptest.ResponseRecorder {
+		body := `{"model":"claude-test","max_tokens":64,"messages":[{"role":"user","content":"hi"}]}`
+		req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(body))
+		req.Header.Set("anthropic-version", "2023-06-01")
+		req.Header.Set("authorization", "Bearer secret-admin")
+		if priority != "" {
+			req.Header.Set("x-cc-priority", priority)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// Upstream 529s are normalized to 503 overloaded_error; shed requests
+	// carry x-cc-shed-class on top.
+	reachedUpstream := func(rr *httptest.ResponseRecorder) bool {
+		return rr.Code == http.StatusServiceUnavailable && rr.Header().Get("x-cc-shed-class") == "" &&
+			strings.Contains(rr.Body.String(), `"code":"overloaded"`)
+	}
+
+	// Below min_samples nothing is shed yet.
+	if rr := send("low"); !reachedUpstream(rr) {
+		t.Fatalf("expected upstream failure before saturation is known, got %d", rr.Code)
+	}
+	send("")
+	rr := send("low")
+	if rr.Code != http.StatusServiceUnavailable || rr.Header().Get("x-cc-shed-class") != "low" {
+		t.Fatalf("expected low priority to be shed, got %d %s", rr.Code, rr.Body.String())
+	}
+	if rr := send(""); !reachedUpstream(rr) {
+		t.Fatalf("normal priority has no policy and must reach upstream, got %d", rr.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/status", nil)
+	req.Header.Set("authorization", "Bearer secret-admin")
+	status := httptest.NewRecorder()
+	router.ServeHTTP(status, req)
+	var body struct {
+		LoadShedding struct {
+			Shedding    []string         `json:"shedding"`
+			ShedTotal   int64            `json:"shed_total"`
+			ShedByClass map[string]int64 `json:"shed_by_class"`
+			Saturated   int              `json:"saturated"`
+		} `json:"load_shedding"`
+	}
+	if err := json.Unmarshal(status.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode status: %v", err)
+	}
+	ls := body.LoadShedding
+	if ls.ShedTotal != 1 || ls.ShedByClass["low"] != 1 || len(ls.Shedding) != 1 || ls.Saturated < 3 {
+		t.Fatalf("unexpected load shedding status: %+v", ls)
+	}
+}
@@ -0,0 +1,103 @@
+package gateway_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ccgateway/internal/ccrun"
+	. "ccgateway/internal/gateway"
+	"ccgateway/internal/plan"
+	"ccgateway/internal/todo"
+)
+
+func mcpRPC(t *testing.T, router http.Handler, body string) (int, map[string]any) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/mcp", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Body.Len() == 0 {
+		return rr.Code, nil
+	}
+	var out map[string]any
+	if err := json.Unmarshal(rr.Body.Bytes(), &out); err != nil {
+		t.Fatalf("decode rpc response: %v; body=%s", err, rr.Body.String())
+	}
+	return rr.Code, out
+}
+
+func TestGatewayMCPServerExposesPlansTodosAndRuns(t *testing.T) {
+	todoStore := todo.NewStore()
+	router := newTestRouterWithDeps(t, Dependencies{
+		RunStore:  ccrun.NewStore(),
+		PlanStore: plan.NewStore(),
+		TodoStore: todoStore,
+	})
+
+	_, resp := mcpRPC(t, router, `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26"}}`)
+	result, _ := resp["result"].(map[string]any)
+	if result["protocolVersion"] != "2025-03-26" || result["capabilities"] == nil {
+		t.Fatalf("unexpected initialize result: %+v", resp)
+	}
+	if code, _ := mcpRPC(t, router, `{"jsonrpc":"2.0","method":"notifications/initialized"}`); code != http.StatusAccepted {
+		t.Fatalf("expected 202 for notification, got %d", code)
+	}
+
+	_, resp = mcpRPC(t, router, `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`)
+	result, _ = resp["result"].(map[string]any)
+	tools, _ := result["tools"].([]any)
+	names := map[string]bool{}
+	for _, raw := range tools {
+		names[raw.(map[string]any)["name"].(string)] = true
+	}
+	for _, want := range []string{"runs_list", "runs_get", "plans_create", "plans_execute", "todos_update"} {
+		if !names[want] {
+			t.Fatalf("expected tool %s in %v", want, names)
+		}
+	}
+	if names["tool_catalog_list"] {
+		t.Fatalf("tool catalog tool should be hidden without a catalog")
+	}
+
+	_, resp = mcpRPC(t, router, `{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"plans_create","arguments":{"title":"ship","steps":[{"title":"build"},{"title":"test"}]}}}`)
+	result, _ = resp["result"].(map[string]any)
+	if result["isError"] != false {
+		t.Fatalf("plans_create failed: %+v", resp)
+	}
+	if got := todoStore.List(todo.ListFilter{}); len(got) != 2 {
+		t.Fatalf("expected plan steps seeded as todos, got %d", len(got))
+	}
+
+	_, resp = mcpRPC(t, router, `{"jsonrpc":"2.0","id":4,"method":"tools/call","params":{"name":"runs_get","arguments":{"id":"missing"}}}`)
+	result, _ = resp["result"].(map[string]any)
+	if result["isError"] != true {
+		t.Fatalf("expected in-band tool error, got %+v", resp)
+	}
+
+	_, resp = mcpRPC(t, router, `{"jsonrpc":"2.0","id":5,"method":"tools/call","params":{"name":"nope"}}`)
+	if rpcErr, _ := resp["error"].(map[string]any); rpcErr["code"] != float64(-32602) {
+		t.Fatalf("expected invalid params error, got %+v", resp)
+	}
+	_, resp = mcpRPC(t, router, `{"jsonrpc":"2.0","id":6,"method":"resources/list"}`)
+	if rpcErr, _ := resp["error"].(map[string]any); rpcErr["code"] != float64(-32601) {
+		t.Fatalf("expected method not found, got %+v", resp)
+	}
+}
+
+func TestGatewayMCPServerNegotiatesProtocolVersion(t *testing.T) {
+	router := newTestRouterWithDeps(t, Dependencies{RunStore: ccrun.NewStore()})
+	for requested, want := range map[string]string{
+		"2024-11-05": "2024-11-05",
+		"2025-03-26": "2025-03-26",
+		"1999-01-01": "2025-03-26",
+		"":           "2025-03-26",
+	} {
+		_, resp := mcpRPC(t, router, `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"`+requested+`"}}`)
+		result, _ := resp["result"].(map[string]any)
+		if result["protocolVersion"] != want {
+			t.Fatalf("requested %q: expected %q, got %+v", requested, want, resp)
+		}
+	}
+}
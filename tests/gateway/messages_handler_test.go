@@ -7,6 +7,7 @@ import (
 	. "ccgateway/internal/gateway"
 	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -17,8 +18,10 @@ import (
 	"ccgateway/internal/modelmap"
 	"ccgateway/internal/orchestrator"
 	"ccgateway/internal/policy"
+	"ccgateway/internal/servertools"
 	"ccgateway/internal/settings"
 	"ccgateway/internal/token"
+	"ccgateway/internal/toolruntime"
 	"ccgateway/internal/upstream"
 )
 
@@ -336,6 +339,9 @@ func TestMessagesTokenModelRestriction(t *testing.T) {
 	if env.Error.Type != "permission_error" {
 		t.Fatalf("expected permission_error, got %q", env.Error.Type)
 	}
+	if allowed, _ := env.Error.Details["allowed_models"].([]any); len(allowed) != 1 || allowed[0] != "claude-allowed" {
+		t.Fatalf("expected allowed model list in error details, got %#v", env.Error.Details)
+	}
 }
 
 func TestMessagesRequireTokenWhenTokenServiceConfiguredWithoutAdminToken(t *testing.T) {
@@ -1550,3 +1556,119 @@ func TestMessagesStreamAutoEnableToolFallbackWhenUpstreamUnsupported(t *testing.
 		t.Fatalf("expected tool.fallback_applied event")
 	}
 }
+
+type recordingServerTool struct {
+	calls int
+}
+
+func (t *recordingServerTool) Name() string { return "web_search" }
+
+func (t *recordingServerTool) Execute(_ context.Context, call toolruntime.Call) (toolruntime.Result, error) {
+	t.calls++
+	return toolruntime.Result{Content: map[string]any{"results": []any{}}}, nil
+}
+
+func TestMessagesServerSideToolLoopUsesServerTools(t *testing.T) {
+	svc := &toolLoopService{toolName: "web_search"}
+	tool := &recordingServerTool{}
+	cfg := settings.DefaultRuntimeSettings()
+	cfg.ToolLoop.Mode = "server_loop"
+	cfg.ToolLoop.MaxSteps = 3
+	router := newTestRouterWithDeps(t, Dependencies{
+		Orchestrator: svc,
+		Settings:     settings.NewStore(cfg),
+		ServerTools:  []servertools.Tool{tool},
+	})
+
+	body := `{
+		"model":"claude-test",
+		"max_tokens":128,
+		"messages":[{"role":"user","content":"search please"}],
+		"tools":[{"type":"web_search_20250305","name":"web_search","max_uses":3}]
+	}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(body))
+	req.Header.Set("anthropic-version", "2023-06-01")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d; body=%s", rr.Code, rr.Body.String())
+	}
+	if tool.calls != 1 || !svc.sawToolResult {
+		t.Fatalf("expected web_search executed by server tool, calls=%d sawToolResult=%v", tool.calls, svc.sawToolResult)
+	}
+}
+
+type denyToolPolicy struct {
+	denied string
+}
+
+func (p denyToolPolicy) Authorize(_ context.Context, action policy.Action) error {
+	for _, name := range action.ToolNames {
+		if name == p.denied {
+			return errors.New("tool denied: " + name)
+		}
+	}
+	return nil
+}
+
+func TestMessagesAutoInjectsMCPToolsInServerLoop(t *testing.T) {
+	mcpRPC := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"jsonrpc": "2.0",
+			"id":      req["id"],
+			"result": map[string]any{"tools": []map[string]any{
+				{"name": "kb_search", "description": "search the KB", "inputSchema": map[string]any{"type": "object"}},
+				{"name": "get_weather", "description": "remote weather"},
+				{"name": "drop_tables"},
+			}},
+		})
+	}))
+	defer mcpRPC.Close()
+	registry := mcpregistry.NewStore(mcpRPC.Client())
+	if _, err := registry.Register(mcpregistry.RegisterInput{ID: "kb", Name: "kb", Transport: mcpregistry.TransportHTTP, URL: mcpRPC.URL}); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := settings.DefaultRuntimeSettings()
+	cfg.ToolLoop.Mode = "server_loop"
+	cfg.AutoInjectMCPTools = true
+	st := settings.NewStore(cfg)
+	svc := &captureService{}
+	router := newTestRouterWithDeps(t, Dependencies{
+		Orchestrator: svc,
+		Policy:       denyToolPolicy{denied: "drop_tables"},
+		Settings:     st,
+		MCPRegistry:  registry,
+	})
+
+	send := func() []string {
+		body := `{"model":"claude-test","max_tokens":64,"messages":[{"role":"user","content":"hi"}],
+			"tools":[{"name":"get_weather","description":"client weather","input_schema":{"type":"object"}}]}`
+		req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(body))
+		req.Header.Set("anthropic-version", "2023-06-01")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		names := []string{}
+		for _, tool := range svc.capturedReq.Tools {
+			names = append(names, tool.Name+":"+tool.Description)
+		}
+		return names
+	}
+
+	got := send()
+	want := []string{"get_weather:client weather", "kb_search:search the KB"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("unexpected upstream tools: %v", got)
+	}
+
+	cfg.ToolLoop.Mode = "client_loop"
+	st.Put(cfg)
+	if got := send(); len(got) != 1 {
+		t.Fatalf("expected no injection in client_loop, got %v", got)
+	}
+}
@@ -0,0 +1,55 @@
+package gateway_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "ccgateway/internal/gateway"
+	"ccgateway/internal/settings"
+)
+
+func TestModeParamsOverrideSampling(t *testing.T) {
+	zero := 0.0
+	st := settings.NewStore(settings.DefaultRuntimeSettings())
+	cfg := st.Get()
+	cfg.ModeParams = map[string]settings.ModeParams{
+		"plan": {Temperature: &zero, MaxTokens: 256, StopSequences: []string{"</plan>"}},
+	}
+	st.Put(cfg)
+	svc := &captureService{}
+	router := newTestRouterWithDeps(t, Dependencies{Orchestrator: svc, Settings: st})
+
+	send := func(mode string) {
+		t.Helper()
+		body := `{"model":"claude-test","max_tokens":4096,"temperature":0.9,"messages":[{"role":"user","content":"hi"}],"metadata":{"stop_sequences":["STOP"]}}`
+		req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(body))
+		req.Header.Set("anthropic-version", "2023-06-01")
+		req.Header.Set("x-cc-mode", mode)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+	}
+
+	send("plan")
+	got := svc.capturedReq
+	if got.MaxTokens != 256 || got.Metadata["temperature"] != 0.0 {
+		t.Fatalf("expected plan overrides, got max_tokens=%d temperature=%v", got.MaxTokens, got.Metadata["temperature"])
+	}
+	stops, _ := got.Metadata["stop_sequences"].([]string)
+	if len(stops) != 2 || stops[0] != "STOP" || stops[1] != "</plan>" {
+		t.Fatalf("expected merged stop sequences, got %#v", got.Metadata["stop_sequences"])
+	}
+	if _, leaked := got.Metadata["mode_params"]; leaked {
+		t.Fatalf("expected mode_params to be consumed")
+	}
+
+	send("chat")
+	got = svc.capturedReq
+	if got.MaxTokens != 4096 || got.Metadata["temperature"] != 0.9 {
+		t.Fatalf("expected chat request untouched, got max_tokens=%d temperature=%v", got.MaxTokens, got.Metadata["temperature"])
+	}
+}
@@ -0,0 +1,67 @@
+package gateway_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ccgateway/internal/ccevent"
+	"ccgateway/internal/ccrun"
+	. "ccgateway/internal/gateway"
+	"ccgateway/internal/modelmap"
+	"ccgateway/internal/upstream"
+)
+
+func TestModelAliasGroupRecordsServingTarget(t *testing.T) {
+	svc := upstream.NewRouterService(upstream.RouterConfig{
+		Routes: map[string][]string{
+			"provider-a/large":  {"a"},
+			"provider-b/medium": {"b"},
+		},
+		Timeout: 2 * time.Second,
+	}, []upstream.Adapter{
+		upstream.NewMockAdapter("a", true),
+		upstream.NewMockAdapter("b", false),
+	})
+	runs := ccrun.NewStore()
+	events := ccevent.NewStore()
+	router := newTestRouterWithDeps(t, Dependencies{
+		Orchestrator: svc,
+		ModelMapper: modelmap.NewGroupMapper(map[string][]string{
+			"claude-sonnet": {"provider-a/large", "provider-b/medium"},
+		}, false, ""),
+		RunStore:   runs,
+		EventStore: events,
+	})
+
+	body := `{"model":"claude-sonnet","max_tokens":64,"messages":[{"role":"user","content":"hi"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(body))
+	req.Header.Set("anthropic-version", "2023-06-01")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if got := rr.Header().Get("x-cc-upstream-model"); got != "provider-a/large" {
+		t.Fatalf("expected first target as upstream model header, got %q", got)
+	}
+
+	run, ok := runs.Get(rr.Header().Get("x-cc-run-id"))
+	if !ok {
+		t.Fatalf("run not recorded")
+	}
+	if run.Metadata["model_target"] != "provider-b/medium" || run.Metadata["model_target_index"] != 1 {
+		t.Fatalf("expected serving target in run metadata, got %#v", run.Metadata)
+	}
+	found := false
+	for _, ev := range events.List(ccevent.ListFilter{RunID: run.ID}) {
+		if ev.EventType == "run.model_fallback" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("expected run.model_fallback event")
+	}
+}
@@ -0,0 +1,198 @@
+package gateway_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ccgateway/internal/channel"
+	. "ccgateway/internal/gateway"
+	"ccgateway/internal/settings"
+	"ccgateway/internal/token"
+	"ccgateway/internal/upstream"
+)
+
+func TestModelsListReflectsTokenRestriction(t *testing.T) {
+	tokenSvc := token.NewInMemoryService()
+	restricted, _ := tokenSvc.Generate("user-restricted", 100)
+	allowed := "model-b, model-a"
+	restricted.Models = &allowed
+	if err := tokenSvc.Update(restricted); err != nil {
+		t.Fatalf("update token: %v", err)
+	}
+	open, _ := tokenSvc.Generate("user-open", 100)
+
+	st := settings.NewStore(settings.DefaultRuntimeSettings())
+	cfg := st.Get()
+	cfg.ModelMappings = map[string]string{"model-a": "up-a", "model-c": "up-c", "claude-*": "up-x"}
+	st.Put(cfg)
+
+	router := newTestRouterWithDeps(t, Dependencies{
+		TokenService: tokenSvc,
+		Settings:     st,
+		AdminToken:   "secret-admin",
+	})
+
+	list := func(tk string) (ids []string, restrictedFlag bool) {
+		req := httptest.NewRequest(http.MethodGet, "/v1/models", nil)
+		req.Header.Set("authorization", "Bearer "+tk)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		var body struct {
+			Data []struct {
+				ID string `json:"id"`
+			} `json:"data"`
+			Restricted bool `json:"restricted"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		for _, m := range body.Data {
+			ids = append(ids, m.ID)
+		}
+		return ids, body.Restricted
+	}
+
+	ids, flag := list(restricted.Value)
+	if !flag || len(ids) != 2 || ids[0] != "model-a" || ids[1] != "model-b" {
+		t.Fatalf("expected restricted token to see its declared list, got %v restricted=%v", ids, flag)
+	}
+	ids, flag = list(open.Value)
+	if flag || len(ids) != 2 || ids[0] != "model-a" || ids[1] != "model-c" {
+		t.Fatalf("expected unrestricted token to see mapped models, got %v restricted=%v", ids, flag)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/models/model-c", nil)
+	req.Header.Set("authorization", "Bearer "+restricted.Value)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for model outside token list, got %d", rr.Code)
+	}
+	var env ErrorEnvelope
+	_ = json.Unmarshal(rr.Body.Bytes(), &env)
+	if got, _ := env.Error.Details["allowed_models"].([]any); len(got) != 2 {
+		t.Fatalf("expected allowed models in details, got %#v", env.Error.Details)
+	}
+
+	getModel := func(id string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/v1/models/"+id, nil)
+		req.Header.Set("authorization", "Bearer "+open.Value)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+	if rr := getModel("model-c"); rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 for mapped model, got %d: %s", rr.Code, rr.Body.String())
+	}
+	rr = getModel("no-such-model")
+	env = ErrorEnvelope{}
+	_ = json.Unmarshal(rr.Body.Bytes(), &env)
+	if rr.Code != http.StatusNotFound || env.Error.Type != "not_found_error" {
+		t.Fatalf("expected 404 not_found_error for unknown model, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestModelsListMergesSourcesInBothShapes(t *testing.T) {
+	st := settings.NewStore(settings.DefaultRuntimeSettings())
+	cfg := st.Get()
+	cfg.ModelMappings = map[string]string{"model-a": "up-a"}
+	st.Put(cfg)
+
+	svc := upstream.NewRouterService(upstream.RouterConfig{
+		Routes:       map[string][]string{"route-b": {"mock"}, "route-*": {"mock"}},
+		DefaultRoute: []string{"mock"},
+	}, []upstream.Adapter{upstream.NewMockAdapter("mock", false)})
+
+	channels := channel.NewAbilityStore()
+	if err := channels.AddChannel(&channel.Channel{Name: "c1", Type: "openai", Models: "chan-c, chan-*", Group: "default", Status: channel.StatusEnabled}); err != nil {
+		t.Fatalf("add channel: %v", err)
+	}
+	if err := channels.AddChannel(&channel.Channel{Name: "c2", Type: "openai", Models: "chan-off", Group: "default", Status: channel.StatusManuallyDisabled}); err != nil {
+		t.Fatalf("add channel: %v", err)
+	}
+
+	router := newTestRouterWithDeps(t, Dependencies{
+		Orchestrator: svc,
+		Settings:     st,
+		ChannelStore: channels,
+	})
+	get := func(path string, anthropic bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if anthropic {
+			req.Header.Set("anthropic-version", "2023-06-01")
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := get("/v1/models", false)
+	var openai struct {
+		Object string `json:"object"`
+		Data   []struct {
+			ID     string `json:"id"`
+			Object string `json:"object"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &openai); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	var ids []string
+	for _, m := range openai.Data {
+		ids = append(ids, m.ID)
+	}
+	if openai.Object != "list" || strings.Join(ids, ",") != "chan-c,model-a,route-b" {
+		t.Fatalf("unexpected openai list: %s", rr.Body.String())
+	}
+
+	type anthropicList struct {
+		Data []struct {
+			Type        string `json:"type"`
+			ID          string `json:"id"`
+			DisplayName string `json:"display_name"`
+			CreatedAt   string `json:"created_at"`
+		} `json:"data"`
+		HasMore bool   `json:"has_more"`
+		FirstID string `json:"first_id"`
+		LastID  string `json:"last_id"`
+	}
+	rr = get("/v1/models?limit=2", true)
+	var page anthropicList
+	if err := json.Unmarshal(rr.Body.Bytes(), &page); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(page.Data) != 2 || !page.HasMore || page.FirstID != "chan-c" || page.LastID != "model-a" {
+		t.Fatalf("unexpected first page: %s", rr.Body.String())
+	}
+	if page.Data[0].Type != "model" || page.Data[0].DisplayName != "chan-c" || page.Data[0].CreatedAt == "" {
+		t.Fatalf("unexpected anthropic entry: %+v", page.Data[0])
+	}
+	rr = get("/v1/models?limit=2&after_id="+page.LastID, true)
+	page = anthropicList{}
+	_ = json.Unmarshal(rr.Body.Bytes(), &page)
+	if len(page.Data) != 1 || page.HasMore || page.FirstID != "route-b" {
+		t.Fatalf("unexpected second page: %s", rr.Body.String())
+	}
+	rr = get("/v1/models?before_id=route-b&limit=1", true)
+	page = anthropicList{}
+	_ = json.Unmarshal(rr.Body.Bytes(), &page)
+	if len(page.Data) != 1 || !page.HasMore || page.FirstID != "model-a" {
+		t.Fatalf("unexpected before_id page: %s", rr.Body.String())
+	}
+	if rr := get("/v1/models?limit=0", true); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for bad limit, got %d", rr.Code)
+	}
+
+	rr = get("/v1/models/model-a", true)
+	var one map[string]any
+	_ = json.Unmarshal(rr.Body.Bytes(), &one)
+	if one["type"] != "model" || one["id"] != "model-a" {
+		t.Fatalf("unexpected anthropic model: %s", rr.Body.String())
+	}
+}
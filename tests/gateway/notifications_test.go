@@ -0,0 +1,124 @@
+package gateway_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "ccgateway/internal/gateway"
+	"ccgateway/internal/notification"
+	"ccgateway/internal/orchestrator"
+	"ccgateway/internal/upstream"
+)
+
+type flakyAdapter struct{ fail bool }
+
+func (a *flakyAdapter) Name() string { return "flaky" }
+
+func (a *flakyAdapter) Complete(_ context.Context, req orchestrator.Request) (orchestrator.Response, error) {
+	if a.fail {
+		return orchestrator.Response{}, errors.New("connection refused")
+	}
+	return orchestrator.Response{
+		Model:      req.Model,
+		Blocks:     []orchestrator.AssistantBlock{{Type: "text", Text: "ok"}},
+		StopReason: "end_turn",
+	}, nil
+}
+
+type notificationList struct {
+	Data   []notification.Notification `json:"data"`
+	Counts notification.Counts         `json:"counts"`
+}
+
+func TestDefaultAdminTokenRaisesNotification(t *testing.T) {
+	router := newTestRouterWithDeps(t, Dependencies{AdminToken: DefaultAdminToken})
+	req := httptest.NewRequest(http.MethodGet, "/admin/notifications?status=unacknowledged", nil)
+	req.Header.Set("authorization", "Bearer "+DefaultAdminToken)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var body notificationList
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(body.Data) != 1 || body.Data[0].Kind != "admin_token_default" || body.Data[0].Severity != notification.SeverityCritical {
+		t.Fatalf("expected default token notification, got %+v", body.Data)
+	}
+	if body.Counts.Unacknowledged != 1 {
+		t.Fatalf("unexpected counts: %+v", body.Counts)
+	}
+}
+
+func TestAdapterFailuresRaiseAndAcknowledgeNotification(t *testing.T) {
+	adapter := &flakyAdapter{fail: true}
+	center := notification.NewCenter()
+	svc := upstream.NewRouterService(upstream.RouterConfig{DefaultRoute: []string{"flaky"}}, []upstream.Adapter{adapter})
+	router := newTestRouterWithDeps(t, Dependencies{
+		Orchestrator:  svc,
+		AdminToken:    "secret-admin",
+		Notifications: center,
+	})
+	send := func() {
+		body := `{"model":"claude-test","max_tokens":64,"messages":[{"role":"user","content":"hi"}]}`
+		req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(body))
+		req.Header.Set("anthropic-version", "2023-06-01")
+		req.Header.Set("authorization", "Bearer secret-admin")
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	admin := func(method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("authorization", "Bearer secret-admin")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	for i := 0; i < 4; i++ {
+		send()
+	}
+	if center.IsOpen("adapter_unhealthy:flaky") {
+		t.Fatalf("a few failures must not raise yet")
+	}
+	for i := 0; i < 4; i++ {
+		send()
+	}
+	items, _ := center.List(notification.ListFilter{Kind: "adapter_unhealthy"})
+	if len(items) != 1 || items[0].Data["adapter"] != "flaky" {
+		t.Fatalf("expected one adapter notification, got %+v", items)
+	}
+	id := items[0].ID
+
+	rr := admin(http.MethodPost, "/admin/notifications/"+id+"/acknowledge")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("acknowledge: %d %s", rr.Code, rr.Body.String())
+	}
+	var acked notification.Notification
+	_ = json.Unmarshal(rr.Body.Bytes(), &acked)
+	if acked.AcknowledgedAt == nil || !strings.HasPrefix(acked.AcknowledgedBy, "sha256:") {
+		t.Fatalf("unexpected acknowledged notification: %+v", acked)
+	}
+	if rr := admin(http.MethodGet, "/admin/notifications/"+id+"/acknowledge"); rr.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405, got %d", rr.Code)
+	}
+	if rr := admin(http.MethodPost, "/admin/notifications/missing/read"); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rr.Code)
+	}
+
+	adapter.fail = false
+	send()
+	if center.IsOpen("adapter_unhealthy:flaky") {
+		t.Fatalf("a success must resolve the notification")
+	}
+	var body notificationList
+	_ = json.Unmarshal(admin(http.MethodGet, "/admin/notifications?status=resolved").Body.Bytes(), &body)
+	if len(body.Data) != 1 || body.Data[0].ID != id || body.Counts.Unacknowledged != 0 {
+		t.Fatalf("unexpected resolved list: %+v", body)
+	}
+}
@@ -0,0 +1,56 @@
+package gateway_test
+
+import (
+	. "ccgateway/internal/gateway"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ccgateway/internal/upstream"
+)
+
+func TestOfflineModeIsReportedToClientsAndAdmins(t *testing.T) {
+	offline := &upstream.OfflineMode{Fixtures: []upstream.Fixture{{Contains: "ping", Text: "pong from fixture"}}}
+	adapters, err := offline.BuildAdapters([]upstream.AdapterSpec{{Name: "primary", Kind: upstream.AdapterKindOpenAI}})
+	if err != nil {
+		t.Fatalf("build offline adapters: %v", err)
+	}
+	svc := upstream.NewRouterService(upstream.RouterConfig{
+		DefaultRoute: []string{"primary"},
+		Timeout:      time.Second,
+		Offline:      offline,
+	}, adapters)
+	router := newTestRouterWithDeps(t, Dependencies{Orchestrator: svc})
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(`{"model":"claude-test","max_tokens":16,"messages":[{"role":"user","content":"ping"}]}`))
+	req.Header.Set("anthropic-version", "2023-06-01")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "pong from fixture") {
+		t.Fatalf("expected fixture reply, got %d %s", rr.Code, rr.Body.String())
+	}
+	if rr.Header().Get("x-cc-offline") != "true" {
+		t.Fatalf("expected offline header on responses")
+	}
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/admin/status", nil))
+	var status struct {
+		Offline upstream.OfflineStatus `json:"offline"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &status); err != nil {
+		t.Fatalf("decode admin status: %v", err)
+	}
+	if !status.Offline.Enabled || status.Offline.Fixtures != 1 {
+		t.Fatalf("expected offline status in admin status, got %+v", status.Offline)
+	}
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/admin/upstream", nil))
+	if !strings.Contains(rr.Body.String(), `"offline":true`) {
+		t.Fatalf("expected offline flag in upstream config, got %s", rr.Body.String())
+	}
+}
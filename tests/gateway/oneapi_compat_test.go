@@ -0,0 +1,159 @@
+package gateway_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ccgateway/internal/auditlog"
+	"ccgateway/internal/auth"
+	"ccgateway/internal/channel"
+	. "ccgateway/internal/gateway"
+	"ccgateway/internal/ipaccess"
+	"ccgateway/internal/token"
+)
+
+type oneAPIEnvelope struct {
+	Success bool            `json:"success"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+func TestOneAPICompatibleManagementEndpoints(t *testing.T) {
+	channels := channel.NewAbilityStore()
+	authSvc := auth.NewInMemoryService()
+	tokens := token.NewInMemoryService()
+	router := newTestRouterWithDeps(t, Dependencies{
+		AdminToken:   "secret-admin",
+		ChannelStore: channels,
+		AuthService:  authSvc,
+		TokenService: tokens,
+	})
+	call := func(method, path, body string) oneAPIEnvelope {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("authorization", "secret-admin")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		var env oneAPIEnvelope
+		if rr.Code != http.StatusOK || json.Unmarshal(rr.Body.Bytes(), &env) != nil {
+			t.Fatalf("%s %s: unexpected response %d: %s", method, path, rr.Code, rr.Body.String())
+		}
+		return env
+	}
+
+	unauth := httptest.NewRecorder()
+	router.ServeHTTP(unauth, httptest.NewRequest(http.MethodGet, "/api/channel/", nil))
+	if unauth.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without the admin token, got %d", unauth.Code)
+	}
+
+	if env := call(http.MethodPost, "/api/channel/", `{"name":"claude","type":14,"key":"k1\nk2","models":"claude-x","group":"default","weight":5,"priority":2,"other":""}`); !env.Success {
+		t.Fatalf("create channel failed: %s", env.Message)
+	}
+	env := call(http.MethodGet, "/api/channel/?p=0", "")
+	var listed []map[string]any
+	_ = json.Unmarshal(env.Data, &listed)
+	if len(listed) != 2 || listed[0]["type"] != float64(14) || listed[0]["weight"] != float64(5) || listed[0]["key"] != nil {
+		t.Fatalf("expected two anthropic channels without keys, got %s", env.Data)
+	}
+	stored, _ := channels.GetChannel(1)
+	if stored.Type != "anthropic" || stored.Key != "k1" || stored.Priority != 2 {
+		t.Fatalf("unexpected stored channel %+v", stored)
+	}
+	if env := call(http.MethodPut, "/api/channel/", `{"id":1,"name":"renamed","status":2}`); !env.Success {
+		t.Fatalf("update channel failed: %s", env.Message)
+	}
+	if stored, _ := channels.GetChannel(1); stored.Name != "renamed" || stored.Status != channel.StatusManuallyDisabled || stored.Models != "claude-x" {
+		t.Fatalf("expected a partial update, got %+v", stored)
+	}
+	if env := call(http.MethodGet, "/api/channel/search?keyword=renamed", ""); !strings.Contains(string(env.Data), `"renamed"`) {
+		t.Fatalf("expected search to find the channel, got %s", env.Data)
+	}
+	if env := call(http.MethodGet, "/api/channel/99", ""); env.Success {
+		t.Fatal("expected success=false for a missing channel")
+	}
+	if env := call(http.MethodDelete, "/api/channel/2", ""); !env.Success {
+		t.Fatalf("delete failed: %s", env.Message)
+	}
+
+	if env := call(http.MethodPost, "/api/user/", `{"username":"alice","password":"secret123","display_name":"Alice","role":10,"group":"vip","quota":500}`); !env.Success {
+		t.Fatalf("create user failed: %s", env.Message)
+	}
+	var users []map[string]any
+	_ = json.Unmarshal(call(http.MethodGet, "/api/user/search?keyword=ali", "").Data, &users)
+	if len(users) != 1 {
+		t.Fatalf("expected one user, got %+v", users)
+	}
+	user := users[0]
+	if user["role"] != float64(10) || user["group"] != "vip" || user["quota"] != float64(500) || user["password"] != nil {
+		t.Fatalf("unexpected user %+v", user)
+	}
+	userID, _ := user["id"].(string)
+
+	if env := call(http.MethodPost, "/api/token/", `{"name":"cli","remain_quota":1000,"expired_time":-1}`); env.Success {
+		t.Fatal("expected token creation without user_id to fail")
+	}
+	env = call(http.MethodPost, "/api/token/", `{"user_id":"`+userID+`","name":"cli","remain_quota":1000,"expired_time":-1}`)
+	var created map[string]any
+	_ = json.Unmarshal(env.Data, &created)
+	if !env.Success || created["name"] != "cli" || created["remain_quota"] != float64(1000) || strings.HasPrefix(created["key"].(string), "sk-") {
+		t.Fatalf("unexpected token %s (%s)", env.Data, env.Message)
+	}
+	id := created["id"].(float64)
+	if env := call(http.MethodPut, "/api/token/?status_only=1", `{"id":`+jsonNumber(id)+`,"status":2,"name":"ignored"}`); !env.Success || !strings.Contains(string(env.Data), `"status":2`) || !strings.Contains(string(env.Data), `"name":"cli"`) {
+		t.Fatalf("expected a status-only update, got %s (%s)", env.Data, env.Message)
+	}
+	if env := call(http.MethodGet, "/api/token/", ""); !strings.Contains(string(env.Data), `"cli"`) {
+		t.Fatalf("expected the token in the list, got %s", env.Data)
+	}
+}
+
+func jsonNumber(v float64) string {
+	raw, _ := json.Marshal(v)
+	return string(raw)
+}
+
+func TestOneAPIManagementIsAuditedAndAdminRestricted(t *testing.T) {
+	audit, err := auditlog.NewStore(filepath.Join(t.TempDir(), "audit.jsonl"))
+	if err != nil {
+		t.Fatalf("new audit store: %v", err)
+	}
+	policy, _ := ipaccess.NewPolicy(ipaccess.Config{AdminAllow: []string{"10.0.0.0/8"}})
+	router := newTestRouterWithDeps(t, Dependencies{
+		AdminToken:   "secret-admin",
+		ChannelStore: channel.NewAbilityStore(),
+		AdminAudit:   audit,
+		IPAccess:     policy,
+	})
+	call := func(method, path, body, remote string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("authorization", "secret-admin")
+		req.RemoteAddr = remote
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	for _, path := range []string{"/api/channel/", "/api/token/", "/api/user/"} {
+		if rr := call(http.MethodGet, path, "", "203.0.113.4:4000"); rr.Code != http.StatusForbidden {
+			t.Fatalf("expected 403 for %s outside the admin allowlist, got %d", path, rr.Code)
+		}
+	}
+	if rr := call(http.MethodPost, "/api/channel/", `{"name":"c1","key":"k"}`, "10.0.0.5:4000"); rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 creating a channel, got %d: %s", rr.Code, rr.Body.String())
+	}
+	entries, _ := audit.List(auditlog.Query{Path: "/api/channel", Limit: 10})
+	if len(entries) != 1 || entries[0].Method != http.MethodPost || entries[0].Actor != auditlog.ActorID("secret-admin") {
+		t.Fatalf("expected the channel create audited, got %+v", entries)
+	}
+
+	rr := call(http.MethodPatch, "/api/channel/", `{}`, "10.0.0.5:4000")
+	var env oneAPIEnvelope
+	if rr.Code != http.StatusMethodNotAllowed || json.Unmarshal(rr.Body.Bytes(), &env) != nil || env.Success || env.Message == "" {
+		t.Fatalf("expected a one-api envelope for 405, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
@@ -3,6 +3,7 @@ package gateway_test
 import (
 	"ccgateway/internal/ccevent"
 	. "ccgateway/internal/gateway"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -10,6 +11,7 @@ import (
 	"testing"
 
 	"ccgateway/internal/modelmap"
+	"ccgateway/internal/orchestrator"
 	"ccgateway/internal/policy"
 	"ccgateway/internal/settings"
 	"ccgateway/internal/upstream"
@@ -956,3 +958,62 @@ func TestOpenAIResponsesStreamAutoToolFallbackWhenUpstreamUnsupported(t *testing
 		t.Fatalf("expected tool.fallback_applied event")
 	}
 }
+
+type fencedJSONService struct {
+	captured orchestrator.Request
+}
+
+func (s *fencedJSONService) Complete(_ context.Context, req orchestrator.Request) (orchestrator.Response, error) {
+	s.captured = req
+	return orchestrator.Response{
+		Model:      req.Model,
+		Blocks:     []orchestrator.AssistantBlock{{Type: "text", Text: "```json\n{\"answer\":\"yes\",}\n```"}},
+		StopReason: "end_turn",
+	}, nil
+}
+
+func (s *fencedJSONService) Stream(ctx context.Context, req orchestrator.Request) (<-chan orchestrator.StreamEvent, <-chan error) {
+	return orchestrator.NewSimpleService().Stream(ctx, req)
+}
+
+func TestOpenAIChatCompletionsResponseFormatRepair(t *testing.T) {
+	svc := &fencedJSONService{}
+	events := ccevent.NewStore()
+	router := newTestRouterWithDeps(t, Dependencies{Orchestrator: svc, EventStore: events})
+	body := `{
+		"model":"claude-test",
+		"messages":[{"role":"user","content":"answer in json"}],
+		"response_format":{"type":"json_schema","json_schema":{"name":"answer","strict":true,"schema":{"type":"object","required":["answer"],"properties":{"answer":{"type":"string"}}}}}
+	}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d; body=%s", rr.Code, rr.Body.String())
+	}
+	if f := svc.captured.ResponseFormat; f == nil || f.Type != "json_schema" || f.Name != "answer" || !f.Strict || f.Schema == nil {
+		t.Fatalf("expected response_format in canonical request, got %+v", svc.captured.ResponseFormat)
+	}
+	var resp OpenAIChatCompletionsResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("unmarshal response: %v", err)
+	}
+	if got := resp.Choices[0].Message.Content; got != `{"answer":"yes"}` {
+		t.Fatalf("expected repaired JSON content, got %q", got)
+	}
+	repairs := events.List(ccevent.ListFilter{EventType: "format.repair_applied"})
+	if len(repairs) != 1 {
+		t.Fatalf("expected one format.repair_applied event, got %d", len(repairs))
+	}
+	if len(events.List(ccevent.ListFilter{EventType: "format.validation_failed"})) != 0 {
+		t.Fatalf("expected repaired output to pass schema validation")
+	}
+
+	bad := `{"model":"claude-test","messages":[{"role":"user","content":"x"}],"response_format":{"type":"json_schema"}}`
+	req = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(bad))
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for json_schema without schema, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,99 @@
+package gateway_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ccgateway/internal/ccrun"
+	. "ccgateway/internal/gateway"
+	"ccgateway/internal/statepersist"
+)
+
+type stubPersistenceHealth struct {
+	status statepersist.HealthStatus
+}
+
+func (s *stubPersistenceHealth) Health() statepersist.HealthStatus { return s.status }
+
+func TestPersistenceDegradedFlipsReadinessAndBlocksStateWrites(t *testing.T) {
+	health := &stubPersistenceHealth{}
+	router := newTestRouterWithDeps(t, Dependencies{
+		RunStore:    ccrun.NewStore(),
+		Persistence: health,
+	})
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected ready, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	health.status = statepersist.HealthStatus{Degraded: true, ReadOnly: true, ConsecutiveFailures: 3, LastError: "disk full"}
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 readiness while degraded, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	var body map[string]any
+	_ = json.Unmarshal(rr.Body.Bytes(), &body)
+	if rr.Code != http.StatusOK || body["ready"] != false || body["degraded"] != true {
+		t.Fatalf("expected live-but-degraded healthz, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/v1/cc/todos", strings.NewReader(`{"title":"t"}`)))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected write to be rejected, got %d: %s", rr.Code, rr.Body.String())
+	}
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/cc/runs", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected reads to keep working, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+type stubPersistenceRepairer struct {
+	stubPersistenceHealth
+	pending int
+	flushes int
+}
+
+func (s *stubPersistenceRepairer) Repairs() statepersist.RepairStatus {
+	return statepersist.RepairStatus{Pending: s.pending}
+}
+
+func (s *stubPersistenceRepairer) FlushRepairs() statepersist.RepairStatus {
+	s.flushes++
+	s.pending = 0
+	return statepersist.RepairStatus{Repaired: 2}
+}
+
+func TestAdminPersistenceRepairsListAndFlush(t *testing.T) {
+	persistence := &stubPersistenceRepairer{pending: 2}
+	persistence.status.PendingRepairs = 2
+	router := newTestRouterWithDeps(t, Dependencies{Persistence: persistence, AdminToken: "secret-admin"})
+	do := func(method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("authorization", "Bearer secret-admin")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := do(http.MethodGet, "/admin/status")
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"pending_repairs":2`) || !strings.Contains(rr.Body.String(), `"repairs":{"pending":2`) {
+		t.Fatalf("expected pending repairs in status: %d %s", rr.Code, rr.Body.String())
+	}
+	if rr := do(http.MethodGet, "/admin/persistence/repairs"); rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"pending":2`) {
+		t.Fatalf("list: %d %s", rr.Code, rr.Body.String())
+	}
+	if rr := do(http.MethodPost, "/admin/persistence/repairs"); rr.Code != http.StatusOK || persistence.flushes != 1 || !strings.Contains(rr.Body.String(), `"repaired_total":2`) {
+		t.Fatalf("flush: %d %s", rr.Code, rr.Body.String())
+	}
+}
@@ -0,0 +1,83 @@
+package gateway_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ccgateway/internal/ccevent"
+	. "ccgateway/internal/gateway"
+	"ccgateway/internal/plugin"
+)
+
+func TestPluginMiddlewareRewritesRequestAndResponsePerProject(t *testing.T) {
+	exec := plugin.NewExecutor()
+	exec.RegisterRuntime(plugin.RuntimeScript, plugin.RuntimeFunc(func(_ context.Context, cfg plugin.MiddlewareConfig, input []byte) ([]byte, error) {
+		var env map[string]any
+		_ = json.Unmarshal(input, &env)
+		switch cfg.Entrypoint {
+		case "tag-system":
+			req := env["request"].(map[string]any)
+			req["system"] = "[tagged]"
+			return json.Marshal(map[string]any{"request": req})
+		case "shout":
+			resp := env["response"].(map[string]any)
+			for _, b := range resp["blocks"].([]any) {
+				block := b.(map[string]any)
+				block["text"] = strings.ToUpper(block["text"].(string))
+			}
+			return json.Marshal(map[string]any{"response": resp})
+		default:
+			return []byte(`{"error":"not today"}`), nil
+		}
+	}))
+	store := plugin.NewManager()
+	install := func(name string, mw plugin.MiddlewareConfig) {
+		t.Helper()
+		if err := store.Install(plugin.Plugin{Name: name, Middleware: &mw}); err != nil {
+			t.Fatalf("install %s: %v", name, err)
+		}
+	}
+	install("tagger", plugin.MiddlewareConfig{Entrypoint: "tag-system", Stages: []string{"request"}})
+	install("prj_acme::shouter", plugin.MiddlewareConfig{Entrypoint: "shout", Stages: []string{"response"}})
+	install("flaky", plugin.MiddlewareConfig{Entrypoint: "flaky", Order: 5})
+
+	events := ccevent.NewStore()
+	svc := &captureService{}
+	router := newTestRouterWithDeps(t, Dependencies{
+		Orchestrator:     svc,
+		PluginStore:      store,
+		PluginMiddleware: exec,
+		EventStore:       events,
+	})
+	send := func(project string) string {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(`{"model":"claude-test","max_tokens":16,"messages":[{"role":"user","content":"hi"}]}`))
+		req.Header.Set("anthropic-version", "2023-06-01")
+		if project != "" {
+			req.Header.Set("x-cc-project", project)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		return rr.Body.String()
+	}
+
+	if body := send(""); !strings.Contains(body, `"text":"ok"`) {
+		t.Fatalf("a project plugin must not run for other projects: %s", body)
+	}
+	if svc.capturedReq.System != "[tagged]" {
+		t.Fatalf("expected the global plugin to rewrite the request, got %#v", svc.capturedReq.System)
+	}
+	if body := send("acme"); !strings.Contains(body, `"text":"OK"`) {
+		t.Fatalf("expected the project plugin to rewrite the response: %s", body)
+	}
+	if failed := events.List(ccevent.ListFilter{EventType: "plugin.middleware_failed"}); len(failed) != 4 || failed[0].Data["plugin"] != "flaky" {
+		t.Fatalf("expected the failing plugin to be skipped and recorded at both stages, got %+v", failed)
+	}
+}
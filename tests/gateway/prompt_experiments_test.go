@@ -0,0 +1,188 @@
+package gateway_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"ccgateway/internal/ccevent"
+	"ccgateway/internal/ccrun"
+	. "ccgateway/internal/gateway"
+	"ccgateway/internal/settings"
+)
+
+func TestPromptExperimentSplitsFeedbackAndAutoPromotes(t *testing.T) {
+	cfg := settings.DefaultRuntimeSettings()
+	cfg.Routing.ReflectionPasses = -1
+	cfg.PromptExperiments = map[string]settings.PromptExperiment{
+		"chat": {
+			Enabled: true,
+			Variants: []settings.PromptVariant{
+				{Name: "terse", Prefix: "Be terse."},
+				{Name: "thorough", Prefix: "Be thorough."},
+			},
+			AutoPromote: true,
+			MinSamples:  3,
+			Confidence:  0.9,
+		},
+	}
+	store := settings.NewStore(cfg)
+	runs := ccrun.NewStore()
+	events := ccevent.NewStore()
+	svc := &captureService{}
+	router := newTestRouterWithDeps(t, Dependencies{
+		Orchestrator: svc,
+		Settings:     store,
+		RunStore:     runs,
+		EventStore:   events,
+	})
+
+	send := func() string {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(`{"model":"claude-test","max_tokens":16,"system":"You help.","messages":[{"role":"user","content":"hi"}]}`))
+		req.Header.Set("anthropic-version", "2023-06-01")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d; body=%s", rr.Code, rr.Body.String())
+		}
+		return rr.Header().Get("x-cc-run-id")
+	}
+	feedback := func(runID string, rating int) *httptest.ResponseRecorder {
+		t.Helper()
+		body := `{"rating":` + strconv.Itoa(rating) + `}`
+		req := httptest.NewRequest(http.MethodPost, "/v1/cc/runs/"+runID+"/feedback", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	seen := map[string]int{}
+	for i := 0; i < 60 && (seen["terse"] < 3 || seen["thorough"] < 3); i++ {
+		runID := send()
+		variant, _ := svc.capturedReq.Metadata["prompt_variant"].(string)
+		system, _ := svc.capturedReq.System.(string)
+		want := map[string]string{"terse": "Be terse.\n\nYou help.", "thorough": "Be thorough.\n\nYou help."}[variant]
+		if want == "" || system != want {
+			t.Fatalf("variant %q got system %q", variant, system)
+		}
+		run, ok := runs.Get(runID)
+		if !ok || run.Metadata["prompt_variant"] != variant || run.Metadata["prompt_experiment"] != "chat" {
+			t.Fatalf("run not attributed to variant %q: %+v", variant, run.Metadata)
+		}
+		seen[variant]++
+		if seen[variant] > 3 {
+			continue
+		}
+		rating := 1
+		if variant == "thorough" {
+			rating = -1
+		}
+		if rr := feedback(runID, rating); rr.Code != http.StatusOK {
+			t.Fatalf("feedback: %d %s", rr.Code, rr.Body.String())
+		}
+	}
+	if seen["terse"] < 3 || seen["thorough"] < 3 {
+		t.Fatalf("traffic was not split across variants: %+v", seen)
+	}
+	if got := store.Get().PromptExperiments["chat"].Promoted; got != "terse" {
+		t.Fatalf("expected terse to be promoted, got %q", got)
+	}
+	for i := 0; i < 5; i++ {
+		send()
+		if svc.capturedReq.Metadata["prompt_variant"] != "terse" || svc.capturedReq.Metadata["prompt_cohort"] != "promoted" {
+			t.Fatalf("promoted variant should take all traffic: %+v", svc.capturedReq.Metadata)
+		}
+	}
+
+	var promoted bool
+	for _, ev := range events.List(ccevent.ListFilter{}) {
+		promoted = promoted || ev.EventType == "prompt_experiment.promoted"
+	}
+	if !promoted {
+		t.Fatalf("expected a prompt_experiment.promoted event")
+	}
+	if rr := feedback(send(), 3); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for bad rating, got %d", rr.Code)
+	}
+	if rr := feedback("run_missing", 1); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown run, got %d", rr.Code)
+	}
+}
+
+func TestAdminPromptExperimentsReportAndManualPromote(t *testing.T) {
+	cfg := settings.DefaultRuntimeSettings()
+	cfg.PromptExperiments = map[string]settings.PromptExperiment{
+		"plan": {
+			Enabled:  true,
+			Variants: []settings.PromptVariant{{Name: "a", Prefix: "A"}, {Name: "b", Prefix: "B"}},
+		},
+	}
+	store := settings.NewStore(cfg)
+	runs := ccrun.NewStore()
+	for i, seed := range []struct {
+		variant string
+		score   float64
+	}{{"a", 8}, {"a", 6}, {"b", 4}} {
+		id := "run_" + strconv.Itoa(i)
+		if _, err := runs.Create(ccrun.CreateInput{ID: id, Path: "/v1/messages", Mode: "plan", Metadata: map[string]any{"prompt_experiment": "plan", "prompt_variant": seed.variant}}); err != nil {
+			t.Fatalf("create run: %v", err)
+		}
+		if _, err := runs.RecordScore(id, ccrun.Score{JudgeVersion: "v1", Score: seed.score}); err != nil {
+			t.Fatalf("score run: %v", err)
+		}
+	}
+	router := newTestRouterWithDeps(t, Dependencies{Settings: store, RunStore: runs, AdminToken: "secret-admin"})
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("authorization", "Bearer secret-admin")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := do(http.MethodGet, "/admin/prompt-experiments?mode=plan", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("report: %d %s", rr.Code, rr.Body.String())
+	}
+	var report struct {
+		Data []struct {
+			Mode       string `json:"mode"`
+			MinSamples int    `json:"min_samples"`
+			Ready      bool   `json:"ready"`
+			Leader     string `json:"leader"`
+			Variants   []struct {
+				Variant string  `json:"variant"`
+				Runs    int     `json:"runs"`
+				Scored  int     `json:"scored"`
+				Mean    float64 `json:"mean"`
+			} `json:"variants"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(report.Data) != 1 || report.Data[0].MinSamples != 30 || report.Data[0].Ready {
+		t.Fatalf("unexpected report: %+v", report.Data)
+	}
+	got := report.Data[0]
+	if len(got.Variants) != 2 || got.Variants[0].Runs != 2 || got.Variants[0].Mean != 0.7 || got.Variants[1].Scored != 1 || got.Leader != "a" {
+		t.Fatalf("unexpected variant stats: %+v", got)
+	}
+
+	if rr := do(http.MethodPost, "/admin/prompt-experiments/plan/promote", `{"variant":"b"}`); rr.Code != http.StatusOK {
+		t.Fatalf("promote: %d %s", rr.Code, rr.Body.String())
+	}
+	if store.Get().PromptExperiments["plan"].Promoted != "b" {
+		t.Fatalf("expected b to be promoted")
+	}
+	if rr := do(http.MethodPost, "/admin/prompt-experiments/plan/promote", `{"variant":"zzz"}`); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown variant, got %d", rr.Code)
+	}
+	if rr := do(http.MethodPost, "/admin/prompt-experiments/plan/promote", `{}`); rr.Code != http.StatusOK || store.Get().PromptExperiments["plan"].Promoted != "" {
+		t.Fatalf("empty variant should resume the split, got %d", rr.Code)
+	}
+}
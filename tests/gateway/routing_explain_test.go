@@ -0,0 +1,112 @@
+package gateway_test
+
+import (
+	. "ccgateway/internal/gateway"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"ccgateway/internal/orchestrator"
+	"ccgateway/internal/scheduler"
+	"ccgateway/internal/settings"
+	"ccgateway/internal/upstream"
+)
+
+// callCountingAdapter counts the calls it gets.
+type callCountingAdapter struct {
+	name  string
+	calls *int64
+}
+
+func (a callCountingAdapter) Name() string { return a.name }
+
+func (a callCountingAdapter) Complete(_ context.Context, req orchestrator.Request) (orchestrator.Response, error) {
+	atomic.AddInt64(a.calls, 1)
+	return orchestrator.Response{Model: req.Model, Blocks: []orchestrator.AssistantBlock{{Type: "text", Text: "ok"}}, StopReason: "end_turn"}, nil
+}
+
+func TestAdminRoutingExplainTracesWithoutCallingUpstream(t *testing.T) {
+	election := scheduler.NewElection(scheduler.ElectionConfig{Enabled: true})
+	dispatcher := upstream.NewDispatcher(upstream.DispatchConfig{Enabled: true}, election)
+	election.UpdateScores([]scheduler.IntelligenceScore{
+		{AdapterName: "smart", Score: 95},
+		{AdapterName: "worker", Score: 60},
+	})
+	var calls int64
+	svc := upstream.NewRouterService(upstream.RouterConfig{Dispatcher: dispatcher}, []upstream.Adapter{
+		callCountingAdapter{name: "smart", calls: &calls},
+		callCountingAdapter{name: "worker", calls: &calls},
+		callCountingAdapter{name: "planner", calls: &calls},
+	})
+	cfg := settings.DefaultRuntimeSettings()
+	cfg.Routing.ModeRoutes = map[string][]string{"plan": {"planner", "smart"}}
+	router := newTestRouterWithDeps(t, Dependencies{
+		Orchestrator: svc,
+		Settings:     settings.NewStore(cfg),
+		AdminToken:   "secret-admin",
+	})
+
+	explain := func(body string) (int, map[string]any) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/admin/routing/explain", strings.NewReader(body))
+		req.Header.Set("authorization", "Bearer secret-admin")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		var out map[string]any
+		_ = json.Unmarshal(rr.Body.Bytes(), &out)
+		return rr.Code, out
+	}
+	step := func(out map[string]any, name string) map[string]any {
+		t.Helper()
+		trace, _ := out["trace"].([]any)
+		for _, raw := range trace {
+			if st, _ := raw.(map[string]any); st["step"] == name {
+				return st
+			}
+		}
+		t.Fatalf("no %s step in %v", name, out)
+		return nil
+	}
+
+	code, out := explain(`{"model":"m","messages":[{"role":"user","content":"hi"}]}`)
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %v", code, out)
+	}
+	dispatch := step(out, "dispatcher")
+	if dispatch["applied"] != true || dispatch["detail"].(map[string]any)["source"] != "dispatcher" {
+		t.Fatalf("expected a dispatcher decision, got %v", dispatch)
+	}
+	if adapters, _ := out["adapters"].([]any); len(adapters) == 0 || adapters[0] != "worker" {
+		t.Fatalf("expected the worker first, got %v", out["adapters"])
+	}
+
+	code, out = explain(`{"model":"m","mode":"plan","headers":{"x-cc-exclude-adapters":"smart"}}`)
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %v", code, out)
+	}
+	if st := step(out, "mode_route"); st["applied"] != true {
+		t.Fatalf("expected the mode route, got %v", st)
+	}
+	if st := step(out, "adapter_override"); st["applied"] != true {
+		t.Fatalf("expected the exclusion, got %v", st)
+	}
+	if adapters, _ := out["adapters"].([]any); len(adapters) != 1 || adapters[0] != "planner" {
+		t.Fatalf("expected only the planner, got %v", out["adapters"])
+	}
+
+	if code, out = explain(`{"model":"m","headers":{"x-cc-adapter":"nope"}}`); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unknown adapter, got %d: %v", code, out)
+	}
+	if code, _ = explain(`{"messages":[]}`); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 without a model, got %d", code)
+	}
+
+	stats := dispatcher.Snapshot()["stats"].(map[string]int64)
+	if calls != 0 || stats["simple_routed"] != 0 || stats["complex_routed"] != 0 {
+		t.Fatalf("explain must not call upstream or count dispatches: calls=%d stats=%v", calls, stats)
+	}
+}
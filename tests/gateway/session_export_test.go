@@ -0,0 +1,107 @@
+package gateway_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ccgateway/internal/ccevent"
+	"ccgateway/internal/ccrun"
+	. "ccgateway/internal/gateway"
+	"ccgateway/internal/session"
+	"ccgateway/internal/settings"
+	"ccgateway/internal/toolruntime"
+)
+
+func TestSessionExportInlinesToolCalls(t *testing.T) {
+	registry := toolruntime.NewRegistry()
+	registry.Register("kb_search", func(context.Context, toolruntime.Call) (toolruntime.Result, error) {
+		return toolruntime.Result{Content: "Refunds take <5> days"}, nil
+	})
+	cfg := settings.DefaultRuntimeSettings()
+	cfg.ToolLoop.Mode = "server_loop"
+	cfg.Routing.ReflectionPasses = -1
+	sessions := session.NewStore()
+	router := newTestRouterWithDeps(t, Dependencies{
+		Orchestrator: &citingService{},
+		Settings:     settings.NewStore(cfg),
+		ToolExecutor: registry,
+		SessionStore: sessions,
+		RunStore:     ccrun.NewStore(),
+		EventStore:   ccevent.NewStore(),
+	})
+	sess, err := sessions.Create(session.CreateInput{Title: "refund debugging"})
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+
+	body := `{"model":"claude-test","max_tokens":128,"metadata":{"session_id":"` + sess.ID + `"},"messages":[{"role":"user","content":"refunds?"}],"tools":[{"name":"kb_search","input_schema":{"type":"object"}}]}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(body))
+	req.Header.Set("anthropic-version", "2023-06-01")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	export := func(format string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/v1/cc/sessions/"+sess.ID+"/export?format="+format, nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr = export("json")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var transcript struct {
+		Runs []struct {
+			ID        string `json:"id"`
+			ToolCalls []struct {
+				Tool   string         `json:"tool"`
+				Input  map[string]any `json:"input"`
+				Output string         `json:"output"`
+			} `json:"tool_calls"`
+		} `json:"runs"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &transcript); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(transcript.Runs) != 1 || len(transcript.Runs[0].ToolCalls) != 1 {
+		t.Fatalf("expected one run with one tool call, got %s", rr.Body.String())
+	}
+	call := transcript.Runs[0].ToolCalls[0]
+	if call.Tool != "kb_search" || call.Input["q"] != "refunds" || call.Output != "Refunds take <5> days" {
+		t.Fatalf("unexpected tool call: %#v", call)
+	}
+
+	rr = export("")
+	if ct := rr.Header().Get("content-type"); !strings.HasPrefix(ct, "text/markdown") {
+		t.Fatalf("expected markdown by default, got %q", ct)
+	}
+	md := rr.Body.String()
+	for _, want := range []string{"# Session refund debugging", "## Run 1: `" + transcript.Runs[0].ID + "`", "### Tool call: kb_search `toolu_1`", "\"q\": \"refunds\"", "Refunds take <5> days"} {
+		if !strings.Contains(md, want) {
+			t.Fatalf("expected %q in markdown:\n%s", want, md)
+		}
+	}
+
+	html := export("html").Body.String()
+	if !strings.Contains(html, "Refunds take &lt;5&gt; days") || strings.Contains(html, "<5>") {
+		t.Fatalf("expected escaped tool output in html:\n%s", html)
+	}
+
+	if rr := export("pdf"); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unknown format, got %d", rr.Code)
+	}
+	req = httptest.NewRequest(http.MethodGet, "/v1/cc/sessions/missing/export", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for a missing session, got %d", rr.Code)
+	}
+}
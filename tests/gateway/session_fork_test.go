@@ -0,0 +1,99 @@
+package gateway_test
+
+import (
+	. "ccgateway/internal/gateway"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"ccgateway/internal/ccrun"
+	"ccgateway/internal/modelmap"
+	"ccgateway/internal/orchestrator"
+	"ccgateway/internal/policy"
+	"ccgateway/internal/session"
+)
+
+func TestCCSessionForkAtRunCopiesHistoryUpToRun(t *testing.T) {
+	st := session.NewStore()
+	runs := ccrun.NewStore()
+	parent, err := st.Create(session.CreateInput{ID: "sess_parent", Title: "parent"})
+	if err != nil {
+		t.Fatalf("create parent: %v", err)
+	}
+	var runIDs []string
+	for _, turn := range [][2]string{{"first question", "first answer"}, {"second question", "second answer"}} {
+		run, err := runs.Create(ccrun.CreateInput{SessionID: parent.ID, Path: "/v1/messages"})
+		if err != nil {
+			t.Fatalf("create run: %v", err)
+		}
+		if _, err := runs.Complete(run.ID, ccrun.CompleteInput{StatusCode: 200, Prompt: turn[0], Output: turn[1]}); err != nil {
+			t.Fatalf("complete run: %v", err)
+		}
+		runIDs = append(runIDs, run.ID)
+	}
+	other, _ := runs.Create(ccrun.CreateInput{SessionID: "sess_other", Path: "/v1/messages"})
+
+	router := newTestRouterWithDeps(t, Dependencies{
+		Orchestrator: orchestrator.NewSimpleService(),
+		Policy:       policy.NewNoopEngine(),
+		ModelMapper:  modelmap.NewIdentityMapper(),
+		SessionStore: st,
+		RunStore:     runs,
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/cc/sessions/"+parent.ID+"/fork?at_run="+runIDs[0], nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d; body=%s", rr.Code, rr.Body.String())
+	}
+	var child session.Session
+	if err := json.Unmarshal(rr.Body.Bytes(), &child); err != nil {
+		t.Fatalf("unmarshal child: %v", err)
+	}
+	if child.ParentID != parent.ID || child.Metadata["forked_at_run"] != runIDs[0] {
+		t.Fatalf("unexpected child session: %#v", child)
+	}
+	if len(child.Messages) != 2 || child.Messages[0].Role != "user" || child.Messages[0].Content != "first question" ||
+		child.Messages[1].Role != "assistant" || child.Messages[1].Content != "first answer" {
+		t.Fatalf("expected only the first turn, got %#v", child.Messages)
+	}
+	if got, _ := st.Get(parent.ID); len(got.Messages) != 0 || len(got.Metadata) != 0 {
+		t.Fatalf("parent must not change, got %#v", got)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/v1/cc/sessions/"+parent.ID+"/fork?at_run="+runIDs[1], nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	child = session.Session{}
+	_ = json.Unmarshal(rr.Body.Bytes(), &child)
+	if rr.Code != http.StatusCreated || len(child.Messages) != 4 {
+		t.Fatalf("expected both turns, got %d; body=%s", rr.Code, rr.Body.String())
+	}
+
+	// A plain fork copies the stored messages of its parent.
+	req = httptest.NewRequest(http.MethodPost, "/v1/cc/sessions/"+child.ID+"/fork", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	var grandchild session.Session
+	_ = json.Unmarshal(rr.Body.Bytes(), &grandchild)
+	if rr.Code != http.StatusCreated || len(grandchild.Messages) != 4 {
+		t.Fatalf("expected plain fork to copy messages, got %d; body=%s", rr.Code, rr.Body.String())
+	}
+
+	for _, tc := range []struct {
+		runID string
+		want  int
+	}{
+		{runID: "run_missing", want: http.StatusNotFound},
+		{runID: other.ID, want: http.StatusBadRequest},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/v1/cc/sessions/"+parent.ID+"/fork?at_run="+tc.runID, nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != tc.want {
+			t.Fatalf("at_run=%s: expected %d, got %d; body=%s", tc.runID, tc.want, rr.Code, rr.Body.String())
+		}
+	}
+}
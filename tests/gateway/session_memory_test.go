@@ -0,0 +1,140 @@
+package gateway_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"ccgateway/internal/ccevent"
+	. "ccgateway/internal/gateway"
+	"ccgateway/internal/memory"
+	"ccgateway/internal/settings"
+)
+
+type fakeDistiller struct {
+	mu       sync.Mutex
+	requests []memory.DistillRequest
+}
+
+func (d *fakeDistiller) SummarizeRecent(context.Context, []memory.Message) (string, error) {
+	return "unused", nil
+}
+
+func (d *fakeDistiller) SummarizeSession(context.Context, string) (*memory.SessionMemory, error) {
+	return nil, nil
+}
+
+func (d *fakeDistiller) ExtractKeyInfo(context.Context, []memory.Message) (map[string]interface{}, error) {
+	return nil, nil
+}
+
+func (d *fakeDistiller) Distill(_ context.Context, req memory.DistillRequest) (memory.Distillation, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.requests = append(d.requests, req)
+	return memory.Distillation{Summary: "User is porting the billing service to Go.", Facts: []string{"User prefers tabs"}}, nil
+}
+
+func (d *fakeDistiller) calls() []memory.DistillRequest {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]memory.DistillRequest(nil), d.requests...)
+}
+
+func newMemoryTestRouter(t *testing.T, enabled bool) (http.Handler, *captureService, *fakeDistiller, memory.MemoryStore, *ccevent.Store) {
+	t.Helper()
+	cfg := settings.DefaultRuntimeSettings()
+	cfg.Routing.ReflectionPasses = -1
+	cfg.Memory = settings.MemorySettings{Enabled: enabled, SummarizerModel: "cheap-model", SummarizeAfter: 4, KeepRecent: 2}
+	svc := &captureService{}
+	distiller := &fakeDistiller{}
+	store := memory.NewInMemoryStore()
+	events := ccevent.NewStore()
+	router := newTestRouterWithDeps(t, Dependencies{
+		Orchestrator: svc,
+		Settings:     settings.NewStore(cfg),
+		EventStore:   events,
+		MemoryStore:  store,
+		Summarizer:   distiller,
+	})
+	return router, svc, distiller, store, events
+}
+
+func sendMemoryTurn(t *testing.T, router http.Handler, body string) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(body))
+	req.Header.Set("anthropic-version", "2023-06-01")
+	req.Header.Set("x-cc-session-id", "sess-mem")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d; body=%s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestSessionMemoryDistillsAndInjectsIntoLaterRequests(t *testing.T) {
+	router, svc, distiller, store, events := newMemoryTestRouter(t, true)
+	turn := `{"model":"claude-test","max_tokens":16,"system":"You help.","messages":[{"role":"user","content":"turn %s"}]}`
+	sendMemoryTurn(t, router, strings.Replace(turn, "%s", "one", 1))
+	if system, _ := svc.capturedReq.System.(string); system != "You help." {
+		t.Fatalf("nothing remembered yet, got system %q", system)
+	}
+	sendMemoryTurn(t, router, strings.Replace(turn, "%s", "two", 1))
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(events.List(ccevent.ListFilter{EventType: "memory.updated"})) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("session memory was not distilled")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	sm, _ := store.GetSessionMemory(context.Background(), "sess-mem")
+	if sm.Summary == "" || len(sm.Facts) != 1 || sm.Facts[0].Text != "User prefers tabs" || sm.Facts[0].RunID == "" {
+		t.Fatalf("unexpected session memory: %+v", sm)
+	}
+	calls := distiller.calls()
+	if len(calls) != 1 || calls[0].Model != "cheap-model" || len(calls[0].Messages) != 2 || calls[0].Messages[0].Content != "turn one" {
+		t.Fatalf("unexpected distill requests: %+v", calls)
+	}
+	wm, _ := store.GetWorkingMemory(context.Background(), "sess-mem")
+	if len(wm.Messages) != 2 || wm.Messages[0].Content != "turn two" {
+		t.Fatalf("expected the latest turn to stay verbatim, got %+v", wm.Messages)
+	}
+
+	sendMemoryTurn(t, router, strings.Replace(turn, "%s", "three", 1))
+	system, _ := svc.capturedReq.System.(string)
+	if !strings.HasPrefix(system, "You help.\n\n[CC_MEMORY]") ||
+		!strings.Contains(system, "User is porting the billing service to Go.") ||
+		!strings.Contains(system, "- User prefers tabs") {
+		t.Fatalf("memory not injected: %q", system)
+	}
+
+	sendMemoryTurn(t, router, `{"model":"claude-test","max_tokens":16,"system":"You help.","metadata":{"memory":false},"messages":[{"role":"user","content":"off the record"}]}`)
+	if system, _ := svc.capturedReq.System.(string); system != "You help." {
+		t.Fatalf("metadata opt-out should skip injection, got %q", system)
+	}
+	wm, _ = store.GetWorkingMemory(context.Background(), "sess-mem")
+	for _, msg := range wm.Messages {
+		if msg.Content == "off the record" {
+			t.Fatalf("opted-out turn should not be remembered")
+		}
+	}
+}
+
+func TestSessionMemoryDisabledBySettings(t *testing.T) {
+	router, svc, _, store, _ := newMemoryTestRouter(t, false)
+	for i := 0; i < 3; i++ {
+		sendMemoryTurn(t, router, `{"model":"claude-test","max_tokens":16,"system":"You help.","messages":[{"role":"user","content":"hi"}]}`)
+	}
+	if system, _ := svc.capturedReq.System.(string); system != "You help." {
+		t.Fatalf("unexpected system %q", system)
+	}
+	wm, _ := store.GetWorkingMemory(context.Background(), "sess-mem")
+	if len(wm.Messages) != 0 {
+		t.Fatalf("memory disabled, expected nothing recorded, got %+v", wm.Messages)
+	}
+}
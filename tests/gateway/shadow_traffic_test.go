@@ -0,0 +1,90 @@
+package gateway_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ccgateway/internal/ccevent"
+	. "ccgateway/internal/gateway"
+	"ccgateway/internal/orchestrator"
+	"ccgateway/internal/settings"
+	"ccgateway/internal/upstream"
+)
+
+type namedTextAdapter struct{ name, text string }
+
+func (a namedTextAdapter) Name() string { return a.name }
+
+func (a namedTextAdapter) Complete(_ context.Context, req orchestrator.Request) (orchestrator.Response, error) {
+	return orchestrator.Response{
+		Model:      req.Model,
+		Blocks:     []orchestrator.AssistantBlock{{Type: "text", Text: a.text}},
+		StopReason: "end_turn",
+		Usage:      orchestrator.Usage{InputTokens: 3, OutputTokens: 5},
+	}, nil
+}
+
+func TestShadowModeRecordsShadowAnswerButReturnsPrimary(t *testing.T) {
+	cfg := settings.DefaultRuntimeSettings()
+	cfg.Routing.Shadow = settings.ShadowSettings{Enabled: true, Adapters: []string{"candidate"}, Percent: 100}
+	svc := upstream.NewRouterService(upstream.RouterConfig{DefaultRoute: []string{"primary"}}, []upstream.Adapter{
+		namedTextAdapter{name: "primary", text: "primary answer"},
+		namedTextAdapter{name: "candidate", text: "shadow answer"},
+	})
+	eventStore := ccevent.NewStore()
+	router := newTestRouterWithDeps(t, Dependencies{
+		Orchestrator: svc,
+		Settings:     settings.NewStore(cfg),
+		EventStore:   eventStore,
+	})
+
+	body := `{"model":"claude-test","max_tokens":64,"messages":[{"role":"user","content":"hi"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(body))
+	req.Header.Set("anthropic-version", "2023-06-01")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "primary answer") || strings.Contains(rr.Body.String(), "shadow answer") {
+		t.Fatalf("client must only see the primary answer: %d %s", rr.Code, rr.Body.String())
+	}
+
+	var events []ccevent.Event
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if events = eventStore.List(ccevent.ListFilter{EventType: "shadow.completed", Limit: 10}); len(events) > 0 {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if len(events) != 1 {
+		t.Fatalf("expected one shadow.completed event, got %d", len(events))
+	}
+	data := events[0].Data
+	if data["adapter"] != "candidate" || data["primary_adapter"] != "primary" || data["response_text"] != "shadow answer" || data["judged"] != true {
+		t.Fatalf("unexpected shadow event: %+v", data)
+	}
+	if events[0].RunID == "" {
+		t.Fatalf("shadow event must be tied to the primary run")
+	}
+
+	status := httptest.NewRecorder()
+	router.ServeHTTP(status, httptest.NewRequest(http.MethodGet, "/admin/shadow", nil))
+	var report struct {
+		Adapters []struct {
+			Adapter string `json:"adapter"`
+			Calls   int    `json:"calls"`
+			Judged  int    `json:"judged"`
+		} `json:"adapters"`
+		Config settings.ShadowSettings `json:"config"`
+	}
+	if err := json.Unmarshal(status.Body.Bytes(), &report); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(report.Adapters) != 1 || report.Adapters[0].Calls != 1 || report.Adapters[0].Judged != 1 || !report.Config.Enabled {
+		t.Fatalf("unexpected shadow report: %s", status.Body.String())
+	}
+}
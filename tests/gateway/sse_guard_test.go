@@ -0,0 +1,128 @@
+package gateway_test
+
+import (
+	"bufio"
+	. "ccgateway/internal/gateway"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ccgateway/internal/orchestrator"
+)
+
+func TestMessagesStreamSendsPingsWhileIdle(t *testing.T) {
+	svc := &gatedStreamService{release: make(chan struct{})}
+	srv := httptest.NewServer(newTestRouterWithDeps(t, Dependencies{
+		Orchestrator: svc,
+		SSE:          SSEConfig{PingInterval: 20 * time.Millisecond},
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	body := `{"model":"claude-test","max_tokens":64,"stream":true,"messages":[{"role":"user","content":"hi"}]}`
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, srv.URL+"/v1/messages", strings.NewReader(body))
+	req.Header.Set("anthropic-version", "2023-06-01")
+	req.Header.Set("content-type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("stream request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var lines []string
+	pinged, released := false, false
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if pinged && !released {
+			if line != `data: {"type":"ping"}` {
+				t.Fatalf("expected ping data after the ping event, got %q", line)
+			}
+			close(svc.release)
+			released = true
+		}
+		if line == "event: ping" && !pinged {
+			if len(lines) > 0 && lines[len(lines)-1] != "" {
+				t.Fatalf("ping split an event: previous line %q", lines[len(lines)-1])
+			}
+			pinged = true
+		}
+		lines = append(lines, line)
+	}
+	if !released {
+		t.Fatalf("expected a ping while the stream was idle, got %v", lines)
+	}
+	joined := strings.Join(lines, "\n")
+	if !strings.Contains(joined, `"text":"world"`) || !strings.Contains(joined, "event: message_stop") {
+		t.Fatalf("expected the stream to finish after pings, got %s", joined)
+	}
+}
+
+// stuckWriter accepts writes but blocks every flush until released, like a
+// client that stopped reading.
+type stuckWriter struct {
+	header  http.Header
+	release chan struct{}
+}
+
+func (w *stuckWriter) Header() http.Header         { return w.header }
+func (w *stuckWriter) WriteHeader(int)             {}
+func (w *stuckWriter) Write(p []byte) (int, error) { return len(p), nil }
+func (w *stuckWriter) Flush()                      { <-w.release }
+
+// canceledStreamService streams one event and records when its context
+// ends.
+type canceledStreamService struct {
+	canceled chan struct{}
+}
+
+func (s *canceledStreamService) Complete(ctx context.Context, req orchestrator.Request) (orchestrator.Response, error) {
+	return orchestrator.NewSimpleService().Complete(ctx, req)
+}
+
+func (s *canceledStreamService) Stream(ctx context.Context, req orchestrator.Request) (<-chan orchestrator.StreamEvent, <-chan error) {
+	events := make(chan orchestrator.StreamEvent, 1)
+	errs := make(chan error, 1)
+	go func() {
+		defer close(events)
+		defer close(errs)
+		events <- orchestrator.StreamEvent{Type: "message_start"}
+		<-ctx.Done()
+		close(s.canceled)
+	}()
+	return events, errs
+}
+
+func TestMessagesStreamDropsStuckClient(t *testing.T) {
+	svc := &canceledStreamService{canceled: make(chan struct{})}
+	router := newTestRouterWithDeps(t, Dependencies{
+		Orchestrator: svc,
+		SSE:          SSEConfig{PingInterval: -1, WriteTimeout: 30 * time.Millisecond},
+	})
+	body := `{"model":"claude-test","max_tokens":64,"stream":true,"messages":[{"role":"user","content":"hi"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(body))
+	req.Header.Set("anthropic-version", "2023-06-01")
+	w := &stuckWriter{header: http.Header{}, release: make(chan struct{})}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		router.ServeHTTP(w, req)
+	}()
+
+	select {
+	case <-svc.canceled:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the stream to be cancelled once the client stopped draining")
+	}
+	close(w.release)
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the handler to return after dropping the client")
+	}
+}
@@ -0,0 +1,141 @@
+package gateway_test
+
+import (
+	"bufio"
+	. "ccgateway/internal/gateway"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"ccgateway/internal/orchestrator"
+)
+
+// gatedStreamService streams a first text delta, then waits for release
+// before finishing; it fails if its context ends while waiting.
+type gatedStreamService struct {
+	release chan struct{}
+}
+
+func (s *gatedStreamService) Complete(ctx context.Context, req orchestrator.Request) (orchestrator.Response, error) {
+	return orchestrator.NewSimpleService().Complete(ctx, req)
+}
+
+func (s *gatedStreamService) Stream(ctx context.Context, req orchestrator.Request) (<-chan orchestrator.StreamEvent, <-chan error) {
+	events := make(chan orchestrator.StreamEvent, 8)
+	errs := make(chan error, 1)
+	go func() {
+		defer close(events)
+		defer close(errs)
+		events <- orchestrator.StreamEvent{Type: "message_start"}
+		events <- orchestrator.StreamEvent{Type: "content_block_start", Index: 0, Block: orchestrator.AssistantBlock{Type: "text"}}
+		events <- orchestrator.StreamEvent{Type: "content_block_delta", Index: 0, DeltaText: "hello "}
+		select {
+		case <-s.release:
+		case <-ctx.Done():
+			errs <- errors.New("generation canceled")
+			return
+		}
+		events <- orchestrator.StreamEvent{Type: "content_block_delta", Index: 0, DeltaText: "world"}
+		events <- orchestrator.StreamEvent{Type: "content_block_stop", Index: 0}
+		events <- orchestrator.StreamEvent{Type: "message_delta", StopReason: "end_turn"}
+		events <- orchestrator.StreamEvent{Type: "message_stop"}
+	}()
+	return events, errs
+}
+
+func TestMessagesStreamResumesAfterClientDisconnect(t *testing.T) {
+	svc := &gatedStreamService{release: make(chan struct{})}
+	srv := httptest.NewServer(newTestRouterWithDeps(t, Dependencies{
+		Orchestrator: svc,
+		StreamResume: StreamResumeConfig{Window: time.Minute},
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	body := `{"model":"claude-test","max_tokens":64,"stream":true,"messages":[{"role":"user","content":"hi"}]}`
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, srv.URL+"/v1/messages", strings.NewReader(body))
+	req.Header.Set("anthropic-version", "2023-06-01")
+	req.Header.Set("content-type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("stream request: %v", err)
+	}
+	runID := resp.Header.Get("x-cc-run-id")
+	if runID == "" || resp.Header.Get("x-cc-stream-resume") != "/v1/messages/stream/"+runID {
+		t.Fatalf("expected resume headers, got %v", resp.Header)
+	}
+	received := 0
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if strings.HasPrefix(scanner.Text(), "event: ") {
+			received++
+		}
+		if strings.Contains(scanner.Text(), "hello ") {
+			break
+		}
+	}
+	cancel()
+	resp.Body.Close()
+	close(svc.release)
+
+	resumed := getResumedStream(t, srv.URL+"/v1/messages/stream/"+runID+"?from_event="+strconv.Itoa(received), http.StatusOK)
+	if strings.Contains(resumed, "message_start") || strings.Contains(resumed, "hello ") {
+		t.Fatalf("expected only events after %d, got %s", received, resumed)
+	}
+	if !strings.Contains(resumed, `"text":"world"`) || !strings.Contains(resumed, "event: message_stop") {
+		t.Fatalf("expected the rest of the generation, got %s", resumed)
+	}
+
+	full := getResumedStream(t, srv.URL+"/v1/messages/stream/"+runID, http.StatusOK)
+	if !strings.Contains(full, "event: message_start") || !strings.Contains(full, "hello ") || !strings.Contains(full, "world") {
+		t.Fatalf("expected a full replay from event 0, got %s", full)
+	}
+
+	getResumedStream(t, srv.URL+"/v1/messages/stream/"+runID+"?from_event=-1", http.StatusBadRequest)
+	getResumedStream(t, srv.URL+"/v1/messages/stream/"+runID+"?from_event=99", http.StatusBadRequest)
+	getResumedStream(t, srv.URL+"/v1/messages/stream/run_missing", http.StatusNotFound)
+}
+
+func TestMessagesStreamResumeDisabledByDefault(t *testing.T) {
+	srv := httptest.NewServer(newTestRouter(t))
+	defer srv.Close()
+	body := `{"model":"claude-test","max_tokens":64,"stream":true,"messages":[{"role":"user","content":"hi"}]}`
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/v1/messages", strings.NewReader(body))
+	req.Header.Set("anthropic-version", "2023-06-01")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("stream request: %v", err)
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.Header.Get("x-cc-stream-resume") != "" {
+		t.Fatalf("expected no resume header when disabled")
+	}
+	getResumedStream(t, srv.URL+"/v1/messages/stream/"+resp.Header.Get("x-cc-run-id"), http.StatusNotFound)
+}
+
+func getResumedStream(t *testing.T, url string, wantStatus int) string {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("resume %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("read resumed stream: %v", err)
+	}
+	if resp.StatusCode != wantStatus {
+		t.Fatalf("resume %s: expected %d, got %d; body=%s", url, wantStatus, resp.StatusCode, raw)
+	}
+	return string(raw)
+}
@@ -0,0 +1,151 @@
+package gateway_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ccgateway/internal/ccevent"
+	"ccgateway/internal/ccrun"
+	. "ccgateway/internal/gateway"
+	"ccgateway/internal/orchestrator"
+	"ccgateway/internal/settings"
+)
+
+// brokenStreamService streams events and then either fails with err or
+// just closes. Complete answers with "recovered answer".
+type brokenStreamService struct {
+	events    []orchestrator.StreamEvent
+	err       error
+	completes int
+}
+
+func (s *brokenStreamService) Complete(_ context.Context, req orchestrator.Request) (orchestrator.Response, error) {
+	s.completes++
+	return orchestrator.Response{
+		Model:      req.Model,
+		Blocks:     []orchestrator.AssistantBlock{{Type: "text", Text: "recovered answer"}},
+		StopReason: "end_turn",
+		Usage:      orchestrator.Usage{InputTokens: 3, OutputTokens: 2},
+	}, nil
+}
+
+func (s *brokenStreamService) Stream(_ context.Context, _ orchestrator.Request) (<-chan orchestrator.StreamEvent, <-chan error) {
+	events := make(chan orchestrator.StreamEvent)
+	errs := make(chan error)
+	go func() {
+		for _, ev := range s.events {
+			events <- ev
+		}
+		if s.err != nil {
+			errs <- s.err
+		}
+		close(events)
+		close(errs)
+	}()
+	return events, errs
+}
+
+func postStreamingMessage(t *testing.T, router http.Handler) *httptest.ResponseRecorder {
+	t.Helper()
+	body := `{"model":"claude-test","max_tokens":128,"stream":true,"messages":[{"role":"user","content":"hi"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(body))
+	req.Header.Set("anthropic-version", "2023-06-01")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestStreamTruncationMarksRunAndEmitsEvent(t *testing.T) {
+	eventStore := ccevent.NewStore()
+	runStore := ccrun.NewStore()
+	svc := &brokenStreamService{
+		events: []orchestrator.StreamEvent{
+			{Type: "message_start"},
+			{Type: "content_block_start", Block: orchestrator.AssistantBlock{Type: "text"}},
+			{Type: "content_block_delta", DeltaText: "partial ans"},
+		},
+		err: errors.New("connection reset by peer"),
+	}
+	router := newTestRouterWithDeps(t, Dependencies{Orchestrator: svc, EventStore: eventStore, RunStore: runStore})
+
+	rr := postStreamingMessage(t, router)
+	if !strings.Contains(rr.Body.String(), "connection reset by peer") {
+		t.Fatalf("expected the error to reach the client, got %s", rr.Body.String())
+	}
+	events := eventStore.List(ccevent.ListFilter{EventType: "run.stream_truncated", Limit: 10})
+	if len(events) != 1 {
+		t.Fatalf("expected one truncation event, got %d", len(events))
+	}
+	data := events[0].Data
+	if data["reason"] != "upstream_error" || data["content_bytes"] != len("partial ans") || data["recovered"] != false {
+		t.Fatalf("unexpected event data: %+v", data)
+	}
+	if sum, _ := data["content_sha256"].(string); len(sum) != 64 {
+		t.Fatalf("expected a sha256 checksum, got %v", data["content_sha256"])
+	}
+	if svc.completes != 0 {
+		t.Fatalf("content already reached the client; must not retry")
+	}
+	runs := runStore.List(ccrun.ListFilter{Limit: 10})
+	if len(runs) != 1 || !runs[0].Truncated || runs[0].TruncationReason != "upstream_error" {
+		t.Fatalf("expected truncated run, got %+v", runs)
+	}
+}
+
+func TestStreamWithoutStopIsTruncatedButCleanStreamIsNot(t *testing.T) {
+	eventStore := ccevent.NewStore()
+	clean := &brokenStreamService{events: []orchestrator.StreamEvent{
+		{Type: "message_start", PassThrough: true, RawData: []byte(`{"type":"message_start","message":{"usage":{"input_tokens":5}}}`)},
+		{Type: "content_block_delta", PassThrough: true, RawData: []byte(`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"hello"}}`)},
+		{Type: "message_delta", PassThrough: true, RawData: []byte(`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":2}}`)},
+		{Type: "message_stop", PassThrough: true, RawData: []byte(`{"type":"message_stop"}`)},
+	}}
+	postStreamingMessage(t, newTestRouterWithDeps(t, Dependencies{Orchestrator: clean, EventStore: eventStore}))
+	if got := eventStore.List(ccevent.ListFilter{EventType: "run.stream_truncated", Limit: 10}); len(got) != 0 {
+		t.Fatalf("clean stream flagged as truncated: %+v", got[0].Data)
+	}
+
+	cut := &brokenStreamService{events: clean.events[:2]}
+	postStreamingMessage(t, newTestRouterWithDeps(t, Dependencies{Orchestrator: cut, EventStore: eventStore}))
+	got := eventStore.List(ccevent.ListFilter{EventType: "run.stream_truncated", Limit: 10})
+	if len(got) != 1 || got[0].Data["reason"] != "missing_stop" {
+		t.Fatalf("expected missing_stop truncation, got %+v", got)
+	}
+}
+
+func TestTruncatedStreamRetriesWithoutStreamingBeforeContent(t *testing.T) {
+	eventStore := ccevent.NewStore()
+	runStore := ccrun.NewStore()
+	cfg := settings.DefaultRuntimeSettings()
+	cfg.Routing.RetryTruncatedStreams = true
+	svc := &brokenStreamService{events: []orchestrator.StreamEvent{{Type: "message_start"}}}
+	router := newTestRouterWithDeps(t, Dependencies{
+		Orchestrator: svc,
+		EventStore:   eventStore,
+		RunStore:     runStore,
+		Settings:     settings.NewStore(cfg),
+	})
+
+	rr := postStreamingMessage(t, router)
+	body := rr.Body.String()
+	if svc.completes != 1 {
+		t.Fatalf("expected one non-streaming retry, got %d", svc.completes)
+	}
+	if !strings.Contains(body, "recovered answer") || !strings.Contains(body, "event: message_stop") {
+		t.Fatalf("expected replayed answer in stream, got %s", body)
+	}
+	if strings.Count(body, "event: message_start") != 1 {
+		t.Fatalf("message_start must not be repeated: %s", body)
+	}
+	events := eventStore.List(ccevent.ListFilter{EventType: "run.stream_truncated", Limit: 10})
+	if len(events) != 1 || events[0].Data["recovered"] != true || events[0].Data["reason"] != "missing_stop" {
+		t.Fatalf("unexpected truncation events: %+v", events)
+	}
+	if runs := runStore.List(ccrun.ListFilter{Limit: 10}); len(runs) != 1 || runs[0].Truncated {
+		t.Fatalf("recovered run must not be marked truncated: %+v", runs)
+	}
+}
@@ -0,0 +1,32 @@
+package gateway_test
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "ccgateway/internal/gateway"
+)
+
+func TestGatewayErrorsGoToStructuredLogger(t *testing.T) {
+	var buf bytes.Buffer
+	router := newTestRouterWithDeps(t, Dependencies{
+		AdminToken: "secret-admin",
+		Logger:     slog.New(slog.NewJSONHandler(&buf, nil)),
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/status", nil)
+	req.Header.Set("authorization", "Bearer wrong")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", rr.Code)
+	}
+	out := buf.String()
+	if !strings.Contains(out, `"level":"WARN"`) || !strings.Contains(out, `"msg":"request failed"`) || !strings.Contains(out, `"status":401`) {
+		t.Fatalf("expected a structured warn record, got %s", out)
+	}
+}
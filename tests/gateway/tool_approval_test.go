@@ -0,0 +1,184 @@
+package gateway_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ccgateway/internal/ccevent"
+	. "ccgateway/internal/gateway"
+	"ccgateway/internal/settings"
+	"ccgateway/internal/toolruntime"
+)
+
+type approvalHarness struct {
+	router http.Handler
+	svc    *citingService
+	events *ccevent.Store
+	inputs chan map[string]any
+}
+
+func newApprovalHarness(t *testing.T, approval settings.ToolApprovalSettings) *approvalHarness {
+	t.Helper()
+	h := &approvalHarness{svc: &citingService{}, events: ccevent.NewStore(), inputs: make(chan map[string]any, 1)}
+	registry := toolruntime.NewRegistry()
+	registry.Register("kb_search", func(_ context.Context, call toolruntime.Call) (toolruntime.Result, error) {
+		h.inputs <- call.Input
+		return toolruntime.Result{Content: "ran"}, nil
+	})
+	cfg := settings.DefaultRuntimeSettings()
+	cfg.ToolLoop.Mode = "server_loop"
+	cfg.ToolLoop.Approval = approval
+	cfg.Routing.ReflectionPasses = -1
+	h.router = newTestRouterWithDeps(t, Dependencies{
+		Orchestrator: h.svc,
+		Settings:     settings.NewStore(cfg),
+		ToolExecutor: registry,
+		EventStore:   h.events,
+	})
+	return h
+}
+
+// start sends a message that calls kb_search and returns the recorder,
+// filled in once the loop finishes.
+func (h *approvalHarness) start() <-chan *httptest.ResponseRecorder {
+	done := make(chan *httptest.ResponseRecorder, 1)
+	go func() {
+		body := `{"model":"claude-test","max_tokens":128,"messages":[{"role":"user","content":"refunds?"}],"tools":[{"name":"kb_search","input_schema":{"type":"object"}}]}`
+		req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(body))
+		req.Header.Set("anthropic-version", "2023-06-01")
+		rr := httptest.NewRecorder()
+		h.router.ServeHTTP(rr, req)
+		done <- rr
+	}()
+	return done
+}
+
+func (h *approvalHarness) waitForApproval(t *testing.T) ccevent.Event {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if items := h.events.List(ccevent.ListFilter{EventType: "tool.approval_required"}); len(items) > 0 {
+			return items[0]
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("expected a tool.approval_required event")
+	return ccevent.Event{}
+}
+
+func (h *approvalHarness) decide(runID, callID, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/v1/cc/runs/"+runID+"/approvals/"+callID, strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	h.router.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestToolApprovalRunsEditedInputOnApprove(t *testing.T) {
+	h := newApprovalHarness(t, settings.ToolApprovalSettings{Tools: []string{"kb_*"}})
+	done := h.start()
+	ev := h.waitForApproval(t)
+	if ev.Data["tool"] != "kb_search" || ev.Data["tool_use_id"] != "toolu_1" {
+		t.Fatalf("unexpected approval event: %#v", ev.Data)
+	}
+
+	listReq := httptest.NewRequest(http.MethodGet, "/v1/cc/runs/"+ev.RunID+"/approvals", nil)
+	listRR := httptest.NewRecorder()
+	h.router.ServeHTTP(listRR, listReq)
+	var listed struct {
+		Count int `json:"count"`
+	}
+	if err := json.Unmarshal(listRR.Body.Bytes(), &listed); err != nil || listed.Count != 1 {
+		t.Fatalf("expected one pending approval, got %d: %s", listRR.Code, listRR.Body.String())
+	}
+
+	if rr := h.decide(ev.RunID, "toolu_1", `{"decision":"approve","input":{"q":"refund policy"}}`); rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 approving, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := <-done; rr.Code != http.StatusOK {
+		t.Fatalf("expected the loop to finish, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if input := <-h.inputs; input["q"] != "refund policy" {
+		t.Fatalf("expected the edited input to run, got %#v", input)
+	}
+	if h.svc.toolContent != "ran" {
+		t.Fatalf("expected the tool result fed back, got %q", h.svc.toolContent)
+	}
+	if rr := h.decide(ev.RunID, "toolu_1", `{"decision":"deny"}`); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 once decided, got %d", rr.Code)
+	}
+}
+
+func TestToolApprovalDenySkipsTool(t *testing.T) {
+	h := newApprovalHarness(t, settings.ToolApprovalSettings{Tools: []string{"kb_search"}})
+	done := h.start()
+	ev := h.waitForApproval(t)
+	if rr := h.decide(ev.RunID, "toolu_1", `{"decision":"maybe"}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unknown decision, got %d", rr.Code)
+	}
+	if rr := h.decide(ev.RunID, "toolu_1", `{"decision":"deny","reason":"not on prod"}`); rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 denying, got %d: %s", rr.Code, rr.Body.String())
+	}
+	<-done
+	if len(h.inputs) != 0 {
+		t.Fatalf("expected the denied tool not to run")
+	}
+	if h.svc.toolContent != "tool call was denied by the reviewer: not on prod" {
+		t.Fatalf("unexpected tool result: %q", h.svc.toolContent)
+	}
+	resolved := h.events.List(ccevent.ListFilter{EventType: "tool.approval_resolved"})
+	if len(resolved) != 1 || resolved[0].Data["approved"] != false || resolved[0].Data["source"] != "reviewer" {
+		t.Fatalf("expected a reviewer denial event, got %#v", resolved)
+	}
+}
+
+func TestToolApprovalTimesOut(t *testing.T) {
+	h := newApprovalHarness(t, settings.ToolApprovalSettings{Tools: []string{"kb_search"}, TimeoutSeconds: 1})
+	rr := <-h.start()
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if len(h.inputs) != 0 || h.svc.toolContent != "tool call was not approved in time" {
+		t.Fatalf("expected the call denied on timeout, got %q", h.svc.toolContent)
+	}
+}
+
+func TestToolApprovalRejectsOtherProjects(t *testing.T) {
+	h := newApprovalHarness(t, settings.ToolApprovalSettings{Tools: []string{"kb_search"}})
+	done := h.start()
+	ev := h.waitForApproval(t)
+
+	foreign := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("x-cc-project", "globex")
+		rr := httptest.NewRecorder()
+		h.router.ServeHTTP(rr, req)
+		return rr
+	}
+	listRR := foreign(http.MethodGet, "/v1/cc/runs/"+ev.RunID+"/approvals", "")
+	var listed struct {
+		Count int `json:"count"`
+	}
+	if err := json.Unmarshal(listRR.Body.Bytes(), &listed); err != nil || listed.Count != 0 {
+		t.Fatalf("expected another project to see no approvals, got %d: %s", listRR.Code, listRR.Body.String())
+	}
+	if rr := foreign(http.MethodPost, "/v1/cc/runs/"+ev.RunID+"/approvals/toolu_1", `{"decision":"approve","input":{"q":"drop tables"}}`); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 approving from another project, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	if rr := h.decide(ev.RunID, "toolu_1", `{"decision":"approve"}`); rr.Code != http.StatusOK {
+		t.Fatalf("expected the run's project to decide, got %d: %s", rr.Code, rr.Body.String())
+	}
+	<-done
+	if input := <-h.inputs; input["q"] == "drop tables" {
+		t.Fatalf("expected the foreign edit to be ignored, got %#v", input)
+	}
+	resolved := h.events.List(ccevent.ListFilter{EventType: "tool.approval_resolved"})
+	if len(resolved) != 1 || resolved[0].Data["decided_by"] != "anonymous" {
+		t.Fatalf("expected the decider recorded, got %#v", resolved)
+	}
+}
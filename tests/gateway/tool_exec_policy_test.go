@@ -0,0 +1,94 @@
+package gateway_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ccgateway/internal/ccevent"
+	. "ccgateway/internal/gateway"
+	"ccgateway/internal/settings"
+	"ccgateway/internal/toolcatalog"
+	"ccgateway/internal/toolruntime"
+)
+
+func runPolicyToolLoop(t *testing.T, spec toolcatalog.ToolSpec, handler toolruntime.Handler) (*citingService, *ccevent.Store) {
+	t.Helper()
+	registry := toolruntime.NewRegistry()
+	registry.Register("kb_search", handler)
+	cfg := settings.DefaultRuntimeSettings()
+	cfg.ToolLoop.Mode = "server_loop"
+	cfg.Routing.ReflectionPasses = -1
+	svc := &citingService{}
+	events := ccevent.NewStore()
+	router := newTestRouterWithDeps(t, Dependencies{
+		Orchestrator: svc,
+		Settings:     settings.NewStore(cfg),
+		ToolExecutor: registry,
+		ToolCatalog:  toolcatalog.NewScopedCatalog([]toolcatalog.ToolSpec{spec}),
+		EventStore:   events,
+	})
+	body := `{"model":"claude-test","max_tokens":128,"messages":[{"role":"user","content":"refunds?"}],"tools":[{"name":"kb_search","input_schema":{"type":"object"}}]}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(body))
+	req.Header.Set("anthropic-version", "2023-06-01")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d; body=%s", rr.Code, rr.Body.String())
+	}
+	return svc, events
+}
+
+func TestToolPolicyRetriesIdempotentTool(t *testing.T) {
+	calls := 0
+	svc, events := runPolicyToolLoop(t, toolcatalog.ToolSpec{Name: "kb_search", Retries: 2, Idempotent: true}, func(context.Context, toolruntime.Call) (toolruntime.Result, error) {
+		calls++
+		if calls == 1 {
+			return toolruntime.Result{}, errors.New("connection reset")
+		}
+		return toolruntime.Result{Content: "refunds take 5 days"}, nil
+	})
+	if calls != 2 || svc.toolContent != "refunds take 5 days" {
+		t.Fatalf("expected the second attempt to answer, got calls=%d content=%q", calls, svc.toolContent)
+	}
+	retried := events.List(ccevent.ListFilter{EventType: "tool.retried"})
+	if len(retried) != 1 || retried[0].Data["retry_on"] != "error" || retried[0].Data["reason"] != "connection reset" {
+		t.Fatalf("expected one tool.retried event, got %#v", retried)
+	}
+}
+
+func TestToolPolicyDoesNotRetryNonIdempotentTool(t *testing.T) {
+	calls := 0
+	svc, _ := runPolicyToolLoop(t, toolcatalog.ToolSpec{Name: "kb_search", Retries: 2}, func(context.Context, toolruntime.Call) (toolruntime.Result, error) {
+		calls++
+		return toolruntime.Result{}, errors.New("connection reset")
+	})
+	if calls != 1 || svc.toolContent != "connection reset" {
+		t.Fatalf("expected a single attempt, got calls=%d content=%q", calls, svc.toolContent)
+	}
+}
+
+func TestToolPolicyTimesOutSlowTool(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	start := time.Now()
+	svc, events := runPolicyToolLoop(t, toolcatalog.ToolSpec{Name: "kb_search", TimeoutMS: 30}, func(context.Context, toolruntime.Call) (toolruntime.Result, error) {
+		// Ignores ctx on purpose: the loop must not wait for it.
+		<-release
+		return toolruntime.Result{Content: "too late"}, nil
+	})
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("expected the slow tool to be abandoned, took %s", elapsed)
+	}
+	if !strings.Contains(svc.toolContent, "timed out") {
+		t.Fatalf("expected a timeout tool result, got %q", svc.toolContent)
+	}
+	gaps := events.List(ccevent.ListFilter{EventType: "tool.gap_detected"})
+	if len(gaps) != 1 || gaps[0].Data["reason"] != "tool_timeout" {
+		t.Fatalf("expected a tool_timeout gap event, got %#v", gaps)
+	}
+}
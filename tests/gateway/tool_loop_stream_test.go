@@ -0,0 +1,144 @@
+package gateway_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "ccgateway/internal/gateway"
+	"ccgateway/internal/modelmap"
+	"ccgateway/internal/orchestrator"
+	"ccgateway/internal/policy"
+	"ccgateway/internal/settings"
+)
+
+// streamingToolLoopService asks for a tool on its first streamed round and
+// answers with text once the tool result is in the conversation.
+type streamingToolLoopService struct {
+	streams       int
+	sawToolResult bool
+}
+
+func (s *streamingToolLoopService) Complete(context.Context, orchestrator.Request) (orchestrator.Response, error) {
+	return orchestrator.Response{}, nil
+}
+
+func (s *streamingToolLoopService) Stream(_ context.Context, req orchestrator.Request) (<-chan orchestrator.StreamEvent, <-chan error) {
+	s.streams++
+	events := make(chan orchestrator.StreamEvent, 16)
+	errs := make(chan error, 1)
+	if s.streams == 1 {
+		events <- orchestrator.StreamEvent{Type: "message_start", Usage: orchestrator.Usage{InputTokens: 5}}
+		events <- orchestrator.StreamEvent{Type: "content_block_start", Index: 0, Block: orchestrator.AssistantBlock{Type: "text"}}
+		events <- orchestrator.StreamEvent{Type: "content_block_delta", Index: 0, DeltaText: "checking"}
+		events <- orchestrator.StreamEvent{Type: "content_block_stop", Index: 0}
+		events <- orchestrator.StreamEvent{Type: "content_block_start", Index: 1, Block: orchestrator.AssistantBlock{Type: "tool_use", ID: "toolu_1", Name: "get_weather"}}
+		events <- orchestrator.StreamEvent{Type: "content_block_delta", Index: 1, DeltaJSON: `{"city":`}
+		events <- orchestrator.StreamEvent{Type: "content_block_delta", Index: 1, DeltaJSON: `"Beijing"}`}
+		events <- orchestrator.StreamEvent{Type: "content_block_stop", Index: 1}
+		events <- orchestrator.StreamEvent{Type: "message_delta", StopReason: "tool_use", Usage: orchestrator.Usage{OutputTokens: 2}}
+		events <- orchestrator.StreamEvent{Type: "message_stop"}
+	} else {
+		s.sawToolResult = containsToolResult(req.Messages, "toolu_1")
+		events <- orchestrator.StreamEvent{Type: "message_start", Usage: orchestrator.Usage{InputTokens: 7}}
+		events <- orchestrator.StreamEvent{Type: "content_block_start", Index: 0, Block: orchestrator.AssistantBlock{Type: "text"}}
+		events <- orchestrator.StreamEvent{Type: "content_block_delta", Index: 0, DeltaText: "sunny"}
+		events <- orchestrator.StreamEvent{Type: "content_block_stop", Index: 0}
+		events <- orchestrator.StreamEvent{Type: "message_delta", StopReason: "end_turn", Usage: orchestrator.Usage{OutputTokens: 3}}
+		events <- orchestrator.StreamEvent{Type: "message_stop"}
+	}
+	close(events)
+	close(errs)
+	return events, errs
+}
+
+func TestMessagesIncrementalToolLoopStream(t *testing.T) {
+	svc := &streamingToolLoopService{}
+	cfg := settings.DefaultRuntimeSettings()
+	cfg.ToolLoop.Mode = "server_loop"
+	cfg.ToolLoop.MaxSteps = 3
+	cfg.ToolLoop.Stream = settings.ToolLoopStreamIncremental
+	router := newTestRouterWithDeps(t, Dependencies{
+		Orchestrator: svc,
+		Policy:       policy.NewNoopEngine(),
+		ModelMapper:  modelmap.NewIdentityMapper(),
+		Settings:     settings.NewStore(cfg),
+	})
+
+	body := `{
+		"model":"claude-test",
+		"max_tokens":128,
+		"stream":true,
+		"messages":[{"role":"user","content":"weather?"}],
+		"tools":[{"name":"get_weather","input_schema":{"type":"object","properties":{"city":{"type":"string"}}}}]
+	}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(body))
+	req.Header.Set("anthropic-version", "2023-06-01")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d; body=%s", rr.Code, rr.Body.String())
+	}
+	if svc.streams != 2 || !svc.sawToolResult {
+		t.Fatalf("expected two streamed rounds with the tool result fed back, got streams=%d sawToolResult=%v", svc.streams, svc.sawToolResult)
+	}
+
+	var names []string
+	var blockTypes []string
+	var indexes []int
+	var finalDelta map[string]any
+	for _, frame := range strings.Split(rr.Body.String(), "\n\n") {
+		var event, data string
+		for _, line := range strings.Split(frame, "\n") {
+			if strings.HasPrefix(line, "event: ") {
+				event = strings.TrimPrefix(line, "event: ")
+			} else if strings.HasPrefix(line, "data: ") {
+				data = strings.TrimPrefix(line, "data: ")
+			}
+		}
+		if event == "" || event == "ping" {
+			continue
+		}
+		names = append(names, event)
+		var payload map[string]any
+		if err := json.Unmarshal([]byte(data), &payload); err != nil {
+			t.Fatalf("decode %s payload %q: %v", event, data, err)
+		}
+		switch event {
+		case "content_block_start":
+			block, _ := payload["content_block"].(map[string]any)
+			blockTypes = append(blockTypes, block["type"].(string))
+			indexes = append(indexes, int(payload["index"].(float64)))
+		case "message_delta":
+			finalDelta = payload
+		}
+	}
+
+	if names[0] != "message_start" || names[len(names)-1] != "message_stop" {
+		t.Fatalf("expected a single message envelope, got %v", names)
+	}
+	if strings.Count(strings.Join(names, ","), "message_start") != 1 || strings.Count(strings.Join(names, ","), "message_delta") != 1 {
+		t.Fatalf("expected one message_start and one message_delta, got %v", names)
+	}
+	wantTypes := []string{"text", "server_tool_use", "server_tool_result", "text"}
+	if strings.Join(blockTypes, ",") != strings.Join(wantTypes, ",") {
+		t.Fatalf("expected blocks %v, got %v", wantTypes, blockTypes)
+	}
+	for i, idx := range indexes {
+		if idx != i {
+			t.Fatalf("expected contiguous block indexes, got %v", indexes)
+		}
+	}
+	delta, _ := finalDelta["delta"].(map[string]any)
+	if delta["stop_reason"] != "end_turn" {
+		t.Fatalf("expected final stop_reason end_turn, got %#v", finalDelta)
+	}
+	usage, _ := finalDelta["usage"].(map[string]any)
+	if usage["output_tokens"] != float64(5) {
+		t.Fatalf("expected output tokens summed across rounds, got %#v", usage)
+	}
+}
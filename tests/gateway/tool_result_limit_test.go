@@ -0,0 +1,99 @@
+package gateway_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ccgateway/internal/ccevent"
+	. "ccgateway/internal/gateway"
+	"ccgateway/internal/orchestrator"
+	"ccgateway/internal/settings"
+	"ccgateway/internal/toolruntime"
+)
+
+// summarizingLoopService calls kb_search once, records the tool result it
+// gets back and answers tool result summary requests itself.
+type summarizingLoopService struct {
+	citingService
+	summaries int
+}
+
+func (s *summarizingLoopService) Complete(ctx context.Context, req orchestrator.Request) (orchestrator.Response, error) {
+	if req.Metadata["purpose"] == "tool_result_summary" {
+		s.summaries++
+		return orchestrator.Response{Blocks: []orchestrator.AssistantBlock{{Type: "text", Text: "refunds: 5 days"}}}, nil
+	}
+	return s.citingService.Complete(ctx, req)
+}
+
+func runLimitedToolLoop(t *testing.T, svc orchestrator.Service, limits map[string]settings.ToolResultLimit) *ccevent.Store {
+	t.Helper()
+	registry := toolruntime.NewRegistry()
+	registry.Register("kb_search", func(context.Context, toolruntime.Call) (toolruntime.Result, error) {
+		return toolruntime.Result{Content: "BEGIN " + strings.Repeat("x", 200) + " END"}, nil
+	})
+	cfg := settings.DefaultRuntimeSettings()
+	cfg.ToolLoop.Mode = "server_loop"
+	cfg.ToolLoop.ResultLimits = limits
+	cfg.Routing.ReflectionPasses = -1
+	events := ccevent.NewStore()
+	router := newTestRouterWithDeps(t, Dependencies{
+		Orchestrator: svc,
+		Settings:     settings.NewStore(cfg),
+		ToolExecutor: registry,
+		EventStore:   events,
+	})
+	body := `{"model":"claude-test","max_tokens":128,"messages":[{"role":"user","content":"refunds?"}],"tools":[{"name":"kb_search","input_schema":{"type":"object"}}]}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(body))
+	req.Header.Set("anthropic-version", "2023-06-01")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d; body=%s", rr.Code, rr.Body.String())
+	}
+	return events
+}
+
+func TestToolResultLimitTruncatesWithMarker(t *testing.T) {
+	svc := &citingService{}
+	events := runLimitedToolLoop(t, svc, map[string]settings.ToolResultLimit{
+		"*":         {MaxChars: 1000},
+		"kb_search": {MaxChars: 40, Strategy: "tail"},
+	})
+	if !strings.HasPrefix(svc.toolContent, "[tool result truncated: kept the last 40 of 210 characters]") || !strings.HasSuffix(svc.toolContent, "x END") || strings.Contains(svc.toolContent, "BEGIN") {
+		t.Fatalf("expected the tail kept behind a marker, got %q", svc.toolContent)
+	}
+	truncated := events.List(ccevent.ListFilter{EventType: "tool.result_truncated"})
+	if len(truncated) != 1 {
+		t.Fatalf("expected one tool.result_truncated event, got %d", len(truncated))
+	}
+	data := truncated[0].Data
+	if data["tool"] != "kb_search" || data["strategy"] != "tail" || data["original_chars"] != 210 || data["max_chars"] != 40 {
+		t.Fatalf("unexpected event data: %#v", data)
+	}
+}
+
+func TestToolResultLimitSummarizesAndFallsBackToHead(t *testing.T) {
+	svc := &summarizingLoopService{}
+	runLimitedToolLoop(t, svc, map[string]settings.ToolResultLimit{
+		"kb_search": {MaxChars: 50, Strategy: "summarize"},
+	})
+	if svc.summaries != 1 || svc.toolContent != "[tool result summarized from 210 characters]\n\nrefunds: 5 days" {
+		t.Fatalf("expected the summary in place of the result, got summaries=%d content=%q", svc.summaries, svc.toolContent)
+	}
+
+	svc = &summarizingLoopService{}
+	events := runLimitedToolLoop(t, svc, map[string]settings.ToolResultLimit{
+		"kb_search": {MaxChars: 10, Strategy: "summarize"},
+	})
+	if svc.toolContent != "BEGIN xxxx\n\n[tool result truncated: kept the first 10 of 210 characters]" {
+		t.Fatalf("expected a head cut when the summary is too long, got %q", svc.toolContent)
+	}
+	truncated := events.List(ccevent.ListFilter{EventType: "tool.result_truncated"})
+	if len(truncated) != 1 || truncated[0].Data["strategy"] != "head" || truncated[0].Data["requested_strategy"] != "summarize" {
+		t.Fatalf("expected the fallback recorded, got %#v", truncated)
+	}
+}
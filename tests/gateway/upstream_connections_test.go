@@ -0,0 +1,45 @@
+package gateway_test
+
+import (
+	. "ccgateway/internal/gateway"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"ccgateway/internal/upstream"
+)
+
+func TestAdminUpstreamConnectionsReportsReuse(t *testing.T) {
+	adapter, err := upstream.NewHTTPAdapter(upstream.HTTPAdapterConfig{Name: "pooled", Kind: upstream.AdapterKindOpenAI, BaseURL: "http://127.0.0.1:1"}, nil)
+	if err != nil {
+		t.Fatalf("new adapter: %v", err)
+	}
+	svc := upstream.NewRouterService(upstream.RouterConfig{DefaultRoute: []string{"pooled"}}, []upstream.Adapter{adapter, namedTextAdapter{name: "scripted"}})
+	router := newTestRouterWithDeps(t, Dependencies{Orchestrator: svc, AdminToken: "secret-admin"})
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/upstream/connections", nil)
+	req.Header.Set("authorization", "Bearer secret-admin")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var out struct {
+		Adapters []upstream.ConnectionStats `json:"adapters"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &out); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(out.Adapters) != 1 || out.Adapters[0].Adapter != "pooled" || !out.Adapters[0].HTTP2 {
+		t.Fatalf("expected stats for the http adapter only, got %+v", out.Adapters)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/admin/upstream/connections", nil)
+	req.Header.Set("authorization", "Bearer secret-admin")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405, got %d", rr.Code)
+	}
+}
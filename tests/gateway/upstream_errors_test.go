@@ -0,0 +1,84 @@
+package gateway_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "ccgateway/internal/gateway"
+	"ccgateway/internal/orchestrator"
+	"ccgateway/internal/upstream"
+)
+
+type failingAdapter struct {
+	err error
+}
+
+func (failingAdapter) Name() string { return "failing" }
+
+func (a failingAdapter) Complete(context.Context, orchestrator.Request) (orchestrator.Response, error) {
+	return orchestrator.Response{}, a.err
+}
+
+func TestMessagesNormalizesUpstreamErrors(t *testing.T) {
+	cases := []struct {
+		err        error
+		wantStatus int
+		wantType   string
+		wantCode   string
+	}{
+		{
+			err:        &upstream.HTTPStatusError{Adapter: "failing", Status: 429, RetryAfter: "12", Body: `{"type":"error","error":{"type":"rate_limit_error","message":"slow down"}}`},
+			wantStatus: http.StatusTooManyRequests, wantType: "rate_limit_error", wantCode: upstream.ErrorCodeRateLimited,
+		},
+		{
+			err:        &upstream.HTTPStatusError{Adapter: "failing", Status: 404, Body: `{"error":{"message":"The model does not exist","code":"model_not_found"}}`},
+			wantStatus: http.StatusNotFound, wantType: "not_found_error", wantCode: upstream.ErrorCodeModelNotFound,
+		},
+		{
+			err:        &upstream.HTTPStatusError{Adapter: "failing", Status: 401, Body: `{"type":"error","error":{"type":"authentication_error","message":"invalid x-api-key"}}`},
+			wantStatus: http.StatusBadGateway, wantType: "api_error", wantCode: upstream.ErrorCodeInvalidAPIKey,
+		},
+	}
+	for _, tc := range cases {
+		svc := upstream.NewRouterService(upstream.RouterConfig{DefaultRoute: []string{"failing"}}, []upstream.Adapter{failingAdapter{err: tc.err}})
+		router := newTestRouterWithDeps(t, Dependencies{Orchestrator: svc})
+		body := `{"model":"claude-test","max_tokens":64,"messages":[{"role":"user","content":"hi"}]}`
+		req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(body))
+		req.Header.Set("anthropic-version", "2023-06-01")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != tc.wantStatus {
+			t.Fatalf("%s: expected %d, got %d; body=%s", tc.wantCode, tc.wantStatus, rr.Code, rr.Body.String())
+		}
+		var env ErrorEnvelope
+		if err := json.Unmarshal(rr.Body.Bytes(), &env); err != nil {
+			t.Fatalf("decode envelope: %v", err)
+		}
+		if env.Error.Type != tc.wantType || env.Error.Code != tc.wantCode || env.Error.Details["upstream_status"] == nil {
+			t.Fatalf("%s: unexpected envelope %#v", tc.wantCode, env)
+		}
+		if tc.wantCode == upstream.ErrorCodeRateLimited && rr.Header().Get("retry-after") != "12" {
+			t.Fatalf("expected retry-after to be forwarded, got %q", rr.Header().Get("retry-after"))
+		}
+	}
+}
+
+func TestMessagesStreamErrorEventCarriesCode(t *testing.T) {
+	svc := upstream.NewRouterService(upstream.RouterConfig{DefaultRoute: []string{"failing"}}, []upstream.Adapter{failingAdapter{
+		err: &upstream.HTTPStatusError{Adapter: "failing", Status: 529, Body: `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`},
+	}})
+	router := newTestRouterWithDeps(t, Dependencies{Orchestrator: svc})
+	body := `{"model":"claude-test","max_tokens":64,"stream":true,"messages":[{"role":"user","content":"hi"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(body))
+	req.Header.Set("anthropic-version", "2023-06-01")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if !strings.Contains(rr.Body.String(), "event: error") || !strings.Contains(rr.Body.String(), `"type":"overloaded_error"`) ||
+		!strings.Contains(rr.Body.String(), `"code":"overloaded"`) {
+		t.Fatalf("expected a normalized error event, got %s", rr.Body.String())
+	}
+}
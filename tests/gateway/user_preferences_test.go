@@ -0,0 +1,182 @@
+package gateway_test
+
+import (
+	"ccgateway/internal/auth"
+	. "ccgateway/internal/gateway"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ccgateway/internal/modelmap"
+	"ccgateway/internal/policy"
+	"ccgateway/internal/token"
+)
+
+func TestMePreferencesAppliedWhenRequestOmitsThem(t *testing.T) {
+	authSvc := auth.NewInMemoryService()
+	user, err := authSvc.Register("thin-client", "secret", auth.RoleUser)
+	if err != nil {
+		t.Fatalf("register user: %v", err)
+	}
+	tokenSvc := token.NewInMemoryService()
+	tk, err := tokenSvc.Generate(user.ID, 200)
+	if err != nil {
+		t.Fatalf("generate token: %v", err)
+	}
+	svc := &captureService{}
+	router := newTestRouterWithDeps(t, Dependencies{
+		Orchestrator: svc,
+		Policy:       policy.NewNoopEngine(),
+		ModelMapper:  modelmap.NewIdentityMapper(),
+		AuthService:  authSvc,
+		TokenService: tokenSvc,
+		AdminToken:   "secret-admin",
+	})
+
+	put := httptest.NewRequest(http.MethodPut, "/v1/me/preferences", strings.NewReader(`{"mode":"plan","model":"claude-pref","temperature":0.2}`))
+	put.Header.Set("authorization", "Bearer "+tk.Value)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, put)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 for put preferences, got %d; body=%s", rr.Code, rr.Body.String())
+	}
+
+	get := httptest.NewRequest(http.MethodGet, "/v1/me/preferences", nil)
+	get.Header.Set("authorization", "Bearer "+tk.Value)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, get)
+	var got struct {
+		UserID      string           `json:"user_id"`
+		Preferences auth.Preferences `json:"preferences"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode preferences: %v", err)
+	}
+	if got.UserID != user.ID || got.Preferences.Model != "claude-pref" || got.Preferences.Mode != "plan" {
+		t.Fatalf("unexpected preferences: %s", rr.Body.String())
+	}
+
+	send := func(body string, mode string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(body))
+		req.Header.Set("anthropic-version", "2023-06-01")
+		req.Header.Set("authorization", "Bearer "+tk.Value)
+		if mode != "" {
+			req.Header.Set("x-cc-mode", mode)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d; body=%s", rr.Code, rr.Body.String())
+		}
+		return rr
+	}
+
+	rr = send(`{"max_tokens":32,"messages":[{"role":"user","content":"hi"}]}`, "")
+	if svc.capturedReq.Model != "claude-pref" || rr.Header().Get("x-cc-mode") != "plan" {
+		t.Fatalf("expected preferred model and mode, got model=%q mode=%q", svc.capturedReq.Model, rr.Header().Get("x-cc-mode"))
+	}
+	if temp, _ := svc.capturedReq.Metadata["temperature"].(float64); temp != 0.2 {
+		t.Fatalf("expected preferred temperature, got %#v", svc.capturedReq.Metadata["temperature"])
+	}
+
+	rr = send(`{"model":"claude-explicit","temperature":0.9,"max_tokens":32,"messages":[{"role":"user","content":"hi"}]}`, "chat")
+	if svc.capturedReq.Model != "claude-explicit" || rr.Header().Get("x-cc-mode") != "chat" {
+		t.Fatalf("expected explicit values to win, got model=%q mode=%q", svc.capturedReq.Model, rr.Header().Get("x-cc-mode"))
+	}
+	if temp, _ := svc.capturedReq.Metadata["temperature"].(float64); temp != 0.9 {
+		t.Fatalf("expected explicit temperature, got %#v", svc.capturedReq.Metadata["temperature"])
+	}
+}
+
+func TestMePreferencesRequiresUserTokenAndValidates(t *testing.T) {
+	authSvc := auth.NewInMemoryService()
+	user, _ := authSvc.Register("pref-validate", "secret", auth.RoleUser)
+	tokenSvc := token.NewInMemoryService()
+	tk, _ := tokenSvc.Generate(user.ID, 200)
+	router := newTestRouterWithDeps(t, Dependencies{
+		AuthService:  authSvc,
+		TokenService: tokenSvc,
+		AdminToken:   "secret-admin",
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/me/preferences", nil)
+	req.Header.Set("authorization", "Bearer secret-admin")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for admin token, got %d", rr.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPut, "/v1/me/preferences", strings.NewReader(`{"temperature":5}`))
+	req.Header.Set("authorization", "Bearer "+tk.Value)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid temperature, got %d; body=%s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestAdminUserPreferencesSetSystemPrompt(t *testing.T) {
+	authSvc := auth.NewInMemoryService()
+	user, err := authSvc.Register("profiled", "secret", auth.RoleUser)
+	if err != nil {
+		t.Fatalf("register user: %v", err)
+	}
+	tokenSvc := token.NewInMemoryService()
+	tk, err := tokenSvc.Generate(user.ID, 0)
+	if err != nil {
+		t.Fatalf("generate token: %v", err)
+	}
+	svc := &captureService{}
+	router := newTestRouterWithDeps(t, Dependencies{
+		Orchestrator: svc,
+		AuthService:  authSvc,
+		TokenService: tokenSvc,
+		AdminToken:   "secret-admin",
+	})
+
+	path := "/admin/auth/users/" + user.ID + "/preferences"
+	put := httptest.NewRequest(http.MethodPut, path, strings.NewReader(`{"model":"claude-team","system_prompt":"  Follow the team style guide.  "}`))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, put)
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without the admin token, got %d", rr.Code)
+	}
+	put = httptest.NewRequest(http.MethodPut, path, strings.NewReader(`{"model":"claude-team","system_prompt":"  Follow the team style guide.  "}`))
+	put.Header.Set("authorization", "Bearer secret-admin")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, put)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"system_prompt":"Follow the team style guide."`) {
+		t.Fatalf("expected the trimmed profile saved, got %d; body=%s", rr.Code, rr.Body.String())
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(`{"max_tokens":32,"system":"Answer briefly.","messages":[{"role":"user","content":"hi"}]}`))
+	req.Header.Set("anthropic-version", "2023-06-01")
+	req.Header.Set("authorization", "Bearer "+tk.Value)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d; body=%s", rr.Code, rr.Body.String())
+	}
+	if svc.capturedReq.Model != "claude-team" || svc.capturedReq.System != "Follow the team style guide.\n\nAnswer briefly." {
+		t.Fatalf("expected the profile applied, got model=%q system=%#v", svc.capturedReq.Model, svc.capturedReq.System)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"model":"gpt-test","messages":[{"role":"user","content":"hi"}]}`))
+	req.Header.Set("authorization", "Bearer "+tk.Value)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || svc.capturedReq.System != "Follow the team style guide." {
+		t.Fatalf("expected the profile as the whole system prompt, got %d system=%#v", rr.Code, svc.capturedReq.System)
+	}
+
+	get := httptest.NewRequest(http.MethodGet, "/admin/auth/users/nobody/preferences", nil)
+	get.Header.Set("authorization", "Bearer secret-admin")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, get)
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown user, got %d", rr.Code)
+	}
+}
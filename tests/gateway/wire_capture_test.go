@@ -0,0 +1,93 @@
+package gateway_test
+
+import (
+	. "ccgateway/internal/gateway"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ccgateway/internal/upstream"
+)
+
+func TestAdminRunWireReturnsCapturedPayloads(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"msg_1","type":"message","role":"assistant","model":"m","content":[{"type":"text","text":"wired"}],"stop_reason":"end_turn","usage":{"input_tokens":3,"output_tokens":1}}`))
+	}))
+	defer srv.Close()
+	adapter, err := upstream.NewHTTPAdapter(upstream.HTTPAdapterConfig{Name: "wire", Kind: upstream.AdapterKindAnthropic, BaseURL: srv.URL, APIKey: "sk-upstream"}, nil)
+	if err != nil {
+		t.Fatalf("new adapter: %v", err)
+	}
+	svc := upstream.NewRouterService(upstream.RouterConfig{DefaultRoute: []string{"wire"}}, []upstream.Adapter{adapter})
+	router := newTestRouterWithDeps(t, Dependencies{Orchestrator: svc, AdminToken: "secret-admin"})
+
+	admin := func(method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("authorization", "Bearer secret-admin")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+	send := func(debug bool) string {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(`{"model":"claude-test","max_tokens":16,"messages":[{"role":"user","content":"inspect me"}]}`))
+		req.Header.Set("anthropic-version", "2023-06-01")
+		req.Header.Set("authorization", "Bearer secret-admin")
+		if debug {
+			req.Header.Set("x-cc-debug-wire", "1")
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		return rr.Header().Get("x-cc-run-id")
+	}
+
+	// Capture is off until an admin enables it.
+	if run := send(true); admin(http.MethodGet, "/admin/runs/"+run+"/wire", "").Code != http.StatusNotFound {
+		t.Fatalf("expected no capture while disabled")
+	}
+	if rr := admin(http.MethodPut, "/admin/wire-capture", `{"enabled":true}`); rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"enabled":true`) {
+		t.Fatalf("enable capture: %d %s", rr.Code, rr.Body.String())
+	}
+	if run := send(false); admin(http.MethodGet, "/admin/runs/"+run+"/wire", "").Code != http.StatusNotFound {
+		t.Fatalf("expected no capture without the debug header")
+	}
+
+	run := send(true)
+	rr := admin(http.MethodGet, "/admin/runs/"+run+"/wire", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if strings.Contains(rr.Body.String(), "sk-upstream") {
+		t.Fatalf("api key leaked: %s", rr.Body.String())
+	}
+	var out struct {
+		RunID     string `json:"run_id"`
+		Exchanges []struct {
+			Adapter        string            `json:"adapter"`
+			Status         int               `json:"status"`
+			RequestHeaders map[string]string `json:"request_headers"`
+			RequestBody    map[string]any    `json:"request_body"`
+			ResponseBody   map[string]any    `json:"response_body"`
+		} `json:"exchanges"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &out); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if out.RunID != run || len(out.Exchanges) != 1 {
+		t.Fatalf("unexpected capture %s", rr.Body.String())
+	}
+	ex := out.Exchanges[0]
+	if ex.Adapter != "wire" || ex.Status != http.StatusOK || ex.RequestHeaders["x-api-key"] != "[REDACTED]" {
+		t.Fatalf("unexpected exchange %+v", ex)
+	}
+	if ex.RequestBody["model"] == nil || ex.ResponseBody["id"] != "msg_1" {
+		t.Fatalf("expected decoded bodies, got %+v", ex)
+	}
+}
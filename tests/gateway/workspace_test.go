@@ -0,0 +1,34 @@
+package gateway_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	. "ccgateway/internal/gateway"
+	"ccgateway/internal/session"
+	"ccgateway/internal/workspace"
+)
+
+func TestReleasingToolStateRemovesWorkspace(t *testing.T) {
+	ws, err := workspace.New(workspace.Config{Root: t.TempDir()})
+	if err != nil {
+		t.Fatalf("new workspace: %v", err)
+	}
+	if err := ws.Write("sess_1", "/notes.txt", []byte("draft")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	dir, _ := ws.Dir("sess_1")
+	router := newTestRouterWithDeps(t, Dependencies{Orchestrator: &captureService{}, SessionStore: session.NewStore(), Workspaces: ws})
+
+	req := httptest.NewRequest(http.MethodDelete, "/v1/cc/sessions/sess_1/tool-state", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Fatalf("expected the session workspace removed, got %v", err)
+	}
+}
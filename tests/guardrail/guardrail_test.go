@@ -0,0 +1,145 @@
+package guardrail_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "ccgateway/internal/guardrail"
+)
+
+type fakeModerator struct {
+	result Moderation
+	err    error
+	calls  int
+}
+
+func (m *fakeModerator) Moderate(_ context.Context, _ string) (Moderation, error) {
+	m.calls++
+	return m.result, m.err
+}
+
+func TestCheckRedactsPIIAndRegexMatches(t *testing.T) {
+	e, err := New(Config{Enabled: true, Rules: []Rule{
+		{Name: "pii", Kind: KindPII, Action: ActionRedact, PII: []string{"email", "credit_card"}},
+		{Name: "codename", Kind: KindRegex, Action: ActionRedact, Pattern: `(?i)project\s+falcon`, Mask: "[CODENAME]"},
+	}}, nil)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	texts := []string{
+		"mail alice@example.com about Project Falcon",
+		"card 4111 1111 1111 1111, order 1234 5678 9012 3456",
+	}
+	res := e.Check(context.Background(), StageInput, texts)
+	if res.Blocked {
+		t.Fatalf("did not expect a block")
+	}
+	if got := res.Texts[0]; got != "mail [EMAIL] about [CODENAME]" {
+		t.Fatalf("unexpected redaction: %q", got)
+	}
+	if got := res.Texts[1]; got != "card [CARD], order 1234 5678 9012 3456" {
+		t.Fatalf("expected only the Luhn-valid card to be masked, got %q", got)
+	}
+	if !res.Changed(texts) || len(res.Findings) != 2 || res.Findings[0].Matches != 2 {
+		t.Fatalf("unexpected findings: %+v", res.Findings)
+	}
+}
+
+func TestCheckHonoursStagesAndStopsAtFirstBlock(t *testing.T) {
+	e, err := New(Config{Enabled: true, Rules: []Rule{
+		{Name: "note", Kind: KindRegex, Action: ActionAnnotate, Pattern: "secret"},
+		{Name: "deny", Kind: KindRegex, Action: ActionBlock, Pattern: "secret", Stages: []string{"output"}},
+		{Name: "after", Kind: KindRegex, Action: ActionAnnotate, Pattern: "secret"},
+	}}, nil)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	in := e.Check(context.Background(), StageInput, []string{"a secret"})
+	if in.Blocked || len(in.Findings) != 2 || in.Texts[0] != "a secret" {
+		t.Fatalf("unexpected input result: %+v", in)
+	}
+	out := e.Check(context.Background(), StageOutput, []string{"a secret"})
+	if !out.Blocked || out.BlockedBy != "deny" || len(out.Findings) != 2 {
+		t.Fatalf("unexpected output result: %+v", out)
+	}
+}
+
+func TestModerationRulesUseCategoriesAndFailOpen(t *testing.T) {
+	mod := &fakeModerator{result: Moderation{
+		Flagged:    true,
+		Categories: map[string]bool{"violence": true},
+		Scores:     map[string]float64{"violence": 0.9, "self-harm": 0.4},
+	}}
+	e, err := New(Config{Enabled: true, Rules: []Rule{
+		{Name: "self-harm", Kind: KindModeration, Action: ActionBlock, Categories: []string{"self-harm"}, Threshold: 0.5},
+		{Name: "violence", Kind: KindModeration, Action: ActionBlock, Categories: []string{"violence"}},
+	}}, mod)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	res := e.Check(context.Background(), StageInput, []string{"text"})
+	if !res.Blocked || res.BlockedBy != "violence" || mod.calls != 1 {
+		t.Fatalf("expected one moderation call and a violence block, got %+v calls=%d", res, mod.calls)
+	}
+
+	mod.err = errors.New("down")
+	if res := e.Check(context.Background(), StageInput, []string{"text"}); res.Blocked || res.Err == nil {
+		t.Fatalf("expected fail-open with the error reported, got %+v", res)
+	}
+	cfg := e.Config()
+	cfg.FailClosed = true
+	if err := e.Update(cfg); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if res := e.Check(context.Background(), StageInput, []string{"text"}); !res.Blocked || res.BlockedBy != "moderation_error" {
+		t.Fatalf("expected fail-closed block, got %+v", res)
+	}
+}
+
+func TestUpdateRejectsInvalidRules(t *testing.T) {
+	e, err := New(Config{}, nil)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	for _, rule := range []Rule{
+		{Name: "", Kind: KindRegex, Action: ActionBlock, Pattern: "x"},
+		{Name: "bad-re", Kind: KindRegex, Action: ActionBlock, Pattern: "("},
+		{Name: "bad-pii", Kind: KindPII, Action: ActionRedact, PII: []string{"passport"}},
+		{Name: "no-mod", Kind: KindModeration, Action: ActionBlock},
+		{Name: "bad-action", Kind: KindRegex, Action: "drop", Pattern: "x"},
+	} {
+		if err := e.Update(Config{Enabled: true, Rules: []Rule{rule}}); !errors.Is(err, ErrInvalidConfig) {
+			t.Fatalf("expected ErrInvalidConfig for %+v, got %v", rule, err)
+		}
+	}
+	if e.Enabled() {
+		t.Fatalf("rejected updates must leave the pipeline unchanged")
+	}
+}
+
+func TestHTTPModeratorParsesOpenAIResponse(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("authorization") != "Bearer mod-key" {
+			t.Errorf("missing api key")
+		}
+		w.Header().Set("content-type", "application/json")
+		_, _ = w.Write([]byte(`{"results":[{"flagged":true,"categories":{"hate":true},"category_scores":{"hate":0.8}}]}`))
+	}))
+	defer srv.Close()
+
+	m := NewHTTPModerator(HTTPModeratorConfig{URL: srv.URL, APIKey: "mod-key"})
+	got, err := m.Moderate(context.Background(), "text")
+	if err != nil {
+		t.Fatalf("moderate: %v", err)
+	}
+	if !got.Flagged || !got.Categories["hate"] || got.Scores["hate"] != 0.8 {
+		t.Fatalf("unexpected moderation: %+v", got)
+	}
+	if !strings.Contains(strings.Join(PIIDetectors(), ","), "email") {
+		t.Fatalf("expected the email detector to be listed")
+	}
+}
@@ -0,0 +1,58 @@
+package idgen_test
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	. "ccgateway/internal/idgen"
+)
+
+func TestULIDsAreUniqueAndSortable(t *testing.T) {
+	g := NewULID()
+	ids := make([]string, 1000)
+	seen := map[string]bool{}
+	for i := range ids {
+		id := g.New("run")
+		if !strings.HasPrefix(id, "run_") || len(id) != len("run_")+26 {
+			t.Fatalf("unexpected ULID %q", id)
+		}
+		if strings.ContainsAny(id[4:], "ILOU") {
+			t.Fatalf("ULID uses letters outside the Crockford alphabet: %q", id)
+		}
+		if seen[id] {
+			t.Fatalf("duplicate ULID %q", id)
+		}
+		seen[id] = true
+		ids[i] = id
+	}
+	if !sort.StringsAreSorted(ids) {
+		t.Fatalf("ULIDs from one generator must sort in creation order")
+	}
+}
+
+func TestNewFormats(t *testing.T) {
+	ksuid, err := New("ksuid")
+	if err != nil {
+		t.Fatalf("ksuid: %v", err)
+	}
+	if id := ksuid.New("msg"); !strings.HasPrefix(id, "msg_") || len(id) != len("msg_")+27 {
+		t.Fatalf("unexpected KSUID %q", id)
+	}
+	legacy, err := New("legacy")
+	if err != nil {
+		t.Fatalf("legacy: %v", err)
+	}
+	if a, b := legacy.New("run"), legacy.New("run"); a == b || strings.Count(a, "_") != 2 {
+		t.Fatalf("unexpected legacy IDs %q %q", a, b)
+	}
+	if _, err := New("uuid7"); err == nil {
+		t.Fatalf("expected unknown format error")
+	}
+	t.Setenv("ID_FORMAT", "")
+	if g, err := FromEnv(); err != nil {
+		t.Fatalf("default: %v", err)
+	} else if _, ok := g.(*ULID); !ok {
+		t.Fatalf("ULID should be the default, got %T", g)
+	}
+}
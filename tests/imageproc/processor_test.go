@@ -0,0 +1,94 @@
+package imageproc_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	. "ccgateway/internal/imageproc"
+)
+
+func encodePNG(t *testing.T, w, h int, alpha uint8) []byte {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{R: uint8(x * 7), G: uint8(y * 13), B: uint8((x + y) * 3), A: alpha})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestNormalizePassesThroughSmallImages(t *testing.T) {
+	p := NewProcessor(DefaultConfig())
+	data := encodePNG(t, 32, 16, 255)
+	res, err := p.Normalize("image/png", data)
+	if err != nil {
+		t.Fatalf("normalize: %v", err)
+	}
+	if res.Resized || res.Recompressed || !bytes.Equal(res.Data, data) || res.MediaType != "image/png" {
+		t.Fatalf("expected small image unchanged, got %+v", res)
+	}
+	if res.Width != 32 || res.Height != 16 {
+		t.Fatalf("unexpected dimensions %dx%d", res.Width, res.Height)
+	}
+}
+
+func TestNormalizeDownscalesToLimits(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.MaxDimension = 64
+	cfg.MaxBytes = 16 << 10
+	p := NewProcessor(cfg)
+
+	res, err := p.Normalize("image/png", encodePNG(t, 400, 200, 255))
+	if err != nil {
+		t.Fatalf("normalize: %v", err)
+	}
+	if !res.Resized || !res.Recompressed || res.MediaType != "image/jpeg" {
+		t.Fatalf("expected opaque image resized to jpeg, got resized=%v recompressed=%v type=%s", res.Resized, res.Recompressed, res.MediaType)
+	}
+	decoded, _, err := image.DecodeConfig(bytes.NewReader(res.Data))
+	if err != nil {
+		t.Fatalf("decode output: %v", err)
+	}
+	if decoded.Width != 64 || decoded.Height != 32 {
+		t.Fatalf("expected 64x32 output, got %dx%d", decoded.Width, decoded.Height)
+	}
+	if int64(len(res.Data)) > cfg.MaxBytes {
+		t.Fatalf("expected output within %d bytes, got %d", cfg.MaxBytes, len(res.Data))
+	}
+
+	res, err = p.Normalize("image/png", encodePNG(t, 200, 200, 128))
+	if err != nil {
+		t.Fatalf("normalize translucent: %v", err)
+	}
+	if res.MediaType != "image/png" || !res.Resized {
+		t.Fatalf("expected translucent image to stay png, got %+v", res.MediaType)
+	}
+}
+
+func TestNormalizeRejectsUnknownData(t *testing.T) {
+	p := NewProcessor(DefaultConfig())
+	if _, err := p.Normalize("image/png", []byte("not an image")); err == nil {
+		t.Fatalf("expected error for undecodable data")
+	}
+}
+
+func TestUpdateConfigPatchValidates(t *testing.T) {
+	p := NewProcessor(DefaultConfig())
+	bad := 0
+	if _, err := p.UpdateConfigPatch(ConfigPatch{MaxDimension: &bad}); err == nil {
+		t.Fatalf("expected max_dimension validation error")
+	}
+	proxy := true
+	cfg, err := p.UpdateConfigPatch(ConfigPatch{ProxyURLs: &proxy})
+	if err != nil || !cfg.ProxyURLs {
+		t.Fatalf("expected proxy_urls enabled, got %+v err=%v", cfg, err)
+	}
+}
@@ -0,0 +1,107 @@
+package ipaccess_test
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	. "ccgateway/internal/ipaccess"
+)
+
+func TestClientIPIgnoresForwardingHeadersFromUntrustedPeers(t *testing.T) {
+	p, err := NewPolicy(Config{})
+	if err != nil {
+		t.Fatalf("new policy: %v", err)
+	}
+	req := httptest.NewRequest("GET", "/v1/messages", nil)
+	req.RemoteAddr = "203.0.113.7:5000"
+	req.Header.Set("x-forwarded-for", "10.0.0.1")
+	req.Header.Set("x-real-ip", "10.0.0.2")
+	if got := p.ClientIP(req); got != "203.0.113.7" {
+		t.Fatalf("expected peer address, got %q", got)
+	}
+}
+
+func TestClientIPWalksTrustedProxies(t *testing.T) {
+	p, err := NewPolicy(Config{TrustedProxies: []string{"10.0.0.0/8"}})
+	if err != nil {
+		t.Fatalf("new policy: %v", err)
+	}
+	req := httptest.NewRequest("GET", "/v1/messages", nil)
+	req.RemoteAddr = "10.0.0.5:5000"
+	req.Header.Set("x-forwarded-for", "1.2.3.4, 198.51.100.9, 10.1.1.1")
+	if got := p.ClientIP(req); got != "198.51.100.9" {
+		t.Fatalf("expected first untrusted hop from the right, got %q", got)
+	}
+
+	req = httptest.NewRequest("GET", "/v1/messages", nil)
+	req.RemoteAddr = "10.0.0.5:5000"
+	req.Header.Set("x-real-ip", "198.51.100.10")
+	if got := p.ClientIP(req); got != "198.51.100.10" {
+		t.Fatalf("expected x-real-ip from trusted proxy, got %q", got)
+	}
+}
+
+func TestCheckListsAndRules(t *testing.T) {
+	p, err := NewPolicy(Config{
+		Allow:      []string{"10.0.0.0/8", "192.0.2.0/24"},
+		Deny:       []string{"10.9.0.0/16"},
+		AdminAllow: []string{"10.1.0.0/16"},
+		Rules: []Rule{
+			{PathPrefix: "/v1/", Deny: []string{"192.0.2.50"}},
+			{PathPrefix: "/v1/messages", Allow: []string{"192.0.2.50"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("new policy: %v", err)
+	}
+	cases := []struct {
+		path, ip string
+		allowed  bool
+	}{
+		{"/v1/models", "10.2.3.4", true},
+		{"/v1/models", "203.0.113.1", false},
+		{"/v1/models", "10.9.1.1", false},
+		{"/admin/status", "10.2.3.4", false},
+		{"/admin", "10.1.2.3", true},
+		{"/administrator", "10.2.3.4", true},
+		{"/api/channel/", "10.2.3.4", false},
+		{"/api/user", "10.1.2.3", true},
+		{"/api/tokenizer", "10.2.3.4", true},
+		{"/v1/models", "192.0.2.50", false},
+		{"/v1/messages", "192.0.2.50", true},
+		{"/v1/messages", "192.0.2.51", false},
+	}
+	for _, tc := range cases {
+		err := p.Check(tc.path, tc.ip)
+		if tc.allowed && err != nil {
+			t.Fatalf("%s from %s: unexpected denial: %v", tc.path, tc.ip, err)
+		}
+		if !tc.allowed && !errors.Is(err, ErrDenied) {
+			t.Fatalf("%s from %s: expected ErrDenied, got %v", tc.path, tc.ip, err)
+		}
+	}
+	if got := p.Snapshot()["denied"]; got != uint64(6) {
+		t.Fatalf("expected 6 denials counted, got %v", got)
+	}
+}
+
+func TestUpdateConfigPatchRejectsInvalidRanges(t *testing.T) {
+	p, err := NewPolicy(Config{Allow: []string{"10.0.0.0/8"}})
+	if err != nil {
+		t.Fatalf("new policy: %v", err)
+	}
+	if _, err := p.UpdateConfigPatch(ConfigPatch{Deny: []string{"not-an-ip"}}); err == nil {
+		t.Fatalf("expected invalid cidr error")
+	}
+	if _, err := p.UpdateConfigPatch(ConfigPatch{Rules: []Rule{{PathPrefix: "v1"}}}); err == nil {
+		t.Fatalf("expected path_prefix error")
+	}
+	cfg, err := p.UpdateConfigPatch(ConfigPatch{Deny: []string{" 10.0.0.1 "}})
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if len(cfg.Allow) != 1 || len(cfg.Deny) != 1 || cfg.Deny[0] != "10.0.0.1" {
+		t.Fatalf("unexpected config: %+v", cfg)
+	}
+}
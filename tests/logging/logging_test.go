@@ -0,0 +1,80 @@
+package logging_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	. "ccgateway/internal/logging"
+)
+
+func decodeLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var out []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var rec map[string]any
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("line is not JSON: %q", line)
+		}
+		out = append(out, rec)
+	}
+	return out
+}
+
+func TestLoggerWritesJSONLinesWithCorrelation(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(Config{Level: slog.LevelInfo, Format: FormatJSON, Output: &buf}).With("component", "gateway")
+
+	ctx := WithRun(context.Background(), "run_1", "sess_1")
+	ctx = WithRun(ctx, "", "")
+	logger.DebugContext(ctx, "hidden")
+	logger.WarnContext(ctx, "upstream slow", "adapter", "a1")
+	logger.Info("no run")
+
+	recs := decodeLines(t, &buf)
+	if len(recs) != 2 {
+		t.Fatalf("expected debug filtered out, got %d records", len(recs))
+	}
+	first := recs[0]
+	if first["level"] != "WARN" || first["msg"] != "upstream slow" || first["component"] != "gateway" {
+		t.Fatalf("unexpected record: %v", first)
+	}
+	if first["run_id"] != "run_1" || first["session_id"] != "sess_1" || first["adapter"] != "a1" {
+		t.Fatalf("expected run correlation, got %v", first)
+	}
+	if _, ok := recs[1]["run_id"]; ok {
+		t.Fatalf("a record without context must not carry a run: %v", recs[1])
+	}
+	if RunID(ctx) != "run_1" {
+		t.Fatalf("expected RunID to read the attached run")
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("LOG_LEVEL", "warning")
+	t.Setenv("LOG_FORMAT", "text")
+	t.Setenv("LOG_OUTPUT", "stdout")
+	cfg, err := ConfigFromEnv()
+	if err != nil {
+		t.Fatalf("config: %v", err)
+	}
+	if cfg.Level != slog.LevelWarn || cfg.Format != FormatText {
+		t.Fatalf("unexpected config: %+v", cfg)
+	}
+
+	t.Setenv("LOG_LEVEL", "verbose")
+	if _, err := ConfigFromEnv(); err == nil {
+		t.Fatalf("expected an error for an unknown level")
+	}
+	t.Setenv("LOG_LEVEL", "")
+	t.Setenv("LOG_FORMAT", "xml")
+	if _, err := ConfigFromEnv(); err == nil {
+		t.Fatalf("expected an error for an unknown format")
+	}
+}
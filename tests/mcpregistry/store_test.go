@@ -4,9 +4,13 @@ import (
 	. "ccgateway/internal/mcpregistry"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"runtime"
 	"strings"
 	"sync/atomic"
@@ -511,3 +515,219 @@ func TestStoreCallToolAnyFallbackOnToolNotFound(t *testing.T) {
 		t.Fatalf("unexpected fallback content: %#v", got.Content)
 	}
 }
+
+func TestStoreToolSessionHandles(t *testing.T) {
+	var issued int32
+	seen := map[string]string{}
+	var deleted []string
+	rpcServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			deleted = append(deleted, r.Header.Get("Mcp-Session-Id"))
+			return
+		}
+		var req map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		handle := r.Header.Get("Mcp-Session-Id")
+		if handle == "" && req["method"] == "tools/call" {
+			handle = fmt.Sprintf("h%d", atomic.AddInt32(&issued, 1))
+			w.Header().Set("Mcp-Session-Id", handle)
+		}
+		params, _ := req["params"].(map[string]any)
+		meta, _ := params["_meta"].(map[string]any)
+		if sid, _ := meta["session_id"].(string); sid != "" {
+			seen[sid] = handle
+		}
+		w.Header().Set("content-type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"jsonrpc": "2.0",
+			"id":      req["id"],
+			"result":  map[string]any{"content": handle},
+		})
+	}))
+	defer rpcServer.Close()
+
+	store := NewStore(rpcServer.Client())
+	if _, err := store.Register(RegisterInput{ID: "browser", Name: "browser", Transport: TransportHTTP, URL: rpcServer.URL}); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	call := func(sessionID string) string {
+		out, err := store.CallTool(WithToolSession(context.Background(), sessionID), "browser", "open", nil)
+		if err != nil {
+			t.Fatalf("call tool: %v", err)
+		}
+		return out.Content.(string)
+	}
+
+	a1 := call("sess_a")
+	b1 := call("sess_b")
+	a2 := call("sess_a")
+	if a1 == "" || a1 == b1 || a2 != a1 {
+		t.Fatalf("expected one stable handle per session, got a1=%q b1=%q a2=%q", a1, b1, a2)
+	}
+	if seen["sess_a"] != a1 {
+		t.Fatalf("expected session id forwarded in _meta, got %v", seen)
+	}
+
+	if n := store.ReleaseToolSession(context.Background(), "sess_a"); n != 1 {
+		t.Fatalf("expected one released handle, got %d", n)
+	}
+	if len(deleted) != 1 || deleted[0] != a1 {
+		t.Fatalf("expected DELETE for released handle, got %v", deleted)
+	}
+	if a3 := call("sess_a"); a3 == a1 {
+		t.Fatalf("expected a fresh handle after release")
+	}
+}
+
+func TestStoreStdioIdleShutdownRestartsOnNextCall(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sh command may not be available on windows CI")
+	}
+	starts := filepath.Join(t.TempDir(), "starts")
+	store := NewStore(nil)
+	server, err := store.Register(RegisterInput{
+		ID:            "mcp_stdio_idle",
+		Name:          "stdio-idle",
+		Transport:     TransportStdio,
+		Command:       "sh",
+		Args:          []string{"-c", "echo start >> " + starts + "; exec cat"},
+		TimeoutMS:     1200,
+		IdleTimeoutMS: 80,
+	})
+	if err != nil {
+		t.Fatalf("register stdio: %v", err)
+	}
+	countStarts := func() int {
+		raw, _ := os.ReadFile(starts)
+		return strings.Count(string(raw), "start")
+	}
+
+	for i := 0; i < 2; i++ {
+		if checked, err := store.CheckHealth(context.Background(), server.ID); err != nil || !checked.Status.Healthy {
+			t.Fatalf("check health stdio: %v %+v", err, checked.Status)
+		}
+	}
+	if n := countStarts(); n != 1 {
+		t.Fatalf("expected process reused while active, got %d starts", n)
+	}
+
+	time.Sleep(250 * time.Millisecond)
+	if checked, err := store.CheckHealth(context.Background(), server.ID); err != nil || !checked.Status.Healthy {
+		t.Fatalf("check health after idle: %v %+v", err, checked.Status)
+	}
+	if n := countStarts(); n != 2 {
+		t.Fatalf("expected idle process stopped and restarted, got %d starts", n)
+	}
+}
+
+func TestStoreToolSyncRefreshesCacheAndTracksSchemaChanges(t *testing.T) {
+	var listCalls, failing int64
+	toolName := atomic.Value{}
+	toolName.Store("search")
+	rpcServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req["method"] == "tools/list" {
+			atomic.AddInt64(&listCalls, 1)
+			if atomic.LoadInt64(&failing) == 1 {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"jsonrpc": "2.0",
+			"id":      req["id"],
+			"result": map[string]any{
+				"tools": []map[string]any{{"name": toolName.Load().(string)}},
+			},
+		})
+	}))
+	defer rpcServer.Close()
+
+	store := NewStore(rpcServer.Client())
+	store.SetToolsCacheTTL(time.Millisecond)
+	store.SetToolSyncInterval(time.Hour)
+	if _, err := store.Register(RegisterInput{ID: "sync_http", Name: "sync", Transport: TransportHTTP, URL: rpcServer.URL}); err != nil {
+		t.Fatal(err)
+	}
+
+	statuses := store.SyncAllTools(context.Background())
+	if len(statuses) != 1 || statuses[0].ToolCount != 1 || statuses[0].SchemaHash == "" || statuses[0].LastError != "" {
+		t.Fatalf("unexpected sync status: %+v", statuses)
+	}
+	first := statuses[0]
+
+	// With sync on, the synced list outlives the short cache TTL.
+	time.Sleep(5 * time.Millisecond)
+	if _, err := store.ListTools(context.Background(), "sync_http"); err != nil {
+		t.Fatal(err)
+	}
+	if got := atomic.LoadInt64(&listCalls); got != 1 {
+		t.Fatalf("expected cached tool list, got %d tools/list calls", got)
+	}
+
+	toolName.Store("search_v2")
+	status, err := store.SyncTools(context.Background(), "sync_http")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.SchemaHash == first.SchemaHash || !status.LastChangedAt.Equal(status.LastSyncAt) {
+		t.Fatalf("expected schema change to be recorded: %+v", status)
+	}
+
+	atomic.StoreInt64(&failing, 1)
+	status, err = store.SyncTools(context.Background(), "sync_http")
+	if err == nil || status.Failures != 1 || status.Syncs != 3 || status.LastError == "" {
+		t.Fatalf("expected failed sync to be recorded: %+v err=%v", status, err)
+	}
+	tools, err := store.ListTools(context.Background(), "sync_http")
+	if err != nil || len(tools) != 1 || tools[0].Name != "search_v2" {
+		t.Fatalf("expected last synced tools to keep serving: %+v err=%v", tools, err)
+	}
+
+	if err := store.Delete("sync_http"); err != nil {
+		t.Fatal(err)
+	}
+	if got := store.ToolSyncStatuses(); len(got) != 0 {
+		t.Fatalf("expected sync status removed with server: %+v", got)
+	}
+}
+
+func TestStoreSoftDeleteRestorePurge(t *testing.T) {
+	store := NewStore(nil)
+	if _, err := store.Register(RegisterInput{ID: "mcp_a", Name: "A", Transport: TransportHTTP, URL: "http://127.0.0.1:1"}); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if err := store.SoftDelete("mcp_a"); err != nil {
+		t.Fatalf("soft delete: %v", err)
+	}
+	if _, ok := store.Get("mcp_a"); ok {
+		t.Fatalf("soft-deleted server must not be live")
+	}
+	deleted := store.ListDeleted()
+	if len(deleted) != 1 || deleted[0].ID != "mcp_a" || deleted[0].DeletedAt == nil {
+		t.Fatalf("expected server in trash, got %+v", deleted)
+	}
+
+	restored, err := store.Restore("mcp_a")
+	if err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if restored.DeletedAt != nil || len(store.List(0)) != 1 {
+		t.Fatalf("unexpected restore result: %+v", restored)
+	}
+
+	_ = store.SoftDelete("mcp_a")
+	if _, err := store.Register(RegisterInput{ID: "mcp_a", Name: "A2", Transport: TransportHTTP, URL: "http://127.0.0.1:2"}); err != nil {
+		t.Fatalf("id should be reusable while trashed: %v", err)
+	}
+	if _, err := store.Restore("mcp_a"); !errors.Is(err, ErrAlreadyExists) {
+		t.Fatalf("expected ErrAlreadyExists, got %v", err)
+	}
+	if err := store.Purge("mcp_a"); err != nil {
+		t.Fatalf("purge: %v", err)
+	}
+	if len(store.ListDeleted()) != 0 {
+		t.Fatalf("trash should be empty after purge")
+	}
+}
@@ -0,0 +1,76 @@
+package memory_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"ccgateway/internal/memory"
+	"ccgateway/internal/orchestrator"
+)
+
+type stubUpstream struct {
+	req  orchestrator.Request
+	text string
+}
+
+func (s *stubUpstream) Complete(_ context.Context, req orchestrator.Request) (orchestrator.Response, error) {
+	s.req = req
+	return orchestrator.Response{Blocks: []orchestrator.AssistantBlock{{Type: "text", Text: s.text}}}, nil
+}
+
+func TestLLMSummarizer_Distill(t *testing.T) {
+	up := &stubUpstream{text: "Here you go:\n```json\n{\"summary\": \" Porting billing. \", \"facts\": [\"Uses Go 1.22\", \" \"]}\n```"}
+	s := memory.NewLLMSummarizer(up, "default-model")
+	out, err := s.Distill(context.Background(), memory.DistillRequest{
+		PreviousSummary: "Started a port.",
+		Facts:           []string{"Prefers tabs"},
+		Messages:        []memory.Message{{Role: "user", Content: "we target go 1.22"}},
+	})
+	if err != nil {
+		t.Fatalf("Distill failed: %v", err)
+	}
+	if out.Summary != "Porting billing." || len(out.Facts) != 1 || out.Facts[0] != "Uses Go 1.22" {
+		t.Fatalf("unexpected distillation: %+v", out)
+	}
+	if up.req.Model != "default-model" {
+		t.Fatalf("expected the default model, got %q", up.req.Model)
+	}
+	prompt, _ := up.req.Messages[0].Content.(string)
+	if !strings.Contains(prompt, "Started a port.") || !strings.Contains(prompt, "- Prefers tabs") || !strings.Contains(prompt, "we target go 1.22") {
+		t.Fatalf("prompt misses previous memory: %q", prompt)
+	}
+
+	out, err = memory.ParseDistillation("Just prose, no JSON.")
+	if err != nil || out.Summary != "Just prose, no JSON." || len(out.Facts) != 0 {
+		t.Fatalf("expected prose to become the summary, got %+v err=%v", out, err)
+	}
+	if _, err := memory.ParseDistillation("  "); err == nil {
+		t.Fatalf("expected an error for an empty answer")
+	}
+}
+
+func TestMergeFacts(t *testing.T) {
+	old := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := old.Add(time.Hour)
+	existing := []memory.Fact{
+		{Text: "Prefers tabs", RunID: "run-1", CreatedAt: old},
+		{Text: "Uses Go 1.21", RunID: "run-1", CreatedAt: old},
+	}
+	got := memory.MergeFacts(existing, []string{"prefers tabs", "Uses Go 1.22", "Uses Go 1.22", "Deploys on Fridays"}, "run-2", 2, now)
+	if len(got) != 2 || got[0].Text != "Uses Go 1.22" || got[1].Text != "Deploys on Fridays" {
+		t.Fatalf("unexpected facts: %+v", got)
+	}
+	if got[0].RunID != "run-2" || !got[0].CreatedAt.Equal(now) {
+		t.Fatalf("new facts should carry the distilling run: %+v", got[0])
+	}
+
+	kept := memory.MergeFacts(existing, []string{"prefers tabs"}, "run-2", 0, now)
+	if len(kept) != 1 || kept[0].Text != "Prefers tabs" || kept[0].RunID != "run-1" {
+		t.Fatalf("surviving facts should keep their origin: %+v", kept)
+	}
+	if memory.RenderContext(&memory.SessionMemory{}) != "" {
+		t.Fatalf("empty memory should render nothing")
+	}
+}
@@ -53,3 +53,46 @@ func TestStaticMapperWildcard(t *testing.T) {
 		t.Fatalf("expected wildcard mapped target, got %q", got)
 	}
 }
+
+func TestGroupMapperResolvesOrderedTargets(t *testing.T) {
+	m := NewGroupMapper(map[string][]string{
+		"claude-sonnet": {"provider-a/large", " provider-b/medium ", "provider-a/large"},
+		"claude-*":      {"provider-c/small"},
+	}, true, "")
+	got, err := m.ResolveGroup("claude-sonnet")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 2 || got[0] != "provider-a/large" || got[1] != "provider-b/medium" {
+		t.Fatalf("unexpected targets: %v", got)
+	}
+	if first, _ := m.Resolve("claude-sonnet"); first != "provider-a/large" {
+		t.Fatalf("expected Resolve to return the first target, got %q", first)
+	}
+	if got, _ := m.ResolveGroup("claude-haiku"); len(got) != 1 || got[0] != "provider-c/small" {
+		t.Fatalf("unexpected pattern targets: %v", got)
+	}
+}
+
+func TestNewFromEnvAcceptsAliasGroups(t *testing.T) {
+	t.Setenv("MODEL_MAP_JSON", `{"a":"b","c":["d","e"]}`)
+	m, err := NewFromEnv()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	grouped, ok := m.(GroupMapper)
+	if !ok {
+		t.Fatalf("expected a group mapper, got %T", m)
+	}
+	if got, _ := grouped.ResolveGroup("c"); len(got) != 2 || got[1] != "e" {
+		t.Fatalf("unexpected group: %v", got)
+	}
+	if got, _ := m.Resolve("a"); got != "b" {
+		t.Fatalf("expected b, got %q", got)
+	}
+
+	t.Setenv("MODEL_MAP_JSON", `{"a":1}`)
+	if _, err := NewFromEnv(); err == nil {
+		t.Fatalf("expected error for non-string entry")
+	}
+}
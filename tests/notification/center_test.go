@@ -0,0 +1,78 @@
+package notification_test
+
+import (
+	"errors"
+	"testing"
+
+	. "ccgateway/internal/notification"
+)
+
+func TestRaiseDedupesOpenNotificationsByKey(t *testing.T) {
+	c := NewCenter()
+	first := c.Raise(RaiseInput{Kind: "adapter_unhealthy", Key: "adapter_unhealthy:a", Title: "a failing"})
+	again := c.Raise(RaiseInput{Kind: "adapter_unhealthy", Key: "adapter_unhealthy:a", Title: "a failing", Severity: SeverityCritical})
+	if again.ID != first.ID || again.Count != 2 || again.Severity != SeverityCritical {
+		t.Fatalf("expected the repeat to update in place: %+v", again)
+	}
+	if first.Severity != SeverityWarning {
+		t.Fatalf("severity should default to warning, got %q", first.Severity)
+	}
+	c.Raise(RaiseInput{Kind: "adapter_unhealthy", Key: "adapter_unhealthy:b"})
+	if got := c.Counts(); got.Total != 2 || got.Unacknowledged != 2 || got.BySeverity[SeverityCritical] != 1 {
+		t.Fatalf("unexpected counts: %+v", got)
+	}
+
+	if _, ok := c.Resolve("adapter_unhealthy:a"); !ok {
+		t.Fatalf("expected open notification to resolve")
+	}
+	if c.IsOpen("adapter_unhealthy:a") {
+		t.Fatalf("resolved key must not stay open")
+	}
+	next := c.Raise(RaiseInput{Kind: "adapter_unhealthy", Key: "adapter_unhealthy:a"})
+	if next.ID == first.ID || next.Count != 1 {
+		t.Fatalf("a raise after resolve must start a new notification: %+v", next)
+	}
+}
+
+func TestReadAndAcknowledgeWorkflow(t *testing.T) {
+	c := NewCenter()
+	n := c.Raise(RaiseInput{Kind: "persistence_degraded", Severity: SeverityCritical, Title: "persistence"})
+	other := c.Raise(RaiseInput{Kind: "tool_gap_spike"})
+
+	if _, err := c.MarkRead(n.ID); err != nil {
+		t.Fatalf("mark read: %v", err)
+	}
+	unread, err := c.List(ListFilter{Status: StatusUnread})
+	if err != nil || len(unread) != 1 || unread[0].ID != other.ID {
+		t.Fatalf("unexpected unread list: %+v %v", unread, err)
+	}
+
+	acked, err := c.Acknowledge(other.ID, "sha256:abc")
+	if err != nil || acked.AcknowledgedAt == nil || acked.ReadAt == nil || acked.AcknowledgedBy != "sha256:abc" {
+		t.Fatalf("unexpected acknowledge result: %+v %v", acked, err)
+	}
+	if got := c.Counts(); got.Unread != 0 || got.Unacknowledged != 1 {
+		t.Fatalf("unexpected counts after ack: %+v", got)
+	}
+
+	// A repeat keeps the acknowledgement but shows up as unread again; an
+	// escalation needs a fresh acknowledgement.
+	again := c.Raise(RaiseInput{Kind: "tool_gap_spike"})
+	if again.AcknowledgedAt == nil || again.ReadAt != nil {
+		t.Fatalf("repeat should stay acknowledged and become unread: %+v", again)
+	}
+	escalated := c.Raise(RaiseInput{Kind: "tool_gap_spike", Severity: SeverityCritical})
+	if escalated.AcknowledgedAt != nil {
+		t.Fatalf("escalation must clear the acknowledgement: %+v", escalated)
+	}
+
+	if n := c.ReadAll(); n != 1 {
+		t.Fatalf("expected one notification marked read, got %d", n)
+	}
+	if _, err := c.Acknowledge("missing", ""); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if _, err := c.List(ListFilter{Status: "bogus"}); !errors.Is(err, ErrInvalidStatus) {
+		t.Fatalf("expected ErrInvalidStatus, got %v", err)
+	}
+}
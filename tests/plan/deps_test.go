@@ -0,0 +1,41 @@
+package plan_test
+
+import (
+	"strings"
+	"testing"
+
+	. "ccgateway/internal/plan"
+)
+
+func TestOrderStepsRespectsDependencies(t *testing.T) {
+	steps, err := OrderSteps([]Step{
+		{ID: "deploy", Title: "deploy", DependsOn: []string{"build", "test"}},
+		{ID: "test", Title: "test", DependsOn: []string{"build"}},
+		{Title: "docs"},
+		{ID: "build", Title: "build"},
+	})
+	if err != nil {
+		t.Fatalf("order: %v", err)
+	}
+	var ids []string
+	for _, s := range steps {
+		ids = append(ids, s.ID)
+	}
+	if strings.Join(ids, ",") != "s3,build,test,deploy" {
+		t.Fatalf("unexpected order: %v", ids)
+	}
+}
+
+func TestOrderStepsRejectsBadDependencies(t *testing.T) {
+	cases := map[string][]Step{
+		"unknown":   {{ID: "a", Title: "a", DependsOn: []string{"zzz"}}},
+		"cyclic":    {{ID: "a", Title: "a", DependsOn: []string{"b"}}, {ID: "b", Title: "b", DependsOn: []string{"a"}}},
+		"itself":    {{ID: "a", Title: "a", DependsOn: []string{"a"}}},
+		"duplicate": {{ID: "a", Title: "a"}, {ID: "a", Title: "again"}},
+	}
+	for want, steps := range cases {
+		if _, err := OrderSteps(steps); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("%s: expected error mentioning %q, got %v", want, want, err)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package plugin_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"ccgateway/internal/orchestrator"
+	. "ccgateway/internal/plugin"
+)
+
+func middlewarePlugin(name string, order int, mw MiddlewareConfig) Plugin {
+	mw.Order = order
+	if mw.Entrypoint == "" {
+		mw.Entrypoint = name
+	}
+	_ = mw.Normalize()
+	return Plugin{Name: name, Enabled: true, Middleware: &mw}
+}
+
+func TestRewriteRequestRunsPluginsInOrderAndIsolatesFailures(t *testing.T) {
+	exec := NewExecutor()
+	exec.RegisterRuntime(RuntimeScript, RuntimeFunc(func(_ context.Context, cfg MiddlewareConfig, input []byte) ([]byte, error) {
+		var env struct {
+			Request map[string]any `json:"request"`
+		}
+		_ = json.Unmarshal(input, &env)
+		switch cfg.Entrypoint {
+		case "broken":
+			panic("boom")
+		case "noop":
+			return nil, nil
+		}
+		env.Request["system"] = env.Request["system"].(string) + "+" + cfg.Entrypoint
+		return json.Marshal(map[string]any{"request": env.Request})
+	}))
+	plugins := []Plugin{
+		middlewarePlugin("second", 2, MiddlewareConfig{}),
+		middlewarePlugin("broken", 1, MiddlewareConfig{}),
+		middlewarePlugin("first", 1, MiddlewareConfig{}),
+		middlewarePlugin("noop", 3, MiddlewareConfig{}),
+		middlewarePlugin("response-only", 0, MiddlewareConfig{Stages: []string{"response"}}),
+	}
+	req := orchestrator.Request{Model: "m", System: "base", Messages: []orchestrator.Message{{Role: "user", Content: "hi"}}}
+	out, outcomes, err := exec.RewriteRequest(context.Background(), plugins, req)
+	if err != nil {
+		t.Fatalf("rewrite: %v", err)
+	}
+	if out.System != "base+first+second" || len(out.Messages) != 1 {
+		t.Fatalf("unexpected request: %+v", out)
+	}
+	if len(outcomes) != 4 || outcomes[0].Plugin != "broken" || !strings.Contains(outcomes[0].Error, "boom") || outcomes[3].Changed {
+		t.Fatalf("unexpected outcomes: %+v", outcomes)
+	}
+
+	plugins[1] = middlewarePlugin("broken", 1, MiddlewareConfig{FailureMode: "abort"})
+	if _, _, err := exec.RewriteRequest(context.Background(), plugins, req); !errors.Is(err, ErrMiddlewareAborted) {
+		t.Fatalf("expected ErrMiddlewareAborted, got %v", err)
+	}
+}
+
+func TestScriptRuntimeRewritesResponseAndSkipsUnknownRuntimes(t *testing.T) {
+	exec := NewExecutor()
+	script := middlewarePlugin("upper", 0, MiddlewareConfig{
+		Entrypoint: "sh",
+		Args:       []string{"-c", `cat >/dev/null; printf '{"response":{"model":"m","blocks":[{"type":"text","text":"REWRITTEN"}],"stop_reason":"end_turn"}}'`},
+	})
+	wasm := Plugin{Name: "wasm-filter", Enabled: true, Middleware: &MiddlewareConfig{Runtime: "wasm", Entrypoint: "filter.wasm", Order: 1}}
+	resp := orchestrator.Response{Model: "m", Blocks: []orchestrator.AssistantBlock{{Type: "text", Text: "original"}}}
+	out, outcomes, err := exec.RewriteResponse(context.Background(), []Plugin{wasm, script}, orchestrator.Request{}, resp)
+	if err != nil {
+		t.Fatalf("rewrite: %v", err)
+	}
+	if len(out.Blocks) != 1 || out.Blocks[0].Text != "REWRITTEN" {
+		t.Fatalf("unexpected response: %+v", out)
+	}
+	if len(outcomes) != 2 || !strings.Contains(outcomes[1].Error, ErrRuntimeUnavailable.Error()) {
+		t.Fatalf("expected the wasm plugin to be skipped, got %+v", outcomes)
+	}
+}
+
+func TestInstallValidatesMiddleware(t *testing.T) {
+	m := NewManager()
+	for _, runtime := range []string{"lua", "wasm"} {
+		if err := m.Install(Plugin{Name: "bad", Middleware: &MiddlewareConfig{Entrypoint: "x", Runtime: runtime}}); err == nil {
+			t.Fatalf("expected runtime %q to be rejected", runtime)
+		}
+	}
+	if err := m.Install(Plugin{Name: "good", Middleware: &MiddlewareConfig{Entrypoint: "./filter.sh", Stages: []string{"Request"}}}); err != nil {
+		t.Fatalf("install: %v", err)
+	}
+	p, _ := m.Get("good")
+	if p.Middleware.Runtime != RuntimeScript || p.Middleware.FailureMode != FailureSkip || !p.Middleware.Handles(StageRequest) || p.Middleware.Handles(StageResponse) {
+		t.Fatalf("unexpected normalized middleware: %+v", p.Middleware)
+	}
+}
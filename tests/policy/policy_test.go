@@ -3,8 +3,10 @@ package policy_test
 import (
 	. "ccgateway/internal/policy"
 	"context"
+	"strings"
 	"testing"
 
+	"ccgateway/internal/requestctx"
 	"ccgateway/internal/settings"
 	"ccgateway/internal/toolcatalog"
 )
@@ -66,3 +68,29 @@ func TestDynamicEngineToolPolicy(t *testing.T) {
 		t.Fatalf("experimental tool should pass after enabling: %v", err)
 	}
 }
+
+func TestDynamicEngineEnforcesSettingsRules(t *testing.T) {
+	cfg := settings.DefaultRuntimeSettings()
+	cfg.Policy = settings.PolicySettings{Rules: []settings.PolicyRule{
+		{ID: "deny-free-opus", Effect: "deny", Models: []string{"*opus*"}, Groups: []string{"free"}},
+		{ID: "deny-acme-shell", Effect: "deny", Tools: []string{"bash"}, Projects: []string{"acme"}},
+	}}
+	engine := NewDynamicEngine(settings.NewStore(cfg), nil)
+
+	free := WithTokenAttributes(context.Background(), TokenAttributes{Group: "free"})
+	if err := engine.Authorize(free, Action{Path: "/v1/messages", Model: "claude-opus"}); err == nil || !strings.Contains(err.Error(), "deny-free-opus") {
+		t.Fatalf("expected the free group denied opus, got %v", err)
+	}
+	paid := WithTokenAttributes(context.Background(), TokenAttributes{Group: "paid"})
+	if err := engine.Authorize(paid, Action{Path: "/v1/messages", Model: "claude-opus"}); err != nil {
+		t.Fatalf("expected the paid group allowed, got %v", err)
+	}
+
+	acme := requestctx.WithProjectID(context.Background(), "acme")
+	if d := engine.Decide(acme, Action{ToolNames: []string{"bash"}}); d.Allowed || d.MatchedRule == nil || d.MatchedRule.ID != "deny-acme-shell" {
+		t.Fatalf("expected bash denied in acme, got %+v", d)
+	}
+	if err := engine.Authorize(context.Background(), Action{ToolNames: []string{"bash"}}); err != nil {
+		t.Fatalf("expected bash allowed outside acme, got %v", err)
+	}
+}
@@ -0,0 +1,58 @@
+package policy_test
+
+import (
+	"testing"
+
+	. "ccgateway/internal/policy"
+)
+
+func TestEvaluateRulesPriorityAndDefault(t *testing.T) {
+	rules := []Rule{
+		{ID: "allow-all-tools", Effect: "allow", Tools: []string{"*"}},
+		{ID: "deny-shell", Effect: "deny", Priority: 10, Tools: []string{"bash*"}},
+		{ID: "deny-guest-plan", Effect: "deny", Modes: []string{"plan"}, Groups: []string{"guest"}},
+	}
+	if err := ValidateRules(rules); err != nil {
+		t.Fatalf("validate rules: %v", err)
+	}
+
+	d := EvaluateRules(rules, Action{Path: "/v1/messages", Mode: "chat", ToolNames: []string{"read", "bash_exec"}}, TokenAttributes{}, "allow")
+	if d.Allowed || d.MatchedRule == nil || d.MatchedRule.ID != "deny-shell" || d.MatchedTool != "bash_exec" {
+		t.Fatalf("expected deny-shell to win by priority, got %+v", d)
+	}
+
+	d = EvaluateRules(rules, Action{Mode: "plan"}, TokenAttributes{Group: "Guest"}, "allow")
+	if d.Allowed || d.MatchedRule == nil || d.MatchedRule.ID != "deny-guest-plan" {
+		t.Fatalf("expected guest plan deny, got %+v", d)
+	}
+
+	d = EvaluateRules(rules, Action{Mode: "chat"}, TokenAttributes{}, "deny")
+	if d.Allowed || d.MatchedRule != nil || d.Effect != "deny" {
+		t.Fatalf("expected default deny without match, got %+v", d)
+	}
+}
+
+func TestValidateRulesRejectsBadInput(t *testing.T) {
+	cases := [][]Rule{
+		{{ID: "", Effect: "allow"}},
+		{{ID: "a", Effect: "maybe"}},
+		{{ID: "a", Effect: "allow"}, {ID: "a", Effect: "deny"}},
+		{{ID: "a", Effect: "allow", Models: []string{"[bad"}}},
+	}
+	for i, rules := range cases {
+		if err := ValidateRules(rules); err == nil {
+			t.Fatalf("case %d: expected validation error", i)
+		}
+	}
+}
+
+func TestEvaluateRulesMatchesRoutedAdapters(t *testing.T) {
+	rules := []Rule{{ID: "deny-premium-pin", Effect: "deny", Adapters: []string{"premium-*"}, Groups: []string{"free"}}}
+	d := EvaluateRules(rules, Action{Adapters: []string{"basic", "premium-east"}}, TokenAttributes{Group: "free"}, "allow")
+	if d.Allowed || d.MatchedRule == nil {
+		t.Fatalf("expected pinning a premium adapter to be denied, got %+v", d)
+	}
+	if d := EvaluateRules(rules, Action{}, TokenAttributes{Group: "free"}, "allow"); !d.Allowed {
+		t.Fatalf("expected requests without adapters to skip the rule, got %+v", d)
+	}
+}
@@ -0,0 +1,119 @@
+package probe_test
+
+import (
+	. "ccgateway/internal/probe"
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"ccgateway/internal/scheduler"
+	"ccgateway/internal/upstream"
+)
+
+func TestIntelligenceHistoryTrendsSurviveReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "intel.jsonl")
+	h, err := NewIntelligenceHistory(path)
+	if err != nil {
+		t.Fatalf("open history: %v", err)
+	}
+	base := time.Now().Add(-time.Hour)
+	for i, score := range []float64{40, 60, 80, 90} {
+		h.Record(IntelligenceResult{
+			AdapterName: "a1",
+			Model:       "m1",
+			Score:       score,
+			TestedAt:    base.Add(time.Duration(i) * time.Minute),
+			Details:     []QAScore{{Category: "math", Score: score / 5}},
+		})
+	}
+	h.Record(IntelligenceResult{AdapterName: "a2", Model: "m2", Score: 70, TestedAt: base})
+	if err := h.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	reopened, err := NewIntelligenceHistory(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer reopened.Close()
+	trends := reopened.Trends("", "", 2)
+	if len(trends) != 2 {
+		t.Fatalf("expected 2 trends, got %+v", trends)
+	}
+	top := trends[0]
+	if top.Adapter != "a1" || top.Samples != 4 || top.Latest != 90 || top.Delta != 10 || top.Direction != "up" {
+		t.Fatalf("unexpected trend: %+v", top)
+	}
+	if top.Average != 67.5 || top.Min != 40 || top.Max != 90 {
+		t.Fatalf("unexpected aggregates: %+v", top)
+	}
+	// The election score averages the last three runs only.
+	if top.ElectionScore != 76.67 {
+		t.Fatalf("expected election score 76.67, got %v", top.ElectionScore)
+	}
+	if len(top.History) != 2 || top.History[1].Score != 90 || top.History[1].Categories["math"] != 18 {
+		t.Fatalf("expected the two newest points, got %+v", top.History)
+	}
+	if got := reopened.Trends("a2", "", 0); len(got) != 1 || got[0].Previous != nil {
+		t.Fatalf("unexpected filtered trend: %+v", got)
+	}
+}
+
+func TestRunnerSchedulesIntelligenceAndFeedsElection(t *testing.T) {
+	health := scheduler.NewEngine(scheduler.Config{}, []string{"smart", "dumb"})
+	smart := &mockIntelAdapter{name: "smart", responses: map[string]string{"sheep": "9", "37 * 43": "1591"}}
+	dumb := &mockIntelAdapter{name: "dumb"}
+	runner := NewRunner(Config{Enabled: false, DefaultModels: []string{"m"}}, []upstream.Adapter{smart, dumb}, health)
+
+	history, _ := NewIntelligenceHistory("")
+	published := make(chan []scheduler.IntelligenceScore, 4)
+	runner.EnableIntelligence(IntelligenceConfig{Interval: time.Hour, InitialDelay: -1}, history, func(scores []scheduler.IntelligenceScore) {
+		published <- scores
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	runner.Start(ctx)
+
+	var scores []scheduler.IntelligenceScore
+	select {
+	case scores = <-published:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("intelligence run did not publish scores")
+	}
+	if len(scores) != 2 {
+		t.Fatalf("expected one score per adapter, got %+v", scores)
+	}
+	byName := map[string]float64{}
+	for _, s := range scores {
+		byName[s.AdapterName] = s.Score
+	}
+	if byName["smart"] <= byName["dumb"] {
+		t.Fatalf("expected smart to outscore dumb: %+v", scores)
+	}
+
+	report := runner.IntelligenceReport("smart", "", 10)
+	if !report.Enabled || report.Runs != 1 || report.LastRunAt == nil || report.NextRunAt == nil {
+		t.Fatalf("unexpected schedule in report: %+v", report)
+	}
+	if len(report.Trends) != 1 || report.Trends[0].Adapter != "smart" || len(report.ElectionScores) != 2 {
+		t.Fatalf("unexpected report trends: %+v", report)
+	}
+}
+
+func TestEnableIntelligenceSeedsElectionFromHistory(t *testing.T) {
+	health := scheduler.NewEngine(scheduler.Config{}, []string{"a1"})
+	runner := NewRunner(Config{}, []upstream.Adapter{&mockIntelAdapter{name: "a1"}}, health)
+	history, _ := NewIntelligenceHistory("")
+	history.Record(IntelligenceResult{AdapterName: "a1", Model: "m1", Score: 55, TestedAt: time.Now()})
+
+	var got []scheduler.IntelligenceScore
+	runner.EnableIntelligence(IntelligenceConfig{}, history, func(scores []scheduler.IntelligenceScore) { got = scores })
+	if len(got) != 1 || got[0].AdapterName != "a1" || got[0].Score != 55 {
+		t.Fatalf("expected persisted score to be published at once, got %+v", got)
+	}
+	if cfg := runner.IntelligenceConfig(); cfg.Interval != 10*time.Minute || cfg.Timeout != 15*time.Second {
+		t.Fatalf("unexpected defaults: %+v", cfg)
+	}
+}
@@ -0,0 +1,93 @@
+package probe_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "ccgateway/internal/probe"
+	"ccgateway/internal/scheduler"
+	"ccgateway/internal/upstream"
+)
+
+func TestValidateProbeTasksNormalizesAndRejects(t *testing.T) {
+	tasks, err := ValidateProbeTasks([]ProbeTask{
+		{Prompt: " 用中文回答：1+1 等于几？ ", Expected: "2", Validator: "NUMBER"},
+		{Category: " Code ", Prompt: "write go", Keywords: []string{"func", " ", "return"}},
+	})
+	if err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	if tasks[0].Name != "task_1" || tasks[0].Category != "custom" || tasks[0].Weight != 1 || tasks[0].MaxTokens != 256 || tasks[0].Validator != ValidatorNumber {
+		t.Fatalf("unexpected defaults: %+v", tasks[0])
+	}
+	if tasks[1].Category != "code" || tasks[1].Validator != ValidatorContainsAll || len(tasks[1].Keywords) != 2 {
+		t.Fatalf("unexpected keyword task: %+v", tasks[1])
+	}
+
+	for name, bad := range map[string]ProbeTask{
+		"no prompt":     {Expected: "x"},
+		"no expected":   {Prompt: "p", Validator: ValidatorExact},
+		"bad regex":     {Prompt: "p", Validator: ValidatorRegex, Expected: "("},
+		"bad number":    {Prompt: "p", Validator: ValidatorNumber, Expected: "many"},
+		"bad validator": {Prompt: "p", Validator: "vibes", Expected: "x"},
+		"neg weight":    {Prompt: "p", Expected: "x", Weight: -1},
+	} {
+		if _, err := ValidateProbeTasks([]ProbeTask{bad}); err == nil {
+			t.Fatalf("%s: expected validation error", name)
+		}
+	}
+}
+
+func TestProbeIntelligenceTasksWeightsScores(t *testing.T) {
+	tasks, err := ValidateProbeTasks([]ProbeTask{
+		{Name: "sum", Category: "math", Prompt: "What is 1200+34?", Expected: "1234", Validator: ValidatorNumber, Weight: 3},
+		{Name: "go", Category: "code", Prompt: "Write a Go add function", Keywords: []string{"func", "return"}, Validator: ValidatorContainsAll},
+	})
+	if err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	adapter := &mockIntelAdapter{name: "a1", responses: map[string]string{
+		"1200+34": "The answer is 1,234.",
+		"Go add":  "func add(a, b int) int { a + b }",
+	}}
+	res := ProbeIntelligenceTasks(context.Background(), adapter, "m", time.Second, tasks)
+	if len(res.Details) != 2 || res.Details[0].Score != 75 || res.Details[1].Score != 12.5 || res.Score != 87.5 {
+		t.Fatalf("unexpected weighted scores: %+v", res)
+	}
+	if res.Details[0].Task != "sum" {
+		t.Fatalf("expected task name on detail, got %+v", res.Details[0])
+	}
+}
+
+func TestRunnerPersistsCustomProbeTasks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.json")
+	newRunner := func() *Runner {
+		return NewRunner(Config{}, []upstream.Adapter{upstream.NewMockAdapter("a1", false)}, scheduler.NewEngine(scheduler.Config{}, []string{"a1"}))
+	}
+	r := newRunner()
+	if err := r.LoadIntelligenceTasks(path); err != nil {
+		t.Fatalf("load missing file: %v", err)
+	}
+	if set := r.IntelligenceTasks(); set.Custom || len(set.Tasks) != 5 || set.Tasks[0].Validator != ValidatorBuiltin {
+		t.Fatalf("expected built-in suite, got %+v", set)
+	}
+	if _, err := r.SetIntelligenceTasks([]ProbeTask{{Prompt: "ping", Expected: "pong"}}); err != nil {
+		t.Fatalf("set tasks: %v", err)
+	}
+
+	reloaded := newRunner()
+	if err := reloaded.LoadIntelligenceTasks(path); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if set := reloaded.IntelligenceTasks(); !set.Custom || len(set.Tasks) != 1 || set.Tasks[0].Expected != "pong" {
+		t.Fatalf("expected persisted custom suite, got %+v", set)
+	}
+	if _, err := reloaded.SetIntelligenceTasks(nil); err != nil {
+		t.Fatalf("reset tasks: %v", err)
+	}
+	if set := reloaded.IntelligenceTasks(); set.Custom {
+		t.Fatalf("empty upload should restore the built-in suite")
+	}
+}
@@ -4,6 +4,9 @@ import (
 	. "ccgateway/internal/probe"
 	"context"
 	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -183,3 +186,107 @@ func TestRunnerUpdateConfigPatch(t *testing.T) {
 		t.Fatalf("expected smoke flags true")
 	}
 }
+
+func TestRunnerUsesAdapterHealthCheckInsteadOfChatProbe(t *testing.T) {
+	var healthy atomic.Bool
+	healthy.Store(true)
+	var chatCalls atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" {
+			chatCalls.Add(1)
+			http.Error(w, "unexpected", http.StatusInternalServerError)
+			return
+		}
+		if !healthy.Load() {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	adapter, err := upstream.BuildAdapterFromSpec(upstream.AdapterSpec{
+		Name:    "a1",
+		Kind:    upstream.AdapterKindOpenAI,
+		BaseURL: server.URL,
+		HealthCheck: &upstream.HealthCheckSpec{
+			Path:               "/health",
+			ExpectBodyContains: "ok",
+			IntervalMS:         10,
+		},
+	})
+	if err != nil {
+		t.Fatalf("build adapter: %v", err)
+	}
+	health := scheduler.NewEngine(scheduler.Config{
+		FailureThreshold: 2,
+		Cooldown:         2 * time.Second,
+		StrictProbeGate:  true,
+	}, []string{"a1"})
+	r := NewRunner(Config{
+		Enabled:       true,
+		Interval:      time.Hour,
+		Timeout:       time.Second,
+		StreamSmoke:   true,
+		ToolSmoke:     true,
+		DefaultModels: []string{"m1"},
+	}, []upstream.Adapter{adapter}, health)
+
+	r.RunOnce(context.Background())
+	if chatCalls.Load() != 0 {
+		t.Fatalf("expected no chat probe calls, got %d", chatCalls.Load())
+	}
+	if got := health.Order(orchestrator.Request{Model: "m1"}, []string{"a1"}, false); len(got) != 1 {
+		t.Fatalf("expected healthy adapter to be routable, got %v", got)
+	}
+	status := r.HealthChecks()["a1"]
+	if !status.OK || status.StatusCode != http.StatusOK || status.Path != "/health" || status.IntervalMS != 10 {
+		t.Fatalf("unexpected health check status: %+v", status)
+	}
+
+	healthy.Store(false)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r.Start(ctx)
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if r.HealthChecks()["a1"].Failures > 0 {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	status = r.HealthChecks()["a1"]
+	if status.OK || status.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected failing health check on its own interval, got %+v", status)
+	}
+	if got := health.Order(orchestrator.Request{Model: "m1"}, []string{"a1"}, false); len(got) != 0 {
+		t.Fatalf("expected unhealthy adapter to be filtered, got %v", got)
+	}
+}
+
+func TestRunnerReportsOutcomeChanges(t *testing.T) {
+	health := scheduler.NewEngine(scheduler.Config{}, []string{"a1"})
+	var failing atomic.Bool
+	adapter := &fakeAdapter{name: "a1", completeFn: func(req orchestrator.Request) (orchestrator.Response, error) {
+		if failing.Load() {
+			return orchestrator.Response{}, errors.New("upstream 503")
+		}
+		return orchestrator.Response{Model: req.Model, Blocks: []orchestrator.AssistantBlock{{Type: "text", Text: "ok"}}}, nil
+	}}
+	runner := NewRunner(Config{Enabled: true, Timeout: time.Second, DefaultModels: []string{"m1"}}, []upstream.Adapter{adapter}, health)
+	var outcomes []Outcome
+	runner.SetOnOutcomeChange(func(o Outcome) { outcomes = append(outcomes, o) })
+
+	runner.RunOnce(context.Background())
+	if len(outcomes) != 0 {
+		t.Fatalf("expected a first passing probe to stay quiet, got %+v", outcomes)
+	}
+	failing.Store(true)
+	runner.RunOnce(context.Background())
+	runner.RunOnce(context.Background())
+	failing.Store(false)
+	runner.RunOnce(context.Background())
+	if len(outcomes) != 2 || outcomes[0].OK || outcomes[0].Error != "upstream 503" || outcomes[0].Model != "m1" || !outcomes[1].OK {
+		t.Fatalf("expected one failure then one recovery, got %+v", outcomes)
+	}
+}
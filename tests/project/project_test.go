@@ -0,0 +1,93 @@
+package project_test
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	. "ccgateway/internal/project"
+)
+
+func TestStorePersistsProjectsAndTokenBindings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "projects.json")
+	store, err := New(Config{Path: path})
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	settings := json.RawMessage(`{"model_mappings":{"a":"b"}}`)
+	tokens := []int64{7, 3, 7}
+	if _, err := store.Create(Input{ID: "Acme"}); !errors.Is(err, ErrInvalidID) {
+		t.Fatalf("expected ErrInvalidID for a non-normalized id, got %v", err)
+	}
+	created, err := store.Create(Input{ID: "acme", Settings: &settings, TokenIDs: &tokens})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if len(created.TokenIDs) != 2 || created.TokenIDs[0] != 3 {
+		t.Fatalf("expected deduplicated sorted token ids, got %v", created.TokenIDs)
+	}
+	if _, err := store.Create(Input{ID: "acme"}); !errors.Is(err, ErrExists) {
+		t.Fatalf("expected ErrExists, got %v", err)
+	}
+	other := []int64{3}
+	if _, err := store.Create(Input{ID: "globex", TokenIDs: &other}); !errors.Is(err, ErrTokenBound) {
+		t.Fatalf("expected ErrTokenBound, got %v", err)
+	}
+	bad := json.RawMessage(`[1]`)
+	if _, err := store.Update("acme", Input{Settings: &bad}); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("expected ErrInvalidInput for non-object settings, got %v", err)
+	}
+
+	reloaded, err := New(Config{Path: path})
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	p, ok := reloaded.ForToken(7)
+	if !ok || p.ID != "acme" || string(p.Settings) != string(settings) {
+		t.Fatalf("expected acme restored with its binding, got %+v ok=%v", p, ok)
+	}
+	if err := reloaded.Delete("acme"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, ok := reloaded.ForToken(7); ok {
+		t.Fatalf("expected the binding removed with the project")
+	}
+}
+
+func TestStoreEnforcesQuota(t *testing.T) {
+	store, _ := New(Config{})
+	quota := Quota{RequestsPerMinute: 2, TokenBudget: 100}
+	if _, err := store.Create(Input{ID: "acme", Quota: &quota}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := store.Admit("acme"); err != nil {
+			t.Fatalf("admit %d: %v", i, err)
+		}
+	}
+	if err := store.Admit("acme"); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("expected ErrRateLimited, got %v", err)
+	}
+	if store.RetryAfter("acme") <= 0 {
+		t.Fatalf("expected a retry-after while limited")
+	}
+	if err := store.Admit("unknown"); err != nil {
+		t.Fatalf("expected unknown projects to pass, got %v", err)
+	}
+
+	store.Charge("acme", 100)
+	quota.RequestsPerMinute = 0
+	if _, err := store.Update("acme", Input{Quota: &quota}); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if err := store.Admit("acme"); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("expected ErrQuotaExceeded, got %v", err)
+	}
+	if _, err := store.ResetUsage("acme"); err != nil {
+		t.Fatalf("reset: %v", err)
+	}
+	if err := store.Admit("acme"); err != nil {
+		t.Fatalf("expected admission after reset, got %v", err)
+	}
+}
@@ -1,7 +1,9 @@
 package runlog_test
 
 import (
+	"bytes"
 	. "ccgateway/internal/runlog"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"strings"
@@ -85,3 +87,26 @@ func TestFileLoggerWritesDecodeDiagnosticsFields(t *testing.T) {
 		t.Fatalf("expected curl_command in log file, got: %s", text)
 	}
 }
+
+func TestSlogLoggerLevelsByStatus(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewSlogLogger(slog.New(slog.NewJSONHandler(&buf, nil)))
+	file, err := NewFileLogger(filepath.Join(t.TempDir(), "runs.log"))
+	if err != nil {
+		t.Fatalf("new file logger: %v", err)
+	}
+	multi := Multi{file, l}
+	_ = multi.Log(Entry{RunID: "run_ok", Path: "/v1/messages", Status: 200, DurationMS: 5})
+	_ = multi.Log(Entry{RunID: "run_bad", Path: "/v1/messages", Status: 502, Error: "upstream down"})
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected two records, got %q", buf.String())
+	}
+	if !strings.Contains(lines[0], `"level":"INFO"`) || !strings.Contains(lines[0], `"run_id":"run_ok"`) {
+		t.Fatalf("unexpected success record: %s", lines[0])
+	}
+	if !strings.Contains(lines[1], `"level":"ERROR"`) || !strings.Contains(lines[1], `"error":"upstream down"`) {
+		t.Fatalf("unexpected failure record: %s", lines[1])
+	}
+}
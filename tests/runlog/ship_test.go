@@ -0,0 +1,75 @@
+package runlog_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	. "ccgateway/internal/runlog"
+)
+
+type chunkStore struct {
+	chunks map[string]string
+	fail   bool
+}
+
+func (s *chunkStore) PutObject(_ context.Context, key string, data []byte, _ string) error {
+	if s.fail {
+		return errors.New("bucket unavailable")
+	}
+	s.chunks[key] = string(data)
+	return nil
+}
+
+func TestShippingLoggerUploadsChunks(t *testing.T) {
+	store := &chunkStore{chunks: map[string]string{}}
+	l := NewShippingLogger(store, ShipConfig{})
+	_ = l.Log(Entry{RunID: "run_1", Path: "/v1/messages", Status: 200})
+	_ = l.Log(Entry{RunID: "run_2", Path: "/v1/messages", Status: 502})
+
+	store.fail = true
+	if err := l.Flush(context.Background()); err == nil {
+		t.Fatalf("expected upload failure")
+	}
+	if buffered, _ := l.Stats(); buffered != 2 {
+		t.Fatalf("expected entries kept after failed upload, got %d", buffered)
+	}
+
+	store.fail = false
+	if err := l.Flush(context.Background()); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	if len(store.chunks) != 1 {
+		t.Fatalf("expected one chunk, got %d", len(store.chunks))
+	}
+	for key, chunk := range store.chunks {
+		if !strings.HasSuffix(key, ".jsonl") || strings.Count(key, "/") != 3 {
+			t.Fatalf("unexpected chunk key %q", key)
+		}
+		if strings.Count(chunk, "\n") != 2 || !strings.Contains(chunk, `"run_id":"run_1"`) || !strings.Contains(chunk, `"run_id":"run_2"`) {
+			t.Fatalf("unexpected chunk %q", chunk)
+		}
+	}
+	if err := l.Flush(context.Background()); err != nil || len(store.chunks) != 1 {
+		t.Fatalf("expected empty flush to upload nothing, err=%v chunks=%d", err, len(store.chunks))
+	}
+}
+
+func TestShippingLoggerDropsOldestBeyondBuffer(t *testing.T) {
+	store := &chunkStore{chunks: map[string]string{}, fail: true}
+	l := NewShippingLogger(store, ShipConfig{MaxBuffered: 2})
+	for _, id := range []string{"run_1", "run_2", "run_3"} {
+		_ = l.Log(Entry{RunID: id})
+	}
+	if buffered, dropped := l.Stats(); buffered != 2 || dropped != 1 {
+		t.Fatalf("expected 2 buffered and 1 dropped, got %d/%d", buffered, dropped)
+	}
+	store.fail = false
+	_ = l.Flush(context.Background())
+	for _, chunk := range store.chunks {
+		if strings.Contains(chunk, `"run_id":"run_1"`) {
+			t.Fatalf("expected the oldest entry dropped, got %q", chunk)
+		}
+	}
+}
@@ -126,3 +126,49 @@ func TestUpdateConfigPatch(t *testing.T) {
 		t.Fatalf("expected strict gate true")
 	}
 }
+
+func TestHealthChangeFiresOnCooldownAndRecovery(t *testing.T) {
+	e := NewEngine(Config{FailureThreshold: 2, Cooldown: time.Minute}, []string{"a1"})
+	var changes []HealthChange
+	e.SetOnHealthChange(func(h HealthChange) {
+		// The engine lock is released before the callback runs.
+		_ = e.Snapshot()
+		changes = append(changes, h)
+	})
+
+	e.ObserveFailure("a1", "m1", errors.New("boom"))
+	if len(changes) != 0 {
+		t.Fatalf("expected no change below the threshold, got %+v", changes)
+	}
+	e.ObserveFailure("a1", "m1", errors.New("boom"))
+	e.ObserveFailure("a1", "m1", errors.New("boom again"))
+	if len(changes) != 1 || !changes[0].Down || changes[0].ConsecutiveFailures != 2 || changes[0].LastError != "boom" {
+		t.Fatalf("expected a single down change, got %+v", changes)
+	}
+	e.ObserveSuccess("a1", "m1", time.Millisecond)
+	e.ObserveSuccess("a1", "m1", time.Millisecond)
+	if len(changes) != 2 || changes[1].Down || changes[1].Adapter != "a1" {
+		t.Fatalf("expected one recovery change, got %+v", changes)
+	}
+}
+
+func TestFirstTokenSLODemotesSlowStreams(t *testing.T) {
+	e := NewEngine(Config{FirstTokenSLO: 500 * time.Millisecond}, []string{"slow", "fast"})
+	req := orchestrator.Request{Model: "m1"}
+	e.ObserveStreamLatency("slow", "m1", 2*time.Second, 20)
+	e.ObserveStreamLatency("fast", "m1", 200*time.Millisecond, 60)
+
+	if got := e.Order(req, []string{"slow", "fast"}, true); got[0] != "fast" {
+		t.Fatalf("expected the adapter within the SLO first, got %v", got)
+	}
+
+	noSLO := NewEngine(Config{}, []string{"slow", "fast"})
+	noSLO.ObserveStreamLatency("slow", "m1", 2*time.Second, 20)
+	if got := noSLO.Order(req, []string{"slow", "fast"}, true); got[0] != "slow" {
+		t.Fatalf("expected route order without an SLO, got %v", got)
+	}
+	adapters := e.Snapshot()
+	if slow := adapters["slow"].(map[string]any); slow["first_token_ms"] != int64(2000) {
+		t.Fatalf("expected first_token_ms in snapshot, got %v", slow)
+	}
+}
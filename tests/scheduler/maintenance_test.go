@@ -0,0 +1,92 @@
+package scheduler_test
+
+import (
+	. "ccgateway/internal/scheduler"
+	"errors"
+	"testing"
+	"time"
+
+	"ccgateway/internal/orchestrator"
+)
+
+func TestMaintenanceWindowRoutesAroundAdapterWithoutCountingFailures(t *testing.T) {
+	now := time.Now().UTC()
+	e := NewEngine(Config{
+		FailureThreshold: 1,
+		Cooldown:         time.Minute,
+		MaintenanceWindows: []MaintenanceWindow{{
+			ID:       "cheap-nightly",
+			Adapter:  "cheap",
+			StartsAt: now.Add(-time.Minute),
+			EndsAt:   now.Add(time.Hour),
+			Reason:   "provider maintenance",
+		}},
+	}, []string{"cheap", "backup"})
+
+	got := e.Order(orchestrator.Request{Model: "m1"}, []string{"cheap", "backup"}, false)
+	if len(got) != 1 || got[0] != "backup" {
+		t.Fatalf("expected cheap routed around during maintenance, got %v", got)
+	}
+
+	e.ObserveFailure("cheap", "m1", errors.New("503 maintenance"))
+	snap := e.Snapshot()["cheap"].(map[string]any)
+	if snap["failures"].(int64) != 0 || snap["maintenance_failures"].(int64) != 1 || snap["in_maintenance"] != true {
+		t.Fatalf("expected failure recorded as maintenance only, got %+v", snap)
+	}
+
+	if _, err := e.UpdateConfigPatch(ConfigPatch{MaintenanceWindows: &[]MaintenanceWindow{}}); err != nil {
+		t.Fatalf("clear windows: %v", err)
+	}
+	got = e.Order(orchestrator.Request{Model: "m1"}, []string{"cheap", "backup"}, false)
+	if len(got) != 2 {
+		t.Fatalf("expected cheap back in rotation without cooldown, got %v", got)
+	}
+}
+
+func TestMaintenanceCronWindowActiveAndUpcoming(t *testing.T) {
+	e := NewEngine(Config{}, []string{"cheap"})
+	_, err := e.UpdateConfigPatch(ConfigPatch{MaintenanceWindows: &[]MaintenanceWindow{{
+		ID:         "nightly",
+		Adapter:    "cheap",
+		Cron:       "30 2 * * *",
+		DurationMS: int64(time.Hour / time.Millisecond),
+	}}})
+	if err != nil {
+		t.Fatalf("set windows: %v", err)
+	}
+
+	day := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+	if _, ok := e.InMaintenance("cheap", day.Add(2*time.Hour+45*time.Minute)); !ok {
+		t.Fatalf("expected 02:45 inside nightly window")
+	}
+	if _, ok := e.InMaintenance("cheap", day.Add(3*time.Hour+30*time.Minute)); ok {
+		t.Fatalf("expected 03:30 outside nightly window")
+	}
+
+	upcoming := e.UpcomingMaintenance(day.Add(12*time.Hour), 24*time.Hour)
+	if len(upcoming) != 1 {
+		t.Fatalf("expected one upcoming occurrence, got %+v", upcoming)
+	}
+	want := time.Date(2026, 3, 11, 2, 30, 0, 0, time.UTC)
+	if !upcoming[0].StartsAt.Equal(want) || !upcoming[0].EndsAt.Equal(want.Add(time.Hour)) || upcoming[0].Active {
+		t.Fatalf("unexpected occurrence: %+v", upcoming[0])
+	}
+}
+
+func TestMaintenanceWindowValidation(t *testing.T) {
+	cases := [][]MaintenanceWindow{
+		{{Adapter: "a1", Cron: "* * *", DurationMS: 60000}},
+		{{Adapter: "a1", Cron: "61 * * * *", DurationMS: 60000}},
+		{{Adapter: "a1", Cron: "0 2 * * *"}},
+		{{Cron: "0 2 * * *", DurationMS: 60000}},
+		{{Adapter: "a1"}},
+	}
+	for i, windows := range cases {
+		if err := ValidateMaintenanceWindows(windows); err == nil {
+			t.Fatalf("case %d: expected validation error", i)
+		}
+	}
+	if err := ValidateMaintenanceWindows([]MaintenanceWindow{{Adapter: "a1", Cron: "*/15 1-3 * * 1,7", DurationMS: 60000}}); err != nil {
+		t.Fatalf("expected valid cron, got %v", err)
+	}
+}
@@ -0,0 +1,143 @@
+package servertools_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	. "ccgateway/internal/servertools"
+	"ccgateway/internal/toolruntime"
+	"ccgateway/internal/workspace"
+)
+
+func builtinToolsByName(t *testing.T, root string) map[string]Tool {
+	t.Helper()
+	ws, err := workspace.New(workspace.Config{Root: root})
+	if err != nil {
+		t.Fatalf("new workspace: %v", err)
+	}
+	out := map[string]Tool{}
+	for _, tool := range NewBuiltinTools(ws, BuiltinToolsConfig{Shell: "sh", TimeoutMS: 2000, Backend: BackendSubprocess}) {
+		out[tool.Name()] = tool
+	}
+	return out
+}
+
+// sessionDir returns the workspace directory the tools use for sessionID.
+func sessionDir(t *testing.T, root, sessionID string) string {
+	t.Helper()
+	ws, err := workspace.New(workspace.Config{Root: root})
+	if err != nil {
+		t.Fatalf("new workspace: %v", err)
+	}
+	dir, err := ws.Dir(sessionID)
+	if err != nil {
+		t.Fatalf("session dir: %v", err)
+	}
+	return dir
+}
+
+func TestTextEditorEditsInSessionWorkspace(t *testing.T) {
+	root := t.TempDir()
+	editor := builtinToolsByName(t, root)[TextEditorName]
+	state := toolruntime.NewStateStore(time.Minute).Session("s1")
+	run := func(input map[string]any) toolruntime.Result {
+		t.Helper()
+		res, err := editor.Execute(context.Background(), toolruntime.Call{Name: editor.Name(), Input: input, SessionID: "s1", State: state})
+		if err != nil {
+			t.Fatalf("execute %v: %v", input["command"], err)
+		}
+		return res
+	}
+
+	if res := run(map[string]any{"command": "create", "path": "/repo/main.go", "file_text": "package main\n\nfunc main() {}\n"}); res.IsError {
+		t.Fatalf("create failed: %v", res.Content)
+	}
+	if _, err := os.Stat(filepath.Join(sessionDir(t, root, "s1"), "repo", "main.go")); err != nil {
+		t.Fatalf("expected the file inside the session workspace: %v", err)
+	}
+	if res := run(map[string]any{"command": "str_replace", "path": "/repo/main.go", "old_str": "func main() {}", "new_str": "func main() { run() }"}); res.IsError {
+		t.Fatalf("str_replace failed: %v", res.Content)
+	}
+	if res := run(map[string]any{"command": "insert", "path": "/repo/main.go", "insert_line": float64(1), "new_str": "// entry point"}); res.IsError {
+		t.Fatalf("insert failed: %v", res.Content)
+	}
+	view := run(map[string]any{"command": "view", "path": "/repo/main.go", "view_range": []any{float64(2), float64(4)}})
+	if view.Content != "     2\t// entry point\n     3\t\n     4\tfunc main() { run() }\n" {
+		t.Fatalf("unexpected view: %q", view.Content)
+	}
+	if res := run(map[string]any{"command": "str_replace", "path": "/repo/main.go", "old_str": "missing", "new_str": "x"}); !res.IsError {
+		t.Fatalf("expected an error for an unmatched old_str")
+	}
+
+	run(map[string]any{"command": "undo_edit", "path": "/repo/main.go"})
+	raw, _ := os.ReadFile(filepath.Join(sessionDir(t, root, "s1"), "repo", "main.go"))
+	if string(raw) != "package main\n\nfunc main() { run() }\n" {
+		t.Fatalf("expected undo to drop the insert, got %q", raw)
+	}
+
+	if res := run(map[string]any{"command": "view", "path": "../../etc/passwd"}); !res.IsError {
+		t.Fatalf("expected paths to stay inside the workspace, got %v", res.Content)
+	}
+	if err := os.Symlink("/etc", filepath.Join(sessionDir(t, root, "s1"), "escape")); err != nil {
+		t.Fatalf("symlink: %v", err)
+	}
+	if res := run(map[string]any{"command": "view", "path": "/escape/passwd"}); !res.IsError || !strings.Contains(res.Content.(string), "outside the workspace") {
+		t.Fatalf("expected symlinks out of the workspace rejected, got %v", res.Content)
+	}
+}
+
+func TestBashRunsInSessionWorkspace(t *testing.T) {
+	root := t.TempDir()
+	bash := builtinToolsByName(t, root)["bash"]
+	res, err := bash.Execute(context.Background(), toolruntime.Call{Name: "bash", SessionID: "s2", Input: map[string]any{"command": "echo hi > note.txt && pwd"}})
+	if err != nil || res.IsError {
+		t.Fatalf("bash failed: %v %v", err, res.Content)
+	}
+	if strings.TrimSpace(res.Content.(string)) != sessionDir(t, root, "s2") {
+		t.Fatalf("expected the session directory as cwd, got %q", res.Content)
+	}
+	if _, err := os.Stat(filepath.Join(sessionDir(t, root, "s2"), "note.txt")); err != nil {
+		t.Fatalf("expected the command to write into the workspace: %v", err)
+	}
+
+	res, _ = bash.Execute(context.Background(), toolruntime.Call{Name: "bash", SessionID: "s2", Input: map[string]any{"command": "exit 3"}})
+	if !res.IsError || !strings.Contains(res.Content.(string), "exit status 3") {
+		t.Fatalf("expected a failing command reported as an error, got %v", res.Content)
+	}
+}
+
+func TestBashRunsUnderSandboxLimits(t *testing.T) {
+	ws, err := workspace.New(workspace.Config{Root: t.TempDir()})
+	if err != nil {
+		t.Fatalf("new workspace: %v", err)
+	}
+	bash := NewBuiltinTools(ws, BuiltinToolsConfig{Shell: "sh", Backend: BackendSubprocess, CPUSeconds: 7, MemoryMB: 512})[0]
+	res, err := bash.Execute(context.Background(), toolruntime.Call{Name: "bash", SessionID: "s1", Input: map[string]any{"command": "ulimit -t; ulimit -v"}})
+	if err != nil || res.IsError {
+		t.Fatalf("bash failed: %v %v", err, res.Content)
+	}
+	if got := strings.Fields(res.Content.(string)); len(got) != 2 || got[0] != "7" || got[1] != "524288" {
+		t.Fatalf("expected the CPU and memory rlimits applied, got %q", res.Content)
+	}
+
+	// A stand-in runtime echoes the arguments it would pass to docker.
+	runtime := filepath.Join(t.TempDir(), "runtime")
+	if err := os.WriteFile(runtime, []byte("#!/bin/sh\necho \"$@\"\n"), 0o755); err != nil {
+		t.Fatalf("write runtime: %v", err)
+	}
+	bash = NewBuiltinTools(ws, BuiltinToolsConfig{Backend: BackendContainer, ContainerRuntime: runtime, Image: "img"})[0]
+	res, err = bash.Execute(context.Background(), toolruntime.Call{Name: "bash", SessionID: "s1", Input: map[string]any{"command": "ls"}})
+	if err != nil || res.IsError {
+		t.Fatalf("bash failed: %v %v", err, res.Content)
+	}
+	args := res.Content.(string)
+	for _, want := range []string{"--network none", "--read-only", "--volume " + sessionDir(t, ws.Config().Root, "s1") + ":/workspace", "img bash -c ls"} {
+		if !strings.Contains(args, want) {
+			t.Fatalf("expected %q in the container invocation, got %q", want, args)
+		}
+	}
+}
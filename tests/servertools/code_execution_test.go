@@ -0,0 +1,150 @@
+package servertools_test
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	. "ccgateway/internal/servertools"
+	"ccgateway/internal/toolruntime"
+)
+
+// shellAsPython runs "python" snippets with sh so the sandbox plumbing can be
+// exercised without a Python toolchain.
+func shellAsPython(t *testing.T, cfg CodeExecutionConfig) *CodeExecution {
+	t.Helper()
+	cfg.Backend = BackendSubprocess
+	cfg.PythonBin = "sh"
+	tool, err := NewCodeExecution(cfg)
+	if err != nil {
+		t.Fatalf("new code execution: %v", err)
+	}
+	return tool
+}
+
+func TestCodeExecutionSubprocessRun(t *testing.T) {
+	tool := shellAsPython(t, CodeExecutionConfig{})
+	res, err := tool.Execute(context.Background(), toolruntime.Call{
+		Name:  "code_execution",
+		Input: map[string]any{"code": "echo hello; pwd; echo oops >&2; exit 3"},
+	})
+	if err != nil || res.IsError {
+		t.Fatalf("execute: %v %+v", err, res)
+	}
+	content := res.Content.(map[string]any)
+	stdout, _ := content["stdout"].(string)
+	if !strings.HasPrefix(stdout, "hello\n") || !strings.Contains(stdout, "cc-code-exec-") {
+		t.Fatalf("expected output from scratch dir, got %q", stdout)
+	}
+	if content["stderr"] != "oops\n" || content["return_code"] != 3 {
+		t.Fatalf("unexpected result: %#v", content)
+	}
+}
+
+func TestCodeExecutionBudgets(t *testing.T) {
+	tool := shellAsPython(t, CodeExecutionConfig{TimeoutMS: 200, MaxOutputBytes: 16})
+	run := func(code string) toolruntime.Result {
+		res, err := tool.Execute(context.Background(), toolruntime.Call{
+			Name: "code_execution", Input: map[string]any{"code": code},
+		})
+		if err != nil {
+			t.Fatalf("execute: %v", err)
+		}
+		return res
+	}
+
+	res := run("yes | head -c 1000")
+	content := res.Content.(map[string]any)
+	if len(content["stdout"].(string)) != 16 || content["truncated"] != true {
+		t.Fatalf("expected output capped at 16 bytes, got %#v", content)
+	}
+
+	started := time.Now()
+	res = run("sleep 5")
+	if !res.IsError || res.Content.(map[string]any)["timed_out"] != true {
+		t.Fatalf("expected timeout result, got %+v", res)
+	}
+	if time.Since(started) > 3*time.Second {
+		t.Fatalf("expected run killed at its timeout budget")
+	}
+
+	res = run("   ")
+	if !res.IsError || !strings.Contains(res.Content.(string), "requires code") {
+		t.Fatalf("expected missing code reported as an error result, got %+v", res)
+	}
+}
+
+func TestCodeExecutionRunBudgets(t *testing.T) {
+	tool := shellAsPython(t, CodeExecutionConfig{RunOutputBytes: 10, RunTimeMS: 300})
+	run := func(runID, code string) toolruntime.Result {
+		res, err := tool.Execute(context.Background(), toolruntime.Call{
+			Name: "code_execution", Input: map[string]any{"code": code}, RunID: runID,
+		})
+		if err != nil {
+			t.Fatalf("execute: %v", err)
+		}
+		return res
+	}
+
+	if res := run("run_out", "echo 12345678"); res.IsError {
+		t.Fatalf("expected the first execution within budget, got %+v", res)
+	}
+	res := run("run_out", "echo abcdef")
+	if content := res.Content.(map[string]any); content["stdout"] != "a" || content["truncated"] != true {
+		t.Fatalf("expected output capped at what is left of the run budget, got %#v", content)
+	}
+	res = run("run_out", "echo more")
+	if !res.IsError || !strings.Contains(res.Content.(string), "budget exhausted") {
+		t.Fatalf("expected the run output budget enforced, got %+v", res)
+	}
+	if res := run("run_other", "echo fresh"); res.IsError {
+		t.Fatalf("expected other runs to keep their own budget, got %+v", res)
+	}
+
+	started := time.Now()
+	res = run("run_time", "sleep 5")
+	if !res.IsError || res.Content.(map[string]any)["timed_out"] != true || time.Since(started) > 3*time.Second {
+		t.Fatalf("expected the execution cut off at the run time budget, got %+v", res)
+	}
+	res = run("run_time", "echo late")
+	if !res.IsError || !strings.Contains(res.Content.(string), "budget exhausted") {
+		t.Fatalf("expected the run time budget enforced, got %+v", res)
+	}
+}
+
+func TestCodeExecutionPython(t *testing.T) {
+	if _, err := exec.LookPath("python3"); err != nil {
+		t.Skip("python3 not available")
+	}
+	tool, err := NewCodeExecution(CodeExecutionConfig{Backend: BackendSubprocess})
+	if err != nil {
+		t.Fatalf("new code execution: %v", err)
+	}
+	res, err := tool.Execute(context.Background(), toolruntime.Call{
+		Name:  "code_execution",
+		Input: map[string]any{"language": "python", "code": "print(sum(range(10)))"},
+	})
+	if err != nil || res.IsError {
+		t.Fatalf("execute: %v %+v", err, res)
+	}
+	if out := res.Content.(map[string]any)["stdout"]; out != "45\n" {
+		t.Fatalf("unexpected stdout %q", out)
+	}
+}
+
+func TestCodeExecutionConfigValidation(t *testing.T) {
+	if _, err := NewCodeExecution(CodeExecutionConfig{Backend: "vm"}); err == nil {
+		t.Fatalf("expected unknown backend to be rejected")
+	}
+	t.Setenv("CODE_EXECUTION_BACKEND", "")
+	if tool, err := NewCodeExecutionFromEnv(); err != nil || tool != nil {
+		t.Fatalf("expected code execution disabled without backend, got %v %v", tool, err)
+	}
+	t.Setenv("CODE_EXECUTION_BACKEND", "container")
+	t.Setenv("CODE_EXECUTION_TIMEOUT_MS", "abc")
+	if _, err := NewCodeExecutionFromEnv(); err == nil {
+		t.Fatalf("expected invalid timeout to be rejected")
+	}
+}
@@ -0,0 +1,180 @@
+package servertools_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ccgateway/internal/ccevent"
+	"ccgateway/internal/requestctx"
+	. "ccgateway/internal/servertools"
+	"ccgateway/internal/toolruntime"
+	"ccgateway/internal/workspace"
+)
+
+// serveRepo publishes a one-commit repository over git's dumb HTTP
+// protocol and returns its clone URL.
+func serveRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	src, bare := t.TempDir(), filepath.Join(t.TempDir(), "demo.git")
+	git := func(dir string, args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=t", "-c", "user.email=t@example.com"}, args...)...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	git(src, "init", "-q", "-b", "main")
+	if err := os.WriteFile(filepath.Join(src, "README.md"), []byte("demo\n"), 0o644); err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	git(src, "add", "-A")
+	git(src, "commit", "-q", "-m", "init")
+	git(src, "clone", "-q", "--bare", src, bare)
+	git(bare, "update-server-info")
+	srv := httptest.NewServer(http.FileServer(http.Dir(filepath.Dir(bare))))
+	t.Cleanup(srv.Close)
+	return srv.URL + "/demo.git"
+}
+
+func TestGitToolsCloneEditCommit(t *testing.T) {
+	repoURL := serveRepo(t)
+	ws, err := workspace.New(workspace.Config{Root: t.TempDir()})
+	if err != nil {
+		t.Fatalf("new workspace: %v", err)
+	}
+	events := ccevent.NewStore()
+	git := NewGitTools(ws, GitConfig{
+		AllowedHosts: []string{"127.0.0.1"},
+		ProjectHosts: map[string][]string{"locked": {"github.com"}},
+	}, events)
+	tools := map[string]Tool{}
+	for _, tool := range git.Tools() {
+		tools[tool.Name()] = tool
+	}
+	run := func(ctx context.Context, name string, input map[string]any) toolruntime.Result {
+		t.Helper()
+		res, err := tools[name].Execute(ctx, toolruntime.Call{ID: "toolu_" + name, Name: name, Input: input, SessionID: "s1"})
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		return res
+	}
+	ctx := context.Background()
+
+	if res := run(ctx, GitCloneName, map[string]any{"url": repoURL}); res.IsError {
+		t.Fatalf("clone failed: %v", res.Content)
+	}
+	if got, err := ws.Read("s1", "/demo/README.md"); err != nil || string(got) != "demo\n" {
+		t.Fatalf("expected the clone in the workspace, got %q %v", got, err)
+	}
+	for _, url := range []string{"file:///etc", "ext::sh -c touch% /tmp/pwned", "https://evil.example/x.git"} {
+		if res := run(ctx, GitCloneName, map[string]any{"url": url}); !res.IsError {
+			t.Fatalf("expected %q rejected", url)
+		}
+	}
+	locked := requestctx.WithProjectID(ctx, "locked")
+	if res := run(locked, GitCloneName, map[string]any{"url": repoURL, "path": "again"}); !res.IsError || !strings.Contains(res.Content.(string), "not allowed") {
+		t.Fatalf("expected the project allowlist to apply, got %v", res.Content)
+	}
+
+	if res := run(ctx, GitBranchName, map[string]any{"path": "demo", "name": "feature/docs", "create": true}); res.IsError {
+		t.Fatalf("branch failed: %v", res.Content)
+	}
+	if err := ws.Write("s1", "/demo/README.md", []byte("demo\nmore\n")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if res := run(ctx, GitStatusName, map[string]any{"path": "demo"}); !strings.Contains(res.Content.(string), "## feature/docs") || !strings.Contains(res.Content.(string), " M README.md") {
+		t.Fatalf("unexpected status: %v", res.Content)
+	}
+	if res := run(ctx, GitDiffName, map[string]any{"path": "demo"}); !strings.Contains(res.Content.(string), "+more") {
+		t.Fatalf("unexpected diff: %v", res.Content)
+	}
+	if res := run(ctx, GitDiffName, map[string]any{"path": "demo", "ref": "--output=/tmp/x"}); !res.IsError {
+		t.Fatalf("expected an option-like ref rejected")
+	}
+	if res := run(ctx, GitCommitName, map[string]any{"path": "demo", "message": "docs: more"}); res.IsError {
+		t.Fatalf("commit failed: %v", res.Content)
+	}
+	if res := run(ctx, GitStatusName, map[string]any{"path": "demo"}); strings.Contains(res.Content.(string), "README.md") {
+		t.Fatalf("expected a clean tree after commit, got %v", res.Content)
+	}
+
+	audit := events.List(ccevent.ListFilter{EventType: "tool.git"}) // newest first
+	if len(audit) != 11 {
+		t.Fatalf("expected every operation audited, got %d", len(audit))
+	}
+	if denied := audit[6]; denied.Data["ok"] != false || denied.Data["project_id"] != "locked" || denied.Data["host"] != "127.0.0.1" {
+		t.Fatalf("unexpected denial event: %#v", denied.Data)
+	}
+	if commit := audit[1]; commit.Data["tool"] != GitCommitName || len(commit.Data["commit"].(string)) != 40 {
+		t.Fatalf("expected the commit hash audited, got %#v", commit.Data)
+	}
+}
+
+func TestGitToolsIgnoreProgramsInRepositoryConfig(t *testing.T) {
+	repoURL := serveRepo(t)
+	ws, err := workspace.New(workspace.Config{Root: t.TempDir()})
+	if err != nil {
+		t.Fatalf("new workspace: %v", err)
+	}
+	tools := map[string]Tool{}
+	for _, tool := range NewGitTools(ws, GitConfig{AllowedHosts: []string{"127.0.0.1"}}, nil).Tools() {
+		tools[tool.Name()] = tool
+	}
+	run := func(name string, input map[string]any) toolruntime.Result {
+		t.Helper()
+		res, err := tools[name].Execute(context.Background(), toolruntime.Call{Name: name, Input: input, SessionID: "s1"})
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		return res
+	}
+	if res := run(GitCloneName, map[string]any{"url": repoURL}); res.IsError {
+		t.Fatalf("clone failed: %v", res.Content)
+	}
+
+	if err := ws.Write("s1", "/demo/.git/config", []byte("[core]\n")); !errors.Is(err, workspace.ErrProtectedPath) {
+		t.Fatalf("expected writes into .git rejected, got %v", err)
+	}
+
+	// Anything else able to write the repository, such as a shell, could
+	// still plant programs in its config.
+	marker := filepath.Join(t.TempDir(), "ran")
+	repo, _ := ws.Resolve("s1", "/demo")
+	config, err := os.OpenFile(filepath.Join(repo, ".git", "config"), os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("open config: %v", err)
+	}
+	_, _ = config.WriteString("[core]\n\tfsmonitor = \"touch " + marker + "; false\"\n" +
+		"[filter \"x\"]\n\tclean = \"touch " + marker + "; cat\"\n\tsmudge = \"touch " + marker + "; cat\"\n" +
+		"[diff \"x\"]\n\ttextconv = \"touch " + marker + "; cat\"\n")
+	config.Close()
+	if err := ws.Write("s1", "/demo/.gitattributes", []byte("* filter=x diff=x\n")); err != nil {
+		t.Fatalf("write attributes: %v", err)
+	}
+	if err := ws.Write("s1", "/demo/README.md", []byte("changed\n")); err != nil {
+		t.Fatalf("write readme: %v", err)
+	}
+
+	run(GitStatusName, map[string]any{"path": "/demo"})
+	run(GitDiffName, map[string]any{"path": "/demo"})
+	if res := run(GitCommitName, map[string]any{"path": "/demo", "message": "edit"}); res.IsError {
+		t.Fatalf("commit failed: %v", res.Content)
+	}
+	run(GitDiffName, map[string]any{"path": "/demo", "ref": "HEAD~1"})
+	run(GitBranchName, map[string]any{"path": "/demo", "name": "feature", "create": true})
+	if _, err := os.Stat(marker); err == nil {
+		t.Fatalf("expected no program from the repository config to run")
+	}
+}
@@ -0,0 +1,138 @@
+package servertools_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "ccgateway/internal/servertools"
+	"ccgateway/internal/toolruntime"
+)
+
+func TestWebSearchSearXNGBackend(t *testing.T) {
+	var gotQuery string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotQuery = r.URL.Query().Get("q")
+		if r.URL.Query().Get("format") != "json" {
+			t.Fatalf("expected format=json, got %q", r.URL.RawQuery)
+		}
+		w.Header().Set("content-type", "application/json")
+		_, _ = w.Write([]byte(`{"results":[
+			{"title":"Go","url":"https://go.dev/doc","content":"docs"},
+			{"title":"Spam","url":"https://spam.example.com/x","content":"ads"},
+			{"title":"Blog","url":"https://blog.go.dev/post","content":"news"}
+		]}`))
+	}))
+	defer backend.Close()
+
+	tool, err := NewWebSearch(WebSearchConfig{Provider: ProviderSearXNG, Endpoint: backend.URL + "/search"})
+	if err != nil {
+		t.Fatalf("new web search: %v", err)
+	}
+	res, err := tool.Execute(context.Background(), toolruntime.Call{
+		Name:  "web_search",
+		Input: map[string]any{"query": "golang generics", "allowed_domains": []any{"go.dev"}, "max_results": float64(1)},
+	})
+	if err != nil || res.IsError {
+		t.Fatalf("execute: %v %+v", err, res)
+	}
+	if gotQuery != "golang generics" {
+		t.Fatalf("unexpected backend query %q", gotQuery)
+	}
+	content := res.Content.(map[string]any)
+	results := content["results"].([]SearchResult)
+	if len(results) != 1 || results[0].URL != "https://go.dev/doc" || results[0].Snippet != "docs" {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+}
+
+func TestWebSearchBraveAndTavilyAuth(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", "application/json")
+		switch r.URL.Path {
+		case "/brave":
+			if r.Header.Get("X-Subscription-Token") != "bk" {
+				t.Fatalf("missing brave token")
+			}
+			_, _ = w.Write([]byte(`{"web":{"results":[{"title":"B","url":"https://b.example.com","description":"brave hit"}]}}`))
+		case "/tavily":
+			if r.Header.Get("authorization") != "Bearer tk" || r.Method != http.MethodPost {
+				t.Fatalf("unexpected tavily request: %s %q", r.Method, r.Header.Get("authorization"))
+			}
+			var body map[string]any
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			if body["query"] != "q1" {
+				t.Fatalf("unexpected tavily body: %#v", body)
+			}
+			_, _ = w.Write([]byte(`{"results":[{"title":"T","url":"https://t.example.com","content":"tavily hit"}]}`))
+		}
+	}))
+	defer backend.Close()
+
+	brave, err := NewWebSearch(WebSearchConfig{Provider: ProviderBrave, Endpoint: backend.URL + "/brave", APIKey: "bk"})
+	if err != nil {
+		t.Fatalf("new brave: %v", err)
+	}
+	got, err := brave.Search(context.Background(), "q1")
+	if err != nil || len(got) != 1 || got[0].Snippet != "brave hit" {
+		t.Fatalf("brave search: %+v err=%v", got, err)
+	}
+	tavily, err := NewWebSearch(WebSearchConfig{Provider: ProviderTavily, Endpoint: backend.URL + "/tavily", APIKey: "tk"})
+	if err != nil {
+		t.Fatalf("new tavily: %v", err)
+	}
+	got, err = tavily.Search(context.Background(), "q1")
+	if err != nil || len(got) != 1 || got[0].URL != "https://t.example.com" {
+		t.Fatalf("tavily search: %+v err=%v", got, err)
+	}
+}
+
+func TestWebSearchBackendFailureIsToolError(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer backend.Close()
+
+	tool, err := NewWebSearch(WebSearchConfig{Endpoint: backend.URL + "/s?q={query}"})
+	if err != nil {
+		t.Fatalf("new web search: %v", err)
+	}
+	if tool.Config().Provider != ProviderCustom {
+		t.Fatalf("expected endpoint without provider to select custom, got %q", tool.Config().Provider)
+	}
+	res, err := tool.Execute(context.Background(), toolruntime.Call{Name: "web_search", Input: map[string]any{"query": "x"}})
+	if err != nil || !res.IsError {
+		t.Fatalf("expected error result, got %+v err=%v", res, err)
+	}
+	if _, err := tool.Execute(context.Background(), toolruntime.Call{Name: "web_search", Input: map[string]any{}}); err == nil {
+		t.Fatalf("expected missing query to fail")
+	}
+}
+
+func TestWebSearchConfigValidation(t *testing.T) {
+	if _, err := NewWebSearch(WebSearchConfig{Provider: ProviderBrave}); err == nil {
+		t.Fatalf("expected brave without api key to fail")
+	}
+	if _, err := NewWebSearch(WebSearchConfig{Provider: ProviderSearXNG}); err == nil {
+		t.Fatalf("expected searxng without endpoint to fail")
+	}
+	if _, err := NewWebSearch(WebSearchConfig{Provider: "bing"}); err == nil {
+		t.Fatalf("expected unknown provider to fail")
+	}
+}
+
+func TestRegisterOverridesRegistryHandler(t *testing.T) {
+	reg := toolruntime.NewDefaultExecutor()
+	tool, _ := NewWebSearch(WebSearchConfig{Provider: ProviderCustom, Endpoint: "http://127.0.0.1:1/{query}", TimeoutMS: 200})
+	Register(reg, tool)
+	res, err := reg.Execute(context.Background(), toolruntime.Call{Name: "web_search", Input: map[string]any{"query": "x"}})
+	if err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if msg, _ := res.Content.(string); !res.IsError || !strings.HasPrefix(msg, "web search failed") {
+		t.Fatalf("expected registered server tool to handle web_search, got %+v", res)
+	}
+}
@@ -0,0 +1,54 @@
+package servertools_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	. "ccgateway/internal/servertools"
+	"ccgateway/internal/toolruntime"
+	"ccgateway/internal/workspace"
+)
+
+func TestWorkspaceToolsEditAndDiff(t *testing.T) {
+	ws, err := workspace.New(workspace.Config{Root: t.TempDir(), QuotaBytes: 64})
+	if err != nil {
+		t.Fatalf("new workspace: %v", err)
+	}
+	tools := map[string]Tool{}
+	for _, tool := range NewWorkspaceTools(ws) {
+		tools[tool.Name()] = tool
+	}
+	states := toolruntime.NewStateStore(time.Minute)
+	run := func(name string, input map[string]any) toolruntime.Result {
+		t.Helper()
+		res, err := tools[name].Execute(context.Background(), toolruntime.Call{Name: name, Input: input, SessionID: "s1", State: states.Session("s1")})
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		return res
+	}
+
+	if res := run(WorkspaceWriteName, map[string]any{"file_path": "/app/config.yaml", "content": "port: 80\nhost: a\n"}); res.IsError {
+		t.Fatalf("write failed: %v", res.Content)
+	}
+	if res := run(WorkspaceEditName, map[string]any{"file_path": "/app/config.yaml", "old_string": "port: 80", "new_string": "port: 8080"}); res.IsError {
+		t.Fatalf("edit failed: %v", res.Content)
+	}
+	if res := run(WorkspaceReadName, map[string]any{"file_path": "/app/config.yaml", "offset": float64(1), "limit": float64(1)}); res.Content != "     1\tport: 8080\n" {
+		t.Fatalf("unexpected read: %q", res.Content)
+	}
+	if res := run(WorkspaceListName, map[string]any{"path": "/app"}); res.Content != "- /app/config.yaml (19 bytes)\n" {
+		t.Fatalf("unexpected listing: %q", res.Content)
+	}
+	if res := run(WorkspaceDiffName, map[string]any{}); !strings.Contains(res.Content.(string), "+port: 8080") {
+		t.Fatalf("expected the new file in the diff, got %v", res.Content)
+	}
+	if res := run(WorkspaceWriteName, map[string]any{"file_path": "/big.txt", "content": strings.Repeat("x", 60)}); !res.IsError || !strings.Contains(res.Content.(string), "quota") {
+		t.Fatalf("expected the quota enforced, got %v", res.Content)
+	}
+	if info, _ := states.Info("s1"); len(info.Keys) != 1 {
+		t.Fatalf("expected the workspace recorded in session state, got %#v", info.Keys)
+	}
+}
@@ -98,6 +98,34 @@ func TestStoreForkInheritAndOverride(t *testing.T) {
 	}
 }
 
+func TestStoreForkCopiesMessages(t *testing.T) {
+	st := NewStore()
+	parent, _ := st.Create(CreateInput{ID: "sess_parent"})
+	if err := st.AppendMessage(parent.ID, SessionMessage{Role: "user", Content: "hi"}); err != nil {
+		t.Fatalf("append message: %v", err)
+	}
+	child, err := st.Fork(parent.ID, CreateInput{})
+	if err != nil {
+		t.Fatalf("fork: %v", err)
+	}
+	if len(child.Messages) != 1 || child.Messages[0].Content != "hi" {
+		t.Fatalf("expected copied history, got %#v", child.Messages)
+	}
+	seeded, err := st.Fork(parent.ID, CreateInput{Messages: []SessionMessage{}})
+	if err != nil {
+		t.Fatalf("fork seeded: %v", err)
+	}
+	if len(seeded.Messages) != 0 {
+		t.Fatalf("expected the given empty history, got %#v", seeded.Messages)
+	}
+	if err := st.AppendMessage(child.ID, SessionMessage{Role: "assistant", Content: "hello"}); err != nil {
+		t.Fatalf("append to child: %v", err)
+	}
+	if got, _ := st.Get(parent.ID); len(got.Messages) != 1 {
+		t.Fatalf("parent must not change, got %#v", got.Messages)
+	}
+}
+
 func TestStoreCreateRejectDuplicateID(t *testing.T) {
 	st := NewStore()
 	if _, err := st.Create(CreateInput{ID: "sess_dup"}); err != nil {
@@ -350,3 +350,216 @@ func TestNewFromEnvRespectsExplicitIntelligentDispatchBoolOverrides(t *testing.T
 		t.Fatalf("expected fallback_to_scheduler=false from explicit env override")
 	}
 }
+
+func TestStoreCanarySanitizeAndClone(t *testing.T) {
+	s := NewStore(RuntimeSettings{
+		Routing: RoutingSettings{
+			Canary: CanarySettings{
+				Enabled:  true,
+				Percent:  150,
+				Route:    []string{" a2 ", ""},
+				Modes:    []string{" Plan "},
+				StickyBy: " USER ",
+			},
+		},
+	})
+	got := s.Get().Routing.Canary
+	if got.Name != "canary" || got.Percent != 100 || got.StickyBy != CanaryStickyUser {
+		t.Fatalf("unexpected sanitized canary: %+v", got)
+	}
+	if len(got.Route) != 1 || got.Route[0] != "a2" || got.Modes[0] != "plan" {
+		t.Fatalf("unexpected canary route/modes: %+v", got)
+	}
+	got.Route[0] = "mutated"
+	if s.Get().Routing.Canary.Route[0] != "a2" {
+		t.Fatalf("expected canary route to be cloned")
+	}
+
+	s.Put(RuntimeSettings{Routing: RoutingSettings{Canary: CanarySettings{StickyBy: "cookie"}}})
+	if got := s.Get().Routing.Canary.StickyBy; got != CanaryStickyRequest {
+		t.Fatalf("expected unknown sticky_by to fall back to request, got %q", got)
+	}
+}
+
+func TestDegradationSanitizeAndLadderFor(t *testing.T) {
+	cfg := DefaultRuntimeSettings()
+	cfg.Routing.Degradation = DegradationSettings{
+		Enabled: true,
+		Ladder: []DegradationStep{
+			{Action: " Disable_Judge ", InFlight: 5},
+			{Action: "shrink_max_tokens"},
+			{Action: "cheap_model", Model: " haiku ", ErrorRate: 3},
+			{Action: "explode"},
+		},
+		ModeLadders: map[string][]DegradationStep{
+			" Plan ": {{Action: "reject", InFlight: 1}},
+		},
+	}
+	store := NewStore(cfg)
+	got := store.Get().Routing.Degradation
+	if got.WindowSeconds != 60 || got.MinSamples != 20 {
+		t.Fatalf("expected window/min sample defaults, got %+v", got)
+	}
+	if len(got.Ladder) != 2 || got.Ladder[0].Action != DegradeDisableJudge || got.Ladder[1].Model != "haiku" || got.Ladder[1].ErrorRate != 1 {
+		t.Fatalf("unexpected sanitized ladder: %+v", got.Ladder)
+	}
+	if plan := got.LadderFor("plan"); len(plan) != 1 || plan[0].Action != DegradeReject {
+		t.Fatalf("expected plan ladder, got %+v", plan)
+	}
+	if chat := got.LadderFor("chat"); len(chat) != 2 {
+		t.Fatalf("expected default ladder for chat, got %+v", chat)
+	}
+}
+
+func TestJudgeRubricSanitizeAndRubricFor(t *testing.T) {
+	cfg := DefaultRuntimeSettings()
+	cfg.Routing.Judge = JudgeSettings{
+		Rubric: JudgeRubric{
+			Prompt: "  Judge {{mode}} answers. ",
+			Dimensions: []JudgeDimension{
+				{Name: " Accuracy ", Weight: 3},
+				{Name: "accuracy", Weight: 9},
+				{Name: "formatting"},
+				{Name: "  "},
+			},
+		},
+		ModeRubrics: map[string]JudgeRubric{
+			" Plan ": {Dimensions: []JudgeDimension{{Name: "safety", Weight: 2}}},
+		},
+	}
+	store := NewStore(cfg)
+	got := store.Get().Routing.Judge
+	if got.Rubric.Prompt != "Judge {{mode}} answers." {
+		t.Fatalf("expected trimmed prompt, got %q", got.Rubric.Prompt)
+	}
+	dims := got.Rubric.Dimensions
+	if len(dims) != 2 || dims[0].Name != "accuracy" || dims[0].Weight != 3 || dims[1].Name != "formatting" || dims[1].Weight != 1 {
+		t.Fatalf("unexpected sanitized dimensions: %+v", dims)
+	}
+	if plan := got.RubricFor("plan"); len(plan.Dimensions) != 1 || plan.Dimensions[0].Name != "safety" {
+		t.Fatalf("expected plan rubric, got %+v", plan)
+	}
+	if chat := got.RubricFor("chat"); len(chat.Dimensions) != 2 {
+		t.Fatalf("expected default rubric for chat, got %+v", chat)
+	}
+
+	got.Rubric.Dimensions[0].Name = "mutated"
+	if store.Get().Routing.Judge.Rubric.Dimensions[0].Name != "accuracy" {
+		t.Fatalf("Get must return a copy of the rubric")
+	}
+}
+
+func TestPromptExperimentSanitizeAndLookup(t *testing.T) {
+	cfg := DefaultRuntimeSettings()
+	cfg.PromptExperiments = map[string]PromptExperiment{
+		" Chat ": {
+			Enabled: true,
+			Variants: []PromptVariant{
+				{Name: " a ", Prefix: "  Be terse. "},
+				{Name: "a", Prefix: "dup"},
+				{Name: "b", Weight: -2},
+				{Name: " "},
+			},
+			StickyBy: " TOKEN ",
+			Promoted: "c",
+		},
+		"plan": {Variants: []PromptVariant{{Name: "x"}}},
+	}
+	store := NewStore(cfg)
+	exp, ok := store.PromptExperiment("chat")
+	if !ok {
+		t.Fatalf("expected chat experiment")
+	}
+	if len(exp.Variants) != 2 || exp.Variants[0].Prefix != "Be terse." || exp.Variants[1].Weight != 1 {
+		t.Fatalf("unexpected sanitized variants: %+v", exp.Variants)
+	}
+	if exp.StickyBy != CanaryStickyToken || exp.MinSamples != 30 || exp.Confidence != 0.95 || exp.Promoted != "" {
+		t.Fatalf("unexpected experiment defaults: %+v", exp)
+	}
+	if _, ok := store.PromptExperiment("plan"); ok {
+		t.Fatalf("disabled experiment should not be returned")
+	}
+
+	exp.Variants[0].Name = "mutated"
+	if got, _ := store.PromptExperiment("chat"); got.Variants[0].Name != "a" {
+		t.Fatalf("PromptExperiment must return a copy")
+	}
+}
+
+func TestStoreOverlayMergesNestedObjects(t *testing.T) {
+	base := DefaultRuntimeSettings()
+	base.ModelMappings = map[string]string{"a": "global-a", "b": "global-b"}
+	base.Routing.TimeoutMS = 5000
+	s := NewStore(base)
+
+	scoped, err := s.Overlay([]byte(`{"model_mappings":{"a":"project-a"},"routing":{"retries":3}}`))
+	if err != nil {
+		t.Fatalf("overlay: %v", err)
+	}
+	got := scoped.Get()
+	if got.ModelMappings["a"] != "project-a" || got.ModelMappings["b"] != "global-b" {
+		t.Fatalf("expected mappings merged key by key, got %v", got.ModelMappings)
+	}
+	if got.Routing.Retries != 3 || got.Routing.TimeoutMS != 5000 {
+		t.Fatalf("expected routing merged, got %+v", got.Routing)
+	}
+	if s.Get().ModelMappings["a"] != "global-a" {
+		t.Fatalf("expected the global store untouched")
+	}
+	if _, err := s.Overlay([]byte(`{"routing":{"retries":"many"}}`)); err == nil {
+		t.Fatalf("expected a type error for a bad override")
+	}
+}
+
+func TestStoreModeParamsSanitizeAndFallback(t *testing.T) {
+	cold, hot, badTopP := 0.1, 3.0, 1.5
+	cfg, err := Parse([]byte(`{}`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	cfg.ModeParams = map[string]ModeParams{
+		" Plan ":  {Temperature: &cold, MaxTokens: 512, StopSequences: []string{" END ", ""}},
+		"default": {Temperature: &hot, TopP: &badTopP, MaxTokens: -1},
+		"chat":    {MaxTokens: 2048},
+	}
+	s := NewStore(DefaultRuntimeSettings())
+	s.Put(cfg)
+
+	plan, ok := s.ModeParamsFor("plan")
+	if !ok || plan.Temperature == nil || *plan.Temperature != 0.1 || plan.MaxTokens != 512 {
+		t.Fatalf("unexpected plan params: %+v", plan)
+	}
+	if len(plan.StopSequences) != 1 || plan.StopSequences[0] != "END" {
+		t.Fatalf("expected trimmed stop sequences, got %v", plan.StopSequences)
+	}
+	if _, ok := s.Get().ModeParams["default"]; ok {
+		t.Fatalf("expected entry with only invalid values to be dropped")
+	}
+	if _, ok := s.ModeParamsFor("review"); ok {
+		t.Fatalf("expected no params for mode without entry or default")
+	}
+	*plan.Temperature = 1
+	if again, _ := s.ModeParamsFor("plan"); *again.Temperature != 0.1 {
+		t.Fatalf("expected params to be cloned")
+	}
+}
+
+func TestStoreToolResultLimitsSanitize(t *testing.T) {
+	cfg, err := Parse([]byte(`{"tool_loop":{"result_limits":{" KB_Search ":{"max_chars":4000,"strategy":"Summarize","model":" small "},"*":{"max_chars":8000,"strategy":"middle"},"shell":{"max_chars":0}}}}`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	s := NewStore(DefaultRuntimeSettings())
+	s.Put(cfg)
+
+	limits := s.Get().ToolLoop.ResultLimits
+	if len(limits) != 2 {
+		t.Fatalf("expected the uncapped entry dropped, got %#v", limits)
+	}
+	if got := limits["kb_search"]; got.MaxChars != 4000 || got.Strategy != ToolResultSummarize || got.Model != "small" {
+		t.Fatalf("unexpected kb_search limit: %#v", got)
+	}
+	if got := limits["*"]; got.Strategy != ToolResultHead {
+		t.Fatalf("expected unknown strategy to default to head, got %#v", got)
+	}
+}
@@ -1,10 +1,15 @@
 package statepersist_test
 
 import (
+	"bytes"
 	. "ccgateway/internal/statepersist"
+	"errors"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"ccgateway/internal/ccrun"
+	"ccgateway/internal/dataprotect"
 	"ccgateway/internal/plan"
 	"ccgateway/internal/todo"
 )
@@ -77,3 +82,156 @@ func TestManagerAutoSaveOnChange(t *testing.T) {
 		t.Fatalf("unexpected loaded plans: %+v", got)
 	}
 }
+
+type flakyBackend struct {
+	fail bool
+}
+
+func (b *flakyBackend) Load(string, any) error { return ErrNotFound }
+
+func (b *flakyBackend) Save(string, any) error {
+	if b.fail {
+		return errors.New("disk full")
+	}
+	return nil
+}
+
+func TestManagerDegradesAfterRepeatedSaveFailures(t *testing.T) {
+	backend := &flakyBackend{fail: true}
+	runs := ccrun.NewStore()
+	manager := NewManager(backend, runs, nil, nil)
+	manager.SetHealthConfig(HealthConfig{FailureThreshold: 2, ReadOnlyOnDegraded: true})
+	var transitions []HealthStatus
+	manager.SetOnHealthChange(func(h HealthStatus) { transitions = append(transitions, h) })
+	manager.BindAutoSave()
+
+	if _, err := runs.Create(ccrun.CreateInput{Path: "/v1/messages"}); err != nil {
+		t.Fatal(err)
+	}
+	if h := manager.Health(); h.Degraded || h.ConsecutiveFailures != 1 || manager.ReadOnly() {
+		t.Fatalf("expected a single failure to stay healthy: %+v", h)
+	}
+	if _, err := runs.Create(ccrun.CreateInput{Path: "/v1/messages"}); err != nil {
+		t.Fatal(err)
+	}
+	h := manager.Health()
+	if !h.Degraded || !h.ReadOnly || h.LastError != "disk full" || h.DegradedSince == nil {
+		t.Fatalf("expected degraded read-only state: %+v", h)
+	}
+	if len(transitions) != 1 || !transitions[0].Degraded {
+		t.Fatalf("expected one degraded transition, got %+v", transitions)
+	}
+
+	backend.fail = false
+	if err := manager.SaveAll(); err != nil {
+		t.Fatal(err)
+	}
+	if h := manager.Health(); h.Degraded || h.ReadOnly || h.TotalFailures != 2 || h.LastSuccessAt == nil {
+		t.Fatalf("expected recovery: %+v", h)
+	}
+	if len(transitions) != 2 || transitions[1].Degraded {
+		t.Fatalf("expected recovered transition, got %+v", transitions)
+	}
+}
+
+func TestManagerEncryptsRunTextPerProject(t *testing.T) {
+	dir := t.TempDir()
+	backend, err := NewFileBackend(dir)
+	if err != nil {
+		t.Fatalf("new backend: %v", err)
+	}
+	keys, err := dataprotect.NewKeyring(bytes.Repeat([]byte{9}, 32), nil)
+	if err != nil {
+		t.Fatalf("keyring: %v", err)
+	}
+	runs := ccrun.NewStore()
+	if _, err := runs.Create(ccrun.CreateInput{ID: "run_1", Path: "/v1/messages", ProjectID: "acme"}); err != nil {
+		t.Fatalf("create run: %v", err)
+	}
+	if _, err := runs.Complete("run_1", ccrun.CompleteInput{StatusCode: 200, Prompt: "top secret prompt", Output: "top secret answer"}); err != nil {
+		t.Fatalf("complete run: %v", err)
+	}
+	manager := NewManager(backend, runs, nil, nil)
+	manager.SetCipher(keys)
+	if err := manager.SaveAll(); err != nil {
+		t.Fatalf("save all: %v", err)
+	}
+	raw, err := os.ReadFile(filepath.Join(dir, "runs.json"))
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if bytes.Contains(raw, []byte("top secret")) {
+		t.Fatalf("run text persisted in clear: %s", raw)
+	}
+	if run, _ := runs.Get("run_1"); run.PromptText != "top secret prompt" {
+		t.Fatalf("in-memory run must stay readable, got %q", run.PromptText)
+	}
+
+	restored := ccrun.NewStore()
+	plain := NewManager(backend, restored, nil, nil)
+	if err := plain.LoadAll(); err == nil {
+		t.Fatalf("loading sealed state without a key must fail")
+	}
+	sealed := NewManager(backend, restored, nil, nil)
+	sealed.SetCipher(keys)
+	if err := sealed.LoadAll(); err != nil {
+		t.Fatalf("load all: %v", err)
+	}
+	if run, _ := restored.Get("run_1"); run.PromptText != "top secret prompt" || run.OutputText != "top secret answer" {
+		t.Fatalf("unexpected restored run: %+v", run)
+	}
+}
+
+func TestManagerSealsTextThatLooksSealed(t *testing.T) {
+	keys, err := dataprotect.NewKeyring(bytes.Repeat([]byte{9}, 32), nil)
+	if err != nil {
+		t.Fatalf("keyring: %v", err)
+	}
+	for _, withCipher := range []bool{false, true} {
+		backend, err := NewFileBackend(t.TempDir())
+		if err != nil {
+			t.Fatalf("new backend: %v", err)
+		}
+		runs := ccrun.NewStore()
+		prompts := map[string]string{"run_1": "enc:hello", "run_2": "enc:v1:abc"}
+		for id, prompt := range prompts {
+			if _, err := runs.Create(ccrun.CreateInput{ID: id, Path: "/v1/messages", ProjectID: "acme"}); err != nil {
+				t.Fatalf("create run: %v", err)
+			}
+			if _, err := runs.Complete(id, ccrun.CompleteInput{StatusCode: 200, Prompt: prompt, Output: prompt}); err != nil {
+				t.Fatalf("complete run: %v", err)
+			}
+		}
+		manager := NewManager(backend, runs, nil, nil)
+		if withCipher {
+			manager.SetCipher(keys)
+		}
+		if err := manager.SaveAll(); err != nil {
+			t.Fatalf("save all (cipher=%v): %v", withCipher, err)
+		}
+		var saved ccrun.StoreState
+		if err := backend.Load("runs", &saved); err != nil {
+			t.Fatalf("load saved state: %v", err)
+		}
+		for _, run := range saved.Runs {
+			if run.TextSealed != withCipher || (withCipher && run.PromptText == prompts[run.ID]) {
+				t.Fatalf("cipher=%v: run %s persisted as %+v", withCipher, run.ID, run)
+			}
+		}
+
+		restored := ccrun.NewStore()
+		loader := NewManager(backend, restored, nil, nil)
+		if withCipher {
+			loader.SetCipher(keys)
+		}
+		if err := loader.LoadAll(); err != nil {
+			t.Fatalf("load all (cipher=%v): %v", withCipher, err)
+		}
+		for id, prompt := range prompts {
+			run, ok := restored.Get(id)
+			if !ok || run.PromptText != prompt || run.OutputText != prompt {
+				t.Fatalf("cipher=%v: run %s restored as %+v", withCipher, id, run)
+			}
+		}
+	}
+}
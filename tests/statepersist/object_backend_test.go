@@ -0,0 +1,89 @@
+package statepersist_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"ccgateway/internal/ccrun"
+	. "ccgateway/internal/statepersist"
+	"ccgateway/internal/storage"
+)
+
+type memObjects struct {
+	mu   sync.Mutex
+	data map[string][]byte
+	fail bool
+	puts int
+}
+
+func (m *memObjects) PutObject(_ context.Context, key string, data []byte, _ string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.fail {
+		return errors.New("bucket unavailable")
+	}
+	m.puts++
+	m.data[key] = append([]byte(nil), data...)
+	return nil
+}
+
+func (m *memObjects) GetObject(_ context.Context, key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.data[key]
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+	return data, nil
+}
+
+func TestObjectBackendUploadsSnapshotsOnFlush(t *testing.T) {
+	store := &memObjects{data: map[string][]byte{}}
+	backend := NewObjectBackend(store)
+	var missing map[string]any
+	if err := backend.Load("runs", &missing); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	_ = backend.Save("runs", map[string]int{"n": 1})
+	_ = backend.Save("runs", map[string]int{"n": 2})
+	if store.puts != 0 || backend.Pending() != 1 {
+		t.Fatalf("expected saves buffered until flush, puts=%d pending=%d", store.puts, backend.Pending())
+	}
+
+	store.fail = true
+	if err := backend.Flush(context.Background()); err == nil || backend.Pending() != 1 {
+		t.Fatalf("expected a failed flush to keep the key pending, err=%v pending=%d", err, backend.Pending())
+	}
+	store.fail = false
+	if err := backend.Flush(context.Background()); err != nil || backend.Pending() != 0 || store.puts != 1 {
+		t.Fatalf("expected one upload, err=%v pending=%d puts=%d", err, backend.Pending(), store.puts)
+	}
+	if string(store.data["runs.json"]) != `{"n":2}` {
+		t.Fatalf("expected the latest snapshot uploaded, got %s", store.data["runs.json"])
+	}
+}
+
+func TestObjectBackendRestoresAfterRestart(t *testing.T) {
+	store := &memObjects{data: map[string][]byte{}}
+	runs := ccrun.NewStore()
+	runs.Create(ccrun.CreateInput{ID: "run_1", Path: "/v1/messages"})
+	backend := NewObjectBackend(store)
+	first := NewManager(backend, runs, nil, nil)
+	if err := first.SaveAll(); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if err := backend.Flush(context.Background()); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+
+	restored := ccrun.NewStore()
+	if err := NewManager(NewObjectBackend(store), restored, nil, nil).LoadAll(); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if _, ok := restored.Get("run_1"); !ok {
+		t.Fatalf("expected run_1 restored from the bucket")
+	}
+}
@@ -0,0 +1,101 @@
+package statepersist_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"ccgateway/internal/ccrun"
+	"ccgateway/internal/plan"
+	. "ccgateway/internal/statepersist"
+)
+
+// keyFlakyBackend fails saves for the keys in fail and records the rest.
+type keyFlakyBackend struct {
+	mu    sync.Mutex
+	fail  map[string]bool
+	saved map[string]int
+}
+
+func (b *keyFlakyBackend) Load(string, any) error { return ErrNotFound }
+
+func (b *keyFlakyBackend) Save(key string, _ any) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.fail[key] {
+		return errors.New("write " + key + ": input/output error")
+	}
+	if b.saved == nil {
+		b.saved = map[string]int{}
+	}
+	b.saved[key]++
+	return nil
+}
+
+func (b *keyFlakyBackend) setFail(key string, fail bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.fail[key] = fail
+}
+
+func TestManagerQueuesFailedKeysForRepair(t *testing.T) {
+	backend := &keyFlakyBackend{fail: map[string]bool{"runs": true}}
+	runs := ccrun.NewStore()
+	plans := plan.NewStore()
+	manager := NewManager(backend, runs, plans, nil)
+	manager.BindAutoSave()
+
+	if _, err := runs.Create(ccrun.CreateInput{Path: "/v1/messages"}); err != nil {
+		t.Fatal(err)
+	}
+	if backend.saved["plans"] != 1 {
+		t.Fatalf("a failing key must not block the others: %+v", backend.saved)
+	}
+	status := manager.Repairs()
+	if status.Pending != 1 || status.Items[0].Key != "runs" || status.Items[0].Attempts != 1 || status.Items[0].NextAttemptAt.IsZero() {
+		t.Fatalf("expected runs to be queued: %+v", status)
+	}
+	if h := manager.Health(); h.PendingRepairs != 1 {
+		t.Fatalf("expected pending repairs in health: %+v", h)
+	}
+
+	// Still inside the backoff window: nothing is retried.
+	if got := manager.RetryRepairs(); got.Items[0].Attempts != 1 {
+		t.Fatalf("retry ignored backoff: %+v", got)
+	}
+	// A forced flush retries and backs off further on failure.
+	first := status.Items[0].NextAttemptAt
+	if got := manager.FlushRepairs(); got.Items[0].Attempts != 2 || !got.Items[0].NextAttemptAt.After(first) {
+		t.Fatalf("expected a second attempt with longer backoff: %+v", got)
+	}
+
+	backend.setFail("runs", false)
+	got := manager.FlushRepairs()
+	if got.Pending != 0 || got.Repaired != 1 || got.Queued != 1 || backend.saved["runs"] != 1 {
+		t.Fatalf("expected the queue to drain: %+v saved=%+v", got, backend.saved)
+	}
+	if h := manager.Health(); h.PendingRepairs != 0 || h.ConsecutiveFailures != 0 {
+		t.Fatalf("expected healthy state after repair: %+v", h)
+	}
+}
+
+func TestRepairQueueOrdersByPriority(t *testing.T) {
+	backend := &keyFlakyBackend{fail: map[string]bool{"runs": true, "plans": true}}
+	manager := NewManager(backend, ccrun.NewStore(), plan.NewStore(), nil)
+	if err := manager.SaveAll(); err == nil {
+		t.Fatal("expected save error")
+	}
+	status := manager.Repairs()
+	if status.Pending != 2 || status.Items[0].Key != "runs" || status.Items[1].Key != "plans" {
+		t.Fatalf("expected runs before plans: %+v", status)
+	}
+
+	backend.setFail("plans", false)
+	status = manager.FlushRepairs()
+	if status.Pending != 1 || status.Items[0].Key != "runs" {
+		t.Fatalf("expected only runs left: %+v", status)
+	}
+	if h := manager.Health(); h.LastSuccessAt != nil {
+		t.Fatalf("health must not recover while repairs are pending: %+v", h)
+	}
+}
@@ -0,0 +1,133 @@
+package storage_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	. "ccgateway/internal/storage"
+)
+
+// fakeS3 is a path-style bucket that checks request signing headers.
+type fakeS3 struct {
+	t       *testing.T
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	sum := sha256.Sum256(body)
+	if got := r.Header.Get("x-amz-content-sha256"); got != hex.EncodeToString(sum[:]) {
+		f.t.Errorf("payload hash %q does not match body", got)
+	}
+	auth := r.Header.Get("authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/") || !strings.Contains(auth, "/us-east-1/s3/aws4_request") ||
+		!strings.Contains(auth, "host;x-amz-content-sha256;x-amz-date, Signature=") || r.Header.Get("x-amz-date") == "" {
+		f.t.Errorf("unexpected authorization %q", auth)
+	}
+	key, ok := strings.CutPrefix(r.URL.Path, "/bucket/")
+	if !ok {
+		http.Error(w, "no bucket", http.StatusBadRequest)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case r.Method == http.MethodGet && key == "":
+		type content struct {
+			Key string `xml:"Key"`
+		}
+		var out struct {
+			XMLName  xml.Name  `xml:"ListBucketResult"`
+			Contents []content `xml:"Contents"`
+		}
+		prefix := r.URL.Query().Get("prefix")
+		for k := range f.objects {
+			if strings.HasPrefix(k, prefix) {
+				out.Contents = append(out.Contents, content{Key: k})
+			}
+		}
+		sort.Slice(out.Contents, func(i, j int) bool { return out.Contents[i].Key < out.Contents[j].Key })
+		_ = xml.NewEncoder(w).Encode(out)
+	case r.Method == http.MethodPut:
+		f.objects[key] = body
+	case r.Method == http.MethodGet:
+		data, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = io.WriteString(w, "<Error><Code>NoSuchKey</Code></Error>")
+			return
+		}
+		_, _ = w.Write(data)
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = io.WriteString(w, "<Error><Code>AccessDenied</Code><Message>nope</Message></Error>")
+	}
+}
+
+func newFakeS3(t *testing.T, prefix string) (*S3Backend, *fakeS3) {
+	t.Helper()
+	fake := &fakeS3{t: t, objects: map[string][]byte{}}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+	b, err := NewS3Backend(S3Config{
+		Endpoint:        srv.URL,
+		Bucket:          "bucket",
+		Prefix:          prefix,
+		AccessKeyID:     "AKID",
+		SecretAccessKey: "secret",
+		PathStyle:       true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b, fake
+}
+
+func TestS3Backend(t *testing.T) {
+	b, _ := newFakeS3(t, "")
+	testBackend(t, b)
+}
+
+func TestS3BackendPrefixAndObjects(t *testing.T) {
+	b, fake := newFakeS3(t, "gateway/state")
+	ctx := context.Background()
+	if err := b.PutObject(ctx, "runs/a b.json", []byte(`{"ok":true}`), "application/json"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fake.objects["gateway/state/runs/a b.json"]; !ok {
+		t.Fatalf("expected the key stored under the prefix, got %v", fake.objects)
+	}
+	data, err := b.GetObject(ctx, "runs/a b.json")
+	if err != nil || string(data) != `{"ok":true}` {
+		t.Fatalf("unexpected object %q err=%v", data, err)
+	}
+	keys, _ := b.List(ctx, "runs/")
+	if len(keys) != 1 || keys[0] != "runs/a b.json" {
+		t.Fatalf("expected keys without the prefix, got %v", keys)
+	}
+	if _, err := b.GetObject(ctx, "missing"); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestNewS3BackendRequiresBucketAndCredentials(t *testing.T) {
+	if _, err := NewS3Backend(S3Config{AccessKeyID: "a", SecretAccessKey: "b"}); err == nil {
+		t.Fatalf("expected missing bucket error")
+	}
+	if _, err := NewS3Backend(S3Config{Bucket: "b"}); err == nil {
+		t.Fatalf("expected missing credentials error")
+	}
+}
@@ -0,0 +1,61 @@
+package structured_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	. "ccgateway/internal/structured"
+)
+
+func TestRepairStrategies(t *testing.T) {
+	cases := []struct {
+		name       string
+		in         string
+		strategies []string
+	}{
+		{name: "valid", in: `{"a":1}`},
+		{name: "fence", in: "```json\n{\"a\":1}\n```", strategies: []string{StrategyCodeFence}},
+		{name: "prose", in: `Sure! Here it is: {"a":[1,2]} hope that helps`, strategies: []string{StrategyExtract}},
+		{name: "trailing comma", in: `{"a":[1,2,],}`, strategies: []string{StrategyTrailingCommas}},
+		{name: "truncated", in: `{"a":{"b":"hel`, strategies: []string{StrategyCloseBrackets}},
+	}
+	for _, tc := range cases {
+		out, strategies, err := Repair(tc.in)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.name, err)
+		}
+		if !json.Valid([]byte(out)) {
+			t.Fatalf("%s: output is not valid JSON: %q", tc.name, out)
+		}
+		if len(tc.strategies) == 0 && len(strategies) == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(strategies, tc.strategies) {
+			t.Fatalf("%s: expected strategies %v, got %v", tc.name, tc.strategies, strategies)
+		}
+	}
+	if _, _, err := Repair("no json here"); err == nil {
+		t.Fatalf("expected plain prose to be unrepairable")
+	}
+}
+
+func TestValidateSchemaSubset(t *testing.T) {
+	schema := map[string]any{
+		"type":                 "object",
+		"required":             []any{"name", "tags"},
+		"additionalProperties": false,
+		"properties": map[string]any{
+			"name": map[string]any{"type": "string"},
+			"age":  map[string]any{"type": "integer"},
+			"tags": map[string]any{"type": "array", "items": map[string]any{"enum": []any{"a", "b"}}},
+		},
+	}
+	if errs := ValidateText(`{"name":"x","tags":["a"],"age":3}`, schema); len(errs) != 0 {
+		t.Fatalf("expected valid document, got %v", errs)
+	}
+	errs := ValidateText(`{"age":1.5,"tags":["c"],"extra":true}`, schema)
+	if len(errs) != 4 {
+		t.Fatalf("expected 4 violations (missing name, age type, tag enum, extra), got %v", errs)
+	}
+}
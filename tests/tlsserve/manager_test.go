@@ -0,0 +1,283 @@
+package tlsserve_test
+
+import (
+	. "ccgateway/internal/tlsserve"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func selfSigned(t *testing.T, name string) ([]byte, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(90 * 24 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func servedName(t *testing.T, m *Manager) string {
+	t.Helper()
+	cert, err := m.GetCertificate(nil)
+	if err != nil {
+		t.Fatalf("get certificate: %v", err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf.Subject.CommonName
+}
+
+func TestManagerServesAndReloadsCertificateFiles(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	certPEM, keyPEM := selfSigned(t, "one.example.com")
+	_ = os.WriteFile(certFile, certPEM, 0o600)
+	_ = os.WriteFile(keyFile, keyPEM, 0o600)
+
+	m, err := New(Config{CertFile: certFile, KeyFile: keyFile})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", m.TLSConfig())
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("secure"))
+	})}
+	go func() { _ = srv.Serve(ln) }()
+	defer srv.Close()
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	resp, err := client.Get("https://" + ln.Addr().String())
+	if err != nil {
+		t.Fatalf("https get: %v", err)
+	}
+	resp.Body.Close()
+	if got := resp.TLS.PeerCertificates[0].Subject.CommonName; got != "one.example.com" {
+		t.Fatalf("expected the configured certificate, got %q", got)
+	}
+
+	certPEM, keyPEM = selfSigned(t, "two.example.com")
+	_ = os.WriteFile(certFile, certPEM, 0o600)
+	_ = os.WriteFile(keyFile, keyPEM, 0o600)
+	later := time.Now().Add(time.Minute)
+	_ = os.Chtimes(certFile, later, later)
+	_ = os.Chtimes(keyFile, later, later)
+	if err := m.Refresh(context.Background()); err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if got := servedName(t, m); got != "two.example.com" {
+		t.Fatalf("expected the rewritten certificate, got %q", got)
+	}
+
+	if _, err := New(Config{CertFile: certFile}); err == nil {
+		t.Fatalf("expected a cert without a key to be rejected")
+	}
+}
+
+func TestManagerHTTPHandlerRedirectsToHTTPS(t *testing.T) {
+	certPEM, keyPEM := selfSigned(t, "gw.example.com")
+	dir := t.TempDir()
+	_ = os.WriteFile(filepath.Join(dir, "c"), certPEM, 0o600)
+	_ = os.WriteFile(filepath.Join(dir, "k"), keyPEM, 0o600)
+	m, err := New(Config{CertFile: filepath.Join(dir, "c"), KeyFile: filepath.Join(dir, "k"), HTTPSPort: "8443"})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "http://gw.example.com/v1/models?x=1", nil)
+	rr := httptest.NewRecorder()
+	m.HTTPHandler().ServeHTTP(rr, req)
+	if rr.Code != http.StatusMovedPermanently || rr.Header().Get("location") != "https://gw.example.com:8443/v1/models?x=1" {
+		t.Fatalf("unexpected redirect %d %q", rr.Code, rr.Header().Get("location"))
+	}
+}
+
+// fakeACME is just enough of an ACME server to walk one order through
+// http-01 validation, which it checks against the manager's HTTP handler.
+type fakeACME struct {
+	t       *testing.T
+	srv     *httptest.Server
+	caKey   *ecdsa.PrivateKey
+	caCert  *x509.Certificate
+	solver  http.Handler
+	mu      sync.Mutex
+	nonces  int
+	orders  int
+	valid   map[string]bool
+	certPEM []byte
+	badOnce bool
+}
+
+func newFakeACME(t *testing.T) *fakeACME {
+	f := &fakeACME{t: t, valid: map[string]bool{}, badOnce: true}
+	f.caKey, _ = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "fake ca"}, NotBefore: time.Now().Add(-time.Hour), NotAfter: time.Now().Add(time.Hour * 24 * 365), IsCA: true, BasicConstraintsValid: true, KeyUsage: x509.KeyUsageCertSign}
+	der, _ := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &f.caKey.PublicKey, f.caKey)
+	f.caCert, _ = x509.ParseCertificate(der)
+	f.srv = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.srv.Close)
+	return f
+}
+
+func (f *fakeACME) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.nonces++
+	w.Header().Set("replay-nonce", fmt.Sprintf("nonce-%d", f.nonces))
+	base := f.srv.URL
+	if r.URL.Path == "/dir" {
+		_ = json.NewEncoder(w).Encode(map[string]string{"newNonce": base + "/nonce", "newAccount": base + "/acct", "newOrder": base + "/order"})
+		return
+	}
+	if r.URL.Path == "/nonce" {
+		return
+	}
+	var jws struct{ Protected, Payload, Signature string }
+	if err := json.NewDecoder(r.Body).Decode(&jws); err != nil || r.Header.Get("content-type") != "application/jose+json" {
+		http.Error(w, "bad jws", http.StatusBadRequest)
+		return
+	}
+	protected, _ := base64.RawURLEncoding.DecodeString(jws.Protected)
+	payload, _ := base64.RawURLEncoding.DecodeString(jws.Payload)
+	var hdr map[string]any
+	_ = json.Unmarshal(protected, &hdr)
+	if hdr["url"] != base+r.URL.Path || hdr["alg"] != "ES256" {
+		http.Error(w, "bad protected header", http.StatusBadRequest)
+		return
+	}
+	switch {
+	case r.URL.Path == "/acct":
+		if f.badOnce {
+			f.badOnce = false
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"type":"urn:ietf:params:acme:error:badNonce","detail":"stale"}`))
+			return
+		}
+		if hdr["jwk"] == nil {
+			http.Error(w, "want jwk", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("location", base+"/acct/1")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"status":"valid"}`))
+	case r.URL.Path == "/order":
+		f.orders++
+		var req struct {
+			Identifiers []struct{ Value string } `json:"identifiers"`
+		}
+		_ = json.Unmarshal(payload, &req)
+		authz := []string{}
+		for _, id := range req.Identifiers {
+			authz = append(authz, base+"/authz/"+id.Value)
+		}
+		w.Header().Set("location", base+"/order/1")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(map[string]any{"status": "pending", "authorizations": authz, "finalize": base + "/finalize"})
+	case strings.HasPrefix(r.URL.Path, "/authz/"):
+		domain := strings.TrimPrefix(r.URL.Path, "/authz/")
+		status := "pending"
+		if f.valid[domain] {
+			status = "valid"
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"status": status, "identifier": map[string]string{"value": domain},
+			"challenges": []map[string]string{{"type": "dns-01", "url": base + "/nope", "token": "x"}, {"type": "http-01", "url": base + "/chal/" + domain, "token": "tok-" + domain}}})
+	case strings.HasPrefix(r.URL.Path, "/chal/"):
+		domain := strings.TrimPrefix(r.URL.Path, "/chal/")
+		rr := httptest.NewRecorder()
+		f.solver.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "http://"+domain+"/.well-known/acme-challenge/tok-"+domain, nil))
+		f.valid[domain] = rr.Code == http.StatusOK && strings.HasPrefix(rr.Body.String(), "tok-"+domain+".")
+		_, _ = w.Write([]byte(`{}`))
+	case r.URL.Path == "/finalize":
+		var req struct{ CSR string }
+		_ = json.Unmarshal(payload, &req)
+		der, _ := base64.RawURLEncoding.DecodeString(req.CSR)
+		csr, err := x509.ParseCertificateRequest(der)
+		if err != nil {
+			http.Error(w, "bad csr", http.StatusBadRequest)
+			return
+		}
+		tmpl := &x509.Certificate{SerialNumber: big.NewInt(2), Subject: csr.Subject, DNSNames: csr.DNSNames, NotBefore: time.Now().Add(-time.Hour), NotAfter: time.Now().Add(90 * 24 * time.Hour)}
+		cert, _ := x509.CreateCertificate(rand.Reader, tmpl, f.caCert, csr.PublicKey, f.caKey)
+		f.certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert})
+		_ = json.NewEncoder(w).Encode(map[string]any{"status": "processing"})
+	case r.URL.Path == "/order/1":
+		_ = json.NewEncoder(w).Encode(map[string]any{"status": "valid", "certificate": base + "/cert"})
+	case r.URL.Path == "/cert":
+		w.Header().Set("content-type", "application/pem-certificate-chain")
+		_, _ = w.Write(f.certPEM)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestManagerProvisionsCertificateOverACME(t *testing.T) {
+	fake := newFakeACME(t)
+	cache := t.TempDir()
+	cfg := Config{ACMEDomains: []string{"gw.example.com", "api.example.com"}, ACMEEmail: "ops@example.com", ACMEDirectory: fake.srv.URL + "/dir", ACMECacheDir: cache}
+	m, err := New(cfg)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	fake.solver = m.HTTPHandler()
+	if _, err := m.GetCertificate(nil); err == nil {
+		t.Fatalf("expected no certificate before provisioning")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := m.Refresh(ctx); err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if got := servedName(t, m); got != "gw.example.com" {
+		t.Fatalf("expected the issued certificate, got %q", got)
+	}
+	if st := m.Status(); st.Mode != "acme" || st.NotAfter.IsZero() || st.LastError != "" {
+		t.Fatalf("unexpected status %+v", st)
+	}
+	if _, err := os.Stat(filepath.Join(cache, "gw.example.com.crt")); err != nil {
+		t.Fatalf("expected the certificate cached: %v", err)
+	}
+
+	// A restart serves the cached certificate without a new order.
+	again, err := New(cfg)
+	if err != nil {
+		t.Fatalf("new from cache: %v", err)
+	}
+	if err := again.Refresh(ctx); err != nil {
+		t.Fatalf("refresh from cache: %v", err)
+	}
+	if fake.orders != 1 || servedName(t, again) != "gw.example.com" {
+		t.Fatalf("expected the cached certificate to be reused, orders=%d", fake.orders)
+	}
+}
@@ -0,0 +1,157 @@
+package token_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ccgateway/internal/token"
+)
+
+func TestHashedFileServiceNeverPersistsTokenValue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	svc, err := token.NewHashedFileService(path)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	tk, err := svc.Generate("u1", 100)
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	if !strings.HasPrefix(tk.Value, "sk-cc-") || !strings.HasPrefix(tk.Value, tk.Prefix) {
+		t.Fatalf("unexpected value/prefix: %q / %q", tk.Value, tk.Prefix)
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read store: %v", err)
+	}
+	if strings.Contains(string(raw), tk.Value) {
+		t.Fatalf("store file must not contain the raw token value")
+	}
+	if !strings.Contains(string(raw), tk.Prefix) {
+		t.Fatalf("store file should keep the display prefix")
+	}
+
+	listed := svc.List("u1")
+	if len(listed) != 1 || listed[0].Value != tk.Prefix+"..." {
+		t.Fatalf("expected masked value in list, got %+v", listed)
+	}
+
+	reopened, err := token.NewHashedFileService(path)
+	if err != nil {
+		t.Fatalf("reopen store: %v", err)
+	}
+	got, err := reopened.Validate(tk.Value)
+	if err != nil {
+		t.Fatalf("validate after reopen: %v", err)
+	}
+	if got.ID != tk.ID || got.LastUsedAt == nil {
+		t.Fatalf("unexpected validated token: %+v", got)
+	}
+	if _, err := reopened.Validate(tk.Prefix + strings.Repeat("0", len(tk.Value)-len(tk.Prefix))); err != token.ErrInvalidToken {
+		t.Fatalf("expected invalid token for wrong value with same prefix, got %v", err)
+	}
+	if err := reopened.DeductQuota(tk.Value, 40); err != nil {
+		t.Fatalf("deduct: %v", err)
+	}
+	if err := reopened.Flush(); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+
+	again, err := token.NewHashedFileService(path)
+	if err != nil {
+		t.Fatalf("reopen store: %v", err)
+	}
+	if list := again.List("u1"); len(list) != 1 || list[0].Quota != 60 || list[0].Used != 40 || list[0].LastUsedAt == nil {
+		t.Fatalf("usage not persisted: %+v", list)
+	}
+	next, err := again.Generate("u1", 0)
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	if next.ID <= tk.ID {
+		t.Fatalf("ids must keep increasing across reloads: %d after %d", next.ID, tk.ID)
+	}
+}
+
+func TestHashedFileServiceRotateAndExpiry(t *testing.T) {
+	svc, err := token.NewHashedFileService("")
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	tk, err := svc.Generate("u1", 0)
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+
+	rotated, err := svc.Rotate(tk.ID)
+	if err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+	if rotated.ID != tk.ID || rotated.Value == tk.Value || rotated.RotatedAt == nil {
+		t.Fatalf("unexpected rotated token: %+v", rotated)
+	}
+	if _, err := svc.Validate(tk.Value); err != token.ErrInvalidToken {
+		t.Fatalf("old value must stop validating, got %v", err)
+	}
+	if _, err := svc.Validate(rotated.Value); err != nil {
+		t.Fatalf("new value must validate: %v", err)
+	}
+
+	listed := svc.List("u1")[0]
+	listed.ExpiredAt = 1
+	if err := svc.Update(listed); err != nil {
+		t.Fatalf("update by id: %v", err)
+	}
+	if _, err := svc.Validate(rotated.Value); err != token.ErrTokenExpired {
+		t.Fatalf("expected expired token, got %v", err)
+	}
+
+	if err := svc.DeleteByID(tk.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if len(svc.List("u1")) != 0 {
+		t.Fatalf("expected token to be deleted")
+	}
+}
+
+func TestHashedFileServiceSoftDeleteSurvivesReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	svc, err := token.NewHashedFileService(path)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	tk, err := svc.Generate("u1", 100)
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	if err := svc.SoftDeleteByID(tk.ID); err != nil {
+		t.Fatalf("soft delete: %v", err)
+	}
+	if _, err := svc.Validate(tk.Value); err == nil {
+		t.Fatalf("soft-deleted token must not validate")
+	}
+
+	reopened, err := token.NewHashedFileService(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	deleted := reopened.ListDeleted()
+	if len(deleted) != 1 || deleted[0].ID != tk.ID || deleted[0].DeletedAt == nil {
+		t.Fatalf("expected token in trash after reload, got %+v", deleted)
+	}
+	if strings.Contains(deleted[0].Value, tk.Value) {
+		t.Fatalf("trash listing must not reveal the token value")
+	}
+	if _, err := reopened.RestoreByID(tk.ID); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if _, err := reopened.Validate(tk.Value); err != nil {
+		t.Fatalf("restored token should validate: %v", err)
+	}
+	if next, _ := reopened.Generate("u1", 1); next.ID == tk.ID {
+		t.Fatalf("new token reused a trashed id")
+	}
+}
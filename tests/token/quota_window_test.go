@@ -0,0 +1,129 @@
+package token_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"ccgateway/internal/token"
+)
+
+func TestInMemoryQuotaWindowsEnforceWarnAndReset(t *testing.T) {
+	svc := token.NewInMemoryService()
+	var events []token.ThresholdEvent
+	svc.OnQuotaThreshold(func(ev token.ThresholdEvent) { events = append(events, ev) })
+
+	tk, err := svc.Generate("team-a", 0)
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	tk.QuotaWindows = []token.QuotaWindow{{Period: "Daily", Limit: 100, WarnPercent: 50}}
+	if err := svc.Update(tk); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	w := tk.QuotaWindows[0]
+	if w.Period != token.PeriodDaily || !w.ResetAt.After(time.Now()) || w.ResetAt.Hour() != 0 {
+		t.Fatalf("unexpected normalized window: %+v", w)
+	}
+
+	if err := svc.DeductQuota(tk.Value, 40); err != nil {
+		t.Fatalf("deduct 40: %v", err)
+	}
+	if len(events) != 0 {
+		t.Fatalf("expected no threshold event below 50%%, got %+v", events)
+	}
+	if err := svc.DeductQuota(tk.Value, 20); err != nil {
+		t.Fatalf("deduct 20: %v", err)
+	}
+	if err := svc.DeductQuota(tk.Value, 10); err != nil {
+		t.Fatalf("deduct 10: %v", err)
+	}
+	if len(events) != 1 || events[0].Period != token.PeriodDaily || events[0].Used != 60 {
+		t.Fatalf("expected one threshold event at 60, got %+v", events)
+	}
+
+	err = svc.DeductQuota(tk.Value, 50)
+	var windowErr *token.QuotaWindowError
+	if !errors.As(err, &windowErr) || !errors.Is(err, token.ErrQuotaExceeded) {
+		t.Fatalf("expected window quota error, got %v", err)
+	}
+	if tk.Status != token.StatusEnabled {
+		t.Fatalf("a spent window must not mark the token exhausted, status=%d", tk.Status)
+	}
+	if err := svc.DeductQuota(tk.Value, 30); err != nil {
+		t.Fatalf("deduct to the limit: %v", err)
+	}
+	if _, err := svc.Validate(tk.Value); !errors.As(err, &windowErr) {
+		t.Fatalf("expected validate to reject a spent window, got %v", err)
+	}
+
+	// Raising the limit mid-window keeps what was spent.
+	raised := *tk
+	raised.QuotaWindows = []token.QuotaWindow{{Period: token.PeriodDaily, Limit: 200, Used: 0}}
+	if err := svc.Update(&raised); err != nil {
+		t.Fatalf("raise limit: %v", err)
+	}
+	if tk.QuotaWindows[0].Used != 100 {
+		t.Fatalf("expected usage to carry over, got %+v", tk.QuotaWindows[0])
+	}
+
+	// The schedule passing starts a fresh window.
+	tk.QuotaWindows[0].ResetAt = time.Now().Add(-time.Second)
+	if _, err := svc.Validate(tk.Value); err != nil {
+		t.Fatalf("validate after reset: %v", err)
+	}
+	if tk.QuotaWindows[0].Used != 0 {
+		t.Fatalf("expected window usage reset, got %+v", tk.QuotaWindows[0])
+	}
+
+	if err := svc.DeductQuota(tk.Value, 200); err != nil {
+		t.Fatalf("deduct full window: %v", err)
+	}
+	if _, err := svc.ResetQuotaWindows(tk.ID, "monthly"); err == nil {
+		t.Fatalf("expected error resetting a period the token does not have")
+	}
+	if _, err := svc.ResetQuotaWindows(tk.ID, ""); err != nil {
+		t.Fatalf("admin reset: %v", err)
+	}
+	if _, err := svc.Validate(tk.Value); err != nil {
+		t.Fatalf("validate after admin reset: %v", err)
+	}
+}
+
+func TestHashedStoreQuotaWindowsPersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	svc, err := token.NewHashedFileService(path)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	tk, err := svc.Generate("team-b", 0)
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	tk.QuotaWindows = []token.QuotaWindow{{Period: token.PeriodMonthly, Limit: 1000}}
+	if err := svc.Update(tk); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if err := svc.DeductQuota(tk.Value, 300); err != nil {
+		t.Fatalf("deduct: %v", err)
+	}
+	if err := svc.Flush(); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	if err := svc.Update(&token.Token{ID: tk.ID, QuotaWindows: []token.QuotaWindow{{Period: "weekly", Limit: 1}}}); !errors.Is(err, token.ErrInvalidQuotaWindow) {
+		t.Fatalf("expected invalid window error, got %v", err)
+	}
+
+	reopened, err := token.NewHashedFileService(path)
+	if err != nil {
+		t.Fatalf("reopen store: %v", err)
+	}
+	got, err := reopened.Get(tk.Value)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if len(got.QuotaWindows) != 1 || got.QuotaWindows[0].Used != 300 || got.QuotaWindows[0].WarnPercent != 80 || got.QuotaWindows[0].ResetAt.Day() != 1 {
+		t.Fatalf("unexpected persisted window: %+v", got.QuotaWindows)
+	}
+}
@@ -0,0 +1,113 @@
+package tokenizer_test
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "ccgateway/internal/tokenizer"
+)
+
+// tiktokenFile renders ranks in the tiktoken format: every single byte of
+// the alphabet first, then the listed merges.
+func tiktokenFile(merges ...string) string {
+	var sb strings.Builder
+	rank := 0
+	for _, c := range "abcdefghijklmnopqrstuvwxyz !'" {
+		fmt.Fprintf(&sb, "%s %d\n", base64.StdEncoding.EncodeToString([]byte(string(c))), rank)
+		rank++
+	}
+	for _, m := range merges {
+		fmt.Fprintf(&sb, "%s %d\n", base64.StdEncoding.EncodeToString([]byte(m)), rank)
+		rank++
+	}
+	return sb.String()
+}
+
+func TestBPEMergesByRankWithinPieces(t *testing.T) {
+	bpe, err := LoadTiktoken(strings.NewReader(tiktokenFile("he", "ll", "hell", " w", "o ")), "test")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	// "hello" merges to hell+o; " world" to " w"+o+r+l+d. "o " never
+	// merges because the pre-tokenizer keeps the space with the next word.
+	if got := bpe.Count("hello world"); got != 7 {
+		t.Fatalf("expected 7 tokens, got %d", got)
+	}
+	if got := bpe.Count("it's"); got != 4 {
+		t.Fatalf("expected the contraction split off, got %d", got)
+	}
+	if _, err := LoadTiktoken(strings.NewReader("not-base64!! 1\n"), "bad"); err == nil {
+		t.Fatalf("expected an error for a malformed rank file")
+	}
+}
+
+func TestSentencePieceSegmentsWithByteFallback(t *testing.T) {
+	vocab := "<unk>\t0\n<s>\t0\n<0x41>\t0\n▁hello\t-1\n▁\t-2\n▁wor\t-3\nld\t-3\nh\t-6\ne\t-6\nl\t-6\no\t-6\nw\t-6\nr\t-6\nd\t-6\n"
+	sp, err := LoadSentencePiece(strings.NewReader(vocab), "sp")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if got := sp.Count("hello world"); got != 3 {
+		t.Fatalf("expected ▁hello ▁wor ld, got %d pieces", got)
+	}
+	// é is outside the vocabulary: two byte pieces.
+	if got := sp.Count("hello é"); got != 4 {
+		t.Fatalf("expected byte fallback for é, got %d pieces", got)
+	}
+}
+
+func TestRegistrySelectsVocabularyByModel(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "cl100k_base.tiktoken"), []byte(tiktokenFile("he")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "gemini.vocab"), []byte("▁hi\t-1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "README"), []byte("ignored"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r := NewRegistry()
+	if err := r.LoadDir(dir); err != nil {
+		t.Fatalf("load dir: %v", err)
+	}
+	cases := map[string]string{
+		"gpt-4":                "cl100k_base",
+		"openai/gpt-3.5-turbo": "cl100k_base",
+		"gpt-4o-mini":          "approx:openai",
+		"gemini-1.5-pro":       "gemini",
+		"claude-sonnet-4":      "approx:claude",
+		"llama-3-70b":          "approx:other",
+	}
+	for model, want := range cases {
+		if got := r.ForModel(model).Name(); got != want {
+			t.Errorf("%s: expected %s, got %s", model, want, got)
+		}
+	}
+	if got := r.Loaded(); len(got) != 2 {
+		t.Fatalf("expected two vocabularies, got %v", got)
+	}
+}
+
+func TestApproximationCountsByShape(t *testing.T) {
+	tok := Approximate(FamilyOpenAI)
+	cases := map[string]int{
+		"hello world":                2,
+		"internationalization":       5,
+		"1234567":                    3,
+		"你好":                         2,
+		"x := f(y)\n\tif err != nil": 13,
+	}
+	for text, want := range cases {
+		if got := tok.Count(text); got != want {
+			t.Errorf("%q: expected %d, got %d", text, want, got)
+		}
+	}
+	if Approximate(FamilyClaude).Count("internationalization") <= tok.Count("internationalization") {
+		t.Fatalf("claude packs fewer characters per token than openai")
+	}
+}
@@ -31,3 +31,27 @@ func TestCatalogCheckAllowed(t *testing.T) {
 		t.Fatalf("unknown tool should pass when unknown enabled: %v", err)
 	}
 }
+
+func TestCatalogExecutionPolicy(t *testing.T) {
+	c := NewCatalog([]ToolSpec{
+		{Name: "Fetch", TimeoutMS: 2000, Retries: 2, RetryOn: []string{" Timeout ", "bogus", "is_error"}, Idempotent: true},
+		{Name: "deploy", Retries: 3, TimeoutMS: -5},
+		{Name: "search", Retries: 1, Idempotent: true},
+	})
+
+	fetch, _ := c.Get("fetch")
+	if fetch.TimeoutMS != 2000 || len(fetch.RetryOn) != 2 {
+		t.Fatalf("unexpected fetch policy: %+v", fetch)
+	}
+	if !fetch.ShouldRetry(RetryOnTimeout) || !fetch.ShouldRetry(RetryOnIsError) || fetch.ShouldRetry(RetryOnError) {
+		t.Fatalf("expected retries limited to retry_on, got %+v", fetch)
+	}
+	deploy, _ := c.Get("deploy")
+	if deploy.TimeoutMS != 0 || deploy.ShouldRetry(RetryOnError) {
+		t.Fatalf("expected non-idempotent tool never retried, got %+v", deploy)
+	}
+	search, _ := c.Get("search")
+	if !search.ShouldRetry(RetryOnError) || !search.ShouldRetry(RetryOnTimeout) || search.ShouldRetry(RetryOnIsError) {
+		t.Fatalf("expected default retry_on of error and timeout, got %+v", search)
+	}
+}
@@ -0,0 +1,104 @@
+package toolruntime_test
+
+import (
+	. "ccgateway/internal/toolruntime"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type closeRecorder struct {
+	closed int
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed++
+	return nil
+}
+
+func TestStateStoreSetReplaceRelease(t *testing.T) {
+	store := NewStateStore(time.Minute)
+	released := []string{}
+	store.OnRelease(func(sessionID string) { released = append(released, sessionID) })
+
+	if store.Session("") != nil {
+		t.Fatalf("expected no state handle without a session id")
+	}
+	st := store.Session("sess_1")
+	first := &closeRecorder{}
+	st.Set("browser", first)
+	st.Set("cwd", "/tmp")
+	if v, ok := store.Session("sess_1").Get("browser"); !ok || v != first {
+		t.Fatalf("expected state shared across handles of one session")
+	}
+	if _, ok := store.Session("sess_2").Get("browser"); ok {
+		t.Fatalf("expected state isolated per session")
+	}
+
+	second := &closeRecorder{}
+	st.Set("browser", second)
+	if first.closed != 1 {
+		t.Fatalf("expected replaced handle to be closed, got %d", first.closed)
+	}
+	info, ok := store.Info("sess_1")
+	if !ok || len(info.Keys) != 2 || info.Keys[0] != "browser" || info.Keys[1] != "cwd" {
+		t.Fatalf("unexpected info: %+v", info)
+	}
+
+	if !store.Release("sess_1") {
+		t.Fatalf("expected release to report existing state")
+	}
+	if second.closed != 1 {
+		t.Fatalf("expected handle closed on release")
+	}
+	if len(released) != 1 || released[0] != "sess_1" {
+		t.Fatalf("expected release hook for sess_1, got %v", released)
+	}
+	if _, ok := store.Info("sess_1"); ok {
+		t.Fatalf("expected state gone after release")
+	}
+}
+
+func TestStateStoreExpiresIdleSessions(t *testing.T) {
+	store := NewStateStore(40 * time.Millisecond)
+	handle := &closeRecorder{}
+	store.Session("idle").Set("conn", handle)
+	time.Sleep(80 * time.Millisecond)
+
+	expired := store.Sweep()
+	if len(expired) != 1 || expired[0] != "idle" {
+		t.Fatalf("expected idle session expired, got %v", expired)
+	}
+	if handle.closed != 1 {
+		t.Fatalf("expected expired handle to be closed")
+	}
+}
+
+func TestSetWorkingDirectoryScopesFileTools(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("hello"), 0o644); err != nil {
+		t.Fatalf("write fixture: %v", err)
+	}
+	ex := NewDefaultExecutor()
+	store := NewStateStore(time.Minute)
+	state := store.Session("sess_cwd")
+
+	out, err := ex.Execute(context.Background(), Call{Name: "set_working_directory", Input: map[string]any{"path": dir}, SessionID: "sess_cwd", State: state})
+	if err != nil || out.IsError {
+		t.Fatalf("set working directory: %v %+v", err, out)
+	}
+	out, err = ex.Execute(context.Background(), Call{Name: "file_read", Input: map[string]any{"path": "notes.txt"}, SessionID: "sess_cwd", State: state})
+	if err != nil || out.IsError {
+		t.Fatalf("relative file_read: %v %+v", err, out)
+	}
+	if content := out.Content.(map[string]any); content["content"] != "hello" {
+		t.Fatalf("unexpected file content: %#v", content)
+	}
+
+	out, _ = ex.Execute(context.Background(), Call{Name: "set_working_directory", Input: map[string]any{"path": dir}})
+	if !out.IsError {
+		t.Fatalf("expected set_working_directory without session to fail")
+	}
+}
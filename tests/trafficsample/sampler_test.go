@@ -0,0 +1,66 @@
+package trafficsample_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	. "ccgateway/internal/trafficsample"
+)
+
+func TestRedactTextMasksPII(t *testing.T) {
+	in := "mail bob@example.com key sk-abcdefghijklmnop1234 ip 10.1.2.3 card 4111 1111 1111 1111"
+	out := RedactText(in)
+	for _, leaked := range []string{"bob@example.com", "sk-abcdefghijklmnop1234", "10.1.2.3", "4111 1111"} {
+		if strings.Contains(out, leaked) {
+			t.Fatalf("expected %q to be redacted, got %q", leaked, out)
+		}
+	}
+	if !strings.Contains(out, "[EMAIL]") || !strings.Contains(out, "[API_KEY]") {
+		t.Fatalf("expected mask markers, got %q", out)
+	}
+}
+
+func TestSamplerOffersOnlyToSubscribersWithRate(t *testing.T) {
+	s := NewSampler(Config{Enabled: true, SampleRate: 0.5, RedactFields: []string{"user_id"}})
+	if s.Offer(Sample{RunID: "run_1"}) {
+		t.Fatalf("expected no sampling without subscribers")
+	}
+
+	ch, cancel := s.Subscribe()
+	defer cancel()
+
+	s.SetRandSource(func() float64 { return 0.9 })
+	if s.Offer(Sample{RunID: "run_2"}) {
+		t.Fatalf("expected draw above rate to be skipped")
+	}
+	s.SetRandSource(func() float64 { return 0.1 })
+	if !s.Offer(Sample{
+		RunID:    "run_3",
+		Prompt:   "contact me at alice@example.com",
+		Metadata: map[string]any{"user_id": "u-1", "note": "call +1 415 555 0100"},
+	}) {
+		t.Fatalf("expected draw below rate to be sampled")
+	}
+
+	select {
+	case got := <-ch:
+		if got.RunID != "run_3" || strings.Contains(got.Prompt, "alice@example.com") {
+			t.Fatalf("unexpected sample: %+v", got)
+		}
+		if got.Metadata["user_id"] != "[REDACTED]" {
+			t.Fatalf("expected configured field to be masked, got %+v", got.Metadata)
+		}
+		if strings.Contains(got.Metadata["note"].(string), "555") {
+			t.Fatalf("expected phone number redacted in metadata, got %+v", got.Metadata)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for sample")
+	}
+
+	if _, err := s.UpdateConfigPatch(ConfigPatch{SampleRate: ptrFloat(2)}); err == nil {
+		t.Fatalf("expected out of range sample rate to fail")
+	}
+}
+
+func ptrFloat(v float64) *float64 { return &v }
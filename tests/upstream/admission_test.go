@@ -0,0 +1,194 @@
+package upstream_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"ccgateway/internal/orchestrator"
+	. "ccgateway/internal/upstream"
+)
+
+// gatedAdapter holds every call until release is closed or sent to, and
+// records the order calls reached it.
+type gatedAdapter struct {
+	name    string
+	limit   int
+	release chan struct{}
+
+	mu   sync.Mutex
+	seen []string
+}
+
+func (a *gatedAdapter) Name() string { return a.name }
+
+func (a *gatedAdapter) AdminSpec() AdapterSpec {
+	return AdapterSpec{Name: a.name, Kind: AdapterKindCanonical, MaxConcurrency: a.limit}
+}
+
+func (a *gatedAdapter) Complete(ctx context.Context, req orchestrator.Request) (orchestrator.Response, error) {
+	text, _ := req.Messages[0].Content.(string)
+	a.mu.Lock()
+	a.seen = append(a.seen, text)
+	a.mu.Unlock()
+	select {
+	case <-a.release:
+	case <-ctx.Done():
+		return orchestrator.Response{}, ctx.Err()
+	}
+	return orchestrator.Response{
+		Model:      req.Model,
+		Blocks:     []orchestrator.AssistantBlock{{Type: "text", Text: a.name}},
+		StopReason: "end_turn",
+	}, nil
+}
+
+func (a *gatedAdapter) order() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]string(nil), a.seen...)
+}
+
+func admissionRequest(text string, priority int) orchestrator.Request {
+	return orchestrator.Request{
+		Model:     "m",
+		MaxTokens: 16,
+		Messages:  []orchestrator.Message{{Role: "user", Content: text}},
+		Metadata:  map[string]any{"admission_priority": priority},
+	}
+}
+
+func waitQueued(t *testing.T, svc *RouterService, adapter string, queued int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, st := range svc.AdmissionStatus() {
+			if st.Adapter == adapter && st.Queued == queued {
+				return
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("expected %d calls queued on %s, got %+v", queued, adapter, svc.AdmissionStatus())
+}
+
+func TestAdmissionQueueAdmitsByPriority(t *testing.T) {
+	slow := &gatedAdapter{name: "slow", limit: 1, release: make(chan struct{})}
+	svc := NewRouterService(RouterConfig{DefaultRoute: []string{"slow"}, Timeout: 5 * time.Second}, []Adapter{slow})
+
+	var wg sync.WaitGroup
+	call := func(text string, priority int) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := svc.Complete(context.Background(), admissionRequest(text, priority)); err != nil {
+				t.Errorf("%s: %v", text, err)
+			}
+		}()
+	}
+	call("first", 0)
+	for len(slow.order()) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	call("low", 0)
+	waitQueued(t, svc, "slow", 1)
+	call("high", 20)
+	waitQueued(t, svc, "slow", 2)
+
+	for i := 0; i < 3; i++ {
+		slow.release <- struct{}{}
+	}
+	wg.Wait()
+
+	if got := slow.order(); len(got) != 3 || got[1] != "high" || got[2] != "low" {
+		t.Fatalf("expected the high priority call admitted first, got %v", got)
+	}
+	st := svc.AdmissionStatus()
+	if len(st) != 1 || st[0].MaxConcurrency != 1 || st[0].Admitted != 3 || st[0].Waited != 2 || st[0].Active != 0 {
+		t.Fatalf("unexpected admission status: %+v", st)
+	}
+}
+
+func TestAdmissionTimeoutFallsThroughToNextCandidate(t *testing.T) {
+	slow := &gatedAdapter{name: "slow", limit: 1, release: make(chan struct{})}
+	defer close(slow.release)
+	svc := NewRouterService(RouterConfig{
+		DefaultRoute: []string{"slow", "fast"},
+		Timeout:      5 * time.Second,
+		QueueTimeout: 30 * time.Millisecond,
+	}, []Adapter{slow, NewMockAdapter("fast", false)})
+
+	go func() { _, _ = svc.Complete(context.Background(), admissionRequest("busy", 0)) }()
+	for len(slow.order()) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	resp, err := svc.Complete(context.Background(), admissionRequest("next", 0))
+	if err != nil {
+		t.Fatalf("expected fallback to fast, got %v", err)
+	}
+	if resp.Trace.Provider != "fast" {
+		t.Fatalf("expected provider fast, got %q", resp.Trace.Provider)
+	}
+	if st := svc.AdmissionStatus(); len(st) != 1 || st[0].TimedOut != 1 {
+		t.Fatalf("expected one queue timeout, got %+v", st)
+	}
+
+	only := admissionRequest("only", 0)
+	only.Metadata["routing_adapter_route"] = []string{"slow"}
+	if _, err := svc.Complete(context.Background(), only); !errors.Is(err, ErrAdmissionTimeout) {
+		t.Fatalf("expected ErrAdmissionTimeout, got %v", err)
+	}
+}
+
+func TestQueuedStreamSendsPingKeepAlives(t *testing.T) {
+	slow := &streamingGatedAdapter{&gatedAdapter{name: "slow", limit: 1, release: make(chan struct{})}}
+	svc := NewRouterService(RouterConfig{
+		DefaultRoute:      []string{"slow"},
+		Timeout:           5 * time.Second,
+		QueuePingInterval: 10 * time.Millisecond,
+	}, []Adapter{slow})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = svc.Complete(context.Background(), admissionRequest("busy", 0))
+	}()
+	for len(slow.order()) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	events, errs := svc.Stream(context.Background(), admissionRequest("stream", 0))
+	ev := <-events
+	if ev.Type != "ping" || ev.Queue == nil || ev.Queue.Adapter != "slow" || ev.Queue.Depth != 1 {
+		t.Fatalf("expected a queue ping first, got %+v", ev)
+	}
+	close(slow.release)
+	var types []string
+	for ev := range events {
+		if ev.Type != "ping" {
+			types = append(types, ev.Type)
+		}
+	}
+	if err := <-errs; err != nil {
+		t.Fatalf("stream failed: %v", err)
+	}
+	if len(types) != 2 || types[0] != "message_start" {
+		t.Fatalf("expected the stream to start once admitted, got %v", types)
+	}
+	<-done
+}
+
+type streamingGatedAdapter struct{ *gatedAdapter }
+
+func (a *streamingGatedAdapter) Stream(_ context.Context, _ orchestrator.Request) (<-chan orchestrator.StreamEvent, <-chan error) {
+	events := make(chan orchestrator.StreamEvent, 2)
+	errs := make(chan error, 1)
+	events <- orchestrator.StreamEvent{Type: "message_start"}
+	events <- orchestrator.StreamEvent{Type: "message_stop"}
+	close(events)
+	close(errs)
+	return events, errs
+}
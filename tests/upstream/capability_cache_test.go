@@ -0,0 +1,152 @@
+package upstream_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	. "ccgateway/internal/upstream"
+)
+
+func modelsServer(t *testing.T, hits *int64) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(hits, 1)
+		switch r.URL.Path {
+		case "/v1/models":
+			if r.Header.Get("authorization") == "" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			_, _ = w.Write([]byte(`{"data":[{"id":"gpt-b","context_length":128000,"top_provider":{"max_completion_tokens":16384},"architecture":{"input_modalities":["text","image"]}},{"id":"gpt-a"}]}`))
+		case "/v1beta/models":
+			_, _ = w.Write([]byte(`{"models":[{"name":"models/gemini-pro","inputTokenLimit":32000,"outputTokenLimit":8192}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newPoolAdapter(t *testing.T, name, baseURL, key string, headers map[string]string) Adapter {
+	t.Helper()
+	a, err := NewHTTPAdapter(HTTPAdapterConfig{Name: name, Kind: AdapterKindOpenAI, BaseURL: baseURL, APIKey: key, Headers: headers}, nil)
+	if err != nil {
+		t.Fatalf("new adapter: %v", err)
+	}
+	return a
+}
+
+func TestCapabilityCacheSharesOneProbePerBaseURL(t *testing.T) {
+	var hits int64
+	srv := modelsServer(t, &hits)
+	svc := NewRouterService(RouterConfig{}, []Adapter{
+		newPoolAdapter(t, "key-1", srv.URL, "k1", nil),
+		newPoolAdapter(t, "key-2", srv.URL+"/", "k2", nil),
+		newPoolAdapter(t, "key-3", srv.URL, "k3", nil),
+	})
+
+	var wg sync.WaitGroup
+	for _, name := range []string{"key-1", "key-2", "key-3", "key-1"} {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			if _, err := svc.AdapterCapabilities(context.Background(), name, false); err != nil {
+				t.Errorf("capabilities %s: %v", name, err)
+			}
+		}(name)
+	}
+	wg.Wait()
+	if got := atomic.LoadInt64(&hits); got != 1 {
+		t.Fatalf("expected one probe for the shared base_url, got %d", got)
+	}
+
+	caps, err := svc.AdapterCapabilities(context.Background(), "key-2", false)
+	if err != nil {
+		t.Fatalf("capabilities: %v", err)
+	}
+	if len(caps.Models) != 2 || caps.Models[0] != "gpt-a" || caps.Probes != 1 || caps.Hits != 4 {
+		t.Fatalf("unexpected capabilities: %+v", caps)
+	}
+	c := caps.Constraints["gpt-b"]
+	if c.ContextWindow != 128000 || c.MaxOutputTokens != 16384 || c.SupportsVision == nil || !*c.SupportsVision {
+		t.Fatalf("unexpected constraints: %+v", c)
+	}
+	snap := svc.CapabilitySnapshot()
+	if len(snap) != 1 || len(snap[0].Adapters) != 3 {
+		t.Fatalf("expected one entry shared by three adapters, got %+v", snap)
+	}
+
+	if _, err := svc.AdapterCapabilities(context.Background(), "key-1", true); err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if got := atomic.LoadInt64(&hits); got != 2 {
+		t.Fatalf("refresh should probe again, got %d probes", got)
+	}
+	if _, err := svc.AdapterCapabilities(context.Background(), "missing", false); !errors.Is(err, ErrUnknownAdapter) {
+		t.Fatalf("expected ErrUnknownAdapter, got %v", err)
+	}
+}
+
+func TestCapabilityCacheInvalidatesOnConfigChange(t *testing.T) {
+	var hits int64
+	srv := modelsServer(t, &hits)
+	svc := NewRouterService(RouterConfig{}, []Adapter{newPoolAdapter(t, "a", srv.URL, "k1", nil)})
+	if _, err := svc.AdapterCapabilities(context.Background(), "a", false); err != nil {
+		t.Fatalf("capabilities: %v", err)
+	}
+
+	// Adding a pool key keeps the entry.
+	if _, err := svc.UpdateUpstreamConfig(UpstreamAdminConfig{Adapters: []AdapterSpec{
+		{Name: "a", Kind: AdapterKindOpenAI, BaseURL: srv.URL, APIKey: "k1"},
+		{Name: "b", Kind: AdapterKindOpenAI, BaseURL: srv.URL, APIKey: "k2"},
+	}, DefaultRoute: []string{"a", "b"}}); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if _, err := svc.AdapterCapabilities(context.Background(), "b", false); err != nil || atomic.LoadInt64(&hits) != 1 {
+		t.Fatalf("expected cached entry after adding a key, probes=%d err=%v", atomic.LoadInt64(&hits), err)
+	}
+
+	// Changing the headers sent to the provider drops it.
+	if _, err := svc.UpdateUpstreamConfig(UpstreamAdminConfig{Adapters: []AdapterSpec{
+		{Name: "a", Kind: AdapterKindOpenAI, BaseURL: srv.URL, APIKey: "k1", Headers: map[string]string{"x-org": "two"}},
+	}, DefaultRoute: []string{"a"}}); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if len(svc.CapabilitySnapshot()) != 0 {
+		t.Fatalf("expected the entry to be invalidated")
+	}
+	if _, err := svc.AdapterCapabilities(context.Background(), "a", false); err != nil || atomic.LoadInt64(&hits) != 2 {
+		t.Fatalf("expected a fresh probe, probes=%d err=%v", atomic.LoadInt64(&hits), err)
+	}
+}
+
+func TestCapabilityCacheGeminiModelsAndFailures(t *testing.T) {
+	var hits int64
+	srv := modelsServer(t, &hits)
+	gemini, err := NewHTTPAdapter(HTTPAdapterConfig{Name: "g", Kind: AdapterKindGemini, BaseURL: srv.URL, APIKey: "k"}, nil)
+	if err != nil {
+		t.Fatalf("new adapter: %v", err)
+	}
+	cache := NewCapabilityCache(0)
+	caps, err := cache.Lookup(context.Background(), gemini)
+	if err != nil || len(caps.Models) != 1 || caps.Models[0] != "gemini-pro" || caps.Constraints["gemini-pro"].MaxOutputTokens != 8192 {
+		t.Fatalf("unexpected gemini capabilities: %+v err=%v", caps, err)
+	}
+
+	noKey := newPoolAdapter(t, "nokey", srv.URL, "", nil)
+	for i := 0; i < 2; i++ {
+		caps, err = cache.Lookup(context.Background(), noKey)
+		if err == nil || caps.Error == "" {
+			t.Fatalf("expected a cached probe error, got %+v", caps)
+		}
+	}
+	if got := atomic.LoadInt64(&hits); got != 2 {
+		t.Fatalf("failed probes should be cached too, got %d probes", got)
+	}
+}
@@ -0,0 +1,84 @@
+package upstream_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"ccgateway/internal/orchestrator"
+	"ccgateway/internal/scheduler"
+	. "ccgateway/internal/upstream"
+)
+
+func costElection() *scheduler.Election {
+	election := scheduler.NewElection(scheduler.ElectionConfig{Enabled: true})
+	election.UpdateScores([]scheduler.IntelligenceScore{
+		{AdapterName: "smart", Model: "m", Score: 95, TestedAt: time.Now()},
+		{AdapterName: "pricey", Model: "m", Score: 80, TestedAt: time.Now()},
+		{AdapterName: "cheap", Model: "m", Score: 74, TestedAt: time.Now()},
+		{AdapterName: "weak", Model: "m", Score: 40, TestedAt: time.Now()},
+	})
+	return election
+}
+
+func TestDispatcher_SimplePrefersCheapestWithinScoreDelta(t *testing.T) {
+	d := NewDispatcher(DispatchConfig{
+		Enabled:             true,
+		FallbackToScheduler: true,
+		AdapterPrices: map[string]AdapterPrice{
+			"pricey": {InputPerMTok: 15, OutputPerMTok: 75},
+			"cheap":  {InputPerMTok: 0.25, OutputPerMTok: 1.25},
+			"weak":   {InputPerMTok: 0.01, OutputPerMTok: 0.01},
+		},
+		PreferCheapestWithinScoreDelta: 10,
+	}, costElection())
+	req := orchestrator.Request{
+		Model:     "test",
+		MaxTokens: 1000,
+		Messages:  []orchestrator.Message{{Role: "user", Content: "hi"}},
+	}
+
+	for i := 0; i < 3; i++ {
+		route, decision := d.Decide(context.Background(), req)
+		// weak is cheaper but scores 40 points below the best worker.
+		if want := []string{"cheap", "pricey", "weak", "smart"}; !reflect.DeepEqual(route, want) {
+			t.Fatalf("expected %v, got %v", want, route)
+		}
+		if decision == nil || decision.Reason != "simple_to_cheapest" || decision.EstimatedCostUSD != 0.00125 {
+			t.Fatalf("unexpected decision: %+v", decision)
+		}
+	}
+
+	complexReq := req
+	complexReq.Tools = []orchestrator.Tool{{Name: "bash"}}
+	route, decision := d.Decide(context.Background(), complexReq)
+	if len(route) == 0 || route[0] != "smart" || decision.Reason != "complex_to_scheduler" {
+		t.Fatalf("complex requests should still reach the scheduler, got %v (%+v)", route, decision)
+	}
+}
+
+func TestDispatcher_CostPolicyOffKeepsRoundRobin(t *testing.T) {
+	d := NewDispatcher(DispatchConfig{
+		Enabled:       true,
+		AdapterPrices: map[string]AdapterPrice{"cheap": {InputPerMTok: 0.25, OutputPerMTok: 1.25}},
+	}, costElection())
+	req := orchestrator.Request{Model: "test", Messages: []orchestrator.Message{{Role: "user", Content: "hi"}}}
+
+	seen := map[string]bool{}
+	for i := 0; i < 6; i++ {
+		route, decision := d.Decide(context.Background(), req)
+		if decision.Reason != "simple_to_workers" {
+			t.Fatalf("expected round-robin without the delta knob, got %q", decision.Reason)
+		}
+		seen[route[0]] = true
+	}
+	if len(seen) < 2 {
+		t.Fatalf("expected traffic across workers, got %v", seen)
+	}
+
+	d.UpdateConfig(DispatchConfig{Enabled: true, PreferCheapestWithinScoreDelta: 10})
+	if _, decision := d.Decide(context.Background(), req); decision.Reason != "simple_to_workers" {
+		t.Fatalf("expected round-robin without prices, got %q", decision.Reason)
+	}
+}
@@ -0,0 +1,103 @@
+package upstream_test
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"ccgateway/internal/orchestrator"
+	"ccgateway/internal/scheduler"
+	. "ccgateway/internal/upstream"
+)
+
+func TestDispatchHistoryRecordsDecisionsAndElectionsAcrossRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dispatch-history.jsonl")
+	history, err := NewDispatchHistory(path)
+	if err != nil {
+		t.Fatalf("open history: %v", err)
+	}
+	election := scheduler.NewElection(scheduler.ElectionConfig{Enabled: true, MinScoreDifference: 5})
+	dispatcher := NewDispatcher(DispatchConfig{Enabled: true, MinScoreDifference: 5}, election)
+	dispatcher.SetHistory(history)
+	election.SetOnChange(dispatcher.RecordElection)
+	election.UpdateScores([]scheduler.IntelligenceScore{
+		{AdapterName: "smart", Model: "m1", Score: 90},
+		{AdapterName: "worker", Model: "m2", Score: 87},
+	})
+
+	svc := NewRouterService(RouterConfig{Dispatcher: dispatcher}, []Adapter{
+		NewMockAdapter("smart", false),
+		NewMockAdapter("worker", true),
+	})
+	simple := orchestrator.Request{Model: "m", Messages: []orchestrator.Message{{Role: "user", Content: "hi"}}}
+	complex := orchestrator.Request{Model: "m", Tools: []orchestrator.Tool{{Name: "bash"}}, Messages: []orchestrator.Message{{Role: "user", Content: "hi"}}}
+	if _, err := svc.Complete(context.Background(), simple); err != nil {
+		t.Fatalf("simple request: %v", err)
+	}
+	if _, err := svc.Complete(context.Background(), complex); err != nil {
+		t.Fatalf("complex request: %v", err)
+	}
+	if err := history.Close(); err != nil {
+		t.Fatalf("close history: %v", err)
+	}
+
+	reopened, err := NewDispatchHistory(path)
+	if err != nil {
+		t.Fatalf("reopen history: %v", err)
+	}
+	defer reopened.Close()
+	report := reopened.Analytics(DispatchAnalyticsQuery{Since: time.Now().Add(-time.Hour)})
+	if report.Decisions != 2 {
+		t.Fatalf("expected 2 decisions, got %+v", report)
+	}
+	if report.ByTarget["scheduler"].Requests != 1 || report.ByTarget["worker"].Requests != 1 {
+		t.Fatalf("unexpected per-target split: %+v", report.ByTarget)
+	}
+	// The worker always fails, so the simple request escalates to the scheduler.
+	if report.EscalationRate != 1 || report.SuccessRate != 1 || report.ByServedBy["smart"].Requests != 2 {
+		t.Fatalf("expected worker escalation to scheduler, got %+v", report)
+	}
+	if report.ByReason["simple_to_workers"] != 1 || report.ByReason["complex_to_scheduler"] != 1 {
+		t.Fatalf("unexpected reasons: %+v", report.ByReason)
+	}
+	if report.Elections.Count != 1 || report.Elections.CloseScoreCount != 1 || report.Elections.ScoreDelta.Max != 3 {
+		t.Fatalf("unexpected election analytics: %+v", report.Elections)
+	}
+	var at2, at5 int
+	for _, sim := range report.Elections.MinScoreDiff {
+		switch sim.Threshold {
+		case 2:
+			at2 = sim.CloseElections
+		case 5:
+			at5 = sim.CloseElections
+		}
+	}
+	if at2 != 0 || at5 != 1 {
+		t.Fatalf("unexpected min score simulation: %+v", report.Elections.MinScoreDiff)
+	}
+	if len(report.LongContextChars) == 0 {
+		t.Fatalf("expected long context simulation rows")
+	}
+	if empty := reopened.Analytics(DispatchAnalyticsQuery{Until: time.Now().Add(-time.Hour)}); empty.Decisions != 0 {
+		t.Fatalf("expected window to exclude decisions, got %d", empty.Decisions)
+	}
+}
+
+func TestDispatcherDecideExplainsRoute(t *testing.T) {
+	election := scheduler.NewElection(scheduler.ElectionConfig{Enabled: true})
+	election.UpdateScores([]scheduler.IntelligenceScore{{AdapterName: "solo", Score: 80}})
+	dispatcher := NewDispatcher(DispatchConfig{Enabled: true}, election)
+
+	route, decision := dispatcher.Decide(context.Background(), orchestrator.Request{
+		Model:    "m",
+		Messages: []orchestrator.Message{{Role: "user", Content: strings.Repeat("a", 10)}},
+	})
+	if len(route) != 1 || route[0] != "solo" || decision == nil {
+		t.Fatalf("unexpected route/decision: %v %+v", route, decision)
+	}
+	if decision.Target != "scheduler" || decision.Reason != "no_workers" || decision.ContextChars != 10 {
+		t.Fatalf("unexpected decision: %+v", decision)
+	}
+}
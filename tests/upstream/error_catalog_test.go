@@ -0,0 +1,131 @@
+package upstream_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"ccgateway/internal/orchestrator"
+	. "ccgateway/internal/upstream"
+)
+
+func TestClassifyErrorCatalog(t *testing.T) {
+	cases := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantType   string
+		wantCode   string
+	}{
+		{
+			name:       "anthropic rate limit",
+			err:        &HTTPStatusError{Adapter: "a", Status: 429, Body: `{"type":"error","error":{"type":"rate_limit_error","message":"Number of request tokens has exceeded your per-minute rate limit"}}`},
+			wantStatus: 429, wantType: "rate_limit_error", wantCode: ErrorCodeRateLimited,
+		},
+		{
+			name:       "openai quota exhausted",
+			err:        &HTTPStatusError{Adapter: "o", Status: 429, Body: `{"error":{"message":"You exceeded your current quota, please check your plan and billing details.","type":"insufficient_quota","code":"insufficient_quota"}}`},
+			wantStatus: 402, wantType: "billing_error", wantCode: ErrorCodeQuotaExhausted,
+		},
+		{
+			name:       "anthropic credit balance",
+			err:        &HTTPStatusError{Adapter: "a", Status: 400, Body: `{"type":"error","error":{"type":"invalid_request_error","message":"Your credit balance is too low to access the Anthropic API."}}`},
+			wantStatus: 402, wantType: "billing_error", wantCode: ErrorCodeQuotaExhausted,
+		},
+		{
+			name:       "openai invalid key",
+			err:        &HTTPStatusError{Adapter: "o", Status: 401, Body: `{"error":{"message":"Incorrect API key provided","type":"invalid_request_error","code":"invalid_api_key"}}`},
+			wantStatus: 502, wantType: "api_error", wantCode: ErrorCodeInvalidAPIKey,
+		},
+		{
+			name:       "gemini invalid key",
+			err:        &HTTPStatusError{Adapter: "g", Status: 400, Body: `{"error":{"code":400,"message":"API key not valid. Please pass a valid API key.","status":"INVALID_ARGUMENT","details":[{"reason":"API_KEY_INVALID"}]}}`},
+			wantStatus: 502, wantType: "api_error", wantCode: ErrorCodeInvalidAPIKey,
+		},
+		{
+			name:       "azure content filter",
+			err:        &HTTPStatusError{Adapter: "o", Status: 400, Body: `{"error":{"message":"The response was filtered due to the prompt triggering Azure OpenAI's content management policy.","code":"content_filter"}}`},
+			wantStatus: 400, wantType: "invalid_request_error", wantCode: ErrorCodeContentFiltered,
+		},
+		{
+			name:       "openai model not found",
+			err:        &HTTPStatusError{Adapter: "o", Status: 404, Body: `{"error":{"message":"The model 'gpt-x' does not exist or you do not have access to it.","code":"model_not_found"}}`},
+			wantStatus: 404, wantType: "not_found_error", wantCode: ErrorCodeModelNotFound,
+		},
+		{
+			name:       "gemini model not found",
+			err:        &HTTPStatusError{Adapter: "g", Status: 404, Body: `{"error":{"code":404,"message":"models/gemini-x is not found for API version v1beta","status":"NOT_FOUND"}}`},
+			wantStatus: 404, wantType: "not_found_error", wantCode: ErrorCodeModelNotFound,
+		},
+		{
+			name:       "anthropic prompt too long",
+			err:        &HTTPStatusError{Adapter: "a", Status: 400, Body: `{"type":"error","error":{"type":"invalid_request_error","message":"prompt is too long: 210000 tokens > 200000 maximum"}}`},
+			wantStatus: 400, wantType: "invalid_request_error", wantCode: ErrorCodeContextLength,
+		},
+		{
+			name:       "anthropic overloaded",
+			err:        &HTTPStatusError{Adapter: "a", Status: 529, Body: `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`},
+			wantStatus: 503, wantType: "overloaded_error", wantCode: ErrorCodeOverloaded,
+		},
+		{
+			name:       "plain bad request",
+			err:        &HTTPStatusError{Adapter: "a", Status: 400, Body: `{"type":"error","error":{"type":"invalid_request_error","message":"max_tokens: field required"}}`},
+			wantStatus: 400, wantType: "invalid_request_error", wantCode: ErrorCodeInvalidRequest,
+		},
+		{
+			name:       "wrapped string error",
+			err:        fmt.Errorf("model %q: %w", "m", errors.New("adapter x upstream status 429: slow down")),
+			wantStatus: 429, wantType: "rate_limit_error", wantCode: ErrorCodeRateLimited,
+		},
+		{
+			name:       "openai stream error chunk",
+			err:        errors.New(`openai stream returned error: {"message":"This model's maximum context length is 8192 tokens","code":"context_length_exceeded"}`),
+			wantStatus: 400, wantType: "invalid_request_error", wantCode: ErrorCodeContextLength,
+		},
+		{
+			name:       "timeout",
+			err:        fmt.Errorf("adapter a: %w", context.DeadlineExceeded),
+			wantStatus: 504, wantType: "api_error", wantCode: ErrorCodeUpstreamTimeout,
+		},
+		{
+			name:       "unknown server error",
+			err:        &HTTPStatusError{Adapter: "a", Status: 500, Body: "boom"},
+			wantStatus: 502, wantType: "api_error", wantCode: ErrorCodeUpstreamError,
+		},
+		{
+			name:       "no status, phrase only",
+			err:        errors.New("all adapters failed: model not found somewhere"),
+			wantStatus: 502, wantType: "api_error", wantCode: ErrorCodeUpstreamError,
+		},
+	}
+	for _, tc := range cases {
+		got := ClassifyError(tc.err)
+		if got.Status != tc.wantStatus || got.Type != tc.wantType || got.Code != tc.wantCode {
+			t.Errorf("%s: got %d %s %s, want %d %s %s", tc.name, got.Status, got.Type, got.Code, tc.wantStatus, tc.wantType, tc.wantCode)
+		}
+	}
+}
+
+func TestHTTPAdapterReturnsTypedStatusError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("retry-after", "7")
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte(`{"type":"error","error":{"type":"rate_limit_error","message":"slow down"}}`))
+	}))
+	defer srv.Close()
+	adapter, err := NewHTTPAdapter(HTTPAdapterConfig{Name: "limited", Kind: AdapterKindAnthropic, BaseURL: srv.URL, Model: "m"}, nil)
+	if err != nil {
+		t.Fatalf("new adapter: %v", err)
+	}
+	_, err = adapter.Complete(context.Background(), orchestrator.Request{Model: "m", MaxTokens: 16, Messages: []orchestrator.Message{{Role: "user", Content: "hi"}}})
+	var statusErr *HTTPStatusError
+	if !errors.As(err, &statusErr) || statusErr.Status != 429 || statusErr.RetryAfter != "7" || statusErr.Adapter != "limited" {
+		t.Fatalf("expected typed 429 error, got %#v", err)
+	}
+	if got := ClassifyError(err); got.Code != ErrorCodeRateLimited || got.RetryAfter != "7" || got.ProviderCode != "rate_limit_error" {
+		t.Fatalf("unexpected classification %#v", got)
+	}
+}
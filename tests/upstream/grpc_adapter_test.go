@@ -0,0 +1,186 @@
+package upstream_test
+
+import (
+	. "ccgateway/internal/upstream"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ccgateway/internal/orchestrator"
+)
+
+func newGRPCTestServer(t *testing.T, handler func(w http.ResponseWriter, r *http.Request, req GRPCGenerateRequest)) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 || r.Header.Get("content-type") != "application/grpc" {
+			http.Error(w, "want grpc over http/2", http.StatusUnsupportedMediaType)
+			return
+		}
+		msg, err := ReadGRPCFrame(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var req GRPCGenerateRequest
+		if err := req.Unmarshal(msg); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("content-type", "application/grpc")
+		handler(w, r, req)
+	}))
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func writeGRPCStatus(w http.ResponseWriter, code, message string) {
+	w.Header().Set(http.TrailerPrefix+"Grpc-Status", code)
+	if message != "" {
+		w.Header().Set(http.TrailerPrefix+"Grpc-Message", message)
+	}
+}
+
+func TestGRPCAdapterComplete(t *testing.T) {
+	var gotPath, gotAuth, gotTimeout string
+	var got GRPCGenerateRequest
+	srv := newGRPCTestServer(t, func(w http.ResponseWriter, r *http.Request, req GRPCGenerateRequest) {
+		gotPath, gotAuth, gotTimeout, got = r.URL.Path, r.Header.Get("authorization"), r.Header.Get("grpc-timeout"), req
+		out := GRPCGenerateResponse{Model: "internal-7b", Text: "hello from grpc", StopReason: "max_tokens", Usage: &GRPCUsage{InputTokens: 12, OutputTokens: 4}}
+		_, _ = w.Write(AppendGRPCFrame(nil, out.Marshal()))
+		writeGRPCStatus(w, "0", "")
+	})
+	adapter, err := NewGRPCAdapter(GRPCAdapterConfig{Name: "internal", BaseURL: srv.URL, APIKey: "sk-internal", TimeoutMS: 5000}, srv.Client())
+	if err != nil {
+		t.Fatalf("new adapter: %v", err)
+	}
+
+	resp, err := adapter.Complete(context.Background(), orchestrator.Request{
+		Model:     "claude-test",
+		MaxTokens: 64,
+		System:    "be brief",
+		Messages:  []orchestrator.Message{{Role: "user", Content: "hi"}},
+		Metadata:  map[string]any{"temperature": 0.2, "stop_sequences": []any{"END"}},
+	})
+	if err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+	if gotPath != "/ccgateway.inference.v1.Inference/Generate" || gotAuth != "Bearer sk-internal" {
+		t.Fatalf("unexpected call path=%q auth=%q", gotPath, gotAuth)
+	}
+	if !strings.HasSuffix(gotTimeout, "m") && !strings.HasSuffix(gotTimeout, "u") {
+		t.Fatalf("expected the deadline as grpc-timeout, got %q", gotTimeout)
+	}
+	if got.Model != "claude-test" || got.System != "be brief" || got.MaxTokens != 64 || len(got.Messages) != 1 || got.Messages[0].Content != "hi" {
+		t.Fatalf("unexpected request %+v", got)
+	}
+	if got.Temperature == nil || *got.Temperature != 0.2 || got.TopP != nil || len(got.StopSequences) != 1 {
+		t.Fatalf("unexpected sampling fields %+v", got)
+	}
+	if resp.Model != "internal-7b" || len(resp.Blocks) != 1 || resp.Blocks[0].Text != "hello from grpc" {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+	if resp.StopReason != "max_tokens" || resp.Usage.InputTokens != 12 || resp.Usage.OutputTokens != 4 {
+		t.Fatalf("unexpected stop/usage %+v", resp)
+	}
+}
+
+func TestGRPCAdapterStream(t *testing.T) {
+	srv := newGRPCTestServer(t, func(w http.ResponseWriter, r *http.Request, req GRPCGenerateRequest) {
+		if r.URL.Path != "/ccgateway.inference.v1.Inference/GenerateStream" {
+			writeGRPCStatus(w, "12", "unexpected method")
+			return
+		}
+		for _, chunk := range []GRPCGenerateResponse{{Text: "hel"}, {Text: "lo"}, {StopReason: "end_turn", Usage: &GRPCUsage{InputTokens: 3, OutputTokens: 2}}} {
+			_, _ = w.Write(AppendGRPCFrame(nil, chunk.Marshal()))
+			w.(http.Flusher).Flush()
+		}
+		writeGRPCStatus(w, "0", "")
+	})
+	adapter, err := NewGRPCAdapter(GRPCAdapterConfig{Name: "internal", BaseURL: srv.URL}, srv.Client())
+	if err != nil {
+		t.Fatalf("new adapter: %v", err)
+	}
+
+	events, errs := adapter.Stream(context.Background(), orchestrator.Request{Model: "m", MaxTokens: 16, Messages: []orchestrator.Message{{Role: "user", Content: "hi"}}})
+	var types []string
+	var text strings.Builder
+	var last orchestrator.StreamEvent
+	for ev := range events {
+		types = append(types, ev.Type)
+		text.WriteString(ev.DeltaText)
+		if ev.Type == "message_delta" {
+			last = ev
+		}
+	}
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("stream: %v", err)
+		}
+	}
+	if text.String() != "hello" {
+		t.Fatalf("expected streamed text, got %q", text.String())
+	}
+	want := "message_start,content_block_start,content_block_delta,content_block_delta,content_block_stop,message_delta,message_stop"
+	if strings.Join(types, ",") != want {
+		t.Fatalf("unexpected events %v", types)
+	}
+	if last.StopReason != "end_turn" || last.Usage.OutputTokens != 2 {
+		t.Fatalf("unexpected message_delta %+v", last)
+	}
+}
+
+func TestGRPCAdapterMapsStatusCodes(t *testing.T) {
+	srv := newGRPCTestServer(t, func(w http.ResponseWriter, r *http.Request, req GRPCGenerateRequest) {
+		// Trailers-only response: the status arrives with the headers.
+		w.Header().Set("grpc-status", "8")
+		w.Header().Set("grpc-message", "quota%20exceeded")
+		w.WriteHeader(http.StatusOK)
+	})
+	adapter, err := NewGRPCAdapter(GRPCAdapterConfig{Name: "internal", BaseURL: srv.URL}, srv.Client())
+	if err != nil {
+		t.Fatalf("new adapter: %v", err)
+	}
+	_, err = adapter.Complete(context.Background(), orchestrator.Request{Model: "m", MaxTokens: 16, Messages: []orchestrator.Message{{Role: "user", Content: "hi"}}})
+	var statusErr *HTTPStatusError
+	if !errors.As(err, &statusErr) || statusErr.Status != http.StatusTooManyRequests || !strings.Contains(statusErr.Body, "quota exceeded") {
+		t.Fatalf("expected a 429 status error, got %v", err)
+	}
+}
+
+func TestGRPCAdapterHonorsDeadline(t *testing.T) {
+	srv := newGRPCTestServer(t, func(w http.ResponseWriter, r *http.Request, req GRPCGenerateRequest) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(2 * time.Second):
+		}
+	})
+	adapter, err := NewGRPCAdapter(GRPCAdapterConfig{Name: "internal", BaseURL: srv.URL, TimeoutMS: 50}, srv.Client())
+	if err != nil {
+		t.Fatalf("new adapter: %v", err)
+	}
+	start := time.Now()
+	_, err = adapter.Complete(context.Background(), orchestrator.Request{Model: "m", MaxTokens: 16, Messages: []orchestrator.Message{{Role: "user", Content: "hi"}}})
+	if err == nil || time.Since(start) > time.Second {
+		t.Fatalf("expected the call to stop at the deadline, got err=%v after %s", err, time.Since(start))
+	}
+}
+
+func TestGRPCAdapterRejectsPlaintextAndBuildsFromSpec(t *testing.T) {
+	if _, err := NewGRPCAdapter(GRPCAdapterConfig{Name: "internal", BaseURL: "http://10.0.0.1:50051"}, nil); err == nil {
+		t.Fatalf("expected plaintext base_url to be rejected")
+	}
+	adapter, err := BuildAdapterFromSpec(AdapterSpec{Name: "internal", Kind: "grpc", BaseURL: "https://models.internal:443", PoolSize: 3, GRPCService: "acme.Llm"})
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	spec := adapter.(interface{ AdminSpec() AdapterSpec }).AdminSpec()
+	if spec.Kind != AdapterKindGRPC || spec.PoolSize != 3 || spec.GRPCService != "acme.Llm" || spec.GRPCMethod != "Generate" {
+		t.Fatalf("unexpected spec %+v", spec)
+	}
+}
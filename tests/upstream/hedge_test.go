@@ -0,0 +1,151 @@
+package upstream_test
+
+import (
+	. "ccgateway/internal/upstream"
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"ccgateway/internal/orchestrator"
+)
+
+// hedgeAdapter answers after delay and notes when its call was canceled
+// before that.
+type hedgeAdapter struct {
+	name     string
+	delay    time.Duration
+	canceled chan struct{}
+	once     sync.Once
+}
+
+func newHedgeAdapter(name string, delay time.Duration) *hedgeAdapter {
+	return &hedgeAdapter{name: name, delay: delay, canceled: make(chan struct{})}
+}
+
+func (a *hedgeAdapter) Name() string { return a.name }
+
+func (a *hedgeAdapter) wait(ctx context.Context) error {
+	select {
+	case <-time.After(a.delay):
+		return nil
+	case <-ctx.Done():
+		a.once.Do(func() { close(a.canceled) })
+		return ctx.Err()
+	}
+}
+
+func (a *hedgeAdapter) Complete(ctx context.Context, req orchestrator.Request) (orchestrator.Response, error) {
+	if err := a.wait(ctx); err != nil {
+		return orchestrator.Response{}, err
+	}
+	return orchestrator.Response{
+		Model:      req.Model,
+		Blocks:     []orchestrator.AssistantBlock{{Type: "text", Text: "from " + a.name}},
+		StopReason: "end_turn",
+	}, nil
+}
+
+func (a *hedgeAdapter) Stream(ctx context.Context, req orchestrator.Request) (<-chan orchestrator.StreamEvent, <-chan error) {
+	events := make(chan orchestrator.StreamEvent)
+	errs := make(chan error, 1)
+	go func() {
+		defer close(events)
+		defer close(errs)
+		if err := a.wait(ctx); err != nil {
+			errs <- err
+			return
+		}
+		events <- orchestrator.StreamEvent{Type: "message_start"}
+		events <- orchestrator.StreamEvent{Type: "content_block_delta", DeltaText: "from " + a.name}
+		events <- orchestrator.StreamEvent{Type: "message_stop"}
+	}()
+	return events, errs
+}
+
+func hedgedRequest(afterMS int) (orchestrator.Request, *[]HedgeOutcome, context.Context) {
+	var outcomes []HedgeOutcome
+	ctx := WithHedgeObserver(context.Background(), func(o HedgeOutcome) {
+		outcomes = append(outcomes, o)
+	})
+	return orchestrator.Request{
+		Model:     "m",
+		MaxTokens: 16,
+		Messages:  []orchestrator.Message{{Role: "user", Content: "hi"}},
+		Metadata:  map[string]any{"hedge_after_ms": afterMS},
+	}, &outcomes, ctx
+}
+
+func TestRouterServiceHedgesSlowPrimary(t *testing.T) {
+	slow := newHedgeAdapter("slow", 2*time.Second)
+	fast := newHedgeAdapter("fast", 10*time.Millisecond)
+	svc := NewRouterService(RouterConfig{DefaultRoute: []string{"slow", "fast"}, Timeout: 5 * time.Second}, []Adapter{slow, fast})
+
+	req, outcomes, ctx := hedgedRequest(30)
+	started := time.Now()
+	resp, err := svc.Complete(ctx, req)
+	if err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+	if resp.Trace.Provider != "fast" || time.Since(started) > time.Second {
+		t.Fatalf("expected the hedge to win quickly, got %q after %v", resp.Trace.Provider, time.Since(started))
+	}
+	select {
+	case <-slow.canceled:
+	case <-time.After(time.Second):
+		t.Fatalf("expected the losing primary to be canceled")
+	}
+	if len(*outcomes) != 1 {
+		t.Fatalf("expected one hedge outcome, got %#v", *outcomes)
+	}
+	if o := (*outcomes)[0]; !o.Fired || o.Winner != "fast" || o.Primary != "slow" || o.Secondary != "fast" || o.AfterMS != 30 || o.Stream {
+		t.Fatalf("unexpected outcome %#v", o)
+	}
+}
+
+func TestRouterServiceHedgeNotFiredForFastPrimary(t *testing.T) {
+	primary := newHedgeAdapter("primary", 0)
+	secondary := newHedgeAdapter("secondary", 0)
+	svc := NewRouterService(RouterConfig{DefaultRoute: []string{"primary", "secondary"}, Timeout: 5 * time.Second}, []Adapter{primary, secondary})
+
+	req, outcomes, ctx := hedgedRequest(500)
+	resp, err := svc.Complete(ctx, req)
+	if err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+	if resp.Trace.Provider != "primary" {
+		t.Fatalf("expected primary, got %q", resp.Trace.Provider)
+	}
+	if len(*outcomes) != 1 || (*outcomes)[0].Fired || (*outcomes)[0].Winner != "primary" {
+		t.Fatalf("expected an unfired hedge, got %#v", *outcomes)
+	}
+}
+
+func TestRouterServiceStreamHedgesSlowPrimary(t *testing.T) {
+	slow := newHedgeAdapter("slow", 2*time.Second)
+	fast := newHedgeAdapter("fast", 10*time.Millisecond)
+	svc := NewRouterService(RouterConfig{DefaultRoute: []string{"slow", "fast"}, Timeout: 5 * time.Second}, []Adapter{slow, fast})
+
+	req, outcomes, ctx := hedgedRequest(30)
+	events, errs := svc.Stream(ctx, req)
+	var text string
+	for ev := range events {
+		text += ev.DeltaText
+	}
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("stream: %v", err)
+		}
+	}
+	if text != "from fast" {
+		t.Fatalf("expected the hedge's stream, got %q", text)
+	}
+	select {
+	case <-slow.canceled:
+	case <-time.After(time.Second):
+		t.Fatalf("expected the losing primary to be canceled")
+	}
+	if len(*outcomes) != 1 || !(*outcomes)[0].Fired || (*outcomes)[0].Winner != "fast" || !(*outcomes)[0].Stream {
+		t.Fatalf("unexpected outcomes %#v", *outcomes)
+	}
+}
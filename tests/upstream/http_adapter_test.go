@@ -693,3 +693,277 @@ func TestHTTPAdapterOpenAIStreamToAnthropicEvents(t *testing.T) {
 		t.Fatalf("unexpected last event: %+v", got[len(got)-1])
 	}
 }
+
+func TestHTTPAdapterResponseFormatTranslation(t *testing.T) {
+	schema := map[string]any{"type": "object", "properties": map[string]any{"ok": map[string]any{"type": "boolean"}}}
+	format := &orchestrator.ResponseFormat{Type: "json_schema", Name: "verdict", Schema: schema, Strict: true}
+
+	var openAIBody, anthropicBody map[string]any
+	var anthropicBeta string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", "application/json")
+		switch r.URL.Path {
+		case "/v1/chat/completions":
+			_ = json.NewDecoder(r.Body).Decode(&openAIBody)
+			_, _ = w.Write([]byte(`{"choices":[{"finish_reason":"stop","message":{"content":"{\"ok\":true}"}}]}`))
+		case "/v1/messages":
+			anthropicBeta = r.Header.Get("anthropic-beta")
+			_ = json.NewDecoder(r.Body).Decode(&anthropicBody)
+			_, _ = w.Write([]byte(`{"content":[{"type":"text","text":"{\"ok\":true}"}],"stop_reason":"end_turn"}`))
+		default:
+			t.Fatalf("unexpected path: %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	req := orchestrator.Request{
+		Model:          "m",
+		MaxTokens:      32,
+		Messages:       []orchestrator.Message{{Role: "user", Content: "judge"}},
+		ResponseFormat: format,
+		Headers:        map[string]string{"anthropic-beta": "tools-2024-04-04"},
+	}
+	for _, kind := range []AdapterKind{AdapterKindOpenAI, AdapterKindAnthropic} {
+		adapter, err := NewHTTPAdapter(HTTPAdapterConfig{Name: string(kind), Kind: kind, BaseURL: server.URL}, nil)
+		if err != nil {
+			t.Fatalf("new adapter: %v", err)
+		}
+		if _, err := adapter.Complete(context.Background(), req); err != nil {
+			t.Fatalf("%s complete: %v", kind, err)
+		}
+	}
+
+	rf, _ := openAIBody["response_format"].(map[string]any)
+	spec, _ := rf["json_schema"].(map[string]any)
+	if rf["type"] != "json_schema" || spec["name"] != "verdict" || spec["strict"] != true || spec["schema"] == nil {
+		t.Fatalf("unexpected openai response_format: %#v", openAIBody["response_format"])
+	}
+	of, _ := anthropicBody["output_format"].(map[string]any)
+	if of["type"] != "json_schema" || of["schema"] == nil {
+		t.Fatalf("unexpected anthropic output_format: %#v", anthropicBody["output_format"])
+	}
+	if anthropicBeta != "tools-2024-04-04,structured-outputs-2025-11-13" {
+		t.Fatalf("expected structured outputs beta appended, got %q", anthropicBeta)
+	}
+}
+
+func TestHTTPAdapterImageBlockTranslation(t *testing.T) {
+	var openAIBody, geminiBody map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", "application/json")
+		switch r.URL.Path {
+		case "/v1/chat/completions":
+			_ = json.NewDecoder(r.Body).Decode(&openAIBody)
+			_, _ = w.Write([]byte(`{"choices":[{"finish_reason":"stop","message":{"content":"ok"}}]}`))
+		case "/v1beta/models/m:generateContent":
+			_ = json.NewDecoder(r.Body).Decode(&geminiBody)
+			_, _ = w.Write([]byte(`{"candidates":[{"finishReason":"STOP","content":{"parts":[{"text":"ok"}]}}]}`))
+		default:
+			t.Fatalf("unexpected path: %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	req := orchestrator.Request{
+		Model:     "m",
+		MaxTokens: 32,
+		Messages: []orchestrator.Message{{
+			Role: "user",
+			Content: []any{
+				map[string]any{"type": "text", "text": "describe"},
+				map[string]any{"type": "image", "source": map[string]any{"type": "base64", "media_type": "image/png", "data": "aGVsbG8="}},
+			},
+		}},
+	}
+	for _, kind := range []AdapterKind{AdapterKindOpenAI, AdapterKindGemini} {
+		adapter, err := NewHTTPAdapter(HTTPAdapterConfig{Name: string(kind), Kind: kind, BaseURL: server.URL, Model: "m"}, nil)
+		if err != nil {
+			t.Fatalf("new adapter: %v", err)
+		}
+		if _, err := adapter.Complete(context.Background(), req); err != nil {
+			t.Fatalf("%s complete: %v", kind, err)
+		}
+	}
+
+	messages, _ := openAIBody["messages"].([]any)
+	last, _ := messages[len(messages)-1].(map[string]any)
+	parts, _ := last["content"].([]any)
+	if len(parts) != 2 {
+		t.Fatalf("expected text and image parts, got %#v", last["content"])
+	}
+	imagePart, _ := parts[1].(map[string]any)
+	imageURL, _ := imagePart["image_url"].(map[string]any)
+	if imagePart["type"] != "image_url" || imageURL["url"] != "data:image/png;base64,aGVsbG8=" {
+		t.Fatalf("unexpected openai image part: %#v", imagePart)
+	}
+
+	contents, _ := geminiBody["contents"].([]any)
+	content, _ := contents[0].(map[string]any)
+	gparts, _ := content["parts"].([]any)
+	if len(gparts) != 2 {
+		t.Fatalf("expected text and inline_data parts, got %#v", content["parts"])
+	}
+	inline, _ := gparts[1].(map[string]any)["inline_data"].(map[string]any)
+	if inline["mime_type"] != "image/png" || inline["data"] != "aGVsbG8=" {
+		t.Fatalf("unexpected gemini inline_data: %#v", gparts[1])
+	}
+}
+
+func TestHTTPAdapterCustomHealthCheck(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/models" {
+			t.Fatalf("unexpected path: %s", r.URL.Path)
+		}
+		if r.Method != http.MethodGet {
+			t.Fatalf("unexpected method: %s", r.Method)
+		}
+		if got := r.Header.Get("authorization"); got != "Bearer test-key" {
+			t.Fatalf("unexpected auth header: %q", got)
+		}
+		_, _ = w.Write([]byte(`{"data":[{"id":"gpt-4o"}]}`))
+	}))
+	defer server.Close()
+
+	adapter, err := BuildAdapterFromSpec(AdapterSpec{
+		Name:    "oa",
+		Kind:    AdapterKindOpenAI,
+		BaseURL: server.URL,
+		APIKey:  "test-key",
+		HealthCheck: &HealthCheckSpec{
+			Path:               "/v1/models",
+			ExpectStatus:       http.StatusOK,
+			ExpectBodyContains: "gpt-4o",
+		},
+	})
+	if err != nil {
+		t.Fatalf("build adapter: %v", err)
+	}
+	httpAdapter := adapter.(*HTTPAdapter)
+	res, err := httpAdapter.CheckHealth(context.Background())
+	if err != nil {
+		t.Fatalf("health check: %v", err)
+	}
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", res.StatusCode)
+	}
+	if hc := httpAdapter.AdminSpec().HealthCheck; hc == nil || hc.Method != http.MethodGet {
+		t.Fatalf("expected health check in admin spec with default method, got %+v", hc)
+	}
+
+	adapter, err = BuildAdapterFromSpec(AdapterSpec{
+		Name:        "oa",
+		Kind:        AdapterKindOpenAI,
+		BaseURL:     server.URL,
+		APIKey:      "test-key",
+		HealthCheck: &HealthCheckSpec{Path: "/v1/models", ExpectBodyContains: "claude"},
+	})
+	if err != nil {
+		t.Fatalf("build adapter: %v", err)
+	}
+	if _, err := adapter.(*HTTPAdapter).CheckHealth(context.Background()); err == nil || !strings.Contains(err.Error(), "claude") {
+		t.Fatalf("expected body mismatch error, got %v", err)
+	}
+
+	if _, err := BuildAdapterFromSpec(AdapterSpec{
+		Name:        "bad",
+		Kind:        AdapterKindOpenAI,
+		BaseURL:     server.URL,
+		HealthCheck: &HealthCheckSpec{Method: "DELETE", Path: "/health"},
+	}); err == nil {
+		t.Fatalf("expected unsupported method to be rejected")
+	}
+}
+
+func TestHTTPAdapterForwardsStopSequences(t *testing.T) {
+	for _, tc := range []struct {
+		kind  AdapterKind
+		field string
+		reply string
+	}{
+		{AdapterKindOpenAI, "stop", `{"model":"m","choices":[{"finish_reason":"stop","message":{"content":"ok"}}],"usage":{"prompt_tokens":1,"completion_tokens":1}}`},
+		{AdapterKindAnthropic, "stop_sequences", `{"model":"m","content":[{"type":"text","text":"ok"}],"stop_reason":"end_turn","usage":{"input_tokens":1,"output_tokens":1}}`},
+	} {
+		var got any
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var body map[string]any
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			got = body[tc.field]
+			w.Header().Set("content-type", "application/json")
+			_, _ = w.Write([]byte(tc.reply))
+		}))
+		adapter, err := NewHTTPAdapter(HTTPAdapterConfig{Name: "a", Kind: tc.kind, BaseURL: server.URL, APIKey: "k"}, nil)
+		if err != nil {
+			t.Fatalf("new adapter: %v", err)
+		}
+		_, err = adapter.Complete(context.Background(), orchestrator.Request{
+			Model:     "m",
+			MaxTokens: 16,
+			Messages:  []orchestrator.Message{{Role: "user", Content: "hi"}},
+			Metadata:  map[string]any{"stop_sequences": []string{"END"}},
+		})
+		server.Close()
+		if err != nil {
+			t.Fatalf("%s complete: %v", tc.kind, err)
+		}
+		if list, ok := got.([]any); !ok || len(list) != 1 || list[0] != "END" {
+			t.Fatalf("%s: expected %s to carry the stop sequence, got %#v", tc.kind, tc.field, got)
+		}
+	}
+}
+
+func TestHTTPAdapterTranslatesBuiltinTools(t *testing.T) {
+	computer := orchestrator.Tool{
+		Name:        "computer",
+		Type:        "computer_20250124",
+		Description: "Control the screen",
+		InputSchema: map[string]any{"type": "object"},
+		Options:     map[string]any{"display_width_px": 1024, "display_height_px": 768},
+	}
+	for _, tc := range []struct {
+		kind  AdapterKind
+		reply string
+	}{
+		{AdapterKindAnthropic, `{"model":"m","content":[{"type":"tool_use","id":"t1","name":"computer","input":{"action":"left_click","coordinate":[10,20]}}],"stop_reason":"tool_use","usage":{"input_tokens":1,"output_tokens":1}}`},
+		{AdapterKindOpenAI, `{"model":"m","choices":[{"finish_reason":"tool_calls","message":{"tool_calls":[{"id":"t1","type":"function","function":{"name":"computer","arguments":"{\"action\":\"left_click\",\"coordinate\":\"[10, 20]\"}"}}]}}],"usage":{"prompt_tokens":1,"completion_tokens":1}}`},
+	} {
+		var declared map[string]any
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var body map[string]any
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			if tools, ok := body["tools"].([]any); ok && len(tools) == 1 {
+				declared, _ = tools[0].(map[string]any)
+			}
+			w.Header().Set("content-type", "application/json")
+			_, _ = w.Write([]byte(tc.reply))
+		}))
+		adapter, err := NewHTTPAdapter(HTTPAdapterConfig{Name: "a", Kind: tc.kind, BaseURL: server.URL, APIKey: "k"}, nil)
+		if err != nil {
+			t.Fatalf("new adapter: %v", err)
+		}
+		resp, err := adapter.Complete(context.Background(), orchestrator.Request{
+			Model:     "m",
+			MaxTokens: 16,
+			Messages:  []orchestrator.Message{{Role: "user", Content: "click"}},
+			Tools:     []orchestrator.Tool{computer},
+		})
+		server.Close()
+		if err != nil {
+			t.Fatalf("%s complete: %v", tc.kind, err)
+		}
+		switch tc.kind {
+		case AdapterKindAnthropic:
+			if declared["type"] != "computer_20250124" || declared["display_width_px"] != float64(1024) || declared["input_schema"] != nil {
+				t.Fatalf("expected the native computer declaration, got %#v", declared)
+			}
+		case AdapterKindOpenAI:
+			fn, _ := declared["function"].(map[string]any)
+			if declared["type"] != "function" || fn["name"] != "computer" || fn["parameters"] == nil {
+				t.Fatalf("expected a function declaration, got %#v", declared)
+			}
+		}
+		coord, ok := resp.Blocks[0].Input["coordinate"].([]any)
+		if !ok || len(coord) != 2 || coord[0] != float64(10) {
+			t.Fatalf("%s: expected coordinate as a list, got %#v", tc.kind, resp.Blocks[0].Input)
+		}
+	}
+}
@@ -32,3 +32,15 @@ func TestNewJudgeFromEnvLLM(t *testing.T) {
 		t.Fatalf("expected llm judge")
 	}
 }
+
+func TestNewJudgeFromEnvRejectsBadDimensions(t *testing.T) {
+	t.Setenv("JUDGE_MODE", "llm")
+	t.Setenv("JUDGE_ROUTE", "judge-a")
+	t.Setenv("JUDGE_MODEL", "judge-model")
+	t.Setenv("JUDGE_DIMENSIONS", "accuracy:heavy")
+	if _, err := NewJudgeFromEnv([]Adapter{
+		&staticJudgeAdapter{name: "judge-a", text: "0"},
+	}, []string{"judge-a"}); err == nil {
+		t.Fatalf("expected error for bad JUDGE_DIMENSIONS")
+	}
+}
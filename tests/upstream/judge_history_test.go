@@ -0,0 +1,123 @@
+package upstream_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"ccgateway/internal/orchestrator"
+	. "ccgateway/internal/upstream"
+)
+
+func TestRouterServiceRecordsJudgeVerdicts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "judge-history.jsonl")
+	history, err := NewJudgeHistory(path)
+	if err != nil {
+		t.Fatalf("open history: %v", err)
+	}
+	svc := NewRouterService(RouterConfig{
+		DefaultRoute:        []string{"fast-short", "slow-better"},
+		Timeout:             2 * time.Second,
+		ParallelCandidates:  2,
+		EnableResponseJudge: true,
+		Judge:               NewHeuristicJudge(),
+		JudgeHistory:        history,
+	}, []Adapter{
+		&delayedTextAdapter{name: "fast-short", delay: 10 * time.Millisecond, text: "ok"},
+		&delayedTextAdapter{
+			name:  "slow-better",
+			delay: 25 * time.Millisecond,
+			text:  "This answer is more complete and contains several meaningful details.",
+		},
+	})
+	if _, err := svc.Complete(context.Background(), orchestrator.Request{
+		RunID:     "run_1",
+		Model:     "m1",
+		MaxTokens: 64,
+		Messages:  []orchestrator.Message{{Role: "user", Content: "explain"}},
+	}); err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+	if err := history.Close(); err != nil {
+		t.Fatalf("close history: %v", err)
+	}
+
+	reopened, err := NewJudgeHistory(path)
+	if err != nil {
+		t.Fatalf("reopen history: %v", err)
+	}
+	defer reopened.Close()
+	verdicts := reopened.Verdicts("run_1", 0)
+	if len(verdicts) != 1 {
+		t.Fatalf("expected 1 verdict for run_1, got %+v", verdicts)
+	}
+	v := verdicts[0]
+	if v.Winner != "slow-better" || v.Judge != "heuristic" || v.Model != "m1" || v.Rationale == "" {
+		t.Fatalf("unexpected verdict: %+v", v)
+	}
+	if len(v.Candidates) != 2 {
+		t.Fatalf("expected both candidates, got %+v", v.Candidates)
+	}
+	scores := map[string]float64{}
+	for _, c := range v.Candidates {
+		scores[c.Adapter] = c.Score
+	}
+	if scores["slow-better"] <= scores["fast-short"] {
+		t.Fatalf("expected winner to outscore loser, got %+v", scores)
+	}
+	if got := reopened.Verdicts("run_other", 0); len(got) != 0 {
+		t.Fatalf("expected no verdicts for other run, got %+v", got)
+	}
+}
+
+func TestJudgeHistoryReportWinRates(t *testing.T) {
+	history, err := NewJudgeHistory("")
+	if err != nil {
+		t.Fatalf("open history: %v", err)
+	}
+	now := time.Now().UTC()
+	record := func(at time.Time, model, winner string) {
+		loser := "a"
+		if winner == "a" {
+			loser = "b"
+		}
+		history.Record(JudgeVerdict{
+			Timestamp: at,
+			Model:     model,
+			Judge:     "heuristic",
+			Winner:    winner,
+			Candidates: []JudgeCandidate{
+				{Adapter: winner, Score: 10, LatencyMS: 100, Winner: true},
+				{Adapter: loser, Score: 4, LatencyMS: 300},
+			},
+		})
+	}
+	record(now.Add(-48*time.Hour), "m1", "b")
+	record(now.Add(-time.Hour), "m1", "a")
+	record(now.Add(-time.Hour), "m1", "a")
+	record(now.Add(-time.Hour), "m2", "b")
+
+	report := history.Report(JudgeReportQuery{Since: now.Add(-24 * time.Hour)})
+	if report.Verdicts != 3 || report.ByJudge["heuristic"] != 3 {
+		t.Fatalf("expected 3 verdicts in window, got %+v", report)
+	}
+	if len(report.ByAdapter) != 2 || report.ByAdapter[0].Adapter != "a" {
+		t.Fatalf("expected adapter a first, got %+v", report.ByAdapter)
+	}
+	a := report.ByAdapter[0]
+	if a.Contests != 3 || a.Wins != 2 || a.WinRate != 0.6667 || a.AvgScore != 8 {
+		t.Fatalf("unexpected stats for a: %+v", a)
+	}
+	if len(report.ByModel) != 4 || report.ByModel[0].Model != "m1" || report.ByModel[0].Adapter != "a" || report.ByModel[0].WinRate != 1 {
+		t.Fatalf("unexpected per-model stats: %+v", report.ByModel)
+	}
+	if len(report.Recent) != 3 {
+		t.Fatalf("expected 3 recent verdicts, got %d", len(report.Recent))
+	}
+
+	m2 := history.Report(JudgeReportQuery{Model: "m2"})
+	if m2.Verdicts != 1 || m2.ByAdapter[0].Adapter != "b" || m2.ByAdapter[0].WinRate != 1 {
+		t.Fatalf("unexpected m2 report: %+v", m2)
+	}
+}
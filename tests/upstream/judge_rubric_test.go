@@ -0,0 +1,122 @@
+package upstream_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"ccgateway/internal/orchestrator"
+	. "ccgateway/internal/upstream"
+)
+
+type recordingJudgeAdapter struct {
+	name string
+	text string
+	last orchestrator.Request
+}
+
+func (a *recordingJudgeAdapter) Name() string { return a.name }
+
+func (a *recordingJudgeAdapter) Complete(_ context.Context, req orchestrator.Request) (orchestrator.Response, error) {
+	a.last = req
+	return orchestrator.Response{
+		Model:      req.Model,
+		Blocks:     []orchestrator.AssistantBlock{{Type: "text", Text: a.text}},
+		StopReason: "end_turn",
+	}, nil
+}
+
+func TestParseJudgeDimensions(t *testing.T) {
+	dims, err := ParseJudgeDimensions("Accuracy:0.5, formatting , safety:2")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(dims) != 3 || dims[0].Name != "accuracy" || dims[0].Weight != 0.5 || dims[1].Weight != 1 || dims[2].Weight != 2 {
+		t.Fatalf("unexpected dimensions: %+v", dims)
+	}
+	for _, bad := range []string{"accuracy:x", "accuracy:-1", ":2", "accuracy,accuracy"} {
+		if _, err := ParseJudgeDimensions(bad); err == nil {
+			t.Fatalf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestLLMJudgeAppliesRubricFromMetadata(t *testing.T) {
+	adapter := &recordingJudgeAdapter{
+		name: "judge-a",
+		text: "```json\n" + `{"index":1,"scores":[{"accuracy":4,"safety":10},{"accuracy":9,"safety":6}],"reason":"b is more accurate"}` + "\n```",
+	}
+	judge, err := NewLLMJudge(LLMJudgeConfig{
+		Route: []string{"judge-a"},
+		Model: "judge-model",
+	}, []Adapter{adapter})
+	if err != nil {
+		t.Fatalf("new llm judge: %v", err)
+	}
+	req := orchestrator.Request{
+		Model: "m1",
+		Metadata: map[string]any{
+			"mode": "plan",
+			"judge_rubric": JudgeRubric{
+				Prompt: "Grade {{mode}} answers on:\n{{dimensions}}",
+				Dimensions: []JudgeDimension{
+					{Name: "accuracy", Weight: 3, Description: "facts are right"},
+					{Name: "safety", Weight: 1},
+				},
+			},
+		},
+	}
+	v, err := judge.Judge(context.Background(), req, []JudgedCandidate{
+		{AdapterName: "a1", Order: 0},
+		{AdapterName: "a2", Order: 1},
+	})
+	if err != nil {
+		t.Fatalf("judge: %v", err)
+	}
+	if v.Index != 1 || v.Rationale != "b is more accurate" {
+		t.Fatalf("unexpected verdict: %+v", v)
+	}
+	// (3*4 + 1*10) / 4 and (3*9 + 1*6) / 4
+	if len(v.Scores) != 2 || v.Scores[0] != 5.5 || v.Scores[1] != 8.25 {
+		t.Fatalf("unexpected weighted scores: %+v", v.Scores)
+	}
+	if v.Dimensions[1]["accuracy"] != 9 || v.Dimensions[0]["safety"] != 10 {
+		t.Fatalf("unexpected dimension scores: %+v", v.Dimensions)
+	}
+	system, _ := adapter.last.System.(string)
+	if !strings.Contains(system, "Grade plan answers") || !strings.Contains(system, "- accuracy (weight 3): facts are right") {
+		t.Fatalf("rubric template not applied: %q", system)
+	}
+	if adapter.last.MaxTokens < 512 {
+		t.Fatalf("expected room for dimension scores, got max_tokens=%d", adapter.last.MaxTokens)
+	}
+	if prompt := adapter.last.Messages[0].Content.(string); !strings.Contains(prompt, `"scores"`) {
+		t.Fatalf("expected the prompt to ask for scores: %q", prompt)
+	}
+}
+
+func TestLLMJudgeRubricFallsBackToIndexReply(t *testing.T) {
+	adapter := &recordingJudgeAdapter{name: "judge-a", text: "0"}
+	judge, err := NewLLMJudge(LLMJudgeConfig{
+		Route:  []string{"judge-a"},
+		Model:  "judge-model",
+		Rubric: JudgeRubric{Dimensions: []JudgeDimension{{Name: "accuracy", Weight: 1}}},
+	}, []Adapter{adapter})
+	if err != nil {
+		t.Fatalf("new llm judge: %v", err)
+	}
+	v, err := judge.Judge(context.Background(), orchestrator.Request{Model: "m1"}, []JudgedCandidate{
+		{AdapterName: "a1"},
+		{AdapterName: "a2", Order: 1},
+	})
+	if err != nil {
+		t.Fatalf("judge: %v", err)
+	}
+	if v.Index != 0 || v.Scores[0] != 1 || v.Scores[1] != 0 {
+		t.Fatalf("unexpected verdict: %+v", v)
+	}
+	system, _ := adapter.last.System.(string)
+	if !strings.Contains(system, "candidate answers to a chat request") || !strings.Contains(system, "- accuracy (weight 1)") {
+		t.Fatalf("expected the default rubric prompt, got %q", system)
+	}
+}
@@ -82,3 +82,28 @@ func TestParseJudgeIndex(t *testing.T) {
 		t.Fatalf("expected 0, got %d", idx)
 	}
 }
+
+func TestLLMJudgeVerdictUsesReason(t *testing.T) {
+	judge, err := NewLLMJudge(LLMJudgeConfig{
+		Route: []string{"judge-a"},
+		Model: "judge-model",
+	}, []Adapter{
+		&staticJudgeAdapter{name: "judge-a", text: `{"index":1,"reason":"second answer covers the question"}`},
+	})
+	if err != nil {
+		t.Fatalf("new llm judge: %v", err)
+	}
+	v, err := judge.Judge(context.Background(), orchestrator.Request{Model: "m1"}, []JudgedCandidate{
+		{AdapterName: "a1", Order: 0},
+		{AdapterName: "a2", Order: 1},
+	})
+	if err != nil {
+		t.Fatalf("judge: %v", err)
+	}
+	if v.Index != 1 || len(v.Scores) != 2 || v.Scores[1] != 1 || v.Scores[0] != 0 {
+		t.Fatalf("unexpected verdict: %+v", v)
+	}
+	if v.Rationale != "second answer covers the question" {
+		t.Fatalf("unexpected rationale: %q", v.Rationale)
+	}
+}
@@ -0,0 +1,89 @@
+package upstream_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"ccgateway/internal/orchestrator"
+	"ccgateway/internal/scheduler"
+	. "ccgateway/internal/upstream"
+)
+
+func aliasGroupRouter(selector CandidateSelector) *RouterService {
+	return NewRouterService(RouterConfig{
+		Routes: map[string][]string{
+			"provider-a/large":  {"a"},
+			"provider-b/medium": {"b"},
+		},
+		Timeout:  2 * time.Second,
+		Selector: selector,
+	}, []Adapter{
+		NewMockAdapter("a", true),
+		NewMockAdapter("b", false),
+	})
+}
+
+func aliasGroupRequest() orchestrator.Request {
+	return orchestrator.Request{
+		Model:     "provider-a/large",
+		MaxTokens: 64,
+		Messages:  []orchestrator.Message{{Role: "user", Content: "hello"}},
+		Metadata:  map[string]any{"model_fallbacks": []string{"provider-b/medium"}},
+	}
+}
+
+func TestRouterServiceFallsBackAcrossAliasGroup(t *testing.T) {
+	svc := aliasGroupRouter(nil)
+	var seen []ModelTarget
+	ctx := WithModelTargetObserver(context.Background(), func(t ModelTarget) { seen = append(seen, t) })
+
+	resp, err := svc.Complete(ctx, aliasGroupRequest())
+	if err != nil {
+		t.Fatalf("expected fallback target to serve, got %v", err)
+	}
+	if resp.Trace.Provider != "b" || resp.Trace.Model != "provider-b/medium" || !resp.Trace.FallbackUsed {
+		t.Fatalf("unexpected trace: %+v", resp.Trace)
+	}
+	if len(seen) != 1 || seen[0].Model != "provider-b/medium" || seen[0].Index != 1 || seen[0].LastError == "" {
+		t.Fatalf("unexpected observed targets: %+v", seen)
+	}
+
+	seen = nil
+	events, errs := svc.Stream(ctx, aliasGroupRequest())
+	var text string
+	for ev := range events {
+		text += ev.DeltaText
+	}
+	if err := <-errs; err != nil {
+		t.Fatalf("unexpected stream error: %v", err)
+	}
+	if text == "" || len(seen) != 1 || seen[0].Index != 1 {
+		t.Fatalf("expected stream served by second target, text=%q seen=%+v", text, seen)
+	}
+}
+
+func TestRouterServiceSkipsCooledDownTarget(t *testing.T) {
+	engine := scheduler.NewEngine(scheduler.Config{FailureThreshold: 1, Cooldown: time.Minute}, []string{"a", "b"})
+	engine.ObserveFailure("a", "provider-a/large", context.DeadlineExceeded)
+	svc := aliasGroupRouter(engine)
+	var seen []ModelTarget
+	ctx := WithModelTargetObserver(context.Background(), func(t ModelTarget) { seen = append(seen, t) })
+
+	if _, err := svc.Complete(ctx, aliasGroupRequest()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(seen) != 1 || seen[0].Index != 1 || !strings.Contains(seen[0].LastError, "cooling down") {
+		t.Fatalf("expected cooled-down target to be skipped, got %+v", seen)
+	}
+
+	// Without fallbacks the request is not reported.
+	seen = nil
+	req := aliasGroupRequest()
+	req.Model = "provider-b/medium"
+	req.Metadata = nil
+	if _, err := svc.Complete(ctx, req); err != nil || len(seen) != 0 {
+		t.Fatalf("expected plain request unreported, err=%v seen=%+v", err, seen)
+	}
+}
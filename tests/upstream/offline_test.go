@@ -0,0 +1,83 @@
+package upstream_test
+
+import (
+	. "ccgateway/internal/upstream"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"ccgateway/internal/orchestrator"
+)
+
+func TestOfflineModeReplaysFixturesAndSurvivesConfigApply(t *testing.T) {
+	fixturesPath := filepath.Join(t.TempDir(), "fixtures.json")
+	if err := os.WriteFile(fixturesPath, []byte(`[
+		{"name":"weather","contains":"weather","tool_use":{"name":"get_weather","input":{"city":"Paris"}}},
+		{"name":"broken","adapter":"primary","contains":"explode","error":"rate limited"},
+		{"name":"opus","model":"claude-opus-*","text":"canned opus answer"}
+	]`), 0o644); err != nil {
+		t.Fatalf("write fixtures: %v", err)
+	}
+	t.Setenv("OFFLINE_MODE", "true")
+	t.Setenv("OFFLINE_FIXTURES_PATH", fixturesPath)
+	offline, err := OfflineModeFromEnv()
+	if err != nil || offline == nil {
+		t.Fatalf("offline mode from env: %v", err)
+	}
+	adapters, err := offline.BuildAdapters([]AdapterSpec{{
+		Name:    "primary",
+		Kind:    AdapterKindOpenAI,
+		BaseURL: "http://127.0.0.1:1",
+		Model:   "gpt-4o",
+	}})
+	if err != nil {
+		t.Fatalf("build offline adapters: %v", err)
+	}
+	svc := NewRouterService(RouterConfig{
+		DefaultRoute: []string{"primary"},
+		Timeout:      time.Second,
+		Offline:      offline,
+	}, adapters)
+
+	complete := func(model, text string) (orchestrator.Response, error) {
+		return svc.Complete(context.Background(), orchestrator.Request{
+			Model:    model,
+			Messages: []orchestrator.Message{{Role: "user", Content: text}},
+		})
+	}
+	resp, err := complete("claude-opus-4", "hello")
+	if err != nil || len(resp.Blocks) != 1 || resp.Blocks[0].Text != "canned opus answer" {
+		t.Fatalf("expected model fixture, got %+v err=%v", resp, err)
+	}
+	resp, err = complete("any", "what's the WEATHER like")
+	if err != nil || resp.StopReason != "tool_use" || resp.Blocks[0].Name != "get_weather" {
+		t.Fatalf("expected tool_use fixture, got %+v err=%v", resp, err)
+	}
+	if _, err := complete("any", "please explode"); err == nil || !strings.Contains(err.Error(), "rate limited") {
+		t.Fatalf("expected fixture error, got %v", err)
+	}
+	resp, err = complete("any", "unmatched")
+	if err != nil || !strings.Contains(resp.Blocks[0].Text, "[primary]") {
+		t.Fatalf("expected mock fallback, got %+v err=%v", resp, err)
+	}
+
+	cfg := svc.GetUpstreamConfig()
+	if !cfg.Offline || len(cfg.Adapters) != 1 || cfg.Adapters[0].BaseURL != "http://127.0.0.1:1" {
+		t.Fatalf("expected offline flag with original spec, got %+v", cfg)
+	}
+	cfg.Adapters = append(cfg.Adapters, AdapterSpec{Name: "secondary", Kind: AdapterKindAnthropic, BaseURL: "https://api.example.invalid"})
+	cfg.DefaultRoute = []string{"secondary"}
+	if _, err := svc.UpdateUpstreamConfig(cfg); err != nil {
+		t.Fatalf("apply config offline: %v", err)
+	}
+	resp, err = complete("any", "after apply")
+	if err != nil || !strings.Contains(resp.Blocks[0].Text, "[secondary]") {
+		t.Fatalf("expected applied adapter to stay offline, got %+v err=%v", resp, err)
+	}
+	if status := svc.OfflineStatus(); !status.Enabled || status.Fixtures != 3 {
+		t.Fatalf("unexpected offline status: %+v", status)
+	}
+}
@@ -5,7 +5,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -340,3 +343,98 @@ func TestRouterServiceUpdateUpstreamConfig(t *testing.T) {
 		t.Fatalf("unexpected response after update: %+v", resp)
 	}
 }
+
+func TestRouterServiceUpstreamApplyDrainsReplacedAdapters(t *testing.T) {
+	unblock := make(chan struct{})
+	var unblockOnce sync.Once
+	release := func() { unblockOnce.Do(func() { close(unblock) }) }
+	entered := make(chan struct{}, 1)
+	oldServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entered <- struct{}{}
+		<-unblock
+		w.Header().Set("content-type", "application/json")
+		_, _ = w.Write([]byte(`{"blocks":[{"type":"text","text":"old"}],"stop_reason":"end_turn"}`))
+	}))
+	defer oldServer.Close()
+	defer release()
+	newServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", "application/json")
+		_, _ = w.Write([]byte(`{"blocks":[{"type":"text","text":"new"}],"stop_reason":"end_turn"}`))
+	}))
+	defer newServer.Close()
+
+	initial, err := BuildAdaptersFromSpecs([]AdapterSpec{
+		{Name: "a", Kind: AdapterKindCanonical, BaseURL: oldServer.URL},
+		{Name: "b", Kind: AdapterKindCanonical, BaseURL: newServer.URL},
+	})
+	if err != nil {
+		t.Fatalf("build adapters: %v", err)
+	}
+	svc := NewRouterService(RouterConfig{DefaultRoute: []string{"a"}, DrainTimeout: 5 * time.Second}, initial)
+	req := orchestrator.Request{Model: "m", MaxTokens: 8, Messages: []orchestrator.Message{{Role: "user", Content: "hi"}}}
+
+	inflight := make(chan orchestrator.Response, 1)
+	go func() {
+		resp, err := svc.Complete(context.Background(), req)
+		if err != nil {
+			t.Errorf("in-flight call failed: %v", err)
+		}
+		inflight <- resp
+	}()
+	<-entered
+
+	current := svc.GetUpstreamConfig().Adapters
+	if _, err := svc.UpdateUpstreamConfig(UpstreamAdminConfig{
+		Adapters: []AdapterSpec{
+			{Name: "a", Kind: AdapterKindCanonical, BaseURL: newServer.URL},
+			current[1],
+		},
+		DefaultRoute: []string{"a"},
+	}); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+
+	status := svc.ApplyStatus()
+	if status.Version != 2 {
+		t.Fatalf("expected version 2, got %d", status.Version)
+	}
+	byKey := map[string]AdapterApplyStatus{}
+	for _, a := range status.Adapters {
+		byKey[fmt.Sprintf("%s/%s", a.Name, a.State)] = a
+	}
+	if got := byKey["a/active"]; got.Action != ApplyActionReplaced || got.Version != 2 {
+		t.Fatalf("expected replaced active adapter a, got %+v", status.Adapters)
+	}
+	if got := byKey["a/draining"]; got.InFlight != 1 {
+		t.Fatalf("expected old adapter a draining with 1 in-flight call, got %+v", status.Adapters)
+	}
+	if got := byKey["b/active"]; got.Action != ApplyActionUnchanged || got.Version != 1 {
+		t.Fatalf("expected adapter b kept unchanged, got %+v", status.Adapters)
+	}
+
+	resp, err := svc.Complete(context.Background(), req)
+	if err != nil || len(resp.Blocks) == 0 || resp.Blocks[0].Text != "new" {
+		t.Fatalf("expected new traffic on new adapter set, got %+v err=%v", resp.Blocks, err)
+	}
+
+	release()
+	if resp := <-inflight; len(resp.Blocks) == 0 || resp.Blocks[0].Text != "old" {
+		t.Fatalf("expected in-flight call to finish on old adapter, got %+v", resp.Blocks)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		closed := false
+		for _, a := range svc.ApplyStatus().Adapters {
+			if a.Name == "a" && a.State == AdapterStateClosed && a.InFlight == 0 {
+				closed = true
+			}
+		}
+		if closed {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected drained adapter to be closed, got %+v", svc.ApplyStatus().Adapters)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
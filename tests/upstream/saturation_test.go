@@ -0,0 +1,62 @@
+package upstream_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"ccgateway/internal/orchestrator"
+	. "ccgateway/internal/upstream"
+)
+
+type statusAdapter struct {
+	name   string
+	status int
+}
+
+func (a statusAdapter) Name() string { return a.name }
+
+func (a statusAdapter) Complete(_ context.Context, req orchestrator.Request) (orchestrator.Response, error) {
+	if a.status != 0 {
+		return orchestrator.Response{}, fmt.Errorf("adapter %s upstream status %d: busy", a.name, a.status)
+	}
+	return orchestrator.Response{Model: req.Model, Blocks: []orchestrator.AssistantBlock{{Type: "text", Text: "ok"}}}, nil
+}
+
+func TestSaturationStatus(t *testing.T) {
+	cases := map[error]int{
+		nil: 0,
+		errors.New("upstream status 429: slow down"):                                       429,
+		fmt.Errorf("wrapped: %w", errors.New("adapter a upstream status 529: overloaded")): 529,
+		errors.New("upstream status 500: boom"):                                            0,
+		errors.New("connection refused"):                                                   0,
+	}
+	for err, want := range cases {
+		if got := SaturationStatus(err); got != want {
+			t.Fatalf("SaturationStatus(%v) = %d, want %d", err, got, want)
+		}
+	}
+}
+
+func TestRouterServiceReportsEveryAdapterAttempt(t *testing.T) {
+	svc := NewRouterService(RouterConfig{
+		DefaultRoute: []string{"busy", "ok"},
+		Retries:      1,
+	}, []Adapter{statusAdapter{name: "busy", status: 429}, statusAdapter{name: "ok"}})
+	var saturated, succeeded int
+	svc.OnUpstreamAttempt(func(adapter string, err error) {
+		switch {
+		case err == nil:
+			succeeded++
+		case SaturationStatus(err) != 0:
+			saturated++
+		}
+	})
+	if _, err := svc.Complete(context.Background(), orchestrator.Request{Model: "m"}); err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+	if saturated != 2 || succeeded != 1 {
+		t.Fatalf("expected 2 saturated attempts and 1 success, got %d and %d", saturated, succeeded)
+	}
+}
@@ -158,6 +158,85 @@ printf '%s\n' '{"response":{"model":"script-fallback","text":"fallback text","st
 	}
 }
 
+func TestScriptAdapterPersistentJSONRPC(t *testing.T) {
+	script := writeScript(t, `#!/bin/sh
+while IFS= read -r line; do
+  id=$(printf '%s' "$line" | sed -n 's/^{"jsonrpc":"2.0","id":\([0-9]*\),.*/\1/p')
+  [ -n "$id" ] || continue
+  case "$line" in
+    *'"method":"stream"'*)
+      printf '{"jsonrpc":"2.0","method":"event","params":{"id":%s,"event":{"type":"message_start"}}}\n' "$id"
+      printf '{"jsonrpc":"2.0","method":"event","params":{"id":%s,"event":{"type":"content_block_delta","delta_text":"pid-%s"}}}\n' "$id" "$$"
+      printf '{"jsonrpc":"2.0","method":"event","params":{"id":%s,"event":{"type":"message_stop"}}}\n' "$id"
+      printf '{"jsonrpc":"2.0","id":%s,"result":null}\n' "$id"
+      ;;
+    *'"content":"fail"'*)
+      printf '{"jsonrpc":"2.0","id":%s,"error":{"code":-32000,"message":"boom"}}\n' "$id"
+      ;;
+    *)
+      printf '{"jsonrpc":"2.0","id":%s,"result":{"text":"pid-%s","usage":{"input_tokens":1,"output_tokens":1}}}\n' "$id" "$$"
+      ;;
+  esac
+done
+`)
+	adapter, err := NewScriptAdapter(ScriptAdapterConfig{Name: "script-rpc", Command: script, Persistent: true})
+	if err != nil {
+		t.Fatalf("new script adapter failed: %v", err)
+	}
+	defer adapter.Close()
+	req := func(text string) orchestrator.Request {
+		return orchestrator.Request{Model: "m", MaxTokens: 16, Messages: []orchestrator.Message{{Role: "user", Content: text}}}
+	}
+
+	first, err := adapter.Complete(context.Background(), req("hello"))
+	if err != nil {
+		t.Fatalf("complete failed: %v", err)
+	}
+	second, err := adapter.Complete(context.Background(), req("again"))
+	if err != nil {
+		t.Fatalf("second complete failed: %v", err)
+	}
+	if len(first.Blocks) != 1 || !strings.HasPrefix(first.Blocks[0].Text, "pid-") || first.Model != "m" {
+		t.Fatalf("unexpected response: %+v", first)
+	}
+	if second.Blocks[0].Text != first.Blocks[0].Text {
+		t.Fatalf("expected one process to serve both calls, got %q and %q", first.Blocks[0].Text, second.Blocks[0].Text)
+	}
+
+	events, errs := adapter.Stream(context.Background(), req("stream"))
+	var text strings.Builder
+	count := 0
+	for ev := range events {
+		count++
+		text.WriteString(ev.DeltaText)
+	}
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("stream failed: %v", err)
+		}
+	}
+	if count != 3 || text.String() != first.Blocks[0].Text {
+		t.Fatalf("unexpected stream: %d events, text %q", count, text.String())
+	}
+
+	if _, err := adapter.Complete(context.Background(), req("fail")); err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Fatalf("expected the rpc error, got %v", err)
+	}
+
+	// A closed process is restarted on the next call.
+	_ = adapter.Close()
+	third, err := adapter.Complete(context.Background(), req("hello"))
+	if err != nil {
+		t.Fatalf("complete after restart failed: %v", err)
+	}
+	if third.Blocks[0].Text == first.Blocks[0].Text {
+		t.Fatalf("expected a new process after close")
+	}
+	if spec := adapter.AdminSpec(); !spec.Persistent {
+		t.Fatalf("expected persistent in admin spec: %+v", spec)
+	}
+}
+
 func writeScript(t *testing.T, content string) string {
 	t.Helper()
 	dir := t.TempDir()
@@ -0,0 +1,194 @@
+package upstream_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"ccgateway/internal/orchestrator"
+	. "ccgateway/internal/upstream"
+)
+
+type textAdapter struct {
+	name string
+	text string
+	err  error
+}
+
+func (a textAdapter) Name() string { return a.name }
+
+func (a textAdapter) Complete(_ context.Context, req orchestrator.Request) (orchestrator.Response, error) {
+	if a.err != nil {
+		return orchestrator.Response{}, a.err
+	}
+	return orchestrator.Response{
+		Model:      req.Model,
+		Blocks:     []orchestrator.AssistantBlock{{Type: "text", Text: a.text}},
+		StopReason: "end_turn",
+		Usage:      orchestrator.Usage{InputTokens: 4, OutputTokens: 40},
+	}, nil
+}
+
+// passthroughStreamAdapter streams raw Anthropic events.
+type passthroughStreamAdapter struct{ textAdapter }
+
+func (a passthroughStreamAdapter) Stream(_ context.Context, _ orchestrator.Request) (<-chan orchestrator.StreamEvent, <-chan error) {
+	raw := []string{
+		`{"type":"message_start","message":{"usage":{"input_tokens":4}}}`,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"text"}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"` + a.text + `"}}`,
+		`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":3}}`,
+		`{"type":"message_stop"}`,
+	}
+	events := make(chan orchestrator.StreamEvent, len(raw))
+	errs := make(chan error)
+	for _, data := range raw {
+		events <- orchestrator.StreamEvent{PassThrough: true, RawData: []byte(data)}
+	}
+	close(events)
+	close(errs)
+	return events, errs
+}
+
+type recordingJudge struct{ seen chan []JudgedCandidate }
+
+func (j recordingJudge) Select(_ context.Context, _ orchestrator.Request, candidates []JudgedCandidate) (int, error) {
+	j.seen <- candidates
+	return len(candidates) - 1, nil
+}
+
+func waitShadow(t *testing.T, ch <-chan ShadowResult) ShadowResult {
+	t.Helper()
+	select {
+	case res := <-ch:
+		return res
+	case <-time.After(2 * time.Second):
+		t.Fatalf("timed out waiting for shadow result")
+	}
+	return ShadowResult{}
+}
+
+func TestCompleteMirrorsToShadowWithoutChangingResponse(t *testing.T) {
+	svc := NewRouterService(RouterConfig{DefaultRoute: []string{"primary"}}, []Adapter{
+		textAdapter{name: "primary", text: "short"},
+		textAdapter{name: "candidate", text: strings.Repeat("a much more thorough answer ", 20)},
+		textAdapter{name: "broken", err: errors.New("boom")},
+	})
+	results := make(chan ShadowResult, 4)
+	svc.OnShadowResult(func(res ShadowResult) { results <- res })
+
+	resp, err := svc.Complete(context.Background(), orchestrator.Request{
+		RunID:    "run_1",
+		Model:    "claude-test",
+		Metadata: map[string]any{"shadow_adapters": []any{"candidate", "broken", "primary"}},
+	})
+	if err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+	if resp.Trace.Provider != "primary" || resp.Blocks[0].Text != "short" {
+		t.Fatalf("client must get the primary answer: %+v", resp)
+	}
+
+	got := map[string]ShadowResult{}
+	for i := 0; i < 2; i++ {
+		res := waitShadow(t, results)
+		got[res.Adapter] = res
+	}
+	if _, ok := got["primary"]; ok {
+		t.Fatalf("the primary adapter must not shadow itself")
+	}
+	if got["broken"].Err == nil || got["candidate"].Err != nil || got["candidate"].Primary != "primary" {
+		t.Fatalf("unexpected shadow results: %+v", got)
+	}
+	select {
+	case extra := <-results:
+		t.Fatalf("unexpected extra shadow result: %+v", extra)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestShadowResultIsJudgedAgainstPrimary(t *testing.T) {
+	judge := recordingJudge{seen: make(chan []JudgedCandidate, 1)}
+	svc := NewRouterService(RouterConfig{DefaultRoute: []string{"primary"}, Judge: judge}, []Adapter{
+		textAdapter{name: "primary", text: "short"},
+		textAdapter{name: "candidate", text: "longer answer"},
+	})
+	results := make(chan ShadowResult, 1)
+	svc.OnShadowResult(func(res ShadowResult) { results <- res })
+	if _, err := svc.Complete(context.Background(), orchestrator.Request{
+		Model:    "claude-test",
+		Metadata: map[string]any{"shadow_adapters": []string{"candidate"}, "routing_adapter_route": []string{"primary"}},
+	}); err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+	res := waitShadow(t, results)
+	if res.Err != nil || !res.Judged || !res.ShadowWon || res.Primary != "primary" {
+		t.Fatalf("unexpected shadow result: %+v", res)
+	}
+	if res.Request.Metadata["shadow"] != true || res.Request.Metadata["routing_adapter_route"] != nil {
+		t.Fatalf("shadow request should be marked and unrouted: %+v", res.Request.Metadata)
+	}
+	candidates := <-judge.seen
+	if len(candidates) != 2 || candidates[0].AdapterName != "primary" || candidates[1].AdapterName != "candidate" {
+		t.Fatalf("unexpected judged candidates: %+v", candidates)
+	}
+}
+
+func TestStreamMirrorsCollectedResponse(t *testing.T) {
+	judge := recordingJudge{seen: make(chan []JudgedCandidate, 1)}
+	svc := NewRouterService(RouterConfig{DefaultRoute: []string{"primary"}, Judge: judge}, []Adapter{
+		passthroughStreamAdapter{textAdapter{name: "primary", text: "streamed"}},
+		textAdapter{name: "candidate", text: "shadow"},
+	})
+	results := make(chan ShadowResult, 1)
+	svc.OnShadowResult(func(res ShadowResult) { results <- res })
+	events, errs := svc.Stream(context.Background(), orchestrator.Request{
+		Model:    "claude-test",
+		Metadata: map[string]any{"shadow_adapters": []string{"candidate"}},
+	})
+	for range events {
+	}
+	for err := range errs {
+		t.Fatalf("stream error: %v", err)
+	}
+	if res := waitShadow(t, results); res.Err != nil || res.Adapter != "candidate" {
+		t.Fatalf("unexpected shadow result: %+v", res)
+	}
+	candidates := <-judge.seen
+	primary := candidates[0].Response
+	if len(primary.Blocks) != 1 || primary.Blocks[0].Text != "streamed" || primary.StopReason != "end_turn" || primary.Usage.OutputTokens != 3 {
+		t.Fatalf("primary stream not reconstructed: %+v", primary)
+	}
+}
+
+func TestNoMirroringWithoutListener(t *testing.T) {
+	calls := 0
+	svc := NewRouterService(RouterConfig{DefaultRoute: []string{"primary"}}, []Adapter{
+		textAdapter{name: "primary", text: "ok"},
+		countingAdapter{name: "candidate", calls: &calls},
+	})
+	if _, err := svc.Complete(context.Background(), orchestrator.Request{
+		Model:    "claude-test",
+		Metadata: map[string]any{"shadow_adapters": []string{"candidate"}},
+	}); err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if calls != 0 {
+		t.Fatalf("shadow adapter called without a listener")
+	}
+}
+
+type countingAdapter struct {
+	name  string
+	calls *int
+}
+
+func (a countingAdapter) Name() string { return a.name }
+
+func (a countingAdapter) Complete(_ context.Context, req orchestrator.Request) (orchestrator.Response, error) {
+	*a.calls++
+	return orchestrator.Response{Model: req.Model}, nil
+}
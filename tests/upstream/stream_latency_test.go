@@ -0,0 +1,83 @@
+package upstream_test
+
+import (
+	. "ccgateway/internal/upstream"
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"ccgateway/internal/orchestrator"
+)
+
+// pacedStreamAdapter waits before its first delta, then streams the rest.
+type pacedStreamAdapter struct {
+	name      string
+	firstWait time.Duration
+}
+
+func (a *pacedStreamAdapter) Name() string { return a.name }
+
+func (a *pacedStreamAdapter) Complete(_ context.Context, req orchestrator.Request) (orchestrator.Response, error) {
+	return orchestrator.Response{Model: req.Model, Blocks: []orchestrator.AssistantBlock{{Type: "text", Text: "ok"}}}, nil
+}
+
+func (a *pacedStreamAdapter) Stream(_ context.Context, _ orchestrator.Request) (<-chan orchestrator.StreamEvent, <-chan error) {
+	events := make(chan orchestrator.StreamEvent, 8)
+	errs := make(chan error, 1)
+	go func() {
+		defer close(events)
+		defer close(errs)
+		events <- orchestrator.StreamEvent{Type: "message_start"}
+		time.Sleep(a.firstWait)
+		events <- orchestrator.StreamEvent{Type: "content_block_delta", DeltaText: "hello"}
+		time.Sleep(20 * time.Millisecond)
+		events <- orchestrator.StreamEvent{Type: "content_block_delta", DeltaText: " world"}
+		events <- orchestrator.StreamEvent{Type: "message_delta", StopReason: "end_turn", Usage: orchestrator.Usage{OutputTokens: 10}}
+		events <- orchestrator.StreamEvent{Type: "message_stop"}
+	}()
+	return events, errs
+}
+
+type latencySelector struct {
+	fixedSelector
+	mu         sync.Mutex
+	firstToken map[string]time.Duration
+}
+
+func (s *latencySelector) ObserveStreamLatency(adapter, _ string, firstToken time.Duration, _ float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.firstToken[adapter] = firstToken
+}
+
+func TestRouterServiceTracksStreamLatency(t *testing.T) {
+	selector := &latencySelector{fixedSelector: fixedSelector{order: []string{"paced"}}, firstToken: map[string]time.Duration{}}
+	svc := NewRouterService(RouterConfig{DefaultRoute: []string{"paced"}, Selector: selector}, []Adapter{
+		&pacedStreamAdapter{name: "paced", firstWait: 40 * time.Millisecond},
+	})
+	for i := 0; i < 2; i++ {
+		events, errs := svc.Stream(context.Background(), orchestrator.Request{Model: "m", Messages: []orchestrator.Message{{Role: "user", Content: "hi"}}})
+		for range events {
+		}
+		if err := <-errs; err != nil {
+			t.Fatalf("stream: %v", err)
+		}
+	}
+
+	status := svc.StreamLatencyStatus()
+	if len(status) != 1 || status[0].Adapter != "paced" || status[0].Samples != 2 {
+		t.Fatalf("unexpected latency status %#v", status)
+	}
+	if ttft := status[0].FirstTokenMS; ttft.P50 < 40 || ttft.P99 < ttft.P50 {
+		t.Fatalf("expected time to first token of at least 40ms, got %#v", ttft)
+	}
+	if tps := status[0].TokensPerSecond.P50; tps <= 0 {
+		t.Fatalf("expected a tokens-per-second figure, got %v", tps)
+	}
+	selector.mu.Lock()
+	defer selector.mu.Unlock()
+	if selector.firstToken["paced"] < 40*time.Millisecond {
+		t.Fatalf("expected the selector to hear about the stream, got %v", selector.firstToken)
+	}
+}
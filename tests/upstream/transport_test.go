@@ -0,0 +1,85 @@
+package upstream_test
+
+import (
+	. "ccgateway/internal/upstream"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ccgateway/internal/orchestrator"
+)
+
+func newAnthropicStub(t *testing.T, closeConn bool) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if closeConn {
+			w.Header().Set("connection", "close")
+		}
+		w.Header().Set("content-type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"msg_1","type":"message","role":"assistant","model":"m","content":[{"type":"text","text":"ok"}],"stop_reason":"end_turn","usage":{"input_tokens":1,"output_tokens":1}}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func completeN(t *testing.T, adapter *HTTPAdapter, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		if _, err := adapter.Complete(context.Background(), orchestrator.Request{Model: "m", MaxTokens: 8, Messages: []orchestrator.Message{{Role: "user", Content: "hi"}}}); err != nil {
+			t.Fatalf("complete: %v", err)
+		}
+	}
+}
+
+func TestHTTPAdapterReportsConnectionReuse(t *testing.T) {
+	srv := newAnthropicStub(t, false)
+	adapter, err := NewHTTPAdapter(HTTPAdapterConfig{
+		Name:      "pooled",
+		Kind:      AdapterKindAnthropic,
+		BaseURL:   srv.URL,
+		Transport: &TransportSpec{MaxIdleConnsPerHost: 8, IdleConnTimeoutMS: 60000, ProxyURL: "direct"},
+	}, nil)
+	if err != nil {
+		t.Fatalf("new adapter: %v", err)
+	}
+	defer adapter.Close()
+	completeN(t, adapter, 3)
+
+	stats := adapter.ConnectionStats()
+	if stats.Requests != 3 || stats.NewConns != 1 || stats.ReusedConns != 2 || stats.OpenConns != 1 {
+		t.Fatalf("expected one connection reused twice, got %+v", stats)
+	}
+	if stats.ReuseRatio < 0.66 || stats.ReuseRatio > 0.67 || !stats.HTTP2 {
+		t.Fatalf("unexpected ratio or protocol %+v", stats)
+	}
+	if spec := adapter.AdminSpec(); spec.Transport == nil || spec.Transport.MaxIdleConnsPerHost != 8 {
+		t.Fatalf("expected transport in admin spec, got %+v", spec.Transport)
+	}
+}
+
+func TestHTTPAdapterDNSCacheAndProxyValidation(t *testing.T) {
+	srv := newAnthropicStub(t, true)
+	adapter, err := NewHTTPAdapter(HTTPAdapterConfig{
+		Name:      "cached",
+		Kind:      AdapterKindAnthropic,
+		BaseURL:   strings.Replace(srv.URL, "127.0.0.1", "localhost", 1),
+		Transport: &TransportSpec{DNSCacheTTLMS: 60000, DisableHTTP2: true, ProxyURL: "direct"},
+	}, nil)
+	if err != nil {
+		t.Fatalf("new adapter: %v", err)
+	}
+	defer adapter.Close()
+	completeN(t, adapter, 3)
+
+	stats := adapter.ConnectionStats()
+	if stats.NewConns != 3 || stats.DNSCacheMisses != 1 || stats.DNSCacheHits != 2 || stats.HTTP2 {
+		t.Fatalf("expected each closed connection to redial from the cache, got %+v", stats)
+	}
+
+	_, err = BuildAdapterFromSpec(AdapterSpec{Name: "bad", Kind: AdapterKindOpenAI, BaseURL: srv.URL, Transport: &TransportSpec{ProxyURL: "ftp://proxy:21"}})
+	if err == nil || !strings.Contains(err.Error(), "proxy_url") {
+		t.Fatalf("expected proxy_url to be rejected, got %v", err)
+	}
+}
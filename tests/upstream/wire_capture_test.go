@@ -0,0 +1,72 @@
+package upstream_test
+
+import (
+	. "ccgateway/internal/upstream"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ccgateway/internal/orchestrator"
+)
+
+func TestHTTPAdapterCapturesRedactedWirePayloads(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", "text/event-stream")
+		_, _ = w.Write([]byte("event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"id\":\"m1\"}}\n\n" +
+			"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"hi\"}}\n\n" +
+			"event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n"))
+	}))
+	defer srv.Close()
+	adapter, err := NewHTTPAdapter(HTTPAdapterConfig{
+		Name:    "wire",
+		Kind:    AdapterKindAnthropic,
+		BaseURL: srv.URL,
+		APIKey:  "sk-secret-value",
+		Headers: map[string]string{"x-trace": "keep-me"},
+	}, nil)
+	if err != nil {
+		t.Fatalf("new adapter: %v", err)
+	}
+
+	var captured []WireExchange
+	ctx := WithWireObserver(context.Background(), 0, func(ex WireExchange) {
+		captured = append(captured, ex)
+	})
+	events, errs := adapter.Stream(ctx, orchestrator.Request{Model: "m", MaxTokens: 16, Messages: []orchestrator.Message{{Role: "user", Content: "hello"}}})
+	for range events {
+	}
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("stream: %v", err)
+		}
+	}
+
+	if len(captured) != 1 {
+		t.Fatalf("expected one exchange, got %d", len(captured))
+	}
+	ex := captured[0]
+	if ex.Adapter != "wire" || ex.Method != http.MethodPost || ex.Status != http.StatusOK {
+		t.Fatalf("unexpected exchange %+v", ex)
+	}
+	if ex.RequestHeaders["x-api-key"] != "[REDACTED]" || ex.RequestHeaders["x-trace"] != "keep-me" {
+		t.Fatalf("expected the key redacted and other headers kept, got %v", ex.RequestHeaders)
+	}
+	if !strings.Contains(string(ex.RequestBody), `"stream":true`) || !strings.Contains(string(ex.RequestBody), "hello") {
+		t.Fatalf("expected the converted request body, got %s", ex.RequestBody)
+	}
+	if strings.Contains(string(ex.RequestBody), "sk-secret-value") {
+		t.Fatalf("api key leaked into the capture")
+	}
+	if !strings.Contains(string(ex.ResponseBody), "event: message_stop") || ex.Truncated {
+		t.Fatalf("expected the raw stream, got %q (truncated=%v)", ex.ResponseBody, ex.Truncated)
+	}
+
+	// Without an observer nothing is captured.
+	captured = nil
+	_, _ = adapter.Complete(context.Background(), orchestrator.Request{Model: "m", MaxTokens: 16, Messages: []orchestrator.Message{{Role: "user", Content: "hi"}}})
+	if len(captured) != 0 {
+		t.Fatalf("expected no capture without an observer")
+	}
+}
@@ -0,0 +1,139 @@
+package webhook_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"ccgateway/internal/ccevent"
+	. "ccgateway/internal/webhook"
+)
+
+func ptr[T any](v T) *T { return &v }
+
+func waitStatus(t *testing.T, d *Dispatcher, id, status string) Delivery {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, del := range d.Deliveries(DeliveryFilter{}) {
+			if del.ID == id && del.Status == status {
+				return del
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("delivery %s never reached %s: %+v", id, status, d.Deliveries(DeliveryFilter{}))
+	return Delivery{}
+}
+
+func TestDispatchSignsMatchingEvents(t *testing.T) {
+	var mu sync.Mutex
+	var got []ccevent.Event
+	var verified bool
+	var secret string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var ev ccevent.Event
+		_ = json.Unmarshal(body, &ev)
+		mu.Lock()
+		got = append(got, ev)
+		verified = Verify(secret, r.Header.Get(HeaderSignature), body, time.Minute) && r.Header.Get(HeaderEvent) == ev.EventType
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	d, err := NewDispatcher(Config{})
+	if err != nil {
+		t.Fatalf("new dispatcher: %v", err)
+	}
+	sub, err := d.Create(Input{URL: ptr(srv.URL), Events: ptr([]string{"run.failed", "scheduler.*"})})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	secret = sub.Secret
+	if secret == "" || d.List()[0].Secret != "" {
+		t.Fatalf("expected the secret on create only")
+	}
+
+	if out := d.Dispatch(context.Background(), ccevent.Event{ID: "evt_1", EventType: "run.completed"}); len(out) != 0 {
+		t.Fatalf("expected run.completed to match nothing, got %+v", out)
+	}
+	out := d.Dispatch(context.Background(), ccevent.Event{ID: "evt_2", EventType: "scheduler.adapter_down", Data: map[string]any{"adapter": "a1"}})
+	if len(out) != 1 {
+		t.Fatalf("expected one delivery, got %+v", out)
+	}
+	del := waitStatus(t, d, out[0].ID, StatusSucceeded)
+	if del.Attempts != 1 || del.LastStatusCode != http.StatusOK {
+		t.Fatalf("unexpected delivery: %+v", del)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(got) != 1 || got[0].ID != "evt_2" || !verified {
+		t.Fatalf("expected one verified delivery of evt_2, got %+v verified=%v", got, verified)
+	}
+}
+
+func TestDeliveryRetriesServerErrorsButNotClientErrors(t *testing.T) {
+	var calls atomic.Int32
+	flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer flaky.Close()
+	var rejected atomic.Int32
+	gone := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rejected.Add(1)
+		w.WriteHeader(http.StatusGone)
+	}))
+	defer gone.Close()
+
+	d, _ := NewDispatcher(Config{Backoff: time.Millisecond, MaxAttempts: 4})
+	flakySub, _ := d.Create(Input{URL: ptr(flaky.URL), Events: ptr([]string{"*"})})
+	goneSub, _ := d.Create(Input{URL: ptr(gone.URL), Events: ptr([]string{"*"})})
+	d.Dispatch(context.Background(), ccevent.Event{ID: "evt_1", EventType: "quota.threshold_reached"})
+	d.Wait()
+
+	ok := d.Deliveries(DeliveryFilter{SubscriptionID: flakySub.ID})
+	if len(ok) != 1 || ok[0].Status != StatusSucceeded || ok[0].Attempts != 3 {
+		t.Fatalf("expected success on the third attempt, got %+v", ok)
+	}
+	failed := d.Deliveries(DeliveryFilter{SubscriptionID: goneSub.ID})
+	if len(failed) != 1 || failed[0].Status != StatusFailed || failed[0].Attempts != 1 || rejected.Load() != 1 {
+		t.Fatalf("expected a 410 to fail without retrying, got %+v", failed)
+	}
+}
+
+func TestSubscriptionsPersistAcrossRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "webhooks.json")
+	d, err := NewDispatcher(Config{Path: path})
+	if err != nil {
+		t.Fatalf("new dispatcher: %v", err)
+	}
+	sub, err := d.Create(Input{URL: ptr("https://hooks.example.com/cc"), Events: ptr([]string{"tool.gap_detected"})})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, err := d.Update(sub.ID, Input{Enabled: ptr(false)}); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	reloaded, err := NewDispatcher(Config{Path: path})
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	got, ok := reloaded.Get(sub.ID)
+	if !ok || got.Enabled || got.URL != "https://hooks.example.com/cc" {
+		t.Fatalf("expected the disabled subscription back, got %+v ok=%v", got, ok)
+	}
+	if _, err := reloaded.Create(Input{URL: ptr("ftp://nope"), Events: ptr([]string{"*"})}); err != ErrInvalidURL {
+		t.Fatalf("expected ErrInvalidURL, got %v", err)
+	}
+}
@@ -0,0 +1,131 @@
+package webhook_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"ccgateway/internal/ccevent"
+	. "ccgateway/internal/webhook"
+)
+
+// chatServer records request bodies and query strings and answers with
+// reply.
+type chatServer struct {
+	mu      sync.Mutex
+	bodies  []map[string]any
+	queries []string
+	reply   string
+}
+
+func (c *chatServer) start(t *testing.T) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		var body map[string]any
+		_ = json.Unmarshal(raw, &body)
+		c.mu.Lock()
+		c.bodies = append(c.bodies, body)
+		c.queries = append(c.queries, r.URL.RawQuery)
+		c.mu.Unlock()
+		_, _ = io.WriteString(w, c.reply)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+var adapterDown = ccevent.Event{
+	ID:        "evt_1",
+	EventType: "scheduler.adapter_down",
+	Data:      map[string]any{"adapter": "glm", "consecutive_failures": 3, "last_error": "timeout"},
+}
+
+func TestSlackFormatRendersBuiltInTemplate(t *testing.T) {
+	chat := &chatServer{reply: "ok"}
+	srv := chat.start(t)
+	d, _ := NewDispatcher(Config{})
+	sub, err := d.Create(Input{URL: ptr(srv.URL), Events: ptr([]string{"scheduler.*"}), Format: ptr("slack")})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if sub.Secret != "" {
+		t.Fatalf("expected no generated secret for chat formats")
+	}
+	d.Dispatch(context.Background(), adapterDown)
+	d.Wait()
+	if len(chat.bodies) != 1 || chat.bodies[0]["text"] != "[cc-gateway] Adapter down: glm after 3 failures: timeout" {
+		t.Fatalf("unexpected slack payload: %+v", chat.bodies)
+	}
+}
+
+func TestDingTalkSignsQueryAndReportsErrcode(t *testing.T) {
+	chat := &chatServer{reply: `{"errcode":310000,"errmsg":"sign not match"}`}
+	srv := chat.start(t)
+	d, _ := NewDispatcher(Config{})
+	sub, _ := d.Create(Input{
+		URL:      ptr(srv.URL + "/robot/send?access_token=abc"),
+		Events:   ptr([]string{"*"}),
+		Format:   ptr("dingtalk"),
+		Secret:   ptr("SECdemo"),
+		Template: ptr("{{.EventType}} on {{.Data.adapter}}"),
+	})
+	d.Dispatch(context.Background(), adapterDown)
+	d.Wait()
+
+	q := chat.queries[0]
+	if !strings.Contains(q, "access_token=abc") || !strings.Contains(q, "timestamp=") || !strings.Contains(q, "sign=") {
+		t.Fatalf("expected the signed robot URL, got %s", q)
+	}
+	md, _ := chat.bodies[0]["markdown"].(map[string]any)
+	if chat.bodies[0]["msgtype"] != "markdown" || md["text"] != "scheduler.adapter_down on glm" {
+		t.Fatalf("unexpected dingtalk payload: %+v", chat.bodies[0])
+	}
+	got := d.Deliveries(DeliveryFilter{SubscriptionID: sub.ID})
+	if len(got) != 1 || got[0].Status != StatusFailed || got[0].Attempts != 1 || !strings.Contains(got[0].LastError, "310000") {
+		t.Fatalf("expected a non-retried failure carrying the errcode, got %+v", got)
+	}
+}
+
+func TestFeishuSignsBodyAndRateLimits(t *testing.T) {
+	chat := &chatServer{reply: `{"code":0}`}
+	srv := chat.start(t)
+	d, _ := NewDispatcher(Config{})
+	sub, _ := d.Create(Input{
+		URL:       ptr(srv.URL),
+		Events:    ptr([]string{"probe.failed"}),
+		Format:    ptr("feishu"),
+		Secret:    ptr("feishu-secret"),
+		RateLimit: ptr(2),
+	})
+	ev := ccevent.Event{EventType: "probe.failed", Data: map[string]any{"adapter": "a1", "model": "m1", "check": "probe", "error": "503"}}
+	for i := 0; i < 3; i++ {
+		d.Dispatch(context.Background(), ev)
+	}
+	d.Wait()
+
+	if len(chat.bodies) != 2 {
+		t.Fatalf("expected two messages under the limit, got %d", len(chat.bodies))
+	}
+	body := chat.bodies[0]
+	content, _ := body["content"].(map[string]any)
+	if body["msg_type"] != "text" || body["sign"] == nil || body["timestamp"] == nil || content["text"] != "[cc-gateway] Probe failed: a1 / m1 (probe): 503" {
+		t.Fatalf("unexpected feishu payload: %+v", body)
+	}
+	if got := d.Deliveries(DeliveryFilter{SubscriptionID: sub.ID, Status: StatusSuppressed}); len(got) != 1 {
+		t.Fatalf("expected one suppressed delivery, got %+v", got)
+	}
+}
+
+func TestInvalidFormatAndTemplateAreRejected(t *testing.T) {
+	d, _ := NewDispatcher(Config{})
+	if _, err := d.Create(Input{URL: ptr("https://x.example"), Events: ptr([]string{"*"}), Format: ptr("teams")}); err != ErrInvalidFormat {
+		t.Fatalf("expected ErrInvalidFormat, got %v", err)
+	}
+	if _, err := d.Create(Input{URL: ptr("https://x.example"), Events: ptr([]string{"*"}), Format: ptr("slack"), Template: ptr("{{.Broken")}); err == nil || !strings.Contains(err.Error(), ErrInvalidTemplate.Error()) {
+		t.Fatalf("expected ErrInvalidTemplate, got %v", err)
+	}
+}
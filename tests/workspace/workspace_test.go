@@ -0,0 +1,122 @@
+package workspace_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "ccgateway/internal/workspace"
+)
+
+func newManager(t *testing.T, cfg Config) *Manager {
+	t.Helper()
+	if cfg.Root == "" {
+		cfg.Root = t.TempDir()
+	}
+	m, err := New(cfg)
+	if err != nil {
+		t.Fatalf("new workspace: %v", err)
+	}
+	return m
+}
+
+func TestManagerSandboxesSessionPaths(t *testing.T) {
+	m := newManager(t, Config{})
+	if err := m.Write("s1", "/src/a.txt", []byte("one")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if _, err := m.Read("s2", "/src/a.txt"); err == nil {
+		t.Fatalf("expected sessions not to see each other's files")
+	}
+	if got, err := m.Read("s1", "../../src/a.txt"); err != nil || string(got) != "one" {
+		t.Fatalf("expected dot-dot to stay inside the session, got %q %v", got, err)
+	}
+	if err := m.Write("a/b", "/x.txt", []byte("slash")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if _, err := m.Read("a_b", "/x.txt"); err == nil {
+		t.Fatalf("expected ids that sanitize alike to stay isolated")
+	}
+	if a, b := mustDir(t, m, "a/b"), mustDir(t, m, "a_b"); a == b {
+		t.Fatalf("expected distinct directories, both got %s", a)
+	}
+	dir, _ := m.Dir("s1")
+	if err := os.Symlink("/etc", filepath.Join(dir, "etc")); err != nil {
+		t.Fatalf("symlink: %v", err)
+	}
+	if _, err := m.Read("s1", "/etc/hostname"); !errors.Is(err, ErrOutsideWorkspace) {
+		t.Fatalf("expected a symlink escape rejected, got %v", err)
+	}
+
+	entries, err := m.List("s1", "/")
+	if err != nil || len(entries) != 2 || entries[0].Path != "/src" || !entries[0].IsDir {
+		t.Fatalf("unexpected listing %#v %v", entries, err)
+	}
+
+	if err := m.Release("s1"); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Fatalf("expected the session directory removed, got %v", err)
+	}
+}
+
+func TestManagerEnforcesQuota(t *testing.T) {
+	m := newManager(t, Config{QuotaBytes: 10, MaxFileBytes: 8})
+	if err := m.Write("s", "a", []byte("123456789")); !errors.Is(err, ErrFileTooLarge) {
+		t.Fatalf("expected the file limit, got %v", err)
+	}
+	if err := m.Write("s", "a", []byte("123456")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := m.Write("s", "b", []byte("12345")); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("expected the quota, got %v", err)
+	}
+	if err := m.Write("s", "a", []byte("12345678")); err != nil {
+		t.Fatalf("expected overwriting to count only the new size: %v", err)
+	}
+	if used, _ := m.Usage("s"); used != 8 {
+		t.Fatalf("expected 8 bytes used, got %d", used)
+	}
+}
+
+func TestManagerDiffsSessionChanges(t *testing.T) {
+	m := newManager(t, Config{})
+	dir, _ := m.Dir("s")
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n\nfunc main() {\n}\n"), 0o644); err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	if diff, _ := m.Diff("s", ""); diff != "" {
+		t.Fatalf("expected no changes yet, got %q", diff)
+	}
+	if err := m.Write("s", "/main.go", []byte("package main\n\nfunc main() {\n\trun()\n}\n")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := m.Write("s", "/notes.md", []byte("todo\n")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	diff, err := m.Diff("s", "")
+	if err != nil {
+		t.Fatalf("diff: %v", err)
+	}
+	want := "--- a/main.go\n+++ b/main.go\n@@ -1,4 +1,5 @@\n package main\n \n func main() {\n+\trun()\n }\n" +
+		"--- /dev/null\n+++ b/notes.md\n@@ -0,0 +1,1 @@\n+todo\n"
+	if diff != want {
+		t.Fatalf("unexpected diff:\n%s", diff)
+	}
+	if one, _ := m.Diff("s", "/notes.md"); !strings.HasPrefix(one, "--- /dev/null") || strings.Contains(one, "main.go") {
+		t.Fatalf("expected a single-file diff, got:\n%s", one)
+	}
+}
+
+func mustDir(t *testing.T, m *Manager, sessionID string) string {
+	t.Helper()
+	dir, err := m.Dir(sessionID)
+	if err != nil {
+		t.Fatalf("dir %q: %v", sessionID, err)
+	}
+	return dir
+}
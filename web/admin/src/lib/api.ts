@@ -102,6 +102,56 @@ export async function apiRequest<T = any>(path: string, opts: APIOptions = {}):
   return (await resp.text()) as T;
 }
 
+export type StreamMessage = {
+  id: string;
+  event: string;
+  data: string;
+};
+
+// apiStream reads a Server-Sent Events response with the admin headers
+// EventSource cannot send. It resolves when the stream ends or signal aborts.
+export async function apiStream(path: string, onMessage: (msg: StreamMessage) => void, signal?: AbortSignal, lastEventID = ""): Promise<void> {
+  const token = (localStorage.getItem(TOKEN_STORAGE_KEY) || "").trim();
+  const headers = new Headers({ Accept: "text/event-stream" });
+  if (token) {
+    headers.set("x-admin-token", token);
+    headers.set("Authorization", `Bearer ${token}`);
+  }
+  if (lastEventID) {
+    headers.set("Last-Event-ID", lastEventID);
+  }
+  const resp = await fetch(resolveRequestPath(path), { headers, signal });
+  if (!resp.ok || !resp.body) {
+    const text = (await resp.text()).trim();
+    throw new Error(text || `HTTP ${resp.status}`);
+  }
+  const reader = resp.body.getReader();
+  const decoder = new TextDecoder();
+  let buffer = "";
+  for (;;) {
+    const { value, done } = await reader.read();
+    if (done) {
+      return;
+    }
+    buffer += decoder.decode(value, { stream: true });
+    let sep = buffer.indexOf("\n\n");
+    while (sep >= 0) {
+      const block = buffer.slice(0, sep);
+      buffer = buffer.slice(sep + 2);
+      const msg: StreamMessage = { id: "", event: "message", data: "" };
+      for (const line of block.split("\n")) {
+        if (line.startsWith("id: ")) msg.id = line.slice(4);
+        else if (line.startsWith("event: ")) msg.event = line.slice(7);
+        else if (line.startsWith("data: ")) msg.data += line.slice(6);
+      }
+      if (msg.data) {
+        onMessage(msg);
+      }
+      sep = buffer.indexOf("\n\n");
+    }
+  }
+}
+
 export function encodeKey(v: string): string {
   return encodeURIComponent(v || "");
 }